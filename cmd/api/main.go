@@ -1,31 +1,133 @@
 package main
 
 import (
+	"context"
 	"log"
 	"online-shop/internal/application/commands"
+	"online-shop/internal/application/pipeline"
 	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/analytics"
+	"online-shop/internal/domain/backup"
+	"online-shop/internal/domain/badge"
+	"online-shop/internal/domain/campaign"
+	"online-shop/internal/domain/cms"
+	"online-shop/internal/domain/cod"
+	"online-shop/internal/domain/draftorder"
+	"online-shop/internal/domain/emaildelivery"
+	"online-shop/internal/domain/emailtemplate"
+	"online-shop/internal/domain/export"
+	"online-shop/internal/domain/forecast"
+	"online-shop/internal/domain/fraud"
+	"online-shop/internal/domain/job"
+	"online-shop/internal/domain/notification"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/ratelimit"
+	"online-shop/internal/domain/reconciliation"
+	"online-shop/internal/domain/searchsync"
+	"online-shop/internal/domain/shipping"
+	"online-shop/internal/domain/pricealert"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/stockalert"
+	"online-shop/internal/domain/storefront"
+	"online-shop/internal/domain/user"
+	"online-shop/internal/domain/webhook"
+	"online-shop/internal/domain/whatsapp"
 	"online-shop/internal/infrastructure/database"
 	"online-shop/internal/infrastructure/elasticsearch"
+	"online-shop/internal/infrastructure/eventstore"
+	emailprovider "online-shop/internal/infrastructure/emaildelivery"
+	"online-shop/internal/infrastructure/geocoding"
+	"online-shop/internal/infrastructure/logstore"
+	"online-shop/internal/infrastructure/oauth"
 	"online-shop/internal/infrastructure/payment"
+	"online-shop/internal/infrastructure/queue"
 	"online-shop/internal/infrastructure/redis"
+	"online-shop/internal/infrastructure/sitemap"
+	"online-shop/internal/infrastructure/spreadsheet"
+	"online-shop/internal/infrastructure/storage"
+	webhookprovider "online-shop/internal/infrastructure/webhook"
+	whatsappprovider "online-shop/internal/infrastructure/whatsapp"
 	"online-shop/internal/interfaces/http/handlers"
 	"online-shop/internal/interfaces/http/middleware"
+	"online-shop/pkg/apperror"
 	"online-shop/pkg/config"
+	"online-shop/pkg/fieldcrypt"
+	"online-shop/pkg/i18n"
+	"online-shop/pkg/id"
 	"online-shop/pkg/jwt"
 	"online-shop/pkg/logger"
+	"online-shop/pkg/scheduler"
+	"online-shop/pkg/secrets"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
 func main() {
+	// Load configuration; until it is, entries use the default logger config
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Fatal("Failed to load config: ", err)
+	}
+
 	// Initialize logger
-	logger.Init()
+	if err := logger.Init(&cfg.Logger); err != nil {
+		logger.Fatal("Failed to initialize logger: ", err)
+	}
+	defer logger.Sync()
+	logger.RotateOn(syscall.SIGHUP)
 	log := logger.GetLogger()
+	zapLogger := logger.Zap()
 
-	// Load configuration
-	cfg, err := config.LoadConfig()
+	// Resolve secrets referenced from the config; raw keeps the references
+	// so rotated values can be watched
+	secretsManager, err := secrets.New(&cfg.Secrets)
 	if err != nil {
-		log.Fatal("Failed to load config: ", err)
+		log.Fatal("Failed to initialize secrets provider: ", err)
+	}
+	raw := *cfg
+	if err := secretsManager.ResolveConfig(context.Background(), cfg); err != nil {
+		log.Fatal("Failed to resolve secrets: ", err)
+	}
+
+	// Watch the config files; reloadable fields apply without a restart
+	configWatcher, err := config.NewWatcher(cfg)
+	if err != nil {
+		log.Fatal("Failed to watch config: ", err)
+	}
+	configWatcher.OnChange(func(next *config.Config) {
+		if err := logger.SetLevel(next.Logger.Level); err != nil {
+			log.Error("Invalid log level: ", err)
+		}
+	})
+	if err := configWatcher.Start(func(result *config.ReloadResult, err error) {
+		if err != nil {
+			log.Error("Config reload rejected: ", err)
+			return
+		}
+		if len(result.Restart) > 0 {
+			log.Warn("Config changes need a restart to apply: ", strings.Join(result.Restart, ", "))
+		}
+		if result.Applied {
+			log.Info("Config reloaded")
+		}
+	}); err != nil {
+		log.Warn("Failed to watch config files, hot reload disabled: ", err)
+	}
+	defer configWatcher.Stop()
+
+	// Initialize column encryption
+	if err := fieldcrypt.Configure(&cfg.Encryption); err != nil {
+		log.Fatal("Failed to initialize column encryption: ", err)
+	}
+
+	// Initialize ID generation
+	if err := id.SetNode(uint16(cfg.ID.NodeID)); err != nil {
+		log.Fatal("Failed to initialize ID generation: ", err)
 	}
 
 	// Initialize database
@@ -39,9 +141,22 @@ func main() {
 		log.Fatal("Failed to run migrations: ", err)
 	}
 
+	// Product and category writes record the changes the search index and
+	// caches follow, in the same transaction
+	if cfg.SearchSync.Outbox() {
+		if err := db.DB.Use(database.NewChangeCapturePlugin(database.SearchSyncedTables...)); err != nil {
+			log.Fatal("Failed to capture search changes: ", err)
+		}
+	}
+
+	// Commands and queries are validated, logged, timed and retried, and
+	// each command runs in a transaction
+	pipeline.Use(pipeline.Standard(&cfg.Pipeline, zapLogger, database.NewTransactor(db.DB)))
+
 	// Initialize Redis
 	redisClient := redis.NewClient(&cfg.Redis)
 	cacheService := redis.NewCacheService(redisClient)
+	cacheInvalidator := redis.NewCacheInvalidator(redisClient)
 
 	// Initialize Elasticsearch
 	esClient, err := elasticsearch.NewClient(&cfg.Elasticsearch)
@@ -54,36 +169,377 @@ func main() {
 	if err := searchService.CreateIndex(nil); err != nil {
 		log.Warn("Failed to create Elasticsearch index: ", err)
 	}
+	if err := searchService.CreateLocaleIndices(nil, i18n.TranslatedLocales()); err != nil {
+		log.Warn("Failed to create localized Elasticsearch indices: ", err)
+	}
+
+	// Analytics reports and the inventory forecast read the event store,
+	// when events are kept in one
+	var eventsDB *database.Database
+	if cfg.Analytics.Sink == "timescale" {
+		if eventsDB, err = database.NewDatabase(&cfg.Analytics.Database); err != nil {
+			log.Warn("Failed to connect to analytics database, reports and inventory forecast disabled: ", err)
+		} else {
+			defer eventsDB.Close()
+		}
+	}
 
 	// Initialize repositories
 	userRepo := database.NewUserRepository(db.DB)
 	productRepo := database.NewProductRepository(db.DB)
 	categoryRepo := database.NewCategoryRepository(db.DB)
+	slugRedirectRepo := database.NewSlugRedirectRepository(db.DB)
+	attributeTemplateRepo := database.NewAttributeTemplateRepository(db.DB)
 	orderRepo := database.NewOrderRepository(db.DB)
 	paymentRepo := database.NewPaymentRepository(db.DB)
+	commissionRepo := database.NewCommissionRepository(db.DB)
+	warehouseRepo := database.NewWarehouseRepository(db.DB)
+	stockRepo := database.NewStockRepository(db.DB)
+	recommendationRepo := database.NewRecommendationRepository(db.DB)
+	bannerRepo := database.NewBannerRepository(db.DB)
+	dashboardRepo := database.NewDashboardRepository(db.DB)
+	auditRepo := database.NewAuditRepository(db.DB)
+	backupRepo := database.NewBackupRepository(db.DB)
+	reconciliationRepo := database.NewReconciliationRepository(db.DB)
+	fraudRepo := database.NewFraudRepository(db.DB)
+	codRepo := database.NewCODRepository(db.DB)
+	codLimitRepo := database.NewCODLimitRepository(db.DB)
+	stockAlertRepo := database.NewStockAlertRepository(db.DB)
+	priceAlertRepo := database.NewPriceAlertRepository(db.DB)
+	priceHistoryRepo := database.NewPriceHistoryRepository(db.DB)
+	storefrontSettingsRepo := database.NewStorefrontSettingsRepository(db.DB)
+	storefrontSettingsCache := redis.NewStorefrontSettingsCache(redisClient)
+	storefrontDefaults := storefront.Defaults(cfg.Storefront.StoreName, cfg.Storefront.Currency, cfg.Orders.NumberPrefix, i18n.Locales(), i18n.DefaultLocale)
+	getStorefrontSettingsHandler := queries.NewGetStorefrontSettingsQueryHandler(storefrontSettingsRepo, storefrontSettingsCache, storefrontDefaults, cfg.Storefront.CacheTTL())
+	// Order numbers take the prefix the admins set in the storefront settings
+	orderNumbers := database.NewOrderNumberSequence(db.DB, cfg.Orders.NumberPrefix, cfg.Orders.NumberDigits, getStorefrontSettingsHandler)
+	oauthAccountRepo := database.NewOAuthAccountRepository(db.DB)
+	userErasureRepo := database.NewUserErasureRepository(db.DB)
+	exportRepo := database.NewExportRepository(db.DB)
+	experimentRepo := database.NewExperimentRepository(db.DB)
+	emailSuppressionRepo := database.NewEmailSuppressionRepository(db.DB)
+	whatsAppTemplateRepo := database.NewWhatsAppTemplateRepository(db.DB)
+	cmsBlockRepo := database.NewCMSBlockRepository(db.DB)
+	cmsPageRepo := database.NewCMSPageRepository(db.DB)
+	cmsAssetRepo := database.NewCMSAssetRepository(db.DB)
+	flashSaleRepo := database.NewFlashSaleRepository(db.DB)
+	paymentMethodRepo := database.NewPaymentMethodRepository(db.DB)
+	receiptRepo := database.NewReceiptRepository(db.DB)
+	emailTemplateRepo := database.NewEmailTemplateRepository(db.DB)
+	whatsAppMessageRepo := database.NewWhatsAppMessageRepository(db.DB)
+	webhookEndpointRepo := database.NewWebhookEndpointRepository(db.DB)
+	webhookDeliveryRepo := database.NewWebhookDeliveryRepository(db.DB)
+	impersonationRepo := database.NewImpersonationRepository(db.DB)
+	merchandisingRuleRepo := database.NewMerchandisingRuleRepository(db.DB)
+	badgeRepo := database.NewBadgeRepository(db.DB)
+	forecastRepo := database.NewForecastRepository(db.DB)
+	moderationRepo := database.NewModerationRepository(db.DB)
+	productVersionRepo := database.NewProductVersionRepository(db.DB)
+	emailDeadLetterRepo := database.NewEmailDeadLetterRepository(db.DB)
+	consentRepo := database.NewConsentRepository(db.DB)
+	jobRepo := database.NewJobRepository(db.DB)
+
+	// Initialize idempotency store
+	idempotencyStore := redis.NewIdempotencyStore(redisClient)
+	if cfg.Idempotency.Store == "postgres" {
+		idempotencyStore = database.NewIdempotencyRepository(db.DB)
+	}
+
+	// Initialize product analytics stores
+	trendingStore := redis.NewTrendingStore(redisClient)
+	recentlyViewedStore := redis.NewRecentlyViewedStore(redisClient)
+	dashboardStatsStore := redis.NewDashboardStatsStore(redisClient)
+	maintenanceStore := redis.NewMaintenanceStore(redisClient)
+	flashSaleCounter := redis.NewFlashSaleCounter(redisClient)
+
+	// Initialize analytics publisher
+	var analyticsPublisher analytics.Publisher = analytics.NopPublisher{}
+	var deletionPublisher user.DeletionPublisher = user.NopDeletionPublisher{}
+	var exportPublisher export.Publisher = export.UnavailablePublisher{}
+	var stockAlertNotifier stockalert.Notifier = stockalert.UnavailableNotifier{}
+	var priceAlertNotifier pricealert.Notifier = pricealert.UnavailableNotifier{}
+	var emailPublisher emaildelivery.Publisher = emaildelivery.UnavailablePublisher{}
+	var emailTemplatePublisher emailtemplate.Publisher = emailtemplate.UnavailablePublisher{}
+	var jobPublisher job.Publisher = job.UnavailablePublisher{}
+	// Draft order links cannot be emailed without the queue
+	var draftOrderNotifier draftorder.Notifier
+	rabbitmq, err := queue.NewRabbitMQ(cfg, zapLogger)
+	if err != nil {
+		log.Warn("Failed to connect to RabbitMQ, queued work disabled: ", err)
+		rabbitmq = nil
+	} else {
+		defer rabbitmq.Close()
+		deletionPublisher = queue.NewAccountPublisher(rabbitmq)
+		exportPublisher = queue.NewExportPublisher(rabbitmq)
+		stockAlertNotifier = queue.NewStockAlertPublisher(rabbitmq)
+		priceAlertNotifier = queue.NewPriceAlertPublisher(rabbitmq)
+		emailPublisher = queue.NewEmailDeliveryPublisher(rabbitmq)
+		emailTemplatePublisher = queue.NewEmailTemplatePublisher(rabbitmq)
+		jobPublisher = queue.NewJobPublisher(rabbitmq)
+		draftOrderNotifier = queue.NewDraftOrderPublisher(rabbitmq)
+	}
+	// Analytics events may go over Kafka instead, so they don't depend on
+	// RabbitMQ being connected
+	if eventBroker, closeBroker, err := queue.NewEventBroker(cfg, rabbitmq, zapLogger); err != nil {
+		log.Warn("Event broker unavailable, analytics events disabled: ", err)
+	} else {
+		defer closeBroker()
+		analyticsPublisher = queue.NewAnalyticsPublisher(eventBroker)
+	}
 
 	// Initialize payment provider
 	midtransProvider := payment.NewMidtransProvider(&cfg.Midtrans)
 	paymentService := payment.NewPaymentService(midtransProvider, paymentRepo)
 
+	// Initialize OAuth providers
+	oauthProviders, err := oauth.Providers(&cfg.OAuth)
+	if err != nil {
+		log.Fatal("Failed to initialize OAuth providers: ", err)
+	}
+
+	// Initialize email provider webhooks
+	emailWebhooks, err := emailprovider.Webhooks(&cfg.Email)
+	if err != nil {
+		log.Fatal("Failed to initialize email webhooks: ", err)
+	}
+
+	// Initialize the WhatsApp Cloud API
+	var whatsAppWebhook whatsapp.Webhook = whatsapp.DisabledClient{}
+	var whatsAppClient whatsapp.Client = whatsapp.DisabledClient{}
+	if cfg.WhatsApp.Enabled {
+		cloudAPI := whatsappprovider.NewCloudAPI(&cfg.WhatsApp)
+		whatsAppWebhook, whatsAppClient = cloudAPI, cloudAPI
+	}
+	oauthStateStore := redis.NewOAuthStateStore(redisClient)
+	sessionStore := redis.NewSessionStore(redisClient)
+
 	// Initialize JWT manager
-	jwtManager := jwt.NewJWTManager(cfg.JWT.SecretKey, cfg.JWT.ExpiryHours)
+	jwtManager, err := jwt.NewJWTManager(&cfg.JWT)
+	if err != nil {
+		log.Fatal("Failed to initialize JWT manager: ", err)
+	}
+	secretsManager.Watch(raw.JWT.SecretKey, jwtManager.SetSecret)
+	secretsManager.Watch(raw.Midtrans.ServerKey, midtransProvider.SetServerKey)
 
 	// Initialize command handlers
 	registerHandler := commands.NewRegisterUserCommandHandler(userRepo)
 	loginHandler := commands.NewLoginUserCommandHandler(userRepo)
 	updateProfileHandler := commands.NewUpdateUserProfileCommandHandler(userRepo)
 	changePasswordHandler := commands.NewChangePasswordCommandHandler(userRepo)
-	createOrderHandler := commands.NewCreateOrderCommandHandler(orderRepo, productRepo)
-	cancelOrderHandler := commands.NewCancelOrderCommandHandler(orderRepo, productRepo)
+	startSessionHandler := commands.NewStartSessionCommandHandler(sessionStore, jwtManager.RefreshExpiry())
+	refreshSessionHandler := commands.NewRefreshSessionCommandHandler(sessionStore, jwtManager.RefreshExpiry())
+	revokeSessionHandler := commands.NewRevokeSessionCommandHandler(sessionStore)
+	revokeAllSessionsHandler := commands.NewRevokeAllSessionsCommandHandler(sessionStore)
+	requestAccountDeletionHandler := commands.NewRequestAccountDeletionCommandHandler(userRepo, sessionStore, deletionPublisher, cfg.AccountDeletion.GracePeriod())
+	eraseAccountsHandler := commands.NewEraseAccountsCommandHandler(userErasureRepo, deletionPublisher)
+	requestDataExportHandler := commands.NewRequestDataExportCommandHandler(exportRepo, exportPublisher)
+	startOAuthLoginHandler := commands.NewStartOAuthLoginCommandHandler(oauthProviders, oauthStateStore, cfg.OAuth.StateTTL())
+	completeOAuthLoginHandler := commands.NewCompleteOAuthLoginCommandHandler(oauthProviders, oauthStateStore, oauthAccountRepo, userRepo)
+	// Orders are scored for fraud at checkout; risky ones wait for review
+	// in the admin panel
+	var fraudScreener *commands.FraudScreener
+	if cfg.Fraud.Enabled {
+		fraudScreener = commands.NewFraudScreener(fraudRepo, userRepo, orderRepo, fraud.Rules{
+			ReviewScore:       cfg.Fraud.ReviewScore,
+			VelocityWindow:    cfg.Fraud.VelocityWindow(),
+			VelocityLimit:     cfg.Fraud.VelocityLimit,
+			HighValueAmount:   cfg.Fraud.HighValueAmount,
+			DisposableDomains: cfg.Fraud.DisposableDomains,
+		})
+	}
+	// Third parties are told of orders and products through the endpoints
+	// they register
+	var webhookPublisher *commands.WebhookPublisher
+	if cfg.Webhooks.Enabled {
+		webhookPublisher = commands.NewWebhookPublisher(webhookEndpointRepo, webhookDeliveryRepo)
+	}
+	// Orders are charged the rates of the shipping zone their address falls
+	// in, once the geocoder has found the address
+	geocoder, err := geocoding.New(&cfg.Shipping)
+	if err != nil {
+		log.Fatal("Failed to initialize geocoder: ", err)
+	}
+	shippingZoneRepo := database.NewShippingZoneRepository(db.DB)
+	// Delivery is estimated from the warehouses' processing times and the
+	// transit days of the zone rates, counting working days only
+	deliveryLocation, err := cfg.Shipping.Delivery.Location()
+	if err != nil {
+		log.Fatal("Failed to load delivery time zone: ", err)
+	}
+	deliverySchedule, err := shipping.NewSchedule(deliveryLocation, cfg.Shipping.Delivery.SkipWeekends, cfg.Shipping.Delivery.Holidays)
+	if err != nil {
+		log.Fatal("Failed to load delivery holidays: ", err)
+	}
+	deliveryProcessing := shipping.Processing{Days: cfg.Shipping.Delivery.ProcessingDays, CutoffHour: cfg.Shipping.Delivery.CutoffHour}
+	getDeliveryEstimateHandler := queries.NewGetDeliveryEstimateQueryHandler(productRepo, shippingZoneRepo, warehouseRepo, stockRepo, deliverySchedule, deliveryProcessing)
+	getShippingQuoteHandler := queries.NewGetShippingQuoteQueryHandler(shippingZoneRepo, geocoder, redis.NewLocationCache(redisClient), cfg.Shipping.CacheTTL(), getDeliveryEstimateHandler)
+	var confirmationNotifier order.ConfirmationNotifier
+	if rabbitmq != nil {
+		confirmationNotifier = queue.NewOrderConfirmationPublisher(rabbitmq)
+	}
+	orderConfirmer := commands.NewOrderConfirmer(warehouseRepo, userRepo, productRepo, confirmationNotifier, deliverySchedule, deliveryProcessing)
+	receiptSender := commands.NewReceiptSender(userRepo, paymentRepo, nil)
+	if rabbitmq != nil {
+		receiptSender = commands.NewReceiptSender(userRepo, paymentRepo, queue.NewReceiptPublisher(rabbitmq))
+	}
+	// Bundles count their stock from their components as orders take it
+	bundleRepo := database.NewBundleRepository(db.DB)
+	bundleStocker := commands.NewBundleStocker(productRepo, bundleRepo)
+	purchaseRules := commands.NewPurchaseRules(userRepo, database.NewPurchaseHistory(db.DB), cfg.Orders.MinOrderValue)
+	createOrderHandler := commands.NewCreateOrderCommandHandler(orderRepo, productRepo, commissionRepo, warehouseRepo, stockRepo, flashSaleRepo, flashSaleCounter, commands.CreateOrderOptions{
+		FraudScreener:  fraudScreener,
+		Numbers:        orderNumbers,
+		Webhooks:       webhookPublisher,
+		ShippingQuoter: getShippingQuoteHandler,
+		Bundles:        bundleStocker,
+		PurchaseRules:  purchaseRules,
+		GiftWrapFee:    cfg.Orders.GiftWrapFee,
+	})
+	cancellationPolicy := order.CancellationPolicy{
+		AfterShipment: cfg.Orders.Cancellation.AfterShipment,
+		FlatFee:       cfg.Orders.Cancellation.FlatFee,
+		FeeRate:       cfg.Orders.Cancellation.FeeRate,
+	}
+	cancelOrderHandler := commands.NewCancelOrderCommandHandler(orderRepo, productRepo, stockRepo, flashSaleCounter, paymentRepo, midtransProvider, cancellationPolicy, bundleStocker)
+	quoteRepo := database.NewQuoteRepository(db.DB)
+	requestQuoteHandler := commands.NewRequestQuoteCommandHandler(quoteRepo, productRepo, webhookPublisher, cfg.Quotes.MinQuantity)
+	cancelQuoteHandler := commands.NewCancelQuoteCommandHandler(quoteRepo)
+	acceptQuoteHandler := commands.NewAcceptQuoteCommandHandler(quoteRepo, createOrderHandler, webhookPublisher)
+	orgRepo := database.NewOrganizationRepository(db.DB)
+	translationRepo := database.NewTranslationRepository(db.DB)
+	purchaseApprovalRepo := database.NewPurchaseApprovalRepository(db.DB)
+	invoiceRepo := database.NewInvoiceRepository(db.DB)
+	payOnInvoiceHandler := commands.NewPayOnInvoiceCommandHandler(orderRepo, paymentRepo, orgRepo, purchaseApprovalRepo, invoiceRepo, orderConfirmer)
+	decidePurchaseHandler := commands.NewDecidePurchaseApprovalCommandHandler(orderRepo, paymentRepo, orgRepo, purchaseApprovalRepo, invoiceRepo, orderConfirmer)
+	addPaymentMethodHandler := commands.NewAddPaymentMethodCommandHandler(paymentMethodRepo, payment.ProviderMidtrans)
+	deletePaymentMethodHandler := commands.NewDeletePaymentMethodCommandHandler(paymentMethodRepo)
+	setDefaultPaymentMethodHandler := commands.NewSetDefaultPaymentMethodCommandHandler(paymentMethodRepo)
+	subscribeStockAlertHandler := commands.NewSubscribeStockAlertCommandHandler(stockAlertRepo, productRepo, cfg.StockAlerts.Expiry())
+	cancelStockAlertHandler := commands.NewCancelStockAlertCommandHandler(stockAlertRepo)
+	watchPriceHandler := commands.NewWatchPriceCommandHandler(priceAlertRepo, productRepo, cfg.PriceAlerts.Expiry())
+	cancelPriceAlertHandler := commands.NewCancelPriceAlertCommandHandler(priceAlertRepo)
+	oneClickCheckoutHandler := commands.NewOneClickCheckoutCommandHandler(paymentMethodRepo, paymentRepo, midtransProvider, orderRepo, createOrderHandler, cancelOrderHandler, orderConfirmer, receiptSender)
+	createOrderPaymentsHandler := commands.NewCreateOrderPaymentsCommandHandler(orderRepo, paymentRepo, paymentMethodRepo, midtransProvider, midtransProvider, orderConfirmer, receiptSender)
+	payOrderPaymentHandler := commands.NewPayOrderPaymentCommandHandler(orderRepo, paymentRepo, midtransProvider)
+	reconcileOrderPaymentsHandler := commands.NewReconcileOrderPaymentsCommandHandler(orderRepo, paymentRepo, orderConfirmer, receiptSender)
+	// Cash on delivery is offered within the configured limits
+	codLimits := cod.Limits{
+		MaxOrderAmount:       cfg.COD.MaxOrderAmount,
+		MaxOutstandingAmount: cfg.COD.MaxOutstandingAmount,
+		MaxOpenOrders:        cfg.COD.MaxOpenOrders,
+	}
+	var offeredCODLimits *cod.Limits
+	if cfg.COD.Enabled {
+		offeredCODLimits = &codLimits
+	}
+	payOnDeliveryHandler := commands.NewPayOnDeliveryCommandHandler(orderRepo, paymentRepo, codRepo, codLimitRepo, offeredCODLimits, orderConfirmer)
+	recordRemittanceHandler := commands.NewRecordRemittanceCommandHandler(codRepo)
+	setCODLimitHandler := commands.NewSetCODLimitCommandHandler(codLimitRepo, userRepo)
+	paymentService.OnStatusChange(func(ctx context.Context, orderID string) error {
+		return reconcileOrderPaymentsHandler.Handle(ctx, commands.ReconcileOrderPaymentsCommand{OrderID: orderID})
+	})
+	// Draft orders become orders once paid through their links
+	draftOrderRepo := database.NewDraftOrderRepository(db.DB)
+	payDraftOrderHandler := commands.NewPayDraftOrderCommandHandler(draftOrderRepo, paymentRepo, midtransProvider)
+	completeDraftOrderHandler := commands.NewCompleteDraftOrderCommandHandler(draftOrderRepo, orderRepo, paymentRepo, createOrderHandler, orderConfirmer, receiptSender)
+	paymentService.OnDraftPayment(func(ctx context.Context, paymentID string) error {
+		_, err := completeDraftOrderHandler.Handle(ctx, commands.CompleteDraftOrderCommand{PaymentID: paymentID})
+		return err
+	})
+	updateProductSlugHandler := commands.NewUpdateProductSlugCommandHandler(productRepo, slugRedirectRepo, webhookPublisher)
+	updateCategorySlugHandler := commands.NewUpdateCategorySlugCommandHandler(categoryRepo, slugRedirectRepo)
+	setProductFeaturedHandler := commands.NewSetProductFeaturedCommandHandler(productRepo)
+	setProductAttributesHandler := commands.NewSetProductAttributesCommandHandler(productRepo, attributeTemplateRepo, webhookPublisher)
+	setProductBundleHandler := commands.NewSetProductBundleCommandHandler(productRepo, bundleRepo, webhookPublisher)
+	setProductStatusHandler := commands.NewSetProductStatusCommandHandler(productRepo, webhookPublisher)
+	scheduleProductPublishHandler := commands.NewScheduleProductPublishCommandHandler(productRepo)
+	duplicateProductHandler := commands.NewDuplicateProductCommandHandler(productRepo)
+	setCategoryAttributesHandler := commands.NewSetCategoryAttributesCommandHandler(categoryRepo, attributeTemplateRepo)
+	refreshBoughtTogetherHandler := commands.NewRefreshBoughtTogetherCommandHandler(recommendationRepo)
+	refreshDashboardStatsHandler := commands.NewRefreshDashboardStatsCommandHandler(dashboardRepo, dashboardStatsStore)
+	trackExperimentHandler := commands.NewTrackExperimentCommandHandler(experimentRepo, analyticsPublisher)
+	ingestEmailEventsHandler := commands.NewIngestEmailEventsCommandHandler(emailWebhooks, emailSuppressionRepo)
+	campaignRepo := database.NewCampaignRepository(db.DB)
+	trackCampaignEventHandler := commands.NewTrackCampaignEventCommandHandler(campaignRepo, campaign.NewTracker(cfg.Campaigns.TrackingURL, cfg.Campaigns.TrackingSecret))
+	notificationPreferenceRepo := database.NewNotificationPreferenceRepository(db.DB)
+	unsubscribeLinks := notification.NewLinks(cfg.Notifications.BaseURL, cfg.Notifications.UnsubscribePageURL, cfg.Notifications.UnsubscribeSecret)
+	ingestWhatsAppWebhookHandler := commands.NewIngestWhatsAppWebhookCommandHandler(whatsAppWebhook, whatsAppTemplateRepo, whatsAppMessageRepo)
 
 	// Initialize query handlers
 	getUserProfileHandler := queries.NewGetUserProfileQueryHandler(userRepo)
+	listSessionsHandler := queries.NewListSessionsQueryHandler(sessionStore)
 	getProductHandler := queries.NewGetProductQueryHandler(productRepo)
-	searchProductsHandler := queries.NewSearchProductsQueryHandler(productRepo)
+	getProductBySlugHandler := queries.NewGetProductBySlugQueryHandler(productRepo, slugRedirectRepo)
+	searchRankingHandler := queries.NewGetSearchRankingQueryHandler(merchandisingRuleRepo)
+	searchProductsHandler := queries.NewSearchProductsQueryHandler(productRepo, searchRankingHandler)
+	getProductFacetsHandler := queries.NewGetProductFacetsQueryHandler(productRepo)
+	compareProductsHandler := queries.NewCompareProductsQueryHandler(productRepo)
+	listProductsHandler := queries.NewListProductsQueryHandler(productRepo)
 	listCategoriesHandler := queries.NewListCategoriesQueryHandler(categoryRepo)
+	getCategoryHandler := queries.NewGetCategoryQueryHandler(categoryRepo, slugRedirectRepo)
+	getProductsByCategoryHandler := queries.NewGetProductsByCategoryQueryHandler(getCategoryHandler, productRepo, searchRankingHandler)
+	getFeaturedProductsHandler := queries.NewGetFeaturedProductsQueryHandler(productRepo)
+	getTrendingProductsHandler := queries.NewGetTrendingProductsQueryHandler(trendingStore, productRepo)
+	getSimilarProductsHandler := queries.NewGetSimilarProductsQueryHandler(productRepo, searchService)
+	getBoughtTogetherHandler := queries.NewGetBoughtTogetherQueryHandler(recommendationRepo, productRepo)
+	getRecentlyViewedHandler := queries.NewGetRecentlyViewedQueryHandler(recentlyViewedStore, productRepo, cacheService)
+	getHomeFeedHandler := queries.NewGetHomeFeedQueryHandler(
+		bannerRepo,
+		getFeaturedProductsHandler,
+		getTrendingProductsHandler,
+		getRecentlyViewedHandler,
+		getSimilarProductsHandler,
+		getBoughtTogetherHandler,
+	)
+
+	// Storefront content; images are served through signed storage links
+	cmsCache := redis.NewCMSCache(redisClient)
+	var cmsStorage cms.Storage = cms.UnavailableStorage{}
+	if assetStore, err := storage.New(&cfg.Storage); err != nil {
+		log.Warn("Failed to initialize asset storage, CMS images unavailable: ", err)
+	} else {
+		cmsStorage = assetStore
+	}
+	getLiveBannersHandler := queries.NewGetLiveBannersQueryHandler(bannerRepo, cmsCache, cfg.CMS.CacheTTL())
+	getCMSSlotHandler := queries.NewGetCMSSlotQueryHandler(cmsBlockRepo, cmsCache, cfg.CMS.CacheTTL())
+	getPublishedPageHandler := queries.NewGetPublishedPageQueryHandler(cmsPageRepo, cmsCache, cfg.CMS.CacheTTL())
+	getCMSAssetLinkHandler := queries.NewGetCMSAssetLinkQueryHandler(cmsAssetRepo, cmsStorage, cmsCache, cfg.CMS.CacheTTL())
+	getFlashSaleOffersHandler := queries.NewGetFlashSaleOffersQueryHandler(flashSaleRepo, flashSaleCounter)
+	localizeCatalogHandler := queries.NewLocalizeCatalogQueryHandler(translationRepo)
+	getFlashSaleHandler := queries.NewGetFlashSaleQueryHandler(flashSaleRepo, productRepo, flashSaleCounter)
+	getCurrentFlashSalesHandler := queries.NewGetCurrentFlashSalesQueryHandler(flashSaleRepo, productRepo, flashSaleCounter)
+	listPaymentMethodsHandler := queries.NewListPaymentMethodsQueryHandler(paymentMethodRepo)
+	listStockAlertsHandler := queries.NewListStockAlertsQueryHandler(stockAlertRepo)
+	listPriceAlertsHandler := queries.NewListPriceAlertsQueryHandler(priceAlertRepo)
+	getOrderPaymentsHandler := queries.NewGetOrderPaymentsQueryHandler(paymentRepo)
+	getOrderInvoiceHandler := queries.NewGetOrderInvoiceQueryHandler(emailTemplateRepo, paymentRepo, userRepo, productRepo)
+	listReceiptsHandler := queries.NewListReceiptsQueryHandler(receiptRepo)
+	listCODCollectionsHandler := queries.NewListCODCollectionsQueryHandler(codRepo)
+	listCourierBalancesHandler := queries.NewListCourierBalancesQueryHandler(codRepo)
+	listRemittancesHandler := queries.NewListRemittancesQueryHandler(codRepo)
+	getRemittanceHandler := queries.NewGetRemittanceQueryHandler(codRepo)
+	getCODCustomerHandler := queries.NewGetCODCustomerQueryHandler(codRepo, codLimitRepo, codLimits)
 	getOrderHandler := queries.NewGetOrderQueryHandler(orderRepo)
 	getUserOrdersHandler := queries.NewGetUserOrdersQueryHandler(orderRepo)
+	trackOrderHandler := queries.NewTrackOrderQueryHandler(orderRepo, userRepo)
+	listOrdersHandler := queries.NewListOrdersQueryHandler(orderRepo, userRepo)
+	getCancellationReportHandler := queries.NewGetCancellationReportQueryHandler(orderRepo)
+	getAssignmentsHandler := queries.NewGetAssignmentsQueryHandler(experimentRepo)
+
+	cartStore := redis.NewCartStore(redisClient, cfg.Cart.SessionTTL(), cfg.Cart.UserTTL())
+	cartHandler := handlers.NewCartHandler(
+		queries.NewGetCartQueryHandler(cartStore, productRepo),
+		commands.NewAddToCartCommandHandler(cartStore, productRepo, purchaseRules),
+		commands.NewUpdateCartItemCommandHandler(cartStore, productRepo, purchaseRules),
+		commands.NewRemoveFromCartCommandHandler(cartStore),
+		commands.NewClearCartCommandHandler(cartStore),
+		commands.NewMergeCartCommandHandler(cartStore),
+		commands.NewCheckoutCartCommandHandler(cartStore, createOrderHandler),
+		commands.NewPreviewCheckoutCommandHandler(cartStore, createOrderHandler),
+		analyticsPublisher,
+		cfg.Cart.SessionTTL(),
+		cfg.Cart.SecureCookie,
+	)
 
 	// Initialize HTTP handlers
 	userHandler := handlers.NewUserHandler(
@@ -92,13 +548,59 @@ func main() {
 		updateProfileHandler,
 		changePasswordHandler,
 		getUserProfileHandler,
+		startSessionHandler,
+		refreshSessionHandler,
+		requestAccountDeletionHandler,
+		commands.NewVerifyUserAgeCommandHandler(userRepo),
+		jwtManager,
+		cartHandler,
+	)
+
+	sessionHandler := handlers.NewSessionHandler(
+		listSessionsHandler,
+		revokeSessionHandler,
+		revokeAllSessionsHandler,
+	)
+
+	jwksHandler := handlers.NewJWKSHandler(jwtManager)
+
+	dataExportHandler := handlers.NewDataExportHandler(requestDataExportHandler)
+
+	oauthHandler := handlers.NewOAuthHandler(
+		startOAuthLoginHandler,
+		completeOAuthLoginHandler,
+		startSessionHandler,
 		jwtManager,
+		cartHandler,
+		cfg.OAuth.SuccessRedirectURL,
 	)
 
 	productHandler := handlers.NewProductHandler(
 		getProductHandler,
+		getProductBySlugHandler,
 		searchProductsHandler,
 		listCategoriesHandler,
+		getCategoryHandler,
+		getProductsByCategoryHandler,
+		updateProductSlugHandler,
+		updateCategorySlugHandler,
+		getFeaturedProductsHandler,
+		getTrendingProductsHandler,
+		setProductFeaturedHandler,
+		getFlashSaleOffersHandler,
+		getProductFacetsHandler,
+		compareProductsHandler,
+		setProductAttributesHandler,
+		setCategoryAttributesHandler,
+		setProductBundleHandler,
+		setProductStatusHandler,
+		scheduleProductPublishHandler,
+		duplicateProductHandler,
+		commands.NewSetProductPurchaseLimitsCommandHandler(productRepo),
+		commands.NewSetCategoryMinimumAgeCommandHandler(categoryRepo),
+		localizeCatalogHandler,
+		analyticsPublisher,
+		cfg.SEO.SiteURL,
 	)
 
 	orderHandler := handlers.NewOrderHandler(
@@ -106,19 +608,575 @@ func main() {
 		cancelOrderHandler,
 		getOrderHandler,
 		getUserOrdersHandler,
+		trackOrderHandler,
+		listOrdersHandler,
+		getCancellationReportHandler,
+		analyticsPublisher,
+	)
+
+	// The v2 API runs the same queries and commands and answers with its own
+	// representations
+	productV2Handler := handlers.NewProductV2Handler(productHandler, listProductsHandler)
+	orderV2Handler := handlers.NewOrderV2Handler(orderHandler)
+
+	paymentMethodHandler := handlers.NewPaymentMethodHandler(
+		addPaymentMethodHandler,
+		deletePaymentMethodHandler,
+		setDefaultPaymentMethodHandler,
+		listPaymentMethodsHandler,
+		oneClickCheckoutHandler,
+		analyticsPublisher,
+	)
+
+	stockAlertHandler := handlers.NewStockAlertHandler(
+		subscribeStockAlertHandler,
+		cancelStockAlertHandler,
+		listStockAlertsHandler,
+	)
+
+	priceAlertHandler := handlers.NewPriceAlertHandler(
+		watchPriceHandler,
+		cancelPriceAlertHandler,
+		listPriceAlertsHandler,
+	)
+
+	quoteHandler := handlers.NewQuoteHandler(
+		requestQuoteHandler,
+		commands.NewOfferQuoteCommandHandler(quoteRepo, cfg.Quotes.Validity(), cfg.Quotes.MaxValidity()),
+		commands.NewDeclineQuoteCommandHandler(quoteRepo),
+		cancelQuoteHandler,
+		acceptQuoteHandler,
+		queries.NewListQuotesQueryHandler(quoteRepo),
+		queries.NewGetQuoteQueryHandler(quoteRepo),
 	)
 
+	organizationHandler := handlers.NewOrganizationHandler(
+		commands.NewCreateOrganizationCommandHandler(orgRepo, cfg.Organizations.PaymentTerms()),
+		commands.NewAddOrganizationMemberCommandHandler(orgRepo, userRepo, cfg.Organizations.Members()),
+		commands.NewUpdateOrganizationMemberCommandHandler(orgRepo),
+		commands.NewRemoveOrganizationMemberCommandHandler(orgRepo),
+		commands.NewSetApprovalChainCommandHandler(orgRepo),
+		commands.NewSetOrganizationCreditCommandHandler(orgRepo),
+		payOnInvoiceHandler,
+		decidePurchaseHandler,
+		commands.NewRecordInvoicePaymentCommandHandler(invoiceRepo, paymentRepo, orderRepo),
+		queries.NewGetOrganizationQueryHandler(orgRepo, invoiceRepo),
+		queries.NewListOrganizationsQueryHandler(orgRepo),
+		queries.NewListOrganizationMembersQueryHandler(orgRepo),
+		queries.NewListOrganizationOrdersQueryHandler(orgRepo, orderRepo),
+		queries.NewListPurchaseApprovalsQueryHandler(orgRepo, purchaseApprovalRepo),
+		queries.NewListInvoicesQueryHandler(orgRepo, invoiceRepo),
+	)
+
+	orderPaymentHandler := handlers.NewOrderPaymentHandler(
+		createOrderPaymentsHandler,
+		payOrderPaymentHandler,
+		getOrderHandler,
+		getOrderPaymentsHandler,
+		getOrderInvoiceHandler,
+		listReceiptsHandler,
+	)
+
+	codHandler := handlers.NewCODHandler(
+		payOnDeliveryHandler,
+		recordRemittanceHandler,
+		setCODLimitHandler,
+		listCODCollectionsHandler,
+		listCourierBalancesHandler,
+		listRemittancesHandler,
+		getRemittanceHandler,
+		getCODCustomerHandler,
+	)
+
+	recommendationHandler := handlers.NewRecommendationHandler(
+		getSimilarProductsHandler,
+		getBoughtTogetherHandler,
+		cfg.SEO.SiteURL,
+	)
+
+	recentlyViewedHandler := handlers.NewRecentlyViewedHandler(getRecentlyViewedHandler, cfg.SEO.SiteURL)
+
+	homeHandler := handlers.NewHomeHandler(getHomeFeedHandler, cfg.SEO.SiteURL)
+	contentHandler := handlers.NewContentHandler(getLiveBannersHandler, getCMSSlotHandler, getPublishedPageHandler, getCMSAssetLinkHandler)
+	updateStorefrontSettingsHandler := commands.NewUpdateStorefrontSettingsCommandHandler(storefrontSettingsRepo, storefrontSettingsCache, auditRepo)
+	storefrontHandler := handlers.NewStorefrontHandler(getStorefrontSettingsHandler, updateStorefrontSettingsHandler)
+	shippingHandler := handlers.NewShippingHandler(
+		getShippingQuoteHandler,
+		getDeliveryEstimateHandler,
+		queries.NewListShippingZonesQueryHandler(shippingZoneRepo),
+		commands.NewCreateShippingZoneCommandHandler(shippingZoneRepo),
+		commands.NewUpdateShippingZoneCommandHandler(shippingZoneRepo),
+		commands.NewDeleteShippingZoneCommandHandler(shippingZoneRepo),
+	)
+
+	sitemapHandler := handlers.NewSitemapHandler(cfg.SEO.SitemapDir)
+
+	flashSaleHandler := handlers.NewFlashSaleHandler(
+		commands.NewCreateFlashSaleCommandHandler(flashSaleRepo, productRepo),
+		commands.NewUpdateFlashSaleCommandHandler(flashSaleRepo, productRepo),
+		commands.NewDeleteFlashSaleCommandHandler(flashSaleRepo),
+		queries.NewListFlashSalesQueryHandler(flashSaleRepo),
+		getFlashSaleHandler,
+		getCurrentFlashSalesHandler,
+		cfg.SEO.SiteURL,
+	)
+
+	// Experiment reports count the events in the event store, so they are
+	// only served when there is one
+	var getExperimentReportHandler *queries.GetExperimentReportQueryHandler
+	if eventsDB != nil {
+		getExperimentReportHandler = queries.NewGetExperimentReportQueryHandler(experimentRepo, eventstore.NewTimescaleExperimentReports(eventsDB.DB))
+	}
+	experimentHandler := handlers.NewExperimentHandler(
+		commands.NewCreateExperimentCommandHandler(experimentRepo),
+		commands.NewUpdateExperimentCommandHandler(experimentRepo),
+		trackExperimentHandler,
+		queries.NewListExperimentsQueryHandler(experimentRepo),
+		getAssignmentsHandler,
+		getExperimentReportHandler,
+	)
+
+	emailDeliveryHandler := handlers.NewEmailDeliveryHandler(
+		ingestEmailEventsHandler,
+		commands.NewAddEmailSuppressionCommandHandler(emailSuppressionRepo),
+		commands.NewRemoveEmailSuppressionCommandHandler(emailSuppressionRepo),
+		queries.NewListEmailSuppressionsQueryHandler(emailSuppressionRepo),
+		queries.NewGetEmailSuppressionQueryHandler(emailSuppressionRepo),
+		commands.NewResendEmailDeadLetterCommandHandler(emailDeadLetterRepo, emailPublisher),
+		queries.NewListEmailDeadLettersQueryHandler(emailDeadLetterRepo),
+		queries.NewGetEmailDeadLetterQueryHandler(emailDeadLetterRepo),
+		commands.NewSendEmailCampaignCommandHandler(emailTemplateRepo, emailPublisher, consentRepo, cfg.Email.BatchSize),
+	)
+
+	draftOrderHandler := handlers.NewDraftOrderHandler(
+		commands.NewCreateDraftOrderCommandHandler(draftOrderRepo, userRepo, productRepo, createOrderHandler, auditRepo, cfg.DraftOrders.Validity()),
+		commands.NewSendDraftOrderCommandHandler(draftOrderRepo, userRepo, draftOrderNotifier, cfg.Notifications.BaseURL, cfg.DraftOrders.PaymentPageURL),
+		commands.NewCancelDraftOrderCommandHandler(draftOrderRepo),
+		payDraftOrderHandler,
+		queries.NewListDraftOrdersQueryHandler(draftOrderRepo),
+		queries.NewGetDraftOrderQueryHandler(draftOrderRepo),
+		queries.NewGetDraftOrderSummaryQueryHandler(draftOrderRepo, emailTemplateRepo),
+	)
+
+	audienceRepo := database.NewAudienceRepository(db.DB)
+	campaignHandler := handlers.NewCampaignHandler(
+		trackCampaignEventHandler,
+		queries.NewListCampaignsQueryHandler(campaignRepo),
+		queries.NewGetCampaignQueryHandler(campaignRepo),
+		queries.NewGetCampaignStatsQueryHandler(campaignRepo),
+		queries.NewCountCampaignAudienceQueryHandler(campaignRepo, audienceRepo),
+		commands.NewCreateCampaignCommandHandler(campaignRepo, emailTemplateRepo),
+		commands.NewUpdateCampaignCommandHandler(campaignRepo, emailTemplateRepo),
+		commands.NewScheduleCampaignCommandHandler(campaignRepo),
+		commands.NewCancelCampaignCommandHandler(campaignRepo),
+	)
+	notificationPreferenceHandler := handlers.NewNotificationPreferenceHandler(
+		queries.NewGetNotificationPreferencesQueryHandler(userRepo, notificationPreferenceRepo),
+		commands.NewUpdateNotificationPreferencesCommandHandler(userRepo, notificationPreferenceRepo),
+		queries.NewGetUnsubscribeQueryHandler(unsubscribeLinks),
+		commands.NewUnsubscribeCommandHandler(userRepo, notificationPreferenceRepo, unsubscribeLinks),
+	)
+	whatsAppHandler := handlers.NewWhatsAppHandler(
+		whatsAppWebhook,
+		ingestWhatsAppWebhookHandler,
+		commands.NewCreateWhatsAppTemplateCommandHandler(whatsAppClient, whatsAppTemplateRepo),
+		commands.NewDeleteWhatsAppTemplateCommandHandler(whatsAppClient, whatsAppTemplateRepo),
+		commands.NewSyncWhatsAppTemplatesCommandHandler(whatsAppClient, whatsAppTemplateRepo),
+		queries.NewListWhatsAppTemplatesQueryHandler(whatsAppTemplateRepo),
+		queries.NewGetWhatsAppTemplateQueryHandler(whatsAppTemplateRepo),
+		queries.NewListWhatsAppMessagesQueryHandler(whatsAppMessageRepo),
+		queries.NewGetWhatsAppMessageQueryHandler(whatsAppMessageRepo),
+	)
+
+	// Locally stored exports are downloaded through the API
+	var localStore *storage.LocalStore
+	if cfg.Storage.Driver == "local" {
+		if localStore, err = storage.NewLocalStore(&cfg.Storage); err != nil {
+			log.Fatal("Failed to initialize export storage: ", err)
+		}
+	}
+	downloadHandler := handlers.NewDownloadHandler(localStore)
+
 	// Initialize middleware
-	authMiddleware := middleware.NewAuthMiddleware(jwtManager)
+	authMiddleware := middleware.NewAuthMiddleware(jwtManager, sessionStore)
+	if cfg.Impersonation.Enabled {
+		authMiddleware.WithImpersonation(impersonationRepo, auditRepo, zapLogger)
+	}
+	auditMiddleware := middleware.Audit(auditRepo, zapLogger)
+	// Admins impersonating a customer cannot change how the account is
+	// secured or move money
+	notImpersonated := middleware.DenyImpersonation()
+
+	// Schedule sitemap regeneration
+	sitemapGenerator := sitemap.NewGenerator(productRepo, categoryRepo, cfg.SEO.SiteURL, cfg.SEO.SitemapDir, sitemap.MaxURLsPerFile)
+	jobs := scheduler.New(log)
+	jobs.EveryNow("sitemap", cfg.SEO.SitemapInterval(), sitemapGenerator.Generate)
+	jobs.EveryNow("bought-together", cfg.Recommendation.RefreshInterval(), func(ctx context.Context) error {
+		pairs, err := refreshBoughtTogetherHandler.Handle(ctx, commands.RefreshBoughtTogetherCommand{
+			LookbackDays:  cfg.Recommendation.LookbackDays,
+			MinSupport:    cfg.Recommendation.MinSupport,
+			MaxPerProduct: cfg.Recommendation.MaxRelated,
+		})
+		if err != nil {
+			return err
+		}
+		log.Info("Refreshed frequently bought together pairs: ", pairs)
+		return nil
+	})
+	jobs.EveryNow("dashboard-stats", cfg.Dashboard.RefreshInterval(), func(ctx context.Context) error {
+		_, err := refreshDashboardStatsHandler.Handle(ctx, commands.RefreshDashboardStatsCommand{
+			LowStockThreshold: cfg.Dashboard.LowStockThreshold,
+			TTL:               2 * cfg.Dashboard.RefreshInterval(),
+		})
+		return err
+	})
+
+	jobs.Every("account-erasure", cfg.AccountDeletion.Interval(), func(ctx context.Context) error {
+		erased, err := eraseAccountsHandler.Handle(ctx, commands.EraseAccountsCommand{
+			GracePeriod: cfg.AccountDeletion.GracePeriod(),
+			BatchSize:   cfg.AccountDeletion.BatchSize,
+		})
+		if erased > 0 {
+			log.Info("Erased deleted accounts: ", erased)
+		}
+		return err
+	})
+
+	// Database backups; the job checks hourly whether one is due and is
+	// woken early by backups triggered from the admin panel
+	if backupStore, err := storage.New(&cfg.Storage); err != nil {
+		log.Warn("Failed to initialize backup storage, backups disabled: ", err)
+	} else {
+		runBackupHandler := commands.NewRunBackupCommandHandler(backupRepo, database.NewPGDumper(&cfg.Database, cfg.Backup.PGDumpPath), backupStore)
+		jobs.Every(backup.JobName, time.Hour, func(ctx context.Context) error {
+			backups, err := runBackupHandler.Handle(ctx, commands.RunBackupCommand{
+				Database:  cfg.Database.DBName,
+				Interval:  cfg.Backup.Interval(),
+				Retention: cfg.Backup.Retention(),
+				Keep:      cfg.Backup.KeepMinimum,
+				Timeout:   cfg.Backup.Timeout(),
+			})
+			if len(backups) > 0 {
+				log.Info("Database backups run: ", len(backups))
+			}
+			return err
+		})
+	}
+
+	// Payment reconciliation; the job checks hourly whether last night's
+	// run is due and is woken early by runs triggered from the admin panel
+	runReconciliationHandler := commands.NewRunReconciliationCommandHandler(reconciliationRepo, paymentRepo, midtransProvider)
+	jobs.Every(reconciliation.JobName, time.Hour, func(ctx context.Context) error {
+		runs, err := runReconciliationHandler.Handle(ctx, commands.RunReconciliationCommand{
+			Nightly: cfg.Reconciliation.Enabled,
+			Delay:   cfg.Reconciliation.Delay(),
+			Timeout: cfg.Reconciliation.Timeout(),
+		})
+		for _, run := range runs {
+			if run.Status == reconciliation.StatusCompleted {
+				log.Info("Payment reconciliation run: ", run.PeriodStart.Format("2006-01-02"), ", mismatches: ", len(run.Mismatches))
+			}
+		}
+		return err
+	})
+
+	// Back in stock alerts; restocks are noticed by checking the stock of
+	// subscribed products
+	if cfg.StockAlerts.Enabled {
+		notifyBackInStockHandler := commands.NewNotifyBackInStockCommandHandler(stockAlertRepo, productRepo, userRepo, stockAlertNotifier, cfg.SEO.SiteURL)
+		jobs.Every(stockalert.JobName, cfg.StockAlerts.Interval(), func(ctx context.Context) error {
+			sent, err := notifyBackInStockHandler.Handle(ctx, commands.NotifyBackInStockCommand{BatchSize: cfg.StockAlerts.BatchSize})
+			if sent > 0 {
+				log.Info("Back in stock alerts sent: ", sent)
+			}
+			return err
+		})
+	}
+
+	// Price drop alerts, triggered by drops in the price history
+	if cfg.PriceAlerts.Enabled {
+		notifyPriceDropsHandler := commands.NewNotifyPriceDropsCommandHandler(priceHistoryRepo, priceAlertRepo, productRepo, userRepo, priceAlertNotifier, cfg.SEO.SiteURL)
+		jobs.Every(pricealert.JobName, cfg.PriceAlerts.Interval(), func(ctx context.Context) error {
+			sent, err := notifyPriceDropsHandler.Handle(ctx, commands.NotifyPriceDropsCommand{BatchSize: cfg.PriceAlerts.BatchSize})
+			if sent > 0 {
+				log.Info("Price drop alerts sent: ", sent)
+			}
+			return err
+		})
+	}
+	// Webhook deliveries, queued as orders and products change
+	if cfg.Webhooks.Enabled {
+		dispatchWebhooksHandler := commands.NewDispatchWebhooksCommandHandler(webhookEndpointRepo, webhookDeliveryRepo, webhookprovider.NewHTTPSender(cfg.Webhooks.Timeout(), cfg.Environment), webhook.RetryPolicy{
+			Attempts:  cfg.Webhooks.MaxAttempts,
+			BaseDelay: cfg.Webhooks.RetryBase(),
+			MaxDelay:  cfg.Webhooks.RetryMax(),
+		})
+		jobs.Every(webhook.JobName, cfg.Webhooks.Interval(), func(ctx context.Context) error {
+			_, err := dispatchWebhooksHandler.Handle(ctx, commands.DispatchWebhooksCommand{
+				BatchSize: cfg.Webhooks.BatchSize,
+				Lease:     2 * cfg.Webhooks.Timeout(),
+			})
+			return err
+		})
+	}
+	// Search index and cache sync, applying the changes captured with
+	// product and category writes
+	if cfg.SearchSync.Outbox() {
+		syncSearchHandler := commands.NewSyncSearchCommandHandler(database.NewSearchSyncRepository(db.DB), productRepo, elasticsearch.NewLocalizedIndex(searchService, translationRepo, i18n.TranslatedLocales()), cacheService, cacheInvalidator)
+		jobs.Every(searchsync.JobName, cfg.SearchSync.Interval(), func(ctx context.Context) error {
+			_, err := syncSearchHandler.Handle(ctx, commands.SyncSearchCommand{
+				BatchSize: cfg.SearchSync.BatchSize,
+				Lease:     time.Minute,
+			})
+			return err
+		})
+	}
+	// Product badges, worked out from their rules and the badges set by hand
+	// and stored on the products
+	if cfg.Badges.Enabled {
+		refreshBadgesHandler := commands.NewRefreshBadgesCommandHandler(badgeRepo, productRepo, flashSaleRepo)
+		jobs.EveryNow(badge.JobName, cfg.Badges.Interval(), func(ctx context.Context) error {
+			updated, err := refreshBadgesHandler.Handle(ctx, commands.RefreshBadgesCommand{BatchSize: cfg.Badges.BatchSize})
+			if updated > 0 {
+				log.Info("Product badges updated: ", updated)
+			}
+			return err
+		})
+	}
+	// Inventory forecast, projecting stock-outs from the sales recorded in
+	// the analytics store and suggesting what to reorder
+	if cfg.InventoryForecast.Enabled && eventsDB != nil {
+		refreshForecastsHandler := commands.NewRefreshForecastsCommandHandler(eventstore.NewTimescaleReports(eventsDB.DB), productRepo, stockRepo, forecastRepo)
+		forecastPolicy := forecast.Policy{
+			LookbackDays: cfg.InventoryForecast.LookbackDays,
+			LeadTimeDays: cfg.InventoryForecast.LeadTimeDays,
+			SafetyDays:   cfg.InventoryForecast.SafetyDays,
+			CoverDays:    cfg.InventoryForecast.CoverDays,
+		}
+		jobs.EveryNow(forecast.JobName, cfg.InventoryForecast.Interval(), func(ctx context.Context) error {
+			suggestions, err := refreshForecastsHandler.Handle(ctx, commands.RefreshForecastsCommand{Policy: forecastPolicy, BatchSize: cfg.InventoryForecast.BatchSize})
+			if err == nil {
+				log.Info("Reorder suggestions updated: ", suggestions)
+			}
+			return err
+		})
+	}
+	// Drafts published at their publish date
+	if cfg.ProductPublish.Enabled {
+		publishScheduledHandler := commands.NewPublishScheduledProductsCommandHandler(productRepo, database.NewScheduleRepository(db.DB), webhookPublisher)
+		jobs.Every(product.PublishJobName, cfg.ProductPublish.Interval(), func(ctx context.Context) error {
+			published, err := publishScheduledHandler.Handle(ctx, commands.PublishScheduledProductsCommand{BatchSize: cfg.ProductPublish.BatchSize})
+			if published > 0 {
+				log.Info("Scheduled products published: ", published)
+			}
+			return err
+		})
+	}
+	// Marketing campaigns queued for the email worker once due
+	if cfg.Campaigns.Enabled {
+		sendDueCampaignsHandler := commands.NewSendDueCampaignsCommandHandler(campaignRepo, audienceRepo, emailPublisher)
+		jobs.Every(campaign.JobName, cfg.Campaigns.Interval(), func(ctx context.Context) error {
+			queued, err := sendDueCampaignsHandler.Handle(ctx, commands.SendDueCampaignsCommand{
+				BatchSize:        cfg.Campaigns.BatchSize,
+				BatchesPerSecond: cfg.Campaigns.BatchesPerSecond,
+			})
+			if queued > 0 {
+				log.Info("Campaign emails queued: ", queued)
+			}
+			return err
+		})
+	}
+	jobs.Every("secrets", cfg.Secrets.RefreshInterval(), secretsManager.Refresh)
+	jobs.Start(context.Background())
+	defer jobs.Stop()
+
+	// Admin API handlers
+	dashboardHandler := handlers.NewDashboardHandler(queries.NewGetDashboardStatsQueryHandler(dashboardStatsStore, dashboardRepo, cfg.Dashboard.LowStockThreshold))
+	commissionHandler := handlers.NewCommissionHandler(
+		commands.NewCreateCommissionRuleCommandHandler(commissionRepo),
+		commands.NewUpdateCommissionRuleCommandHandler(commissionRepo),
+		commands.NewDeleteCommissionRuleCommandHandler(commissionRepo),
+		queries.NewListCommissionRulesQueryHandler(commissionRepo),
+	)
+	warehouseHandler := handlers.NewWarehouseHandler(
+		commands.NewCreateWarehouseCommandHandler(warehouseRepo),
+		commands.NewUpdateWarehouseCommandHandler(warehouseRepo),
+		commands.NewSetWarehouseStockCommandHandler(warehouseRepo, stockRepo),
+		queries.NewListWarehousesQueryHandler(warehouseRepo),
+		queries.NewGetWarehouseStockQueryHandler(stockRepo),
+	)
+	bannerHandler := handlers.NewBannerHandler(
+		commands.NewCreateBannerCommandHandler(bannerRepo, cmsCache),
+		commands.NewUpdateBannerCommandHandler(bannerRepo, cmsCache),
+		commands.NewDeleteBannerCommandHandler(bannerRepo, cmsCache),
+		queries.NewListBannersQueryHandler(bannerRepo),
+	)
+	cmsHandler := handlers.NewCMSHandler(
+		commands.NewCreateCMSBlockCommandHandler(cmsBlockRepo, cmsCache),
+		commands.NewUpdateCMSBlockCommandHandler(cmsBlockRepo, cmsCache),
+		commands.NewDeleteCMSBlockCommandHandler(cmsBlockRepo, cmsCache),
+		queries.NewListCMSBlocksQueryHandler(cmsBlockRepo),
+		commands.NewCreateCMSPageCommandHandler(cmsPageRepo, cmsCache),
+		commands.NewUpdateCMSPageCommandHandler(cmsPageRepo, cmsCache),
+		commands.NewDeleteCMSPageCommandHandler(cmsPageRepo, cmsCache),
+		queries.NewListCMSPagesQueryHandler(cmsPageRepo),
+		queries.NewGetCMSPageQueryHandler(cmsPageRepo),
+		commands.NewUploadCMSAssetCommandHandler(cmsAssetRepo, cmsStorage, cfg.CMS.MaxAssetSize(), cfg.CMS.AllowedAssetTypes),
+		commands.NewDeleteCMSAssetCommandHandler(cmsAssetRepo, cmsStorage, cmsCache),
+		queries.NewListCMSAssetsQueryHandler(cmsAssetRepo),
+	)
+	accounting, err := spreadsheet.Accounting(&cfg.Export.Accounting, cfg.Storefront.Currency)
+	if err != nil {
+		log.Fatal("Invalid accounting export configuration: ", err)
+	}
+	// Finished exports are linked from the object store the CMS images are
+	// kept in
+	exportHandler := handlers.NewExportHandler(
+		commands.NewRequestExportCommandHandler(exportRepo, exportPublisher, accounting),
+		queries.NewListExportsQueryHandler(exportRepo, cmsStorage, cfg.Export.LinkTTL()),
+		queries.NewGetExportQueryHandler(exportRepo, cmsStorage, cfg.Export.LinkTTL()),
+	)
+	auditHandler := handlers.NewAuditHandler(queries.NewListAuditLogsQueryHandler(auditRepo))
+	systemLogHandler := handlers.NewSystemLogHandler(queries.NewSearchSystemLogsQueryHandler(logstore.NewFileReader(cfg.Logger.SearchFiles()...)))
+	systemHandler := handlers.NewSystemHandler(
+		commands.NewTriggerBackupCommandHandler(backupRepo, jobs),
+		queries.NewListBackupsQueryHandler(backupRepo),
+		queries.NewGetBackupQueryHandler(backupRepo),
+		queries.NewGetBackupStatusQueryHandler(backupRepo, cfg.Backup.Interval()),
+	)
+	cacheHandler := handlers.NewCacheHandler(commands.NewClearCacheCommandHandler(cacheInvalidator, auditRepo, cfg.Environment))
+	configHandler := handlers.NewConfigHandler(configWatcher)
+	emailTemplateCache := redis.NewEmailTemplateCache(redisClient)
+	emailTemplateHandler := handlers.NewEmailTemplateHandler(
+		commands.NewSaveEmailTemplateCommandHandler(emailTemplateRepo, emailTemplateCache),
+		commands.NewActivateEmailTemplateCommandHandler(emailTemplateRepo, emailTemplateCache),
+		commands.NewDeleteEmailTemplateCommandHandler(emailTemplateRepo, emailTemplateCache),
+		commands.NewSendTestEmailCommandHandler(emailTemplateRepo, emailTemplatePublisher),
+		queries.NewListEmailTemplatesQueryHandler(emailTemplateRepo),
+		queries.NewGetEmailTemplateQueryHandler(emailTemplateRepo, emailTemplateCache, cfg.SMTP.TemplateCacheTTL()),
+		queries.NewListEmailTemplateVersionsQueryHandler(emailTemplateRepo),
+		queries.NewPreviewEmailTemplateQueryHandler(emailTemplateRepo),
+	)
+	reconciliationHandler := handlers.NewReconciliationHandler(
+		commands.NewTriggerReconciliationCommandHandler(reconciliationRepo, midtransProvider.Provider(), jobs),
+		queries.NewListReconciliationRunsQueryHandler(reconciliationRepo),
+		queries.NewGetReconciliationRunQueryHandler(reconciliationRepo),
+	)
+	fraudHandler := handlers.NewFraudHandler(
+		commands.NewApproveOrderReviewCommandHandler(fraudRepo, orderRepo),
+		commands.NewDeclineOrderReviewCommandHandler(fraudRepo, orderRepo, cancelOrderHandler),
+		queries.NewListFraudReviewsQueryHandler(fraudRepo),
+		queries.NewGetFraudReviewQueryHandler(fraudRepo),
+	)
+	jobHandler := handlers.NewJobHandler(
+		queries.NewListJobsQueryHandler(jobRepo),
+		queries.NewGetJobQueryHandler(jobRepo),
+		commands.NewRetryJobCommandHandler(jobRepo, jobPublisher),
+		commands.NewCancelJobCommandHandler(jobRepo),
+	)
+	translationHandler := handlers.NewTranslationHandler(
+		commands.NewSetProductTranslationCommandHandler(productRepo, translationRepo),
+		commands.NewDeleteProductTranslationCommandHandler(translationRepo),
+		commands.NewSetCategoryTranslationCommandHandler(categoryRepo, translationRepo),
+		commands.NewDeleteCategoryTranslationCommandHandler(translationRepo),
+		queries.NewListProductTranslationsQueryHandler(translationRepo),
+		queries.NewListCategoryTranslationsQueryHandler(translationRepo),
+		queries.NewGetTranslationReportQueryHandler(translationRepo, i18n.TranslatedLocales()),
+		queries.NewListMissingTranslationsQueryHandler(translationRepo),
+	)
+	merchandisingHandler := handlers.NewMerchandisingHandler(
+		queries.NewListMerchandisingRulesQueryHandler(merchandisingRuleRepo),
+		queries.NewPreviewMerchandisingQueryHandler(merchandisingRuleRepo),
+		commands.NewCreateMerchandisingRuleCommandHandler(merchandisingRuleRepo, productRepo, categoryRepo),
+		commands.NewUpdateMerchandisingRuleCommandHandler(merchandisingRuleRepo, productRepo, categoryRepo),
+		commands.NewDeleteMerchandisingRuleCommandHandler(merchandisingRuleRepo),
+	)
+	badgeHandler := handlers.NewBadgeHandler(
+		queries.NewListBadgesQueryHandler(badgeRepo),
+		queries.NewListProductBadgesQueryHandler(badgeRepo),
+		commands.NewCreateBadgeCommandHandler(badgeRepo),
+		commands.NewUpdateBadgeCommandHandler(badgeRepo, jobs),
+		commands.NewDeleteBadgeCommandHandler(badgeRepo, jobs),
+		commands.NewAssignProductBadgeCommandHandler(badgeRepo, productRepo, jobs),
+		commands.NewUnassignProductBadgeCommandHandler(badgeRepo, jobs),
+	)
+	forecastHandler := handlers.NewForecastHandler(queries.NewListReorderSuggestionsQueryHandler(forecastRepo))
+	// Without the outbox, moderation and rollbacks update the search index
+	// and product cache themselves
+	var productIndex searchsync.Index
+	var productCache searchsync.ProductCache
+	if !cfg.SearchSync.Outbox() {
+		productIndex = elasticsearch.NewLocalizedIndex(searchService, translationRepo, i18n.TranslatedLocales())
+		productCache = cacheService
+	}
+	moderationHandler := handlers.NewModerationHandler(
+		queries.NewListProductSubmissionsQueryHandler(moderationRepo),
+		queries.NewGetProductSubmissionQueryHandler(moderationRepo),
+		commands.NewSubmitProductCommandHandler(productRepo, categoryRepo, moderationRepo),
+		commands.NewReviewProductSubmissionCommandHandler(moderationRepo, productRepo, categoryRepo, webhookPublisher, productIndex, productCache),
+	)
+	productVersionHandler := handlers.NewProductVersionHandler(
+		queries.NewListProductVersionsQueryHandler(productVersionRepo),
+		queries.NewGetProductVersionQueryHandler(productVersionRepo),
+		queries.NewDiffProductVersionsQueryHandler(productVersionRepo),
+		commands.NewRollbackProductCommandHandler(productRepo, categoryRepo, productVersionRepo, auditRepo, webhookPublisher, productIndex, productCache),
+	)
+	recordOfflinePaymentHandler := commands.NewRecordOfflinePaymentCommandHandler(orderRepo, paymentRepo, orderConfirmer, receiptSender, auditRepo)
+	manualOrderHandler := handlers.NewManualOrderHandler(
+		commands.NewCreateManualOrderCommandHandler(userRepo, productRepo, createOrderHandler, recordOfflinePaymentHandler, auditRepo),
+		recordOfflinePaymentHandler,
+	)
+	impersonationHandler := handlers.NewImpersonationHandler(
+		commands.NewStartImpersonationCommandHandler(userRepo, impersonationRepo, auditRepo, cfg.Impersonation.TTL(), cfg.Impersonation.MaxTTL()),
+		commands.NewEndImpersonationCommandHandler(impersonationRepo, auditRepo),
+		queries.NewListImpersonationsQueryHandler(impersonationRepo),
+		jwtManager,
+	)
+	maintenanceHandler := handlers.NewMaintenanceHandler(
+		queries.NewGetMaintenanceQueryHandler(maintenanceStore),
+		commands.NewSetMaintenanceCommandHandler(maintenanceStore, auditRepo),
+		commands.NewScheduleMaintenanceCommandHandler(maintenanceStore, auditRepo),
+		commands.NewCancelMaintenanceWindowCommandHandler(maintenanceStore, auditRepo),
+	)
+	// Sales, product and user analytics aggregate the event store
+	var reportHandler *handlers.ReportHandler
+	if eventsDB != nil {
+		reports := eventstore.NewTimescaleReports(eventsDB.DB)
+		reportHandler = handlers.NewReportHandler(
+			queries.NewGetSalesAnalyticsQueryHandler(reports),
+			queries.NewGetRevenueAnalyticsQueryHandler(reports),
+			queries.NewGetProductAnalyticsQueryHandler(reports),
+			queries.NewGetUserAnalyticsQueryHandler(reports),
+		)
+	}
 
 	// Setup Gin router
-	r := gin.Default()
+	r := gin.New()
+	r.Use(gin.Recovery())
+	// Requests are logged through the shared logger with their request,
+	// user and trace ID
+	r.Use(middleware.RequestID())
+	r.Use(middleware.RequestLogger(zapLogger))
+	r.MaxMultipartMemory = cfg.RequestLimits.MultipartMemory()
+	r.Use(middleware.Errors())
+	r.Use(middleware.BodyLimit(&cfg.RequestLimits))
+	r.Use(middleware.Timeout(cfg.Server.RequestTimeout()))
+
+	// Messages follow the user's saved locale, then Accept-Language
+	r.Use(middleware.Locale(middleware.LocalePreferencesFunc(func(ctx context.Context, userID string) string {
+		u, err := userRepo.GetByID(ctx, userID)
+		if err != nil || u == nil {
+			return ""
+		}
+		return u.Locale
+	})))
 
 	// CORS middleware
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, Idempotency-Key, X-Session-ID, X-Cart-Token")
+		c.Header("Access-Control-Expose-Headers", "X-Cart-Token")
 		c.Header("Access-Control-Allow-Credentials", "true")
 
 		if c.Request.Method == "OPTIONS" {
@@ -129,59 +1187,613 @@ func main() {
 		c.Next()
 	})
 
+	// Security headers, set per environment
+	r.Use(middleware.SecurityHeaders(&cfg.SecurityHeaders))
+
+	// Rate limiting shared by every API instance
+	if cfg.RateLimit.Enabled {
+		rules, err := middleware.RateLimitRules(&cfg.RateLimit)
+		if err != nil {
+			log.Fatal("Invalid rate limit configuration: ", err)
+		}
+		ruleSet := ratelimit.NewRuleSet(rules)
+		configWatcher.OnChange(func(next *config.Config) {
+			rules, err := middleware.RateLimitRules(&next.RateLimit)
+			if err != nil {
+				log.Error("Invalid rate limit configuration, keeping current rules: ", err)
+				return
+			}
+			ruleSet.Replace(rules)
+		})
+		r.Use(authMiddleware.OptionalAuth(), middleware.ReloadableRateLimit(redis.NewRateLimiter(redisClient), ruleSet, zapLogger))
+	}
+
+	// Maintenance mode, switched from the admin API; health checks stay up
+	r.Use(middleware.Maintenance(maintenanceStore, &cfg.Maintenance, zapLogger))
+
 	// Health check
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
+	// Token signing keys
+	r.GET("/.well-known/jwks.json", jwksHandler.GetKeys)
+
+	// Content-Security-Policy violation reports, stored as analytics events
+	cspReportHandler := handlers.NewCSPReportHandler(commands.NewRecordCSPReportCommandHandler(analyticsPublisher))
+	r.POST("/csp-reports", cspReportHandler.ReportViolation)
+
+	// Error catalog
+	errorCatalogHandler := handlers.NewErrorCatalogHandler()
+	r.GET("/errors", errorCatalogHandler.ListErrors)
+	r.GET("/errors/:code", errorCatalogHandler.GetError)
+
+	// OpenAPI document and the docs UI that reads it
+	docsHandler := handlers.NewDocsHandler()
+	r.GET(handlers.OpenAPIPath, docsHandler.GetSpec)
+	r.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, ginSwagger.URL(handlers.OpenAPIPath)))
+
+	// Sitemaps
+	r.GET("/sitemap.xml", sitemapHandler.GetIndex)
+	r.GET("/sitemaps/:file", sitemapHandler.GetSitemap)
+	r.GET("/downloads/*key", downloadHandler.Download)
+
 	// API routes
-	api := r.Group("/api/v1")
+	api := r.Group("/api/v1", middleware.APIVersion(middleware.APIv1))
 
 	// User routes
 	users := api.Group("/users")
 	{
 		users.POST("/register", userHandler.Register)
 		users.POST("/login", userHandler.Login)
+		users.POST("/refresh", userHandler.RefreshToken)
 		users.GET("/profile", authMiddleware.RequireAuth(), userHandler.GetProfile)
-		users.PUT("/profile", authMiddleware.RequireAuth(), userHandler.UpdateProfile)
-		users.PUT("/password", authMiddleware.RequireAuth(), userHandler.ChangePassword)
+		users.PUT("/profile", authMiddleware.RequireAuth(), auditMiddleware, userHandler.UpdateProfile)
+		users.PUT("/password", authMiddleware.RequireAuth(), notImpersonated, auditMiddleware, userHandler.ChangePassword)
+		users.DELETE("/account", authMiddleware.RequireAuth(), notImpersonated, auditMiddleware, userHandler.DeleteAccount)
+		users.GET("/notification-preferences", authMiddleware.RequireAuth(), notificationPreferenceHandler.GetPreferences)
+		users.PUT("/notification-preferences", authMiddleware.RequireAuth(), auditMiddleware, notificationPreferenceHandler.UpdatePreferences)
+	}
+
+	// Session routes
+	sessions := api.Group("/user/sessions", authMiddleware.RequireAuth(), auditMiddleware)
+	{
+		sessions.GET("", sessionHandler.ListSessions)
+		sessions.DELETE("", notImpersonated, sessionHandler.RevokeAllSessions)
+		sessions.DELETE("/:id", notImpersonated, sessionHandler.RevokeSession)
+	}
+
+	// Saved payment methods
+	paymentMethods := api.Group("/user/payment-methods", authMiddleware.RequireAuth(), auditMiddleware)
+	{
+		paymentMethods.GET("", paymentMethodHandler.ListMethods)
+		paymentMethods.POST("", notImpersonated, paymentMethodHandler.AddMethod)
+		paymentMethods.DELETE("/:id", notImpersonated, paymentMethodHandler.DeleteMethod)
+		paymentMethods.PUT("/:id/default", notImpersonated, paymentMethodHandler.SetDefaultMethod)
+	}
+
+	// Receipts of what the user has paid
+	api.GET("/user/receipts", authMiddleware.RequireAuth(), auditMiddleware, orderPaymentHandler.ListReceipts)
+
+	// Back in stock alerts
+	stockAlerts := api.Group("/user/stock-alerts", authMiddleware.RequireAuth(), auditMiddleware)
+	{
+		stockAlerts.GET("", stockAlertHandler.ListAlerts)
+		stockAlerts.POST("", stockAlertHandler.Subscribe)
+		stockAlerts.DELETE("/:id", stockAlertHandler.Cancel)
+	}
+
+	// Price drop alerts
+	priceAlerts := api.Group("/user/price-alerts", authMiddleware.RequireAuth(), auditMiddleware)
+	{
+		priceAlerts.GET("", priceAlertHandler.ListAlerts)
+		priceAlerts.POST("", priceAlertHandler.Watch)
+		priceAlerts.DELETE("/:id", priceAlertHandler.Cancel)
+	}
+
+	// Requests for quotes
+	quotes := api.Group("/user/quotes", authMiddleware.RequireAuth(), auditMiddleware)
+	{
+		quotes.GET("", quoteHandler.ListMyQuotes)
+		quotes.POST("", quoteHandler.RequestQuote)
+		quotes.GET("/:id", quoteHandler.GetQuote)
+		quotes.POST("/:id/accept", quoteHandler.AcceptQuote)
+		quotes.POST("/:id/cancel", quoteHandler.CancelQuote)
+	}
+
+	// Business accounts buying on invoice
+	organization := api.Group("/user/organization", authMiddleware.RequireAuth(), auditMiddleware)
+	{
+		organization.GET("", organizationHandler.GetOrganization)
+		organization.POST("", notImpersonated, organizationHandler.CreateOrganization)
+		organization.GET("/members", organizationHandler.ListMembers)
+		organization.POST("/members", notImpersonated, organizationHandler.AddMember)
+		organization.PUT("/members/:userId", notImpersonated, organizationHandler.UpdateMember)
+		organization.DELETE("/members/:userId", notImpersonated, organizationHandler.RemoveMember)
+		organization.PUT("/approval-chain", notImpersonated, organizationHandler.SetApprovalChain)
+		organization.GET("/orders", organizationHandler.ListOrders)
+		organization.GET("/approvals", organizationHandler.ListApprovals)
+		organization.POST("/approvals/:id/approve", notImpersonated, organizationHandler.ApprovePurchase)
+		organization.POST("/approvals/:id/reject", notImpersonated, organizationHandler.RejectPurchase)
+		organization.GET("/invoices", organizationHandler.ListInvoices)
+	}
+
+	// Personal data export
+	api.POST("/user/data-export", authMiddleware.RequireAuth(), notImpersonated, auditMiddleware, dataExportHandler.RequestDataExport)
+
+	// OAuth routes
+	oauthRoutes := api.Group("/auth/oauth/:provider")
+	{
+		oauthRoutes.GET("/start", oauthHandler.Start)
+		oauthRoutes.GET("/callback", oauthHandler.Callback)
+		oauthRoutes.POST("/callback", oauthHandler.Callback)
 	}
 
 	// Product routes
 	products := api.Group("/products")
 	{
 		products.GET("/search", productHandler.SearchProducts)
-		products.GET("/:id", productHandler.GetProduct)
+		products.GET("/search/facets", productHandler.GetSearchFacets)
+		products.GET("/compare", productHandler.CompareProducts)
+		products.GET("/featured", productHandler.GetFeaturedProducts)
+		products.GET("/trending", productHandler.GetTrendingProducts)
+		products.GET("/:id", authMiddleware.OptionalAuth(), productHandler.GetProduct)
+		products.GET("/:id/similar", recommendationHandler.GetSimilarProducts)
+		products.GET("/:id/bought-together", recommendationHandler.GetBoughtTogether)
+		products.GET("/:id/delivery-estimate", shippingHandler.DeliveryEstimate)
 		products.GET("/categories", productHandler.ListCategories)
+		products.GET("/category/:slug", productHandler.GetProductsByCategory)
 	}
 
+	// Home feed
+	api.GET("/home", authMiddleware.OptionalAuth(), homeHandler.GetHome)
+
+	// Storefront content
+	content := api.Group("/cms")
+	{
+		content.GET("/banners", contentHandler.GetBanners)
+		content.GET("/blocks/:slot", contentHandler.GetSlot)
+		content.GET("/pages/:slug", contentHandler.GetPage)
+		content.GET("/assets/:id", contentHandler.GetAsset)
+	}
+
+	// Storefront settings
+	api.GET("/storefront/settings", storefrontHandler.GetPublicSettings)
+
+	// Shipping quotes
+	api.POST("/shipping/quote", shippingHandler.Quote)
+
+	// Flash sales
+	flashSales := api.Group("/flash-sales")
+	{
+		flashSales.GET("", flashSaleHandler.GetCurrentSales)
+		flashSales.GET("/:id", flashSaleHandler.GetPublicSale)
+	}
+
+	// Recently viewed products
+	api.GET("/user/recently-viewed", authMiddleware.OptionalAuth(), recentlyViewedHandler.GetRecentlyViewed)
+
+	// Experiment assignments and tracking
+	experiments := api.Group("/experiments", authMiddleware.OptionalAuth())
+	{
+		experiments.GET("", experimentHandler.GetAssignments)
+		experiments.POST("/:key/exposures", experimentHandler.TrackExposure)
+		experiments.POST("/:key/conversions", experimentHandler.TrackConversion)
+	}
+
+	// Email bounce and complaint webhooks (no auth, the provider's signature is verified)
+	api.POST("/email/webhooks/:provider", emailDeliveryHandler.IngestEvents)
+
+	// Campaign email opens and clicks (no auth, the links are signed)
+	api.GET("/campaigns/track/open/:token", campaignHandler.TrackOpen)
+	api.GET("/campaigns/track/click/:token", campaignHandler.TrackClick)
+
+	// Unsubscribe links of alert and marketing emails (no auth, the links
+	// are signed); mail clients post for one-click unsubscribes
+	api.GET("/notifications/unsubscribe/:token", notificationPreferenceHandler.GetUnsubscribe)
+	api.POST("/notifications/unsubscribe/:token", notificationPreferenceHandler.Unsubscribe)
+
+	// Payment links of draft orders (no auth, the token is the secret); the
+	// summary is a hosted page for browsers
+	api.GET("/draft-orders/:token", draftOrderHandler.GetSummary)
+	api.POST("/draft-orders/:token/pay", draftOrderHandler.Pay)
+
+	// WhatsApp webhook (no auth, the app secret signature is verified)
+	api.GET("/whatsapp/webhook", whatsAppHandler.VerifyWebhook)
+	api.POST("/whatsapp/webhook", whatsAppHandler.ReceiveWebhook)
+
+	// Category routes
+	api.GET("/categories/:slug", productHandler.GetCategory)
+
+	// Order tracking without an account (no auth, rate limited per IP)
+	api.GET("/orders/track", orderHandler.TrackOrder)
+
+	// Cart routes, for signed-in customers and, by their cart token,
+	// anonymous visitors; checking out needs an account
+	carts := api.Group("/cart", authMiddleware.OptionalAuth())
+	{
+		carts.GET("", cartHandler.GetCart)
+		carts.POST("/items", cartHandler.AddToCart)
+		carts.PUT("/items/:id", cartHandler.UpdateCartItem)
+		carts.DELETE("/items/:id", cartHandler.RemoveFromCart)
+		carts.DELETE("", cartHandler.ClearCart)
+		carts.POST("/merge", authMiddleware.RequireAuth(), cartHandler.MergeCart)
+		carts.POST("/checkout", authMiddleware.RequireAuth(), auditMiddleware, middleware.Idempotency(idempotencyStore, cfg.Idempotency.TTL()), cartHandler.Checkout)
+	}
+
+	// Checkout totals, priced the way the order would be
+	api.POST("/checkout/preview", authMiddleware.RequireAuth(), cartHandler.PreviewCheckout)
+
 	// Order routes
 	orders := api.Group("/orders")
 	orders.Use(authMiddleware.RequireAuth())
+	orders.Use(auditMiddleware)
 	{
-		orders.POST("", orderHandler.CreateOrder)
+		orders.POST("", middleware.Idempotency(idempotencyStore, cfg.Idempotency.TTL()), orderHandler.CreateOrder)
+		orders.POST("/one-click", notImpersonated, middleware.Idempotency(idempotencyStore, cfg.Idempotency.TTL()), paymentMethodHandler.OneClickCheckout)
 		orders.GET("", orderHandler.GetUserOrders)
 		orders.GET("/:id", orderHandler.GetOrder)
 		orders.PUT("/:id/cancel", orderHandler.CancelOrder)
+		orders.GET("/:id/payments", orderPaymentHandler.GetPayments)
+		orders.GET("/:id/invoice", orderPaymentHandler.GetInvoice)
+		orders.POST("/:id/payments", notImpersonated, middleware.Idempotency(idempotencyStore, cfg.Idempotency.TTL()), orderPaymentHandler.CreatePayments)
+		orders.POST("/:id/payments/:paymentId/pay", notImpersonated, middleware.Idempotency(idempotencyStore, cfg.Idempotency.TTL()), orderPaymentHandler.PayPayment)
+		orders.POST("/:id/cash-on-delivery", notImpersonated, middleware.Idempotency(idempotencyStore, cfg.Idempotency.TTL()), codHandler.PayOnDelivery)
+		orders.POST("/:id/pay-on-invoice", notImpersonated, middleware.Idempotency(idempotencyStore, cfg.Idempotency.TTL()), organizationHandler.PayOnInvoice)
 	}
 
 	// Payment webhook (no auth required)
 	api.POST("/payments/webhook", func(c *gin.Context) {
 		var data map[string]interface{}
 		if err := c.ShouldBindJSON(&data); err != nil {
-			c.JSON(400, gin.H{"error": err.Error()})
+			middleware.AbortWithError(c, apperror.ErrInvalidRequest.WithDetail(err.Error()))
 			return
 		}
 
-		if err := paymentService.HandleWebhook(data); err != nil {
+		if err := paymentService.HandleWebhook(c.Request.Context(), data); err != nil {
 			log.Error("Payment webhook error: ", err)
-			c.JSON(500, gin.H{"error": "Internal server error"})
+			middleware.AbortWithError(c, apperror.ErrInternal)
 			return
 		}
 
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
+	// API v2 routes: the v2 representations, paged by keyset cursor
+	apiV2 := r.Group("/api/v2", middleware.APIVersion(middleware.APIv2))
+	productsV2 := apiV2.Group("/products")
+	{
+		productsV2.GET("", productV2Handler.ListProducts)
+		productsV2.GET("/:id", authMiddleware.OptionalAuth(), productV2Handler.GetProduct)
+	}
+	ordersV2 := apiV2.Group("/orders", authMiddleware.RequireAuth(), auditMiddleware)
+	{
+		ordersV2.POST("", middleware.Idempotency(idempotencyStore, cfg.Idempotency.TTL()), orderV2Handler.CreateOrder)
+		ordersV2.GET("", orderV2Handler.ListOrders)
+		ordersV2.GET("/:id", orderV2Handler.GetOrder)
+		ordersV2.PUT("/:id/cancel", orderV2Handler.CancelOrder)
+	}
+
+	// Admin routes
+	admin := r.Group("/admin", authMiddleware.RequireAuth(), authMiddleware.RequireRole(string(user.RoleAdmin)), auditMiddleware)
+	admin.GET("/dashboard", dashboardHandler.GetStats)
+
+	adminUsers := admin.Group("/users")
+	{
+		adminUsers.PUT("/:id/age-verification", userHandler.VerifyAge)
+	}
+
+	// Support admins acting as customers, when impersonation is enabled
+	if cfg.Impersonation.Enabled {
+		adminUsers.POST("/:id/impersonate", impersonationHandler.StartImpersonation)
+		impersonations := admin.Group("/impersonations")
+		{
+			impersonations.GET("", impersonationHandler.ListImpersonations)
+			impersonations.POST("/:id/end", impersonationHandler.EndImpersonation)
+		}
+	}
+
+	adminProducts := admin.Group("/products")
+	{
+		adminProducts.PUT("/:id/slug", productHandler.UpdateProductSlug)
+		adminProducts.PUT("/:id/featured", productHandler.SetFeatured)
+		adminProducts.PUT("/:id/attributes", productHandler.SetProductAttributes)
+		adminProducts.PUT("/:id/bundle", productHandler.SetProductBundle)
+		adminProducts.PUT("/:id/purchase-limits", productHandler.SetPurchaseLimits)
+		adminProducts.POST("/:id/activate", productHandler.ActivateProduct)
+		adminProducts.POST("/:id/deactivate", productHandler.DeactivateProduct)
+		adminProducts.PUT("/:id/status", productHandler.SetProductStatus)
+		adminProducts.PUT("/:id/publish-at", productHandler.ScheduleProductPublish)
+		adminProducts.POST("/:id/duplicate", productHandler.DuplicateProduct)
+		adminProducts.GET("/:id/versions", productVersionHandler.ListVersions)
+		adminProducts.GET("/:id/versions/:version", productVersionHandler.GetVersion)
+		adminProducts.GET("/:id/versions/:version/diff", productVersionHandler.DiffVersions)
+		adminProducts.POST("/:id/versions/:version/rollback", productVersionHandler.RollbackProduct)
+		adminProducts.GET("/:id/translations", translationHandler.ListProductTranslations)
+		adminProducts.PUT("/:id/translations/:locale", translationHandler.SetProductTranslation)
+		adminProducts.DELETE("/:id/translations/:locale", translationHandler.DeleteProductTranslation)
+		adminProducts.GET("/:id/badges", badgeHandler.ListProductBadges)
+		adminProducts.POST("/:id/badges", badgeHandler.AssignProductBadge)
+		adminProducts.DELETE("/:id/badges/:code", badgeHandler.UnassignProductBadge)
+	}
+
+	adminCategories := admin.Group("/categories")
+	{
+		adminCategories.PUT("/:id/slug", productHandler.UpdateCategorySlug)
+		adminCategories.PUT("/:id/attributes", productHandler.SetCategoryAttributes)
+		adminCategories.PUT("/:id/minimum-age", productHandler.SetCategoryMinimumAge)
+		adminCategories.GET("/:id/translations", translationHandler.ListCategoryTranslations)
+		adminCategories.PUT("/:id/translations/:locale", translationHandler.SetCategoryTranslation)
+		adminCategories.DELETE("/:id/translations/:locale", translationHandler.DeleteCategoryTranslation)
+	}
+
+	translations := admin.Group("/translations")
+	{
+		translations.GET("", translationHandler.GetReport)
+		translations.GET("/missing", translationHandler.ListMissing)
+	}
+
+	merchandising := admin.Group("/merchandising")
+	{
+		merchandising.GET("/rules", merchandisingHandler.ListRules)
+		merchandising.POST("/rules", merchandisingHandler.CreateRule)
+		merchandising.PUT("/rules/:id", merchandisingHandler.UpdateRule)
+		merchandising.DELETE("/rules/:id", merchandisingHandler.DeleteRule)
+		merchandising.GET("/preview", merchandisingHandler.Preview)
+	}
+
+	badges := admin.Group("/badges")
+	{
+		badges.GET("", badgeHandler.ListBadges)
+		badges.POST("", badgeHandler.CreateBadge)
+		badges.PUT("/:code", badgeHandler.UpdateBadge)
+		badges.DELETE("/:code", badgeHandler.DeleteBadge)
+	}
+
+	submissions := admin.Group("/product-submissions")
+	{
+		submissions.GET("", moderationHandler.ListSubmissions)
+		submissions.GET("/:id", moderationHandler.GetSubmission)
+		submissions.POST("/:id/approve", moderationHandler.ApproveSubmission)
+		submissions.POST("/:id/reject", moderationHandler.RejectSubmission)
+	}
+
+	admin.GET("/inventory/reorder-suggestions", forecastHandler.ListReorderSuggestions)
+
+	adminOrders := admin.Group("/orders")
+	{
+		adminOrders.GET("", orderHandler.GetOrders)
+		adminOrders.POST("", manualOrderHandler.CreateOrder)
+		adminOrders.GET("/cancellations", orderHandler.GetCancellationReport)
+		adminOrders.GET("/:id", orderHandler.GetOrder)
+		adminOrders.POST("/:id/offline-payments", manualOrderHandler.RecordOfflinePayment)
+	}
+
+	commissions := admin.Group("/commissions")
+	{
+		commissions.GET("", commissionHandler.ListRules)
+		commissions.POST("", commissionHandler.CreateRule)
+		commissions.PUT("/:id", commissionHandler.UpdateRule)
+		commissions.DELETE("/:id", commissionHandler.DeleteRule)
+	}
+
+	warehouses := admin.Group("/warehouses")
+	{
+		warehouses.GET("", warehouseHandler.ListWarehouses)
+		warehouses.POST("", warehouseHandler.CreateWarehouse)
+		warehouses.PUT("/:id", warehouseHandler.UpdateWarehouse)
+		warehouses.GET("/:id/stock", warehouseHandler.GetStock)
+		warehouses.PUT("/:id/stock/:product_id", warehouseHandler.SetStock)
+	}
+
+	banners := admin.Group("/banners")
+	{
+		banners.GET("", bannerHandler.ListBanners)
+		banners.POST("", bannerHandler.CreateBanner)
+		banners.PUT("/:id", bannerHandler.UpdateBanner)
+		banners.DELETE("/:id", bannerHandler.DeleteBanner)
+	}
+
+	cmsBlocks := admin.Group("/cms/blocks")
+	{
+		cmsBlocks.GET("", cmsHandler.ListBlocks)
+		cmsBlocks.POST("", cmsHandler.CreateBlock)
+		cmsBlocks.PUT("/:id", cmsHandler.UpdateBlock)
+		cmsBlocks.DELETE("/:id", cmsHandler.DeleteBlock)
+	}
+	cmsPages := admin.Group("/cms/pages")
+	{
+		cmsPages.GET("", cmsHandler.ListPages)
+		cmsPages.POST("", cmsHandler.CreatePage)
+		cmsPages.GET("/:id", cmsHandler.GetPage)
+		cmsPages.PUT("/:id", cmsHandler.UpdatePage)
+		cmsPages.DELETE("/:id", cmsHandler.DeletePage)
+	}
+	cmsAssets := admin.Group("/cms/assets")
+	{
+		cmsAssets.GET("", cmsHandler.ListAssets)
+		cmsAssets.POST("", cmsHandler.UploadAsset)
+		cmsAssets.DELETE("/:id", cmsHandler.DeleteAsset)
+	}
+
+	admin.GET("/storefront/settings", storefrontHandler.GetSettings)
+	admin.PUT("/storefront/settings", storefrontHandler.UpdateSettings)
+
+	shippingZones := admin.Group("/shipping/zones")
+	{
+		shippingZones.GET("", shippingHandler.ListZones)
+		shippingZones.POST("", shippingHandler.CreateZone)
+		shippingZones.PUT("/:id", shippingHandler.UpdateZone)
+		shippingZones.DELETE("/:id", shippingHandler.DeleteZone)
+	}
+
+	adminFlashSales := admin.Group("/flash-sales")
+	{
+		adminFlashSales.GET("", flashSaleHandler.ListSales)
+		adminFlashSales.POST("", flashSaleHandler.CreateSale)
+		adminFlashSales.GET("/:id", flashSaleHandler.GetSale)
+		adminFlashSales.PUT("/:id", flashSaleHandler.UpdateSale)
+		adminFlashSales.DELETE("/:id", flashSaleHandler.DeleteSale)
+	}
+
+	adminExperiments := admin.Group("/experiments")
+	{
+		adminExperiments.GET("", experimentHandler.ListExperiments)
+		adminExperiments.POST("", experimentHandler.CreateExperiment)
+		adminExperiments.PUT("/:id", experimentHandler.UpdateExperiment)
+	}
+
+	// Analytics and experiment reports, when there is an event store
+	if eventsDB != nil {
+		adminExperiments.GET("/:id/report", experimentHandler.GetExperimentReport)
+		analyticsRoutes := admin.Group("/analytics")
+		{
+			analyticsRoutes.GET("/sales", reportHandler.GetSalesAnalytics)
+			analyticsRoutes.GET("/products", reportHandler.GetProductAnalytics)
+			analyticsRoutes.GET("/users", reportHandler.GetUserAnalytics)
+			analyticsRoutes.GET("/revenue", reportHandler.GetRevenueAnalytics)
+		}
+	}
+
+	emailTemplates := admin.Group("/email-templates")
+	{
+		emailTemplates.GET("", emailTemplateHandler.ListTemplates)
+		emailTemplates.GET("/:name", emailTemplateHandler.GetTemplate)
+		emailTemplates.PUT("/:name", emailTemplateHandler.SaveTemplate)
+		emailTemplates.DELETE("/:name", emailTemplateHandler.DeleteTemplate)
+		emailTemplates.GET("/:name/versions", emailTemplateHandler.ListVersions)
+		emailTemplates.POST("/:name/versions/:version/activate", emailTemplateHandler.ActivateVersion)
+		emailTemplates.POST("/:name/preview", emailTemplateHandler.PreviewTemplate)
+		emailTemplates.POST("/:name/test", emailTemplateHandler.SendTestEmail)
+	}
+
+	emailSuppressions := admin.Group("/email-suppressions")
+	{
+		emailSuppressions.GET("", emailDeliveryHandler.ListSuppressions)
+		emailSuppressions.POST("", emailDeliveryHandler.AddSuppression)
+		emailSuppressions.GET("/:email", emailDeliveryHandler.GetSuppression)
+		emailSuppressions.DELETE("/:email", emailDeliveryHandler.RemoveSuppression)
+	}
+
+	emailDeadLetters := admin.Group("/email-dead-letters")
+	{
+		emailDeadLetters.GET("", emailDeliveryHandler.ListDeadLetters)
+		emailDeadLetters.GET("/:id", emailDeliveryHandler.GetDeadLetter)
+		emailDeadLetters.POST("/:id/resend", emailDeliveryHandler.ResendDeadLetter)
+	}
+	admin.POST("/email-campaigns", emailDeliveryHandler.SendCampaign)
+
+	campaigns := admin.Group("/campaigns")
+	{
+		campaigns.GET("", campaignHandler.ListCampaigns)
+		campaigns.POST("", campaignHandler.CreateCampaign)
+		campaigns.GET("/:id", campaignHandler.GetCampaign)
+		campaigns.PUT("/:id", campaignHandler.UpdateCampaign)
+		campaigns.POST("/:id/schedule", campaignHandler.ScheduleCampaign)
+		campaigns.POST("/:id/cancel", campaignHandler.CancelCampaign)
+		campaigns.GET("/:id/stats", campaignHandler.GetCampaignStats)
+		campaigns.GET("/:id/audience", campaignHandler.CountAudience)
+	}
+
+	whatsAppTemplates := admin.Group("/whatsapp-templates")
+	{
+		whatsAppTemplates.GET("", whatsAppHandler.ListTemplates)
+		whatsAppTemplates.POST("", whatsAppHandler.CreateTemplate)
+		whatsAppTemplates.POST("/sync", whatsAppHandler.SyncTemplates)
+		whatsAppTemplates.GET("/:name/:language", whatsAppHandler.GetTemplate)
+		whatsAppTemplates.DELETE("/:name", whatsAppHandler.DeleteTemplate)
+	}
+	whatsAppMessages := admin.Group("/whatsapp-messages")
+	{
+		whatsAppMessages.GET("", whatsAppHandler.ListMessages)
+		whatsAppMessages.GET("/:id", whatsAppHandler.GetMessage)
+	}
+
+	exports := admin.Group("/exports")
+	{
+		exports.POST("", exportHandler.RequestExport)
+		exports.GET("", exportHandler.ListExports)
+		exports.GET("/:id", exportHandler.GetExport)
+	}
+
+	reconciliationRuns := admin.Group("/reconciliation/runs")
+	{
+		reconciliationRuns.GET("", reconciliationHandler.ListRuns)
+		reconciliationRuns.POST("", reconciliationHandler.TriggerRun)
+		reconciliationRuns.GET("/:id", reconciliationHandler.GetRun)
+	}
+
+	fraudReviews := admin.Group("/fraud/reviews")
+	{
+		fraudReviews.GET("", fraudHandler.ListReviews)
+		fraudReviews.GET("/:id", fraudHandler.GetReview)
+		fraudReviews.POST("/:id/approve", fraudHandler.ApproveOrder)
+		fraudReviews.POST("/:id/decline", fraudHandler.DeclineOrder)
+	}
+
+	cashOnDelivery := admin.Group("/cod")
+	{
+		cashOnDelivery.GET("/collections", codHandler.ListCollections)
+		cashOnDelivery.GET("/couriers", codHandler.ListCourierBalances)
+		cashOnDelivery.GET("/remittances", codHandler.ListRemittances)
+		cashOnDelivery.POST("/remittances", codHandler.RecordRemittance)
+		cashOnDelivery.GET("/remittances/:id", codHandler.GetRemittance)
+		cashOnDelivery.GET("/customers/:id", codHandler.GetCustomer)
+		cashOnDelivery.PUT("/customers/:id", codHandler.SetCustomerLimit)
+	}
+
+	organizations := admin.Group("/organizations")
+	{
+		organizations.GET("", organizationHandler.ListOrganizations)
+		organizations.PUT("/:id/credit", organizationHandler.SetCredit)
+		organizations.GET("/invoices", organizationHandler.ListAllInvoices)
+		organizations.POST("/invoices/:id/pay", organizationHandler.RecordInvoicePayment)
+	}
+
+	queuedJobs := admin.Group("/jobs")
+	{
+		queuedJobs.GET("", jobHandler.ListJobs)
+		queuedJobs.GET("/:id", jobHandler.GetJob)
+		queuedJobs.POST("/:id/retry", jobHandler.RetryJob)
+		queuedJobs.POST("/:id/cancel", jobHandler.CancelJob)
+	}
+
+	admin.GET("/audit-logs", auditHandler.ListAuditLogs)
+	admin.GET("/config", configHandler.GetConfig)
+
+	system := admin.Group("/system")
+	{
+		system.GET("/logs", systemLogHandler.SearchLogs)
+		system.POST("/backup", systemHandler.TriggerBackup)
+		system.GET("/backups", systemHandler.ListBackups)
+		system.GET("/backups/:id", systemHandler.GetBackup)
+		system.GET("/health", systemHandler.GetHealth)
+		system.POST("/cache/clear", cacheHandler.ClearCache)
+		system.GET("/maintenance", maintenanceHandler.GetMaintenance)
+		system.PUT("/maintenance", maintenanceHandler.SetMaintenance)
+		system.POST("/maintenance/windows", maintenanceHandler.ScheduleWindow)
+		system.DELETE("/maintenance/windows/:id", maintenanceHandler.CancelWindow)
+	}
+
+	// Merchant routes: merchants price the quotes asked of them and draft
+	// orders for customers to pay by link; admins see to everyone's
+	merchant := r.Group("/merchant", authMiddleware.RequireAuth(), authMiddleware.RequireRole(string(user.RoleMerchant), string(user.RoleAdmin)), auditMiddleware)
+	merchantQuotes := merchant.Group("/quotes")
+	{
+		merchantQuotes.GET("", quoteHandler.ListMerchantQuotes)
+		merchantQuotes.GET("/:id", quoteHandler.GetMerchantQuote)
+		merchantQuotes.POST("/:id/offer", quoteHandler.OfferQuote)
+		merchantQuotes.POST("/:id/decline", quoteHandler.DeclineQuote)
+	}
+	draftOrders := merchant.Group("/draft-orders")
+	{
+		draftOrders.GET("", draftOrderHandler.ListDrafts)
+		draftOrders.POST("", draftOrderHandler.CreateDraft)
+		draftOrders.GET("/:id", draftOrderHandler.GetDraft)
+		draftOrders.POST("/:id/send", draftOrderHandler.SendDraft)
+		draftOrders.POST("/:id/cancel", draftOrderHandler.CancelDraft)
+	}
+
 	// Start server
 	addr := cfg.Server.Host + ":" + cfg.Server.Port
 	log.Info("Starting server on ", addr)
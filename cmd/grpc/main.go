@@ -1,9 +1,22 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net"
+	"os"
+	"os/signal"
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/cod"
+	"online-shop/internal/domain/merchandising"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/recommendation"
+	"online-shop/internal/domain/shipping"
+	"online-shop/internal/domain/storefront"
 	"online-shop/internal/infrastructure/redis"
 	"online-shop/internal/infrastructure/database"
 	grpcServices "online-shop/internal/infrastructure/grpc"
@@ -12,8 +25,16 @@ import (
 	userPb "online-shop/online-shop/proto/user"
 	productPb "online-shop/online-shop/proto/product"
 	orderPb "online-shop/online-shop/proto/order"
+	"online-shop/pkg/apperror"
 	"online-shop/pkg/config"
+	"online-shop/pkg/fieldcrypt"
+	"online-shop/pkg/i18n"
+	"online-shop/pkg/id"
 	"online-shop/pkg/jwt"
+	"online-shop/pkg/logger"
+	"online-shop/pkg/secrets"
+	"syscall"
+	"time"
 	"go.uber.org/zap"
 
 	"google.golang.org/grpc"
@@ -23,48 +44,40 @@ import (
 )
 
 func main() {
+	// Load configuration; until it is, entries use the default logger config
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Zap().Fatal("Failed to load config", zap.Error(err))
+	}
+
 	// Initialize logger
-	logr, _ := zap.NewProduction()
+	if err := logger.Init(&cfg.Logger); err != nil {
+		logger.Zap().Fatal("Failed to initialize logger", zap.Error(err))
+	}
+	logr := logger.Zap()
 	defer logr.Sync()
+	logger.RotateOn(syscall.SIGHUP)
 	logr.Info("Starting Online Shop gRPC Server...")
 
-	// Load configuration
-	cfg, err := config.LoadConfig()
+	// Resolve secrets referenced from the config; raw keeps the references
+	// so rotated values can be watched
+	secretsManager, err := secrets.New(&cfg.Secrets)
 	if err != nil {
-		logr.Error("Failed to load config", zap.Error(err))
-		// Use default config
-		cfg = &config.Config{
-			GRPC: config.GRPCConfig{
-				Host: "0.0.0.0",
-				Port: "12001",
-			},
-			Database: config.DatabaseConfig{
-				Host:     "localhost",
-				Port:     "5432",
-				User:     "postgres",
-				Password: "password",
-				DBName:   "online_shop",
-				SSLMode:  "disable",
-			},
-			Redis: config.RedisConfig{
-				Host:     "localhost",
-				Port:     "6379",
-				Password: "",
-				DB:       0,
-			},
-			Elasticsearch: config.ElasticsearchConfig{
-				URL: "http://localhost:9200",
-			},
-			JWT: config.JWTConfig{
-				SecretKey:   "your-secret-key",
-				ExpiryHours: 24,
-			},
-			Midtrans: config.MidtransConfig{
-				ServerKey:   "your-server-key",
-				ClientKey:   "your-client-key",
-				Environment: "sandbox",
-			},
-		}
+		logr.Fatal("Failed to initialize secrets provider", zap.Error(err))
+	}
+	raw := *cfg
+	if err := secretsManager.ResolveConfig(context.Background(), cfg); err != nil {
+		logr.Fatal("Failed to resolve secrets", zap.Error(err))
+	}
+
+	// Initialize column encryption
+	if err := fieldcrypt.Configure(&cfg.Encryption); err != nil {
+		logr.Fatal("Failed to initialize column encryption", zap.Error(err))
+	}
+
+	// Initialize ID generation
+	if err := id.SetNode(uint16(cfg.ID.NodeID)); err != nil {
+		logr.Fatal("Failed to initialize ID generation", zap.Error(err))
 	}
 
 	// Initialize database connection
@@ -76,6 +89,28 @@ func main() {
 		// Continue without database for now
 	}
 
+	// Product writes are versioned as they are through the API
+	if db != nil {
+		if err := db.Use(database.NewVersionPlugin()); err != nil {
+			logr.Fatal("Failed to version products", zap.Error(err))
+		}
+	}
+
+	// Product and category writes record the changes the search index and
+	// caches follow; the API's search sync job applies them
+	if db != nil && cfg.SearchSync.Outbox() {
+		if err := db.Use(database.NewChangeCapturePlugin(database.SearchSyncedTables...)); err != nil {
+			logr.Fatal("Failed to capture search changes", zap.Error(err))
+		}
+	}
+
+	// Commands run in a transaction only when there is a database
+	var transactor pipeline.Transactor
+	if db != nil {
+		transactor = database.NewTransactor(db)
+	}
+	pipeline.Use(pipeline.Standard(&cfg.Pipeline, logr, transactor))
+
 	// Initialize Redis client
 	redisAddr := fmt.Sprintf("%s:%s", cfg.Redis.Host, cfg.Redis.Port)
 	redisClient := redis.NewRedisClient(redisAddr, logr)
@@ -94,17 +129,50 @@ func main() {
 	}
 
 	// Initialize JWT service
-	jwtService := jwt.NewJWTManager(cfg.JWT.SecretKey, cfg.JWT.ExpiryHours)
+	jwtService, err := jwt.NewJWTManager(&cfg.JWT)
+	if err != nil {
+		logr.Fatal("Failed to initialize JWT service", zap.Error(err))
+	}
 
 	// Initialize payment provider
 	paymentProvider := payment.NewMidtransProvider(&cfg.Midtrans)
 
+	// Pick up rotated secrets without a restart
+	secretsManager.Watch(raw.JWT.SecretKey, jwtService.SetSecret)
+	secretsManager.Watch(raw.Midtrans.ServerKey, paymentProvider.SetServerKey)
+	// Create context for graceful shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		secretsTicker := time.NewTicker(cfg.Secrets.RefreshInterval())
+		defer secretsTicker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-secretsTicker.C:
+				if err := secretsManager.Refresh(ctx); err != nil {
+					logr.Error("Failed to refresh secrets", zap.Error(err))
+				}
+			}
+		}
+	}()
+
 	// Initialize repositories (only if database is available)
 	var userRepo *database.UserRepository
 	var productRepo *database.ProductRepository
 	var categoryRepo *database.CategoryRepository
 	var orderRepo *database.OrderRepository
 	var paymentRepo *database.PaymentRepository
+	var recommendationRepo recommendation.Repository
+	var priceHistoryRepo product.PriceHistoryRepository
+	var ruleRepo merchandising.Repository
+	var orderNumbers order.NumberGenerator
+	var payOnDeliveryHandler *commands.PayOnDeliveryCommandHandler
+	var cancelOrderHandler *commands.CancelOrderCommandHandler
+	var webhookPublisher *commands.WebhookPublisher
 
 	if db != nil {
 		userRepo = database.NewUserRepository(db).(*database.UserRepository)
@@ -112,26 +180,93 @@ func main() {
 		categoryRepo = database.NewCategoryRepository(db).(*database.CategoryRepository)
 		orderRepo = database.NewOrderRepository(db).(*database.OrderRepository)
 		paymentRepo = database.NewPaymentRepository(db).(*database.PaymentRepository)
+		recommendationRepo = database.NewRecommendationRepository(db)
+		priceHistoryRepo = database.NewPriceHistoryRepository(db)
+		ruleRepo = database.NewMerchandisingRuleRepository(db)
+		storefrontSettings := queries.NewGetStorefrontSettingsQueryHandler(
+			database.NewStorefrontSettingsRepository(db),
+			redis.NewStorefrontSettingsCache(redis.NewClient(&cfg.Redis)),
+			storefront.Defaults(cfg.Storefront.StoreName, cfg.Storefront.Currency, cfg.Orders.NumberPrefix, i18n.Locales(), i18n.DefaultLocale),
+			cfg.Storefront.CacheTTL(),
+		)
+		orderNumbers = database.NewOrderNumberSequence(db, cfg.Orders.NumberPrefix, cfg.Orders.NumberDigits, storefrontSettings)
+		if cfg.Webhooks.Enabled {
+			webhookPublisher = commands.NewWebhookPublisher(database.NewWebhookEndpointRepository(db), database.NewWebhookDeliveryRepository(db))
+		}
+
+		var codLimits *cod.Limits
+		if cfg.COD.Enabled {
+			codLimits = &cod.Limits{
+				MaxOrderAmount:       cfg.COD.MaxOrderAmount,
+				MaxOutstandingAmount: cfg.COD.MaxOutstandingAmount,
+				MaxOpenOrders:        cfg.COD.MaxOpenOrders,
+			}
+		}
+		// Orders paid on delivery have their delivery estimated again when
+		// confirmed; this server queues no confirmation emails
+		deliveryLocation, err := cfg.Shipping.Delivery.Location()
+		if err != nil {
+			logr.Fatal("Failed to load delivery time zone", zap.Error(err))
+		}
+		deliverySchedule, err := shipping.NewSchedule(deliveryLocation, cfg.Shipping.Delivery.SkipWeekends, cfg.Shipping.Delivery.Holidays)
+		if err != nil {
+			logr.Fatal("Failed to load delivery holidays", zap.Error(err))
+		}
+		orderConfirmer := commands.NewOrderConfirmer(database.NewWarehouseRepository(db), userRepo, productRepo, nil, deliverySchedule, shipping.Processing{
+			Days:       cfg.Shipping.Delivery.ProcessingDays,
+			CutoffHour: cfg.Shipping.Delivery.CutoffHour,
+		})
+		payOnDeliveryHandler = commands.NewPayOnDeliveryCommandHandler(orderRepo, paymentRepo, database.NewCODRepository(db), database.NewCODLimitRepository(db), codLimits, orderConfirmer)
+		cancelOrderHandler = commands.NewCancelOrderCommandHandler(orderRepo, productRepo, database.NewStockRepository(db), redis.NewFlashSaleCounter(redis.NewClient(&cfg.Redis)), paymentRepo, paymentProvider, order.CancellationPolicy{
+			AfterShipment: cfg.Orders.Cancellation.AfterShipment,
+			FlatFee:       cfg.Orders.Cancellation.FlatFee,
+			FeeRate:       cfg.Orders.Cancellation.FeeRate,
+		}, commands.NewBundleStocker(productRepo, database.NewBundleRepository(db)))
+	}
+
+	// Initialize idempotency store
+	idempotencyStore := redis.NewIdempotencyStore(redis.NewClient(&cfg.Redis))
+	if cfg.Idempotency.Store == "postgres" && db != nil {
+		idempotencyStore = database.NewIdempotencyRepository(db)
 	}
 
 	// Create gRPC server
-	server := grpc.NewServer()
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			grpcServices.DeadlineInterceptor(cfg.GRPC.RequestTimeout()),
+			apperror.UnaryServerInterceptor(logr),
+			grpcServices.IdempotencyInterceptor(idempotencyStore, cfg.Idempotency.TTL(), logr),
+		),
+	)
 
 	// Initialize and register gRPC services
 	if userRepo != nil {
-		userService := grpcServices.NewUserServiceServer(userRepo, redisClient, jwtService, logr)
+		sessionStore := redis.NewSessionStore(redis.NewClient(&cfg.Redis))
+		userService := grpcServices.NewUserServiceServer(
+			userRepo,
+			redisClient,
+			jwtService,
+			sessionStore,
+			commands.NewStartSessionCommandHandler(sessionStore, jwtService.RefreshExpiry()),
+			commands.NewRefreshSessionCommandHandler(sessionStore, jwtService.RefreshExpiry()),
+			commands.NewRevokeSessionCommandHandler(sessionStore),
+			logr,
+		)
 		userPb.RegisterUserServiceServer(server, userService)
 		logr.Info("UserService registered")
 	}
 
 	if productRepo != nil && categoryRepo != nil {
-		productService := grpcServices.NewProductServiceServer(productRepo, categoryRepo, redisClient, searchService, logr)
+		productService := grpcServices.NewProductServiceServer(productRepo, categoryRepo, redisClient, searchService, recommendationRepo, priceHistoryRepo, ruleRepo, webhookPublisher, logr)
+		if cfg.SearchSync.Outbox() {
+			productService.SyncThroughOutbox()
+		}
 		productPb.RegisterProductServiceServer(server, productService)
 		logr.Info("ProductService registered")
 	}
 
 	if orderRepo != nil && productRepo != nil && userRepo != nil && paymentRepo != nil {
-		orderService := grpcServices.NewOrderServiceServer(orderRepo, productRepo, userRepo, paymentRepo, redisClient, paymentProvider, logr)
+		orderService := grpcServices.NewOrderServiceServer(orderRepo, productRepo, userRepo, paymentRepo, redisClient, paymentProvider, payOnDeliveryHandler, cancelOrderHandler, orderNumbers, webhookPublisher, logr)
 		orderPb.RegisterOrderServiceServer(server, orderService)
 		logr.Info("OrderService registered")
 	}
@@ -149,6 +284,17 @@ func main() {
 	logr.Info("gRPC Server starting on", zap.String("address", address))
 	logr.Info("gRPC reflection enabled for debugging")
 
+	// Stop the server and background work on an interrupt signal
+	go func() {
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+		<-sigChan
+		logr.Info("Received shutdown signal, stopping gRPC server...")
+		cancel()
+		server.GracefulStop()
+	}()
+
 	// Start server
 	if err := server.Serve(listener); err != nil {
 		log.Fatalf("Failed to serve gRPC server: %v", err)
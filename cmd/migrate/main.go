@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"online-shop/internal/infrastructure/database"
+	"online-shop/pkg/config"
+	"online-shop/pkg/fieldcrypt"
+	"online-shop/pkg/logger"
+)
+
+func main() {
+	reencrypt := flag.Bool("reencrypt", false, "move encrypted columns onto the active key and encrypt plaintext values")
+	flag.Parse()
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		panic("Failed to load config: " + err.Error())
+	}
+
+	// Initialize logger
+	if err := logger.Init(&cfg.Logger); err != nil {
+		panic("Failed to initialize logger: " + err.Error())
+	}
+	log := logger.GetLogger()
+
+	// Initialize column encryption
+	if err := fieldcrypt.Configure(&cfg.Encryption); err != nil {
+		log.Fatal("Failed to initialize column encryption: ", err)
+	}
+
+	// Initialize database
+	db, err := database.NewDatabase(&cfg.Database)
+	if err != nil {
+		log.Fatal("Failed to connect to database: ", err)
+	}
+	defer db.Close()
+
+	// Run migrations
+	if err := db.Migrate(); err != nil {
+		log.Fatal("Failed to run migrations: ", err)
+	}
+	log.Info("Database migrated")
+
+	if !*reencrypt {
+		return
+	}
+
+	keyring := fieldcrypt.Current()
+	if keyring == nil {
+		log.Fatal("Re-encryption needs encryption keys to be configured")
+	}
+
+	log.Info("Re-encrypting columns with key ", keyring.ActiveKeyID())
+	updated, err := database.NewReencryptor(db.DB, keyring, cfg.Encryption.BatchSize).Run(context.Background())
+	for table, rows := range updated {
+		log.Infof("Re-encrypted %d rows in %s", rows, table)
+	}
+	if err != nil {
+		log.Fatal("Re-encryption stopped: ", err)
+	}
+}
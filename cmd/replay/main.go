@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"online-shop/internal/application/commands"
+	"online-shop/internal/infrastructure/database"
+	"online-shop/internal/infrastructure/elasticsearch"
+	"online-shop/internal/infrastructure/eventstore"
+	"online-shop/internal/infrastructure/redis"
+	"online-shop/pkg/config"
+	"online-shop/pkg/fieldcrypt"
+	"online-shop/pkg/i18n"
+	"online-shop/pkg/logger"
+	"os"
+	"strings"
+	"time"
+)
+
+func main() {
+	from := flag.String("from", "", "replay events recorded at or after this time (RFC 3339)")
+	to := flag.String("to", "", "replay events recorded before this time (RFC 3339), now by default")
+	productID := flag.String("product", "", "only replay the events of this product")
+	orderID := flag.String("order", "", "only replay the events of this order")
+	userID := flag.String("user", "", "only replay the events of this user")
+	projections := flag.String("projections", "", "comma-separated projections to rebuild: search, trending, cache (all by default)")
+	dryRun := flag.Bool("dry-run", false, "report what the replay would rebuild without writing anything")
+	flag.Parse()
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		panic("Failed to load config: " + err.Error())
+	}
+
+	// Initialize logger
+	if err := logger.Init(&cfg.Logger); err != nil {
+		panic("Failed to initialize logger: " + err.Error())
+	}
+	log := logger.GetLogger()
+
+	cmd := commands.ReplayEventsCommand{
+		ProductID: *productID,
+		OrderID:   *orderID,
+		UserID:    *userID,
+		DryRun:    *dryRun,
+		To:        time.Now(),
+	}
+	if cmd.From, err = time.Parse(time.RFC3339, *from); err != nil {
+		log.Fatal("-from must be an RFC 3339 time: ", err)
+	}
+	if *to != "" {
+		if cmd.To, err = time.Parse(time.RFC3339, *to); err != nil {
+			log.Fatal("-to must be an RFC 3339 time: ", err)
+		}
+	}
+	if *projections != "" {
+		for _, name := range strings.Split(*projections, ",") {
+			cmd.Projections = append(cmd.Projections, strings.ToLower(strings.TrimSpace(name)))
+		}
+	}
+
+	if cfg.Analytics.Sink != "timescale" {
+		log.Fatal("Replaying events needs the timescale analytics sink")
+	}
+
+	// Initialize column encryption
+	if err := fieldcrypt.Configure(&cfg.Encryption); err != nil {
+		log.Fatal("Failed to initialize column encryption: ", err)
+	}
+
+	// Initialize databases
+	db, err := database.NewDatabase(&cfg.Database)
+	if err != nil {
+		log.Fatal("Failed to connect to database: ", err)
+	}
+	defer db.Close()
+
+	eventsDB, err := database.NewDatabase(&cfg.Analytics.Database)
+	if err != nil {
+		log.Fatal("Failed to connect to analytics database: ", err)
+	}
+	defer eventsDB.Close()
+
+	// Initialize Redis and Elasticsearch
+	redisClient := redis.NewClient(&cfg.Redis)
+	defer redisClient.Close()
+
+	esClient, err := elasticsearch.NewClient(&cfg.Elasticsearch)
+	if err != nil {
+		log.Fatal("Failed to connect to Elasticsearch: ", err)
+	}
+
+	handler := commands.NewReplayEventsCommandHandler(
+		eventstore.NewTimescaleEventReader(eventsDB.DB),
+		database.NewProductRepository(db.DB),
+		elasticsearch.NewLocalizedIndex(elasticsearch.NewSearchService(esClient), database.NewTranslationRepository(db.DB), i18n.TranslatedLocales()),
+		redis.NewTrendingStore(redisClient),
+		redis.NewCacheService(redisClient),
+		redis.NewCacheInvalidator(redisClient),
+	)
+
+	if cmd.DryRun {
+		log.Info("Dry run, nothing is written")
+	}
+	result, err := handler.Handle(context.Background(), cmd)
+	if result != nil {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(result)
+	}
+	if err != nil {
+		log.Fatal("Replay stopped: ", err)
+	}
+}
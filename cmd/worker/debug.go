@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"online-shop/internal/infrastructure/queue"
+	"online-shop/pkg/workerpool"
+)
+
+// debugState is the worker's live state served on /debug
+type debugState struct {
+	StartedAt     time.Time              `json:"started_at"`
+	UptimeSeconds float64                `json:"uptime_seconds"`
+	Goroutines    int                    `json:"goroutines"`
+	Queues        []queue.ConsumerState  `json:"queues"`
+	Pools         []workerpool.PoolState `json:"pools"`
+}
+
+// newDebugServer serves the worker's Prometheus metrics on /metrics and the
+// live state of its queue consumers and worker pools on /debug
+func newDebugServer(addr string, rabbitmq *queue.RabbitMQ, startedAt time.Time) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/debug", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(debugState{
+			StartedAt:     startedAt,
+			UptimeSeconds: time.Since(startedAt).Seconds(),
+			Goroutines:    runtime.NumGoroutine(),
+			Queues:        rabbitmq.ConsumerStates(),
+			Pools:         workerpool.States(),
+		})
+	})
+
+	return &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+}
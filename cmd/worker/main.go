@@ -2,36 +2,85 @@ package main
 
 import (
 	"context"
+	"net/http"
 	"os"
 	"os/signal"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/sirupsen/logrus"
 	"go.uber.org/zap"
 
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/analytics"
+	"online-shop/internal/domain/campaign"
+	"online-shop/internal/domain/emaildelivery"
+	"online-shop/internal/domain/notification"
+	"online-shop/internal/domain/whatsapp"
+	"online-shop/internal/infrastructure/archive"
+	"online-shop/internal/infrastructure/database"
+	"online-shop/internal/infrastructure/eventstore"
 	"online-shop/internal/infrastructure/queue"
+	"online-shop/internal/infrastructure/redis"
+	"online-shop/internal/infrastructure/spreadsheet"
+	"online-shop/internal/infrastructure/storage"
+	whatsappprovider "online-shop/internal/infrastructure/whatsapp"
 	"online-shop/internal/workers"
 	"online-shop/pkg/config"
+	"online-shop/pkg/fieldcrypt"
+	"online-shop/pkg/id"
 	"online-shop/pkg/logger"
+	"online-shop/pkg/secrets"
 )
 
 func main() {
+	startedAt := time.Now()
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
 		panic("Failed to load configuration: " + err.Error())
 	}
 
-	// Initialize logger
-	log, err := logger.NewLogger(cfg.Environment)
-	if err != nil {
+	// Initialize logger; the workers use its logrus-compatible API
+	if err := logger.Init(&cfg.Logger); err != nil {
 		panic("Failed to initialize logger: " + err.Error())
 	}
+	log := logger.Zap()
 	defer log.Sync()
+	logger.RotateOn(syscall.SIGHUP)
+	workerLog := logger.GetLogger()
+
+	// Worker jobs are long-running batches, so they run without
+	// transactions
+	pipeline.Use(pipeline.Standard(&cfg.Pipeline, log, nil))
 
 	log.Info("Starting worker service", zap.String("environment", cfg.Environment))
 
+	// Resolve secrets referenced from the config; raw keeps the references
+	// so rotated values can be watched
+	secretsManager, err := secrets.New(&cfg.Secrets)
+	if err != nil {
+		log.Fatal("Failed to initialize secrets provider", zap.Error(err))
+	}
+	raw := *cfg
+	if err := secretsManager.ResolveConfig(context.Background(), cfg); err != nil {
+		log.Fatal("Failed to resolve secrets", zap.Error(err))
+	}
+
+	// Initialize column encryption
+	if err := fieldcrypt.Configure(&cfg.Encryption); err != nil {
+		log.Fatal("Failed to initialize column encryption", zap.Error(err))
+	}
+
+	// Initialize ID generation
+	if err := id.SetNode(uint16(cfg.ID.NodeID)); err != nil {
+		log.Fatal("Failed to initialize ID generation", zap.Error(err))
+	}
+
 	// Initialize RabbitMQ
 	rabbitmq, err := queue.NewRabbitMQ(cfg, log)
 	if err != nil {
@@ -39,11 +88,61 @@ func main() {
 	}
 	defer rabbitmq.Close()
 
+	// Initialize the broker carrying analytics events
+	eventBroker, closeEventBroker, err := queue.NewEventBroker(cfg, rabbitmq, log)
+	if err != nil {
+		log.Fatal("Failed to connect to the event broker", zap.Error(err))
+	}
+	defer closeEventBroker()
+
+	// Initialize analytics event store
+	eventSink, closeEventSink := newEventSink(cfg, log)
+	defer closeEventSink()
+
+	// Initialize product analytics stores
+	redisClient := redis.NewClient(&cfg.Redis)
+	trendingStore := redis.NewTrendingStore(redisClient)
+	recentlyViewedStore := redis.NewRecentlyViewedStore(redisClient)
+
+	// Initialize report export generation
+	exportGenerator, closeExports := newExportGenerator(cfg, rabbitmq, log)
+	defer closeExports()
+
+	// Initialize email templates edited in the admin API, the suppression
+	// list and the dead letters
+	emailTemplates, emailSuppressions, emailDeadLetters, closeEmailStores := newEmailStores(cfg, redisClient, log)
+	defer closeEmailStores()
+
+	// Initialize job tracking; the admin API lists, retries and cancels the
+	// jobs recorded
+	jobTracker, closeJobs := newJobTracker(cfg, workerLog, log)
+	defer closeJobs()
+
 	// Initialize workers
-	emailWorker := workers.NewEmailWorker(cfg, log)
-	invoiceWorker := workers.NewInvoiceWorker(cfg, log)
-	notificationWorker := workers.NewNotificationWorker(cfg, log)
-	analyticsWorker := workers.NewAnalyticsWorker(cfg, log)
+	emailWorker := workers.NewEmailWorker(cfg, workerLog)
+	emailWorker.SetTemplateStore(emailTemplates)
+	emailWorker.SetSuppressions(emailSuppressions)
+	emailWorker.SetDeadLetters(emailDeadLetters)
+	if cfg.Campaigns.Enabled {
+		emailWorker.SetTracker(campaign.NewTracker(cfg.Campaigns.TrackingURL, cfg.Campaigns.TrackingSecret))
+	}
+	invoiceWorker := workers.NewInvoiceWorker(cfg, workerLog)
+	notificationWorker := workers.NewNotificationWorker(cfg, workerLog)
+	if cfg.WhatsApp.Enabled {
+		whatsAppSender, closeWhatsApp := newWhatsAppSender(cfg, log)
+		defer closeWhatsApp()
+		notificationWorker.SetWhatsApp(whatsAppSender)
+	}
+	notificationPolicy, closePolicy := newNotificationPolicy(cfg, log)
+	defer closePolicy()
+	notificationWorker.SetPolicy(notificationPolicy)
+	emailWorker.SetPolicy(notificationPolicy)
+	if cfg.Notifications.UnsubscribeSecret != "" {
+		emailWorker.SetUnsubscribeLinks(notification.NewLinks(cfg.Notifications.BaseURL, cfg.Notifications.UnsubscribePageURL, cfg.Notifications.UnsubscribeSecret))
+	}
+	analyticsWorker := workers.NewAnalyticsWorker(cfg, workerLog, eventSink, trendingStore, recentlyViewedStore)
+	exportWorker := workers.NewExportWorker(cfg, workerLog, exportGenerator)
+	secretsManager.Watch(raw.SMTP.Password, emailWorker.SetSMTPPassword)
 
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -57,7 +156,7 @@ func main() {
 	go func() {
 		defer wg.Done()
 		log.Info("Starting email worker")
-		if err := rabbitmq.ConsumeMessages(ctx, queue.EmailQueue, emailWorker.ProcessMessage); err != nil {
+		if err := rabbitmq.ConsumeMessages(ctx, queue.EmailQueue, jobTracker.Track(queue.EmailQueue, cfg.Workers.JobTimeout(queue.EmailQueue), emailWorker.ProcessMessage)); err != nil {
 			log.Error("Email worker stopped", zap.Error(err))
 		}
 	}()
@@ -67,7 +166,7 @@ func main() {
 	go func() {
 		defer wg.Done()
 		log.Info("Starting invoice worker")
-		if err := rabbitmq.ConsumeMessages(ctx, queue.InvoiceQueue, invoiceWorker.ProcessMessage); err != nil {
+		if err := rabbitmq.ConsumeMessages(ctx, queue.InvoiceQueue, jobTracker.Track(queue.InvoiceQueue, cfg.Workers.JobTimeout(queue.InvoiceQueue), invoiceWorker.ProcessMessage)); err != nil {
 			log.Error("Invoice worker stopped", zap.Error(err))
 		}
 	}()
@@ -77,7 +176,7 @@ func main() {
 	go func() {
 		defer wg.Done()
 		log.Info("Starting notification worker")
-		if err := rabbitmq.ConsumeMessages(ctx, queue.NotificationQueue, notificationWorker.ProcessMessage); err != nil {
+		if err := rabbitmq.ConsumeMessages(ctx, queue.NotificationQueue, jobTracker.Track(queue.NotificationQueue, cfg.Workers.JobTimeout(queue.NotificationQueue), notificationWorker.ProcessMessage)); err != nil {
 			log.Error("Notification worker stopped", zap.Error(err))
 		}
 	}()
@@ -87,11 +186,59 @@ func main() {
 	go func() {
 		defer wg.Done()
 		log.Info("Starting analytics worker")
-		if err := rabbitmq.ConsumeMessages(ctx, queue.AnalyticsQueue, analyticsWorker.ProcessMessage); err != nil {
+		if err := eventBroker.Consume(ctx, queue.AnalyticsQueue, jobTracker.Track(queue.AnalyticsQueue, cfg.Workers.JobTimeout(queue.AnalyticsQueue), analyticsWorker.ProcessMessage)); err != nil {
 			log.Error("Analytics worker stopped", zap.Error(err))
 		}
 	}()
 
+	// Export worker
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		log.Info("Starting export worker")
+		if err := rabbitmq.ConsumeMessages(ctx, queue.ExportQueue, jobTracker.Track(queue.ExportQueue, cfg.Workers.JobTimeout(queue.ExportQueue), exportWorker.ProcessMessage)); err != nil {
+			log.Error("Export worker stopped", zap.Error(err))
+		}
+	}()
+
+	// Trending ranking refresher
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		refreshTicker := time.NewTicker(5 * time.Minute)
+		defer refreshTicker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-refreshTicker.C:
+				if err := trendingStore.Refresh(ctx, time.Now()); err != nil {
+					log.Error("Failed to refresh trending products", zap.Error(err))
+				}
+			}
+		}
+	}()
+
+	// Secrets refresher
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		secretsTicker := time.NewTicker(cfg.Secrets.RefreshInterval())
+		defer secretsTicker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-secretsTicker.C:
+				if err := secretsManager.Refresh(ctx); err != nil {
+					log.Error("Failed to refresh secrets", zap.Error(err))
+				}
+			}
+		}
+	}()
+
 	// Health check worker
 	wg.Add(1)
 	go func() {
@@ -107,10 +254,27 @@ func main() {
 				if err := rabbitmq.HealthCheck(); err != nil {
 					log.Error("RabbitMQ health check failed", zap.Error(err))
 				}
+				if eventBroker != queue.Broker(rabbitmq) {
+					if err := eventBroker.HealthCheck(); err != nil {
+						log.Error("Event broker health check failed", zap.Error(err))
+					}
+				}
 			}
 		}
 	}()
 
+	// Debug server with the metrics and the consumers' live state
+	var debugServer *http.Server
+	if cfg.Workers.DebugAddr != "" {
+		debugServer = newDebugServer(cfg.Workers.DebugAddr, rabbitmq, startedAt)
+		go func() {
+			log.Info("Starting debug server", zap.String("addr", cfg.Workers.DebugAddr))
+			if err := debugServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Error("Debug server stopped", zap.Error(err))
+			}
+		}()
+	}
+
 	log.Info("All workers started successfully")
 
 	// Wait for interrupt signal
@@ -137,5 +301,145 @@ func main() {
 		log.Warn("Timeout waiting for workers to stop")
 	}
 
+	if debugServer != nil {
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := debugServer.Shutdown(shutdownCtx); err != nil {
+			log.Warn("Failed to stop debug server", zap.Error(err))
+		}
+		cancelShutdown()
+	}
+
 	log.Info("Worker service shutdown complete")
-}
\ No newline at end of file
+}
+
+// newEventSink connects the configured analytics store. Events are only
+// processed for real-time metrics when no store is configured.
+func newEventSink(cfg *config.Config, log *zap.Logger) (workers.EventSink, func()) {
+	if cfg.Analytics.Sink != "timescale" {
+		log.Info("Analytics event store disabled", zap.String("sink", cfg.Analytics.Sink))
+		return nil, func() {}
+	}
+
+	db, err := database.NewDatabase(&cfg.Analytics.Database)
+	if err != nil {
+		log.Fatal("Failed to connect to analytics database", zap.Error(err))
+	}
+
+	writer := eventstore.NewTimescaleWriter(db.DB)
+	if err := writer.Migrate(); err != nil {
+		log.Fatal("Failed to migrate analytics database", zap.Error(err))
+	}
+
+	batch := eventstore.NewBatchWriter(writer, cfg.Analytics.BatchSize, cfg.Analytics.FlushInterval(), log)
+	batch.Start()
+
+	return batch, func() {
+		if err := batch.Close(context.Background()); err != nil {
+			log.Error("Failed to flush analytics events", zap.Error(err))
+		}
+		db.Close()
+	}
+}
+
+// newEmailStores reads stored email templates, cached in Redis, and the
+// suppression list and dead letters from the primary database
+func newEmailStores(cfg *config.Config, redisClient *redis.Client, log *zap.Logger) (*queries.GetEmailTemplateQueryHandler, emaildelivery.SuppressionRepository, emaildelivery.DeadLetterRepository, func()) {
+	db, err := database.NewDatabase(&cfg.Database)
+	if err != nil {
+		log.Fatal("Failed to connect to database", zap.Error(err))
+	}
+
+	store := queries.NewGetEmailTemplateQueryHandler(
+		database.NewEmailTemplateRepository(db.DB),
+		redis.NewEmailTemplateCache(redisClient),
+		cfg.SMTP.TemplateCacheTTL(),
+	)
+	return store, database.NewEmailSuppressionRepository(db.DB), database.NewEmailDeadLetterRepository(db.DB), func() { db.Close() }
+}
+
+// newJobTracker records the queue messages the workers handle in the jobs
+// table of the primary database
+func newJobTracker(cfg *config.Config, workerLog *logrus.Logger, log *zap.Logger) (*workers.JobTracker, func()) {
+	db, err := database.NewDatabase(&cfg.Database)
+	if err != nil {
+		log.Fatal("Failed to connect to database", zap.Error(err))
+	}
+
+	return workers.NewJobTracker(database.NewJobRepository(db.DB), workerLog), func() { db.Close() }
+}
+
+// newWhatsAppSender sends WhatsApp notifications through the Cloud API with
+// the templates and message log in the primary database
+func newWhatsAppSender(cfg *config.Config, log *zap.Logger) (*whatsapp.Sender, func()) {
+	db, err := database.NewDatabase(&cfg.Database)
+	if err != nil {
+		log.Fatal("Failed to connect to database", zap.Error(err))
+	}
+
+	sender := whatsapp.NewSender(
+		whatsappprovider.NewCloudAPI(&cfg.WhatsApp),
+		database.NewWhatsAppTemplateRepository(db.DB),
+		database.NewWhatsAppMessageRepository(db.DB),
+		database.NewConsentRepository(db.DB),
+	)
+	return sender, func() { db.Close() }
+}
+
+// newNotificationPolicy checks the users' marketing consents and
+// notification preferences in the primary database before sending
+func newNotificationPolicy(cfg *config.Config, log *zap.Logger) (*notification.Policy, func()) {
+	db, err := database.NewDatabase(&cfg.Database)
+	if err != nil {
+		log.Fatal("Failed to connect to database", zap.Error(err))
+	}
+
+	policy := notification.NewPolicy(database.NewConsentRepository(db.DB), database.NewNotificationPreferenceRepository(db.DB))
+	return policy, func() { db.Close() }
+}
+
+// newExportGenerator wires report generation against the primary database
+// and the configured object store. Personal data exports also read the
+// analytics event store when one is configured.
+func newExportGenerator(cfg *config.Config, rabbitmq *queue.RabbitMQ, log *zap.Logger) (*commands.GenerateExportCommandHandler, func()) {
+	db, err := database.NewDatabase(&cfg.Database)
+	if err != nil {
+		log.Fatal("Failed to connect to database", zap.Error(err))
+	}
+
+	var events analytics.EventReader
+	closeEvents := func() {}
+	if cfg.Analytics.Sink == "timescale" {
+		eventsDB, err := database.NewDatabase(&cfg.Analytics.Database)
+		if err != nil {
+			log.Fatal("Failed to connect to analytics database", zap.Error(err))
+		}
+		events = eventstore.NewTimescaleEventReader(eventsDB.DB)
+		closeEvents = func() { eventsDB.Close() }
+	}
+
+	store, err := storage.New(&cfg.Storage)
+	if err != nil {
+		log.Fatal("Failed to initialize export storage", zap.Error(err))
+	}
+
+	accounting, err := spreadsheet.Accounting(&cfg.Export.Accounting, cfg.Storefront.Currency)
+	if err != nil {
+		log.Fatal("Invalid accounting export configuration", zap.Error(err))
+	}
+
+	generator := commands.NewGenerateExportCommandHandler(
+		database.NewExportRepository(db.DB),
+		database.NewExportSource(db.DB, accounting),
+		store,
+		spreadsheet.Encoders(),
+		database.NewPersonalDataSource(db.DB, events),
+		archive.Archivers(),
+		queue.NewExportPublisher(rabbitmq),
+		cfg.Export.LinkTTL(),
+	)
+
+	return generator, func() {
+		db.Close()
+		closeEvents()
+	}
+}
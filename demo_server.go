@@ -10,24 +10,19 @@ import (
 )
 
 func main() {
-	// Initialize logger
-	logger.Init()
-	log := logger.GetLogger()
-	log.Info("🚀 Starting Online Shop Demo Server...")
-
 	// Load configuration
-	cfg, err := config.LoadConfig()
+	cfg, err := config.Load()
 	if err != nil {
-		log.Error("Failed to load config: ", err)
-		// Use default config
-		cfg = &config.Config{
-			Server: config.ServerConfig{
-				Host: "0.0.0.0",
-				Port: "12000",
-			},
-		}
+		logger.Fatal("Failed to load config: ", err)
 	}
 
+	// Initialize logger
+	if err := logger.Init(&cfg.Logger); err != nil {
+		logger.Fatal("Failed to initialize logger: ", err)
+	}
+	log := logger.GetLogger()
+	log.Info("🚀 Starting Online Shop Demo Server...")
+
 	// Create Gin router
 	router := gin.Default()
 
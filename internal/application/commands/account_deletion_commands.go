@@ -0,0 +1,146 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/session"
+	"online-shop/internal/domain/user"
+)
+
+type RequestAccountDeletionCommand struct {
+	UserID string `json:"user_id"`
+}
+
+type AccountDeletionResult struct {
+	UserID     string    `json:"user_id"`
+	EraseAfter time.Time `json:"erase_after"`
+}
+
+type RequestAccountDeletionCommandHandler struct {
+	userRepo    user.Repository
+	sessions    session.Store
+	publisher   user.DeletionPublisher
+	gracePeriod time.Duration
+}
+
+func NewRequestAccountDeletionCommandHandler(
+	userRepo user.Repository,
+	sessions session.Store,
+	publisher user.DeletionPublisher,
+	gracePeriod time.Duration,
+) *RequestAccountDeletionCommandHandler {
+	return &RequestAccountDeletionCommandHandler{
+		userRepo:    userRepo,
+		sessions:    sessions,
+		publisher:   publisher,
+		gracePeriod: gracePeriod,
+	}
+}
+
+// Handle disables the account and signs out all of its sessions. The data is
+// erased by EraseAccountsCommandHandler after the grace period.
+func (h *RequestAccountDeletionCommandHandler) Handle(ctx context.Context, cmd RequestAccountDeletionCommand) (*AccountDeletionResult, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *RequestAccountDeletionCommandHandler) handle(ctx context.Context, cmd RequestAccountDeletionCommand) (*AccountDeletionResult, error) {
+	u, err := h.userRepo.GetByID(ctx, cmd.UserID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	now := time.Now()
+	if err := u.RequestDeletion(now); err != nil {
+		return nil, err
+	}
+
+	// Events go out before the change is stored, so a failure is retried by
+	// the caller and consumers see each deletion at least once
+	eraseAfter := now.Add(h.gracePeriod)
+	err = h.publisher.PublishDeletion(ctx, user.DeletionEvent{
+		Type:       user.EventDeletionRequested,
+		UserID:     u.ID,
+		OccurredAt: now,
+		EraseAfter: &eraseAfter,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.userRepo.Update(ctx, u); err != nil {
+		return nil, err
+	}
+
+	sessions, err := h.sessions.ListByUser(ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, sess := range sessions {
+		if err := h.sessions.Delete(ctx, sess); err != nil {
+			return nil, err
+		}
+	}
+
+	return &AccountDeletionResult{UserID: u.ID, EraseAfter: eraseAfter}, nil
+}
+
+type EraseAccountsCommand struct {
+	GracePeriod time.Duration `json:"grace_period"`
+	BatchSize   int           `json:"batch_size"`
+}
+
+// NonTransactional: each account is erased, and its erasure announced, on
+// its own, so the accounts erased before a failure stay erased.
+func (EraseAccountsCommand) NonTransactional() {}
+
+type EraseAccountsCommandHandler struct {
+	erasureRepo user.ErasureRepository
+	publisher   user.DeletionPublisher
+}
+
+func NewEraseAccountsCommandHandler(erasureRepo user.ErasureRepository, publisher user.DeletionPublisher) *EraseAccountsCommandHandler {
+	return &EraseAccountsCommandHandler{
+		erasureRepo: erasureRepo,
+		publisher:   publisher,
+	}
+}
+
+// Handle erases the accounts whose grace period has passed and returns how
+// many were erased. It runs from the scheduler; an account that fails stays
+// pending and is retried on the next run.
+func (h *EraseAccountsCommandHandler) Handle(ctx context.Context, cmd EraseAccountsCommand) (int, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *EraseAccountsCommandHandler) handle(ctx context.Context, cmd EraseAccountsCommand) (int, error) {
+	if cmd.BatchSize <= 0 {
+		cmd.BatchSize = 100
+	}
+
+	now := time.Now()
+	users, err := h.erasureRepo.ListDueForErasure(ctx, now.Add(-cmd.GracePeriod), cmd.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	erased := 0
+	for _, u := range users {
+		err := h.publisher.PublishDeletion(ctx, user.DeletionEvent{
+			Type:       user.EventErased,
+			UserID:     u.ID,
+			OccurredAt: now,
+		})
+		if err != nil {
+			return erased, err
+		}
+
+		u.Anonymize(now)
+		if err := h.erasureRepo.Erase(ctx, u); err != nil {
+			return erased, err
+		}
+		erased++
+	}
+	return erased, nil
+}
@@ -0,0 +1,205 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/backup"
+)
+
+type TriggerBackupCommand struct {
+	RequestedBy string `json:"-"`
+}
+
+type TriggerBackupCommandHandler struct {
+	backupRepo backup.Repository
+	scheduler  backup.Scheduler
+}
+
+func NewTriggerBackupCommandHandler(backupRepo backup.Repository, scheduler backup.Scheduler) *TriggerBackupCommandHandler {
+	return &TriggerBackupCommandHandler{
+		backupRepo: backupRepo,
+		scheduler:  scheduler,
+	}
+}
+
+// Handle records a pending backup and wakes the backup job; progress is
+// polled through the backup's status.
+func (h *TriggerBackupCommandHandler) Handle(ctx context.Context, cmd TriggerBackupCommand) (*backup.Backup, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *TriggerBackupCommandHandler) handle(ctx context.Context, cmd TriggerBackupCommand) (*backup.Backup, error) {
+	for _, status := range []backup.Status{backup.StatusPending, backup.StatusRunning} {
+		active, err := h.backupRepo.Latest(ctx, status)
+		if err != nil {
+			return nil, err
+		}
+		if active != nil {
+			return nil, ErrBackupInProgress
+		}
+	}
+
+	b := backup.NewBackup(backup.TriggerManual, cmd.RequestedBy)
+	if err := h.backupRepo.Create(ctx, b); err != nil {
+		return nil, err
+	}
+
+	if err := h.scheduler.Trigger(backup.JobName); err != nil {
+		b.Fail(err)
+		h.backupRepo.Update(ctx, b)
+		return nil, err
+	}
+
+	return b, nil
+}
+
+type RunBackupCommand struct {
+	Database  string
+	Interval  time.Duration
+	Retention time.Duration
+	Keep      int
+	Timeout   time.Duration
+}
+
+// NonTransactional, as a dump can run for minutes, up to the command's
+// Timeout.
+func (RunBackupCommand) NonTransactional() {}
+
+type RunBackupCommandHandler struct {
+	backupRepo backup.Repository
+	dumper     backup.Dumper
+	storage    backup.Storage
+}
+
+func NewRunBackupCommandHandler(backupRepo backup.Repository, dumper backup.Dumper, storage backup.Storage) *RunBackupCommandHandler {
+	return &RunBackupCommandHandler{
+		backupRepo: backupRepo,
+		dumper:     dumper,
+		storage:    storage,
+	}
+}
+
+// Handle runs every pending backup, starts a scheduled one when the last
+// successful backup is older than the interval, and then applies the
+// retention policy. It returns the backups it ran.
+func (h *RunBackupCommandHandler) Handle(ctx context.Context, cmd RunBackupCommand) ([]*backup.Backup, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *RunBackupCommandHandler) handle(ctx context.Context, cmd RunBackupCommand) ([]*backup.Backup, error) {
+	if cmd.Timeout <= 0 {
+		cmd.Timeout = time.Hour
+	}
+
+	if err := h.failAbandoned(ctx, cmd.Timeout); err != nil {
+		return nil, err
+	}
+
+	pending, err := h.backupRepo.ListByStatus(ctx, backup.StatusPending)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pending) == 0 && cmd.Interval > 0 {
+		last, err := h.backupRepo.Latest(ctx, backup.StatusCompleted)
+		if err != nil {
+			return nil, err
+		}
+		if last == nil || time.Since(last.CreatedAt) >= cmd.Interval {
+			b := backup.NewBackup(backup.TriggerScheduled, "")
+			if err := h.backupRepo.Create(ctx, b); err != nil {
+				return nil, err
+			}
+			pending = append(pending, b)
+		}
+	}
+
+	var firstErr error
+	for _, b := range pending {
+		if err := h.run(b, cmd); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if cmd.Retention > 0 {
+		if err := h.expire(ctx, cmd.Retention, cmd.Keep); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return pending, firstErr
+}
+
+// run is bounded by the backup's own timeout rather than the caller's
+// context, so a backup started from a request that goes away still finishes
+// and is recorded.
+func (h *RunBackupCommandHandler) run(b *backup.Backup, cmd RunBackupCommand) error {
+	ctx, cancel := context.WithTimeout(context.Background(), cmd.Timeout)
+	defer cancel()
+
+	b.Start()
+	if err := h.backupRepo.Update(ctx, b); err != nil {
+		return err
+	}
+
+	data, err := h.dumper.Dump(ctx)
+	if err != nil {
+		return h.fail(ctx, b, err)
+	}
+
+	key := backup.ObjectKey(cmd.Database, *b.StartedAt, h.dumper.Extension())
+	if err := h.storage.Put(ctx, key, "application/octet-stream", data); err != nil {
+		return h.fail(ctx, b, err)
+	}
+
+	b.Complete(key, int64(len(data)))
+	return h.backupRepo.Update(ctx, b)
+}
+
+func (h *RunBackupCommandHandler) fail(ctx context.Context, b *backup.Backup, err error) error {
+	b.Fail(err)
+	if updateErr := h.backupRepo.Update(ctx, b); updateErr != nil {
+		return updateErr
+	}
+	return fmt.Errorf("backup %s failed: %w", b.ID, err)
+}
+
+// failAbandoned marks backups left running by a process that died as failed,
+// otherwise they would block new manual backups forever.
+func (h *RunBackupCommandHandler) failAbandoned(ctx context.Context, timeout time.Duration) error {
+	running, err := h.backupRepo.ListByStatus(ctx, backup.StatusRunning)
+	if err != nil {
+		return err
+	}
+	for _, b := range running {
+		if b.StartedAt != nil && time.Since(*b.StartedAt) < timeout {
+			continue
+		}
+		b.Fail(fmt.Errorf("backup did not finish within %s", timeout))
+		if err := h.backupRepo.Update(ctx, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *RunBackupCommandHandler) expire(ctx context.Context, retention time.Duration, keep int) error {
+	completed, err := h.backupRepo.ListByStatus(ctx, backup.StatusCompleted)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range backup.Expired(completed, time.Now(), retention, keep) {
+		if err := h.storage.Delete(ctx, b.ObjectKey); err != nil {
+			return err
+		}
+		b.Expire()
+		if err := h.backupRepo.Update(ctx, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,346 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/badge"
+	"online-shop/internal/domain/flashsale"
+	"online-shop/internal/domain/product"
+)
+
+type CreateBadgeCommand struct {
+	Code     string `json:"code" validate:"required,max=50"`
+	Label    string `json:"label" validate:"required,notblank,max=50"`
+	Priority int    `json:"priority"`
+}
+
+// UpdateBadgeCommand edits a badge. The rule settings only mean something
+// for the built-in badges.
+type UpdateBadgeCommand struct {
+	Code      string   `json:"-" validate:"required"`
+	Label     *string  `json:"label" validate:"omitempty,notblank,max=50"`
+	Enabled   *bool    `json:"enabled"`
+	Priority  *int     `json:"priority"`
+	Days      *int     `json:"days" validate:"omitempty,min=0,max=3650"`
+	Limit     *int     `json:"limit" validate:"omitempty,min=0,max=1000"`
+	Threshold *int     `json:"threshold" validate:"omitempty,min=0"`
+	MinPrice  *float64 `json:"min_price" validate:"omitempty,min=0"`
+}
+
+type DeleteBadgeCommand struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type AssignProductBadgeCommand struct {
+	ProductID string     `json:"-" validate:"required"`
+	Code      string     `json:"code" validate:"required"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	CreatedBy string     `json:"-"`
+}
+
+type UnassignProductBadgeCommand struct {
+	ProductID string `json:"product_id" validate:"required"`
+	Code      string `json:"code" validate:"required"`
+}
+
+// wakeBadgeJob has the badge job apply a change now rather than on its next
+// run. The job is not registered when badges are disabled, in which case
+// there is nothing to wake.
+func wakeBadgeJob(scheduler badge.Scheduler) {
+	if scheduler != nil {
+		_ = scheduler.Trigger(badge.JobName)
+	}
+}
+
+func badgeDefinitions(ctx context.Context, badgeRepo badge.Repository) ([]*badge.Definition, error) {
+	stored, err := badgeRepo.ListDefinitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return badge.Definitions(stored), nil
+}
+
+type CreateBadgeCommandHandler struct {
+	badgeRepo badge.Repository
+}
+
+func NewCreateBadgeCommandHandler(badgeRepo badge.Repository) *CreateBadgeCommandHandler {
+	return &CreateBadgeCommandHandler{badgeRepo: badgeRepo}
+}
+
+// Handle creates a badge that is set on products by hand.
+func (h *CreateBadgeCommandHandler) Handle(ctx context.Context, cmd CreateBadgeCommand) (*badge.Definition, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *CreateBadgeCommandHandler) handle(ctx context.Context, cmd CreateBadgeCommand) (*badge.Definition, error) {
+	d, err := badge.NewDefinition(cmd.Code, cmd.Label, cmd.Priority)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := badgeDefinitions(ctx, h.badgeRepo)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := badge.Find(existing, d.Code); err == nil {
+		return nil, fmt.Errorf("%w: %s already exists", badge.ErrInvalidBadge, d.Code)
+	}
+
+	if err := h.badgeRepo.SaveDefinition(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+type UpdateBadgeCommandHandler struct {
+	badgeRepo badge.Repository
+	scheduler badge.Scheduler
+}
+
+func NewUpdateBadgeCommandHandler(badgeRepo badge.Repository, scheduler badge.Scheduler) *UpdateBadgeCommandHandler {
+	return &UpdateBadgeCommandHandler{badgeRepo: badgeRepo, scheduler: scheduler}
+}
+
+func (h *UpdateBadgeCommandHandler) Handle(ctx context.Context, cmd UpdateBadgeCommand) (*badge.Definition, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *UpdateBadgeCommandHandler) handle(ctx context.Context, cmd UpdateBadgeCommand) (*badge.Definition, error) {
+	existing, err := badgeDefinitions(ctx, h.badgeRepo)
+	if err != nil {
+		return nil, err
+	}
+	d, err := badge.Find(existing, cmd.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	if cmd.Label != nil {
+		d.Label = strings.TrimSpace(*cmd.Label)
+	}
+	if cmd.Enabled != nil {
+		d.Enabled = *cmd.Enabled
+	}
+	if cmd.Priority != nil {
+		d.Priority = *cmd.Priority
+	}
+	if cmd.Days != nil {
+		d.Days = *cmd.Days
+	}
+	if cmd.Limit != nil {
+		d.Limit = *cmd.Limit
+	}
+	if cmd.Threshold != nil {
+		d.Threshold = *cmd.Threshold
+	}
+	if cmd.MinPrice != nil {
+		d.MinPrice = *cmd.MinPrice
+	}
+	if err := d.Check(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if d.CreatedAt.IsZero() {
+		d.CreatedAt = now
+	}
+	d.UpdatedAt = now
+
+	if err := h.badgeRepo.SaveDefinition(ctx, d); err != nil {
+		return nil, err
+	}
+	wakeBadgeJob(h.scheduler)
+	return d, nil
+}
+
+type DeleteBadgeCommandHandler struct {
+	badgeRepo badge.Repository
+	scheduler badge.Scheduler
+}
+
+func NewDeleteBadgeCommandHandler(badgeRepo badge.Repository, scheduler badge.Scheduler) *DeleteBadgeCommandHandler {
+	return &DeleteBadgeCommandHandler{badgeRepo: badgeRepo, scheduler: scheduler}
+}
+
+// Handle deletes a badge created by an admin, taking it off the products it
+// was set on. The built-in badges can be disabled but not deleted.
+func (h *DeleteBadgeCommandHandler) Handle(ctx context.Context, cmd DeleteBadgeCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *DeleteBadgeCommandHandler) handle(ctx context.Context, cmd DeleteBadgeCommand) error {
+	if badge.IsBuiltIn(cmd.Code) {
+		return fmt.Errorf("%w: built-in badges can be disabled but not deleted", badge.ErrInvalidBadge)
+	}
+	if err := h.badgeRepo.DeleteDefinition(ctx, cmd.Code); err != nil {
+		return err
+	}
+	wakeBadgeJob(h.scheduler)
+	return nil
+}
+
+type AssignProductBadgeCommandHandler struct {
+	badgeRepo   badge.Repository
+	productRepo product.Repository
+	scheduler   badge.Scheduler
+}
+
+func NewAssignProductBadgeCommandHandler(badgeRepo badge.Repository, productRepo product.Repository, scheduler badge.Scheduler) *AssignProductBadgeCommandHandler {
+	return &AssignProductBadgeCommandHandler{badgeRepo: badgeRepo, productRepo: productRepo, scheduler: scheduler}
+}
+
+// Handle sets a badge on a product by hand, until ExpiresAt when it is
+// given. Setting it again replaces the expiry.
+func (h *AssignProductBadgeCommandHandler) Handle(ctx context.Context, cmd AssignProductBadgeCommand) (*badge.Assignment, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *AssignProductBadgeCommandHandler) handle(ctx context.Context, cmd AssignProductBadgeCommand) (*badge.Assignment, error) {
+	if _, err := h.productRepo.GetByID(ctx, cmd.ProductID); err != nil {
+		return nil, err
+	}
+	existing, err := badgeDefinitions(ctx, h.badgeRepo)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := badge.Find(existing, cmd.Code); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if cmd.ExpiresAt != nil && !cmd.ExpiresAt.After(now) {
+		return nil, fmt.Errorf("%w: expires_at must be in the future", badge.ErrInvalidBadge)
+	}
+
+	a := &badge.Assignment{
+		ProductID: cmd.ProductID,
+		Code:      cmd.Code,
+		ExpiresAt: cmd.ExpiresAt,
+		CreatedBy: cmd.CreatedBy,
+		CreatedAt: now,
+	}
+	if err := h.badgeRepo.Assign(ctx, a); err != nil {
+		return nil, err
+	}
+	wakeBadgeJob(h.scheduler)
+	return a, nil
+}
+
+type UnassignProductBadgeCommandHandler struct {
+	badgeRepo badge.Repository
+	scheduler badge.Scheduler
+}
+
+func NewUnassignProductBadgeCommandHandler(badgeRepo badge.Repository, scheduler badge.Scheduler) *UnassignProductBadgeCommandHandler {
+	return &UnassignProductBadgeCommandHandler{badgeRepo: badgeRepo, scheduler: scheduler}
+}
+
+// Handle takes a badge set by hand off a product. A badge its rules give
+// it stays.
+func (h *UnassignProductBadgeCommandHandler) Handle(ctx context.Context, cmd UnassignProductBadgeCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *UnassignProductBadgeCommandHandler) handle(ctx context.Context, cmd UnassignProductBadgeCommand) error {
+	if err := h.badgeRepo.Unassign(ctx, cmd.ProductID, cmd.Code); err != nil {
+		return err
+	}
+	wakeBadgeJob(h.scheduler)
+	return nil
+}
+
+type RefreshBadgesCommand struct {
+	BatchSize int
+}
+
+// NonTransactional: the catalog is walked in batches, and badges already
+// stored stay when a later batch fails.
+func (RefreshBadgesCommand) NonTransactional() {}
+
+type RefreshBadgesCommandHandler struct {
+	badgeRepo     badge.Repository
+	productRepo   product.Repository
+	flashSaleRepo flashsale.Repository
+}
+
+func NewRefreshBadgesCommandHandler(badgeRepo badge.Repository, productRepo product.Repository, flashSaleRepo flashsale.Repository) *RefreshBadgesCommandHandler {
+	return &RefreshBadgesCommandHandler{badgeRepo: badgeRepo, productRepo: productRepo, flashSaleRepo: flashSaleRepo}
+}
+
+// Handle evaluates the badge rules against every active product and stores
+// the badges of those whose badges changed, returning how many did.
+func (h *RefreshBadgesCommandHandler) Handle(ctx context.Context, cmd RefreshBadgesCommand) (int, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *RefreshBadgesCommandHandler) handle(ctx context.Context, cmd RefreshBadgesCommand) (int, error) {
+	if cmd.BatchSize <= 0 {
+		cmd.BatchSize = 500
+	}
+	now := time.Now()
+	rules, err := badgeDefinitions(ctx, h.badgeRepo)
+	if err != nil {
+		return 0, err
+	}
+
+	facts := badge.Facts{BestSellers: map[string]bool{}}
+	if d, _ := badge.Find(rules, badge.BestSeller); d != nil && d.Enabled {
+		ids, err := h.badgeRepo.BestSellers(ctx, now.AddDate(0, 0, -d.Days), d.Limit)
+		if err != nil {
+			return 0, err
+		}
+		for _, productID := range ids {
+			facts.BestSellers[productID] = true
+		}
+	}
+
+	updated := 0
+	var after *product.Position
+	for {
+		if err := ctx.Err(); err != nil {
+			return updated, err
+		}
+		products, err := h.productRepo.ListAfter(ctx, product.SearchFilter{Status: product.StatusActive}, after, cmd.BatchSize)
+		if err != nil {
+			return updated, err
+		}
+		if len(products) == 0 {
+			return updated, nil
+		}
+
+		ids := make([]string, len(products))
+		for i, p := range products {
+			ids[i] = p.ID
+		}
+		sales, err := h.flashSaleRepo.ListLive(ctx, ids, now)
+		if err != nil {
+			return updated, err
+		}
+		facts.OnSale = make(map[string]bool)
+		for _, s := range sales {
+			for _, item := range s.Items {
+				facts.OnSale[item.ProductID] = true
+			}
+		}
+		assignments, err := h.badgeRepo.Assignments(ctx, ids, now)
+		if err != nil {
+			return updated, err
+		}
+
+		for _, p := range products {
+			badges := badge.Evaluate(rules, p, assignments, facts, now)
+			if badge.Same(p.Badges, badges) {
+				continue
+			}
+			if err := h.badgeRepo.SetProductBadges(ctx, p.ID, badges); err != nil {
+				return updated, err
+			}
+			updated++
+		}
+
+		last := product.PositionOf(products[len(products)-1])
+		after = &last
+	}
+}
@@ -0,0 +1,147 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/banner"
+	"online-shop/internal/domain/cms"
+)
+
+type CreateBannerCommand struct {
+	Title    string     `json:"title" validate:"required"`
+	ImageURL string     `json:"image_url" validate:"required"`
+	LinkURL  string     `json:"link_url"`
+	Position int        `json:"position"`
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+}
+
+type UpdateBannerCommand struct {
+	BannerID string     `json:"banner_id" validate:"required"`
+	Title    *string    `json:"title"`
+	ImageURL *string    `json:"image_url"`
+	LinkURL  *string    `json:"link_url"`
+	Position *int       `json:"position"`
+	Active   *bool      `json:"active"`
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+}
+
+type DeleteBannerCommand struct {
+	BannerID string `json:"banner_id" validate:"required"`
+}
+
+// CreateBannerCommandHandler clears the cms cache, which serves the public
+// banner list
+type CreateBannerCommandHandler struct {
+	bannerRepo banner.Repository
+	cache      cms.Cache
+}
+
+func NewCreateBannerCommandHandler(bannerRepo banner.Repository, cache cms.Cache) *CreateBannerCommandHandler {
+	return &CreateBannerCommandHandler{bannerRepo: bannerRepo, cache: cache}
+}
+
+func (h *CreateBannerCommandHandler) Handle(ctx context.Context, cmd CreateBannerCommand) (*banner.Banner, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *CreateBannerCommandHandler) handle(ctx context.Context, cmd CreateBannerCommand) (*banner.Banner, error) {
+	b, err := banner.NewBanner(cmd.Title, cmd.ImageURL, cmd.LinkURL, cmd.Position, cmd.StartsAt, cmd.EndsAt)
+	if err != nil {
+		return nil, ErrInvalidBannerData
+	}
+
+	if err := h.bannerRepo.Create(ctx, b); err != nil {
+		return nil, err
+	}
+	clearCMSCache(h.cache)
+
+	return b, nil
+}
+
+// UpdateBannerCommandHandler clears the cms cache, which serves the public
+// banner list
+type UpdateBannerCommandHandler struct {
+	bannerRepo banner.Repository
+	cache      cms.Cache
+}
+
+func NewUpdateBannerCommandHandler(bannerRepo banner.Repository, cache cms.Cache) *UpdateBannerCommandHandler {
+	return &UpdateBannerCommandHandler{bannerRepo: bannerRepo, cache: cache}
+}
+
+func (h *UpdateBannerCommandHandler) Handle(ctx context.Context, cmd UpdateBannerCommand) (*banner.Banner, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *UpdateBannerCommandHandler) handle(ctx context.Context, cmd UpdateBannerCommand) (*banner.Banner, error) {
+	b, err := h.bannerRepo.GetByID(ctx, cmd.BannerID)
+	if err != nil {
+		return nil, ErrBannerNotFound
+	}
+
+	if cmd.Title != nil {
+		b.Title = *cmd.Title
+	}
+	if cmd.ImageURL != nil {
+		b.ImageURL = *cmd.ImageURL
+	}
+	if cmd.LinkURL != nil {
+		b.LinkURL = *cmd.LinkURL
+	}
+	if cmd.Position != nil {
+		b.Position = *cmd.Position
+	}
+	if cmd.Active != nil {
+		b.Active = *cmd.Active
+	}
+	if cmd.StartsAt != nil {
+		b.StartsAt = cmd.StartsAt
+	}
+	if cmd.EndsAt != nil {
+		b.EndsAt = cmd.EndsAt
+	}
+	if b.Title == "" || b.ImageURL == "" {
+		return nil, ErrInvalidBannerData
+	}
+	if b.StartsAt != nil && b.EndsAt != nil && !b.EndsAt.After(*b.StartsAt) {
+		return nil, ErrInvalidBannerData
+	}
+	b.UpdatedAt = time.Now()
+
+	if err := h.bannerRepo.Update(ctx, b); err != nil {
+		return nil, err
+	}
+	clearCMSCache(h.cache)
+
+	return b, nil
+}
+
+// DeleteBannerCommandHandler clears the cms cache, which serves the public
+// banner list
+type DeleteBannerCommandHandler struct {
+	bannerRepo banner.Repository
+	cache      cms.Cache
+}
+
+func NewDeleteBannerCommandHandler(bannerRepo banner.Repository, cache cms.Cache) *DeleteBannerCommandHandler {
+	return &DeleteBannerCommandHandler{bannerRepo: bannerRepo, cache: cache}
+}
+
+func (h *DeleteBannerCommandHandler) Handle(ctx context.Context, cmd DeleteBannerCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *DeleteBannerCommandHandler) handle(ctx context.Context, cmd DeleteBannerCommand) error {
+	if _, err := h.bannerRepo.GetByID(ctx, cmd.BannerID); err != nil {
+		return ErrBannerNotFound
+	}
+	if err := h.bannerRepo.Delete(ctx, cmd.BannerID); err != nil {
+		return err
+	}
+	clearCMSCache(h.cache)
+	return nil
+}
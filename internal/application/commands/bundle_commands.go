@@ -0,0 +1,145 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/product"
+)
+
+// BundleStocker keeps the stock of bundles at what the stock of their
+// components makes up. It is given to the handlers that change product
+// stock.
+type BundleStocker struct {
+	productRepo product.Repository
+	bundleRepo  product.BundleRepository
+}
+
+func NewBundleStocker(productRepo product.Repository, bundleRepo product.BundleRepository) *BundleStocker {
+	return &BundleStocker{
+		productRepo: productRepo,
+		bundleRepo:  bundleRepo,
+	}
+}
+
+// Refresh counts the stock of the bundles made of any of the products again.
+func (s *BundleStocker) Refresh(ctx context.Context, productIDs []string) error {
+	bundles, err := s.bundleRepo.ListBundlesOf(ctx, productIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, bundle := range bundles {
+		parts, err := s.productRepo.GetByIDs(ctx, bundle.StockedIDs())
+		if err != nil {
+			return err
+		}
+		byID := make(map[string]*product.Product, len(parts))
+		for _, p := range parts {
+			byID[p.ID] = p
+		}
+		stock := product.BundleStock(bundle.Components, byID)
+		if stock == bundle.Stock {
+			continue
+		}
+		if err := s.productRepo.UpdateStock(ctx, bundle.ID, stock-bundle.Stock); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refresh counts again the stock of the bundles made of the products of
+// items. Orders check the stock of the components themselves, so a bundle
+// whose count is left behind is only shown wrong until the next refresh,
+// and a refresh that fails does not fail the caller.
+func (s *BundleStocker) refresh(ctx context.Context, items []order.OrderItem) {
+	if s == nil {
+		return
+	}
+	productIDs := make([]string, 0, len(items))
+	for _, item := range items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	_ = s.Refresh(ctx, productIDs)
+}
+
+type BundleComponentCmd struct {
+	ProductID string `json:"product_id" validate:"required"`
+	Quantity  int    `json:"quantity" validate:"required,min=1,max=1000"`
+}
+
+// SetProductBundleCommand replaces the components of a bundle. A bundle
+// given no components is sold on its own again once it is restocked.
+type SetProductBundleCommand struct {
+	ProductID  string               `json:"-" validate:"required"`
+	Components []BundleComponentCmd `json:"components" validate:"max=20,dive"`
+}
+
+type SetProductBundleCommandHandler struct {
+	productRepo product.Repository
+	bundleRepo  product.BundleRepository
+	webhooks    *WebhookPublisher
+}
+
+func NewSetProductBundleCommandHandler(productRepo product.Repository, bundleRepo product.BundleRepository, webhooks *WebhookPublisher) *SetProductBundleCommandHandler {
+	return &SetProductBundleCommandHandler{
+		productRepo: productRepo,
+		bundleRepo:  bundleRepo,
+		webhooks:    webhooks,
+	}
+}
+
+func (h *SetProductBundleCommandHandler) Handle(ctx context.Context, cmd SetProductBundleCommand) (*product.Product, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *SetProductBundleCommandHandler) handle(ctx context.Context, cmd SetProductBundleCommand) (*product.Product, error) {
+	p, err := h.productRepo.GetByID(ctx, cmd.ProductID)
+	if err != nil {
+		return nil, ErrProductNotFound
+	}
+
+	components := make([]product.Component, len(cmd.Components))
+	ids := make([]string, len(cmd.Components))
+	for i, c := range cmd.Components {
+		components[i] = product.Component{ProductID: c.ProductID, Quantity: c.Quantity}
+		ids[i] = c.ProductID
+	}
+
+	// A product other bundles are made of cannot become a bundle itself
+	if len(components) > 0 {
+		bundles, err := h.bundleRepo.ListBundlesOf(ctx, []string{p.ID})
+		if err != nil {
+			return nil, err
+		}
+		if len(bundles) > 0 {
+			return nil, ErrInvalidBundle.WithDetail("product is a component of bundle %s", bundles[0].ID)
+		}
+	}
+
+	parts, err := h.productRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*product.Product, len(parts))
+	for _, part := range parts {
+		byID[part.ID] = part
+	}
+	if err := p.SetComponents(components, byID); err != nil {
+		return nil, err
+	}
+
+	p.UpdatedAt = time.Now()
+	if err := h.productRepo.Update(ctx, p); err != nil {
+		return nil, err
+	}
+	if h.webhooks != nil {
+		if err := h.webhooks.ProductUpdated(ctx, p); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
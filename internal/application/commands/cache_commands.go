@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"context"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/audit"
+	"online-shop/internal/domain/cache"
+)
+
+type ClearCacheCommand struct {
+	Scope     cache.Scope `json:"scope" binding:"required"`
+	Confirm   string      `json:"confirm"`
+	ActorID   string      `json:"-"`
+	ActorRole string      `json:"-"`
+	RequestID string      `json:"-"`
+}
+
+// NonTransactional, so even a partial flush is recorded in the audit log.
+func (ClearCacheCommand) NonTransactional() {}
+
+type CacheClearResult struct {
+	Scope           cache.Scope      `json:"scope"`
+	KeysInvalidated int64            `json:"keys_invalidated"`
+	Patterns        map[string]int64 `json:"patterns"`
+}
+
+type ClearCacheCommandHandler struct {
+	invalidator cache.Invalidator
+	auditRepo   audit.Repository
+	production  bool
+}
+
+func NewClearCacheCommandHandler(invalidator cache.Invalidator, auditRepo audit.Repository, environment string) *ClearCacheCommandHandler {
+	return &ClearCacheCommandHandler{
+		invalidator: invalidator,
+		auditRepo:   auditRepo,
+		production:  environment == "production",
+	}
+}
+
+// Handle deletes the keys of one cache scope and records the flush in the
+// audit log. In production, disruptive scopes must be confirmed by repeating
+// the scope name so a mistyped request cannot empty the whole cache.
+func (h *ClearCacheCommandHandler) Handle(ctx context.Context, cmd ClearCacheCommand) (*CacheClearResult, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *ClearCacheCommandHandler) handle(ctx context.Context, cmd ClearCacheCommand) (*CacheClearResult, error) {
+	patterns, err := cmd.Scope.Patterns()
+	if err != nil {
+		return nil, err
+	}
+	if h.production && cmd.Scope.Disruptive() && cmd.Confirm != string(cmd.Scope) {
+		return nil, ErrCacheFlushNotConfirmed
+	}
+
+	result := &CacheClearResult{Scope: cmd.Scope, Patterns: make(map[string]int64, len(patterns))}
+	var flushErr error
+	for _, pattern := range patterns {
+		n, err := h.invalidator.DeletePattern(ctx, pattern)
+		result.Patterns[pattern] = n
+		result.KeysInvalidated += n
+		if err != nil {
+			flushErr = err
+			break
+		}
+	}
+
+	// A partial flush is still recorded, it changed what clients are served
+	entry := audit.NewEntry(audit.SourceCommand, cmd.ActorID, "cache.clear", "cache", string(cmd.Scope))
+	entry.ActorRole = cmd.ActorRole
+	entry.RequestID = cmd.RequestID
+	entry.Changes = map[string]audit.Change{
+		"keys_invalidated": {Before: nil, After: result.KeysInvalidated},
+	}
+	if err := h.auditRepo.Create(ctx, entry); err != nil && flushErr == nil {
+		flushErr = err
+	}
+
+	if flushErr != nil {
+		return result, flushErr
+	}
+	return result, nil
+}
@@ -0,0 +1,343 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/campaign"
+	"online-shop/internal/domain/emaildelivery"
+	"online-shop/internal/domain/emailtemplate"
+	"online-shop/internal/domain/notification"
+)
+
+// CreateCampaignCommand composes a draft campaign. Data is shared by every
+// recipient, who each also get their FirstName and LastName.
+type CreateCampaignCommand struct {
+	Name      string                 `json:"name" validate:"required,notblank,max=200"`
+	Template  string                 `json:"template" validate:"required"`
+	Subject   string                 `json:"subject" validate:"max=300"`
+	Locale    string                 `json:"locale" validate:"omitempty,locale"`
+	Data      map[string]interface{} `json:"data"`
+	Segment   campaign.Segment       `json:"segment"`
+	CreatedBy string                 `json:"-"`
+}
+
+// UpdateCampaignCommand replaces the content of a campaign that has not
+// started sending.
+type UpdateCampaignCommand struct {
+	ID       string                 `json:"-" validate:"required"`
+	Name     string                 `json:"name" validate:"required,notblank,max=200"`
+	Template string                 `json:"template" validate:"required"`
+	Subject  string                 `json:"subject" validate:"max=300"`
+	Locale   string                 `json:"locale" validate:"omitempty,locale"`
+	Data     map[string]interface{} `json:"data"`
+	Segment  campaign.Segment       `json:"segment"`
+}
+
+// ScheduleCampaignCommand sends a campaign at SendAt, or straight away
+// without one.
+type ScheduleCampaignCommand struct {
+	ID     string     `json:"-" validate:"required"`
+	SendAt *time.Time `json:"send_at"`
+}
+
+type CancelCampaignCommand struct {
+	ID string `json:"id" validate:"required"`
+}
+
+// SendDueCampaignsCommand queues the campaigns that are due, BatchSize
+// recipients per email batch and at most BatchesPerSecond batches a second.
+type SendDueCampaignsCommand struct {
+	BatchSize        int
+	BatchesPerSecond float64
+}
+
+// TrackCampaignEventCommand records an open or click of a campaign email
+// from the token of its tracking link.
+type TrackCampaignEventCommand struct {
+	Token string `json:"token" validate:"required"`
+	Type  string `json:"type" validate:"required,oneof=open click"`
+}
+
+// checkCampaignTemplate checks the campaign's data against its template as
+// every recipient's email will be rendered with it.
+func checkCampaignTemplate(ctx context.Context, templateRepo emailtemplate.Repository, content campaign.Content) error {
+	t, err := emailtemplate.Resolve(ctx, templateRepo, content.Template, content.Locale, 0)
+	if err != nil {
+		return err
+	}
+	sample := map[string]interface{}{"FirstName": "", "LastName": ""}
+	return t.CheckData(emaildelivery.MergeData(content.Data, sample))
+}
+
+type CreateCampaignCommandHandler struct {
+	campaignRepo campaign.Repository
+	templateRepo emailtemplate.Repository
+}
+
+func NewCreateCampaignCommandHandler(campaignRepo campaign.Repository, templateRepo emailtemplate.Repository) *CreateCampaignCommandHandler {
+	return &CreateCampaignCommandHandler{campaignRepo: campaignRepo, templateRepo: templateRepo}
+}
+
+func (h *CreateCampaignCommandHandler) Handle(ctx context.Context, cmd CreateCampaignCommand) (*campaign.Campaign, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *CreateCampaignCommandHandler) handle(ctx context.Context, cmd CreateCampaignCommand) (*campaign.Campaign, error) {
+	content := campaign.Content{
+		Name:     cmd.Name,
+		Template: cmd.Template,
+		Subject:  cmd.Subject,
+		Locale:   cmd.Locale,
+		Data:     cmd.Data,
+		Segment:  cmd.Segment,
+	}
+	c, err := campaign.New(content, cmd.CreatedBy)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCampaignTemplate(ctx, h.templateRepo, content); err != nil {
+		return nil, err
+	}
+
+	if err := h.campaignRepo.Create(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+type UpdateCampaignCommandHandler struct {
+	campaignRepo campaign.Repository
+	templateRepo emailtemplate.Repository
+}
+
+func NewUpdateCampaignCommandHandler(campaignRepo campaign.Repository, templateRepo emailtemplate.Repository) *UpdateCampaignCommandHandler {
+	return &UpdateCampaignCommandHandler{campaignRepo: campaignRepo, templateRepo: templateRepo}
+}
+
+func (h *UpdateCampaignCommandHandler) Handle(ctx context.Context, cmd UpdateCampaignCommand) (*campaign.Campaign, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *UpdateCampaignCommandHandler) handle(ctx context.Context, cmd UpdateCampaignCommand) (*campaign.Campaign, error) {
+	c, err := h.campaignRepo.Get(ctx, cmd.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	content := campaign.Content{
+		Name:     cmd.Name,
+		Template: cmd.Template,
+		Subject:  cmd.Subject,
+		Locale:   cmd.Locale,
+		Data:     cmd.Data,
+		Segment:  cmd.Segment,
+	}
+	if err := c.Edit(content, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := checkCampaignTemplate(ctx, h.templateRepo, content); err != nil {
+		return nil, err
+	}
+
+	if err := h.campaignRepo.Update(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+type ScheduleCampaignCommandHandler struct {
+	campaignRepo campaign.Repository
+}
+
+func NewScheduleCampaignCommandHandler(campaignRepo campaign.Repository) *ScheduleCampaignCommandHandler {
+	return &ScheduleCampaignCommandHandler{campaignRepo: campaignRepo}
+}
+
+func (h *ScheduleCampaignCommandHandler) Handle(ctx context.Context, cmd ScheduleCampaignCommand) (*campaign.Campaign, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *ScheduleCampaignCommandHandler) handle(ctx context.Context, cmd ScheduleCampaignCommand) (*campaign.Campaign, error) {
+	c, err := h.campaignRepo.Get(ctx, cmd.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	sendAt := now
+	if cmd.SendAt != nil {
+		sendAt = *cmd.SendAt
+	}
+	if err := c.Schedule(sendAt, now); err != nil {
+		return nil, err
+	}
+
+	if err := h.campaignRepo.Update(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+type CancelCampaignCommandHandler struct {
+	campaignRepo campaign.Repository
+}
+
+func NewCancelCampaignCommandHandler(campaignRepo campaign.Repository) *CancelCampaignCommandHandler {
+	return &CancelCampaignCommandHandler{campaignRepo: campaignRepo}
+}
+
+func (h *CancelCampaignCommandHandler) Handle(ctx context.Context, cmd CancelCampaignCommand) (*campaign.Campaign, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *CancelCampaignCommandHandler) handle(ctx context.Context, cmd CancelCampaignCommand) (*campaign.Campaign, error) {
+	c, err := h.campaignRepo.Get(ctx, cmd.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Cancel(time.Now()); err != nil {
+		return nil, err
+	}
+
+	if err := h.campaignRepo.Update(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+type SendDueCampaignsCommandHandler struct {
+	campaignRepo campaign.Repository
+	audienceRepo campaign.AudienceRepository
+	publisher    emaildelivery.Publisher
+}
+
+func NewSendDueCampaignsCommandHandler(campaignRepo campaign.Repository, audienceRepo campaign.AudienceRepository, publisher emaildelivery.Publisher) *SendDueCampaignsCommandHandler {
+	return &SendDueCampaignsCommandHandler{campaignRepo: campaignRepo, audienceRepo: audienceRepo, publisher: publisher}
+}
+
+// Handle queues the due campaigns for the email worker and returns how many
+// recipients it queued. Each batch is recorded on the campaign once queued,
+// so a run that is interrupted carries on where it stopped; a campaign
+// canceled while sending stops at its next batch.
+func (h *SendDueCampaignsCommandHandler) Handle(ctx context.Context, cmd SendDueCampaignsCommand) (int, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *SendDueCampaignsCommandHandler) handle(ctx context.Context, cmd SendDueCampaignsCommand) (int, error) {
+	if cmd.BatchSize <= 0 {
+		cmd.BatchSize = 500
+	}
+	var interval time.Duration
+	if cmd.BatchesPerSecond > 0 {
+		interval = time.Duration(float64(time.Second) / cmd.BatchesPerSecond)
+	}
+
+	due, err := h.campaignRepo.ListDue(ctx, time.Now(), 10)
+	if err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	for _, c := range due {
+		n, err := h.send(ctx, c, cmd.BatchSize, interval)
+		queued += n
+		if err != nil {
+			return queued, fmt.Errorf("campaign %s: %w", c.ID, err)
+		}
+	}
+	return queued, nil
+}
+
+// send queues a campaign's batches at most one per interval
+func (h *SendDueCampaignsCommandHandler) send(ctx context.Context, c *campaign.Campaign, batchSize int, interval time.Duration) (int, error) {
+	if err := c.Start(time.Now()); err != nil {
+		return 0, err
+	}
+	if ok, err := h.campaignRepo.Progress(ctx, c); !ok || err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	var last time.Time
+	for {
+		members, err := h.audienceRepo.Audience(ctx, c.Segment, *c.StartedAt, c.Cursor, batchSize)
+		if err != nil {
+			return queued, err
+		}
+		if len(members) == 0 {
+			c.Complete(time.Now())
+			_, err := h.campaignRepo.Progress(ctx, c)
+			return queued, err
+		}
+
+		if wait := interval - time.Since(last); !last.IsZero() && wait > 0 {
+			select {
+			case <-ctx.Done():
+				return queued, ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+		last = time.Now()
+
+		recipients := make([]emaildelivery.Recipient, len(members))
+		for i, u := range members {
+			recipients[i] = emaildelivery.Recipient{To: u.Email, Data: campaign.RecipientData(u)}
+		}
+		err = h.publisher.PublishBatch(ctx, &emaildelivery.Batch{
+			CampaignID: c.ID,
+			Template:   c.Template,
+			Subject:    c.Subject,
+			Locale:     c.SendLocale(),
+			Data:       c.Data,
+			Recipients: recipients,
+			Tracked:    true,
+			Category:   string(notification.CategoryMarketing),
+		})
+		if err != nil {
+			return queued, err
+		}
+		queued += len(recipients)
+
+		// Admins may cancel while the campaign is sending
+		c.Queued(members[len(members)-1].ID, len(recipients), time.Now())
+		if ok, err := h.campaignRepo.Progress(ctx, c); !ok || err != nil {
+			return queued, err
+		}
+	}
+}
+
+type TrackCampaignEventCommandHandler struct {
+	campaignRepo campaign.Repository
+	tracker      *campaign.Tracker
+}
+
+func NewTrackCampaignEventCommandHandler(campaignRepo campaign.Repository, tracker *campaign.Tracker) *TrackCampaignEventCommandHandler {
+	return &TrackCampaignEventCommandHandler{campaignRepo: campaignRepo, tracker: tracker}
+}
+
+// Handle records the event and returns the link, whose URL a click is
+// redirected to.
+func (h *TrackCampaignEventCommandHandler) Handle(ctx context.Context, cmd TrackCampaignEventCommand) (*campaign.Link, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *TrackCampaignEventCommandHandler) handle(ctx context.Context, cmd TrackCampaignEventCommand) (*campaign.Link, error) {
+	link, err := h.tracker.Parse(cmd.Token)
+	if err != nil {
+		return nil, err
+	}
+	if cmd.Type == campaign.EventClick && link.URL == "" {
+		return nil, campaign.ErrInvalidLink
+	}
+	if cmd.Type == campaign.EventOpen {
+		link.URL = ""
+	}
+
+	if err := h.campaignRepo.RecordEvent(ctx, link.Event(cmd.Type, time.Now())); err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
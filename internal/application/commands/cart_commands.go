@@ -0,0 +1,259 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/cart"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/product"
+)
+
+type AddToCartCommand struct {
+	Owner     cart.Owner `json:"-"`
+	ProductID string     `json:"product_id" validate:"required"`
+	Quantity  int        `json:"quantity" validate:"required,min=1,max=1000"`
+}
+
+type UpdateCartItemCommand struct {
+	Owner     cart.Owner `json:"-"`
+	ProductID string     `json:"-" validate:"required"`
+	Quantity  int        `json:"quantity" validate:"required,min=1,max=1000"`
+}
+
+type RemoveFromCartCommand struct {
+	Owner     cart.Owner `json:"-"`
+	ProductID string     `json:"-" validate:"required"`
+}
+
+type ClearCartCommand struct {
+	Owner cart.Owner `json:"-"`
+}
+
+// MergeCartCommand moves the anonymous cart with the token into the user's
+// account cart.
+type MergeCartCommand struct {
+	UserID string `json:"-" validate:"required"`
+	Token  string `json:"-" validate:"required"`
+}
+
+// CheckoutCartCommand places an order for everything in the user's cart.
+type CheckoutCartCommand struct {
+	UserID          string         `json:"-" validate:"required"`
+	ShippingAddress order.Address  `json:"shipping_address" validate:"required"`
+	BillingAddress  *order.Address `json:"billing_address,omitempty"`
+	ShippingRateID  string         `json:"shipping_rate_id,omitempty"`
+	Note            string         `json:"note,omitempty" validate:"max=500"`
+	GiftWrap        bool           `json:"gift_wrap,omitempty"`
+	GiftMessage     string         `json:"gift_message,omitempty" validate:"max=300"`
+}
+
+type AddToCartCommandHandler struct {
+	cartRepo      cart.Repository
+	productRepo   product.Repository
+	purchaseRules *PurchaseRules
+}
+
+func NewAddToCartCommandHandler(cartRepo cart.Repository, productRepo product.Repository, purchaseRules *PurchaseRules) *AddToCartCommandHandler {
+	return &AddToCartCommandHandler{cartRepo: cartRepo, productRepo: productRepo, purchaseRules: purchaseRules}
+}
+
+func (h *AddToCartCommandHandler) Handle(ctx context.Context, cmd AddToCartCommand) (*cart.Cart, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *AddToCartCommandHandler) handle(ctx context.Context, cmd AddToCartCommand) (*cart.Cart, error) {
+	c, err := h.cartRepo.Get(ctx, cmd.Owner)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCartItem(ctx, h.productRepo, h.purchaseRules, cmd.Owner, cmd.ProductID, c.Quantity(cmd.ProductID)+cmd.Quantity); err != nil {
+		return nil, err
+	}
+	if err := c.Add(cmd.ProductID, cmd.Quantity, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := h.cartRepo.Save(ctx, cmd.Owner, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+type UpdateCartItemCommandHandler struct {
+	cartRepo      cart.Repository
+	productRepo   product.Repository
+	purchaseRules *PurchaseRules
+}
+
+func NewUpdateCartItemCommandHandler(cartRepo cart.Repository, productRepo product.Repository, purchaseRules *PurchaseRules) *UpdateCartItemCommandHandler {
+	return &UpdateCartItemCommandHandler{cartRepo: cartRepo, productRepo: productRepo, purchaseRules: purchaseRules}
+}
+
+func (h *UpdateCartItemCommandHandler) Handle(ctx context.Context, cmd UpdateCartItemCommand) (*cart.Cart, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *UpdateCartItemCommandHandler) handle(ctx context.Context, cmd UpdateCartItemCommand) (*cart.Cart, error) {
+	c, err := h.cartRepo.Get(ctx, cmd.Owner)
+	if err != nil {
+		return nil, err
+	}
+	if c.Quantity(cmd.ProductID) == 0 {
+		return nil, cart.ErrItemNotFound
+	}
+	if err := checkCartItem(ctx, h.productRepo, h.purchaseRules, cmd.Owner, cmd.ProductID, cmd.Quantity); err != nil {
+		return nil, err
+	}
+	if err := c.Set(cmd.ProductID, cmd.Quantity, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := h.cartRepo.Save(ctx, cmd.Owner, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// checkCartItem checks the owner can order the product in the quantity.
+// Bundles are ordered as their components, whose stock and limits are
+// checked at checkout.
+func checkCartItem(ctx context.Context, productRepo product.Repository, rules *PurchaseRules, owner cart.Owner, productID string, quantity int) error {
+	prod, err := productRepo.GetByID(ctx, productID)
+	if err != nil || prod.Status != product.StatusActive {
+		return ErrProductNotFound
+	}
+	if !prod.IsBundle() && prod.Stock < quantity {
+		return ErrInsufficientStock
+	}
+	return rules.checkItem(ctx, owner.UserID, prod, quantity)
+}
+
+type RemoveFromCartCommandHandler struct {
+	cartRepo cart.Repository
+}
+
+func NewRemoveFromCartCommandHandler(cartRepo cart.Repository) *RemoveFromCartCommandHandler {
+	return &RemoveFromCartCommandHandler{cartRepo: cartRepo}
+}
+
+func (h *RemoveFromCartCommandHandler) Handle(ctx context.Context, cmd RemoveFromCartCommand) (*cart.Cart, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *RemoveFromCartCommandHandler) handle(ctx context.Context, cmd RemoveFromCartCommand) (*cart.Cart, error) {
+	c, err := h.cartRepo.Get(ctx, cmd.Owner)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Remove(cmd.ProductID, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := h.cartRepo.Save(ctx, cmd.Owner, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+type ClearCartCommandHandler struct {
+	cartRepo cart.Repository
+}
+
+func NewClearCartCommandHandler(cartRepo cart.Repository) *ClearCartCommandHandler {
+	return &ClearCartCommandHandler{cartRepo: cartRepo}
+}
+
+func (h *ClearCartCommandHandler) Handle(ctx context.Context, cmd ClearCartCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *ClearCartCommandHandler) handle(ctx context.Context, cmd ClearCartCommand) error {
+	return h.cartRepo.Delete(ctx, cmd.Owner)
+}
+
+type MergeCartCommandHandler struct {
+	cartRepo cart.Repository
+}
+
+func NewMergeCartCommandHandler(cartRepo cart.Repository) *MergeCartCommandHandler {
+	return &MergeCartCommandHandler{cartRepo: cartRepo}
+}
+
+// Handle returns the account cart with the anonymous cart's items, and
+// deletes the anonymous cart. An unknown or expired token merges nothing.
+func (h *MergeCartCommandHandler) Handle(ctx context.Context, cmd MergeCartCommand) (*cart.Cart, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *MergeCartCommandHandler) handle(ctx context.Context, cmd MergeCartCommand) (*cart.Cart, error) {
+	anonymous := cart.Owner{Token: cmd.Token}
+	theirs, err := h.cartRepo.Get(ctx, anonymous)
+	if err != nil {
+		return nil, err
+	}
+	account := cart.Owner{UserID: cmd.UserID}
+	mine, err := h.cartRepo.Get(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	if len(theirs.Items) == 0 {
+		return mine, nil
+	}
+
+	mine.Merge(theirs, time.Now())
+	if err := h.cartRepo.Save(ctx, account, mine); err != nil {
+		return nil, err
+	}
+	if err := h.cartRepo.Delete(ctx, anonymous); err != nil {
+		return nil, err
+	}
+	return mine, nil
+}
+
+type CheckoutCartCommandHandler struct {
+	cartRepo           cart.Repository
+	createOrderHandler *CreateOrderCommandHandler
+}
+
+func NewCheckoutCartCommandHandler(cartRepo cart.Repository, createOrderHandler *CreateOrderCommandHandler) *CheckoutCartCommandHandler {
+	return &CheckoutCartCommandHandler{cartRepo: cartRepo, createOrderHandler: createOrderHandler}
+}
+
+// Handle places the order like CreateOrderCommand with the cart's items,
+// then empties the cart. The cart is kept when the order is refused, for
+// the customer to fix.
+func (h *CheckoutCartCommandHandler) Handle(ctx context.Context, cmd CheckoutCartCommand) (*order.Order, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *CheckoutCartCommandHandler) handle(ctx context.Context, cmd CheckoutCartCommand) (*order.Order, error) {
+	owner := cart.Owner{UserID: cmd.UserID}
+	c, err := h.cartRepo.Get(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.Items) == 0 {
+		return nil, cart.ErrEmpty
+	}
+
+	items := make([]CreateOrderItemCmd, len(c.Items))
+	for i, item := range c.Items {
+		items[i] = CreateOrderItemCmd{ProductID: item.ProductID, Quantity: item.Quantity}
+	}
+	newOrder, err := h.createOrderHandler.Handle(ctx, CreateOrderCommand{
+		UserID:          cmd.UserID,
+		Items:           items,
+		ShippingAddress: cmd.ShippingAddress,
+		BillingAddress:  cmd.BillingAddress,
+		ShippingRateID:  cmd.ShippingRateID,
+		Note:            cmd.Note,
+		GiftWrap:        cmd.GiftWrap,
+		GiftMessage:     cmd.GiftMessage,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The order is placed; a cart left behind is only an inconvenience
+	_ = h.cartRepo.Delete(ctx, owner)
+	return newOrder, nil
+}
@@ -0,0 +1,134 @@
+package commands
+
+import (
+	"context"
+	"math"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/cart"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/shipping"
+)
+
+// PreviewCheckoutCommand prices an order without placing it. Without items
+// it prices the user's cart.
+type PreviewCheckoutCommand struct {
+	UserID          string               `json:"-" validate:"required"`
+	Items           []CreateOrderItemCmd `json:"items" validate:"max=100,dive"`
+	ShippingAddress order.Address        `json:"shipping_address" validate:"required"`
+	ShippingRateID  string               `json:"shipping_rate_id,omitempty"`
+	Note            string               `json:"note,omitempty" validate:"max=500"`
+	GiftWrap        bool                 `json:"gift_wrap,omitempty"`
+	GiftMessage     string               `json:"gift_message,omitempty" validate:"max=300"`
+}
+
+// CheckoutPreview is what an order would be placed for. Its items are
+// priced the way the order prices them: bundles as their components, and
+// products on a live flash sale at the sale price.
+type CheckoutPreview struct {
+	Items []CheckoutLine `json:"items"`
+	// Subtotal is what the items come to, and Discount how much less that
+	// is than at the products' own prices
+	Subtotal float64 `json:"subtotal"`
+	Discount float64 `json:"discount"`
+	// ShippingOptions are the rates of the address's shipping zone,
+	// cheapest first, and Shipping the one charged; shops without zones
+	// have no options and ship at no charge
+	ShippingOptions []shipping.Option      `json:"shipping_options"`
+	Shipping        order.ShippingCharge   `json:"shipping"`
+	Delivery        order.DeliveryEstimate `json:"delivery_estimate"`
+	// Gift is wrapped for its WrapFee on top of Subtotal
+	Gift order.Gift `json:"gift"`
+	// Tax is charged on top of the rest; orders charge none,
+	// so it is zero
+	Tax   float64 `json:"tax"`
+	Total float64 `json:"total"`
+}
+
+type CheckoutLine struct {
+	ProductID      string                `json:"product_id"`
+	Product        order.ProductSnapshot `json:"product"`
+	Quantity       int                   `json:"quantity"`
+	Price          float64               `json:"price"`
+	Subtotal       float64               `json:"subtotal"`
+	FlashSaleID    string                `json:"flash_sale_id,omitempty"`
+	BundleID       string                `json:"bundle_id,omitempty"`
+	BundleQuantity int                   `json:"bundle_quantity,omitempty"`
+}
+
+type PreviewCheckoutCommandHandler struct {
+	cartRepo           cart.Repository
+	createOrderHandler *CreateOrderCommandHandler
+}
+
+func NewPreviewCheckoutCommandHandler(cartRepo cart.Repository, createOrderHandler *CreateOrderCommandHandler) *PreviewCheckoutCommandHandler {
+	return &PreviewCheckoutCommandHandler{cartRepo: cartRepo, createOrderHandler: createOrderHandler}
+}
+
+// Handle prices the order like CreateOrderCommand, and turns it down for
+// the same reasons, but reserves and saves nothing. Flash sale units are
+// only counted when the order is placed, so a sale can sell out between
+// the preview and the order.
+func (h *PreviewCheckoutCommandHandler) Handle(ctx context.Context, cmd PreviewCheckoutCommand) (*CheckoutPreview, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *PreviewCheckoutCommandHandler) handle(ctx context.Context, cmd PreviewCheckoutCommand) (*CheckoutPreview, error) {
+	items := cmd.Items
+	if len(items) == 0 {
+		c, err := h.cartRepo.Get(ctx, cart.Owner{UserID: cmd.UserID})
+		if err != nil {
+			return nil, err
+		}
+		if len(c.Items) == 0 {
+			return nil, cart.ErrEmpty
+		}
+		items = make([]CreateOrderItemCmd, len(c.Items))
+		for i, item := range c.Items {
+			items[i] = CreateOrderItemCmd{ProductID: item.ProductID, Quantity: item.Quantity}
+		}
+	}
+
+	priced, err := h.createOrderHandler.price(ctx, CreateOrderCommand{
+		UserID:          cmd.UserID,
+		Items:           items,
+		ShippingAddress: cmd.ShippingAddress,
+		ShippingRateID:  cmd.ShippingRateID,
+		Note:            cmd.Note,
+		GiftWrap:        cmd.GiftWrap,
+		GiftMessage:     cmd.GiftMessage,
+	})
+	if err != nil {
+		return nil, err
+	}
+	o := priced.order
+
+	preview := &CheckoutPreview{
+		Items:           make([]CheckoutLine, len(o.Items)),
+		ShippingOptions: []shipping.Option{},
+		Shipping:        o.Shipping,
+		Delivery:        o.Delivery,
+		Gift:            o.Gift,
+		Total:           o.TotalAmount,
+	}
+	for i, item := range o.Items {
+		preview.Items[i] = CheckoutLine{
+			ProductID:      item.ProductID,
+			Product:        item.Product,
+			Quantity:       item.Quantity,
+			Price:          item.Price,
+			Subtotal:       item.Subtotal,
+			FlashSaleID:    item.FlashSaleID,
+			BundleID:       item.BundleID,
+			BundleQuantity: item.BundleQuantity,
+		}
+		preview.Subtotal += item.Subtotal
+	}
+	if discount := math.Round((priced.listTotal-preview.Subtotal)*100) / 100; discount > 0 {
+		preview.Discount = discount
+	}
+	if priced.shipping != nil && priced.shipping.Options != nil {
+		preview.ShippingOptions = priced.shipping.Options
+	}
+	return preview, nil
+}
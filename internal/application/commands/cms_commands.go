@@ -0,0 +1,379 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/cms"
+)
+
+type CreateCMSBlockCommand struct {
+	Slot     string     `json:"slot" validate:"required,slug"`
+	Title    string     `json:"title" validate:"required,max=200"`
+	Body     string     `json:"body"`
+	ImageURL string     `json:"image_url"`
+	LinkURL  string     `json:"link_url"`
+	Position int        `json:"position"`
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+}
+
+type UpdateCMSBlockCommand struct {
+	BlockID  string     `json:"-" validate:"required"`
+	Slot     *string    `json:"slot" validate:"omitempty,slug"`
+	Title    *string    `json:"title" validate:"omitempty,max=200"`
+	Body     *string    `json:"body"`
+	ImageURL *string    `json:"image_url"`
+	LinkURL  *string    `json:"link_url"`
+	Position *int       `json:"position"`
+	Active   *bool      `json:"active"`
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+}
+
+type DeleteCMSBlockCommand struct {
+	BlockID string `json:"block_id" validate:"required"`
+}
+
+type CreateCMSPageCommand struct {
+	Slug            string     `json:"slug" validate:"required,slug"`
+	Title           string     `json:"title" validate:"required,max=200"`
+	Body            string     `json:"body" validate:"required"`
+	MetaDescription string     `json:"meta_description" validate:"max=300"`
+	ImageURL        string     `json:"image_url"`
+	StartsAt        *time.Time `json:"starts_at"`
+	EndsAt          *time.Time `json:"ends_at"`
+}
+
+// UpdateCMSPageCommand edits a page; setting published makes it live once
+// its window starts.
+type UpdateCMSPageCommand struct {
+	PageID          string     `json:"-" validate:"required"`
+	Slug            *string    `json:"slug" validate:"omitempty,slug"`
+	Title           *string    `json:"title" validate:"omitempty,max=200"`
+	Body            *string    `json:"body"`
+	MetaDescription *string    `json:"meta_description" validate:"omitempty,max=300"`
+	ImageURL        *string    `json:"image_url"`
+	Published       *bool      `json:"published"`
+	StartsAt        *time.Time `json:"starts_at"`
+	EndsAt          *time.Time `json:"ends_at"`
+}
+
+type DeleteCMSPageCommand struct {
+	PageID string `json:"page_id" validate:"required"`
+}
+
+// UploadCMSAssetCommand stores an image for banners, blocks and pages to
+// link to.
+type UploadCMSAssetCommand struct {
+	Filename   string
+	Data       []byte
+	UploadedBy string
+}
+
+type DeleteCMSAssetCommand struct {
+	AssetID string `json:"asset_id" validate:"required"`
+}
+
+type CreateCMSBlockCommandHandler struct {
+	blockRepo cms.BlockRepository
+	cache     cms.Cache
+}
+
+func NewCreateCMSBlockCommandHandler(blockRepo cms.BlockRepository, cache cms.Cache) *CreateCMSBlockCommandHandler {
+	return &CreateCMSBlockCommandHandler{blockRepo: blockRepo, cache: cache}
+}
+
+func (h *CreateCMSBlockCommandHandler) Handle(ctx context.Context, cmd CreateCMSBlockCommand) (*cms.Block, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *CreateCMSBlockCommandHandler) handle(ctx context.Context, cmd CreateCMSBlockCommand) (*cms.Block, error) {
+	b, err := cms.NewBlock(cmd.Slot, cmd.Title, cmd.Body, cmd.ImageURL, cmd.LinkURL, cmd.Position,
+		cms.Window{StartsAt: cmd.StartsAt, EndsAt: cmd.EndsAt})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.blockRepo.Create(ctx, b); err != nil {
+		return nil, err
+	}
+	clearCMSCache(h.cache)
+	return b, nil
+}
+
+type UpdateCMSBlockCommandHandler struct {
+	blockRepo cms.BlockRepository
+	cache     cms.Cache
+}
+
+func NewUpdateCMSBlockCommandHandler(blockRepo cms.BlockRepository, cache cms.Cache) *UpdateCMSBlockCommandHandler {
+	return &UpdateCMSBlockCommandHandler{blockRepo: blockRepo, cache: cache}
+}
+
+func (h *UpdateCMSBlockCommandHandler) Handle(ctx context.Context, cmd UpdateCMSBlockCommand) (*cms.Block, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *UpdateCMSBlockCommandHandler) handle(ctx context.Context, cmd UpdateCMSBlockCommand) (*cms.Block, error) {
+	b, err := h.blockRepo.GetByID(ctx, cmd.BlockID)
+	if err != nil {
+		return nil, err
+	}
+
+	if cmd.Slot != nil {
+		b.Slot = *cmd.Slot
+	}
+	if cmd.Title != nil {
+		b.Title = *cmd.Title
+	}
+	if cmd.Body != nil {
+		b.Body = *cmd.Body
+	}
+	if cmd.ImageURL != nil {
+		b.ImageURL = *cmd.ImageURL
+	}
+	if cmd.LinkURL != nil {
+		b.LinkURL = *cmd.LinkURL
+	}
+	if cmd.Position != nil {
+		b.Position = *cmd.Position
+	}
+	if cmd.Active != nil {
+		b.Active = *cmd.Active
+	}
+	if cmd.StartsAt != nil {
+		b.StartsAt = cmd.StartsAt
+	}
+	if cmd.EndsAt != nil {
+		b.EndsAt = cmd.EndsAt
+	}
+	if err := b.Check(); err != nil {
+		return nil, err
+	}
+	b.UpdatedAt = time.Now()
+
+	if err := h.blockRepo.Update(ctx, b); err != nil {
+		return nil, err
+	}
+	clearCMSCache(h.cache)
+	return b, nil
+}
+
+type DeleteCMSBlockCommandHandler struct {
+	blockRepo cms.BlockRepository
+	cache     cms.Cache
+}
+
+func NewDeleteCMSBlockCommandHandler(blockRepo cms.BlockRepository, cache cms.Cache) *DeleteCMSBlockCommandHandler {
+	return &DeleteCMSBlockCommandHandler{blockRepo: blockRepo, cache: cache}
+}
+
+func (h *DeleteCMSBlockCommandHandler) Handle(ctx context.Context, cmd DeleteCMSBlockCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *DeleteCMSBlockCommandHandler) handle(ctx context.Context, cmd DeleteCMSBlockCommand) error {
+	if err := h.blockRepo.Delete(ctx, cmd.BlockID); err != nil {
+		return err
+	}
+	clearCMSCache(h.cache)
+	return nil
+}
+
+type CreateCMSPageCommandHandler struct {
+	pageRepo cms.PageRepository
+	cache    cms.Cache
+}
+
+func NewCreateCMSPageCommandHandler(pageRepo cms.PageRepository, cache cms.Cache) *CreateCMSPageCommandHandler {
+	return &CreateCMSPageCommandHandler{pageRepo: pageRepo, cache: cache}
+}
+
+func (h *CreateCMSPageCommandHandler) Handle(ctx context.Context, cmd CreateCMSPageCommand) (*cms.Page, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *CreateCMSPageCommandHandler) handle(ctx context.Context, cmd CreateCMSPageCommand) (*cms.Page, error) {
+	p, err := cms.NewPage(cmd.Slug, cmd.Title, cmd.Body, cmd.MetaDescription, cmd.ImageURL,
+		cms.Window{StartsAt: cmd.StartsAt, EndsAt: cmd.EndsAt})
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPageSlug(ctx, h.pageRepo, p.Slug); err != nil {
+		return nil, err
+	}
+
+	if err := h.pageRepo.Create(ctx, p); err != nil {
+		return nil, err
+	}
+	clearCMSCache(h.cache)
+	return p, nil
+}
+
+type UpdateCMSPageCommandHandler struct {
+	pageRepo cms.PageRepository
+	cache    cms.Cache
+}
+
+func NewUpdateCMSPageCommandHandler(pageRepo cms.PageRepository, cache cms.Cache) *UpdateCMSPageCommandHandler {
+	return &UpdateCMSPageCommandHandler{pageRepo: pageRepo, cache: cache}
+}
+
+func (h *UpdateCMSPageCommandHandler) Handle(ctx context.Context, cmd UpdateCMSPageCommand) (*cms.Page, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *UpdateCMSPageCommandHandler) handle(ctx context.Context, cmd UpdateCMSPageCommand) (*cms.Page, error) {
+	p, err := h.pageRepo.GetByID(ctx, cmd.PageID)
+	if err != nil {
+		return nil, err
+	}
+
+	if cmd.Slug != nil && *cmd.Slug != p.Slug {
+		if err := checkPageSlug(ctx, h.pageRepo, *cmd.Slug); err != nil {
+			return nil, err
+		}
+		p.Slug = *cmd.Slug
+	}
+	if cmd.Title != nil {
+		p.Title = *cmd.Title
+	}
+	if cmd.Body != nil {
+		p.Body = *cmd.Body
+	}
+	if cmd.MetaDescription != nil {
+		p.MetaDescription = *cmd.MetaDescription
+	}
+	if cmd.ImageURL != nil {
+		p.ImageURL = *cmd.ImageURL
+	}
+	if cmd.Published != nil {
+		p.Published = *cmd.Published
+	}
+	if cmd.StartsAt != nil {
+		p.StartsAt = cmd.StartsAt
+	}
+	if cmd.EndsAt != nil {
+		p.EndsAt = cmd.EndsAt
+	}
+	if err := p.Check(); err != nil {
+		return nil, err
+	}
+	p.UpdatedAt = time.Now()
+
+	if err := h.pageRepo.Update(ctx, p); err != nil {
+		return nil, err
+	}
+	clearCMSCache(h.cache)
+	return p, nil
+}
+
+type DeleteCMSPageCommandHandler struct {
+	pageRepo cms.PageRepository
+	cache    cms.Cache
+}
+
+func NewDeleteCMSPageCommandHandler(pageRepo cms.PageRepository, cache cms.Cache) *DeleteCMSPageCommandHandler {
+	return &DeleteCMSPageCommandHandler{pageRepo: pageRepo, cache: cache}
+}
+
+func (h *DeleteCMSPageCommandHandler) Handle(ctx context.Context, cmd DeleteCMSPageCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *DeleteCMSPageCommandHandler) handle(ctx context.Context, cmd DeleteCMSPageCommand) error {
+	if err := h.pageRepo.Delete(ctx, cmd.PageID); err != nil {
+		return err
+	}
+	clearCMSCache(h.cache)
+	return nil
+}
+
+type UploadCMSAssetCommandHandler struct {
+	assetRepo    cms.AssetRepository
+	storage      cms.Storage
+	maxSize      int
+	allowedTypes []string
+}
+
+func NewUploadCMSAssetCommandHandler(assetRepo cms.AssetRepository, storage cms.Storage, maxSize int, allowedTypes []string) *UploadCMSAssetCommandHandler {
+	return &UploadCMSAssetCommandHandler{assetRepo: assetRepo, storage: storage, maxSize: maxSize, allowedTypes: allowedTypes}
+}
+
+// MaxSize is the largest file accepted, in bytes.
+func (h *UploadCMSAssetCommandHandler) MaxSize() int {
+	return h.maxSize
+}
+
+func (h *UploadCMSAssetCommandHandler) Handle(ctx context.Context, cmd UploadCMSAssetCommand) (*cms.Asset, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *UploadCMSAssetCommandHandler) handle(ctx context.Context, cmd UploadCMSAssetCommand) (*cms.Asset, error) {
+	a, err := cms.NewAsset(cmd.Filename, cmd.Data, h.maxSize, h.allowedTypes, cmd.UploadedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.storage.Put(ctx, a.Key, a.ContentType, cmd.Data); err != nil {
+		return nil, err
+	}
+	if err := h.assetRepo.Create(ctx, a); err != nil {
+		// Do not leave a file behind that nothing refers to
+		_ = h.storage.Delete(ctx, a.Key)
+		return nil, err
+	}
+	return a, nil
+}
+
+type DeleteCMSAssetCommandHandler struct {
+	assetRepo cms.AssetRepository
+	storage   cms.Storage
+	cache     cms.Cache
+}
+
+func NewDeleteCMSAssetCommandHandler(assetRepo cms.AssetRepository, storage cms.Storage, cache cms.Cache) *DeleteCMSAssetCommandHandler {
+	return &DeleteCMSAssetCommandHandler{assetRepo: assetRepo, storage: storage, cache: cache}
+}
+
+// Handle removes the record first: a file left in storage is harmless, a
+// record pointing at a missing file is a broken image.
+func (h *DeleteCMSAssetCommandHandler) Handle(ctx context.Context, cmd DeleteCMSAssetCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *DeleteCMSAssetCommandHandler) handle(ctx context.Context, cmd DeleteCMSAssetCommand) error {
+	a, err := h.assetRepo.Get(ctx, cmd.AssetID)
+	if err != nil {
+		return err
+	}
+	if err := h.assetRepo.Delete(ctx, a.ID); err != nil {
+		return err
+	}
+	clearCMSCache(h.cache)
+	// The asset is already gone from the database, so its file is removed
+	// even when the request was cancelled
+	_ = h.storage.Delete(context.Background(), a.Key)
+	return nil
+}
+
+func checkPageSlug(ctx context.Context, pageRepo cms.PageRepository, slug string) error {
+	_, err := pageRepo.GetBySlug(ctx, slug)
+	if err == nil {
+		return ErrSlugTaken
+	}
+	if !errors.Is(err, cms.ErrPageNotFound) {
+		return err
+	}
+	return nil
+}
+
+// clearCMSCache drops the cached public content after an edit. A failure
+// only delays the edit until the entries expire. The edit is already
+// stored, so the cache is cleared even when the request was cancelled.
+func clearCMSCache(cache cms.Cache) {
+	_ = cache.Clear(context.Background())
+}
@@ -0,0 +1,232 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/cod"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/payment"
+	"online-shop/internal/domain/user"
+)
+
+// PayOnDeliveryCommand has a pending order paid in cash to the courier
+// instead of online.
+type PayOnDeliveryCommand struct {
+	OrderID string `json:"-" validate:"required"`
+	UserID  string `json:"-" validate:"required"`
+}
+
+// RecordRemittanceCommand books the cash a courier handed over for the
+// collections they made.
+type RecordRemittanceCommand struct {
+	Carrier       string   `json:"carrier" validate:"required,max=50"`
+	Reference     string   `json:"reference" validate:"max=100"`
+	Amount        float64  `json:"amount" validate:"gte=0"`
+	CollectionIDs []string `json:"collection_ids" validate:"required,min=1,max=500,dive,required"`
+	Note          string   `json:"note" validate:"max=500"`
+	RecordedBy    string   `json:"-"`
+}
+
+// SetCODLimitCommand overrides the cash on delivery limits of a customer.
+// Omitted amounts follow the shop's limits.
+type SetCODLimitCommand struct {
+	UserID               string   `json:"-" validate:"required"`
+	Blocked              bool     `json:"blocked"`
+	MaxOrderAmount       *float64 `json:"max_order_amount" validate:"omitempty,gte=0"`
+	MaxOutstandingAmount *float64 `json:"max_outstanding_amount" validate:"omitempty,gte=0"`
+	Note                 string   `json:"note" validate:"max=500"`
+	UpdatedBy            string   `json:"-"`
+}
+
+type PayOnDeliveryCommandHandler struct {
+	orderRepo   order.Repository
+	paymentRepo payment.Repository
+	codRepo     cod.Repository
+	limitRepo   cod.LimitRepository
+	limits      *cod.Limits
+	confirmer   *OrderConfirmer
+}
+
+// NewPayOnDeliveryCommandHandler takes nil limits when cash on delivery is
+// turned off.
+func NewPayOnDeliveryCommandHandler(
+	orderRepo order.Repository,
+	paymentRepo payment.Repository,
+	codRepo cod.Repository,
+	limitRepo cod.LimitRepository,
+	limits *cod.Limits,
+	confirmer *OrderConfirmer,
+) *PayOnDeliveryCommandHandler {
+	return &PayOnDeliveryCommandHandler{
+		orderRepo:   orderRepo,
+		paymentRepo: paymentRepo,
+		codRepo:     codRepo,
+		limitRepo:   limitRepo,
+		limits:      limits,
+		confirmer:   confirmer,
+	}
+}
+
+// Handle confirms the order so it can be picked and shipped, with a pending
+// cash payment for its total that is settled when the courier collects it.
+// The cash is collected with one parcel, so an order split across
+// warehouses is paid online.
+func (h *PayOnDeliveryCommandHandler) Handle(ctx context.Context, cmd PayOnDeliveryCommand) (*cod.Collection, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *PayOnDeliveryCommandHandler) handle(ctx context.Context, cmd PayOnDeliveryCommand) (*cod.Collection, error) {
+	if h.limits == nil {
+		return nil, fmt.Errorf("%w: cash on delivery is not offered", cod.ErrNotEligible)
+	}
+
+	o, err := h.orderRepo.GetByID(ctx, cmd.OrderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+	if o.UserID != cmd.UserID {
+		return nil, ErrForbidden
+	}
+	if o.Status == order.StatusOnHold {
+		return nil, ErrOrderOnHold
+	}
+	if o.Status != order.StatusPending {
+		return nil, ErrOrderNotPayable
+	}
+	if len(o.Shipments) > 1 {
+		return nil, fmt.Errorf("%w: the order ships in several parcels", cod.ErrNotEligible)
+	}
+
+	existing, err := h.paymentRepo.ListByOrderID(ctx, o.ID)
+	if err != nil {
+		return nil, err
+	}
+	summary := payment.Summarize(o.TotalAmount, existing)
+	if summary.Settled() {
+		return nil, payment.ErrAlreadyPaid
+	}
+	if summary.Pending > 0 || summary.Paid > 0 {
+		return nil, fmt.Errorf("%w: the order has online payments", cod.ErrNotEligible)
+	}
+
+	customer, err := h.limitRepo.Get(ctx, o.UserID)
+	if err != nil {
+		return nil, err
+	}
+	open, err := h.codRepo.Exposure(ctx, o.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.limits.Check(customer, o.TotalAmount, open); err != nil {
+		return nil, err
+	}
+
+	if err := o.PayOnDelivery(); err != nil {
+		return nil, ErrOrderNotPayable
+	}
+	if h.confirmer != nil {
+		if err := h.confirmer.Estimate(ctx, o, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	pay := payment.NewPayment(o.ID, o.UserID, o.TotalAmount, payment.MethodCashOnDelivery)
+	pay.ExternalID = pay.ID
+	collection := cod.NewCollection(o.ID, o.UserID, pay.ID, o.TotalAmount)
+
+	if err := h.paymentRepo.Create(ctx, pay); err != nil {
+		return nil, err
+	}
+	if err := h.codRepo.Create(ctx, collection); err != nil {
+		return nil, err
+	}
+	if err := h.orderRepo.Update(ctx, o); err != nil {
+		return nil, err
+	}
+	h.confirmer.notify(ctx, o, true)
+	return collection, nil
+}
+
+type RecordRemittanceCommandHandler struct {
+	codRepo cod.Repository
+}
+
+func NewRecordRemittanceCommandHandler(codRepo cod.Repository) *RecordRemittanceCommandHandler {
+	return &RecordRemittanceCommandHandler{codRepo: codRepo}
+}
+
+// Handle settles the collections with the courier. What they handed over is
+// booked as is; a difference from what they collected is recorded on the
+// remittance for finance to follow up.
+func (h *RecordRemittanceCommandHandler) Handle(ctx context.Context, cmd RecordRemittanceCommand) (*cod.Remittance, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *RecordRemittanceCommandHandler) handle(ctx context.Context, cmd RecordRemittanceCommand) (*cod.Remittance, error) {
+	ids := make([]string, 0, len(cmd.CollectionIDs))
+	seen := make(map[string]bool, len(cmd.CollectionIDs))
+	for _, id := range cmd.CollectionIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	collections, err := h.codRepo.ListByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(collections) != len(ids) {
+		return nil, fmt.Errorf("%w: %d of the collections do not exist", cod.ErrNotRemittable, len(ids)-len(collections))
+	}
+
+	remittance, err := cod.Remit(strings.TrimSpace(cmd.Carrier), cmd.Reference, cmd.Amount, collections, cmd.RecordedBy, cmd.Note, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := h.codRepo.CreateRemittance(ctx, remittance, collections); err != nil {
+		return nil, err
+	}
+
+	remittance.Collections = make([]cod.Collection, 0, len(collections))
+	for _, c := range collections {
+		remittance.Collections = append(remittance.Collections, *c)
+	}
+	return remittance, nil
+}
+
+type SetCODLimitCommandHandler struct {
+	limitRepo cod.LimitRepository
+	userRepo  user.Repository
+}
+
+func NewSetCODLimitCommandHandler(limitRepo cod.LimitRepository, userRepo user.Repository) *SetCODLimitCommandHandler {
+	return &SetCODLimitCommandHandler{limitRepo: limitRepo, userRepo: userRepo}
+}
+
+func (h *SetCODLimitCommandHandler) Handle(ctx context.Context, cmd SetCODLimitCommand) (*cod.CustomerLimit, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *SetCODLimitCommandHandler) handle(ctx context.Context, cmd SetCODLimitCommand) (*cod.CustomerLimit, error) {
+	if _, err := h.userRepo.GetByID(ctx, cmd.UserID); err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	limit := &cod.CustomerLimit{
+		UserID:               cmd.UserID,
+		Blocked:              cmd.Blocked,
+		MaxOrderAmount:       cmd.MaxOrderAmount,
+		MaxOutstandingAmount: cmd.MaxOutstandingAmount,
+		Note:                 strings.TrimSpace(cmd.Note),
+		UpdatedBy:            cmd.UpdatedBy,
+		UpdatedAt:            time.Now(),
+	}
+	if err := h.limitRepo.Save(ctx, limit); err != nil {
+		return nil, err
+	}
+	return limit, nil
+}
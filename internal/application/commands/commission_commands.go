@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/commission"
+)
+
+type CreateCommissionRuleCommand struct {
+	Name     string           `json:"name" validate:"required"`
+	Scope    commission.Scope `json:"scope" validate:"required"`
+	ScopeID  string           `json:"scope_id"`
+	Rate     float64          `json:"rate" validate:"min=0,max=100"`
+	FixedFee float64          `json:"fixed_fee" validate:"min=0"`
+}
+
+type UpdateCommissionRuleCommand struct {
+	RuleID   string   `json:"rule_id" validate:"required"`
+	Name     *string  `json:"name"`
+	Rate     *float64 `json:"rate"`
+	FixedFee *float64 `json:"fixed_fee"`
+	Active   *bool    `json:"active"`
+}
+
+type DeleteCommissionRuleCommand struct {
+	RuleID string `json:"rule_id" validate:"required"`
+}
+
+type CreateCommissionRuleCommandHandler struct {
+	commissionRepo commission.Repository
+}
+
+func NewCreateCommissionRuleCommandHandler(commissionRepo commission.Repository) *CreateCommissionRuleCommandHandler {
+	return &CreateCommissionRuleCommandHandler{commissionRepo: commissionRepo}
+}
+
+func (h *CreateCommissionRuleCommandHandler) Handle(ctx context.Context, cmd CreateCommissionRuleCommand) (*commission.Rule, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *CreateCommissionRuleCommandHandler) handle(ctx context.Context, cmd CreateCommissionRuleCommand) (*commission.Rule, error) {
+	rule, err := commission.NewRule(cmd.Name, cmd.Scope, cmd.ScopeID, cmd.Rate, cmd.FixedFee)
+	if err != nil {
+		return nil, ErrInvalidCommissionRule
+	}
+
+	if err := h.commissionRepo.Create(ctx, rule); err != nil {
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+type UpdateCommissionRuleCommandHandler struct {
+	commissionRepo commission.Repository
+}
+
+func NewUpdateCommissionRuleCommandHandler(commissionRepo commission.Repository) *UpdateCommissionRuleCommandHandler {
+	return &UpdateCommissionRuleCommandHandler{commissionRepo: commissionRepo}
+}
+
+func (h *UpdateCommissionRuleCommandHandler) Handle(ctx context.Context, cmd UpdateCommissionRuleCommand) (*commission.Rule, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *UpdateCommissionRuleCommandHandler) handle(ctx context.Context, cmd UpdateCommissionRuleCommand) (*commission.Rule, error) {
+	rule, err := h.commissionRepo.GetByID(ctx, cmd.RuleID)
+	if err != nil {
+		return nil, ErrCommissionRuleNotFound
+	}
+
+	if cmd.Name != nil {
+		rule.Name = *cmd.Name
+	}
+	if cmd.Rate != nil {
+		if *cmd.Rate < 0 || *cmd.Rate > 100 {
+			return nil, ErrInvalidCommissionRule
+		}
+		rule.Rate = *cmd.Rate
+	}
+	if cmd.FixedFee != nil {
+		if *cmd.FixedFee < 0 {
+			return nil, ErrInvalidCommissionRule
+		}
+		rule.FixedFee = *cmd.FixedFee
+	}
+	if cmd.Active != nil {
+		rule.Active = *cmd.Active
+	}
+	rule.UpdatedAt = time.Now()
+
+	if err := h.commissionRepo.Update(ctx, rule); err != nil {
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+type DeleteCommissionRuleCommandHandler struct {
+	commissionRepo commission.Repository
+}
+
+func NewDeleteCommissionRuleCommandHandler(commissionRepo commission.Repository) *DeleteCommissionRuleCommandHandler {
+	return &DeleteCommissionRuleCommandHandler{commissionRepo: commissionRepo}
+}
+
+func (h *DeleteCommissionRuleCommandHandler) Handle(ctx context.Context, cmd DeleteCommissionRuleCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *DeleteCommissionRuleCommandHandler) handle(ctx context.Context, cmd DeleteCommissionRuleCommand) error {
+	if _, err := h.commissionRepo.GetByID(ctx, cmd.RuleID); err != nil {
+		return ErrCommissionRuleNotFound
+	}
+	return h.commissionRepo.Delete(ctx, cmd.RuleID)
+}
@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/analytics"
+)
+
+// maxCSPReports caps the violations taken from one request, as the
+// endpoint is open to anyone
+const maxCSPReports = 20
+
+// RecordCSPReportCommand is a browser's report of Content-Security-Policy
+// violations, in the report-uri format (application/csp-report) or the
+// Reporting API one (application/reports+json).
+type RecordCSPReportCommand struct {
+	Body      []byte
+	IPAddress string
+	UserAgent string
+}
+
+// cspReport is the report-uri format
+type cspReport struct {
+	Report struct {
+		DocumentURI        string `json:"document-uri"`
+		Referrer           string `json:"referrer"`
+		BlockedURI         string `json:"blocked-uri"`
+		ViolatedDirective  string `json:"violated-directive"`
+		EffectiveDirective string `json:"effective-directive"`
+		Disposition        string `json:"disposition"`
+		SourceFile         string `json:"source-file"`
+		LineNumber         int    `json:"line-number"`
+		ColumnNumber       int    `json:"column-number"`
+		StatusCode         int    `json:"status-code"`
+		ScriptSample       string `json:"script-sample"`
+	} `json:"csp-report"`
+}
+
+// reportingAPIReport is one report of the Reporting API format
+type reportingAPIReport struct {
+	Type string `json:"type"`
+	Body struct {
+		DocumentURL        string `json:"documentURL"`
+		Referrer           string `json:"referrer"`
+		BlockedURL         string `json:"blockedURL"`
+		EffectiveDirective string `json:"effectiveDirective"`
+		Disposition        string `json:"disposition"`
+		SourceFile         string `json:"sourceFile"`
+		LineNumber         int    `json:"lineNumber"`
+		ColumnNumber       int    `json:"columnNumber"`
+		StatusCode         int    `json:"statusCode"`
+		Sample             string `json:"sample"`
+	} `json:"body"`
+}
+
+type cspViolation struct {
+	documentURL string
+	referrer    string
+	properties  map[string]interface{}
+}
+
+type RecordCSPReportCommandHandler struct {
+	publisher analytics.Publisher
+}
+
+func NewRecordCSPReportCommandHandler(publisher analytics.Publisher) *RecordCSPReportCommandHandler {
+	return &RecordCSPReportCommandHandler{publisher: publisher}
+}
+
+// Handle publishes each violation through the analytics pipeline and
+// returns how many were recorded. Reports of other types sent to the same
+// endpoint are ignored.
+func (h *RecordCSPReportCommandHandler) Handle(ctx context.Context, cmd RecordCSPReportCommand) (int, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *RecordCSPReportCommandHandler) handle(ctx context.Context, cmd RecordCSPReportCommand) (int, error) {
+	violations, err := parseCSPReport(cmd.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, v := range violations {
+		event := analytics.NewCSPViolationEvent(v.properties, v.documentURL, v.referrer, cmd.IPAddress, cmd.UserAgent)
+		if err := h.publisher.Publish(ctx, event); err != nil {
+			return 0, err
+		}
+	}
+	return len(violations), nil
+}
+
+func parseCSPReport(body []byte) ([]cspViolation, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, ErrInvalidCSPReport
+	}
+
+	if body[0] == '[' {
+		var reports []reportingAPIReport
+		if err := json.Unmarshal(body, &reports); err != nil {
+			return nil, ErrInvalidCSPReport
+		}
+		var violations []cspViolation
+		for _, r := range reports {
+			if r.Type != "csp-violation" {
+				continue
+			}
+			if len(violations) == maxCSPReports {
+				break
+			}
+			b := r.Body
+			violations = append(violations, cspViolation{
+				documentURL: b.DocumentURL,
+				referrer:    b.Referrer,
+				properties: cspProperties(b.BlockedURL, b.EffectiveDirective, b.EffectiveDirective, b.Disposition,
+					b.SourceFile, b.LineNumber, b.ColumnNumber, b.StatusCode, b.Sample),
+			})
+		}
+		return violations, nil
+	}
+
+	var report cspReport
+	if err := json.Unmarshal(body, &report); err != nil || report.Report.DocumentURI == "" {
+		return nil, ErrInvalidCSPReport
+	}
+	r := report.Report
+	return []cspViolation{{
+		documentURL: r.DocumentURI,
+		referrer:    r.Referrer,
+		properties: cspProperties(r.BlockedURI, r.ViolatedDirective, r.EffectiveDirective, r.Disposition,
+			r.SourceFile, r.LineNumber, r.ColumnNumber, r.StatusCode, r.ScriptSample),
+	}}, nil
+}
+
+func cspProperties(blocked, violated, effective, disposition, sourceFile string, line, column, status int, sample string) map[string]interface{} {
+	if effective == "" {
+		effective = violated
+	}
+	properties := map[string]interface{}{
+		"blocked_uri":         blocked,
+		"violated_directive":  violated,
+		"effective_directive": effective,
+		"disposition":         disposition,
+	}
+	if sourceFile != "" {
+		properties["source_file"] = sourceFile
+		properties["line_number"] = line
+		properties["column_number"] = column
+	}
+	if status != 0 {
+		properties["status_code"] = status
+	}
+	if sample != "" {
+		properties["sample"] = sample
+	}
+	return properties
+}
@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/dashboard"
+)
+
+type RefreshDashboardStatsCommand struct {
+	LowStockThreshold int
+	TTL               time.Duration
+}
+
+// NonTransactional, as the snapshot aggregates over every order and
+// product and is only written to the cache.
+func (RefreshDashboardStatsCommand) NonTransactional() {}
+
+type RefreshDashboardStatsCommandHandler struct {
+	dashboardRepo dashboard.Repository
+	cache         dashboard.Cache
+}
+
+func NewRefreshDashboardStatsCommandHandler(dashboardRepo dashboard.Repository, cache dashboard.Cache) *RefreshDashboardStatsCommandHandler {
+	return &RefreshDashboardStatsCommandHandler{
+		dashboardRepo: dashboardRepo,
+		cache:         cache,
+	}
+}
+
+// Handle recomputes today's dashboard aggregates and replaces the cached
+// snapshot served to the admin dashboard.
+func (h *RefreshDashboardStatsCommandHandler) Handle(ctx context.Context, cmd RefreshDashboardStatsCommand) (*dashboard.Stats, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *RefreshDashboardStatsCommandHandler) handle(ctx context.Context, cmd RefreshDashboardStatsCommand) (*dashboard.Stats, error) {
+	if cmd.LowStockThreshold <= 0 {
+		cmd.LowStockThreshold = 10
+	}
+	if cmd.TTL <= 0 {
+		cmd.TTL = 10 * time.Minute
+	}
+
+	stats, err := h.dashboardRepo.Compute(ctx, dashboard.StartOfDay(time.Now()), cmd.LowStockThreshold)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.cache.Save(ctx, stats, cmd.TTL); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
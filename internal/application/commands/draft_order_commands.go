@@ -0,0 +1,484 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/audit"
+	"online-shop/internal/domain/draftorder"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/payment"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/user"
+	"online-shop/pkg/apperror"
+)
+
+// CreateDraftOrderCommand drafts an order for a customer to pay through a
+// link. MerchantID is empty for admins, who can draft any products; a
+// merchant drafts their own. The customer is found like for an order staff
+// place, by UserID or by the email of Customer.
+type CreateDraftOrderCommand struct {
+	ActorID    string `json:"-" validate:"required"`
+	ActorRole  string `json:"-"`
+	RequestID  string `json:"-"`
+	MerchantID string `json:"-"`
+
+	UserID   string                  `json:"user_id,omitempty" validate:"required_without=Customer"`
+	Customer *ManualOrderCustomerCmd `json:"customer,omitempty"`
+	Items    []ManualOrderItemCmd    `json:"items" validate:"required,min=1,max=100,dive"`
+	// PriceReason says why prices were overridden, and is required with them
+	PriceReason     string        `json:"price_reason,omitempty" validate:"max=500"`
+	ShippingAddress order.Address `json:"shipping_address" validate:"required"`
+	ShippingRateID  string        `json:"shipping_rate_id,omitempty"`
+	Note            string        `json:"note,omitempty" validate:"max=500"`
+	GiftWrap        bool          `json:"gift_wrap,omitempty"`
+	GiftMessage     string        `json:"gift_message,omitempty" validate:"max=300"`
+}
+
+type CreateDraftOrderCommandHandler struct {
+	draftRepo          draftorder.Repository
+	userRepo           user.Repository
+	productRepo        product.Repository
+	createOrderHandler *CreateOrderCommandHandler
+	auditRepo          audit.Repository
+	validity           time.Duration
+}
+
+// NewCreateDraftOrderCommandHandler keeps drafts payable for validity.
+func NewCreateDraftOrderCommandHandler(
+	draftRepo draftorder.Repository,
+	userRepo user.Repository,
+	productRepo product.Repository,
+	createOrderHandler *CreateOrderCommandHandler,
+	auditRepo audit.Repository,
+	validity time.Duration,
+) *CreateDraftOrderCommandHandler {
+	return &CreateDraftOrderCommandHandler{
+		draftRepo:          draftRepo,
+		userRepo:           userRepo,
+		productRepo:        productRepo,
+		createOrderHandler: createOrderHandler,
+		auditRepo:          auditRepo,
+		validity:           validity,
+	}
+}
+
+// Handle prices the draft like an order staff place, so it is checked for
+// stock and age restrictions and charged shipping, but reserves nothing:
+// stock is only taken once the customer has paid. The items are fixed at
+// the prices staff set or else the products' own, which flash sales do not
+// change. Who drafted it, for whom and the prices they changed are written
+// to the audit log.
+func (h *CreateDraftOrderCommandHandler) Handle(ctx context.Context, cmd CreateDraftOrderCommand) (*draftorder.Draft, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *CreateDraftOrderCommandHandler) handle(ctx context.Context, cmd CreateDraftOrderCommand) (*draftorder.Draft, error) {
+	items := make([]draftorder.Item, len(cmd.Items))
+	overridden := false
+	for i, item := range cmd.Items {
+		prod, err := h.productRepo.GetByID(ctx, item.ProductID)
+		if err != nil || (cmd.MerchantID != "" && prod.MerchantID != cmd.MerchantID) {
+			return nil, ErrProductNotFound
+		}
+		items[i] = draftorder.Item{
+			ProductID: prod.ID,
+			Name:      prod.Name,
+			Quantity:  item.Quantity,
+			Price:     prod.Price,
+			ListPrice: prod.Price,
+		}
+		if item.Price > 0 {
+			items[i].Price = item.Price
+			overridden = true
+		}
+	}
+	reason := strings.TrimSpace(cmd.PriceReason)
+	if overridden && reason == "" {
+		return nil, ErrInvalidDraftOrder.WithDetail("a price_reason is required to override prices")
+	}
+
+	customer, created, err := orderCustomer(ctx, h.userRepo, cmd.UserID, cmd.Customer, ErrInvalidDraftOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := draftorder.New(cmd.ActorID, cmd.MerchantID, customer.ID, customer.Email, items, 0, h.validity)
+	if err != nil {
+		return nil, err
+	}
+	d.PriceReason = reason
+	d.ShippingAddress = cmd.ShippingAddress
+	d.ShippingRateID = cmd.ShippingRateID
+	d.Note = cmd.Note
+	d.GiftWrap = cmd.GiftWrap
+	d.GiftMessage = cmd.GiftMessage
+
+	priced, err := h.createOrderHandler.price(ctx, draftOrderCommand(d))
+	if err != nil {
+		return nil, err
+	}
+	d.ShippingFee = priced.order.Shipping.Fee
+	d.GiftWrapFee = priced.order.Gift.WrapFee
+	d.TotalAmount = priced.order.TotalAmount
+	if err := h.draftRepo.Create(ctx, d); err != nil {
+		return nil, err
+	}
+
+	entry := audit.NewEntry(audit.SourceCommand, cmd.ActorID, "draft_order.create", "draft_order", d.ID)
+	entry.ActorRole = cmd.ActorRole
+	entry.RequestID = cmd.RequestID
+	entry.Changes = map[string]audit.Change{
+		"user_id":      {Before: nil, After: customer.ID},
+		"total_amount": {Before: nil, After: d.TotalAmount},
+	}
+	if created {
+		entry.Changes["customer_created"] = audit.Change{Before: nil, After: true}
+	}
+	for _, item := range d.Items {
+		if item.Price != item.ListPrice {
+			entry.Changes["items."+item.ProductID+".price"] = audit.Change{Before: item.ListPrice, After: item.Price}
+		}
+	}
+	if overridden {
+		entry.Changes["price_reason"] = audit.Change{Before: nil, After: reason}
+	}
+	if err := h.auditRepo.Create(ctx, entry); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// draftOrderCommand is the order the draft is placed as, at its prices.
+func draftOrderCommand(d *draftorder.Draft) CreateOrderCommand {
+	items := make([]CreateOrderItemCmd, len(d.Items))
+	for i, item := range d.Items {
+		items[i] = CreateOrderItemCmd{ProductID: item.ProductID, Quantity: item.Quantity, Price: item.Price}
+	}
+	return CreateOrderCommand{
+		UserID:          d.UserID,
+		Items:           items,
+		ShippingAddress: d.ShippingAddress,
+		ShippingRateID:  d.ShippingRateID,
+		Note:            d.Note,
+		GiftWrap:        d.GiftWrap,
+		GiftMessage:     d.GiftMessage,
+		PlacedBy:        d.CreatedBy,
+		Channel:         order.ChannelPaymentLink,
+	}
+}
+
+// merchantDraft loads a draft of the merchant; other merchants' drafts are
+// not found. An empty merchantID loads any draft.
+func merchantDraft(ctx context.Context, draftRepo draftorder.Repository, draftID, merchantID string) (*draftorder.Draft, error) {
+	d, err := draftRepo.GetByID(ctx, draftID)
+	if err != nil {
+		return nil, err
+	}
+	if merchantID != "" && d.MerchantID != merchantID {
+		return nil, ErrDraftOrderNotFound
+	}
+	return d, nil
+}
+
+// SendDraftOrderCommand emails the customer the draft's payment link.
+// MerchantID is empty for admins.
+type SendDraftOrderCommand struct {
+	DraftOrderID string `json:"-" validate:"required"`
+	MerchantID   string `json:"-"`
+}
+
+type SendDraftOrderCommandHandler struct {
+	draftRepo draftorder.Repository
+	userRepo  user.Repository
+	notifier  draftorder.Notifier
+	baseURL   string
+	pageURL   string
+}
+
+// NewSendDraftOrderCommandHandler links to pageURL, or to the summary the
+// API hosts under baseURL without one. It takes a nil notifier when emails
+// cannot be sent.
+func NewSendDraftOrderCommandHandler(draftRepo draftorder.Repository, userRepo user.Repository, notifier draftorder.Notifier, baseURL, pageURL string) *SendDraftOrderCommandHandler {
+	return &SendDraftOrderCommandHandler{
+		draftRepo: draftRepo,
+		userRepo:  userRepo,
+		notifier:  notifier,
+		baseURL:   baseURL,
+		pageURL:   pageURL,
+	}
+}
+
+// Handle sends the link of a draft that can still be paid, as often as
+// staff ask.
+func (h *SendDraftOrderCommandHandler) Handle(ctx context.Context, cmd SendDraftOrderCommand) (*draftorder.Draft, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *SendDraftOrderCommandHandler) handle(ctx context.Context, cmd SendDraftOrderCommand) (*draftorder.Draft, error) {
+	if h.notifier == nil {
+		return nil, ErrEmailQueueUnavailable
+	}
+	d, err := merchantDraft(ctx, h.draftRepo, cmd.DraftOrderID, cmd.MerchantID)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Sent(time.Now()); err != nil {
+		return nil, err
+	}
+
+	notice := draftorder.LinkNotice{Draft: d, Link: d.Link(h.baseURL, h.pageURL)}
+	if customer, err := h.userRepo.GetByID(ctx, d.UserID); err == nil {
+		notice.CustomerName = strings.TrimSpace(customer.FirstName + " " + customer.LastName)
+		notice.Locale = customer.Locale
+	}
+	if err := h.notifier.LinkSent(ctx, notice); err != nil {
+		return nil, err
+	}
+
+	if err := h.draftRepo.Update(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// CancelDraftOrderCommand withdraws a draft that was not paid. MerchantID
+// is empty for admins.
+type CancelDraftOrderCommand struct {
+	DraftOrderID string `json:"-" validate:"required"`
+	MerchantID   string `json:"-"`
+}
+
+type CancelDraftOrderCommandHandler struct {
+	draftRepo draftorder.Repository
+}
+
+func NewCancelDraftOrderCommandHandler(draftRepo draftorder.Repository) *CancelDraftOrderCommandHandler {
+	return &CancelDraftOrderCommandHandler{draftRepo: draftRepo}
+}
+
+func (h *CancelDraftOrderCommandHandler) Handle(ctx context.Context, cmd CancelDraftOrderCommand) (*draftorder.Draft, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *CancelDraftOrderCommandHandler) handle(ctx context.Context, cmd CancelDraftOrderCommand) (*draftorder.Draft, error) {
+	d, err := merchantDraft(ctx, h.draftRepo, cmd.DraftOrderID, cmd.MerchantID)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Cancel(time.Now()); err != nil {
+		return nil, err
+	}
+	if err := h.draftRepo.Update(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// PayDraftOrderCommand starts paying a draft through its link. Whoever has
+// the link can pay it.
+type PayDraftOrderCommand struct {
+	Token  string         `json:"-" validate:"required"`
+	Method payment.Method `json:"method" validate:"required,oneof=credit_card bank_transfer e_wallet virtual_account"`
+}
+
+// NonTransactional, as the provider issues the link and the payment
+// recording it has to outlive a later failure.
+func (PayDraftOrderCommand) NonTransactional() {}
+
+type PayDraftOrderCommandHandler struct {
+	draftRepo   draftorder.Repository
+	paymentRepo payment.Repository
+	provider    payment.PaymentProvider
+}
+
+func NewPayDraftOrderCommandHandler(draftRepo draftorder.Repository, paymentRepo payment.Repository, provider payment.PaymentProvider) *PayDraftOrderCommandHandler {
+	return &PayDraftOrderCommandHandler{draftRepo: draftRepo, paymentRepo: paymentRepo, provider: provider}
+}
+
+// Handle returns a payment of the draft's total with a live link from the
+// payment provider. A payment started earlier with the same method is
+// returned while its link is live, so a reloaded page does not charge
+// twice. The payment has no order until it is paid and the draft is
+// converted into one.
+func (h *PayDraftOrderCommandHandler) Handle(ctx context.Context, cmd PayDraftOrderCommand) (*payment.Payment, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *PayDraftOrderCommandHandler) handle(ctx context.Context, cmd PayDraftOrderCommand) (*payment.Payment, error) {
+	d, err := h.draftRepo.GetByToken(ctx, cmd.Token)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err := d.CheckPayable(now); err != nil {
+		return nil, err
+	}
+
+	if d.PaymentID != "" {
+		if pay, err := h.paymentRepo.GetByID(ctx, d.PaymentID); err == nil {
+			if pay.IsPaid() {
+				return nil, payment.ErrAlreadyPaid
+			}
+			if pay.Status == payment.StatusPending && pay.Method == cmd.Method && pay.PaymentURL != "" && !pay.IsExpired() {
+				return pay, nil
+			}
+		}
+	}
+
+	pay := payment.NewPayment("", d.UserID, d.TotalAmount, cmd.Method)
+	pay.DraftOrderID = d.ID
+	if err := issuePaymentLink(ctx, h.provider, pay); err != nil {
+		return nil, err
+	}
+	if err := h.paymentRepo.Create(ctx, pay); err != nil {
+		return nil, err
+	}
+
+	d.Started(pay.ID, now)
+	if err := h.draftRepo.Update(ctx, d); err != nil {
+		return nil, err
+	}
+	return pay, nil
+}
+
+// CompleteDraftOrderCommand converts the draft paid by the payment into an
+// order. The payment provider's webhook sends it whenever the status of a
+// draft's payment changes.
+type CompleteDraftOrderCommand struct {
+	PaymentID string `json:"payment_id" validate:"required"`
+}
+
+// NonTransactional: the claim on the draft has to be visible to concurrent
+// deliveries of the webhook as soon as it is made.
+func (CompleteDraftOrderCommand) NonTransactional() {}
+
+type CompleteDraftOrderCommandHandler struct {
+	draftRepo          draftorder.Repository
+	orderRepo          order.Repository
+	paymentRepo        payment.Repository
+	createOrderHandler *CreateOrderCommandHandler
+	confirmer          *OrderConfirmer
+	receipts           *ReceiptSender
+}
+
+func NewCompleteDraftOrderCommandHandler(
+	draftRepo draftorder.Repository,
+	orderRepo order.Repository,
+	paymentRepo payment.Repository,
+	createOrderHandler *CreateOrderCommandHandler,
+	confirmer *OrderConfirmer,
+	receipts *ReceiptSender,
+) *CompleteDraftOrderCommandHandler {
+	return &CompleteDraftOrderCommandHandler{
+		draftRepo:          draftRepo,
+		orderRepo:          orderRepo,
+		paymentRepo:        paymentRepo,
+		createOrderHandler: createOrderHandler,
+		confirmer:          confirmer,
+		receipts:           receipts,
+	}
+}
+
+// Handle places the order once the payment is paid, like an order staff
+// place, and reconciles its payments so it is confirmed and the customer is
+// emailed the receipt. A payment that did not go through leaves the draft
+// open to be paid again. When the order cannot be placed, such as when a
+// product sold out since the draft was priced, the draft is marked failed
+// with why, for staff to refund the payment; so is a draft paid after it
+// was cancelled. A second payment of a converted draft is added to its
+// order, which shows as overpaid. Only the delivery of the webhook that
+// claims the draft places its order; others are retried until it is done.
+func (h *CompleteDraftOrderCommandHandler) Handle(ctx context.Context, cmd CompleteDraftOrderCommand) (*draftorder.Draft, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *CompleteDraftOrderCommandHandler) handle(ctx context.Context, cmd CompleteDraftOrderCommand) (*draftorder.Draft, error) {
+	pay, err := h.paymentRepo.GetByID(ctx, cmd.PaymentID)
+	if err != nil || pay.DraftOrderID == "" {
+		return nil, ErrPaymentNotFound
+	}
+	d, err := h.draftRepo.GetByID(ctx, pay.DraftOrderID)
+	if err != nil {
+		return nil, err
+	}
+	if !pay.IsPaid() || pay.OrderID != "" {
+		return d, nil
+	}
+
+	now := time.Now()
+	if d.Status == draftorder.StatusCompleted {
+		o, err := h.orderRepo.GetByID(ctx, d.OrderID)
+		if err != nil {
+			return nil, ErrOrderNotFound
+		}
+		if err := h.attach(ctx, pay, o); err != nil {
+			return nil, err
+		}
+		return d, nil
+	}
+	if d.Status == draftorder.StatusConverting {
+		return nil, ErrDraftOrderConverting
+	}
+	if !d.Open() {
+		if d.Status != draftorder.StatusFailed {
+			d.Fail("paid after the draft was "+string(d.Status), now)
+			if err := h.draftRepo.Update(ctx, d); err != nil {
+				return nil, err
+			}
+		}
+		return d, nil
+	}
+
+	// Deliveries of the webhook can arrive together, or be retried while
+	// the first is still placing the order; only the one that claims the
+	// draft places it, the others are retried once it is done
+	claimed, err := h.draftRepo.Claim(ctx, d.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrDraftOrderConverting
+	}
+
+	o, err := h.createOrderHandler.Handle(ctx, draftOrderCommand(d))
+	if err != nil {
+		// Unexpected failures are retried with the webhook, so the draft
+		// goes back to the status it was claimed from
+		appErr := apperror.From(err)
+		if appErr.Kind == apperror.KindInternal || appErr.Kind == apperror.KindUnavailable {
+			if updateErr := h.draftRepo.Update(ctx, d); updateErr != nil {
+				return nil, updateErr
+			}
+			return nil, err
+		}
+		d.Fail(appErr.Error(), now)
+		if err := h.draftRepo.Update(ctx, d); err != nil {
+			return nil, err
+		}
+		return d, nil
+	}
+
+	d.Completed(o.ID, now)
+	if err := h.draftRepo.Update(ctx, d); err != nil {
+		return nil, err
+	}
+	if err := h.attach(ctx, pay, o); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// attach makes the payment one of the order's and reconciles the order's
+// payments.
+func (h *CompleteDraftOrderCommandHandler) attach(ctx context.Context, pay *payment.Payment, o *order.Order) error {
+	pay.OrderID = o.ID
+	pay.UpdatedAt = time.Now()
+	if err := h.paymentRepo.Update(ctx, pay); err != nil {
+		return err
+	}
+	_, err := reconcileOrderPayments(ctx, h.orderRepo, h.paymentRepo, h.confirmer, h.receipts, o)
+	return err
+}
@@ -0,0 +1,250 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/emaildelivery"
+	"online-shop/internal/domain/emailtemplate"
+	"online-shop/internal/domain/notification"
+	"online-shop/internal/domain/user"
+	"online-shop/pkg/i18n"
+
+	"online-shop/pkg/id"
+)
+
+// IngestEmailEventsCommand is a notification a provider posted to its
+// webhook. The body is kept raw, as signatures are over the exact bytes.
+type IngestEmailEventsCommand struct {
+	Provider string
+	Header   http.Header
+	Body     []byte
+}
+
+// IngestEmailEventsResult counts the events in a notification and the
+// addresses they suppressed.
+type IngestEmailEventsResult struct {
+	Events     int `json:"events"`
+	Suppressed int `json:"suppressed"`
+}
+
+// AddEmailSuppressionCommand stops sends to an address by hand, for example
+// when its owner asks support to.
+type AddEmailSuppressionCommand struct {
+	Email  string `json:"email" validate:"required,email"`
+	Detail string `json:"detail" validate:"max=500"`
+}
+
+// RemoveEmailSuppressionCommand lets the email worker send to an address
+// again, once its owner has fixed the mailbox.
+type RemoveEmailSuppressionCommand struct {
+	Email string `json:"email" validate:"required"`
+}
+
+// ResendEmailDeadLetterCommand queues a dead-lettered email again, once the
+// cause of the failure is fixed.
+type ResendEmailDeadLetterCommand struct {
+	ID string `json:"id" validate:"required"`
+}
+
+// SendEmailCampaignCommand sends a template to many recipients. The
+// recipients are queued in batches so the worker can pace them to the
+// providers' rate limits.
+type SendEmailCampaignCommand struct {
+	Template   string                    `json:"template" validate:"required"`
+	Subject    string                    `json:"subject"`
+	Locale     string                    `json:"locale" validate:"omitempty,locale"`
+	Data       map[string]interface{}    `json:"data"`
+	Recipients []emaildelivery.Recipient `json:"recipients" validate:"required,min=1,max=10000,dive"`
+}
+
+// NonTransactional: batches already queued stay queued, and are sent, when
+// queuing a later one fails.
+func (SendEmailCampaignCommand) NonTransactional() {}
+
+// EmailCampaign is a queued campaign. Its ID is on the dead letters of the
+// emails that could not be sent. Recipients counts the addresses queued;
+// Skipped those left out for not having agreed to marketing emails.
+type EmailCampaign struct {
+	ID         string `json:"id"`
+	Recipients int    `json:"recipients"`
+	Skipped    int    `json:"skipped"`
+	Batches    int    `json:"batches"`
+}
+
+type IngestEmailEventsCommandHandler struct {
+	webhooks     map[string]emaildelivery.Webhook
+	suppressions emaildelivery.SuppressionRepository
+}
+
+func NewIngestEmailEventsCommandHandler(webhooks map[string]emaildelivery.Webhook, suppressions emaildelivery.SuppressionRepository) *IngestEmailEventsCommandHandler {
+	return &IngestEmailEventsCommandHandler{webhooks: webhooks, suppressions: suppressions}
+}
+
+// Handle suppresses the addresses of hard bounces and complaints. Soft
+// bounces are only counted.
+func (h *IngestEmailEventsCommandHandler) Handle(ctx context.Context, cmd IngestEmailEventsCommand) (*IngestEmailEventsResult, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *IngestEmailEventsCommandHandler) handle(ctx context.Context, cmd IngestEmailEventsCommand) (*IngestEmailEventsResult, error) {
+	webhook, ok := h.webhooks[cmd.Provider]
+	if !ok {
+		return nil, emaildelivery.ErrUnknownWebhook
+	}
+
+	events, err := webhook.Events(ctx, cmd.Header, cmd.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &IngestEmailEventsResult{Events: len(events)}
+	for _, event := range events {
+		if !event.Suppresses() || event.Email == "" {
+			continue
+		}
+		if err := h.suppressions.Add(ctx, emaildelivery.SuppressionFor(event)); err != nil {
+			return nil, err
+		}
+		result.Suppressed++
+	}
+	return result, nil
+}
+
+type AddEmailSuppressionCommandHandler struct {
+	suppressions emaildelivery.SuppressionRepository
+}
+
+func NewAddEmailSuppressionCommandHandler(suppressions emaildelivery.SuppressionRepository) *AddEmailSuppressionCommandHandler {
+	return &AddEmailSuppressionCommandHandler{suppressions: suppressions}
+}
+
+func (h *AddEmailSuppressionCommandHandler) Handle(ctx context.Context, cmd AddEmailSuppressionCommand) (*emaildelivery.Suppression, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *AddEmailSuppressionCommandHandler) handle(ctx context.Context, cmd AddEmailSuppressionCommand) (*emaildelivery.Suppression, error) {
+	suppression := emaildelivery.NewSuppression(cmd.Email, emaildelivery.ReasonManual, "", cmd.Detail)
+	if err := h.suppressions.Add(ctx, suppression); err != nil {
+		return nil, err
+	}
+	return suppression, nil
+}
+
+type RemoveEmailSuppressionCommandHandler struct {
+	suppressions emaildelivery.SuppressionRepository
+}
+
+func NewRemoveEmailSuppressionCommandHandler(suppressions emaildelivery.SuppressionRepository) *RemoveEmailSuppressionCommandHandler {
+	return &RemoveEmailSuppressionCommandHandler{suppressions: suppressions}
+}
+
+func (h *RemoveEmailSuppressionCommandHandler) Handle(ctx context.Context, cmd RemoveEmailSuppressionCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *RemoveEmailSuppressionCommandHandler) handle(ctx context.Context, cmd RemoveEmailSuppressionCommand) error {
+	return h.suppressions.Remove(ctx, emaildelivery.NormalizeAddress(cmd.Email))
+}
+
+type ResendEmailDeadLetterCommandHandler struct {
+	deadLetters emaildelivery.DeadLetterRepository
+	publisher   emaildelivery.Publisher
+}
+
+func NewResendEmailDeadLetterCommandHandler(deadLetters emaildelivery.DeadLetterRepository, publisher emaildelivery.Publisher) *ResendEmailDeadLetterCommandHandler {
+	return &ResendEmailDeadLetterCommandHandler{deadLetters: deadLetters, publisher: publisher}
+}
+
+// Handle queues the email and records when. A resend that fails again
+// becomes a new dead letter.
+func (h *ResendEmailDeadLetterCommandHandler) Handle(ctx context.Context, cmd ResendEmailDeadLetterCommand) (*emaildelivery.DeadLetter, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *ResendEmailDeadLetterCommandHandler) handle(ctx context.Context, cmd ResendEmailDeadLetterCommand) (*emaildelivery.DeadLetter, error) {
+	deadLetter, err := h.deadLetters.Get(ctx, cmd.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.publisher.Resend(ctx, deadLetter); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := h.deadLetters.MarkResent(ctx, deadLetter.ID, now); err != nil {
+		return nil, err
+	}
+	deadLetter.ResentAt = &now
+	return deadLetter, nil
+}
+
+type SendEmailCampaignCommandHandler struct {
+	templateRepo emailtemplate.Repository
+	publisher    emaildelivery.Publisher
+	consents     user.ConsentRepository
+	batchSize    int
+}
+
+func NewSendEmailCampaignCommandHandler(templateRepo emailtemplate.Repository, publisher emaildelivery.Publisher, consents user.ConsentRepository, batchSize int) *SendEmailCampaignCommandHandler {
+	return &SendEmailCampaignCommandHandler{templateRepo: templateRepo, publisher: publisher, consents: consents, batchSize: batchSize}
+}
+
+// Handle checks every recipient's data against the template before queueing
+// anything, so a campaign with bad data sends nothing. Campaigns are
+// marketing, so only recipients whose accounts agreed to marketing emails
+// are sent to.
+func (h *SendEmailCampaignCommandHandler) Handle(ctx context.Context, cmd SendEmailCampaignCommand) (*EmailCampaign, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *SendEmailCampaignCommandHandler) handle(ctx context.Context, cmd SendEmailCampaignCommand) (*EmailCampaign, error) {
+	locale := i18n.Negotiate("", cmd.Locale)
+
+	t, err := emailtemplate.Resolve(ctx, h.templateRepo, cmd.Template, locale, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i, recipient := range cmd.Recipients {
+		if err := t.CheckData(emaildelivery.MergeData(cmd.Data, recipient.Data)); err != nil {
+			return nil, fmt.Errorf("recipients[%d]: %w", i, err)
+		}
+	}
+
+	emails := make([]string, len(cmd.Recipients))
+	for i, recipient := range cmd.Recipients {
+		emails[i] = emaildelivery.NormalizeAddress(recipient.To)
+	}
+	consented, err := h.consents.ConsentedEmails(ctx, emails)
+	if err != nil {
+		return nil, err
+	}
+	var allowed []emaildelivery.Recipient
+	for _, recipient := range cmd.Recipients {
+		if consented[emaildelivery.NormalizeAddress(recipient.To)] {
+			allowed = append(allowed, recipient)
+		}
+	}
+
+	campaign := &EmailCampaign{ID: id.New(), Recipients: len(allowed), Skipped: len(cmd.Recipients) - len(allowed)}
+	for _, recipients := range emaildelivery.Split(allowed, h.batchSize) {
+		err := h.publisher.PublishBatch(ctx, &emaildelivery.Batch{
+			CampaignID: campaign.ID,
+			Template:   cmd.Template,
+			Subject:    cmd.Subject,
+			Locale:     locale,
+			Data:       cmd.Data,
+			Recipients: recipients,
+			Category:   string(notification.CategoryMarketing),
+		})
+		if err != nil {
+			return nil, err
+		}
+		campaign.Batches++
+	}
+	return campaign, nil
+}
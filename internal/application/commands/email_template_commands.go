@@ -0,0 +1,170 @@
+package commands
+
+import (
+	"context"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/emailtemplate"
+	"online-shop/pkg/i18n"
+)
+
+// SaveEmailTemplateCommand stores a new version of a template and makes it
+// the one the email worker sends. Earlier versions are kept for rollback.
+type SaveEmailTemplateCommand struct {
+	Name      string                   `json:"name" validate:"required"`
+	Locale    string                   `json:"locale" validate:"omitempty,locale"`
+	Subject   string                   `json:"subject" validate:"required"`
+	Body      string                   `json:"body" validate:"required"`
+	Variables []emailtemplate.Variable `json:"variables"`
+	ActorID   string                   `json:"-"`
+}
+
+// ActivateEmailTemplateCommand rolls a template back to an earlier version.
+type ActivateEmailTemplateCommand struct {
+	Name    string `json:"name" validate:"required"`
+	Locale  string `json:"locale" validate:"omitempty,locale"`
+	Version int    `json:"version" validate:"required,min=1"`
+}
+
+// DeleteEmailTemplateCommand removes every stored version, so the email
+// worker goes back to the embedded default.
+type DeleteEmailTemplateCommand struct {
+	Name   string `json:"name" validate:"required"`
+	Locale string `json:"locale" validate:"omitempty,locale"`
+}
+
+// SendTestEmailCommand queues a template to an admin's address. Version 0
+// sends the active version; data left out is filled in from the variables'
+// examples.
+type SendTestEmailCommand struct {
+	Name    string                 `json:"name" validate:"required"`
+	Locale  string                 `json:"locale" validate:"omitempty,locale"`
+	Version int                    `json:"version" validate:"min=0"`
+	To      string                 `json:"to" validate:"required,email"`
+	Data    map[string]interface{} `json:"data"`
+}
+
+type SaveEmailTemplateCommandHandler struct {
+	templateRepo  emailtemplate.Repository
+	templateCache emailtemplate.Cache
+}
+
+func NewSaveEmailTemplateCommandHandler(templateRepo emailtemplate.Repository, templateCache emailtemplate.Cache) *SaveEmailTemplateCommandHandler {
+	return &SaveEmailTemplateCommandHandler{templateRepo: templateRepo, templateCache: templateCache}
+}
+
+func (h *SaveEmailTemplateCommandHandler) Handle(ctx context.Context, cmd SaveEmailTemplateCommand) (*emailtemplate.Template, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *SaveEmailTemplateCommandHandler) handle(ctx context.Context, cmd SaveEmailTemplateCommand) (*emailtemplate.Template, error) {
+	locale := i18n.Negotiate("", cmd.Locale)
+
+	versions, err := h.templateRepo.Versions(ctx, cmd.Name, locale)
+	if err != nil {
+		return nil, err
+	}
+	next := 1
+	if len(versions) > 0 {
+		next = versions[0].Version + 1
+	}
+
+	t, err := emailtemplate.NewTemplate(cmd.Name, locale, next, cmd.Subject, cmd.Body, cmd.Variables, cmd.ActorID)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.templateRepo.Create(ctx, t); err != nil {
+		return nil, err
+	}
+
+	invalidateEmailTemplate(h.templateCache, t.Name, t.Locale)
+	return t, nil
+}
+
+type ActivateEmailTemplateCommandHandler struct {
+	templateRepo  emailtemplate.Repository
+	templateCache emailtemplate.Cache
+}
+
+func NewActivateEmailTemplateCommandHandler(templateRepo emailtemplate.Repository, templateCache emailtemplate.Cache) *ActivateEmailTemplateCommandHandler {
+	return &ActivateEmailTemplateCommandHandler{templateRepo: templateRepo, templateCache: templateCache}
+}
+
+func (h *ActivateEmailTemplateCommandHandler) Handle(ctx context.Context, cmd ActivateEmailTemplateCommand) (*emailtemplate.Template, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *ActivateEmailTemplateCommandHandler) handle(ctx context.Context, cmd ActivateEmailTemplateCommand) (*emailtemplate.Template, error) {
+	locale := i18n.Negotiate("", cmd.Locale)
+
+	if err := h.templateRepo.Activate(ctx, cmd.Name, locale, cmd.Version); err != nil {
+		return nil, err
+	}
+	invalidateEmailTemplate(h.templateCache, cmd.Name, locale)
+
+	return h.templateRepo.GetVersion(ctx, cmd.Name, locale, cmd.Version)
+}
+
+type DeleteEmailTemplateCommandHandler struct {
+	templateRepo  emailtemplate.Repository
+	templateCache emailtemplate.Cache
+}
+
+func NewDeleteEmailTemplateCommandHandler(templateRepo emailtemplate.Repository, templateCache emailtemplate.Cache) *DeleteEmailTemplateCommandHandler {
+	return &DeleteEmailTemplateCommandHandler{templateRepo: templateRepo, templateCache: templateCache}
+}
+
+func (h *DeleteEmailTemplateCommandHandler) Handle(ctx context.Context, cmd DeleteEmailTemplateCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *DeleteEmailTemplateCommandHandler) handle(ctx context.Context, cmd DeleteEmailTemplateCommand) error {
+	locale := i18n.Negotiate("", cmd.Locale)
+
+	if err := h.templateRepo.Delete(ctx, cmd.Name, locale); err != nil {
+		return err
+	}
+	invalidateEmailTemplate(h.templateCache, cmd.Name, locale)
+	return nil
+}
+
+type SendTestEmailCommandHandler struct {
+	templateRepo emailtemplate.Repository
+	publisher    emailtemplate.Publisher
+}
+
+func NewSendTestEmailCommandHandler(templateRepo emailtemplate.Repository, publisher emailtemplate.Publisher) *SendTestEmailCommandHandler {
+	return &SendTestEmailCommandHandler{templateRepo: templateRepo, publisher: publisher}
+}
+
+// Handle checks the data against the template's variables before queueing,
+// so a test send fails here rather than in the worker.
+func (h *SendTestEmailCommandHandler) Handle(ctx context.Context, cmd SendTestEmailCommand) (*emailtemplate.Template, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *SendTestEmailCommandHandler) handle(ctx context.Context, cmd SendTestEmailCommand) (*emailtemplate.Template, error) {
+	locale := i18n.Negotiate("", cmd.Locale)
+
+	t, err := emailtemplate.Resolve(ctx, h.templateRepo, cmd.Name, locale, cmd.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	data := t.SampleData(cmd.Data)
+	if err := t.CheckData(data); err != nil {
+		return nil, err
+	}
+	if err := h.publisher.SendTest(ctx, cmd.To, t, locale, data); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// invalidateEmailTemplate drops the cached active version. A failure only
+// delays the change until the cached entry expires, so it does not fail the
+// command. The change is already stored, so the entry is dropped even when
+// the request was cancelled.
+func invalidateEmailTemplate(templateCache emailtemplate.Cache, name, locale string) {
+	_ = templateCache.Invalidate(context.Background(), name, locale)
+}
@@ -1,32 +1,415 @@
 package commands
 
-import "errors"
+import (
+	"online-shop/internal/domain/analytics"
+	"online-shop/internal/domain/badge"
+	"online-shop/internal/domain/cache"
+	"online-shop/internal/domain/campaign"
+	"online-shop/internal/domain/cart"
+	"online-shop/internal/domain/cms"
+	"online-shop/internal/domain/cod"
+	"online-shop/internal/domain/draftorder"
+	"online-shop/internal/domain/emaildelivery"
+	"online-shop/internal/domain/emailtemplate"
+	"online-shop/internal/domain/experiment"
+	"online-shop/internal/domain/export"
+	"online-shop/internal/domain/flashsale"
+	"online-shop/internal/domain/fraud"
+	"online-shop/internal/domain/fulfillment"
+	"online-shop/internal/domain/impersonation"
+	"online-shop/internal/domain/job"
+	"online-shop/internal/domain/maintenance"
+	"online-shop/internal/domain/merchandising"
+	"online-shop/internal/domain/moderation"
+	"online-shop/internal/domain/notification"
+	"online-shop/internal/domain/oauth"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/organization"
+	"online-shop/internal/domain/payment"
+	"online-shop/internal/domain/pricealert"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/productversion"
+	"online-shop/internal/domain/quote"
+	"online-shop/internal/domain/reconciliation"
+	"online-shop/internal/domain/session"
+	"online-shop/internal/domain/shipping"
+	"online-shop/internal/domain/stockalert"
+	"online-shop/internal/domain/storefront"
+	"online-shop/internal/domain/systemlog"
+	"online-shop/internal/domain/user"
+	"online-shop/internal/domain/warehouse"
+	"online-shop/internal/domain/webhook"
+	"online-shop/internal/domain/whatsapp"
+	"online-shop/pkg/apperror"
+)
 
 var (
 	// User errors
-	ErrUserAlreadyExists  = errors.New("user already exists")
-	ErrUserNotFound       = errors.New("user not found")
-	ErrInvalidCredentials = errors.New("invalid credentials")
-	ErrUserInactive       = errors.New("user account is inactive")
+	ErrUserAlreadyExists   = apperror.Define(apperror.KindConflict, "user_already_exists", "user already exists")
+	ErrUserNotFound        = apperror.Define(apperror.KindNotFound, "user_not_found", "user not found")
+	ErrInvalidCredentials  = apperror.Define(apperror.KindUnauthenticated, "invalid_credentials", "invalid credentials")
+	ErrUserInactive        = apperror.Define(apperror.KindPermissionDenied, "user_inactive", "user account is inactive")
+	ErrIncorrectPassword   = apperror.Define(apperror.KindInvalidArgument, "incorrect_password", "current password is incorrect")
+	ErrInvalidRefreshToken = apperror.Define(apperror.KindUnauthenticated, "invalid_refresh_token", "Invalid refresh token")
+	ErrUnsupportedLocale   = apperror.Define(apperror.KindInvalidArgument, "unsupported_locale", "locale is not supported")
 
 	// Product errors
-	ErrProductNotFound     = errors.New("product not found")
-	ErrInsufficientStock   = errors.New("insufficient stock")
-	ErrInvalidProductData  = errors.New("invalid product data")
+	ErrProductNotFound       = apperror.Define(apperror.KindNotFound, "product_not_found", "product not found")
+	ErrInsufficientStock     = apperror.Define(apperror.KindFailedPrecondition, "insufficient_stock", "insufficient stock")
+	ErrInvalidProductData    = apperror.Define(apperror.KindInvalidArgument, "invalid_product_data", "invalid product data")
+	ErrCategoryNotFound      = apperror.Define(apperror.KindNotFound, "category_not_found", "category not found")
+	ErrSlugTaken             = apperror.Define(apperror.KindConflict, "slug_taken", "slug is already in use")
+	ErrInvalidSlug           = apperror.Define(apperror.KindInvalidArgument, "invalid_slug", "invalid slug")
+	ErrInvalidFeaturedWindow = apperror.Define(apperror.KindInvalidArgument, "invalid_featured_window", "featured window must end after it starts")
 
 	// Order errors
-	ErrOrderNotFound       = errors.New("order not found")
-	ErrOrderCannotBeCancelled = errors.New("order cannot be cancelled")
-	ErrInvalidOrderData    = errors.New("invalid order data")
+	ErrOrderNotFound          = apperror.Define(apperror.KindNotFound, "order_not_found", "order not found")
+	ErrOrderCannotBeCancelled = apperror.Define(apperror.KindFailedPrecondition, "order_not_cancellable", "order cannot be cancelled")
+	ErrOrderCancelConflict    = apperror.Define(apperror.KindConflict, "order_cancel_conflict", "order changed while it was being cancelled")
+	ErrInvalidOrderData       = apperror.Define(apperror.KindInvalidArgument, "invalid_order_data", "invalid order data")
+	ErrOrderAccessDenied      = apperror.Define(apperror.KindPermissionDenied, "order_access_denied", "Access denied")
 
 	// Payment errors
-	ErrPaymentNotFound     = errors.New("payment not found")
-	ErrPaymentFailed       = errors.New("payment failed")
-	ErrPaymentExpired      = errors.New("payment expired")
-	ErrInvalidPaymentData  = errors.New("invalid payment data")
+	ErrPaymentNotFound    = apperror.Define(apperror.KindNotFound, "payment_not_found", "payment not found")
+	ErrPaymentFailed      = apperror.Define(apperror.KindFailedPrecondition, "payment_failed", "payment failed")
+	ErrPaymentExpired     = apperror.Define(apperror.KindFailedPrecondition, "payment_expired", "payment expired")
+	ErrInvalidPaymentData = apperror.Define(apperror.KindInvalidArgument, "invalid_payment_data", "invalid payment data")
+
+	// Commission errors
+	ErrCommissionRuleNotFound = apperror.Define(apperror.KindNotFound, "commission_rule_not_found", "commission rule not found")
+	ErrInvalidCommissionRule  = apperror.Define(apperror.KindInvalidArgument, "invalid_commission_rule", "invalid commission rule")
+
+	// Warehouse errors
+	ErrWarehouseNotFound    = apperror.Define(apperror.KindNotFound, "warehouse_not_found", "warehouse not found")
+	ErrInvalidWarehouseData = apperror.Define(apperror.KindInvalidArgument, "invalid_warehouse_data", "invalid warehouse data")
+
+	// Banner errors
+	ErrBannerNotFound    = apperror.Define(apperror.KindNotFound, "banner_not_found", "banner not found")
+	ErrInvalidBannerData = apperror.Define(apperror.KindInvalidArgument, "invalid_banner_data", "invalid banner data")
+
+	// Export errors
+	ErrExportNotFound    = apperror.Define(apperror.KindNotFound, "export_not_found", "export not found")
+	ErrInvalidExportData = apperror.Define(apperror.KindInvalidArgument, "invalid_export_request", "invalid export request")
+	ErrDataExportPending = apperror.Define(apperror.KindConflict, "data_export_pending", "a personal data export is already in progress")
+
+	// Backup errors
+	ErrBackupNotFound   = apperror.Define(apperror.KindNotFound, "backup_not_found", "backup not found")
+	ErrBackupInProgress = apperror.Define(apperror.KindConflict, "backup_in_progress", "a backup is already pending or running")
+
+	// Cache errors
+	ErrCacheFlushNotConfirmed = apperror.Define(apperror.KindFailedPrecondition, "cache_flush_not_confirmed", "clearing this cache scope in production requires confirm to repeat the scope")
+
+	// OAuth errors
+	ErrOAuthEmailMissing    = apperror.Define(apperror.KindFailedPrecondition, "oauth_email_missing", "the provider did not share an email address")
+	ErrOAuthEmailUnverified = apperror.Define(apperror.KindFailedPrecondition, "oauth_email_unverified", "the provider has not verified this email address")
+
+	// Experiment errors
+	ErrExperimentNotFound       = apperror.Define(apperror.KindNotFound, "experiment_not_found", "experiment not found")
+	ErrInvalidExperimentData    = apperror.Define(apperror.KindInvalidArgument, "invalid_experiment_data", "invalid experiment data")
+	ErrExperimentKeyTaken       = apperror.Define(apperror.KindConflict, "experiment_key_taken", "experiment key is already in use")
+	ErrExperimentVariantsLocked = apperror.Define(apperror.KindConflict, "experiment_variants_locked", "variants can only be changed while the experiment is a draft")
+	ErrExperimentVisitorUnknown = apperror.Define(apperror.KindInvalidArgument, "experiment_visitor_unknown", "a signed-in user or session ID is required")
+	ErrNotInExperiment          = apperror.Define(apperror.KindConflict, "not_in_experiment", "visitor is not enrolled in this experiment")
 
 	// General errors
-	ErrUnauthorized        = errors.New("unauthorized")
-	ErrForbidden          = errors.New("forbidden")
-	ErrValidationFailed   = errors.New("validation failed")
-)
\ No newline at end of file
+	ErrUnauthorized     = apperror.ErrUnauthenticated
+	ErrForbidden        = apperror.ErrPermissionDenied
+	ErrValidationFailed = apperror.ErrValidationFailed
+)
+
+// Codes for errors defined by the domain packages, which do not depend on
+// apperror
+var (
+	ErrDeletionPending               = apperror.Define(apperror.KindConflict, "account_deletion_pending", "account deletion is already pending")
+	ErrCannotFulfill                 = apperror.Define(apperror.KindFailedPrecondition, "cannot_fulfill", "insufficient stock across warehouses")
+	ErrUnknownOAuthProvider          = apperror.Define(apperror.KindNotFound, "oauth_provider_unknown", "unknown oauth provider")
+	ErrInvalidOAuthState             = apperror.Define(apperror.KindInvalidArgument, "oauth_state_invalid", "oauth state is invalid or has expired")
+	ErrSessionNotFound               = apperror.Define(apperror.KindNotFound, "session_not_found", "session not found")
+	ErrUnknownCacheScope             = apperror.Define(apperror.KindInvalidArgument, "cache_scope_unknown", "unknown cache scope")
+	ErrExportQueueUnavailable        = apperror.Define(apperror.KindUnavailable, "export_queue_unavailable", "export queue unavailable")
+	ErrInvalidTransition             = apperror.Define(apperror.KindConflict, "experiment_invalid_transition", "experiment status cannot change that way")
+	ErrInvalidLogLevel               = apperror.Define(apperror.KindInvalidArgument, "invalid_log_level", "invalid log level")
+	ErrInvalidReportRange            = apperror.Define(apperror.KindInvalidArgument, "invalid_report_range", "invalid report range")
+	ErrEmailTemplateNotFound         = apperror.Define(apperror.KindNotFound, "email_template_not_found", "email template not found")
+	ErrInvalidEmailTemplate          = apperror.Define(apperror.KindInvalidArgument, "invalid_email_template", "invalid email template")
+	ErrEmailDataMismatch             = apperror.Define(apperror.KindInvalidArgument, "email_data_mismatch", "email data does not match the template's variables")
+	ErrEmailQueueUnavailable         = apperror.Define(apperror.KindUnavailable, "email_queue_unavailable", "email queue unavailable")
+	ErrEmailNotSuppressed            = apperror.Define(apperror.KindNotFound, "email_not_suppressed", "address is not on the suppression list")
+	ErrUnknownEmailWebhook           = apperror.Define(apperror.KindNotFound, "email_webhook_unknown", "unknown email webhook")
+	ErrInvalidEmailWebhook           = apperror.Define(apperror.KindUnauthenticated, "email_webhook_invalid", "email webhook could not be verified")
+	ErrEmailDeadLetterNotFound       = apperror.Define(apperror.KindNotFound, "email_dead_letter_not_found", "email dead letter not found")
+	ErrWhatsAppTemplateNotFound      = apperror.Define(apperror.KindNotFound, "whatsapp_template_not_found", "whatsapp template not found")
+	ErrInvalidWhatsAppTemplate       = apperror.Define(apperror.KindInvalidArgument, "invalid_whatsapp_template", "invalid whatsapp template")
+	ErrWhatsAppTemplateNotApproved   = apperror.Define(apperror.KindFailedPrecondition, "whatsapp_template_not_approved", "whatsapp template is not approved")
+	ErrWhatsAppMessageNotFound       = apperror.Define(apperror.KindNotFound, "whatsapp_message_not_found", "whatsapp message not found")
+	ErrWhatsAppRejected              = apperror.Define(apperror.KindFailedPrecondition, "whatsapp_rejected", "whatsapp rejected the request")
+	ErrInvalidWhatsAppWebhook        = apperror.Define(apperror.KindUnauthenticated, "whatsapp_webhook_invalid", "whatsapp webhook could not be verified")
+	ErrWhatsAppDisabled              = apperror.Define(apperror.KindUnavailable, "whatsapp_disabled", "whatsapp is not enabled")
+	ErrCMSBlockNotFound              = apperror.Define(apperror.KindNotFound, "cms_block_not_found", "content block not found")
+	ErrCMSPageNotFound               = apperror.Define(apperror.KindNotFound, "cms_page_not_found", "page not found")
+	ErrCMSAssetNotFound              = apperror.Define(apperror.KindNotFound, "cms_asset_not_found", "asset not found")
+	ErrInvalidCMSContent             = apperror.Define(apperror.KindInvalidArgument, "invalid_cms_content", "invalid content")
+	ErrInvalidCMSAsset               = apperror.Define(apperror.KindInvalidArgument, "invalid_cms_asset", "invalid asset")
+	ErrCMSStorageUnavailable         = apperror.Define(apperror.KindUnavailable, "cms_storage_unavailable", "asset storage unavailable")
+	ErrFlashSaleNotFound             = apperror.Define(apperror.KindNotFound, "flash_sale_not_found", "flash sale not found")
+	ErrInvalidFlashSale              = apperror.Define(apperror.KindInvalidArgument, "invalid_flash_sale", "invalid flash sale")
+	ErrFlashSaleSoldOut              = apperror.Define(apperror.KindFailedPrecondition, "flash_sale_sold_out", "flash sale item is sold out")
+	ErrFlashSaleLimitReached         = apperror.Define(apperror.KindFailedPrecondition, "flash_sale_limit_reached", "flash sale purchase limit reached")
+	ErrPaymentMethodNotFound         = apperror.Define(apperror.KindNotFound, "payment_method_not_found", "payment method not found")
+	ErrInvalidPaymentMethod          = apperror.Define(apperror.KindInvalidArgument, "invalid_payment_method", "invalid payment method")
+	ErrPaymentMethodExpired          = apperror.Define(apperror.KindFailedPrecondition, "payment_method_expired", "payment method has expired")
+	ErrPaymentMethodLimitReached     = apperror.Define(apperror.KindFailedPrecondition, "payment_method_limit_reached", "too many saved payment methods")
+	ErrInvalidPaymentSplit           = apperror.Define(apperror.KindInvalidArgument, "invalid_payment_split", "invalid payment split")
+	ErrOrderPaymentsOpen             = apperror.Define(apperror.KindConflict, "order_payments_open", "order has payments awaiting settlement")
+	ErrOrderAlreadyPaid              = apperror.Define(apperror.KindFailedPrecondition, "order_already_paid", "order is already paid in full")
+	ErrOrderNotPayable               = apperror.Define(apperror.KindFailedPrecondition, "order_not_payable", "order cannot be paid")
+	ErrPaymentNotPending             = apperror.Define(apperror.KindFailedPrecondition, "payment_not_pending", "payment is no longer pending")
+	ErrReconciliationNotFound        = apperror.Define(apperror.KindNotFound, "reconciliation_not_found", "reconciliation run not found")
+	ErrReconciliationInProgress      = apperror.Define(apperror.KindConflict, "reconciliation_in_progress", "a reconciliation of that day is already pending or running")
+	ErrInvalidReconciliationPeriod   = apperror.Define(apperror.KindInvalidArgument, "invalid_reconciliation_period", "invalid reconciliation period")
+	ErrFraudReviewNotFound           = apperror.Define(apperror.KindNotFound, "fraud_review_not_found", "fraud review not found")
+	ErrFraudReviewClosed             = apperror.Define(apperror.KindFailedPrecondition, "fraud_review_closed", "order is not awaiting fraud review")
+	ErrOrderOnHold                   = apperror.Define(apperror.KindFailedPrecondition, "order_on_hold", "order is on hold for review")
+	ErrInvalidProductAttribute       = apperror.Define(apperror.KindInvalidArgument, "invalid_product_attribute", "invalid product attribute")
+	ErrInvalidAttributeTemplate      = apperror.Define(apperror.KindInvalidArgument, "invalid_attribute_template", "invalid attribute template")
+	ErrInvalidProductComparison      = apperror.Define(apperror.KindInvalidArgument, "invalid_product_comparison", "invalid product comparison")
+	ErrStockAlertNotFound            = apperror.Define(apperror.KindNotFound, "stock_alert_not_found", "stock alert not found")
+	ErrProductInStock                = apperror.Define(apperror.KindFailedPrecondition, "product_in_stock", "product is in stock")
+	ErrPriceAlertNotFound            = apperror.Define(apperror.KindNotFound, "price_alert_not_found", "price alert not found")
+	ErrInvalidPriceTarget            = apperror.Define(apperror.KindInvalidArgument, "invalid_price_target", "target price must be below the current price")
+	ErrPaymentProviderUnavailable    = apperror.Define(apperror.KindUnavailable, "payment_provider_unavailable", "payment provider unavailable, try again shortly")
+	ErrInvalidOrderListing           = apperror.Define(apperror.KindInvalidArgument, "invalid_order_listing", "invalid order listing")
+	ErrShipmentNotFound              = apperror.Define(apperror.KindNotFound, "shipment_not_found", "shipment not found")
+	ErrShipmentWrongStatus           = apperror.Define(apperror.KindFailedPrecondition, "shipment_wrong_status", "shipment cannot take this step")
+	ErrInvalidParcel                 = apperror.Define(apperror.KindInvalidArgument, "invalid_parcel", "invalid parcel")
+	ErrShippingLabelRequired         = apperror.Define(apperror.KindInvalidArgument, "shipping_label_required", "carrier and tracking number are required")
+	ErrShippingLabelFailed           = apperror.Define(apperror.KindUnavailable, "shipping_label_failed", "shipping label could not be printed, try again shortly")
+	ErrInvalidDeliveryProof          = apperror.Define(apperror.KindInvalidArgument, "invalid_delivery_proof", "invalid delivery proof")
+	ErrCODUnavailable                = apperror.Define(apperror.KindFailedPrecondition, "cash_on_delivery_unavailable", "order cannot be paid on delivery")
+	ErrCODLimitExceeded              = apperror.Define(apperror.KindFailedPrecondition, "cash_on_delivery_limit_exceeded", "cash on delivery limit exceeded")
+	ErrCODAmountMismatch             = apperror.Define(apperror.KindFailedPrecondition, "collected_amount_mismatch", "collected amount does not match the amount due")
+	ErrCODNotRemittable              = apperror.Define(apperror.KindFailedPrecondition, "collections_not_remittable", "collections cannot be remitted")
+	ErrRemittanceNotFound            = apperror.Define(apperror.KindNotFound, "remittance_not_found", "courier remittance not found")
+	ErrRefundDeclined                = apperror.Define(apperror.KindFailedPrecondition, "refund_declined", "refund was declined by the payment provider")
+	ErrWebhookEndpointNotFound       = apperror.Define(apperror.KindNotFound, "webhook_endpoint_not_found", "webhook endpoint not found")
+	ErrWebhookDeliveryNotFound       = apperror.Define(apperror.KindNotFound, "webhook_delivery_not_found", "webhook delivery not found")
+	ErrInvalidWebhookEndpoint        = apperror.Define(apperror.KindInvalidArgument, "invalid_webhook_endpoint", "invalid webhook endpoint")
+	ErrWebhookEndpointDisabled       = apperror.Define(apperror.KindFailedPrecondition, "webhook_endpoint_disabled", "webhook endpoint is disabled")
+	ErrImpersonationNotFound         = apperror.Define(apperror.KindNotFound, "impersonation_not_found", "impersonation not found")
+	ErrImpersonationNotAllowed       = apperror.Define(apperror.KindFailedPrecondition, "impersonation_not_allowed", "user cannot be impersonated")
+	ErrImpersonationInactive         = apperror.Define(apperror.KindConflict, "impersonation_inactive", "impersonation has already ended")
+	ErrMaintenanceWindowNotFound     = apperror.Define(apperror.KindNotFound, "maintenance_window_not_found", "maintenance window not found")
+	ErrInvalidMaintenancePeriod      = apperror.Define(apperror.KindInvalidArgument, "invalid_maintenance_period", "invalid maintenance period")
+	ErrInvalidCSPReport              = apperror.Define(apperror.KindInvalidArgument, "invalid_csp_report", "the body is not a CSP violation report")
+	ErrJobNotFound                   = apperror.Define(apperror.KindNotFound, "job_not_found", "job not found")
+	ErrJobNotRetryable               = apperror.Define(apperror.KindFailedPrecondition, "job_not_retryable", "only failed or cancelled jobs can be retried")
+	ErrJobNotCancellable             = apperror.Define(apperror.KindFailedPrecondition, "job_not_cancellable", "only pending jobs can be cancelled")
+	ErrJobQueueUnavailable           = apperror.Define(apperror.KindUnavailable, "job_queue_unavailable", "job queue unavailable")
+	ErrInvalidReplay                 = apperror.Define(apperror.KindInvalidArgument, "invalid_replay_range", "invalid replay range")
+	ErrUnknownProjection             = apperror.Define(apperror.KindInvalidArgument, "projection_unknown", "unknown projection")
+	ErrTrendingNotRebuildable        = apperror.Define(apperror.KindFailedPrecondition, "trending_not_rebuildable", "trending can only be replayed for a time range or one product")
+	ErrInvalidStorefrontSettings     = apperror.Define(apperror.KindInvalidArgument, "invalid_storefront_settings", "invalid storefront settings")
+	ErrShippingZoneNotFound          = apperror.Define(apperror.KindNotFound, "shipping_zone_not_found", "shipping zone not found")
+	ErrInvalidShippingZone           = apperror.Define(apperror.KindInvalidArgument, "invalid_shipping_zone", "invalid shipping zone")
+	ErrAddressNotServiceable         = apperror.Define(apperror.KindFailedPrecondition, "address_not_serviceable", "the shop does not ship to this address")
+	ErrShippingRateNotFound          = apperror.Define(apperror.KindInvalidArgument, "shipping_rate_not_found", "shipping rate not offered for the address")
+	ErrAddressNotFound               = apperror.Define(apperror.KindInvalidArgument, "address_not_found", "address could not be found")
+	ErrInvalidBundle                 = apperror.Define(apperror.KindInvalidArgument, "invalid_bundle", "invalid bundle")
+	ErrQuoteNotFound                 = apperror.Define(apperror.KindNotFound, "quote_not_found", "quote not found")
+	ErrInvalidQuoteRequest           = apperror.Define(apperror.KindInvalidArgument, "invalid_quote_request", "invalid quote request")
+	ErrInvalidQuoteOffer             = apperror.Define(apperror.KindInvalidArgument, "invalid_quote_offer", "invalid quote offer")
+	ErrQuoteWrongStatus              = apperror.Define(apperror.KindFailedPrecondition, "quote_wrong_status", "quote cannot do that in its status")
+	ErrQuoteExpired                  = apperror.Define(apperror.KindFailedPrecondition, "quote_expired", "quote has expired")
+	ErrOrganizationNotFound          = apperror.Define(apperror.KindNotFound, "organization_not_found", "organization not found")
+	ErrOrganizationMemberNotFound    = apperror.Define(apperror.KindNotFound, "organization_member_not_found", "organization member not found")
+	ErrPurchaseApprovalNotFound      = apperror.Define(apperror.KindNotFound, "purchase_approval_not_found", "purchase approval not found")
+	ErrInvoiceNotFound               = apperror.Define(apperror.KindNotFound, "invoice_not_found", "invoice not found")
+	ErrAlreadyOrganizationMember     = apperror.Define(apperror.KindConflict, "already_organization_member", "user already belongs to an organization")
+	ErrOrganizationRole              = apperror.Define(apperror.KindPermissionDenied, "organization_role", "organization role does not allow this")
+	ErrLastOrganizationAdmin         = apperror.Define(apperror.KindFailedPrecondition, "last_organization_admin", "an organization needs at least one admin")
+	ErrInvalidApprovalChain          = apperror.Define(apperror.KindInvalidArgument, "invalid_approval_chain", "invalid approval chain")
+	ErrInvoiceUnavailable            = apperror.Define(apperror.KindFailedPrecondition, "invoice_unavailable", "order cannot be paid on invoice")
+	ErrCreditLimitExceeded           = apperror.Define(apperror.KindFailedPrecondition, "credit_limit_exceeded", "organization credit limit exceeded")
+	ErrPurchaseApprovalDecided       = apperror.Define(apperror.KindConflict, "purchase_approval_decided", "purchase approval was already decided")
+	ErrInvoiceNotOpen                = apperror.Define(apperror.KindFailedPrecondition, "invoice_not_open", "invoice is not open")
+	ErrOrganizationFull              = apperror.Define(apperror.KindFailedPrecondition, "organization_full", "organization has as many members as it can")
+	ErrTranslationNotFound           = apperror.Define(apperror.KindNotFound, "translation_not_found", "translation not found")
+	ErrDefaultLocaleTranslation      = apperror.Define(apperror.KindInvalidArgument, "default_locale_translation", "products and categories are written in the default locale, which takes no translation")
+	ErrMerchandisingRuleNotFound     = apperror.Define(apperror.KindNotFound, "merchandising_rule_not_found", "merchandising rule not found")
+	ErrInvalidMerchandisingRule      = apperror.Define(apperror.KindInvalidArgument, "invalid_merchandising_rule", "invalid merchandising rule")
+	ErrBadgeNotFound                 = apperror.Define(apperror.KindNotFound, "badge_not_found", "badge not found")
+	ErrInvalidBadge                  = apperror.Define(apperror.KindInvalidArgument, "invalid_badge", "invalid badge")
+	ErrInvalidProductStatus          = apperror.Define(apperror.KindInvalidArgument, "invalid_product_status", "invalid product status")
+	ErrProductSubmissionNotFound     = apperror.Define(apperror.KindNotFound, "product_submission_not_found", "product submission not found")
+	ErrInvalidProductSubmission      = apperror.Define(apperror.KindInvalidArgument, "invalid_product_submission", "invalid product submission")
+	ErrSubmissionAlreadyReviewed     = apperror.Define(apperror.KindFailedPrecondition, "submission_already_reviewed", "product submission was already reviewed")
+	ErrProductVersionNotFound        = apperror.Define(apperror.KindNotFound, "product_version_not_found", "product version not found")
+	ErrInvalidProfile                = apperror.Define(apperror.KindInvalidArgument, "invalid_profile", "invalid profile")
+	ErrNoMarketingConsent            = apperror.Define(apperror.KindFailedPrecondition, "marketing_consent_required", "user has not agreed to marketing over this channel")
+	ErrCampaignNotFound              = apperror.Define(apperror.KindNotFound, "campaign_not_found", "campaign not found")
+	ErrInvalidCampaign               = apperror.Define(apperror.KindInvalidArgument, "invalid_campaign", "invalid campaign")
+	ErrCampaignWrongStatus           = apperror.Define(apperror.KindFailedPrecondition, "campaign_wrong_status", "campaign status does not allow this")
+	ErrInvalidTrackingLink           = apperror.Define(apperror.KindNotFound, "invalid_tracking_link", "tracking link not found")
+	ErrInvalidNotificationPreference = apperror.Define(apperror.KindInvalidArgument, "invalid_notification_preference", "invalid notification preference")
+	ErrInvalidUnsubscribeLink        = apperror.Define(apperror.KindNotFound, "invalid_unsubscribe_link", "unsubscribe link not found")
+	ErrInvalidCartItem               = apperror.Define(apperror.KindInvalidArgument, "invalid_cart_item", "invalid cart item")
+	ErrCartItemNotFound              = apperror.Define(apperror.KindNotFound, "cart_item_not_found", "cart item not found")
+	ErrCartFull                      = apperror.Define(apperror.KindFailedPrecondition, "cart_full", "cart is full")
+	ErrCartEmpty                     = apperror.Define(apperror.KindFailedPrecondition, "cart_empty", "cart is empty")
+	ErrInvalidPurchaseLimits         = apperror.Define(apperror.KindInvalidArgument, "invalid_purchase_limits", "invalid purchase limits")
+	ErrPurchaseLimitExceeded         = apperror.Define(apperror.KindFailedPrecondition, "purchase_limit_exceeded", "purchase limit exceeded")
+	ErrAgeRestricted                 = apperror.Define(apperror.KindFailedPrecondition, "age_restricted", "product is age restricted")
+	ErrBelowMinimumOrderValue        = apperror.Define(apperror.KindFailedPrecondition, "below_minimum_order_value", "order is below the minimum order value")
+	ErrInvalidOrderExtras            = apperror.Define(apperror.KindInvalidArgument, "invalid_order_extras", "invalid order note or gift options")
+	ErrOrderInvoiceUnavailable       = apperror.Define(apperror.KindFailedPrecondition, "order_invoice_unavailable", "order has no invoice")
+	ErrInvalidManualOrder            = apperror.Define(apperror.KindInvalidArgument, "invalid_manual_order", "invalid order placed for a customer")
+	ErrInvalidOfflinePayment         = apperror.Define(apperror.KindInvalidArgument, "invalid_offline_payment", "invalid offline payment")
+	ErrDraftOrderNotFound            = apperror.Define(apperror.KindNotFound, "draft_order_not_found", "draft order not found")
+	ErrInvalidDraftOrder             = apperror.Define(apperror.KindInvalidArgument, "invalid_draft_order", "invalid draft order")
+	ErrDraftOrderWrongStatus         = apperror.Define(apperror.KindFailedPrecondition, "draft_order_wrong_status", "draft order cannot do that in its status")
+	ErrDraftOrderExpired             = apperror.Define(apperror.KindFailedPrecondition, "draft_order_expired", "draft order has expired")
+	ErrDraftOrderConverting          = apperror.Define(apperror.KindUnavailable, "draft_order_converting", "draft order is being converted into an order")
+)
+
+func init() {
+	apperror.Map(user.ErrDeletionPending, ErrDeletionPending)
+	apperror.Map(warehouse.ErrCannotFulfill, ErrCannotFulfill)
+	apperror.Map(oauth.ErrUnknownProvider, ErrUnknownOAuthProvider)
+	apperror.Map(oauth.ErrInvalidState, ErrInvalidOAuthState)
+	apperror.Map(session.ErrNotFound, ErrSessionNotFound)
+	apperror.Map(cache.ErrUnknownScope, ErrUnknownCacheScope)
+	apperror.Map(export.ErrQueueUnavailable, ErrExportQueueUnavailable)
+	apperror.Map(experiment.ErrInvalidTransition, ErrInvalidTransition)
+	apperror.Map(systemlog.ErrInvalidLevel, ErrInvalidLogLevel)
+	apperror.Map(analytics.ErrInvalidRange, ErrInvalidReportRange)
+	apperror.Map(emailtemplate.ErrNotFound, ErrEmailTemplateNotFound)
+	apperror.MapWithDetail(emailtemplate.ErrInvalid, ErrInvalidEmailTemplate)
+	apperror.MapWithDetail(emailtemplate.ErrDataMismatch, ErrEmailDataMismatch)
+	apperror.Map(emailtemplate.ErrQueueUnavailable, ErrEmailQueueUnavailable)
+	apperror.Map(emaildelivery.ErrSuppressionNotFound, ErrEmailNotSuppressed)
+	apperror.Map(emaildelivery.ErrUnknownWebhook, ErrUnknownEmailWebhook)
+	apperror.Map(emaildelivery.ErrInvalidWebhook, ErrInvalidEmailWebhook)
+	apperror.Map(emaildelivery.ErrDeadLetterNotFound, ErrEmailDeadLetterNotFound)
+	apperror.Map(emaildelivery.ErrQueueUnavailable, ErrEmailQueueUnavailable)
+	apperror.Map(whatsapp.ErrTemplateNotFound, ErrWhatsAppTemplateNotFound)
+	apperror.MapWithDetail(whatsapp.ErrInvalidTemplate, ErrInvalidWhatsAppTemplate)
+	apperror.MapWithDetail(whatsapp.ErrTemplateNotApproved, ErrWhatsAppTemplateNotApproved)
+	apperror.Map(whatsapp.ErrMessageNotFound, ErrWhatsAppMessageNotFound)
+	apperror.MapWithDetail(whatsapp.ErrRejected, ErrWhatsAppRejected)
+	apperror.Map(whatsapp.ErrInvalidWebhook, ErrInvalidWhatsAppWebhook)
+	apperror.Map(whatsapp.ErrDisabled, ErrWhatsAppDisabled)
+	apperror.Map(cms.ErrBlockNotFound, ErrCMSBlockNotFound)
+	apperror.Map(cms.ErrPageNotFound, ErrCMSPageNotFound)
+	apperror.Map(cms.ErrAssetNotFound, ErrCMSAssetNotFound)
+	apperror.MapWithDetail(cms.ErrInvalidContent, ErrInvalidCMSContent)
+	apperror.MapWithDetail(cms.ErrInvalidAsset, ErrInvalidCMSAsset)
+	apperror.Map(cms.ErrStorageUnavailable, ErrCMSStorageUnavailable)
+	apperror.Map(flashsale.ErrSaleNotFound, ErrFlashSaleNotFound)
+	apperror.MapWithDetail(flashsale.ErrInvalidSale, ErrInvalidFlashSale)
+	apperror.Map(flashsale.ErrSoldOut, ErrFlashSaleSoldOut)
+	apperror.Map(flashsale.ErrLimitReached, ErrFlashSaleLimitReached)
+	apperror.Map(payment.ErrMethodNotFound, ErrPaymentMethodNotFound)
+	apperror.MapWithDetail(payment.ErrInvalidMethod, ErrInvalidPaymentMethod)
+	apperror.Map(payment.ErrMethodExpired, ErrPaymentMethodExpired)
+	apperror.MapWithDetail(payment.ErrChargeDeclined, ErrPaymentFailed)
+	apperror.MapWithDetail(payment.ErrInvalidSplit, ErrInvalidPaymentSplit)
+	apperror.Map(payment.ErrPaymentsOpen, ErrOrderPaymentsOpen)
+	apperror.Map(payment.ErrAlreadyPaid, ErrOrderAlreadyPaid)
+	apperror.Map(reconciliation.ErrNotFound, ErrReconciliationNotFound)
+	apperror.MapWithDetail(reconciliation.ErrInvalidPeriod, ErrInvalidReconciliationPeriod)
+	apperror.Map(fraud.ErrNotFound, ErrFraudReviewNotFound)
+	apperror.Map(fraud.ErrAlreadyReviewed, ErrFraudReviewClosed)
+	apperror.Map(product.ErrNotFound, ErrProductNotFound)
+	apperror.MapWithDetail(product.ErrInvalidAttribute, ErrInvalidProductAttribute)
+	apperror.MapWithDetail(product.ErrInvalidTemplate, ErrInvalidAttributeTemplate)
+	apperror.MapWithDetail(product.ErrInvalidComparison, ErrInvalidProductComparison)
+	apperror.Map(stockalert.ErrNotFound, ErrStockAlertNotFound)
+	apperror.Map(pricealert.ErrNotFound, ErrPriceAlertNotFound)
+	apperror.Map(pricealert.ErrTargetNotBelowPrice, ErrInvalidPriceTarget)
+	apperror.Map(order.ErrNotFound, ErrOrderNotFound)
+	apperror.Map(payment.ErrProviderUnavailable, ErrPaymentProviderUnavailable)
+	apperror.MapWithDetail(order.ErrInvalidListing, ErrInvalidOrderListing)
+	apperror.Map(order.ErrShipmentNotFound, ErrShipmentNotFound)
+	apperror.MapWithDetail(order.ErrShipmentState, ErrShipmentWrongStatus)
+	apperror.MapWithDetail(order.ErrInvalidParcel, ErrInvalidParcel)
+	apperror.MapWithDetail(fulfillment.ErrNoLabelPrinter, ErrShippingLabelRequired)
+	apperror.Map(fulfillment.ErrLabelFailed, ErrShippingLabelFailed)
+	apperror.MapWithDetail(order.ErrInvalidProof, ErrInvalidDeliveryProof)
+	apperror.MapWithDetail(cod.ErrNotEligible, ErrCODUnavailable)
+	apperror.MapWithDetail(cod.ErrLimitExceeded, ErrCODLimitExceeded)
+	apperror.MapWithDetail(cod.ErrAmountMismatch, ErrCODAmountMismatch)
+	apperror.MapWithDetail(cod.ErrNotRemittable, ErrCODNotRemittable)
+	apperror.Map(cod.ErrRemittanceNotFound, ErrRemittanceNotFound)
+	apperror.MapWithDetail(order.ErrNotCancellable, ErrOrderCannotBeCancelled)
+	apperror.MapWithDetail(payment.ErrRefundDeclined, ErrRefundDeclined)
+	apperror.Map(webhook.ErrEndpointNotFound, ErrWebhookEndpointNotFound)
+	apperror.Map(webhook.ErrDeliveryNotFound, ErrWebhookDeliveryNotFound)
+	apperror.MapWithDetail(webhook.ErrInvalidEndpoint, ErrInvalidWebhookEndpoint)
+	apperror.MapWithDetail(webhook.ErrEndpointDisabled, ErrWebhookEndpointDisabled)
+	apperror.Map(impersonation.ErrNotFound, ErrImpersonationNotFound)
+	apperror.MapWithDetail(impersonation.ErrNotAllowed, ErrImpersonationNotAllowed)
+	apperror.Map(impersonation.ErrInactive, ErrImpersonationInactive)
+	apperror.Map(maintenance.ErrWindowNotFound, ErrMaintenanceWindowNotFound)
+	apperror.MapWithDetail(maintenance.ErrInvalidPeriod, ErrInvalidMaintenancePeriod)
+	apperror.Map(job.ErrNotFound, ErrJobNotFound)
+	apperror.MapWithDetail(job.ErrNotRetryable, ErrJobNotRetryable)
+	apperror.MapWithDetail(job.ErrNotCancellable, ErrJobNotCancellable)
+	apperror.Map(job.ErrQueueUnavailable, ErrJobQueueUnavailable)
+	apperror.Map(analytics.ErrInvalidReplay, ErrInvalidReplay)
+	apperror.MapWithDetail(analytics.ErrUnknownProjection, ErrUnknownProjection)
+	apperror.Map(analytics.ErrTrendingNotRebuildable, ErrTrendingNotRebuildable)
+	apperror.MapWithDetail(storefront.ErrInvalidSettings, ErrInvalidStorefrontSettings)
+	apperror.Map(shipping.ErrZoneNotFound, ErrShippingZoneNotFound)
+	apperror.MapWithDetail(shipping.ErrInvalidZone, ErrInvalidShippingZone)
+	apperror.Map(shipping.ErrNotServiceable, ErrAddressNotServiceable)
+	apperror.Map(shipping.ErrRateNotFound, ErrShippingRateNotFound)
+	apperror.MapWithDetail(shipping.ErrAddressNotFound, ErrAddressNotFound)
+	apperror.MapWithDetail(product.ErrInvalidBundle, ErrInvalidBundle)
+	apperror.Map(quote.ErrNotFound, ErrQuoteNotFound)
+	apperror.MapWithDetail(quote.ErrInvalidRequest, ErrInvalidQuoteRequest)
+	apperror.MapWithDetail(quote.ErrInvalidOffer, ErrInvalidQuoteOffer)
+	apperror.MapWithDetail(quote.ErrWrongStatus, ErrQuoteWrongStatus)
+	apperror.Map(quote.ErrExpired, ErrQuoteExpired)
+	apperror.Map(organization.ErrNotFound, ErrOrganizationNotFound)
+	apperror.Map(organization.ErrMemberNotFound, ErrOrganizationMemberNotFound)
+	apperror.Map(organization.ErrApprovalNotFound, ErrPurchaseApprovalNotFound)
+	apperror.Map(organization.ErrInvoiceNotFound, ErrInvoiceNotFound)
+	apperror.Map(organization.ErrAlreadyMember, ErrAlreadyOrganizationMember)
+	apperror.MapWithDetail(organization.ErrNotPermitted, ErrOrganizationRole)
+	apperror.Map(organization.ErrLastAdmin, ErrLastOrganizationAdmin)
+	apperror.MapWithDetail(organization.ErrInvalidChain, ErrInvalidApprovalChain)
+	apperror.MapWithDetail(organization.ErrNotInvoiceable, ErrInvoiceUnavailable)
+	apperror.MapWithDetail(organization.ErrCreditExceeded, ErrCreditLimitExceeded)
+	apperror.MapWithDetail(organization.ErrDecided, ErrPurchaseApprovalDecided)
+	apperror.MapWithDetail(organization.ErrInvoiceNotOpen, ErrInvoiceNotOpen)
+	apperror.MapWithDetail(organization.ErrFull, ErrOrganizationFull)
+	apperror.Map(product.ErrTranslationNotFound, ErrTranslationNotFound)
+	apperror.Map(merchandising.ErrRuleNotFound, ErrMerchandisingRuleNotFound)
+	apperror.MapWithDetail(merchandising.ErrInvalidRule, ErrInvalidMerchandisingRule)
+	apperror.Map(badge.ErrBadgeNotFound, ErrBadgeNotFound)
+	apperror.MapWithDetail(badge.ErrInvalidBadge, ErrInvalidBadge)
+	apperror.MapWithDetail(product.ErrInvalidStatus, ErrInvalidProductStatus)
+	apperror.Map(moderation.ErrSubmissionNotFound, ErrProductSubmissionNotFound)
+	apperror.MapWithDetail(moderation.ErrInvalidSubmission, ErrInvalidProductSubmission)
+	apperror.Map(moderation.ErrAlreadyReviewed, ErrSubmissionAlreadyReviewed)
+	apperror.Map(productversion.ErrVersionNotFound, ErrProductVersionNotFound)
+	apperror.MapWithDetail(user.ErrInvalidProfile, ErrInvalidProfile)
+	apperror.Map(user.ErrNoMarketingConsent, ErrNoMarketingConsent)
+	apperror.Map(campaign.ErrNotFound, ErrCampaignNotFound)
+	apperror.MapWithDetail(campaign.ErrInvalidCampaign, ErrInvalidCampaign)
+	apperror.MapWithDetail(campaign.ErrWrongStatus, ErrCampaignWrongStatus)
+	apperror.Map(campaign.ErrInvalidLink, ErrInvalidTrackingLink)
+	apperror.MapWithDetail(notification.ErrInvalidPreference, ErrInvalidNotificationPreference)
+	apperror.Map(notification.ErrInvalidUnsubscribe, ErrInvalidUnsubscribeLink)
+	apperror.MapWithDetail(cart.ErrInvalidItem, ErrInvalidCartItem)
+	apperror.Map(cart.ErrItemNotFound, ErrCartItemNotFound)
+	apperror.Map(cart.ErrFull, ErrCartFull)
+	apperror.Map(cart.ErrEmpty, ErrCartEmpty)
+	apperror.MapWithDetail(product.ErrInvalidLimits, ErrInvalidPurchaseLimits)
+	apperror.MapWithDetail(product.ErrLimitExceeded, ErrPurchaseLimitExceeded)
+	apperror.MapWithDetail(product.ErrAgeRestricted, ErrAgeRestricted)
+	apperror.MapWithDetail(order.ErrInvalidExtras, ErrInvalidOrderExtras)
+	apperror.Map(draftorder.ErrNotFound, ErrDraftOrderNotFound)
+	apperror.MapWithDetail(draftorder.ErrWrongStatus, ErrDraftOrderWrongStatus)
+	apperror.Map(draftorder.ErrExpired, ErrDraftOrderExpired)
+}
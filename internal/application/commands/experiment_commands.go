@@ -0,0 +1,182 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/analytics"
+	"online-shop/internal/domain/experiment"
+)
+
+type CreateExperimentCommand struct {
+	Key             string               `json:"key" validate:"required"`
+	Name            string               `json:"name" validate:"required"`
+	Description     string               `json:"description"`
+	Variants        []experiment.Variant `json:"variants" validate:"required"`
+	TrafficPercent  float64              `json:"traffic_percent"`
+	ConversionEvent string               `json:"conversion_event"`
+}
+
+// UpdateExperimentCommand edits an experiment. Variants can only change
+// while it is a draft, since reweighting a running experiment would move
+// visitors between variants.
+type UpdateExperimentCommand struct {
+	ExperimentID    string               `json:"experiment_id" validate:"required"`
+	Name            *string              `json:"name"`
+	Description     *string              `json:"description"`
+	Variants        []experiment.Variant `json:"variants"`
+	TrafficPercent  *float64             `json:"traffic_percent"`
+	ConversionEvent *string              `json:"conversion_event"`
+	// Status moves the experiment to running, paused or completed
+	Status *string `json:"status"`
+}
+
+// TrackExperimentCommand records that a visitor saw their variant or
+// converted. The variant is always assigned server side.
+type TrackExperimentCommand struct {
+	ExperimentKey string
+	UserID        string
+	SessionID     string
+	Event         string
+	Value         float64
+}
+
+type CreateExperimentCommandHandler struct {
+	experimentRepo experiment.Repository
+}
+
+func NewCreateExperimentCommandHandler(experimentRepo experiment.Repository) *CreateExperimentCommandHandler {
+	return &CreateExperimentCommandHandler{experimentRepo: experimentRepo}
+}
+
+func (h *CreateExperimentCommandHandler) Handle(ctx context.Context, cmd CreateExperimentCommand) (*experiment.Experiment, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *CreateExperimentCommandHandler) handle(ctx context.Context, cmd CreateExperimentCommand) (*experiment.Experiment, error) {
+	if cmd.TrafficPercent == 0 {
+		cmd.TrafficPercent = 100
+	}
+	e, err := experiment.NewExperiment(cmd.Key, cmd.Name, cmd.Description, cmd.Variants, cmd.TrafficPercent, cmd.ConversionEvent)
+	if err != nil {
+		return nil, ErrInvalidExperimentData
+	}
+	if _, err := h.experimentRepo.GetByKey(ctx, e.Key); err == nil {
+		return nil, ErrExperimentKeyTaken
+	}
+
+	if err := h.experimentRepo.Create(ctx, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+type UpdateExperimentCommandHandler struct {
+	experimentRepo experiment.Repository
+}
+
+func NewUpdateExperimentCommandHandler(experimentRepo experiment.Repository) *UpdateExperimentCommandHandler {
+	return &UpdateExperimentCommandHandler{experimentRepo: experimentRepo}
+}
+
+func (h *UpdateExperimentCommandHandler) Handle(ctx context.Context, cmd UpdateExperimentCommand) (*experiment.Experiment, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *UpdateExperimentCommandHandler) handle(ctx context.Context, cmd UpdateExperimentCommand) (*experiment.Experiment, error) {
+	e, err := h.experimentRepo.GetByID(ctx, cmd.ExperimentID)
+	if err != nil {
+		return nil, ErrExperimentNotFound
+	}
+
+	if cmd.Name != nil {
+		e.Name = *cmd.Name
+	}
+	if cmd.Description != nil {
+		e.Description = *cmd.Description
+	}
+	if cmd.Variants != nil {
+		if e.Status != experiment.StatusDraft {
+			return nil, ErrExperimentVariantsLocked
+		}
+		e.Variants = cmd.Variants
+	}
+	if cmd.TrafficPercent != nil {
+		e.TrafficPercent = *cmd.TrafficPercent
+	}
+	if cmd.ConversionEvent != nil {
+		e.ConversionEvent = *cmd.ConversionEvent
+		if e.ConversionEvent == "" {
+			e.ConversionEvent = analytics.EventExperimentConversion
+		}
+	}
+	if err := e.Validate(); err != nil {
+		return nil, ErrInvalidExperimentData
+	}
+
+	now := time.Now()
+	if cmd.Status != nil && *cmd.Status != e.Status {
+		var err error
+		switch *cmd.Status {
+		case experiment.StatusRunning:
+			err = e.Start(now)
+		case experiment.StatusPaused:
+			err = e.Pause(now)
+		case experiment.StatusCompleted:
+			err = e.Complete(now)
+		default:
+			err = experiment.ErrInvalidTransition
+		}
+		if err != nil {
+			return nil, ErrInvalidExperimentData
+		}
+	}
+	e.UpdatedAt = now
+
+	if err := h.experimentRepo.Update(ctx, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+type TrackExperimentCommandHandler struct {
+	experimentRepo experiment.Repository
+	publisher      analytics.Publisher
+}
+
+func NewTrackExperimentCommandHandler(experimentRepo experiment.Repository, publisher analytics.Publisher) *TrackExperimentCommandHandler {
+	return &TrackExperimentCommandHandler{experimentRepo: experimentRepo, publisher: publisher}
+}
+
+// Handle publishes the exposure or conversion through the analytics pipeline
+// and returns the visitor's assignment. Visitors outside the experiment's
+// traffic are not tracked and get ErrNotInExperiment.
+func (h *TrackExperimentCommandHandler) Handle(ctx context.Context, cmd TrackExperimentCommand) (*experiment.Assignment, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *TrackExperimentCommandHandler) handle(ctx context.Context, cmd TrackExperimentCommand) (*experiment.Assignment, error) {
+	if cmd.Event != analytics.EventExperimentExposure && cmd.Event != analytics.EventExperimentConversion {
+		return nil, ErrInvalidExperimentData
+	}
+	unit := experiment.Unit(cmd.UserID, cmd.SessionID)
+	if unit == "" {
+		return nil, ErrExperimentVisitorUnknown
+	}
+
+	e, err := h.experimentRepo.GetByKey(ctx, cmd.ExperimentKey)
+	if err != nil {
+		return nil, ErrExperimentNotFound
+	}
+	variant, ok := e.Assign(unit)
+	if !ok {
+		return nil, ErrNotInExperiment
+	}
+
+	event := analytics.NewExperimentEvent(cmd.Event, e.Key, variant, cmd.UserID, cmd.SessionID, cmd.Value)
+	if err := h.publisher.Publish(ctx, event); err != nil {
+		return nil, err
+	}
+	return &experiment.Assignment{Experiment: e.Key, Variant: variant}, nil
+}
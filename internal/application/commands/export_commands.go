@@ -0,0 +1,274 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/export"
+)
+
+type RequestExportCommand struct {
+	RequestedBy string        `json:"-"`
+	Type        export.Type   `json:"type" binding:"required"`
+	Format      export.Format `json:"format" binding:"required"`
+	From        time.Time     `json:"from"`
+	To          time.Time     `json:"to"`
+	// Layout is the accounting layout of an accounting export; empty for
+	// the default one
+	Layout string `json:"layout"`
+}
+
+type RequestExportCommandHandler struct {
+	exportRepo export.Repository
+	publisher  export.Publisher
+	accounting export.Accounting
+}
+
+// NewRequestExportCommandHandler checks accounting exports against the
+// layouts of accounting.
+func NewRequestExportCommandHandler(exportRepo export.Repository, publisher export.Publisher, accounting export.Accounting) *RequestExportCommandHandler {
+	return &RequestExportCommandHandler{
+		exportRepo: exportRepo,
+		publisher:  publisher,
+		accounting: accounting,
+	}
+}
+
+// Handle records the export and queues it; the file is produced by the
+// export worker.
+func (h *RequestExportCommandHandler) Handle(ctx context.Context, cmd RequestExportCommand) (*export.Export, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *RequestExportCommandHandler) handle(ctx context.Context, cmd RequestExportCommand) (*export.Export, error) {
+	if cmd.Type == export.TypePersonalData {
+		return nil, fmt.Errorf("%w: personal data exports are requested by the account owner", ErrInvalidExportData)
+	}
+
+	e, err := export.NewExport(cmd.RequestedBy, cmd.Type, cmd.Format, cmd.From, cmd.To)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExportData, err)
+	}
+	if cmd.Type == export.TypeAccounting {
+		layout, err := h.accounting.Layout(cmd.Layout)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidExportData, err)
+		}
+		e.Layout = layout.Name
+	} else if cmd.Layout != "" {
+		return nil, fmt.Errorf("%w: only accounting exports have a layout", ErrInvalidExportData)
+	}
+
+	if err := h.exportRepo.Create(ctx, e); err != nil {
+		return nil, err
+	}
+
+	if err := h.publisher.Enqueue(ctx, e.ID); err != nil {
+		e.Fail(err)
+		h.exportRepo.Update(ctx, e)
+		return nil, err
+	}
+
+	return e, nil
+}
+
+type RequestDataExportCommand struct {
+	UserID string        `json:"-"`
+	Format export.Format `json:"format"`
+}
+
+type RequestDataExportCommandHandler struct {
+	exportRepo export.Repository
+	publisher  export.Publisher
+}
+
+func NewRequestDataExportCommandHandler(exportRepo export.Repository, publisher export.Publisher) *RequestDataExportCommandHandler {
+	return &RequestDataExportCommandHandler{
+		exportRepo: exportRepo,
+		publisher:  publisher,
+	}
+}
+
+// Handle queues a personal data export for the user. Only one may be in
+// progress at a time; the archive link is emailed once it is ready.
+func (h *RequestDataExportCommandHandler) Handle(ctx context.Context, cmd RequestDataExportCommand) (*export.Export, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *RequestDataExportCommandHandler) handle(ctx context.Context, cmd RequestDataExportCommand) (*export.Export, error) {
+	if cmd.Format == "" {
+		cmd.Format = export.FormatZIP
+	}
+
+	e, err := export.NewExport(cmd.UserID, export.TypePersonalData, cmd.Format, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExportData, err)
+	}
+
+	recent, err := h.exportRepo.ListByRequester(ctx, cmd.UserID, 20, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range recent {
+		if r.Type == export.TypePersonalData && !r.IsFinished() {
+			return nil, ErrDataExportPending
+		}
+	}
+
+	if err := h.exportRepo.Create(ctx, e); err != nil {
+		return nil, err
+	}
+
+	if err := h.publisher.Enqueue(ctx, e.ID); err != nil {
+		e.Fail(err)
+		h.exportRepo.Update(ctx, e)
+		return nil, err
+	}
+
+	return e, nil
+}
+
+type GenerateExportCommand struct {
+	ExportID string
+}
+
+// NonTransactional, as an export streams whole tables and would hold a
+// transaction open for as long as that takes.
+func (GenerateExportCommand) NonTransactional() {}
+
+type GenerateExportCommandHandler struct {
+	exportRepo export.Repository
+	source     export.Source
+	storage    export.Storage
+	encoders   map[export.Format]export.Encoder
+	personal   export.PersonalDataSource
+	archivers  map[export.Format]export.Archiver
+	notifier   export.Notifier
+	linkTTL    time.Duration
+}
+
+func NewGenerateExportCommandHandler(
+	exportRepo export.Repository,
+	source export.Source,
+	storage export.Storage,
+	encoders map[export.Format]export.Encoder,
+	personal export.PersonalDataSource,
+	archivers map[export.Format]export.Archiver,
+	notifier export.Notifier,
+	linkTTL time.Duration,
+) *GenerateExportCommandHandler {
+	return &GenerateExportCommandHandler{
+		exportRepo: exportRepo,
+		source:     source,
+		storage:    storage,
+		encoders:   encoders,
+		personal:   personal,
+		archivers:  archivers,
+		notifier:   notifier,
+		linkTTL:    linkTTL,
+	}
+}
+
+// Handle builds the export file, uploads it and notifies the requester.
+// Finished exports are skipped so a redelivered message does no extra work.
+// Returned errors are retryable; generation failures are recorded on the
+// export instead.
+func (h *GenerateExportCommandHandler) Handle(ctx context.Context, cmd GenerateExportCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *GenerateExportCommandHandler) handle(ctx context.Context, cmd GenerateExportCommand) error {
+	e, err := h.exportRepo.GetByID(ctx, cmd.ExportID)
+	if err != nil {
+		return ErrExportNotFound
+	}
+	if e.IsFinished() {
+		return nil
+	}
+
+	e.Start()
+	if err := h.exportRepo.Update(ctx, e); err != nil {
+		return err
+	}
+
+	key, rows, size, err := h.generate(ctx, e)
+	if err != nil {
+		e.Fail(err)
+		if updateErr := h.exportRepo.Update(ctx, e); updateErr != nil {
+			return updateErr
+		}
+		return h.notifier.ExportFinished(ctx, e, "")
+	}
+
+	e.Complete(key, rows, size)
+	if err := h.exportRepo.Update(ctx, e); err != nil {
+		return err
+	}
+
+	url, err := h.storage.SignedURL(e.ObjectKey, h.linkTTL)
+	if err != nil {
+		return err
+	}
+	return h.notifier.ExportFinished(ctx, e, url)
+}
+
+func (h *GenerateExportCommandHandler) generate(ctx context.Context, e *export.Export) (string, int, int64, error) {
+	if e.Type == export.TypePersonalData {
+		return h.generatePersonalData(ctx, e)
+	}
+
+	encoder, ok := h.encoders[e.Format]
+	if !ok {
+		return "", 0, 0, fmt.Errorf("unsupported export format: %s", e.Format)
+	}
+
+	table, err := h.source.Load(ctx, e)
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	var buf bytes.Buffer
+	if err := encoder.Encode(&buf, table); err != nil {
+		return "", 0, 0, err
+	}
+
+	key := fmt.Sprintf("exports/%s/%s-%s.%s", e.RequestedBy, e.Type, e.ID, encoder.Extension())
+	if err := h.storage.Put(ctx, key, encoder.ContentType(), buf.Bytes()); err != nil {
+		return "", 0, 0, err
+	}
+
+	return key, len(table.Rows), int64(buf.Len()), nil
+}
+
+// generatePersonalData archives everything held about the requester. The
+// export can only ever cover the account that asked for it.
+func (h *GenerateExportCommandHandler) generatePersonalData(ctx context.Context, e *export.Export) (string, int, int64, error) {
+	if h.personal == nil {
+		return "", 0, 0, errors.New("personal data exports are not configured")
+	}
+	archiver, ok := h.archivers[e.Format]
+	if !ok {
+		return "", 0, 0, fmt.Errorf("unsupported export format: %s", e.Format)
+	}
+
+	data, err := h.personal.LoadPersonalData(ctx, e.RequestedBy)
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	var buf bytes.Buffer
+	if err := archiver.Archive(&buf, data); err != nil {
+		return "", 0, 0, err
+	}
+
+	key := fmt.Sprintf("exports/%s/%s-%s.%s", e.RequestedBy, e.Type, e.ID, archiver.Extension())
+	if err := h.storage.Put(ctx, key, archiver.ContentType(), buf.Bytes()); err != nil {
+		return "", 0, 0, err
+	}
+
+	return key, data.Records(), int64(buf.Len()), nil
+}
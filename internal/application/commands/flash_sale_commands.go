@@ -0,0 +1,159 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/flashsale"
+	"online-shop/internal/domain/product"
+)
+
+type FlashSaleItemCmd struct {
+	ProductID    string  `json:"product_id" validate:"required"`
+	SalePrice    float64 `json:"sale_price" validate:"required,gt=0"`
+	Quantity     int     `json:"quantity" validate:"required,min=1"`
+	PerUserLimit int     `json:"per_user_limit" validate:"min=0"`
+}
+
+type CreateFlashSaleCommand struct {
+	Name     string             `json:"name" validate:"required,max=200"`
+	StartsAt time.Time          `json:"starts_at" validate:"required"`
+	EndsAt   time.Time          `json:"ends_at" validate:"required"`
+	Items    []FlashSaleItemCmd `json:"items" validate:"required,min=1,max=200,dive"`
+}
+
+// UpdateFlashSaleCommand edits a sale; items, when given, replace the
+// products on sale. Units already sold keep counting against an item's new
+// quantity.
+type UpdateFlashSaleCommand struct {
+	SaleID   string             `json:"-" validate:"required"`
+	Name     *string            `json:"name" validate:"omitempty,max=200"`
+	StartsAt *time.Time         `json:"starts_at"`
+	EndsAt   *time.Time         `json:"ends_at"`
+	Active   *bool              `json:"active"`
+	Items    []FlashSaleItemCmd `json:"items" validate:"omitempty,max=200,dive"`
+}
+
+type DeleteFlashSaleCommand struct {
+	SaleID string `json:"sale_id" validate:"required"`
+}
+
+type CreateFlashSaleCommandHandler struct {
+	saleRepo    flashsale.Repository
+	productRepo product.Repository
+}
+
+func NewCreateFlashSaleCommandHandler(saleRepo flashsale.Repository, productRepo product.Repository) *CreateFlashSaleCommandHandler {
+	return &CreateFlashSaleCommandHandler{saleRepo: saleRepo, productRepo: productRepo}
+}
+
+func (h *CreateFlashSaleCommandHandler) Handle(ctx context.Context, cmd CreateFlashSaleCommand) (*flashsale.Sale, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *CreateFlashSaleCommandHandler) handle(ctx context.Context, cmd CreateFlashSaleCommand) (*flashsale.Sale, error) {
+	s, err := flashsale.NewSale(cmd.Name, cmd.StartsAt, cmd.EndsAt, flashSaleItems(cmd.Items))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFlashSalePrices(ctx, h.productRepo, s); err != nil {
+		return nil, err
+	}
+
+	if err := h.saleRepo.Create(ctx, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+type UpdateFlashSaleCommandHandler struct {
+	saleRepo    flashsale.Repository
+	productRepo product.Repository
+}
+
+func NewUpdateFlashSaleCommandHandler(saleRepo flashsale.Repository, productRepo product.Repository) *UpdateFlashSaleCommandHandler {
+	return &UpdateFlashSaleCommandHandler{saleRepo: saleRepo, productRepo: productRepo}
+}
+
+func (h *UpdateFlashSaleCommandHandler) Handle(ctx context.Context, cmd UpdateFlashSaleCommand) (*flashsale.Sale, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *UpdateFlashSaleCommandHandler) handle(ctx context.Context, cmd UpdateFlashSaleCommand) (*flashsale.Sale, error) {
+	s, err := h.saleRepo.GetByID(ctx, cmd.SaleID)
+	if err != nil {
+		return nil, err
+	}
+
+	if cmd.Name != nil {
+		s.Name = *cmd.Name
+	}
+	if cmd.StartsAt != nil {
+		s.StartsAt = *cmd.StartsAt
+	}
+	if cmd.EndsAt != nil {
+		s.EndsAt = *cmd.EndsAt
+	}
+	if cmd.Active != nil {
+		s.Active = *cmd.Active
+	}
+	if cmd.Items != nil {
+		if err := s.SetItems(flashSaleItems(cmd.Items)); err != nil {
+			return nil, err
+		}
+		if err := checkFlashSalePrices(ctx, h.productRepo, s); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.Check(); err != nil {
+		return nil, err
+	}
+	s.UpdatedAt = time.Now()
+
+	if err := h.saleRepo.Update(ctx, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+type DeleteFlashSaleCommandHandler struct {
+	saleRepo flashsale.Repository
+}
+
+func NewDeleteFlashSaleCommandHandler(saleRepo flashsale.Repository) *DeleteFlashSaleCommandHandler {
+	return &DeleteFlashSaleCommandHandler{saleRepo: saleRepo}
+}
+
+func (h *DeleteFlashSaleCommandHandler) Handle(ctx context.Context, cmd DeleteFlashSaleCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *DeleteFlashSaleCommandHandler) handle(ctx context.Context, cmd DeleteFlashSaleCommand) error {
+	return h.saleRepo.Delete(ctx, cmd.SaleID)
+}
+
+func flashSaleItems(cmds []FlashSaleItemCmd) []flashsale.Item {
+	items := make([]flashsale.Item, 0, len(cmds))
+	for _, c := range cmds {
+		items = append(items, flashsale.Item{
+			ProductID:    c.ProductID,
+			SalePrice:    c.SalePrice,
+			Quantity:     c.Quantity,
+			PerUserLimit: c.PerUserLimit,
+		})
+	}
+	return items
+}
+
+func checkFlashSalePrices(ctx context.Context, productRepo product.Repository, s *flashsale.Sale) error {
+	ids := make([]string, 0, len(s.Items))
+	for _, item := range s.Items {
+		ids = append(ids, item.ProductID)
+	}
+	products, err := productRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	return s.CheckPrices(products)
+}
@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/analytics"
+	"online-shop/internal/domain/forecast"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/warehouse"
+)
+
+type RefreshForecastsCommand struct {
+	Policy    forecast.Policy
+	BatchSize int
+}
+
+// NonTransactional: forecasts are stored batch by batch, and a failing
+// batch leaves the earlier ones in place.
+func (RefreshForecastsCommand) NonTransactional() {}
+
+type RefreshForecastsCommandHandler struct {
+	reports      analytics.ReportRepository
+	productRepo  product.Repository
+	stockRepo    warehouse.StockRepository
+	forecastRepo forecast.Repository
+}
+
+func NewRefreshForecastsCommandHandler(reports analytics.ReportRepository, productRepo product.Repository, stockRepo warehouse.StockRepository, forecastRepo forecast.Repository) *RefreshForecastsCommandHandler {
+	return &RefreshForecastsCommandHandler{reports: reports, productRepo: productRepo, stockRepo: stockRepo, forecastRepo: forecastRepo}
+}
+
+// Handle forecasts every active product from its sales over the policy's
+// lookback, replaces the stored reorder suggestions and returns how many
+// there are now.
+func (h *RefreshForecastsCommandHandler) Handle(ctx context.Context, cmd RefreshForecastsCommand) (int, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *RefreshForecastsCommandHandler) handle(ctx context.Context, cmd RefreshForecastsCommand) (int, error) {
+	if cmd.BatchSize <= 0 {
+		cmd.BatchSize = 500
+	}
+	policy := cmd.Policy.Normalize()
+	now := time.Now()
+
+	units, err := h.reports.UnitsSold(ctx, analytics.ReportRange{From: now.AddDate(0, 0, -policy.LookbackDays), To: now})
+	if err != nil {
+		return 0, err
+	}
+	sold := make(map[string][]analytics.ProductUnits)
+	for _, u := range units {
+		sold[u.ProductID] = append(sold[u.ProductID], u)
+	}
+
+	var suggestions []*forecast.Suggestion
+	var after *product.Position
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		products, err := h.productRepo.ListAfter(ctx, product.SearchFilter{Status: product.StatusActive}, after, cmd.BatchSize)
+		if err != nil {
+			return 0, err
+		}
+		if len(products) == 0 {
+			break
+		}
+
+		ids := make([]string, len(products))
+		for i, p := range products {
+			ids[i] = p.ID
+		}
+		stocks, err := h.stockRepo.GetByProducts(ctx, ids)
+		if err != nil {
+			return 0, err
+		}
+		byProduct := make(map[string][]*warehouse.Stock)
+		for _, s := range stocks {
+			byProduct[s.ProductID] = append(byProduct[s.ProductID], s)
+		}
+
+		for _, p := range products {
+			suggestions = append(suggestions, forecast.ForecastProduct(p, byProduct[p.ID], sold[p.ID], policy, now)...)
+		}
+
+		last := product.PositionOf(products[len(products)-1])
+		after = &last
+	}
+
+	if err := h.forecastRepo.Replace(ctx, suggestions); err != nil {
+		return 0, err
+	}
+	return len(suggestions), nil
+}
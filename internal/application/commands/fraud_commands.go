@@ -0,0 +1,167 @@
+package commands
+
+import (
+	"context"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/fraud"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/user"
+)
+
+// ReviewOrderCommand approves or declines an order held for fraud review.
+type ReviewOrderCommand struct {
+	OrderID    string `json:"-" validate:"required"`
+	ReviewerID string `json:"-" validate:"required"`
+	Note       string `json:"note" validate:"omitempty,max=500"`
+}
+
+// FraudScreener scores orders as they are placed. It is given to
+// CreateOrderCommandHandler, which holds the orders it finds risky.
+type FraudScreener struct {
+	assessmentRepo fraud.Repository
+	userRepo       user.Repository
+	orderRepo      order.Repository
+	rules          fraud.Rules
+}
+
+func NewFraudScreener(assessmentRepo fraud.Repository, userRepo user.Repository, orderRepo order.Repository, rules fraud.Rules) *FraudScreener {
+	return &FraudScreener{
+		assessmentRepo: assessmentRepo,
+		userRepo:       userRepo,
+		orderRepo:      orderRepo,
+		rules:          rules,
+	}
+}
+
+// Screen scores an order that is about to be saved and holds it when the
+// score calls for a review. The assessment is saved by Record once the
+// order has been.
+func (s *FraudScreener) Screen(ctx context.Context, o *order.Order, billing *order.Address) (*fraud.Assessment, error) {
+	u, err := s.userRepo.GetByID(ctx, o.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Enough of the latest orders to tell a first order and to reach the
+	// velocity limit
+	limit := s.rules.VelocityLimit
+	if limit < 1 {
+		limit = 1
+	}
+	previous, err := s.orderRepo.GetByUserID(ctx, o.UserID, limit, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	checkout := fraud.Checkout{
+		OrderID:  o.ID,
+		UserID:   o.UserID,
+		Email:    u.Email,
+		Amount:   o.TotalAmount,
+		Shipping: fraud.Address{Country: o.ShippingAddress.Country, PostalCode: o.ShippingAddress.PostalCode},
+		At:       o.CreatedAt,
+	}
+	if billing != nil {
+		checkout.Billing = &fraud.Address{Country: billing.Country, PostalCode: billing.PostalCode}
+	}
+	for _, p := range previous {
+		checkout.PreviousOrders = append(checkout.PreviousOrders, p.CreatedAt)
+	}
+
+	a := fraud.Assess(s.rules, checkout)
+	if a.NeedsReview() {
+		o.Hold()
+	}
+	return a, nil
+}
+
+func (s *FraudScreener) Record(ctx context.Context, a *fraud.Assessment) error {
+	return s.assessmentRepo.Create(ctx, a)
+}
+
+type ApproveOrderReviewCommandHandler struct {
+	assessmentRepo fraud.Repository
+	orderRepo      order.Repository
+}
+
+func NewApproveOrderReviewCommandHandler(assessmentRepo fraud.Repository, orderRepo order.Repository) *ApproveOrderReviewCommandHandler {
+	return &ApproveOrderReviewCommandHandler{assessmentRepo: assessmentRepo, orderRepo: orderRepo}
+}
+
+// Handle releases a held order so the customer can pay for it. An order
+// the customer cancelled while it was held stays cancelled.
+func (h *ApproveOrderReviewCommandHandler) Handle(ctx context.Context, cmd ReviewOrderCommand) (*fraud.Assessment, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *ApproveOrderReviewCommandHandler) handle(ctx context.Context, cmd ReviewOrderCommand) (*fraud.Assessment, error) {
+	a, err := h.assessmentRepo.GetByOrderID(ctx, cmd.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	o, err := h.orderRepo.GetByID(ctx, cmd.OrderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+
+	if err := a.Approve(cmd.ReviewerID, cmd.Note); err != nil {
+		return nil, err
+	}
+	if o.Status == order.StatusOnHold {
+		if err := o.Release(); err != nil {
+			return nil, err
+		}
+		if err := h.orderRepo.Update(ctx, o); err != nil {
+			return nil, err
+		}
+	}
+	if err := h.assessmentRepo.Update(ctx, a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+type DeclineOrderReviewCommandHandler struct {
+	assessmentRepo     fraud.Repository
+	orderRepo          order.Repository
+	cancelOrderHandler *CancelOrderCommandHandler
+}
+
+func NewDeclineOrderReviewCommandHandler(assessmentRepo fraud.Repository, orderRepo order.Repository, cancelOrderHandler *CancelOrderCommandHandler) *DeclineOrderReviewCommandHandler {
+	return &DeclineOrderReviewCommandHandler{
+		assessmentRepo:     assessmentRepo,
+		orderRepo:          orderRepo,
+		cancelOrderHandler: cancelOrderHandler,
+	}
+}
+
+// Handle cancels a held order and puts its stock back on sale. Held orders
+// cannot be paid, so there is nothing to refund.
+func (h *DeclineOrderReviewCommandHandler) Handle(ctx context.Context, cmd ReviewOrderCommand) (*fraud.Assessment, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *DeclineOrderReviewCommandHandler) handle(ctx context.Context, cmd ReviewOrderCommand) (*fraud.Assessment, error) {
+	a, err := h.assessmentRepo.GetByOrderID(ctx, cmd.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	o, err := h.orderRepo.GetByID(ctx, cmd.OrderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+
+	if err := a.Decline(cmd.ReviewerID, cmd.Note); err != nil {
+		return nil, err
+	}
+	if o.Status == order.StatusOnHold {
+		if err := h.cancelOrderHandler.Handle(ctx, CancelOrderCommand{OrderID: o.ID, UserID: o.UserID, Reason: order.CancelReasonFraudDeclined}); err != nil {
+			return nil, err
+		}
+	}
+	if err := h.assessmentRepo.Update(ctx, a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
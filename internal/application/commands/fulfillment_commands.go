@@ -0,0 +1,279 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/cod"
+	"online-shop/internal/domain/fulfillment"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/payment"
+	"online-shop/internal/domain/warehouse"
+)
+
+// GeneratePickListCommand puts the oldest pending shipments of a warehouse
+// on a new pick list. Limit defaults to fulfillment.pick_list_size.
+type GeneratePickListCommand struct {
+	WarehouseID string `json:"-" validate:"required"`
+	Limit       int    `json:"limit" validate:"omitempty,min=1,max=500"`
+}
+
+// PackShipmentCommand records the parcel a picked shipment was packed in.
+type PackShipmentCommand struct {
+	ShipmentID  string `json:"-" validate:"required"`
+	WeightGrams int    `json:"weight_grams" validate:"required,min=1"`
+	LengthCm    int    `json:"length_cm" validate:"required,min=1"`
+	WidthCm     int    `json:"width_cm" validate:"required,min=1"`
+	HeightCm    int    `json:"height_cm" validate:"required,min=1"`
+}
+
+// ShipShipmentCommand hands a packed shipment to its carrier. Without a
+// carrier and tracking number the label is printed by the label printer.
+type ShipShipmentCommand struct {
+	ShipmentID     string `json:"-" validate:"required"`
+	Carrier        string `json:"carrier" validate:"omitempty,max=50"`
+	TrackingNumber string `json:"tracking_number" validate:"omitempty,max=100"`
+}
+
+// DeliverShipmentCommand records the proof of delivery the courier took, and
+// for an order paid on delivery the cash they collected.
+type DeliverShipmentCommand struct {
+	ShipmentID      string   `json:"-" validate:"required"`
+	ReceivedBy      string   `json:"received_by" validate:"required,notblank,max=100"`
+	PhotoURL        string   `json:"photo_url" validate:"omitempty,url,max=500"`
+	SignatureURL    string   `json:"signature_url" validate:"omitempty,url,max=500"`
+	CollectedAmount *float64 `json:"collected_amount" validate:"omitempty,gte=0"`
+}
+
+type GeneratePickListCommandHandler struct {
+	orderRepo     order.Repository
+	warehouseRepo warehouse.Repository
+	size          int
+}
+
+func NewGeneratePickListCommandHandler(orderRepo order.Repository, warehouseRepo warehouse.Repository, size int) *GeneratePickListCommandHandler {
+	if size <= 0 {
+		size = 50
+	}
+	return &GeneratePickListCommandHandler{orderRepo: orderRepo, warehouseRepo: warehouseRepo, size: size}
+}
+
+// Handle moves the shipments to picking, and their confirmed orders to
+// processing. A shipment someone else put on a list in the meantime is left
+// out.
+func (h *GeneratePickListCommandHandler) Handle(ctx context.Context, cmd GeneratePickListCommand) (*fulfillment.PickList, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *GeneratePickListCommandHandler) handle(ctx context.Context, cmd GeneratePickListCommand) (*fulfillment.PickList, error) {
+	if _, err := h.warehouseRepo.GetByID(ctx, cmd.WarehouseID); err != nil {
+		return nil, ErrWarehouseNotFound
+	}
+	if cmd.Limit <= 0 {
+		cmd.Limit = h.size
+	}
+
+	orders, err := h.orderRepo.ListForFulfillment(ctx, cmd.WarehouseID, order.ShipmentStatusPending, cmd.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var picked []*order.Order
+	for _, o := range orders {
+		for i := range o.Shipments {
+			s := &o.Shipments[i]
+			if s.WarehouseID != cmd.WarehouseID || s.Status != order.ShipmentStatusPending {
+				continue
+			}
+			if _, err := o.StartPicking(s.ID, now); err != nil {
+				return nil, err
+			}
+			err := h.orderRepo.SaveShipment(ctx, o, s, order.ShipmentStatusPending)
+			if errors.Is(err, order.ErrShipmentState) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			picked = append(picked, o)
+		}
+	}
+
+	return fulfillment.BuildPickList(cmd.WarehouseID, picked, now), nil
+}
+
+type PackShipmentCommandHandler struct {
+	orderRepo order.Repository
+}
+
+func NewPackShipmentCommandHandler(orderRepo order.Repository) *PackShipmentCommandHandler {
+	return &PackShipmentCommandHandler{orderRepo: orderRepo}
+}
+
+func (h *PackShipmentCommandHandler) Handle(ctx context.Context, cmd PackShipmentCommand) (*order.Shipment, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *PackShipmentCommandHandler) handle(ctx context.Context, cmd PackShipmentCommand) (*order.Shipment, error) {
+	o, err := h.orderRepo.GetByShipmentID(ctx, cmd.ShipmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	parcel := order.Parcel{WeightGrams: cmd.WeightGrams, LengthCm: cmd.LengthCm, WidthCm: cmd.WidthCm, HeightCm: cmd.HeightCm}
+	s, err := o.PackShipment(cmd.ShipmentID, parcel, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := h.orderRepo.SaveShipment(ctx, o, s, order.ShipmentStatusPicking); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+type ShipShipmentCommandHandler struct {
+	orderRepo order.Repository
+	codRepo   cod.Repository
+	printer   fulfillment.LabelPrinter
+	webhooks  *WebhookPublisher
+}
+
+// NewShipShipmentCommandHandler takes a nil printer when labels are made
+// outside the shop and entered by hand, and nil webhooks when third parties
+// are not told of shipments.
+func NewShipShipmentCommandHandler(orderRepo order.Repository, codRepo cod.Repository, printer fulfillment.LabelPrinter, webhooks *WebhookPublisher) *ShipShipmentCommandHandler {
+	return &ShipShipmentCommandHandler{orderRepo: orderRepo, codRepo: codRepo, printer: printer, webhooks: webhooks}
+}
+
+func (h *ShipShipmentCommandHandler) Handle(ctx context.Context, cmd ShipShipmentCommand) (*order.Shipment, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *ShipShipmentCommandHandler) handle(ctx context.Context, cmd ShipShipmentCommand) (*order.Shipment, error) {
+	o, err := h.orderRepo.GetByShipmentID(ctx, cmd.ShipmentID)
+	if err != nil {
+		return nil, err
+	}
+	s := o.Shipment(cmd.ShipmentID)
+	if s == nil {
+		return nil, order.ErrShipmentNotFound
+	}
+	// Checked before a label is bought for it
+	if s.Status != order.ShipmentStatusPacked {
+		return nil, fmt.Errorf("%w: shipment is %s, not %s", order.ErrShipmentState, s.Status, order.ShipmentStatusPacked)
+	}
+
+	label := &fulfillment.Label{Carrier: cmd.Carrier, TrackingNumber: cmd.TrackingNumber}
+	if label.Carrier == "" || label.TrackingNumber == "" {
+		if h.printer == nil {
+			return nil, fulfillment.ErrNoLabelPrinter
+		}
+		req := fulfillment.LabelRequest{
+			ShipmentID:  s.ID,
+			OrderID:     o.ID,
+			OrderNumber: o.Number,
+			WarehouseID: s.WarehouseID,
+			ShipTo:      o.ShippingAddress,
+			Parcel:      s.Parcel,
+		}
+		// The carrier is told what to collect so it is printed on the label
+		if o.PaymentStatus == order.PaymentStatusCashOnDelivery {
+			collection, err := h.codRepo.GetByOrderID(ctx, o.ID)
+			if err != nil {
+				return nil, err
+			}
+			req.CODAmount = collection.Amount
+		}
+		label, err = h.printer.Print(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s, err = o.ShipShipment(cmd.ShipmentID, label.Carrier, label.TrackingNumber, label.URL, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := h.orderRepo.SaveShipment(ctx, o, s, order.ShipmentStatusPacked); err != nil {
+		return nil, err
+	}
+	if h.webhooks != nil {
+		if err := h.webhooks.OrderShipped(ctx, o, s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+type DeliverShipmentCommandHandler struct {
+	orderRepo   order.Repository
+	paymentRepo payment.Repository
+	codRepo     cod.Repository
+}
+
+func NewDeliverShipmentCommandHandler(orderRepo order.Repository, paymentRepo payment.Repository, codRepo cod.Repository) *DeliverShipmentCommandHandler {
+	return &DeliverShipmentCommandHandler{orderRepo: orderRepo, paymentRepo: paymentRepo, codRepo: codRepo}
+}
+
+// Handle marks a shipped shipment delivered. For an order paid on delivery
+// the courier must have collected exactly what is due; the cash payment is
+// then settled and the cash counted as held by the shipment's carrier until
+// they remit it.
+func (h *DeliverShipmentCommandHandler) Handle(ctx context.Context, cmd DeliverShipmentCommand) (*order.Shipment, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *DeliverShipmentCommandHandler) handle(ctx context.Context, cmd DeliverShipmentCommand) (*order.Shipment, error) {
+	o, err := h.orderRepo.GetByShipmentID(ctx, cmd.ShipmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	proof := order.DeliveryProof{ReceivedBy: strings.TrimSpace(cmd.ReceivedBy), PhotoURL: cmd.PhotoURL, SignatureURL: cmd.SignatureURL}
+	s, err := o.DeliverShipment(cmd.ShipmentID, proof, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var collection *cod.Collection
+	if o.PaymentStatus == order.PaymentStatusCashOnDelivery {
+		if collection, err = h.codRepo.GetByOrderID(ctx, o.ID); err != nil {
+			return nil, err
+		}
+		if cmd.CollectedAmount == nil {
+			return nil, fmt.Errorf("%w: %.2f is due on delivery", cod.ErrAmountMismatch, collection.Amount)
+		}
+		if err := collection.Collect(s.Carrier, *cmd.CollectedAmount, now); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := h.orderRepo.SaveShipment(ctx, o, s, order.ShipmentStatusShipped); err != nil {
+		return nil, err
+	}
+	if collection == nil {
+		return s, nil
+	}
+
+	if err := h.codRepo.Update(ctx, collection); err != nil {
+		return nil, err
+	}
+	pay, err := h.paymentRepo.GetByID(ctx, collection.PaymentID)
+	if err != nil {
+		return nil, ErrPaymentNotFound
+	}
+	pay.MarkAsPaid(collection.ID)
+	if err := h.paymentRepo.Update(ctx, pay); err != nil {
+		return nil, err
+	}
+	if _, err := reconcileOrderPayments(ctx, h.orderRepo, h.paymentRepo, nil, nil, o); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/job"
+)
+
+// RetryJobCommand puts a failed or cancelled job back on its queue
+type RetryJobCommand struct {
+	ID string `json:"-" validate:"required"`
+}
+
+// CancelJobCommand stops a pending job from running
+type CancelJobCommand struct {
+	ID     string `json:"-" validate:"required"`
+	UserID string `json:"-" validate:"required"`
+}
+
+type RetryJobCommandHandler struct {
+	jobs      job.Repository
+	publisher job.Publisher
+}
+
+func NewRetryJobCommandHandler(jobs job.Repository, publisher job.Publisher) *RetryJobCommandHandler {
+	return &RetryJobCommandHandler{jobs: jobs, publisher: publisher}
+}
+
+// Handle queues the job's message again and records it as pending. The job
+// is retried with a fresh set of attempts; earlier ones still count in its
+// Attempts.
+func (h *RetryJobCommandHandler) Handle(ctx context.Context, cmd RetryJobCommand) (*job.Job, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *RetryJobCommandHandler) handle(ctx context.Context, cmd RetryJobCommand) (*job.Job, error) {
+	j, err := h.jobs.Get(ctx, cmd.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := j.Retry(time.Now()); err != nil {
+		return nil, err
+	}
+
+	if err := h.publisher.Requeue(ctx, j); err != nil {
+		return nil, err
+	}
+	if err := h.jobs.Save(ctx, j); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+type CancelJobCommandHandler struct {
+	jobs job.Repository
+}
+
+func NewCancelJobCommandHandler(jobs job.Repository) *CancelJobCommandHandler {
+	return &CancelJobCommandHandler{jobs: jobs}
+}
+
+func (h *CancelJobCommandHandler) Handle(ctx context.Context, cmd CancelJobCommand) (*job.Job, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *CancelJobCommandHandler) handle(ctx context.Context, cmd CancelJobCommand) (*job.Job, error) {
+	j, err := h.jobs.Get(ctx, cmd.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := j.Cancel(cmd.UserID, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := h.jobs.Save(ctx, j); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
@@ -0,0 +1,163 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/audit"
+	"online-shop/internal/domain/maintenance"
+)
+
+// SetMaintenanceCommand switches maintenance mode on or off for every API
+// instance. EndsAt, when given, switches it off again by itself.
+type SetMaintenanceCommand struct {
+	Enabled   bool       `json:"enabled"`
+	Message   string     `json:"message" validate:"max=500"`
+	EndsAt    *time.Time `json:"ends_at"`
+	ActorID   string     `json:"-" validate:"required"`
+	ActorRole string     `json:"-"`
+	RequestID string     `json:"-"`
+}
+
+// ScheduleMaintenanceCommand plans maintenance ahead, such as for a
+// database upgrade announced to customers.
+type ScheduleMaintenanceCommand struct {
+	StartsAt  time.Time `json:"starts_at" validate:"required"`
+	EndsAt    time.Time `json:"ends_at" validate:"required"`
+	Message   string    `json:"message" validate:"max=500"`
+	ActorID   string    `json:"-" validate:"required"`
+	ActorRole string    `json:"-"`
+	RequestID string    `json:"-"`
+}
+
+// CancelMaintenanceWindowCommand removes a scheduled window. A window under
+// way is cut short.
+type CancelMaintenanceWindowCommand struct {
+	ID        string `json:"-" validate:"required"`
+	ActorID   string `json:"-" validate:"required"`
+	ActorRole string `json:"-"`
+	RequestID string `json:"-"`
+}
+
+type SetMaintenanceCommandHandler struct {
+	store     maintenance.Store
+	auditRepo audit.Repository
+}
+
+func NewSetMaintenanceCommandHandler(store maintenance.Store, auditRepo audit.Repository) *SetMaintenanceCommandHandler {
+	return &SetMaintenanceCommandHandler{store: store, auditRepo: auditRepo}
+}
+
+// Handle changes the state shared by every API instance, which pick it up
+// within their refresh interval, and records the change in the audit log.
+func (h *SetMaintenanceCommandHandler) Handle(ctx context.Context, cmd SetMaintenanceCommand) (*maintenance.State, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *SetMaintenanceCommandHandler) handle(ctx context.Context, cmd SetMaintenanceCommand) (*maintenance.State, error) {
+	state, err := h.store.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *state
+	if err := state.Switch(cmd.Enabled, cmd.Message, cmd.EndsAt, cmd.ActorID, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := h.store.Save(ctx, state); err != nil {
+		return nil, err
+	}
+
+	entry := maintenanceEntry("maintenance.update", cmd.ActorID, cmd.ActorRole, cmd.RequestID)
+	entry.Changes = map[string]audit.Change{
+		"enabled": {Before: before.Enabled, After: state.Enabled},
+		"message": {Before: before.Message, After: state.Message},
+		"ends_at": {Before: before.EndsAt, After: state.EndsAt},
+	}
+	if err := h.auditRepo.Create(ctx, entry); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+type ScheduleMaintenanceCommandHandler struct {
+	store     maintenance.Store
+	auditRepo audit.Repository
+}
+
+func NewScheduleMaintenanceCommandHandler(store maintenance.Store, auditRepo audit.Repository) *ScheduleMaintenanceCommandHandler {
+	return &ScheduleMaintenanceCommandHandler{store: store, auditRepo: auditRepo}
+}
+
+func (h *ScheduleMaintenanceCommandHandler) Handle(ctx context.Context, cmd ScheduleMaintenanceCommand) (*maintenance.Window, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *ScheduleMaintenanceCommandHandler) handle(ctx context.Context, cmd ScheduleMaintenanceCommand) (*maintenance.Window, error) {
+	now := time.Now()
+	window, err := maintenance.NewWindow(cmd.StartsAt, cmd.EndsAt, cmd.Message, cmd.ActorID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := h.store.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	state.Schedule(*window, cmd.ActorID, now)
+	if err := h.store.Save(ctx, state); err != nil {
+		return nil, err
+	}
+
+	entry := maintenanceEntry("maintenance.schedule", cmd.ActorID, cmd.ActorRole, cmd.RequestID)
+	entry.Changes = map[string]audit.Change{
+		"window": {Before: nil, After: window},
+	}
+	if err := h.auditRepo.Create(ctx, entry); err != nil {
+		return nil, err
+	}
+	return window, nil
+}
+
+type CancelMaintenanceWindowCommandHandler struct {
+	store     maintenance.Store
+	auditRepo audit.Repository
+}
+
+func NewCancelMaintenanceWindowCommandHandler(store maintenance.Store, auditRepo audit.Repository) *CancelMaintenanceWindowCommandHandler {
+	return &CancelMaintenanceWindowCommandHandler{store: store, auditRepo: auditRepo}
+}
+
+func (h *CancelMaintenanceWindowCommandHandler) Handle(ctx context.Context, cmd CancelMaintenanceWindowCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *CancelMaintenanceWindowCommandHandler) handle(ctx context.Context, cmd CancelMaintenanceWindowCommand) error {
+	state, err := h.store.Get(ctx)
+	if err != nil {
+		return err
+	}
+	window, err := state.Cancel(cmd.ID, cmd.ActorID, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := h.store.Save(ctx, state); err != nil {
+		return err
+	}
+
+	entry := maintenanceEntry("maintenance.cancel", cmd.ActorID, cmd.ActorRole, cmd.RequestID)
+	entry.Changes = map[string]audit.Change{
+		"window": {Before: window, After: nil},
+	}
+	return h.auditRepo.Create(ctx, entry)
+}
+
+// maintenanceEntry records a change to maintenance mode, which has no
+// resource of its own
+func maintenanceEntry(action, actorID, actorRole, requestID string) *audit.Entry {
+	entry := audit.NewEntry(audit.SourceCommand, actorID, action, "system", "maintenance")
+	entry.ActorRole = actorRole
+	entry.RequestID = requestID
+	return entry
+}
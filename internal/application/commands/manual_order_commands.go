@@ -0,0 +1,309 @@
+package commands
+
+import (
+	"context"
+	"strings"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/audit"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/payment"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/user"
+	"online-shop/pkg/apperror"
+)
+
+// CreateManualOrderCommand places an order on a customer's behalf, such as
+// one taken over the phone. The customer is an account given by UserID, or
+// found by the email of Customer and opened when there is none.
+type CreateManualOrderCommand struct {
+	ActorID   string `json:"-" validate:"required"`
+	ActorRole string `json:"-"`
+	RequestID string `json:"-"`
+
+	UserID   string                  `json:"user_id,omitempty" validate:"required_without=Customer"`
+	Customer *ManualOrderCustomerCmd `json:"customer,omitempty"`
+	Channel  order.Channel           `json:"channel" validate:"required,oneof=phone in_store"`
+	Items    []ManualOrderItemCmd    `json:"items" validate:"required,min=1,max=100,dive"`
+	// PriceReason says why prices were overridden, and is required with them
+	PriceReason     string        `json:"price_reason,omitempty" validate:"max=500"`
+	ShippingAddress order.Address `json:"shipping_address" validate:"required"`
+	ShippingRateID  string        `json:"shipping_rate_id,omitempty"`
+	Note            string        `json:"note,omitempty" validate:"max=500"`
+	GiftWrap        bool          `json:"gift_wrap,omitempty"`
+	GiftMessage     string        `json:"gift_message,omitempty" validate:"max=300"`
+	// Payment is what the customer has already paid the shop offline
+	Payment *OfflinePaymentCmd `json:"payment,omitempty"`
+}
+
+type ManualOrderCustomerCmd struct {
+	Email     string `json:"email" validate:"required,email"`
+	FirstName string `json:"first_name" validate:"required,max=100"`
+	LastName  string `json:"last_name" validate:"max=100"`
+	Phone     string `json:"phone" validate:"max=20"`
+}
+
+type ManualOrderItemCmd struct {
+	ProductID string `json:"product_id" validate:"required"`
+	Quantity  int    `json:"quantity" validate:"required,min=1,max=1000"`
+	// Price overrides the unit price of the product or bundle
+	Price float64 `json:"price,omitempty" validate:"omitempty,gt=0"`
+}
+
+type OfflinePaymentCmd struct {
+	// Amount is what was paid; without it, everything still to pay
+	Amount float64 `json:"amount,omitempty" validate:"omitempty,gt=0"`
+	// Reference identifies the payment, such as the slip of a card
+	// terminal or the reference of a bank transfer
+	Reference string `json:"reference" validate:"required,max=100"`
+}
+
+// ManualOrder is an order staff placed for a customer, with its payments
+// when one was taken offline.
+type ManualOrder struct {
+	Order           *order.Order     `json:"order"`
+	CustomerCreated bool             `json:"customer_created"`
+	Payments        *payment.Summary `json:"payments,omitempty"`
+}
+
+type CreateManualOrderCommandHandler struct {
+	userRepo           user.Repository
+	productRepo        product.Repository
+	createOrderHandler *CreateOrderCommandHandler
+	offlinePayments    *RecordOfflinePaymentCommandHandler
+	auditRepo          audit.Repository
+}
+
+func NewCreateManualOrderCommandHandler(
+	userRepo user.Repository,
+	productRepo product.Repository,
+	createOrderHandler *CreateOrderCommandHandler,
+	offlinePayments *RecordOfflinePaymentCommandHandler,
+	auditRepo audit.Repository,
+) *CreateManualOrderCommandHandler {
+	return &CreateManualOrderCommandHandler{
+		userRepo:           userRepo,
+		productRepo:        productRepo,
+		createOrderHandler: createOrderHandler,
+		offlinePayments:    offlinePayments,
+		auditRepo:          auditRepo,
+	}
+}
+
+// Handle places the order like any other, so it is checked for stock and
+// age restrictions and charged shipping, at the prices staff set in place
+// of the products' prices and flash sales. Like an accepted quote it is not
+// held to purchase limits or the minimum order value, and it is not
+// screened for fraud. Who placed it, for whom and the prices they changed
+// are written to the audit log.
+func (h *CreateManualOrderCommandHandler) Handle(ctx context.Context, cmd CreateManualOrderCommand) (*ManualOrder, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *CreateManualOrderCommandHandler) handle(ctx context.Context, cmd CreateManualOrderCommand) (*ManualOrder, error) {
+	// The products' own prices, for the audit log of what was overridden
+	listPrices := make(map[string]float64)
+	for _, item := range cmd.Items {
+		if item.Price <= 0 {
+			continue
+		}
+		prod, err := h.productRepo.GetByID(ctx, item.ProductID)
+		if err != nil {
+			return nil, ErrProductNotFound
+		}
+		listPrices[item.ProductID] = prod.Price
+	}
+	reason := strings.TrimSpace(cmd.PriceReason)
+	if len(listPrices) > 0 && reason == "" {
+		return nil, ErrInvalidManualOrder.WithDetail("a price_reason is required to override prices")
+	}
+
+	customer, created, err := orderCustomer(ctx, h.userRepo, cmd.UserID, cmd.Customer, ErrInvalidManualOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]CreateOrderItemCmd, len(cmd.Items))
+	for i, item := range cmd.Items {
+		items[i] = CreateOrderItemCmd{ProductID: item.ProductID, Quantity: item.Quantity, Price: item.Price}
+	}
+	o, err := h.createOrderHandler.Handle(ctx, CreateOrderCommand{
+		UserID:          customer.ID,
+		Items:           items,
+		ShippingAddress: cmd.ShippingAddress,
+		ShippingRateID:  cmd.ShippingRateID,
+		Note:            cmd.Note,
+		GiftWrap:        cmd.GiftWrap,
+		GiftMessage:     cmd.GiftMessage,
+		PlacedBy:        cmd.ActorID,
+		Channel:         cmd.Channel,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entry := audit.NewEntry(audit.SourceCommand, cmd.ActorID, "order.place_for_customer", "order", o.ID)
+	entry.ActorRole = cmd.ActorRole
+	entry.RequestID = cmd.RequestID
+	entry.Changes = map[string]audit.Change{
+		"user_id": {Before: nil, After: customer.ID},
+		"channel": {Before: nil, After: cmd.Channel},
+	}
+	if created {
+		entry.Changes["customer_created"] = audit.Change{Before: nil, After: true}
+	}
+	for _, item := range cmd.Items {
+		if listPrice, ok := listPrices[item.ProductID]; ok {
+			entry.Changes["items."+item.ProductID+".price"] = audit.Change{Before: listPrice, After: item.Price}
+		}
+	}
+	if len(listPrices) > 0 {
+		entry.Changes["price_reason"] = audit.Change{Before: nil, After: reason}
+	}
+	if err := h.auditRepo.Create(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	result := &ManualOrder{Order: o, CustomerCreated: created}
+	if cmd.Payment != nil {
+		summary, err := h.offlinePayments.record(ctx, o, RecordOfflinePaymentCommand{
+			OrderID:   o.ID,
+			ActorID:   cmd.ActorID,
+			ActorRole: cmd.ActorRole,
+			RequestID: cmd.RequestID,
+			Amount:    cmd.Payment.Amount,
+			Reference: cmd.Payment.Reference,
+		})
+		if err != nil {
+			return nil, err
+		}
+		result.Payments = summary
+	}
+	return result, nil
+}
+
+// orderCustomer returns the account staff place an order for, given by
+// userID or found by the customer's email, and whether it was opened for
+// it. Like an account opened through an identity provider, one opened here
+// has no password until the customer sets one. Missing or invalid customer
+// details are invalid, an error the caller gives.
+func orderCustomer(ctx context.Context, userRepo user.Repository, userID string, customer *ManualOrderCustomerCmd, invalid *apperror.Error) (*user.User, bool, error) {
+	if userID != "" {
+		u, err := userRepo.GetByID(ctx, userID)
+		if err != nil || !u.IsActive() {
+			return nil, false, ErrUserNotFound
+		}
+		return u, false, nil
+	}
+	if customer == nil {
+		return nil, false, invalid.WithDetail("a user_id or customer is required")
+	}
+
+	email := strings.TrimSpace(customer.Email)
+	if u, err := userRepo.GetByEmail(ctx, email); err == nil && u != nil {
+		if !u.IsActive() {
+			return nil, false, ErrUserNotFound
+		}
+		return u, false, nil
+	}
+
+	u, err := user.NewExternalUser(email, strings.TrimSpace(customer.FirstName), strings.TrimSpace(customer.LastName))
+	if err != nil {
+		return nil, false, invalid.Wrap(err)
+	}
+	u.Phone = strings.TrimSpace(customer.Phone)
+	if err := userRepo.Create(ctx, u); err != nil {
+		return nil, false, err
+	}
+	return u, true, nil
+}
+
+// RecordOfflinePaymentCommand records a payment staff took for an order
+// outside the payment provider.
+type RecordOfflinePaymentCommand struct {
+	OrderID   string `json:"-" validate:"required"`
+	ActorID   string `json:"-" validate:"required"`
+	ActorRole string `json:"-"`
+	RequestID string `json:"-"`
+	// Amount is what was paid; without it, everything still to pay
+	Amount    float64 `json:"amount,omitempty" validate:"omitempty,gt=0"`
+	Reference string  `json:"reference" validate:"required,max=100"`
+}
+
+type RecordOfflinePaymentCommandHandler struct {
+	orderRepo   order.Repository
+	paymentRepo payment.Repository
+	confirmer   *OrderConfirmer
+	receipts    *ReceiptSender
+	auditRepo   audit.Repository
+}
+
+func NewRecordOfflinePaymentCommandHandler(orderRepo order.Repository, paymentRepo payment.Repository, confirmer *OrderConfirmer, receipts *ReceiptSender, auditRepo audit.Repository) *RecordOfflinePaymentCommandHandler {
+	return &RecordOfflinePaymentCommandHandler{orderRepo: orderRepo, paymentRepo: paymentRepo, confirmer: confirmer, receipts: receipts, auditRepo: auditRepo}
+}
+
+// Handle records the payment as paid and reconciles the order's payments,
+// so an order it settles is confirmed and the customer is emailed the
+// receipt. It cannot pay more than what is neither paid nor pending
+// online.
+func (h *RecordOfflinePaymentCommandHandler) Handle(ctx context.Context, cmd RecordOfflinePaymentCommand) (*payment.Summary, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *RecordOfflinePaymentCommandHandler) handle(ctx context.Context, cmd RecordOfflinePaymentCommand) (*payment.Summary, error) {
+	o, err := h.orderRepo.GetByID(ctx, cmd.OrderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+	return h.record(ctx, o, cmd)
+}
+
+func (h *RecordOfflinePaymentCommandHandler) record(ctx context.Context, o *order.Order, cmd RecordOfflinePaymentCommand) (*payment.Summary, error) {
+	switch o.Status {
+	case order.StatusOnHold:
+		return nil, ErrOrderOnHold
+	case order.StatusCancelled, order.StatusRefunded:
+		return nil, ErrOrderNotPayable
+	}
+
+	existing, err := h.paymentRepo.ListByOrderID(ctx, o.ID)
+	if err != nil {
+		return nil, err
+	}
+	summary := payment.Summarize(o.TotalAmount, existing)
+	if summary.Settled() {
+		return nil, payment.ErrAlreadyPaid
+	}
+	payable := summary.Outstanding - summary.Pending
+	if payable < payment.Tolerance {
+		return nil, ErrInvalidOfflinePayment.WithDetail("the rest of the order is pending payment")
+	}
+	amount := cmd.Amount
+	if amount == 0 {
+		amount = payable
+	}
+	if amount > payable+payment.Tolerance {
+		return nil, ErrInvalidOfflinePayment.WithDetail("at most %.2f is left to pay", payable)
+	}
+
+	pay := payment.NewPayment(o.ID, o.UserID, amount, payment.MethodOffline)
+	pay.ExternalID = pay.ID
+	pay.MarkAsPaid(strings.TrimSpace(cmd.Reference))
+	if err := h.paymentRepo.Create(ctx, pay); err != nil {
+		return nil, err
+	}
+
+	entry := audit.NewEntry(audit.SourceCommand, cmd.ActorID, "payment.record_offline", "payment", pay.ID)
+	entry.ActorRole = cmd.ActorRole
+	entry.RequestID = cmd.RequestID
+	entry.Changes = map[string]audit.Change{
+		"order_id":  {Before: nil, After: o.ID},
+		"amount":    {Before: nil, After: amount},
+		"reference": {Before: nil, After: pay.TransactionID},
+	}
+	if err := h.auditRepo.Create(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return reconcileOrderPayments(ctx, h.orderRepo, h.paymentRepo, h.confirmer, h.receipts, o)
+}
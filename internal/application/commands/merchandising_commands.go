@@ -0,0 +1,182 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/merchandising"
+	"online-shop/internal/domain/product"
+)
+
+type CreateMerchandisingRuleCommand struct {
+	Name            string     `json:"name" validate:"required,notblank,max=100"`
+	Kind            string     `json:"kind" validate:"required,oneof=pin boost bury"`
+	Query           string     `json:"query" validate:"max=200"`
+	CategoryID      string     `json:"category_id"`
+	ProductIDs      []string   `json:"product_ids" validate:"max=100,dive,required"`
+	AttributeKey    string     `json:"attribute_key" validate:"max=100"`
+	AttributeValues []string   `json:"attribute_values" validate:"max=50,dive,required"`
+	Weight          float64    `json:"weight" validate:"min=0,max=100"`
+	StartsAt        *time.Time `json:"starts_at"`
+	EndsAt          *time.Time `json:"ends_at"`
+	CreatedBy       string     `json:"-"`
+}
+
+// UpdateMerchandisingRuleCommand edits a rule; its kind stays as it was.
+type UpdateMerchandisingRuleCommand struct {
+	RuleID          string     `json:"-" validate:"required"`
+	Name            *string    `json:"name" validate:"omitempty,notblank,max=100"`
+	Query           *string    `json:"query" validate:"omitempty,max=200"`
+	CategoryID      *string    `json:"category_id"`
+	ProductIDs      *[]string  `json:"product_ids" validate:"omitempty,max=100,dive,required"`
+	AttributeKey    *string    `json:"attribute_key" validate:"omitempty,max=100"`
+	AttributeValues *[]string  `json:"attribute_values" validate:"omitempty,max=50,dive,required"`
+	Weight          *float64   `json:"weight" validate:"omitempty,min=0,max=100"`
+	Active          *bool      `json:"active"`
+	StartsAt        *time.Time `json:"starts_at"`
+	EndsAt          *time.Time `json:"ends_at"`
+}
+
+type DeleteMerchandisingRuleCommand struct {
+	RuleID string `json:"rule_id" validate:"required"`
+}
+
+// checkRuleTargets makes sure the category and the products a rule names
+// exist, for a typo not to leave the rule silently matching nothing
+func checkRuleTargets(ctx context.Context, productRepo product.Repository, categoryRepo product.CategoryRepository, r *merchandising.Rule) error {
+	if r.CategoryID != "" {
+		if _, err := categoryRepo.GetByID(ctx, r.CategoryID); err != nil {
+			return fmt.Errorf("%w: category %s not found", merchandising.ErrInvalidRule, r.CategoryID)
+		}
+	}
+	if len(r.ProductIDs) == 0 {
+		return nil
+	}
+	products, err := productRepo.GetByIDs(ctx, r.ProductIDs)
+	if err != nil {
+		return err
+	}
+	found := make(map[string]bool, len(products))
+	for _, p := range products {
+		found[p.ID] = true
+	}
+	for _, productID := range r.ProductIDs {
+		if !found[productID] {
+			return fmt.Errorf("%w: product %s not found", merchandising.ErrInvalidRule, productID)
+		}
+	}
+	return nil
+}
+
+type CreateMerchandisingRuleCommandHandler struct {
+	ruleRepo     merchandising.Repository
+	productRepo  product.Repository
+	categoryRepo product.CategoryRepository
+}
+
+func NewCreateMerchandisingRuleCommandHandler(ruleRepo merchandising.Repository, productRepo product.Repository, categoryRepo product.CategoryRepository) *CreateMerchandisingRuleCommandHandler {
+	return &CreateMerchandisingRuleCommandHandler{ruleRepo: ruleRepo, productRepo: productRepo, categoryRepo: categoryRepo}
+}
+
+func (h *CreateMerchandisingRuleCommandHandler) Handle(ctx context.Context, cmd CreateMerchandisingRuleCommand) (*merchandising.Rule, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *CreateMerchandisingRuleCommandHandler) handle(ctx context.Context, cmd CreateMerchandisingRuleCommand) (*merchandising.Rule, error) {
+	r, err := merchandising.NewRule(cmd.Name, merchandising.Kind(cmd.Kind), cmd.Query, cmd.CategoryID, cmd.ProductIDs,
+		strings.TrimSpace(cmd.AttributeKey), cmd.AttributeValues, cmd.Weight, cmd.StartsAt, cmd.EndsAt, cmd.CreatedBy)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRuleTargets(ctx, h.productRepo, h.categoryRepo, r); err != nil {
+		return nil, err
+	}
+
+	if err := h.ruleRepo.Create(ctx, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+type UpdateMerchandisingRuleCommandHandler struct {
+	ruleRepo     merchandising.Repository
+	productRepo  product.Repository
+	categoryRepo product.CategoryRepository
+}
+
+func NewUpdateMerchandisingRuleCommandHandler(ruleRepo merchandising.Repository, productRepo product.Repository, categoryRepo product.CategoryRepository) *UpdateMerchandisingRuleCommandHandler {
+	return &UpdateMerchandisingRuleCommandHandler{ruleRepo: ruleRepo, productRepo: productRepo, categoryRepo: categoryRepo}
+}
+
+func (h *UpdateMerchandisingRuleCommandHandler) Handle(ctx context.Context, cmd UpdateMerchandisingRuleCommand) (*merchandising.Rule, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *UpdateMerchandisingRuleCommandHandler) handle(ctx context.Context, cmd UpdateMerchandisingRuleCommand) (*merchandising.Rule, error) {
+	r, err := h.ruleRepo.GetByID(ctx, cmd.RuleID)
+	if err != nil {
+		return nil, err
+	}
+
+	if cmd.Name != nil {
+		r.Name = strings.TrimSpace(*cmd.Name)
+	}
+	if cmd.Query != nil {
+		r.Query = merchandising.NormalizeQuery(*cmd.Query)
+	}
+	if cmd.CategoryID != nil {
+		r.CategoryID = *cmd.CategoryID
+	}
+	if cmd.ProductIDs != nil {
+		r.ProductIDs = *cmd.ProductIDs
+	}
+	if cmd.AttributeKey != nil {
+		r.AttributeKey = strings.TrimSpace(*cmd.AttributeKey)
+	}
+	if cmd.AttributeValues != nil {
+		r.AttributeValues = *cmd.AttributeValues
+	}
+	if cmd.Weight != nil {
+		r.Weight = *cmd.Weight
+	}
+	if cmd.Active != nil {
+		r.Active = *cmd.Active
+	}
+	if cmd.StartsAt != nil {
+		r.StartsAt = cmd.StartsAt
+	}
+	if cmd.EndsAt != nil {
+		r.EndsAt = cmd.EndsAt
+	}
+	if err := r.Check(); err != nil {
+		return nil, err
+	}
+	if err := checkRuleTargets(ctx, h.productRepo, h.categoryRepo, r); err != nil {
+		return nil, err
+	}
+	r.UpdatedAt = time.Now()
+
+	if err := h.ruleRepo.Update(ctx, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+type DeleteMerchandisingRuleCommandHandler struct {
+	ruleRepo merchandising.Repository
+}
+
+func NewDeleteMerchandisingRuleCommandHandler(ruleRepo merchandising.Repository) *DeleteMerchandisingRuleCommandHandler {
+	return &DeleteMerchandisingRuleCommandHandler{ruleRepo: ruleRepo}
+}
+
+func (h *DeleteMerchandisingRuleCommandHandler) Handle(ctx context.Context, cmd DeleteMerchandisingRuleCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *DeleteMerchandisingRuleCommandHandler) handle(ctx context.Context, cmd DeleteMerchandisingRuleCommand) error {
+	return h.ruleRepo.Delete(ctx, cmd.RuleID)
+}
@@ -0,0 +1,229 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/moderation"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/searchsync"
+)
+
+// SubmitProductCommand submits a merchant's new product, when ProductID is
+// empty, or changes to one of their products for review. MerchantID is
+// empty for an admin, who may submit changes to any product but not new
+// ones. Stock is only taken for new products.
+type SubmitProductCommand struct {
+	MerchantID  string   `json:"-"`
+	ProductID   string   `json:"-"`
+	Name        string   `json:"name" validate:"required,notblank,max=200"`
+	Description string   `json:"description" validate:"max=5000"`
+	Price       float64  `json:"price" validate:"gt=0"`
+	Stock       int      `json:"stock" validate:"min=0"`
+	CategoryID  string   `json:"category_id" validate:"required"`
+	Images      []string `json:"images" validate:"max=20,dive,url"`
+}
+
+type SubmitProductCommandHandler struct {
+	productRepo    product.Repository
+	categoryRepo   product.CategoryRepository
+	submissionRepo moderation.Repository
+}
+
+func NewSubmitProductCommandHandler(productRepo product.Repository, categoryRepo product.CategoryRepository, submissionRepo moderation.Repository) *SubmitProductCommandHandler {
+	return &SubmitProductCommandHandler{productRepo: productRepo, categoryRepo: categoryRepo, submissionRepo: submissionRepo}
+}
+
+// Handle records the submission. A product that is not live yet takes the
+// content at once and waits for review; a live product stays as it is
+// until the changes are approved. Submitting again while a submission is
+// pending replaces its content.
+func (h *SubmitProductCommandHandler) Handle(ctx context.Context, cmd SubmitProductCommand) (*moderation.Submission, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *SubmitProductCommandHandler) handle(ctx context.Context, cmd SubmitProductCommand) (*moderation.Submission, error) {
+	content := moderation.Content{
+		Name:        cmd.Name,
+		Description: cmd.Description,
+		Price:       cmd.Price,
+		CategoryID:  cmd.CategoryID,
+		Images:      cmd.Images,
+	}
+	if err := content.Check(); err != nil {
+		return nil, err
+	}
+	if _, err := h.categoryRepo.GetByID(ctx, content.CategoryID); err != nil {
+		return nil, ErrCategoryNotFound
+	}
+	now := time.Now()
+
+	if cmd.ProductID == "" {
+		return h.submitNew(ctx, cmd, content, now)
+	}
+
+	p, err := h.productRepo.GetByID(ctx, cmd.ProductID)
+	if err != nil || p.Status == product.StatusDeleted || (cmd.MerchantID != "" && p.MerchantID != cmd.MerchantID) {
+		return nil, ErrProductNotFound
+	}
+	if !moderation.IsLive(p) {
+		content.Apply(p, now)
+		p.Status = product.StatusPendingReview
+		p.PublishAt = nil
+		if err := h.productRepo.Update(ctx, p); err != nil {
+			return nil, err
+		}
+	}
+
+	s, err := h.submissionRepo.Pending(ctx, p.ID)
+	if errors.Is(err, moderation.ErrSubmissionNotFound) {
+		s = moderation.NewSubmission(p, content, now)
+		if err := h.submissionRepo.Create(ctx, s); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Resubmit(content, now); err != nil {
+		return nil, err
+	}
+	if err := h.submissionRepo.Update(ctx, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (h *SubmitProductCommandHandler) submitNew(ctx context.Context, cmd SubmitProductCommand, content moderation.Content, now time.Time) (*moderation.Submission, error) {
+	if cmd.MerchantID == "" {
+		return nil, fmt.Errorf("%w: new products are submitted by their merchant", moderation.ErrInvalidSubmission)
+	}
+
+	// A failed slug lookup is not the merchant's mistake
+	var lookupErr error
+	slugExists := func(slug string) (bool, error) {
+		taken, err := h.productRepo.SlugExists(ctx, slug)
+		lookupErr = err
+		return taken, err
+	}
+	p, err := product.NewProduct(content.Name, content.Description, content.Price, cmd.Stock, content.CategoryID, cmd.MerchantID, content.Images, slugExists)
+	if lookupErr != nil {
+		return nil, lookupErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", moderation.ErrInvalidSubmission, err)
+	}
+	p.Status = product.StatusPendingReview
+	if err := h.productRepo.Create(ctx, p); err != nil {
+		return nil, err
+	}
+
+	s := moderation.NewSubmission(p, content, now)
+	if err := h.submissionRepo.Create(ctx, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+type ReviewProductSubmissionCommand struct {
+	SubmissionID string `json:"-" validate:"required"`
+	ReviewedBy   string `json:"-"`
+	Approve      bool   `json:"-"`
+	Comment      string `json:"comment" validate:"max=2000"`
+}
+
+type ReviewProductSubmissionCommandHandler struct {
+	submissionRepo moderation.Repository
+	productRepo    product.Repository
+	categoryRepo   product.CategoryRepository
+	webhooks       *WebhookPublisher
+	// index and productCache are nil when the search index follows the
+	// database through the search sync outbox
+	index        searchsync.Index
+	productCache searchsync.ProductCache
+}
+
+func NewReviewProductSubmissionCommandHandler(submissionRepo moderation.Repository, productRepo product.Repository, categoryRepo product.CategoryRepository, webhooks *WebhookPublisher, index searchsync.Index, productCache searchsync.ProductCache) *ReviewProductSubmissionCommandHandler {
+	return &ReviewProductSubmissionCommandHandler{
+		submissionRepo: submissionRepo,
+		productRepo:    productRepo,
+		categoryRepo:   categoryRepo,
+		webhooks:       webhooks,
+		index:          index,
+		productCache:   productCache,
+	}
+}
+
+// Handle approves or rejects a pending submission. Approved content goes
+// live on the product and to the search index; rejecting needs a comment
+// for the merchant.
+func (h *ReviewProductSubmissionCommandHandler) Handle(ctx context.Context, cmd ReviewProductSubmissionCommand) (*moderation.Submission, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *ReviewProductSubmissionCommandHandler) handle(ctx context.Context, cmd ReviewProductSubmissionCommand) (*moderation.Submission, error) {
+	s, err := h.submissionRepo.GetByID(ctx, cmd.SubmissionID)
+	if err != nil {
+		return nil, err
+	}
+	p, err := h.productRepo.GetByID(ctx, s.ProductID)
+	if err != nil || p.Status == product.StatusDeleted {
+		return nil, ErrProductNotFound
+	}
+	now := time.Now()
+
+	if !cmd.Approve {
+		if err := s.Reject(p, cmd.ReviewedBy, cmd.Comment, now); err != nil {
+			return nil, err
+		}
+		if s.Kind == moderation.KindCreate {
+			if err := h.productRepo.Update(ctx, p); err != nil {
+				return nil, err
+			}
+		}
+		if err := h.submissionRepo.Update(ctx, s); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+
+	// The category may have been deleted since the submission
+	if _, err := h.categoryRepo.GetByID(ctx, s.Content.CategoryID); err != nil {
+		return nil, ErrCategoryNotFound
+	}
+	if err := s.Approve(p, cmd.ReviewedBy, cmd.Comment, now); err != nil {
+		return nil, err
+	}
+	if err := h.productRepo.Update(ctx, p); err != nil {
+		return nil, err
+	}
+	if err := h.submissionRepo.Update(ctx, s); err != nil {
+		return nil, err
+	}
+
+	if h.index != nil {
+		// Read again for the category the index carries
+		live, err := h.productRepo.GetByID(ctx, p.ID)
+		if err != nil {
+			return nil, err
+		}
+		if err := h.index.IndexProduct(ctx, live); err != nil {
+			return nil, err
+		}
+	}
+	if h.productCache != nil {
+		if err := h.productCache.InvalidateProduct(ctx, p.ID); err != nil {
+			return nil, err
+		}
+	}
+	if h.webhooks != nil {
+		if err := h.webhooks.ProductUpdated(ctx, p); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
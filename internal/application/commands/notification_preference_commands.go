@@ -0,0 +1,140 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/notification"
+	"online-shop/internal/domain/user"
+)
+
+// UpdateNotificationPreferencesCommand turns categories on or off per
+// channel, e.g. {"orders": {"sms": false}, "marketing": {"email": true}}.
+// Categories and channels left out keep their choice.
+type UpdateNotificationPreferencesCommand struct {
+	UserID      string                     `json:"-" validate:"required"`
+	Preferences map[string]map[string]bool `json:"preferences" validate:"required"`
+}
+
+// UnsubscribeCommand turns off the emails an unsubscribe link is for.
+type UnsubscribeCommand struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type UpdateNotificationPreferencesCommandHandler struct {
+	userRepo user.Repository
+	prefRepo notification.Repository
+}
+
+func NewUpdateNotificationPreferencesCommandHandler(userRepo user.Repository, prefRepo notification.Repository) *UpdateNotificationPreferencesCommandHandler {
+	return &UpdateNotificationPreferencesCommandHandler{userRepo: userRepo, prefRepo: prefRepo}
+}
+
+// Handle saves the choices and returns the whole preference center.
+// Marketing choices are the user's marketing consent.
+func (h *UpdateNotificationPreferencesCommandHandler) Handle(ctx context.Context, cmd UpdateNotificationPreferencesCommand) ([]notification.Settings, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *UpdateNotificationPreferencesCommandHandler) handle(ctx context.Context, cmd UpdateNotificationPreferencesCommand) ([]notification.Settings, error) {
+	u, err := h.userRepo.GetByID(ctx, cmd.UserID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	now := time.Now()
+	var prefs []*notification.Preference
+	consentChanged := false
+	for category, channels := range cmd.Preferences {
+		for channel, enabled := range channels {
+			if err := notification.Check(notification.Category(category), user.Channel(channel), enabled); err != nil {
+				return nil, err
+			}
+			switch notification.Category(category) {
+			case notification.CategoryMarketing:
+				if err := u.SetMarketingConsent(user.Channel(channel), enabled, now); err != nil {
+					return nil, err
+				}
+				consentChanged = true
+			case notification.CategoryAccount:
+			default:
+				prefs = append(prefs, &notification.Preference{
+					UserID:    u.ID,
+					Category:  notification.Category(category),
+					Channel:   user.Channel(channel),
+					Enabled:   enabled,
+					UpdatedAt: now,
+				})
+			}
+		}
+	}
+
+	if err := h.prefRepo.Save(ctx, prefs); err != nil {
+		return nil, err
+	}
+	if consentChanged {
+		if err := h.userRepo.Update(ctx, u); err != nil {
+			return nil, err
+		}
+	}
+
+	stored, err := h.prefRepo.List(ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	return notification.Center(u, stored), nil
+}
+
+type UnsubscribeCommandHandler struct {
+	userRepo user.Repository
+	prefRepo notification.Repository
+	links    *notification.Links
+}
+
+func NewUnsubscribeCommandHandler(userRepo user.Repository, prefRepo notification.Repository, links *notification.Links) *UnsubscribeCommandHandler {
+	return &UnsubscribeCommandHandler{userRepo: userRepo, prefRepo: prefRepo, links: links}
+}
+
+// Handle turns the link's category off over email and returns what it
+// turned off. Following a link again, or one for an address that has no
+// account, changes nothing and succeeds.
+func (h *UnsubscribeCommandHandler) Handle(ctx context.Context, cmd UnsubscribeCommand) (*notification.Unsubscribe, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *UnsubscribeCommandHandler) handle(ctx context.Context, cmd UnsubscribeCommand) (*notification.Unsubscribe, error) {
+	link, err := h.links.Parse(cmd.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := h.userRepo.GetByEmail(ctx, link.Email)
+	if err != nil {
+		return &link, nil
+	}
+
+	now := time.Now()
+	if link.Category == notification.CategoryMarketing {
+		if err := u.SetMarketingConsent(user.ChannelEmail, false, now); err != nil {
+			return nil, err
+		}
+		if err := h.userRepo.Update(ctx, u); err != nil {
+			return nil, fmt.Errorf("failed to withdraw marketing consent: %w", err)
+		}
+		return &link, nil
+	}
+
+	err = h.prefRepo.Save(ctx, []*notification.Preference{{
+		UserID:    u.ID,
+		Category:  link.Category,
+		Channel:   user.ChannelEmail,
+		Enabled:   false,
+		UpdatedAt: now,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
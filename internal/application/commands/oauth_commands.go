@@ -0,0 +1,184 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/oauth"
+	"online-shop/internal/domain/user"
+)
+
+type StartOAuthLoginCommand struct {
+	Provider string `json:"provider"`
+}
+
+// OAuthLoginStart is where to send the browser, with the binding it keeps
+// until the callback for as long as the login may take.
+type OAuthLoginStart struct {
+	URL     string
+	Binding string
+	TTL     time.Duration
+	// FormPost is set for providers that post the callback back from their
+	// own site, such as Apple
+	FormPost bool
+}
+
+// formPoster is met by providers that know how they send the callback
+type formPoster interface {
+	FormPost() bool
+}
+
+type StartOAuthLoginCommandHandler struct {
+	providers  map[string]oauth.Provider
+	stateStore oauth.StateStore
+	stateTTL   time.Duration
+}
+
+func NewStartOAuthLoginCommandHandler(providers map[string]oauth.Provider, stateStore oauth.StateStore, stateTTL time.Duration) *StartOAuthLoginCommandHandler {
+	return &StartOAuthLoginCommandHandler{
+		providers:  providers,
+		stateStore: stateStore,
+		stateTTL:   stateTTL,
+	}
+}
+
+// Handle stores a fresh login state and returns the provider URL to send the
+// browser to, with the binding the browser must hand back on the callback.
+func (h *StartOAuthLoginCommandHandler) Handle(ctx context.Context, cmd StartOAuthLoginCommand) (*OAuthLoginStart, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *StartOAuthLoginCommandHandler) handle(ctx context.Context, cmd StartOAuthLoginCommand) (*OAuthLoginStart, error) {
+	provider, ok := h.providers[cmd.Provider]
+	if !ok {
+		return nil, oauth.ErrUnknownProvider
+	}
+
+	state, err := oauth.NewState(cmd.Provider)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.stateStore.Save(ctx, state, h.stateTTL); err != nil {
+		return nil, err
+	}
+
+	start := &OAuthLoginStart{URL: provider.AuthCodeURL(state), Binding: state.Binding, TTL: h.stateTTL}
+	if p, ok := provider.(formPoster); ok {
+		start.FormPost = p.FormPost()
+	}
+	return start, nil
+}
+
+type CompleteOAuthLoginCommand struct {
+	Provider string `json:"provider"`
+	Code     string `json:"code"`
+	State    string `json:"state"`
+	// Binding is what the browser kept when it started the login
+	Binding string `json:"-"`
+}
+
+type OAuthLoginResult struct {
+	User    *user.User `json:"user"`
+	Created bool       `json:"created"`
+	Linked  bool       `json:"linked"`
+}
+
+type CompleteOAuthLoginCommandHandler struct {
+	providers   map[string]oauth.Provider
+	stateStore  oauth.StateStore
+	accountRepo oauth.AccountRepository
+	userRepo    user.Repository
+}
+
+func NewCompleteOAuthLoginCommandHandler(
+	providers map[string]oauth.Provider,
+	stateStore oauth.StateStore,
+	accountRepo oauth.AccountRepository,
+	userRepo user.Repository,
+) *CompleteOAuthLoginCommandHandler {
+	return &CompleteOAuthLoginCommandHandler{
+		providers:   providers,
+		stateStore:  stateStore,
+		accountRepo: accountRepo,
+		userRepo:    userRepo,
+	}
+}
+
+// Handle finishes the provider callback. A known identity signs in its linked
+// user; a new identity is linked to the user with the same email, but only if
+// the provider verified that email, otherwise anyone could take over an
+// account by registering its address with a provider. Unknown emails get a
+// new customer account.
+func (h *CompleteOAuthLoginCommandHandler) Handle(ctx context.Context, cmd CompleteOAuthLoginCommand) (*OAuthLoginResult, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *CompleteOAuthLoginCommandHandler) handle(ctx context.Context, cmd CompleteOAuthLoginCommand) (*OAuthLoginResult, error) {
+	provider, ok := h.providers[cmd.Provider]
+	if !ok {
+		return nil, oauth.ErrUnknownProvider
+	}
+	// A callback from another browser than the one that started the login
+	// would sign that browser in to the starter's account. It is refused
+	// before the state is taken, so it cannot spend the real login's state
+	pending, err := h.stateStore.Get(ctx, cmd.State)
+	if err != nil {
+		return nil, err
+	}
+	if !pending.Bound(cmd.Binding) {
+		return nil, oauth.ErrInvalidState
+	}
+
+	state, err := h.stateStore.Take(ctx, cmd.State)
+	if err != nil {
+		return nil, err
+	}
+	if state.Provider != cmd.Provider {
+		return nil, oauth.ErrInvalidState
+	}
+
+	identity, err := provider.Exchange(ctx, cmd.Code, state)
+	if err != nil {
+		return nil, err
+	}
+
+	if account, err := h.accountRepo.GetByProviderSubject(ctx, identity.Provider, identity.Subject); err == nil {
+		u, err := h.userRepo.GetByID(ctx, account.UserID)
+		if err != nil {
+			return nil, err
+		}
+		return h.result(u, false, false)
+	}
+
+	if identity.Email == "" {
+		return nil, ErrOAuthEmailMissing
+	}
+	if !identity.EmailVerified {
+		return nil, ErrOAuthEmailUnverified
+	}
+
+	created := false
+	u, err := h.userRepo.GetByEmail(ctx, identity.Email)
+	if err != nil {
+		if u, err = user.NewExternalUser(identity.Email, identity.FirstName, identity.LastName); err != nil {
+			return nil, err
+		}
+		if err := h.userRepo.Create(ctx, u); err != nil {
+			return nil, err
+		}
+		created = true
+	}
+
+	if err := h.accountRepo.Create(ctx, oauth.NewAccount(u.ID, identity)); err != nil {
+		return nil, err
+	}
+	return h.result(u, created, !created)
+}
+
+func (h *CompleteOAuthLoginCommandHandler) result(u *user.User, created, linked bool) (*OAuthLoginResult, error) {
+	if !u.IsActive() {
+		return nil, ErrUserInactive
+	}
+	return &OAuthLoginResult{User: u, Created: created, Linked: linked}, nil
+}
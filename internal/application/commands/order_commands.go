@@ -1,19 +1,50 @@
 package commands
 
 import (
+	"context"
+	"errors"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/commission"
+	"online-shop/internal/domain/flashsale"
+	"online-shop/internal/domain/fraud"
 	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/payment"
 	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/shipping"
+	"online-shop/internal/domain/warehouse"
 )
 
 type CreateOrderCommand struct {
-	UserID          string                `json:"user_id" validate:"required"`
-	Items           []CreateOrderItemCmd  `json:"items" validate:"required,min=1"`
+	UserID          string                `json:"-" validate:"required"`
+	Items           []CreateOrderItemCmd  `json:"items" validate:"required,min=1,max=100,dive"`
 	ShippingAddress order.Address         `json:"shipping_address" validate:"required"`
+	// BillingAddress is only used to score the order for fraud
+	BillingAddress  *order.Address        `json:"billing_address,omitempty"`
+	// ShippingRateID picks a rate of the address's shipping zone; the
+	// cheapest one is used without it
+	ShippingRateID  string                `json:"shipping_rate_id,omitempty"`
+	// Note is for the shop, and GiftWrap and GiftMessage send the order as
+	// a present; they are printed on the invoice and the pick list
+	Note        string `json:"note,omitempty" validate:"max=500"`
+	GiftWrap    bool   `json:"gift_wrap,omitempty"`
+	GiftMessage string `json:"gift_message,omitempty" validate:"max=300"`
+	// QuoteID is set by accepted quotes, whose items carry their prices
+	QuoteID string `json:"-"`
+	// PlacedBy and Channel are set for orders staff place on the customer's
+	// behalf, whose items can carry prices like a quote's
+	PlacedBy string        `json:"-"`
+	Channel  order.Channel `json:"-"`
 }
 
 type CreateOrderItemCmd struct {
 	ProductID string `json:"product_id" validate:"required"`
-	Quantity  int    `json:"quantity" validate:"required,min=1"`
+	Quantity  int    `json:"quantity" validate:"required,min=1,max=1000"`
+	// Price is the unit price negotiated in a quote or set by staff, which
+	// takes the place of the product's price and its flash sales; customers
+	// cannot set it
+	Price float64 `json:"-"`
 }
 
 type UpdateOrderStatusCommand struct {
@@ -21,68 +52,470 @@ type UpdateOrderStatusCommand struct {
 	Status  order.Status `json:"status" validate:"required"`
 }
 
+// CancelOrderCommand cancels an order, for one of the customer's reasons
+// or one of the shop's. No reason is recorded as other.
 type CancelOrderCommand struct {
-	OrderID string `json:"order_id" validate:"required"`
-	UserID  string `json:"user_id" validate:"required"`
+	OrderID string             `json:"-" validate:"required"`
+	UserID  string             `json:"-" validate:"required"`
+	Reason  order.CancelReason `json:"reason" validate:"omitempty,oneof=changed_mind ordered_by_mistake found_better_price delivery_too_slow wrong_address other fraud_declined payment_failed"`
+	Note    string             `json:"note" validate:"max=500"`
 }
 
+// NonTransactional: refunds go out through the provider, and what was
+// refunded has to be kept when a later step fails.
+func (CancelOrderCommand) NonTransactional() {}
+
 type CreateOrderCommandHandler struct {
-	orderRepo   order.Repository
-	productRepo product.Repository
+	orderRepo      order.Repository
+	productRepo    product.Repository
+	commissionRepo commission.Repository
+	warehouseRepo  warehouse.Repository
+	stockRepo      warehouse.StockRepository
+	flashSaleRepo  flashsale.Repository
+	flashCounter   flashsale.Counter
+	fraudScreener  *FraudScreener
+	numbers        order.NumberGenerator
+	webhooks       *WebhookPublisher
+	shippingQuoter shipping.Quoter
+	bundles        *BundleStocker
+	purchaseRules  *PurchaseRules
+	giftWrapFee    float64
+}
+
+// CreateOrderOptions are the collaborators an order can be placed without;
+// each one left unset skips what it does.
+type CreateOrderOptions struct {
+	// FraudScreener holds risky orders for review
+	FraudScreener *FraudScreener
+	// Numbers gives orders their customer facing numbers
+	Numbers order.NumberGenerator
+	// Webhooks tells subscribers an order was placed
+	Webhooks *WebhookPublisher
+	// ShippingQuoter charges the rate of the address's shipping zone
+	ShippingQuoter shipping.Quoter
+	// Bundles takes the stock of the products in bundles
+	Bundles *BundleStocker
+	// PurchaseRules limits how much of a product can be bought
+	PurchaseRules *PurchaseRules
+	// GiftWrapFee is charged for orders sent as a present
+	GiftWrapFee float64
 }
 
-func NewCreateOrderCommandHandler(orderRepo order.Repository, productRepo product.Repository) *CreateOrderCommandHandler {
+func NewCreateOrderCommandHandler(
+	orderRepo order.Repository,
+	productRepo product.Repository,
+	commissionRepo commission.Repository,
+	warehouseRepo warehouse.Repository,
+	stockRepo warehouse.StockRepository,
+	flashSaleRepo flashsale.Repository,
+	flashCounter flashsale.Counter,
+	opts CreateOrderOptions,
+) *CreateOrderCommandHandler {
 	return &CreateOrderCommandHandler{
-		orderRepo:   orderRepo,
-		productRepo: productRepo,
+		orderRepo:      orderRepo,
+		productRepo:    productRepo,
+		commissionRepo: commissionRepo,
+		warehouseRepo:  warehouseRepo,
+		stockRepo:      stockRepo,
+		flashSaleRepo:  flashSaleRepo,
+		flashCounter:   flashCounter,
+		fraudScreener:  opts.FraudScreener,
+		numbers:        opts.Numbers,
+		webhooks:       opts.Webhooks,
+		shippingQuoter: opts.ShippingQuoter,
+		bundles:        opts.Bundles,
+		purchaseRules:  opts.PurchaseRules,
+		giftWrapFee:    opts.GiftWrapFee,
 	}
 }
 
-func (h *CreateOrderCommandHandler) Handle(cmd CreateOrderCommand) (*order.Order, error) {
+func (h *CreateOrderCommandHandler) Handle(ctx context.Context, cmd CreateOrderCommand) (*order.Order, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *CreateOrderCommandHandler) handle(ctx context.Context, cmd CreateOrderCommand) (*order.Order, error) {
+	priced, err := h.price(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	newOrder, products, sales := priced.order, priced.products, priced.sales
+
+	// Pick fulfillment warehouses
+	if err := h.routeFulfillment(ctx, newOrder); err != nil {
+		return nil, err
+	}
+
+	// Record marketplace commission on each item
+	if err := h.applyCommission(ctx, newOrder, products); err != nil {
+		return nil, err
+	}
+
+	// Score the order for fraud; a risky one is held for review. Staff
+	// placing an order have spoken to the customer themselves
+	var assessment *fraud.Assessment
+	if h.fraudScreener != nil && !newOrder.Assisted() {
+		if assessment, err = h.fraudScreener.Screen(ctx, newOrder, cmd.BillingAddress); err != nil {
+			return nil, err
+		}
+	}
+
+	// Number the order
+	if h.numbers != nil {
+		if newOrder.Number, err = h.numbers.Next(ctx, newOrder.CreatedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	// Count flash sale units once the order is priced. Whatever fails after
+	// this rolls the order back, so the units are given back with it
+	reserved, err := h.reserveFlashSales(ctx, newOrder, sales)
+	if err != nil {
+		return nil, err
+	}
+	placed := false
+	defer func() {
+		if !placed {
+			releaseFlashSales(h.flashCounter, newOrder.UserID, reserved)
+		}
+	}()
+
+	// Save order
+	if err := h.orderRepo.Create(ctx, newOrder); err != nil {
+		return nil, err
+	}
+	if assessment != nil {
+		if err := h.fraudScreener.Record(ctx, assessment); err != nil {
+			return nil, err
+		}
+	}
+
+	// Update product stock
+	for _, item := range newOrder.Items {
+		if err := h.productRepo.UpdateStock(ctx, item.ProductID, -item.Quantity); err != nil {
+			// TODO: Implement compensation logic or use saga pattern
+			return nil, err
+		}
+	}
+	h.bundles.refresh(ctx, newOrder.Items)
+
+	// Update warehouse stock
+	for _, item := range newOrder.Items {
+		if item.WarehouseID == "" {
+			continue
+		}
+		// The stock was routed on a read; another order may have taken it
+		// since, which fails this one rather than leaving it unfulfillable
+		err := h.stockRepo.AdjustQuantity(ctx, item.WarehouseID, item.ProductID, -item.Quantity)
+		if errors.Is(err, warehouse.ErrOutOfStock) {
+			return nil, ErrInsufficientStock
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Tell the third parties subscribed to new orders
+	if h.webhooks != nil {
+		if err := h.webhooks.OrderCreated(ctx, newOrder); err != nil {
+			return nil, err
+		}
+	}
+
+	placed = true
+	return newOrder, nil
+}
+
+// pricedOrder is an order priced and charged for shipping, before anything
+// is reserved or saved.
+type pricedOrder struct {
+	order    *order.Order
+	products map[string]*product.Product
+	sales    []*flashsale.Sale
+	// listTotal is what the items come to at their products' prices,
+	// before flash sales and quotes
+	listTotal float64
+	// shipping is the address's quote, nil without a shipping quoter
+	shipping *shipping.Quote
+}
+
+// price prices the items of the order and charges its shipping. Placing an
+// order and previewing the checkout both price through it, so a preview
+// always shows what the order is placed for.
+func (h *CreateOrderCommandHandler) price(ctx context.Context, cmd CreateOrderCommand) (*pricedOrder, error) {
 	var orderItems []order.CreateOrderItem
+	var listTotal float64
+	var bundles []*product.Product
+	products := make(map[string]*product.Product)
+
+	now := time.Now()
+	productIDs := make([]string, 0, len(cmd.Items))
+	for _, item := range cmd.Items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	sales, err := h.flashSaleRepo.ListLive(ctx, productIDs, now)
+	if err != nil {
+		return nil, err
+	}
 
 	// Validate products and calculate prices
 	for _, item := range cmd.Items {
-		prod, err := h.productRepo.GetByID(item.ProductID)
+		prod, err := h.productRepo.GetByID(ctx, item.ProductID)
 		if err != nil {
 			return nil, ErrProductNotFound
 		}
 
+		// Bundles are ordered as their components
+		if prod.IsBundle() {
+			if prod.Status != product.StatusActive {
+				return nil, ErrProductNotFound
+			}
+			price := prod.Price
+			if item.Price > 0 {
+				price = item.Price
+			}
+			components, err := h.explodeBundle(ctx, prod, item.Quantity, price, products)
+			if err != nil {
+				return nil, err
+			}
+			orderItems = append(orderItems, components...)
+			listTotal += prod.Price * float64(item.Quantity)
+			bundles = append(bundles, prod)
+			continue
+		}
+
 		if !prod.IsAvailable() {
 			return nil, ErrProductNotFound
 		}
 
-		if prod.Stock < item.Quantity {
-			return nil, ErrInsufficientStock
+		orderItem := order.CreateOrderItem{
+			ProductID:  item.ProductID,
+			MerchantID: prod.MerchantID,
+			Quantity:   item.Quantity,
+			Price:      prod.Price,
+			Product:    order.NewProductSnapshot(prod),
+		}
+		if item.Price > 0 {
+			orderItem.Price = item.Price
+		} else if sale, saleItem := flashsale.Best(sales, prod.ID, now); saleItem != nil {
+			orderItem.Price = saleItem.SalePrice
+			orderItem.FlashSaleID = sale.ID
 		}
+		orderItems = append(orderItems, orderItem)
+		listTotal += prod.Price * float64(item.Quantity)
+		products[prod.ID] = prod
+	}
 
-		orderItems = append(orderItems, order.CreateOrderItem{
-			ProductID: item.ProductID,
-			Quantity:  item.Quantity,
-			Price:     prod.Price,
-		})
+	// A product can be ordered on its own and in bundles; its stock has to
+	// cover them all
+	needed := make(map[string]int, len(orderItems))
+	for _, item := range orderItems {
+		needed[item.ProductID] += item.Quantity
+	}
+	for productID, quantity := range needed {
+		if products[productID].Stock < quantity {
+			return nil, ErrInsufficientStock
+		}
 	}
 
 	// Create order
 	newOrder, err := order.NewOrder(cmd.UserID, orderItems, cmd.ShippingAddress)
 	if err != nil {
+		return nil, ErrInvalidOrderData.Wrap(err)
+	}
+	newOrder.QuoteID = cmd.QuoteID
+	if cmd.PlacedBy != "" {
+		newOrder.PlaceOnBehalf(cmd.PlacedBy, cmd.Channel)
+	}
+
+	// Purchase limits, age restrictions and the minimum order value
+	if err := h.purchaseRules.checkOrder(ctx, newOrder, products, bundles); err != nil {
 		return nil, err
 	}
 
-	// Save order
-	if err := h.orderRepo.Create(newOrder); err != nil {
+	// The note and gift options; wrapping is charged on top of the items
+	if err := newOrder.SetNote(cmd.Note); err != nil {
+		return nil, err
+	}
+	if err := newOrder.SetGift(cmd.GiftWrap, cmd.GiftMessage, h.giftWrapFee); err != nil {
 		return nil, err
 	}
 
-	// Update product stock
-	for _, item := range cmd.Items {
-		if err := h.productRepo.UpdateStock(item.ProductID, -item.Quantity); err != nil {
-			// TODO: Implement compensation logic or use saga pattern
+	// Charge delivery to the address; an address outside the shipping
+	// zones is turned away
+	var quote *shipping.Quote
+	if h.shippingQuoter != nil {
+		if quote, err = h.chargeShipping(ctx, newOrder, cmd.ShippingRateID); err != nil {
 			return nil, err
 		}
 	}
 
-	return newOrder, nil
+	return &pricedOrder{order: newOrder, products: products, sales: sales, listTotal: listTotal, shipping: quote}, nil
+}
+
+// explodeBundle turns quantity of a bundle into items of its components,
+// each priced at its share of price, what one bundle sells for, so they are stocked, routed
+// and shipped like the products they are. Bundles are not priced by flash
+// sales. The components are added to products.
+func (h *CreateOrderCommandHandler) explodeBundle(ctx context.Context, bundle *product.Product, quantity int, price float64, products map[string]*product.Product) ([]order.CreateOrderItem, error) {
+	parts, err := h.productRepo.GetByIDs(ctx, bundle.StockedIDs())
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*product.Product, len(parts))
+	for _, p := range parts {
+		byID[p.ID] = p
+	}
+
+	// Components may be inactive, to be sold only in bundles
+	items := make([]order.CreateOrderItem, 0, len(bundle.Components))
+	prices := bundle.SplitPrice(price, byID)
+	for i, c := range bundle.Components {
+		part, ok := byID[c.ProductID]
+		if !ok || part.Status == product.StatusDeleted {
+			return nil, ErrProductNotFound
+		}
+		items = append(items, order.CreateOrderItem{
+			ProductID:      part.ID,
+			MerchantID:     part.MerchantID,
+			Quantity:       c.Quantity * quantity,
+			Price:          prices[i],
+			BundleID:       bundle.ID,
+			BundleQuantity: quantity,
+			Product:        order.NewProductSnapshot(part),
+		})
+		products[part.ID] = part
+	}
+	return items, nil
+}
+
+// reserveFlashSales counts the flash sale items of the order against their
+// allocations and the customer's limits. Either every item is counted or,
+// on the first one that is sold out or over the limit, none are.
+func (h *CreateOrderCommandHandler) reserveFlashSales(ctx context.Context, o *order.Order, sales []*flashsale.Sale) ([]order.OrderItem, error) {
+	var reserved []order.OrderItem
+	for _, item := range o.Items {
+		if item.FlashSaleID == "" {
+			continue
+		}
+		var sale *flashsale.Sale
+		for _, s := range sales {
+			if s.ID == item.FlashSaleID {
+				sale = s
+			}
+		}
+
+		if err := h.flashCounter.Reserve(ctx, sale.Item(item.ProductID), o.UserID, item.Quantity, sale.EndsAt); err != nil {
+			releaseFlashSales(h.flashCounter, o.UserID, reserved)
+			return nil, err
+		}
+		reserved = append(reserved, item)
+	}
+	return reserved, nil
+}
+
+// releaseFlashSales gives the flash sale units of items back. A failure
+// only leaves the units unsold, never oversold, so it does not fail the
+// caller. The units are given back even when the request that took them
+// was cancelled, so it does not run on the request's context.
+func releaseFlashSales(counter flashsale.Counter, userID string, items []order.OrderItem) {
+	ctx := context.Background()
+	for _, item := range items {
+		if item.FlashSaleID == "" {
+			continue
+		}
+		_ = counter.Release(ctx, &flashsale.Item{SaleID: item.FlashSaleID, ProductID: item.ProductID}, userID, item.Quantity)
+	}
+}
+
+func (h *CreateOrderCommandHandler) chargeShipping(ctx context.Context, o *order.Order, rateID string) (*shipping.Quote, error) {
+	productIDs := make([]string, 0, len(o.Items))
+	for _, item := range o.Items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	quote, err := h.shippingQuoter.Quote(ctx, o.ShippingAddress, o.TotalAmount, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	// Shops without zones ship everywhere at no charge
+	if quote.ZoneID == "" {
+		return quote, nil
+	}
+
+	option, err := quote.Option(rateID)
+	if err != nil {
+		return nil, err
+	}
+	o.ChargeShipping(order.ShippingCharge{
+		ZoneID:  quote.ZoneID,
+		RateID:  option.RateID,
+		Rate:    option.Name,
+		Fee:     option.Fee,
+		MinDays: option.MinDays,
+		MaxDays: option.MaxDays,
+	})
+	if option.Estimate != nil {
+		o.Delivery = *option.Estimate
+	}
+	return quote, nil
+}
+
+func (h *CreateOrderCommandHandler) routeFulfillment(ctx context.Context, o *order.Order) error {
+	warehouses, err := h.warehouseRepo.ListActive(ctx)
+	if err != nil {
+		return err
+	}
+	// Stores without warehouses keep fulfilling from the product stock only
+	if len(warehouses) == 0 {
+		return nil
+	}
+
+	var productIDs []string
+	var lines []warehouse.Line
+	for _, item := range o.Items {
+		productIDs = append(productIDs, item.ProductID)
+		lines = append(lines, warehouse.Line{ProductID: item.ProductID, Quantity: item.Quantity})
+	}
+
+	stock, err := h.stockRepo.GetByProducts(ctx, productIDs)
+	if err != nil {
+		return err
+	}
+
+	allocations, err := warehouse.Route(lines, warehouses, stock, o.ShippingAddress)
+	if err != nil {
+		return ErrInsufficientStock
+	}
+
+	assigned := make([]order.WarehouseAllocation, 0, len(allocations))
+	for _, a := range allocations {
+		assigned = append(assigned, order.WarehouseAllocation{
+			ProductID:   a.ProductID,
+			WarehouseID: a.WarehouseID,
+			Quantity:    a.Quantity,
+		})
+	}
+	o.AssignWarehouses(assigned)
+
+	return nil
+}
+
+func (h *CreateOrderCommandHandler) applyCommission(ctx context.Context, o *order.Order, products map[string]*product.Product) error {
+	rules, err := h.commissionRepo.ListActive(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range o.Items {
+		prod := products[o.Items[i].ProductID]
+		rule := commission.Resolve(rules, prod.CategoryID, prod.MerchantID)
+		if rule == nil {
+			continue
+		}
+		o.Items[i].ApplyCommission(rule.ID, rule.Calculate(o.Items[i].Subtotal))
+	}
+	o.TallyCommission()
+
+	return nil
 }
 
 type UpdateOrderStatusCommandHandler struct {
@@ -93,56 +526,179 @@ func NewUpdateOrderStatusCommandHandler(orderRepo order.Repository) *UpdateOrder
 	return &UpdateOrderStatusCommandHandler{orderRepo: orderRepo}
 }
 
-func (h *UpdateOrderStatusCommandHandler) Handle(cmd UpdateOrderStatusCommand) error {
-	existingOrder, err := h.orderRepo.GetByID(cmd.OrderID)
+func (h *UpdateOrderStatusCommandHandler) Handle(ctx context.Context, cmd UpdateOrderStatusCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *UpdateOrderStatusCommandHandler) handle(ctx context.Context, cmd UpdateOrderStatusCommand) error {
+	existingOrder, err := h.orderRepo.GetByID(ctx, cmd.OrderID)
 	if err != nil {
 		return ErrOrderNotFound
 	}
 
 	existingOrder.UpdateStatus(cmd.Status)
-	return h.orderRepo.Update(existingOrder)
+	return h.orderRepo.Update(ctx, existingOrder)
 }
 
 type CancelOrderCommandHandler struct {
-	orderRepo   order.Repository
-	productRepo product.Repository
+	orderRepo    order.Repository
+	productRepo  product.Repository
+	stockRepo    warehouse.StockRepository
+	flashCounter flashsale.Counter
+	paymentRepo  payment.Repository
+	provider     payment.PaymentProvider
+	policy       order.CancellationPolicy
+	bundles      *BundleStocker
 }
 
-func NewCancelOrderCommandHandler(orderRepo order.Repository, productRepo product.Repository) *CancelOrderCommandHandler {
+func NewCancelOrderCommandHandler(
+	orderRepo order.Repository,
+	productRepo product.Repository,
+	stockRepo warehouse.StockRepository,
+	flashCounter flashsale.Counter,
+	paymentRepo payment.Repository,
+	provider payment.PaymentProvider,
+	policy order.CancellationPolicy,
+	bundles *BundleStocker,
+) *CancelOrderCommandHandler {
 	return &CancelOrderCommandHandler{
-		orderRepo:   orderRepo,
-		productRepo: productRepo,
+		orderRepo:    orderRepo,
+		productRepo:  productRepo,
+		stockRepo:    stockRepo,
+		flashCounter: flashCounter,
+		paymentRepo:  paymentRepo,
+		provider:     provider,
+		policy:       policy,
+		bundles:      bundles,
 	}
 }
 
-func (h *CancelOrderCommandHandler) Handle(cmd CancelOrderCommand) error {
-	existingOrder, err := h.orderRepo.GetByID(cmd.OrderID)
+// Handle cancels the order under the cancellation policy and pays back what
+// the customer paid, less the policy's fee. The order is claimed first,
+// moving it to cancelling and putting its stock back in one transaction, so
+// only one request cancels it and its stock is put back once. Refunds are
+// issued after, keyed by order and payment: a cancellation that fails on one
+// is retried from where it stopped without paying anything back twice.
+func (h *CancelOrderCommandHandler) Handle(ctx context.Context, cmd CancelOrderCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *CancelOrderCommandHandler) handle(ctx context.Context, cmd CancelOrderCommand) error {
+	existingOrder, err := h.orderRepo.GetByID(ctx, cmd.OrderID)
 	if err != nil {
 		return ErrOrderNotFound
 	}
 
 	// Check if user owns the order
 	if existingOrder.UserID != cmd.UserID {
-		return ErrUnauthorized
+		return ErrForbidden
+	}
+
+	// An order left cancelling by an earlier attempt has been claimed and
+	// had its stock put back already; only its refunds are left
+	if existingOrder.Status != order.StatusCancelling {
+		if err := h.claim(ctx, existingOrder, cmd); err != nil {
+			return err
+		}
 	}
 
-	// Check if order can be cancelled
-	if !existingOrder.CanBeCancelled() {
-		return ErrOrderCannotBeCancelled
+	kept, refunded, err := h.settlePayments(ctx, existingOrder, existingOrder.Cancellation.Fee)
+	if err != nil {
+		return err
 	}
 
-	// Cancel order
-	if err := existingOrder.Cancel(); err != nil {
+	existingOrder.Cancel(kept, refunded, time.Now())
+	_, err = h.orderRepo.UpdateFrom(ctx, existingOrder, order.StatusCancelling)
+	return err
+}
+
+// claim moves the order to cancelling, provided it is still in the status
+// the policy's fee was worked out for, and puts its stock back in the same
+// transaction. Bundles were ordered as their components, which are put
+// back, and count their stock from them again.
+func (h *CancelOrderCommandHandler) claim(ctx context.Context, o *order.Order, cmd CancelOrderCommand) error {
+	fee, err := h.policy.Fee(o)
+	if err != nil {
 		return err
 	}
 
-	// Restore product stock
-	for _, item := range existingOrder.Items {
-		if err := h.productRepo.UpdateStock(item.ProductID, item.Quantity); err != nil {
-			// TODO: Implement compensation logic
+	reason := cmd.Reason
+	if reason == "" {
+		reason = order.CancelReasonOther
+	}
+	from := o.Status
+	o.BeginCancel(reason, cmd.Note, fee, time.Now())
+
+	err = pipeline.Atomic(ctx, func(ctx context.Context) error {
+		claimed, err := h.orderRepo.UpdateFrom(ctx, o, from)
+		if err != nil {
 			return err
 		}
+		if !claimed {
+			return ErrOrderCancelConflict
+		}
+		for _, item := range o.Items {
+			if err := h.productRepo.UpdateStock(ctx, item.ProductID, item.Quantity); err != nil {
+				return err
+			}
+			if item.WarehouseID != "" {
+				if err := h.stockRepo.AdjustQuantity(ctx, item.WarehouseID, item.ProductID, item.Quantity); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	h.bundles.refresh(ctx, o.Items)
+
+	// Cancelled flash sale units go back on sale
+	releaseFlashSales(h.flashCounter, o.UserID, o.Items)
+	return nil
+}
+
+// settlePayments refunds what was paid on the order beyond fee, oldest
+// payment first, and cancels its pending payments. It returns what is kept
+// and what has been refunded in all, counting refunds issued by an earlier
+// attempt. Cash on delivery is only paid once the order is delivered, so it
+// never has anything to refund.
+func (h *CancelOrderCommandHandler) settlePayments(ctx context.Context, o *order.Order, fee float64) (kept, refunded float64, err error) {
+	payments, err := h.paymentRepo.ListByOrderID(ctx, o.ID)
+	if err != nil {
+		return 0, 0, err
 	}
+	summary := payment.Summarize(o.TotalAmount, payments)
 
-	return h.orderRepo.Update(existingOrder)
-}
\ No newline at end of file
+	kept = fee
+	if kept > summary.Paid {
+		kept = summary.Paid
+	}
+	owed := summary.Paid - kept
+	for _, pay := range payments {
+		switch {
+		case pay.Status == payment.StatusPending:
+			pay.MarkAsCancelled()
+		case owed >= payment.Tolerance && pay.Refundable() > 0:
+			amount := pay.Refundable()
+			if amount > owed {
+				amount = owed
+			}
+			key := o.ID + "-" + pay.ID
+			if err := h.provider.RefundPayment(ctx, pay.ExternalID, amount, key); err != nil {
+				return 0, 0, err
+			}
+			pay.MarkAsRefunded(amount)
+			owed -= amount
+		default:
+			refunded += pay.RefundedAmount
+			continue
+		}
+		if err := h.paymentRepo.Update(ctx, pay); err != nil {
+			return 0, 0, err
+		}
+		refunded += pay.RefundedAmount
+	}
+	return kept, refunded, nil
+}
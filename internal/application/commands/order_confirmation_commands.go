@@ -0,0 +1,131 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/shipping"
+	"online-shop/internal/domain/user"
+	"online-shop/internal/domain/warehouse"
+)
+
+// OrderConfirmer estimates delivery again once an order is confirmed, now
+// that its warehouses can start on it, and emails the customer the
+// confirmation. It is given to the handlers that confirm orders.
+type OrderConfirmer struct {
+	warehouseRepo warehouse.Repository
+	userRepo      user.Repository
+	productRepo   product.Repository
+	notifier      order.ConfirmationNotifier
+	schedule      *shipping.Schedule
+	processing    shipping.Processing
+}
+
+// NewOrderConfirmer takes a nil notifier when confirmations cannot be sent;
+// orders are still estimated.
+func NewOrderConfirmer(
+	warehouseRepo warehouse.Repository,
+	userRepo user.Repository,
+	productRepo product.Repository,
+	notifier order.ConfirmationNotifier,
+	schedule *shipping.Schedule,
+	processing shipping.Processing,
+) *OrderConfirmer {
+	return &OrderConfirmer{
+		warehouseRepo: warehouseRepo,
+		userRepo:      userRepo,
+		productRepo:   productRepo,
+		notifier:      notifier,
+		schedule:      schedule,
+		processing:    processing,
+	}
+}
+
+// Estimate sets when the order arrives if it is confirmed now: once the
+// slowest of the warehouses it leaves from dispatches it, at the transit
+// days of its shipping rate. Orders charged no rate are left unestimated.
+func (c *OrderConfirmer) Estimate(ctx context.Context, o *order.Order, at time.Time) error {
+	if o.Shipping.ZoneID == "" {
+		return nil
+	}
+
+	var processing []shipping.Processing
+	seen := make(map[string]bool)
+	for _, item := range o.Items {
+		if item.WarehouseID == "" || seen[item.WarehouseID] {
+			continue
+		}
+		seen[item.WarehouseID] = true
+		w, err := c.warehouseRepo.GetByID(ctx, item.WarehouseID)
+		if err != nil {
+			return err
+		}
+		processing = append(processing, w.Processing(c.processing))
+	}
+	if len(processing) == 0 {
+		processing = append(processing, c.processing)
+	}
+
+	o.Delivery = c.schedule.Estimate(at, c.schedule.Slowest(at, processing), o.Shipping.MinDays, o.Shipping.MaxDays)
+	return nil
+}
+
+// Notify emails the customer their confirmed order.
+func (c *OrderConfirmer) Notify(ctx context.Context, o *order.Order) error {
+	if c.notifier == nil {
+		return nil
+	}
+	customer, err := c.userRepo.GetByID(ctx, o.UserID)
+	if err != nil {
+		return err
+	}
+
+	// Items are named as they were ordered; those ordered before products
+	// were snapshotted take the product's current name
+	names := make(map[string]string, len(o.Items))
+	for _, item := range o.Items {
+		if _, ok := names[item.ProductID]; ok {
+			continue
+		}
+		if item.Product.Name != "" {
+			names[item.ProductID] = item.Product.Name
+			continue
+		}
+		names[item.ProductID] = item.ProductID
+		if prod, err := c.productRepo.GetByID(ctx, item.ProductID); err == nil {
+			names[item.ProductID] = prod.Name
+		}
+	}
+
+	return c.notifier.OrderConfirmed(ctx, order.Confirmation{
+		Order:        o,
+		Email:        customer.Email,
+		CustomerName: strings.TrimSpace(customer.FirstName + " " + customer.LastName),
+		Locale:       customer.Locale,
+		ProductNames: names,
+	})
+}
+
+// confirm runs record, which may confirm the order, and estimates delivery
+// if it did. It reports whether it did, so the caller can notify once the
+// order is saved.
+func (c *OrderConfirmer) confirm(ctx context.Context, o *order.Order, record func()) (bool, error) {
+	wasConfirmed := o.Status == order.StatusConfirmed
+	record()
+	if c == nil || wasConfirmed || o.Status != order.StatusConfirmed {
+		return false, nil
+	}
+	return true, c.Estimate(ctx, o, time.Now())
+}
+
+// notify sends the confirmation of a newly confirmed order. A confirmation
+// that cannot be sent does not undo the payment that confirmed it.
+func (c *OrderConfirmer) notify(ctx context.Context, o *order.Order, confirmed bool) {
+	if c == nil || !confirmed {
+		return
+	}
+	_ = c.Notify(ctx, o)
+}
@@ -0,0 +1,355 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/payment"
+)
+
+// PaymentAllocationCmd is one instrument's share of an order. With
+// PaymentMethodID the share is charged to a saved card straight away.
+type PaymentAllocationCmd struct {
+	Method          payment.Method `json:"method" validate:"required,oneof=credit_card bank_transfer e_wallet virtual_account"`
+	Amount          float64        `json:"amount" validate:"required,gt=0"`
+	PaymentMethodID string         `json:"payment_method_id"`
+}
+
+type InstallmentPlanCmd struct {
+	Count  int            `json:"count" validate:"required,min=2,max=12"`
+	Method payment.Method `json:"method" validate:"required,oneof=credit_card bank_transfer e_wallet virtual_account"`
+}
+
+// CreateOrderPaymentsCommand pays what is outstanding on an order, either
+// split across several instruments or in monthly installments.
+type CreateOrderPaymentsCommand struct {
+	OrderID      string                 `json:"-" validate:"required"`
+	UserID       string                 `json:"-" validate:"required"`
+	Allocations  []PaymentAllocationCmd `json:"allocations" validate:"omitempty,max=5,dive"`
+	Installments *InstallmentPlanCmd    `json:"installments"`
+}
+
+// NonTransactional: the payments are recorded before their links are
+// issued, and a rollback would lose links the provider already holds.
+func (CreateOrderPaymentsCommand) NonTransactional() {}
+
+// PayOrderPaymentCommand issues a payment link for one of an order's
+// pending payments, such as an installment that has come due.
+type PayOrderPaymentCommand struct {
+	OrderID   string `json:"-" validate:"required"`
+	UserID    string `json:"-" validate:"required"`
+	PaymentID string `json:"-" validate:"required"`
+}
+
+// NonTransactional, as the link is issued by the provider and a rollback
+// would leave it with no record.
+func (PayOrderPaymentCommand) NonTransactional() {}
+
+type ReconcileOrderPaymentsCommand struct {
+	OrderID string `json:"order_id" validate:"required"`
+}
+
+type CreateOrderPaymentsCommandHandler struct {
+	orderRepo     order.Repository
+	paymentRepo   payment.Repository
+	methodRepo    payment.MethodRepository
+	provider      payment.PaymentProvider
+	tokenProvider payment.TokenProvider
+	confirmer     *OrderConfirmer
+	receipts      *ReceiptSender
+}
+
+func NewCreateOrderPaymentsCommandHandler(
+	orderRepo order.Repository,
+	paymentRepo payment.Repository,
+	methodRepo payment.MethodRepository,
+	provider payment.PaymentProvider,
+	tokenProvider payment.TokenProvider,
+	confirmer *OrderConfirmer,
+	receipts *ReceiptSender,
+) *CreateOrderPaymentsCommandHandler {
+	return &CreateOrderPaymentsCommandHandler{
+		orderRepo:     orderRepo,
+		paymentRepo:   paymentRepo,
+		methodRepo:    methodRepo,
+		provider:      provider,
+		tokenProvider: tokenProvider,
+		confirmer:     confirmer,
+		receipts:      receipts,
+	}
+}
+
+// Handle creates a payment for each allocation, issues links for those due
+// and charges the saved cards. A declined card fails its own share, and
+// those of the cards after it, while what was already charged stays
+// recorded as paid. Failed shares reopen and can be split again.
+func (h *CreateOrderPaymentsCommandHandler) Handle(ctx context.Context, cmd CreateOrderPaymentsCommand) (*payment.Summary, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *CreateOrderPaymentsCommandHandler) handle(ctx context.Context, cmd CreateOrderPaymentsCommand) (*payment.Summary, error) {
+	o, err := h.orderRepo.GetByID(ctx, cmd.OrderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+	if o.UserID != cmd.UserID {
+		return nil, ErrForbidden
+	}
+	if o.Status == order.StatusCancelled || o.Status == order.StatusRefunded {
+		return nil, ErrOrderNotPayable
+	}
+	if o.Status == order.StatusOnHold {
+		return nil, ErrOrderOnHold
+	}
+
+	existing, err := h.paymentRepo.ListByOrderID(ctx, o.ID)
+	if err != nil {
+		return nil, err
+	}
+	before := payment.Summarize(o.TotalAmount, existing)
+	if before.Settled() {
+		return nil, payment.ErrAlreadyPaid
+	}
+	if before.Pending > 0 {
+		return nil, payment.ErrPaymentsOpen
+	}
+
+	allocations, err := orderAllocations(cmd, before.Outstanding, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := payment.CheckSplit(before.Outstanding, allocations); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	payments := make([]*payment.Payment, 0, len(allocations))
+	charges := make(map[*payment.Payment]*payment.SavedMethod)
+	for _, a := range allocations {
+		pay := payment.NewAllocatedPayment(o.ID, o.UserID, a)
+		pay.ExternalID = pay.ID
+		if a.SavedMethodID != "" {
+			method, err := ownedPaymentMethod(ctx, h.methodRepo, o.UserID, a.SavedMethodID)
+			if err != nil {
+				return nil, err
+			}
+			if method.ExpiredAt(now) {
+				return nil, payment.ErrMethodExpired
+			}
+			charges[pay] = method
+		}
+		payments = append(payments, pay)
+	}
+
+	// Every share is recorded as pending before any provider is called, so
+	// no link is issued and no card charged that the shop has no record of
+	for _, pay := range payments {
+		if err := h.paymentRepo.Create(ctx, pay); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, pay := range payments {
+		if _, ok := charges[pay]; ok || !pay.Due(now) {
+			continue
+		}
+		// A share whose link could not be issued stays pending without one;
+		// paying it issues a new link
+		if err := issuePaymentLink(ctx, h.provider, pay); err != nil {
+			continue
+		}
+		if err := h.paymentRepo.Update(ctx, pay); err != nil {
+			return nil, err
+		}
+	}
+
+	var declined error
+	for _, pay := range payments {
+		method, ok := charges[pay]
+		if !ok {
+			continue
+		}
+		if declined != nil {
+			// Cards after a declined one are not charged, and their shares
+			// reopen along with the declined one
+			pay.MarkAsFailed()
+		} else if err := h.charge(ctx, pay, method); err != nil {
+			declined = err
+		}
+		if err := h.paymentRepo.Update(ctx, pay); err != nil {
+			return nil, err
+		}
+	}
+
+	summary, err := reconcileOrderPayments(ctx, h.orderRepo, h.paymentRepo, h.confirmer, h.receipts, o)
+	if declined != nil {
+		return nil, declined
+	}
+	return summary, err
+}
+
+// charge charges a share to a saved card. Only a decline fails the share:
+// a charge whose outcome is unknown, such as one the provider timed out on,
+// is left pending for the payment webhook to settle.
+func (h *CreateOrderPaymentsCommandHandler) charge(ctx context.Context, pay *payment.Payment, method *payment.SavedMethod) error {
+	result, err := h.tokenProvider.ChargeToken(ctx, pay, method.Token)
+	if err == nil && result.Status != payment.StatusPaid && result.Status != payment.StatusPending {
+		err = fmt.Errorf("%w: the card issuer refused the charge", payment.ErrChargeDeclined)
+	}
+	if errors.Is(err, payment.ErrChargeDeclined) {
+		pay.MarkAsFailed()
+		return err
+	}
+	if err != nil {
+		// Still pending, for the webhook to settle
+		return nil
+	}
+	pay.PaymentURL = result.RedirectURL
+	pay.TransactionID = result.TransactionID
+	if result.Status == payment.StatusPaid {
+		pay.MarkAsPaid(result.TransactionID)
+	}
+	return nil
+}
+
+func orderAllocations(cmd CreateOrderPaymentsCommand, outstanding float64, now time.Time) ([]payment.Allocation, error) {
+	if (len(cmd.Allocations) > 0) == (cmd.Installments != nil) {
+		return nil, fmt.Errorf("%w: give either allocations or installments", payment.ErrInvalidSplit)
+	}
+	if cmd.Installments != nil {
+		return payment.Installments(outstanding, cmd.Installments.Count, cmd.Installments.Method, now)
+	}
+
+	allocations := make([]payment.Allocation, 0, len(cmd.Allocations))
+	for _, a := range cmd.Allocations {
+		allocations = append(allocations, payment.Allocation{
+			Method:        a.Method,
+			Amount:        a.Amount,
+			SavedMethodID: a.PaymentMethodID,
+		})
+	}
+	return allocations, nil
+}
+
+type PayOrderPaymentCommandHandler struct {
+	orderRepo   order.Repository
+	paymentRepo payment.Repository
+	provider    payment.PaymentProvider
+}
+
+func NewPayOrderPaymentCommandHandler(orderRepo order.Repository, paymentRepo payment.Repository, provider payment.PaymentProvider) *PayOrderPaymentCommandHandler {
+	return &PayOrderPaymentCommandHandler{orderRepo: orderRepo, paymentRepo: paymentRepo, provider: provider}
+}
+
+// Handle returns the payment with a live link, issuing a new one when it has
+// none or its link has lapsed. Installments can be paid ahead of their due
+// date.
+func (h *PayOrderPaymentCommandHandler) Handle(ctx context.Context, cmd PayOrderPaymentCommand) (*payment.Payment, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *PayOrderPaymentCommandHandler) handle(ctx context.Context, cmd PayOrderPaymentCommand) (*payment.Payment, error) {
+	o, err := h.orderRepo.GetByID(ctx, cmd.OrderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+	if o.UserID != cmd.UserID {
+		return nil, ErrForbidden
+	}
+	if o.Status == order.StatusOnHold {
+		return nil, ErrOrderOnHold
+	}
+
+	pay, err := h.paymentRepo.GetByID(ctx, cmd.PaymentID)
+	if err != nil || pay.OrderID != o.ID {
+		return nil, ErrPaymentNotFound
+	}
+	if pay.Method == payment.MethodCashOnDelivery {
+		return nil, ErrPaymentNotPending.WithDetail("the payment is made in cash on delivery")
+	}
+	if pay.Method == payment.MethodInvoice {
+		return nil, ErrPaymentNotPending.WithDetail("the payment is made by bank transfer against the invoice")
+	}
+	switch pay.Status {
+	case payment.StatusPending:
+	case payment.StatusExpired:
+		return nil, ErrPaymentExpired
+	default:
+		return nil, ErrPaymentNotPending
+	}
+	if pay.PaymentURL != "" && !pay.IsExpired() {
+		return pay, nil
+	}
+
+	if err := issuePaymentLink(ctx, h.provider, pay); err != nil {
+		return nil, err
+	}
+	if err := h.paymentRepo.Update(ctx, pay); err != nil {
+		return nil, err
+	}
+	return pay, nil
+}
+
+type ReconcileOrderPaymentsCommandHandler struct {
+	orderRepo   order.Repository
+	paymentRepo payment.Repository
+	confirmer   *OrderConfirmer
+	receipts    *ReceiptSender
+}
+
+func NewReconcileOrderPaymentsCommandHandler(orderRepo order.Repository, paymentRepo payment.Repository, confirmer *OrderConfirmer, receipts *ReceiptSender) *ReconcileOrderPaymentsCommandHandler {
+	return &ReconcileOrderPaymentsCommandHandler{orderRepo: orderRepo, paymentRepo: paymentRepo, confirmer: confirmer, receipts: receipts}
+}
+
+// Handle brings the order's paid amount and payment status in line with its
+// payments. It runs whenever a payment's status changes.
+func (h *ReconcileOrderPaymentsCommandHandler) Handle(ctx context.Context, cmd ReconcileOrderPaymentsCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *ReconcileOrderPaymentsCommandHandler) handle(ctx context.Context, cmd ReconcileOrderPaymentsCommand) error {
+	o, err := h.orderRepo.GetByID(ctx, cmd.OrderID)
+	if err != nil {
+		return ErrOrderNotFound
+	}
+	_, err = reconcileOrderPayments(ctx, h.orderRepo, h.paymentRepo, h.confirmer, h.receipts, o)
+	return err
+}
+
+// reconcileOrderPayments takes a nil confirmer where payments cannot
+// confirm the order, and nil receipts where they are not emailed one.
+func reconcileOrderPayments(ctx context.Context, orderRepo order.Repository, paymentRepo payment.Repository, confirmer *OrderConfirmer, receipts *ReceiptSender, o *order.Order) (*payment.Summary, error) {
+	payments, err := paymentRepo.ListByOrderID(ctx, o.ID)
+	if err != nil {
+		return nil, err
+	}
+	summary := payment.Summarize(o.TotalAmount, payments)
+
+	confirmed, err := confirmer.confirm(ctx, o, func() { o.RecordPayment(summary.Paid, summary.Settled()) })
+	if err != nil {
+		return nil, err
+	}
+	if err := orderRepo.Update(ctx, o); err != nil {
+		return nil, err
+	}
+	confirmer.notify(ctx, o, confirmed)
+	receipts.send(ctx, o, summary)
+	return summary, nil
+}
+
+func issuePaymentLink(ctx context.Context, provider payment.PaymentProvider, pay *payment.Payment) error {
+	response, err := provider.CreatePayment(ctx, pay)
+	if err != nil {
+		return err
+	}
+	pay.PaymentURL = response.PaymentURL
+	pay.ExternalID = response.ExternalID
+	pay.TransactionID = response.TransactionID
+	pay.ExpiresAt = response.ExpiresAt
+	pay.UpdatedAt = time.Now()
+	return nil
+}
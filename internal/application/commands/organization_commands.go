@@ -0,0 +1,642 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/organization"
+	"online-shop/internal/domain/payment"
+	"online-shop/internal/domain/user"
+)
+
+// CreateOrganizationCommand opens a business account, with the user as its
+// first admin.
+type CreateOrganizationCommand struct {
+	UserID string `json:"-" validate:"required"`
+	Name   string `json:"name" validate:"required,notblank,max=200"`
+	TaxID  string `json:"tax_id" validate:"max=50"`
+}
+
+type CreateOrganizationCommandHandler struct {
+	orgRepo          organization.Repository
+	paymentTermsDays int
+}
+
+// NewCreateOrganizationCommandHandler opens organizations on payment terms
+// of paymentTermsDays; they buy on invoice once an admin grants them credit.
+func NewCreateOrganizationCommandHandler(orgRepo organization.Repository, paymentTermsDays int) *CreateOrganizationCommandHandler {
+	return &CreateOrganizationCommandHandler{orgRepo: orgRepo, paymentTermsDays: paymentTermsDays}
+}
+
+func (h *CreateOrganizationCommandHandler) Handle(ctx context.Context, cmd CreateOrganizationCommand) (*organization.Organization, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *CreateOrganizationCommandHandler) handle(ctx context.Context, cmd CreateOrganizationCommand) (*organization.Organization, error) {
+	if err := h.notMember(ctx, cmd.UserID); err != nil {
+		return nil, err
+	}
+
+	org, admin := organization.New(cmd.Name, cmd.TaxID, cmd.UserID, h.paymentTermsDays)
+	if err := h.orgRepo.Create(ctx, org); err != nil {
+		return nil, err
+	}
+	if err := h.orgRepo.SaveMember(ctx, admin); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+func (h *CreateOrganizationCommandHandler) notMember(ctx context.Context, userID string) error {
+	return notOrganizationMember(ctx, h.orgRepo, userID)
+}
+
+// notOrganizationMember fails with ErrAlreadyMember for a user who buys for
+// an organization already.
+func notOrganizationMember(ctx context.Context, orgRepo organization.Repository, userID string) error {
+	_, err := orgRepo.GetMember(ctx, userID)
+	if err == nil {
+		return organization.ErrAlreadyMember
+	}
+	if !errors.Is(err, organization.ErrMemberNotFound) {
+		return err
+	}
+	return nil
+}
+
+// organizationMember returns the user's membership; a user who buys for no
+// organization has none to manage.
+func organizationMember(ctx context.Context, orgRepo organization.Repository, userID string) (*organization.Member, error) {
+	m, err := orgRepo.GetMember(ctx, userID)
+	if errors.Is(err, organization.ErrMemberNotFound) {
+		return nil, organization.ErrNotFound
+	}
+	return m, err
+}
+
+// organizationAdmin returns the membership of a user who manages their
+// organization.
+func organizationAdmin(ctx context.Context, orgRepo organization.Repository, userID string) (*organization.Member, error) {
+	m, err := organizationMember(ctx, orgRepo, userID)
+	if err != nil {
+		return nil, err
+	}
+	if m.Role != organization.RoleAdmin {
+		return nil, fmt.Errorf("%w: only admins manage the organization", organization.ErrNotPermitted)
+	}
+	return m, nil
+}
+
+// AddOrganizationMemberCommand adds the user with the email to the admin's
+// organization.
+type AddOrganizationMemberCommand struct {
+	ActorID string            `json:"-" validate:"required"`
+	Email   string            `json:"email" validate:"required,email"`
+	Role    organization.Role `json:"role" validate:"required,oneof=admin approver buyer"`
+}
+
+type AddOrganizationMemberCommandHandler struct {
+	orgRepo    organization.Repository
+	userRepo   user.Repository
+	maxMembers int
+}
+
+func NewAddOrganizationMemberCommandHandler(orgRepo organization.Repository, userRepo user.Repository, maxMembers int) *AddOrganizationMemberCommandHandler {
+	return &AddOrganizationMemberCommandHandler{orgRepo: orgRepo, userRepo: userRepo, maxMembers: maxMembers}
+}
+
+func (h *AddOrganizationMemberCommandHandler) Handle(ctx context.Context, cmd AddOrganizationMemberCommand) (*organization.Member, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *AddOrganizationMemberCommandHandler) handle(ctx context.Context, cmd AddOrganizationMemberCommand) (*organization.Member, error) {
+	admin, err := organizationAdmin(ctx, h.orgRepo, cmd.ActorID)
+	if err != nil {
+		return nil, err
+	}
+	u, err := h.userRepo.GetByEmail(ctx, strings.TrimSpace(cmd.Email))
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if err := notOrganizationMember(ctx, h.orgRepo, u.ID); err != nil {
+		return nil, err
+	}
+
+	members, err := h.orgRepo.ListMembers(ctx, admin.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	if len(members) >= h.maxMembers {
+		return nil, organization.ErrFull
+	}
+
+	m := organization.NewMember(admin.OrganizationID, u.ID, cmd.Role, admin.UserID)
+	if err := h.orgRepo.SaveMember(ctx, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// UpdateOrganizationMemberCommand changes the role of a member of the
+// admin's organization.
+type UpdateOrganizationMemberCommand struct {
+	ActorID string            `json:"-" validate:"required"`
+	UserID  string            `json:"-" validate:"required"`
+	Role    organization.Role `json:"role" validate:"required,oneof=admin approver buyer"`
+}
+
+type UpdateOrganizationMemberCommandHandler struct {
+	orgRepo organization.Repository
+}
+
+func NewUpdateOrganizationMemberCommandHandler(orgRepo organization.Repository) *UpdateOrganizationMemberCommandHandler {
+	return &UpdateOrganizationMemberCommandHandler{orgRepo: orgRepo}
+}
+
+func (h *UpdateOrganizationMemberCommandHandler) Handle(ctx context.Context, cmd UpdateOrganizationMemberCommand) (*organization.Member, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *UpdateOrganizationMemberCommandHandler) handle(ctx context.Context, cmd UpdateOrganizationMemberCommand) (*organization.Member, error) {
+	admin, err := organizationAdmin(ctx, h.orgRepo, cmd.ActorID)
+	if err != nil {
+		return nil, err
+	}
+	m, members, err := colleague(ctx, h.orgRepo, admin.OrganizationID, cmd.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if m.Role == organization.RoleAdmin && cmd.Role != organization.RoleAdmin && organization.CountAdmins(members) == 1 {
+		return nil, organization.ErrLastAdmin
+	}
+
+	m.Role = cmd.Role
+	m.UpdatedAt = time.Now()
+	if err := h.orgRepo.SaveMember(ctx, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// colleague returns the member of the organization with the user ID, and
+// all of its members; members of other organizations are not found.
+func colleague(ctx context.Context, orgRepo organization.Repository, organizationID, userID string) (*organization.Member, []*organization.Member, error) {
+	members, err := orgRepo.ListMembers(ctx, organizationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, m := range members {
+		if m.UserID == userID {
+			return m, members, nil
+		}
+	}
+	return nil, nil, organization.ErrMemberNotFound
+}
+
+// RemoveOrganizationMemberCommand takes a member out of the admin's
+// organization. Any member can leave by removing themselves.
+type RemoveOrganizationMemberCommand struct {
+	ActorID string `json:"-" validate:"required"`
+	UserID  string `json:"-" validate:"required"`
+}
+
+type RemoveOrganizationMemberCommandHandler struct {
+	orgRepo organization.Repository
+}
+
+func NewRemoveOrganizationMemberCommandHandler(orgRepo organization.Repository) *RemoveOrganizationMemberCommandHandler {
+	return &RemoveOrganizationMemberCommandHandler{orgRepo: orgRepo}
+}
+
+// Handle keeps the orders the member bought for the organization in its
+// history.
+func (h *RemoveOrganizationMemberCommandHandler) Handle(ctx context.Context, cmd RemoveOrganizationMemberCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *RemoveOrganizationMemberCommandHandler) handle(ctx context.Context, cmd RemoveOrganizationMemberCommand) error {
+	actor, err := organizationMember(ctx, h.orgRepo, cmd.ActorID)
+	if err != nil {
+		return err
+	}
+	if actor.UserID != cmd.UserID && actor.Role != organization.RoleAdmin {
+		return fmt.Errorf("%w: only admins remove other members", organization.ErrNotPermitted)
+	}
+	m, members, err := colleague(ctx, h.orgRepo, actor.OrganizationID, cmd.UserID)
+	if err != nil {
+		return err
+	}
+	if m.Role == organization.RoleAdmin && organization.CountAdmins(members) == 1 {
+		return organization.ErrLastAdmin
+	}
+	return h.orgRepo.DeleteMember(ctx, m.UserID)
+}
+
+type ApprovalStepCmd struct {
+	MinAmount float64           `json:"min_amount" validate:"gte=0"`
+	Role      organization.Role `json:"role" validate:"required,oneof=approver admin"`
+}
+
+// SetApprovalChainCommand replaces the approvals the admin's organization
+// needs for purchases on invoice.
+type SetApprovalChainCommand struct {
+	ActorID string            `json:"-" validate:"required"`
+	Steps   []ApprovalStepCmd `json:"steps" validate:"max=5,dive"`
+}
+
+type SetApprovalChainCommandHandler struct {
+	orgRepo organization.Repository
+}
+
+func NewSetApprovalChainCommandHandler(orgRepo organization.Repository) *SetApprovalChainCommandHandler {
+	return &SetApprovalChainCommandHandler{orgRepo: orgRepo}
+}
+
+// Handle applies to purchases asked for from then on; those already waiting
+// keep the steps they were asked with.
+func (h *SetApprovalChainCommandHandler) Handle(ctx context.Context, cmd SetApprovalChainCommand) (*organization.Organization, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *SetApprovalChainCommandHandler) handle(ctx context.Context, cmd SetApprovalChainCommand) (*organization.Organization, error) {
+	admin, err := organizationAdmin(ctx, h.orgRepo, cmd.ActorID)
+	if err != nil {
+		return nil, err
+	}
+	org, err := h.orgRepo.GetByID(ctx, admin.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	steps := make([]organization.ApprovalStep, len(cmd.Steps))
+	for i, step := range cmd.Steps {
+		steps[i] = organization.ApprovalStep{MinAmount: step.MinAmount, Role: step.Role}
+	}
+	if err := org.SetApprovalChain(steps); err != nil {
+		return nil, err
+	}
+	if err := h.orgRepo.Update(ctx, org); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// SetOrganizationCreditCommand grants an organization credit terms, or
+// takes them away with a limit of zero.
+type SetOrganizationCreditCommand struct {
+	OrganizationID   string  `json:"-" validate:"required"`
+	CreditLimit      float64 `json:"credit_limit" validate:"gte=0"`
+	PaymentTermsDays int     `json:"payment_terms_days" validate:"required,min=1,max=180"`
+}
+
+type SetOrganizationCreditCommandHandler struct {
+	orgRepo organization.Repository
+}
+
+func NewSetOrganizationCreditCommandHandler(orgRepo organization.Repository) *SetOrganizationCreditCommandHandler {
+	return &SetOrganizationCreditCommandHandler{orgRepo: orgRepo}
+}
+
+// Handle leaves open invoices due when they were issued for; a lower limit
+// only keeps the organization from buying more.
+func (h *SetOrganizationCreditCommandHandler) Handle(ctx context.Context, cmd SetOrganizationCreditCommand) (*organization.Organization, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *SetOrganizationCreditCommandHandler) handle(ctx context.Context, cmd SetOrganizationCreditCommand) (*organization.Organization, error) {
+	org, err := h.orgRepo.GetByID(ctx, cmd.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	org.CreditLimit = cmd.CreditLimit
+	org.PaymentTermsDays = cmd.PaymentTermsDays
+	org.UpdatedAt = time.Now()
+	if err := h.orgRepo.Update(ctx, org); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// orderInvoicer bills orders to organizations, once their purchase is
+// approved or straight away when it needs no approval.
+type orderInvoicer struct {
+	orderRepo   order.Repository
+	paymentRepo payment.Repository
+	invoiceRepo organization.InvoiceRepository
+	confirmer   *OrderConfirmer
+}
+
+// payable reports whether the order can still be bought on invoice: it is
+// pending, with nothing paid or being paid online.
+func (v *orderInvoicer) payable(ctx context.Context, o *order.Order) error {
+	if o.Status == order.StatusOnHold {
+		return ErrOrderOnHold
+	}
+	if o.Status != order.StatusPending || o.PaymentStatus != order.PaymentStatusUnpaid {
+		return ErrOrderNotPayable
+	}
+	existing, err := v.paymentRepo.ListByOrderID(ctx, o.ID)
+	if err != nil {
+		return err
+	}
+	summary := payment.Summarize(o.TotalAmount, existing)
+	if summary.Pending > 0 || summary.Paid > 0 {
+		return fmt.Errorf("%w: the order has online payments", organization.ErrNotInvoiceable)
+	}
+	return nil
+}
+
+func (v *orderInvoicer) checkCredit(ctx context.Context, org *organization.Organization, amount float64) error {
+	owed, err := v.invoiceRepo.Exposure(ctx, org.ID, time.Now())
+	if err != nil {
+		return err
+	}
+	return org.CheckCredit(owed, amount)
+}
+
+// invoice confirms the order with a pending invoice payment for its total,
+// due on the organization's payment terms. The organization is read with
+// Repository.Lock, so another purchase cannot be checked against the same
+// credit before this invoice is stored.
+func (v *orderInvoicer) invoice(ctx context.Context, o *order.Order, org *organization.Organization, purchaseOrder string) (*organization.Invoice, error) {
+	if err := v.checkCredit(ctx, org, o.TotalAmount); err != nil {
+		return nil, err
+	}
+	if err := o.PayOnInvoice(org.ID); err != nil {
+		return nil, ErrOrderNotPayable
+	}
+
+	now := time.Now()
+	if v.confirmer != nil {
+		if err := v.confirmer.Estimate(ctx, o, now); err != nil {
+			return nil, err
+		}
+	}
+	pay := payment.NewPayment(o.ID, o.UserID, o.TotalAmount, payment.MethodInvoice)
+	pay.ExternalID = pay.ID
+	invoice := organization.NewInvoice(org, o.ID, pay.ID, o.TotalAmount, purchaseOrder, now)
+	pay.DueAt = &invoice.DueAt
+	pay.ExpiresAt = invoice.DueAt
+
+	if err := v.paymentRepo.Create(ctx, pay); err != nil {
+		return nil, err
+	}
+	if err := v.invoiceRepo.Create(ctx, invoice); err != nil {
+		return nil, err
+	}
+	if err := v.orderRepo.Update(ctx, o); err != nil {
+		return nil, err
+	}
+	v.confirmer.notify(ctx, o, true)
+	return invoice, nil
+}
+
+// PayOnInvoiceCommand buys a pending order on the account of the
+// organization the customer buys for.
+type PayOnInvoiceCommand struct {
+	OrderID       string `json:"-" validate:"required"`
+	UserID        string `json:"-" validate:"required"`
+	PurchaseOrder string `json:"purchase_order" validate:"max=100"`
+}
+
+type PayOnInvoiceCommandHandler struct {
+	orgRepo      organization.Repository
+	approvalRepo organization.ApprovalRepository
+	invoicer     *orderInvoicer
+}
+
+func NewPayOnInvoiceCommandHandler(
+	orderRepo order.Repository,
+	paymentRepo payment.Repository,
+	orgRepo organization.Repository,
+	approvalRepo organization.ApprovalRepository,
+	invoiceRepo organization.InvoiceRepository,
+	confirmer *OrderConfirmer,
+) *PayOnInvoiceCommandHandler {
+	return &PayOnInvoiceCommandHandler{
+		orgRepo:      orgRepo,
+		approvalRepo: approvalRepo,
+		invoicer: &orderInvoicer{
+			orderRepo:   orderRepo,
+			paymentRepo: paymentRepo,
+			invoiceRepo: invoiceRepo,
+			confirmer:   confirmer,
+		},
+	}
+}
+
+// Handle invoices the order to the organization when its approval chain
+// asks for no approval of its total. Otherwise the order is held for the
+// chain's approvals, and stays pending until the last of them invoices it.
+// Either way it must fit within the organization's credit.
+func (h *PayOnInvoiceCommandHandler) Handle(ctx context.Context, cmd PayOnInvoiceCommand) (*organization.Purchase, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *PayOnInvoiceCommandHandler) handle(ctx context.Context, cmd PayOnInvoiceCommand) (*organization.Purchase, error) {
+	member, err := h.orgRepo.GetMember(ctx, cmd.UserID)
+	if errors.Is(err, organization.ErrMemberNotFound) {
+		return nil, fmt.Errorf("%w: only members of an organization buy on invoice", organization.ErrNotInvoiceable)
+	}
+	if err != nil {
+		return nil, err
+	}
+	org, err := h.orgRepo.Lock(ctx, member.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := h.invoicer.orderRepo.GetByID(ctx, cmd.OrderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+	if o.UserID != cmd.UserID {
+		return nil, ErrForbidden
+	}
+	if err := h.invoicer.payable(ctx, o); err != nil {
+		return nil, err
+	}
+	if _, err := h.approvalRepo.GetPendingByOrderID(ctx, o.ID); err == nil {
+		return nil, fmt.Errorf("%w: the order awaits approval", organization.ErrNotInvoiceable)
+	} else if !errors.Is(err, organization.ErrApprovalNotFound) {
+		return nil, err
+	}
+
+	steps := org.StepsFor(o.TotalAmount)
+	if len(steps) == 0 {
+		invoice, err := h.invoicer.invoice(ctx, o, org, cmd.PurchaseOrder)
+		if err != nil {
+			return nil, err
+		}
+		return &organization.Purchase{Invoice: invoice}, nil
+	}
+
+	// A purchase the organization cannot afford is not sent for approval
+	if err := h.invoicer.checkCredit(ctx, org, o.TotalAmount); err != nil {
+		return nil, err
+	}
+	approval := organization.NewApproval(org.ID, o.ID, cmd.UserID, o.TotalAmount, cmd.PurchaseOrder, steps)
+	if err := h.approvalRepo.Create(ctx, approval); err != nil {
+		return nil, err
+	}
+	// The order is in the organization's history while it waits
+	o.OrganizationID = org.ID
+	o.UpdatedAt = time.Now()
+	if err := h.invoicer.orderRepo.Update(ctx, o); err != nil {
+		return nil, err
+	}
+	return &organization.Purchase{Approval: approval}, nil
+}
+
+// DecidePurchaseApprovalCommand approves or rejects the next step of a
+// purchase on invoice.
+type DecidePurchaseApprovalCommand struct {
+	ApprovalID string `json:"-" validate:"required"`
+	UserID     string `json:"-" validate:"required"`
+	Approve    bool   `json:"-"`
+	Note       string `json:"note" validate:"max=500"`
+}
+
+type DecidePurchaseApprovalCommandHandler struct {
+	orgRepo      organization.Repository
+	approvalRepo organization.ApprovalRepository
+	invoicer     *orderInvoicer
+}
+
+func NewDecidePurchaseApprovalCommandHandler(
+	orderRepo order.Repository,
+	paymentRepo payment.Repository,
+	orgRepo organization.Repository,
+	approvalRepo organization.ApprovalRepository,
+	invoiceRepo organization.InvoiceRepository,
+	confirmer *OrderConfirmer,
+) *DecidePurchaseApprovalCommandHandler {
+	return &DecidePurchaseApprovalCommandHandler{
+		orgRepo:      orgRepo,
+		approvalRepo: approvalRepo,
+		invoicer: &orderInvoicer{
+			orderRepo:   orderRepo,
+			paymentRepo: paymentRepo,
+			invoiceRepo: invoiceRepo,
+			confirmer:   confirmer,
+		},
+	}
+}
+
+// Handle invoices the order once its last step is approved. A purchase
+// whose order was cancelled or paid otherwise in the meantime is closed as
+// cancelled instead of decided; one that no longer fits within the credit
+// stays undecided until invoices are paid.
+func (h *DecidePurchaseApprovalCommandHandler) Handle(ctx context.Context, cmd DecidePurchaseApprovalCommand) (*organization.Purchase, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *DecidePurchaseApprovalCommandHandler) handle(ctx context.Context, cmd DecidePurchaseApprovalCommand) (*organization.Purchase, error) {
+	member, err := h.orgRepo.GetMember(ctx, cmd.UserID)
+	if errors.Is(err, organization.ErrMemberNotFound) {
+		return nil, organization.ErrApprovalNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	approval, err := h.approvalRepo.GetByID(ctx, cmd.ApprovalID)
+	if err != nil {
+		return nil, err
+	}
+	if approval.OrganizationID != member.OrganizationID {
+		return nil, organization.ErrApprovalNotFound
+	}
+
+	now := time.Now()
+	o, err := h.invoicer.orderRepo.GetByID(ctx, approval.OrderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+	if approval.Status == organization.ApprovalPending && h.invoicer.payable(ctx, o) != nil {
+		approval.Cancel(now)
+		if err := h.approvalRepo.Update(ctx, approval); err != nil {
+			return nil, err
+		}
+		return &organization.Purchase{Approval: approval}, nil
+	}
+
+	if err := approval.Decide(member, cmd.Approve, cmd.Note, now); err != nil {
+		return nil, err
+	}
+	purchase := &organization.Purchase{Approval: approval}
+	if approval.Status == organization.ApprovalApproved {
+		org, err := h.orgRepo.Lock(ctx, approval.OrganizationID)
+		if err != nil {
+			return nil, err
+		}
+		if purchase.Invoice, err = h.invoicer.invoice(ctx, o, org, approval.PurchaseOrder); err != nil {
+			return nil, err
+		}
+	}
+	if err := h.approvalRepo.Update(ctx, approval); err != nil {
+		return nil, err
+	}
+	return purchase, nil
+}
+
+// RecordInvoicePaymentCommand books the bank transfer an invoice was paid
+// with.
+type RecordInvoicePaymentCommand struct {
+	InvoiceID string `json:"-" validate:"required"`
+	Reference string `json:"reference" validate:"required,max=100"`
+}
+
+type RecordInvoicePaymentCommandHandler struct {
+	invoiceRepo organization.InvoiceRepository
+	paymentRepo payment.Repository
+	orderRepo   order.Repository
+}
+
+func NewRecordInvoicePaymentCommandHandler(invoiceRepo organization.InvoiceRepository, paymentRepo payment.Repository, orderRepo order.Repository) *RecordInvoicePaymentCommandHandler {
+	return &RecordInvoicePaymentCommandHandler{invoiceRepo: invoiceRepo, paymentRepo: paymentRepo, orderRepo: orderRepo}
+}
+
+// Handle settles the invoice payment in full, so the order is paid. The
+// invoice of a cancelled order was cancelled with it and is not owed.
+func (h *RecordInvoicePaymentCommandHandler) Handle(ctx context.Context, cmd RecordInvoicePaymentCommand) (*organization.Invoice, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *RecordInvoicePaymentCommandHandler) handle(ctx context.Context, cmd RecordInvoicePaymentCommand) (*organization.Invoice, error) {
+	invoice, err := h.invoiceRepo.GetByID(ctx, cmd.InvoiceID)
+	if err != nil {
+		return nil, err
+	}
+	pay, err := h.paymentRepo.GetByID(ctx, invoice.PaymentID)
+	if err != nil {
+		return nil, ErrPaymentNotFound
+	}
+	if pay.Status != payment.StatusPending {
+		return nil, fmt.Errorf("%w: its payment is %s", organization.ErrInvoiceNotOpen, pay.Status)
+	}
+	if err := invoice.Pay(cmd.Reference, time.Now()); err != nil {
+		return nil, err
+	}
+
+	pay.MarkAsPaid(invoice.Reference)
+	if err := h.paymentRepo.Update(ctx, pay); err != nil {
+		return nil, err
+	}
+	if err := h.invoiceRepo.Update(ctx, invoice); err != nil {
+		return nil, err
+	}
+
+	o, err := h.orderRepo.GetByID(ctx, invoice.OrderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+	if _, err := reconcileOrderPayments(ctx, h.orderRepo, h.paymentRepo, nil, nil, o); err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
@@ -0,0 +1,313 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/payment"
+)
+
+// AddPaymentMethodCommand saves a card the provider has already tokenized.
+// The card number never reaches the API: Token is the provider's saved
+// token and MaskedCard its masked number.
+type AddPaymentMethodCommand struct {
+	UserID      string `json:"-" validate:"required"`
+	Token       string `json:"token" validate:"required,max=255"`
+	MaskedCard  string `json:"masked_card" validate:"required,max=32"`
+	Brand       string `json:"brand" validate:"omitempty,max=32"`
+	ExpiryMonth int    `json:"expiry_month" validate:"required,min=1,max=12"`
+	ExpiryYear  int    `json:"expiry_year" validate:"required,min=2000,max=2100"`
+	Label       string `json:"label" validate:"omitempty,max=100"`
+	MakeDefault bool   `json:"make_default"`
+}
+
+type DeletePaymentMethodCommand struct {
+	UserID   string `json:"-" validate:"required"`
+	MethodID string `json:"-" validate:"required"`
+}
+
+type SetDefaultPaymentMethodCommand struct {
+	UserID   string `json:"-" validate:"required"`
+	MethodID string `json:"-" validate:"required"`
+}
+
+// OneClickCheckoutCommand places an order and pays for it with a saved
+// method, the customer's default when PaymentMethodID is empty.
+type OneClickCheckoutCommand struct {
+	UserID          string               `json:"-" validate:"required"`
+	PaymentMethodID string               `json:"payment_method_id"`
+	Items           []CreateOrderItemCmd `json:"items" validate:"required,min=1,max=100,dive"`
+	ShippingAddress order.Address        `json:"shipping_address" validate:"required"`
+	BillingAddress  *order.Address       `json:"billing_address,omitempty"`
+}
+
+// NonTransactional: the payment is recorded before the card is charged, and
+// that record has to survive a failure placing the order.
+func (OneClickCheckoutCommand) NonTransactional() {}
+
+// OneClickCheckoutResult has no Payment when the order is held for fraud
+// review; the customer pays once it has been approved.
+type OneClickCheckoutResult struct {
+	Order   *order.Order     `json:"order"`
+	Payment *payment.Payment `json:"payment"`
+}
+
+type AddPaymentMethodCommandHandler struct {
+	methodRepo payment.MethodRepository
+	provider   string
+}
+
+func NewAddPaymentMethodCommandHandler(methodRepo payment.MethodRepository, provider string) *AddPaymentMethodCommandHandler {
+	return &AddPaymentMethodCommandHandler{methodRepo: methodRepo, provider: provider}
+}
+
+func (h *AddPaymentMethodCommandHandler) Handle(ctx context.Context, cmd AddPaymentMethodCommand) (*payment.SavedMethod, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *AddPaymentMethodCommandHandler) handle(ctx context.Context, cmd AddPaymentMethodCommand) (*payment.SavedMethod, error) {
+	existing, err := h.methodRepo.ListByUser(ctx, cmd.UserID)
+	if err != nil {
+		return nil, err
+	}
+	// Saving the same card again is not an error
+	for _, m := range existing {
+		if m.Token == cmd.Token {
+			return m, nil
+		}
+	}
+	if len(existing) >= payment.MaxSavedMethods {
+		return nil, ErrPaymentMethodLimitReached
+	}
+
+	m, err := payment.NewSavedMethod(cmd.UserID, h.provider, cmd.Token, cmd.MaskedCard, cmd.Brand, cmd.ExpiryMonth, cmd.ExpiryYear, cmd.Label)
+	if err != nil {
+		return nil, err
+	}
+	// The first method saved becomes the default
+	m.IsDefault = len(existing) == 0
+
+	if err := h.methodRepo.Create(ctx, m); err != nil {
+		return nil, err
+	}
+	if cmd.MakeDefault && !m.IsDefault {
+		if err := h.methodRepo.SetDefault(ctx, cmd.UserID, m.ID); err != nil {
+			return nil, err
+		}
+		m.IsDefault = true
+	}
+	return m, nil
+}
+
+type DeletePaymentMethodCommandHandler struct {
+	methodRepo payment.MethodRepository
+}
+
+func NewDeletePaymentMethodCommandHandler(methodRepo payment.MethodRepository) *DeletePaymentMethodCommandHandler {
+	return &DeletePaymentMethodCommandHandler{methodRepo: methodRepo}
+}
+
+// Handle deletes a method. When it was the default, the most recently saved
+// of the remaining methods takes its place.
+func (h *DeletePaymentMethodCommandHandler) Handle(ctx context.Context, cmd DeletePaymentMethodCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *DeletePaymentMethodCommandHandler) handle(ctx context.Context, cmd DeletePaymentMethodCommand) error {
+	m, err := ownedPaymentMethod(ctx, h.methodRepo, cmd.UserID, cmd.MethodID)
+	if err != nil {
+		return err
+	}
+
+	if err := h.methodRepo.Delete(ctx, m.ID); err != nil {
+		return err
+	}
+	if !m.IsDefault {
+		return nil
+	}
+
+	remaining, err := h.methodRepo.ListByUser(ctx, cmd.UserID)
+	if err != nil || len(remaining) == 0 {
+		return err
+	}
+	return h.methodRepo.SetDefault(ctx, cmd.UserID, remaining[0].ID)
+}
+
+type SetDefaultPaymentMethodCommandHandler struct {
+	methodRepo payment.MethodRepository
+}
+
+func NewSetDefaultPaymentMethodCommandHandler(methodRepo payment.MethodRepository) *SetDefaultPaymentMethodCommandHandler {
+	return &SetDefaultPaymentMethodCommandHandler{methodRepo: methodRepo}
+}
+
+func (h *SetDefaultPaymentMethodCommandHandler) Handle(ctx context.Context, cmd SetDefaultPaymentMethodCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *SetDefaultPaymentMethodCommandHandler) handle(ctx context.Context, cmd SetDefaultPaymentMethodCommand) error {
+	m, err := ownedPaymentMethod(ctx, h.methodRepo, cmd.UserID, cmd.MethodID)
+	if err != nil {
+		return err
+	}
+	return h.methodRepo.SetDefault(ctx, cmd.UserID, m.ID)
+}
+
+type OneClickCheckoutCommandHandler struct {
+	methodRepo         payment.MethodRepository
+	paymentRepo        payment.Repository
+	tokenProvider      payment.TokenProvider
+	orderRepo          order.Repository
+	createOrderHandler *CreateOrderCommandHandler
+	cancelOrderHandler *CancelOrderCommandHandler
+	confirmer          *OrderConfirmer
+	receipts           *ReceiptSender
+}
+
+func NewOneClickCheckoutCommandHandler(
+	methodRepo payment.MethodRepository,
+	paymentRepo payment.Repository,
+	tokenProvider payment.TokenProvider,
+	orderRepo order.Repository,
+	createOrderHandler *CreateOrderCommandHandler,
+	cancelOrderHandler *CancelOrderCommandHandler,
+	confirmer *OrderConfirmer,
+	receipts *ReceiptSender,
+) *OneClickCheckoutCommandHandler {
+	return &OneClickCheckoutCommandHandler{
+		methodRepo:         methodRepo,
+		paymentRepo:        paymentRepo,
+		tokenProvider:      tokenProvider,
+		orderRepo:          orderRepo,
+		createOrderHandler: createOrderHandler,
+		cancelOrderHandler: cancelOrderHandler,
+		confirmer:          confirmer,
+		receipts:           receipts,
+	}
+}
+
+// Handle places the order and charges the saved token. A declined charge
+// cancels the order so its stock goes back on sale. A charge that needs
+// 3-D Secure leaves the order pending with the payment's URL for the
+// customer to finish on; the payment webhook settles it from there, as it
+// does a charge whose outcome is unknown because the provider failed.
+func (h *OneClickCheckoutCommandHandler) Handle(ctx context.Context, cmd OneClickCheckoutCommand) (*OneClickCheckoutResult, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *OneClickCheckoutCommandHandler) handle(ctx context.Context, cmd OneClickCheckoutCommand) (*OneClickCheckoutResult, error) {
+	method, err := h.paymentMethod(ctx, cmd.UserID, cmd.PaymentMethodID)
+	if err != nil {
+		return nil, err
+	}
+	if method.ExpiredAt(time.Now()) {
+		return nil, payment.ErrMethodExpired
+	}
+
+	newOrder, err := h.createOrderHandler.Handle(ctx, CreateOrderCommand{
+		UserID:          cmd.UserID,
+		Items:           cmd.Items,
+		ShippingAddress: cmd.ShippingAddress,
+		BillingAddress:  cmd.BillingAddress,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if newOrder.Status == order.StatusOnHold {
+		return &OneClickCheckoutResult{Order: newOrder}, nil
+	}
+
+	// The payment is recorded before the card is charged, so no charge is
+	// ever made that the shop has no record of
+	pay := payment.NewPayment(newOrder.ID, cmd.UserID, newOrder.TotalAmount, method.Type)
+	pay.ExternalID = pay.ID
+	if err := h.paymentRepo.Create(ctx, pay); err != nil {
+		if cancelErr := h.cancel(ctx, newOrder); cancelErr != nil {
+			return nil, cancelErr
+		}
+		return nil, err
+	}
+
+	result, err := h.tokenProvider.ChargeToken(ctx, pay, method.Token)
+	if err == nil && result.Status != payment.StatusPaid && result.Status != payment.StatusPending {
+		err = fmt.Errorf("%w: the card issuer refused the charge", payment.ErrChargeDeclined)
+	}
+	if errors.Is(err, payment.ErrChargeDeclined) {
+		pay.MarkAsFailed()
+		if updateErr := h.paymentRepo.Update(ctx, pay); updateErr != nil {
+			return nil, updateErr
+		}
+		if cancelErr := h.cancel(ctx, newOrder); cancelErr != nil {
+			return nil, cancelErr
+		}
+		return nil, err
+	}
+	if err != nil {
+		// The charge may still have gone through, such as when the provider
+		// timed out after capturing it, so the order is left pending for the
+		// payment webhook or reconciliation to settle
+		return &OneClickCheckoutResult{Order: newOrder, Payment: pay}, nil
+	}
+
+	pay.PaymentURL = result.RedirectURL
+	pay.TransactionID = result.TransactionID
+	if result.Status == payment.StatusPaid {
+		pay.MarkAsPaid(result.TransactionID)
+	}
+	if err := h.paymentRepo.Update(ctx, pay); err != nil {
+		return nil, err
+	}
+
+	if pay.IsPaid() {
+		confirmed, err := h.confirmer.confirm(ctx, newOrder, func() { newOrder.RecordPayment(pay.Amount, true) })
+		if err != nil {
+			return nil, err
+		}
+		if err := h.orderRepo.Update(ctx, newOrder); err != nil {
+			return nil, err
+		}
+		h.confirmer.notify(ctx, newOrder, confirmed)
+		h.receipts.send(ctx, newOrder, payment.Summarize(newOrder.TotalAmount, []*payment.Payment{pay}))
+	}
+	return &OneClickCheckoutResult{Order: newOrder, Payment: pay}, nil
+}
+
+func (h *OneClickCheckoutCommandHandler) paymentMethod(ctx context.Context, userID, methodID string) (*payment.SavedMethod, error) {
+	if methodID != "" {
+		return ownedPaymentMethod(ctx, h.methodRepo, userID, methodID)
+	}
+
+	methods, err := h.methodRepo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range methods {
+		if m.IsDefault {
+			return m, nil
+		}
+	}
+	return nil, payment.ErrMethodNotFound
+}
+
+// cancel gives a declined order's stock back. A failure is returned rather
+// than the decline, since the order is still pending and holding its stock.
+func (h *OneClickCheckoutCommandHandler) cancel(ctx context.Context, o *order.Order) error {
+	return h.cancelOrderHandler.Handle(ctx, CancelOrderCommand{OrderID: o.ID, UserID: o.UserID, Reason: order.CancelReasonPaymentFailed})
+}
+
+// ownedPaymentMethod reads a method of the user's; another user's method is
+// reported as not found.
+func ownedPaymentMethod(ctx context.Context, methodRepo payment.MethodRepository, userID, methodID string) (*payment.SavedMethod, error) {
+	m, err := methodRepo.GetByID(ctx, methodID)
+	if err != nil {
+		return nil, err
+	}
+	if m.UserID != userID {
+		return nil, payment.ErrMethodNotFound
+	}
+	return m, nil
+}
@@ -0,0 +1,260 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/pricealert"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/user"
+)
+
+type WatchPriceCommand struct {
+	UserID      string             `json:"-" validate:"required"`
+	ProductID   string             `json:"product_id" validate:"required"`
+	TargetPrice float64            `json:"target_price" validate:"required,gt=0"`
+	Channel     pricealert.Channel `json:"channel" validate:"omitempty,oneof=email push sms in-app whatsapp"`
+}
+
+type WatchPriceCommandHandler struct {
+	watchRepo   pricealert.Repository
+	productRepo product.Repository
+	lifetime    time.Duration
+}
+
+func NewWatchPriceCommandHandler(watchRepo pricealert.Repository, productRepo product.Repository, lifetime time.Duration) *WatchPriceCommandHandler {
+	return &WatchPriceCommandHandler{
+		watchRepo:   watchRepo,
+		productRepo: productRepo,
+		lifetime:    lifetime,
+	}
+}
+
+// Handle watches a product for a drop to the target price. Watching again
+// moves the active watch to the new target and channel.
+func (h *WatchPriceCommandHandler) Handle(ctx context.Context, cmd WatchPriceCommand) (*pricealert.Watch, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *WatchPriceCommandHandler) handle(ctx context.Context, cmd WatchPriceCommand) (*pricealert.Watch, error) {
+	if cmd.Channel == "" {
+		cmd.Channel = pricealert.ChannelEmail
+	}
+
+	p, err := h.productRepo.GetByID(ctx, cmd.ProductID)
+	if err != nil || p.Status == product.StatusDeleted {
+		return nil, ErrProductNotFound
+	}
+
+	existing, err := h.watchRepo.FindActive(ctx, cmd.UserID, cmd.ProductID)
+	if err == nil {
+		if err := existing.Retarget(p.Price, cmd.TargetPrice, cmd.Channel); err != nil {
+			return nil, err
+		}
+		if err := h.watchRepo.Update(ctx, existing); err != nil {
+			return nil, err
+		}
+		return existing, nil
+	}
+	if !errors.Is(err, pricealert.ErrNotFound) {
+		return nil, err
+	}
+
+	w, err := pricealert.NewWatch(cmd.UserID, cmd.ProductID, p.Price, cmd.TargetPrice, cmd.Channel, h.lifetime)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.watchRepo.Create(ctx, w); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+type CancelPriceAlertCommand struct {
+	UserID  string `json:"-" validate:"required"`
+	WatchID string `json:"-" validate:"required"`
+}
+
+type CancelPriceAlertCommandHandler struct {
+	watchRepo pricealert.Repository
+}
+
+func NewCancelPriceAlertCommandHandler(watchRepo pricealert.Repository) *CancelPriceAlertCommandHandler {
+	return &CancelPriceAlertCommandHandler{watchRepo: watchRepo}
+}
+
+func (h *CancelPriceAlertCommandHandler) Handle(ctx context.Context, cmd CancelPriceAlertCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *CancelPriceAlertCommandHandler) handle(ctx context.Context, cmd CancelPriceAlertCommand) error {
+	w, err := h.watchRepo.GetByID(ctx, cmd.WatchID)
+	if err != nil {
+		return err
+	}
+	if w.UserID != cmd.UserID {
+		return pricealert.ErrNotFound
+	}
+	if !w.IsActive() {
+		return nil
+	}
+
+	w.Cancel()
+	return h.watchRepo.Update(ctx, w)
+}
+
+// RecordPriceChangeCommand adds a product's price change to its price history.
+type RecordPriceChangeCommand struct {
+	ProductID string
+	OldPrice  float64
+	NewPrice  float64
+}
+
+type RecordPriceChangeCommandHandler struct {
+	historyRepo product.PriceHistoryRepository
+}
+
+func NewRecordPriceChangeCommandHandler(historyRepo product.PriceHistoryRepository) *RecordPriceChangeCommandHandler {
+	return &RecordPriceChangeCommandHandler{historyRepo: historyRepo}
+}
+
+// Handle records the change; an unchanged price records nothing.
+func (h *RecordPriceChangeCommandHandler) Handle(ctx context.Context, cmd RecordPriceChangeCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *RecordPriceChangeCommandHandler) handle(ctx context.Context, cmd RecordPriceChangeCommand) error {
+	if cmd.NewPrice == cmd.OldPrice {
+		return nil
+	}
+	return h.historyRepo.Record(ctx, product.NewPriceChange(cmd.ProductID, cmd.OldPrice, cmd.NewPrice))
+}
+
+// NotifyPriceDropsCommand sends alerts for the price drops in the price
+// history. At most BatchSize watchers of a product are notified per run,
+// earliest watchers first.
+type NotifyPriceDropsCommand struct {
+	BatchSize int
+}
+
+// NonTransactional: each watch is closed as its alert goes out, so a
+// failure later in the run cannot send it twice.
+func (NotifyPriceDropsCommand) NonTransactional() {}
+
+type NotifyPriceDropsCommandHandler struct {
+	historyRepo product.PriceHistoryRepository
+	watchRepo   pricealert.Repository
+	productRepo product.Repository
+	userRepo    user.Repository
+	notifier    pricealert.Notifier
+	siteURL     string
+}
+
+func NewNotifyPriceDropsCommandHandler(historyRepo product.PriceHistoryRepository, watchRepo pricealert.Repository, productRepo product.Repository, userRepo user.Repository, notifier pricealert.Notifier, siteURL string) *NotifyPriceDropsCommandHandler {
+	return &NotifyPriceDropsCommandHandler{
+		historyRepo: historyRepo,
+		watchRepo:   watchRepo,
+		productRepo: productRepo,
+		userRepo:    userRepo,
+		notifier:    notifier,
+		siteURL:     siteURL,
+	}
+}
+
+// Handle expires watches that ran out and checks the unprocessed price drops
+// against the watches of their products. Watches are compared with the
+// current price, so a drop that was already reversed alerts no one. A drop
+// is processed once every triggered watch was notified; one whose alerts
+// could not all be queued is retried on the next run. It returns how many
+// were sent.
+func (h *NotifyPriceDropsCommandHandler) Handle(ctx context.Context, cmd NotifyPriceDropsCommand) (int, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *NotifyPriceDropsCommandHandler) handle(ctx context.Context, cmd NotifyPriceDropsCommand) (int, error) {
+	if cmd.BatchSize <= 0 {
+		cmd.BatchSize = 100
+	}
+
+	if _, err := h.watchRepo.ExpireBefore(ctx, time.Now()); err != nil {
+		return 0, err
+	}
+
+	drops, err := h.historyRepo.ListUnprocessedDrops(ctx, 10*cmd.BatchSize)
+	if err != nil || len(drops) == 0 {
+		return 0, err
+	}
+	var ids []string
+	changes := map[string][]string{}
+	for _, c := range drops {
+		if _, ok := changes[c.ProductID]; !ok {
+			ids = append(ids, c.ProductID)
+		}
+		changes[c.ProductID] = append(changes[c.ProductID], c.ID)
+	}
+	products, err := h.productRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+	found := map[string]*product.Product{}
+	for _, p := range products {
+		found[p.ID] = p
+	}
+
+	sent := 0
+	for _, id := range ids {
+		p, ok := found[id]
+		if ok && p.Status == product.StatusActive {
+			watches, err := h.watchRepo.ListTriggered(ctx, p.ID, p.Price, cmd.BatchSize)
+			if err != nil {
+				return sent, err
+			}
+			for _, w := range watches {
+				notified, err := h.notify(ctx, w, p)
+				if err != nil {
+					return sent, fmt.Errorf("price alert %s: %w", w.ID, err)
+				}
+				if notified {
+					sent++
+				}
+			}
+			if len(watches) == cmd.BatchSize {
+				// More watchers may be waiting; the next run picks them up.
+				continue
+			}
+		}
+		if err := h.historyRepo.MarkProcessed(ctx, changes[id]); err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+// notify sends one alert and closes the watch. Watches of users who are
+// gone are expired without an alert.
+func (h *NotifyPriceDropsCommandHandler) notify(ctx context.Context, w *pricealert.Watch, p *product.Product) (bool, error) {
+	u, err := h.userRepo.GetByID(ctx, w.UserID)
+	if err != nil || u.Status != user.StatusActive {
+		w.Expire()
+		return false, h.watchRepo.Update(ctx, w)
+	}
+
+	alert := pricealert.Alert{
+		Watch:       w,
+		ProductName: p.Name,
+		ProductURL:  strings.TrimSuffix(h.siteURL, "/") + p.URLPath(),
+		Price:       p.Price,
+		Phone:       u.Phone,
+		Locale:      u.Locale,
+	}
+	if err := h.notifier.PriceDropped(ctx, alert); err != nil {
+		return false, err
+	}
+
+	w.Notified(p.Price)
+	return true, h.watchRepo.Update(ctx, w)
+}
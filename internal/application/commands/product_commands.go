@@ -0,0 +1,314 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/product"
+)
+
+type UpdateProductSlugCommand struct {
+	ProductID string `json:"product_id" validate:"required"`
+	Slug      string `json:"slug" validate:"required,notblank,max=200"`
+}
+
+type UpdateCategorySlugCommand struct {
+	CategoryID string `json:"category_id" validate:"required"`
+	Slug       string `json:"slug" validate:"required,notblank,max=200"`
+}
+
+type UpdateProductSlugCommandHandler struct {
+	productRepo  product.Repository
+	redirectRepo product.SlugRedirectRepository
+	webhooks     *WebhookPublisher
+}
+
+func NewUpdateProductSlugCommandHandler(productRepo product.Repository, redirectRepo product.SlugRedirectRepository, webhooks *WebhookPublisher) *UpdateProductSlugCommandHandler {
+	return &UpdateProductSlugCommandHandler{
+		productRepo:  productRepo,
+		redirectRepo: redirectRepo,
+		webhooks:     webhooks,
+	}
+}
+
+func (h *UpdateProductSlugCommandHandler) Handle(ctx context.Context, cmd UpdateProductSlugCommand) (*product.Product, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *UpdateProductSlugCommandHandler) handle(ctx context.Context, cmd UpdateProductSlugCommand) (*product.Product, error) {
+	p, err := h.productRepo.GetByID(ctx, cmd.ProductID)
+	if err != nil {
+		return nil, ErrProductNotFound
+	}
+
+	slug := product.Slugify(cmd.Slug)
+	if slug == "" {
+		return nil, ErrInvalidSlug
+	}
+	if slug == p.Slug {
+		return p, nil
+	}
+
+	taken, err := h.productRepo.SlugExists(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, ErrSlugTaken
+	}
+
+	oldSlug := p.Slug
+	p.Slug = slug
+	p.UpdatedAt = time.Now()
+	if err := h.productRepo.Update(ctx, p); err != nil {
+		return nil, err
+	}
+
+	// Reclaiming a retired slug replaces its redirect
+	if err := h.redirectRepo.DeleteBySlug(ctx, product.EntityProduct, slug); err != nil {
+		return nil, err
+	}
+	if oldSlug != "" {
+		if err := h.redirectRepo.Create(ctx, product.NewSlugRedirect(product.EntityProduct, oldSlug, p.ID)); err != nil {
+			return nil, err
+		}
+	}
+
+	if h.webhooks != nil {
+		if err := h.webhooks.ProductUpdated(ctx, p); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+type UpdateCategorySlugCommandHandler struct {
+	categoryRepo product.CategoryRepository
+	redirectRepo product.SlugRedirectRepository
+}
+
+func NewUpdateCategorySlugCommandHandler(categoryRepo product.CategoryRepository, redirectRepo product.SlugRedirectRepository) *UpdateCategorySlugCommandHandler {
+	return &UpdateCategorySlugCommandHandler{
+		categoryRepo: categoryRepo,
+		redirectRepo: redirectRepo,
+	}
+}
+
+func (h *UpdateCategorySlugCommandHandler) Handle(ctx context.Context, cmd UpdateCategorySlugCommand) (*product.Category, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *UpdateCategorySlugCommandHandler) handle(ctx context.Context, cmd UpdateCategorySlugCommand) (*product.Category, error) {
+	c, err := h.categoryRepo.GetByID(ctx, cmd.CategoryID)
+	if err != nil {
+		return nil, ErrCategoryNotFound
+	}
+
+	slug := product.Slugify(cmd.Slug)
+	if slug == "" {
+		return nil, ErrInvalidSlug
+	}
+	if slug == c.Slug {
+		return c, nil
+	}
+
+	taken, err := h.categoryRepo.SlugExists(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, ErrSlugTaken
+	}
+
+	oldSlug := c.Slug
+	c.Slug = slug
+	c.UpdatedAt = time.Now()
+	if err := h.categoryRepo.Update(ctx, c); err != nil {
+		return nil, err
+	}
+
+	if err := h.redirectRepo.DeleteBySlug(ctx, product.EntityCategory, slug); err != nil {
+		return nil, err
+	}
+	if oldSlug != "" {
+		if err := h.redirectRepo.Create(ctx, product.NewSlugRedirect(product.EntityCategory, oldSlug, c.ID)); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+type SetProductFeaturedCommand struct {
+	ProductID string     `json:"product_id" validate:"required"`
+	Enabled   bool       `json:"enabled"`
+	Position  int        `json:"position" validate:"min=0"`
+	From      *time.Time `json:"from"`
+	Until     *time.Time `json:"until"`
+}
+
+type SetProductFeaturedCommandHandler struct {
+	productRepo product.Repository
+}
+
+func NewSetProductFeaturedCommandHandler(productRepo product.Repository) *SetProductFeaturedCommandHandler {
+	return &SetProductFeaturedCommandHandler{productRepo: productRepo}
+}
+
+func (h *SetProductFeaturedCommandHandler) Handle(ctx context.Context, cmd SetProductFeaturedCommand) (*product.Product, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *SetProductFeaturedCommandHandler) handle(ctx context.Context, cmd SetProductFeaturedCommand) (*product.Product, error) {
+	if cmd.From != nil && cmd.Until != nil && !cmd.Until.After(*cmd.From) {
+		return nil, ErrInvalidFeaturedWindow
+	}
+
+	p, err := h.productRepo.GetByID(ctx, cmd.ProductID)
+	if err != nil {
+		return nil, ErrProductNotFound
+	}
+
+	p.Featured = product.Featured{
+		Enabled:  cmd.Enabled,
+		Position: cmd.Position,
+		From:     cmd.From,
+		Until:    cmd.Until,
+	}
+	p.UpdatedAt = time.Now()
+
+	if err := h.productRepo.Update(ctx, p); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+type AttributeDefinitionCmd struct {
+	Key      string                `json:"key" validate:"required,max=64"`
+	Label    string                `json:"label" validate:"max=100"`
+	Type     product.AttributeType `json:"type" validate:"omitempty,oneof=text number boolean enum"`
+	Unit     string                `json:"unit" validate:"max=20"`
+	Options  []string              `json:"options" validate:"max=100,dive,max=100"`
+	Required bool                  `json:"required"`
+}
+
+// SetCategoryAttributesCommand replaces a category's attribute template.
+// Products keep the attributes they have until they are next set.
+type SetCategoryAttributesCommand struct {
+	CategoryID string                   `json:"-" validate:"required"`
+	Attributes []AttributeDefinitionCmd `json:"attributes" validate:"max=50,dive"`
+}
+
+type SetCategoryAttributesCommandHandler struct {
+	categoryRepo product.CategoryRepository
+	templateRepo product.AttributeTemplateRepository
+}
+
+func NewSetCategoryAttributesCommandHandler(categoryRepo product.CategoryRepository, templateRepo product.AttributeTemplateRepository) *SetCategoryAttributesCommandHandler {
+	return &SetCategoryAttributesCommandHandler{
+		categoryRepo: categoryRepo,
+		templateRepo: templateRepo,
+	}
+}
+
+func (h *SetCategoryAttributesCommandHandler) Handle(ctx context.Context, cmd SetCategoryAttributesCommand) ([]*product.AttributeDefinition, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *SetCategoryAttributesCommandHandler) handle(ctx context.Context, cmd SetCategoryAttributesCommand) ([]*product.AttributeDefinition, error) {
+	if _, err := h.categoryRepo.GetByID(ctx, cmd.CategoryID); err != nil {
+		return nil, ErrCategoryNotFound
+	}
+
+	defs := make([]product.AttributeDefinition, len(cmd.Attributes))
+	for i, a := range cmd.Attributes {
+		defs[i] = product.AttributeDefinition{
+			Key:      a.Key,
+			Label:    a.Label,
+			Type:     a.Type,
+			Unit:     a.Unit,
+			Options:  a.Options,
+			Required: a.Required,
+		}
+	}
+	template, err := product.NewAttributeTemplate(cmd.CategoryID, defs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.templateRepo.Replace(ctx, cmd.CategoryID, template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// AttributeValueCmd sets one attribute. Label, Type and Unit are only read
+// for products whose category has no template.
+type AttributeValueCmd struct {
+	Key   string                `json:"key" validate:"required,max=64"`
+	Value string                `json:"value" validate:"max=500"`
+	Label string                `json:"label" validate:"max=100"`
+	Type  product.AttributeType `json:"type" validate:"omitempty,oneof=text number boolean"`
+	Unit  string                `json:"unit" validate:"max=20"`
+}
+
+// SetProductAttributesCommand replaces a product's attributes.
+type SetProductAttributesCommand struct {
+	ProductID  string              `json:"-" validate:"required"`
+	Attributes []AttributeValueCmd `json:"attributes" validate:"max=100,dive"`
+}
+
+type SetProductAttributesCommandHandler struct {
+	productRepo  product.Repository
+	templateRepo product.AttributeTemplateRepository
+	webhooks     *WebhookPublisher
+}
+
+func NewSetProductAttributesCommandHandler(productRepo product.Repository, templateRepo product.AttributeTemplateRepository, webhooks *WebhookPublisher) *SetProductAttributesCommandHandler {
+	return &SetProductAttributesCommandHandler{
+		productRepo:  productRepo,
+		templateRepo: templateRepo,
+		webhooks:     webhooks,
+	}
+}
+
+func (h *SetProductAttributesCommandHandler) Handle(ctx context.Context, cmd SetProductAttributesCommand) (*product.Product, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *SetProductAttributesCommandHandler) handle(ctx context.Context, cmd SetProductAttributesCommand) (*product.Product, error) {
+	p, err := h.productRepo.GetByID(ctx, cmd.ProductID)
+	if err != nil {
+		return nil, ErrProductNotFound
+	}
+
+	var template []*product.AttributeDefinition
+	if p.CategoryID != "" {
+		if template, err = h.templateRepo.ListByCategory(ctx, p.CategoryID); err != nil {
+			return nil, err
+		}
+	}
+
+	values := make([]product.Attribute, len(cmd.Attributes))
+	for i, a := range cmd.Attributes {
+		values[i] = product.Attribute{Key: a.Key, Value: a.Value, Label: a.Label, Type: a.Type, Unit: a.Unit}
+	}
+	attrs, err := product.ApplyTemplate(template, values)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Attributes = attrs
+	p.UpdatedAt = time.Now()
+	if err := h.productRepo.Update(ctx, p); err != nil {
+		return nil, err
+	}
+	if h.webhooks != nil {
+		if err := h.webhooks.ProductUpdated(ctx, p); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
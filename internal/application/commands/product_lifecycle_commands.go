@@ -0,0 +1,189 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/product"
+)
+
+type SetProductStatusCommand struct {
+	ProductID string         `json:"-" validate:"required"`
+	Status    product.Status `json:"status" validate:"required,oneof=draft active archived"`
+}
+
+type SetProductStatusCommandHandler struct {
+	productRepo product.Repository
+	webhooks    *WebhookPublisher
+}
+
+func NewSetProductStatusCommandHandler(productRepo product.Repository, webhooks *WebhookPublisher) *SetProductStatusCommandHandler {
+	return &SetProductStatusCommandHandler{productRepo: productRepo, webhooks: webhooks}
+}
+
+// Handle moves a product through its lifecycle: publishing a draft,
+// archiving a product or taking it back to draft.
+func (h *SetProductStatusCommandHandler) Handle(ctx context.Context, cmd SetProductStatusCommand) (*product.Product, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *SetProductStatusCommandHandler) handle(ctx context.Context, cmd SetProductStatusCommand) (*product.Product, error) {
+	p, err := h.productRepo.GetByID(ctx, cmd.ProductID)
+	if err != nil {
+		return nil, ErrProductNotFound
+	}
+	if p.Status == cmd.Status {
+		return p, nil
+	}
+
+	if err := p.SetStatus(cmd.Status, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := h.productRepo.Update(ctx, p); err != nil {
+		return nil, err
+	}
+
+	if h.webhooks != nil {
+		if err := h.webhooks.ProductUpdated(ctx, p); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// ScheduleProductPublishCommand sets when a draft is published; a nil
+// PublishAt unschedules it.
+type ScheduleProductPublishCommand struct {
+	ProductID string     `json:"-" validate:"required"`
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+type ScheduleProductPublishCommandHandler struct {
+	productRepo product.Repository
+}
+
+func NewScheduleProductPublishCommandHandler(productRepo product.Repository) *ScheduleProductPublishCommandHandler {
+	return &ScheduleProductPublishCommandHandler{productRepo: productRepo}
+}
+
+func (h *ScheduleProductPublishCommandHandler) Handle(ctx context.Context, cmd ScheduleProductPublishCommand) (*product.Product, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *ScheduleProductPublishCommandHandler) handle(ctx context.Context, cmd ScheduleProductPublishCommand) (*product.Product, error) {
+	p, err := h.productRepo.GetByID(ctx, cmd.ProductID)
+	if err != nil {
+		return nil, ErrProductNotFound
+	}
+
+	if err := p.SchedulePublish(cmd.PublishAt, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := h.productRepo.Update(ctx, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+type DuplicateProductCommand struct {
+	ProductID string `json:"-" validate:"required"`
+	Name      string `json:"name" validate:"max=200"`
+}
+
+type DuplicateProductCommandHandler struct {
+	productRepo product.Repository
+}
+
+func NewDuplicateProductCommandHandler(productRepo product.Repository) *DuplicateProductCommandHandler {
+	return &DuplicateProductCommandHandler{productRepo: productRepo}
+}
+
+// Handle copies a product into a new draft with a slug of its own. The copy
+// starts without stock.
+func (h *DuplicateProductCommandHandler) Handle(ctx context.Context, cmd DuplicateProductCommand) (*product.Product, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *DuplicateProductCommandHandler) handle(ctx context.Context, cmd DuplicateProductCommand) (*product.Product, error) {
+	p, err := h.productRepo.GetByID(ctx, cmd.ProductID)
+	if err != nil || p.Status == product.StatusDeleted {
+		return nil, ErrProductNotFound
+	}
+
+	duplicate := p.Duplicate(cmd.Name, time.Now())
+	if base := product.Slugify(duplicate.Name); base != "" {
+		slug, err := product.UniqueSlug(base, func(slug string) (bool, error) {
+			return h.productRepo.SlugExists(ctx, slug)
+		})
+		if err != nil {
+			return nil, err
+		}
+		duplicate.Slug = slug
+	}
+
+	if err := h.productRepo.Create(ctx, duplicate); err != nil {
+		return nil, err
+	}
+	return duplicate, nil
+}
+
+type PublishScheduledProductsCommand struct {
+	BatchSize int
+}
+
+// NonTransactional: each product is published, and announced to webhooks,
+// on its own, so one failure does not unpublish the rest.
+func (PublishScheduledProductsCommand) NonTransactional() {}
+
+type PublishScheduledProductsCommandHandler struct {
+	productRepo  product.Repository
+	scheduleRepo product.ScheduleRepository
+	webhooks     *WebhookPublisher
+}
+
+func NewPublishScheduledProductsCommandHandler(productRepo product.Repository, scheduleRepo product.ScheduleRepository, webhooks *WebhookPublisher) *PublishScheduledProductsCommandHandler {
+	return &PublishScheduledProductsCommandHandler{productRepo: productRepo, scheduleRepo: scheduleRepo, webhooks: webhooks}
+}
+
+// Handle publishes the drafts whose publish date has come and returns how
+// many it published.
+func (h *PublishScheduledProductsCommandHandler) Handle(ctx context.Context, cmd PublishScheduledProductsCommand) (int, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *PublishScheduledProductsCommandHandler) handle(ctx context.Context, cmd PublishScheduledProductsCommand) (int, error) {
+	if cmd.BatchSize <= 0 {
+		cmd.BatchSize = 100
+	}
+	now := time.Now()
+
+	published := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return published, err
+		}
+		due, err := h.scheduleRepo.ListDueDrafts(ctx, now, cmd.BatchSize)
+		if err != nil {
+			return published, err
+		}
+
+		for _, p := range due {
+			if err := p.SetStatus(product.StatusActive, now); err != nil {
+				return published, err
+			}
+			if err := h.productRepo.Update(ctx, p); err != nil {
+				return published, err
+			}
+			published++
+			if h.webhooks != nil {
+				if err := h.webhooks.ProductUpdated(ctx, p); err != nil {
+					return published, err
+				}
+			}
+		}
+		if len(due) < cmd.BatchSize {
+			return published, nil
+		}
+	}
+}
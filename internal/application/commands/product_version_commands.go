@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/audit"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/productversion"
+	"online-shop/internal/domain/searchsync"
+)
+
+type RollbackProductCommand struct {
+	ProductID string `json:"-" validate:"required"`
+	Version   int    `json:"-" validate:"min=1"`
+	ActorID   string `json:"-"`
+	ActorRole string `json:"-"`
+	RequestID string `json:"-"`
+}
+
+type RollbackProductCommandHandler struct {
+	productRepo  product.Repository
+	categoryRepo product.CategoryRepository
+	versionRepo  productversion.Repository
+	auditRepo    audit.Repository
+	webhooks     *WebhookPublisher
+	// index and productCache are nil when the search index follows the
+	// database through the search sync outbox
+	index        searchsync.Index
+	productCache searchsync.ProductCache
+}
+
+func NewRollbackProductCommandHandler(productRepo product.Repository, categoryRepo product.CategoryRepository, versionRepo productversion.Repository, auditRepo audit.Repository, webhooks *WebhookPublisher, index searchsync.Index, productCache searchsync.ProductCache) *RollbackProductCommandHandler {
+	return &RollbackProductCommandHandler{
+		productRepo:  productRepo,
+		categoryRepo: categoryRepo,
+		versionRepo:  versionRepo,
+		auditRepo:    auditRepo,
+		webhooks:     webhooks,
+		index:        index,
+		productCache: productCache,
+	}
+}
+
+// Handle restores the content of an earlier version on the product, which
+// records it as a new version, and writes the rollback to the audit log.
+// Rolling back to the content the product already has changes nothing.
+func (h *RollbackProductCommandHandler) Handle(ctx context.Context, cmd RollbackProductCommand) (*product.Product, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *RollbackProductCommandHandler) handle(ctx context.Context, cmd RollbackProductCommand) (*product.Product, error) {
+	p, err := h.productRepo.GetByID(ctx, cmd.ProductID)
+	if err != nil || p.Status == product.StatusDeleted {
+		return nil, ErrProductNotFound
+	}
+	target, err := h.versionRepo.Get(ctx, p.ID, cmd.Version)
+	if err != nil {
+		return nil, err
+	}
+	current := productversion.Take(p)
+	if current.Equal(target.Snapshot) {
+		return p, nil
+	}
+	latest, err := h.versionRepo.Latest(ctx, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	// The category may have been deleted since the version
+	if _, err := h.categoryRepo.GetByID(ctx, target.Snapshot.CategoryID); err != nil {
+		return nil, ErrCategoryNotFound
+	}
+
+	target.Snapshot.Apply(p, time.Now())
+	if err := h.productRepo.Update(productversion.WithRollback(ctx, target.Number), p); err != nil {
+		return nil, err
+	}
+
+	entry := audit.NewEntry(audit.SourceCommand, cmd.ActorID, "products.rollback", "products", p.ID)
+	entry.ActorRole = cmd.ActorRole
+	entry.RequestID = cmd.RequestID
+	entry.Changes = productversion.Diff(current, target.Snapshot)
+	entry.Changes["version"] = audit.Change{Before: latest.Number, After: target.Number}
+	if err := h.auditRepo.Create(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	if h.index != nil {
+		// Read again for the category the index carries
+		live, err := h.productRepo.GetByID(ctx, p.ID)
+		if err != nil {
+			return nil, err
+		}
+		if err := h.index.IndexProduct(ctx, live); err != nil {
+			return nil, err
+		}
+		p = live
+	}
+	if h.productCache != nil {
+		if err := h.productCache.InvalidateProduct(ctx, p.ID); err != nil {
+			return nil, err
+		}
+	}
+	if h.webhooks != nil {
+		if err := h.webhooks.ProductUpdated(ctx, p); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,243 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/user"
+)
+
+// SetProductPurchaseLimitsCommand caps how many of a product a customer can
+// buy. Zero lifts a limit.
+type SetProductPurchaseLimitsCommand struct {
+	ProductID      string `json:"-" validate:"required"`
+	MaxPerOrder    int    `json:"max_per_order" validate:"min=0"`
+	MaxPerCustomer int    `json:"max_per_customer" validate:"min=0"`
+}
+
+// SetCategoryMinimumAgeCommand restricts the category's products to
+// customers with a verified age of at least MinimumAge. Zero lifts it.
+type SetCategoryMinimumAgeCommand struct {
+	CategoryID string `json:"-" validate:"required"`
+	MinimumAge int    `json:"minimum_age" validate:"min=0,max=100"`
+}
+
+// VerifyUserAgeCommand records that staff checked the user's birthday
+// against an identity document.
+type VerifyUserAgeCommand struct {
+	UserID string `json:"-" validate:"required"`
+	// Birthday is the date on the document, as 2006-01-02
+	Birthday string `json:"birthday" validate:"required"`
+}
+
+// PurchaseRules are what a customer may buy: the purchase limits of
+// products, the minimum age of restricted categories, and the minimum value
+// of an order. The cart checks them as it is filled, as far as it can for
+// anonymous visitors, and checkout checks them all.
+type PurchaseRules struct {
+	userRepo      user.Repository
+	history       order.PurchaseHistory
+	minOrderValue float64
+}
+
+func NewPurchaseRules(userRepo user.Repository, history order.PurchaseHistory, minOrderValue float64) *PurchaseRules {
+	return &PurchaseRules{userRepo: userRepo, history: history, minOrderValue: minOrderValue}
+}
+
+// checkItem checks the customer can have quantity of the product in their
+// cart. Only the limit per order applies to anonymous visitors, who are
+// checked for the rest when they check out.
+func (r *PurchaseRules) checkItem(ctx context.Context, userID string, p *product.Product, quantity int) error {
+	if r == nil {
+		return nil
+	}
+	bought := 0
+	if userID != "" {
+		if p.MinimumAge() > 0 {
+			u, err := r.userRepo.GetByID(ctx, userID)
+			if err != nil {
+				return ErrUserNotFound
+			}
+			if err := p.CheckAge(u.VerifiedAge(time.Now())); err != nil {
+				return err
+			}
+		}
+		if p.Limits.MaxPerCustomer > 0 {
+			quantities, err := r.history.PurchasedQuantities(ctx, userID, []string{p.ID})
+			if err != nil {
+				return err
+			}
+			bought = quantities[p.ID]
+		}
+	}
+	return p.CheckLimits(quantity, bought)
+}
+
+// checkOrder checks an order before it is placed, with the products of its
+// items and the bundles they were ordered in, whose categories can restrict
+// them too. Orders from quotes and those staff place for customers were
+// agreed with the shop, so only the age restrictions apply to them.
+func (r *PurchaseRules) checkOrder(ctx context.Context, o *order.Order, products map[string]*product.Product, bundles []*product.Product) error {
+	if r == nil {
+		return nil
+	}
+
+	// Items in their order, so the first product at fault is the one named
+	ordered := append([]*product.Product{}, bundles...)
+	quantities := make(map[string]int, len(o.Items))
+	for _, item := range o.Items {
+		if _, seen := quantities[item.ProductID]; !seen {
+			ordered = append(ordered, products[item.ProductID])
+		}
+		quantities[item.ProductID] += item.Quantity
+	}
+
+	if err := r.checkAge(ctx, o.UserID, ordered); err != nil {
+		return err
+	}
+	if o.QuoteID != "" || o.Assisted() {
+		return nil
+	}
+
+	if r.minOrderValue > 0 && o.TotalAmount < r.minOrderValue {
+		return ErrBelowMinimumOrderValue.WithDetail("items must come to at least %.2f before shipping", r.minOrderValue)
+	}
+
+	var limited []string
+	for id, p := range products {
+		if p.Limits.MaxPerCustomer > 0 {
+			limited = append(limited, id)
+		}
+	}
+	bought := map[string]int{}
+	if len(limited) > 0 {
+		var err error
+		if bought, err = r.history.PurchasedQuantities(ctx, o.UserID, limited); err != nil {
+			return err
+		}
+	}
+	for _, p := range ordered {
+		if p.IsBundle() {
+			continue
+		}
+		if err := p.CheckLimits(quantities[p.ID], bought[p.ID]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *PurchaseRules) checkAge(ctx context.Context, userID string, products []*product.Product) error {
+	restricted := false
+	for _, p := range products {
+		if p.MinimumAge() > 0 {
+			restricted = true
+		}
+	}
+	if !restricted {
+		return nil
+	}
+
+	u, err := r.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	age, verified := u.VerifiedAge(time.Now())
+	for _, p := range products {
+		if err := p.CheckAge(age, verified); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type SetProductPurchaseLimitsCommandHandler struct {
+	productRepo product.Repository
+}
+
+func NewSetProductPurchaseLimitsCommandHandler(productRepo product.Repository) *SetProductPurchaseLimitsCommandHandler {
+	return &SetProductPurchaseLimitsCommandHandler{productRepo: productRepo}
+}
+
+func (h *SetProductPurchaseLimitsCommandHandler) Handle(ctx context.Context, cmd SetProductPurchaseLimitsCommand) (*product.Product, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *SetProductPurchaseLimitsCommandHandler) handle(ctx context.Context, cmd SetProductPurchaseLimitsCommand) (*product.Product, error) {
+	p, err := h.productRepo.GetByID(ctx, cmd.ProductID)
+	if err != nil {
+		return nil, ErrProductNotFound
+	}
+
+	limits := product.PurchaseLimits{MaxPerOrder: cmd.MaxPerOrder, MaxPerCustomer: cmd.MaxPerCustomer}
+	if err := p.SetLimits(limits, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := h.productRepo.Update(ctx, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+type SetCategoryMinimumAgeCommandHandler struct {
+	categoryRepo product.CategoryRepository
+}
+
+func NewSetCategoryMinimumAgeCommandHandler(categoryRepo product.CategoryRepository) *SetCategoryMinimumAgeCommandHandler {
+	return &SetCategoryMinimumAgeCommandHandler{categoryRepo: categoryRepo}
+}
+
+func (h *SetCategoryMinimumAgeCommandHandler) Handle(ctx context.Context, cmd SetCategoryMinimumAgeCommand) (*product.Category, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *SetCategoryMinimumAgeCommandHandler) handle(ctx context.Context, cmd SetCategoryMinimumAgeCommand) (*product.Category, error) {
+	c, err := h.categoryRepo.GetByID(ctx, cmd.CategoryID)
+	if err != nil {
+		return nil, ErrCategoryNotFound
+	}
+
+	if err := c.SetMinimumAge(cmd.MinimumAge, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := h.categoryRepo.Update(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+type VerifyUserAgeCommandHandler struct {
+	userRepo user.Repository
+}
+
+func NewVerifyUserAgeCommandHandler(userRepo user.Repository) *VerifyUserAgeCommandHandler {
+	return &VerifyUserAgeCommandHandler{userRepo: userRepo}
+}
+
+// Handle sets the user's birthday to the verified one. The verification
+// lapses if the user changes their birthday.
+func (h *VerifyUserAgeCommandHandler) Handle(ctx context.Context, cmd VerifyUserAgeCommand) (*user.User, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *VerifyUserAgeCommandHandler) handle(ctx context.Context, cmd VerifyUserAgeCommand) (*user.User, error) {
+	u, err := h.userRepo.GetByID(ctx, cmd.UserID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	birthday, err := time.Parse(user.BirthdayLayout, cmd.Birthday)
+	if err != nil {
+		return nil, ErrInvalidProfile.WithDetail("birthday must be a date like %s", user.BirthdayLayout)
+	}
+	if err := u.VerifyAge(birthday, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := h.userRepo.Update(ctx, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
@@ -0,0 +1,315 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/quote"
+)
+
+type QuoteItemCmd struct {
+	ProductID string `json:"product_id" validate:"required"`
+	Quantity  int    `json:"quantity" validate:"required,min=1,max=1000"`
+}
+
+// RequestQuoteCommand asks the merchant of the products for a price on
+// them.
+type RequestQuoteCommand struct {
+	UserID  string         `json:"-" validate:"required"`
+	Company string         `json:"company" validate:"required,notblank,max=200"`
+	Items   []QuoteItemCmd `json:"items" validate:"required,min=1,max=100,dive"`
+	Note    string         `json:"note" validate:"max=2000"`
+}
+
+type RequestQuoteCommandHandler struct {
+	quoteRepo   quote.Repository
+	productRepo product.Repository
+	webhooks    *WebhookPublisher
+	minQuantity int
+}
+
+// NewRequestQuoteCommandHandler takes requests for at least minQuantity
+// units across their items.
+func NewRequestQuoteCommandHandler(quoteRepo quote.Repository, productRepo product.Repository, webhooks *WebhookPublisher, minQuantity int) *RequestQuoteCommandHandler {
+	return &RequestQuoteCommandHandler{
+		quoteRepo:   quoteRepo,
+		productRepo: productRepo,
+		webhooks:    webhooks,
+		minQuantity: minQuantity,
+	}
+}
+
+func (h *RequestQuoteCommandHandler) Handle(ctx context.Context, cmd RequestQuoteCommand) (*quote.Quote, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *RequestQuoteCommandHandler) handle(ctx context.Context, cmd RequestQuoteCommand) (*quote.Quote, error) {
+	ids := make([]string, len(cmd.Items))
+	for i, item := range cmd.Items {
+		ids[i] = item.ProductID
+	}
+	products, err := h.productRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*product.Product, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+
+	// A quote is priced by one merchant; stock is only checked once it is
+	// converted into an order
+	items := make([]quote.Item, len(cmd.Items))
+	merchantID := ""
+	for i, item := range cmd.Items {
+		p, ok := byID[item.ProductID]
+		if !ok || p.Status != product.StatusActive {
+			return nil, ErrProductNotFound
+		}
+		if i == 0 {
+			merchantID = p.MerchantID
+		} else if p.MerchantID != merchantID {
+			return nil, ErrInvalidQuoteRequest.WithDetail("a quote is for the products of one merchant")
+		}
+		items[i] = quote.Item{ProductID: p.ID, Quantity: item.Quantity, ListPrice: p.Price}
+	}
+
+	q, err := quote.NewRequest(cmd.UserID, merchantID, cmd.Company, items, cmd.Note, h.minQuantity)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.quoteRepo.Create(ctx, q); err != nil {
+		return nil, err
+	}
+
+	if h.webhooks != nil {
+		if err := h.webhooks.QuoteRequested(ctx, q); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+type QuotePriceCmd struct {
+	ProductID string  `json:"product_id" validate:"required"`
+	Price     float64 `json:"price" validate:"required,gt=0"`
+}
+
+// OfferQuoteCommand prices a quote, or prices it again. MerchantID is empty
+// for admins, who can price any quote.
+type OfferQuoteCommand struct {
+	QuoteID    string          `json:"-" validate:"required"`
+	MerchantID string          `json:"-"`
+	Prices     []QuotePriceCmd `json:"prices" validate:"required,min=1,max=100,dive"`
+	// ValidUntil defaults to the configured validity
+	ValidUntil *time.Time `json:"valid_until"`
+	Response   string     `json:"response" validate:"max=2000"`
+}
+
+type OfferQuoteCommandHandler struct {
+	quoteRepo   quote.Repository
+	validity    time.Duration
+	maxValidity time.Duration
+}
+
+// NewOfferQuoteCommandHandler holds offers for validity unless merchants
+// say otherwise, and for no longer than maxValidity.
+func NewOfferQuoteCommandHandler(quoteRepo quote.Repository, validity, maxValidity time.Duration) *OfferQuoteCommandHandler {
+	return &OfferQuoteCommandHandler{
+		quoteRepo:   quoteRepo,
+		validity:    validity,
+		maxValidity: maxValidity,
+	}
+}
+
+func (h *OfferQuoteCommandHandler) Handle(ctx context.Context, cmd OfferQuoteCommand) (*quote.Quote, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *OfferQuoteCommandHandler) handle(ctx context.Context, cmd OfferQuoteCommand) (*quote.Quote, error) {
+	q, err := merchantQuote(ctx, h.quoteRepo, cmd.QuoteID, cmd.MerchantID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	validUntil := now.Add(h.validity)
+	if cmd.ValidUntil != nil {
+		validUntil = *cmd.ValidUntil
+	}
+	if validUntil.After(now.Add(h.maxValidity)) {
+		return nil, ErrInvalidQuoteOffer.WithDetail("offers are valid for at most %d days", int(h.maxValidity/(24*time.Hour)))
+	}
+
+	prices := make(map[string]float64, len(cmd.Prices))
+	for _, p := range cmd.Prices {
+		prices[p.ProductID] = p.Price
+	}
+	if err := q.Offer(prices, validUntil, cmd.Response, now); err != nil {
+		return nil, err
+	}
+
+	if err := h.quoteRepo.Update(ctx, q); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// DeclineQuoteCommand turns a request for a quote down. MerchantID is empty
+// for admins.
+type DeclineQuoteCommand struct {
+	QuoteID    string `json:"-" validate:"required"`
+	MerchantID string `json:"-"`
+	Response   string `json:"response" validate:"max=2000"`
+}
+
+type DeclineQuoteCommandHandler struct {
+	quoteRepo quote.Repository
+}
+
+func NewDeclineQuoteCommandHandler(quoteRepo quote.Repository) *DeclineQuoteCommandHandler {
+	return &DeclineQuoteCommandHandler{quoteRepo: quoteRepo}
+}
+
+func (h *DeclineQuoteCommandHandler) Handle(ctx context.Context, cmd DeclineQuoteCommand) (*quote.Quote, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *DeclineQuoteCommandHandler) handle(ctx context.Context, cmd DeclineQuoteCommand) (*quote.Quote, error) {
+	q, err := merchantQuote(ctx, h.quoteRepo, cmd.QuoteID, cmd.MerchantID)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.Decline(cmd.Response, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := h.quoteRepo.Update(ctx, q); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// merchantQuote loads a quote the merchant prices; other merchants' quotes
+// are not found. An empty merchantID loads any quote.
+func merchantQuote(ctx context.Context, quoteRepo quote.Repository, quoteID, merchantID string) (*quote.Quote, error) {
+	q, err := quoteRepo.GetByID(ctx, quoteID)
+	if err != nil {
+		return nil, err
+	}
+	if merchantID != "" && q.MerchantID != merchantID {
+		return nil, ErrQuoteNotFound
+	}
+	return q, nil
+}
+
+// CancelQuoteCommand withdraws a request for a quote, or turns its offer
+// down.
+type CancelQuoteCommand struct {
+	QuoteID string `json:"-" validate:"required"`
+	UserID  string `json:"-" validate:"required"`
+}
+
+type CancelQuoteCommandHandler struct {
+	quoteRepo quote.Repository
+}
+
+func NewCancelQuoteCommandHandler(quoteRepo quote.Repository) *CancelQuoteCommandHandler {
+	return &CancelQuoteCommandHandler{quoteRepo: quoteRepo}
+}
+
+func (h *CancelQuoteCommandHandler) Handle(ctx context.Context, cmd CancelQuoteCommand) (*quote.Quote, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *CancelQuoteCommandHandler) handle(ctx context.Context, cmd CancelQuoteCommand) (*quote.Quote, error) {
+	q, err := h.quoteRepo.GetByID(ctx, cmd.QuoteID)
+	if err != nil {
+		return nil, err
+	}
+	if q.UserID != cmd.UserID {
+		return nil, ErrForbidden
+	}
+	if err := q.Cancel(time.Now()); err != nil {
+		return nil, err
+	}
+	if err := h.quoteRepo.Update(ctx, q); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// AcceptQuoteCommand converts an offered quote into an order at its prices.
+type AcceptQuoteCommand struct {
+	QuoteID         string         `json:"-" validate:"required"`
+	UserID          string         `json:"-" validate:"required"`
+	ShippingAddress order.Address  `json:"shipping_address" validate:"required"`
+	BillingAddress  *order.Address `json:"billing_address,omitempty"`
+	ShippingRateID  string         `json:"shipping_rate_id,omitempty"`
+}
+
+type AcceptQuoteCommandHandler struct {
+	quoteRepo          quote.Repository
+	createOrderHandler *CreateOrderCommandHandler
+	webhooks           *WebhookPublisher
+}
+
+func NewAcceptQuoteCommandHandler(quoteRepo quote.Repository, createOrderHandler *CreateOrderCommandHandler, webhooks *WebhookPublisher) *AcceptQuoteCommandHandler {
+	return &AcceptQuoteCommandHandler{
+		quoteRepo:          quoteRepo,
+		createOrderHandler: createOrderHandler,
+		webhooks:           webhooks,
+	}
+}
+
+// Handle places the order like any other, so it is checked for stock,
+// charged shipping and screened for fraud, with the quote's prices in place
+// of the products' prices and flash sales. An order that cannot be placed
+// leaves the offer open.
+func (h *AcceptQuoteCommandHandler) Handle(ctx context.Context, cmd AcceptQuoteCommand) (*order.Order, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *AcceptQuoteCommandHandler) handle(ctx context.Context, cmd AcceptQuoteCommand) (*order.Order, error) {
+	q, err := h.quoteRepo.GetByID(ctx, cmd.QuoteID)
+	if err != nil {
+		return nil, err
+	}
+	if q.UserID != cmd.UserID {
+		return nil, ErrForbidden
+	}
+
+	now := time.Now()
+	if err := q.CheckAcceptable(now); err != nil {
+		return nil, err
+	}
+
+	items := make([]CreateOrderItemCmd, len(q.Items))
+	for i, item := range q.Items {
+		items[i] = CreateOrderItemCmd{ProductID: item.ProductID, Quantity: item.Quantity, Price: item.Price}
+	}
+	o, err := h.createOrderHandler.Handle(ctx, CreateOrderCommand{
+		UserID:          q.UserID,
+		Items:           items,
+		ShippingAddress: cmd.ShippingAddress,
+		BillingAddress:  cmd.BillingAddress,
+		ShippingRateID:  cmd.ShippingRateID,
+		QuoteID:         q.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	q.Accepted(o.ID, now)
+	if err := h.quoteRepo.Update(ctx, q); err != nil {
+		return nil, err
+	}
+	if h.webhooks != nil {
+		if err := h.webhooks.QuoteAccepted(ctx, q); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
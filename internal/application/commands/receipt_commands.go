@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/payment"
+	"online-shop/internal/domain/user"
+)
+
+// ReceiptSender emails customers a receipt for each of their payments once
+// it is paid. The payment records when its receipt went out, so it is sent
+// once however often the order's payments are reconciled.
+type ReceiptSender struct {
+	userRepo    user.Repository
+	paymentRepo payment.Repository
+	notifier    payment.ReceiptNotifier
+}
+
+// NewReceiptSender takes a nil notifier when receipts cannot be sent.
+func NewReceiptSender(userRepo user.Repository, paymentRepo payment.Repository, notifier payment.ReceiptNotifier) *ReceiptSender {
+	return &ReceiptSender{userRepo: userRepo, paymentRepo: paymentRepo, notifier: notifier}
+}
+
+// send emails the receipts due for the payments of the summary. Like the
+// order confirmation, a receipt that cannot be sent does not undo the
+// payment; it is tried again when the order's payments are next
+// reconciled.
+func (s *ReceiptSender) send(ctx context.Context, o *order.Order, summary *payment.Summary) {
+	if s == nil || s.notifier == nil {
+		return
+	}
+
+	var customer *user.User
+	for _, pay := range summary.Payments {
+		if !pay.ReceiptDue() {
+			continue
+		}
+		if customer == nil {
+			u, err := s.userRepo.GetByID(ctx, o.UserID)
+			if err != nil {
+				return
+			}
+			customer = u
+		}
+
+		err := s.notifier.PaymentReceived(ctx, payment.ReceiptNotice{
+			Receipt:      payment.NewReceipt(pay, o.Number),
+			Email:        customer.Email,
+			CustomerName: strings.TrimSpace(customer.FirstName + " " + customer.LastName),
+			Locale:       customer.Locale,
+			Outstanding:  summary.Outstanding,
+		})
+		if err != nil {
+			continue
+		}
+		pay.MarkReceiptSent(time.Now())
+		_ = s.paymentRepo.Update(ctx, pay)
+	}
+}
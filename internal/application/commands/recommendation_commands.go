@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/recommendation"
+)
+
+type RefreshBoughtTogetherCommand struct {
+	LookbackDays  int
+	MinSupport    int
+	MaxPerProduct int
+}
+
+// NonTransactional, as the pairs are rebuilt from months of orders in one
+// long scan.
+func (RefreshBoughtTogetherCommand) NonTransactional() {}
+
+type RefreshBoughtTogetherCommandHandler struct {
+	recommendationRepo recommendation.Repository
+}
+
+func NewRefreshBoughtTogetherCommandHandler(recommendationRepo recommendation.Repository) *RefreshBoughtTogetherCommandHandler {
+	return &RefreshBoughtTogetherCommandHandler{recommendationRepo: recommendationRepo}
+}
+
+// Handle rebuilds the frequently-bought-together pairs from recent orders and
+// returns how many pairs were stored.
+func (h *RefreshBoughtTogetherCommandHandler) Handle(ctx context.Context, cmd RefreshBoughtTogetherCommand) (int, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *RefreshBoughtTogetherCommandHandler) handle(ctx context.Context, cmd RefreshBoughtTogetherCommand) (int, error) {
+	if cmd.LookbackDays <= 0 {
+		cmd.LookbackDays = 90
+	}
+	if cmd.MinSupport <= 0 {
+		cmd.MinSupport = 2
+	}
+
+	since := time.Now().AddDate(0, 0, -cmd.LookbackDays)
+	baskets, err := h.recommendationRepo.Baskets(ctx, since)
+	if err != nil {
+		return 0, err
+	}
+
+	pairs := recommendation.MinePairs(baskets, cmd.MinSupport, cmd.MaxPerProduct)
+	if err := h.recommendationRepo.ReplacePairs(ctx, pairs); err != nil {
+		return 0, err
+	}
+
+	return len(pairs), nil
+}
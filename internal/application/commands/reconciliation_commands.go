@@ -0,0 +1,217 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/payment"
+	"online-shop/internal/domain/reconciliation"
+)
+
+// TriggerReconciliationCommand reconciles one UTC day again, yesterday
+// when Date is empty.
+type TriggerReconciliationCommand struct {
+	Date        string `json:"date" validate:"omitempty,datetime=2006-01-02"`
+	RequestedBy string `json:"-"`
+}
+
+type TriggerReconciliationCommandHandler struct {
+	runRepo   reconciliation.Repository
+	provider  string
+	scheduler reconciliation.Scheduler
+}
+
+func NewTriggerReconciliationCommandHandler(runRepo reconciliation.Repository, provider string, scheduler reconciliation.Scheduler) *TriggerReconciliationCommandHandler {
+	return &TriggerReconciliationCommandHandler{
+		runRepo:   runRepo,
+		provider:  provider,
+		scheduler: scheduler,
+	}
+}
+
+// Handle records a pending run and wakes the reconciliation job; progress is
+// polled through the run's status.
+func (h *TriggerReconciliationCommandHandler) Handle(ctx context.Context, cmd TriggerReconciliationCommand) (*reconciliation.Run, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *TriggerReconciliationCommandHandler) handle(ctx context.Context, cmd TriggerReconciliationCommand) (*reconciliation.Run, error) {
+	today, _ := reconciliation.Day(time.Now())
+	day := today.AddDate(0, 0, -1)
+	if cmd.Date != "" {
+		parsed, err := time.Parse("2006-01-02", cmd.Date)
+		if err != nil {
+			return nil, fmt.Errorf("%w: date must be YYYY-MM-DD", reconciliation.ErrInvalidPeriod)
+		}
+		day = parsed
+	}
+	if !day.Before(today) {
+		return nil, fmt.Errorf("%w: only days that have ended can be reconciled", reconciliation.ErrInvalidPeriod)
+	}
+
+	latest, err := h.runRepo.LatestForPeriod(ctx, day)
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil && latest.IsActive() {
+		return nil, ErrReconciliationInProgress
+	}
+
+	run := reconciliation.NewRun(h.provider, reconciliation.TriggerManual, cmd.RequestedBy, day)
+	if err := h.runRepo.Create(ctx, run); err != nil {
+		return nil, err
+	}
+
+	if err := h.scheduler.Trigger(reconciliation.JobName); err != nil {
+		run.Fail(err)
+		h.runRepo.Update(ctx, run)
+		return nil, err
+	}
+
+	return run, nil
+}
+
+// RunReconciliationCommand runs pending reconciliations. With Nightly set,
+// yesterday is reconciled once Delay has passed since midnight UTC, which
+// gives the provider time to settle the day's last transactions.
+type RunReconciliationCommand struct {
+	Nightly bool
+	Delay   time.Duration
+	Timeout time.Duration
+}
+
+// NonTransactional, as the run waits on the provider's settlement reports
+// for up to its Timeout.
+func (RunReconciliationCommand) NonTransactional() {}
+
+type RunReconciliationCommandHandler struct {
+	runRepo     reconciliation.Repository
+	paymentRepo payment.Repository
+	source      reconciliation.Source
+}
+
+func NewRunReconciliationCommandHandler(runRepo reconciliation.Repository, paymentRepo payment.Repository, source reconciliation.Source) *RunReconciliationCommandHandler {
+	return &RunReconciliationCommandHandler{
+		runRepo:     runRepo,
+		paymentRepo: paymentRepo,
+		source:      source,
+	}
+}
+
+// Handle runs every pending reconciliation and, when the nightly run for
+// yesterday is due and has not succeeded, starts it. A failed nightly run is
+// retried the next time the job runs. It returns the runs it ran.
+func (h *RunReconciliationCommandHandler) Handle(ctx context.Context, cmd RunReconciliationCommand) ([]*reconciliation.Run, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *RunReconciliationCommandHandler) handle(ctx context.Context, cmd RunReconciliationCommand) ([]*reconciliation.Run, error) {
+	if cmd.Timeout <= 0 {
+		cmd.Timeout = 30 * time.Minute
+	}
+
+	if err := h.failAbandoned(ctx, cmd.Timeout); err != nil {
+		return nil, err
+	}
+
+	pending, err := h.runRepo.ListByStatus(ctx, reconciliation.StatusPending)
+	if err != nil {
+		return nil, err
+	}
+
+	today, _ := reconciliation.Day(time.Now())
+	if cmd.Nightly && time.Since(today) >= cmd.Delay {
+		yesterday := today.AddDate(0, 0, -1)
+		latest, err := h.runRepo.LatestForPeriod(ctx, yesterday)
+		if err != nil {
+			return nil, err
+		}
+		if latest == nil || latest.Status == reconciliation.StatusFailed {
+			run := reconciliation.NewRun(h.source.Provider(), reconciliation.TriggerScheduled, "", yesterday)
+			if err := h.runRepo.Create(ctx, run); err != nil {
+				return nil, err
+			}
+			pending = append(pending, run)
+		}
+	}
+
+	var firstErr error
+	for _, run := range pending {
+		if err := h.run(run, cmd.Timeout); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return pending, firstErr
+}
+
+// run is bounded by its own timeout rather than the caller's context, so a
+// run started from a request that goes away still finishes and is recorded.
+func (h *RunReconciliationCommandHandler) run(run *reconciliation.Run, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	run.Start()
+	if err := h.runRepo.Update(ctx, run); err != nil {
+		return err
+	}
+
+	created, err := h.paymentRepo.ListCreatedBetween(ctx, run.PeriodStart, run.PeriodEnd)
+	if err != nil {
+		return h.fail(ctx, run, err)
+	}
+	// Cash on delivery, invoices and payments taken offline never reach the
+	// provider; they are reconciled with the couriers' remittances and the
+	// bank transfers instead
+	payments := make([]*payment.Payment, 0, len(created))
+	for _, p := range created {
+		if p.Method != payment.MethodCashOnDelivery && p.Method != payment.MethodInvoice && p.Method != payment.MethodOffline {
+			payments = append(payments, p)
+		}
+	}
+	settlements, err := h.source.Settlements(ctx, run.PeriodStart, run.PeriodEnd, payments)
+	if err != nil {
+		return h.fail(ctx, run, err)
+	}
+
+	// A payment that cannot be read is reported as orphaned rather than
+	// failing the run, so whoever reads the report looks at it either way
+	result := reconciliation.Reconcile(payments, settlements, func(externalID string) *payment.Payment {
+		p, err := h.paymentRepo.GetByExternalID(ctx, externalID)
+		if err != nil {
+			return nil
+		}
+		return p
+	})
+
+	run.Complete(result)
+	return h.runRepo.Update(ctx, run)
+}
+
+func (h *RunReconciliationCommandHandler) fail(ctx context.Context, run *reconciliation.Run, err error) error {
+	run.Fail(err)
+	if updateErr := h.runRepo.Update(ctx, run); updateErr != nil {
+		return updateErr
+	}
+	return fmt.Errorf("reconciliation %s failed: %w", run.ID, err)
+}
+
+// failAbandoned marks runs left running by a process that died as failed,
+// otherwise they would block their day from being reconciled again.
+func (h *RunReconciliationCommandHandler) failAbandoned(ctx context.Context, timeout time.Duration) error {
+	running, err := h.runRepo.ListByStatus(ctx, reconciliation.StatusRunning)
+	if err != nil {
+		return err
+	}
+	for _, run := range running {
+		if run.StartedAt != nil && time.Since(*run.StartedAt) < timeout {
+			continue
+		}
+		run.Fail(fmt.Errorf("reconciliation did not finish within %s", timeout))
+		if err := h.runRepo.Update(ctx, run); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/analytics"
+	"online-shop/internal/domain/cache"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/searchsync"
+)
+
+// trendingReplayWindow is how far back the trending ranking reads its
+// hourly buckets; older events are not scored again
+const trendingReplayWindow = 24 * time.Hour
+
+// ReplayEventsCommand rebuilds projections from the product events stored
+// in [From, To): every event, or those of one product, order or user. All
+// projections are rebuilt when none are named, except trending for one
+// order or user. With DryRun the events are read and counted and nothing is
+// written.
+type ReplayEventsCommand struct {
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	ProductID   string    `json:"product_id,omitempty"`
+	OrderID     string    `json:"order_id,omitempty"`
+	UserID      string    `json:"user_id,omitempty"`
+	Projections []string  `json:"projections,omitempty"`
+	DryRun      bool      `json:"dry_run"`
+	BatchSize   int       `json:"batch_size,omitempty"`
+}
+
+// ReplayEventsResult tells what a replay did, or would do in a dry run
+type ReplayEventsResult struct {
+	DryRun       bool           `json:"dry_run"`
+	Projections  []string       `json:"projections"`
+	From         time.Time      `json:"from"`
+	To           time.Time      `json:"to"`
+	Events       int            `json:"events"`
+	EventsByName map[string]int `json:"events_by_name"`
+	Products     int            `json:"products"`
+	// Indexed and Unindexed count the products written to and removed from
+	// the search index
+	Indexed   int `json:"indexed"`
+	Unindexed int `json:"unindexed"`
+	// Scored counts the events added to the trending ranking
+	Scored      int `json:"scored"`
+	Invalidated int `json:"invalidated"`
+}
+
+type ReplayEventsCommandHandler struct {
+	events       analytics.EventReader
+	productRepo  product.Repository
+	index        searchsync.Index
+	trending     product.TrendingRepository
+	productCache searchsync.ProductCache
+	invalidator  cache.Invalidator
+}
+
+func NewReplayEventsCommandHandler(events analytics.EventReader, productRepo product.Repository, index searchsync.Index, trending product.TrendingRepository, productCache searchsync.ProductCache, invalidator cache.Invalidator) *ReplayEventsCommandHandler {
+	return &ReplayEventsCommandHandler{
+		events:       events,
+		productRepo:  productRepo,
+		index:        index,
+		trending:     trending,
+		productCache: productCache,
+		invalidator:  invalidator,
+	}
+}
+
+// Handle pages through the events oldest first. The products they refer to
+// are indexed as the database has them now, or removed from the index once
+// deleted, and their cached copies and the listings are invalidated. The
+// trending scores of the replayed hours are dropped, for the product when
+// one is named, and built again from the events; the hours are taken whole,
+// so the range is widened to the hour.
+func (h *ReplayEventsCommandHandler) Handle(ctx context.Context, cmd ReplayEventsCommand) (*ReplayEventsResult, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *ReplayEventsCommandHandler) handle(ctx context.Context, cmd ReplayEventsCommand) (*ReplayEventsResult, error) {
+	if cmd.BatchSize <= 0 {
+		cmd.BatchSize = 1000
+	}
+	projections, err := replayProjections(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := analytics.ReplayFilter{
+		From:      cmd.From,
+		To:        cmd.To,
+		EventType: analytics.EventTypeProduct,
+		ProductID: cmd.ProductID,
+		OrderID:   cmd.OrderID,
+		UserID:    cmd.UserID,
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var trendingFrom time.Time
+	if projections[analytics.ProjectionTrending] {
+		filter.From = filter.From.Truncate(time.Hour)
+		if to := filter.To.Truncate(time.Hour); to.Before(filter.To) {
+			filter.To = to.Add(time.Hour)
+		}
+		trendingFrom = filter.From
+		if oldest := now.Add(-trendingReplayWindow).Truncate(time.Hour); trendingFrom.Before(oldest) {
+			trendingFrom = oldest
+		}
+		if !cmd.DryRun && trendingFrom.Before(filter.To) {
+			if err := h.trending.Forget(ctx, trendingFrom, filter.To, cmd.ProductID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	result := &ReplayEventsResult{
+		DryRun:       cmd.DryRun,
+		From:         filter.From,
+		To:           filter.To,
+		EventsByName: make(map[string]int),
+	}
+	for _, name := range analytics.Projections {
+		if projections[name] {
+			result.Projections = append(result.Projections, name)
+		}
+	}
+
+	var productIDs []string
+	seen := make(map[string]bool)
+	var after *analytics.Event
+	for {
+		events, err := h.events.ListAfter(ctx, filter, after, cmd.BatchSize)
+		if err != nil {
+			return result, err
+		}
+		for _, e := range events {
+			result.Events++
+			result.EventsByName[e.EventName]++
+			if id := e.ProductID(); id != "" && !seen[id] {
+				seen[id] = true
+				productIDs = append(productIDs, id)
+			}
+
+			score, ok := e.TrendingScore()
+			if !projections[analytics.ProjectionTrending] || !ok || e.Timestamp.Before(trendingFrom) {
+				continue
+			}
+			result.Scored++
+			if cmd.DryRun {
+				continue
+			}
+			if err := h.trending.Record(ctx, e.ProductID(), score, e.Timestamp); err != nil {
+				return result, err
+			}
+		}
+		if len(events) < cmd.BatchSize {
+			break
+		}
+		after = &events[len(events)-1]
+	}
+	result.Products = len(productIDs)
+
+	if projections[analytics.ProjectionTrending] && !cmd.DryRun && result.Scored > 0 {
+		if err := h.trending.Refresh(ctx, now); err != nil {
+			return result, err
+		}
+	}
+
+	if projections[analytics.ProjectionSearch] || projections[analytics.ProjectionCache] {
+		if err := h.replayProducts(ctx, cmd.DryRun, projections, productIDs, result); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// replayProducts brings the search index and the caches in step with the
+// products, 200 at a time
+func (h *ReplayEventsCommandHandler) replayProducts(ctx context.Context, dryRun bool, projections map[string]bool, productIDs []string, result *ReplayEventsResult) error {
+	for start := 0; start < len(productIDs); start += 200 {
+		end := start + 200
+		if end > len(productIDs) {
+			end = len(productIDs)
+		}
+		ids := productIDs[start:end]
+
+		found, err := h.productRepo.GetByIDs(ctx, ids)
+		if err != nil {
+			return err
+		}
+		byID := make(map[string]*product.Product, len(found))
+		for _, p := range found {
+			byID[p.ID] = p
+		}
+
+		for _, id := range ids {
+			if projections[analytics.ProjectionSearch] {
+				p := byID[id]
+				if p == nil || p.Status == product.StatusDeleted {
+					result.Unindexed++
+					if !dryRun {
+						err = h.index.DeleteProduct(ctx, id)
+					}
+				} else {
+					result.Indexed++
+					if !dryRun {
+						err = h.index.IndexProduct(ctx, p)
+					}
+				}
+				if err != nil {
+					return err
+				}
+			}
+			if projections[analytics.ProjectionCache] {
+				result.Invalidated++
+				if dryRun {
+					continue
+				}
+				if err := h.productCache.InvalidateProduct(ctx, id); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if !projections[analytics.ProjectionCache] || dryRun || len(productIDs) == 0 {
+		return nil
+	}
+	for _, pattern := range searchsync.ListingPatterns {
+		if _, err := h.invalidator.DeletePattern(ctx, pattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replayProjections resolves the projections a replay rebuilds
+func replayProjections(cmd ReplayEventsCommand) (map[string]bool, error) {
+	oneOrderOrUser := cmd.OrderID != "" || cmd.UserID != ""
+	projections := make(map[string]bool, len(analytics.Projections))
+	if len(cmd.Projections) == 0 {
+		for _, name := range analytics.Projections {
+			projections[name] = name != analytics.ProjectionTrending || !oneOrderOrUser
+		}
+		return projections, nil
+	}
+
+	known := make(map[string]bool, len(analytics.Projections))
+	for _, name := range analytics.Projections {
+		known[name] = true
+	}
+	for _, name := range cmd.Projections {
+		if !known[name] {
+			return nil, fmt.Errorf("%w: %s", analytics.ErrUnknownProjection, name)
+		}
+		if name == analytics.ProjectionTrending && oneOrderOrUser {
+			return nil, analytics.ErrTrendingNotRebuildable
+		}
+		projections[name] = true
+	}
+	return projections, nil
+}
@@ -0,0 +1,161 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/cache"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/searchsync"
+)
+
+// SyncSearchCommand applies up to BatchSize captured changes. Each is
+// claimed for Lease first, long enough to be applied, so another instance
+// running the job leaves it alone.
+type SyncSearchCommand struct {
+	BatchSize int
+	Lease     time.Duration
+}
+
+type SyncSearchCommandHandler struct {
+	changeRepo   searchsync.Repository
+	productRepo  product.Repository
+	index        searchsync.Index
+	productCache searchsync.ProductCache
+	invalidator  cache.Invalidator
+}
+
+func NewSyncSearchCommandHandler(changeRepo searchsync.Repository, productRepo product.Repository, index searchsync.Index, productCache searchsync.ProductCache, invalidator cache.Invalidator) *SyncSearchCommandHandler {
+	return &SyncSearchCommandHandler{
+		changeRepo:   changeRepo,
+		productRepo:  productRepo,
+		index:        index,
+		productCache: productCache,
+		invalidator:  invalidator,
+	}
+}
+
+// syncTarget is a row with the changes captured for it
+type syncTarget struct {
+	entity   string
+	entityID string
+}
+
+// Handle applies the due changes, oldest first, and returns how many it
+// applied. A changed product is indexed as the database has it now, or
+// dropped from the index once deleted; a changed category has its products
+// indexed again. The cached products and the listings are invalidated, to
+// be read again. A row changed several times is applied once. Changes that
+// fail are retried after a backoff.
+func (h *SyncSearchCommandHandler) Handle(ctx context.Context, cmd SyncSearchCommand) (int, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *SyncSearchCommandHandler) handle(ctx context.Context, cmd SyncSearchCommand) (int, error) {
+	if cmd.BatchSize <= 0 {
+		cmd.BatchSize = 200
+	}
+	if cmd.Lease <= 0 {
+		cmd.Lease = time.Minute
+	}
+
+	now := time.Now()
+	due, err := h.changeRepo.ListDue(ctx, now, cmd.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var order []syncTarget
+	targets := make(map[syncTarget][]*searchsync.Change)
+	for _, c := range due {
+		claimed, err := h.changeRepo.Claim(ctx, c, now.Add(cmd.Lease))
+		if err != nil {
+			return 0, err
+		}
+		if !claimed {
+			continue
+		}
+		t := syncTarget{entity: c.Entity, entityID: c.EntityID}
+		if _, seen := targets[t]; !seen {
+			order = append(order, t)
+		}
+		targets[t] = append(targets[t], c)
+	}
+
+	var applied []string
+	for _, t := range order {
+		if err := h.apply(ctx, t); err != nil {
+			for _, c := range targets[t] {
+				c.Fail(err, time.Now())
+				if err := h.changeRepo.Update(ctx, c); err != nil {
+					return len(applied), err
+				}
+			}
+			continue
+		}
+		for _, c := range targets[t] {
+			applied = append(applied, c.ID)
+		}
+	}
+	if len(applied) == 0 {
+		return 0, nil
+	}
+
+	for _, pattern := range searchsync.ListingPatterns {
+		if _, err := h.invalidator.DeletePattern(ctx, pattern); err != nil {
+			// The changes are kept, to invalidate the listings again
+			return 0, err
+		}
+	}
+	return len(applied), h.changeRepo.Delete(ctx, applied)
+}
+
+func (h *SyncSearchCommandHandler) apply(ctx context.Context, t syncTarget) error {
+	switch t.entity {
+	case searchsync.EntityProduct:
+		found, err := h.productRepo.GetByIDs(ctx, []string{t.entityID})
+		if err != nil {
+			return err
+		}
+		if len(found) == 0 {
+			return h.syncProduct(ctx, t.entityID, nil)
+		}
+		return h.syncProduct(ctx, t.entityID, found[0])
+	case searchsync.EntityCategory:
+		// Products carry their category's name and slug in the index
+		var after *product.Position
+		for {
+			products, err := h.productRepo.ListAfter(ctx, product.SearchFilter{CategoryID: t.entityID}, after, 200)
+			if err != nil {
+				return err
+			}
+			for _, p := range products {
+				if err := h.syncProduct(ctx, p.ID, p); err != nil {
+					return err
+				}
+			}
+			if len(products) < 200 {
+				return nil
+			}
+			position := product.PositionOf(products[len(products)-1])
+			after = &position
+		}
+	}
+	return nil
+}
+
+// syncProduct indexes the product, or drops it from the index when it is
+// nil or deleted, and invalidates its cached copy
+func (h *SyncSearchCommandHandler) syncProduct(ctx context.Context, productID string, p *product.Product) error {
+	var err error
+	if p == nil || p.Status == product.StatusDeleted {
+		err = h.index.DeleteProduct(ctx, productID)
+	} else {
+		err = h.index.IndexProduct(ctx, p)
+	}
+	if err != nil {
+		return err
+	}
+	return h.productCache.InvalidateProduct(ctx, productID)
+}
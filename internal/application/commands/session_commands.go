@@ -0,0 +1,149 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/session"
+)
+
+type StartSessionCommand struct {
+	UserID    string `json:"user_id"`
+	UserAgent string `json:"user_agent"`
+	IPAddress string `json:"ip_address"`
+}
+
+type StartSessionCommandHandler struct {
+	store session.Store
+	ttl   time.Duration
+}
+
+// NewStartSessionCommandHandler takes the session lifetime, which should match
+// the refresh token lifetime.
+func NewStartSessionCommandHandler(store session.Store, ttl time.Duration) *StartSessionCommandHandler {
+	return &StartSessionCommandHandler{store: store, ttl: ttl}
+}
+
+func (h *StartSessionCommandHandler) Handle(ctx context.Context, cmd StartSessionCommand) (*session.Session, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *StartSessionCommandHandler) handle(ctx context.Context, cmd StartSessionCommand) (*session.Session, error) {
+	sess := session.NewSession(cmd.UserID, cmd.UserAgent, cmd.IPAddress)
+	if err := h.store.Save(ctx, sess, h.ttl); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+type RefreshSessionCommand struct {
+	UserID    string `json:"user_id"`
+	SessionID string `json:"session_id"`
+	UserAgent string `json:"user_agent"`
+	IPAddress string `json:"ip_address"`
+}
+
+type RefreshSessionCommandHandler struct {
+	store session.Store
+	ttl   time.Duration
+}
+
+func NewRefreshSessionCommandHandler(store session.Store, ttl time.Duration) *RefreshSessionCommandHandler {
+	return &RefreshSessionCommandHandler{store: store, ttl: ttl}
+}
+
+// Handle extends the session a refresh token belongs to. A refresh token
+// without a session is rejected as revoked, it must not mint a session of
+// its own.
+func (h *RefreshSessionCommandHandler) Handle(ctx context.Context, cmd RefreshSessionCommand) (*session.Session, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *RefreshSessionCommandHandler) handle(ctx context.Context, cmd RefreshSessionCommand) (*session.Session, error) {
+	if cmd.SessionID == "" {
+		return nil, session.ErrNotFound
+	}
+
+	sess, err := h.store.Get(ctx, cmd.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if sess.UserID != cmd.UserID {
+		return nil, session.ErrNotFound
+	}
+
+	sess.Seen(cmd.IPAddress, time.Now())
+	if err := h.store.Save(ctx, sess, h.ttl); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+type RevokeSessionCommand struct {
+	UserID    string `json:"user_id"`
+	SessionID string `json:"session_id"`
+}
+
+type RevokeSessionCommandHandler struct {
+	store session.Store
+}
+
+func NewRevokeSessionCommandHandler(store session.Store) *RevokeSessionCommandHandler {
+	return &RevokeSessionCommandHandler{store: store}
+}
+
+// Handle revokes one of the user's sessions. Another user's session is
+// reported as not found.
+func (h *RevokeSessionCommandHandler) Handle(ctx context.Context, cmd RevokeSessionCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *RevokeSessionCommandHandler) handle(ctx context.Context, cmd RevokeSessionCommand) error {
+	sess, err := h.store.Get(ctx, cmd.SessionID)
+	if err != nil {
+		return err
+	}
+	if sess.UserID != cmd.UserID {
+		return session.ErrNotFound
+	}
+	return h.store.Delete(ctx, sess)
+}
+
+type RevokeAllSessionsCommand struct {
+	UserID string `json:"user_id"`
+	// KeepSessionID, when set, is left signed in
+	KeepSessionID string `json:"keep_session_id"`
+}
+
+type RevokeAllSessionsCommandHandler struct {
+	store session.Store
+}
+
+func NewRevokeAllSessionsCommandHandler(store session.Store) *RevokeAllSessionsCommandHandler {
+	return &RevokeAllSessionsCommandHandler{store: store}
+}
+
+// Handle returns the number of sessions revoked
+func (h *RevokeAllSessionsCommandHandler) Handle(ctx context.Context, cmd RevokeAllSessionsCommand) (int, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *RevokeAllSessionsCommandHandler) handle(ctx context.Context, cmd RevokeAllSessionsCommand) (int, error) {
+	sessions, err := h.store.ListByUser(ctx, cmd.UserID)
+	if err != nil {
+		return 0, err
+	}
+
+	revoked := 0
+	for _, sess := range sessions {
+		if sess.ID == cmd.KeepSessionID {
+			continue
+		}
+		if err := h.store.Delete(ctx, sess); err != nil {
+			return revoked, err
+		}
+		revoked++
+	}
+	return revoked, nil
+}
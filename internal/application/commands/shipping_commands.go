@@ -0,0 +1,154 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/shipping"
+)
+
+type CreateShippingZoneCommand struct {
+	Name         string                 `json:"name" validate:"required,notblank,max=100"`
+	Countries    []string               `json:"countries" validate:"required,min=1,dive,len=2"`
+	Provinces    []string               `json:"provinces" validate:"dive,notblank,max=100"`
+	PostalRanges []shipping.PostalRange `json:"postal_ranges" validate:"dive"`
+	Rates        []ShippingRateCmd      `json:"rates" validate:"required,min=1,max=20,dive"`
+}
+
+// UpdateShippingZoneCommand edits a zone; rates given replace them all,
+// and those sent with their id keep it.
+type UpdateShippingZoneCommand struct {
+	ZoneID       string                  `json:"-" validate:"required"`
+	Name         *string                 `json:"name" validate:"omitempty,notblank,max=100"`
+	Countries    *[]string               `json:"countries" validate:"omitempty,min=1,dive,len=2"`
+	Provinces    *[]string               `json:"provinces" validate:"omitempty,dive,notblank,max=100"`
+	PostalRanges *[]shipping.PostalRange `json:"postal_ranges" validate:"omitempty,dive"`
+	Rates        *[]ShippingRateCmd      `json:"rates" validate:"omitempty,min=1,max=20,dive"`
+	Active       *bool                   `json:"active"`
+}
+
+type ShippingRateCmd struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name" validate:"required,notblank,max=50"`
+	Fee      float64 `json:"fee" validate:"min=0"`
+	FreeOver float64 `json:"free_over" validate:"min=0"`
+	MinDays  int     `json:"min_days" validate:"min=0"`
+	MaxDays  int     `json:"max_days" validate:"min=0"`
+}
+
+type DeleteShippingZoneCommand struct {
+	ZoneID string `json:"zone_id" validate:"required"`
+}
+
+func shippingRates(cmds []ShippingRateCmd) []shipping.Rate {
+	rates := make([]shipping.Rate, 0, len(cmds))
+	for _, r := range cmds {
+		rates = append(rates, shipping.Rate{
+			ID:       r.ID,
+			Name:     strings.TrimSpace(r.Name),
+			Fee:      r.Fee,
+			FreeOver: r.FreeOver,
+			MinDays:  r.MinDays,
+			MaxDays:  r.MaxDays,
+		})
+	}
+	return rates
+}
+
+func upperAll(values []string) []string {
+	upper := make([]string, len(values))
+	for i, v := range values {
+		upper[i] = strings.ToUpper(strings.TrimSpace(v))
+	}
+	return upper
+}
+
+type CreateShippingZoneCommandHandler struct {
+	zoneRepo shipping.Repository
+}
+
+func NewCreateShippingZoneCommandHandler(zoneRepo shipping.Repository) *CreateShippingZoneCommandHandler {
+	return &CreateShippingZoneCommandHandler{zoneRepo: zoneRepo}
+}
+
+func (h *CreateShippingZoneCommandHandler) Handle(ctx context.Context, cmd CreateShippingZoneCommand) (*shipping.Zone, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *CreateShippingZoneCommandHandler) handle(ctx context.Context, cmd CreateShippingZoneCommand) (*shipping.Zone, error) {
+	z, err := shipping.NewZone(strings.TrimSpace(cmd.Name), upperAll(cmd.Countries), cmd.Provinces, cmd.PostalRanges, shippingRates(cmd.Rates))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.zoneRepo.Create(ctx, z); err != nil {
+		return nil, err
+	}
+	return z, nil
+}
+
+type UpdateShippingZoneCommandHandler struct {
+	zoneRepo shipping.Repository
+}
+
+func NewUpdateShippingZoneCommandHandler(zoneRepo shipping.Repository) *UpdateShippingZoneCommandHandler {
+	return &UpdateShippingZoneCommandHandler{zoneRepo: zoneRepo}
+}
+
+func (h *UpdateShippingZoneCommandHandler) Handle(ctx context.Context, cmd UpdateShippingZoneCommand) (*shipping.Zone, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *UpdateShippingZoneCommandHandler) handle(ctx context.Context, cmd UpdateShippingZoneCommand) (*shipping.Zone, error) {
+	z, err := h.zoneRepo.GetByID(ctx, cmd.ZoneID)
+	if err != nil {
+		return nil, err
+	}
+
+	if cmd.Name != nil {
+		z.Name = strings.TrimSpace(*cmd.Name)
+	}
+	if cmd.Countries != nil {
+		z.Countries = upperAll(*cmd.Countries)
+	}
+	if cmd.Provinces != nil {
+		z.Provinces = *cmd.Provinces
+	}
+	if cmd.PostalRanges != nil {
+		z.PostalRanges = *cmd.PostalRanges
+	}
+	if cmd.Rates != nil {
+		z.SetRates(shippingRates(*cmd.Rates))
+	}
+	if cmd.Active != nil {
+		z.Active = *cmd.Active
+	}
+	if err := z.Check(); err != nil {
+		return nil, err
+	}
+	z.UpdatedAt = time.Now()
+
+	if err := h.zoneRepo.Update(ctx, z); err != nil {
+		return nil, err
+	}
+	return z, nil
+}
+
+type DeleteShippingZoneCommandHandler struct {
+	zoneRepo shipping.Repository
+}
+
+func NewDeleteShippingZoneCommandHandler(zoneRepo shipping.Repository) *DeleteShippingZoneCommandHandler {
+	return &DeleteShippingZoneCommandHandler{zoneRepo: zoneRepo}
+}
+
+// Handle deletes a zone. Orders already placed keep what they were charged.
+func (h *DeleteShippingZoneCommandHandler) Handle(ctx context.Context, cmd DeleteShippingZoneCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *DeleteShippingZoneCommandHandler) handle(ctx context.Context, cmd DeleteShippingZoneCommand) error {
+	return h.zoneRepo.Delete(ctx, cmd.ZoneID)
+}
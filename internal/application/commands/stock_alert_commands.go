@@ -0,0 +1,209 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/stockalert"
+	"online-shop/internal/domain/user"
+)
+
+type SubscribeStockAlertCommand struct {
+	UserID    string             `json:"-" validate:"required"`
+	ProductID string             `json:"product_id" validate:"required"`
+	Channel   stockalert.Channel `json:"channel" validate:"omitempty,oneof=email push sms in-app whatsapp"`
+}
+
+type SubscribeStockAlertCommandHandler struct {
+	subscriptionRepo stockalert.Repository
+	productRepo      product.Repository
+	lifetime         time.Duration
+}
+
+func NewSubscribeStockAlertCommandHandler(subscriptionRepo stockalert.Repository, productRepo product.Repository, lifetime time.Duration) *SubscribeStockAlertCommandHandler {
+	return &SubscribeStockAlertCommandHandler{
+		subscriptionRepo: subscriptionRepo,
+		productRepo:      productRepo,
+		lifetime:         lifetime,
+	}
+}
+
+// Handle subscribes the user to an out of stock product. Subscribing again
+// only changes the channel of the active subscription.
+func (h *SubscribeStockAlertCommandHandler) Handle(ctx context.Context, cmd SubscribeStockAlertCommand) (*stockalert.Subscription, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *SubscribeStockAlertCommandHandler) handle(ctx context.Context, cmd SubscribeStockAlertCommand) (*stockalert.Subscription, error) {
+	if cmd.Channel == "" {
+		cmd.Channel = stockalert.ChannelEmail
+	}
+
+	p, err := h.productRepo.GetByID(ctx, cmd.ProductID)
+	if err != nil || p.Status == product.StatusDeleted {
+		return nil, ErrProductNotFound
+	}
+	if p.IsAvailable() {
+		return nil, ErrProductInStock
+	}
+
+	existing, err := h.subscriptionRepo.FindActive(ctx, cmd.UserID, cmd.ProductID)
+	if err == nil {
+		if existing.Channel != cmd.Channel {
+			existing.Channel = cmd.Channel
+			existing.UpdatedAt = time.Now()
+			if err := h.subscriptionRepo.Update(ctx, existing); err != nil {
+				return nil, err
+			}
+		}
+		return existing, nil
+	}
+	if !errors.Is(err, stockalert.ErrNotFound) {
+		return nil, err
+	}
+
+	s := stockalert.NewSubscription(cmd.UserID, cmd.ProductID, cmd.Channel, h.lifetime)
+	if err := h.subscriptionRepo.Create(ctx, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+type CancelStockAlertCommand struct {
+	UserID         string `json:"-" validate:"required"`
+	SubscriptionID string `json:"-" validate:"required"`
+}
+
+type CancelStockAlertCommandHandler struct {
+	subscriptionRepo stockalert.Repository
+}
+
+func NewCancelStockAlertCommandHandler(subscriptionRepo stockalert.Repository) *CancelStockAlertCommandHandler {
+	return &CancelStockAlertCommandHandler{subscriptionRepo: subscriptionRepo}
+}
+
+func (h *CancelStockAlertCommandHandler) Handle(ctx context.Context, cmd CancelStockAlertCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *CancelStockAlertCommandHandler) handle(ctx context.Context, cmd CancelStockAlertCommand) error {
+	s, err := h.subscriptionRepo.GetByID(ctx, cmd.SubscriptionID)
+	if err != nil {
+		return err
+	}
+	if s.UserID != cmd.UserID {
+		return stockalert.ErrNotFound
+	}
+	if !s.IsActive() {
+		return nil
+	}
+
+	s.Cancel()
+	return h.subscriptionRepo.Update(ctx, s)
+}
+
+// NotifyBackInStockCommand sends alerts for subscribed products that can be
+// bought again. At most BatchSize subscribers of a product are notified per
+// run, earliest subscribers first.
+type NotifyBackInStockCommand struct {
+	BatchSize int
+}
+
+// NonTransactional: a subscription is closed as soon as its alert is sent,
+// so a failure later in the run cannot alert the subscriber twice.
+func (NotifyBackInStockCommand) NonTransactional() {}
+
+type NotifyBackInStockCommandHandler struct {
+	subscriptionRepo stockalert.Repository
+	productRepo      product.Repository
+	userRepo         user.Repository
+	notifier         stockalert.Notifier
+	siteURL          string
+}
+
+func NewNotifyBackInStockCommandHandler(subscriptionRepo stockalert.Repository, productRepo product.Repository, userRepo user.Repository, notifier stockalert.Notifier, siteURL string) *NotifyBackInStockCommandHandler {
+	return &NotifyBackInStockCommandHandler{
+		subscriptionRepo: subscriptionRepo,
+		productRepo:      productRepo,
+		userRepo:         userRepo,
+		notifier:         notifier,
+		siteURL:          siteURL,
+	}
+}
+
+// Handle expires subscriptions that ran out and notifies the subscribers of
+// restocked products. A subscription whose alert could not be queued stays
+// active and is retried on the next run. It returns how many were sent.
+func (h *NotifyBackInStockCommandHandler) Handle(ctx context.Context, cmd NotifyBackInStockCommand) (int, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *NotifyBackInStockCommandHandler) handle(ctx context.Context, cmd NotifyBackInStockCommand) (int, error) {
+	if cmd.BatchSize <= 0 {
+		cmd.BatchSize = 100
+	}
+
+	if _, err := h.subscriptionRepo.ExpireBefore(ctx, time.Now()); err != nil {
+		return 0, err
+	}
+
+	ids, err := h.subscriptionRepo.ListProductIDs(ctx)
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	products, err := h.productRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, p := range products {
+		if !p.IsAvailable() {
+			continue
+		}
+		subscriptions, err := h.subscriptionRepo.ListActiveByProduct(ctx, p.ID, cmd.BatchSize)
+		if err != nil {
+			return sent, err
+		}
+		for _, s := range subscriptions {
+			ok, err := h.notify(ctx, s, p)
+			if err != nil {
+				return sent, fmt.Errorf("stock alert %s: %w", s.ID, err)
+			}
+			if ok {
+				sent++
+			}
+		}
+	}
+	return sent, nil
+}
+
+// notify sends one alert and closes the subscription. Subscriptions of
+// users who are gone are expired without an alert.
+func (h *NotifyBackInStockCommandHandler) notify(ctx context.Context, s *stockalert.Subscription, p *product.Product) (bool, error) {
+	u, err := h.userRepo.GetByID(ctx, s.UserID)
+	if err != nil || u.Status != user.StatusActive {
+		s.Expire()
+		return false, h.subscriptionRepo.Update(ctx, s)
+	}
+
+	alert := stockalert.Alert{
+		Subscription: s,
+		ProductName:  p.Name,
+		ProductURL:   strings.TrimSuffix(h.siteURL, "/") + p.URLPath(),
+		Price:        p.Price,
+		Phone:        u.Phone,
+		Locale:       u.Locale,
+	}
+	if err := h.notifier.BackInStock(ctx, alert); err != nil {
+		return false, err
+	}
+
+	s.Notified()
+	return true, h.subscriptionRepo.Update(ctx, s)
+}
@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/audit"
+	"online-shop/internal/domain/storefront"
+)
+
+// UpdateStorefrontSettingsCommand replaces the storefront settings as a
+// whole; fields left out are cleared.
+type UpdateStorefrontSettingsCommand struct {
+	StoreName         string                `json:"store_name" validate:"required,notblank,max=100"`
+	LogoURL           string                `json:"logo_url" validate:"omitempty,url,max=500"`
+	Theme             StorefrontThemeCmd    `json:"theme"`
+	Currencies        []string              `json:"currencies" validate:"required,min=1,max=20,dive,iso4217"`
+	DefaultCurrency   string                `json:"default_currency" validate:"required,iso4217"`
+	Locales           []string              `json:"locales" validate:"required,min=1,max=20,dive,locale"`
+	DefaultLocale     string                `json:"default_locale" validate:"required,locale"`
+	Contact           StorefrontContactCmd  `json:"contact"`
+	OrderNumberPrefix string                `json:"order_number_prefix" validate:"required,alphanum,max=10"`
+	Checkout          StorefrontCheckoutCmd `json:"checkout"`
+	ActorID           string                `json:"-" validate:"required"`
+	ActorRole         string                `json:"-"`
+	RequestID         string                `json:"-"`
+}
+
+type StorefrontThemeCmd struct {
+	PrimaryColor string `json:"primary_color" validate:"omitempty,hexcolor"`
+	AccentColor  string `json:"accent_color" validate:"omitempty,hexcolor"`
+	FaviconURL   string `json:"favicon_url" validate:"omitempty,url,max=500"`
+}
+
+type StorefrontContactCmd struct {
+	Email   string `json:"email" validate:"omitempty,email"`
+	Phone   string `json:"phone" validate:"omitempty,phone"`
+	Address string `json:"address" validate:"max=500"`
+}
+
+type StorefrontCheckoutCmd struct {
+	AllowOrderNotes        bool   `json:"allow_order_notes"`
+	RequireTermsAcceptance bool   `json:"require_terms_acceptance"`
+	TermsURL               string `json:"terms_url" validate:"omitempty,url,max=500"`
+}
+
+type UpdateStorefrontSettingsCommandHandler struct {
+	repo      storefront.Repository
+	cache     storefront.Cache
+	auditRepo audit.Repository
+}
+
+func NewUpdateStorefrontSettingsCommandHandler(repo storefront.Repository, cache storefront.Cache, auditRepo audit.Repository) *UpdateStorefrontSettingsCommandHandler {
+	return &UpdateStorefrontSettingsCommandHandler{repo: repo, cache: cache, auditRepo: auditRepo}
+}
+
+// Handle saves the settings, clears the cached copy so storefronts read
+// them on their next request, and records the change in the audit log.
+// Order numbers take the new prefix from then on.
+func (h *UpdateStorefrontSettingsCommandHandler) Handle(ctx context.Context, cmd UpdateStorefrontSettingsCommand) (*storefront.Settings, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *UpdateStorefrontSettingsCommandHandler) handle(ctx context.Context, cmd UpdateStorefrontSettingsCommand) (*storefront.Settings, error) {
+	settings := &storefront.Settings{
+		StoreName: strings.TrimSpace(cmd.StoreName),
+		LogoURL:   cmd.LogoURL,
+		Theme: storefront.Theme{
+			PrimaryColor: cmd.Theme.PrimaryColor,
+			AccentColor:  cmd.Theme.AccentColor,
+			FaviconURL:   cmd.Theme.FaviconURL,
+		},
+		Currencies:      cmd.Currencies,
+		DefaultCurrency: cmd.DefaultCurrency,
+		Locales:         cmd.Locales,
+		DefaultLocale:   cmd.DefaultLocale,
+		Contact: storefront.Contact{
+			Email:   cmd.Contact.Email,
+			Phone:   cmd.Contact.Phone,
+			Address: strings.TrimSpace(cmd.Contact.Address),
+		},
+		OrderNumberPrefix: cmd.OrderNumberPrefix,
+		Checkout: storefront.Checkout{
+			AllowOrderNotes:        cmd.Checkout.AllowOrderNotes,
+			RequireTermsAcceptance: cmd.Checkout.RequireTermsAcceptance,
+			TermsURL:               cmd.Checkout.TermsURL,
+		},
+		UpdatedBy: cmd.ActorID,
+		UpdatedAt: time.Now(),
+	}
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+
+	before, err := h.repo.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.repo.Save(ctx, settings); err != nil {
+		return nil, err
+	}
+	if err := h.cache.Clear(ctx); err != nil {
+		return nil, err
+	}
+
+	entry := audit.NewEntry(audit.SourceCommand, cmd.ActorID, "storefront.update", "storefront_settings", storefront.SettingsID)
+	entry.ActorRole = cmd.ActorRole
+	entry.RequestID = cmd.RequestID
+	entry.Changes = map[string]audit.Change{
+		"settings": {Before: before, After: settings},
+	}
+	if err := h.auditRepo.Create(ctx, entry); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
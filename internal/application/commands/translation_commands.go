@@ -0,0 +1,132 @@
+package commands
+
+import (
+	"context"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/product"
+	"online-shop/pkg/i18n"
+)
+
+// SetProductTranslationCommand writes a product's name and description in
+// a locale other than the default one, replacing the translation it had.
+type SetProductTranslationCommand struct {
+	ProductID   string `json:"-" validate:"required"`
+	Locale      string `json:"-" validate:"required,locale"`
+	Name        string `json:"name" validate:"required,notblank,max=200"`
+	Description string `json:"description" validate:"max=5000"`
+	UpdatedBy   string `json:"-"`
+}
+
+type SetProductTranslationCommandHandler struct {
+	productRepo     product.Repository
+	translationRepo product.TranslationRepository
+}
+
+func NewSetProductTranslationCommandHandler(productRepo product.Repository, translationRepo product.TranslationRepository) *SetProductTranslationCommandHandler {
+	return &SetProductTranslationCommandHandler{productRepo: productRepo, translationRepo: translationRepo}
+}
+
+// Handle serves the translation straight away; search indices in the locale
+// follow once the change is synced.
+func (h *SetProductTranslationCommandHandler) Handle(ctx context.Context, cmd SetProductTranslationCommand) (*product.Translation, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *SetProductTranslationCommandHandler) handle(ctx context.Context, cmd SetProductTranslationCommand) (*product.Translation, error) {
+	if cmd.Locale == i18n.DefaultLocale {
+		return nil, ErrDefaultLocaleTranslation
+	}
+	if _, err := h.productRepo.GetByID(ctx, cmd.ProductID); err != nil {
+		return nil, ErrProductNotFound
+	}
+
+	t := product.NewTranslation(cmd.ProductID, cmd.Locale, cmd.Name, cmd.Description, cmd.UpdatedBy)
+	if err := h.translationRepo.SaveProduct(ctx, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+type DeleteProductTranslationCommand struct {
+	ProductID string `json:"-" validate:"required"`
+	Locale    string `json:"-" validate:"required"`
+}
+
+type DeleteProductTranslationCommandHandler struct {
+	translationRepo product.TranslationRepository
+}
+
+func NewDeleteProductTranslationCommandHandler(translationRepo product.TranslationRepository) *DeleteProductTranslationCommandHandler {
+	return &DeleteProductTranslationCommandHandler{translationRepo: translationRepo}
+}
+
+// Handle has the product served in its own text in the locale again.
+func (h *DeleteProductTranslationCommandHandler) Handle(ctx context.Context, cmd DeleteProductTranslationCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *DeleteProductTranslationCommandHandler) handle(ctx context.Context, cmd DeleteProductTranslationCommand) error {
+	return h.translationRepo.DeleteProduct(ctx, cmd.ProductID, cmd.Locale)
+}
+
+// SetCategoryTranslationCommand writes a category's name and description in
+// a locale other than the default one.
+type SetCategoryTranslationCommand struct {
+	CategoryID  string `json:"-" validate:"required"`
+	Locale      string `json:"-" validate:"required,locale"`
+	Name        string `json:"name" validate:"required,notblank,max=200"`
+	Description string `json:"description" validate:"max=5000"`
+	UpdatedBy   string `json:"-"`
+}
+
+type SetCategoryTranslationCommandHandler struct {
+	categoryRepo    product.CategoryRepository
+	translationRepo product.TranslationRepository
+}
+
+func NewSetCategoryTranslationCommandHandler(categoryRepo product.CategoryRepository, translationRepo product.TranslationRepository) *SetCategoryTranslationCommandHandler {
+	return &SetCategoryTranslationCommandHandler{categoryRepo: categoryRepo, translationRepo: translationRepo}
+}
+
+// Handle has the category's products indexed again in the locale, with the
+// category's translated name.
+func (h *SetCategoryTranslationCommandHandler) Handle(ctx context.Context, cmd SetCategoryTranslationCommand) (*product.CategoryTranslation, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *SetCategoryTranslationCommandHandler) handle(ctx context.Context, cmd SetCategoryTranslationCommand) (*product.CategoryTranslation, error) {
+	if cmd.Locale == i18n.DefaultLocale {
+		return nil, ErrDefaultLocaleTranslation
+	}
+	if _, err := h.categoryRepo.GetByID(ctx, cmd.CategoryID); err != nil {
+		return nil, ErrCategoryNotFound
+	}
+
+	t := product.NewCategoryTranslation(cmd.CategoryID, cmd.Locale, cmd.Name, cmd.Description, cmd.UpdatedBy)
+	if err := h.translationRepo.SaveCategory(ctx, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+type DeleteCategoryTranslationCommand struct {
+	CategoryID string `json:"-" validate:"required"`
+	Locale     string `json:"-" validate:"required"`
+}
+
+type DeleteCategoryTranslationCommandHandler struct {
+	translationRepo product.TranslationRepository
+}
+
+func NewDeleteCategoryTranslationCommandHandler(translationRepo product.TranslationRepository) *DeleteCategoryTranslationCommandHandler {
+	return &DeleteCategoryTranslationCommandHandler{translationRepo: translationRepo}
+}
+
+func (h *DeleteCategoryTranslationCommandHandler) Handle(ctx context.Context, cmd DeleteCategoryTranslationCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *DeleteCategoryTranslationCommandHandler) handle(ctx context.Context, cmd DeleteCategoryTranslationCommand) error {
+	return h.translationRepo.DeleteCategory(ctx, cmd.CategoryID, cmd.Locale)
+}
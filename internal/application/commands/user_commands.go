@@ -1,15 +1,22 @@
 package commands
 
 import (
+	"context"
+	"fmt"
+	"time"
+
+	"online-shop/internal/application/pipeline"
 	"online-shop/internal/domain/user"
+	"online-shop/pkg/i18n"
 )
 
 type RegisterUserCommand struct {
 	Email     string `json:"email" validate:"required,email"`
-	Password  string `json:"password" validate:"required,min=8"`
-	FirstName string `json:"first_name" validate:"required"`
-	LastName  string `json:"last_name" validate:"required"`
-	Phone     string `json:"phone"`
+	Password  string `json:"password" validate:"required,min=8,max=72"`
+	FirstName string `json:"first_name" validate:"required,notblank,max=100"`
+	LastName  string `json:"last_name" validate:"required,notblank,max=100"`
+	Phone     string `json:"phone" validate:"omitempty,phone"`
+	Locale    string `json:"locale" validate:"omitempty,locale"`
 }
 
 type LoginUserCommand struct {
@@ -25,7 +32,7 @@ type UpdateUserProfileCommand struct {
 type ChangePasswordCommand struct {
 	UserID      string `json:"user_id" validate:"required"`
 	OldPassword string `json:"old_password" validate:"required"`
-	NewPassword string `json:"new_password" validate:"required,min=8"`
+	NewPassword string `json:"new_password" validate:"required,min=8,max=72"`
 }
 
 type DeactivateUserCommand struct {
@@ -40,9 +47,13 @@ func NewRegisterUserCommandHandler(userRepo user.Repository) *RegisterUserComman
 	return &RegisterUserCommandHandler{userRepo: userRepo}
 }
 
-func (h *RegisterUserCommandHandler) Handle(cmd RegisterUserCommand) (*user.User, error) {
+func (h *RegisterUserCommandHandler) Handle(ctx context.Context, cmd RegisterUserCommand) (*user.User, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *RegisterUserCommandHandler) handle(ctx context.Context, cmd RegisterUserCommand) (*user.User, error) {
 	// Check if user already exists
-	existingUser, _ := h.userRepo.GetByEmail(cmd.Email)
+	existingUser, _ := h.userRepo.GetByEmail(ctx, cmd.Email)
 	if existingUser != nil {
 		return nil, ErrUserAlreadyExists
 	}
@@ -52,9 +63,12 @@ func (h *RegisterUserCommandHandler) Handle(cmd RegisterUserCommand) (*user.User
 	if err != nil {
 		return nil, err
 	}
+	if cmd.Locale != "" {
+		newUser.Locale = i18n.Negotiate("", cmd.Locale)
+	}
 
 	// Save user
-	if err := h.userRepo.Create(newUser); err != nil {
+	if err := h.userRepo.Create(ctx, newUser); err != nil {
 		return nil, err
 	}
 
@@ -69,9 +83,13 @@ func NewLoginUserCommandHandler(userRepo user.Repository) *LoginUserCommandHandl
 	return &LoginUserCommandHandler{userRepo: userRepo}
 }
 
-func (h *LoginUserCommandHandler) Handle(cmd LoginUserCommand) (*user.User, error) {
+func (h *LoginUserCommandHandler) Handle(ctx context.Context, cmd LoginUserCommand) (*user.User, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *LoginUserCommandHandler) handle(ctx context.Context, cmd LoginUserCommand) (*user.User, error) {
 	// Get user by email
-	existingUser, err := h.userRepo.GetByEmail(cmd.Email)
+	existingUser, err := h.userRepo.GetByEmail(ctx, cmd.Email)
 	if err != nil {
 		return nil, ErrInvalidCredentials
 	}
@@ -97,14 +115,19 @@ func NewUpdateUserProfileCommandHandler(userRepo user.Repository) *UpdateUserPro
 	return &UpdateUserProfileCommandHandler{userRepo: userRepo}
 }
 
-func (h *UpdateUserProfileCommandHandler) Handle(cmd UpdateUserProfileCommand) (*user.User, error) {
+func (h *UpdateUserProfileCommandHandler) Handle(ctx context.Context, cmd UpdateUserProfileCommand) (*user.User, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *UpdateUserProfileCommandHandler) handle(ctx context.Context, cmd UpdateUserProfileCommand) (*user.User, error) {
 	// Get user
-	existingUser, err := h.userRepo.GetByID(cmd.UserID)
+	existingUser, err := h.userRepo.GetByID(ctx, cmd.UserID)
 	if err != nil {
 		return nil, ErrUserNotFound
 	}
 
 	// Update fields
+	now := time.Now()
 	for key, value := range cmd.Updates {
 		switch key {
 		case "first_name":
@@ -119,17 +142,67 @@ func (h *UpdateUserProfileCommandHandler) Handle(cmd UpdateUserProfileCommand) (
 			if v, ok := value.(string); ok {
 				existingUser.Phone = v
 			}
+		case "locale":
+			if v, ok := value.(string); ok {
+				if v != "" && !i18n.Supported(v) {
+					return nil, ErrUnsupportedLocale.WithMeta("locale", v)
+				}
+				if v != "" {
+					v = i18n.Negotiate("", v)
+				}
+				existingUser.Locale = v
+			}
+		case "birthday":
+			if err := setBirthday(existingUser, value, now); err != nil {
+				return nil, err
+			}
+		case "gender":
+			if v, ok := value.(string); ok {
+				if err := existingUser.SetGender(user.Gender(v), now); err != nil {
+					return nil, err
+				}
+			}
+		case "marketing_consent":
+			consents, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%w: marketing_consent must map channels to true or false", user.ErrInvalidProfile)
+			}
+			for channel, granted := range consents {
+				g, ok := granted.(bool)
+				if !ok {
+					return nil, fmt.Errorf("%w: marketing consent for %s must be true or false", user.ErrInvalidProfile, channel)
+				}
+				if err := existingUser.SetMarketingConsent(user.Channel(channel), g, now); err != nil {
+					return nil, err
+				}
+			}
 		}
 	}
 
 	// Save user
-	if err := h.userRepo.Update(existingUser); err != nil {
+	if err := h.userRepo.Update(ctx, existingUser); err != nil {
 		return nil, err
 	}
 
 	return existingUser, nil
 }
 
+// setBirthday takes the birthday as a date, or null to clear it
+func setBirthday(u *user.User, value interface{}, now time.Time) error {
+	if value == nil {
+		return u.SetBirthday(nil, now)
+	}
+	v, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("%w: birthday must be a date like %s", user.ErrInvalidProfile, user.BirthdayLayout)
+	}
+	birthday, err := time.Parse(user.BirthdayLayout, v)
+	if err != nil {
+		return fmt.Errorf("%w: birthday must be a date like %s", user.ErrInvalidProfile, user.BirthdayLayout)
+	}
+	return u.SetBirthday(&birthday, now)
+}
+
 type ChangePasswordCommandHandler struct {
 	userRepo user.Repository
 }
@@ -138,16 +211,20 @@ func NewChangePasswordCommandHandler(userRepo user.Repository) *ChangePasswordCo
 	return &ChangePasswordCommandHandler{userRepo: userRepo}
 }
 
-func (h *ChangePasswordCommandHandler) Handle(cmd ChangePasswordCommand) error {
+func (h *ChangePasswordCommandHandler) Handle(ctx context.Context, cmd ChangePasswordCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *ChangePasswordCommandHandler) handle(ctx context.Context, cmd ChangePasswordCommand) error {
 	// Get user
-	existingUser, err := h.userRepo.GetByID(cmd.UserID)
+	existingUser, err := h.userRepo.GetByID(ctx, cmd.UserID)
 	if err != nil {
 		return ErrUserNotFound
 	}
 
 	// Validate old password
 	if err := existingUser.ValidatePassword(cmd.OldPassword); err != nil {
-		return ErrInvalidCredentials
+		return ErrIncorrectPassword
 	}
 
 	// Update password
@@ -156,5 +233,5 @@ func (h *ChangePasswordCommandHandler) Handle(cmd ChangePasswordCommand) error {
 	}
 
 	// Save user
-	return h.userRepo.Update(existingUser)
+	return h.userRepo.Update(ctx, existingUser)
 }
\ No newline at end of file
@@ -0,0 +1,134 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/warehouse"
+)
+
+type CreateWarehouseCommand struct {
+	Code     string        `json:"code" validate:"required"`
+	Name     string        `json:"name" validate:"required"`
+	Address  order.Address `json:"address"`
+	Priority int           `json:"priority"`
+	// ProcessingDays and CutoffHour default to the shop-wide processing
+	ProcessingDays *int `json:"processing_days" validate:"omitempty,min=0,max=30"`
+	CutoffHour     *int `json:"cutoff_hour" validate:"omitempty,min=0,max=23"`
+}
+
+type UpdateWarehouseCommand struct {
+	WarehouseID    string         `json:"warehouse_id" validate:"required"`
+	Name           *string        `json:"name"`
+	Address        *order.Address `json:"address"`
+	Priority       *int           `json:"priority"`
+	ProcessingDays *int           `json:"processing_days" validate:"omitempty,min=0,max=30"`
+	CutoffHour     *int           `json:"cutoff_hour" validate:"omitempty,min=0,max=23"`
+	Active         *bool          `json:"active"`
+}
+
+type SetWarehouseStockCommand struct {
+	WarehouseID string `json:"warehouse_id" validate:"required"`
+	ProductID   string `json:"product_id" validate:"required"`
+	Quantity    int    `json:"quantity" validate:"min=0"`
+}
+
+type CreateWarehouseCommandHandler struct {
+	warehouseRepo warehouse.Repository
+}
+
+func NewCreateWarehouseCommandHandler(warehouseRepo warehouse.Repository) *CreateWarehouseCommandHandler {
+	return &CreateWarehouseCommandHandler{warehouseRepo: warehouseRepo}
+}
+
+func (h *CreateWarehouseCommandHandler) Handle(ctx context.Context, cmd CreateWarehouseCommand) (*warehouse.Warehouse, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *CreateWarehouseCommandHandler) handle(ctx context.Context, cmd CreateWarehouseCommand) (*warehouse.Warehouse, error) {
+	w, err := warehouse.NewWarehouse(cmd.Code, cmd.Name, cmd.Address, cmd.Priority)
+	if err != nil {
+		return nil, ErrInvalidWarehouseData
+	}
+	w.ProcessingDays = cmd.ProcessingDays
+	w.CutoffHour = cmd.CutoffHour
+
+	if err := h.warehouseRepo.Create(ctx, w); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+type UpdateWarehouseCommandHandler struct {
+	warehouseRepo warehouse.Repository
+}
+
+func NewUpdateWarehouseCommandHandler(warehouseRepo warehouse.Repository) *UpdateWarehouseCommandHandler {
+	return &UpdateWarehouseCommandHandler{warehouseRepo: warehouseRepo}
+}
+
+func (h *UpdateWarehouseCommandHandler) Handle(ctx context.Context, cmd UpdateWarehouseCommand) (*warehouse.Warehouse, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *UpdateWarehouseCommandHandler) handle(ctx context.Context, cmd UpdateWarehouseCommand) (*warehouse.Warehouse, error) {
+	w, err := h.warehouseRepo.GetByID(ctx, cmd.WarehouseID)
+	if err != nil {
+		return nil, ErrWarehouseNotFound
+	}
+
+	if cmd.Name != nil {
+		w.Name = *cmd.Name
+	}
+	if cmd.Address != nil {
+		w.Address = *cmd.Address
+	}
+	if cmd.Priority != nil {
+		w.Priority = *cmd.Priority
+	}
+	if cmd.ProcessingDays != nil {
+		w.ProcessingDays = cmd.ProcessingDays
+	}
+	if cmd.CutoffHour != nil {
+		w.CutoffHour = cmd.CutoffHour
+	}
+	if cmd.Active != nil {
+		w.Active = *cmd.Active
+	}
+	w.UpdatedAt = time.Now()
+
+	if err := h.warehouseRepo.Update(ctx, w); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+type SetWarehouseStockCommandHandler struct {
+	warehouseRepo warehouse.Repository
+	stockRepo     warehouse.StockRepository
+}
+
+func NewSetWarehouseStockCommandHandler(warehouseRepo warehouse.Repository, stockRepo warehouse.StockRepository) *SetWarehouseStockCommandHandler {
+	return &SetWarehouseStockCommandHandler{
+		warehouseRepo: warehouseRepo,
+		stockRepo:     stockRepo,
+	}
+}
+
+func (h *SetWarehouseStockCommandHandler) Handle(ctx context.Context, cmd SetWarehouseStockCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *SetWarehouseStockCommandHandler) handle(ctx context.Context, cmd SetWarehouseStockCommand) error {
+	if cmd.Quantity < 0 {
+		return ErrInvalidWarehouseData
+	}
+	if _, err := h.warehouseRepo.GetByID(ctx, cmd.WarehouseID); err != nil {
+		return ErrWarehouseNotFound
+	}
+	return h.stockRepo.SetQuantity(ctx, cmd.WarehouseID, cmd.ProductID, cmd.Quantity)
+}
@@ -0,0 +1,398 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/quote"
+	"online-shop/internal/domain/webhook"
+)
+
+// WebhookPublisher queues an event for every endpoint subscribed to it; the
+// webhook job sends them. It is given to the handlers whose changes third
+// parties are told of.
+type WebhookPublisher struct {
+	endpointRepo webhook.EndpointRepository
+	deliveryRepo webhook.DeliveryRepository
+}
+
+func NewWebhookPublisher(endpointRepo webhook.EndpointRepository, deliveryRepo webhook.DeliveryRepository) *WebhookPublisher {
+	return &WebhookPublisher{endpointRepo: endpointRepo, deliveryRepo: deliveryRepo}
+}
+
+// Publish queues the event about the merchants' orders or products, with
+// data as its body.
+func (p *WebhookPublisher) Publish(ctx context.Context, event webhook.Event, merchantIDs []string, data interface{}) error {
+	endpoints, err := p.endpointRepo.ListActive(ctx)
+	if err != nil {
+		return err
+	}
+
+	n := webhook.NewNotification(event, data, time.Now())
+	var deliveries []*webhook.Delivery
+	for _, e := range endpoints {
+		if !e.Subscribes(event, merchantIDs) {
+			continue
+		}
+		d, err := webhook.NewDelivery(e.ID, n)
+		if err != nil {
+			return err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return p.deliveryRepo.CreateBatch(ctx, deliveries)
+}
+
+// OrderCreated publishes order.created with the order as its data.
+func (p *WebhookPublisher) OrderCreated(ctx context.Context, o *order.Order) error {
+	return p.Publish(ctx, webhook.EventOrderCreated, orderMerchants(o), o)
+}
+
+// ShippedOrder is the data of an order.shipped event: the order and the
+// shipment of it that left the warehouse.
+type ShippedOrder struct {
+	Order    *order.Order    `json:"order"`
+	Shipment *order.Shipment `json:"shipment"`
+}
+
+// OrderShipped publishes order.shipped for each shipment that ships, so an
+// order split across warehouses is published once per parcel.
+func (p *WebhookPublisher) OrderShipped(ctx context.Context, o *order.Order, s *order.Shipment) error {
+	return p.Publish(ctx, webhook.EventOrderShipped, orderMerchants(o), ShippedOrder{Order: o, Shipment: s})
+}
+
+// ProductUpdated publishes product.updated with the product as it now is.
+func (p *WebhookPublisher) ProductUpdated(ctx context.Context, pr *product.Product) error {
+	return p.Publish(ctx, webhook.EventProductUpdated, []string{pr.MerchantID}, pr)
+}
+
+// QuoteRequested publishes quote.requested for the merchant asked to price
+// the quote.
+func (p *WebhookPublisher) QuoteRequested(ctx context.Context, q *quote.Quote) error {
+	return p.Publish(ctx, webhook.EventQuoteRequested, []string{q.MerchantID}, q)
+}
+
+// QuoteAccepted publishes quote.accepted once the quote is converted into
+// its order.
+func (p *WebhookPublisher) QuoteAccepted(ctx context.Context, q *quote.Quote) error {
+	return p.Publish(ctx, webhook.EventQuoteAccepted, []string{q.MerchantID}, q)
+}
+
+// orderMerchants returns the merchants selling the order's items
+func orderMerchants(o *order.Order) []string {
+	var merchants []string
+	seen := make(map[string]bool)
+	for _, item := range o.Items {
+		if item.MerchantID != "" && !seen[item.MerchantID] {
+			seen[item.MerchantID] = true
+			merchants = append(merchants, item.MerchantID)
+		}
+	}
+	return merchants
+}
+
+// RegisterWebhookEndpointCommand registers a URL to be sent events. A
+// merchant's endpoint is sent the events about their own orders and
+// products; an admin may leave MerchantID empty to register a partner's
+// endpoint, which is sent every event.
+type RegisterWebhookEndpointCommand struct {
+	MerchantID  string          `json:"merchant_id"`
+	URL         string          `json:"url" validate:"required,url,max=2048"`
+	Description string          `json:"description" validate:"max=200"`
+	Events      []webhook.Event `json:"events" validate:"required,min=1,dive,oneof=order.created order.shipped product.updated quote.requested quote.accepted"`
+	CreatedBy   string          `json:"-"`
+}
+
+// UpdateWebhookEndpointCommand changes an endpoint; omitted fields are kept.
+// Scope is the merchant whose endpoint it must be, or empty for an admin.
+type UpdateWebhookEndpointCommand struct {
+	ID           string          `json:"-" validate:"required"`
+	Scope        string          `json:"-"`
+	URL          string          `json:"url" validate:"omitempty,url,max=2048"`
+	Description  *string         `json:"description" validate:"omitempty,max=200"`
+	Events       []webhook.Event `json:"events" validate:"omitempty,min=1,dive,oneof=order.created order.shipped product.updated quote.requested quote.accepted"`
+	Active       *bool           `json:"active"`
+	RotateSecret bool            `json:"rotate_secret"`
+}
+
+type DeleteWebhookEndpointCommand struct {
+	ID    string `json:"-" validate:"required"`
+	Scope string `json:"-"`
+}
+
+// DispatchWebhooksCommand sends up to BatchSize due deliveries. Each is
+// claimed for Lease first, long enough to be sent, so another instance
+// running the job leaves it alone.
+type DispatchWebhooksCommand struct {
+	BatchSize int
+	Lease     time.Duration
+}
+
+// NonTransactional: a delivery's claim has to be visible to the other
+// instances as soon as it is made.
+func (DispatchWebhooksCommand) NonTransactional() {}
+
+// RedeliverWebhookCommand sends a delivery again at once, as when a failed
+// endpoint has been fixed. Scope is as for UpdateWebhookEndpointCommand.
+type RedeliverWebhookCommand struct {
+	DeliveryID string `json:"-" validate:"required"`
+	Scope      string `json:"-"`
+}
+
+type RegisterWebhookEndpointCommandHandler struct {
+	endpointRepo webhook.EndpointRepository
+	allowLocal   bool
+}
+
+// NewRegisterWebhookEndpointCommandHandler accepts endpoints on the local
+// network, or over plain http, only in development.
+func NewRegisterWebhookEndpointCommandHandler(endpointRepo webhook.EndpointRepository, environment string) *RegisterWebhookEndpointCommandHandler {
+	return &RegisterWebhookEndpointCommandHandler{endpointRepo: endpointRepo, allowLocal: environment == "development"}
+}
+
+// Handle returns the endpoint with the secret its deliveries are signed
+// with, which is shown this once.
+func (h *RegisterWebhookEndpointCommandHandler) Handle(ctx context.Context, cmd RegisterWebhookEndpointCommand) (*webhook.Registration, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *RegisterWebhookEndpointCommandHandler) handle(ctx context.Context, cmd RegisterWebhookEndpointCommand) (*webhook.Registration, error) {
+	e, err := webhook.NewEndpoint(strings.TrimSpace(cmd.MerchantID), cmd.URL, cmd.Description, cmd.Events, cmd.CreatedBy, h.allowLocal)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.endpointRepo.Create(ctx, e); err != nil {
+		return nil, err
+	}
+	return &webhook.Registration{Endpoint: e, Secret: e.Secret}, nil
+}
+
+type UpdateWebhookEndpointCommandHandler struct {
+	endpointRepo webhook.EndpointRepository
+	allowLocal   bool
+}
+
+func NewUpdateWebhookEndpointCommandHandler(endpointRepo webhook.EndpointRepository, environment string) *UpdateWebhookEndpointCommandHandler {
+	return &UpdateWebhookEndpointCommandHandler{endpointRepo: endpointRepo, allowLocal: environment == "development"}
+}
+
+// Handle returns the endpoint with its new secret when it was rotated.
+// Deliveries already queued are sent with the endpoint as it now is.
+func (h *UpdateWebhookEndpointCommandHandler) Handle(ctx context.Context, cmd UpdateWebhookEndpointCommand) (*webhook.Registration, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *UpdateWebhookEndpointCommandHandler) handle(ctx context.Context, cmd UpdateWebhookEndpointCommand) (*webhook.Registration, error) {
+	e, err := scopedEndpoint(ctx, h.endpointRepo, cmd.ID, cmd.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	rawURL, events := e.URL, e.Events
+	if cmd.URL != "" {
+		rawURL = cmd.URL
+	}
+	if len(cmd.Events) > 0 {
+		events = cmd.Events
+	}
+	if err := e.Configure(rawURL, events, h.allowLocal); err != nil {
+		return nil, err
+	}
+	if cmd.Description != nil {
+		e.Description = strings.TrimSpace(*cmd.Description)
+	}
+	if cmd.Active != nil {
+		e.Active = *cmd.Active
+	}
+
+	registration := &webhook.Registration{Endpoint: e}
+	if cmd.RotateSecret {
+		if err := e.RotateSecret(); err != nil {
+			return nil, err
+		}
+		registration.Secret = e.Secret
+	}
+
+	if err := h.endpointRepo.Update(ctx, e); err != nil {
+		return nil, err
+	}
+	return registration, nil
+}
+
+type DeleteWebhookEndpointCommandHandler struct {
+	endpointRepo webhook.EndpointRepository
+}
+
+func NewDeleteWebhookEndpointCommandHandler(endpointRepo webhook.EndpointRepository) *DeleteWebhookEndpointCommandHandler {
+	return &DeleteWebhookEndpointCommandHandler{endpointRepo: endpointRepo}
+}
+
+// Handle deletes the endpoint with its delivery log; deliveries still
+// queued for it are not sent.
+func (h *DeleteWebhookEndpointCommandHandler) Handle(ctx context.Context, cmd DeleteWebhookEndpointCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *DeleteWebhookEndpointCommandHandler) handle(ctx context.Context, cmd DeleteWebhookEndpointCommand) error {
+	if _, err := scopedEndpoint(ctx, h.endpointRepo, cmd.ID, cmd.Scope); err != nil {
+		return err
+	}
+	return h.endpointRepo.Delete(ctx, cmd.ID)
+}
+
+// scopedEndpoint returns the endpoint when it is managed in the scope.
+// Another merchant's endpoint is not found, so its existence is not given
+// away.
+func scopedEndpoint(ctx context.Context, endpointRepo webhook.EndpointRepository, id, scope string) (*webhook.Endpoint, error) {
+	e, err := endpointRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !e.ManagedBy(scope) {
+		return nil, webhook.ErrEndpointNotFound
+	}
+	return e, nil
+}
+
+// webhookDispatcher sends deliveries and records how they went
+type webhookDispatcher struct {
+	deliveryRepo webhook.DeliveryRepository
+	sender       webhook.Sender
+	retry        webhook.RetryPolicy
+}
+
+func (d webhookDispatcher) send(ctx context.Context, e *webhook.Endpoint, delivery *webhook.Delivery, manual bool) (*webhook.Attempt, error) {
+	a := d.sender.Send(ctx, e, delivery)
+	a.Manual = manual
+	delivery.Record(a, d.retry)
+	if err := d.deliveryRepo.Record(ctx, delivery, a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+type DispatchWebhooksCommandHandler struct {
+	endpointRepo webhook.EndpointRepository
+	webhookDispatcher
+}
+
+func NewDispatchWebhooksCommandHandler(endpointRepo webhook.EndpointRepository, deliveryRepo webhook.DeliveryRepository, sender webhook.Sender, retry webhook.RetryPolicy) *DispatchWebhooksCommandHandler {
+	return &DispatchWebhooksCommandHandler{
+		endpointRepo:      endpointRepo,
+		webhookDispatcher: webhookDispatcher{deliveryRepo: deliveryRepo, sender: sender, retry: retry},
+	}
+}
+
+// Handle sends the due deliveries, longest waiting first, and returns how
+// many it sent. Once an endpoint fails in a run its other deliveries wait
+// for the next one, so an endpoint that is down does not hold up the rest.
+// Deliveries to endpoints that were disabled or deleted fail.
+func (h *DispatchWebhooksCommandHandler) Handle(ctx context.Context, cmd DispatchWebhooksCommand) (int, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *DispatchWebhooksCommandHandler) handle(ctx context.Context, cmd DispatchWebhooksCommand) (int, error) {
+	if cmd.BatchSize <= 0 {
+		cmd.BatchSize = 100
+	}
+	if cmd.Lease <= 0 {
+		cmd.Lease = time.Minute
+	}
+
+	now := time.Now()
+	due, err := h.deliveryRepo.ListDue(ctx, now, cmd.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	endpoints := make(map[string]*webhook.Endpoint)
+	failing := make(map[string]bool)
+	sent := 0
+	for _, d := range due {
+		e, seen := endpoints[d.EndpointID]
+		if !seen {
+			e, err = h.endpointRepo.GetByID(ctx, d.EndpointID)
+			if err != nil && !errors.Is(err, webhook.ErrEndpointNotFound) {
+				return sent, err
+			}
+			endpoints[d.EndpointID] = e
+		}
+		if e == nil || !e.Active {
+			d.Abandon("endpoint was disabled or deleted", now)
+			if err := h.deliveryRepo.Update(ctx, d); err != nil {
+				return sent, err
+			}
+			continue
+		}
+		if failing[e.ID] {
+			continue
+		}
+
+		claimed, err := h.deliveryRepo.Claim(ctx, d, time.Now().Add(cmd.Lease))
+		if err != nil {
+			return sent, err
+		}
+		if !claimed {
+			continue
+		}
+		a, err := h.send(ctx, e, d, false)
+		if err != nil {
+			return sent, err
+		}
+		sent++
+		if !a.Succeeded() {
+			failing[e.ID] = true
+		}
+	}
+	return sent, nil
+}
+
+type RedeliverWebhookCommandHandler struct {
+	endpointRepo webhook.EndpointRepository
+	webhookDispatcher
+}
+
+func NewRedeliverWebhookCommandHandler(endpointRepo webhook.EndpointRepository, deliveryRepo webhook.DeliveryRepository, sender webhook.Sender, retry webhook.RetryPolicy) *RedeliverWebhookCommandHandler {
+	return &RedeliverWebhookCommandHandler{
+		endpointRepo:      endpointRepo,
+		webhookDispatcher: webhookDispatcher{deliveryRepo: deliveryRepo, sender: sender, retry: retry},
+	}
+}
+
+// Handle queues the delivery again with a fresh set of attempts and sends
+// it at once. When that fails it is retried like a new delivery. The
+// delivery is returned with the attempt just made.
+func (h *RedeliverWebhookCommandHandler) Handle(ctx context.Context, cmd RedeliverWebhookCommand) (*webhook.Delivery, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *RedeliverWebhookCommandHandler) handle(ctx context.Context, cmd RedeliverWebhookCommand) (*webhook.Delivery, error) {
+	d, err := h.deliveryRepo.GetByID(ctx, cmd.DeliveryID)
+	if err != nil {
+		return nil, err
+	}
+	e, err := scopedEndpoint(ctx, h.endpointRepo, d.EndpointID, cmd.Scope)
+	if errors.Is(err, webhook.ErrEndpointNotFound) {
+		return nil, webhook.ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !e.Active {
+		return nil, fmt.Errorf("%w: enable it before redelivering", webhook.ErrEndpointDisabled)
+	}
+
+	d.Redeliver(time.Now())
+	a, err := h.send(ctx, e, d, true)
+	if err != nil {
+		return nil, err
+	}
+	d.AttemptLog = append([]webhook.Attempt{*a}, d.AttemptLog...)
+	return d, nil
+}
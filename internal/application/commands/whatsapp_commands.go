@@ -0,0 +1,195 @@
+package commands
+
+import (
+	"context"
+	"errors"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/whatsapp"
+	"online-shop/pkg/i18n"
+)
+
+// CreateWhatsAppTemplateCommand submits a template to Meta for review. It
+// can be sent once Meta approves it, which the webhook or a sync records.
+type CreateWhatsAppTemplateCommand struct {
+	Name       string               `json:"name" validate:"required"`
+	Language   string               `json:"language" validate:"omitempty,locale"`
+	Category   string               `json:"category" validate:"required,oneof=MARKETING UTILITY AUTHENTICATION"`
+	Body       string               `json:"body" validate:"required,max=1024"`
+	Parameters []whatsapp.Parameter `json:"parameters"`
+}
+
+// DeleteWhatsAppTemplateCommand deletes a template in every language, at
+// Meta and here.
+type DeleteWhatsAppTemplateCommand struct {
+	Name string `json:"name" validate:"required"`
+}
+
+// SyncWhatsAppTemplatesCommand pulls the review status of every template
+// from Meta, for reviews the webhook missed.
+type SyncWhatsAppTemplatesCommand struct{}
+
+// NonTransactional, as it waits on Meta's API for every template.
+func (SyncWhatsAppTemplatesCommand) NonTransactional() {}
+
+// SyncWhatsAppTemplatesResult counts the stored templates a sync updated.
+type SyncWhatsAppTemplatesResult struct {
+	Updated int `json:"updated"`
+}
+
+// IngestWhatsAppWebhookCommand is a notification Meta posted to the webhook.
+// The body is kept raw, as the signature is over the exact bytes.
+type IngestWhatsAppWebhookCommand struct {
+	Signature string
+	Body      []byte
+}
+
+// IngestWhatsAppWebhookResult counts the messages and templates a
+// notification updated.
+type IngestWhatsAppWebhookResult struct {
+	Messages  int `json:"messages"`
+	Templates int `json:"templates"`
+}
+
+type CreateWhatsAppTemplateCommandHandler struct {
+	client    whatsapp.Client
+	templates whatsapp.TemplateRepository
+}
+
+func NewCreateWhatsAppTemplateCommandHandler(client whatsapp.Client, templates whatsapp.TemplateRepository) *CreateWhatsAppTemplateCommandHandler {
+	return &CreateWhatsAppTemplateCommandHandler{client: client, templates: templates}
+}
+
+func (h *CreateWhatsAppTemplateCommandHandler) Handle(ctx context.Context, cmd CreateWhatsAppTemplateCommand) (*whatsapp.Template, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *CreateWhatsAppTemplateCommandHandler) handle(ctx context.Context, cmd CreateWhatsAppTemplateCommand) (*whatsapp.Template, error) {
+	t, err := whatsapp.NewTemplate(cmd.Name, i18n.Negotiate("", cmd.Language), cmd.Category, cmd.Body, cmd.Parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := h.client.SubmitTemplate(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	t.ProviderID = status.ProviderID
+	if status.Status != "" {
+		t.Status = status.Status
+	}
+
+	if err := h.templates.Save(ctx, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+type DeleteWhatsAppTemplateCommandHandler struct {
+	client    whatsapp.Client
+	templates whatsapp.TemplateRepository
+}
+
+func NewDeleteWhatsAppTemplateCommandHandler(client whatsapp.Client, templates whatsapp.TemplateRepository) *DeleteWhatsAppTemplateCommandHandler {
+	return &DeleteWhatsAppTemplateCommandHandler{client: client, templates: templates}
+}
+
+func (h *DeleteWhatsAppTemplateCommandHandler) Handle(ctx context.Context, cmd DeleteWhatsAppTemplateCommand) error {
+	return pipeline.Exec(ctx, cmd, h.handle)
+}
+
+func (h *DeleteWhatsAppTemplateCommandHandler) handle(ctx context.Context, cmd DeleteWhatsAppTemplateCommand) error {
+	if err := h.client.DeleteTemplate(ctx, cmd.Name); err != nil {
+		return err
+	}
+	return h.templates.Delete(ctx, cmd.Name)
+}
+
+type SyncWhatsAppTemplatesCommandHandler struct {
+	client    whatsapp.Client
+	templates whatsapp.TemplateRepository
+}
+
+func NewSyncWhatsAppTemplatesCommandHandler(client whatsapp.Client, templates whatsapp.TemplateRepository) *SyncWhatsAppTemplatesCommandHandler {
+	return &SyncWhatsAppTemplatesCommandHandler{client: client, templates: templates}
+}
+
+// Handle skips templates that are only at Meta, such as ones created in its
+// console; they are not sent until they are submitted here.
+func (h *SyncWhatsAppTemplatesCommandHandler) Handle(ctx context.Context, cmd SyncWhatsAppTemplatesCommand) (*SyncWhatsAppTemplatesResult, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *SyncWhatsAppTemplatesCommandHandler) handle(ctx context.Context, cmd SyncWhatsAppTemplatesCommand) (*SyncWhatsAppTemplatesResult, error) {
+	statuses, err := h.client.Templates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SyncWhatsAppTemplatesResult{}
+	for _, status := range statuses {
+		err := h.templates.SetStatus(ctx, status)
+		if errors.Is(err, whatsapp.ErrTemplateNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Updated++
+	}
+	return result, nil
+}
+
+type IngestWhatsAppWebhookCommandHandler struct {
+	webhook   whatsapp.Webhook
+	templates whatsapp.TemplateRepository
+	messages  whatsapp.MessageRepository
+}
+
+func NewIngestWhatsAppWebhookCommandHandler(webhook whatsapp.Webhook, templates whatsapp.TemplateRepository, messages whatsapp.MessageRepository) *IngestWhatsAppWebhookCommandHandler {
+	return &IngestWhatsAppWebhookCommandHandler{webhook: webhook, templates: templates, messages: messages}
+}
+
+// Handle records delivery statuses and template reviews. Statuses of
+// messages not sent by the worker, and reviews of templates not stored, are
+// ignored.
+func (h *IngestWhatsAppWebhookCommandHandler) Handle(ctx context.Context, cmd IngestWhatsAppWebhookCommand) (*IngestWhatsAppWebhookResult, error) {
+	return pipeline.Handle(ctx, cmd, h.handle)
+}
+
+func (h *IngestWhatsAppWebhookCommandHandler) handle(ctx context.Context, cmd IngestWhatsAppWebhookCommand) (*IngestWhatsAppWebhookResult, error) {
+	updates, err := h.webhook.Updates(cmd.Signature, cmd.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &IngestWhatsAppWebhookResult{}
+	for _, update := range updates.Statuses {
+		message, err := h.messages.Get(ctx, update.MessageID)
+		if errors.Is(err, whatsapp.ErrMessageNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !message.Apply(update) {
+			continue
+		}
+		if err := h.messages.Update(ctx, message); err != nil {
+			return nil, err
+		}
+		result.Messages++
+	}
+
+	for _, status := range updates.Templates {
+		err := h.templates.SetStatus(ctx, status)
+		if errors.Is(err, whatsapp.ErrTemplateNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Templates++
+	}
+	return result, nil
+}
@@ -0,0 +1,149 @@
+package pipeline
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"time"
+
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/logger"
+	"online-shop/pkg/validation"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+var handlerDuration = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "application_handler_duration_seconds",
+		Help:    "Time taken by command and query handlers, by outcome: success or error",
+		Buckets: prometheus.DefBuckets,
+	},
+	[]string{"kind", "name", "outcome"},
+)
+
+// Validation checks messages against their validate tags before they are
+// handled, for callers that did not validate them already
+func Validation() Behavior {
+	return func(ctx context.Context, req Request, next Next) error {
+		if isStruct(req.Message) {
+			if err := validation.Struct(req.Message); err != nil {
+				var verr *validation.Error
+				if errors.As(err, &verr) {
+					return apperror.ErrValidationFailed.WithFields(verr.Fields)
+				}
+				return err
+			}
+		}
+		return next(ctx)
+	}
+}
+
+func isStruct(message interface{}) bool {
+	t := reflect.TypeOf(message)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t != nil && t.Kind() == reflect.Struct
+}
+
+// Logging logs every handled message with the request-scoped fields of its
+// context. Failures the client caused are logged at debug level like
+// successes; the request log already records them.
+func Logging(log *zap.Logger) Behavior {
+	return func(ctx context.Context, req Request, next Next) error {
+		start := time.Now()
+		err := next(ctx)
+
+		fields := append(logger.Fields(ctx),
+			zap.String(string(req.Kind), req.Name),
+			zap.Duration("duration", time.Since(start)))
+		switch {
+		case err == nil:
+			log.Debug("Handled "+string(req.Kind), fields...)
+		case apperror.From(err).Kind == apperror.KindInternal:
+			log.Error("Failed to handle "+string(req.Kind), append(fields, zap.Error(err))...)
+		default:
+			log.Debug("Refused "+string(req.Kind), append(fields, zap.Error(err))...)
+		}
+		return err
+	}
+}
+
+// Metrics records how long each handler takes in
+// application_handler_duration_seconds
+func Metrics() Behavior {
+	return func(ctx context.Context, req Request, next Next) error {
+		start := time.Now()
+		err := next(ctx)
+		outcome := "success"
+		if err != nil {
+			outcome = "error"
+		}
+		handlerDuration.WithLabelValues(string(req.Kind), req.Name, outcome).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// Retry runs a query up to retries more times while it fails on an
+// unavailable dependency, waiting backoff longer before each attempt.
+// Commands are never retried: they may have had effects before failing.
+func Retry(retries int, backoff time.Duration) Behavior {
+	return func(ctx context.Context, req Request, next Next) error {
+		err := next(ctx)
+		if req.Kind != KindQuery {
+			return err
+		}
+		for attempt := 1; attempt <= retries && Transient(err); attempt++ {
+			timer := time.NewTimer(time.Duration(attempt) * backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+			err = next(ctx)
+		}
+		return err
+	}
+}
+
+// Transient reports whether err is worth retrying: a dependency that was
+// unavailable or a database connection that broke, but not a request that
+// was cancelled or ran out of time
+func Transient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	return errors.Is(err, driver.ErrBadConn) || apperror.From(err).Kind == apperror.KindUnavailable
+}
+
+// Transactor runs fn in a database transaction carried by the context it
+// passes on, committing it when fn succeeds and rolling it back otherwise
+type Transactor interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// NonTransactional is implemented by commands that must not run in a
+// transaction: long-running jobs, which would hold one for minutes, and
+// commands charging or refunding through a provider, whose record of what
+// the provider did would be lost with a rollback. The command type opts
+// out itself, so the choice moves with it when it is renamed.
+type NonTransactional interface {
+	NonTransactional()
+}
+
+// Transaction runs each command in a transaction, so a command writing
+// through several repositories either saves everything or nothing. Queries
+// and NonTransactional commands run without one.
+func Transaction(tx Transactor) Behavior {
+	return func(ctx context.Context, req Request, next Next) error {
+		if _, skip := req.Message.(NonTransactional); req.Kind != KindCommand || skip {
+			return next(ctx)
+		}
+		return tx.Transaction(ctx, next)
+	}
+}
@@ -0,0 +1,151 @@
+// Package pipeline runs command and query handlers through shared
+// behaviors, so validation, logging, metrics, retries and transactions are
+// set up once rather than in every handler.
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+
+	"online-shop/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// Kind tells commands, which change state, from queries, which only read it
+type Kind string
+
+const (
+	KindCommand Kind = "command"
+	KindQuery   Kind = "query"
+)
+
+// Request is the command or query being handled
+type Request struct {
+	// Name is the message's type name, e.g. CreateOrderCommand
+	Name    string
+	Kind    Kind
+	Message interface{}
+}
+
+func newRequest(message interface{}) Request {
+	name := ""
+	if t := reflect.TypeOf(message); t != nil {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		name = t.Name()
+	}
+	kind := KindCommand
+	if strings.HasSuffix(name, "Query") {
+		kind = KindQuery
+	}
+	return Request{Name: name, Kind: kind, Message: message}
+}
+
+// Next runs the rest of the pipeline, then the handler
+type Next func(ctx context.Context) error
+
+// Behavior runs around every handler. It calls next to go on, possibly with
+// a derived context or more than once, or returns without calling it to
+// refuse the request.
+type Behavior func(ctx context.Context, req Request, next Next) error
+
+// Pipeline is an ordered list of behaviors, the first one outermost
+type Pipeline struct {
+	behaviors []Behavior
+	// tx runs Atomic's functions; nil without transactions
+	tx Transactor
+}
+
+func New(behaviors ...Behavior) *Pipeline {
+	return &Pipeline{behaviors: behaviors}
+}
+
+// Run runs handle for message through every behavior
+func (p *Pipeline) Run(ctx context.Context, message interface{}, handle Next) error {
+	req := newRequest(message)
+	next := handle
+	for i := len(p.behaviors) - 1; i >= 0; i-- {
+		behavior, inner := p.behaviors[i], next
+		next = func(ctx context.Context) error {
+			return behavior(ctx, req, inner)
+		}
+	}
+	return next(ctx)
+}
+
+// Standard is the pipeline the binaries use: metrics and logging outermost,
+// so refused requests are counted and logged too, then validation, retries
+// and, when tx is not nil, transactions. A retried query reruns in full.
+func Standard(cfg *config.PipelineConfig, log *zap.Logger, tx Transactor) *Pipeline {
+	behaviors := []Behavior{
+		Metrics(),
+		Logging(log),
+		Validation(),
+		Retry(cfg.Retries, cfg.RetryBackoff()),
+	}
+	if tx == nil || !cfg.Transactions.Enabled {
+		return New(behaviors...)
+	}
+	p := New(append(behaviors, Transaction(tx))...)
+	p.tx = tx
+	return p
+}
+
+var (
+	mu      sync.RWMutex
+	current = New()
+)
+
+// Use sets the pipeline every handler runs through. Binaries call it once
+// their dependencies are built; until then handlers run without behaviors.
+func Use(p *Pipeline) {
+	mu.Lock()
+	current = p
+	mu.Unlock()
+}
+
+// Current returns the pipeline set with Use
+func Current() *Pipeline {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Atomic runs fn in a transaction of the current pipeline, for the steps of
+// a NonTransactional command that have to be saved together or not at all.
+// Without transactions fn runs as it is; within a transaction already it
+// joins that one.
+func Atomic(ctx context.Context, fn func(ctx context.Context) error) error {
+	p := Current()
+	if p.tx == nil {
+		return fn(ctx)
+	}
+	return p.tx.Transaction(ctx, fn)
+}
+
+// Handle runs a handler returning a result through the current pipeline.
+// Handlers implement their Handle method with it:
+//
+//	func (h *CreateOrderCommandHandler) Handle(ctx context.Context, cmd CreateOrderCommand) (*order.Order, error) {
+//		return pipeline.Handle(ctx, cmd, h.handle)
+//	}
+func Handle[M, R any](ctx context.Context, message M, handle func(context.Context, M) (R, error)) (R, error) {
+	var result R
+	err := Current().Run(ctx, message, func(ctx context.Context) error {
+		var err error
+		result, err = handle(ctx, message)
+		return err
+	})
+	return result, err
+}
+
+// Exec is Handle for handlers that only return an error
+func Exec[M any](ctx context.Context, message M, handle func(context.Context, M) error) error {
+	return Current().Run(ctx, message, func(ctx context.Context) error {
+		return handle(ctx, message)
+	})
+}
@@ -0,0 +1,167 @@
+package queries
+
+import (
+	"context"
+	"math"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/analytics"
+)
+
+type SalesSummary struct {
+	From              string            `json:"from"`
+	To                string            `json:"to"`
+	Orders            int64             `json:"orders"`
+	Items             int64             `json:"items"`
+	Revenue           float64           `json:"revenue"`
+	Commission        float64           `json:"commission"`
+	MerchantPayout    float64           `json:"merchant_payout"`
+	AverageOrderValue float64           `json:"average_order_value"`
+	Funnel            *analytics.Funnel `json:"funnel"`
+}
+
+type UserAnalytics struct {
+	Activity []analytics.UserActivityPoint `json:"activity"`
+	Cohorts  []analytics.Cohort            `json:"cohorts"`
+}
+
+type GetSalesAnalyticsQuery struct {
+	Range analytics.ReportRange
+}
+
+type GetSalesAnalyticsQueryHandler struct {
+	reports analytics.ReportRepository
+}
+
+func NewGetSalesAnalyticsQueryHandler(reports analytics.ReportRepository) *GetSalesAnalyticsQueryHandler {
+	return &GetSalesAnalyticsQueryHandler{reports: reports}
+}
+
+// Handle totals the revenue series over the range and attaches the purchase
+// funnel for the same window.
+func (h *GetSalesAnalyticsQueryHandler) Handle(ctx context.Context, query GetSalesAnalyticsQuery) (*SalesSummary, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetSalesAnalyticsQueryHandler) handle(ctx context.Context, query GetSalesAnalyticsQuery) (*SalesSummary, error) {
+	if err := query.Range.Normalize(); err != nil {
+		return nil, err
+	}
+
+	points, err := h.reports.Revenue(ctx, query.Range)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := h.reports.FunnelCounts(ctx, query.Range, analytics.FunnelSteps)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &SalesSummary{
+		From:   query.Range.From.Format("2006-01-02"),
+		To:     query.Range.To.Format("2006-01-02"),
+		Funnel: analytics.BuildFunnel(analytics.FunnelSteps, counts),
+	}
+	for _, p := range points {
+		summary.Orders += p.Orders
+		summary.Items += p.Items
+		summary.Revenue += p.Revenue
+		summary.Commission += p.Commission
+		summary.MerchantPayout += p.MerchantPayout
+	}
+	summary.Commission = math.Round(summary.Commission*100) / 100
+	summary.MerchantPayout = math.Round(summary.MerchantPayout*100) / 100
+	if summary.Orders > 0 {
+		summary.AverageOrderValue = summary.Revenue / float64(summary.Orders)
+	}
+	return summary, nil
+}
+
+type GetRevenueAnalyticsQuery struct {
+	Range analytics.ReportRange
+}
+
+type GetRevenueAnalyticsQueryHandler struct {
+	reports analytics.ReportRepository
+}
+
+func NewGetRevenueAnalyticsQueryHandler(reports analytics.ReportRepository) *GetRevenueAnalyticsQueryHandler {
+	return &GetRevenueAnalyticsQueryHandler{reports: reports}
+}
+
+func (h *GetRevenueAnalyticsQueryHandler) Handle(ctx context.Context, query GetRevenueAnalyticsQuery) ([]analytics.RevenuePoint, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetRevenueAnalyticsQueryHandler) handle(ctx context.Context, query GetRevenueAnalyticsQuery) ([]analytics.RevenuePoint, error) {
+	if err := query.Range.Normalize(); err != nil {
+		return nil, err
+	}
+	return h.reports.Revenue(ctx, query.Range)
+}
+
+type GetProductAnalyticsQuery struct {
+	Range  analytics.ReportRange
+	SortBy string
+	Limit  int
+}
+
+type GetProductAnalyticsQueryHandler struct {
+	reports analytics.ReportRepository
+}
+
+func NewGetProductAnalyticsQueryHandler(reports analytics.ReportRepository) *GetProductAnalyticsQueryHandler {
+	return &GetProductAnalyticsQueryHandler{reports: reports}
+}
+
+func (h *GetProductAnalyticsQueryHandler) Handle(ctx context.Context, query GetProductAnalyticsQuery) ([]analytics.ProductStat, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetProductAnalyticsQueryHandler) handle(ctx context.Context, query GetProductAnalyticsQuery) ([]analytics.ProductStat, error) {
+	if err := query.Range.Normalize(); err != nil {
+		return nil, err
+	}
+	if query.Limit <= 0 || query.Limit > 100 {
+		query.Limit = 20
+	}
+	return h.reports.TopProducts(ctx, query.Range, query.SortBy, query.Limit)
+}
+
+type GetUserAnalyticsQuery struct {
+	Range analytics.ReportRange
+}
+
+type GetUserAnalyticsQueryHandler struct {
+	reports analytics.ReportRepository
+}
+
+func NewGetUserAnalyticsQueryHandler(reports analytics.ReportRepository) *GetUserAnalyticsQueryHandler {
+	return &GetUserAnalyticsQueryHandler{reports: reports}
+}
+
+func (h *GetUserAnalyticsQueryHandler) Handle(ctx context.Context, query GetUserAnalyticsQuery) (*UserAnalytics, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetUserAnalyticsQueryHandler) handle(ctx context.Context, query GetUserAnalyticsQuery) (*UserAnalytics, error) {
+	if err := query.Range.Normalize(); err != nil {
+		return nil, err
+	}
+
+	activity, err := h.reports.UserActivity(ctx, query.Range)
+	if err != nil {
+		return nil, err
+	}
+
+	cells, err := h.reports.CohortCells(ctx, query.Range)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserAnalytics{
+		Activity: activity,
+		Cohorts:  analytics.BuildCohorts(cells, query.Range.Interval),
+	}, nil
+}
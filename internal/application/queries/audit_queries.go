@@ -0,0 +1,47 @@
+package queries
+
+import (
+	"context"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/audit"
+)
+
+type ListAuditLogsQuery struct {
+	Filter audit.Filter `json:"filter"`
+	Limit  int          `json:"limit"`
+	Offset int          `json:"offset"`
+}
+
+type AuditLogPage struct {
+	Entries []*audit.Entry `json:"entries"`
+	Total   int64          `json:"total"`
+}
+
+type ListAuditLogsQueryHandler struct {
+	auditRepo audit.Repository
+}
+
+func NewListAuditLogsQueryHandler(auditRepo audit.Repository) *ListAuditLogsQueryHandler {
+	return &ListAuditLogsQueryHandler{auditRepo: auditRepo}
+}
+
+func (h *ListAuditLogsQueryHandler) Handle(ctx context.Context, query ListAuditLogsQuery) (*AuditLogPage, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListAuditLogsQueryHandler) handle(ctx context.Context, query ListAuditLogsQuery) (*AuditLogPage, error) {
+	if query.Limit <= 0 {
+		query.Limit = 50
+	}
+	if query.Limit > 200 {
+		query.Limit = 200
+	}
+
+	entries, total, err := h.auditRepo.List(ctx, query.Filter, query.Limit, query.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuditLogPage{Entries: entries, Total: total}, nil
+}
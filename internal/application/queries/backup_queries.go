@@ -0,0 +1,100 @@
+package queries
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/backup"
+)
+
+type ListBackupsQuery struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+type ListBackupsQueryHandler struct {
+	backupRepo backup.Repository
+}
+
+func NewListBackupsQueryHandler(backupRepo backup.Repository) *ListBackupsQueryHandler {
+	return &ListBackupsQueryHandler{backupRepo: backupRepo}
+}
+
+func (h *ListBackupsQueryHandler) Handle(ctx context.Context, query ListBackupsQuery) ([]*backup.Backup, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListBackupsQueryHandler) handle(ctx context.Context, query ListBackupsQuery) ([]*backup.Backup, error) {
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+
+	return h.backupRepo.List(ctx, query.Limit, query.Offset)
+}
+
+type GetBackupQuery struct {
+	BackupID string `json:"backup_id"`
+}
+
+type GetBackupQueryHandler struct {
+	backupRepo backup.Repository
+}
+
+func NewGetBackupQueryHandler(backupRepo backup.Repository) *GetBackupQueryHandler {
+	return &GetBackupQueryHandler{backupRepo: backupRepo}
+}
+
+func (h *GetBackupQueryHandler) Handle(ctx context.Context, query GetBackupQuery) (*backup.Backup, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetBackupQueryHandler) handle(ctx context.Context, query GetBackupQuery) (*backup.Backup, error) {
+	return h.backupRepo.GetByID(ctx, query.BackupID)
+}
+
+type GetBackupStatusQuery struct{}
+
+// BackupStatus summarizes backups for the system health report. Stale is set
+// when no backup has succeeded within twice the backup interval.
+type BackupStatus struct {
+	LastCompleted *backup.Backup `json:"last_completed"`
+	LastFailed    *backup.Backup `json:"last_failed,omitempty"`
+	Stale         bool           `json:"stale"`
+}
+
+type GetBackupStatusQueryHandler struct {
+	backupRepo backup.Repository
+	interval   time.Duration
+}
+
+func NewGetBackupStatusQueryHandler(backupRepo backup.Repository, interval time.Duration) *GetBackupStatusQueryHandler {
+	return &GetBackupStatusQueryHandler{
+		backupRepo: backupRepo,
+		interval:   interval,
+	}
+}
+
+func (h *GetBackupStatusQueryHandler) Handle(ctx context.Context, query GetBackupStatusQuery) (*BackupStatus, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetBackupStatusQueryHandler) handle(ctx context.Context, query GetBackupStatusQuery) (*BackupStatus, error) {
+	completed, err := h.backupRepo.Latest(ctx, backup.StatusCompleted)
+	if err != nil {
+		return nil, err
+	}
+	failed, err := h.backupRepo.Latest(ctx, backup.StatusFailed)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &BackupStatus{LastCompleted: completed}
+	if failed != nil && (completed == nil || failed.CreatedAt.After(completed.CreatedAt)) {
+		status.LastFailed = failed
+	}
+	if h.interval > 0 {
+		status.Stale = completed == nil || time.Since(completed.CreatedAt) > 2*h.interval
+	}
+	return status, nil
+}
@@ -0,0 +1,53 @@
+package queries
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/badge"
+)
+
+type ListBadgesQuery struct{}
+
+type ListBadgesQueryHandler struct {
+	badgeRepo badge.Repository
+}
+
+func NewListBadgesQueryHandler(badgeRepo badge.Repository) *ListBadgesQueryHandler {
+	return &ListBadgesQueryHandler{badgeRepo: badgeRepo}
+}
+
+// Handle lists every badge by priority, the built-in ones with their rules.
+func (h *ListBadgesQueryHandler) Handle(ctx context.Context, query ListBadgesQuery) ([]*badge.Definition, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListBadgesQueryHandler) handle(ctx context.Context, query ListBadgesQuery) ([]*badge.Definition, error) {
+	stored, err := h.badgeRepo.ListDefinitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return badge.Definitions(stored), nil
+}
+
+type ListProductBadgesQuery struct {
+	ProductID string `json:"product_id" validate:"required"`
+}
+
+type ListProductBadgesQueryHandler struct {
+	badgeRepo badge.Repository
+}
+
+func NewListProductBadgesQueryHandler(badgeRepo badge.Repository) *ListProductBadgesQueryHandler {
+	return &ListProductBadgesQueryHandler{badgeRepo: badgeRepo}
+}
+
+// Handle lists the badges set on a product by hand that have not expired.
+func (h *ListProductBadgesQueryHandler) Handle(ctx context.Context, query ListProductBadgesQuery) ([]*badge.Assignment, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListProductBadgesQueryHandler) handle(ctx context.Context, query ListProductBadgesQuery) ([]*badge.Assignment, error) {
+	return h.badgeRepo.Assignments(ctx, []string{query.ProductID}, time.Now())
+}
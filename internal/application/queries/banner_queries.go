@@ -0,0 +1,32 @@
+package queries
+
+import (
+	"context"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/banner"
+)
+
+type ListBannersQuery struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+type ListBannersQueryHandler struct {
+	bannerRepo banner.Repository
+}
+
+func NewListBannersQueryHandler(bannerRepo banner.Repository) *ListBannersQueryHandler {
+	return &ListBannersQueryHandler{bannerRepo: bannerRepo}
+}
+
+func (h *ListBannersQueryHandler) Handle(ctx context.Context, query ListBannersQuery) ([]*banner.Banner, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListBannersQueryHandler) handle(ctx context.Context, query ListBannersQuery) ([]*banner.Banner, error) {
+	if query.Limit <= 0 {
+		query.Limit = 50
+	}
+	return h.bannerRepo.List(ctx, query.Limit, query.Offset)
+}
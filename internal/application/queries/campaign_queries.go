@@ -0,0 +1,136 @@
+package queries
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/campaign"
+)
+
+type ListCampaignsQuery struct {
+	Status string `json:"status" validate:"omitempty,oneof=draft scheduled sending sent canceled"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+type CampaignPage struct {
+	Campaigns []*campaign.Campaign
+	Total     int64
+}
+
+type ListCampaignsQueryHandler struct {
+	campaignRepo campaign.Repository
+}
+
+func NewListCampaignsQueryHandler(campaignRepo campaign.Repository) *ListCampaignsQueryHandler {
+	return &ListCampaignsQueryHandler{campaignRepo: campaignRepo}
+}
+
+// Handle lists the campaigns, the most recently created first.
+func (h *ListCampaignsQueryHandler) Handle(ctx context.Context, query ListCampaignsQuery) (*CampaignPage, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListCampaignsQueryHandler) handle(ctx context.Context, query ListCampaignsQuery) (*CampaignPage, error) {
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+
+	campaigns, total, err := h.campaignRepo.List(ctx, campaign.Filter{
+		Status: campaign.Status(query.Status),
+		Limit:  query.Limit,
+		Offset: query.Offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &CampaignPage{Campaigns: campaigns, Total: total}, nil
+}
+
+type GetCampaignQuery struct {
+	CampaignID string `json:"campaign_id" validate:"required"`
+}
+
+type GetCampaignQueryHandler struct {
+	campaignRepo campaign.Repository
+}
+
+func NewGetCampaignQueryHandler(campaignRepo campaign.Repository) *GetCampaignQueryHandler {
+	return &GetCampaignQueryHandler{campaignRepo: campaignRepo}
+}
+
+func (h *GetCampaignQueryHandler) Handle(ctx context.Context, query GetCampaignQuery) (*campaign.Campaign, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetCampaignQueryHandler) handle(ctx context.Context, query GetCampaignQuery) (*campaign.Campaign, error) {
+	return h.campaignRepo.Get(ctx, query.CampaignID)
+}
+
+// GetCampaignStatsQuery reports the opens and clicks of a campaign against
+// the recipients it was queued for.
+type GetCampaignStatsQuery struct {
+	CampaignID string `json:"campaign_id" validate:"required"`
+}
+
+type GetCampaignStatsQueryHandler struct {
+	campaignRepo campaign.Repository
+}
+
+func NewGetCampaignStatsQueryHandler(campaignRepo campaign.Repository) *GetCampaignStatsQueryHandler {
+	return &GetCampaignStatsQueryHandler{campaignRepo: campaignRepo}
+}
+
+func (h *GetCampaignStatsQueryHandler) Handle(ctx context.Context, query GetCampaignStatsQuery) (*campaign.Stats, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetCampaignStatsQueryHandler) handle(ctx context.Context, query GetCampaignStatsQuery) (*campaign.Stats, error) {
+	c, err := h.campaignRepo.Get(ctx, query.CampaignID)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := h.campaignRepo.Stats(ctx, c.ID)
+	if err != nil {
+		return nil, err
+	}
+	stats.Rate(c.Recipients)
+	return stats, nil
+}
+
+// CountCampaignAudienceQuery counts the customers a campaign's segment
+// selects now, so admins can size it before scheduling.
+type CountCampaignAudienceQuery struct {
+	CampaignID string `json:"campaign_id" validate:"required"`
+}
+
+type CampaignAudience struct {
+	CampaignID string `json:"campaign_id"`
+	Recipients int64  `json:"recipients"`
+}
+
+type CountCampaignAudienceQueryHandler struct {
+	campaignRepo campaign.Repository
+	audienceRepo campaign.AudienceRepository
+}
+
+func NewCountCampaignAudienceQueryHandler(campaignRepo campaign.Repository, audienceRepo campaign.AudienceRepository) *CountCampaignAudienceQueryHandler {
+	return &CountCampaignAudienceQueryHandler{campaignRepo: campaignRepo, audienceRepo: audienceRepo}
+}
+
+func (h *CountCampaignAudienceQueryHandler) Handle(ctx context.Context, query CountCampaignAudienceQuery) (*CampaignAudience, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *CountCampaignAudienceQueryHandler) handle(ctx context.Context, query CountCampaignAudienceQuery) (*CampaignAudience, error) {
+	c, err := h.campaignRepo.Get(ctx, query.CampaignID)
+	if err != nil {
+		return nil, err
+	}
+	count, err := h.audienceRepo.CountAudience(ctx, c.Segment, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &CampaignAudience{CampaignID: c.ID, Recipients: count}, nil
+}
@@ -0,0 +1,86 @@
+package queries
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/cart"
+	"online-shop/internal/domain/product"
+)
+
+type GetCartQuery struct {
+	Owner cart.Owner `json:"-"`
+}
+
+// CartView is a cart with its products. Products that can no longer be
+// ordered stay in the cart, unavailable and out of the total, for the
+// customer to remove.
+type CartView struct {
+	Items     []CartLine `json:"items"`
+	Total     float64    `json:"total"`
+	UpdatedAt time.Time  `json:"updated_at,omitempty"`
+}
+
+// CartLine is priced at the product's price; flash sales and shipping are
+// applied at checkout.
+type CartLine struct {
+	cart.Item
+	Product   *product.Product `json:"product,omitempty"`
+	Available bool             `json:"available"`
+	Subtotal  float64          `json:"subtotal"`
+}
+
+type GetCartQueryHandler struct {
+	cartRepo    cart.Repository
+	productRepo product.Repository
+}
+
+func NewGetCartQueryHandler(cartRepo cart.Repository, productRepo product.Repository) *GetCartQueryHandler {
+	return &GetCartQueryHandler{cartRepo: cartRepo, productRepo: productRepo}
+}
+
+func (h *GetCartQueryHandler) Handle(ctx context.Context, query GetCartQuery) (*CartView, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetCartQueryHandler) handle(ctx context.Context, query GetCartQuery) (*CartView, error) {
+	view := &CartView{Items: []CartLine{}}
+	// A visitor without a cart token has no cart yet
+	if query.Owner.Anonymous() && query.Owner.Token == "" {
+		return view, nil
+	}
+
+	c, err := h.cartRepo.Get(ctx, query.Owner)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.Items) == 0 {
+		return view, nil
+	}
+
+	ids := make([]string, len(c.Items))
+	for i, item := range c.Items {
+		ids[i] = item.ProductID
+	}
+	products, err := h.productRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*product.Product, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+
+	view.UpdatedAt = c.UpdatedAt
+	for _, item := range c.Items {
+		line := CartLine{Item: *item, Product: byID[item.ProductID]}
+		if p := line.Product; p != nil && p.Status == product.StatusActive && (p.IsBundle() || p.Stock >= item.Quantity) {
+			line.Available = true
+			line.Subtotal = p.Price * float64(item.Quantity)
+			view.Total += line.Subtotal
+		}
+		view.Items = append(view.Items, line)
+	}
+	return view, nil
+}
@@ -0,0 +1,287 @@
+package queries
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/banner"
+	"online-shop/internal/domain/cms"
+)
+
+const (
+	// cmsBannerCandidates caps the banners the public list is picked from
+	cmsBannerCandidates = 200
+	// CMSAssetLinkTTL is how long the signed link an asset redirects to
+	// stays valid
+	CMSAssetLinkTTL = time.Hour
+)
+
+type ListCMSBlocksQuery struct {
+	Slot   string `json:"slot"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+type ListCMSPagesQuery struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+type GetCMSPageQuery struct {
+	PageID string `json:"page_id"`
+}
+
+type ListCMSAssetsQuery struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+type GetLiveBannersQuery struct{}
+
+type GetCMSSlotQuery struct {
+	Slot string `json:"slot"`
+}
+
+type GetPublishedPageQuery struct {
+	Slug string `json:"slug"`
+}
+
+type GetCMSAssetLinkQuery struct {
+	AssetID string `json:"asset_id"`
+}
+
+type ListCMSBlocksQueryHandler struct {
+	blockRepo cms.BlockRepository
+}
+
+func NewListCMSBlocksQueryHandler(blockRepo cms.BlockRepository) *ListCMSBlocksQueryHandler {
+	return &ListCMSBlocksQueryHandler{blockRepo: blockRepo}
+}
+
+func (h *ListCMSBlocksQueryHandler) Handle(ctx context.Context, query ListCMSBlocksQuery) ([]*cms.Block, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListCMSBlocksQueryHandler) handle(ctx context.Context, query ListCMSBlocksQuery) ([]*cms.Block, error) {
+	if query.Limit <= 0 {
+		query.Limit = 50
+	}
+	return h.blockRepo.List(ctx, query.Slot, query.Limit, query.Offset)
+}
+
+type ListCMSPagesQueryHandler struct {
+	pageRepo cms.PageRepository
+}
+
+func NewListCMSPagesQueryHandler(pageRepo cms.PageRepository) *ListCMSPagesQueryHandler {
+	return &ListCMSPagesQueryHandler{pageRepo: pageRepo}
+}
+
+func (h *ListCMSPagesQueryHandler) Handle(ctx context.Context, query ListCMSPagesQuery) ([]*cms.Page, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListCMSPagesQueryHandler) handle(ctx context.Context, query ListCMSPagesQuery) ([]*cms.Page, error) {
+	if query.Limit <= 0 {
+		query.Limit = 50
+	}
+	return h.pageRepo.List(ctx, query.Limit, query.Offset)
+}
+
+// GetCMSPageQueryHandler reads a page for the admin API, drafts included.
+type GetCMSPageQueryHandler struct {
+	pageRepo cms.PageRepository
+}
+
+func NewGetCMSPageQueryHandler(pageRepo cms.PageRepository) *GetCMSPageQueryHandler {
+	return &GetCMSPageQueryHandler{pageRepo: pageRepo}
+}
+
+func (h *GetCMSPageQueryHandler) Handle(ctx context.Context, query GetCMSPageQuery) (*cms.Page, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetCMSPageQueryHandler) handle(ctx context.Context, query GetCMSPageQuery) (*cms.Page, error) {
+	return h.pageRepo.GetByID(ctx, query.PageID)
+}
+
+type ListCMSAssetsQueryHandler struct {
+	assetRepo cms.AssetRepository
+}
+
+func NewListCMSAssetsQueryHandler(assetRepo cms.AssetRepository) *ListCMSAssetsQueryHandler {
+	return &ListCMSAssetsQueryHandler{assetRepo: assetRepo}
+}
+
+func (h *ListCMSAssetsQueryHandler) Handle(ctx context.Context, query ListCMSAssetsQuery) ([]*cms.Asset, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListCMSAssetsQueryHandler) handle(ctx context.Context, query ListCMSAssetsQuery) ([]*cms.Asset, error) {
+	if query.Limit <= 0 {
+		query.Limit = 50
+	}
+	return h.assetRepo.List(ctx, query.Limit, query.Offset)
+}
+
+// The public handlers below cache what could go live rather than what is
+// live, and pick the live content on every read, so a scheduled start or
+// end takes effect on time instead of when the entry expires.
+
+type GetLiveBannersQueryHandler struct {
+	bannerRepo banner.Repository
+	cache      cms.Cache
+	ttl        time.Duration
+}
+
+func NewGetLiveBannersQueryHandler(bannerRepo banner.Repository, cache cms.Cache, ttl time.Duration) *GetLiveBannersQueryHandler {
+	return &GetLiveBannersQueryHandler{bannerRepo: bannerRepo, cache: cache, ttl: ttl}
+}
+
+func (h *GetLiveBannersQueryHandler) Handle(ctx context.Context, query GetLiveBannersQuery) ([]*banner.Banner, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetLiveBannersQueryHandler) handle(ctx context.Context, query GetLiveBannersQuery) ([]*banner.Banner, error) {
+	now := time.Now()
+	var candidates []*banner.Banner
+	err := readThroughCMS(ctx, h.cache, h.ttl, "banners", &candidates, func() error {
+		banners, err := h.bannerRepo.List(ctx, cmsBannerCandidates, 0)
+		for _, b := range banners {
+			if b.Active && (b.EndsAt == nil || b.EndsAt.After(now)) {
+				candidates = append(candidates, b)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	live := []*banner.Banner{}
+	for _, b := range candidates {
+		if b.Live(now) {
+			live = append(live, b)
+		}
+	}
+	return live, nil
+}
+
+type GetCMSSlotQueryHandler struct {
+	blockRepo cms.BlockRepository
+	cache     cms.Cache
+	ttl       time.Duration
+}
+
+func NewGetCMSSlotQueryHandler(blockRepo cms.BlockRepository, cache cms.Cache, ttl time.Duration) *GetCMSSlotQueryHandler {
+	return &GetCMSSlotQueryHandler{blockRepo: blockRepo, cache: cache, ttl: ttl}
+}
+
+// Handle returns the live blocks of a slot in position order; an unknown
+// slot is just empty.
+func (h *GetCMSSlotQueryHandler) Handle(ctx context.Context, query GetCMSSlotQuery) ([]*cms.Block, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetCMSSlotQueryHandler) handle(ctx context.Context, query GetCMSSlotQuery) ([]*cms.Block, error) {
+	now := time.Now()
+	var candidates []*cms.Block
+	err := readThroughCMS(ctx, h.cache, h.ttl, "blocks:"+query.Slot, &candidates, func() error {
+		var err error
+		candidates, err = h.blockRepo.ListActive(ctx, query.Slot, now)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	live := []*cms.Block{}
+	for _, b := range candidates {
+		if b.Live(now) {
+			live = append(live, b)
+		}
+	}
+	return live, nil
+}
+
+type GetPublishedPageQueryHandler struct {
+	pageRepo cms.PageRepository
+	cache    cms.Cache
+	ttl      time.Duration
+}
+
+func NewGetPublishedPageQueryHandler(pageRepo cms.PageRepository, cache cms.Cache, ttl time.Duration) *GetPublishedPageQueryHandler {
+	return &GetPublishedPageQueryHandler{pageRepo: pageRepo, cache: cache, ttl: ttl}
+}
+
+// Handle returns ErrPageNotFound for drafts and pages outside their window,
+// so they cannot be told apart from missing ones.
+func (h *GetPublishedPageQueryHandler) Handle(ctx context.Context, query GetPublishedPageQuery) (*cms.Page, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetPublishedPageQueryHandler) handle(ctx context.Context, query GetPublishedPageQuery) (*cms.Page, error) {
+	var page *cms.Page
+	err := readThroughCMS(ctx, h.cache, h.ttl, "page:"+query.Slug, &page, func() error {
+		var err error
+		page, err = h.pageRepo.GetBySlug(ctx, query.Slug)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !page.Live(time.Now()) {
+		return nil, cms.ErrPageNotFound
+	}
+	return page, nil
+}
+
+// GetCMSAssetLinkQueryHandler signs a short-lived link to an asset's file.
+// Content keeps linking to the asset through the API, so the file's own
+// link never has to be public or permanent.
+type GetCMSAssetLinkQueryHandler struct {
+	assetRepo cms.AssetRepository
+	storage   cms.Storage
+	cache     cms.Cache
+	ttl       time.Duration
+}
+
+func NewGetCMSAssetLinkQueryHandler(assetRepo cms.AssetRepository, storage cms.Storage, cache cms.Cache, ttl time.Duration) *GetCMSAssetLinkQueryHandler {
+	return &GetCMSAssetLinkQueryHandler{assetRepo: assetRepo, storage: storage, cache: cache, ttl: ttl}
+}
+
+func (h *GetCMSAssetLinkQueryHandler) Handle(ctx context.Context, query GetCMSAssetLinkQuery) (string, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetCMSAssetLinkQueryHandler) handle(ctx context.Context, query GetCMSAssetLinkQuery) (string, error) {
+	// Only the storage key is cached, as the asset's JSON leaves it out
+	var key string
+	err := readThroughCMS(ctx, h.cache, h.ttl, "asset:"+query.AssetID, &key, func() error {
+		asset, err := h.assetRepo.Get(ctx, query.AssetID)
+		if err != nil {
+			return err
+		}
+		key = asset.Key
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return h.storage.SignedURL(key, CMSAssetLinkTTL)
+}
+
+// readThroughCMS fills dest from the cache, or with load on a miss and then
+// caches it. When the cache cannot be reached the content is still served
+// from the database.
+func readThroughCMS(ctx context.Context, cache cms.Cache, ttl time.Duration, key string, dest interface{}, load func() error) error {
+	if found, err := cache.Get(ctx, key, dest); err == nil && found {
+		return nil
+	}
+	if err := load(); err != nil {
+		return err
+	}
+	_ = cache.Set(ctx, key, dest, ttl)
+	return nil
+}
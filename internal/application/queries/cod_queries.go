@@ -0,0 +1,162 @@
+package queries
+
+import (
+	"context"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/cod"
+)
+
+// ListCODCollectionsQuery lists cash on delivery collections, such as the
+// cash a courier holds with status collected.
+type ListCODCollectionsQuery struct {
+	Status  string `json:"status" validate:"omitempty,oneof=awaiting_delivery collected settled"`
+	Carrier string `json:"carrier"`
+	Limit   int    `json:"limit"`
+	Offset  int    `json:"offset"`
+}
+
+type CODCollectionPage struct {
+	Collections []*cod.Collection
+	Total       int64
+}
+
+type ListCODCollectionsQueryHandler struct {
+	codRepo cod.Repository
+}
+
+func NewListCODCollectionsQueryHandler(codRepo cod.Repository) *ListCODCollectionsQueryHandler {
+	return &ListCODCollectionsQueryHandler{codRepo: codRepo}
+}
+
+func (h *ListCODCollectionsQueryHandler) Handle(ctx context.Context, query ListCODCollectionsQuery) (*CODCollectionPage, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListCODCollectionsQueryHandler) handle(ctx context.Context, query ListCODCollectionsQuery) (*CODCollectionPage, error) {
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+
+	filter := cod.CollectionFilter{Status: cod.Status(query.Status), Carrier: query.Carrier}
+	collections, total, err := h.codRepo.List(ctx, filter, query.Limit, query.Offset)
+	if err != nil {
+		return nil, err
+	}
+	return &CODCollectionPage{Collections: collections, Total: total}, nil
+}
+
+type ListCourierBalancesQuery struct{}
+
+type ListCourierBalancesQueryHandler struct {
+	codRepo cod.Repository
+}
+
+func NewListCourierBalancesQueryHandler(codRepo cod.Repository) *ListCourierBalancesQueryHandler {
+	return &ListCourierBalancesQueryHandler{codRepo: codRepo}
+}
+
+// Handle returns the cash each courier has collected and not remitted.
+func (h *ListCourierBalancesQueryHandler) Handle(ctx context.Context, query ListCourierBalancesQuery) ([]cod.CourierBalance, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListCourierBalancesQueryHandler) handle(ctx context.Context, query ListCourierBalancesQuery) ([]cod.CourierBalance, error) {
+	balances, err := h.codRepo.Balances(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if balances == nil {
+		balances = []cod.CourierBalance{}
+	}
+	return balances, nil
+}
+
+type ListRemittancesQuery struct {
+	Carrier string `json:"carrier"`
+	Limit   int    `json:"limit"`
+	Offset  int    `json:"offset"`
+}
+
+type ListRemittancesQueryHandler struct {
+	codRepo cod.Repository
+}
+
+func NewListRemittancesQueryHandler(codRepo cod.Repository) *ListRemittancesQueryHandler {
+	return &ListRemittancesQueryHandler{codRepo: codRepo}
+}
+
+func (h *ListRemittancesQueryHandler) Handle(ctx context.Context, query ListRemittancesQuery) ([]*cod.Remittance, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListRemittancesQueryHandler) handle(ctx context.Context, query ListRemittancesQuery) ([]*cod.Remittance, error) {
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+	return h.codRepo.ListRemittances(ctx, query.Carrier, query.Limit, query.Offset)
+}
+
+type GetRemittanceQuery struct {
+	ID string `json:"id"`
+}
+
+type GetRemittanceQueryHandler struct {
+	codRepo cod.Repository
+}
+
+func NewGetRemittanceQueryHandler(codRepo cod.Repository) *GetRemittanceQueryHandler {
+	return &GetRemittanceQueryHandler{codRepo: codRepo}
+}
+
+func (h *GetRemittanceQueryHandler) Handle(ctx context.Context, query GetRemittanceQuery) (*cod.Remittance, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetRemittanceQueryHandler) handle(ctx context.Context, query GetRemittanceQuery) (*cod.Remittance, error) {
+	return h.codRepo.GetRemittance(ctx, query.ID)
+}
+
+type GetCODCustomerQuery struct {
+	UserID string `json:"user_id"`
+}
+
+// CODCustomer is how much a customer may order for payment on delivery and
+// how much they already owe that way.
+type CODCustomer struct {
+	UserID   string             `json:"user_id"`
+	Override *cod.CustomerLimit `json:"override"`
+	Limits   cod.Limits         `json:"limits"`
+	Exposure cod.Exposure       `json:"exposure"`
+}
+
+type GetCODCustomerQueryHandler struct {
+	codRepo   cod.Repository
+	limitRepo cod.LimitRepository
+	limits    cod.Limits
+}
+
+func NewGetCODCustomerQueryHandler(codRepo cod.Repository, limitRepo cod.LimitRepository, limits cod.Limits) *GetCODCustomerQueryHandler {
+	return &GetCODCustomerQueryHandler{codRepo: codRepo, limitRepo: limitRepo, limits: limits}
+}
+
+func (h *GetCODCustomerQueryHandler) Handle(ctx context.Context, query GetCODCustomerQuery) (*CODCustomer, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetCODCustomerQueryHandler) handle(ctx context.Context, query GetCODCustomerQuery) (*CODCustomer, error) {
+	override, err := h.limitRepo.Get(ctx, query.UserID)
+	if err != nil {
+		return nil, err
+	}
+	exposure, err := h.codRepo.Exposure(ctx, query.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return &CODCustomer{
+		UserID:   query.UserID,
+		Override: override,
+		Limits:   h.limits.For(override),
+		Exposure: exposure,
+	}, nil
+}
@@ -0,0 +1,32 @@
+package queries
+
+import (
+	"context"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/commission"
+)
+
+type ListCommissionRulesQuery struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+type ListCommissionRulesQueryHandler struct {
+	commissionRepo commission.Repository
+}
+
+func NewListCommissionRulesQueryHandler(commissionRepo commission.Repository) *ListCommissionRulesQueryHandler {
+	return &ListCommissionRulesQueryHandler{commissionRepo: commissionRepo}
+}
+
+func (h *ListCommissionRulesQueryHandler) Handle(ctx context.Context, query ListCommissionRulesQuery) ([]*commission.Rule, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListCommissionRulesQueryHandler) handle(ctx context.Context, query ListCommissionRulesQuery) ([]*commission.Rule, error) {
+	if query.Limit <= 0 {
+		query.Limit = 50
+	}
+	return h.commissionRepo.List(ctx, query.Limit, query.Offset)
+}
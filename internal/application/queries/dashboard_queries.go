@@ -0,0 +1,42 @@
+package queries
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/dashboard"
+)
+
+type GetDashboardStatsQuery struct{}
+
+type GetDashboardStatsQueryHandler struct {
+	cache             dashboard.Cache
+	dashboardRepo     dashboard.Repository
+	lowStockThreshold int
+}
+
+func NewGetDashboardStatsQueryHandler(cache dashboard.Cache, dashboardRepo dashboard.Repository, lowStockThreshold int) *GetDashboardStatsQueryHandler {
+	return &GetDashboardStatsQueryHandler{
+		cache:             cache,
+		dashboardRepo:     dashboardRepo,
+		lowStockThreshold: lowStockThreshold,
+	}
+}
+
+// Handle serves the snapshot kept warm by the refresh job. A snapshot from a
+// previous day is never returned; on a miss the stats are computed directly.
+func (h *GetDashboardStatsQueryHandler) Handle(ctx context.Context, query GetDashboardStatsQuery) (*dashboard.Stats, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetDashboardStatsQueryHandler) handle(ctx context.Context, query GetDashboardStatsQuery) (*dashboard.Stats, error) {
+	today := dashboard.StartOfDay(time.Now())
+
+	stats, err := h.cache.Load(ctx)
+	if err == nil && !stats.Since.Before(today) {
+		return stats, nil
+	}
+
+	return h.dashboardRepo.Compute(ctx, today, h.lowStockThreshold)
+}
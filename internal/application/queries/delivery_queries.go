@@ -0,0 +1,136 @@
+package queries
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/shipping"
+	"online-shop/internal/domain/warehouse"
+)
+
+// GetDeliveryEstimateQuery asks when one of a product ordered now arrives
+// in the area of a postal code, for product pages.
+type GetDeliveryEstimateQuery struct {
+	ProductID  string `json:"product_id" validate:"required"`
+	Country    string `json:"country" validate:"required,len=2"`
+	State      string `json:"state" validate:"max=100"`
+	PostalCode string `json:"postal_code" validate:"max=20"`
+}
+
+type GetDeliveryEstimateQueryHandler struct {
+	productRepo   product.Repository
+	zoneRepo      shipping.Repository
+	warehouseRepo warehouse.Repository
+	stockRepo     warehouse.StockRepository
+	schedule      *shipping.Schedule
+	processing    shipping.Processing
+}
+
+// NewGetDeliveryEstimateQueryHandler estimates from the warehouses holding
+// the products, and from processing for stores without warehouses.
+func NewGetDeliveryEstimateQueryHandler(
+	productRepo product.Repository,
+	zoneRepo shipping.Repository,
+	warehouseRepo warehouse.Repository,
+	stockRepo warehouse.StockRepository,
+	schedule *shipping.Schedule,
+	processing shipping.Processing,
+) *GetDeliveryEstimateQueryHandler {
+	return &GetDeliveryEstimateQueryHandler{
+		productRepo:   productRepo,
+		zoneRepo:      zoneRepo,
+		warehouseRepo: warehouseRepo,
+		stockRepo:     stockRepo,
+		schedule:      schedule,
+		processing:    processing,
+	}
+}
+
+// Handle quotes the rates of the zone the area falls in, each with when it
+// delivers. The area is not geocoded: the estimate is only as close as the
+// country, state and postal code given.
+func (h *GetDeliveryEstimateQueryHandler) Handle(ctx context.Context, query GetDeliveryEstimateQuery) (*shipping.Quote, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetDeliveryEstimateQueryHandler) handle(ctx context.Context, query GetDeliveryEstimateQuery) (*shipping.Quote, error) {
+	prod, err := h.productRepo.GetByID(ctx, query.ProductID)
+	if err != nil {
+		return nil, err
+	}
+
+	zones, err := h.zoneRepo.ListActive(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(zones) == 0 {
+		return &shipping.Quote{Options: []shipping.Option{}}, nil
+	}
+	zone := shipping.Match(zones, order.Address{
+		Country:    strings.ToUpper(query.Country),
+		State:      query.State,
+		PostalCode: query.PostalCode,
+	})
+	if zone == nil {
+		return nil, shipping.ErrNotServiceable
+	}
+
+	quote := shipping.NewQuote(zone, prod.Price)
+	if err := h.EstimateQuote(ctx, quote, prod.StockedIDs()); err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
+
+// EstimateQuote makes the handler the shipping.Estimator of quotes. Each
+// product is taken to leave from the quickest warehouse holding it, and the
+// order to arrive once the slowest of them has dispatched it.
+func (h *GetDeliveryEstimateQueryHandler) EstimateQuote(ctx context.Context, quote *shipping.Quote, productIDs []string) error {
+	now := time.Now()
+	processing, err := h.dispatch(ctx, productIDs, now)
+	if err != nil {
+		return err
+	}
+	h.schedule.EstimateOptions(quote, now, processing)
+	return nil
+}
+
+func (h *GetDeliveryEstimateQueryHandler) dispatch(ctx context.Context, productIDs []string, now time.Time) (shipping.Processing, error) {
+	warehouses, err := h.warehouseRepo.ListActive(ctx)
+	if err != nil {
+		return shipping.Processing{}, err
+	}
+	if len(warehouses) == 0 || len(productIDs) == 0 {
+		return h.processing, nil
+	}
+	stock, err := h.stockRepo.GetByProducts(ctx, productIDs)
+	if err != nil {
+		return shipping.Processing{}, err
+	}
+
+	byID := make(map[string]*warehouse.Warehouse, len(warehouses))
+	for _, w := range warehouses {
+		byID[w.ID] = w
+	}
+	holding := make(map[string][]shipping.Processing)
+	for _, s := range stock {
+		if w, ok := byID[s.WarehouseID]; ok && s.Available() > 0 {
+			holding[s.ProductID] = append(holding[s.ProductID], w.Processing(h.processing))
+		}
+	}
+
+	quickest := make([]shipping.Processing, 0, len(productIDs))
+	for _, productID := range productIDs {
+		if ps := holding[productID]; len(ps) > 0 {
+			quickest = append(quickest, h.schedule.Fastest(now, ps))
+		}
+	}
+	if len(quickest) == 0 {
+		return h.processing, nil
+	}
+	return h.schedule.Slowest(now, quickest), nil
+}
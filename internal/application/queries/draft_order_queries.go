@@ -0,0 +1,195 @@
+package queries
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/draftorder"
+	"online-shop/internal/domain/emailtemplate"
+	"online-shop/pkg/i18n"
+)
+
+// ListDraftOrdersQuery lists a merchant's drafts when MerchantID is set;
+// admins leave it empty and see every draft.
+type ListDraftOrdersQuery struct {
+	MerchantID string            `json:"merchant_id"`
+	Status     draftorder.Status `json:"status" validate:"omitempty,oneof=open sent completed cancelled expired failed"`
+	Limit      int               `json:"limit"`
+	Offset     int               `json:"offset"`
+}
+
+type ListDraftOrdersQueryHandler struct {
+	draftRepo draftorder.Repository
+}
+
+func NewListDraftOrdersQueryHandler(draftRepo draftorder.Repository) *ListDraftOrdersQueryHandler {
+	return &ListDraftOrdersQueryHandler{draftRepo: draftRepo}
+}
+
+// Handle lists the drafts newest first. Lapsed drafts are listed as
+// expired; they are stored as open or sent, so asking for those lists them
+// too.
+func (h *ListDraftOrdersQueryHandler) Handle(ctx context.Context, query ListDraftOrdersQuery) ([]*draftorder.Draft, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListDraftOrdersQueryHandler) handle(ctx context.Context, query ListDraftOrdersQuery) ([]*draftorder.Draft, error) {
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+	drafts, err := h.draftRepo.List(ctx, draftorder.Filter{MerchantID: query.MerchantID, Status: query.Status}, query.Limit, query.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, d := range drafts {
+		d.ExpireAt(now)
+	}
+	return drafts, nil
+}
+
+// GetDraftOrderQuery reads a draft as the merchant who drafted it, when
+// MerchantID is set; other merchants' drafts are not found.
+type GetDraftOrderQuery struct {
+	DraftOrderID string `json:"draft_order_id" validate:"required"`
+	MerchantID   string `json:"merchant_id"`
+}
+
+type GetDraftOrderQueryHandler struct {
+	draftRepo draftorder.Repository
+}
+
+func NewGetDraftOrderQueryHandler(draftRepo draftorder.Repository) *GetDraftOrderQueryHandler {
+	return &GetDraftOrderQueryHandler{draftRepo: draftRepo}
+}
+
+func (h *GetDraftOrderQueryHandler) Handle(ctx context.Context, query GetDraftOrderQuery) (*draftorder.Draft, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetDraftOrderQueryHandler) handle(ctx context.Context, query GetDraftOrderQuery) (*draftorder.Draft, error) {
+	d, err := h.draftRepo.GetByID(ctx, query.DraftOrderID)
+	if err != nil {
+		return nil, err
+	}
+	if query.MerchantID != "" && d.MerchantID != query.MerchantID {
+		return nil, commands.ErrDraftOrderNotFound
+	}
+	d.ExpireAt(time.Now())
+	return d, nil
+}
+
+// GetDraftOrderSummaryQuery reads the draft paid through the link with
+// Token. With HTML, the summary is also rendered as the page the API hosts
+// for the link, whose form posts the chosen payment method to PayURL.
+type GetDraftOrderSummaryQuery struct {
+	Token  string `json:"token" validate:"required"`
+	Locale string `json:"locale"`
+	HTML   bool   `json:"html"`
+	PayURL string `json:"pay_url"`
+}
+
+// DraftOrderSummary is what the customer is shown of a draft: what it is
+// for and what it costs, without who drafted it.
+type DraftOrderSummary struct {
+	Status      draftorder.Status `json:"status"`
+	Items       []draftorder.Item `json:"items"`
+	Subtotal    float64           `json:"subtotal"`
+	ShippingFee float64           `json:"shipping_fee"`
+	GiftWrap    bool              `json:"gift_wrap,omitempty"`
+	GiftWrapFee float64           `json:"gift_wrap_fee,omitempty"`
+	GiftMessage string            `json:"gift_message,omitempty"`
+	Note        string            `json:"note,omitempty"`
+	TotalAmount float64           `json:"total_amount"`
+	ExpiresAt   time.Time         `json:"expires_at"`
+	// Payable is whether a payment can be started through the link
+	Payable bool `json:"payable"`
+	// HTML is the hosted page, when it was asked for
+	HTML string `json:"-"`
+}
+
+type GetDraftOrderSummaryQueryHandler struct {
+	draftRepo    draftorder.Repository
+	templateRepo emailtemplate.Repository
+}
+
+func NewGetDraftOrderSummaryQueryHandler(draftRepo draftorder.Repository, templateRepo emailtemplate.Repository) *GetDraftOrderSummaryQueryHandler {
+	return &GetDraftOrderSummaryQueryHandler{draftRepo: draftRepo, templateRepo: templateRepo}
+}
+
+// Handle summarizes the draft for whoever has its link. The page is
+// rendered with the shop's draft_order_page template, so it can be styled
+// like the shop's emails.
+func (h *GetDraftOrderSummaryQueryHandler) Handle(ctx context.Context, query GetDraftOrderSummaryQuery) (*DraftOrderSummary, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetDraftOrderSummaryQueryHandler) handle(ctx context.Context, query GetDraftOrderSummaryQuery) (*DraftOrderSummary, error) {
+	d, err := h.draftRepo.GetByToken(ctx, query.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	// A lapsed draft is summarized as expired
+	payable := d.CheckPayable(time.Now()) == nil
+	summary := &DraftOrderSummary{
+		Status:      d.Status,
+		Items:       d.Items,
+		ShippingFee: d.ShippingFee,
+		GiftWrap:    d.GiftWrap,
+		GiftWrapFee: d.GiftWrapFee,
+		GiftMessage: d.GiftMessage,
+		Note:        d.Note,
+		TotalAmount: d.TotalAmount,
+		ExpiresAt:   d.ExpiresAt,
+		Payable:     payable,
+	}
+	for _, item := range d.Items {
+		summary.Subtotal += item.Price * float64(item.Quantity)
+	}
+	if !query.HTML {
+		return summary, nil
+	}
+
+	items := make([]map[string]interface{}, len(d.Items))
+	for i, item := range d.Items {
+		items[i] = map[string]interface{}{
+			"ProductName": item.Name,
+			"Quantity":    item.Quantity,
+			"UnitPrice":   item.Price,
+			"TotalPrice":  item.Price * float64(item.Quantity),
+		}
+	}
+	data := map[string]interface{}{
+		"Items":       items,
+		"Subtotal":    summary.Subtotal,
+		"GiftWrap":    d.GiftWrap,
+		"GiftWrapFee": d.GiftWrapFee,
+		"GiftMessage": d.GiftMessage,
+		"Note":        d.Note,
+		"TotalAmount": d.TotalAmount,
+		"ExpiresAt":   d.ExpiresAt.Format("January 2, 2006"),
+	}
+	if d.ShippingFee > 0 {
+		data["ShippingAmount"] = d.ShippingFee
+	}
+	if summary.Payable {
+		data["PayURL"] = query.PayURL
+	}
+
+	locale := i18n.Negotiate("", query.Locale)
+	t, err := emailtemplate.Resolve(ctx, h.templateRepo, "draft_order_page", locale, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.CheckData(data); err != nil {
+		return nil, err
+	}
+	if _, summary.HTML, err = t.Render(locale, data); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
@@ -0,0 +1,101 @@
+package queries
+
+import (
+	"context"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/emaildelivery"
+)
+
+type ListEmailSuppressionsQuery struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+type GetEmailSuppressionQuery struct {
+	Email string `json:"email"`
+}
+
+type ListEmailSuppressionsQueryHandler struct {
+	suppressions emaildelivery.SuppressionRepository
+}
+
+func NewListEmailSuppressionsQueryHandler(suppressions emaildelivery.SuppressionRepository) *ListEmailSuppressionsQueryHandler {
+	return &ListEmailSuppressionsQueryHandler{suppressions: suppressions}
+}
+
+// Handle lists suppressions, most recent first
+func (h *ListEmailSuppressionsQueryHandler) Handle(ctx context.Context, query ListEmailSuppressionsQuery) ([]*emaildelivery.Suppression, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListEmailSuppressionsQueryHandler) handle(ctx context.Context, query ListEmailSuppressionsQuery) ([]*emaildelivery.Suppression, error) {
+	if query.Limit <= 0 {
+		query.Limit = 50
+	}
+	return h.suppressions.List(ctx, query.Limit, query.Offset)
+}
+
+type GetEmailSuppressionQueryHandler struct {
+	suppressions emaildelivery.SuppressionRepository
+}
+
+func NewGetEmailSuppressionQueryHandler(suppressions emaildelivery.SuppressionRepository) *GetEmailSuppressionQueryHandler {
+	return &GetEmailSuppressionQueryHandler{suppressions: suppressions}
+}
+
+func (h *GetEmailSuppressionQueryHandler) Handle(ctx context.Context, query GetEmailSuppressionQuery) (*emaildelivery.Suppression, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetEmailSuppressionQueryHandler) handle(ctx context.Context, query GetEmailSuppressionQuery) (*emaildelivery.Suppression, error) {
+	return h.suppressions.Get(ctx, emaildelivery.NormalizeAddress(query.Email))
+}
+
+// ListEmailDeadLettersQuery lists dead letters; Pending leaves out the ones
+// already resent.
+type ListEmailDeadLettersQuery struct {
+	Pending bool `json:"pending"`
+	Limit   int  `json:"limit"`
+	Offset  int  `json:"offset"`
+}
+
+type GetEmailDeadLetterQuery struct {
+	ID string `json:"id"`
+}
+
+type ListEmailDeadLettersQueryHandler struct {
+	deadLetters emaildelivery.DeadLetterRepository
+}
+
+func NewListEmailDeadLettersQueryHandler(deadLetters emaildelivery.DeadLetterRepository) *ListEmailDeadLettersQueryHandler {
+	return &ListEmailDeadLettersQueryHandler{deadLetters: deadLetters}
+}
+
+// Handle lists dead letters, most recent first
+func (h *ListEmailDeadLettersQueryHandler) Handle(ctx context.Context, query ListEmailDeadLettersQuery) ([]*emaildelivery.DeadLetter, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListEmailDeadLettersQueryHandler) handle(ctx context.Context, query ListEmailDeadLettersQuery) ([]*emaildelivery.DeadLetter, error) {
+	if query.Limit <= 0 {
+		query.Limit = 50
+	}
+	return h.deadLetters.List(ctx, query.Pending, query.Limit, query.Offset)
+}
+
+type GetEmailDeadLetterQueryHandler struct {
+	deadLetters emaildelivery.DeadLetterRepository
+}
+
+func NewGetEmailDeadLetterQueryHandler(deadLetters emaildelivery.DeadLetterRepository) *GetEmailDeadLetterQueryHandler {
+	return &GetEmailDeadLetterQueryHandler{deadLetters: deadLetters}
+}
+
+func (h *GetEmailDeadLetterQueryHandler) Handle(ctx context.Context, query GetEmailDeadLetterQuery) (*emaildelivery.DeadLetter, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetEmailDeadLetterQueryHandler) handle(ctx context.Context, query GetEmailDeadLetterQuery) (*emaildelivery.DeadLetter, error) {
+	return h.deadLetters.Get(ctx, query.ID)
+}
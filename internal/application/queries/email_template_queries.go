@@ -0,0 +1,180 @@
+package queries
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/emailtemplate"
+	"online-shop/pkg/i18n"
+)
+
+type ListEmailTemplatesQuery struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// GetEmailTemplateQuery finds the template to send. Version 0 is the active
+// version, or the embedded default when none is stored.
+type GetEmailTemplateQuery struct {
+	Name    string `json:"name"`
+	Locale  string `json:"locale"`
+	Version int    `json:"version"`
+}
+
+type ListEmailTemplateVersionsQuery struct {
+	Name   string `json:"name"`
+	Locale string `json:"locale"`
+}
+
+// PreviewEmailTemplateQuery renders a template without sending it. With a
+// body it previews that draft, otherwise the stored version or default.
+// Data left out is filled in from the variables' examples.
+type PreviewEmailTemplateQuery struct {
+	Name      string                   `json:"name"`
+	Locale    string                   `json:"locale" validate:"omitempty,locale"`
+	Version   int                      `json:"version" validate:"min=0"`
+	Subject   string                   `json:"subject"`
+	Body      string                   `json:"body"`
+	Variables []emailtemplate.Variable `json:"variables"`
+	Data      map[string]interface{}   `json:"data"`
+}
+
+// EmailTemplatePreview is a rendered template.
+type EmailTemplatePreview struct {
+	Name    string                 `json:"name"`
+	Locale  string                 `json:"locale"`
+	Version int                    `json:"version"`
+	Subject string                 `json:"subject"`
+	Body    string                 `json:"body"`
+	Data    map[string]interface{} `json:"data"`
+}
+
+type ListEmailTemplatesQueryHandler struct {
+	templateRepo emailtemplate.Repository
+}
+
+func NewListEmailTemplatesQueryHandler(templateRepo emailtemplate.Repository) *ListEmailTemplatesQueryHandler {
+	return &ListEmailTemplatesQueryHandler{templateRepo: templateRepo}
+}
+
+func (h *ListEmailTemplatesQueryHandler) Handle(ctx context.Context, query ListEmailTemplatesQuery) ([]*emailtemplate.Template, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListEmailTemplatesQueryHandler) handle(ctx context.Context, query ListEmailTemplatesQuery) ([]*emailtemplate.Template, error) {
+	if query.Limit <= 0 {
+		query.Limit = 50
+	}
+	return h.templateRepo.List(ctx, query.Limit, query.Offset)
+}
+
+// GetEmailTemplateQueryHandler reads active versions through the cache, as
+// the email worker looks a template up for every email it sends.
+type GetEmailTemplateQueryHandler struct {
+	templateRepo  emailtemplate.Repository
+	templateCache emailtemplate.Cache
+	ttl           time.Duration
+}
+
+func NewGetEmailTemplateQueryHandler(templateRepo emailtemplate.Repository, templateCache emailtemplate.Cache, ttl time.Duration) *GetEmailTemplateQueryHandler {
+	return &GetEmailTemplateQueryHandler{templateRepo: templateRepo, templateCache: templateCache, ttl: ttl}
+}
+
+func (h *GetEmailTemplateQueryHandler) Handle(ctx context.Context, query GetEmailTemplateQuery) (*emailtemplate.Template, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetEmailTemplateQueryHandler) handle(ctx context.Context, query GetEmailTemplateQuery) (*emailtemplate.Template, error) {
+	locale := i18n.Negotiate("", query.Locale)
+	if query.Version > 0 {
+		return h.templateRepo.GetVersion(ctx, query.Name, locale, query.Version)
+	}
+
+	t, found, err := h.templateCache.Get(ctx, query.Name, locale)
+	if err != nil || !found {
+		t, err = h.templateRepo.Active(ctx, query.Name, locale)
+		if err != nil && err != emailtemplate.ErrNotFound {
+			return nil, err
+		}
+		// Cache misses too, so emails without a stored template do not
+		// query the database every time
+		_ = h.templateCache.Set(ctx, query.Name, locale, t, h.ttl)
+	}
+
+	if t == nil {
+		if d, ok := emailtemplate.Default(query.Name); ok {
+			return d, nil
+		}
+		return nil, emailtemplate.ErrNotFound
+	}
+	return t, nil
+}
+
+type ListEmailTemplateVersionsQueryHandler struct {
+	templateRepo emailtemplate.Repository
+}
+
+func NewListEmailTemplateVersionsQueryHandler(templateRepo emailtemplate.Repository) *ListEmailTemplateVersionsQueryHandler {
+	return &ListEmailTemplateVersionsQueryHandler{templateRepo: templateRepo}
+}
+
+func (h *ListEmailTemplateVersionsQueryHandler) Handle(ctx context.Context, query ListEmailTemplateVersionsQuery) ([]*emailtemplate.Template, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListEmailTemplateVersionsQueryHandler) handle(ctx context.Context, query ListEmailTemplateVersionsQuery) ([]*emailtemplate.Template, error) {
+	versions, err := h.templateRepo.Versions(ctx, query.Name, i18n.Negotiate("", query.Locale))
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, emailtemplate.ErrNotFound
+	}
+	return versions, nil
+}
+
+type PreviewEmailTemplateQueryHandler struct {
+	templateRepo emailtemplate.Repository
+}
+
+func NewPreviewEmailTemplateQueryHandler(templateRepo emailtemplate.Repository) *PreviewEmailTemplateQueryHandler {
+	return &PreviewEmailTemplateQueryHandler{templateRepo: templateRepo}
+}
+
+func (h *PreviewEmailTemplateQueryHandler) Handle(ctx context.Context, query PreviewEmailTemplateQuery) (*EmailTemplatePreview, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *PreviewEmailTemplateQueryHandler) handle(ctx context.Context, query PreviewEmailTemplateQuery) (*EmailTemplatePreview, error) {
+	locale := i18n.Negotiate("", query.Locale)
+
+	var t *emailtemplate.Template
+	var err error
+	if query.Body != "" {
+		t, err = emailtemplate.NewTemplate(query.Name, locale, 0, query.Subject, query.Body, query.Variables, "")
+		if err != nil {
+			return nil, err
+		}
+	} else if t, err = emailtemplate.Resolve(ctx, h.templateRepo, query.Name, locale, query.Version); err != nil {
+		return nil, err
+	}
+
+	data := t.SampleData(query.Data)
+	if err := t.CheckData(data); err != nil {
+		return nil, err
+	}
+	subject, body, err := t.Render(locale, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EmailTemplatePreview{
+		Name:    query.Name,
+		Locale:  locale,
+		Version: t.Version,
+		Subject: subject,
+		Body:    body,
+		Data:    data,
+	}, nil
+}
@@ -0,0 +1,121 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/analytics"
+	"online-shop/internal/domain/experiment"
+)
+
+type ListExperimentsQuery struct {
+	Status string `json:"status"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+type ListExperimentsQueryHandler struct {
+	experimentRepo experiment.Repository
+}
+
+func NewListExperimentsQueryHandler(experimentRepo experiment.Repository) *ListExperimentsQueryHandler {
+	return &ListExperimentsQueryHandler{experimentRepo: experimentRepo}
+}
+
+func (h *ListExperimentsQueryHandler) Handle(ctx context.Context, query ListExperimentsQuery) ([]*experiment.Experiment, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListExperimentsQueryHandler) handle(ctx context.Context, query ListExperimentsQuery) ([]*experiment.Experiment, error) {
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+	return h.experimentRepo.List(ctx, query.Status, query.Limit, query.Offset)
+}
+
+type GetAssignmentsQuery struct {
+	UserID    string `json:"user_id"`
+	SessionID string `json:"session_id"`
+}
+
+type GetAssignmentsQueryHandler struct {
+	experimentRepo experiment.Repository
+}
+
+func NewGetAssignmentsQueryHandler(experimentRepo experiment.Repository) *GetAssignmentsQueryHandler {
+	return &GetAssignmentsQueryHandler{experimentRepo: experimentRepo}
+}
+
+// Handle returns the visitor's variant in every running experiment they are
+// enrolled in. Assignment is a pure function of the visitor and experiment,
+// so nothing is stored.
+func (h *GetAssignmentsQueryHandler) Handle(ctx context.Context, query GetAssignmentsQuery) ([]experiment.Assignment, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetAssignmentsQueryHandler) handle(ctx context.Context, query GetAssignmentsQuery) ([]experiment.Assignment, error) {
+	unit := experiment.Unit(query.UserID, query.SessionID)
+	if unit == "" {
+		return nil, errors.New("a signed-in user or session ID is required")
+	}
+
+	running, err := h.experimentRepo.List(ctx, experiment.StatusRunning, 100, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	assignments := make([]experiment.Assignment, 0, len(running))
+	for _, e := range running {
+		if variant, ok := e.Assign(unit); ok {
+			assignments = append(assignments, experiment.Assignment{Experiment: e.Key, Variant: variant})
+		}
+	}
+	return assignments, nil
+}
+
+type GetExperimentReportQuery struct {
+	ExperimentID string
+	Range        analytics.ReportRange
+}
+
+type GetExperimentReportQueryHandler struct {
+	experimentRepo experiment.Repository
+	reports        experiment.ReportRepository
+}
+
+func NewGetExperimentReportQueryHandler(experimentRepo experiment.Repository, reports experiment.ReportRepository) *GetExperimentReportQueryHandler {
+	return &GetExperimentReportQueryHandler{experimentRepo: experimentRepo, reports: reports}
+}
+
+// Handle reports on the experiment's whole run unless a range is given.
+func (h *GetExperimentReportQueryHandler) Handle(ctx context.Context, query GetExperimentReportQuery) (*experiment.Report, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetExperimentReportQueryHandler) handle(ctx context.Context, query GetExperimentReportQuery) (*experiment.Report, error) {
+	e, err := h.experimentRepo.GetByID(ctx, query.ExperimentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.Range.From.IsZero() && e.StartedAt != nil {
+		query.Range.From = *e.StartedAt
+	}
+	if query.Range.To.IsZero() {
+		query.Range.To = time.Now()
+		if e.EndedAt != nil {
+			query.Range.To = *e.EndedAt
+		}
+	}
+	if err := query.Range.Normalize(); err != nil {
+		return nil, err
+	}
+
+	counts, err := h.reports.VariantCounts(ctx, e, query.Range)
+	if err != nil {
+		return nil, err
+	}
+	return experiment.BuildReport(e, query.Range, counts), nil
+}
@@ -0,0 +1,99 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/export"
+)
+
+type ListExportsQuery struct {
+	RequestedBy string `json:"requested_by"`
+	Limit       int    `json:"limit"`
+	Offset      int    `json:"offset"`
+}
+
+type ListExportsQueryHandler struct {
+	exportRepo export.Repository
+	storage    export.Storage
+	linkTTL    time.Duration
+}
+
+func NewListExportsQueryHandler(exportRepo export.Repository, storage export.Storage, linkTTL time.Duration) *ListExportsQueryHandler {
+	return &ListExportsQueryHandler{
+		exportRepo: exportRepo,
+		storage:    storage,
+		linkTTL:    linkTTL,
+	}
+}
+
+func (h *ListExportsQueryHandler) Handle(ctx context.Context, query ListExportsQuery) ([]*export.Export, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListExportsQueryHandler) handle(ctx context.Context, query ListExportsQuery) ([]*export.Export, error) {
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+
+	exports, err := h.exportRepo.ListByRequester(ctx, query.RequestedBy, query.Limit, query.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range exports {
+		attachDownloadURL(e, h.storage, h.linkTTL)
+	}
+	return exports, nil
+}
+
+type GetExportQuery struct {
+	ExportID    string `json:"export_id"`
+	RequestedBy string `json:"requested_by"`
+}
+
+type GetExportQueryHandler struct {
+	exportRepo export.Repository
+	storage    export.Storage
+	linkTTL    time.Duration
+}
+
+func NewGetExportQueryHandler(exportRepo export.Repository, storage export.Storage, linkTTL time.Duration) *GetExportQueryHandler {
+	return &GetExportQueryHandler{
+		exportRepo: exportRepo,
+		storage:    storage,
+		linkTTL:    linkTTL,
+	}
+}
+
+// Handle only returns exports owned by the caller, so one admin cannot pick
+// up download links for another admin's reports.
+func (h *GetExportQueryHandler) Handle(ctx context.Context, query GetExportQuery) (*export.Export, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetExportQueryHandler) handle(ctx context.Context, query GetExportQuery) (*export.Export, error) {
+	e, err := h.exportRepo.GetByID(ctx, query.ExportID)
+	if err != nil {
+		return nil, err
+	}
+	if e.RequestedBy != query.RequestedBy {
+		return nil, errors.New("export not found")
+	}
+
+	attachDownloadURL(e, h.storage, h.linkTTL)
+	return e, nil
+}
+
+// attachDownloadURL signs a fresh link for completed exports. Links expire,
+// so they are generated on read rather than stored.
+func attachDownloadURL(e *export.Export, storage export.Storage, ttl time.Duration) {
+	if e.Status != export.StatusCompleted || e.ObjectKey == "" {
+		return
+	}
+	if url, err := storage.SignedURL(e.ObjectKey, ttl); err == nil {
+		e.DownloadURL = url
+	}
+}
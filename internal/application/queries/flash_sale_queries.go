@@ -0,0 +1,216 @@
+package queries
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/flashsale"
+	"online-shop/internal/domain/product"
+)
+
+type ListFlashSalesQuery struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// GetFlashSaleQuery reads a sale with its products and what has sold.
+// ActiveOnly hides deactivated sales, as the storefront does.
+type GetFlashSaleQuery struct {
+	SaleID     string `json:"sale_id" validate:"required"`
+	ActiveOnly bool   `json:"-"`
+}
+
+type GetCurrentFlashSalesQuery struct {
+	Limit int `json:"limit"`
+}
+
+type GetFlashSaleOffersQuery struct {
+	Products []*product.Product
+}
+
+type ListFlashSalesQueryHandler struct {
+	saleRepo flashsale.Repository
+}
+
+func NewListFlashSalesQueryHandler(saleRepo flashsale.Repository) *ListFlashSalesQueryHandler {
+	return &ListFlashSalesQueryHandler{saleRepo: saleRepo}
+}
+
+func (h *ListFlashSalesQueryHandler) Handle(ctx context.Context, query ListFlashSalesQuery) ([]*flashsale.Sale, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListFlashSalesQueryHandler) handle(ctx context.Context, query ListFlashSalesQuery) ([]*flashsale.Sale, error) {
+	if query.Limit <= 0 {
+		query.Limit = 50
+	}
+	sales, err := h.saleRepo.List(ctx, query.Limit, query.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, s := range sales {
+		s.Countdown = s.CountdownAt(now)
+	}
+	return sales, nil
+}
+
+type GetFlashSaleQueryHandler struct {
+	saleRepo    flashsale.Repository
+	productRepo product.Repository
+	counter     flashsale.Counter
+}
+
+func NewGetFlashSaleQueryHandler(saleRepo flashsale.Repository, productRepo product.Repository, counter flashsale.Counter) *GetFlashSaleQueryHandler {
+	return &GetFlashSaleQueryHandler{saleRepo: saleRepo, productRepo: productRepo, counter: counter}
+}
+
+func (h *GetFlashSaleQueryHandler) Handle(ctx context.Context, query GetFlashSaleQuery) (*flashsale.Sale, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetFlashSaleQueryHandler) handle(ctx context.Context, query GetFlashSaleQuery) (*flashsale.Sale, error) {
+	s, err := h.saleRepo.GetByID(ctx, query.SaleID)
+	if err != nil {
+		return nil, err
+	}
+	if query.ActiveOnly && !s.Active {
+		return nil, flashsale.ErrSaleNotFound
+	}
+
+	if err := fillFlashSales(ctx, h.productRepo, h.counter, []*flashsale.Sale{s}, !query.ActiveOnly); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetCurrentFlashSalesQueryHandler lists the live and upcoming sales for
+// the storefront, soonest first.
+type GetCurrentFlashSalesQueryHandler struct {
+	saleRepo    flashsale.Repository
+	productRepo product.Repository
+	counter     flashsale.Counter
+}
+
+func NewGetCurrentFlashSalesQueryHandler(saleRepo flashsale.Repository, productRepo product.Repository, counter flashsale.Counter) *GetCurrentFlashSalesQueryHandler {
+	return &GetCurrentFlashSalesQueryHandler{saleRepo: saleRepo, productRepo: productRepo, counter: counter}
+}
+
+func (h *GetCurrentFlashSalesQueryHandler) Handle(ctx context.Context, query GetCurrentFlashSalesQuery) ([]*flashsale.Sale, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetCurrentFlashSalesQueryHandler) handle(ctx context.Context, query GetCurrentFlashSalesQuery) ([]*flashsale.Sale, error) {
+	if query.Limit <= 0 {
+		query.Limit = 10
+	}
+	sales, err := h.saleRepo.ListCurrent(ctx, time.Now(), query.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fillFlashSales(ctx, h.productRepo, h.counter, sales, false); err != nil {
+		return nil, err
+	}
+	return sales, nil
+}
+
+// GetFlashSaleOffersQueryHandler sets the live flash sale, if any, on each
+// product being served.
+type GetFlashSaleOffersQueryHandler struct {
+	saleRepo flashsale.Repository
+	counter  flashsale.Counter
+}
+
+func NewGetFlashSaleOffersQueryHandler(saleRepo flashsale.Repository, counter flashsale.Counter) *GetFlashSaleOffersQueryHandler {
+	return &GetFlashSaleOffersQueryHandler{saleRepo: saleRepo, counter: counter}
+}
+
+func (h *GetFlashSaleOffersQueryHandler) Handle(ctx context.Context, query GetFlashSaleOffersQuery) error {
+	return pipeline.Exec(ctx, query, h.handle)
+}
+
+func (h *GetFlashSaleOffersQueryHandler) handle(ctx context.Context, query GetFlashSaleOffersQuery) error {
+	if len(query.Products) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(query.Products))
+	for _, p := range query.Products {
+		ids = append(ids, p.ID)
+	}
+
+	now := time.Now()
+	sales, err := h.saleRepo.ListLive(ctx, ids, now)
+	if err != nil || len(sales) == 0 {
+		return err
+	}
+
+	type offer struct {
+		sale *flashsale.Sale
+		item *flashsale.Item
+	}
+	offers := make(map[string]offer, len(query.Products))
+	var items []*flashsale.Item
+	for _, p := range query.Products {
+		if sale, item := flashsale.Best(sales, p.ID, now); item != nil {
+			offers[p.ID] = offer{sale: sale, item: item}
+			items = append(items, item)
+		}
+	}
+	if err := h.counter.Sold(ctx, items); err != nil {
+		return err
+	}
+
+	for _, p := range query.Products {
+		if o, ok := offers[p.ID]; ok {
+			p.FlashSale = o.sale.Offer(o.item, p.Price, now)
+		}
+	}
+	return nil
+}
+
+// fillFlashSales sets the countdown, units sold and product of every item.
+// Products that are no longer available are dropped unless the caller is
+// an admin who needs to see the sale as configured.
+func fillFlashSales(ctx context.Context, productRepo product.Repository, counter flashsale.Counter, sales []*flashsale.Sale, keepUnavailable bool) error {
+	var ids []string
+	var items []*flashsale.Item
+	for _, s := range sales {
+		for i := range s.Items {
+			ids = append(ids, s.Items[i].ProductID)
+			items = append(items, &s.Items[i])
+		}
+	}
+
+	if err := counter.Sold(ctx, items); err != nil {
+		return err
+	}
+	products, err := productRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]*product.Product, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+
+	now := time.Now()
+	for _, s := range sales {
+		s.Countdown = s.CountdownAt(now)
+		kept := s.Items[:0]
+		for _, item := range s.Items {
+			p, ok := byID[item.ProductID]
+			if !(ok && p.IsAvailable()) && !keepUnavailable {
+				continue
+			}
+			if ok {
+				item.Product = p
+			}
+			kept = append(kept, item)
+		}
+		s.Items = kept
+	}
+	return nil
+}
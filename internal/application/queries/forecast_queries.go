@@ -0,0 +1,53 @@
+package queries
+
+import (
+	"context"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/forecast"
+)
+
+type ListReorderSuggestionsQuery struct {
+	WarehouseID string `json:"warehouse_id"`
+	ProductID   string `json:"product_id"`
+	Status      string `json:"status" validate:"omitempty,oneof=ok reorder out_of_stock"`
+	Limit       int    `json:"limit"`
+	Offset      int    `json:"offset"`
+}
+
+type ReorderSuggestionPage struct {
+	Suggestions []*forecast.Suggestion
+	Total       int64
+}
+
+type ListReorderSuggestionsQueryHandler struct {
+	forecastRepo forecast.Repository
+}
+
+func NewListReorderSuggestionsQueryHandler(forecastRepo forecast.Repository) *ListReorderSuggestionsQueryHandler {
+	return &ListReorderSuggestionsQueryHandler{forecastRepo: forecastRepo}
+}
+
+// Handle lists the suggestions of the last forecast run, soonest stock-out
+// first.
+func (h *ListReorderSuggestionsQueryHandler) Handle(ctx context.Context, query ListReorderSuggestionsQuery) (*ReorderSuggestionPage, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListReorderSuggestionsQueryHandler) handle(ctx context.Context, query ListReorderSuggestionsQuery) (*ReorderSuggestionPage, error) {
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+
+	suggestions, total, err := h.forecastRepo.List(ctx, forecast.Filter{
+		WarehouseID: query.WarehouseID,
+		ProductID:   query.ProductID,
+		Status:      forecast.Status(query.Status),
+		Limit:       query.Limit,
+		Offset:      query.Offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ReorderSuggestionPage{Suggestions: suggestions, Total: total}, nil
+}
@@ -0,0 +1,63 @@
+package queries
+
+import (
+	"context"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/fraud"
+)
+
+// ListFraudReviewsQuery lists the review queue unless another status is
+// asked for; "all" lists every assessment.
+type ListFraudReviewsQuery struct {
+	Status string `json:"status" validate:"omitempty,oneof=all cleared pending_review approved declined"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+type ListFraudReviewsQueryHandler struct {
+	assessmentRepo fraud.Repository
+}
+
+func NewListFraudReviewsQueryHandler(assessmentRepo fraud.Repository) *ListFraudReviewsQueryHandler {
+	return &ListFraudReviewsQueryHandler{assessmentRepo: assessmentRepo}
+}
+
+func (h *ListFraudReviewsQueryHandler) Handle(ctx context.Context, query ListFraudReviewsQuery) ([]*fraud.Assessment, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListFraudReviewsQueryHandler) handle(ctx context.Context, query ListFraudReviewsQuery) ([]*fraud.Assessment, error) {
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+
+	status := fraud.Status(query.Status)
+	switch query.Status {
+	case "":
+		status = fraud.StatusPendingReview
+	case "all":
+		status = ""
+	}
+	return h.assessmentRepo.List(ctx, status, query.Limit, query.Offset)
+}
+
+type GetFraudReviewQuery struct {
+	OrderID string `json:"order_id"`
+}
+
+type GetFraudReviewQueryHandler struct {
+	assessmentRepo fraud.Repository
+}
+
+func NewGetFraudReviewQueryHandler(assessmentRepo fraud.Repository) *GetFraudReviewQueryHandler {
+	return &GetFraudReviewQueryHandler{assessmentRepo: assessmentRepo}
+}
+
+func (h *GetFraudReviewQueryHandler) Handle(ctx context.Context, query GetFraudReviewQuery) (*fraud.Assessment, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetFraudReviewQueryHandler) handle(ctx context.Context, query GetFraudReviewQuery) (*fraud.Assessment, error) {
+	return h.assessmentRepo.GetByOrderID(ctx, query.OrderID)
+}
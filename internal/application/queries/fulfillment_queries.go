@@ -0,0 +1,39 @@
+package queries
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/fulfillment"
+	"online-shop/internal/domain/order"
+)
+
+// maxPickListShipments bounds the current pick list of a warehouse
+const maxPickListShipments = 500
+
+// GetPickListQuery is what a warehouse has on its pick lists and has not
+// packed yet.
+type GetPickListQuery struct {
+	WarehouseID string `json:"warehouse_id" validate:"required"`
+}
+
+type GetPickListQueryHandler struct {
+	orderRepo order.Repository
+}
+
+func NewGetPickListQueryHandler(orderRepo order.Repository) *GetPickListQueryHandler {
+	return &GetPickListQueryHandler{orderRepo: orderRepo}
+}
+
+func (h *GetPickListQueryHandler) Handle(ctx context.Context, query GetPickListQuery) (*fulfillment.PickList, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetPickListQueryHandler) handle(ctx context.Context, query GetPickListQuery) (*fulfillment.PickList, error) {
+	orders, err := h.orderRepo.ListForFulfillment(ctx, query.WarehouseID, order.ShipmentStatusPicking, maxPickListShipments)
+	if err != nil {
+		return nil, err
+	}
+	return fulfillment.BuildPickList(query.WarehouseID, orders, time.Now()), nil
+}
@@ -0,0 +1,223 @@
+package queries
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/banner"
+	"online-shop/internal/domain/product"
+)
+
+const (
+	homeFeaturedLimit       = 8
+	homeTrendingLimit       = 12
+	homeRecentlyViewedLimit = 10
+	homeRecommendedLimit    = 12
+	homeSectionTimeout      = 2 * time.Second
+)
+
+type GetHomeFeedQuery struct {
+	UserID    string `json:"user_id"`
+	SessionID string `json:"session_id"`
+}
+
+// HomeFeed is the composed home page. Sections that failed or timed out are
+// left empty and listed in Unavailable so clients can hide or retry them.
+type HomeFeed struct {
+	Banners        []*banner.Banner   `json:"banners"`
+	Featured       []*product.Product `json:"featured"`
+	Trending       []*product.Product `json:"trending"`
+	RecentlyViewed []*product.Product `json:"recently_viewed"`
+	Recommended    []*product.Product `json:"recommended"`
+	Unavailable    []string           `json:"unavailable,omitempty"`
+}
+
+type GetHomeFeedQueryHandler struct {
+	bannerRepo     banner.Repository
+	featured       *GetFeaturedProductsQueryHandler
+	trending       *GetTrendingProductsQueryHandler
+	recentlyViewed *GetRecentlyViewedQueryHandler
+	similar        *GetSimilarProductsQueryHandler
+	boughtTogether *GetBoughtTogetherQueryHandler
+	timeout        time.Duration
+}
+
+func NewGetHomeFeedQueryHandler(
+	bannerRepo banner.Repository,
+	featured *GetFeaturedProductsQueryHandler,
+	trending *GetTrendingProductsQueryHandler,
+	recentlyViewed *GetRecentlyViewedQueryHandler,
+	similar *GetSimilarProductsQueryHandler,
+	boughtTogether *GetBoughtTogetherQueryHandler,
+) *GetHomeFeedQueryHandler {
+	return &GetHomeFeedQueryHandler{
+		bannerRepo:     bannerRepo,
+		featured:       featured,
+		trending:       trending,
+		recentlyViewed: recentlyViewed,
+		similar:        similar,
+		boughtTogether: boughtTogether,
+		timeout:        homeSectionTimeout,
+	}
+}
+
+type homeSection struct {
+	name     string
+	banners  []*banner.Banner
+	products []*product.Product
+	err      error
+}
+
+// Handle loads every section in parallel. A section that errors or misses
+// the deadline does not fail the feed.
+func (h *GetHomeFeedQueryHandler) Handle(ctx context.Context, query GetHomeFeedQuery) (*HomeFeed, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetHomeFeedQueryHandler) handle(ctx context.Context, query GetHomeFeedQuery) (*HomeFeed, error) {
+	loaders := map[string]func() homeSection{
+		"banners": func() homeSection {
+			banners, err := h.bannerRepo.ListActive(ctx, time.Now())
+			return homeSection{banners: banners, err: err}
+		},
+		"featured": func() homeSection {
+			products, err := h.featured.Handle(ctx, GetFeaturedProductsQuery{Limit: homeFeaturedLimit})
+			return homeSection{products: products, err: err}
+		},
+		"trending": func() homeSection {
+			products, err := h.trending.Handle(ctx, GetTrendingProductsQuery{Limit: homeTrendingLimit})
+			return homeSection{products: products, err: err}
+		},
+		"recently_viewed": func() homeSection {
+			products, err := h.recentlyViewed.Handle(ctx, GetRecentlyViewedQuery{
+				UserID:    query.UserID,
+				SessionID: query.SessionID,
+				Limit:     homeRecentlyViewedLimit,
+			})
+			return homeSection{products: products, err: err}
+		},
+		"recommended": func() homeSection {
+			products, err := h.recommended(ctx, query)
+			return homeSection{products: products, err: err}
+		},
+	}
+
+	results := make(chan homeSection, len(loaders))
+	for name, load := range loaders {
+		go func(name string, load func() homeSection) {
+			section := load()
+			section.name = name
+			results <- section
+		}(name, load)
+	}
+
+	feed := &HomeFeed{
+		Banners:        []*banner.Banner{},
+		Featured:       []*product.Product{},
+		Trending:       []*product.Product{},
+		RecentlyViewed: []*product.Product{},
+		Recommended:    []*product.Product{},
+	}
+
+	pending := make(map[string]bool, len(loaders))
+	for name := range loaders {
+		pending[name] = true
+	}
+
+	deadline := time.NewTimer(h.timeout)
+	defer deadline.Stop()
+
+	for len(pending) > 0 {
+		select {
+		case section := <-results:
+			delete(pending, section.name)
+			if section.err != nil {
+				feed.Unavailable = append(feed.Unavailable, section.name)
+				continue
+			}
+			feed.assign(section)
+		case <-deadline.C:
+			for name := range pending {
+				feed.Unavailable = append(feed.Unavailable, name)
+			}
+			pending = nil
+		}
+	}
+	sort.Strings(feed.Unavailable)
+
+	return feed, nil
+}
+
+func (f *HomeFeed) assign(section homeSection) {
+	if section.banners != nil {
+		f.Banners = section.banners
+	}
+	if section.products == nil {
+		return
+	}
+	switch section.name {
+	case "featured":
+		f.Featured = section.products
+	case "trending":
+		f.Trending = section.products
+	case "recently_viewed":
+		f.RecentlyViewed = section.products
+	case "recommended":
+		f.Recommended = section.products
+	}
+}
+
+// recommended personalises the feed from the viewer's recent history:
+// products bought together with what they looked at, topped up with
+// products similar to the latest view.
+func (h *GetHomeFeedQueryHandler) recommended(ctx context.Context, query GetHomeFeedQuery) ([]*product.Product, error) {
+	viewed, err := h.recentlyViewed.Handle(ctx, GetRecentlyViewedQuery{
+		UserID:    query.UserID,
+		SessionID: query.SessionID,
+		Limit:     3,
+	})
+	if err != nil || len(viewed) == 0 {
+		return []*product.Product{}, err
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range viewed {
+		seen[p.ID] = true
+	}
+
+	var recommended []*product.Product
+	add := func(products []*product.Product) {
+		for _, p := range products {
+			if len(recommended) >= homeRecommendedLimit {
+				return
+			}
+			if !seen[p.ID] {
+				seen[p.ID] = true
+				recommended = append(recommended, p)
+			}
+		}
+	}
+
+	for _, p := range viewed {
+		related, err := h.boughtTogether.Handle(ctx, GetBoughtTogetherQuery{ProductID: p.ID})
+		if err != nil {
+			return nil, err
+		}
+		add(related)
+	}
+
+	if len(recommended) < homeRecommendedLimit {
+		similar, err := h.similar.Handle(ctx, GetSimilarProductsQuery{ProductID: viewed[0].ID, Limit: homeRecommendedLimit})
+		if err != nil {
+			return nil, err
+		}
+		add(similar)
+	}
+
+	if recommended == nil {
+		recommended = []*product.Product{}
+	}
+	return recommended, nil
+}
@@ -0,0 +1,48 @@
+package queries
+
+import (
+	"context"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/impersonation"
+)
+
+// ListImpersonationsQuery lists impersonations, newest first, such as those
+// of one customer or the ones still active.
+type ListImpersonationsQuery struct {
+	AdminID    string `json:"admin_id"`
+	CustomerID string `json:"customer_id"`
+	Active     bool   `json:"active"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+}
+
+type ImpersonationPage struct {
+	Grants []*impersonation.Grant
+	Total  int64
+}
+
+type ListImpersonationsQueryHandler struct {
+	grantRepo impersonation.Repository
+}
+
+func NewListImpersonationsQueryHandler(grantRepo impersonation.Repository) *ListImpersonationsQueryHandler {
+	return &ListImpersonationsQueryHandler{grantRepo: grantRepo}
+}
+
+func (h *ListImpersonationsQueryHandler) Handle(ctx context.Context, query ListImpersonationsQuery) (*ImpersonationPage, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListImpersonationsQueryHandler) handle(ctx context.Context, query ListImpersonationsQuery) (*ImpersonationPage, error) {
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+
+	filter := impersonation.Filter{AdminID: query.AdminID, CustomerID: query.CustomerID, Active: query.Active}
+	grants, total, err := h.grantRepo.List(ctx, filter, query.Limit, query.Offset)
+	if err != nil {
+		return nil, err
+	}
+	return &ImpersonationPage{Grants: grants, Total: total}, nil
+}
@@ -0,0 +1,172 @@
+package queries
+
+import (
+	"context"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/emailtemplate"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/payment"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/user"
+	"online-shop/pkg/i18n"
+)
+
+type GetOrderInvoiceQuery struct {
+	Order  *order.Order
+	Locale string
+}
+
+// OrderInvoice is the order's invoice rendered as an HTML document.
+type OrderInvoice struct {
+	Number string
+	HTML   string
+}
+
+type GetOrderInvoiceQueryHandler struct {
+	templateRepo emailtemplate.Repository
+	paymentRepo  payment.Repository
+	userRepo     user.Repository
+	productRepo  product.Repository
+}
+
+func NewGetOrderInvoiceQueryHandler(templateRepo emailtemplate.Repository, paymentRepo payment.Repository, userRepo user.Repository, productRepo product.Repository) *GetOrderInvoiceQueryHandler {
+	return &GetOrderInvoiceQueryHandler{templateRepo: templateRepo, paymentRepo: paymentRepo, userRepo: userRepo, productRepo: productRepo}
+}
+
+// Handle renders the order's invoice with the shop's invoice email
+// template, so the document matches the invoice customers are emailed.
+// Orders held for review or cancelled have none.
+func (h *GetOrderInvoiceQueryHandler) Handle(ctx context.Context, query GetOrderInvoiceQuery) (*OrderInvoice, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetOrderInvoiceQueryHandler) handle(ctx context.Context, query GetOrderInvoiceQuery) (*OrderInvoice, error) {
+	o := query.Order
+	if o.Status == order.StatusOnHold || o.Status == order.StatusCancelled {
+		return nil, commands.ErrOrderInvoiceUnavailable
+	}
+
+	payments, err := h.paymentRepo.ListByOrderID(ctx, o.ID)
+	if err != nil {
+		return nil, err
+	}
+	summary := payment.Summarize(o.TotalAmount, payments)
+
+	data := map[string]interface{}{
+		"OrderNumber":   o.Number,
+		"InvoiceNumber": o.InvoiceNumber(),
+		"Date":          o.CreatedAt.Format("January 2, 2006"),
+		"TotalAmount":   o.TotalAmount,
+		"AmountPaid":    summary.Paid,
+		"BalanceDue":    summary.Outstanding,
+		"Note":          o.Note,
+		"GiftWrap":      o.Gift.Wrap,
+		"GiftWrapFee":   o.Gift.WrapFee,
+		"GiftMessage":   o.Gift.Message,
+	}
+	if o.Number == "" {
+		data["OrderNumber"] = o.ID
+	}
+	if o.Shipping.Fee > 0 {
+		data["ShippingAmount"] = o.Shipping.Fee
+	}
+	if customer, err := h.userRepo.GetByID(ctx, o.UserID); err == nil {
+		data["CustomerEmail"] = customer.Email
+	}
+
+	var subtotal float64
+	items := make([]map[string]interface{}, len(o.Items))
+	for i, item := range o.Items {
+		items[i] = map[string]interface{}{
+			"ProductName": h.productName(ctx, item),
+			"Quantity":    item.Quantity,
+			"UnitPrice":   item.Price,
+			"TotalPrice":  item.Subtotal,
+		}
+		subtotal += item.Subtotal
+	}
+	data["Items"] = items
+	data["Subtotal"] = subtotal
+
+	// Payments that never went through are left off the invoice
+	var lines []map[string]interface{}
+	for _, p := range summary.Payments {
+		if p.Status != payment.StatusPaid && p.Status != payment.StatusPending && p.Status != payment.StatusRefunded {
+			continue
+		}
+		lines = append(lines, map[string]interface{}{
+			"Method":      string(p.Method),
+			"Amount":      p.Amount,
+			"Status":      string(p.Status),
+			"Installment": p.Installment,
+		})
+	}
+	if len(lines) > 0 {
+		data["Payments"] = lines
+	}
+
+	locale := i18n.Negotiate("", query.Locale)
+	t, err := emailtemplate.Resolve(ctx, h.templateRepo, "invoice", locale, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.CheckData(data); err != nil {
+		return nil, err
+	}
+	_, body, err := t.Render(locale, data)
+	if err != nil {
+		return nil, err
+	}
+	return &OrderInvoice{Number: o.InvoiceNumber(), HTML: body}, nil
+}
+
+// productName names the item as it was ordered; items ordered before
+// products were snapshotted take the product's current name.
+func (h *GetOrderInvoiceQueryHandler) productName(ctx context.Context, item order.OrderItem) string {
+	if item.Product.Name != "" {
+		return item.Product.Name
+	}
+	if prod, err := h.productRepo.GetByID(ctx, item.ProductID); err == nil {
+		return prod.Name
+	}
+	return item.ProductID
+}
+
+type ListReceiptsQuery struct {
+	UserID string `validate:"required"`
+	Limit  int
+	Offset int
+}
+
+type ReceiptPage struct {
+	Receipts []payment.Receipt
+	Total    int64
+}
+
+type ListReceiptsQueryHandler struct {
+	receiptRepo payment.ReceiptRepository
+}
+
+func NewListReceiptsQueryHandler(receiptRepo payment.ReceiptRepository) *ListReceiptsQueryHandler {
+	return &ListReceiptsQueryHandler{receiptRepo: receiptRepo}
+}
+
+// Handle returns a page of the receipts of what the user has paid, newest
+// first
+func (h *ListReceiptsQueryHandler) Handle(ctx context.Context, query ListReceiptsQuery) (*ReceiptPage, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListReceiptsQueryHandler) handle(ctx context.Context, query ListReceiptsQuery) (*ReceiptPage, error) {
+	if query.Limit <= 0 {
+		query.Limit = 10
+	}
+
+	receipts, total, err := h.receiptRepo.ListReceipts(ctx, query.UserID, query.Limit, query.Offset)
+	if err != nil {
+		return nil, err
+	}
+	return &ReceiptPage{Receipts: receipts, Total: total}, nil
+}
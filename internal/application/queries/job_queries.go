@@ -0,0 +1,68 @@
+package queries
+
+import (
+	"context"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/job"
+)
+
+// ListJobsQuery lists the jobs the workers took off the queues, newest
+// first
+type ListJobsQuery struct {
+	Queue  string `json:"queue"`
+	Type   string `json:"type"`
+	State  string `json:"state" validate:"omitempty,oneof=pending running succeeded failed cancelled"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+type JobPage struct {
+	Jobs  []*job.Job
+	Total int64
+}
+
+type GetJobQuery struct {
+	ID string `json:"id"`
+}
+
+type ListJobsQueryHandler struct {
+	jobs job.Repository
+}
+
+func NewListJobsQueryHandler(jobs job.Repository) *ListJobsQueryHandler {
+	return &ListJobsQueryHandler{jobs: jobs}
+}
+
+func (h *ListJobsQueryHandler) Handle(ctx context.Context, query ListJobsQuery) (*JobPage, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListJobsQueryHandler) handle(ctx context.Context, query ListJobsQuery) (*JobPage, error) {
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+
+	filter := job.Filter{Queue: query.Queue, Type: query.Type, State: job.State(query.State)}
+	jobs, total, err := h.jobs.List(ctx, filter, query.Limit, query.Offset)
+	if err != nil {
+		return nil, err
+	}
+	return &JobPage{Jobs: jobs, Total: total}, nil
+}
+
+type GetJobQueryHandler struct {
+	jobs job.Repository
+}
+
+func NewGetJobQueryHandler(jobs job.Repository) *GetJobQueryHandler {
+	return &GetJobQueryHandler{jobs: jobs}
+}
+
+func (h *GetJobQueryHandler) Handle(ctx context.Context, query GetJobQuery) (*job.Job, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetJobQueryHandler) handle(ctx context.Context, query GetJobQuery) (*job.Job, error) {
+	return h.jobs.Get(ctx, query.ID)
+}
@@ -0,0 +1,40 @@
+package queries
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/maintenance"
+)
+
+// MaintenanceView is the maintenance state with whether it is in effect
+// right now.
+type MaintenanceView struct {
+	*maintenance.State
+	Status maintenance.Status `json:"status"`
+}
+
+type GetMaintenanceQuery struct{}
+
+type GetMaintenanceQueryHandler struct {
+	store maintenance.Store
+}
+
+func NewGetMaintenanceQueryHandler(store maintenance.Store) *GetMaintenanceQueryHandler {
+	return &GetMaintenanceQueryHandler{store: store}
+}
+
+func (h *GetMaintenanceQueryHandler) Handle(ctx context.Context, query GetMaintenanceQuery) (*MaintenanceView, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetMaintenanceQueryHandler) handle(ctx context.Context, query GetMaintenanceQuery) (*MaintenanceView, error) {
+	state, err := h.store.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	state.Prune(now)
+	return &MaintenanceView{State: state, Status: state.Status(now)}, nil
+}
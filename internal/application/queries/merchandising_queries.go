@@ -0,0 +1,130 @@
+package queries
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/merchandising"
+	"online-shop/internal/domain/product"
+)
+
+// GetSearchRankingQuery works out how the live merchandising rules rank a
+// search for Query in the category, both of which may be empty.
+type GetSearchRankingQuery struct {
+	Query      string `json:"query"`
+	CategoryID string `json:"category_id"`
+}
+
+type GetSearchRankingQueryHandler struct {
+	ruleRepo merchandising.Repository
+}
+
+func NewGetSearchRankingQueryHandler(ruleRepo merchandising.Repository) *GetSearchRankingQueryHandler {
+	return &GetSearchRankingQueryHandler{ruleRepo: ruleRepo}
+}
+
+func (h *GetSearchRankingQueryHandler) Handle(ctx context.Context, query GetSearchRankingQuery) (product.Ranking, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetSearchRankingQueryHandler) handle(ctx context.Context, query GetSearchRankingQuery) (product.Ranking, error) {
+	now := time.Now()
+	rules, err := h.ruleRepo.ListLive(ctx, now)
+	if err != nil {
+		return product.Ranking{}, err
+	}
+	return merchandising.Resolve(rules, query.Query, query.CategoryID, now), nil
+}
+
+// searchRanking is the ranking of a search, or none when the rules cannot
+// be read; results are then served unmerchandised rather than not at all.
+func searchRanking(ctx context.Context, rankingHandler *GetSearchRankingQueryHandler, query, categoryID string) product.Ranking {
+	if rankingHandler == nil {
+		return product.Ranking{}
+	}
+	ranking, _ := rankingHandler.Handle(ctx, GetSearchRankingQuery{Query: query, CategoryID: categoryID})
+	return ranking
+}
+
+type ListMerchandisingRulesQuery struct {
+	Kind   string `json:"kind" validate:"omitempty,oneof=pin boost bury"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+type MerchandisingRulePage struct {
+	Rules []*merchandising.Rule
+	Total int64
+}
+
+type ListMerchandisingRulesQueryHandler struct {
+	ruleRepo merchandising.Repository
+}
+
+func NewListMerchandisingRulesQueryHandler(ruleRepo merchandising.Repository) *ListMerchandisingRulesQueryHandler {
+	return &ListMerchandisingRulesQueryHandler{ruleRepo: ruleRepo}
+}
+
+// Handle lists the rules newest first, live or not.
+func (h *ListMerchandisingRulesQueryHandler) Handle(ctx context.Context, query ListMerchandisingRulesQuery) (*MerchandisingRulePage, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListMerchandisingRulesQueryHandler) handle(ctx context.Context, query ListMerchandisingRulesQuery) (*MerchandisingRulePage, error) {
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+
+	rules, total, err := h.ruleRepo.List(ctx, merchandising.Kind(query.Kind), query.Limit, query.Offset)
+	if err != nil {
+		return nil, err
+	}
+	return &MerchandisingRulePage{Rules: rules, Total: total}, nil
+}
+
+// PreviewMerchandisingQuery shows which rules apply to a search at a time,
+// now unless At is given, and the ranking they give it.
+type PreviewMerchandisingQuery struct {
+	Query      string     `json:"query"`
+	CategoryID string     `json:"category_id"`
+	At         *time.Time `json:"at"`
+}
+
+type MerchandisingPreview struct {
+	At      time.Time             `json:"at"`
+	Rules   []*merchandising.Rule `json:"rules"`
+	Ranking product.Ranking       `json:"ranking"`
+}
+
+type PreviewMerchandisingQueryHandler struct {
+	ruleRepo merchandising.Repository
+}
+
+func NewPreviewMerchandisingQueryHandler(ruleRepo merchandising.Repository) *PreviewMerchandisingQueryHandler {
+	return &PreviewMerchandisingQueryHandler{ruleRepo: ruleRepo}
+}
+
+func (h *PreviewMerchandisingQueryHandler) Handle(ctx context.Context, query PreviewMerchandisingQuery) (*MerchandisingPreview, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *PreviewMerchandisingQueryHandler) handle(ctx context.Context, query PreviewMerchandisingQuery) (*MerchandisingPreview, error) {
+	at := time.Now()
+	if query.At != nil {
+		at = *query.At
+	}
+	rules, err := h.ruleRepo.ListLive(ctx, at)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &MerchandisingPreview{At: at, Rules: []*merchandising.Rule{}}
+	for _, r := range rules {
+		if r.Matches(query.Query, query.CategoryID) {
+			preview.Rules = append(preview.Rules, r)
+		}
+	}
+	preview.Ranking = merchandising.Resolve(preview.Rules, query.Query, query.CategoryID, at)
+	return preview, nil
+}
@@ -0,0 +1,85 @@
+package queries
+
+import (
+	"context"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/moderation"
+)
+
+// ListProductSubmissionsQuery lists the submissions of one merchant, or of
+// every merchant when MerchantID is empty.
+type ListProductSubmissionsQuery struct {
+	MerchantID string `json:"-"`
+	ProductID  string `json:"product_id"`
+	Status     string `json:"status" validate:"omitempty,oneof=pending approved rejected"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+}
+
+type ProductSubmissionPage struct {
+	Submissions []*moderation.Submission
+	Total       int64
+}
+
+type ListProductSubmissionsQueryHandler struct {
+	submissionRepo moderation.Repository
+}
+
+func NewListProductSubmissionsQueryHandler(submissionRepo moderation.Repository) *ListProductSubmissionsQueryHandler {
+	return &ListProductSubmissionsQueryHandler{submissionRepo: submissionRepo}
+}
+
+// Handle lists the submissions, oldest submitted first, so that the review
+// queue is worked through in order.
+func (h *ListProductSubmissionsQueryHandler) Handle(ctx context.Context, query ListProductSubmissionsQuery) (*ProductSubmissionPage, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListProductSubmissionsQueryHandler) handle(ctx context.Context, query ListProductSubmissionsQuery) (*ProductSubmissionPage, error) {
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+
+	submissions, total, err := h.submissionRepo.List(ctx, moderation.Filter{
+		MerchantID: query.MerchantID,
+		ProductID:  query.ProductID,
+		Status:     moderation.Status(query.Status),
+		Limit:      query.Limit,
+		Offset:     query.Offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ProductSubmissionPage{Submissions: submissions, Total: total}, nil
+}
+
+// GetProductSubmissionQuery finds a submission; with a MerchantID, only
+// among that merchant's.
+type GetProductSubmissionQuery struct {
+	SubmissionID string `json:"submission_id" validate:"required"`
+	MerchantID   string `json:"-"`
+}
+
+type GetProductSubmissionQueryHandler struct {
+	submissionRepo moderation.Repository
+}
+
+func NewGetProductSubmissionQueryHandler(submissionRepo moderation.Repository) *GetProductSubmissionQueryHandler {
+	return &GetProductSubmissionQueryHandler{submissionRepo: submissionRepo}
+}
+
+func (h *GetProductSubmissionQueryHandler) Handle(ctx context.Context, query GetProductSubmissionQuery) (*moderation.Submission, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetProductSubmissionQueryHandler) handle(ctx context.Context, query GetProductSubmissionQuery) (*moderation.Submission, error) {
+	s, err := h.submissionRepo.GetByID(ctx, query.SubmissionID)
+	if err != nil {
+		return nil, err
+	}
+	if query.MerchantID != "" && s.MerchantID != query.MerchantID {
+		return nil, moderation.ErrSubmissionNotFound
+	}
+	return s, nil
+}
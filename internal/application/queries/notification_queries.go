@@ -0,0 +1,66 @@
+package queries
+
+import (
+	"context"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/notification"
+	"online-shop/internal/domain/user"
+)
+
+type GetNotificationPreferencesQuery struct {
+	UserID string `json:"user_id" validate:"required"`
+}
+
+type GetNotificationPreferencesQueryHandler struct {
+	userRepo user.Repository
+	prefRepo notification.Repository
+}
+
+func NewGetNotificationPreferencesQueryHandler(userRepo user.Repository, prefRepo notification.Repository) *GetNotificationPreferencesQueryHandler {
+	return &GetNotificationPreferencesQueryHandler{userRepo: userRepo, prefRepo: prefRepo}
+}
+
+// Handle returns the user's preference center: every category's choices per
+// channel.
+func (h *GetNotificationPreferencesQueryHandler) Handle(ctx context.Context, query GetNotificationPreferencesQuery) ([]notification.Settings, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetNotificationPreferencesQueryHandler) handle(ctx context.Context, query GetNotificationPreferencesQuery) ([]notification.Settings, error) {
+	u, err := h.userRepo.GetByID(ctx, query.UserID)
+	if err != nil {
+		return nil, err
+	}
+	prefs, err := h.prefRepo.List(ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	return notification.Center(u, prefs), nil
+}
+
+type GetUnsubscribeQuery struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type GetUnsubscribeQueryHandler struct {
+	links *notification.Links
+}
+
+func NewGetUnsubscribeQueryHandler(links *notification.Links) *GetUnsubscribeQueryHandler {
+	return &GetUnsubscribeQueryHandler{links: links}
+}
+
+// Handle returns what an unsubscribe link turns off, for the page asking to
+// confirm it.
+func (h *GetUnsubscribeQueryHandler) Handle(ctx context.Context, query GetUnsubscribeQuery) (*notification.Unsubscribe, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetUnsubscribeQueryHandler) handle(ctx context.Context, query GetUnsubscribeQuery) (*notification.Unsubscribe, error) {
+	link, err := h.links.Parse(query.Token)
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
@@ -0,0 +1,35 @@
+package queries
+
+import (
+	"context"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/payment"
+)
+
+type GetOrderPaymentsQuery struct {
+	Order *order.Order
+}
+
+type GetOrderPaymentsQueryHandler struct {
+	paymentRepo payment.Repository
+}
+
+func NewGetOrderPaymentsQueryHandler(paymentRepo payment.Repository) *GetOrderPaymentsQueryHandler {
+	return &GetOrderPaymentsQueryHandler{paymentRepo: paymentRepo}
+}
+
+// Handle returns the order's payments with what is paid, pending and still
+// outstanding against its total
+func (h *GetOrderPaymentsQueryHandler) Handle(ctx context.Context, query GetOrderPaymentsQuery) (*payment.Summary, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetOrderPaymentsQueryHandler) handle(ctx context.Context, query GetOrderPaymentsQuery) (*payment.Summary, error) {
+	payments, err := h.paymentRepo.ListByOrderID(ctx, query.Order.ID)
+	if err != nil {
+		return nil, err
+	}
+	return payment.Summarize(query.Order.TotalAmount, payments), nil
+}
@@ -1,22 +1,55 @@
 package queries
 
 import (
+	"context"
+	"strings"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/analytics"
 	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/user"
+	"online-shop/pkg/pagination"
 )
 
 type GetOrderQuery struct {
 	OrderID string `json:"order_id" validate:"required"`
 }
 
+// GetUserOrdersQuery is a customer's order history. Filter narrows it;
+// its UserID is always replaced by the query's.
 type GetUserOrdersQuery struct {
-	UserID string `json:"user_id" validate:"required"`
-	Limit  int    `json:"limit"`
-	Offset int    `json:"offset"`
+	UserID string           `json:"user_id" validate:"required"`
+	Filter order.ListFilter `json:"-"`
+	Limit  int              `json:"limit"`
+	Offset int              `json:"offset"`
+}
+
+type UserOrderPage struct {
+	Orders []*order.Order `json:"orders"`
+	Total  int64          `json:"total"`
 }
 
+// ListOrdersQuery is the admin order list. Email is matched to the account
+// that placed the orders; Sort is as order.ParseSort reads it and Cursor
+// resumes from an earlier page of the same sort.
 type ListOrdersQuery struct {
-	Limit  int `json:"limit"`
-	Offset int `json:"offset"`
+	Filter order.ListFilter
+	Email  string
+	Sort   string
+	Cursor string
+	Limit  int
+}
+
+type OrderList struct {
+	Orders     []*order.Order
+	NextCursor string
+}
+
+// TrackOrderQuery looks an order up without signing in. The email must be
+// the one of the account that placed it.
+type TrackOrderQuery struct {
+	Number string `json:"number" validate:"required,max=32"`
+	Email  string `json:"email" validate:"required,email"`
 }
 
 type GetOrderQueryHandler struct {
@@ -27,8 +60,12 @@ func NewGetOrderQueryHandler(orderRepo order.Repository) *GetOrderQueryHandler {
 	return &GetOrderQueryHandler{orderRepo: orderRepo}
 }
 
-func (h *GetOrderQueryHandler) Handle(query GetOrderQuery) (*order.Order, error) {
-	return h.orderRepo.GetByID(query.OrderID)
+func (h *GetOrderQueryHandler) Handle(ctx context.Context, query GetOrderQuery) (*order.Order, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetOrderQueryHandler) handle(ctx context.Context, query GetOrderQuery) (*order.Order, error) {
+	return h.orderRepo.GetByID(ctx, query.OrderID)
 }
 
 type GetUserOrdersQueryHandler struct {
@@ -39,24 +76,156 @@ func NewGetUserOrdersQueryHandler(orderRepo order.Repository) *GetUserOrdersQuer
 	return &GetUserOrdersQueryHandler{orderRepo: orderRepo}
 }
 
-func (h *GetUserOrdersQueryHandler) Handle(query GetUserOrdersQuery) ([]*order.Order, error) {
+func (h *GetUserOrdersQueryHandler) Handle(ctx context.Context, query GetUserOrdersQuery) (*UserOrderPage, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetUserOrdersQueryHandler) handle(ctx context.Context, query GetUserOrdersQuery) (*UserOrderPage, error) {
 	if query.Limit <= 0 {
 		query.Limit = 10
 	}
-	return h.orderRepo.GetByUserID(query.UserID, query.Limit, query.Offset)
+	query.Filter.UserID = query.UserID
+
+	orders, total, err := h.orderRepo.ListByFilter(ctx, query.Filter, query.Limit, query.Offset)
+	if err != nil {
+		return nil, err
+	}
+	return &UserOrderPage{Orders: orders, Total: total}, nil
 }
 
 type ListOrdersQueryHandler struct {
 	orderRepo order.Repository
+	userRepo  user.Repository
+}
+
+func NewListOrdersQueryHandler(orderRepo order.Repository, userRepo user.Repository) *ListOrdersQueryHandler {
+	return &ListOrdersQueryHandler{orderRepo: orderRepo, userRepo: userRepo}
 }
 
-func NewListOrdersQueryHandler(orderRepo order.Repository) *ListOrdersQueryHandler {
-	return &ListOrdersQueryHandler{orderRepo: orderRepo}
+// Handle returns a page of the list using keyset pagination, so a page deep
+// into a large list costs the same as the first. One order more than the
+// limit is read to tell whether another page follows.
+func (h *ListOrdersQueryHandler) Handle(ctx context.Context, query ListOrdersQuery) (*OrderList, error) {
+	return pipeline.Handle(ctx, query, h.handle)
 }
 
-func (h *ListOrdersQueryHandler) Handle(query ListOrdersQuery) ([]*order.Order, error) {
+func (h *ListOrdersQueryHandler) handle(ctx context.Context, query ListOrdersQuery) (*OrderList, error) {
 	if query.Limit <= 0 {
-		query.Limit = 20
+		query.Limit = pagination.DefaultPerPage
+	}
+
+	keys, err := order.ParseSort(query.Sort)
+	if err != nil {
+		return nil, err
+	}
+	scope := order.FormatSort(keys)
+
+	var after order.Position
+	if query.Cursor != "" {
+		if after, err = pagination.DecodeKeyset(query.Cursor, scope); err != nil {
+			return nil, err
+		}
+	}
+
+	if email := strings.TrimSpace(query.Email); email != "" {
+		u, err := h.userRepo.GetByEmail(ctx, email)
+		if err != nil {
+			return &OrderList{Orders: []*order.Order{}}, nil
+		}
+		query.Filter.UserID = u.ID
+	}
+
+	orders, err := h.orderRepo.Search(ctx, query.Filter, keys, after, query.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &OrderList{Orders: orders}
+	if len(orders) > query.Limit {
+		list.Orders = orders[:query.Limit]
+		last := list.Orders[query.Limit-1]
+		list.NextCursor = pagination.EncodeKeyset(scope, order.PositionOf(last, keys))
+	}
+	return list, nil
+}
+
+type TrackOrderQueryHandler struct {
+	orderRepo order.Repository
+	userRepo  user.Repository
+}
+
+func NewTrackOrderQueryHandler(orderRepo order.Repository, userRepo user.Repository) *TrackOrderQueryHandler {
+	return &TrackOrderQueryHandler{orderRepo: orderRepo, userRepo: userRepo}
+}
+
+// Handle returns the tracking view of the order. A wrong email is reported
+// the same as an unknown number so neither can be probed on its own.
+func (h *TrackOrderQueryHandler) Handle(ctx context.Context, query TrackOrderQuery) (*order.Tracking, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *TrackOrderQueryHandler) handle(ctx context.Context, query TrackOrderQuery) (*order.Tracking, error) {
+	o, err := h.orderRepo.GetByNumber(ctx, strings.ToUpper(strings.TrimSpace(query.Number)))
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := h.userRepo.GetByID(ctx, o.UserID)
+	if err != nil || !strings.EqualFold(u.Email, strings.TrimSpace(query.Email)) {
+		return nil, order.ErrNotFound
 	}
-	return h.orderRepo.List(query.Limit, query.Offset)
-}
\ No newline at end of file
+	return o.Tracking(), nil
+}
+
+// CancellationReport breaks the orders cancelled in a period down by
+// reason.
+type CancellationReport struct {
+	From     string                   `json:"from"`
+	To       string                   `json:"to"`
+	Orders   int64                    `json:"orders"`
+	Fees     float64                  `json:"fees"`
+	Refunded float64                  `json:"refunded"`
+	Reasons  []order.CancellationStat `json:"reasons"`
+}
+
+type GetCancellationReportQuery struct {
+	Range analytics.ReportRange
+}
+
+type GetCancellationReportQueryHandler struct {
+	orderRepo order.Repository
+}
+
+func NewGetCancellationReportQueryHandler(orderRepo order.Repository) *GetCancellationReportQueryHandler {
+	return &GetCancellationReportQueryHandler{orderRepo: orderRepo}
+}
+
+func (h *GetCancellationReportQueryHandler) Handle(ctx context.Context, query GetCancellationReportQuery) (*CancellationReport, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetCancellationReportQueryHandler) handle(ctx context.Context, query GetCancellationReportQuery) (*CancellationReport, error) {
+	if err := query.Range.Normalize(); err != nil {
+		return nil, err
+	}
+
+	stats, err := h.orderRepo.CancellationStats(ctx, query.Range.From, query.Range.To)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CancellationReport{
+		From:    query.Range.From.Format("2006-01-02"),
+		To:      query.Range.To.Format("2006-01-02"),
+		Reasons: stats,
+	}
+	if report.Reasons == nil {
+		report.Reasons = []order.CancellationStat{}
+	}
+	for _, s := range stats {
+		report.Orders += s.Orders
+		report.Fees += s.Fees
+		report.Refunded += s.Refunded
+	}
+	return report, nil
+}
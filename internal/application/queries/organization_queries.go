@@ -0,0 +1,234 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/organization"
+)
+
+// organizationMember returns the user's membership; a user who buys for no
+// organization has none to show.
+func organizationMember(ctx context.Context, orgRepo organization.Repository, userID string) (*organization.Member, error) {
+	m, err := orgRepo.GetMember(ctx, userID)
+	if errors.Is(err, organization.ErrMemberNotFound) {
+		return nil, organization.ErrNotFound
+	}
+	return m, err
+}
+
+type GetOrganizationQuery struct {
+	UserID string `json:"user_id" validate:"required"`
+}
+
+// OrganizationAccount is the organization a user buys for, their role in it
+// and how much of its credit is left.
+type OrganizationAccount struct {
+	*organization.Organization
+	Role   organization.Role   `json:"role"`
+	Credit organization.Credit `json:"credit"`
+}
+
+type GetOrganizationQueryHandler struct {
+	orgRepo     organization.Repository
+	invoiceRepo organization.InvoiceRepository
+}
+
+func NewGetOrganizationQueryHandler(orgRepo organization.Repository, invoiceRepo organization.InvoiceRepository) *GetOrganizationQueryHandler {
+	return &GetOrganizationQueryHandler{orgRepo: orgRepo, invoiceRepo: invoiceRepo}
+}
+
+func (h *GetOrganizationQueryHandler) Handle(ctx context.Context, query GetOrganizationQuery) (*OrganizationAccount, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetOrganizationQueryHandler) handle(ctx context.Context, query GetOrganizationQuery) (*OrganizationAccount, error) {
+	m, err := organizationMember(ctx, h.orgRepo, query.UserID)
+	if err != nil {
+		return nil, err
+	}
+	org, err := h.orgRepo.GetByID(ctx, m.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	owed, err := h.invoiceRepo.Exposure(ctx, org.ID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &OrganizationAccount{Organization: org, Role: m.Role, Credit: org.Credit(owed)}, nil
+}
+
+type ListOrganizationsQuery struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+type ListOrganizationsQueryHandler struct {
+	orgRepo organization.Repository
+}
+
+func NewListOrganizationsQueryHandler(orgRepo organization.Repository) *ListOrganizationsQueryHandler {
+	return &ListOrganizationsQueryHandler{orgRepo: orgRepo}
+}
+
+func (h *ListOrganizationsQueryHandler) Handle(ctx context.Context, query ListOrganizationsQuery) ([]*organization.Organization, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListOrganizationsQueryHandler) handle(ctx context.Context, query ListOrganizationsQuery) ([]*organization.Organization, error) {
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+	return h.orgRepo.List(ctx, query.Limit, query.Offset)
+}
+
+type ListOrganizationMembersQuery struct {
+	UserID string `json:"user_id" validate:"required"`
+}
+
+type ListOrganizationMembersQueryHandler struct {
+	orgRepo organization.Repository
+}
+
+func NewListOrganizationMembersQueryHandler(orgRepo organization.Repository) *ListOrganizationMembersQueryHandler {
+	return &ListOrganizationMembersQueryHandler{orgRepo: orgRepo}
+}
+
+// Handle lists the members of the organization the user buys for.
+func (h *ListOrganizationMembersQueryHandler) Handle(ctx context.Context, query ListOrganizationMembersQuery) ([]*organization.Member, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListOrganizationMembersQueryHandler) handle(ctx context.Context, query ListOrganizationMembersQuery) ([]*organization.Member, error) {
+	m, err := organizationMember(ctx, h.orgRepo, query.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return h.orgRepo.ListMembers(ctx, m.OrganizationID)
+}
+
+// ListOrganizationOrdersQuery is the order history of the organization the
+// user buys for. Buyers see only the orders they placed; its
+// OrganizationID is always replaced by the user's organization.
+type ListOrganizationOrdersQuery struct {
+	UserID string           `json:"user_id" validate:"required"`
+	Filter order.ListFilter `json:"-"`
+	Limit  int              `json:"limit"`
+	Offset int              `json:"offset"`
+}
+
+type ListOrganizationOrdersQueryHandler struct {
+	orgRepo   organization.Repository
+	orderRepo order.Repository
+}
+
+func NewListOrganizationOrdersQueryHandler(orgRepo organization.Repository, orderRepo order.Repository) *ListOrganizationOrdersQueryHandler {
+	return &ListOrganizationOrdersQueryHandler{orgRepo: orgRepo, orderRepo: orderRepo}
+}
+
+func (h *ListOrganizationOrdersQueryHandler) Handle(ctx context.Context, query ListOrganizationOrdersQuery) (*UserOrderPage, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListOrganizationOrdersQueryHandler) handle(ctx context.Context, query ListOrganizationOrdersQuery) (*UserOrderPage, error) {
+	m, err := organizationMember(ctx, h.orgRepo, query.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if query.Limit <= 0 {
+		query.Limit = 10
+	}
+	query.Filter.OrganizationID = m.OrganizationID
+	query.Filter.UserID = ""
+	if !m.Oversees() {
+		query.Filter.UserID = m.UserID
+	}
+
+	orders, total, err := h.orderRepo.ListByFilter(ctx, query.Filter, query.Limit, query.Offset)
+	if err != nil {
+		return nil, err
+	}
+	return &UserOrderPage{Orders: orders, Total: total}, nil
+}
+
+// ListPurchaseApprovalsQuery lists the purchases of the user's organization
+// that were sent for approval. Buyers see only those they asked for.
+type ListPurchaseApprovalsQuery struct {
+	UserID string `json:"user_id" validate:"required"`
+	Status string `json:"status" validate:"omitempty,oneof=pending approved rejected cancelled"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+type ListPurchaseApprovalsQueryHandler struct {
+	orgRepo      organization.Repository
+	approvalRepo organization.ApprovalRepository
+}
+
+func NewListPurchaseApprovalsQueryHandler(orgRepo organization.Repository, approvalRepo organization.ApprovalRepository) *ListPurchaseApprovalsQueryHandler {
+	return &ListPurchaseApprovalsQueryHandler{orgRepo: orgRepo, approvalRepo: approvalRepo}
+}
+
+func (h *ListPurchaseApprovalsQueryHandler) Handle(ctx context.Context, query ListPurchaseApprovalsQuery) ([]*organization.Approval, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListPurchaseApprovalsQueryHandler) handle(ctx context.Context, query ListPurchaseApprovalsQuery) ([]*organization.Approval, error) {
+	m, err := organizationMember(ctx, h.orgRepo, query.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+	filter := organization.ApprovalFilter{OrganizationID: m.OrganizationID, Status: organization.ApprovalStatus(query.Status)}
+	if !m.Oversees() {
+		filter.RequestedBy = m.UserID
+	}
+	return h.approvalRepo.List(ctx, filter, query.Limit, query.Offset)
+}
+
+// ListInvoicesQuery lists invoices, soonest due first. UserID limits them to
+// the organization of a member who oversees its purchases; admins of the
+// shop leave it empty and may give OrganizationID instead.
+type ListInvoicesQuery struct {
+	UserID         string `json:"user_id"`
+	OrganizationID string `json:"organization_id"`
+	Status         string `json:"status" validate:"omitempty,oneof=open paid"`
+	Limit          int    `json:"limit"`
+	Offset         int    `json:"offset"`
+}
+
+type ListInvoicesQueryHandler struct {
+	orgRepo     organization.Repository
+	invoiceRepo organization.InvoiceRepository
+}
+
+func NewListInvoicesQueryHandler(orgRepo organization.Repository, invoiceRepo organization.InvoiceRepository) *ListInvoicesQueryHandler {
+	return &ListInvoicesQueryHandler{orgRepo: orgRepo, invoiceRepo: invoiceRepo}
+}
+
+func (h *ListInvoicesQueryHandler) Handle(ctx context.Context, query ListInvoicesQuery) ([]*organization.Invoice, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListInvoicesQueryHandler) handle(ctx context.Context, query ListInvoicesQuery) ([]*organization.Invoice, error) {
+	if query.UserID != "" {
+		m, err := organizationMember(ctx, h.orgRepo, query.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if !m.Oversees() {
+			return nil, organization.ErrNotPermitted
+		}
+		query.OrganizationID = m.OrganizationID
+	}
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+	filter := organization.InvoiceFilter{OrganizationID: query.OrganizationID, Status: organization.InvoiceStatus(query.Status)}
+	return h.invoiceRepo.List(ctx, filter, query.Limit, query.Offset)
+}
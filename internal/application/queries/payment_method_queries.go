@@ -0,0 +1,29 @@
+package queries
+
+import (
+	"context"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/payment"
+)
+
+type ListPaymentMethodsQuery struct {
+	UserID string `json:"user_id"`
+}
+
+type ListPaymentMethodsQueryHandler struct {
+	methodRepo payment.MethodRepository
+}
+
+func NewListPaymentMethodsQueryHandler(methodRepo payment.MethodRepository) *ListPaymentMethodsQueryHandler {
+	return &ListPaymentMethodsQueryHandler{methodRepo: methodRepo}
+}
+
+// Handle returns the user's saved methods, the default first
+func (h *ListPaymentMethodsQueryHandler) Handle(ctx context.Context, query ListPaymentMethodsQuery) ([]*payment.SavedMethod, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListPaymentMethodsQueryHandler) handle(ctx context.Context, query ListPaymentMethodsQuery) ([]*payment.SavedMethod, error) {
+	return h.methodRepo.ListByUser(ctx, query.UserID)
+}
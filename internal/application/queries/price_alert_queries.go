@@ -0,0 +1,35 @@
+package queries
+
+import (
+	"context"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/pricealert"
+)
+
+type ListPriceAlertsQuery struct {
+	UserID string `json:"user_id"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+type ListPriceAlertsQueryHandler struct {
+	watchRepo pricealert.Repository
+}
+
+func NewListPriceAlertsQueryHandler(watchRepo pricealert.Repository) *ListPriceAlertsQueryHandler {
+	return &ListPriceAlertsQueryHandler{watchRepo: watchRepo}
+}
+
+// Handle lists the user's watches, newest first, including those that were
+// already notified or expired.
+func (h *ListPriceAlertsQueryHandler) Handle(ctx context.Context, query ListPriceAlertsQuery) ([]*pricealert.Watch, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListPriceAlertsQueryHandler) handle(ctx context.Context, query ListPriceAlertsQuery) ([]*pricealert.Watch, error) {
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+	return h.watchRepo.ListByUser(ctx, query.UserID, query.Limit, query.Offset)
+}
@@ -1,7 +1,14 @@
 package queries
 
 import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"online-shop/internal/application/pipeline"
 	"online-shop/internal/domain/product"
+	"online-shop/pkg/pagination"
 )
 
 type GetProductQuery struct {
@@ -16,6 +23,38 @@ type SearchProductsQuery struct {
 	MerchantID string  `json:"merchant_id"`
 	Limit      int     `json:"limit"`
 	Offset     int     `json:"offset"`
+	// Attributes narrow the search to products with matching attributes
+	Attributes []product.AttributeFilter `json:"-"`
+	// Locale has the query match product translations to it as well
+	Locale string `json:"-"`
+}
+
+func (q SearchProductsQuery) filter() product.SearchFilter {
+	return product.SearchFilter{
+		Query:      q.Query,
+		Locale:     q.Locale,
+		CategoryID: q.CategoryID,
+		MinPrice:   q.MinPrice,
+		MaxPrice:   q.MaxPrice,
+		MerchantID: q.MerchantID,
+		Status:     product.StatusActive,
+		Attributes: q.Attributes,
+		Limit:      q.Limit,
+		Offset:     q.Offset,
+	}
+}
+
+// ListProductsQuery is a search paged by keyset, newest first, as the v2
+// API lists products. Cursor resumes from an earlier page.
+type ListProductsQuery struct {
+	Search SearchProductsQuery
+	Cursor string
+	Limit  int
+}
+
+type ProductList struct {
+	Products   []*product.Product
+	NextCursor string
 }
 
 type ListCategoriesQuery struct {
@@ -31,35 +70,154 @@ func NewGetProductQueryHandler(productRepo product.Repository) *GetProductQueryH
 	return &GetProductQueryHandler{productRepo: productRepo}
 }
 
-func (h *GetProductQueryHandler) Handle(query GetProductQuery) (*product.Product, error) {
-	return h.productRepo.GetByID(query.ProductID)
+func (h *GetProductQueryHandler) Handle(ctx context.Context, query GetProductQuery) (*product.Product, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetProductQueryHandler) handle(ctx context.Context, query GetProductQuery) (*product.Product, error) {
+	return h.productRepo.GetByID(ctx, query.ProductID)
 }
 
 type SearchProductsQueryHandler struct {
-	productRepo product.Repository
+	productRepo    product.Repository
+	rankingHandler *GetSearchRankingQueryHandler
+}
+
+// NewSearchProductsQueryHandler ranks results by the live merchandising
+// rules matching the search.
+func NewSearchProductsQueryHandler(productRepo product.Repository, rankingHandler *GetSearchRankingQueryHandler) *SearchProductsQueryHandler {
+	return &SearchProductsQueryHandler{productRepo: productRepo, rankingHandler: rankingHandler}
 }
 
-func NewSearchProductsQueryHandler(productRepo product.Repository) *SearchProductsQueryHandler {
-	return &SearchProductsQueryHandler{productRepo: productRepo}
+func (h *SearchProductsQueryHandler) Handle(ctx context.Context, query SearchProductsQuery) ([]*product.Product, error) {
+	return pipeline.Handle(ctx, query, h.handle)
 }
 
-func (h *SearchProductsQueryHandler) Handle(query SearchProductsQuery) ([]*product.Product, error) {
+func (h *SearchProductsQueryHandler) handle(ctx context.Context, query SearchProductsQuery) ([]*product.Product, error) {
 	if query.Limit <= 0 {
 		query.Limit = 20
 	}
 
-	filter := product.SearchFilter{
-		Query:      query.Query,
-		CategoryID: query.CategoryID,
-		MinPrice:   query.MinPrice,
-		MaxPrice:   query.MaxPrice,
-		MerchantID: query.MerchantID,
-		Status:     product.StatusActive,
-		Limit:      query.Limit,
-		Offset:     query.Offset,
+	filter := query.filter()
+	filter.Ranking = searchRanking(ctx, h.rankingHandler, query.Query, query.CategoryID)
+	return h.productRepo.List(ctx, filter)
+}
+
+type ListProductsQueryHandler struct {
+	productRepo product.Repository
+}
+
+func NewListProductsQueryHandler(productRepo product.Repository) *ListProductsQueryHandler {
+	return &ListProductsQueryHandler{productRepo: productRepo}
+}
+
+// productsKeyset scopes the cursors of keyset product lists
+const productsKeyset = "products:-created_at"
+
+// Handle returns a page of the search. Like the admin order list, one
+// product more than the limit is read to tell whether another page follows.
+func (h *ListProductsQueryHandler) Handle(ctx context.Context, query ListProductsQuery) (*ProductList, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListProductsQueryHandler) handle(ctx context.Context, query ListProductsQuery) (*ProductList, error) {
+	if query.Limit <= 0 {
+		query.Limit = pagination.DefaultPerPage
+	}
+
+	var after *product.Position
+	if query.Cursor != "" {
+		values, err := pagination.DecodeKeyset(query.Cursor, productsKeyset)
+		if err != nil {
+			return nil, err
+		}
+		position, err := product.ParsePosition(values)
+		if err != nil {
+			return nil, pagination.ErrInvalidCursor
+		}
+		after = &position
+	}
+
+	products, err := h.productRepo.ListAfter(ctx, query.Search.filter(), after, query.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &ProductList{Products: products}
+	if len(products) > query.Limit {
+		list.Products = products[:query.Limit]
+		last := list.Products[query.Limit-1]
+		list.NextCursor = pagination.EncodeKeyset(productsKeyset, product.PositionOf(last).Strings())
+	}
+	return list, nil
+}
+
+type GetProductFacetsQueryHandler struct {
+	productRepo product.Repository
+}
+
+func NewGetProductFacetsQueryHandler(productRepo product.Repository) *GetProductFacetsQueryHandler {
+	return &GetProductFacetsQueryHandler{productRepo: productRepo}
+}
+
+// Handle counts the products a search matches per attribute value, so the
+// counts narrow along with the attribute filters applied.
+func (h *GetProductFacetsQueryHandler) Handle(ctx context.Context, query SearchProductsQuery) ([]product.Facet, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetProductFacetsQueryHandler) handle(ctx context.Context, query SearchProductsQuery) ([]product.Facet, error) {
+	facets, err := h.productRepo.AttributeFacets(ctx, query.filter())
+	if err != nil {
+		return nil, err
 	}
+	if facets == nil {
+		facets = []product.Facet{}
+	}
+	return facets, nil
+}
+
+type CompareProductsQuery struct {
+	ProductIDs []string `json:"product_ids"`
+}
+
+type CompareProductsQueryHandler struct {
+	productRepo product.Repository
+}
+
+func NewCompareProductsQueryHandler(productRepo product.Repository) *CompareProductsQueryHandler {
+	return &CompareProductsQueryHandler{productRepo: productRepo}
+}
+
+// Handle compares the attributes of two to product.MaxCompared active
+// products, in the order they were asked for.
+func (h *CompareProductsQueryHandler) Handle(ctx context.Context, query CompareProductsQuery) (*product.Comparison, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
 
-	return h.productRepo.List(filter)
+func (h *CompareProductsQueryHandler) handle(ctx context.Context, query CompareProductsQuery) (*product.Comparison, error) {
+	seen := map[string]bool{}
+	var ids []string
+	for _, id := range query.ProductIDs {
+		if id = strings.TrimSpace(id); id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) < 2 || len(ids) > product.MaxCompared {
+		return nil, fmt.Errorf("%w: compare 2 to %d products", product.ErrInvalidComparison, product.MaxCompared)
+	}
+
+	products, err := h.productRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	ordered := orderProducts(ids, products)
+	if len(ordered) != len(ids) {
+		return nil, product.ErrNotFound
+	}
+
+	return product.Compare(ordered), nil
 }
 
 type ListCategoriesQueryHandler struct {
@@ -70,9 +228,283 @@ func NewListCategoriesQueryHandler(categoryRepo product.CategoryRepository) *Lis
 	return &ListCategoriesQueryHandler{categoryRepo: categoryRepo}
 }
 
-func (h *ListCategoriesQueryHandler) Handle(query ListCategoriesQuery) ([]*product.Category, error) {
+func (h *ListCategoriesQueryHandler) Handle(ctx context.Context, query ListCategoriesQuery) ([]*product.Category, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListCategoriesQueryHandler) handle(ctx context.Context, query ListCategoriesQuery) ([]*product.Category, error) {
 	if query.Limit <= 0 {
 		query.Limit = 50
 	}
-	return h.categoryRepo.List(query.Limit, query.Offset)
-}
\ No newline at end of file
+	return h.categoryRepo.List(ctx, query.Limit, query.Offset)
+}
+
+type GetProductBySlugQuery struct {
+	Slug string `json:"slug" validate:"required"`
+}
+
+type GetProductBySlugQueryHandler struct {
+	productRepo  product.Repository
+	redirectRepo product.SlugRedirectRepository
+}
+
+func NewGetProductBySlugQueryHandler(productRepo product.Repository, redirectRepo product.SlugRedirectRepository) *GetProductBySlugQueryHandler {
+	return &GetProductBySlugQueryHandler{
+		productRepo:  productRepo,
+		redirectRepo: redirectRepo,
+	}
+}
+
+// Handle looks the product up by its current slug, falling back to slugs it
+// used to have. Callers should redirect when the returned slug differs.
+func (h *GetProductBySlugQueryHandler) Handle(ctx context.Context, query GetProductBySlugQuery) (*product.Product, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetProductBySlugQueryHandler) handle(ctx context.Context, query GetProductBySlugQuery) (*product.Product, error) {
+	p, err := h.productRepo.GetBySlug(ctx, query.Slug)
+	if err == nil {
+		return p, nil
+	}
+
+	redirect, redirectErr := h.redirectRepo.Find(ctx, product.EntityProduct, query.Slug)
+	if redirectErr != nil {
+		return nil, err
+	}
+	return h.productRepo.GetByID(ctx, redirect.EntityID)
+}
+
+type GetCategoryQuery struct {
+	Slug string `json:"slug" validate:"required"`
+}
+
+type GetCategoryQueryHandler struct {
+	categoryRepo product.CategoryRepository
+	redirectRepo product.SlugRedirectRepository
+}
+
+func NewGetCategoryQueryHandler(categoryRepo product.CategoryRepository, redirectRepo product.SlugRedirectRepository) *GetCategoryQueryHandler {
+	return &GetCategoryQueryHandler{
+		categoryRepo: categoryRepo,
+		redirectRepo: redirectRepo,
+	}
+}
+
+func (h *GetCategoryQueryHandler) Handle(ctx context.Context, query GetCategoryQuery) (*product.Category, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetCategoryQueryHandler) handle(ctx context.Context, query GetCategoryQuery) (*product.Category, error) {
+	c, err := h.categoryRepo.GetBySlug(ctx, query.Slug)
+	if err == nil {
+		return c, nil
+	}
+
+	redirect, redirectErr := h.redirectRepo.Find(ctx, product.EntityCategory, query.Slug)
+	if redirectErr != nil {
+		return nil, err
+	}
+	return h.categoryRepo.GetByID(ctx, redirect.EntityID)
+}
+
+type GetProductsByCategoryQuery struct {
+	Slug   string `json:"slug" validate:"required"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+type GetProductsByCategoryQueryHandler struct {
+	getCategoryHandler *GetCategoryQueryHandler
+	productRepo        product.Repository
+	rankingHandler     *GetSearchRankingQueryHandler
+}
+
+// NewGetProductsByCategoryQueryHandler ranks the category's landing page by
+// the live merchandising rules curating it.
+func NewGetProductsByCategoryQueryHandler(getCategoryHandler *GetCategoryQueryHandler, productRepo product.Repository, rankingHandler *GetSearchRankingQueryHandler) *GetProductsByCategoryQueryHandler {
+	return &GetProductsByCategoryQueryHandler{
+		getCategoryHandler: getCategoryHandler,
+		productRepo:        productRepo,
+		rankingHandler:     rankingHandler,
+	}
+}
+
+func (h *GetProductsByCategoryQueryHandler) Handle(ctx context.Context, query GetProductsByCategoryQuery) (*product.Category, []*product.Product, error) {
+	var category *product.Category
+	products, err := pipeline.Handle(ctx, query, func(ctx context.Context, query GetProductsByCategoryQuery) ([]*product.Product, error) {
+		var products []*product.Product
+		var err error
+		category, products, err = h.handle(ctx, query)
+		return products, err
+	})
+	return category, products, err
+}
+
+func (h *GetProductsByCategoryQueryHandler) handle(ctx context.Context, query GetProductsByCategoryQuery) (*product.Category, []*product.Product, error) {
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+
+	category, err := h.getCategoryHandler.Handle(ctx, GetCategoryQuery{Slug: query.Slug})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	products, err := h.productRepo.List(ctx, product.SearchFilter{
+		CategoryID: category.ID,
+		Status:     product.StatusActive,
+		Ranking:    searchRanking(ctx, h.rankingHandler, "", category.ID),
+		Limit:      query.Limit,
+		Offset:     query.Offset,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return category, products, nil
+}
+
+type GetFeaturedProductsQuery struct {
+	Limit int `json:"limit"`
+}
+
+type GetFeaturedProductsQueryHandler struct {
+	productRepo product.Repository
+}
+
+func NewGetFeaturedProductsQueryHandler(productRepo product.Repository) *GetFeaturedProductsQueryHandler {
+	return &GetFeaturedProductsQueryHandler{productRepo: productRepo}
+}
+
+func (h *GetFeaturedProductsQueryHandler) Handle(ctx context.Context, query GetFeaturedProductsQuery) ([]*product.Product, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetFeaturedProductsQueryHandler) handle(ctx context.Context, query GetFeaturedProductsQuery) ([]*product.Product, error) {
+	if query.Limit <= 0 {
+		query.Limit = 12
+	}
+	return h.productRepo.ListFeatured(ctx, time.Now(), query.Limit)
+}
+
+type GetTrendingProductsQuery struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+type GetTrendingProductsQueryHandler struct {
+	trendingRepo product.TrendingRepository
+	productRepo  product.Repository
+}
+
+func NewGetTrendingProductsQueryHandler(trendingRepo product.TrendingRepository, productRepo product.Repository) *GetTrendingProductsQueryHandler {
+	return &GetTrendingProductsQueryHandler{
+		trendingRepo: trendingRepo,
+		productRepo:  productRepo,
+	}
+}
+
+func (h *GetTrendingProductsQueryHandler) Handle(ctx context.Context, query GetTrendingProductsQuery) ([]*product.Product, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetTrendingProductsQueryHandler) handle(ctx context.Context, query GetTrendingProductsQuery) ([]*product.Product, error) {
+	if query.Limit <= 0 {
+		query.Limit = 12
+	}
+
+	ids, err := h.trendingRepo.Top(ctx, query.Limit, query.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	products, err := h.productRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return orderProducts(ids, products), nil
+}
+
+// orderProducts returns active products in the order of ids, dropping any
+// that no longer exist.
+func orderProducts(ids []string, products []*product.Product) []*product.Product {
+	byID := make(map[string]*product.Product, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+
+	ordered := make([]*product.Product, 0, len(ids))
+	for _, id := range ids {
+		if p, ok := byID[id]; ok && p.Status == product.StatusActive {
+			ordered = append(ordered, p)
+		}
+	}
+	return ordered
+}
+
+type GetRecentlyViewedQuery struct {
+	UserID    string `json:"user_id"`
+	SessionID string `json:"session_id"`
+	Limit     int    `json:"limit"`
+}
+
+type GetRecentlyViewedQueryHandler struct {
+	recentlyViewedRepo product.RecentlyViewedRepository
+	productRepo        product.Repository
+	productCache       product.Cache
+}
+
+func NewGetRecentlyViewedQueryHandler(recentlyViewedRepo product.RecentlyViewedRepository, productRepo product.Repository, productCache product.Cache) *GetRecentlyViewedQueryHandler {
+	return &GetRecentlyViewedQueryHandler{
+		recentlyViewedRepo: recentlyViewedRepo,
+		productRepo:        productRepo,
+		productCache:       productCache,
+	}
+}
+
+// Handle returns the viewer's recently viewed products, most recent first.
+// Products are read from the cache and only cache misses hit the database.
+func (h *GetRecentlyViewedQueryHandler) Handle(ctx context.Context, query GetRecentlyViewedQuery) ([]*product.Product, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetRecentlyViewedQueryHandler) handle(ctx context.Context, query GetRecentlyViewedQuery) ([]*product.Product, error) {
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+
+	viewer := product.ViewerKey(query.UserID, query.SessionID)
+	if viewer == "" {
+		return []*product.Product{}, nil
+	}
+
+	ids, err := h.recentlyViewedRepo.List(ctx, viewer, query.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	products := make([]*product.Product, 0, len(ids))
+	var missing []string
+	for _, id := range ids {
+		var p product.Product
+		if err := h.productCache.GetCachedProduct(ctx, id, &p); err != nil {
+			missing = append(missing, id)
+			continue
+		}
+		products = append(products, &p)
+	}
+
+	if len(missing) > 0 {
+		loaded, err := h.productRepo.GetByIDs(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range loaded {
+			h.productCache.CacheProduct(ctx, p.ID, p)
+		}
+		products = append(products, loaded...)
+	}
+
+	return orderProducts(ids, products), nil
+}
@@ -0,0 +1,103 @@
+package queries
+
+import (
+	"context"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/productversion"
+)
+
+type ListProductVersionsQuery struct {
+	ProductID string `json:"product_id" validate:"required"`
+	Limit     int    `json:"limit"`
+	Offset    int    `json:"offset"`
+}
+
+type ProductVersionPage struct {
+	Versions []*productversion.Version
+	Total    int64
+}
+
+type ListProductVersionsQueryHandler struct {
+	versionRepo productversion.Repository
+}
+
+func NewListProductVersionsQueryHandler(versionRepo productversion.Repository) *ListProductVersionsQueryHandler {
+	return &ListProductVersionsQueryHandler{versionRepo: versionRepo}
+}
+
+// Handle lists the versions of a product, newest first.
+func (h *ListProductVersionsQueryHandler) Handle(ctx context.Context, query ListProductVersionsQuery) (*ProductVersionPage, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListProductVersionsQueryHandler) handle(ctx context.Context, query ListProductVersionsQuery) (*ProductVersionPage, error) {
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+
+	versions, total, err := h.versionRepo.List(ctx, query.ProductID, query.Limit, query.Offset)
+	if err != nil {
+		return nil, err
+	}
+	return &ProductVersionPage{Versions: versions, Total: total}, nil
+}
+
+type GetProductVersionQuery struct {
+	ProductID string `json:"product_id" validate:"required"`
+	Version   int    `json:"version" validate:"min=1"`
+}
+
+type GetProductVersionQueryHandler struct {
+	versionRepo productversion.Repository
+}
+
+func NewGetProductVersionQueryHandler(versionRepo productversion.Repository) *GetProductVersionQueryHandler {
+	return &GetProductVersionQueryHandler{versionRepo: versionRepo}
+}
+
+func (h *GetProductVersionQueryHandler) Handle(ctx context.Context, query GetProductVersionQuery) (*productversion.Version, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetProductVersionQueryHandler) handle(ctx context.Context, query GetProductVersionQuery) (*productversion.Version, error) {
+	return h.versionRepo.Get(ctx, query.ProductID, query.Version)
+}
+
+// DiffProductVersionsQuery compares version From of a product with version
+// To, or with its latest version when To is 0.
+type DiffProductVersionsQuery struct {
+	ProductID string `json:"product_id" validate:"required"`
+	From      int    `json:"from" validate:"min=1"`
+	To        int    `json:"to" validate:"min=0"`
+}
+
+type DiffProductVersionsQueryHandler struct {
+	versionRepo productversion.Repository
+}
+
+func NewDiffProductVersionsQueryHandler(versionRepo productversion.Repository) *DiffProductVersionsQueryHandler {
+	return &DiffProductVersionsQueryHandler{versionRepo: versionRepo}
+}
+
+func (h *DiffProductVersionsQueryHandler) Handle(ctx context.Context, query DiffProductVersionsQuery) (*productversion.Comparison, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *DiffProductVersionsQueryHandler) handle(ctx context.Context, query DiffProductVersionsQuery) (*productversion.Comparison, error) {
+	from, err := h.versionRepo.Get(ctx, query.ProductID, query.From)
+	if err != nil {
+		return nil, err
+	}
+
+	var to *productversion.Version
+	if query.To > 0 {
+		to, err = h.versionRepo.Get(ctx, query.ProductID, query.To)
+	} else {
+		to, err = h.versionRepo.Latest(ctx, query.ProductID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return productversion.Compare(from, to), nil
+}
@@ -0,0 +1,86 @@
+package queries
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/quote"
+)
+
+// ListQuotesQuery lists a buyer's quotes when UserID is set, or a
+// merchant's when MerchantID is; admins set neither and see every quote.
+type ListQuotesQuery struct {
+	UserID     string       `json:"user_id"`
+	MerchantID string       `json:"merchant_id"`
+	Status     quote.Status `json:"status" validate:"omitempty,oneof=requested offered accepted declined cancelled expired"`
+	Limit      int          `json:"limit"`
+	Offset     int          `json:"offset"`
+}
+
+type ListQuotesQueryHandler struct {
+	quoteRepo quote.Repository
+}
+
+func NewListQuotesQueryHandler(quoteRepo quote.Repository) *ListQuotesQueryHandler {
+	return &ListQuotesQueryHandler{quoteRepo: quoteRepo}
+}
+
+// Handle lists the quotes newest first. Lapsed offers are listed as expired;
+// they are stored as offered, so asking for offered quotes lists them too.
+func (h *ListQuotesQueryHandler) Handle(ctx context.Context, query ListQuotesQuery) ([]*quote.Quote, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListQuotesQueryHandler) handle(ctx context.Context, query ListQuotesQuery) ([]*quote.Quote, error) {
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+	quotes, err := h.quoteRepo.List(ctx, quote.Filter{
+		UserID:     query.UserID,
+		MerchantID: query.MerchantID,
+		Status:     query.Status,
+	}, query.Limit, query.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, q := range quotes {
+		q.ExpireAt(now)
+	}
+	return quotes, nil
+}
+
+// GetQuoteQuery reads a quote as its buyer, when UserID is set, or as its
+// merchant, when MerchantID is; anyone else's quote is not found.
+type GetQuoteQuery struct {
+	QuoteID    string `json:"quote_id" validate:"required"`
+	UserID     string `json:"user_id"`
+	MerchantID string `json:"merchant_id"`
+}
+
+type GetQuoteQueryHandler struct {
+	quoteRepo quote.Repository
+}
+
+func NewGetQuoteQueryHandler(quoteRepo quote.Repository) *GetQuoteQueryHandler {
+	return &GetQuoteQueryHandler{quoteRepo: quoteRepo}
+}
+
+func (h *GetQuoteQueryHandler) Handle(ctx context.Context, query GetQuoteQuery) (*quote.Quote, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetQuoteQueryHandler) handle(ctx context.Context, query GetQuoteQuery) (*quote.Quote, error) {
+	q, err := h.quoteRepo.GetByID(ctx, query.QuoteID)
+	if err != nil {
+		return nil, err
+	}
+	if (query.UserID != "" && q.UserID != query.UserID) || (query.MerchantID != "" && q.MerchantID != query.MerchantID) {
+		return nil, commands.ErrQuoteNotFound
+	}
+	q.ExpireAt(time.Now())
+	return q, nil
+}
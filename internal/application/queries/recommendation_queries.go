@@ -0,0 +1,108 @@
+package queries
+
+import (
+	"context"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/recommendation"
+)
+
+type GetSimilarProductsQuery struct {
+	ProductID string `json:"product_id"`
+	Limit     int    `json:"limit"`
+}
+
+type GetSimilarProductsQueryHandler struct {
+	productRepo product.Repository
+	index       recommendation.SimilarityIndex
+}
+
+func NewGetSimilarProductsQueryHandler(productRepo product.Repository, index recommendation.SimilarityIndex) *GetSimilarProductsQueryHandler {
+	return &GetSimilarProductsQueryHandler{
+		productRepo: productRepo,
+		index:       index,
+	}
+}
+
+// Handle asks the similarity index first and falls back to same-category
+// products ranked by price when the index fails or has nothing to offer.
+func (h *GetSimilarProductsQueryHandler) Handle(ctx context.Context, query GetSimilarProductsQuery) ([]*product.Product, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetSimilarProductsQueryHandler) handle(ctx context.Context, query GetSimilarProductsQuery) ([]*product.Product, error) {
+	if query.Limit <= 0 {
+		query.Limit = 10
+	}
+
+	target, err := h.productRepo.GetByID(ctx, query.ProductID)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.index != nil {
+		ids, err := h.index.SimilarProducts(ctx, target, query.Limit)
+		if err == nil && len(ids) > 0 {
+			products, err := h.productRepo.GetByIDs(ctx, ids)
+			if err == nil {
+				return orderProducts(ids, products), nil
+			}
+		}
+	}
+
+	candidates, err := h.productRepo.List(ctx, product.SearchFilter{
+		CategoryID: target.CategoryID,
+		Status:     product.StatusActive,
+		Limit:      query.Limit * 5,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return recommendation.RankByPrice(target, candidates, query.Limit), nil
+}
+
+type GetBoughtTogetherQuery struct {
+	ProductID string `json:"product_id"`
+	Limit     int    `json:"limit"`
+}
+
+type GetBoughtTogetherQueryHandler struct {
+	recommendationRepo recommendation.Repository
+	productRepo        product.Repository
+}
+
+func NewGetBoughtTogetherQueryHandler(recommendationRepo recommendation.Repository, productRepo product.Repository) *GetBoughtTogetherQueryHandler {
+	return &GetBoughtTogetherQueryHandler{
+		recommendationRepo: recommendationRepo,
+		productRepo:        productRepo,
+	}
+}
+
+func (h *GetBoughtTogetherQueryHandler) Handle(ctx context.Context, query GetBoughtTogetherQuery) ([]*product.Product, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetBoughtTogetherQueryHandler) handle(ctx context.Context, query GetBoughtTogetherQuery) ([]*product.Product, error) {
+	if query.Limit <= 0 {
+		query.Limit = 6
+	}
+
+	pairs, err := h.recommendationRepo.BoughtTogether(ctx, query.ProductID, query.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		ids = append(ids, pair.RelatedID)
+	}
+
+	products, err := h.productRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return orderProducts(ids, products), nil
+}
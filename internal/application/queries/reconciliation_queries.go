@@ -0,0 +1,55 @@
+package queries
+
+import (
+	"context"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/reconciliation"
+)
+
+type ListReconciliationRunsQuery struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+type ListReconciliationRunsQueryHandler struct {
+	runRepo reconciliation.Repository
+}
+
+func NewListReconciliationRunsQueryHandler(runRepo reconciliation.Repository) *ListReconciliationRunsQueryHandler {
+	return &ListReconciliationRunsQueryHandler{runRepo: runRepo}
+}
+
+// Handle lists runs with their counts, newest period first; the mismatches
+// themselves come with a single run.
+func (h *ListReconciliationRunsQueryHandler) Handle(ctx context.Context, query ListReconciliationRunsQuery) ([]*reconciliation.Run, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListReconciliationRunsQueryHandler) handle(ctx context.Context, query ListReconciliationRunsQuery) ([]*reconciliation.Run, error) {
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+
+	return h.runRepo.List(ctx, query.Limit, query.Offset)
+}
+
+type GetReconciliationRunQuery struct {
+	RunID string `json:"run_id"`
+}
+
+type GetReconciliationRunQueryHandler struct {
+	runRepo reconciliation.Repository
+}
+
+func NewGetReconciliationRunQueryHandler(runRepo reconciliation.Repository) *GetReconciliationRunQueryHandler {
+	return &GetReconciliationRunQueryHandler{runRepo: runRepo}
+}
+
+func (h *GetReconciliationRunQueryHandler) Handle(ctx context.Context, query GetReconciliationRunQuery) (*reconciliation.Run, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetReconciliationRunQueryHandler) handle(ctx context.Context, query GetReconciliationRunQuery) (*reconciliation.Run, error) {
+	return h.runRepo.GetByID(ctx, query.RunID)
+}
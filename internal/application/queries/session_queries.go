@@ -0,0 +1,38 @@
+package queries
+
+import (
+	"context"
+	"sort"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/session"
+)
+
+type ListSessionsQuery struct {
+	UserID string `json:"user_id"`
+}
+
+type ListSessionsQueryHandler struct {
+	store session.Store
+}
+
+func NewListSessionsQueryHandler(store session.Store) *ListSessionsQueryHandler {
+	return &ListSessionsQueryHandler{store: store}
+}
+
+// Handle returns the user's sessions, most recently used first
+func (h *ListSessionsQueryHandler) Handle(ctx context.Context, query ListSessionsQuery) ([]*session.Session, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListSessionsQueryHandler) handle(ctx context.Context, query ListSessionsQuery) ([]*session.Session, error) {
+	sessions, err := h.store.ListByUser(ctx, query.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+	return sessions, nil
+}
@@ -0,0 +1,131 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/shipping"
+)
+
+type ListShippingZonesQuery struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+type ListShippingZonesQueryHandler struct {
+	zoneRepo shipping.Repository
+}
+
+func NewListShippingZonesQueryHandler(zoneRepo shipping.Repository) *ListShippingZonesQueryHandler {
+	return &ListShippingZonesQueryHandler{zoneRepo: zoneRepo}
+}
+
+func (h *ListShippingZonesQueryHandler) Handle(ctx context.Context, query ListShippingZonesQuery) ([]*shipping.Zone, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListShippingZonesQueryHandler) handle(ctx context.Context, query ListShippingZonesQuery) ([]*shipping.Zone, error) {
+	if query.Limit <= 0 {
+		query.Limit = 50
+	}
+	return h.zoneRepo.List(ctx, query.Limit, query.Offset)
+}
+
+// GetShippingQuoteQuery asks what shipping items adding up to Subtotal to
+// the address costs, and when the products arrive.
+type GetShippingQuoteQuery struct {
+	Address    order.Address `json:"address" validate:"required"`
+	Subtotal   float64       `json:"subtotal" validate:"min=0"`
+	ProductIDs []string      `json:"product_ids" validate:"max=100"`
+}
+
+type GetShippingQuoteQueryHandler struct {
+	zoneRepo  shipping.Repository
+	geocoder  shipping.Geocoder
+	locations shipping.LocationCache
+	ttl       time.Duration
+	estimator shipping.Estimator
+}
+
+// NewGetShippingQuoteQueryHandler quotes from the active zones. Addresses
+// are looked up with the geocoder first, unless it is nil, and options are
+// given delivery estimates unless the estimator is.
+func NewGetShippingQuoteQueryHandler(zoneRepo shipping.Repository, geocoder shipping.Geocoder, locations shipping.LocationCache, ttl time.Duration, estimator shipping.Estimator) *GetShippingQuoteQueryHandler {
+	return &GetShippingQuoteQueryHandler{zoneRepo: zoneRepo, geocoder: geocoder, locations: locations, ttl: ttl, estimator: estimator}
+}
+
+// Handle checks the address can be found, then lists the rates of the zone
+// it falls in. An address the geocoder cannot find, or finds in another
+// country, is turned away; when the geocoder cannot be reached the address
+// is taken as given.
+func (h *GetShippingQuoteQueryHandler) Handle(ctx context.Context, query GetShippingQuoteQuery) (*shipping.Quote, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetShippingQuoteQueryHandler) handle(ctx context.Context, query GetShippingQuoteQuery) (*shipping.Quote, error) {
+	address := query.Address
+	location, err := h.locate(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if location != nil {
+		address = location.Resolve(address)
+	}
+
+	zones, err := h.zoneRepo.ListActive(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(zones) == 0 {
+		return &shipping.Quote{Options: []shipping.Option{}, Location: location}, nil
+	}
+	zone := shipping.Match(zones, address)
+	if zone == nil {
+		return nil, shipping.ErrNotServiceable
+	}
+
+	quote := shipping.NewQuote(zone, query.Subtotal)
+	quote.Location = location
+	if h.estimator != nil {
+		if err := h.estimator.EstimateQuote(ctx, quote, query.ProductIDs); err != nil {
+			return nil, err
+		}
+	}
+	return quote, nil
+}
+
+// Quote makes the handler the shipping.Quoter of new orders
+func (h *GetShippingQuoteQueryHandler) Quote(ctx context.Context, address order.Address, subtotal float64, productIDs []string) (*shipping.Quote, error) {
+	return h.handle(ctx, GetShippingQuoteQuery{Address: address, Subtotal: subtotal, ProductIDs: productIDs})
+}
+
+// locate finds the address from the cache or the geocoder, and caches what
+// the geocoder found. It returns nil when there is no geocoder or it
+// cannot be reached.
+func (h *GetShippingQuoteQueryHandler) locate(ctx context.Context, address order.Address) (*shipping.Location, error) {
+	if h.geocoder == nil {
+		return nil, nil
+	}
+	key := shipping.AddressKey(address)
+	location, err := h.locations.Get(ctx, key)
+	if err != nil || location == nil {
+		location, err = h.geocoder.Geocode(ctx, address)
+		if errors.Is(err, shipping.ErrAddressNotFound) {
+			return nil, err
+		}
+		if err != nil {
+			return nil, nil
+		}
+		_ = h.locations.Set(ctx, key, location, h.ttl)
+	}
+
+	if location.Country != "" && !strings.EqualFold(location.Country, address.Country) {
+		return nil, fmt.Errorf("%w: it was found in %s", shipping.ErrAddressNotFound, location.Country)
+	}
+	return location, nil
+}
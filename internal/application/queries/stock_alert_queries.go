@@ -0,0 +1,35 @@
+package queries
+
+import (
+	"context"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/stockalert"
+)
+
+type ListStockAlertsQuery struct {
+	UserID string `json:"user_id"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+type ListStockAlertsQueryHandler struct {
+	subscriptionRepo stockalert.Repository
+}
+
+func NewListStockAlertsQueryHandler(subscriptionRepo stockalert.Repository) *ListStockAlertsQueryHandler {
+	return &ListStockAlertsQueryHandler{subscriptionRepo: subscriptionRepo}
+}
+
+// Handle lists the user's subscriptions, newest first, including those that
+// were already notified or expired.
+func (h *ListStockAlertsQueryHandler) Handle(ctx context.Context, query ListStockAlertsQuery) ([]*stockalert.Subscription, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListStockAlertsQueryHandler) handle(ctx context.Context, query ListStockAlertsQuery) ([]*stockalert.Subscription, error) {
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+	return h.subscriptionRepo.ListByUser(ctx, query.UserID, query.Limit, query.Offset)
+}
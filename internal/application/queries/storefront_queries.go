@@ -0,0 +1,55 @@
+package queries
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/storefront"
+)
+
+type GetStorefrontSettingsQuery struct{}
+
+type GetStorefrontSettingsQueryHandler struct {
+	repo     storefront.Repository
+	cache    storefront.Cache
+	defaults *storefront.Settings
+	ttl      time.Duration
+}
+
+func NewGetStorefrontSettingsQueryHandler(repo storefront.Repository, cache storefront.Cache, defaults *storefront.Settings, ttl time.Duration) *GetStorefrontSettingsQueryHandler {
+	return &GetStorefrontSettingsQueryHandler{repo: repo, cache: cache, defaults: defaults, ttl: ttl}
+}
+
+// Handle serves the settings from the cache, or from the database on a
+// miss, then caches them. Until an admin saves them the defaults apply.
+// When the cache cannot be reached the settings are still served.
+func (h *GetStorefrontSettingsQueryHandler) Handle(ctx context.Context, query GetStorefrontSettingsQuery) (*storefront.Settings, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetStorefrontSettingsQueryHandler) handle(ctx context.Context, query GetStorefrontSettingsQuery) (*storefront.Settings, error) {
+	if cached, err := h.cache.Get(ctx); err == nil && cached != nil {
+		return cached, nil
+	}
+
+	settings, err := h.repo.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if settings == nil {
+		defaults := *h.defaults
+		settings = &defaults
+	}
+	_ = h.cache.Set(ctx, settings, h.ttl)
+	return settings, nil
+}
+
+// NumberPrefix makes order numbers follow the prefix in the settings
+func (h *GetStorefrontSettingsQueryHandler) NumberPrefix(ctx context.Context) (string, error) {
+	settings, err := h.handle(ctx, GetStorefrontSettingsQuery{})
+	if err != nil {
+		return "", err
+	}
+	return settings.OrderNumberPrefix, nil
+}
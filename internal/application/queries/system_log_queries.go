@@ -0,0 +1,53 @@
+package queries
+
+import (
+	"context"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/systemlog"
+)
+
+type SearchSystemLogsQuery struct {
+	Filter systemlog.Filter `json:"filter"`
+	Limit  int              `json:"limit"`
+	Offset int              `json:"offset"`
+}
+
+type SystemLogPage struct {
+	Entries []*systemlog.Entry `json:"entries"`
+	Total   int64              `json:"total"`
+}
+
+type SearchSystemLogsQueryHandler struct {
+	reader systemlog.Reader
+}
+
+func NewSearchSystemLogsQueryHandler(reader systemlog.Reader) *SearchSystemLogsQueryHandler {
+	return &SearchSystemLogsQueryHandler{reader: reader}
+}
+
+func (h *SearchSystemLogsQueryHandler) Handle(ctx context.Context, query SearchSystemLogsQuery) (*SystemLogPage, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *SearchSystemLogsQueryHandler) handle(ctx context.Context, query SearchSystemLogsQuery) (*SystemLogPage, error) {
+	if err := query.Filter.Validate(); err != nil {
+		return nil, err
+	}
+	if query.Limit <= 0 {
+		query.Limit = 100
+	}
+	if query.Limit > 500 {
+		query.Limit = 500
+	}
+	if query.Offset < 0 {
+		query.Offset = 0
+	}
+
+	entries, total, err := h.reader.Query(ctx, query.Filter, query.Limit, query.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SystemLogPage{Entries: entries, Total: total}, nil
+}
@@ -0,0 +1,196 @@
+package queries
+
+import (
+	"context"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/product"
+	"online-shop/pkg/i18n"
+)
+
+// LocalizeCatalogQuery translates products and categories being served to
+// Locale. Those without a translation to it, and everything in the default
+// locale, are served as written.
+type LocalizeCatalogQuery struct {
+	Locale     string
+	Products   []*product.Product
+	Categories []*product.Category
+}
+
+type LocalizeCatalogQueryHandler struct {
+	translationRepo product.TranslationRepository
+}
+
+func NewLocalizeCatalogQueryHandler(translationRepo product.TranslationRepository) *LocalizeCatalogQueryHandler {
+	return &LocalizeCatalogQueryHandler{translationRepo: translationRepo}
+}
+
+// Handle also translates the category each product is served with.
+func (h *LocalizeCatalogQueryHandler) Handle(ctx context.Context, query LocalizeCatalogQuery) error {
+	return pipeline.Exec(ctx, query, h.handle)
+}
+
+func (h *LocalizeCatalogQueryHandler) handle(ctx context.Context, query LocalizeCatalogQuery) error {
+	if query.Locale == "" || query.Locale == i18n.DefaultLocale {
+		return nil
+	}
+
+	productIDs := make([]string, 0, len(query.Products))
+	categories := append([]*product.Category{}, query.Categories...)
+	for _, p := range query.Products {
+		productIDs = append(productIDs, p.ID)
+		if p.Category != nil {
+			categories = append(categories, p.Category)
+		}
+	}
+	categoryIDs := make([]string, 0, len(categories))
+	for _, c := range categories {
+		categoryIDs = append(categoryIDs, c.ID)
+	}
+
+	translations, err := h.translationRepo.ForProducts(ctx, query.Locale, productIDs)
+	if err != nil {
+		return err
+	}
+	for _, p := range query.Products {
+		if t, ok := translations[p.ID]; ok {
+			p.Translate(t)
+		}
+	}
+
+	categoryTranslations, err := h.translationRepo.ForCategories(ctx, query.Locale, categoryIDs)
+	if err != nil {
+		return err
+	}
+	for _, c := range categories {
+		if t, ok := categoryTranslations[c.ID]; ok {
+			c.Translate(t)
+		}
+	}
+	return nil
+}
+
+type ListProductTranslationsQuery struct {
+	ProductID string `json:"product_id" validate:"required"`
+}
+
+type ListProductTranslationsQueryHandler struct {
+	translationRepo product.TranslationRepository
+}
+
+func NewListProductTranslationsQueryHandler(translationRepo product.TranslationRepository) *ListProductTranslationsQueryHandler {
+	return &ListProductTranslationsQueryHandler{translationRepo: translationRepo}
+}
+
+func (h *ListProductTranslationsQueryHandler) Handle(ctx context.Context, query ListProductTranslationsQuery) ([]*product.Translation, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListProductTranslationsQueryHandler) handle(ctx context.Context, query ListProductTranslationsQuery) ([]*product.Translation, error) {
+	return h.translationRepo.ListByProduct(ctx, query.ProductID)
+}
+
+type ListCategoryTranslationsQuery struct {
+	CategoryID string `json:"category_id" validate:"required"`
+}
+
+type ListCategoryTranslationsQueryHandler struct {
+	translationRepo product.TranslationRepository
+}
+
+func NewListCategoryTranslationsQueryHandler(translationRepo product.TranslationRepository) *ListCategoryTranslationsQueryHandler {
+	return &ListCategoryTranslationsQueryHandler{translationRepo: translationRepo}
+}
+
+func (h *ListCategoryTranslationsQueryHandler) Handle(ctx context.Context, query ListCategoryTranslationsQuery) ([]*product.CategoryTranslation, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListCategoryTranslationsQueryHandler) handle(ctx context.Context, query ListCategoryTranslationsQuery) ([]*product.CategoryTranslation, error) {
+	return h.translationRepo.ListByCategory(ctx, query.CategoryID)
+}
+
+type GetTranslationReportQuery struct{}
+
+type GetTranslationReportQueryHandler struct {
+	translationRepo product.TranslationRepository
+	locales         []string
+}
+
+// NewGetTranslationReportQueryHandler reports on the locales the catalog is
+// translated to, those other than the default one.
+func NewGetTranslationReportQueryHandler(translationRepo product.TranslationRepository, locales []string) *GetTranslationReportQueryHandler {
+	return &GetTranslationReportQueryHandler{translationRepo: translationRepo, locales: locales}
+}
+
+// Handle counts, per locale, the active products and the categories and how
+// many of them are translated.
+func (h *GetTranslationReportQueryHandler) Handle(ctx context.Context, query GetTranslationReportQuery) ([]product.TranslationCoverage, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetTranslationReportQueryHandler) handle(ctx context.Context, query GetTranslationReportQuery) ([]product.TranslationCoverage, error) {
+	report := make([]product.TranslationCoverage, 0, len(h.locales))
+	for _, locale := range h.locales {
+		coverage, err := h.translationRepo.Coverage(ctx, locale)
+		if err != nil {
+			return nil, err
+		}
+		report = append(report, coverage)
+	}
+	return report, nil
+}
+
+// ListMissingTranslationsQuery lists what is not translated to Locale yet: a
+// page of the active products, newest first, and every category.
+type ListMissingTranslationsQuery struct {
+	Locale string `json:"locale" validate:"required,locale"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+type MissingTranslations struct {
+	Locale     string              `json:"locale"`
+	Products   []*product.Product  `json:"products"`
+	Total      int64               `json:"total"`
+	Categories []*product.Category `json:"categories"`
+}
+
+type ListMissingTranslationsQueryHandler struct {
+	translationRepo product.TranslationRepository
+}
+
+func NewListMissingTranslationsQueryHandler(translationRepo product.TranslationRepository) *ListMissingTranslationsQueryHandler {
+	return &ListMissingTranslationsQueryHandler{translationRepo: translationRepo}
+}
+
+func (h *ListMissingTranslationsQueryHandler) Handle(ctx context.Context, query ListMissingTranslationsQuery) (*MissingTranslations, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListMissingTranslationsQueryHandler) handle(ctx context.Context, query ListMissingTranslationsQuery) (*MissingTranslations, error) {
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+	missing := &MissingTranslations{Locale: query.Locale, Products: []*product.Product{}, Categories: []*product.Category{}}
+	if query.Locale == i18n.DefaultLocale {
+		return missing, nil
+	}
+
+	products, total, err := h.translationRepo.MissingProducts(ctx, query.Locale, query.Limit, query.Offset)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := h.translationRepo.MissingCategories(ctx, query.Locale)
+	if err != nil {
+		return nil, err
+	}
+	if products != nil {
+		missing.Products = products
+	}
+	if categories != nil {
+		missing.Categories = categories
+	}
+	missing.Total = total
+	return missing, nil
+}
@@ -1,6 +1,9 @@
 package queries
 
 import (
+	"context"
+
+	"online-shop/internal/application/pipeline"
 	"online-shop/internal/domain/user"
 )
 
@@ -21,8 +24,12 @@ func NewGetUserProfileQueryHandler(userRepo user.Repository) *GetUserProfileQuer
 	return &GetUserProfileQueryHandler{userRepo: userRepo}
 }
 
-func (h *GetUserProfileQueryHandler) Handle(query GetUserProfileQuery) (*user.User, error) {
-	return h.userRepo.GetByID(query.UserID)
+func (h *GetUserProfileQueryHandler) Handle(ctx context.Context, query GetUserProfileQuery) (*user.User, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetUserProfileQueryHandler) handle(ctx context.Context, query GetUserProfileQuery) (*user.User, error) {
+	return h.userRepo.GetByID(ctx, query.UserID)
 }
 
 type ListUsersQueryHandler struct {
@@ -33,9 +40,13 @@ func NewListUsersQueryHandler(userRepo user.Repository) *ListUsersQueryHandler {
 	return &ListUsersQueryHandler{userRepo: userRepo}
 }
 
-func (h *ListUsersQueryHandler) Handle(query ListUsersQuery) ([]*user.User, error) {
+func (h *ListUsersQueryHandler) Handle(ctx context.Context, query ListUsersQuery) ([]*user.User, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListUsersQueryHandler) handle(ctx context.Context, query ListUsersQuery) ([]*user.User, error) {
 	if query.Limit <= 0 {
 		query.Limit = 10
 	}
-	return h.userRepo.List(query.Limit, query.Offset)
+	return h.userRepo.List(ctx, query.Limit, query.Offset)
 }
\ No newline at end of file
@@ -0,0 +1,57 @@
+package queries
+
+import (
+	"context"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/warehouse"
+)
+
+type ListWarehousesQuery struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+type ListWarehousesQueryHandler struct {
+	warehouseRepo warehouse.Repository
+}
+
+func NewListWarehousesQueryHandler(warehouseRepo warehouse.Repository) *ListWarehousesQueryHandler {
+	return &ListWarehousesQueryHandler{warehouseRepo: warehouseRepo}
+}
+
+func (h *ListWarehousesQueryHandler) Handle(ctx context.Context, query ListWarehousesQuery) ([]*warehouse.Warehouse, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListWarehousesQueryHandler) handle(ctx context.Context, query ListWarehousesQuery) ([]*warehouse.Warehouse, error) {
+	if query.Limit <= 0 {
+		query.Limit = 50
+	}
+	return h.warehouseRepo.List(ctx, query.Limit, query.Offset)
+}
+
+type GetWarehouseStockQuery struct {
+	WarehouseID string `json:"warehouse_id" validate:"required"`
+	Limit       int    `json:"limit"`
+	Offset      int    `json:"offset"`
+}
+
+type GetWarehouseStockQueryHandler struct {
+	stockRepo warehouse.StockRepository
+}
+
+func NewGetWarehouseStockQueryHandler(stockRepo warehouse.StockRepository) *GetWarehouseStockQueryHandler {
+	return &GetWarehouseStockQueryHandler{stockRepo: stockRepo}
+}
+
+func (h *GetWarehouseStockQueryHandler) Handle(ctx context.Context, query GetWarehouseStockQuery) ([]*warehouse.Stock, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetWarehouseStockQueryHandler) handle(ctx context.Context, query GetWarehouseStockQuery) ([]*warehouse.Stock, error) {
+	if query.Limit <= 0 {
+		query.Limit = 100
+	}
+	return h.stockRepo.GetByWarehouse(ctx, query.WarehouseID, query.Limit, query.Offset)
+}
@@ -0,0 +1,152 @@
+package queries
+
+import (
+	"context"
+	"errors"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/webhook"
+)
+
+// ListWebhookEndpointsQuery lists the endpoints of the merchant in Scope, or
+// every endpoint for an empty Scope.
+type ListWebhookEndpointsQuery struct {
+	Scope  string `json:"-"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+type ListWebhookEndpointsQueryHandler struct {
+	endpointRepo webhook.EndpointRepository
+}
+
+func NewListWebhookEndpointsQueryHandler(endpointRepo webhook.EndpointRepository) *ListWebhookEndpointsQueryHandler {
+	return &ListWebhookEndpointsQueryHandler{endpointRepo: endpointRepo}
+}
+
+func (h *ListWebhookEndpointsQueryHandler) Handle(ctx context.Context, query ListWebhookEndpointsQuery) ([]*webhook.Endpoint, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListWebhookEndpointsQueryHandler) handle(ctx context.Context, query ListWebhookEndpointsQuery) ([]*webhook.Endpoint, error) {
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+	return h.endpointRepo.List(ctx, query.Scope, query.Limit, query.Offset)
+}
+
+type GetWebhookEndpointQuery struct {
+	ID    string `json:"id"`
+	Scope string `json:"-"`
+}
+
+type GetWebhookEndpointQueryHandler struct {
+	endpointRepo webhook.EndpointRepository
+}
+
+func NewGetWebhookEndpointQueryHandler(endpointRepo webhook.EndpointRepository) *GetWebhookEndpointQueryHandler {
+	return &GetWebhookEndpointQueryHandler{endpointRepo: endpointRepo}
+}
+
+func (h *GetWebhookEndpointQueryHandler) Handle(ctx context.Context, query GetWebhookEndpointQuery) (*webhook.Endpoint, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetWebhookEndpointQueryHandler) handle(ctx context.Context, query GetWebhookEndpointQuery) (*webhook.Endpoint, error) {
+	return scopedWebhookEndpoint(ctx, h.endpointRepo, query.ID, query.Scope)
+}
+
+// ListWebhookDeliveriesQuery is the delivery log of an endpoint.
+type ListWebhookDeliveriesQuery struct {
+	EndpointID string `json:"endpoint_id"`
+	Scope      string `json:"-"`
+	Status     string `json:"status" validate:"omitempty,oneof=pending succeeded failed"`
+	Event      string `json:"event" validate:"omitempty,oneof=order.created order.shipped product.updated quote.requested quote.accepted"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+}
+
+type WebhookDeliveryPage struct {
+	Deliveries []*webhook.Delivery
+	Total      int64
+}
+
+type ListWebhookDeliveriesQueryHandler struct {
+	endpointRepo webhook.EndpointRepository
+	deliveryRepo webhook.DeliveryRepository
+}
+
+func NewListWebhookDeliveriesQueryHandler(endpointRepo webhook.EndpointRepository, deliveryRepo webhook.DeliveryRepository) *ListWebhookDeliveriesQueryHandler {
+	return &ListWebhookDeliveriesQueryHandler{endpointRepo: endpointRepo, deliveryRepo: deliveryRepo}
+}
+
+func (h *ListWebhookDeliveriesQueryHandler) Handle(ctx context.Context, query ListWebhookDeliveriesQuery) (*WebhookDeliveryPage, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListWebhookDeliveriesQueryHandler) handle(ctx context.Context, query ListWebhookDeliveriesQuery) (*WebhookDeliveryPage, error) {
+	if _, err := scopedWebhookEndpoint(ctx, h.endpointRepo, query.EndpointID, query.Scope); err != nil {
+		return nil, err
+	}
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+
+	filter := webhook.DeliveryFilter{
+		EndpointID: query.EndpointID,
+		Status:     webhook.DeliveryStatus(query.Status),
+		Event:      webhook.Event(query.Event),
+	}
+	deliveries, total, err := h.deliveryRepo.List(ctx, filter, query.Limit, query.Offset)
+	if err != nil {
+		return nil, err
+	}
+	return &WebhookDeliveryPage{Deliveries: deliveries, Total: total}, nil
+}
+
+// GetWebhookDeliveryQuery returns a delivery with the log of its attempts.
+type GetWebhookDeliveryQuery struct {
+	ID    string `json:"id"`
+	Scope string `json:"-"`
+}
+
+type GetWebhookDeliveryQueryHandler struct {
+	endpointRepo webhook.EndpointRepository
+	deliveryRepo webhook.DeliveryRepository
+}
+
+func NewGetWebhookDeliveryQueryHandler(endpointRepo webhook.EndpointRepository, deliveryRepo webhook.DeliveryRepository) *GetWebhookDeliveryQueryHandler {
+	return &GetWebhookDeliveryQueryHandler{endpointRepo: endpointRepo, deliveryRepo: deliveryRepo}
+}
+
+func (h *GetWebhookDeliveryQueryHandler) Handle(ctx context.Context, query GetWebhookDeliveryQuery) (*webhook.Delivery, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetWebhookDeliveryQueryHandler) handle(ctx context.Context, query GetWebhookDeliveryQuery) (*webhook.Delivery, error) {
+	d, err := h.deliveryRepo.GetByID(ctx, query.ID)
+	if err != nil {
+		return nil, err
+	}
+	_, err = scopedWebhookEndpoint(ctx, h.endpointRepo, d.EndpointID, query.Scope)
+	if errors.Is(err, webhook.ErrEndpointNotFound) {
+		return nil, webhook.ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// scopedWebhookEndpoint returns the endpoint when it is managed in the
+// scope; another merchant's endpoint is not found
+func scopedWebhookEndpoint(ctx context.Context, endpointRepo webhook.EndpointRepository, id, scope string) (*webhook.Endpoint, error) {
+	e, err := endpointRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !e.ManagedBy(scope) {
+		return nil, webhook.ErrEndpointNotFound
+	}
+	return e, nil
+}
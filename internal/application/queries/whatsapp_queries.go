@@ -0,0 +1,99 @@
+package queries
+
+import (
+	"context"
+
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/whatsapp"
+	"online-shop/pkg/i18n"
+)
+
+type ListWhatsAppTemplatesQuery struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+type GetWhatsAppTemplateQuery struct {
+	Name     string `json:"name"`
+	Language string `json:"language"`
+}
+
+type ListWhatsAppMessagesQuery struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+type GetWhatsAppMessageQuery struct {
+	ID string `json:"id"`
+}
+
+type ListWhatsAppTemplatesQueryHandler struct {
+	templates whatsapp.TemplateRepository
+}
+
+func NewListWhatsAppTemplatesQueryHandler(templates whatsapp.TemplateRepository) *ListWhatsAppTemplatesQueryHandler {
+	return &ListWhatsAppTemplatesQueryHandler{templates: templates}
+}
+
+func (h *ListWhatsAppTemplatesQueryHandler) Handle(ctx context.Context, query ListWhatsAppTemplatesQuery) ([]*whatsapp.Template, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListWhatsAppTemplatesQueryHandler) handle(ctx context.Context, query ListWhatsAppTemplatesQuery) ([]*whatsapp.Template, error) {
+	if query.Limit <= 0 {
+		query.Limit = 50
+	}
+	return h.templates.List(ctx, query.Limit, query.Offset)
+}
+
+type GetWhatsAppTemplateQueryHandler struct {
+	templates whatsapp.TemplateRepository
+}
+
+func NewGetWhatsAppTemplateQueryHandler(templates whatsapp.TemplateRepository) *GetWhatsAppTemplateQueryHandler {
+	return &GetWhatsAppTemplateQueryHandler{templates: templates}
+}
+
+func (h *GetWhatsAppTemplateQueryHandler) Handle(ctx context.Context, query GetWhatsAppTemplateQuery) (*whatsapp.Template, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetWhatsAppTemplateQueryHandler) handle(ctx context.Context, query GetWhatsAppTemplateQuery) (*whatsapp.Template, error) {
+	return h.templates.Get(ctx, query.Name, i18n.Negotiate("", query.Language))
+}
+
+type ListWhatsAppMessagesQueryHandler struct {
+	messages whatsapp.MessageRepository
+}
+
+func NewListWhatsAppMessagesQueryHandler(messages whatsapp.MessageRepository) *ListWhatsAppMessagesQueryHandler {
+	return &ListWhatsAppMessagesQueryHandler{messages: messages}
+}
+
+// Handle lists sent messages, most recent first
+func (h *ListWhatsAppMessagesQueryHandler) Handle(ctx context.Context, query ListWhatsAppMessagesQuery) ([]*whatsapp.Message, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *ListWhatsAppMessagesQueryHandler) handle(ctx context.Context, query ListWhatsAppMessagesQuery) ([]*whatsapp.Message, error) {
+	if query.Limit <= 0 {
+		query.Limit = 50
+	}
+	return h.messages.List(ctx, query.Limit, query.Offset)
+}
+
+type GetWhatsAppMessageQueryHandler struct {
+	messages whatsapp.MessageRepository
+}
+
+func NewGetWhatsAppMessageQueryHandler(messages whatsapp.MessageRepository) *GetWhatsAppMessageQueryHandler {
+	return &GetWhatsAppMessageQueryHandler{messages: messages}
+}
+
+func (h *GetWhatsAppMessageQueryHandler) Handle(ctx context.Context, query GetWhatsAppMessageQuery) (*whatsapp.Message, error) {
+	return pipeline.Handle(ctx, query, h.handle)
+}
+
+func (h *GetWhatsAppMessageQueryHandler) handle(ctx context.Context, query GetWhatsAppMessageQuery) (*whatsapp.Message, error) {
+	return h.messages.Get(ctx, query.ID)
+}
@@ -0,0 +1,153 @@
+package analytics
+
+import (
+	"context"
+	"time"
+
+	"online-shop/pkg/id"
+)
+
+// Event mirrors the payload consumed by the analytics worker.
+type Event struct {
+	EventID    string                 `json:"event_id"`
+	UserID     string                 `json:"user_id,omitempty"`
+	SessionID  string                 `json:"session_id,omitempty"`
+	EventType  string                 `json:"event_type"`
+	EventName  string                 `json:"event_name"`
+	Properties map[string]interface{} `json:"properties"`
+	Timestamp  time.Time              `json:"timestamp"`
+	IPAddress  string                 `json:"ip_address,omitempty"`
+	UserAgent  string                 `json:"user_agent,omitempty"`
+	Referrer   string                 `json:"referrer,omitempty"`
+	PageURL    string                 `json:"page_url,omitempty"`
+	DeviceType string                 `json:"device_type,omitempty"`
+	Platform   string                 `json:"platform,omitempty"`
+	Country    string                 `json:"country,omitempty"`
+	City       string                 `json:"city,omitempty"`
+}
+
+const (
+	EventTypeProduct = "product"
+
+	EventProductViewed      = "product_viewed"
+	EventProductAddedToCart = "product_added_to_cart"
+	EventProductPurchased   = "product_purchased"
+
+	EventTypeExperiment = "experiment"
+
+	EventExperimentExposure   = "experiment_exposure"
+	EventExperimentConversion = "experiment_conversion"
+
+	EventTypeSecurity = "security"
+
+	EventCSPViolation = "csp_violation"
+)
+
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Writer persists processed events to the analytics store.
+type Writer interface {
+	Write(ctx context.Context, events []Event) error
+}
+
+// EventReader reads stored events back, oldest first.
+type EventReader interface {
+	ListByUser(ctx context.Context, userID string) ([]Event, error)
+	// ListAfter returns up to limit events matching filter that come after
+	// the event after, or from the start when it is nil. Events are ordered
+	// by time, then ID.
+	ListAfter(ctx context.Context, filter ReplayFilter, after *Event, limit int) ([]Event, error)
+}
+
+func NewProductEvent(name, productID, userID, sessionID string, quantity int) Event {
+	return Event{
+		EventID:   id.New(),
+		UserID:    userID,
+		SessionID: sessionID,
+		EventType: EventTypeProduct,
+		EventName: name,
+		Properties: map[string]interface{}{
+			"product_id": productID,
+			"quantity":   quantity,
+		},
+		Timestamp: time.Now(),
+	}
+}
+
+func (e Event) ProductID() string {
+	id, _ := e.Properties["product_id"].(string)
+	return id
+}
+
+// Quantity reads the quantity property, which arrives as a float64 once the
+// event has been through JSON.
+func (e Event) Quantity() int {
+	switch q := e.Properties["quantity"].(type) {
+	case int:
+		return q
+	case float64:
+		return int(q)
+	}
+	return 1
+}
+
+// NopPublisher drops events; used when no message broker is configured.
+type NopPublisher struct{}
+
+func (NopPublisher) Publish(ctx context.Context, event Event) error {
+	return nil
+}
+
+// NewPurchaseEvent records one order line; price, commission and order ID
+// let reports compute revenue, the marketplace's share of it and order
+// counts from the event stream alone, and the warehouse the line ships
+// from, when known, lets sales be told apart by warehouse.
+func NewPurchaseEvent(orderID, productID, warehouseID, userID, sessionID string, quantity int, price, commission float64) Event {
+	event := NewProductEvent(EventProductPurchased, productID, userID, sessionID, quantity)
+	event.Properties["order_id"] = orderID
+	event.Properties["price"] = price
+	event.Properties["commission"] = commission
+	if warehouseID != "" {
+		event.Properties["warehouse_id"] = warehouseID
+	}
+	return event
+}
+
+// NewExperimentEvent records a visitor seeing, or converting in, a variant.
+// Conversion events may carry a value such as an order total.
+func NewExperimentEvent(name, experimentKey, variant, userID, sessionID string, value float64) Event {
+	event := Event{
+		EventID:   id.New(),
+		UserID:    userID,
+		SessionID: sessionID,
+		EventType: EventTypeExperiment,
+		EventName: name,
+		Properties: map[string]interface{}{
+			"experiment_key": experimentKey,
+			"variant":        variant,
+		},
+		Timestamp: time.Now(),
+	}
+	if value != 0 {
+		event.Properties["value"] = value
+	}
+	return event
+}
+
+// NewCSPViolationEvent records a browser blocking, or reporting, something
+// the Content-Security-Policy does not allow on a page.
+func NewCSPViolationEvent(properties map[string]interface{}, pageURL, referrer, ipAddress, userAgent string) Event {
+	return Event{
+		EventID:    id.New(),
+		EventType:  EventTypeSecurity,
+		EventName:  EventCSPViolation,
+		Properties: properties,
+		Timestamp:  time.Now(),
+		IPAddress:  ipAddress,
+		UserAgent:  userAgent,
+		Referrer:   referrer,
+		PageURL:    pageURL,
+	}
+}
@@ -0,0 +1,66 @@
+package analytics
+
+import (
+	"errors"
+	"time"
+)
+
+// Projections a replay can rebuild from the stored events
+const (
+	// ProjectionSearch indexes the products the events refer to again
+	ProjectionSearch = "search"
+	// ProjectionTrending scores the events into the trending ranking again
+	ProjectionTrending = "trending"
+	// ProjectionCache invalidates the cached products and listings
+	ProjectionCache = "cache"
+)
+
+var Projections = []string{ProjectionSearch, ProjectionTrending, ProjectionCache}
+
+var (
+	ErrInvalidReplay     = errors.New("invalid replay range")
+	ErrUnknownProjection = errors.New("unknown projection")
+	// ErrTrendingNotRebuildable is returned for trending replays of one order
+	// or user: their scores cannot be taken out of the ranking first, so
+	// replaying them would count them twice.
+	ErrTrendingNotRebuildable = errors.New("trending can only be replayed for a time range or one product")
+)
+
+// trendingWeights scores product events for the trending ranking
+var trendingWeights = map[string]float64{
+	EventProductViewed:      1,
+	EventProductAddedToCart: 3,
+	EventProductPurchased:   5,
+}
+
+// TrendingScore is what the event adds to its product's trending score; it
+// reports false for events that do not count towards trending.
+func (e Event) TrendingScore() (float64, bool) {
+	weight, ok := trendingWeights[e.EventName]
+	if !ok || e.ProductID() == "" {
+		return 0, false
+	}
+	if quantity := e.Quantity(); quantity > 1 {
+		return weight * float64(quantity), true
+	}
+	return weight, true
+}
+
+// ReplayFilter selects the stored events recorded in [From, To), narrowed
+// to one event type and to one product, order or user when those are set.
+type ReplayFilter struct {
+	From      time.Time
+	To        time.Time
+	EventType string
+	ProductID string
+	OrderID   string
+	UserID    string
+}
+
+// Validate rejects replays without a range or with an inverted one
+func (f ReplayFilter) Validate() error {
+	if f.From.IsZero() || f.To.IsZero() || !f.From.Before(f.To) {
+		return ErrInvalidReplay
+	}
+	return nil
+}
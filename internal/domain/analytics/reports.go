@@ -0,0 +1,208 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+)
+
+var ErrInvalidRange = errors.New("invalid report range")
+
+// maxReportSpan keeps ad-hoc report queries from scanning the whole store.
+const maxReportSpan = 366 * 24 * time.Hour
+
+// ReportRange selects the [From, To) window of a report and the bucket size
+// used for time series.
+type ReportRange struct {
+	From     time.Time
+	To       time.Time
+	Interval string
+}
+
+// Normalize fills in the defaults (last 30 days, daily buckets) and rejects
+// ranges that are inverted, too long or use an unknown interval.
+func (r *ReportRange) Normalize() error {
+	if r.To.IsZero() {
+		r.To = time.Now()
+	}
+	if r.From.IsZero() {
+		r.From = r.To.AddDate(0, 0, -30)
+	}
+	if r.Interval == "" {
+		r.Interval = IntervalDay
+	}
+
+	switch r.Interval {
+	case IntervalDay, IntervalWeek, IntervalMonth:
+	default:
+		return ErrInvalidRange
+	}
+	if !r.From.Before(r.To) || r.To.Sub(r.From) > maxReportSpan {
+		return ErrInvalidRange
+	}
+	return nil
+}
+
+// RevenuePoint is the sales of one period. Commission is the marketplace's
+// share of Revenue and MerchantPayout the merchants'.
+type RevenuePoint struct {
+	Period            time.Time `json:"period"`
+	Orders            int64     `json:"orders"`
+	Items             int64     `json:"items"`
+	Revenue           float64   `json:"revenue"`
+	Commission        float64   `json:"commission"`
+	MerchantPayout    float64   `json:"merchant_payout"`
+	AverageOrderValue float64   `json:"average_order_value"`
+}
+
+type FunnelStep struct {
+	Step     string  `json:"step"`
+	Visitors int64   `json:"visitors"`
+	Rate     float64 `json:"rate"` // share of the previous step's visitors
+}
+
+type Funnel struct {
+	Steps      []FunnelStep `json:"steps"`
+	Conversion float64      `json:"conversion"` // last step over first step
+}
+
+type ProductStat struct {
+	ProductID      string  `json:"product_id"`
+	Views          int64   `json:"views"`
+	AddedToCart    int64   `json:"added_to_cart"`
+	UnitsSold      int64   `json:"units_sold"`
+	Revenue        float64 `json:"revenue"`
+	ConversionRate float64 `json:"conversion_rate"`
+}
+
+type UserActivityPoint struct {
+	Period      time.Time `json:"period"`
+	ActiveUsers int64     `json:"active_users"`
+	NewUsers    int64     `json:"new_users"`
+}
+
+// CohortCell is the number of users first seen in Cohort that were active
+// again in Period.
+type CohortCell struct {
+	Cohort time.Time
+	Period time.Time
+	Users  int64
+}
+
+type Cohort struct {
+	Cohort    time.Time `json:"cohort"`
+	Size      int64     `json:"size"`
+	Retention []float64 `json:"retention"`
+}
+
+// ProductUnits are the units of a product sold from a warehouse; WarehouseID
+// is empty for sales recorded without one.
+type ProductUnits struct {
+	ProductID   string `json:"product_id"`
+	WarehouseID string `json:"warehouse_id"`
+	Units       int64  `json:"units"`
+}
+
+// ReportRepository runs aggregate queries over the stored event stream.
+type ReportRepository interface {
+	Revenue(ctx context.Context, r ReportRange) ([]RevenuePoint, error)
+	FunnelCounts(ctx context.Context, r ReportRange, steps []string) (map[string]int64, error)
+	TopProducts(ctx context.Context, r ReportRange, sortBy string, limit int) ([]ProductStat, error)
+	UserActivity(ctx context.Context, r ReportRange) ([]UserActivityPoint, error)
+	CohortCells(ctx context.Context, r ReportRange) ([]CohortCell, error)
+	// UnitsSold sums the units purchased in the range per product and
+	// warehouse
+	UnitsSold(ctx context.Context, r ReportRange) ([]ProductUnits, error)
+}
+
+// FunnelSteps is the purchase funnel in order.
+var FunnelSteps = []string{EventProductViewed, EventProductAddedToCart, EventProductPurchased}
+
+// BuildFunnel turns distinct-visitor counts per step into step-to-step rates.
+func BuildFunnel(steps []string, counts map[string]int64) *Funnel {
+	funnel := &Funnel{Steps: make([]FunnelStep, 0, len(steps))}
+	for i, step := range steps {
+		s := FunnelStep{Step: step, Visitors: counts[step]}
+		if i == 0 {
+			if s.Visitors > 0 {
+				s.Rate = 1
+			}
+		} else {
+			s.Rate = ratio(s.Visitors, funnel.Steps[i-1].Visitors)
+		}
+		funnel.Steps = append(funnel.Steps, s)
+	}
+	if len(funnel.Steps) > 0 {
+		funnel.Conversion = ratio(funnel.Steps[len(funnel.Steps)-1].Visitors, funnel.Steps[0].Visitors)
+	}
+	return funnel
+}
+
+// BuildCohorts arranges cohort cells into retention rows. Retention[i] is the
+// share of the cohort active i intervals after it was first seen; the first
+// entry is always 1 for non-empty cohorts.
+func BuildCohorts(cells []CohortCell, interval string) []Cohort {
+	index := make(map[time.Time]int)
+	var cohorts []Cohort
+	counts := make(map[time.Time]map[int]int64)
+
+	for _, cell := range cells {
+		if _, ok := index[cell.Cohort]; !ok {
+			index[cell.Cohort] = len(cohorts)
+			cohorts = append(cohorts, Cohort{Cohort: cell.Cohort})
+			counts[cell.Cohort] = make(map[int]int64)
+		}
+		offset := periodsBetween(cell.Cohort, cell.Period, interval)
+		if offset < 0 {
+			continue
+		}
+		counts[cell.Cohort][offset] += cell.Users
+	}
+
+	for i := range cohorts {
+		c := &cohorts[i]
+		byOffset := counts[c.Cohort]
+		c.Size = byOffset[0]
+
+		last := 0
+		for offset := range byOffset {
+			if offset > last {
+				last = offset
+			}
+		}
+		c.Retention = make([]float64, last+1)
+		for offset := 0; offset <= last; offset++ {
+			c.Retention[offset] = ratio(byOffset[offset], c.Size)
+		}
+	}
+
+	sort.Slice(cohorts, func(i, j int) bool {
+		return cohorts[i].Cohort.Before(cohorts[j].Cohort)
+	})
+	return cohorts
+}
+
+func periodsBetween(from, to time.Time, interval string) int {
+	switch interval {
+	case IntervalMonth:
+		return (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+	case IntervalWeek:
+		return int(to.Sub(from).Hours() / (24 * 7))
+	default:
+		return int(to.Sub(from).Hours() / 24)
+	}
+}
+
+func ratio(part, whole int64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) / float64(whole)
+}
@@ -0,0 +1,169 @@
+package audit
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"online-shop/pkg/id"
+)
+
+const (
+	// SourceHTTP entries record who made a request and its outcome
+	SourceHTTP = "http"
+	// SourceRepository entries record the field-level change of a row,
+	// whichever code path wrote it
+	SourceRepository = "repository"
+	// SourceCommand entries record operations that change no database row,
+	// such as cache flushes
+	SourceCommand = "command"
+
+	SystemActor = "system"
+	redacted    = "[redacted]"
+)
+
+// RedactedFields never have their values written to the audit log, only the
+// fact that they changed.
+var RedactedFields = map[string]bool{
+	"password": true,
+
+	// Encrypted columns
+	"phone":                true,
+	"external_id":          true,
+	"shipping_street":      true,
+	"shipping_city":        true,
+	"shipping_state":       true,
+	"shipping_postal_code": true,
+}
+
+// ignoredFields change on every write and carry no information
+var ignoredFields = map[string]bool{
+	"updated_at": true,
+}
+
+type Change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+type Entry struct {
+	ID        string `json:"id" gorm:"primaryKey"`
+	Source    string `json:"source" gorm:"index"`
+	ActorID   string `json:"actor_id" gorm:"index"`
+	ActorRole string `json:"actor_role"`
+	// ImpersonatorID is the support admin who acted as the actor
+	ImpersonatorID string            `json:"impersonator_id,omitempty" gorm:"index"`
+	Action         string            `json:"action" gorm:"index"`
+	ResourceType   string            `json:"resource_type" gorm:"index:idx_audit_resource"`
+	ResourceID     string            `json:"resource_id" gorm:"index:idx_audit_resource"`
+	Changes        map[string]Change `json:"changes,omitempty" gorm:"type:jsonb;serializer:json"`
+	RequestID      string            `json:"request_id,omitempty"`
+	Method         string            `json:"method,omitempty"`
+	Path           string            `json:"path,omitempty"`
+	StatusCode     int               `json:"status_code,omitempty"`
+	IPAddress      string            `json:"ip_address,omitempty"`
+	UserAgent      string            `json:"user_agent,omitempty"`
+	CreatedAt      time.Time         `json:"created_at" gorm:"index"`
+}
+
+func (Entry) TableName() string {
+	return "audit_logs"
+}
+
+type Filter struct {
+	ActorID        string
+	ImpersonatorID string
+	Source         string
+	Action         string
+	ResourceType   string
+	ResourceID     string
+	From           time.Time
+	To             time.Time
+}
+
+type Repository interface {
+	Create(ctx context.Context, entry *Entry) error
+	List(ctx context.Context, filter Filter, limit, offset int) ([]*Entry, int64, error)
+}
+
+func NewEntry(source, actorID, action, resourceType, resourceID string) *Entry {
+	if actorID == "" {
+		actorID = SystemActor
+	}
+
+	return &Entry{
+		ID:           id.New(),
+		Source:       source,
+		ActorID:      actorID,
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		CreatedAt:    time.Now(),
+	}
+}
+
+// Diff compares two column maps of the same row and returns the changed
+// columns. Redacted columns are reported without their values.
+func Diff(before, after map[string]interface{}) map[string]Change {
+	keys := make(map[string]bool, len(before)+len(after))
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+
+	changes := make(map[string]Change)
+	for k := range keys {
+		if ignoredFields[k] || reflect.DeepEqual(normalize(before[k]), normalize(after[k])) {
+			continue
+		}
+		if RedactedFields[k] {
+			changes[k] = Change{Before: redacted, After: redacted}
+			continue
+		}
+		changes[k] = Change{Before: before[k], After: after[k]}
+	}
+	return changes
+}
+
+// normalize makes values scanned from different queries comparable:
+// timestamps are compared as instants and byte slices as strings.
+func normalize(v interface{}) interface{} {
+	switch value := v.(type) {
+	case time.Time:
+		return value.UTC().Round(time.Microsecond)
+	case *time.Time:
+		if value == nil {
+			return nil
+		}
+		return value.UTC().Round(time.Microsecond)
+	case []byte:
+		return string(value)
+	default:
+		return v
+	}
+}
+
+type actorKey struct{}
+
+// Actor identifies who triggered a change, and the admin acting as them
+// when they are impersonated.
+type Actor struct {
+	ID             string
+	Role           string
+	ImpersonatorID string
+	RequestID      string
+}
+
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func ActorFromContext(ctx context.Context) (Actor, bool) {
+	if ctx == nil {
+		return Actor{}, false
+	}
+	actor, ok := ctx.Value(actorKey{}).(Actor)
+	return actor, ok
+}
@@ -0,0 +1,148 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"online-shop/pkg/id"
+)
+
+// JobName is the scheduler task that runs pending backups
+const JobName = "database-backup"
+
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusCompleted Status = "completed"
+	StatusFailed    Status = "failed"
+	// StatusExpired backups were removed from storage by the retention policy
+	StatusExpired Status = "expired"
+)
+
+type Trigger string
+
+const (
+	TriggerManual    Trigger = "manual"
+	TriggerScheduled Trigger = "scheduled"
+)
+
+type Backup struct {
+	ID          string     `json:"id" gorm:"primaryKey"`
+	Trigger     Trigger    `json:"trigger"`
+	RequestedBy string     `json:"requested_by,omitempty"`
+	Status      Status     `json:"status" gorm:"index"`
+	ObjectKey   string     `json:"object_key,omitempty"`
+	SizeBytes   int64      `json:"size_bytes"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"index"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	StartedAt   *time.Time `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+type Repository interface {
+	Create(ctx context.Context, backup *Backup) error
+	GetByID(ctx context.Context, id string) (*Backup, error)
+	Update(ctx context.Context, backup *Backup) error
+	List(ctx context.Context, limit, offset int) ([]*Backup, error)
+	// ListByStatus returns backups in the given state, oldest first
+	ListByStatus(ctx context.Context, status Status) ([]*Backup, error)
+	// Latest returns the newest backup in the given state, or nil
+	Latest(ctx context.Context, status Status) (*Backup, error)
+}
+
+// Dumper produces a restorable copy of the database.
+type Dumper interface {
+	Dump(ctx context.Context) ([]byte, error)
+	Extension() string
+}
+
+// Storage keeps backup files off the database host.
+type Storage interface {
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	Delete(ctx context.Context, key string) error
+}
+
+// Scheduler wakes a registered job outside its interval.
+type Scheduler interface {
+	Trigger(name string) error
+}
+
+func NewBackup(trigger Trigger, requestedBy string) *Backup {
+	now := time.Now()
+	return &Backup{
+		ID:          id.New(),
+		Trigger:     trigger,
+		RequestedBy: requestedBy,
+		Status:      StatusPending,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// ObjectKey names the backup file after the database and the run time, so
+// listings in the bucket sort chronologically.
+func ObjectKey(database string, at time.Time, extension string) string {
+	return fmt.Sprintf("backups/%s-%s.%s", database, at.UTC().Format("20060102T150405Z"), extension)
+}
+
+func (b *Backup) IsActive() bool {
+	return b.Status == StatusPending || b.Status == StatusRunning
+}
+
+func (b *Backup) Start() {
+	now := time.Now()
+	b.Status = StatusRunning
+	b.Error = ""
+	b.StartedAt = &now
+	b.UpdatedAt = now
+}
+
+func (b *Backup) Complete(objectKey string, size int64) {
+	now := time.Now()
+	b.Status = StatusCompleted
+	b.ObjectKey = objectKey
+	b.SizeBytes = size
+	b.CompletedAt = &now
+	b.UpdatedAt = now
+}
+
+func (b *Backup) Fail(err error) {
+	now := time.Now()
+	b.Status = StatusFailed
+	b.Error = err.Error()
+	b.CompletedAt = &now
+	b.UpdatedAt = now
+}
+
+func (b *Backup) Expire() {
+	b.Status = StatusExpired
+	b.UpdatedAt = time.Now()
+}
+
+// Expired applies the retention policy to completed backups: those older than
+// the retention period are expired, but the newest keep backups always stay
+// so a long outage of the backup job cannot delete every copy.
+func Expired(completed []*Backup, now time.Time, retention time.Duration, keep int) []*Backup {
+	sorted := make([]*Backup, len(completed))
+	copy(sorted, completed)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+	})
+
+	var expired []*Backup
+	cutoff := now.Add(-retention)
+	for i, b := range sorted {
+		if i < keep {
+			continue
+		}
+		if b.CreatedAt.Before(cutoff) {
+			expired = append(expired, b)
+		}
+	}
+	return expired
+}
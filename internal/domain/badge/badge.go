@@ -0,0 +1,289 @@
+// Package badge holds the labels products are shown with, such as New or
+// Sale. The built-in badges are computed by rules the badge job evaluates
+// against the catalog; any badge, built-in or not, can also be set on a
+// product by hand. The job stores what it works out on the products, so
+// that they are listed and indexed with their badges.
+package badge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"online-shop/internal/domain/product"
+)
+
+var (
+	ErrBadgeNotFound = errors.New("badge not found")
+	// ErrInvalidBadge is a badge with a bad code, label or rule, or one
+	// that would replace a built-in badge
+	ErrInvalidBadge = errors.New("invalid badge")
+)
+
+// JobName is the scheduler job evaluating the badge rules
+const JobName = "badges"
+
+// The built-in badges, computed by their rules
+const (
+	// New is shown on products created within the last Days days
+	New = "new"
+	// BestSeller is shown on the Limit products selling the most units in
+	// the last Days days
+	BestSeller = "best_seller"
+	// LowStock is shown on products in stock with Threshold units or fewer
+	LowStock = "low_stock"
+	// Sale is shown on products in a live flash sale
+	Sale = "sale"
+	// FreeShipping is shown on products priced at MinPrice or more
+	FreeShipping = "free_shipping"
+)
+
+var codePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_]*$`)
+
+// Definition is a badge and, for a built-in one, the rule computing it.
+// Badges without a rule are only shown where they are set by hand. A
+// disabled badge is not shown at all.
+type Definition struct {
+	Code     string `json:"code" gorm:"primaryKey"`
+	Label    string `json:"label"`
+	Enabled  bool   `json:"enabled"`
+	Computed bool   `json:"computed"`
+	// Priority orders the badges of a product, lowest first
+	Priority  int       `json:"priority"`
+	Days      int       `json:"days,omitempty"`
+	Limit     int       `json:"limit,omitempty"`
+	Threshold int       `json:"threshold,omitempty"`
+	MinPrice  float64   `json:"min_price,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Definition) TableName() string {
+	return "badge_definitions"
+}
+
+// Assignment is a badge set on a product by hand, until ExpiresAt when it
+// is given.
+type Assignment struct {
+	ProductID string     `json:"product_id" gorm:"primaryKey"`
+	Code      string     `json:"code" gorm:"primaryKey"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (Assignment) TableName() string {
+	return "product_badges"
+}
+
+// Live reports whether the assignment holds at the given time.
+func (a *Assignment) Live(at time.Time) bool {
+	return a.ExpiresAt == nil || at.Before(*a.ExpiresAt)
+}
+
+type Repository interface {
+	// ListDefinitions returns the stored definitions; see Definitions for
+	// the built-in ones that have not been edited
+	ListDefinitions(ctx context.Context) ([]*Definition, error)
+	// SaveDefinition creates or replaces a definition
+	SaveDefinition(ctx context.Context, d *Definition) error
+	// DeleteDefinition deletes a definition along with its assignments
+	DeleteDefinition(ctx context.Context, code string) error
+	// Assign creates or replaces an assignment
+	Assign(ctx context.Context, a *Assignment) error
+	Unassign(ctx context.Context, productID, code string) error
+	// Assignments returns the assignments of the given products live at
+	// the given time
+	Assignments(ctx context.Context, productIDs []string, at time.Time) ([]*Assignment, error)
+	// BestSellers returns the IDs of the limit products selling the most
+	// units in orders placed since the given time that were not cancelled
+	BestSellers(ctx context.Context, since time.Time, limit int) ([]string, error)
+	// SetProductBadges stores the badges of a product, leaving the rest of
+	// it as it is
+	SetProductBadges(ctx context.Context, productID string, badges []product.Badge) error
+}
+
+// Scheduler wakes a registered job outside its interval.
+type Scheduler interface {
+	Trigger(name string) error
+}
+
+// Defaults are the built-in badges as they are until an admin edits them.
+// Free shipping needs a price set before it is enabled.
+func Defaults() []*Definition {
+	return []*Definition{
+		{Code: Sale, Label: "Sale", Enabled: true, Computed: true, Priority: 10},
+		{Code: New, Label: "New", Enabled: true, Computed: true, Priority: 20, Days: 30},
+		{Code: BestSeller, Label: "Best Seller", Enabled: true, Computed: true, Priority: 30, Days: 30, Limit: 20},
+		{Code: FreeShipping, Label: "Free Shipping", Computed: true, Priority: 40},
+		{Code: LowStock, Label: "Low Stock", Enabled: true, Computed: true, Priority: 50, Threshold: 5},
+	}
+}
+
+// Definitions merges the stored definitions over the defaults and orders
+// them by priority.
+func Definitions(stored []*Definition) []*Definition {
+	byCode := make(map[string]*Definition)
+	var definitions []*Definition
+	for _, d := range Defaults() {
+		byCode[d.Code] = d
+		definitions = append(definitions, d)
+	}
+	for _, d := range stored {
+		if builtIn, ok := byCode[d.Code]; ok {
+			*builtIn = *d
+			builtIn.Computed = true
+			continue
+		}
+		d.Computed = false
+		definitions = append(definitions, d)
+	}
+
+	sort.SliceStable(definitions, func(i, j int) bool {
+		if definitions[i].Priority != definitions[j].Priority {
+			return definitions[i].Priority < definitions[j].Priority
+		}
+		return definitions[i].Code < definitions[j].Code
+	})
+	return definitions
+}
+
+// Find returns the definition with the given code.
+func Find(definitions []*Definition, code string) (*Definition, error) {
+	for _, d := range definitions {
+		if d.Code == code {
+			return d, nil
+		}
+	}
+	return nil, ErrBadgeNotFound
+}
+
+// IsBuiltIn reports whether the code is one of the computed badges.
+func IsBuiltIn(code string) bool {
+	switch code {
+	case New, BestSeller, LowStock, Sale, FreeShipping:
+		return true
+	}
+	return false
+}
+
+func NewDefinition(code, label string, priority int) (*Definition, error) {
+	d := &Definition{
+		Code:     strings.TrimSpace(code),
+		Label:    strings.TrimSpace(label),
+		Enabled:  true,
+		Priority: priority,
+	}
+	if IsBuiltIn(d.Code) {
+		return nil, fmt.Errorf("%w: %s is a built-in badge", ErrInvalidBadge, d.Code)
+	}
+	if err := d.Check(); err != nil {
+		return nil, err
+	}
+	d.CreatedAt = time.Now()
+	d.UpdatedAt = d.CreatedAt
+	return d, nil
+}
+
+// Check validates a definition after it is created or edited.
+func (d *Definition) Check() error {
+	if !codePattern.MatchString(d.Code) {
+		return fmt.Errorf("%w: code must be lower case letters, digits and underscores", ErrInvalidBadge)
+	}
+	if d.Label == "" {
+		return fmt.Errorf("%w: label is required", ErrInvalidBadge)
+	}
+	if d.Days < 0 || d.Limit < 0 || d.Threshold < 0 || d.MinPrice < 0 {
+		return fmt.Errorf("%w: rule settings cannot be negative", ErrInvalidBadge)
+	}
+	if !d.Enabled {
+		return nil
+	}
+	switch d.Code {
+	case New:
+		if d.Days < 1 {
+			return fmt.Errorf("%w: new needs a number of days", ErrInvalidBadge)
+		}
+	case BestSeller:
+		if d.Days < 1 || d.Limit < 1 {
+			return fmt.Errorf("%w: best seller needs a number of days and a limit", ErrInvalidBadge)
+		}
+	case LowStock:
+		if d.Threshold < 1 {
+			return fmt.Errorf("%w: low stock needs a threshold", ErrInvalidBadge)
+		}
+	case FreeShipping:
+		if d.MinPrice <= 0 {
+			return fmt.Errorf("%w: free shipping needs a minimum price", ErrInvalidBadge)
+		}
+	}
+	return nil
+}
+
+// Facts are what the rules know of the catalog beyond a product itself,
+// keyed by product ID.
+type Facts struct {
+	BestSellers map[string]bool
+	OnSale      map[string]bool
+}
+
+// Applies reports whether the definition's rule gives the product its badge
+// at the given time. Badges without a rule apply to no product.
+func (d *Definition) Applies(p *product.Product, facts Facts, at time.Time) bool {
+	if !d.Computed || !d.Enabled {
+		return false
+	}
+	switch d.Code {
+	case New:
+		return at.Sub(p.CreatedAt) < time.Duration(d.Days)*24*time.Hour
+	case BestSeller:
+		return facts.BestSellers[p.ID]
+	case LowStock:
+		return p.Stock > 0 && p.Stock <= d.Threshold
+	case Sale:
+		return facts.OnSale[p.ID]
+	case FreeShipping:
+		return p.Price >= d.MinPrice
+	}
+	return false
+}
+
+// Evaluate works out the badges a product is shown with at the given time:
+// those its rules give it and those set on it by hand, in the order of the
+// definitions. Disabled badges are left out even when set by hand.
+func Evaluate(definitions []*Definition, p *product.Product, assignments []*Assignment, facts Facts, at time.Time) []product.Badge {
+	manual := make(map[string]bool, len(assignments))
+	for _, a := range assignments {
+		if a.ProductID == p.ID && a.Live(at) {
+			manual[a.Code] = true
+		}
+	}
+
+	badges := []product.Badge{}
+	for _, d := range definitions {
+		if !d.Enabled {
+			continue
+		}
+		if manual[d.Code] || d.Applies(p, facts, at) {
+			badges = append(badges, product.Badge{Code: d.Code, Label: d.Label})
+		}
+	}
+	return badges
+}
+
+// Same reports whether two lists hold the same badges in the same order.
+func Same(a, b []product.Badge) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+package banner
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"online-shop/pkg/id"
+)
+
+type Banner struct {
+	ID        string     `json:"id" gorm:"primaryKey"`
+	Title     string     `json:"title"`
+	ImageURL  string     `json:"image_url"`
+	LinkURL   string     `json:"link_url"`
+	Position  int        `json:"position"`
+	Active    bool       `json:"active" gorm:"index"`
+	StartsAt  *time.Time `json:"starts_at,omitempty"`
+	EndsAt    *time.Time `json:"ends_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type Repository interface {
+	Create(ctx context.Context, banner *Banner) error
+	GetByID(ctx context.Context, id string) (*Banner, error)
+	Update(ctx context.Context, banner *Banner) error
+	Delete(ctx context.Context, id string) error
+	List(ctx context.Context, limit, offset int) ([]*Banner, error)
+	ListActive(ctx context.Context, at time.Time) ([]*Banner, error)
+}
+
+// Live reports whether the banner is shown at the given time.
+func (b *Banner) Live(at time.Time) bool {
+	if !b.Active || (b.StartsAt != nil && at.Before(*b.StartsAt)) {
+		return false
+	}
+	return b.EndsAt == nil || at.Before(*b.EndsAt)
+}
+
+func NewBanner(title, imageURL, linkURL string, position int, startsAt, endsAt *time.Time) (*Banner, error) {
+	if title == "" || imageURL == "" {
+		return nil, errors.New("banner title and image are required")
+	}
+	if startsAt != nil && endsAt != nil && !endsAt.After(*startsAt) {
+		return nil, errors.New("banner must end after it starts")
+	}
+
+	return &Banner{
+		ID:        id.New(),
+		Title:     title,
+		ImageURL:  imageURL,
+		LinkURL:   linkURL,
+		Position:  position,
+		Active:    true,
+		StartsAt:  startsAt,
+		EndsAt:    endsAt,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}, nil
+}
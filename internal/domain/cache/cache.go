@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"context"
+	"errors"
+)
+
+type Scope string
+
+const (
+	ScopeProducts   Scope = "products"
+	ScopeCategories Scope = "categories"
+	ScopeSearch     Scope = "search"
+	ScopeSessions   Scope = "sessions"
+	ScopeCMS        Scope = "cms"
+	ScopeAll        Scope = "all"
+)
+
+var ErrUnknownScope = errors.New("unknown cache scope")
+
+// scopePatterns lists the key patterns written by the cache service and the
+// gRPC product service for each scope. Idempotency records, trending counters
+// and other data that only lives in Redis are never part of a scope.
+var scopePatterns = map[Scope][]string{
+	ScopeProducts:   {"product:*", "products:*"},
+	ScopeCategories: {"categories:*"},
+	ScopeSearch:     {"search:*"},
+	ScopeSessions:   {"session:*", "user:*"},
+	ScopeCMS:        {"cms:*", "storefront:*"},
+}
+
+// Invalidator deletes cached keys matching a pattern and reports how many
+// were removed.
+type Invalidator interface {
+	DeletePattern(ctx context.Context, pattern string) (int64, error)
+}
+
+// Patterns returns the key patterns of a scope; "all" is every scope.
+func (s Scope) Patterns() ([]string, error) {
+	if s == ScopeAll {
+		var patterns []string
+		for _, scope := range []Scope{ScopeProducts, ScopeCategories, ScopeSearch, ScopeSessions, ScopeCMS} {
+			patterns = append(patterns, scopePatterns[scope]...)
+		}
+		return patterns, nil
+	}
+
+	patterns, ok := scopePatterns[s]
+	if !ok {
+		return nil, ErrUnknownScope
+	}
+	return patterns, nil
+}
+
+// Disruptive scopes log every user out or send a full cold-cache load to the
+// database, so they need an explicit confirmation in production.
+func (s Scope) Disruptive() bool {
+	return s == ScopeAll || s == ScopeSessions
+}
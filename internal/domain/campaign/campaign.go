@@ -0,0 +1,351 @@
+// Package campaign holds the marketing email campaigns admins compose. A
+// campaign sends an email template to a segment of the customers who agreed
+// to marketing emails, at the time it is scheduled for. The campaign job
+// queues it for the email worker in throttled batches, and the opens and
+// clicks of its emails are tracked through signed links back to the API.
+package campaign
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"online-shop/internal/domain/user"
+	"online-shop/pkg/i18n"
+	"online-shop/pkg/id"
+)
+
+var (
+	ErrNotFound = errors.New("campaign not found")
+	// ErrInvalidCampaign is a campaign missing content or with a bad segment
+	ErrInvalidCampaign = errors.New("invalid campaign")
+	// ErrWrongStatus refuses edits, scheduling or sending once the campaign's
+	// status has moved past them
+	ErrWrongStatus = errors.New("campaign status does not allow this")
+)
+
+// JobName is the scheduler task that sends the campaigns that are due
+const JobName = "campaigns"
+
+// oldestAge bounds the ages a segment can select
+const oldestAge = 130
+
+type Status string
+
+const (
+	// StatusDraft campaigns are not sent until scheduled
+	StatusDraft     Status = "draft"
+	StatusScheduled Status = "scheduled"
+	// StatusSending campaigns are being queued, a batch at a time
+	StatusSending  Status = "sending"
+	StatusSent     Status = "sent"
+	StatusCanceled Status = "canceled"
+)
+
+// Segment selects the customers a campaign is sent to; empty fields match
+// everyone. Only active customers who agreed to marketing emails are ever
+// in a segment. Ages and birthday months only match customers who gave
+// their birthday.
+type Segment struct {
+	Locales []string      `json:"locales,omitempty"`
+	Genders []user.Gender `json:"genders,omitempty"`
+	MinAge  int           `json:"min_age,omitempty"`
+	MaxAge  int           `json:"max_age,omitempty"`
+	// BirthdayMonth is 1 for January to 12 for December
+	BirthdayMonth    int        `json:"birthday_month,omitempty"`
+	RegisteredAfter  *time.Time `json:"registered_after,omitempty"`
+	RegisteredBefore *time.Time `json:"registered_before,omitempty"`
+}
+
+// Content is what admins compose of a campaign. Data is the template data
+// shared by every recipient; each also gets their FirstName and LastName.
+type Content struct {
+	Name     string                 `json:"name"`
+	Template string                 `json:"template"`
+	Subject  string                 `json:"subject,omitempty"`
+	Locale   string                 `json:"locale,omitempty"`
+	Data     map[string]interface{} `json:"data,omitempty"`
+	Segment  Segment                `json:"segment"`
+}
+
+type Campaign struct {
+	ID       string                 `json:"id" gorm:"primaryKey"`
+	Name     string                 `json:"name"`
+	Template string                 `json:"template"`
+	Subject  string                 `json:"subject,omitempty"`
+	Locale   string                 `json:"locale,omitempty" gorm:"size:16"`
+	Data     map[string]interface{} `json:"data,omitempty" gorm:"type:jsonb;serializer:json"`
+	Segment  Segment                `json:"segment" gorm:"type:jsonb;serializer:json"`
+	Status   Status                 `json:"status" gorm:"index"`
+	// SendAt is when a scheduled campaign starts sending
+	SendAt *time.Time `json:"send_at,omitempty" gorm:"index"`
+	// Cursor is the last user queued, so a send that was interrupted
+	// carries on after them
+	Cursor      string     `json:"-"`
+	Recipients  int        `json:"recipients"`
+	Batches     int        `json:"batches"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedBy   string     `json:"created_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (Campaign) TableName() string {
+	return "email_campaigns"
+}
+
+// Filter selects campaigns; an empty status matches every campaign.
+type Filter struct {
+	Status Status
+	Limit  int
+	Offset int
+}
+
+type Repository interface {
+	Create(ctx context.Context, c *Campaign) error
+	Get(ctx context.Context, id string) (*Campaign, error)
+	Update(ctx context.Context, c *Campaign) error
+	// Progress saves the status, cursor and counts of a campaign that is
+	// sending, unless it was canceled meanwhile, when ok is false
+	Progress(ctx context.Context, c *Campaign) (ok bool, err error)
+	// List returns the most recent first
+	List(ctx context.Context, filter Filter) ([]*Campaign, int64, error)
+	// ListDue returns the campaigns left sending and the scheduled ones
+	// whose send time is at or before at, the earliest first
+	ListDue(ctx context.Context, at time.Time, limit int) ([]*Campaign, error)
+	RecordEvent(ctx context.Context, e *Event) error
+	Stats(ctx context.Context, campaignID string) (*Stats, error)
+}
+
+// AudienceRepository finds the customers in a segment.
+type AudienceRepository interface {
+	// Audience returns up to limit members of the segment with IDs after
+	// afterID, in ID order, with their ages as of at
+	Audience(ctx context.Context, s Segment, at time.Time, afterID string, limit int) ([]*user.User, error)
+	CountAudience(ctx context.Context, s Segment, at time.Time) (int64, error)
+}
+
+// Validate checks the segment selects something sensible.
+func (s Segment) Validate() error {
+	for _, locale := range s.Locales {
+		if !i18n.Supported(locale) {
+			return fmt.Errorf("%w: locale %q is not supported", ErrInvalidCampaign, locale)
+		}
+	}
+	for _, gender := range s.Genders {
+		if gender == "" || !gender.Valid() {
+			return fmt.Errorf("%w: gender must be female, male or other", ErrInvalidCampaign)
+		}
+	}
+	if s.MinAge < 0 || s.MaxAge < 0 || s.MinAge > oldestAge || s.MaxAge > oldestAge {
+		return fmt.Errorf("%w: ages must be between 0 and %d", ErrInvalidCampaign, oldestAge)
+	}
+	if s.MaxAge > 0 && s.MinAge > s.MaxAge {
+		return fmt.Errorf("%w: min_age is above max_age", ErrInvalidCampaign)
+	}
+	if s.BirthdayMonth < 0 || s.BirthdayMonth > 12 {
+		return fmt.Errorf("%w: birthday_month must be between 1 and 12", ErrInvalidCampaign)
+	}
+	if s.RegisteredAfter != nil && s.RegisteredBefore != nil && !s.RegisteredAfter.Before(*s.RegisteredBefore) {
+		return fmt.Errorf("%w: registered_after must be before registered_before", ErrInvalidCampaign)
+	}
+	return nil
+}
+
+// Matches reports whether the user is in the segment as of at. The
+// audience repository selects the same users in its query.
+func (s Segment) Matches(u *user.User, at time.Time) bool {
+	if u.Status != user.StatusActive || u.Role != user.RoleCustomer || !u.MarketingConsent.Granted(user.ChannelEmail) {
+		return false
+	}
+	if len(s.Locales) > 0 && !containsLocale(s.Locales, u.Locale) {
+		return false
+	}
+	if len(s.Genders) > 0 && !containsGender(s.Genders, u.Gender) {
+		return false
+	}
+	if s.MinAge > 0 || s.MaxAge > 0 || s.BirthdayMonth > 0 {
+		if u.Birthday == nil {
+			return false
+		}
+		if s.MinAge > 0 && u.Birthday.After(s.BornBy(at)) {
+			return false
+		}
+		if s.MaxAge > 0 && !u.Birthday.After(s.BornAfter(at)) {
+			return false
+		}
+		if s.BirthdayMonth > 0 && int(u.Birthday.Month()) != s.BirthdayMonth {
+			return false
+		}
+	}
+	if s.RegisteredAfter != nil && u.CreatedAt.Before(*s.RegisteredAfter) {
+		return false
+	}
+	if s.RegisteredBefore != nil && !u.CreatedAt.Before(*s.RegisteredBefore) {
+		return false
+	}
+	return true
+}
+
+// BornBy is the latest birthday of customers at least MinAge as of at
+func (s Segment) BornBy(at time.Time) time.Time {
+	return at.AddDate(-s.MinAge, 0, 0)
+}
+
+// BornAfter is the birthday customers older than MaxAge were born on or
+// before, as of at
+func (s Segment) BornAfter(at time.Time) time.Time {
+	return at.AddDate(-(s.MaxAge + 1), 0, 0)
+}
+
+// DefaultLocale reports whether the segment's locales take in customers
+// who have not picked a locale, who get the default one.
+func (s Segment) DefaultLocale() bool {
+	return containsLocale(s.Locales, "")
+}
+
+func containsLocale(locales []string, locale string) bool {
+	if locale == "" {
+		locale = i18n.DefaultLocale
+	}
+	for _, l := range locales {
+		if strings.EqualFold(i18n.Negotiate("", l), locale) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsGender(genders []user.Gender, gender user.Gender) bool {
+	for _, g := range genders {
+		if g == gender {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Content) validate() error {
+	if strings.TrimSpace(c.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidCampaign)
+	}
+	if strings.TrimSpace(c.Template) == "" {
+		return fmt.Errorf("%w: template is required", ErrInvalidCampaign)
+	}
+	if c.Locale != "" && !i18n.Supported(c.Locale) {
+		return fmt.Errorf("%w: locale %q is not supported", ErrInvalidCampaign, c.Locale)
+	}
+	return c.Segment.Validate()
+}
+
+// New creates a draft campaign.
+func New(content Content, createdBy string) (*Campaign, error) {
+	if err := content.validate(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	c := &Campaign{
+		ID:        id.New(),
+		Status:    StatusDraft,
+		CreatedBy: createdBy,
+		CreatedAt: now,
+	}
+	c.apply(content, now)
+	return c, nil
+}
+
+func (c *Campaign) apply(content Content, at time.Time) {
+	c.Name = strings.TrimSpace(content.Name)
+	c.Template = content.Template
+	c.Subject = content.Subject
+	c.Locale = content.Locale
+	if c.Locale != "" {
+		c.Locale = i18n.Negotiate("", c.Locale)
+	}
+	c.Data = content.Data
+	c.Segment = content.Segment
+	c.UpdatedAt = at
+}
+
+// Edit replaces the content of a campaign that has not started sending.
+func (c *Campaign) Edit(content Content, at time.Time) error {
+	if c.Status != StatusDraft && c.Status != StatusScheduled {
+		return fmt.Errorf("%w: only draft and scheduled campaigns can be edited, this one is %s", ErrWrongStatus, c.Status)
+	}
+	if err := content.validate(); err != nil {
+		return err
+	}
+	c.apply(content, at)
+	return nil
+}
+
+// Schedule sends the campaign at sendAt, or straight away when sendAt is
+// not after at. A scheduled campaign can be scheduled again.
+func (c *Campaign) Schedule(sendAt, at time.Time) error {
+	if c.Status != StatusDraft && c.Status != StatusScheduled {
+		return fmt.Errorf("%w: only draft and scheduled campaigns can be scheduled, this one is %s", ErrWrongStatus, c.Status)
+	}
+	if sendAt.Before(at) {
+		sendAt = at
+	}
+	c.Status = StatusScheduled
+	c.SendAt = &sendAt
+	c.UpdatedAt = at
+	return nil
+}
+
+// Cancel stops a campaign. One that is sending stops after the batch being
+// queued; what was queued is still sent.
+func (c *Campaign) Cancel(at time.Time) error {
+	if c.Status == StatusSent || c.Status == StatusCanceled {
+		return fmt.Errorf("%w: the campaign is already %s", ErrWrongStatus, c.Status)
+	}
+	c.Status = StatusCanceled
+	c.UpdatedAt = at
+	return nil
+}
+
+// Start marks a due campaign as sending. It does nothing to one that is
+// already sending.
+func (c *Campaign) Start(at time.Time) error {
+	switch c.Status {
+	case StatusSending:
+		return nil
+	case StatusScheduled:
+	default:
+		return fmt.Errorf("%w: only scheduled campaigns can start, this one is %s", ErrWrongStatus, c.Status)
+	}
+	c.Status = StatusSending
+	c.StartedAt = &at
+	c.UpdatedAt = at
+	return nil
+}
+
+// Queued records a batch queued up to and including the user lastID.
+func (c *Campaign) Queued(lastID string, recipients int, at time.Time) {
+	c.Cursor = lastID
+	c.Recipients += recipients
+	c.Batches++
+	c.UpdatedAt = at
+}
+
+// Complete marks the campaign sent once every member is queued.
+func (c *Campaign) Complete(at time.Time) {
+	c.Status = StatusSent
+	c.CompletedAt = &at
+	c.UpdatedAt = at
+}
+
+// SendLocale is the locale the campaign's emails are rendered in.
+func (c *Campaign) SendLocale() string {
+	return i18n.Negotiate("", c.Locale)
+}
+
+// RecipientData is the data only one recipient's email has.
+func RecipientData(u *user.User) map[string]interface{} {
+	return map[string]interface{}{"FirstName": u.FirstName, "LastName": u.LastName}
+}
@@ -0,0 +1,176 @@
+package campaign
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+
+	"online-shop/internal/domain/emaildelivery"
+	"online-shop/pkg/id"
+)
+
+// ErrInvalidLink is returned for tracking links whose signature does not
+// verify
+var ErrInvalidLink = errors.New("invalid tracking link")
+
+// Event types
+const (
+	EventOpen  = "open"
+	EventClick = "click"
+)
+
+// Paths of the tracking endpoints, under the API's base URL
+const (
+	OpenPath  = "/api/v1/campaigns/track/open/"
+	ClickPath = "/api/v1/campaigns/track/click/"
+)
+
+var hrefPattern = regexp.MustCompile(`href="(https?://[^"]+)"`)
+
+// Event is an open or click of a campaign email. Recipients are kept as a
+// hash of their address, so the events hold no personal data.
+type Event struct {
+	ID         string    `json:"id" gorm:"primaryKey"`
+	CampaignID string    `json:"campaign_id" gorm:"index"`
+	Type       string    `json:"type"`
+	Recipient  string    `json:"recipient" gorm:"size:32"`
+	URL        string    `json:"url,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (Event) TableName() string {
+	return "email_campaign_events"
+}
+
+// Stats counts a campaign's opens and clicks. Opens rely on the recipient's
+// mail client loading images, so they are a lower bound.
+type Stats struct {
+	CampaignID   string  `json:"campaign_id"`
+	Recipients   int     `json:"recipients"`
+	Opens        int64   `json:"opens"`
+	UniqueOpens  int64   `json:"unique_opens"`
+	Clicks       int64   `json:"clicks"`
+	UniqueClicks int64   `json:"unique_clicks"`
+	OpenRate     float64 `json:"open_rate"`
+	ClickRate    float64 `json:"click_rate"`
+	// Links counts the clicks of each link, the most clicked first
+	Links []LinkStats `json:"links"`
+}
+
+type LinkStats struct {
+	URL    string `json:"url"`
+	Clicks int64  `json:"clicks"`
+}
+
+// Rate fills in the rates from the unique counts.
+func (s *Stats) Rate(recipients int) {
+	s.Recipients = recipients
+	if recipients == 0 {
+		return
+	}
+	s.OpenRate = float64(s.UniqueOpens) / float64(recipients)
+	s.ClickRate = float64(s.UniqueClicks) / float64(recipients)
+}
+
+// Link is what a tracking link says: who opened or clicked what.
+type Link struct {
+	CampaignID string `json:"c"`
+	Recipient  string `json:"r"`
+	URL        string `json:"u,omitempty"`
+}
+
+// Event records the link being followed.
+func (l Link) Event(eventType string, at time.Time) *Event {
+	return &Event{
+		ID:         id.New(),
+		CampaignID: l.CampaignID,
+		Type:       eventType,
+		Recipient:  l.Recipient,
+		URL:        l.URL,
+		CreatedAt:  at,
+	}
+}
+
+// RecipientKey is the hash recipients are tracked by.
+func RecipientKey(email string) string {
+	sum := sha256.Sum256([]byte(emaildelivery.NormalizeAddress(email)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Tracker signs the tracking links of campaign emails and verifies them
+// when followed. The signature stops the click endpoint from redirecting
+// anywhere it is asked to.
+type Tracker struct {
+	baseURL string
+	secret  []byte
+}
+
+func NewTracker(baseURL, secret string) *Tracker {
+	return &Tracker{baseURL: strings.TrimSuffix(baseURL, "/"), secret: []byte(secret)}
+}
+
+// Instrument sends the links of a rendered email through the click
+// endpoint and adds an open beacon at the end of its body. Links to the
+// keep URLs, such as the unsubscribe link, are left as they are.
+func (t *Tracker) Instrument(body, campaignID, to string, keep ...string) string {
+	recipient := RecipientKey(to)
+	body = hrefPattern.ReplaceAllStringFunc(body, func(match string) string {
+		target := html.UnescapeString(hrefPattern.FindStringSubmatch(match)[1])
+		if strings.HasPrefix(target, t.baseURL+ClickPath) || contains(keep, target) {
+			return match
+		}
+		url := t.baseURL + ClickPath + t.sign(Link{CampaignID: campaignID, Recipient: recipient, URL: target})
+		return `href="` + html.EscapeString(url) + `"`
+	})
+
+	beacon := `<img src="` + html.EscapeString(t.baseURL+OpenPath+t.sign(Link{CampaignID: campaignID, Recipient: recipient})) + `" width="1" height="1" alt="" style="display:none">`
+	if i := strings.LastIndex(strings.ToLower(body), "</body>"); i >= 0 {
+		return body[:i] + beacon + body[i:]
+	}
+	return body + beacon
+}
+
+// Parse verifies a tracking link's token and returns what it links.
+func (t *Tracker) Parse(token string) (Link, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(t.signature(payload))) {
+		return Link{}, ErrInvalidLink
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return Link{}, ErrInvalidLink
+	}
+	var link Link
+	if err := json.Unmarshal(data, &link); err != nil || link.CampaignID == "" {
+		return Link{}, ErrInvalidLink
+	}
+	return link, nil
+}
+
+func contains(urls []string, url string) bool {
+	for _, u := range urls {
+		if u != "" && u == url {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Tracker) sign(link Link) string {
+	data, _ := json.Marshal(link)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + t.signature(payload)
+}
+
+func (t *Tracker) signature(payload string) string {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,168 @@
+// Package cart holds shopping carts. Signed-in customers have an account
+// cart; anonymous visitors get a cart under a random session token, which
+// expires when left alone and is merged into the account cart when the
+// visitor signs in.
+package cart
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrInvalidItem is a quantity outside what an order can take
+	ErrInvalidItem  = errors.New("invalid cart item")
+	ErrItemNotFound = errors.New("cart item not found")
+	ErrFull         = errors.New("cart is full")
+	ErrEmpty        = errors.New("cart is empty")
+)
+
+const (
+	// MaxItems and MaxQuantity match what an order can take
+	MaxItems    = 100
+	MaxQuantity = 1000
+)
+
+// tokenLength is the length of a session token: 32 random bytes, base64url
+// encoded without padding
+const tokenLength = 43
+
+// NewToken returns a new session token for an anonymous cart.
+func NewToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// ValidToken reports whether the token could have come from NewToken.
+func ValidToken(token string) bool {
+	if len(token) != tokenLength {
+		return false
+	}
+	_, err := base64.RawURLEncoding.DecodeString(token)
+	return err == nil
+}
+
+// Owner is whose cart it is: the user's when UserID is set, otherwise the
+// anonymous session's with the token.
+type Owner struct {
+	UserID string
+	Token  string
+}
+
+// Anonymous reports whether the cart belongs to a session rather than a
+// user.
+func (o Owner) Anonymous() bool {
+	return o.UserID == ""
+}
+
+type Item struct {
+	ProductID string    `json:"product_id"`
+	Quantity  int       `json:"quantity"`
+	AddedAt   time.Time `json:"added_at"`
+}
+
+type Cart struct {
+	Items     []*Item   `json:"items"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Repository interface {
+	// Get returns the owner's cart, empty when they have none
+	Get(ctx context.Context, owner Owner) (*Cart, error)
+	// Save stores the cart, and restarts its expiry
+	Save(ctx context.Context, owner Owner, c *Cart) error
+	Delete(ctx context.Context, owner Owner) error
+}
+
+func (c *Cart) item(productID string) *Item {
+	for _, item := range c.Items {
+		if item.ProductID == productID {
+			return item
+		}
+	}
+	return nil
+}
+
+// Quantity is how many of the product are in the cart.
+func (c *Cart) Quantity(productID string) int {
+	if item := c.item(productID); item != nil {
+		return item.Quantity
+	}
+	return 0
+}
+
+// Add puts quantity more of the product in the cart.
+func (c *Cart) Add(productID string, quantity int, at time.Time) error {
+	if quantity < 1 {
+		return fmt.Errorf("%w: quantity must be at least 1", ErrInvalidItem)
+	}
+	if item := c.item(productID); item != nil {
+		return c.Set(productID, item.Quantity+quantity, at)
+	}
+	if quantity > MaxQuantity {
+		return fmt.Errorf("%w: quantity must be at most %d", ErrInvalidItem, MaxQuantity)
+	}
+	if len(c.Items) >= MaxItems {
+		return ErrFull
+	}
+	c.Items = append(c.Items, &Item{ProductID: productID, Quantity: quantity, AddedAt: at})
+	c.UpdatedAt = at
+	return nil
+}
+
+// Set changes the quantity of a product already in the cart.
+func (c *Cart) Set(productID string, quantity int, at time.Time) error {
+	item := c.item(productID)
+	if item == nil {
+		return ErrItemNotFound
+	}
+	if quantity < 1 || quantity > MaxQuantity {
+		return fmt.Errorf("%w: quantity must be between 1 and %d", ErrInvalidItem, MaxQuantity)
+	}
+	item.Quantity = quantity
+	c.UpdatedAt = at
+	return nil
+}
+
+func (c *Cart) Remove(productID string, at time.Time) error {
+	for i, item := range c.Items {
+		if item.ProductID == productID {
+			c.Items = append(c.Items[:i], c.Items[i+1:]...)
+			c.UpdatedAt = at
+			return nil
+		}
+	}
+	return ErrItemNotFound
+}
+
+// Merge moves the items of an anonymous cart into this one. A product in
+// both keeps the larger of its two quantities rather than their sum, as
+// adding it again before signing in is usually the same purchase. Products
+// that no longer fit in the cart are left out; Merge returns how many.
+func (c *Cart) Merge(other *Cart, at time.Time) (dropped int) {
+	for _, theirs := range other.Items {
+		if mine := c.item(theirs.ProductID); mine != nil {
+			if theirs.Quantity > mine.Quantity {
+				mine.Quantity = theirs.Quantity
+			}
+			continue
+		}
+		if len(c.Items) >= MaxItems {
+			dropped++
+			continue
+		}
+		copied := *theirs
+		c.Items = append(c.Items, &copied)
+	}
+	if len(other.Items) > 0 {
+		c.UpdatedAt = at
+	}
+	return dropped
+}
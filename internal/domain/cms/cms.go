@@ -0,0 +1,285 @@
+// Package cms holds the storefront content admins edit without a deploy:
+// promo blocks placed in named slots of a page, static pages such as terms
+// or about, and the images both use. Content is scheduled with an optional
+// start and end, and is only served while it is live.
+package cms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"online-shop/pkg/id"
+)
+
+var (
+	ErrBlockNotFound = errors.New("content block not found")
+	ErrPageNotFound  = errors.New("page not found")
+	ErrAssetNotFound = errors.New("asset not found")
+	// ErrInvalidContent is a block or page missing its required fields or
+	// with a bad schedule; ErrInvalidAsset an upload that is empty, too
+	// large or not an accepted image type
+	ErrInvalidContent = errors.New("invalid content")
+	ErrInvalidAsset   = errors.New("invalid asset")
+	// ErrStorageUnavailable is returned by UnavailableStorage.
+	ErrStorageUnavailable = errors.New("asset storage unavailable")
+)
+
+// Window is when content is live. A missing start or end leaves that side
+// open.
+type Window struct {
+	StartsAt *time.Time `json:"starts_at,omitempty"`
+	EndsAt   *time.Time `json:"ends_at,omitempty"`
+}
+
+func (w Window) check() error {
+	if w.StartsAt != nil && w.EndsAt != nil && !w.EndsAt.After(*w.StartsAt) {
+		return fmt.Errorf("%w: must end after it starts", ErrInvalidContent)
+	}
+	return nil
+}
+
+// Contains reports whether at falls within the window.
+func (w Window) Contains(at time.Time) bool {
+	if w.StartsAt != nil && at.Before(*w.StartsAt) {
+		return false
+	}
+	return w.EndsAt == nil || at.Before(*w.EndsAt)
+}
+
+// Block is a promo block shown in a slot, such as home_top or checkout, in
+// position order.
+type Block struct {
+	ID       string `json:"id" gorm:"primaryKey"`
+	Slot     string `json:"slot" gorm:"index"`
+	Title    string `json:"title"`
+	Body     string `json:"body,omitempty"`
+	ImageURL string `json:"image_url,omitempty"`
+	LinkURL  string `json:"link_url,omitempty"`
+	Position int    `json:"position"`
+	Active   bool   `json:"active" gorm:"index"`
+	Window
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Block) TableName() string {
+	return "cms_blocks"
+}
+
+func NewBlock(slot, title, body, imageURL, linkURL string, position int, window Window) (*Block, error) {
+	b := &Block{
+		ID:       id.New(),
+		Slot:     slot,
+		Title:    title,
+		Body:     body,
+		ImageURL: imageURL,
+		LinkURL:  linkURL,
+		Position: position,
+		Active:   true,
+		Window:   window,
+	}
+	if err := b.Check(); err != nil {
+		return nil, err
+	}
+	b.CreatedAt = time.Now()
+	b.UpdatedAt = b.CreatedAt
+	return b, nil
+}
+
+// Check validates a block after it is created or edited.
+func (b *Block) Check() error {
+	if b.Slot == "" || b.Title == "" {
+		return fmt.Errorf("%w: slot and title are required", ErrInvalidContent)
+	}
+	if b.Body == "" && b.ImageURL == "" {
+		return fmt.Errorf("%w: a block needs a body or an image", ErrInvalidContent)
+	}
+	return b.Window.check()
+}
+
+// Live reports whether the block is shown at the given time.
+func (b *Block) Live(at time.Time) bool {
+	return b.Active && b.Contains(at)
+}
+
+// Page is a static page served by its slug.
+type Page struct {
+	ID              string `json:"id" gorm:"primaryKey"`
+	Slug            string `json:"slug" gorm:"uniqueIndex"`
+	Title           string `json:"title"`
+	Body            string `json:"body"`
+	MetaDescription string `json:"meta_description,omitempty"`
+	ImageURL        string `json:"image_url,omitempty"`
+	Published       bool   `json:"published" gorm:"index"`
+	Window
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Page) TableName() string {
+	return "cms_pages"
+}
+
+// NewPage starts as a draft until it is published.
+func NewPage(slug, title, body, metaDescription, imageURL string, window Window) (*Page, error) {
+	p := &Page{
+		ID:              id.New(),
+		Slug:            slug,
+		Title:           title,
+		Body:            body,
+		MetaDescription: metaDescription,
+		ImageURL:        imageURL,
+		Window:          window,
+	}
+	if err := p.Check(); err != nil {
+		return nil, err
+	}
+	p.CreatedAt = time.Now()
+	p.UpdatedAt = p.CreatedAt
+	return p, nil
+}
+
+func (p *Page) Check() error {
+	if p.Slug == "" || p.Title == "" || p.Body == "" {
+		return fmt.Errorf("%w: slug, title and body are required", ErrInvalidContent)
+	}
+	return p.Window.check()
+}
+
+func (p *Page) Live(at time.Time) bool {
+	return p.Published && p.Contains(at)
+}
+
+type BlockRepository interface {
+	Create(ctx context.Context, b *Block) error
+	GetByID(ctx context.Context, id string) (*Block, error)
+	Update(ctx context.Context, b *Block) error
+	Delete(ctx context.Context, id string) error
+	// List returns every block, or those of one slot, in position order
+	List(ctx context.Context, slot string, limit, offset int) ([]*Block, error)
+	// ListActive returns the active blocks of a slot whose window has not
+	// ended, including those that have yet to start
+	ListActive(ctx context.Context, slot string, at time.Time) ([]*Block, error)
+}
+
+type PageRepository interface {
+	Create(ctx context.Context, p *Page) error
+	GetByID(ctx context.Context, id string) (*Page, error)
+	GetBySlug(ctx context.Context, slug string) (*Page, error)
+	Update(ctx context.Context, p *Page) error
+	Delete(ctx context.Context, id string) error
+	List(ctx context.Context, limit, offset int) ([]*Page, error)
+}
+
+// Image types accepted as assets. SVG is left out as it can carry scripts.
+var imageExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// Asset is an uploaded image. The file is kept in object storage under Key;
+// content links to it through the API, which redirects to a signed link.
+type Asset struct {
+	ID          string    `json:"id" gorm:"primaryKey"`
+	Key         string    `json:"-"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int       `json:"size"`
+	UploadedBy  string    `json:"uploaded_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at" gorm:"index"`
+	// URL is where content links to the image; it is set by the API
+	URL string `json:"url" gorm:"-"`
+}
+
+func (Asset) TableName() string {
+	return "cms_assets"
+}
+
+// NewAsset checks an upload. The type is detected from the file itself
+// rather than trusted from the client, and must be one of allowedTypes when
+// they are given.
+func NewAsset(filename string, data []byte, maxSize int, allowedTypes []string, uploadedBy string) (*Asset, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: the file is empty", ErrInvalidAsset)
+	}
+	if maxSize > 0 && len(data) > maxSize {
+		return nil, fmt.Errorf("%w: the file is larger than %d bytes", ErrInvalidAsset, maxSize)
+	}
+	contentType := http.DetectContentType(data)
+	ext, ok := imageExtensions[contentType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not a PNG, JPEG, GIF or WebP image", ErrInvalidAsset, contentType)
+	}
+	if !assetTypeAllowed(contentType, allowedTypes) {
+		return nil, fmt.Errorf("%w: %s images are not accepted", ErrInvalidAsset, contentType)
+	}
+
+	assetID := id.New()
+	return &Asset{
+		ID:          assetID,
+		Key:         "cms/" + assetID + ext,
+		Filename:    strings.TrimSpace(path.Base(strings.ReplaceAll(filename, `\`, "/"))),
+		ContentType: contentType,
+		Size:        len(data),
+		UploadedBy:  uploadedBy,
+		CreatedAt:   time.Now(),
+	}, nil
+}
+
+func assetTypeAllowed(contentType string, allowedTypes []string) bool {
+	if len(allowedTypes) == 0 {
+		return true
+	}
+	for _, allowed := range allowedTypes {
+		if strings.EqualFold(strings.TrimSpace(allowed), contentType) {
+			return true
+		}
+	}
+	return false
+}
+
+type AssetRepository interface {
+	Create(ctx context.Context, a *Asset) error
+	Get(ctx context.Context, id string) (*Asset, error)
+	List(ctx context.Context, limit, offset int) ([]*Asset, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// Storage keeps asset files.
+type Storage interface {
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	SignedURL(key string, ttl time.Duration) (string, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// UnavailableStorage stands in when the object store could not be set up,
+// so uploads fail instead of the API refusing to start.
+type UnavailableStorage struct{}
+
+func (UnavailableStorage) Put(ctx context.Context, key, contentType string, data []byte) error {
+	return ErrStorageUnavailable
+}
+
+func (UnavailableStorage) SignedURL(key string, ttl time.Duration) (string, error) {
+	return "", ErrStorageUnavailable
+}
+
+func (UnavailableStorage) Delete(ctx context.Context, key string) error {
+	return ErrStorageUnavailable
+}
+
+// Cache holds what the public content endpoints serve. Every admin write
+// clears it, and entries expire on their own so a missed clear is not
+// permanent.
+type Cache interface {
+	Get(ctx context.Context, key string, dest interface{}) (found bool, err error)
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	Clear(ctx context.Context) error
+}
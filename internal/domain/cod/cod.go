@@ -0,0 +1,288 @@
+package cod
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"online-shop/pkg/id"
+)
+
+var (
+	ErrNotFound           = errors.New("cash on delivery collection not found")
+	ErrRemittanceNotFound = errors.New("courier remittance not found")
+	// ErrNotEligible names what keeps the order from being paid on delivery
+	ErrNotEligible = errors.New("order cannot be paid on delivery")
+	// ErrLimitExceeded is returned when paying on delivery would take the
+	// customer over one of their limits
+	ErrLimitExceeded = errors.New("cash on delivery limit exceeded")
+	// ErrAmountMismatch is returned when the courier reports collecting
+	// other than what the order is owed
+	ErrAmountMismatch = errors.New("collected amount does not match the amount due")
+	// ErrNotRemittable is returned for a remittance covering collections
+	// that are not held by its courier
+	ErrNotRemittable = errors.New("collections cannot be remitted")
+)
+
+// Tolerance absorbs float rounding when cash amounts are compared
+const Tolerance = 0.01
+
+type Status string
+
+const (
+	// StatusAwaitingDelivery collections are owed by the customer when the
+	// order arrives
+	StatusAwaitingDelivery Status = "awaiting_delivery"
+	// StatusCollected cash is held by the courier
+	StatusCollected Status = "collected"
+	// StatusSettled cash has been handed over to the shop
+	StatusSettled Status = "settled"
+)
+
+// Collection is the cash an order is paid with on delivery, followed from
+// the customer to the courier and on to the shop.
+type Collection struct {
+	ID        string  `json:"id" gorm:"primaryKey"`
+	OrderID   string  `json:"order_id" gorm:"uniqueIndex"`
+	UserID    string  `json:"user_id" gorm:"index"`
+	PaymentID string  `json:"payment_id"`
+	Amount    float64 `json:"amount"`
+	Status    Status  `json:"status" gorm:"index"`
+	// Carrier is the courier who delivered the order and holds its cash
+	Carrier         string     `json:"carrier,omitempty" gorm:"index"`
+	CollectedAmount float64    `json:"collected_amount"`
+	CollectedAt     *time.Time `json:"collected_at,omitempty"`
+	RemittanceID    string     `json:"remittance_id,omitempty" gorm:"index"`
+	SettledAt       *time.Time `json:"settled_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+func (Collection) TableName() string {
+	return "cod_collections"
+}
+
+func NewCollection(orderID, userID, paymentID string, amount float64) *Collection {
+	now := time.Now()
+	return &Collection{
+		ID:        id.New(),
+		OrderID:   orderID,
+		UserID:    userID,
+		PaymentID: paymentID,
+		Amount:    amount,
+		Status:    StatusAwaitingDelivery,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// Collect records the cash the courier took at the door. It must be the
+// amount owed; a customer paying less is a failed delivery, not a
+// collection.
+func (c *Collection) Collect(carrier string, amount float64, at time.Time) error {
+	if c.Status != StatusAwaitingDelivery {
+		return fmt.Errorf("%w: the cash was already collected", ErrAmountMismatch)
+	}
+	if math.Abs(amount-c.Amount) > Tolerance {
+		return fmt.Errorf("%w: %.2f is due, %.2f was collected", ErrAmountMismatch, c.Amount, amount)
+	}
+	c.Status = StatusCollected
+	c.Carrier = carrier
+	c.CollectedAmount = amount
+	c.CollectedAt = &at
+	c.UpdatedAt = at
+	return nil
+}
+
+type RemittanceStatus string
+
+const (
+	RemittanceBalanced RemittanceStatus = "balanced"
+	// RemittanceShort couriers handed over less than they collected
+	RemittanceShort RemittanceStatus = "short"
+	RemittanceOver  RemittanceStatus = "over"
+)
+
+// Remittance is cash a courier handed over to the shop for the collections
+// they made. Expected is what those collections add up to; Difference is
+// Received less Expected, negative when the courier is short.
+type Remittance struct {
+	ID          string           `json:"id" gorm:"primaryKey"`
+	Carrier     string           `json:"carrier" gorm:"index"`
+	Reference   string           `json:"reference,omitempty"`
+	Expected    float64          `json:"expected"`
+	Received    float64          `json:"received"`
+	Difference  float64          `json:"difference"`
+	Status      RemittanceStatus `json:"status" gorm:"index"`
+	Note        string           `json:"note,omitempty"`
+	RecordedBy  string           `json:"recorded_by"`
+	Collections []Collection     `json:"collections,omitempty" gorm:"foreignKey:RemittanceID"`
+	CreatedAt   time.Time        `json:"created_at" gorm:"index"`
+}
+
+func (Remittance) TableName() string {
+	return "cod_remittances"
+}
+
+// Remit reconciles the cash a courier handed over against the collections
+// it pays for, which are settled by it. Every collection must be held by the
+// courier; a shortfall or surplus is recorded on the remittance rather than
+// refused, so the counted cash is always booked.
+func Remit(carrier, reference string, received float64, collections []*Collection, recordedBy, note string, at time.Time) (*Remittance, error) {
+	if len(collections) == 0 {
+		return nil, fmt.Errorf("%w: at least one collection is required", ErrNotRemittable)
+	}
+	r := &Remittance{
+		ID:         id.New(),
+		Carrier:    carrier,
+		Reference:  strings.TrimSpace(reference),
+		Received:   received,
+		Note:       strings.TrimSpace(note),
+		RecordedBy: recordedBy,
+		CreatedAt:  at,
+	}
+	for _, c := range collections {
+		if c.Status != StatusCollected {
+			return nil, fmt.Errorf("%w: collection %s is %s", ErrNotRemittable, c.ID, c.Status)
+		}
+		if !strings.EqualFold(c.Carrier, carrier) {
+			return nil, fmt.Errorf("%w: collection %s is held by %s", ErrNotRemittable, c.ID, c.Carrier)
+		}
+		r.Expected += c.CollectedAmount
+	}
+
+	r.Difference = math.Round((r.Received-r.Expected)*100) / 100
+	switch {
+	case r.Difference <= -Tolerance:
+		r.Status = RemittanceShort
+	case r.Difference >= Tolerance:
+		r.Status = RemittanceOver
+	default:
+		r.Status = RemittanceBalanced
+		r.Difference = 0
+	}
+
+	for _, c := range collections {
+		c.Status = StatusSettled
+		c.RemittanceID = r.ID
+		c.SettledAt = &at
+		c.UpdatedAt = at
+	}
+	return r, nil
+}
+
+// CourierBalance is the cash a courier has collected and not yet handed
+// over.
+type CourierBalance struct {
+	Carrier     string     `json:"carrier"`
+	Collections int        `json:"collections"`
+	Amount      float64    `json:"amount"`
+	OldestAt    *time.Time `json:"oldest_collected_at"`
+}
+
+// Exposure is what a customer owes across the orders they are still to pay
+// on delivery.
+type Exposure struct {
+	Orders int     `json:"orders"`
+	Amount float64 `json:"amount"`
+}
+
+// Limits cap how much a customer can order for payment on delivery. Zero
+// means no cap.
+type Limits struct {
+	MaxOrderAmount       float64 `json:"max_order_amount"`
+	MaxOutstandingAmount float64 `json:"max_outstanding_amount"`
+	MaxOpenOrders        int     `json:"max_open_orders"`
+}
+
+// CustomerLimit overrides the shop's limits for one customer, or keeps them
+// from paying on delivery at all, such as after refusing deliveries. A nil
+// amount keeps the shop's limit.
+type CustomerLimit struct {
+	UserID               string    `json:"user_id" gorm:"primaryKey"`
+	Blocked              bool      `json:"blocked"`
+	MaxOrderAmount       *float64  `json:"max_order_amount"`
+	MaxOutstandingAmount *float64  `json:"max_outstanding_amount"`
+	Note                 string    `json:"note,omitempty"`
+	UpdatedBy            string    `json:"updated_by"`
+	UpdatedAt            time.Time `json:"updated_at"`
+}
+
+func (CustomerLimit) TableName() string {
+	return "cod_customer_limits"
+}
+
+// For returns the limits that apply to the customer.
+func (l Limits) For(customer *CustomerLimit) Limits {
+	if customer == nil {
+		return l
+	}
+	if customer.MaxOrderAmount != nil {
+		l.MaxOrderAmount = *customer.MaxOrderAmount
+	}
+	if customer.MaxOutstandingAmount != nil {
+		l.MaxOutstandingAmount = *customer.MaxOutstandingAmount
+	}
+	return l
+}
+
+// Check reports whether the customer, already owing open, can pay an order
+// of amount on delivery.
+func (l Limits) Check(customer *CustomerLimit, amount float64, open Exposure) error {
+	if customer != nil && customer.Blocked {
+		return fmt.Errorf("%w: cash on delivery is not available on this account", ErrLimitExceeded)
+	}
+	limits := l.For(customer)
+	if limits.MaxOrderAmount > 0 && amount > limits.MaxOrderAmount+Tolerance {
+		return fmt.Errorf("%w: orders of up to %.2f can be paid on delivery", ErrLimitExceeded, limits.MaxOrderAmount)
+	}
+	if limits.MaxOpenOrders > 0 && open.Orders >= limits.MaxOpenOrders {
+		return fmt.Errorf("%w: at most %d orders can await payment on delivery", ErrLimitExceeded, limits.MaxOpenOrders)
+	}
+	if limits.MaxOutstandingAmount > 0 && open.Amount+amount > limits.MaxOutstandingAmount+Tolerance {
+		return fmt.Errorf("%w: at most %.2f can await payment on delivery, %.2f already does", ErrLimitExceeded, limits.MaxOutstandingAmount, open.Amount)
+	}
+	return nil
+}
+
+// CollectionFilter narrows a collection listing; empty fields match all.
+type CollectionFilter struct {
+	Status  Status
+	Carrier string
+}
+
+type Repository interface {
+	Create(ctx context.Context, c *Collection) error
+	// GetByOrderID returns the order's collection, or ErrNotFound when the
+	// order is not paid on delivery
+	GetByOrderID(ctx context.Context, orderID string) (*Collection, error)
+	Update(ctx context.Context, c *Collection) error
+	// ListByIDs returns the collections with the IDs that exist
+	ListByIDs(ctx context.Context, ids []string) ([]*Collection, error)
+	// List returns a page of collections, newest first, and how many match
+	List(ctx context.Context, filter CollectionFilter, limit, offset int) ([]*Collection, int64, error)
+	// Exposure adds up the customer's collections awaiting delivery on
+	// orders that are not cancelled
+	Exposure(ctx context.Context, userID string) (Exposure, error)
+	// Balances returns what each courier holds, most first
+	Balances(ctx context.Context) ([]CourierBalance, error)
+	// CreateRemittance saves the remittance with the collections it
+	// settled. It fails with ErrNotRemittable, saving nothing, when one of
+	// them was settled in the meantime.
+	CreateRemittance(ctx context.Context, r *Remittance, collections []*Collection) error
+	// GetRemittance returns the remittance with its collections, or
+	// ErrRemittanceNotFound
+	GetRemittance(ctx context.Context, id string) (*Remittance, error)
+	// ListRemittances returns remittances newest first; an empty carrier
+	// lists every courier's
+	ListRemittances(ctx context.Context, carrier string, limit, offset int) ([]*Remittance, error)
+}
+
+type LimitRepository interface {
+	// Get returns the customer's override, or nil when they have none
+	Get(ctx context.Context, userID string) (*CustomerLimit, error)
+	Save(ctx context.Context, l *CustomerLimit) error
+}
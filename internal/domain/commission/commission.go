@@ -0,0 +1,114 @@
+package commission
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"online-shop/pkg/id"
+)
+
+// Rule is a marketplace commission charged on order items. Rate is a
+// percentage of the item subtotal; FixedFee is added per order item.
+type Rule struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name"`
+	Scope     Scope     `json:"scope" gorm:"index:idx_commission_scope"`
+	ScopeID   string    `json:"scope_id" gorm:"index:idx_commission_scope"`
+	Rate      float64   `json:"rate"`
+	FixedFee  float64   `json:"fixed_fee"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Scope string
+
+const (
+	ScopeGlobal   Scope = "global"
+	ScopeCategory Scope = "category"
+	ScopeMerchant Scope = "merchant"
+)
+
+type Repository interface {
+	Create(ctx context.Context, rule *Rule) error
+	GetByID(ctx context.Context, id string) (*Rule, error)
+	Update(ctx context.Context, rule *Rule) error
+	Delete(ctx context.Context, id string) error
+	List(ctx context.Context, limit, offset int) ([]*Rule, error)
+	// ListActive returns the active rules, most recently updated first
+	ListActive(ctx context.Context) ([]*Rule, error)
+}
+
+func NewRule(name string, scope Scope, scopeID string, rate, fixedFee float64) (*Rule, error) {
+	if !scope.IsValid() {
+		return nil, errors.New("invalid commission scope")
+	}
+	if scope != ScopeGlobal && scopeID == "" {
+		return nil, errors.New("scope id is required for category and merchant rules")
+	}
+	if rate < 0 || rate > 100 || fixedFee < 0 {
+		return nil, errors.New("invalid commission amount")
+	}
+	if scope == ScopeGlobal {
+		scopeID = ""
+	}
+
+	return &Rule{
+		ID:        id.New(),
+		Name:      name,
+		Scope:     scope,
+		ScopeID:   scopeID,
+		Rate:      rate,
+		FixedFee:  fixedFee,
+		Active:    true,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}, nil
+}
+
+func (s Scope) IsValid() bool {
+	return s == ScopeGlobal || s == ScopeCategory || s == ScopeMerchant
+}
+
+// Calculate returns the commission owed on the given subtotal, rounded to
+// the cent and never more than the subtotal itself.
+func (r *Rule) Calculate(subtotal float64) float64 {
+	fee := math.Round((subtotal*r.Rate/100+r.FixedFee)*100) / 100
+	if fee > subtotal {
+		return subtotal
+	}
+	return fee
+}
+
+// Resolve picks the most specific active rule for an item: merchant rules
+// win over category rules, which win over the global rule. Of several rules
+// for the same scope the first listed wins, the latest updated as
+// ListActive orders them.
+func Resolve(rules []*Rule, categoryID, merchantID string) *Rule {
+	var global, category *Rule
+	for _, r := range rules {
+		if !r.Active {
+			continue
+		}
+		switch r.Scope {
+		case ScopeMerchant:
+			if merchantID != "" && r.ScopeID == merchantID {
+				return r
+			}
+		case ScopeCategory:
+			if category == nil && categoryID != "" && r.ScopeID == categoryID {
+				category = r
+			}
+		case ScopeGlobal:
+			if global == nil {
+				global = r
+			}
+		}
+	}
+	if category != nil {
+		return category
+	}
+	return global
+}
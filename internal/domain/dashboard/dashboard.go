@@ -0,0 +1,38 @@
+package dashboard
+
+import (
+	"context"
+	"time"
+)
+
+// Stats is a snapshot of the admin dashboard KPIs. "Today" counters cover
+// the window starting at Since.
+type Stats struct {
+	TodayOrders       int64     `json:"today_orders"`
+	TodayRevenue      float64   `json:"today_revenue"`
+	PendingShipments  int64     `json:"pending_shipments"`
+	FailedPayments    int64     `json:"failed_payments"`
+	NewUsers          int64     `json:"new_users"`
+	LowStockProducts  int64     `json:"low_stock_products"`
+	LowStockThreshold int       `json:"low_stock_threshold"`
+	Since             time.Time `json:"since"`
+	ComputedAt        time.Time `json:"computed_at"`
+}
+
+// Repository computes the aggregates from the primary database. It is
+// expensive and meant to be called by the refresh job, not per request.
+type Repository interface {
+	Compute(ctx context.Context, since time.Time, lowStockThreshold int) (*Stats, error)
+}
+
+// Cache holds the last computed snapshot.
+type Cache interface {
+	Save(ctx context.Context, stats *Stats, ttl time.Duration) error
+	Load(ctx context.Context) (*Stats, error)
+}
+
+// StartOfDay returns local midnight of the day containing t.
+func StartOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,245 @@
+package draftorder
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"online-shop/internal/domain/order"
+	"online-shop/pkg/id"
+)
+
+var (
+	ErrNotFound = errors.New("draft order not found")
+	// ErrWrongStatus is returned for a step the draft's status does not
+	// allow, such as paying a draft that was cancelled
+	ErrWrongStatus = errors.New("draft order cannot do that in its status")
+	ErrExpired     = errors.New("draft order has expired")
+)
+
+type Status string
+
+const (
+	// StatusOpen drafts were created and not sent yet; their link can
+	// already be paid
+	StatusOpen Status = "open"
+	// StatusSent drafts were emailed to the customer
+	StatusSent Status = "sent"
+	// StatusConverting drafts were paid and are being converted; only the
+	// delivery of the payment webhook that claimed the draft places its
+	// order
+	StatusConverting Status = "converting"
+	// StatusCompleted drafts were paid and converted into an order
+	StatusCompleted Status = "completed"
+	StatusCancelled Status = "cancelled"
+	StatusExpired   Status = "expired"
+	// StatusFailed drafts were paid but could not be converted, such as
+	// when their products sold out in the meantime; staff follow them up
+	// and refund the payment
+	StatusFailed Status = "failed"
+)
+
+// Draft is an order staff put together for a customer, who pays it through
+// a link before it becomes an order.
+type Draft struct {
+	ID string `json:"id" gorm:"primaryKey"`
+	// MerchantID is the merchant who drafted it, of whose products it is;
+	// empty for drafts admins created
+	MerchantID string `json:"merchant_id,omitempty" gorm:"index"`
+	CreatedBy  string `json:"created_by"`
+	UserID     string `json:"user_id" gorm:"index"`
+	// Email is where the link is sent
+	Email  string `json:"email"`
+	Status Status `json:"status" gorm:"index"`
+	// Token is the secret of the draft's payment link
+	Token string `json:"token" gorm:"uniqueIndex"`
+	Items []Item `json:"items" gorm:"type:jsonb;serializer:json"`
+	// PriceReason says why prices were overridden
+	PriceReason     string        `json:"price_reason,omitempty"`
+	ShippingAddress order.Address `json:"shipping_address" gorm:"embedded;embeddedPrefix:shipping_"`
+	ShippingRateID  string        `json:"shipping_rate_id,omitempty"`
+	Note            string        `json:"note,omitempty"`
+	GiftWrap        bool          `json:"gift_wrap,omitempty"`
+	GiftMessage     string        `json:"gift_message,omitempty"`
+	ShippingFee     float64       `json:"shipping_fee"`
+	GiftWrapFee     float64       `json:"gift_wrap_fee,omitempty"`
+	// TotalAmount is what the order was priced at when it was drafted, and
+	// what the link charges
+	TotalAmount float64   `json:"total_amount"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	// PaymentID is the latest payment started through the link
+	PaymentID string `json:"payment_id,omitempty"`
+	// OrderID is the order a paid draft was converted into
+	OrderID       string     `json:"order_id,omitempty" gorm:"index"`
+	FailureReason string     `json:"failure_reason,omitempty"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	CompletedAt   *time.Time `json:"completed_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Item is a product drafted, with the name and unit price it was drafted
+// at. Prices are fixed when the order is drafted, so the order is placed
+// for what the customer was shown; ListPrice is the product's own price.
+type Item struct {
+	ProductID string  `json:"product_id"`
+	Name      string  `json:"name"`
+	Quantity  int     `json:"quantity"`
+	Price     float64 `json:"price"`
+	ListPrice float64 `json:"list_price"`
+}
+
+// Filter narrows a listing to one merchant's drafts, and to a status when
+// one is given.
+type Filter struct {
+	MerchantID string
+	Status     Status
+}
+
+// LinkNotice is a draft's payment link emailed to its customer.
+type LinkNotice struct {
+	Draft        *Draft
+	Link         string
+	CustomerName string
+	Locale       string
+}
+
+// Notifier emails customers the payment links of their drafts.
+type Notifier interface {
+	LinkSent(ctx context.Context, n LinkNotice) error
+}
+
+type Repository interface {
+	Create(ctx context.Context, d *Draft) error
+	GetByID(ctx context.Context, id string) (*Draft, error)
+	GetByToken(ctx context.Context, token string) (*Draft, error)
+	Update(ctx context.Context, d *Draft) error
+	// Claim moves an open or sent draft to converting, reporting false when
+	// it is in another status, such as when another delivery claimed it
+	// first
+	Claim(ctx context.Context, id string, now time.Time) (bool, error)
+	// List returns the drafts matching the filter, newest first
+	List(ctx context.Context, filter Filter, limit, offset int) ([]*Draft, error)
+}
+
+// New drafts an order for userID, payable until validity has passed.
+func New(createdBy, merchantID, userID, email string, items []Item, total float64, validity time.Duration) (*Draft, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &Draft{
+		ID:          id.New(),
+		MerchantID:  merchantID,
+		CreatedBy:   createdBy,
+		UserID:      userID,
+		Email:       email,
+		Status:      StatusOpen,
+		Token:       token,
+		Items:       items,
+		TotalAmount: total,
+		ExpiresAt:   now.Add(validity),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}, nil
+}
+
+// newToken returns 32 random bytes, base64url encoded without padding.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Link is the draft's payment link: pageURL, the storefront's page for it,
+// with the token, or without a page the summary the API hosts under
+// baseURL.
+func (d *Draft) Link(baseURL, pageURL string) string {
+	if pageURL != "" {
+		return pageURL + "?token=" + url.QueryEscape(d.Token)
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/api/v1/draft-orders/" + d.Token
+}
+
+// Open reports whether the draft still waits to be paid.
+func (d *Draft) Open() bool {
+	return d.Status == StatusOpen || d.Status == StatusSent
+}
+
+// Sent records that the link was emailed to the customer. It can be sent
+// again, such as when the customer lost the email.
+func (d *Draft) Sent(now time.Time) error {
+	if err := d.CheckPayable(now); err != nil {
+		return err
+	}
+	d.Status = StatusSent
+	d.SentAt = &now
+	d.UpdatedAt = now
+	return nil
+}
+
+// CheckPayable reports whether a payment can be started through the link
+// at now, or ErrExpired once it is past its validity.
+func (d *Draft) CheckPayable(now time.Time) error {
+	if d.ExpireAt(now) {
+		return ErrExpired
+	}
+	if !d.Open() {
+		return fmt.Errorf("%w: draft order is %s", ErrWrongStatus, d.Status)
+	}
+	return nil
+}
+
+// Cancel withdraws the draft; its link can no longer be paid.
+func (d *Draft) Cancel(now time.Time) error {
+	if !d.Open() {
+		return fmt.Errorf("%w: draft order is %s", ErrWrongStatus, d.Status)
+	}
+	d.Status = StatusCancelled
+	d.UpdatedAt = now
+	return nil
+}
+
+// Started records the payment started through the link.
+func (d *Draft) Started(paymentID string, now time.Time) {
+	d.PaymentID = paymentID
+	d.UpdatedAt = now
+}
+
+// Completed records the order the paid draft was converted into.
+func (d *Draft) Completed(orderID string, now time.Time) {
+	d.OrderID = orderID
+	d.Status = StatusCompleted
+	d.FailureReason = ""
+	d.CompletedAt = &now
+	d.UpdatedAt = now
+}
+
+// Fail records why a paid draft could not be converted into an order.
+func (d *Draft) Fail(reason string, now time.Time) {
+	d.Status = StatusFailed
+	d.FailureReason = reason
+	d.UpdatedAt = now
+}
+
+// ExpireAt expires a draft that was not paid in time, and reports whether
+// it did. Like quotes, drafts are expired as they are read rather than by a
+// job, so a lapsed draft is still stored as open and a payment started
+// before it lapsed still converts it.
+func (d *Draft) ExpireAt(now time.Time) bool {
+	if !d.Open() || now.Before(d.ExpiresAt) {
+		return false
+	}
+	d.Status = StatusExpired
+	d.UpdatedAt = now
+	return true
+}
@@ -0,0 +1,134 @@
+package emaildelivery
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"online-shop/pkg/id"
+)
+
+var (
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+	// ErrQueueUnavailable stops a dead letter resend or a campaign batch that
+	// has no email queue to go to
+	ErrQueueUnavailable = errors.New("email queue unavailable")
+)
+
+// DeadLetter is an email the worker gave up on, either because the failure
+// was permanent or because the retries ran out. It keeps what is needed to
+// queue the email again once the cause is fixed.
+type DeadLetter struct {
+	ID         string                 `json:"id" gorm:"primaryKey"`
+	To         string                 `json:"to" gorm:"index"`
+	Subject    string                 `json:"subject,omitempty"`
+	Template   string                 `json:"template"`
+	Locale     string                 `json:"locale,omitempty"`
+	Version    int                    `json:"version,omitempty"`
+	Data       map[string]interface{} `json:"data" gorm:"serializer:json"`
+	CampaignID string                 `json:"campaign_id,omitempty" gorm:"index"`
+	Category   string                 `json:"category,omitempty"`
+	Error      string                 `json:"error"`
+	// Permanent is set when no retry would have helped
+	Permanent bool       `json:"permanent"`
+	CreatedAt time.Time  `json:"created_at" gorm:"index"`
+	ResentAt  *time.Time `json:"resent_at,omitempty"`
+}
+
+func (DeadLetter) TableName() string {
+	return "email_dead_letters"
+}
+
+type DeadLetterRepository interface {
+	Create(ctx context.Context, d *DeadLetter) error
+	Get(ctx context.Context, id string) (*DeadLetter, error)
+	// List returns the most recent first; pending leaves out the ones
+	// already resent
+	List(ctx context.Context, pending bool, limit, offset int) ([]*DeadLetter, error)
+	MarkResent(ctx context.Context, id string, at time.Time) error
+}
+
+func NewDeadLetter(to, subject, template, locale string, version int, data map[string]interface{}, campaignID string, err error) *DeadLetter {
+	return &DeadLetter{
+		ID:         id.New(),
+		To:         to,
+		Subject:    subject,
+		Template:   template,
+		Locale:     locale,
+		Version:    version,
+		Data:       data,
+		CampaignID: campaignID,
+		Error:      err.Error(),
+		Permanent:  IsPermanent(err),
+		CreatedAt:  time.Now(),
+	}
+}
+
+// Recipient is one address of a campaign. Its data is merged over the
+// campaign's, so only what differs per recipient needs to be given.
+type Recipient struct {
+	To   string                 `json:"to" validate:"required,email"`
+	Data map[string]interface{} `json:"data,omitempty"`
+}
+
+// Batch is a slice of a campaign's recipients, queued as one message so a
+// large campaign does not flood the queue.
+type Batch struct {
+	CampaignID string
+	Template   string
+	Subject    string
+	Locale     string
+	Data       map[string]interface{}
+	Recipients []Recipient
+	// Tracked emails get the campaign's open and click tracking
+	Tracked bool
+	// Category is the notification category of the campaign's emails
+	Category string
+}
+
+// MergeData is the data a recipient's email is rendered with.
+func MergeData(shared, own map[string]interface{}) map[string]interface{} {
+	data := make(map[string]interface{}, len(shared)+len(own))
+	for key, value := range shared {
+		data[key] = value
+	}
+	for key, value := range own {
+		data[key] = value
+	}
+	return data
+}
+
+// Split cuts recipients into batches of at most size.
+func Split(recipients []Recipient, size int) [][]Recipient {
+	if size <= 0 {
+		size = len(recipients)
+	}
+	var batches [][]Recipient
+	for start := 0; start < len(recipients); start += size {
+		end := start + size
+		if end > len(recipients) {
+			end = len(recipients)
+		}
+		batches = append(batches, recipients[start:end])
+	}
+	return batches
+}
+
+// Publisher queues emails for the email worker.
+type Publisher interface {
+	// Resend queues a dead letter's email again
+	Resend(ctx context.Context, d *DeadLetter) error
+	PublishBatch(ctx context.Context, b *Batch) error
+}
+
+// UnavailablePublisher is used while RabbitMQ is down, so resending a dead
+// letter or starting a campaign fails rather than drop the emails.
+type UnavailablePublisher struct{}
+
+func (UnavailablePublisher) Resend(ctx context.Context, d *DeadLetter) error {
+	return ErrQueueUnavailable
+}
+
+func (UnavailablePublisher) PublishBatch(ctx context.Context, b *Batch) error {
+	return ErrQueueUnavailable
+}
@@ -0,0 +1,279 @@
+// Package emaildelivery sends rendered emails through the configured
+// providers and keeps the suppression list: addresses that bounced or
+// complained are not sent to again.
+package emaildelivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+var (
+	ErrSuppressed          = errors.New("recipient is on the suppression list")
+	ErrNoProvider          = errors.New("no email provider is configured")
+	ErrSuppressionNotFound = errors.New("suppression not found")
+	ErrUnknownWebhook      = errors.New("unknown email webhook")
+	// ErrInvalidWebhook is returned for notifications whose signature does
+	// not verify
+	ErrInvalidWebhook = errors.New("email webhook could not be verified")
+)
+
+// permanentError marks a failure that retrying will not fix, such as a
+// rejected recipient or a refused login
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying.
+func Permanent(err error) error {
+	if err == nil || IsPermanent(err) {
+		return err
+	}
+	return &permanentError{err: err}
+}
+
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// Event types
+const (
+	EventBounce    = "bounce"
+	EventComplaint = "complaint"
+)
+
+// Suppression reasons
+const (
+	ReasonBounce    = "bounce"
+	ReasonComplaint = "complaint"
+	ReasonManual    = "manual"
+)
+
+// Message is a rendered email.
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	HTML    string
+	Locale  string
+	// Headers are extra headers, such as List-Unsubscribe
+	Headers map[string]string
+}
+
+// Provider sends email through one delivery service.
+type Provider interface {
+	Name() string
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Event is a bounce or complaint a provider reported for an address.
+type Event struct {
+	Provider string
+	Type     string
+	Email    string
+	// Permanent is set for hard bounces; soft bounces may succeed later
+	Permanent  bool
+	Detail     string
+	OccurredAt time.Time
+}
+
+// Suppresses reports whether the event stops further sends to the address.
+// Complaints always do, bounces only when permanent.
+func (e Event) Suppresses() bool {
+	switch e.Type {
+	case EventComplaint:
+		return true
+	case EventBounce:
+		return e.Permanent
+	}
+	return false
+}
+
+// Webhook verifies and parses the notifications a provider posts about
+// bounces and complaints. Notifications that are not about either yield no
+// events.
+type Webhook interface {
+	Name() string
+	Events(ctx context.Context, header http.Header, body []byte) ([]Event, error)
+}
+
+// Suppression is an address the email worker no longer sends to.
+type Suppression struct {
+	Email     string    `json:"email" gorm:"primaryKey"`
+	Reason    string    `json:"reason"`
+	Provider  string    `json:"provider,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (Suppression) TableName() string {
+	return "email_suppressions"
+}
+
+type SuppressionRepository interface {
+	// Add suppresses the address, replacing the reason when it already is
+	Add(ctx context.Context, s *Suppression) error
+	Get(ctx context.Context, email string) (*Suppression, error)
+	List(ctx context.Context, limit, offset int) ([]*Suppression, error)
+	Remove(ctx context.Context, email string) error
+}
+
+func NewSuppression(email, reason, provider, detail string) *Suppression {
+	return &Suppression{
+		Email:     NormalizeAddress(email),
+		Reason:    reason,
+		Provider:  provider,
+		Detail:    detail,
+		CreatedAt: time.Now(),
+	}
+}
+
+// SuppressionFor builds the suppression an event adds.
+func SuppressionFor(e Event) *Suppression {
+	reason := ReasonBounce
+	if e.Type == EventComplaint {
+		reason = ReasonComplaint
+	}
+	s := NewSuppression(e.Email, reason, e.Provider, e.Detail)
+	if !e.OccurredAt.IsZero() {
+		s.CreatedAt = e.OccurredAt
+	}
+	return s
+}
+
+// NormalizeAddress is the form addresses are suppressed and looked up in.
+func NormalizeAddress(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// RetryPolicy retries transient failures with exponential backoff. Attempts
+// counts every try, so 1 or less never retries.
+type RetryPolicy struct {
+	Attempts  int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// Backoff is the wait after the given failed attempt, counted from 0: the
+// delay doubles each attempt up to MaxDelay, and a random half of it is
+// jitter so workers that failed together do not retry together.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := float64(p.BaseDelay) * math.Pow(2, float64(attempt))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	half := delay / 2
+	return time.Duration(half + rand.Float64()*half)
+}
+
+// throttledProvider waits for its rate limit before each send
+type throttledProvider struct {
+	Provider
+	limiter *rate.Limiter
+}
+
+// Throttle limits a provider to perSecond sends, allowing bursts of up to a
+// second's worth.
+func Throttle(p Provider, perSecond float64) Provider {
+	burst := int(math.Ceil(perSecond))
+	if burst < 1 {
+		burst = 1
+	}
+	return &throttledProvider{Provider: p, limiter: rate.NewLimiter(rate.Limit(perSecond), burst)}
+}
+
+func (p *throttledProvider) Send(ctx context.Context, msg *Message) error {
+	if err := p.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	return p.Provider.Send(ctx, msg)
+}
+
+// Sender checks the suppression list and then tries each provider in turn
+// until one accepts the message. When every provider fails and at least one
+// failure was transient, the round is retried after a backoff.
+type Sender struct {
+	providers    []Provider
+	suppressions SuppressionRepository
+	retry        RetryPolicy
+}
+
+// NewSender sends through providers in order. Without a suppression
+// repository every address is sent to.
+func NewSender(suppressions SuppressionRepository, providers ...Provider) *Sender {
+	return &Sender{providers: providers, suppressions: suppressions}
+}
+
+// SetRetry retries transient failures; by default each provider is tried
+// once.
+func (s *Sender) SetRetry(policy RetryPolicy) {
+	s.retry = policy
+}
+
+// Providers names the providers in the order they are tried.
+func (s *Sender) Providers() []string {
+	names := make([]string, len(s.providers))
+	for i, p := range s.providers {
+		names[i] = p.Name()
+	}
+	return names
+}
+
+// Send returns the name of the provider that accepted the message, or
+// ErrSuppressed without sending when the recipient is suppressed. Failures
+// no retry would fix are marked Permanent.
+func (s *Sender) Send(ctx context.Context, msg *Message) (string, error) {
+	if len(s.providers) == 0 {
+		return "", ErrNoProvider
+	}
+	if s.suppressions != nil {
+		_, err := s.suppressions.Get(ctx, NormalizeAddress(msg.To))
+		if err == nil {
+			return "", ErrSuppressed
+		}
+		if !errors.Is(err, ErrSuppressionNotFound) {
+			return "", fmt.Errorf("failed to check suppression list: %w", err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		failures := make([]string, 0, len(s.providers))
+		transient := false
+		for _, p := range s.providers {
+			err := p.Send(ctx, msg)
+			if err == nil {
+				return p.Name(), nil
+			}
+			failures = append(failures, p.Name()+": "+err.Error())
+			transient = transient || !IsPermanent(err)
+		}
+
+		err := fmt.Errorf("every email provider failed: %s", strings.Join(failures, "; "))
+		if !transient {
+			return "", Permanent(err)
+		}
+		if attempt+1 >= s.retry.Attempts {
+			return "", err
+		}
+
+		timer := time.NewTimer(s.retry.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return "", ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package emailtemplate
+
+import (
+	"context"
+	"embed"
+	"sort"
+)
+
+// GenericName is the default used for names without a template of their
+// own. It sends data's Subject and Message.
+const GenericName = "generic"
+
+//go:embed defaults/*.html
+var defaultBodies embed.FS
+
+// defaults are the built-in templates. Their text comes from the i18n
+// catalog, so one default serves every locale.
+var defaults = map[string]Template{
+	"welcome": {
+		Subject: `{{t "email.welcome.subject"}}`,
+		Variables: []Variable{
+			{Name: "FirstName", Type: TypeString, Required: true, Example: "Budi"},
+		},
+	},
+	"order_confirmation": {
+		Subject: `{{t "email.order_confirmation.subject"}}`,
+		Variables: []Variable{
+			{Name: "CustomerName", Type: TypeString, Required: true, Example: "Budi Santoso"},
+			{Name: "OrderNumber", Type: TypeString, Required: true, Example: "ORD-2026-000123"},
+			{Name: "Items", Type: TypeList, Required: true, Example: []interface{}{
+				map[string]interface{}{"ProductName": "Coffee Beans", "Quantity": 2, "TotalPrice": 24.5},
+			}},
+			{Name: "TotalAmount", Type: TypeNumber, Required: true, Example: 24.5},
+			{Name: "ShippingRate", Type: TypeString, Example: "Regular"},
+			{Name: "ShippingAmount", Type: TypeNumber, Example: 2},
+			{Name: "DeliveryFrom", Type: TypeString, Example: "2026-01-05"},
+			{Name: "DeliveryTo", Type: TypeString, Example: "2026-01-07"},
+		},
+	},
+	"invoice": {
+		Subject: `{{t "email.invoice.subject"}}`,
+		Variables: []Variable{
+			{Name: "OrderNumber", Type: TypeString, Required: true, Example: "ORD-2026-000123"},
+			{Name: "InvoiceNumber", Type: TypeString, Example: "INV-20260101-ORD-2026-000123"},
+			{Name: "Date", Type: TypeString, Required: true, Example: "January 2, 2026"},
+			{Name: "Items", Type: TypeList, Required: true, Example: []interface{}{
+				map[string]interface{}{"ProductName": "Coffee Beans", "Quantity": 2, "UnitPrice": 12.25, "TotalPrice": 24.5},
+			}},
+			{Name: "Subtotal", Type: TypeNumber},
+			{Name: "TaxAmount", Type: TypeNumber},
+			{Name: "ShippingAmount", Type: TypeNumber},
+			{Name: "TotalAmount", Type: TypeNumber, Required: true, Example: 24.5},
+			{Name: "Payments", Type: TypeList, Example: []interface{}{
+				map[string]interface{}{"Method": "e_wallet", "Amount": 10, "Status": "paid", "Installment": 0},
+				map[string]interface{}{"Method": "credit_card", "Amount": 14.5, "Status": "paid", "Installment": 0},
+			}},
+			{Name: "AmountPaid", Type: TypeNumber, Example: 24.5},
+			{Name: "BalanceDue", Type: TypeNumber, Example: 0},
+			{Name: "CustomerEmail", Type: TypeString},
+			{Name: "Note", Type: TypeString, Example: "Please leave it with the security desk"},
+			{Name: "GiftWrap", Type: TypeBoolean, Example: true},
+			{Name: "GiftWrapFee", Type: TypeNumber, Example: 1.5},
+			{Name: "GiftMessage", Type: TypeString, Example: "Happy birthday, Sari!"},
+		},
+	},
+	"receipt": {
+		Subject: `{{t "email.receipt.subject"}}`,
+		Variables: []Variable{
+			{Name: "CustomerName", Type: TypeString, Required: true, Example: "Budi Santoso"},
+			{Name: "ReceiptNumber", Type: TypeString, Required: true, Example: "RCP-01JGZ8Q4M5N6P7R8S9T0V1W2X3"},
+			{Name: "OrderNumber", Type: TypeString, Required: true, Example: "ORD-2026-000123"},
+			{Name: "Date", Type: TypeString, Required: true, Example: "January 2, 2026"},
+			{Name: "Amount", Type: TypeNumber, Required: true, Example: 24.5},
+			{Name: "Method", Type: TypeString, Required: true, Example: "e_wallet"},
+			{Name: "Installment", Type: TypeNumber, Example: 0},
+			{Name: "TransactionID", Type: TypeString, Example: "b3f1c2d4-5e6f-7a8b-9c0d-1e2f3a4b5c6d"},
+			{Name: "Outstanding", Type: TypeNumber, Example: 0},
+		},
+	},
+	"draft_order": {
+		Subject: `{{t "email.draft_order.subject"}}`,
+		Variables: []Variable{
+			{Name: "CustomerName", Type: TypeString, Example: "Budi Santoso"},
+			{Name: "Items", Type: TypeList, Required: true, Example: []interface{}{
+				map[string]interface{}{"ProductName": "Coffee Beans", "Quantity": 2, "UnitPrice": 12.25, "TotalPrice": 24.5},
+			}},
+			{Name: "ShippingAmount", Type: TypeNumber, Example: 2},
+			{Name: "TotalAmount", Type: TypeNumber, Required: true, Example: 26.5},
+			{Name: "ExpiresAt", Type: TypeString, Required: true, Example: "January 9, 2026"},
+			{Name: "Link", Type: TypeString, Required: true, Example: "https://shop.example.com/pay?token=example"},
+		},
+	},
+	"draft_order_page": {
+		Subject: `{{t "email.draft_order_page.heading"}}`,
+		Variables: []Variable{
+			{Name: "Items", Type: TypeList, Required: true, Example: []interface{}{
+				map[string]interface{}{"ProductName": "Coffee Beans", "Quantity": 2, "UnitPrice": 12.25, "TotalPrice": 24.5},
+			}},
+			{Name: "Subtotal", Type: TypeNumber, Required: true, Example: 24.5},
+			{Name: "ShippingAmount", Type: TypeNumber, Example: 2},
+			{Name: "GiftWrap", Type: TypeBoolean, Example: false},
+			{Name: "GiftWrapFee", Type: TypeNumber, Example: 0},
+			{Name: "GiftMessage", Type: TypeString},
+			{Name: "Note", Type: TypeString},
+			{Name: "TotalAmount", Type: TypeNumber, Required: true, Example: 26.5},
+			{Name: "ExpiresAt", Type: TypeString, Required: true, Example: "January 9, 2026"},
+			{Name: "PayURL", Type: TypeString, Example: "/api/v1/draft-orders/example/pay"},
+		},
+	},
+	"password_reset": {
+		Subject: `{{t "email.password_reset.subject"}}`,
+		Variables: []Variable{
+			{Name: "FirstName", Type: TypeString, Required: true, Example: "Budi"},
+			{Name: "ResetLink", Type: TypeString, Required: true, Example: "https://shop.example.com/reset?token=example"},
+		},
+	},
+	GenericName: {
+		Subject: `{{.Subject}}`,
+		Variables: []Variable{
+			{Name: "Subject", Type: TypeString, Required: true, Example: "A message from Online Shop"},
+			{Name: "Message", Type: TypeString, Required: true, Example: "Hello from the Online Shop team."},
+		},
+	},
+}
+
+// Default returns the embedded template for name. Its version is 0 and it
+// has no locale, since the text is translated when it is rendered.
+func Default(name string) (*Template, bool) {
+	d, ok := defaults[name]
+	if !ok {
+		return nil, false
+	}
+	body, err := defaultBodies.ReadFile("defaults/" + name + ".html")
+	if err != nil {
+		return nil, false
+	}
+
+	t := d
+	t.Name = name
+	t.Body = string(body)
+	t.Variables = append([]Variable(nil), d.Variables...)
+	return &t, true
+}
+
+// Resolve returns a stored version of a template, or for version 0 the
+// active version, falling back to the embedded default.
+func Resolve(ctx context.Context, repo Repository, name, locale string, version int) (*Template, error) {
+	if version > 0 {
+		return repo.GetVersion(ctx, name, locale, version)
+	}
+
+	t, err := repo.Active(ctx, name, locale)
+	if err == ErrNotFound {
+		if d, ok := Default(name); ok {
+			return d, nil
+		}
+	}
+	return t, err
+}
+
+// Defaults lists the names of the embedded templates.
+func Defaults() []string {
+	names := make([]string, 0, len(defaults))
+	for name := range defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,362 @@
+// Package emailtemplate holds the email templates admins edit. Every save is
+// a new version of a template's name and locale, and the active version is
+// what the email worker sends. Names without a stored version use the
+// defaults embedded in the binary.
+package emailtemplate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"text/template/parse"
+	"time"
+
+	"online-shop/pkg/i18n"
+
+	"online-shop/pkg/id"
+)
+
+// Variable types
+const (
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	TypeList    = "list"
+)
+
+var (
+	ErrNotFound = errors.New("email template not found")
+	// ErrInvalid rejects a template that does not parse or declares bad
+	// variables, and ErrDataMismatch data a template's variables do not
+	// accept; the reason is in the error's message
+	ErrInvalid      = errors.New("invalid email template")
+	ErrDataMismatch = errors.New("email data does not match the template's variables")
+	// ErrQueueUnavailable answers a test send when no email queue is connected
+	ErrQueueUnavailable = errors.New("email queue unavailable")
+)
+
+// reasonError is a kind of error, such as ErrInvalid, with the reason for
+// this occurrence
+type reasonError struct {
+	kind   error
+	reason string
+}
+
+func (e *reasonError) Error() string { return e.reason }
+func (e *reasonError) Unwrap() error { return e.kind }
+
+func invalid(format string, args ...interface{}) error {
+	return &reasonError{kind: ErrInvalid, reason: fmt.Sprintf(format, args...)}
+}
+
+var (
+	namePattern     = regexp.MustCompile(`^[a-z0-9][a-z0-9_]{0,63}$`)
+	variablePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Variable is one value a template expects in the email's data.
+type Variable struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Required bool   `json:"required"`
+	// Example fills the variable in previews that leave it out
+	Example interface{} `json:"example,omitempty"`
+}
+
+// Template is one version of an email. Subject is a text template and Body
+// an HTML template; both can call t to render an i18n key in the email's
+// locale. Version 0 is an embedded default that was never stored.
+type Template struct {
+	ID        string     `json:"id,omitempty" gorm:"primaryKey"`
+	Name      string     `json:"name" gorm:"uniqueIndex:idx_email_template_version"`
+	Locale    string     `json:"locale" gorm:"uniqueIndex:idx_email_template_version"`
+	Version   int        `json:"version" gorm:"uniqueIndex:idx_email_template_version"`
+	Subject   string     `json:"subject"`
+	Body      string     `json:"body"`
+	Variables []Variable `json:"variables" gorm:"serializer:json"`
+	Active    bool       `json:"active" gorm:"index"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (Template) TableName() string {
+	return "email_templates"
+}
+
+type Repository interface {
+	// Create stores a new version and makes it the active one
+	Create(ctx context.Context, t *Template) error
+	Active(ctx context.Context, name, locale string) (*Template, error)
+	GetVersion(ctx context.Context, name, locale string, version int) (*Template, error)
+	// Versions lists every version of a template, newest first
+	Versions(ctx context.Context, name, locale string) ([]*Template, error)
+	// Activate makes an earlier version the active one again
+	Activate(ctx context.Context, name, locale string, version int) error
+	// List returns the active version of every stored template
+	List(ctx context.Context, limit, offset int) ([]*Template, error)
+	// Delete removes every version, so the embedded default is used again
+	Delete(ctx context.Context, name, locale string) error
+}
+
+// Cache holds the active version of templates for the email worker. A
+// cached nil template records that nothing is stored, so sending a default
+// does not query the database every time.
+type Cache interface {
+	Get(ctx context.Context, name, locale string) (t *Template, found bool, err error)
+	Set(ctx context.Context, name, locale string, t *Template, ttl time.Duration) error
+	Invalidate(ctx context.Context, name, locale string) error
+}
+
+// Publisher queues a test send of a template for the email worker.
+type Publisher interface {
+	SendTest(ctx context.Context, to string, t *Template, locale string, data map[string]interface{}) error
+}
+
+// UnavailablePublisher takes the email queue's place when it cannot be
+// reached, so an admin asking for a test send is told it was not sent.
+type UnavailablePublisher struct{}
+
+func (UnavailablePublisher) SendTest(ctx context.Context, to string, t *Template, locale string, data map[string]interface{}) error {
+	return ErrQueueUnavailable
+}
+
+// NewTemplate builds the next version of a template. It is not active until
+// it is stored.
+func NewTemplate(name, locale string, version int, subject, body string, variables []Variable, createdBy string) (*Template, error) {
+	t := &Template{
+		ID:        id.New(),
+		Name:      name,
+		Locale:    locale,
+		Version:   version,
+		Subject:   subject,
+		Body:      body,
+		Variables: variables,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Validate checks the template parses and only uses declared variables.
+func (t *Template) Validate() error {
+	if !namePattern.MatchString(t.Name) {
+		return invalid("template name must be lowercase letters, digits or _")
+	}
+	if !i18n.Supported(t.Locale) {
+		return invalid("locale %q is not supported", t.Locale)
+	}
+	if strings.TrimSpace(t.Subject) == "" || strings.TrimSpace(t.Body) == "" {
+		return invalid("subject and body are required")
+	}
+
+	declared := make(map[string]bool, len(t.Variables))
+	for _, v := range t.Variables {
+		if !variablePattern.MatchString(v.Name) {
+			return invalid("variable name %q is not a valid identifier", v.Name)
+		}
+		if declared[v.Name] {
+			return invalid("variable %q is declared twice", v.Name)
+		}
+		switch v.Type {
+		case TypeString, TypeNumber, TypeBoolean, TypeList:
+		default:
+			return invalid("variable %q has unknown type %q", v.Name, v.Type)
+		}
+		if v.Example != nil && !v.accepts(v.Example) {
+			return invalid("example of %q must be a %s", v.Name, v.Type)
+		}
+		declared[v.Name] = true
+	}
+
+	subject, body, err := t.parse(i18n.DefaultLocale, nil)
+	if err != nil {
+		return invalid("%v", err)
+	}
+	used := map[string]bool{}
+	references(subject.Tree.Root, true, used)
+	for _, tmpl := range body.Templates() {
+		if tmpl.Tree != nil {
+			references(tmpl.Tree.Root, true, used)
+		}
+	}
+	var undeclared []string
+	for name := range used {
+		if !declared[name] {
+			undeclared = append(undeclared, name)
+		}
+	}
+	if len(undeclared) > 0 {
+		sort.Strings(undeclared)
+		return invalid("template uses undeclared variables: %s", strings.Join(undeclared, ", "))
+	}
+	return nil
+}
+
+// CheckData reports the first variable that data is missing or has the
+// wrong type for.
+func (t *Template) CheckData(data map[string]interface{}) error {
+	for _, v := range t.Variables {
+		value, ok := data[v.Name]
+		if !ok || value == nil {
+			if v.Required {
+				return &reasonError{kind: ErrDataMismatch, reason: v.Name + " is required"}
+			}
+			continue
+		}
+		if !v.accepts(value) {
+			return &reasonError{kind: ErrDataMismatch, reason: fmt.Sprintf("%s must be a %s", v.Name, v.Type)}
+		}
+	}
+	return nil
+}
+
+// SampleData is data for previews: the variables' examples, overridden by
+// the values given.
+func (t *Template) SampleData(data map[string]interface{}) map[string]interface{} {
+	sample := make(map[string]interface{}, len(t.Variables)+len(data))
+	for _, v := range t.Variables {
+		if v.Example != nil {
+			sample[v.Name] = v.Example
+		}
+	}
+	for key, value := range data {
+		sample[key] = value
+	}
+	return sample
+}
+
+// Render fills in the subject and body in locale.
+func (t *Template) Render(locale string, data map[string]interface{}) (subject, body string, err error) {
+	subjectTmpl, bodyTmpl, err := t.parse(locale, data)
+	if err != nil {
+		return "", "", invalid("%v", err)
+	}
+
+	var buf strings.Builder
+	if err := subjectTmpl.Execute(&buf, data); err != nil {
+		return "", "", invalid("failed to render subject: %v", err)
+	}
+	subject = strings.TrimSpace(buf.String())
+
+	buf.Reset()
+	if err := bodyTmpl.Execute(&buf, data); err != nil {
+		return "", "", invalid("failed to render body: %v", err)
+	}
+	return subject, buf.String(), nil
+}
+
+func (t *Template) parse(locale string, data map[string]interface{}) (*texttemplate.Template, *template.Template, error) {
+	funcs := Funcs(locale, data)
+	subject, err := texttemplate.New("subject").Funcs(texttemplate.FuncMap(funcs)).Parse(t.Subject)
+	if err != nil {
+		return nil, nil, fmt.Errorf("subject: %w", err)
+	}
+	body, err := template.New(t.Name).Funcs(funcs).Parse(t.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("body: %w", err)
+	}
+	return subject, body, nil
+}
+
+// Funcs are the functions templates can call. t renders an i18n key in the
+// email's locale with the email's data filled in.
+func Funcs(locale string, data map[string]interface{}) template.FuncMap {
+	args := Args(data)
+	return template.FuncMap{
+		"t": func(key string) string {
+			return i18n.T(locale, key, args...)
+		},
+	}
+}
+
+// Args turns the scalar values of email data into i18n arguments.
+func Args(data map[string]interface{}) []string {
+	args := make([]string, 0, 2*len(data))
+	for key, value := range data {
+		switch value.(type) {
+		case string, bool, int, int32, int64, float32, float64:
+			args = append(args, key, fmt.Sprint(value))
+		}
+	}
+	return args
+}
+
+func (v Variable) accepts(value interface{}) bool {
+	switch v.Type {
+	case TypeString:
+		_, ok := value.(string)
+		return ok
+	case TypeBoolean:
+		_, ok := value.(bool)
+		return ok
+	case TypeNumber:
+		switch reflect.ValueOf(value).Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			return true
+		}
+	case TypeList:
+		kind := reflect.ValueOf(value).Kind()
+		return kind == reflect.Slice || kind == reflect.Array
+	}
+	return false
+}
+
+// references collects the top-level data keys a template reads. Inside
+// range and with the dot is an element, so only $.Key counts there.
+func references(node parse.Node, rootDot bool, used map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			references(child, rootDot, used)
+		}
+	case *parse.ActionNode:
+		references(n.Pipe, rootDot, used)
+	case *parse.TemplateNode:
+		references(n.Pipe, rootDot, used)
+	case *parse.IfNode:
+		references(n.Pipe, rootDot, used)
+		references(n.List, rootDot, used)
+		references(n.ElseList, rootDot, used)
+	case *parse.RangeNode:
+		references(n.Pipe, rootDot, used)
+		references(n.List, false, used)
+		references(n.ElseList, rootDot, used)
+	case *parse.WithNode:
+		references(n.Pipe, rootDot, used)
+		references(n.List, false, used)
+		references(n.ElseList, rootDot, used)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			for _, arg := range cmd.Args {
+				references(arg, rootDot, used)
+			}
+		}
+	case *parse.ChainNode:
+		references(n.Node, rootDot, used)
+	case *parse.FieldNode:
+		if rootDot {
+			used[n.Ident[0]] = true
+		}
+	case *parse.VariableNode:
+		if n.Ident[0] == "$" && len(n.Ident) > 1 {
+			used[n.Ident[1]] = true
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package experiment
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math"
+	"regexp"
+	"time"
+
+	"online-shop/internal/domain/analytics"
+
+	"online-shop/pkg/id"
+)
+
+const (
+	StatusDraft     = "draft"
+	StatusRunning   = "running"
+	StatusPaused    = "paused"
+	StatusCompleted = "completed"
+)
+
+// buckets is the resolution of traffic allocation, so traffic can be set in
+// hundredths of a percent
+const buckets = 10000
+
+var keyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+var ErrInvalidTransition = errors.New("experiment status cannot change that way")
+
+// Variant is one arm of an experiment. Weights are relative, so 1/1 and
+// 50/50 split traffic the same way.
+type Variant struct {
+	Key    string `json:"key"`
+	Weight int    `json:"weight"`
+}
+
+// Experiment splits visitors between variants. The first variant is the
+// control the others are compared with in reports.
+type Experiment struct {
+	ID          string    `json:"id" gorm:"primaryKey"`
+	Key         string    `json:"key" gorm:"uniqueIndex"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Status      string    `json:"status" gorm:"index"`
+	Variants    []Variant `json:"variants" gorm:"serializer:json"`
+	// TrafficPercent is the share of visitors enrolled; the rest see the
+	// default experience and are not reported
+	TrafficPercent float64 `json:"traffic_percent"`
+	// ConversionEvent is the analytics event that counts as a conversion,
+	// EventConversion by default or e.g. product_purchased
+	ConversionEvent string     `json:"conversion_event"`
+	StartedAt       *time.Time `json:"started_at,omitempty"`
+	EndedAt         *time.Time `json:"ended_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// Assignment is the variant a visitor sees in one experiment.
+type Assignment struct {
+	Experiment string `json:"experiment"`
+	Variant    string `json:"variant"`
+}
+
+type Repository interface {
+	Create(ctx context.Context, e *Experiment) error
+	GetByID(ctx context.Context, id string) (*Experiment, error)
+	GetByKey(ctx context.Context, key string) (*Experiment, error)
+	Update(ctx context.Context, e *Experiment) error
+	List(ctx context.Context, status string, limit, offset int) ([]*Experiment, error)
+}
+
+// VariantCounts is how many distinct visitors were exposed to a variant and
+// how many of them converted afterwards.
+type VariantCounts struct {
+	Variant   string
+	Exposed   int64
+	Converted int64
+}
+
+// ReportRepository counts exposures and conversions in the event store.
+type ReportRepository interface {
+	VariantCounts(ctx context.Context, e *Experiment, rng analytics.ReportRange) ([]VariantCounts, error)
+}
+
+func NewExperiment(key, name, description string, variants []Variant, trafficPercent float64, conversionEvent string) (*Experiment, error) {
+	if conversionEvent == "" {
+		conversionEvent = analytics.EventExperimentConversion
+	}
+	e := &Experiment{
+		ID:              id.New(),
+		Key:             key,
+		Name:            name,
+		Description:     description,
+		Status:          StatusDraft,
+		Variants:        variants,
+		TrafficPercent:  trafficPercent,
+		ConversionEvent: conversionEvent,
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
+	}
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Validate checks the experiment can assign visitors.
+func (e *Experiment) Validate() error {
+	if !keyPattern.MatchString(e.Key) {
+		return errors.New("experiment key must be lowercase letters, digits, - or _")
+	}
+	if e.Name == "" {
+		return errors.New("experiment name is required")
+	}
+	if len(e.Variants) < 2 {
+		return errors.New("an experiment needs at least two variants")
+	}
+	seen := make(map[string]bool, len(e.Variants))
+	for _, v := range e.Variants {
+		if v.Key == "" || seen[v.Key] {
+			return errors.New("variant keys must be unique and not empty")
+		}
+		if v.Weight <= 0 {
+			return errors.New("variant weights must be positive")
+		}
+		seen[v.Key] = true
+	}
+	if e.TrafficPercent <= 0 || e.TrafficPercent > 100 {
+		return errors.New("traffic percent must be between 0 and 100")
+	}
+	return nil
+}
+
+// Start runs a draft or paused experiment.
+func (e *Experiment) Start(at time.Time) error {
+	if e.Status != StatusDraft && e.Status != StatusPaused {
+		return ErrInvalidTransition
+	}
+	if e.StartedAt == nil {
+		e.StartedAt = &at
+	}
+	e.Status = StatusRunning
+	e.UpdatedAt = at
+	return nil
+}
+
+// Pause stops assigning visitors until the experiment is started again.
+func (e *Experiment) Pause(at time.Time) error {
+	if e.Status != StatusRunning {
+		return ErrInvalidTransition
+	}
+	e.Status = StatusPaused
+	e.UpdatedAt = at
+	return nil
+}
+
+// Complete ends the experiment for good.
+func (e *Experiment) Complete(at time.Time) error {
+	if e.Status != StatusRunning && e.Status != StatusPaused {
+		return ErrInvalidTransition
+	}
+	e.Status = StatusCompleted
+	e.EndedAt = &at
+	e.UpdatedAt = at
+	return nil
+}
+
+// Control is the variant the others are measured against.
+func (e *Experiment) Control() string {
+	if len(e.Variants) == 0 {
+		return ""
+	}
+	return e.Variants[0].Key
+}
+
+// Assign picks the variant for a visitor. The same visitor always gets the
+// same variant of an experiment, on every instance and across restarts, as
+// long as its variants and weights are unchanged. Visitors outside the
+// enrolled traffic, and every visitor while the experiment is not running,
+// get no assignment.
+func (e *Experiment) Assign(unit string) (string, bool) {
+	if e.Status != StatusRunning || unit == "" || len(e.Variants) == 0 {
+		return "", false
+	}
+
+	// Enrolment and variant use independent hashes so changing the traffic
+	// share does not move enrolled visitors between variants
+	if float64(hash(e.Key, "traffic", unit)%buckets) >= e.TrafficPercent*buckets/100 {
+		return "", false
+	}
+
+	total := 0
+	for _, v := range e.Variants {
+		total += v.Weight
+	}
+	point := int(hash(e.Key, "variant", unit) % uint64(total))
+	for _, v := range e.Variants {
+		if point < v.Weight {
+			return v.Key, true
+		}
+		point -= v.Weight
+	}
+	return e.Variants[len(e.Variants)-1].Key, true
+}
+
+func hash(parts ...string) uint64 {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return binary.BigEndian.Uint64(h.Sum(nil)[:8])
+}
+
+// Unit identifies a visitor for assignment: the user when signed in,
+// otherwise the anonymous session. A visitor who signs in mid-experiment may
+// therefore switch variant once.
+func Unit(userID, sessionID string) string {
+	if userID != "" {
+		return "user:" + userID
+	}
+	if sessionID != "" {
+		return "session:" + sessionID
+	}
+	return ""
+}
+
+// VariantResult is a variant's line in an experiment report.
+type VariantResult struct {
+	Variant        string  `json:"variant"`
+	Exposed        int64   `json:"exposed"`
+	Converted      int64   `json:"converted"`
+	ConversionRate float64 `json:"conversion_rate"`
+	// Lift is the relative change in conversion rate over the control
+	Lift float64 `json:"lift"`
+	// ZScore of a two-proportion test against the control; beyond ±1.96 the
+	// difference is significant at the 95% level
+	ZScore      float64 `json:"z_score"`
+	Significant bool    `json:"significant"`
+}
+
+type Report struct {
+	Experiment *Experiment     `json:"experiment"`
+	From       time.Time       `json:"from"`
+	To         time.Time       `json:"to"`
+	Variants   []VariantResult `json:"variants"`
+}
+
+// BuildReport compares every variant with the control. Variants nobody was
+// exposed to are listed with zero counts.
+func BuildReport(e *Experiment, rng analytics.ReportRange, counts []VariantCounts) *Report {
+	byVariant := make(map[string]VariantCounts, len(counts))
+	for _, c := range counts {
+		byVariant[c.Variant] = c
+	}
+
+	report := &Report{Experiment: e, From: rng.From, To: rng.To}
+	control := byVariant[e.Control()]
+	for _, v := range e.Variants {
+		c := byVariant[v.Key]
+		result := VariantResult{
+			Variant:        v.Key,
+			Exposed:        c.Exposed,
+			Converted:      c.Converted,
+			ConversionRate: rate(c.Converted, c.Exposed),
+		}
+		if v.Key != e.Control() {
+			base := rate(control.Converted, control.Exposed)
+			if base > 0 {
+				result.Lift = (result.ConversionRate - base) / base
+			}
+			result.ZScore = zScore(control, c)
+			result.Significant = math.Abs(result.ZScore) >= 1.96
+		}
+		report.Variants = append(report.Variants, result)
+	}
+	return report
+}
+
+func rate(part, whole int64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) / float64(whole)
+}
+
+func zScore(control, variant VariantCounts) float64 {
+	if control.Exposed == 0 || variant.Exposed == 0 {
+		return 0
+	}
+	pooled := rate(control.Converted+variant.Converted, control.Exposed+variant.Exposed)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/float64(control.Exposed) + 1/float64(variant.Exposed)))
+	if se == 0 {
+		return 0
+	}
+	return (rate(variant.Converted, variant.Exposed) - rate(control.Converted, control.Exposed)) / se
+}
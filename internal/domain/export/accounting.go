@@ -0,0 +1,293 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// EntryType is what an accounting entry records.
+type EntryType string
+
+const (
+	// EntrySale is an order placed, booked on the day it was placed
+	EntrySale EntryType = "sale"
+	// EntryPayment is money received for an order, on the day it was paid
+	EntryPayment EntryType = "payment"
+	// EntryRefund is money paid back of a payment, on the day it was
+	// refunded
+	EntryRefund EntryType = "refund"
+)
+
+// Entry is one line of an accounting export. Amounts include tax: orders
+// charge none on top of their prices, so the tax in them is worked out
+// from the shop's tax rate.
+type Entry struct {
+	Type          EntryType
+	Date          time.Time
+	OrderID       string
+	OrderNumber   string
+	InvoiceNumber string
+	CustomerName  string
+	CustomerEmail string
+	// Method and TransactionID are those of the payment, for payments and
+	// refunds
+	Method        string
+	TransactionID string
+	Description   string
+	Status        string
+	Amount        float64
+	Currency      string
+}
+
+// Column is one column of a layout: Header over the entries' Field, or
+// over Value for every entry when no field is given, such as the account
+// code an accounting app books sales to.
+type Column struct {
+	Header string
+	Field  string
+	Value  string
+}
+
+// Layout is how an accounting app imports entries: which of them, in
+// which columns, and how dates and cells are written.
+type Layout struct {
+	Name string
+	// Entries are the types exported; empty exports every type
+	Entries []EntryType
+	// Delimiter separates CSV cells; zero for a comma
+	Delimiter  rune
+	DateFormat string
+	Columns    []Column
+}
+
+// Fields are those a layout's columns can take from an entry.
+var Fields = []string{
+	"type", "date", "reference", "order_id", "order_number", "invoice_number",
+	"customer_name", "customer_email", "description", "method", "transaction_id",
+	"status", "net_amount", "tax_amount", "amount", "currency",
+}
+
+// DefaultLayout is used when an accounting export names none.
+const DefaultLayout = "default"
+
+// Layouts are those built in: every entry with every field, and sales laid
+// out for the sales invoice imports of Xero and of Accurate Online.
+// Accounts and tax codes differ from one set of books to another, so
+// shops override these in the configuration to match theirs.
+func Layouts() map[string]Layout {
+	all := make([]Column, len(Fields))
+	for i, field := range Fields {
+		all[i] = Column{Header: field, Field: field}
+	}
+	return map[string]Layout{
+		DefaultLayout: {Name: DefaultLayout, DateFormat: time.RFC3339, Columns: all},
+		"xero": {
+			Name:       "xero",
+			Entries:    []EntryType{EntrySale},
+			DateFormat: "02/01/2006",
+			Columns: []Column{
+				{Header: "ContactName", Field: "customer_name"},
+				{Header: "EmailAddress", Field: "customer_email"},
+				{Header: "InvoiceNumber", Field: "invoice_number"},
+				{Header: "Reference", Field: "order_number"},
+				{Header: "InvoiceDate", Field: "date"},
+				{Header: "DueDate", Field: "date"},
+				{Header: "Description", Field: "description"},
+				{Header: "Quantity", Value: "1"},
+				{Header: "UnitAmount", Field: "amount"},
+				{Header: "AccountCode", Value: "200"},
+				{Header: "TaxAmount", Field: "tax_amount"},
+				{Header: "Currency", Field: "currency"},
+			},
+		},
+		"accurate": {
+			Name:       "accurate",
+			Entries:    []EntryType{EntrySale},
+			DateFormat: "02/01/2006",
+			Columns: []Column{
+				{Header: "No. Faktur", Field: "invoice_number"},
+				{Header: "Tanggal", Field: "date"},
+				{Header: "Pelanggan", Field: "customer_name"},
+				{Header: "Email", Field: "customer_email"},
+				{Header: "Keterangan", Field: "description"},
+				{Header: "DPP", Field: "net_amount"},
+				{Header: "PPN", Field: "tax_amount"},
+				{Header: "Total", Field: "amount"},
+				{Header: "Mata Uang", Field: "currency"},
+			},
+		},
+	}
+}
+
+// Accounting is how the shop's accounting exports are written: the tax
+// rate included in its prices, the currency entries without one are in,
+// where their dates fall and the layouts they can be laid out in.
+type Accounting struct {
+	TaxRate  float64
+	Currency string
+	Location *time.Location
+	Layouts  map[string]Layout
+}
+
+// NewAccounting returns the built-in layouts with custom ones added, or
+// replacing those of the same name. Custom layouts are checked, so a
+// misconfigured one stops the service from starting rather than failing
+// every export that uses it.
+func NewAccounting(taxRate float64, currency string, location *time.Location, custom []Layout) (Accounting, error) {
+	if taxRate < 0 || taxRate >= 1 {
+		return Accounting{}, errors.New("tax rate must be at least 0 and below 1")
+	}
+	layouts := Layouts()
+	for _, l := range custom {
+		if err := l.validate(); err != nil {
+			return Accounting{}, err
+		}
+		layouts[l.Name] = l
+	}
+	return Accounting{TaxRate: taxRate, Currency: currency, Location: location, Layouts: layouts}, nil
+}
+
+// Layout returns the layout called name, or the default one for an empty
+// name.
+func (a Accounting) Layout(name string) (Layout, error) {
+	if name == "" {
+		name = DefaultLayout
+	}
+	l, ok := a.Layouts[name]
+	if !ok {
+		return Layout{}, fmt.Errorf("unknown accounting layout: %s", name)
+	}
+	return l, nil
+}
+
+func (l Layout) validate() error {
+	if l.Name == "" {
+		return errors.New("accounting layout needs a name")
+	}
+	if len(l.Columns) == 0 {
+		return fmt.Errorf("accounting layout %s has no columns", l.Name)
+	}
+	if l.Delimiter == '"' || l.Delimiter == '\r' || l.Delimiter == '\n' {
+		return fmt.Errorf("accounting layout %s cannot delimit cells with %q", l.Name, l.Delimiter)
+	}
+	known := make(map[string]bool, len(Fields))
+	for _, field := range Fields {
+		known[field] = true
+	}
+	for _, c := range l.Columns {
+		if c.Header == "" {
+			return fmt.Errorf("accounting layout %s has a column without a header", l.Name)
+		}
+		if c.Field != "" && !known[c.Field] {
+			return fmt.Errorf("accounting layout %s has an unknown field: %s", l.Name, c.Field)
+		}
+	}
+	for _, t := range l.Entries {
+		if t != EntrySale && t != EntryPayment && t != EntryRefund {
+			return fmt.Errorf("accounting layout %s has an unknown entry type: %s", l.Name, t)
+		}
+	}
+	return nil
+}
+
+// Table lays the entries of the layout's types out in its columns, oldest
+// first.
+func (a Accounting) Table(l Layout, entries []Entry) *Table {
+	types := make(map[EntryType]bool, len(l.Entries))
+	for _, t := range l.Entries {
+		types[t] = true
+	}
+	var kept []Entry
+	for _, e := range entries {
+		if len(types) == 0 || types[e.Type] {
+			kept = append(kept, e)
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].Date.Before(kept[j].Date) })
+
+	dateFormat := l.DateFormat
+	if dateFormat == "" {
+		dateFormat = "2006-01-02"
+	}
+	table := &Table{Delimiter: l.Delimiter, Header: make([]string, len(l.Columns))}
+	for i, c := range l.Columns {
+		table.Header[i] = c.Header
+	}
+	for _, e := range kept {
+		row := make([]string, len(l.Columns))
+		for i, c := range l.Columns {
+			if c.Field == "" {
+				row[i] = c.Value
+				continue
+			}
+			row[i] = a.field(e, c.Field, dateFormat)
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return table
+}
+
+func (a Accounting) field(e Entry, field, dateFormat string) string {
+	switch field {
+	case "type":
+		return string(e.Type)
+	case "date":
+		if a.Location != nil {
+			return e.Date.In(a.Location).Format(dateFormat)
+		}
+		return e.Date.Format(dateFormat)
+	case "reference":
+		if e.Type == EntrySale {
+			return e.InvoiceNumber
+		}
+		return e.TransactionID
+	case "order_id":
+		return e.OrderID
+	case "order_number":
+		return e.OrderNumber
+	case "invoice_number":
+		return e.InvoiceNumber
+	case "customer_name":
+		return e.CustomerName
+	case "customer_email":
+		return e.CustomerEmail
+	case "description":
+		return e.Description
+	case "method":
+		return e.Method
+	case "transaction_id":
+		return e.TransactionID
+	case "status":
+		return e.Status
+	case "net_amount":
+		return formatAmount(e.Amount - a.Tax(e))
+	case "tax_amount":
+		return formatAmount(a.Tax(e))
+	case "amount":
+		return formatAmount(e.Amount)
+	case "currency":
+		if e.Currency == "" {
+			return a.Currency
+		}
+		return e.Currency
+	}
+	return ""
+}
+
+// Tax is the tax included in an entry's amount, to the cent. Sales and
+// the refunds that reverse them carry tax; a payment only settles a sale,
+// so it carries none of its own.
+func (a Accounting) Tax(e Entry) float64 {
+	if e.Type == EntryPayment || a.TaxRate == 0 {
+		return 0
+	}
+	return math.Round(e.Amount*a.TaxRate/(1+a.TaxRate)*100) / 100
+}
+
+func formatAmount(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
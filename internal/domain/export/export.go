@@ -0,0 +1,181 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"online-shop/pkg/id"
+)
+
+type Type string
+
+const (
+	TypeOrders    Type = "orders"
+	TypeProducts  Type = "products"
+	TypeCustomers Type = "customers"
+	// TypeReorderSuggestions are the suggestions of the last inventory
+	// forecast; the range does not apply to them
+	TypeReorderSuggestions Type = "reorder_suggestions"
+	// TypeAccounting are the sales, payments and refunds of a period, laid
+	// out for an accounting app to import
+	TypeAccounting Type = "accounting"
+
+	// TypePersonalData is a customer's own data portability export
+	TypePersonalData Type = "personal_data"
+)
+
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatXLSX Format = "xlsx"
+	FormatJSON Format = "json"
+	FormatZIP  Format = "zip"
+)
+
+type Status string
+
+const (
+	StatusPending    Status = "pending"
+	StatusProcessing Status = "processing"
+	StatusCompleted  Status = "completed"
+	StatusFailed     Status = "failed"
+)
+
+// Export is an admin-requested report file, or a customer's personal data
+// archive. From and To bound report rows by creation time; zero values leave
+// that side open. Layout names the accounting layout of accounting exports.
+type Export struct {
+	ID          string     `json:"id" gorm:"primaryKey"`
+	RequestedBy string     `json:"requested_by" gorm:"index"`
+	Type        Type       `json:"type"`
+	Format      Format     `json:"format"`
+	From        time.Time  `json:"from" gorm:"column:range_from"`
+	To          time.Time  `json:"to" gorm:"column:range_to"`
+	Layout      string     `json:"layout,omitempty"`
+	Status      Status     `json:"status"`
+	ObjectKey   string     `json:"-"`
+	RowCount    int        `json:"row_count"`
+	SizeBytes   int64      `json:"size_bytes"`
+	Error       string     `json:"error,omitempty"`
+	DownloadURL string     `json:"download_url,omitempty" gorm:"-"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+// Table is the tabular content of an export before encoding.
+type Table struct {
+	Header []string
+	Rows   [][]string
+	// Delimiter separates the cells of a CSV file; zero for a comma
+	Delimiter rune
+}
+
+type Repository interface {
+	Create(ctx context.Context, export *Export) error
+	GetByID(ctx context.Context, id string) (*Export, error)
+	Update(ctx context.Context, export *Export) error
+	ListByRequester(ctx context.Context, userID string, limit, offset int) ([]*Export, error)
+}
+
+// Source loads the rows of an export from the primary database.
+type Source interface {
+	Load(ctx context.Context, export *Export) (*Table, error)
+}
+
+// Encoder writes a table in one file format.
+type Encoder interface {
+	ContentType() string
+	Extension() string
+	Encode(w io.Writer, table *Table) error
+}
+
+// Storage keeps generated files and hands out time-limited download links.
+type Storage interface {
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	SignedURL(key string, ttl time.Duration) (string, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Publisher hands an export to the background workers.
+type Publisher interface {
+	Enqueue(ctx context.Context, exportID string) error
+}
+
+// Notifier tells the requester that an export finished, successfully or not.
+type Notifier interface {
+	ExportFinished(ctx context.Context, export *Export, downloadURL string) error
+}
+
+func NewExport(requestedBy string, exportType Type, format Format, from, to time.Time) (*Export, error) {
+	if requestedBy == "" {
+		return nil, errors.New("requester is required")
+	}
+
+	switch exportType {
+	case TypeOrders, TypeProducts, TypeCustomers, TypeReorderSuggestions:
+		if format != FormatCSV && format != FormatXLSX {
+			return nil, errors.New("unsupported export format")
+		}
+	case TypeAccounting:
+		if format != FormatCSV && format != FormatXLSX {
+			return nil, errors.New("unsupported export format")
+		}
+		if from.IsZero() || to.IsZero() {
+			return nil, errors.New("accounting exports cover a period, from and to")
+		}
+	case TypePersonalData:
+		if format != FormatJSON && format != FormatZIP {
+			return nil, errors.New("unsupported export format")
+		}
+	default:
+		return nil, errors.New("unsupported export type")
+	}
+
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return nil, errors.New("export range must end after it starts")
+	}
+
+	return &Export{
+		ID:          id.New(),
+		RequestedBy: requestedBy,
+		Type:        exportType,
+		Format:      format,
+		From:        from,
+		To:          to,
+		Status:      StatusPending,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}, nil
+}
+
+func (e *Export) Start() {
+	e.Status = StatusProcessing
+	e.Error = ""
+	e.UpdatedAt = time.Now()
+}
+
+func (e *Export) Complete(objectKey string, rows int, size int64) {
+	now := time.Now()
+	e.Status = StatusCompleted
+	e.ObjectKey = objectKey
+	e.RowCount = rows
+	e.SizeBytes = size
+	e.CompletedAt = &now
+	e.UpdatedAt = now
+}
+
+func (e *Export) Fail(err error) {
+	now := time.Now()
+	e.Status = StatusFailed
+	e.Error = err.Error()
+	e.CompletedAt = &now
+	e.UpdatedAt = now
+}
+
+func (e *Export) IsFinished() bool {
+	return e.Status == StatusCompleted || e.Status == StatusFailed
+}
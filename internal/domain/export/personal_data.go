@@ -0,0 +1,79 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"online-shop/internal/domain/analytics"
+	"online-shop/internal/domain/oauth"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/user"
+)
+
+// ErrQueueUnavailable fails a personal data export request while there is
+// no export queue, rather than leave it pending
+var ErrQueueUnavailable = errors.New("export queue unavailable")
+
+// PersonalData is everything the shop keeps about one customer, compiled for
+// a data portability request. Addresses and reviews have no domain model, so
+// their rows are exported column by column.
+type PersonalData struct {
+	UserID          string                   `json:"user_id"`
+	GeneratedAt     time.Time                `json:"generated_at"`
+	Profile         *user.User               `json:"profile"`
+	Addresses       []map[string]interface{} `json:"addresses"`
+	Orders          []*order.Order           `json:"orders"`
+	Reviews         []map[string]interface{} `json:"reviews"`
+	LinkedAccounts  []*oauth.Account         `json:"linked_accounts"`
+	AnalyticsEvents []analytics.Event        `json:"analytics_events"`
+}
+
+// Section is one file of a personal data archive.
+type Section struct {
+	Name    string
+	Records int
+	Data    interface{}
+}
+
+// Sections lists the parts of the archive in the order they are written.
+func (d *PersonalData) Sections() []Section {
+	return []Section{
+		{Name: "profile", Records: 1, Data: d.Profile},
+		{Name: "addresses", Records: len(d.Addresses), Data: d.Addresses},
+		{Name: "orders", Records: len(d.Orders), Data: d.Orders},
+		{Name: "reviews", Records: len(d.Reviews), Data: d.Reviews},
+		{Name: "linked_accounts", Records: len(d.LinkedAccounts), Data: d.LinkedAccounts},
+		{Name: "analytics_events", Records: len(d.AnalyticsEvents), Data: d.AnalyticsEvents},
+	}
+}
+
+// Records is the total number of records across all sections.
+func (d *PersonalData) Records() int {
+	total := 0
+	for _, s := range d.Sections() {
+		total += s.Records
+	}
+	return total
+}
+
+// PersonalDataSource compiles the personal data of one user.
+type PersonalDataSource interface {
+	LoadPersonalData(ctx context.Context, userID string) (*PersonalData, error)
+}
+
+// Archiver writes personal data in one archive format.
+type Archiver interface {
+	ContentType() string
+	Extension() string
+	Archive(w io.Writer, data *PersonalData) error
+}
+
+// UnavailablePublisher is wired in while RabbitMQ is down: a customer asking
+// for their data is told at once, rather than wait on an export never made.
+type UnavailablePublisher struct{}
+
+func (UnavailablePublisher) Enqueue(ctx context.Context, exportID string) error {
+	return ErrQueueUnavailable
+}
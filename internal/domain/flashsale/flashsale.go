@@ -0,0 +1,253 @@
+// Package flashsale runs limited-time deals: a sale discounts a list of
+// products for a fixed window, each with its own allocation and an optional
+// per-customer limit. Allocations are counted in Redis at checkout so bursts
+// of orders cannot sell more than was put on sale.
+package flashsale
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"online-shop/internal/domain/product"
+
+	"online-shop/pkg/id"
+)
+
+var (
+	ErrSaleNotFound = errors.New("flash sale not found")
+	// ErrInvalidSale is returned for a sale with bad timing, products or prices
+	ErrInvalidSale = errors.New("invalid flash sale")
+	// ErrSoldOut and ErrLimitReached are returned by Counter.Reserve
+	ErrSoldOut      = errors.New("flash sale item is sold out")
+	ErrLimitReached = errors.New("flash sale purchase limit reached")
+)
+
+// maxItems caps the products of a single sale.
+const maxItems = 200
+
+type Status string
+
+const (
+	StatusUpcoming Status = "upcoming"
+	StatusLive     Status = "live"
+	StatusEnded    Status = "ended"
+)
+
+// Sale is a limited-time deal on a list of products.
+type Sale struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name"`
+	StartsAt  time.Time `json:"starts_at" gorm:"index"`
+	EndsAt    time.Time `json:"ends_at" gorm:"index"`
+	Active    bool      `json:"active"`
+	Items     []Item    `json:"items" gorm:"foreignKey:SaleID;constraint:OnDelete:CASCADE"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Countdown is set when the sale is served
+	Countdown *Countdown `json:"countdown,omitempty" gorm:"-"`
+}
+
+func (Sale) TableName() string {
+	return "flash_sales"
+}
+
+// Item is a product on sale. Quantity is how many units are sold at the sale
+// price; PerUserLimit caps what one customer can buy, 0 for no cap.
+type Item struct {
+	ID           string  `json:"id" gorm:"primaryKey"`
+	SaleID       string  `json:"sale_id" gorm:"uniqueIndex:idx_flash_sale_item"`
+	ProductID    string  `json:"product_id" gorm:"uniqueIndex:idx_flash_sale_item;index"`
+	SalePrice    float64 `json:"sale_price"`
+	Quantity     int     `json:"quantity"`
+	PerUserLimit int     `json:"per_user_limit"`
+	// Sold and Product are set when the item is served
+	Sold    int              `json:"sold" gorm:"-"`
+	Product *product.Product `json:"product,omitempty" gorm:"-"`
+}
+
+func (Item) TableName() string {
+	return "flash_sale_items"
+}
+
+// Remaining is how many units are left at the sale price.
+func (i *Item) Remaining() int {
+	if i.Sold >= i.Quantity {
+		return 0
+	}
+	return i.Quantity - i.Sold
+}
+
+// Countdown tells clients how long until a sale starts or ends.
+type Countdown struct {
+	Status         Status `json:"status"`
+	SecondsToStart int64  `json:"seconds_to_start"`
+	SecondsLeft    int64  `json:"seconds_left"`
+}
+
+func NewSale(name string, startsAt, endsAt time.Time, items []Item) (*Sale, error) {
+	s := &Sale{
+		ID:       id.New(),
+		Name:     name,
+		StartsAt: startsAt,
+		EndsAt:   endsAt,
+		Active:   true,
+	}
+	if err := s.SetItems(items); err != nil {
+		return nil, err
+	}
+	if err := s.Check(); err != nil {
+		return nil, err
+	}
+	s.CreatedAt = time.Now()
+	s.UpdatedAt = s.CreatedAt
+	return s, nil
+}
+
+// SetItems replaces the products on sale.
+func (s *Sale) SetItems(items []Item) error {
+	if len(items) == 0 || len(items) > maxItems {
+		return fmt.Errorf("%w: a sale needs between 1 and %d products", ErrInvalidSale, maxItems)
+	}
+	seen := make(map[string]bool, len(items))
+	for i := range items {
+		item := &items[i]
+		if seen[item.ProductID] {
+			return fmt.Errorf("%w: product %s is listed twice", ErrInvalidSale, item.ProductID)
+		}
+		seen[item.ProductID] = true
+		if item.SalePrice <= 0 || item.Quantity <= 0 || item.PerUserLimit < 0 {
+			return fmt.Errorf("%w: product %s needs a positive price and quantity", ErrInvalidSale, item.ProductID)
+		}
+		item.ID = id.New()
+		item.SaleID = s.ID
+	}
+	s.Items = items
+	return nil
+}
+
+// Check validates a sale after it is created or edited.
+func (s *Sale) Check() error {
+	if s.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidSale)
+	}
+	if !s.EndsAt.After(s.StartsAt) {
+		return fmt.Errorf("%w: must end after it starts", ErrInvalidSale)
+	}
+	return nil
+}
+
+// CheckPrices makes sure every item is discounted from its product's price.
+func (s *Sale) CheckPrices(products []*product.Product) error {
+	prices := make(map[string]float64, len(products))
+	for _, p := range products {
+		prices[p.ID] = p.Price
+	}
+	for _, item := range s.Items {
+		price, ok := prices[item.ProductID]
+		if !ok {
+			return fmt.Errorf("%w: product %s does not exist", ErrInvalidSale, item.ProductID)
+		}
+		if item.SalePrice >= price {
+			return fmt.Errorf("%w: the sale price of product %s must be below %.2f", ErrInvalidSale, item.ProductID, price)
+		}
+	}
+	return nil
+}
+
+// Live reports whether the sale is on at the given time.
+func (s *Sale) Live(at time.Time) bool {
+	return s.Active && !at.Before(s.StartsAt) && at.Before(s.EndsAt)
+}
+
+func (s *Sale) CountdownAt(at time.Time) *Countdown {
+	c := &Countdown{Status: StatusLive}
+	switch {
+	case at.Before(s.StartsAt):
+		c.Status = StatusUpcoming
+		c.SecondsToStart = int64(math.Ceil(s.StartsAt.Sub(at).Seconds()))
+		c.SecondsLeft = int64(math.Ceil(s.EndsAt.Sub(at).Seconds()))
+	case at.Before(s.EndsAt):
+		c.SecondsLeft = int64(math.Ceil(s.EndsAt.Sub(at).Seconds()))
+	default:
+		c.Status = StatusEnded
+	}
+	return c
+}
+
+// Item returns the sale's item for a product, or nil.
+func (s *Sale) Item(productID string) *Item {
+	for i := range s.Items {
+		if s.Items[i].ProductID == productID {
+			return &s.Items[i]
+		}
+	}
+	return nil
+}
+
+// Offer is the item as shown on its product.
+func (s *Sale) Offer(item *Item, originalPrice float64, at time.Time) *product.FlashSaleOffer {
+	offer := &product.FlashSaleOffer{
+		SaleID:        s.ID,
+		Name:          s.Name,
+		Price:         item.SalePrice,
+		OriginalPrice: originalPrice,
+		Remaining:     item.Remaining(),
+		PerUserLimit:  item.PerUserLimit,
+		EndsAt:        s.EndsAt,
+		SecondsLeft:   s.CountdownAt(at).SecondsLeft,
+	}
+	if originalPrice > 0 {
+		offer.DiscountPercent = int(math.Round((1 - item.SalePrice/originalPrice) * 100))
+	}
+	return offer
+}
+
+// Best picks the cheapest live item for a product across sales that
+// overlap, or nil when the product is not on sale.
+func Best(sales []*Sale, productID string, at time.Time) (*Sale, *Item) {
+	var bestSale *Sale
+	var best *Item
+	for _, s := range sales {
+		if !s.Live(at) {
+			continue
+		}
+		item := s.Item(productID)
+		if item != nil && (best == nil || item.SalePrice < best.SalePrice) {
+			bestSale, best = s, item
+		}
+	}
+	return bestSale, best
+}
+
+type Repository interface {
+	// Create saves the sale with its items
+	Create(ctx context.Context, s *Sale) error
+	GetByID(ctx context.Context, id string) (*Sale, error)
+	// Update saves the sale and replaces its items
+	Update(ctx context.Context, s *Sale) error
+	Delete(ctx context.Context, id string) error
+	List(ctx context.Context, limit, offset int) ([]*Sale, error)
+	// ListCurrent returns the active sales that have not ended, soonest
+	// first, with all their items
+	ListCurrent(ctx context.Context, at time.Time, limit int) ([]*Sale, error)
+	// ListLive returns the sales live at the given time with only their
+	// items for the given products
+	ListLive(ctx context.Context, productIDs []string, at time.Time) ([]*Sale, error)
+}
+
+// Counter keeps the units sold per sale item and per customer. Reserve
+// must check and count atomically across every API instance.
+type Counter interface {
+	// Reserve counts quantity against the item's allocation and the user's
+	// limit, or returns ErrSoldOut or ErrLimitReached without counting.
+	// Counts are kept a while after until.
+	Reserve(ctx context.Context, item *Item, userID string, quantity int, until time.Time) error
+	// Release gives back what Reserve counted, as when an order fails or
+	// is cancelled. Only the item's SaleID and ProductID are used
+	Release(ctx context.Context, item *Item, userID string, quantity int) error
+	// Sold sets Sold on each item
+	Sold(ctx context.Context, items []*Item) error
+}
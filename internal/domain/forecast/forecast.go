@@ -0,0 +1,221 @@
+// Package forecast projects when products run out of stock in each
+// warehouse from how fast they sell, and suggests what to reorder. The
+// forecast job works the suggestions out from the sales recorded in the
+// analytics store and replaces the stored ones with them each run.
+package forecast
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"online-shop/internal/domain/analytics"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/warehouse"
+)
+
+// JobName is the scheduler job working out the forecasts
+const JobName = "inventory-forecast"
+
+type Status string
+
+const (
+	// StatusOK has enough stock to last beyond the reorder point
+	StatusOK Status = "ok"
+	// StatusReorder is at or below the reorder point and should be
+	// reordered now to arrive before it runs out
+	StatusReorder Status = "reorder"
+	// StatusOutOfStock is selling with nothing left to sell
+	StatusOutOfStock Status = "out_of_stock"
+)
+
+// Policy is how stock is planned. Sales velocity is taken over the last
+// LookbackDays; a reorder takes LeadTimeDays to arrive, SafetyDays of sales
+// are kept in reserve for it, and it should last CoverDays once in.
+type Policy struct {
+	LookbackDays int
+	LeadTimeDays int
+	SafetyDays   int
+	CoverDays    int
+}
+
+// Suggestion is the forecast of a product in a warehouse. A product stocked
+// in no warehouse is forecast from its own stock, with an empty WarehouseID.
+type Suggestion struct {
+	ProductID   string `json:"product_id" gorm:"primaryKey"`
+	WarehouseID string `json:"warehouse_id" gorm:"primaryKey"`
+	// OnHand is the stock available to sell, reservations aside
+	OnHand        int     `json:"on_hand"`
+	UnitsSold     float64 `json:"units_sold"`
+	DailyVelocity float64 `json:"daily_velocity"`
+	// DaysOfCover and StockoutAt are nil for products that are not selling
+	DaysOfCover     *float64   `json:"days_of_cover,omitempty"`
+	StockoutAt      *time.Time `json:"stockout_at,omitempty"`
+	ReorderPoint    int        `json:"reorder_point"`
+	ReorderQuantity int        `json:"reorder_quantity"`
+	Status          Status     `json:"status" gorm:"index"`
+	ComputedAt      time.Time  `json:"computed_at"`
+}
+
+func (Suggestion) TableName() string {
+	return "reorder_suggestions"
+}
+
+// Filter selects suggestions; empty fields match every suggestion, and a
+// Limit of 0 returns them all.
+type Filter struct {
+	WarehouseID string
+	ProductID   string
+	Status      Status
+	Limit       int
+	Offset      int
+}
+
+type Repository interface {
+	// Replace swaps the stored suggestions for the given ones
+	Replace(ctx context.Context, suggestions []*Suggestion) error
+	// List returns the suggestions, soonest stock-out first
+	List(ctx context.Context, filter Filter) ([]*Suggestion, int64, error)
+}
+
+// Normalize fills in the defaults: 28 days of sales, a week of lead time and
+// of safety stock, and reorders lasting 30 days.
+func (p Policy) Normalize() Policy {
+	if p.LookbackDays <= 0 {
+		p.LookbackDays = 28
+	}
+	if p.LeadTimeDays <= 0 {
+		p.LeadTimeDays = 7
+	}
+	if p.SafetyDays <= 0 {
+		p.SafetyDays = 7
+	}
+	if p.CoverDays <= 0 {
+		p.CoverDays = 30
+	}
+	return p
+}
+
+// Project forecasts onHand units selling unitsSold over the policy's
+// lookback, as of the given time. The reorder point is the sales of the lead
+// time and safety days; at or below it the suggestion is to order enough to
+// cover the lead time and the cover days.
+func Project(productID, warehouseID string, onHand int, unitsSold float64, policy Policy, at time.Time) *Suggestion {
+	policy = policy.Normalize()
+	s := &Suggestion{
+		ProductID:     productID,
+		WarehouseID:   warehouseID,
+		OnHand:        onHand,
+		UnitsSold:     unitsSold,
+		DailyVelocity: unitsSold / float64(policy.LookbackDays),
+		Status:        StatusOK,
+		ComputedAt:    at,
+	}
+	if s.DailyVelocity <= 0 {
+		return s
+	}
+
+	cover := 0.0
+	if onHand > 0 {
+		cover = float64(onHand) / s.DailyVelocity
+	}
+	stockout := at.Add(time.Duration(cover * float64(24*time.Hour)))
+	s.DaysOfCover = &cover
+	s.StockoutAt = &stockout
+
+	s.ReorderPoint = int(math.Ceil(s.DailyVelocity * float64(policy.LeadTimeDays+policy.SafetyDays)))
+	if onHand > s.ReorderPoint {
+		return s
+	}
+	target := int(math.Ceil(s.DailyVelocity * float64(policy.LeadTimeDays+policy.CoverDays)))
+	available := onHand
+	if available < 0 {
+		available = 0
+	}
+	if target > available {
+		s.ReorderQuantity = target - available
+	}
+	s.Status = StatusReorder
+	if onHand <= 0 {
+		s.Status = StatusOutOfStock
+	}
+	return s
+}
+
+// ForecastProduct forecasts a product in each warehouse that stocks it or
+// sold it. Sales recorded without a warehouse are shared among the
+// warehouses stocking it in proportion to their stock, or evenly when none
+// is left; a warehouse that sold it without stocking it has none on hand.
+// Pairs neither stocked nor sold are left out, and so are bundles, which
+// are sold from their components.
+func ForecastProduct(p *product.Product, stocks []*warehouse.Stock, sold []analytics.ProductUnits, policy Policy, at time.Time) []*Suggestion {
+	if p.IsBundle() {
+		return nil
+	}
+
+	var order []string
+	onHand := make(map[string]int)
+	units := make(map[string]float64)
+	stocked := make(map[string]bool)
+	for _, s := range stocks {
+		if s.ProductID != p.ID || stocked[s.WarehouseID] {
+			continue
+		}
+		stocked[s.WarehouseID] = true
+		order = append(order, s.WarehouseID)
+		onHand[s.WarehouseID] = s.Available()
+	}
+
+	unattributed := 0.0
+	for _, u := range sold {
+		if u.ProductID != p.ID {
+			continue
+		}
+		if len(stocked) == 0 || u.WarehouseID == "" {
+			unattributed += float64(u.Units)
+			continue
+		}
+		if _, ok := onHand[u.WarehouseID]; !ok {
+			order = append(order, u.WarehouseID)
+			onHand[u.WarehouseID] = 0
+		}
+		units[u.WarehouseID] += float64(u.Units)
+	}
+
+	if len(stocked) == 0 {
+		if p.Stock <= 0 && unattributed == 0 {
+			return nil
+		}
+		return []*Suggestion{Project(p.ID, "", p.Stock, unattributed, policy, at)}
+	}
+
+	if unattributed > 0 {
+		total := 0
+		for _, warehouseID := range order {
+			if stocked[warehouseID] && onHand[warehouseID] > 0 {
+				total += onHand[warehouseID]
+			}
+		}
+		for _, warehouseID := range order {
+			if !stocked[warehouseID] {
+				continue
+			}
+			if total > 0 {
+				if onHand[warehouseID] > 0 {
+					units[warehouseID] += unattributed * float64(onHand[warehouseID]) / float64(total)
+				}
+			} else {
+				units[warehouseID] += unattributed / float64(len(stocked))
+			}
+		}
+	}
+
+	var suggestions []*Suggestion
+	for _, warehouseID := range order {
+		if onHand[warehouseID] <= 0 && units[warehouseID] == 0 {
+			continue
+		}
+		suggestions = append(suggestions, Project(p.ID, warehouseID, onHand[warehouseID], units[warehouseID], policy, at))
+	}
+	return suggestions
+}
@@ -0,0 +1,263 @@
+package fraud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"online-shop/pkg/id"
+)
+
+var (
+	ErrNotFound = errors.New("fraud assessment not found")
+	// ErrAlreadyReviewed is returned when an order is approved or declined
+	// after its review is over
+	ErrAlreadyReviewed = errors.New("order is not awaiting fraud review")
+)
+
+type Level string
+
+const (
+	LevelLow    Level = "low"
+	LevelMedium Level = "medium"
+	LevelHigh   Level = "high"
+)
+
+type Status string
+
+const (
+	// StatusCleared orders scored below the review threshold and went ahead
+	StatusCleared       Status = "cleared"
+	StatusPendingReview Status = "pending_review"
+	StatusApproved      Status = "approved"
+	StatusDeclined      Status = "declined"
+)
+
+// Rule names the check that added to a score
+type Rule string
+
+const (
+	RuleVelocity        Rule = "velocity"
+	RuleAddressMismatch Rule = "address_mismatch"
+	RuleHighValueFirst  Rule = "high_value_first_order"
+	RuleDisposableEmail Rule = "disposable_email"
+)
+
+// Points each check adds to a score
+const (
+	MaxScore              = 100
+	mediumScore           = 30
+	velocityPoints        = 35
+	countryMismatchPoints = 30
+	postalMismatchPoints  = 15
+	highValueFirstPoints  = 35
+	disposableEmailPoints = 30
+)
+
+type Reason struct {
+	Rule   Rule   `json:"rule"`
+	Points int    `json:"points"`
+	Detail string `json:"detail"`
+}
+
+// Assessment is the risk score an order got at checkout and, for a risky
+// order, the outcome of its manual review.
+type Assessment struct {
+	ID         string     `json:"id" gorm:"primaryKey"`
+	OrderID    string     `json:"order_id" gorm:"uniqueIndex"`
+	UserID     string     `json:"user_id" gorm:"index"`
+	Amount     float64    `json:"amount"`
+	Score      int        `json:"score"`
+	Level      Level      `json:"level"`
+	Reasons    []Reason   `json:"reasons" gorm:"serializer:json"`
+	Status     Status     `json:"status" gorm:"index"`
+	ReviewedBy string     `json:"reviewed_by,omitempty"`
+	ReviewNote string     `json:"review_note,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"index"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func (Assessment) TableName() string {
+	return "fraud_assessments"
+}
+
+type Repository interface {
+	Create(ctx context.Context, a *Assessment) error
+	GetByOrderID(ctx context.Context, orderID string) (*Assessment, error)
+	Update(ctx context.Context, a *Assessment) error
+	// List returns assessments in the given state, oldest first so the
+	// review queue is worked in order; an empty status lists all, newest
+	// first
+	List(ctx context.Context, status Status, limit, offset int) ([]*Assessment, error)
+}
+
+// Rules are the thresholds the checks score against. Orders scoring
+// ReviewScore or more are held for review.
+type Rules struct {
+	ReviewScore       int
+	VelocityWindow    time.Duration
+	VelocityLimit     int
+	HighValueAmount   float64
+	DisposableDomains []string
+}
+
+// Address is the part of an address the checks compare.
+type Address struct {
+	Country    string
+	PostalCode string
+}
+
+// Checkout is what is known about an order when it is placed.
+type Checkout struct {
+	OrderID  string
+	UserID   string
+	Email    string
+	Amount   float64
+	Shipping Address
+	// Billing is nil when the customer gave no billing address
+	Billing *Address
+	// PreviousOrders are the customer's earlier orders' creation times
+	PreviousOrders []time.Time
+	At             time.Time
+}
+
+// Assess scores a checkout. Each check that fires adds its points; the
+// score is capped at MaxScore.
+func Assess(rules Rules, c Checkout) *Assessment {
+	var reasons []Reason
+
+	if rules.VelocityLimit > 0 && rules.VelocityWindow > 0 {
+		// The order being placed counts towards the limit
+		recent := 1
+		for _, at := range c.PreviousOrders {
+			if c.At.Sub(at) < rules.VelocityWindow {
+				recent++
+			}
+		}
+		if recent > rules.VelocityLimit {
+			reasons = append(reasons, Reason{RuleVelocity, velocityPoints,
+				fmt.Sprintf("%d orders within %s", recent, rules.VelocityWindow)})
+		}
+	}
+
+	if b := c.Billing; b != nil {
+		switch {
+		case !strings.EqualFold(b.Country, c.Shipping.Country):
+			reasons = append(reasons, Reason{RuleAddressMismatch, countryMismatchPoints,
+				fmt.Sprintf("billing country %s, shipping country %s", strings.ToUpper(b.Country), strings.ToUpper(c.Shipping.Country))})
+		case normalizePostalCode(b.PostalCode) != normalizePostalCode(c.Shipping.PostalCode):
+			reasons = append(reasons, Reason{RuleAddressMismatch, postalMismatchPoints,
+				"billing and shipping postal codes differ"})
+		}
+	}
+
+	if len(c.PreviousOrders) == 0 && rules.HighValueAmount > 0 && c.Amount >= rules.HighValueAmount {
+		reasons = append(reasons, Reason{RuleHighValueFirst, highValueFirstPoints,
+			fmt.Sprintf("first order of %.2f", c.Amount)})
+	}
+
+	domain, ok := disposableDomain(c.Email, DisposableDomains)
+	if !ok {
+		domain, ok = disposableDomain(c.Email, rules.DisposableDomains)
+	}
+	if ok {
+		reasons = append(reasons, Reason{RuleDisposableEmail, disposableEmailPoints,
+			"email at disposable domain " + domain})
+	}
+
+	score := 0
+	for _, r := range reasons {
+		score += r.Points
+	}
+	if score > MaxScore {
+		score = MaxScore
+	}
+
+	now := time.Now()
+	a := &Assessment{
+		ID:        id.New(),
+		OrderID:   c.OrderID,
+		UserID:    c.UserID,
+		Amount:    c.Amount,
+		Score:     score,
+		Reasons:   reasons,
+		Status:    StatusCleared,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	switch {
+	case score >= rules.ReviewScore:
+		a.Level = LevelHigh
+		a.Status = StatusPendingReview
+	case score >= mediumScore:
+		a.Level = LevelMedium
+	default:
+		a.Level = LevelLow
+	}
+	return a
+}
+
+func (a *Assessment) NeedsReview() bool {
+	return a.Status == StatusPendingReview
+}
+
+func (a *Assessment) Approve(reviewerID, note string) error {
+	return a.review(StatusApproved, reviewerID, note)
+}
+
+func (a *Assessment) Decline(reviewerID, note string) error {
+	return a.review(StatusDeclined, reviewerID, note)
+}
+
+func (a *Assessment) review(status Status, reviewerID, note string) error {
+	if !a.NeedsReview() {
+		return ErrAlreadyReviewed
+	}
+	now := time.Now()
+	a.Status = status
+	a.ReviewedBy = reviewerID
+	a.ReviewNote = strings.TrimSpace(note)
+	a.ReviewedAt = &now
+	a.UpdatedAt = now
+	return nil
+}
+
+// disposableDomain reports whether the email is at one of the domains, or
+// a subdomain of one.
+func disposableDomain(email string, domains []string) (string, bool) {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return "", false
+	}
+	host := strings.ToLower(strings.TrimSpace(email[at+1:]))
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if d != "" && (host == d || strings.HasSuffix(host, "."+d)) {
+			return d, true
+		}
+	}
+	return "", false
+}
+
+func normalizePostalCode(code string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+}
+
+// DisposableDomains are well-known throwaway email providers, checked on
+// top of any configured.
+var DisposableDomains = []string{
+	"10minutemail.com",
+	"dispostable.com",
+	"getnada.com",
+	"guerrillamail.com",
+	"mailinator.com",
+	"maildrop.cc",
+	"sharklasers.com",
+	"temp-mail.org",
+	"throwawaymail.com",
+	"trashmail.com",
+	"yopmail.com",
+}
@@ -0,0 +1,103 @@
+package fulfillment
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"online-shop/internal/domain/order"
+)
+
+var (
+	// ErrNoLabelPrinter is returned when a shipment is shipped without a
+	// carrier and tracking number and there is no label printer to get
+	// them from
+	ErrNoLabelPrinter = errors.New("no label printer is configured; give the carrier and tracking number")
+	ErrLabelFailed    = errors.New("shipping label could not be printed")
+)
+
+// PickList is what warehouse staff collect from the shelves: the total of
+// each product across its shipments, and what goes into each shipment.
+type PickList struct {
+	WarehouseID string         `json:"warehouse_id"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	Lines       []PickLine     `json:"lines"`
+	Shipments   []PickShipment `json:"shipments"`
+}
+
+type PickLine struct {
+	ProductID string `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+}
+
+// PickShipment is what is packed into one shipment, with the order's gift
+// options and note, so the packer can wrap it and put in the gift message.
+type PickShipment struct {
+	ShipmentID  string     `json:"shipment_id"`
+	OrderID     string     `json:"order_id"`
+	OrderNumber string     `json:"order_number"`
+	Items       []PickLine `json:"items"`
+	GiftWrap    bool       `json:"gift_wrap,omitempty"`
+	GiftMessage string     `json:"gift_message,omitempty"`
+	Note        string     `json:"note,omitempty"`
+}
+
+// BuildPickList lists the shipments of orders that are being picked in the
+// warehouse, in the order given, with product totals sorted by product.
+func BuildPickList(warehouseID string, orders []*order.Order, at time.Time) *PickList {
+	list := &PickList{WarehouseID: warehouseID, GeneratedAt: at, Lines: []PickLine{}, Shipments: []PickShipment{}}
+	totals := make(map[string]int)
+
+	for _, o := range orders {
+		for _, s := range o.Shipments {
+			if s.WarehouseID != warehouseID || s.Status != order.ShipmentStatusPicking {
+				continue
+			}
+			shipment := PickShipment{
+				ShipmentID:  s.ID,
+				OrderID:     o.ID,
+				OrderNumber: o.Number,
+				GiftWrap:    o.Gift.Wrap,
+				GiftMessage: o.Gift.Message,
+				Note:        o.Note,
+			}
+			for _, item := range o.ShipmentItems(s.ID) {
+				shipment.Items = append(shipment.Items, PickLine{ProductID: item.ProductID, Quantity: item.Quantity})
+				totals[item.ProductID] += item.Quantity
+			}
+			list.Shipments = append(list.Shipments, shipment)
+		}
+	}
+
+	for productID, quantity := range totals {
+		list.Lines = append(list.Lines, PickLine{ProductID: productID, Quantity: quantity})
+	}
+	sort.Slice(list.Lines, func(i, j int) bool { return list.Lines[i].ProductID < list.Lines[j].ProductID })
+	return list
+}
+
+// LabelRequest describes a packed shipment to a label printer.
+type LabelRequest struct {
+	ShipmentID  string        `json:"shipment_id"`
+	OrderID     string        `json:"order_id"`
+	OrderNumber string        `json:"order_number"`
+	WarehouseID string        `json:"warehouse_id"`
+	ShipTo      order.Address `json:"ship_to"`
+	Parcel      order.Parcel  `json:"parcel"`
+	// CODAmount is the cash the courier collects on delivery, if any
+	CODAmount float64 `json:"cod_amount,omitempty"`
+}
+
+// Label is a printed shipping label and the tracking it comes with.
+type Label struct {
+	Carrier        string `json:"carrier"`
+	TrackingNumber string `json:"tracking_number"`
+	URL            string `json:"url"`
+}
+
+// LabelPrinter buys a label from a carrier or label service for a packed
+// shipment.
+type LabelPrinter interface {
+	Print(ctx context.Context, req LabelRequest) (*Label, error)
+}
@@ -0,0 +1,72 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var (
+	ErrKeyInProgress = errors.New("a request with this idempotency key is still being processed")
+	ErrKeyMismatch   = errors.New("idempotency key was already used with a different request")
+)
+
+// Record is the stored outcome of the first request made with an
+// idempotency key. Records without a StatusCode are still in progress.
+type Record struct {
+	Key         string    `json:"key" gorm:"primaryKey"`
+	RequestHash string    `json:"request_hash"`
+	StatusCode  int       `json:"status_code"`
+	Body        []byte    `json:"body"`
+	ContentType string    `json:"content_type"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at" gorm:"index"`
+}
+
+func (Record) TableName() string {
+	return "idempotency_keys"
+}
+
+type Store interface {
+	// Reserve claims the key for a new request. When the key is already
+	// taken the existing record is returned and nothing is stored.
+	Reserve(ctx context.Context, record *Record, ttl time.Duration) (*Record, error)
+	Complete(ctx context.Context, record *Record, ttl time.Duration) error
+	Release(ctx context.Context, key string) error
+}
+
+func NewRecord(scope, key string, request []byte, ttl time.Duration) *Record {
+	return &Record{
+		Key:         scope + ":" + key,
+		RequestHash: Hash(request),
+		CreatedAt:   time.Now(),
+		ExpiresAt:   time.Now().Add(ttl),
+	}
+}
+
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (r *Record) IsCompleted() bool {
+	return r.StatusCode != 0
+}
+
+func (r *Record) IsExpired() bool {
+	return time.Now().After(r.ExpiresAt)
+}
+
+// Check decides what to do with a duplicate request: replay the stored
+// response, or reject it because it differs or is still in flight.
+func (r *Record) Check(requestHash string) error {
+	if r.RequestHash != requestHash {
+		return ErrKeyMismatch
+	}
+	if !r.IsCompleted() {
+		return ErrKeyInProgress
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package impersonation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"online-shop/internal/domain/user"
+	"online-shop/pkg/id"
+)
+
+var (
+	ErrNotFound = errors.New("impersonation not found")
+	// ErrNotAllowed refuses impersonating anyone but an active customer, or
+	// without a reason, a known scope or a positive duration
+	ErrNotAllowed = errors.New("user cannot be impersonated")
+	// ErrInactive is returned for an impersonation that has ended or expired
+	ErrInactive = errors.New("impersonation has ended")
+)
+
+type Scope string
+
+const (
+	// ScopeRead lets the admin see what the customer sees, and change nothing
+	ScopeRead Scope = "read"
+	// ScopeWrite also lets the admin act for the customer, short of the
+	// account's security settings and moving money
+	ScopeWrite Scope = "write"
+)
+
+// MinReasonLength keeps the reason recorded for an impersonation meaningful,
+// such as a ticket reference with a few words
+const MinReasonLength = 10
+
+// Grant is a support admin's permission to act as a customer for a while.
+// Every request made with it is recorded in the audit log under the admin.
+type Grant struct {
+	ID         string     `json:"id" gorm:"primaryKey"`
+	AdminID    string     `json:"admin_id" gorm:"index"`
+	CustomerID string     `json:"customer_id" gorm:"index"`
+	Reason     string     `json:"reason"`
+	Scope      Scope      `json:"scope"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"index"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	EndedAt    *time.Time `json:"ended_at,omitempty"`
+	EndedBy    string     `json:"ended_by,omitempty"`
+}
+
+func (Grant) TableName() string {
+	return "impersonation_grants"
+}
+
+// NewGrant lets an admin act as a customer until ttl has passed. Admins
+// cannot impersonate themselves, other staff or closed accounts.
+func NewGrant(adminID string, customer *user.User, reason string, scope Scope, ttl time.Duration, now time.Time) (*Grant, error) {
+	reason = strings.TrimSpace(reason)
+	switch {
+	case adminID == customer.ID:
+		return nil, fmt.Errorf("%w: admins cannot impersonate themselves", ErrNotAllowed)
+	case customer.Role != user.RoleCustomer:
+		return nil, fmt.Errorf("%w: only customers can be impersonated", ErrNotAllowed)
+	case !customer.IsActive():
+		return nil, fmt.Errorf("%w: the account is %s", ErrNotAllowed, customer.Status)
+	case len(reason) < MinReasonLength:
+		return nil, fmt.Errorf("%w: a reason of at least %d characters is required", ErrNotAllowed, MinReasonLength)
+	case scope != ScopeRead && scope != ScopeWrite:
+		return nil, fmt.Errorf("%w: unknown scope %q", ErrNotAllowed, scope)
+	case ttl <= 0:
+		return nil, fmt.Errorf("%w: the duration must be positive", ErrNotAllowed)
+	}
+
+	return &Grant{
+		ID:         id.New(),
+		AdminID:    adminID,
+		CustomerID: customer.ID,
+		Reason:     reason,
+		Scope:      scope,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(ttl),
+	}, nil
+}
+
+// Active reports whether requests can still be made with the grant
+func (g *Grant) Active(now time.Time) bool {
+	return g.EndedAt == nil && now.Before(g.ExpiresAt)
+}
+
+// End stops the grant before it expires, so its token is refused from now on
+func (g *Grant) End(by string, now time.Time) error {
+	if !g.Active(now) {
+		return ErrInactive
+	}
+	g.EndedAt = &now
+	g.EndedBy = by
+	return nil
+}
+
+// ReadOnly grants refuse requests that change anything
+func (g *Grant) ReadOnly() bool {
+	return g.Scope != ScopeWrite
+}
+
+type Filter struct {
+	AdminID    string
+	CustomerID string
+	// Active lists only the grants that have neither ended nor expired
+	Active bool
+}
+
+type Repository interface {
+	Create(ctx context.Context, grant *Grant) error
+	GetByID(ctx context.Context, id string) (*Grant, error)
+	Update(ctx context.Context, grant *Grant) error
+	List(ctx context.Context, filter Filter, limit, offset int) ([]*Grant, int64, error)
+}
@@ -0,0 +1,165 @@
+// Package job records the background work the workers take off the queues,
+// so admins can see what ran, what failed and why, and retry or cancel it.
+// A job is keyed by its queue message's ID: deliveries of the same message
+// are attempts of one job.
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("job not found")
+	// Only failed or cancelled jobs can be retried, and only pending ones
+	// cancelled; the errors name the state the job is in
+	ErrNotRetryable   = errors.New("job cannot be retried")
+	ErrNotCancellable = errors.New("job cannot be cancelled")
+	// ErrQueueUnavailable leaves a retried job unqueued while the queue is down
+	ErrQueueUnavailable = errors.New("job queue unavailable")
+)
+
+type State string
+
+const (
+	// StatePending jobs wait on their queue, for the first delivery or a
+	// retry after a failed attempt
+	StatePending   State = "pending"
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	// StateFailed jobs ran out of attempts; they can be retried by hand
+	StateFailed State = "failed"
+	// StateCancelled jobs are acknowledged without running when delivered
+	StateCancelled State = "cancelled"
+)
+
+// Job is one queue message and what became of it. Payload is the message's
+// payload as queued, so a retry runs the same work.
+type Job struct {
+	ID      string                 `json:"id" gorm:"primaryKey"`
+	Queue   string                 `json:"queue" gorm:"index"`
+	Type    string                 `json:"type" gorm:"index"`
+	Payload map[string]interface{} `json:"payload" gorm:"serializer:json"`
+	State   State                  `json:"state" gorm:"index"`
+	// Attempts counts every delivery the workers ran, including the ones
+	// before a retry
+	Attempts    int    `json:"attempts"`
+	MaxAttempts int    `json:"max_attempts"`
+	Error       string `json:"error,omitempty"`
+	// CancelledBy is the admin who cancelled the job
+	CancelledBy string     `json:"cancelled_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"index"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+func (Job) TableName() string {
+	return "jobs"
+}
+
+// New records a message the first time it is delivered
+func New(id, queue, jobType string, payload map[string]interface{}, maxAttempts int, at time.Time) *Job {
+	return &Job{
+		ID:          id,
+		Queue:       queue,
+		Type:        jobType,
+		Payload:     payload,
+		State:       StatePending,
+		MaxAttempts: maxAttempts,
+		CreatedAt:   at,
+		UpdatedAt:   at,
+	}
+}
+
+// Runnable reports whether a delivery of the job should run. Cancelled jobs
+// are not run, nor are jobs that already succeeded, as when a retried
+// message is delivered twice.
+func (j *Job) Runnable() bool {
+	return j.State != StateCancelled && j.State != StateSucceeded
+}
+
+func (j *Job) Start(at time.Time) {
+	j.State = StateRunning
+	j.Attempts++
+	j.StartedAt = &at
+	j.FinishedAt = nil
+	j.UpdatedAt = at
+}
+
+func (j *Job) Succeed(at time.Time) {
+	j.State = StateSucceeded
+	j.Error = ""
+	j.FinishedAt = &at
+	j.UpdatedAt = at
+}
+
+// Fail records a failed attempt. The job waits for its next delivery unless
+// final, when the queue gives up on the message.
+func (j *Job) Fail(err error, final bool, at time.Time) {
+	j.Error = err.Error()
+	j.UpdatedAt = at
+	if final {
+		j.State = StateFailed
+		j.FinishedAt = &at
+		return
+	}
+	j.State = StatePending
+}
+
+// Retry queues a failed or cancelled job again
+func (j *Job) Retry(at time.Time) error {
+	if j.State != StateFailed && j.State != StateCancelled {
+		return fmt.Errorf("%w: it is %s", ErrNotRetryable, j.State)
+	}
+	j.State = StatePending
+	j.Error = ""
+	j.CancelledBy = ""
+	j.FinishedAt = nil
+	j.UpdatedAt = at
+	return nil
+}
+
+// Cancel stops a pending job from running when it is next delivered. A
+// running job cannot be cancelled: the worker running it is not told.
+func (j *Job) Cancel(by string, at time.Time) error {
+	if j.State != StatePending {
+		return fmt.Errorf("%w: it is %s", ErrNotCancellable, j.State)
+	}
+	j.State = StateCancelled
+	j.CancelledBy = by
+	j.FinishedAt = &at
+	j.UpdatedAt = at
+	return nil
+}
+
+// Filter narrows a job listing; empty fields match all.
+type Filter struct {
+	Queue string
+	Type  string
+	State State
+}
+
+type Repository interface {
+	// Get returns the job or ErrNotFound
+	Get(ctx context.Context, id string) (*Job, error)
+	// Save creates or updates the job
+	Save(ctx context.Context, j *Job) error
+	// List returns a page of jobs, newest first, and how many match
+	List(ctx context.Context, filter Filter, limit, offset int) ([]*Job, int64, error)
+}
+
+// Publisher puts a retried job back on its queue
+type Publisher interface {
+	Requeue(ctx context.Context, j *Job) error
+}
+
+// UnavailablePublisher replaces the queue when it cannot be reached, so an
+// admin retrying a job learns it did not go back on the queue.
+type UnavailablePublisher struct{}
+
+func (UnavailablePublisher) Requeue(ctx context.Context, j *Job) error {
+	return ErrQueueUnavailable
+}
@@ -0,0 +1,149 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"online-shop/pkg/id"
+)
+
+var (
+	ErrWindowNotFound = errors.New("maintenance window not found")
+	// ErrInvalidPeriod is a window that ends before it starts or has ended
+	ErrInvalidPeriod = errors.New("invalid maintenance period")
+)
+
+// State is the system-wide maintenance switch together with the windows
+// scheduled ahead. It is shared by every API instance.
+type State struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message,omitempty"`
+	// EndsAt switches maintenance off by itself; clients are told to retry
+	// then. Without it maintenance lasts until it is turned off.
+	EndsAt    *time.Time `json:"ends_at,omitempty"`
+	Windows   []Window   `json:"windows"`
+	UpdatedBy string     `json:"updated_by,omitempty"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Window is maintenance planned ahead, which starts and ends without anyone
+// switching it.
+type Window struct {
+	ID        string    `json:"id"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	Message   string    `json:"message,omitempty"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Status is whether the API is in maintenance at a moment, and until when
+// if that is known.
+type Status struct {
+	Active  bool       `json:"active"`
+	Message string     `json:"message,omitempty"`
+	Until   *time.Time `json:"until,omitempty"`
+}
+
+// Store holds the state where every API instance reads it. Get returns an
+// empty state when maintenance was never set.
+type Store interface {
+	Get(ctx context.Context) (*State, error)
+	Save(ctx context.Context, state *State) error
+}
+
+// NewWindow schedules maintenance from startsAt to endsAt, which must not
+// have ended yet.
+func NewWindow(startsAt, endsAt time.Time, message, createdBy string, now time.Time) (*Window, error) {
+	if !endsAt.After(startsAt) {
+		return nil, fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidPeriod)
+	}
+	if !endsAt.After(now) {
+		return nil, fmt.Errorf("%w: window has already ended", ErrInvalidPeriod)
+	}
+	return &Window{
+		ID:        id.New(),
+		StartsAt:  startsAt,
+		EndsAt:    endsAt,
+		Message:   message,
+		CreatedBy: createdBy,
+		CreatedAt: now,
+	}, nil
+}
+
+// Switch turns maintenance on, until endsAt when it is given, or off.
+func (s *State) Switch(enabled bool, message string, endsAt *time.Time, by string, now time.Time) error {
+	if !enabled {
+		message, endsAt = "", nil
+	}
+	if endsAt != nil && !endsAt.After(now) {
+		return fmt.Errorf("%w: ends_at has already passed", ErrInvalidPeriod)
+	}
+	s.Enabled = enabled
+	s.Message = message
+	s.EndsAt = endsAt
+	s.UpdatedBy = by
+	s.UpdatedAt = now
+	s.Prune(now)
+	return nil
+}
+
+// Covers reports whether the window is under way at now.
+func (w Window) Covers(now time.Time) bool {
+	return !now.Before(w.StartsAt) && now.Before(w.EndsAt)
+}
+
+// Status is the switch when it is on, then the window under way that ends
+// last, so clients are not told to retry while maintenance is still going.
+func (s *State) Status(now time.Time) Status {
+	if s.Enabled && (s.EndsAt == nil || now.Before(*s.EndsAt)) {
+		return Status{Active: true, Message: s.Message, Until: s.EndsAt}
+	}
+
+	var current *Window
+	for i := range s.Windows {
+		w := &s.Windows[i]
+		if w.Covers(now) && (current == nil || w.EndsAt.After(current.EndsAt)) {
+			current = w
+		}
+	}
+	if current == nil {
+		return Status{}
+	}
+	until := current.EndsAt
+	return Status{Active: true, Message: current.Message, Until: &until}
+}
+
+// Schedule adds a window and drops the ones that have ended.
+func (s *State) Schedule(w Window, by string, now time.Time) {
+	s.Prune(now)
+	s.Windows = append(s.Windows, w)
+	s.UpdatedBy = by
+	s.UpdatedAt = now
+}
+
+// Cancel removes a scheduled window, also one under way, and returns it.
+func (s *State) Cancel(windowID, by string, now time.Time) (*Window, error) {
+	for i, w := range s.Windows {
+		if w.ID == windowID {
+			s.Windows = append(s.Windows[:i], s.Windows[i+1:]...)
+			s.UpdatedBy = by
+			s.UpdatedAt = now
+			return &w, nil
+		}
+	}
+	return nil, ErrWindowNotFound
+}
+
+// Prune drops the windows that have ended.
+func (s *State) Prune(now time.Time) {
+	kept := s.Windows[:0]
+	for _, w := range s.Windows {
+		if now.Before(w.EndsAt) {
+			kept = append(kept, w)
+		}
+	}
+	s.Windows = kept
+}
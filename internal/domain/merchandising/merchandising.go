@@ -0,0 +1,201 @@
+// Package merchandising holds the rules admins steer search results with:
+// products pinned to the top of a search, products boosted or buried by
+// attribute, and the curated order of a category's landing page. Rules apply
+// within their scheduling window only.
+package merchandising
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"online-shop/internal/domain/product"
+	"online-shop/pkg/id"
+)
+
+var (
+	ErrRuleNotFound = errors.New("merchandising rule not found")
+	// ErrInvalidRule flags a rule with a bad kind, target or period
+	ErrInvalidRule = errors.New("invalid merchandising rule")
+)
+
+type Kind string
+
+const (
+	// KindPin puts the rule's products first, in their order
+	KindPin Kind = "pin"
+	// KindBoost ranks the products with the attribute Weight times higher
+	KindBoost Kind = "boost"
+	// KindBury ranks the products with the attribute Weight times lower
+	KindBury Kind = "bury"
+)
+
+// MaxPinned caps the products pinned to one search, as Elasticsearch pinned
+// queries take no more
+const MaxPinned = 100
+
+// Rule steers the searches it matches. A rule with a Query matches searches
+// for it, ignoring case and spacing; one with a CategoryID matches searches
+// in the category, and alone curates the category's landing page, which has
+// no search text. A boost or bury with neither matches every search.
+type Rule struct {
+	ID         string   `json:"id" gorm:"primaryKey"`
+	Name       string   `json:"name"`
+	Kind       Kind     `json:"kind"`
+	Query      string   `json:"query,omitempty" gorm:"index"`
+	CategoryID string   `json:"category_id,omitempty" gorm:"index"`
+	ProductIDs []string `json:"product_ids,omitempty" gorm:"serializer:json"`
+	// AttributeKey and AttributeValues pick the products a boost or bury
+	// ranks, those with the attribute set to one of the values
+	AttributeKey    string     `json:"attribute_key,omitempty"`
+	AttributeValues []string   `json:"attribute_values,omitempty" gorm:"serializer:json"`
+	Weight          float64    `json:"weight,omitempty"`
+	Active          bool       `json:"active" gorm:"index"`
+	StartsAt        *time.Time `json:"starts_at,omitempty"`
+	EndsAt          *time.Time `json:"ends_at,omitempty"`
+	CreatedBy       string     `json:"created_by,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+func (Rule) TableName() string {
+	return "merchandising_rules"
+}
+
+type Repository interface {
+	Create(ctx context.Context, rule *Rule) error
+	GetByID(ctx context.Context, id string) (*Rule, error)
+	Update(ctx context.Context, rule *Rule) error
+	Delete(ctx context.Context, id string) error
+	// List returns the rules, newest first; kind filters them when set
+	List(ctx context.Context, kind Kind, limit, offset int) ([]*Rule, int64, error)
+	// ListLive returns the active rules whose window holds at, oldest first
+	ListLive(ctx context.Context, at time.Time) ([]*Rule, error)
+}
+
+func NewRule(name string, kind Kind, query, categoryID string, productIDs []string, attributeKey string, attributeValues []string, weight float64, startsAt, endsAt *time.Time, createdBy string) (*Rule, error) {
+	r := &Rule{
+		ID:              id.New(),
+		Name:            strings.TrimSpace(name),
+		Kind:            kind,
+		Query:           NormalizeQuery(query),
+		CategoryID:      categoryID,
+		ProductIDs:      productIDs,
+		AttributeKey:    attributeKey,
+		AttributeValues: attributeValues,
+		Weight:          weight,
+		Active:          true,
+		StartsAt:        startsAt,
+		EndsAt:          endsAt,
+		CreatedBy:       createdBy,
+	}
+	if err := r.Check(); err != nil {
+		return nil, err
+	}
+	r.CreatedAt = time.Now()
+	r.UpdatedAt = r.CreatedAt
+	return r, nil
+}
+
+// NormalizeQuery lower-cases a search query and collapses its spaces, for
+// rules to match searches however they are typed
+func NormalizeQuery(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
+
+// Check validates a rule after it is created or edited.
+func (r *Rule) Check() error {
+	if r.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidRule)
+	}
+	switch r.Kind {
+	case KindPin:
+		if r.Query == "" && r.CategoryID == "" {
+			return fmt.Errorf("%w: a pin needs a query or a category", ErrInvalidRule)
+		}
+		if len(r.ProductIDs) == 0 || len(r.ProductIDs) > MaxPinned {
+			return fmt.Errorf("%w: a pin takes 1 to %d products", ErrInvalidRule, MaxPinned)
+		}
+		seen := make(map[string]bool, len(r.ProductIDs))
+		for _, productID := range r.ProductIDs {
+			if productID == "" || seen[productID] {
+				return fmt.Errorf("%w: pinned products must be listed once each", ErrInvalidRule)
+			}
+			seen[productID] = true
+		}
+	case KindBoost, KindBury:
+		if r.AttributeKey == "" || len(r.AttributeValues) == 0 {
+			return fmt.Errorf("%w: a %s needs an attribute and its values", ErrInvalidRule, r.Kind)
+		}
+		if r.Weight <= 1 {
+			return fmt.Errorf("%w: a %s needs a weight above 1", ErrInvalidRule, r.Kind)
+		}
+	default:
+		return fmt.Errorf("%w: kind must be pin, boost or bury", ErrInvalidRule)
+	}
+	if r.StartsAt != nil && r.EndsAt != nil && !r.EndsAt.After(*r.StartsAt) {
+		return fmt.Errorf("%w: the rule must end after it starts", ErrInvalidRule)
+	}
+	return nil
+}
+
+// Live reports whether the rule applies at the given time.
+func (r *Rule) Live(at time.Time) bool {
+	if !r.Active || (r.StartsAt != nil && at.Before(*r.StartsAt)) {
+		return false
+	}
+	return r.EndsAt == nil || at.Before(*r.EndsAt)
+}
+
+// Matches reports whether the rule applies to a search for query in the
+// category, both of which may be empty.
+func (r *Rule) Matches(query, categoryID string) bool {
+	query = NormalizeQuery(query)
+	if r.Query != "" && r.Query != query {
+		return false
+	}
+	if r.CategoryID != "" {
+		if r.CategoryID != categoryID {
+			return false
+		}
+		// A category rule without a query curates the landing page only
+		if r.Query == "" && query != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Resolve works out the ranking the rules live at the given time give a
+// search. Pins are taken in the order of the rules, and a product pinned by
+// several rules keeps its first place.
+func Resolve(rules []*Rule, query, categoryID string, at time.Time) product.Ranking {
+	var ranking product.Ranking
+	pinned := make(map[string]bool)
+	for _, r := range rules {
+		if !r.Live(at) || !r.Matches(query, categoryID) {
+			continue
+		}
+		switch r.Kind {
+		case KindPin:
+			for _, productID := range r.ProductIDs {
+				if !pinned[productID] && len(ranking.Pinned) < MaxPinned {
+					pinned[productID] = true
+					ranking.Pinned = append(ranking.Pinned, productID)
+				}
+			}
+		case KindBoost, KindBury:
+			factor := r.Weight
+			if r.Kind == KindBury {
+				factor = 1 / r.Weight
+			}
+			ranking.Boosts = append(ranking.Boosts, product.Boost{
+				Filter: product.AttributeFilter{Key: r.AttributeKey, Values: r.AttributeValues},
+				Factor: factor,
+			})
+		}
+	}
+	return ranking
+}
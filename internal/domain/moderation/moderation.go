@@ -0,0 +1,207 @@
+// Package moderation holds the review of the products merchants submit. A
+// merchant's new product waits for review before it is served, and a
+// merchant's changes to a live product wait in their submission while the
+// product stays as it was. Admins approve or reject each submission; what
+// they approve goes live.
+package moderation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"online-shop/internal/domain/product"
+	"online-shop/pkg/id"
+)
+
+var (
+	ErrSubmissionNotFound = errors.New("product submission not found")
+	// ErrInvalidSubmission is a product missing its name, price or category,
+	// or a rejection that gives the merchant no comment
+	ErrInvalidSubmission = errors.New("invalid product submission")
+	ErrAlreadyReviewed   = errors.New("product submission was already reviewed")
+)
+
+type Kind string
+
+const (
+	// KindCreate submits a product that has not been live yet
+	KindCreate Kind = "create"
+	// KindUpdate submits changes to a live product
+	KindUpdate Kind = "update"
+)
+
+type Status string
+
+const (
+	StatusPending  Status = "pending"
+	StatusApproved Status = "approved"
+	StatusRejected Status = "rejected"
+)
+
+// MaxImages is the most images a submission can carry
+const MaxImages = 20
+
+// Content is what a merchant submits of a product. It replaces the
+// product's content as a whole once approved.
+type Content struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Price       float64  `json:"price"`
+	CategoryID  string   `json:"category_id"`
+	Images      []string `json:"images"`
+}
+
+// Submission is a merchant's product up for review. A product has at most
+// one pending submission; submitting again replaces its content.
+type Submission struct {
+	ID         string  `json:"id" gorm:"primaryKey"`
+	ProductID  string  `json:"product_id" gorm:"index"`
+	MerchantID string  `json:"merchant_id" gorm:"index"`
+	Kind       Kind    `json:"kind"`
+	Status     Status  `json:"status" gorm:"index"`
+	Content    Content `json:"content" gorm:"type:jsonb;serializer:json"`
+	// Comment is the reviewer's, required when rejecting
+	Comment     string     `json:"comment,omitempty"`
+	ReviewedBy  string     `json:"reviewed_by,omitempty"`
+	ReviewedAt  *time.Time `json:"reviewed_at,omitempty"`
+	SubmittedAt time.Time  `json:"submitted_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (Submission) TableName() string {
+	return "product_submissions"
+}
+
+// Filter selects submissions; empty fields match every submission.
+type Filter struct {
+	MerchantID string
+	ProductID  string
+	Status     Status
+	Limit      int
+	Offset     int
+}
+
+type Repository interface {
+	Create(ctx context.Context, s *Submission) error
+	Update(ctx context.Context, s *Submission) error
+	GetByID(ctx context.Context, id string) (*Submission, error)
+	// Pending returns the pending submission of a product, or
+	// ErrSubmissionNotFound when there is none
+	Pending(ctx context.Context, productID string) (*Submission, error)
+	// List returns the submissions, oldest submitted first
+	List(ctx context.Context, filter Filter) ([]*Submission, int64, error)
+}
+
+// Check validates submitted content, trimming it.
+func (c *Content) Check() error {
+	c.Name = strings.TrimSpace(c.Name)
+	c.Description = strings.TrimSpace(c.Description)
+	if c.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidSubmission)
+	}
+	if c.Price <= 0 {
+		return fmt.Errorf("%w: price must be positive", ErrInvalidSubmission)
+	}
+	if c.CategoryID == "" {
+		return fmt.Errorf("%w: category is required", ErrInvalidSubmission)
+	}
+	if len(c.Images) > MaxImages {
+		return fmt.Errorf("%w: at most %d images", ErrInvalidSubmission, MaxImages)
+	}
+	return nil
+}
+
+// Apply replaces the product's content with the submitted one.
+func (c Content) Apply(p *product.Product, at time.Time) {
+	p.Name = c.Name
+	p.Description = c.Description
+	p.Price = c.Price
+	if p.CategoryID != c.CategoryID {
+		p.CategoryID = c.CategoryID
+		p.Category = nil
+	}
+	p.Images = append([]string(nil), c.Images...)
+	p.UpdatedAt = at
+}
+
+// IsLive reports whether the product has been published, so that changes
+// to it wait in their submission instead of being made to it.
+func IsLive(p *product.Product) bool {
+	return p.Status == product.StatusActive || p.Status == product.StatusArchived
+}
+
+// NewSubmission submits content for the product: an update of a live
+// product, or the product itself when it is not live yet.
+func NewSubmission(p *product.Product, content Content, at time.Time) *Submission {
+	kind := KindCreate
+	if IsLive(p) {
+		kind = KindUpdate
+	}
+	return &Submission{
+		ID:          id.New(),
+		ProductID:   p.ID,
+		MerchantID:  p.MerchantID,
+		Kind:        kind,
+		Status:      StatusPending,
+		Content:     content,
+		SubmittedAt: at,
+		UpdatedAt:   at,
+	}
+}
+
+// Resubmit replaces the content of a pending submission.
+func (s *Submission) Resubmit(content Content, at time.Time) error {
+	if s.Status != StatusPending {
+		return ErrAlreadyReviewed
+	}
+	s.Content = content
+	s.SubmittedAt = at
+	s.UpdatedAt = at
+	return nil
+}
+
+// Approve accepts the submission and applies it to its product: its content
+// replaces the product's, and a product that was not live is published.
+func (s *Submission) Approve(p *product.Product, reviewer, comment string, at time.Time) error {
+	if err := s.review(StatusApproved, reviewer, comment, at); err != nil {
+		return err
+	}
+	s.Content.Apply(p, at)
+	if s.Kind == KindCreate {
+		p.Status = product.StatusActive
+		p.PublishAt = nil
+	}
+	return nil
+}
+
+// Reject turns the submission down with the reviewer's comment. A product
+// that was not live goes back to draft for the merchant to submit again; a
+// live one is left as it was.
+func (s *Submission) Reject(p *product.Product, reviewer, comment string, at time.Time) error {
+	if strings.TrimSpace(comment) == "" {
+		return fmt.Errorf("%w: a comment is required to reject", ErrInvalidSubmission)
+	}
+	if err := s.review(StatusRejected, reviewer, comment, at); err != nil {
+		return err
+	}
+	if s.Kind == KindCreate && p.Status == product.StatusPendingReview {
+		p.Status = product.StatusDraft
+		p.UpdatedAt = at
+	}
+	return nil
+}
+
+func (s *Submission) review(status Status, reviewer, comment string, at time.Time) error {
+	if s.Status != StatusPending {
+		return ErrAlreadyReviewed
+	}
+	s.Status = status
+	s.ReviewedBy = reviewer
+	s.Comment = strings.TrimSpace(comment)
+	s.ReviewedAt = &at
+	s.UpdatedAt = at
+	return nil
+}
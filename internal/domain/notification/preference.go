@@ -0,0 +1,168 @@
+// Package notification holds what customers choose to be sent: for each
+// category of notification and each channel, whether they want it. The
+// Policy answers from those choices before anything is sent, for the
+// notification and email workers alike.
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"online-shop/internal/domain/emaildelivery"
+	"online-shop/internal/domain/user"
+)
+
+// ErrInvalidPreference is an unknown category or channel, or turning off
+// a notification the shop must send
+var ErrInvalidPreference = errors.New("invalid notification preference")
+
+type Category string
+
+const (
+	// CategoryAccount is security, password and personal data notices,
+	// which are always sent
+	CategoryAccount Category = "account"
+	// CategoryOrders is order confirmations and delivery updates
+	CategoryOrders Category = "orders"
+	// CategoryAlerts is the price drop and back in stock alerts customers
+	// set up
+	CategoryAlerts Category = "alerts"
+	// CategoryMarketing follows the user's marketing consent
+	CategoryMarketing Category = "marketing"
+)
+
+// Categories lists the categories in the order the preference center shows
+// them.
+var Categories = []Category{CategoryAccount, CategoryOrders, CategoryAlerts, CategoryMarketing}
+
+func (c Category) Valid() bool {
+	for _, category := range Categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// Required categories cannot be turned off.
+func (c Category) Required() bool {
+	return c == CategoryAccount
+}
+
+// Unsubscribable categories are sent unprompted, so their emails carry an
+// unsubscribe link.
+func (c Category) Unsubscribable() bool {
+	return c == CategoryAlerts || c == CategoryMarketing
+}
+
+// Preference is a user's choice for a category over a channel. Only the
+// choices a user made are stored; the rest are on, but for marketing, which
+// is the user's marketing consent and is off until granted.
+type Preference struct {
+	UserID    string       `json:"-" gorm:"primaryKey"`
+	Category  Category     `json:"category" gorm:"primaryKey;size:32"`
+	Channel   user.Channel `json:"channel" gorm:"primaryKey;size:16"`
+	Enabled   bool         `json:"enabled"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+func (Preference) TableName() string {
+	return "notification_preferences"
+}
+
+type Repository interface {
+	List(ctx context.Context, userID string) ([]*Preference, error)
+	// Save creates the preferences or replaces the ones already stored
+	Save(ctx context.Context, prefs []*Preference) error
+	// Enabled reports whether the user has not turned the category off
+	// over the channel
+	Enabled(ctx context.Context, userID string, category Category, channel user.Channel) (bool, error)
+	// EmailEnabled is Enabled over email for the user with the address;
+	// addresses without an account are enabled
+	EmailEnabled(ctx context.Context, email string, category Category) (bool, error)
+}
+
+// Settings are a category's choices per channel, as the preference center
+// shows them.
+type Settings struct {
+	Category Category              `json:"category"`
+	Required bool                  `json:"required"`
+	Channels map[user.Channel]bool `json:"channels"`
+}
+
+// Center lays out every category's choices for the user from their stored
+// preferences and marketing consent.
+func Center(u *user.User, prefs []*Preference) []Settings {
+	settings := make([]Settings, len(Categories))
+	for i, category := range Categories {
+		channels := make(map[user.Channel]bool, len(user.Channels))
+		for _, channel := range user.Channels {
+			channels[channel] = category != CategoryMarketing || u.MarketingConsent.Granted(channel)
+		}
+		settings[i] = Settings{Category: category, Required: category.Required(), Channels: channels}
+	}
+	for _, p := range prefs {
+		for i := range settings {
+			if settings[i].Category == p.Category && p.Category != CategoryMarketing && !p.Category.Required() {
+				settings[i].Channels[p.Channel] = p.Enabled
+			}
+		}
+	}
+	return settings
+}
+
+// Check validates a choice for a category over a channel.
+func Check(category Category, channel user.Channel, enabled bool) error {
+	if !category.Valid() {
+		return fmt.Errorf("%w: unknown category %q", ErrInvalidPreference, category)
+	}
+	if !channel.Valid() {
+		return fmt.Errorf("%w: unknown channel %q", ErrInvalidPreference, channel)
+	}
+	if category.Required() && !enabled {
+		return fmt.Errorf("%w: %s notifications cannot be turned off", ErrInvalidPreference, category)
+	}
+	return nil
+}
+
+// Policy decides whether a notification may be sent. Required categories
+// always are, marketing needs the user's consent over the channel, and the
+// other categories are sent unless the user turned them off.
+type Policy struct {
+	consents user.ConsentRepository
+	prefs    Repository
+}
+
+func NewPolicy(consents user.ConsentRepository, prefs Repository) *Policy {
+	return &Policy{consents: consents, prefs: prefs}
+}
+
+// Allowed reports whether a notification of the category may go to the
+// user over the channel.
+func (p *Policy) Allowed(ctx context.Context, userID string, category Category, channel user.Channel) (bool, error) {
+	switch {
+	case category == "" || category.Required():
+		return true, nil
+	case category == CategoryMarketing:
+		return p.consents.HasMarketingConsent(ctx, userID, channel)
+	default:
+		return p.prefs.Enabled(ctx, userID, category, channel)
+	}
+}
+
+// AllowedEmail reports whether an email of the category may go to the
+// address.
+func (p *Policy) AllowedEmail(ctx context.Context, email string, category Category) (bool, error) {
+	switch {
+	case category == "" || category.Required():
+		return true, nil
+	case category == CategoryMarketing:
+		email = emaildelivery.NormalizeAddress(email)
+		consented, err := p.consents.ConsentedEmails(ctx, []string{email})
+		return consented[email], err
+	default:
+		return p.prefs.EmailEnabled(ctx, email, category)
+	}
+}
@@ -0,0 +1,95 @@
+package notification
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+var (
+	// ErrInvalidUnsubscribe is returned for unsubscribe links whose
+	// signature does not verify
+	ErrInvalidUnsubscribe = errors.New("invalid unsubscribe link")
+	// ErrTurnedOff is returned instead of sending to a recipient who turned
+	// the notification's category off
+	ErrTurnedOff = errors.New("recipient turned these notifications off")
+)
+
+// UnsubscribePath is the path of the unsubscribe endpoint, under the API's
+// base URL
+const UnsubscribePath = "/api/v1/notifications/unsubscribe/"
+
+// Unsubscribe is what an unsubscribe link turns off: the category's emails
+// to the address.
+type Unsubscribe struct {
+	Email    string   `json:"email"`
+	Category Category `json:"category"`
+}
+
+// Links signs the unsubscribe links of emails. A link opens the page at
+// pageURL with the token, or the API's endpoint without a page; mail clients
+// unsubscribe in one click by posting to the endpoint (RFC 8058).
+type Links struct {
+	baseURL string
+	pageURL string
+	secret  []byte
+}
+
+func NewLinks(baseURL, pageURL, secret string) *Links {
+	return &Links{baseURL: strings.TrimSuffix(baseURL, "/"), pageURL: pageURL, secret: []byte(secret)}
+}
+
+// URL is the unsubscribe link shown in the email.
+func (l *Links) URL(email string, category Category) string {
+	token := l.sign(email, category)
+	if l.pageURL == "" {
+		return l.baseURL + UnsubscribePath + token
+	}
+	separator := "?"
+	if strings.Contains(l.pageURL, "?") {
+		separator = "&"
+	}
+	return l.pageURL + separator + "token=" + url.QueryEscape(token)
+}
+
+// Headers are the List-Unsubscribe headers of the email.
+func (l *Links) Headers(email string, category Category) map[string]string {
+	return map[string]string{
+		"List-Unsubscribe":      "<" + l.baseURL + UnsubscribePath + l.sign(email, category) + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+}
+
+// Parse verifies a link's token and returns what it unsubscribes from.
+// Without a secret no token verifies.
+func (l *Links) Parse(token string) (Unsubscribe, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok || len(l.secret) == 0 || !hmac.Equal([]byte(signature), []byte(l.signature(payload))) {
+		return Unsubscribe{}, ErrInvalidUnsubscribe
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return Unsubscribe{}, ErrInvalidUnsubscribe
+	}
+	var u Unsubscribe
+	if err := json.Unmarshal(data, &u); err != nil || u.Email == "" || !u.Category.Unsubscribable() {
+		return Unsubscribe{}, ErrInvalidUnsubscribe
+	}
+	return u, nil
+}
+
+func (l *Links) sign(email string, category Category) string {
+	data, _ := json.Marshal(Unsubscribe{Email: strings.TrimSpace(email), Category: category})
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + l.signature(payload)
+}
+
+func (l *Links) signature(payload string) string {
+	mac := hmac.New(sha256.New, l.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,138 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"online-shop/pkg/id"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown oauth provider")
+	ErrInvalidState    = errors.New("oauth state is invalid or has expired")
+)
+
+// Identity is the verified profile a provider returns for the signed-in user.
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	FirstName     string
+	LastName      string
+}
+
+// Account links a provider identity to a local user. A user can have one
+// account per provider.
+type Account struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	UserID    string    `json:"user_id" gorm:"index"`
+	Provider  string    `json:"provider" gorm:"uniqueIndex:idx_oauth_provider_subject"`
+	Subject   string    `json:"-" gorm:"uniqueIndex:idx_oauth_provider_subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Account) TableName() string {
+	return "oauth_accounts"
+}
+
+// State is kept server-side between the redirect to the provider and the
+// callback. It binds the callback to the request that started it (state),
+// the ID token to this login (nonce) and the code to this client (PKCE).
+// Binding is kept in a cookie in the browser that started the login; it is
+// random rather than derived from the ID, which travels in the callback URL.
+type State struct {
+	ID           string    `json:"id"`
+	Provider     string    `json:"provider"`
+	Nonce        string    `json:"nonce"`
+	CodeVerifier string    `json:"code_verifier"`
+	Binding      string    `json:"binding"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Provider adapts one identity provider's authorization code flow.
+type Provider interface {
+	Name() string
+	AuthCodeURL(state *State) string
+	Exchange(ctx context.Context, code string, state *State) (*Identity, error)
+}
+
+type AccountRepository interface {
+	Create(ctx context.Context, account *Account) error
+	GetByProviderSubject(ctx context.Context, provider, subject string) (*Account, error)
+}
+
+// StateStore keeps pending logins. Get leaves the state in place; Take
+// returns it at most once.
+type StateStore interface {
+	Save(ctx context.Context, state *State, ttl time.Duration) error
+	Get(ctx context.Context, id string) (*State, error)
+	Take(ctx context.Context, id string) (*State, error)
+}
+
+func NewState(provider string) (*State, error) {
+	id, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	binding, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	return &State{
+		ID:           id,
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		Binding:      binding,
+		CreatedAt:    time.Now(),
+	}, nil
+}
+
+// CodeChallenge is the S256 PKCE challenge for the state's verifier.
+func (s *State) CodeChallenge() string {
+	sum := sha256.Sum256([]byte(s.CodeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Bound reports whether binding is the state's: a callback that does not
+// carry it was not started by the browser holding the cookie.
+func (s *State) Bound(binding string) bool {
+	return binding != "" && subtle.ConstantTimeCompare([]byte(s.Binding), []byte(binding)) == 1
+}
+
+func NewAccount(userID string, identity *Identity) *Account {
+	return &Account{
+		ID:        id.New(),
+		UserID:    userID,
+		Provider:  identity.Provider,
+		Subject:   identity.Subject,
+		Email:     identity.Email,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,27 @@
+package order
+
+import "time"
+
+// Channel is how a customer reached the shop with an order staff placed on
+// their behalf. Orders customers place themselves have none.
+type Channel string
+
+const (
+	ChannelPhone   Channel = "phone"
+	ChannelInStore Channel = "in_store"
+	// ChannelPaymentLink orders were drafted by staff and paid by the
+	// customer through the draft's payment link
+	ChannelPaymentLink Channel = "payment_link"
+)
+
+// PlaceOnBehalf records that staffID placed the order for its customer.
+func (o *Order) PlaceOnBehalf(staffID string, channel Channel) {
+	o.PlacedBy = staffID
+	o.Channel = channel
+	o.UpdatedAt = time.Now()
+}
+
+// Assisted reports whether staff placed the order on the customer's behalf.
+func (o *Order) Assisted() bool {
+	return o.PlacedBy != ""
+}
@@ -0,0 +1,119 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// ErrNotCancellable is returned for an order the cancellation policy does
+// not let be cancelled, such as one that was delivered and can only be
+// returned
+var ErrNotCancellable = errors.New("order cannot be cancelled")
+
+// CancelReason is why an order was cancelled, kept for reporting.
+type CancelReason string
+
+const (
+	// Reasons a customer gives
+	CancelReasonChangedMind      CancelReason = "changed_mind"
+	CancelReasonOrderedByMistake CancelReason = "ordered_by_mistake"
+	CancelReasonBetterPrice      CancelReason = "found_better_price"
+	CancelReasonDeliveryTooSlow  CancelReason = "delivery_too_slow"
+	CancelReasonWrongAddress     CancelReason = "wrong_address"
+	CancelReasonOther            CancelReason = "other"
+	// Reasons the shop cancels for
+	CancelReasonFraudDeclined CancelReason = "fraud_declined"
+	CancelReasonPaymentFailed CancelReason = "payment_failed"
+)
+
+// Cancellation records why an order was cancelled and what happened to its
+// payments. Fee is what the shop kept of them; Refunded is what was paid
+// back.
+type Cancellation struct {
+	Reason   CancelReason `json:"reason,omitempty" gorm:"index"`
+	Note     string       `json:"note,omitempty"`
+	Fee      float64      `json:"fee"`
+	Refunded float64      `json:"refunded"`
+	At       *time.Time   `json:"at,omitempty"`
+}
+
+// CancellationPolicy sets what cancelling an order costs. An order is
+// cancelled for free until one of its shipments leaves the warehouse;
+// after that it costs FlatFee plus FeeRate of its total, which pays for the
+// parcel coming back, or cannot be cancelled at all without AfterShipment.
+type CancellationPolicy struct {
+	AfterShipment bool
+	FlatFee       float64
+	FeeRate       float64
+}
+
+// Fee returns what cancelling the order costs, or ErrNotCancellable. The
+// fee is never more than the order's total.
+func (p CancellationPolicy) Fee(o *Order) (float64, error) {
+	switch o.Status {
+	case StatusPending, StatusOnHold, StatusConfirmed, StatusProcessing, StatusShipped:
+	default:
+		return 0, fmt.Errorf("%w: order is %s", ErrNotCancellable, o.Status)
+	}
+
+	shipped := o.Status == StatusShipped
+	for _, s := range o.Shipments {
+		switch s.Status {
+		case ShipmentStatusDelivered:
+			return 0, fmt.Errorf("%w: part of the order has been delivered", ErrNotCancellable)
+		case ShipmentStatusShipped:
+			shipped = true
+		}
+	}
+	if !shipped {
+		return 0, nil
+	}
+	if !p.AfterShipment {
+		return 0, fmt.Errorf("%w: the order has shipped", ErrNotCancellable)
+	}
+
+	fee := math.Round((p.FlatFee+p.FeeRate*o.TotalAmount)*100) / 100
+	if fee > o.TotalAmount {
+		fee = o.TotalAmount
+	}
+	return fee, nil
+}
+
+// BeginCancel moves the order to cancelling, recording why and the fee the
+// policy charges. Its shipments are not worked on any further.
+func (o *Order) BeginCancel(reason CancelReason, note string, fee float64, at time.Time) {
+	o.Status = StatusCancelling
+	o.Cancellation = Cancellation{
+		Reason: reason,
+		Note:   strings.TrimSpace(note),
+		Fee:    fee,
+	}
+	o.UpdatedAt = at
+}
+
+// Cancel records the order as cancelled once its payments have been dealt
+// with: kept of them went to the fee, which is less than the fee when less
+// was paid, and refunded was paid back.
+func (o *Order) Cancel(kept, refunded float64, at time.Time) {
+	o.Status = StatusCancelled
+	o.Cancellation.Fee = kept
+	o.Cancellation.Refunded = refunded
+	o.Cancellation.At = &at
+	o.PaidAmount = kept
+	if refunded > 0 {
+		o.PaymentStatus = PaymentStatusRefunded
+	}
+	o.UpdatedAt = at
+}
+
+// CancellationStat is how many orders were cancelled for a reason, with the
+// fees kept and the amounts refunded on them.
+type CancellationStat struct {
+	Reason   CancelReason `json:"reason"`
+	Orders   int64        `json:"orders"`
+	Fees     float64      `json:"fees"`
+	Refunded float64      `json:"refunded"`
+}
@@ -0,0 +1,19 @@
+package order
+
+import "context"
+
+// Confirmation tells a customer their order is confirmed, with the names of
+// the products it holds by ID.
+type Confirmation struct {
+	Order        *Order
+	Email        string
+	CustomerName string
+	Locale       string
+	ProductNames map[string]string
+}
+
+// ConfirmationNotifier sends customers the confirmation of their order once
+// it is paid for, or is to be paid on delivery.
+type ConfirmationNotifier interface {
+	OrderConfirmed(ctx context.Context, c Confirmation) error
+}
@@ -0,0 +1,173 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrShipmentNotFound = errors.New("shipment not found")
+	// ErrShipmentState is returned for a step taken out of order, such as
+	// packing a shipment nobody picked
+	ErrShipmentState = errors.New("shipment cannot take this step")
+	ErrInvalidParcel = errors.New("invalid parcel")
+	ErrInvalidProof  = errors.New("invalid delivery proof")
+)
+
+// Parcel is the weight and size of a packed shipment, which the carrier
+// rates and labels it by.
+type Parcel struct {
+	WeightGrams int `json:"weight_grams"`
+	LengthCm    int `json:"length_cm"`
+	WidthCm     int `json:"width_cm"`
+	HeightCm    int `json:"height_cm"`
+}
+
+func (p Parcel) Validate() error {
+	if p.WeightGrams <= 0 || p.LengthCm <= 0 || p.WidthCm <= 0 || p.HeightCm <= 0 {
+		return fmt.Errorf("%w: weight and every dimension must be positive", ErrInvalidParcel)
+	}
+	return nil
+}
+
+// DeliveryProof is what the courier captured at the door: who took the
+// shipment, and a photo of it handed over or their signature.
+type DeliveryProof struct {
+	ReceivedBy   string `json:"received_by,omitempty"`
+	PhotoURL     string `json:"photo_url,omitempty"`
+	SignatureURL string `json:"signature_url,omitempty"`
+}
+
+func (p DeliveryProof) Validate() error {
+	if strings.TrimSpace(p.ReceivedBy) == "" {
+		return fmt.Errorf("%w: the name of whoever received the shipment is required", ErrInvalidProof)
+	}
+	if p.PhotoURL == "" && p.SignatureURL == "" {
+		return fmt.Errorf("%w: a photo or a signature is required", ErrInvalidProof)
+	}
+	return nil
+}
+
+// Shipment returns the order's shipment with the ID, or nil.
+func (o *Order) Shipment(shipmentID string) *Shipment {
+	for i := range o.Shipments {
+		if o.Shipments[i].ID == shipmentID {
+			return &o.Shipments[i]
+		}
+	}
+	return nil
+}
+
+// ShipmentItems returns the items that leave in the shipment.
+func (o *Order) ShipmentItems(shipmentID string) []OrderItem {
+	var items []OrderItem
+	for _, item := range o.Items {
+		if item.ShipmentID == shipmentID {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// StartPicking puts a pending shipment on a pick list. Only paid orders
+// that have not shipped yet are picked; a confirmed order moves to
+// processing with its first shipment.
+func (o *Order) StartPicking(shipmentID string, at time.Time) (*Shipment, error) {
+	if o.Status != StatusConfirmed && o.Status != StatusProcessing {
+		return nil, fmt.Errorf("%w: order is %s", ErrShipmentState, o.Status)
+	}
+	s, err := o.shipmentIn(shipmentID, ShipmentStatusPending)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Status = ShipmentStatusPicking
+	s.PickedAt = &at
+	s.UpdatedAt = at
+	if o.Status == StatusConfirmed {
+		o.UpdateStatus(StatusProcessing)
+	}
+	return s, nil
+}
+
+// PackShipment records the parcel of a picked shipment.
+func (o *Order) PackShipment(shipmentID string, parcel Parcel, at time.Time) (*Shipment, error) {
+	if err := parcel.Validate(); err != nil {
+		return nil, err
+	}
+	s, err := o.shipmentIn(shipmentID, ShipmentStatusPicking)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Status = ShipmentStatusPacked
+	s.Parcel = parcel
+	s.PackedAt = &at
+	s.UpdatedAt = at
+	return s, nil
+}
+
+// ShipShipment hands a packed shipment to its carrier. The order is shipped
+// once every one of its shipments is.
+func (o *Order) ShipShipment(shipmentID, carrier, trackingNumber, labelURL string, at time.Time) (*Shipment, error) {
+	s, err := o.shipmentIn(shipmentID, ShipmentStatusPacked)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Status = ShipmentStatusShipped
+	s.Carrier = carrier
+	s.TrackingNumber = trackingNumber
+	s.LabelURL = labelURL
+	s.ShippedAt = &at
+	s.UpdatedAt = at
+
+	for _, other := range o.Shipments {
+		if other.Status != ShipmentStatusShipped && other.Status != ShipmentStatusDelivered {
+			return s, nil
+		}
+	}
+	o.UpdateStatus(StatusShipped)
+	return s, nil
+}
+
+// DeliverShipment records the proof of delivery of a shipped shipment. The
+// order is delivered once every one of its shipments is.
+func (o *Order) DeliverShipment(shipmentID string, proof DeliveryProof, at time.Time) (*Shipment, error) {
+	if err := proof.Validate(); err != nil {
+		return nil, err
+	}
+	s, err := o.shipmentIn(shipmentID, ShipmentStatusShipped)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Status = ShipmentStatusDelivered
+	s.Proof = proof
+	s.DeliveredAt = &at
+	s.UpdatedAt = at
+
+	for _, other := range o.Shipments {
+		if other.Status != ShipmentStatusDelivered {
+			return s, nil
+		}
+	}
+	o.UpdateStatus(StatusDelivered)
+	return s, nil
+}
+
+func (o *Order) shipmentIn(shipmentID string, status ShipmentStatus) (*Shipment, error) {
+	if o.IsCancelled() {
+		return nil, fmt.Errorf("%w: order is %s", ErrShipmentState, o.Status)
+	}
+	s := o.Shipment(shipmentID)
+	if s == nil {
+		return nil, ErrShipmentNotFound
+	}
+	if s.Status != status {
+		return nil, fmt.Errorf("%w: shipment is %s, not %s", ErrShipmentState, s.Status, status)
+	}
+	return s, nil
+}
@@ -0,0 +1,60 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrInvalidExtras is returned for a note or gift message that is too long
+var ErrInvalidExtras = errors.New("invalid order note or gift options")
+
+const (
+	// MaxNoteLength and MaxGiftMessageLength are in characters
+	MaxNoteLength        = 500
+	MaxGiftMessageLength = 300
+)
+
+// Gift is how an order is sent as a present. The message is printed on a
+// card packed with the items, and can be sent without wrapping.
+type Gift struct {
+	Wrap    bool   `json:"wrap"`
+	Message string `json:"message,omitempty" gorm:"size:1200"`
+	// WrapFee is what wrapping was charged, included in TotalAmount
+	WrapFee float64 `json:"wrap_fee"`
+}
+
+// IsGift reports whether the order is wrapped or carries a message.
+func (g Gift) IsGift() bool {
+	return g.Wrap || g.Message != ""
+}
+
+// SetNote sets the customer's note for the shop, such as delivery
+// instructions, or clears it with "".
+func (o *Order) SetNote(note string) error {
+	note = strings.TrimSpace(note)
+	if utf8.RuneCountInString(note) > MaxNoteLength {
+		return fmt.Errorf("%w: note can be at most %d characters", ErrInvalidExtras, MaxNoteLength)
+	}
+	o.Note = note
+	o.UpdatedAt = time.Now()
+	return nil
+}
+
+// SetGift sets the order's gift options and adds wrapFee to its total when
+// it is to be wrapped, in place of any fee charged before.
+func (o *Order) SetGift(wrap bool, message string, wrapFee float64) error {
+	message = strings.TrimSpace(message)
+	if utf8.RuneCountInString(message) > MaxGiftMessageLength {
+		return fmt.Errorf("%w: gift message can be at most %d characters", ErrInvalidExtras, MaxGiftMessageLength)
+	}
+	if !wrap {
+		wrapFee = 0
+	}
+	o.TotalAmount += wrapFee - o.Gift.WrapFee
+	o.Gift = Gift{Wrap: wrap, Message: message, WrapFee: wrapFee}
+	o.UpdatedAt = time.Now()
+	return nil
+}
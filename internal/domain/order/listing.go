@@ -0,0 +1,171 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidListing = errors.New("invalid order listing")
+
+// ListFilter narrows an order list, the admin one or a customer's history.
+// Zero fields do not filter; To is exclusive.
+type ListFilter struct {
+	Statuses      []Status
+	From          time.Time
+	To            time.Time
+	MinAmount     *float64
+	MaxAmount     *float64
+	PaymentMethod string
+	// MerchantID keeps orders with at least one item sold by the merchant
+	MerchantID string
+	UserID     string
+	// OrganizationID keeps the orders bought for the organization
+	OrganizationID string
+	// ProductName keeps orders with an item whose product name contains it
+	ProductName string
+}
+
+type SortField string
+
+const (
+	SortCreatedAt   SortField = "created_at"
+	SortTotalAmount SortField = "total_amount"
+	SortStatus      SortField = "status"
+	SortNumber      SortField = "number"
+)
+
+var sortFields = map[SortField]bool{
+	SortCreatedAt:   true,
+	SortTotalAmount: true,
+	SortStatus:      true,
+	SortNumber:      true,
+}
+
+type SortKey struct {
+	Field      SortField
+	Descending bool
+}
+
+// DefaultSort lists the newest orders first. Every sort ends on the order ID
+// so orders with equal keys keep a stable position between pages.
+var DefaultSort = []SortKey{{Field: SortCreatedAt, Descending: true}}
+
+var validStatuses = map[Status]bool{
+	StatusPending:    true,
+	StatusConfirmed:  true,
+	StatusProcessing: true,
+	StatusShipped:    true,
+	StatusDelivered:  true,
+	StatusCancelling: true,
+	StatusCancelled:  true,
+	StatusRefunded:   true,
+	StatusOnHold:     true,
+}
+
+// ParseStatuses reads a comma separated list of statuses.
+func ParseStatuses(s string) ([]Status, error) {
+	var statuses []Status
+	for _, part := range strings.Split(s, ",") {
+		status := Status(strings.TrimSpace(part))
+		if status == "" {
+			continue
+		}
+		if !validStatuses[status] {
+			return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidListing, status)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// ParseSort reads a comma separated list of fields, each prefixed with - to
+// sort it descending, such as "-created_at,total_amount". An empty string is
+// DefaultSort.
+func ParseSort(s string) ([]SortKey, error) {
+	if strings.TrimSpace(s) == "" {
+		return DefaultSort, nil
+	}
+
+	var keys []SortKey
+	seen := make(map[SortField]bool)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		key := SortKey{Field: SortField(strings.TrimPrefix(part, "-")), Descending: strings.HasPrefix(part, "-")}
+		if !sortFields[key.Field] {
+			return nil, fmt.Errorf("%w: cannot sort by %q", ErrInvalidListing, key.Field)
+		}
+		if seen[key.Field] {
+			return nil, fmt.Errorf("%w: %q is sorted on twice", ErrInvalidListing, key.Field)
+		}
+		seen[key.Field] = true
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// FormatSort writes keys back the way ParseSort reads them.
+func FormatSort(keys []SortKey) string {
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = string(k.Field)
+		if k.Descending {
+			parts[i] = "-" + parts[i]
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Position is where a page of the sorted list ends: the sort key values of
+// its last order followed by the order's ID. The next page starts after it.
+type Position []string
+
+// PositionOf returns the position of o in a list sorted by keys.
+func PositionOf(o *Order, keys []SortKey) Position {
+	p := make(Position, 0, len(keys)+1)
+	for _, k := range keys {
+		switch k.Field {
+		case SortCreatedAt:
+			p = append(p, o.CreatedAt.UTC().Format(time.RFC3339Nano))
+		case SortTotalAmount:
+			p = append(p, strconv.FormatFloat(o.TotalAmount, 'f', -1, 64))
+		case SortStatus:
+			p = append(p, string(o.Status))
+		case SortNumber:
+			p = append(p, o.Number)
+		}
+	}
+	return append(p, o.ID)
+}
+
+// Values converts the position back into the typed values of keys, ending
+// with the order ID, for comparing against the stored columns.
+func (p Position) Values(keys []SortKey) ([]interface{}, error) {
+	if len(p) != len(keys)+1 {
+		return nil, fmt.Errorf("%w: position does not match the sort", ErrInvalidListing)
+	}
+
+	values := make([]interface{}, len(p))
+	for i, k := range keys {
+		switch k.Field {
+		case SortCreatedAt:
+			t, err := time.Parse(time.RFC3339Nano, p[i])
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid position", ErrInvalidListing)
+			}
+			values[i] = t
+		case SortTotalAmount:
+			f, err := strconv.ParseFloat(p[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid position", ErrInvalidListing)
+			}
+			values[i] = f
+		default:
+			values[i] = p[i]
+		}
+	}
+	values[len(keys)] = p[len(keys)]
+	return values, nil
+}
@@ -1,21 +1,59 @@
 package order
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"math"
 	"time"
 
-	"github.com/google/uuid"
+	"online-shop/pkg/id"
 )
 
+var ErrNotFound = errors.New("order not found")
+
 type Order struct {
-	ID          string      `json:"id" gorm:"primaryKey"`
+	ID          string      `json:"id" gorm:"primaryKey;index:idx_orders_created_at_id,priority:2"`
+	// Number is the sequential reference shown to customers and printed on
+	// invoices; orders placed before numbers were introduced have none
+	Number      string      `json:"number" gorm:"size:16;index:idx_orders_number,unique,where:number <> ''"`
 	UserID      string      `json:"user_id"`
 	Items       []OrderItem `json:"items" gorm:"foreignKey:OrderID"`
 	TotalAmount float64     `json:"total_amount"`
-	Status      Status      `json:"status"`
+	// CommissionTotal is the marketplace's commission on the items, and
+	// MerchantPayout what is left of their subtotals for the merchants
+	CommissionTotal float64 `json:"commission_total"`
+	MerchantPayout  float64 `json:"merchant_payout"`
+	Status      Status      `json:"status" gorm:"index"`
 	PaymentID   string      `json:"payment_id"`
+	// PaidAmount is what the order's settled payments add up to; an order
+	// can be paid in several parts
+	PaidAmount    float64       `json:"paid_amount"`
+	PaymentStatus PaymentStatus `json:"payment_status" gorm:"default:unpaid"`
 	ShippingAddress Address `json:"shipping_address" gorm:"embedded;embeddedPrefix:shipping_"`
-	CreatedAt   time.Time   `json:"created_at"`
+	// Shipping is what delivery was charged, included in TotalAmount
+	Shipping    ShippingCharge `json:"shipping" gorm:"embedded;embeddedPrefix:shipping_charge_"`
+	// Delivery is when the order should arrive, estimated when it is placed
+	// and again when it is confirmed
+	Delivery    DeliveryEstimate `json:"delivery_estimate" gorm:"embedded;embeddedPrefix:delivery_estimate_"`
+	// Note is what the customer asked of the shop at checkout, printed
+	// with Gift on the invoice and the pick list
+	Note string `json:"note,omitempty" gorm:"size:2000"`
+	Gift Gift   `json:"gift" gorm:"embedded;embeddedPrefix:gift_"`
+	Shipments   []Shipment  `json:"shipments,omitempty" gorm:"foreignKey:OrderID"`
+	// QuoteID is the quote the order was converted from, at its prices
+	QuoteID      string       `json:"quote_id,omitempty" gorm:"index"`
+	// OrganizationID is the organization the order was bought for on
+	// invoice
+	OrganizationID string `json:"organization_id,omitempty" gorm:"index"`
+	// PlacedBy is the admin who placed the order on the customer's behalf,
+	// and Channel how the customer ordered
+	PlacedBy string  `json:"placed_by,omitempty" gorm:"index"`
+	Channel  Channel `json:"channel,omitempty"`
+	// Cancellation is recorded when the order is cancelled
+	Cancellation Cancellation `json:"cancellation" gorm:"embedded;embeddedPrefix:cancel_"`
+	// CreatedAt and ID index the default newest-first listing
+	CreatedAt   time.Time   `json:"created_at" gorm:"index:idx_orders_created_at_id,priority:1"`
 	UpdatedAt   time.Time   `json:"updated_at"`
 }
 
@@ -23,17 +61,96 @@ type OrderItem struct {
 	ID        string  `json:"id" gorm:"primaryKey"`
 	OrderID   string  `json:"order_id"`
 	ProductID string  `json:"product_id"`
+	MerchantID string `json:"merchant_id" gorm:"index"`
 	Quantity  int     `json:"quantity"`
 	Price     float64 `json:"price"`
 	Subtotal  float64 `json:"subtotal"`
+	CommissionRuleID string  `json:"commission_rule_id,omitempty"`
+	CommissionAmount float64 `json:"commission_amount"`
+	WarehouseID      string  `json:"warehouse_id,omitempty"`
+	ShipmentID       string  `json:"shipment_id,omitempty"`
+	// FlashSaleID is the flash sale the item was priced by, if any
+	FlashSaleID string `json:"flash_sale_id,omitempty"`
+	// BundleID is the bundle the item was ordered in, if any, and
+	// BundleQuantity how many of the bundle were ordered; the item's price
+	// is its share of the bundle price
+	BundleID       string `json:"bundle_id,omitempty" gorm:"index"`
+	BundleQuantity int    `json:"bundle_quantity,omitempty"`
+	// Product is the product as it was ordered
+	Product ProductSnapshot `json:"product" gorm:"embedded;embeddedPrefix:product_"`
+}
+
+// Shipment groups the items of an order that leave from the same warehouse.
+type Shipment struct {
+	ID             string         `json:"id" gorm:"primaryKey"`
+	OrderID        string         `json:"order_id" gorm:"index"`
+	WarehouseID    string         `json:"warehouse_id"`
+	Status         ShipmentStatus `json:"status" gorm:"index"`
+	Carrier        string         `json:"carrier"`
+	TrackingNumber string         `json:"tracking_number"`
+	// Parcel is recorded when the shipment is packed
+	Parcel    Parcel     `json:"parcel" gorm:"embedded;embeddedPrefix:parcel_"`
+	LabelURL  string     `json:"label_url,omitempty"`
+	PickedAt  *time.Time `json:"picked_at,omitempty"`
+	PackedAt  *time.Time `json:"packed_at,omitempty"`
+	ShippedAt *time.Time `json:"shipped_at"`
+	// Proof is captured by the courier when the shipment is delivered
+	Proof       DeliveryProof `json:"proof" gorm:"embedded;embeddedPrefix:proof_"`
+	DeliveredAt *time.Time    `json:"delivered_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type ShipmentStatus string
+
+const (
+	ShipmentStatusPending   ShipmentStatus = "pending"
+	// ShipmentStatusPicking shipments are on a pick list in their warehouse
+	ShipmentStatusPicking ShipmentStatus = "picking"
+	// ShipmentStatusPacked shipments are boxed, weighed and waiting for a
+	// label
+	ShipmentStatusPacked    ShipmentStatus = "packed"
+	ShipmentStatusShipped   ShipmentStatus = "shipped"
+	ShipmentStatusDelivered ShipmentStatus = "delivered"
+)
+
+// WarehouseAllocation is the quantity of a product sourced from a warehouse.
+type WarehouseAllocation struct {
+	ProductID   string
+	WarehouseID string
+	Quantity    int
+}
+
+// ShippingCharge is the rate an order ships at, in the zone its address
+// falls in. Orders placed while the shop had no zones have none.
+type ShippingCharge struct {
+	ZoneID string  `json:"zone_id,omitempty"`
+	RateID string  `json:"rate_id,omitempty"`
+	Rate   string  `json:"rate,omitempty"`
+	Fee    float64 `json:"fee"`
+	// MinDays and MaxDays are how many working days the rate's carrier
+	// takes to deliver
+	MinDays int `json:"min_days,omitempty"`
+	MaxDays int `json:"max_days,omitempty"`
+}
+
+// DeliveryEstimate is the range of dates, written as 2006-01-02 in the
+// shop's time zone, an order should arrive between.
+type DeliveryEstimate struct {
+	Earliest string `json:"earliest,omitempty"`
+	Latest   string `json:"latest,omitempty"`
+}
+
+func (e DeliveryEstimate) IsZero() bool {
+	return e.Earliest == ""
 }
 
 type Address struct {
-	Street     string `json:"street"`
-	City       string `json:"city"`
-	State      string `json:"state"`
-	PostalCode string `json:"postal_code"`
-	Country    string `json:"country"`
+	Street     string `json:"street" gorm:"serializer:encrypted" validate:"required,notblank"`
+	City       string `json:"city" gorm:"serializer:encrypted" validate:"required,notblank"`
+	State      string `json:"state" gorm:"serializer:encrypted"`
+	PostalCode string `json:"postal_code" gorm:"serializer:encrypted" validate:"required,notblank,max=16"`
+	Country    string `json:"country" validate:"required,len=2"`
 }
 
 type Status string
@@ -44,17 +161,66 @@ const (
 	StatusProcessing Status = "processing"
 	StatusShipped    Status = "shipped"
 	StatusDelivered  Status = "delivered"
+	// StatusCancelling orders were claimed for cancellation and had their
+	// stock put back; their refunds are still being issued
+	StatusCancelling Status = "cancelling"
 	StatusCancelled  Status = "cancelled"
 	StatusRefunded   Status = "refunded"
+	// StatusOnHold orders scored as risky at checkout and wait for a fraud
+	// review before they can be paid
+	StatusOnHold Status = "on_hold"
+)
+
+type PaymentStatus string
+
+const (
+	PaymentStatusUnpaid        PaymentStatus = "unpaid"
+	PaymentStatusPartiallyPaid PaymentStatus = "partially_paid"
+	PaymentStatusPaid          PaymentStatus = "paid"
+	// PaymentStatusCashOnDelivery orders are paid to the courier when they
+	// are delivered
+	PaymentStatusCashOnDelivery PaymentStatus = "cash_on_delivery"
+	// PaymentStatusInvoiced orders are billed to an organization, to be
+	// paid within its payment terms
+	PaymentStatusInvoiced PaymentStatus = "invoiced"
+	// PaymentStatusRefunded orders were cancelled and paid back, less any
+	// cancellation fee
+	PaymentStatusRefunded PaymentStatus = "refunded"
 )
 
 type Repository interface {
-	Create(order *Order) error
-	GetByID(id string) (*Order, error)
-	GetByUserID(userID string, limit, offset int) ([]*Order, error)
-	Update(order *Order) error
-	UpdateStatus(orderID string, status Status) error
-	List(limit, offset int) ([]*Order, error)
+	Create(ctx context.Context, order *Order) error
+	GetByID(ctx context.Context, id string) (*Order, error)
+	// GetByNumber returns the order with the number, or ErrNotFound
+	GetByNumber(ctx context.Context, number string) (*Order, error)
+	GetByUserID(ctx context.Context, userID string, limit, offset int) ([]*Order, error)
+	Update(ctx context.Context, order *Order) error
+	UpdateStatus(ctx context.Context, orderID string, status Status) error
+	// UpdateFrom saves the order only while its stored status is still
+	// from, in one statement, reporting false when it is not, such as when
+	// another request changed it first
+	UpdateFrom(ctx context.Context, order *Order, from Status) (bool, error)
+	List(ctx context.Context, limit, offset int) ([]*Order, error)
+	// Search lists the orders matching filter sorted by keys, starting after
+	// the position when one is given
+	Search(ctx context.Context, filter ListFilter, keys []SortKey, after Position, limit int) ([]*Order, error)
+	// ListByFilter returns a page of the orders matching filter, newest
+	// first, and how many match in all
+	ListByFilter(ctx context.Context, filter ListFilter, limit, offset int) ([]*Order, int64, error)
+	// GetByShipmentID returns the order the shipment belongs to, or
+	// ErrShipmentNotFound
+	GetByShipmentID(ctx context.Context, shipmentID string) (*Order, error)
+	// ListForFulfillment returns the confirmed and processing orders, oldest
+	// first, with a shipment in the warehouse in the given status
+	ListForFulfillment(ctx context.Context, warehouseID string, status ShipmentStatus, limit int) ([]*Order, error)
+	// SaveShipment saves a shipment that moved on from the status from, with
+	// the order's status. It fails with ErrShipmentState when the stored
+	// shipment is no longer in that status, so two people cannot take the
+	// same shipment.
+	SaveShipment(ctx context.Context, order *Order, shipment *Shipment, from ShipmentStatus) error
+	// CancellationStats counts the orders cancelled in [from, to) by
+	// reason, most first
+	CancellationStats(ctx context.Context, from, to time.Time) ([]CancellationStat, error)
 }
 
 type Service interface {
@@ -67,8 +233,13 @@ type Service interface {
 
 type CreateOrderItem struct {
 	ProductID string  `json:"product_id"`
+	MerchantID string `json:"merchant_id"`
 	Quantity  int     `json:"quantity"`
 	Price     float64 `json:"price"`
+	FlashSaleID string `json:"flash_sale_id,omitempty"`
+	BundleID       string `json:"bundle_id,omitempty"`
+	BundleQuantity int    `json:"bundle_quantity,omitempty"`
+	Product        ProductSnapshot `json:"product"`
 }
 
 func NewOrder(userID string, items []CreateOrderItem, shippingAddress Address) (*Order, error) {
@@ -76,7 +247,7 @@ func NewOrder(userID string, items []CreateOrderItem, shippingAddress Address) (
 		return nil, errors.New("invalid order data")
 	}
 
-	orderID := uuid.New().String()
+	orderID := id.New()
 	var orderItems []OrderItem
 	var totalAmount float64
 
@@ -87,12 +258,17 @@ func NewOrder(userID string, items []CreateOrderItem, shippingAddress Address) (
 
 		subtotal := float64(item.Quantity) * item.Price
 		orderItem := OrderItem{
-			ID:        uuid.New().String(),
+			ID:        id.New(),
 			OrderID:   orderID,
 			ProductID: item.ProductID,
+			MerchantID: item.MerchantID,
 			Quantity:  item.Quantity,
 			Price:     item.Price,
 			Subtotal:  subtotal,
+			FlashSaleID: item.FlashSaleID,
+			BundleID:       item.BundleID,
+			BundleQuantity: item.BundleQuantity,
+			Product:        item.Product,
 		}
 		orderItems = append(orderItems, orderItem)
 		totalAmount += subtotal
@@ -104,21 +280,88 @@ func NewOrder(userID string, items []CreateOrderItem, shippingAddress Address) (
 		Items:           orderItems,
 		TotalAmount:     totalAmount,
 		Status:          StatusPending,
+		PaymentStatus:   PaymentStatusUnpaid,
 		ShippingAddress: shippingAddress,
 		CreatedAt:       time.Now(),
 		UpdatedAt:       time.Now(),
 	}, nil
 }
 
-func (o *Order) CanBeCancelled() bool {
-	return o.Status == StatusPending || o.Status == StatusConfirmed
+// NumberGenerator hands out order numbers that are unique across every
+// instance of the API.
+type NumberGenerator interface {
+	Next(ctx context.Context, at time.Time) (string, error)
+}
+
+// PrefixSource gives the order number prefix admins have set, or "" to
+// keep the configured one.
+type PrefixSource interface {
+	NumberPrefix(ctx context.Context) (string, error)
+}
+
+// PurchaseHistory counts what customers have bought, for products limited
+// per customer.
+type PurchaseHistory interface {
+	// PurchasedQuantities returns how many of each of the products the
+	// user has in orders that were not cancelled
+	PurchasedQuantities(ctx context.Context, userID string, productIDs []string) (map[string]int, error)
+}
+
+// FormatNumber formats the nth number of the order sequence for an order
+// placed at, such as ORD-2026-000123. The year is only informative: the
+// sequence does not restart with it.
+func FormatNumber(prefix string, at time.Time, n int64, digits int) string {
+	return fmt.Sprintf("%s-%d-%0*d", prefix, at.UTC().Year(), digits, n)
+}
+
+// InvoiceNumber is the number printed on the order's invoice, such as
+// INV-20260102-ORD-2026-000123, from the day it was placed. Orders without
+// a number are invoiced by their ID.
+func (o *Order) InvoiceNumber() string {
+	reference := o.Number
+	if reference == "" {
+		reference = o.ID
+	}
+	return fmt.Sprintf("INV-%s-%s", o.CreatedAt.UTC().Format("20060102"), reference)
+}
+
+// Hold keeps the order from being paid until it has been reviewed.
+func (o *Order) Hold() {
+	o.Status = StatusOnHold
+	o.UpdatedAt = time.Now()
+}
+
+// Release lets a held order go ahead as if it had just been placed.
+func (o *Order) Release() error {
+	if o.Status != StatusOnHold {
+		return errors.New("order is not on hold")
+	}
+	o.Status = StatusPending
+	o.UpdatedAt = time.Now()
+	return nil
+}
+
+// PayOnDelivery confirms a pending order that is to be paid in cash when it
+// is delivered, so it can be fulfilled before it is paid.
+func (o *Order) PayOnDelivery() error {
+	if o.Status != StatusPending || o.PaymentStatus != PaymentStatusUnpaid {
+		return errors.New("order is not awaiting payment")
+	}
+	o.Status = StatusConfirmed
+	o.PaymentStatus = PaymentStatusCashOnDelivery
+	o.UpdatedAt = time.Now()
+	return nil
 }
 
-func (o *Order) Cancel() error {
-	if !o.CanBeCancelled() {
-		return errors.New("order cannot be cancelled")
+// PayOnInvoice confirms a pending order billed to the organization, so it
+// can be fulfilled before the invoice is paid.
+func (o *Order) PayOnInvoice(organizationID string) error {
+	if o.Status != StatusPending || o.PaymentStatus != PaymentStatusUnpaid {
+		return errors.New("order is not awaiting payment")
 	}
-	o.Status = StatusCancelled
+	o.Status = StatusConfirmed
+	o.PaymentStatus = PaymentStatusInvoiced
+	o.OrganizationID = organizationID
 	o.UpdatedAt = time.Now()
 	return nil
 }
@@ -128,10 +371,137 @@ func (o *Order) UpdateStatus(status Status) {
 	o.UpdatedAt = time.Now()
 }
 
+// RecordPayment sets what the order's settled payments add up to. A
+// pending order is confirmed once it is paid in full; a cash on delivery
+// or invoiced order stays so until it is paid, and a refunded order stays
+// refunded.
+func (o *Order) RecordPayment(paid float64, settled bool) {
+	o.PaidAmount = paid
+	switch {
+	case o.PaymentStatus == PaymentStatusRefunded:
+	case settled:
+		o.PaymentStatus = PaymentStatusPaid
+		if o.Status == StatusPending {
+			o.Status = StatusConfirmed
+		}
+	case paid > 0:
+		o.PaymentStatus = PaymentStatusPartiallyPaid
+	case o.PaymentStatus == PaymentStatusCashOnDelivery, o.PaymentStatus == PaymentStatusInvoiced:
+	default:
+		o.PaymentStatus = PaymentStatusUnpaid
+	}
+	o.UpdatedAt = time.Now()
+}
+
 func (o *Order) IsCompleted() bool {
 	return o.Status == StatusDelivered
 }
 
+func (i *OrderItem) ApplyCommission(ruleID string, amount float64) {
+	i.CommissionRuleID = ruleID
+	i.CommissionAmount = amount
+}
+
+// MerchantPayout returns what the merchant is owed for the item after the
+// marketplace commission has been deducted.
+func (i *OrderItem) MerchantPayout() float64 {
+	return math.Round((i.Subtotal-i.CommissionAmount)*100) / 100
+}
+
+// TallyCommission totals the items' commission and merchant payouts on the
+// order, once commission has been applied to them.
+func (o *Order) TallyCommission() {
+	var commission, payout float64
+	for _, item := range o.Items {
+		commission += item.CommissionAmount
+		payout += item.MerchantPayout()
+	}
+	o.CommissionTotal = math.Round(commission*100) / 100
+	o.MerchantPayout = math.Round(payout*100) / 100
+}
+
+// ChargeShipping adds the delivery fee to the order total.
+func (o *Order) ChargeShipping(charge ShippingCharge) {
+	o.TotalAmount += charge.Fee - o.Shipping.Fee
+	o.Shipping = charge
+}
+
+// AssignWarehouses sets the source warehouse on each item, splitting an item
+// in two when its quantity is sourced from more than one warehouse, and
+// groups the result into one shipment per warehouse.
+func (o *Order) AssignWarehouses(allocations []WarehouseAllocation) {
+	pending := make(map[string][]WarehouseAllocation)
+	for _, a := range allocations {
+		pending[a.ProductID] = append(pending[a.ProductID], a)
+	}
+
+	var items []OrderItem
+	for _, item := range o.Items {
+		remaining := item.Quantity
+		first := true
+		for remaining > 0 && len(pending[item.ProductID]) > 0 {
+			alloc := &pending[item.ProductID][0]
+			qty := alloc.Quantity
+			if qty > remaining {
+				qty = remaining
+			}
+
+			part := item
+			if !first {
+				part.ID = id.New()
+			}
+			part.Quantity = qty
+			part.Subtotal = float64(qty) * item.Price
+			part.WarehouseID = alloc.WarehouseID
+			items = append(items, part)
+
+			first = false
+			remaining -= qty
+			alloc.Quantity -= qty
+			if alloc.Quantity == 0 {
+				pending[item.ProductID] = pending[item.ProductID][1:]
+			}
+		}
+		if remaining > 0 {
+			part := item
+			if !first {
+				part.ID = id.New()
+			}
+			part.Quantity = remaining
+			part.Subtotal = float64(remaining) * item.Price
+			items = append(items, part)
+		}
+	}
+	o.Items = items
+
+	shipments := make(map[string]string)
+	o.Shipments = nil
+	for i := range o.Items {
+		warehouseID := o.Items[i].WarehouseID
+		if warehouseID == "" {
+			continue
+		}
+		shipmentID, ok := shipments[warehouseID]
+		if !ok {
+			shipmentID = id.New()
+			shipments[warehouseID] = shipmentID
+			o.Shipments = append(o.Shipments, Shipment{
+				ID:          shipmentID,
+				OrderID:     o.ID,
+				WarehouseID: warehouseID,
+				Status:      ShipmentStatusPending,
+				CreatedAt:   time.Now(),
+				UpdatedAt:   time.Now(),
+			})
+		}
+		o.Items[i].ShipmentID = shipmentID
+	}
+}
+
+func (o *Order) IsSplitShipment() bool {
+	return len(o.Shipments) > 1
+}
+
 func (o *Order) IsCancelled() bool {
-	return o.Status == StatusCancelled || o.Status == StatusRefunded
+	return o.Status == StatusCancelling || o.Status == StatusCancelled || o.Status == StatusRefunded
 }
\ No newline at end of file
@@ -0,0 +1,34 @@
+package order
+
+import "online-shop/internal/domain/product"
+
+// ProductSnapshot is what an order item shows of its product, copied when
+// the order is placed so that the order reads the same after the product is
+// edited or deleted. Items ordered before snapshots were taken have none.
+type ProductSnapshot struct {
+	Name string `json:"name"`
+	Slug string `json:"slug,omitempty"`
+	// Image is the product's first image
+	Image      string              `json:"image,omitempty"`
+	Attributes []SnapshotAttribute `json:"attributes,omitempty" gorm:"type:jsonb;serializer:json"`
+}
+
+// SnapshotAttribute is one of the specifications of an ordered product.
+type SnapshotAttribute struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+	Value string `json:"value"`
+	Unit  string `json:"unit,omitempty"`
+}
+
+// NewProductSnapshot copies what an order item shows of the product.
+func NewProductSnapshot(p *product.Product) ProductSnapshot {
+	s := ProductSnapshot{Name: p.Name, Slug: p.Slug}
+	if len(p.Images) > 0 {
+		s.Image = p.Images[0]
+	}
+	for _, a := range p.Attributes {
+		s.Attributes = append(s.Attributes, SnapshotAttribute{Key: a.Key, Label: a.Label, Value: a.Value, Unit: a.Unit})
+	}
+	return s
+}
@@ -0,0 +1,63 @@
+package order
+
+import "time"
+
+// Tracking is what anyone holding an order's number and email may see. It
+// leaves out who placed the order, where exactly it is going and how it was
+// paid.
+type Tracking struct {
+	Number        string             `json:"number"`
+	Status        Status             `json:"status"`
+	PaymentStatus PaymentStatus      `json:"payment_status"`
+	TotalAmount   float64            `json:"total_amount"`
+	ItemCount     int                `json:"item_count"`
+	ShipTo        TrackingAddress    `json:"ship_to"`
+	Shipments     []TrackingShipment `json:"shipments"`
+	PlacedAt      time.Time          `json:"placed_at"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+}
+
+type TrackingAddress struct {
+	City    string `json:"city"`
+	Country string `json:"country"`
+}
+
+type TrackingShipment struct {
+	Status         ShipmentStatus `json:"status"`
+	Carrier        string         `json:"carrier"`
+	TrackingNumber string         `json:"tracking_number"`
+	Items          int            `json:"items"`
+	ShippedAt      *time.Time     `json:"shipped_at,omitempty"`
+}
+
+func (o *Order) Tracking() *Tracking {
+	t := &Tracking{
+		Number:        o.Number,
+		Status:        o.Status,
+		PaymentStatus: o.PaymentStatus,
+		TotalAmount:   o.TotalAmount,
+		ShipTo: TrackingAddress{
+			City:    o.ShippingAddress.City,
+			Country: o.ShippingAddress.Country,
+		},
+		Shipments: []TrackingShipment{},
+		PlacedAt:  o.CreatedAt,
+		UpdatedAt: o.UpdatedAt,
+	}
+
+	shipped := map[string]int{}
+	for _, item := range o.Items {
+		t.ItemCount += item.Quantity
+		shipped[item.ShipmentID] += item.Quantity
+	}
+	for _, s := range o.Shipments {
+		t.Shipments = append(t.Shipments, TrackingShipment{
+			Status:         s.Status,
+			Carrier:        s.Carrier,
+			TrackingNumber: s.TrackingNumber,
+			Items:          shipped[s.ID],
+			ShippedAt:      s.ShippedAt,
+		})
+	}
+	return t
+}
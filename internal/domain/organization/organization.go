@@ -0,0 +1,233 @@
+package organization
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"online-shop/pkg/id"
+)
+
+var (
+	ErrNotFound         = errors.New("organization not found")
+	ErrMemberNotFound   = errors.New("organization member not found")
+	ErrApprovalNotFound = errors.New("purchase approval not found")
+	ErrInvoiceNotFound  = errors.New("invoice not found")
+	// ErrAlreadyMember is returned for a user who already belongs to an
+	// organization; a user buys for one organization at most
+	ErrAlreadyMember = errors.New("user already belongs to an organization")
+	// ErrNotPermitted is a change the member's role does not allow
+	ErrNotPermitted = errors.New("organization role does not allow this")
+	ErrLastAdmin    = errors.New("an organization needs at least one admin")
+	ErrFull         = errors.New("organization has as many members as it can")
+	ErrInvalidChain = errors.New("invalid approval chain")
+	// ErrNotInvoiceable is an order the buyer cannot put on account
+	ErrNotInvoiceable = errors.New("order cannot be paid on invoice")
+	// ErrCreditExceeded is returned when an invoice would take the
+	// organization over its credit limit, or it has invoices overdue
+	ErrCreditExceeded = errors.New("organization credit limit exceeded")
+	ErrDecided        = errors.New("purchase approval was already decided")
+	ErrInvoiceNotOpen = errors.New("invoice is not open")
+)
+
+// Tolerance absorbs float rounding when amounts are compared with the credit
+// limit
+const Tolerance = 0.01
+
+// MaxApprovalSteps caps how long an approval chain can be
+const MaxApprovalSteps = 5
+
+type Role string
+
+const (
+	// RoleAdmin members manage the organization and its members, and can
+	// approve any step of a purchase
+	RoleAdmin Role = "admin"
+	// RoleApprover members approve purchases and see all of the
+	// organization's orders
+	RoleApprover Role = "approver"
+	// RoleBuyer members place orders and see their own
+	RoleBuyer Role = "buyer"
+)
+
+// Organization is a business customer whose members buy on its account,
+// paying on invoice within the credit the shop grants it.
+type Organization struct {
+	ID    string `json:"id" gorm:"primaryKey"`
+	Name  string `json:"name"`
+	TaxID string `json:"tax_id,omitempty"`
+	// CreditLimit is how much the organization can owe on open invoices;
+	// it is zero, and nothing is bought on invoice, until the shop grants
+	// credit terms
+	CreditLimit float64 `json:"credit_limit"`
+	// PaymentTermsDays is how long invoices have to be paid, 30 for net-30
+	PaymentTermsDays int `json:"payment_terms_days"`
+	// ApprovalChain is the approvals a purchase on invoice needs before it
+	// is placed
+	ApprovalChain []ApprovalStep `json:"approval_chain" gorm:"type:jsonb;serializer:json"`
+	CreatedBy     string         `json:"created_by"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+// Member is a user buying for an organization.
+type Member struct {
+	UserID         string    `json:"user_id" gorm:"primaryKey"`
+	OrganizationID string    `json:"organization_id" gorm:"index"`
+	Role           Role      `json:"role"`
+	AddedBy        string    `json:"added_by"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func (Member) TableName() string {
+	return "organization_members"
+}
+
+// ApprovalStep has purchases of at least MinAmount approved by a member
+// with Role. Admins can approve any step.
+type ApprovalStep struct {
+	MinAmount float64 `json:"min_amount"`
+	Role      Role    `json:"role"`
+}
+
+// New creates an organization, with its creator as its first admin. It
+// buys on invoice with the given terms once the shop sets its credit limit.
+func New(name, taxID, createdBy string, paymentTermsDays int) (*Organization, *Member) {
+	now := time.Now()
+	org := &Organization{
+		ID:               id.New(),
+		Name:             strings.TrimSpace(name),
+		TaxID:            strings.TrimSpace(taxID),
+		PaymentTermsDays: paymentTermsDays,
+		CreatedBy:        createdBy,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	return org, NewMember(org.ID, createdBy, RoleAdmin, createdBy)
+}
+
+func NewMember(organizationID, userID string, role Role, addedBy string) *Member {
+	now := time.Now()
+	return &Member{
+		UserID:         userID,
+		OrganizationID: organizationID,
+		Role:           role,
+		AddedBy:        addedBy,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+}
+
+// SetApprovalChain replaces the approvals purchases need. Steps are taken in
+// order, so their amounts cannot go down; an empty chain places purchases
+// straight away.
+func (o *Organization) SetApprovalChain(steps []ApprovalStep) error {
+	if len(steps) > MaxApprovalSteps {
+		return fmt.Errorf("%w: at most %d steps", ErrInvalidChain, MaxApprovalSteps)
+	}
+	for i, step := range steps {
+		if step.MinAmount < 0 {
+			return fmt.Errorf("%w: step %d has a negative amount", ErrInvalidChain, i+1)
+		}
+		if step.Role != RoleApprover && step.Role != RoleAdmin {
+			return fmt.Errorf("%w: step %d must be approved by an approver or an admin", ErrInvalidChain, i+1)
+		}
+		if i > 0 && step.MinAmount < steps[i-1].MinAmount {
+			return fmt.Errorf("%w: step %d is for less than the step before it", ErrInvalidChain, i+1)
+		}
+	}
+	o.ApprovalChain = steps
+	o.UpdatedAt = time.Now()
+	return nil
+}
+
+// StepsFor returns the approvals a purchase of amount needs.
+func (o *Organization) StepsFor(amount float64) []ApprovalStep {
+	var steps []ApprovalStep
+	for _, step := range o.ApprovalChain {
+		if amount+Tolerance >= step.MinAmount {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+// Exposure is what an organization owes on its open invoices.
+type Exposure struct {
+	Invoices int     `json:"invoices"`
+	Amount   float64 `json:"amount"`
+	Overdue  int     `json:"overdue"`
+}
+
+// Credit is an organization's credit limit and how much of it is used.
+type Credit struct {
+	Limit     float64  `json:"limit"`
+	Available float64  `json:"available"`
+	Owed      Exposure `json:"owed"`
+}
+
+func (o *Organization) Credit(owed Exposure) Credit {
+	available := o.CreditLimit - owed.Amount
+	if available < 0 {
+		available = 0
+	}
+	return Credit{Limit: o.CreditLimit, Available: available, Owed: owed}
+}
+
+// CheckCredit reports whether the organization, already owing owed, can
+// buy amount more on invoice. No more is bought while an invoice is overdue.
+func (o *Organization) CheckCredit(owed Exposure, amount float64) error {
+	if o.CreditLimit <= 0 {
+		return fmt.Errorf("%w: the organization has no credit terms", ErrNotInvoiceable)
+	}
+	if owed.Overdue > 0 {
+		return fmt.Errorf("%w: %d invoices are overdue", ErrCreditExceeded, owed.Overdue)
+	}
+	if owed.Amount+amount > o.CreditLimit+Tolerance {
+		return fmt.Errorf("%w: the credit limit is %.2f and %.2f is already owed", ErrCreditExceeded, o.CreditLimit, owed.Amount)
+	}
+	return nil
+}
+
+// Oversees reports whether the member sees and approves the purchases of
+// the whole organization, rather than only their own.
+func (m *Member) Oversees() bool {
+	return m.Role == RoleAdmin || m.Role == RoleApprover
+}
+
+func (m *Member) canApprove(step ApprovalStep) bool {
+	return m.Role == RoleAdmin || m.Role == step.Role
+}
+
+// CountAdmins counts the admins among members.
+func CountAdmins(members []*Member) int {
+	n := 0
+	for _, m := range members {
+		if m.Role == RoleAdmin {
+			n++
+		}
+	}
+	return n
+}
+
+type Repository interface {
+	Create(ctx context.Context, o *Organization) error
+	GetByID(ctx context.Context, id string) (*Organization, error)
+	// Lock is GetByID holding the organization's row until the command's
+	// transaction ends, so purchases on its account are checked against
+	// its credit one at a time
+	Lock(ctx context.Context, id string) (*Organization, error)
+	Update(ctx context.Context, o *Organization) error
+	// List returns organizations by name
+	List(ctx context.Context, limit, offset int) ([]*Organization, error)
+	// GetMember returns the user's membership, or ErrMemberNotFound when
+	// they buy for no organization
+	GetMember(ctx context.Context, userID string) (*Member, error)
+	// ListMembers returns the organization's members, oldest first
+	ListMembers(ctx context.Context, organizationID string) ([]*Member, error)
+	SaveMember(ctx context.Context, m *Member) error
+	DeleteMember(ctx context.Context, userID string) error
+}
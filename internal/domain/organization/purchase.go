@@ -0,0 +1,228 @@
+package organization
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"online-shop/pkg/id"
+)
+
+type ApprovalStatus string
+
+const (
+	ApprovalPending  ApprovalStatus = "pending"
+	ApprovalApproved ApprovalStatus = "approved"
+	ApprovalRejected ApprovalStatus = "rejected"
+	// ApprovalCancelled approvals were for orders that were cancelled or
+	// paid otherwise before they were decided
+	ApprovalCancelled ApprovalStatus = "cancelled"
+)
+
+// Approval is a purchase on invoice waiting for the approvals of the
+// organization's chain, taken one step after another.
+type Approval struct {
+	ID             string          `json:"id" gorm:"primaryKey"`
+	OrganizationID string          `json:"organization_id" gorm:"index"`
+	OrderID        string          `json:"order_id" gorm:"index"`
+	RequestedBy    string          `json:"requested_by" gorm:"index"`
+	Amount         float64         `json:"amount"`
+	PurchaseOrder  string          `json:"purchase_order,omitempty"`
+	Status         ApprovalStatus  `json:"status" gorm:"index"`
+	Steps          []ApprovalState `json:"steps" gorm:"type:jsonb;serializer:json"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+func (Approval) TableName() string {
+	return "purchase_approvals"
+}
+
+// ApprovalState is a step of the chain and, once it is decided, who
+// decided it.
+type ApprovalState struct {
+	ApprovalStep
+	DecidedBy string     `json:"decided_by,omitempty"`
+	Approved  bool       `json:"approved"`
+	Note      string     `json:"note,omitempty"`
+	DecidedAt *time.Time `json:"decided_at,omitempty"`
+}
+
+// NewApproval asks for the steps to approve the order, bought for amount.
+func NewApproval(organizationID, orderID, requestedBy string, amount float64, purchaseOrder string, steps []ApprovalStep) *Approval {
+	now := time.Now()
+	states := make([]ApprovalState, len(steps))
+	for i, step := range steps {
+		states[i] = ApprovalState{ApprovalStep: step}
+	}
+	return &Approval{
+		ID:             id.New(),
+		OrganizationID: organizationID,
+		OrderID:        orderID,
+		RequestedBy:    requestedBy,
+		Amount:         amount,
+		PurchaseOrder:  strings.TrimSpace(purchaseOrder),
+		Status:         ApprovalPending,
+		Steps:          states,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+}
+
+// Decide approves or rejects the next step as member. No one decides on a
+// purchase they asked for, or decides two steps of the same purchase; one
+// rejection rejects it.
+func (a *Approval) Decide(member *Member, approve bool, note string, now time.Time) error {
+	if a.Status != ApprovalPending {
+		return fmt.Errorf("%w: it is %s", ErrDecided, a.Status)
+	}
+	if member.OrganizationID != a.OrganizationID {
+		return ErrApprovalNotFound
+	}
+	if member.UserID == a.RequestedBy {
+		return fmt.Errorf("%w: purchases are decided by someone other than the buyer", ErrNotPermitted)
+	}
+
+	next := -1
+	for i, state := range a.Steps {
+		if state.DecidedBy == member.UserID {
+			return fmt.Errorf("%w: each step is decided by someone else", ErrNotPermitted)
+		}
+		if state.DecidedAt == nil && next < 0 {
+			next = i
+		}
+	}
+	if next < 0 {
+		return fmt.Errorf("%w: every step is decided", ErrDecided)
+	}
+	if !member.canApprove(a.Steps[next].ApprovalStep) {
+		return fmt.Errorf("%w: step %d is decided by an %s", ErrNotPermitted, next+1, a.Steps[next].Role)
+	}
+
+	a.Steps[next].DecidedBy = member.UserID
+	a.Steps[next].Approved = approve
+	a.Steps[next].Note = strings.TrimSpace(note)
+	a.Steps[next].DecidedAt = &now
+	switch {
+	case !approve:
+		a.Status = ApprovalRejected
+	case next == len(a.Steps)-1:
+		a.Status = ApprovalApproved
+	}
+	a.UpdatedAt = now
+	return nil
+}
+
+// Cancel closes an approval whose order can no longer be bought on invoice.
+func (a *Approval) Cancel(now time.Time) {
+	a.Status = ApprovalCancelled
+	a.UpdatedAt = now
+}
+
+// ApprovalFilter narrows a listing to an organization, and to the purchases
+// of one buyer or in one status when they are given.
+type ApprovalFilter struct {
+	OrganizationID string
+	RequestedBy    string
+	Status         ApprovalStatus
+}
+
+type ApprovalRepository interface {
+	Create(ctx context.Context, a *Approval) error
+	GetByID(ctx context.Context, id string) (*Approval, error)
+	// GetPendingByOrderID returns the order's pending approval, or
+	// ErrApprovalNotFound when it has none
+	GetPendingByOrderID(ctx context.Context, orderID string) (*Approval, error)
+	Update(ctx context.Context, a *Approval) error
+	// List returns the approvals matching the filter, newest first
+	List(ctx context.Context, filter ApprovalFilter, limit, offset int) ([]*Approval, error)
+}
+
+type InvoiceStatus string
+
+const (
+	InvoiceOpen InvoiceStatus = "open"
+	InvoicePaid InvoiceStatus = "paid"
+)
+
+// Invoice is an order bought on the organization's account, to be paid by
+// DueAt. It is backed by a pending invoice payment, so cancelling the order
+// cancels what is owed.
+type Invoice struct {
+	ID             string        `json:"id" gorm:"primaryKey"`
+	OrganizationID string        `json:"organization_id" gorm:"index"`
+	OrderID        string        `json:"order_id" gorm:"uniqueIndex"`
+	PaymentID      string        `json:"payment_id"`
+	Amount         float64       `json:"amount"`
+	PurchaseOrder  string        `json:"purchase_order,omitempty"`
+	Status         InvoiceStatus `json:"status" gorm:"index"`
+	IssuedAt       time.Time     `json:"issued_at"`
+	DueAt          time.Time     `json:"due_at" gorm:"index"`
+	// Reference is the bank transfer the invoice was paid with
+	Reference string     `json:"reference,omitempty"`
+	PaidAt    *time.Time `json:"paid_at,omitempty"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+func (Invoice) TableName() string {
+	return "organization_invoices"
+}
+
+// NewInvoice bills the order to the organization on its payment terms.
+func NewInvoice(org *Organization, orderID, paymentID string, amount float64, purchaseOrder string, now time.Time) *Invoice {
+	return &Invoice{
+		ID:             id.New(),
+		OrganizationID: org.ID,
+		OrderID:        orderID,
+		PaymentID:      paymentID,
+		Amount:         amount,
+		PurchaseOrder:  strings.TrimSpace(purchaseOrder),
+		Status:         InvoiceOpen,
+		IssuedAt:       now,
+		DueAt:          now.AddDate(0, 0, org.PaymentTermsDays),
+		UpdatedAt:      now,
+	}
+}
+
+// Overdue reports whether the invoice is open past its due date.
+func (i *Invoice) Overdue(now time.Time) bool {
+	return i.Status == InvoiceOpen && now.After(i.DueAt)
+}
+
+// Pay records the transfer the invoice was paid with.
+func (i *Invoice) Pay(reference string, now time.Time) error {
+	if i.Status != InvoiceOpen {
+		return fmt.Errorf("%w: it is %s", ErrInvoiceNotOpen, i.Status)
+	}
+	i.Status = InvoicePaid
+	i.Reference = strings.TrimSpace(reference)
+	i.PaidAt = &now
+	i.UpdatedAt = now
+	return nil
+}
+
+// InvoiceFilter narrows a listing to an organization and a status; empty
+// fields match all.
+type InvoiceFilter struct {
+	OrganizationID string
+	Status         InvoiceStatus
+}
+
+type InvoiceRepository interface {
+	Create(ctx context.Context, i *Invoice) error
+	GetByID(ctx context.Context, id string) (*Invoice, error)
+	Update(ctx context.Context, i *Invoice) error
+	// List returns the invoices matching the filter, soonest due first
+	List(ctx context.Context, filter InvoiceFilter, limit, offset int) ([]*Invoice, error)
+	// Exposure adds up the organization's open invoices on orders that are
+	// not cancelled, counting those due before now as overdue
+	Exposure(ctx context.Context, organizationID string, now time.Time) (Exposure, error)
+}
+
+// Purchase is what buying an order on invoice led to: the invoice, or the
+// approval it waits for.
+type Purchase struct {
+	Approval *Approval `json:"approval,omitempty"`
+	Invoice  *Invoice  `json:"invoice,omitempty"`
+}
@@ -1,22 +1,44 @@
 package payment
 
 import (
+	"context"
+	"errors"
 	"time"
 
-	"github.com/google/uuid"
+	"online-shop/pkg/id"
 )
 
+// ErrRefundDeclined is returned by a PaymentProvider that turns a refund
+// down, such as for a transaction that has not settled yet
+var ErrRefundDeclined = errors.New("refund was declined")
+
 type Payment struct {
 	ID              string    `json:"id" gorm:"primaryKey"`
 	OrderID         string    `json:"order_id"`
+	// DraftOrderID is the draft order paid through its link; the payment
+	// has no order until the draft is converted into one
+	DraftOrderID    string    `json:"draft_order_id,omitempty" gorm:"index"`
 	UserID          string    `json:"user_id"`
 	Amount          float64   `json:"amount"`
 	Currency        string    `json:"currency"`
 	Method          Method    `json:"method"`
 	Status          Status    `json:"status"`
 	TransactionID   string    `json:"transaction_id"`
-	ExternalID      string    `json:"external_id"`
+	ExternalID      string    `json:"external_id" gorm:"serializer:encrypted"`
+	// ExternalIDIndex is a blind index of ExternalID, which is stored
+	// encrypted and so cannot be queried directly
+	ExternalIDIndex string    `json:"-" gorm:"index"`
 	PaymentURL      string    `json:"payment_url"`
+	// Installment numbers the payments of an installment plan from 1
+	Installment     int        `json:"installment,omitempty"`
+	DueAt           *time.Time `json:"due_at,omitempty"`
+	// RefundedAmount is what was paid back of a refunded payment; the rest
+	// was kept, such as a cancellation fee
+	RefundedAmount  float64    `json:"refunded_amount"`
+	RefundedAt      *time.Time `json:"refunded_at,omitempty"`
+	// ReceiptSentAt is when the customer was emailed the receipt of the
+	// paid payment
+	ReceiptSentAt   *time.Time `json:"receipt_sent_at,omitempty"`
 	ExpiresAt       time.Time `json:"expires_at"`
 	ProcessedAt     *time.Time `json:"processed_at"`
 	CreatedAt       time.Time `json:"created_at"`
@@ -30,6 +52,16 @@ const (
 	MethodBankTransfer Method = "bank_transfer"
 	MethodEWallet      Method = "e_wallet"
 	MethodVirtualAccount Method = "virtual_account"
+	// MethodCashOnDelivery is paid to the courier, who remits the cash to
+	// the shop; it never reaches the payment provider
+	MethodCashOnDelivery Method = "cash_on_delivery"
+	// MethodInvoice is billed to an organization and paid by bank transfer
+	// within its payment terms; it never reaches the payment provider
+	MethodInvoice Method = "invoice"
+	// MethodOffline was taken by staff outside the payment provider, such
+	// as cash at the counter or a bank transfer for a phone order; its
+	// transaction ID is the reference they recorded
+	MethodOffline Method = "offline"
 )
 
 type Status string
@@ -44,12 +76,17 @@ const (
 )
 
 type Repository interface {
-	Create(payment *Payment) error
-	GetByID(id string) (*Payment, error)
-	GetByOrderID(orderID string) (*Payment, error)
-	GetByExternalID(externalID string) (*Payment, error)
-	Update(payment *Payment) error
-	UpdateStatus(paymentID string, status Status, transactionID string) error
+	Create(ctx context.Context, payment *Payment) error
+	GetByID(ctx context.Context, id string) (*Payment, error)
+	GetByOrderID(ctx context.Context, orderID string) (*Payment, error)
+	// ListByOrderID returns every payment made against an order, oldest first
+	ListByOrderID(ctx context.Context, orderID string) ([]*Payment, error)
+	GetByExternalID(ctx context.Context, externalID string) (*Payment, error)
+	// ListCreatedBetween returns the payments created in [from, to), oldest
+	// first
+	ListCreatedBetween(ctx context.Context, from, to time.Time) ([]*Payment, error)
+	Update(ctx context.Context, payment *Payment) error
+	UpdateStatus(ctx context.Context, paymentID string, status Status, transactionID string) error
 }
 
 type Service interface {
@@ -61,9 +98,12 @@ type Service interface {
 }
 
 type PaymentProvider interface {
-	CreatePayment(payment *Payment) (*PaymentResponse, error)
-	GetPaymentStatus(externalID string) (*PaymentStatus, error)
-	RefundPayment(externalID string, amount float64) error
+	CreatePayment(ctx context.Context, payment *Payment) (*PaymentResponse, error)
+	GetPaymentStatus(ctx context.Context, externalID string) (*PaymentStatus, error)
+	// RefundPayment pays amount of a transaction back. A refund asked for
+	// again with the same key is answered with the first one rather than
+	// refunding twice.
+	RefundPayment(ctx context.Context, externalID string, amount float64, key string) error
 }
 
 type PaymentResponse struct {
@@ -81,7 +121,7 @@ type PaymentStatus struct {
 
 func NewPayment(orderID, userID string, amount float64, method Method) *Payment {
 	return &Payment{
-		ID:         uuid.New().String(),
+		ID:         id.New(),
 		OrderID:    orderID,
 		UserID:     userID,
 		Amount:     amount,
@@ -112,6 +152,28 @@ func (p *Payment) MarkAsExpired() {
 	p.UpdatedAt = time.Now()
 }
 
+func (p *Payment) MarkAsCancelled() {
+	p.Status = StatusCancelled
+	p.UpdatedAt = time.Now()
+}
+
+// MarkAsRefunded records that amount of the payment was paid back.
+func (p *Payment) MarkAsRefunded(amount float64) {
+	p.Status = StatusRefunded
+	p.RefundedAmount += amount
+	now := time.Now()
+	p.RefundedAt = &now
+	p.UpdatedAt = now
+}
+
+// Refundable is what can still be paid back of the payment.
+func (p *Payment) Refundable() float64 {
+	if !p.CanBeRefunded() {
+		return 0
+	}
+	return p.Amount - p.RefundedAmount
+}
+
 func (p *Payment) IsExpired() bool {
 	return time.Now().After(p.ExpiresAt)
 }
@@ -0,0 +1,82 @@
+package payment
+
+import (
+	"context"
+	"time"
+)
+
+// Receipt acknowledges a payment a customer made. A refunded payment keeps
+// its receipt, showing what was paid back.
+type Receipt struct {
+	Number         string    `json:"number"`
+	PaymentID      string    `json:"payment_id"`
+	OrderID        string    `json:"order_id"`
+	OrderNumber    string    `json:"order_number,omitempty"`
+	Amount         float64   `json:"amount"`
+	Currency       string    `json:"currency"`
+	Method         Method    `json:"method"`
+	Installment    int       `json:"installment,omitempty"`
+	Status         Status    `json:"status"`
+	RefundedAmount float64   `json:"refunded_amount,omitempty"`
+	TransactionID  string    `json:"transaction_id,omitempty"`
+	PaidAt         time.Time `json:"paid_at"`
+}
+
+// NewReceipt is the receipt of a paid or refunded payment of the order
+// numbered orderNumber.
+func NewReceipt(p *Payment, orderNumber string) Receipt {
+	// Payments settled by the webhook before processed_at was recorded
+	// have none
+	paidAt := p.UpdatedAt
+	if p.ProcessedAt != nil {
+		paidAt = *p.ProcessedAt
+	}
+	return Receipt{
+		Number:         "RCP-" + p.ID,
+		PaymentID:      p.ID,
+		OrderID:        p.OrderID,
+		OrderNumber:    orderNumber,
+		Amount:         p.Amount,
+		Currency:       p.Currency,
+		Method:         p.Method,
+		Installment:    p.Installment,
+		Status:         p.Status,
+		RefundedAmount: p.RefundedAmount,
+		TransactionID:  p.TransactionID,
+		PaidAt:         paidAt,
+	}
+}
+
+// ReceiptDue reports whether the customer is still to be emailed a receipt
+// for the payment. Cash paid to couriers and organization invoices are
+// settled outside the payment provider and are not emailed one.
+func (p *Payment) ReceiptDue() bool {
+	return p.IsPaid() && p.ReceiptSentAt == nil && p.Method != MethodCashOnDelivery && p.Method != MethodInvoice
+}
+
+func (p *Payment) MarkReceiptSent(at time.Time) {
+	p.ReceiptSentAt = &at
+	p.UpdatedAt = at
+}
+
+// ReceiptRepository lists what customers have paid.
+type ReceiptRepository interface {
+	// ListReceipts returns a page of the receipts of the user's paid and
+	// refunded payments, newest first, and how many there are in all
+	ListReceipts(ctx context.Context, userID string, limit, offset int) ([]Receipt, int64, error)
+}
+
+// ReceiptNotice is a receipt emailed to the customer who paid.
+type ReceiptNotice struct {
+	Receipt      Receipt
+	Email        string
+	CustomerName string
+	Locale       string
+	// Outstanding is what is still owed on the order
+	Outstanding float64
+}
+
+// ReceiptNotifier emails customers the receipts of their payments.
+type ReceiptNotifier interface {
+	PaymentReceived(ctx context.Context, n ReceiptNotice) error
+}
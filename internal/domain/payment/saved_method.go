@@ -0,0 +1,153 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"online-shop/pkg/id"
+)
+
+var (
+	ErrMethodNotFound = errors.New("payment method not found")
+	// ErrInvalidMethod is a card sent without the provider's token, with a
+	// bad expiry, or expired
+	ErrInvalidMethod = errors.New("invalid payment method")
+	ErrMethodExpired = errors.New("payment method has expired")
+	// ErrChargeDeclined is returned by a TokenProvider when the provider
+	// turns the charge down
+	ErrChargeDeclined = errors.New("payment was declined")
+	// ErrProviderUnavailable is returned when the provider cannot be
+	// reached, or is failing often enough that calls to it are paused
+	ErrProviderUnavailable = errors.New("payment provider unavailable")
+)
+
+// MaxSavedMethods caps the methods one customer can keep.
+const MaxSavedMethods = 10
+
+// SavedMethod is a card a customer keeps for one-click checkout. Only the
+// provider's token and what the customer needs to recognise the card are
+// stored: the card number is tokenized by the provider in the browser and
+// never reaches the API.
+type SavedMethod struct {
+	ID          string    `json:"id" gorm:"primaryKey"`
+	UserID      string    `json:"-" gorm:"index"`
+	Provider    string    `json:"provider"`
+	Type        Method    `json:"type"`
+	Token       string    `json:"-" gorm:"serializer:encrypted"`
+	Brand       string    `json:"brand,omitempty"`
+	Last4       string    `json:"last4"`
+	ExpiryMonth int       `json:"expiry_month"`
+	ExpiryYear  int       `json:"expiry_year"`
+	Label       string    `json:"label,omitempty"`
+	IsDefault   bool      `json:"is_default"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (SavedMethod) TableName() string {
+	return "payment_methods"
+}
+
+// NewSavedMethod checks a card token from the provider. maskedCard is the
+// provider's masked number, such as 481111-1114, from which the last four
+// digits are kept.
+func NewSavedMethod(userID, provider, token, maskedCard, brand string, expiryMonth, expiryYear int, label string) (*SavedMethod, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, fmt.Errorf("%w: a provider token is required", ErrInvalidMethod)
+	}
+	// A number long enough to be a card is refused wherever it turns up
+	if digits(token) >= 12 && onlyCardCharacters(token) {
+		return nil, fmt.Errorf("%w: send the provider's token, not the card number", ErrInvalidMethod)
+	}
+	n := digits(maskedCard)
+	if n < 4 || n > 10 {
+		return nil, fmt.Errorf("%w: masked_card must be the provider's masked number", ErrInvalidMethod)
+	}
+	if expiryMonth < 1 || expiryMonth > 12 {
+		return nil, fmt.Errorf("%w: expiry_month must be between 1 and 12", ErrInvalidMethod)
+	}
+
+	now := time.Now()
+	m := &SavedMethod{
+		ID:          id.New(),
+		UserID:      userID,
+		Provider:    provider,
+		Type:        MethodCreditCard,
+		Token:       token,
+		Brand:       strings.ToLower(strings.TrimSpace(brand)),
+		Last4:       lastDigits(maskedCard, 4),
+		ExpiryMonth: expiryMonth,
+		ExpiryYear:  expiryYear,
+		Label:       strings.TrimSpace(label),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if m.ExpiredAt(now) {
+		return nil, fmt.Errorf("%w: the card has expired", ErrInvalidMethod)
+	}
+	return m, nil
+}
+
+// ExpiredAt reports whether the card is past the end of its expiry month.
+func (m *SavedMethod) ExpiredAt(at time.Time) bool {
+	end := time.Date(m.ExpiryYear, time.Month(m.ExpiryMonth)+1, 1, 0, 0, 0, 0, time.UTC)
+	return !at.Before(end)
+}
+
+func digits(s string) int {
+	n := 0
+	for _, r := range s {
+		if unicode.IsDigit(r) {
+			n++
+		}
+	}
+	return n
+}
+
+func onlyCardCharacters(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) && r != ' ' && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+func lastDigits(s string, n int) string {
+	var found []rune
+	for i := len(s) - 1; i >= 0 && len(found) < n; i-- {
+		if s[i] >= '0' && s[i] <= '9' {
+			found = append([]rune{rune(s[i])}, found...)
+		}
+	}
+	return string(found)
+}
+
+type MethodRepository interface {
+	Create(ctx context.Context, m *SavedMethod) error
+	GetByID(ctx context.Context, id string) (*SavedMethod, error)
+	// ListByUser returns the user's methods, the default first
+	ListByUser(ctx context.Context, userID string) ([]*SavedMethod, error)
+	Delete(ctx context.Context, id string) error
+	// SetDefault makes one method the user's default and clears the flag on
+	// the others
+	SetDefault(ctx context.Context, userID, id string) error
+}
+
+// TokenProvider charges a saved token without sending the customer to the
+// provider's payment page. A charge that needs 3-D Secure comes back
+// pending with a RedirectURL.
+type TokenProvider interface {
+	ChargeToken(ctx context.Context, pay *Payment, token string) (*ChargeResult, error)
+}
+
+type ChargeResult struct {
+	Status        Status
+	TransactionID string
+	RedirectURL   string
+}
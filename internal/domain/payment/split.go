@@ -0,0 +1,147 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	// ErrInvalidSplit is a split or installment plan that cannot pay what
+	// is outstanding on the order
+	ErrInvalidSplit = errors.New("invalid payment split")
+	// ErrPaymentsOpen is returned when an order already has allocations
+	// waiting to be paid
+	ErrPaymentsOpen = errors.New("order has payments awaiting settlement")
+	ErrAlreadyPaid  = errors.New("order is already paid in full")
+)
+
+const (
+	MaxAllocations  = 5
+	MaxInstallments = 12
+	// Tolerance absorbs float rounding when amounts are compared to a total
+	Tolerance = 0.01
+	// InstallmentGrace is how long an installment stays payable after it
+	// falls due
+	InstallmentGrace = 7 * 24 * time.Hour
+)
+
+// Allocation is the share of an order's total one instrument pays. A saved
+// card is charged straight away; any other method gets a payment link. An
+// installment due later gets its link when the customer comes to pay it.
+type Allocation struct {
+	Method        Method
+	Amount        float64
+	SavedMethodID string
+	Installment   int
+	DueAt         *time.Time
+}
+
+// CheckSplit checks that the allocations pay exactly the outstanding amount.
+func CheckSplit(outstanding float64, allocations []Allocation) error {
+	if len(allocations) == 0 {
+		return fmt.Errorf("%w: at least one allocation is required", ErrInvalidSplit)
+	}
+
+	var sum float64
+	split := 0
+	for _, a := range allocations {
+		if a.Installment == 0 {
+			split++
+		}
+		if a.Amount <= 0 {
+			return fmt.Errorf("%w: every allocation needs a positive amount", ErrInvalidSplit)
+		}
+		if a.SavedMethodID != "" && a.Method != MethodCreditCard {
+			return fmt.Errorf("%w: a saved method pays by credit card", ErrInvalidSplit)
+		}
+		sum += a.Amount
+	}
+	if split > MaxAllocations {
+		return fmt.Errorf("%w: an order can be split across at most %d payments", ErrInvalidSplit, MaxAllocations)
+	}
+	if math.Abs(sum-outstanding) > Tolerance {
+		return fmt.Errorf("%w: allocations add up to %.2f but %.2f is outstanding", ErrInvalidSplit, sum, outstanding)
+	}
+	return nil
+}
+
+// Installments splits an amount into count monthly payments, the first due
+// at start. Amounts are whole units of the currency; the remainder goes on
+// the first installment.
+func Installments(amount float64, count int, method Method, start time.Time) ([]Allocation, error) {
+	if count < 2 || count > MaxInstallments {
+		return nil, fmt.Errorf("%w: installments must be between 2 and %d", ErrInvalidSplit, MaxInstallments)
+	}
+	part := math.Floor(amount / float64(count))
+	if part <= 0 {
+		return nil, fmt.Errorf("%w: the amount is too small for %d installments", ErrInvalidSplit, count)
+	}
+
+	allocations := make([]Allocation, 0, count)
+	for i := 0; i < count; i++ {
+		due := start.AddDate(0, i, 0)
+		a := Allocation{Method: method, Amount: part, Installment: i + 1, DueAt: &due}
+		if i == 0 {
+			a.Amount = amount - part*float64(count-1)
+		}
+		allocations = append(allocations, a)
+	}
+	return allocations, nil
+}
+
+// NewAllocatedPayment is the payment for one allocation of an order.
+func NewAllocatedPayment(orderID, userID string, a Allocation) *Payment {
+	p := NewPayment(orderID, userID, a.Amount, a.Method)
+	p.Installment = a.Installment
+	p.DueAt = a.DueAt
+	if a.DueAt != nil && a.DueAt.After(p.CreatedAt) {
+		p.ExpiresAt = a.DueAt.Add(InstallmentGrace)
+	}
+	return p
+}
+
+// Due reports whether the payment can be paid now.
+func (p *Payment) Due(at time.Time) bool {
+	return p.DueAt == nil || !p.DueAt.After(at)
+}
+
+// Summary reconciles an order's payments against its total. Failed,
+// cancelled and expired payments count for nothing, so what they were meant
+// to cover is outstanding again.
+type Summary struct {
+	Total       float64    `json:"total"`
+	Paid        float64    `json:"paid"`
+	Pending     float64    `json:"pending"`
+	Outstanding float64    `json:"outstanding"`
+	Payments    []*Payment `json:"payments"`
+}
+
+func Summarize(total float64, payments []*Payment) *Summary {
+	s := &Summary{Total: total, Payments: payments}
+	for _, p := range payments {
+		switch p.Status {
+		case StatusPaid:
+			s.Paid += p.Amount
+		case StatusRefunded:
+			// What was not paid back is still the shop's. Payments refunded
+			// before refunded amounts were recorded have none.
+			if p.RefundedAmount > 0 {
+				s.Paid += p.Amount - p.RefundedAmount
+			}
+		case StatusPending:
+			s.Pending += p.Amount
+		}
+	}
+	s.Outstanding = total - s.Paid
+	if s.Outstanding < Tolerance {
+		s.Outstanding = 0
+	}
+	return s
+}
+
+// Settled reports whether the payments cover the whole total.
+func (s *Summary) Settled() bool {
+	return s.Outstanding == 0
+}
@@ -0,0 +1,158 @@
+package pricealert
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"online-shop/pkg/id"
+)
+
+// JobName is the scheduler job that notifies watchers of price drops
+const JobName = "price-alerts"
+
+var (
+	ErrNotFound = errors.New("price alert not found")
+	// ErrTargetNotBelowPrice is returned when a watch would trigger at once
+	ErrTargetNotBelowPrice = errors.New("target price must be below the current price")
+	// ErrNotifierUnavailable is returned by UnavailableNotifier
+	ErrNotifierUnavailable = errors.New("notification queue unavailable")
+)
+
+// Channel is the notification worker channel an alert is sent over.
+type Channel string
+
+const (
+	ChannelEmail    Channel = "email"
+	ChannelPush     Channel = "push"
+	ChannelSMS      Channel = "sms"
+	ChannelInApp    Channel = "in-app"
+	ChannelWhatsApp Channel = "whatsapp"
+)
+
+type Status string
+
+const (
+	StatusActive Status = "active"
+	// StatusNotified watches were sent their alert and are done
+	StatusNotified  Status = "notified"
+	StatusExpired   Status = "expired"
+	StatusCancelled Status = "cancelled"
+)
+
+// Watch asks for one notification when a product's price falls to
+// TargetPrice or below. A user has at most one active watch per product.
+type Watch struct {
+	ID        string `json:"id" gorm:"primaryKey"`
+	UserID    string `json:"user_id" gorm:"index:idx_price_alerts_active,unique,where:status = 'active'"`
+	ProductID string `json:"product_id" gorm:"index:idx_price_alerts_active,unique,where:status = 'active';index"`
+	// WatchedPrice is the product's price when the watch was set up
+	WatchedPrice  float64    `json:"watched_price"`
+	TargetPrice   float64    `json:"target_price"`
+	Channel       Channel    `json:"channel"`
+	Status        Status     `json:"status" gorm:"index"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	NotifiedAt    *time.Time `json:"notified_at,omitempty"`
+	NotifiedPrice *float64   `json:"notified_price,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (Watch) TableName() string {
+	return "price_alerts"
+}
+
+type Repository interface {
+	Create(ctx context.Context, w *Watch) error
+	GetByID(ctx context.Context, id string) (*Watch, error)
+	Update(ctx context.Context, w *Watch) error
+	// FindActive returns the user's active watch on the product, or
+	// ErrNotFound
+	FindActive(ctx context.Context, userID, productID string) (*Watch, error)
+	ListByUser(ctx context.Context, userID string, limit, offset int) ([]*Watch, error)
+	// ListTriggered returns a product's active watches whose target is at or
+	// above price, oldest first so the earliest watchers hear first
+	ListTriggered(ctx context.Context, productID string, price float64, limit int) ([]*Watch, error)
+	// ExpireBefore expires active watches that ran out before at
+	ExpireBefore(ctx context.Context, at time.Time) (int64, error)
+}
+
+// Alert is a price drop notification for one watcher.
+type Alert struct {
+	Watch       *Watch
+	ProductName string
+	ProductURL  string
+	Price       float64
+	// Phone and Locale address the whatsapp channel
+	Phone  string
+	Locale string
+}
+
+// Notifier hands alerts to the notification worker.
+type Notifier interface {
+	PriceDropped(ctx context.Context, alert Alert) error
+}
+
+// UnavailableNotifier refuses price drop alerts without a notification
+// queue; the drops are kept and alerted once it is back.
+type UnavailableNotifier struct{}
+
+func (UnavailableNotifier) PriceDropped(ctx context.Context, alert Alert) error {
+	return ErrNotifierUnavailable
+}
+
+func NewWatch(userID, productID string, price, target float64, channel Channel, lifetime time.Duration) (*Watch, error) {
+	if target >= price {
+		return nil, ErrTargetNotBelowPrice
+	}
+
+	now := time.Now()
+	return &Watch{
+		ID:           id.New(),
+		UserID:       userID,
+		ProductID:    productID,
+		WatchedPrice: price,
+		TargetPrice:  target,
+		Channel:      channel,
+		Status:       StatusActive,
+		ExpiresAt:    now.Add(lifetime),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}, nil
+}
+
+func (w *Watch) IsActive() bool {
+	return w.Status == StatusActive
+}
+
+// Retarget moves an active watch to a new target at the current price.
+func (w *Watch) Retarget(price, target float64, channel Channel) error {
+	if target >= price {
+		return ErrTargetNotBelowPrice
+	}
+	w.WatchedPrice = price
+	w.TargetPrice = target
+	w.Channel = channel
+	w.UpdatedAt = time.Now()
+	return nil
+}
+
+// Notified closes the watch once its alert is sent for price.
+func (w *Watch) Notified(price float64) {
+	now := time.Now()
+	w.Status = StatusNotified
+	w.NotifiedAt = &now
+	w.NotifiedPrice = &price
+	w.UpdatedAt = now
+}
+
+func (w *Watch) Cancel() {
+	w.Status = StatusCancelled
+	w.UpdatedAt = time.Now()
+}
+
+// Expire closes a watch whose user can no longer be notified.
+func (w *Watch) Expire() {
+	w.Status = StatusExpired
+	w.UpdatedAt = time.Now()
+}
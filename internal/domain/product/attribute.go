@@ -0,0 +1,406 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"online-shop/pkg/id"
+)
+
+var (
+	ErrInvalidAttribute  = errors.New("invalid product attribute")
+	ErrInvalidTemplate   = errors.New("invalid attribute template")
+	ErrInvalidComparison = errors.New("invalid product comparison")
+)
+
+// AttributeType decides how an attribute value is validated, filtered and
+// compared.
+type AttributeType string
+
+const (
+	AttributeText    AttributeType = "text"
+	AttributeNumber  AttributeType = "number"
+	AttributeBoolean AttributeType = "boolean"
+	AttributeEnum    AttributeType = "enum"
+)
+
+const (
+	// MaxCompared is how many products can be compared side by side
+	MaxCompared        = 4
+	maxAttributeLength = 500
+)
+
+var attributeKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_]*$`)
+
+// Attribute is one specification of a product, e.g. weight 1.2 kg. Values
+// are kept as strings in a canonical form for their type so they can be
+// matched exactly.
+type Attribute struct {
+	Key   string        `json:"key"`
+	Label string        `json:"label"`
+	Type  AttributeType `json:"type"`
+	Value string        `json:"value"`
+	Unit  string        `json:"unit,omitempty"`
+}
+
+// Number returns the value of a number attribute.
+func (a Attribute) Number() (float64, bool) {
+	if a.Type != AttributeNumber {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(a.Value, 64)
+	return n, err == nil
+}
+
+// AttributeDefinition is one entry of a category's attribute template. The
+// products of the category may only use the attributes their category
+// defines.
+type AttributeDefinition struct {
+	ID         string        `json:"id" gorm:"primaryKey"`
+	CategoryID string        `json:"category_id" gorm:"uniqueIndex:idx_category_attribute_key"`
+	Key        string        `json:"key" gorm:"uniqueIndex:idx_category_attribute_key"`
+	Label      string        `json:"label"`
+	Type       AttributeType `json:"type"`
+	Unit       string        `json:"unit,omitempty"`
+	Options    []string      `json:"options,omitempty" gorm:"serializer:json"`
+	Required   bool          `json:"required"`
+	Position   int           `json:"position"`
+	CreatedAt  time.Time     `json:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+}
+
+func (AttributeDefinition) TableName() string {
+	return "category_attributes"
+}
+
+type AttributeTemplateRepository interface {
+	// ListByCategory returns a category's template in position order
+	ListByCategory(ctx context.Context, categoryID string) ([]*AttributeDefinition, error)
+	// Replace swaps a category's whole template for defs
+	Replace(ctx context.Context, categoryID string, defs []*AttributeDefinition) error
+}
+
+// AttributeFilter narrows a search to products whose attribute Key has one
+// of Values or, for numbers, lies between Min and Max.
+type AttributeFilter struct {
+	Key    string   `json:"key"`
+	Values []string `json:"values,omitempty"`
+	Min    *float64 `json:"min,omitempty"`
+	Max    *float64 `json:"max,omitempty"`
+}
+
+// Facet counts the products of a search per value of one attribute. Text
+// attributes are too free-form to be facets.
+type Facet struct {
+	Key    string        `json:"key"`
+	Label  string        `json:"label"`
+	Type   AttributeType `json:"type"`
+	Unit   string        `json:"unit,omitempty"`
+	Values []FacetValue  `json:"values"`
+}
+
+type FacetValue struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// Comparison lines up the attributes of several products.
+type Comparison struct {
+	Products   []*Product      `json:"products"`
+	Attributes []ComparisonRow `json:"attributes"`
+}
+
+// ComparisonRow holds one attribute across the compared products, in the
+// order of Comparison.Products; a product without the attribute has nil.
+type ComparisonRow struct {
+	Key     string       `json:"key"`
+	Label   string       `json:"label"`
+	Values  []*Attribute `json:"values"`
+	Differs bool         `json:"differs"`
+}
+
+// NewAttributeTemplate validates a category's attribute definitions. They
+// keep the order given, which is the order products list them in.
+func NewAttributeTemplate(categoryID string, defs []AttributeDefinition) ([]*AttributeDefinition, error) {
+	now := time.Now()
+	seen := map[string]bool{}
+	template := make([]*AttributeDefinition, 0, len(defs))
+	for i, d := range defs {
+		key := strings.ToLower(strings.TrimSpace(d.Key))
+		if !attributeKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("%w: key %q must be lowercase letters, digits and underscores", ErrInvalidTemplate, d.Key)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("%w: key %q is defined twice", ErrInvalidTemplate, key)
+		}
+		seen[key] = true
+
+		def := &AttributeDefinition{
+			ID:         id.New(),
+			CategoryID: categoryID,
+			Key:        key,
+			Label:      strings.TrimSpace(d.Label),
+			Type:       d.Type,
+			Unit:       strings.TrimSpace(d.Unit),
+			Required:   d.Required,
+			Position:   i,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}
+		if def.Label == "" {
+			def.Label = key
+		}
+		if def.Type == "" {
+			def.Type = AttributeText
+		}
+
+		switch def.Type {
+		case AttributeEnum:
+			options, err := enumOptions(d.Options)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrInvalidTemplate, key, err)
+			}
+			def.Options = options
+		case AttributeText, AttributeNumber, AttributeBoolean:
+			if len(d.Options) > 0 {
+				return nil, fmt.Errorf("%w: %s: only enum attributes have options", ErrInvalidTemplate, key)
+			}
+		default:
+			return nil, fmt.Errorf("%w: %s: unknown type %q", ErrInvalidTemplate, key, d.Type)
+		}
+		template = append(template, def)
+	}
+	return template, nil
+}
+
+func enumOptions(options []string) ([]string, error) {
+	seen := map[string]bool{}
+	var cleaned []string
+	for _, o := range options {
+		o = strings.TrimSpace(o)
+		if o == "" {
+			continue
+		}
+		if seen[strings.ToLower(o)] {
+			return nil, fmt.Errorf("option %q is listed twice", o)
+		}
+		seen[strings.ToLower(o)] = true
+		cleaned = append(cleaned, o)
+	}
+	if len(cleaned) == 0 {
+		return nil, errors.New("enum attributes need options")
+	}
+	return cleaned, nil
+}
+
+// ApplyTemplate checks attribute values against a category's template and
+// returns them typed, labelled and in template order. An empty value unsets
+// the attribute. Without a template any key is accepted with the type it
+// was given, text by default, but enums need a template for their options.
+func ApplyTemplate(template []*AttributeDefinition, values []Attribute) ([]Attribute, error) {
+	defs := make(map[string]*AttributeDefinition, len(template))
+	for _, d := range template {
+		defs[d.Key] = d
+	}
+
+	given := map[string]bool{}
+	attrs := make([]Attribute, 0, len(values))
+	for _, v := range values {
+		key := strings.ToLower(strings.TrimSpace(v.Key))
+		if given[key] {
+			return nil, fmt.Errorf("%w: %q is given twice", ErrInvalidAttribute, key)
+		}
+		given[key] = true
+
+		a := Attribute{Key: key, Label: strings.TrimSpace(v.Label), Type: v.Type, Unit: strings.TrimSpace(v.Unit)}
+		var options []string
+		if len(template) > 0 {
+			def, ok := defs[key]
+			if !ok {
+				return nil, fmt.Errorf("%w: %q is not an attribute of this category", ErrInvalidAttribute, key)
+			}
+			a.Label, a.Type, a.Unit, options = def.Label, def.Type, def.Unit, def.Options
+		} else {
+			if !attributeKeyPattern.MatchString(key) {
+				return nil, fmt.Errorf("%w: key %q must be lowercase letters, digits and underscores", ErrInvalidAttribute, v.Key)
+			}
+			if a.Label == "" {
+				a.Label = key
+			}
+			if a.Type == "" {
+				a.Type = AttributeText
+			}
+		}
+
+		if strings.TrimSpace(v.Value) == "" {
+			continue
+		}
+		value, err := canonicalValue(a.Type, v.Value, options)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidAttribute, key, err)
+		}
+		a.Value = value
+		attrs = append(attrs, a)
+	}
+
+	set := map[string]bool{}
+	for _, a := range attrs {
+		set[a.Key] = true
+	}
+	for _, d := range template {
+		if d.Required && !set[d.Key] {
+			return nil, fmt.Errorf("%w: %s is required", ErrInvalidAttribute, d.Key)
+		}
+	}
+
+	// Template order, or alphabetical without one
+	position := func(a Attribute) int {
+		if d, ok := defs[a.Key]; ok {
+			return d.Position
+		}
+		return 0
+	}
+	sort.SliceStable(attrs, func(i, j int) bool {
+		if pi, pj := position(attrs[i]), position(attrs[j]); pi != pj {
+			return pi < pj
+		}
+		return attrs[i].Key < attrs[j].Key
+	})
+	return attrs, nil
+}
+
+func canonicalValue(t AttributeType, value string, options []string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch t {
+	case AttributeText:
+		if len([]rune(value)) > maxAttributeLength {
+			return "", fmt.Errorf("must be at most %d characters", maxAttributeLength)
+		}
+		return value, nil
+	case AttributeNumber:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+			return "", fmt.Errorf("%q is not a number", value)
+		}
+		return strconv.FormatFloat(n, 'f', -1, 64), nil
+	case AttributeBoolean:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("%q is not true or false", value)
+		}
+		return strconv.FormatBool(b), nil
+	case AttributeEnum:
+		for _, o := range options {
+			if strings.EqualFold(o, value) {
+				return o, nil
+			}
+		}
+		if len(options) == 0 {
+			return "", errors.New("enum attributes need a category template")
+		}
+		return "", fmt.Errorf("%q is not one of %s", value, strings.Join(options, ", "))
+	default:
+		return "", fmt.Errorf("unknown type %q", t)
+	}
+}
+
+// Attribute returns the product's attribute with the given key.
+func (p *Product) Attribute(key string) (Attribute, bool) {
+	for _, a := range p.Attributes {
+		if a.Key == key {
+			return a, true
+		}
+	}
+	return Attribute{}, false
+}
+
+// ParseAttributeFilter reads a filter from a query parameter: a comma
+// separated list of values, or a numeric range written min..max where
+// either end may be left out.
+func ParseAttributeFilter(key, raw string) (AttributeFilter, error) {
+	f := AttributeFilter{Key: strings.ToLower(strings.TrimSpace(key))}
+	if !attributeKeyPattern.MatchString(f.Key) {
+		return f, fmt.Errorf("%w: unknown attribute %q", ErrInvalidAttribute, key)
+	}
+
+	if from, to, ok := strings.Cut(raw, ".."); ok {
+		var err error
+		if f.Min, err = parseBound(from); err != nil {
+			return f, fmt.Errorf("%w: %s: %v", ErrInvalidAttribute, f.Key, err)
+		}
+		if f.Max, err = parseBound(to); err != nil {
+			return f, fmt.Errorf("%w: %s: %v", ErrInvalidAttribute, f.Key, err)
+		}
+		if f.Min == nil && f.Max == nil {
+			return f, fmt.Errorf("%w: %s: range needs a minimum or a maximum", ErrInvalidAttribute, f.Key)
+		}
+		return f, nil
+	}
+
+	for _, v := range strings.Split(raw, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			f.Values = append(f.Values, v)
+		}
+	}
+	if len(f.Values) == 0 {
+		return f, fmt.Errorf("%w: %s: no value to filter by", ErrInvalidAttribute, f.Key)
+	}
+	return f, nil
+}
+
+func parseBound(s string) (*float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a number", s)
+	}
+	return &n, nil
+}
+
+// Compare lines up the attributes of the products. Rows follow the order
+// the attributes first appear in, so products of one category are compared
+// in template order.
+func Compare(products []*Product) *Comparison {
+	var rows []ComparisonRow
+	index := map[string]int{}
+	for i, p := range products {
+		for _, a := range p.Attributes {
+			r, ok := index[a.Key]
+			if !ok {
+				r = len(rows)
+				index[a.Key] = r
+				rows = append(rows, ComparisonRow{Key: a.Key, Label: a.Label, Values: make([]*Attribute, len(products))})
+			}
+			a := a
+			rows[r].Values[i] = &a
+		}
+	}
+
+	for r := range rows {
+		first := rows[r].Values[0]
+		for _, v := range rows[r].Values[1:] {
+			if !sameValue(first, v) {
+				rows[r].Differs = true
+				break
+			}
+		}
+	}
+	return &Comparison{Products: products, Attributes: rows}
+}
+
+func sameValue(a, b *Attribute) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return strings.EqualFold(a.Value, b.Value) && strings.EqualFold(a.Unit, b.Unit)
+}
@@ -0,0 +1,139 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrInvalidBundle is returned for repeated, empty or circular components
+var ErrInvalidBundle = errors.New("invalid bundle")
+
+// MaxComponents is the most products a bundle can be made of
+const MaxComponents = 20
+
+// Component is one of the products a bundle is made of, Quantity of it to
+// each bundle.
+type Component struct {
+	ProductID string `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+}
+
+// BundleRepository finds the bundles made of products, so that their stock
+// follows the stock of their components.
+type BundleRepository interface {
+	ListBundlesOf(ctx context.Context, productIDs []string) ([]*Product, error)
+}
+
+// IsBundle reports whether the product is sold as a set of other products.
+// A bundle has no stock of its own; it is sold from its components.
+func (p *Product) IsBundle() bool {
+	return len(p.Components) > 0
+}
+
+// StockedIDs are the products whose stock is taken when the product is sold:
+// its components for a bundle, or the product itself.
+func (p *Product) StockedIDs() []string {
+	if !p.IsBundle() {
+		return []string{p.ID}
+	}
+	ids := make([]string, len(p.Components))
+	for i, c := range p.Components {
+		ids[i] = c.ProductID
+	}
+	return ids
+}
+
+// SetComponents makes the product a bundle of components, or a product of
+// its own again, out of stock, when there are none. Components are found in
+// parts by ID and must be products of the same merchant that are not
+// bundles themselves.
+func (p *Product) SetComponents(components []Component, parts map[string]*Product) error {
+	if len(components) > MaxComponents {
+		return fmt.Errorf("%w: at most %d components", ErrInvalidBundle, MaxComponents)
+	}
+	seen := make(map[string]bool, len(components))
+	for _, c := range components {
+		if c.Quantity < 1 {
+			return fmt.Errorf("%w: component %s needs a quantity of at least 1", ErrInvalidBundle, c.ProductID)
+		}
+		if c.ProductID == p.ID {
+			return fmt.Errorf("%w: a bundle cannot contain itself", ErrInvalidBundle)
+		}
+		if seen[c.ProductID] {
+			return fmt.Errorf("%w: component %s is listed twice", ErrInvalidBundle, c.ProductID)
+		}
+		seen[c.ProductID] = true
+
+		part, ok := parts[c.ProductID]
+		if !ok || part.Status == StatusDeleted {
+			return fmt.Errorf("%w: component %s does not exist", ErrInvalidBundle, c.ProductID)
+		}
+		if part.IsBundle() {
+			return fmt.Errorf("%w: component %s is a bundle", ErrInvalidBundle, c.ProductID)
+		}
+		if part.MerchantID != p.MerchantID {
+			return fmt.Errorf("%w: component %s is sold by another merchant", ErrInvalidBundle, c.ProductID)
+		}
+	}
+
+	// A bundle's stock was its components'; none of it is the product's own
+	wasBundle := p.IsBundle()
+	p.Components = components
+	switch {
+	case p.IsBundle():
+		p.Stock = BundleStock(components, parts)
+	case wasBundle:
+		p.Stock = 0
+	}
+	return nil
+}
+
+// BundleStock is how many whole bundles the stock of their components makes
+// up. A component missing from parts has no stock.
+func BundleStock(components []Component, parts map[string]*Product) int {
+	stock := -1
+	for _, c := range components {
+		part, ok := parts[c.ProductID]
+		if !ok || c.Quantity < 1 {
+			return 0
+		}
+		n := part.Stock / c.Quantity
+		if stock < 0 || n < stock {
+			stock = n
+		}
+	}
+	if stock < 0 {
+		return 0
+	}
+	return stock
+}
+
+// SplitPrice shares price, what one bundle sells for, out between its
+// components in proportion to what they sell for on their own. It returns
+// the unit price of each component, to the cent, in the order of the
+// components; the last one takes what rounding leaves over.
+func (p *Product) SplitPrice(price float64, parts map[string]*Product) []float64 {
+	var list float64
+	for _, c := range p.Components {
+		list += parts[c.ProductID].Price * float64(c.Quantity)
+	}
+
+	prices := make([]float64, len(p.Components))
+	remaining := price
+	for i, c := range p.Components {
+		qty := float64(c.Quantity)
+		if i == len(p.Components)-1 {
+			prices[i] = math.Round(remaining/qty*100) / 100
+			break
+		}
+		share := price / float64(len(p.Components)) / qty
+		if list > 0 {
+			share = price * parts[c.ProductID].Price / list
+		}
+		prices[i] = math.Round(share*100) / 100
+		remaining -= prices[i] * qty
+	}
+	return prices
+}
@@ -0,0 +1,119 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"online-shop/pkg/id"
+)
+
+// ErrInvalidStatus is an unknown status or a change the product's status
+// does not allow; only drafts are scheduled, and only for later
+var ErrInvalidStatus = errors.New("invalid product status")
+
+// PublishJobName is the scheduler job publishing drafts at their publish
+// date
+const PublishJobName = "product-publish"
+
+// ScheduleRepository finds the drafts due to be published.
+type ScheduleRepository interface {
+	// ListDueDrafts returns up to limit drafts whose publish date is at or
+	// before the given time, soonest first
+	ListDueDrafts(ctx context.Context, at time.Time, limit int) ([]*Product, error)
+}
+
+// transitions are the statuses a product can move to from each status.
+// Deleted products are only ever deleted.
+var transitions = map[Status][]Status{
+	StatusDraft:    {StatusActive, StatusArchived},
+	StatusActive:   {StatusDraft, StatusArchived},
+	StatusArchived: {StatusActive, StatusDraft},
+}
+
+// Valid reports whether the status is one admins can set. Products are
+// deleted by deleting them.
+func (s Status) Valid() bool {
+	_, ok := transitions[s]
+	return ok
+}
+
+// SetStatus moves the product to the given status. Leaving draft drops its
+// publish date.
+func (p *Product) SetStatus(to Status, at time.Time) error {
+	if !to.Valid() {
+		return fmt.Errorf("%w: %q is not a product status", ErrInvalidStatus, to)
+	}
+	if p.Status == to {
+		return nil
+	}
+	allowed := false
+	for _, s := range transitions[p.Status] {
+		if s == to {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("%w: a %s product cannot become %s", ErrInvalidStatus, p.Status, to)
+	}
+
+	p.Status = to
+	if to != StatusDraft {
+		p.PublishAt = nil
+	}
+	p.UpdatedAt = at
+	return nil
+}
+
+// SchedulePublish sets when the draft is published, or with nil that it is
+// not. The date must be in the future.
+func (p *Product) SchedulePublish(publishAt *time.Time, at time.Time) error {
+	if publishAt != nil {
+		if p.Status != StatusDraft {
+			return fmt.Errorf("%w: only drafts can be scheduled", ErrInvalidStatus)
+		}
+		if !publishAt.After(at) {
+			return fmt.Errorf("%w: publish date must be in the future", ErrInvalidStatus)
+		}
+	}
+	p.PublishAt = publishAt
+	p.UpdatedAt = at
+	return nil
+}
+
+// DueAt reports whether the product is a draft to be published by the given
+// time.
+func (p *Product) DueAt(at time.Time) bool {
+	return p.Status == StatusDraft && p.PublishAt != nil && !at.Before(*p.PublishAt)
+}
+
+// Duplicate copies the product into a new draft named name, or after the
+// product when name is empty. The copy has the product's details, images,
+// attributes and components, but no stock, slug, badges or placement of its
+// own; the slug is for the caller to set.
+func (p *Product) Duplicate(name string, at time.Time) *Product {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = p.Name + " (copy)"
+	}
+
+	return &Product{
+		ID:          id.New(),
+		Name:        name,
+		Description: p.Description,
+		Price:       p.Price,
+		CategoryID:  p.CategoryID,
+		MerchantID:  p.MerchantID,
+		Images:      append([]string(nil), p.Images...),
+		Status:      StatusDraft,
+		SEO:         SEO{MetaTitle: p.SEO.MetaTitle, MetaDescription: p.SEO.MetaDescription},
+		Attributes:  append([]Attribute(nil), p.Attributes...),
+		Components:  append([]Component(nil), p.Components...),
+		Badges:      []Badge{},
+		CreatedAt:   at,
+		UpdatedAt:   at,
+	}
+}
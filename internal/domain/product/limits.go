@@ -0,0 +1,106 @@
+package product
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrInvalidLimits rejects limits an admin sets, ErrLimitExceeded a
+	// quantity over them and ErrAgeRestricted a buyer too young or
+	// unverified; each names the product or limit
+	ErrInvalidLimits = errors.New("invalid purchase limits")
+	ErrLimitExceeded = errors.New("purchase limit exceeded")
+	ErrAgeRestricted = errors.New("product is age restricted")
+)
+
+// MaxMinimumAge bounds the age a category can be restricted to
+const MaxMinimumAge = 100
+
+// PurchaseLimits caps how many units of a product a customer can buy. They
+// count every unit, whether ordered on its own or in a bundle. Zero is no
+// limit.
+type PurchaseLimits struct {
+	// MaxPerOrder is how many one order can have
+	MaxPerOrder int `json:"max_per_order,omitempty"`
+	// MaxPerCustomer is how many a customer can have across all their
+	// orders that were not cancelled
+	MaxPerCustomer int `json:"max_per_customer,omitempty"`
+}
+
+func (l PurchaseLimits) validate() error {
+	if l.MaxPerOrder < 0 || l.MaxPerCustomer < 0 {
+		return fmt.Errorf("%w: limits cannot be negative", ErrInvalidLimits)
+	}
+	if l.MaxPerCustomer > 0 && l.MaxPerOrder > l.MaxPerCustomer {
+		return fmt.Errorf("%w: max_per_order cannot be more than max_per_customer", ErrInvalidLimits)
+	}
+	return nil
+}
+
+// SetLimits sets the product's purchase limits. Bundles have none of their
+// own; their products' limits apply to them.
+func (p *Product) SetLimits(limits PurchaseLimits, at time.Time) error {
+	if err := limits.validate(); err != nil {
+		return err
+	}
+	if p.IsBundle() && limits != (PurchaseLimits{}) {
+		return fmt.Errorf("%w: set the limits of the bundle's products instead", ErrInvalidLimits)
+	}
+	p.Limits = limits
+	p.UpdatedAt = at
+	return nil
+}
+
+// CheckLimits checks an order for quantity units of the product, from a
+// customer who has bought bought of them before.
+func (p *Product) CheckLimits(quantity, bought int) error {
+	if limit := p.Limits.MaxPerOrder; limit > 0 && quantity > limit {
+		return fmt.Errorf("%w: at most %d of %s per order", ErrLimitExceeded, limit, p.Name)
+	}
+	if limit := p.Limits.MaxPerCustomer; limit > 0 && bought+quantity > limit {
+		if bought >= limit {
+			return fmt.Errorf("%w: you have already bought the most of %s a customer can", ErrLimitExceeded, p.Name)
+		}
+		return fmt.Errorf("%w: at most %d more of %s for you", ErrLimitExceeded, limit-bought, p.Name)
+	}
+	return nil
+}
+
+// MinimumAge is how old a customer has to be to buy the product, set by its
+// category; zero means anyone can.
+func (p *Product) MinimumAge() int {
+	if p.Category == nil {
+		return 0
+	}
+	return p.Category.MinimumAge
+}
+
+// CheckAge checks a customer can buy the product. verified is false when
+// their age was never verified, and age is then ignored.
+func (p *Product) CheckAge(age int, verified bool) error {
+	minimum := p.MinimumAge()
+	if minimum == 0 {
+		return nil
+	}
+	if !verified {
+		return fmt.Errorf("%w: verify your age to buy %s", ErrAgeRestricted, p.Name)
+	}
+	if age < minimum {
+		return fmt.Errorf("%w: you must be %d or older to buy %s", ErrAgeRestricted, minimum, p.Name)
+	}
+	return nil
+}
+
+// SetMinimumAge restricts the category's products to customers at least
+// age years old, or with 0 lifts the restriction. Subcategories are
+// restricted separately.
+func (c *Category) SetMinimumAge(age int, at time.Time) error {
+	if age < 0 || age > MaxMinimumAge {
+		return fmt.Errorf("%w: minimum_age must be between 0 and %d", ErrInvalidLimits, MaxMinimumAge)
+	}
+	c.MinimumAge = age
+	c.UpdatedAt = at
+	return nil
+}
@@ -0,0 +1,58 @@
+package product
+
+import (
+	"errors"
+	"time"
+)
+
+var errInvalidPosition = errors.New("invalid product position")
+
+// Position is where a keyset page of products ends: the creation time and
+// ID of its last product. Keyset lists are newest first, the ID breaking
+// ties, and the next page starts after the position.
+type Position struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// PositionOf returns the position of p in a keyset list.
+func PositionOf(p *Product) Position {
+	return Position{CreatedAt: p.CreatedAt, ID: p.ID}
+}
+
+// Strings writes the position the way ParsePosition reads it, for a cursor.
+func (p Position) Strings() []string {
+	return []string{p.CreatedAt.UTC().Format(time.RFC3339Nano), p.ID}
+}
+
+func ParsePosition(values []string) (Position, error) {
+	if len(values) != 2 || values[1] == "" {
+		return Position{}, errInvalidPosition
+	}
+	t, err := time.Parse(time.RFC3339Nano, values[0])
+	if err != nil {
+		return Position{}, errInvalidPosition
+	}
+	return Position{CreatedAt: t, ID: values[1]}, nil
+}
+
+// Ranking reorders the results of a search. Pinned products come first, in
+// their order, whether or not they match the rest of the search; the others
+// are ranked by their score multiplied by the factors of the boosts whose
+// filter they match.
+type Ranking struct {
+	Pinned []string `json:"pinned,omitempty"`
+	Boosts []Boost  `json:"boosts,omitempty"`
+}
+
+// Boost multiplies the score of the products matching Filter by Factor, which
+// buries them when below 1.
+type Boost struct {
+	Filter AttributeFilter `json:"filter"`
+	Factor float64         `json:"factor"`
+}
+
+// Empty reports whether the ranking leaves results as they are.
+func (r Ranking) Empty() bool {
+	return len(r.Pinned) == 0 && len(r.Boosts) == 0
+}
@@ -0,0 +1,47 @@
+package product
+
+import (
+	"context"
+	"time"
+
+	"online-shop/pkg/id"
+)
+
+// PriceChange is one entry in a product's price history. Drops are picked up
+// by the price alert job, which sets ProcessedAt once the watchers of the
+// product were notified.
+type PriceChange struct {
+	ID          string     `json:"id" gorm:"primaryKey"`
+	ProductID   string     `json:"product_id" gorm:"index"`
+	OldPrice    float64    `json:"old_price"`
+	NewPrice    float64    `json:"new_price"`
+	ChangedAt   time.Time  `json:"changed_at" gorm:"index"`
+	ProcessedAt *time.Time `json:"processed_at,omitempty"`
+}
+
+func (PriceChange) TableName() string {
+	return "product_price_history"
+}
+
+type PriceHistoryRepository interface {
+	Record(ctx context.Context, c *PriceChange) error
+	ListByProduct(ctx context.Context, productID string, limit int) ([]*PriceChange, error)
+	// ListUnprocessedDrops returns price drops not yet checked against price
+	// alerts, oldest first
+	ListUnprocessedDrops(ctx context.Context, limit int) ([]*PriceChange, error)
+	MarkProcessed(ctx context.Context, ids []string) error
+}
+
+func NewPriceChange(productID string, oldPrice, newPrice float64) *PriceChange {
+	return &PriceChange{
+		ID:        id.New(),
+		ProductID: productID,
+		OldPrice:  oldPrice,
+		NewPrice:  newPrice,
+		ChangedAt: time.Now(),
+	}
+}
+
+func (c *PriceChange) IsDrop() bool {
+	return c.NewPrice < c.OldPrice
+}
@@ -1,15 +1,23 @@
 package product
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
+	"unicode"
 
-	"github.com/google/uuid"
+	"online-shop/pkg/id"
 )
 
+// ErrNotFound is returned when some of the products asked for do not exist
+var ErrNotFound = errors.New("product not found")
+
 type Product struct {
 	ID          string    `json:"id" gorm:"primaryKey"`
 	Name        string    `json:"name"`
+	Slug        string    `json:"slug" gorm:"index:idx_products_slug,unique,where:slug <> ''"`
 	Description string    `json:"description"`
 	Price       float64   `json:"price"`
 	Stock       int       `json:"stock"`
@@ -18,55 +26,190 @@ type Product struct {
 	MerchantID  string    `json:"merchant_id"`
 	Images      []string  `json:"images" gorm:"type:text[]"`
 	Status      Status    `json:"status"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	// PublishAt is when a draft is published by the publish job
+	PublishAt *time.Time `json:"publish_at,omitempty" gorm:"index"`
+	SEO       SEO        `json:"seo" gorm:"embedded;embeddedPrefix:seo_"`
+	Featured  Featured   `json:"featured" gorm:"embedded;embeddedPrefix:featured_"`
+	// Attributes are the product's specifications, checked against its
+	// category's template when they are set
+	Attributes []Attribute `json:"attributes" gorm:"type:jsonb;serializer:json"`
+	// Components make the product a bundle; its stock is then what the
+	// stock of its components makes up
+	Components []Component `json:"components,omitempty" gorm:"type:jsonb;serializer:json"`
+	// Badges are the labels the product is shown with, kept up to date by
+	// the badge job from the badge rules and the badges set by hand
+	Badges []Badge `json:"badges" gorm:"type:jsonb;serializer:json"`
+	// Limits caps how many a customer can buy
+	Limits    PurchaseLimits `json:"purchase_limits" gorm:"embedded;embeddedPrefix:limit_"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	// FlashSale is set when the product is served during a flash sale
+	FlashSale *FlashSaleOffer `json:"flash_sale,omitempty" gorm:"-"`
+	// Locale is set when the product is served translated to it
+	Locale string `json:"locale,omitempty" gorm:"-"`
+}
+
+// SEO holds search engine metadata. Empty fields are derived from the
+// product when it is served.
+type SEO struct {
+	MetaTitle       string `json:"meta_title"`
+	MetaDescription string `json:"meta_description"`
+	CanonicalURL    string `json:"canonical_url"`
+}
+
+// Featured is the admin-curated placement of a product. A nil From or Until
+// leaves that side of the window open.
+type Featured struct {
+	Enabled  bool       `json:"enabled" gorm:"index"`
+	Position int        `json:"position"`
+	From     *time.Time `json:"from,omitempty"`
+	Until    *time.Time `json:"until,omitempty"`
+}
+
+// FlashSaleOffer is the live flash sale price of a product along with what
+// clients need for a countdown.
+type FlashSaleOffer struct {
+	SaleID          string    `json:"sale_id"`
+	Name            string    `json:"name"`
+	Price           float64   `json:"price"`
+	OriginalPrice   float64   `json:"original_price"`
+	DiscountPercent int       `json:"discount_percent"`
+	Remaining       int       `json:"remaining"`
+	PerUserLimit    int       `json:"per_user_limit,omitempty"`
+	EndsAt          time.Time `json:"ends_at"`
+	SecondsLeft     int64     `json:"seconds_left"`
+}
+
+// Badge is a label a product is shown with, such as New or Sale.
+type Badge struct {
+	Code  string `json:"code"`
+	Label string `json:"label"`
 }
 
 type Category struct {
 	ID          string    `json:"id" gorm:"primaryKey"`
 	Name        string    `json:"name"`
+	Slug        string    `json:"slug" gorm:"index:idx_categories_slug,unique,where:slug <> ''"`
 	Description string    `json:"description"`
 	ParentID    *string   `json:"parent_id"`
 	Parent      *Category `json:"parent,omitempty" gorm:"foreignKey:ParentID"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	// Attributes is the template the category's products are specified by
+	Attributes []*AttributeDefinition `json:"attributes,omitempty" gorm:"foreignKey:CategoryID"`
+	// MinimumAge restricts the category's products to customers whose age
+	// was verified to be at least this; zero is no restriction
+	MinimumAge int       `json:"minimum_age,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	// Locale is set when the category is served translated to it
+	Locale string `json:"locale,omitempty" gorm:"-"`
 }
 
+// SlugRedirect remembers a slug that was replaced so old URLs keep working.
+type SlugRedirect struct {
+	ID         string    `json:"id" gorm:"primaryKey"`
+	EntityType string    `json:"entity_type" gorm:"uniqueIndex:idx_slug_redirect"`
+	OldSlug    string    `json:"old_slug" gorm:"uniqueIndex:idx_slug_redirect"`
+	EntityID   string    `json:"entity_id" gorm:"index"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+const (
+	EntityProduct  = "product"
+	EntityCategory = "category"
+)
+
 type Status string
 
+// The lifecycle of a product. Only active products are listed, searched and
+// sold; drafts and products pending review are not served at all, while
+// archived products still are but can no longer be bought.
 const (
+	StatusDraft    Status = "draft"
 	StatusActive   Status = "active"
-	StatusInactive Status = "inactive"
+	StatusArchived Status = "archived"
 	StatusDeleted  Status = "deleted"
+	// StatusPendingReview is a merchant's new product waiting for an admin
+	// to approve it; see the moderation package
+	StatusPendingReview Status = "pending_review"
 )
 
 type SearchFilter struct {
-	Query      string
+	Query string
+	// Locale has Query match the product's translation to it as well as
+	// its own text
+	Locale     string
 	CategoryID string
 	MinPrice   float64
 	MaxPrice   float64
 	MerchantID string
 	Status     Status
-	Limit      int
-	Offset     int
+	Attributes []AttributeFilter
+	// Ranking orders the results of List; without it they are in no
+	// particular order
+	Ranking Ranking
+	Limit   int
+	Offset  int
 }
 
 type Repository interface {
-	Create(product *Product) error
-	GetByID(id string) (*Product, error)
-	Update(product *Product) error
-	Delete(id string) error
-	List(filter SearchFilter) ([]*Product, error)
-	Search(query string, limit, offset int) ([]*Product, error)
-	UpdateStock(productID string, quantity int) error
+	Create(ctx context.Context, product *Product) error
+	GetByID(ctx context.Context, id string) (*Product, error)
+	Update(ctx context.Context, product *Product) error
+	Delete(ctx context.Context, id string) error
+	List(ctx context.Context, filter SearchFilter) ([]*Product, error)
+	// ListAfter returns up to limit products matching the filter, newest
+	// first, after the position when one is given. The filter's Limit and
+	// Offset are not used.
+	ListAfter(ctx context.Context, filter SearchFilter, after *Position, limit int) ([]*Product, error)
+	Search(ctx context.Context, query string, limit, offset int) ([]*Product, error)
+	UpdateStock(ctx context.Context, productID string, quantity int) error
+	GetBySlug(ctx context.Context, slug string) (*Product, error)
+	SlugExists(ctx context.Context, slug string) (bool, error)
+	GetByIDs(ctx context.Context, ids []string) ([]*Product, error)
+	ListFeatured(ctx context.Context, at time.Time, limit int) ([]*Product, error)
+	// AttributeFacets counts the products matching the filter per attribute
+	// value, ignoring its limit and offset
+	AttributeFacets(ctx context.Context, filter SearchFilter) ([]Facet, error)
+}
+
+// TrendingRepository keeps time-decayed popularity scores per product.
+type TrendingRepository interface {
+	Record(ctx context.Context, productID string, score float64, at time.Time) error
+	Refresh(ctx context.Context, at time.Time) error
+	Top(ctx context.Context, limit, offset int) ([]string, error)
+	// Forget drops the scores recorded in the hours from from up to to, of
+	// one product or, when productID is empty, of every product. Hours are
+	// dropped whole, so from and to should fall on the hour.
+	Forget(ctx context.Context, from, to time.Time, productID string) error
+}
+
+// RecentlyViewedRepository keeps a short, most-recent-first list of viewed
+// product IDs per viewer (see ViewerKey).
+type RecentlyViewedRepository interface {
+	Add(ctx context.Context, viewer, productID string) error
+	List(ctx context.Context, viewer string, limit int) ([]string, error)
+}
+
+// Cache is the read-through product cache.
+type Cache interface {
+	CacheProduct(ctx context.Context, productID string, product interface{}) error
+	GetCachedProduct(ctx context.Context, productID string, dest interface{}) error
 }
 
 type CategoryRepository interface {
-	Create(category *Category) error
-	GetByID(id string) (*Category, error)
-	Update(category *Category) error
-	Delete(id string) error
-	List(limit, offset int) ([]*Category, error)
+	Create(ctx context.Context, category *Category) error
+	GetByID(ctx context.Context, id string) (*Category, error)
+	Update(ctx context.Context, category *Category) error
+	Delete(ctx context.Context, id string) error
+	List(ctx context.Context, limit, offset int) ([]*Category, error)
+	GetBySlug(ctx context.Context, slug string) (*Category, error)
+	SlugExists(ctx context.Context, slug string) (bool, error)
+}
+
+type SlugRedirectRepository interface {
+	Create(ctx context.Context, redirect *SlugRedirect) error
+	Find(ctx context.Context, entityType, oldSlug string) (*SlugRedirect, error)
+	DeleteBySlug(ctx context.Context, entityType, slug string) error
 }
 
 type Service interface {
@@ -78,14 +221,21 @@ type Service interface {
 	UpdateStock(productID string, quantity int) error
 }
 
-func NewProduct(name, description string, price float64, stock int, categoryID, merchantID string, images []string) (*Product, error) {
+// NewProduct slugs the product after its name, made unique with slugExists,
+// which reports whether another product already has a slug.
+func NewProduct(name, description string, price float64, stock int, categoryID, merchantID string, images []string, slugExists func(slug string) (bool, error)) (*Product, error) {
 	if name == "" || price <= 0 || stock < 0 {
 		return nil, errors.New("invalid product data")
 	}
+	slug, err := newSlug(name, slugExists)
+	if err != nil {
+		return nil, err
+	}
 
 	return &Product{
-		ID:          uuid.New().String(),
+		ID:          id.New(),
 		Name:        name,
+		Slug:        slug,
 		Description: description,
 		Price:       price,
 		Stock:       stock,
@@ -98,14 +248,21 @@ func NewProduct(name, description string, price float64, stock int, categoryID,
 	}, nil
 }
 
-func NewCategory(name, description string, parentID *string) (*Category, error) {
+// NewCategory slugs the category after its name, made unique with
+// slugExists, which reports whether another category already has a slug.
+func NewCategory(name, description string, parentID *string, slugExists func(slug string) (bool, error)) (*Category, error) {
 	if name == "" {
 		return nil, errors.New("category name is required")
 	}
+	slug, err := newSlug(name, slugExists)
+	if err != nil {
+		return nil, err
+	}
 
 	return &Category{
-		ID:          uuid.New().String(),
+		ID:          id.New(),
 		Name:        name,
+		Slug:        slug,
 		Description: description,
 		ParentID:    parentID,
 		CreatedAt:   time.Now(),
@@ -117,6 +274,32 @@ func (p *Product) IsAvailable() bool {
 	return p.Status == StatusActive && p.Stock > 0
 }
 
+// ViewerKey identifies whose recently viewed list an event belongs to. Signed
+// in users are tracked by user ID, anonymous visitors by session ID; it
+// returns "" when neither is known.
+func ViewerKey(userID, sessionID string) string {
+	if userID != "" {
+		return "user:" + userID
+	}
+	if sessionID != "" {
+		return "session:" + sessionID
+	}
+	return ""
+}
+
+func (p *Product) IsFeaturedAt(t time.Time) bool {
+	if !p.Featured.Enabled || p.Status != StatusActive {
+		return false
+	}
+	if p.Featured.From != nil && t.Before(*p.Featured.From) {
+		return false
+	}
+	if p.Featured.Until != nil && !t.Before(*p.Featured.Until) {
+		return false
+	}
+	return true
+}
+
 func (p *Product) ReduceStock(quantity int) error {
 	if p.Stock < quantity {
 		return errors.New("insufficient stock")
@@ -129,4 +312,108 @@ func (p *Product) ReduceStock(quantity int) error {
 func (p *Product) IncreaseStock(quantity int) {
 	p.Stock += quantity
 	p.UpdatedAt = time.Now()
-}
\ No newline at end of file
+}
+
+// URLPath is the public path of the product page.
+func (p *Product) URLPath() string {
+	if p.Slug != "" {
+		return "/products/" + p.Slug
+	}
+	return "/products/" + p.ID
+}
+
+func (c *Category) URLPath() string {
+	if c.Slug != "" {
+		return "/categories/" + c.Slug
+	}
+	return "/categories/" + c.ID
+}
+
+const maxMetaDescriptionLength = 160
+
+// ApplySEODefaults fills empty SEO fields from the product name, description
+// and URL without overwriting values set by merchants.
+func (p *Product) ApplySEODefaults(siteURL string) {
+	if p.SEO.MetaTitle == "" {
+		p.SEO.MetaTitle = p.Name
+	}
+	if p.SEO.MetaDescription == "" {
+		p.SEO.MetaDescription = truncateWords(p.Description, maxMetaDescriptionLength)
+	}
+	if p.SEO.CanonicalURL == "" {
+		p.SEO.CanonicalURL = strings.TrimSuffix(siteURL, "/") + p.URLPath()
+	}
+}
+
+func truncateWords(s string, limit int) string {
+	runes := []rune(strings.Join(strings.Fields(s), " "))
+	if len(runes) <= limit {
+		return string(runes)
+	}
+	cut := limit
+	for i := limit; i > 0; i-- {
+		if runes[i] == ' ' {
+			cut = i
+			break
+		}
+	}
+	return string(runes[:cut]) + "…"
+}
+
+// Slugify turns a name into a lowercase, hyphen-separated URL segment.
+func Slugify(name string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(name) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+			hyphen = false
+			continue
+		}
+		if !hyphen && b.Len() > 0 {
+			b.WriteByte('-')
+			hyphen = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// UniqueSlug appends a numeric suffix to base until exists reports the slug
+// is free.
+func UniqueSlug(base string, exists func(slug string) (bool, error)) (string, error) {
+	if base == "" {
+		return "", errors.New("slug is empty")
+	}
+
+	slug := base
+	for i := 2; ; i++ {
+		taken, err := exists(slug)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return slug, nil
+		}
+		slug = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+// newSlug is the unique slug of a name, or none for a name with nothing to
+// slug, such as one written only in punctuation.
+func newSlug(name string, exists func(slug string) (bool, error)) (string, error) {
+	base := Slugify(name)
+	if base == "" {
+		return "", nil
+	}
+	return UniqueSlug(base, exists)
+}
+
+func NewSlugRedirect(entityType, oldSlug, entityID string) *SlugRedirect {
+	return &SlugRedirect{
+		ID:         id.New(),
+		EntityType: entityType,
+		OldSlug:    oldSlug,
+		EntityID:   entityID,
+		CreatedAt:  time.Now(),
+	}
+}
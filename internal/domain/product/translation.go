@@ -0,0 +1,128 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrTranslationNotFound is returned when a product or category has no
+// translation to the locale asked for
+var ErrTranslationNotFound = errors.New("translation not found")
+
+// Translation is a product's name and description in a locale other than
+// the default one, which the product's own fields are written in.
+type Translation struct {
+	ProductID   string    `json:"product_id" gorm:"primaryKey"`
+	Locale      string    `json:"locale" gorm:"primaryKey"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	UpdatedBy   string    `json:"updated_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (Translation) TableName() string {
+	return "product_translations"
+}
+
+// CategoryTranslation is a category's name and description in a locale
+// other than the default one.
+type CategoryTranslation struct {
+	CategoryID  string    `json:"category_id" gorm:"primaryKey"`
+	Locale      string    `json:"locale" gorm:"primaryKey"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	UpdatedBy   string    `json:"updated_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (CategoryTranslation) TableName() string {
+	return "category_translations"
+}
+
+func NewTranslation(productID, locale, name, description, updatedBy string) *Translation {
+	now := time.Now()
+	return &Translation{
+		ProductID:   productID,
+		Locale:      locale,
+		Name:        strings.TrimSpace(name),
+		Description: strings.TrimSpace(description),
+		UpdatedBy:   updatedBy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+func NewCategoryTranslation(categoryID, locale, name, description, updatedBy string) *CategoryTranslation {
+	now := time.Now()
+	return &CategoryTranslation{
+		CategoryID:  categoryID,
+		Locale:      locale,
+		Name:        strings.TrimSpace(name),
+		Description: strings.TrimSpace(description),
+		UpdatedBy:   updatedBy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// Translate serves the product in the translation's locale. A description
+// left out of the translation keeps the product's own.
+func (p *Product) Translate(t *Translation) {
+	p.Name = t.Name
+	if t.Description != "" {
+		p.Description = t.Description
+	}
+	p.Locale = t.Locale
+}
+
+// Translate serves the category in the translation's locale. A description
+// left out of the translation keeps the category's own.
+func (c *Category) Translate(t *CategoryTranslation) {
+	c.Name = t.Name
+	if t.Description != "" {
+		c.Description = t.Description
+	}
+	c.Locale = t.Locale
+}
+
+// TranslationCoverage counts the active products and the categories, and
+// how many of them are translated to Locale.
+type TranslationCoverage struct {
+	Locale               string `json:"locale"`
+	Products             int64  `json:"products"`
+	TranslatedProducts   int64  `json:"translated_products"`
+	Categories           int64  `json:"categories"`
+	TranslatedCategories int64  `json:"translated_categories"`
+}
+
+// Complete reports whether everything is translated to the locale.
+func (c TranslationCoverage) Complete() bool {
+	return c.TranslatedProducts >= c.Products && c.TranslatedCategories >= c.Categories
+}
+
+type TranslationRepository interface {
+	// SaveProduct creates the translation or replaces the one the product
+	// has to its locale
+	SaveProduct(ctx context.Context, t *Translation) error
+	DeleteProduct(ctx context.Context, productID, locale string) error
+	// ListByProduct returns the product's translations by locale
+	ListByProduct(ctx context.Context, productID string) ([]*Translation, error)
+	// ForProducts returns the translations to locale of those of the
+	// products that have one, by product ID
+	ForProducts(ctx context.Context, locale string, productIDs []string) (map[string]*Translation, error)
+	SaveCategory(ctx context.Context, t *CategoryTranslation) error
+	DeleteCategory(ctx context.Context, categoryID, locale string) error
+	ListByCategory(ctx context.Context, categoryID string) ([]*CategoryTranslation, error)
+	ForCategories(ctx context.Context, locale string, categoryIDs []string) (map[string]*CategoryTranslation, error)
+	// MissingProducts returns the active products with no translation to
+	// locale, newest first, and how many there are
+	MissingProducts(ctx context.Context, locale string, limit, offset int) ([]*Product, int64, error)
+	// MissingCategories returns the categories with no translation to
+	// locale, by name
+	MissingCategories(ctx context.Context, locale string) ([]*Category, error)
+	Coverage(ctx context.Context, locale string) (TranslationCoverage, error)
+}
@@ -0,0 +1,201 @@
+// Package productversion keeps the history of what products say about
+// themselves. Every write changing a product's content is recorded as its
+// next numbered version, with who made it; versions can be compared with
+// each other and a product rolled back to any of them. Stock, status, slug
+// and placement are not part of a version: they are kept by their own
+// features and the audit log.
+package productversion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"time"
+
+	"online-shop/internal/domain/audit"
+	"online-shop/internal/domain/product"
+	"online-shop/pkg/id"
+)
+
+var ErrVersionNotFound = errors.New("product version not found")
+
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	// ActionRollback versions restore an earlier version's content
+	ActionRollback Action = "rollback"
+)
+
+// Snapshot is the content of a product as of a version.
+type Snapshot struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Price       float64             `json:"price"`
+	CategoryID  string              `json:"category_id"`
+	Images      []string            `json:"images"`
+	SEO         product.SEO         `json:"seo"`
+	Attributes  []product.Attribute `json:"attributes"`
+	Components  []product.Component `json:"components"`
+}
+
+// Version is one numbered state of a product's content, starting at 1.
+type Version struct {
+	ID        string `json:"id" gorm:"primaryKey"`
+	ProductID string `json:"product_id" gorm:"uniqueIndex:idx_product_version_number"`
+	Number    int    `json:"number" gorm:"uniqueIndex:idx_product_version_number"`
+	Action    Action `json:"action"`
+	// RolledBackTo is the version a rollback restored
+	RolledBackTo int      `json:"rolled_back_to,omitempty"`
+	Snapshot     Snapshot `json:"snapshot" gorm:"type:jsonb;serializer:json"`
+	ActorID      string   `json:"actor_id" gorm:"index"`
+	ActorRole    string   `json:"actor_role,omitempty"`
+	// ImpersonatorID is the support admin who acted as the actor
+	ImpersonatorID string `json:"impersonator_id,omitempty"`
+	// RequestID ties the version to the audit log entries of its request
+	RequestID string    `json:"request_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (Version) TableName() string {
+	return "product_versions"
+}
+
+// Comparison is what changed in a product from one version to another,
+// keyed by the snapshot's JSON fields.
+type Comparison struct {
+	ProductID string                  `json:"product_id"`
+	From      int                     `json:"from"`
+	To        int                     `json:"to"`
+	Changes   map[string]audit.Change `json:"changes"`
+}
+
+type Repository interface {
+	Get(ctx context.Context, productID string, number int) (*Version, error)
+	// Latest returns the newest version of a product, or
+	// ErrVersionNotFound when it has none
+	Latest(ctx context.Context, productID string) (*Version, error)
+	// List returns the versions of a product, newest first
+	List(ctx context.Context, productID string, limit, offset int) ([]*Version, int64, error)
+}
+
+// Take snapshots the product's content.
+func Take(p *product.Product) Snapshot {
+	return Snapshot{
+		Name:        p.Name,
+		Description: p.Description,
+		Price:       p.Price,
+		CategoryID:  p.CategoryID,
+		Images:      append([]string(nil), p.Images...),
+		SEO:         p.SEO,
+		Attributes:  append([]product.Attribute(nil), p.Attributes...),
+		Components:  append([]product.Component(nil), p.Components...),
+	}
+}
+
+// Equal reports whether two snapshots hold the same content. Empty and nil
+// lists are the same.
+func (s Snapshot) Equal(other Snapshot) bool {
+	return reflect.DeepEqual(s.fields(), other.fields())
+}
+
+// Apply restores the snapshot's content on the product.
+func (s Snapshot) Apply(p *product.Product, at time.Time) {
+	p.Name = s.Name
+	p.Description = s.Description
+	p.Price = s.Price
+	if p.CategoryID != s.CategoryID {
+		p.CategoryID = s.CategoryID
+		p.Category = nil
+	}
+	p.Images = append([]string(nil), s.Images...)
+	p.SEO = s.SEO
+	p.Attributes = append([]product.Attribute(nil), s.Attributes...)
+	p.Components = append([]product.Component(nil), s.Components...)
+	p.UpdatedAt = at
+}
+
+// fields returns the snapshot as its JSON fields, the way it is stored and
+// compared.
+func (s Snapshot) fields() map[string]interface{} {
+	fields := make(map[string]interface{})
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fields
+	}
+	_ = json.Unmarshal(data, &fields)
+	for k, v := range fields {
+		// A nil list is encoded as null
+		if v == nil {
+			fields[k] = []interface{}{}
+		}
+	}
+	return fields
+}
+
+// Next builds the version following latest, which is nil for a product
+// without versions. The actor is taken from the context, and a write marked
+// with WithRollback is recorded as the rollback it is.
+func Next(ctx context.Context, latest *Version, productID string, snapshot Snapshot, action Action, at time.Time) *Version {
+	number := 1
+	if latest != nil {
+		number = latest.Number + 1
+	}
+	actor, _ := audit.ActorFromContext(ctx)
+	if actor.ID == "" {
+		actor.ID = audit.SystemActor
+	}
+
+	v := &Version{
+		ID:             id.New(),
+		ProductID:      productID,
+		Number:         number,
+		Action:         action,
+		Snapshot:       snapshot,
+		ActorID:        actor.ID,
+		ActorRole:      actor.Role,
+		ImpersonatorID: actor.ImpersonatorID,
+		RequestID:      actor.RequestID,
+		CreatedAt:      at,
+	}
+	if restored, ok := RollbackFromContext(ctx); ok {
+		v.Action = ActionRollback
+		v.RolledBackTo = restored
+	}
+	return v
+}
+
+// Compare returns what changed from one version of a product to another.
+func Compare(from, to *Version) *Comparison {
+	return &Comparison{
+		ProductID: to.ProductID,
+		From:      from.Number,
+		To:        to.Number,
+		Changes:   Diff(from.Snapshot, to.Snapshot),
+	}
+}
+
+// Diff returns the fields that differ between two snapshots.
+func Diff(from, to Snapshot) map[string]audit.Change {
+	return audit.Diff(from.fields(), to.fields())
+}
+
+type rollbackKey struct{}
+
+// WithRollback marks the product writes made with the context as restoring
+// the given version, so they are recorded as a rollback to it.
+func WithRollback(ctx context.Context, number int) context.Context {
+	return context.WithValue(ctx, rollbackKey{}, number)
+}
+
+// RollbackFromContext returns the version a write restores, if it is a
+// rollback.
+func RollbackFromContext(ctx context.Context) (int, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	number, ok := ctx.Value(rollbackKey{}).(int)
+	return number, ok
+}
@@ -0,0 +1,220 @@
+package quote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"online-shop/pkg/id"
+)
+
+var (
+	ErrNotFound = errors.New("quote not found")
+	// ErrInvalidRequest is a request without a company or enough units, and
+	// ErrInvalidOffer an offer that has expired or does not price every item
+	ErrInvalidRequest = errors.New("invalid quote request")
+	ErrInvalidOffer   = errors.New("invalid quote offer")
+	// ErrWrongStatus is returned for a step the quote's status does not
+	// allow, such as accepting a quote that has not been priced
+	ErrWrongStatus = errors.New("quote cannot do that in its status")
+	ErrExpired     = errors.New("quote has expired")
+)
+
+type Status string
+
+const (
+	// StatusRequested quotes wait for the merchant to price them
+	StatusRequested Status = "requested"
+	// StatusOffered quotes can be accepted until they are valid until
+	StatusOffered Status = "offered"
+	// StatusAccepted quotes were converted into an order
+	StatusAccepted Status = "accepted"
+	// StatusDeclined quotes were turned down by the merchant
+	StatusDeclined Status = "declined"
+	// StatusCancelled quotes were withdrawn, or their offer turned down, by
+	// the buyer
+	StatusCancelled Status = "cancelled"
+	StatusExpired   Status = "expired"
+)
+
+// Quote is a business customer's request for a price on large quantities of
+// one merchant's products, and the merchant's offer for it.
+type Quote struct {
+	ID         string `json:"id" gorm:"primaryKey"`
+	UserID     string `json:"user_id" gorm:"index"`
+	MerchantID string `json:"merchant_id" gorm:"index"`
+	// Company is the business the buyer asks for
+	Company string `json:"company"`
+	Status  Status `json:"status" gorm:"index"`
+	Items   []Item `json:"items" gorm:"type:jsonb;serializer:json"`
+	// Note is the buyer's, Response the merchant's
+	Note     string `json:"note,omitempty"`
+	Response string `json:"response,omitempty"`
+	// TotalAmount is what the items come to at the offered prices
+	TotalAmount float64    `json:"total_amount"`
+	ValidUntil  *time.Time `json:"valid_until,omitempty"`
+	OfferedAt   *time.Time `json:"offered_at,omitempty"`
+	// OrderID is the order an accepted quote was converted into
+	OrderID    string     `json:"order_id,omitempty" gorm:"index"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// Item is a product asked for, with its price when it was asked for and
+// the unit price the merchant offers.
+type Item struct {
+	ProductID string  `json:"product_id"`
+	Quantity  int     `json:"quantity"`
+	ListPrice float64 `json:"list_price"`
+	Price     float64 `json:"price,omitempty"`
+}
+
+// Filter narrows a listing to one buyer or one merchant, and to a status
+// when one is given.
+type Filter struct {
+	UserID     string
+	MerchantID string
+	Status     Status
+}
+
+type Repository interface {
+	Create(ctx context.Context, q *Quote) error
+	GetByID(ctx context.Context, id string) (*Quote, error)
+	Update(ctx context.Context, q *Quote) error
+	// List returns the quotes matching the filter, newest first
+	List(ctx context.Context, filter Filter, limit, offset int) ([]*Quote, error)
+}
+
+// NewRequest asks merchantID for a price on items, which together must
+// count at least minQuantity units.
+func NewRequest(userID, merchantID, company string, items []Item, note string, minQuantity int) (*Quote, error) {
+	if strings.TrimSpace(company) == "" {
+		return nil, fmt.Errorf("%w: company is required", ErrInvalidRequest)
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%w: at least one item is required", ErrInvalidRequest)
+	}
+
+	total := 0
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		if item.Quantity < 1 {
+			return nil, fmt.Errorf("%w: product %s needs a quantity of at least 1", ErrInvalidRequest, item.ProductID)
+		}
+		if seen[item.ProductID] {
+			return nil, fmt.Errorf("%w: product %s is listed twice", ErrInvalidRequest, item.ProductID)
+		}
+		seen[item.ProductID] = true
+		total += item.Quantity
+	}
+	if total < minQuantity {
+		return nil, fmt.Errorf("%w: quotes are for at least %d units, got %d", ErrInvalidRequest, minQuantity, total)
+	}
+
+	now := time.Now()
+	return &Quote{
+		ID:         id.New(),
+		UserID:     userID,
+		MerchantID: merchantID,
+		Company:    strings.TrimSpace(company),
+		Status:     StatusRequested,
+		Items:      items,
+		Note:       note,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}, nil
+}
+
+// Offer prices every item of the quote, by product ID, until validUntil. An
+// offer can be revised, even once it has lapsed, until the buyer accepts it.
+func (q *Quote) Offer(prices map[string]float64, validUntil time.Time, response string, now time.Time) error {
+	if q.Status != StatusRequested && q.Status != StatusOffered {
+		return fmt.Errorf("%w: quote is %s", ErrWrongStatus, q.Status)
+	}
+	if !validUntil.After(now) {
+		return fmt.Errorf("%w: valid_until must be in the future", ErrInvalidOffer)
+	}
+	if len(prices) != len(q.Items) {
+		return fmt.Errorf("%w: every item needs a price, and only the items", ErrInvalidOffer)
+	}
+
+	items := make([]Item, len(q.Items))
+	var total float64
+	for i, item := range q.Items {
+		price, ok := prices[item.ProductID]
+		if !ok {
+			return fmt.Errorf("%w: product %s needs a price", ErrInvalidOffer, item.ProductID)
+		}
+		if price <= 0 {
+			return fmt.Errorf("%w: the price of product %s must be positive", ErrInvalidOffer, item.ProductID)
+		}
+		item.Price = price
+		items[i] = item
+		total += price * float64(item.Quantity)
+	}
+
+	q.Items = items
+	q.TotalAmount = total
+	q.ValidUntil = &validUntil
+	q.Response = response
+	q.OfferedAt = &now
+	q.Status = StatusOffered
+	q.UpdatedAt = now
+	return nil
+}
+
+// Decline turns the request down, with why.
+func (q *Quote) Decline(response string, now time.Time) error {
+	if q.Status != StatusRequested && q.Status != StatusOffered {
+		return fmt.Errorf("%w: quote is %s", ErrWrongStatus, q.Status)
+	}
+	q.Response = response
+	q.Status = StatusDeclined
+	q.UpdatedAt = now
+	return nil
+}
+
+// Cancel withdraws the request, or turns its offer down.
+func (q *Quote) Cancel(now time.Time) error {
+	if q.Status != StatusRequested && q.Status != StatusOffered {
+		return fmt.Errorf("%w: quote is %s", ErrWrongStatus, q.Status)
+	}
+	q.Status = StatusCancelled
+	q.UpdatedAt = now
+	return nil
+}
+
+// CheckAcceptable reports whether the offer can still be taken at now, or
+// ErrExpired once it is past its validity.
+func (q *Quote) CheckAcceptable(now time.Time) error {
+	if q.ExpireAt(now) {
+		return ErrExpired
+	}
+	if q.Status != StatusOffered {
+		return fmt.Errorf("%w: quote is %s", ErrWrongStatus, q.Status)
+	}
+	return nil
+}
+
+// Accepted records the order the offer was converted into.
+func (q *Quote) Accepted(orderID string, now time.Time) {
+	q.OrderID = orderID
+	q.AcceptedAt = &now
+	q.Status = StatusAccepted
+	q.UpdatedAt = now
+}
+
+// ExpireAt expires an offer that was not accepted in time, and reports
+// whether it did. Offers are expired as they are read rather than by a job,
+// so a lapsed offer is still stored as offered and can be priced again.
+func (q *Quote) ExpireAt(now time.Time) bool {
+	if q.Status != StatusOffered || q.ValidUntil == nil || now.Before(*q.ValidUntil) {
+		return false
+	}
+	q.Status = StatusExpired
+	q.UpdatedAt = now
+	return true
+}
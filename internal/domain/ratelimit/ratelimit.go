@@ -0,0 +1,153 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ByIP   = "ip"
+	ByUser = "user"
+)
+
+// Policy allows Limit requests per sliding Window. Requests are counted per
+// client IP, or per user when By is "user" and the caller is authenticated.
+type Policy struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+	By     string
+}
+
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+type Limiter interface {
+	Allow(ctx context.Context, key string, policy Policy) (*Result, error)
+}
+
+// Route applies a policy to one route. Path is the gin route pattern, e.g.
+// /api/v1/orders/:id; a trailing * matches every route under the prefix.
+type Route struct {
+	Method string
+	Path   string
+	Policy Policy
+}
+
+type Rules struct {
+	Default        Policy
+	Routes         []Route
+	exemptRoles    map[string]bool
+	exemptNetworks []*net.IPNet
+}
+
+func NewPolicy(name string, limit int, window time.Duration, by string) (Policy, error) {
+	if limit <= 0 || window <= 0 {
+		return Policy{}, fmt.Errorf("rate limit policy %s needs a positive limit and window", name)
+	}
+	switch by {
+	case "":
+		by = ByIP
+	case ByIP, ByUser:
+	default:
+		return Policy{}, fmt.Errorf("rate limit policy %s: unsupported key %q", name, by)
+	}
+	return Policy{Name: name, Limit: limit, Window: window, By: by}, nil
+}
+
+func NewRules(def Policy, routes []Route, exemptRoles, exemptCIDRs []string) (*Rules, error) {
+	rules := &Rules{
+		Default:     def,
+		Routes:      routes,
+		exemptRoles: make(map[string]bool, len(exemptRoles)),
+	}
+	for _, role := range exemptRoles {
+		rules.exemptRoles[role] = true
+	}
+	for _, cidr := range exemptCIDRs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, errors.New("invalid rate limit exempt network: " + cidr)
+		}
+		rules.exemptNetworks = append(rules.exemptNetworks, network)
+	}
+	return rules, nil
+}
+
+// PolicyFor returns the first route policy matching the request, or the
+// default policy.
+func (r *Rules) PolicyFor(method, path string) Policy {
+	for _, route := range r.Routes {
+		if route.Method != "" && !strings.EqualFold(route.Method, method) {
+			continue
+		}
+		if prefix := strings.TrimSuffix(route.Path, "*"); prefix != route.Path {
+			if strings.HasPrefix(path, prefix) {
+				return route.Policy
+			}
+			continue
+		}
+		if route.Path == path {
+			return route.Policy
+		}
+	}
+	return r.Default
+}
+
+// Exempt reports whether the caller skips rate limiting: trusted roles such as
+// admins, and internal networks such as other services in the cluster.
+func (r *Rules) Exempt(role, clientIP string) bool {
+	if role != "" && r.exemptRoles[role] {
+		return true
+	}
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+	for _, network := range r.exemptNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Key is the counter a request is charged to.
+func (p Policy) Key(userID, clientIP string) string {
+	if p.By == ByUser && userID != "" {
+		return "ratelimit:" + p.Name + ":user:" + userID
+	}
+	return "ratelimit:" + p.Name + ":ip:" + clientIP
+}
+
+// RuleSet holds the rules in force so a config reload can replace them while
+// requests are being served.
+type RuleSet struct {
+	mu    sync.RWMutex
+	rules *Rules
+}
+
+func NewRuleSet(rules *Rules) *RuleSet {
+	return &RuleSet{rules: rules}
+}
+
+func (s *RuleSet) Rules() *Rules {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rules
+}
+
+func (s *RuleSet) Replace(rules *Rules) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = rules
+}
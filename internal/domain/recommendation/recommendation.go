@@ -0,0 +1,121 @@
+package recommendation
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"online-shop/internal/domain/product"
+)
+
+// Pair records how often RelatedID was bought in the same order as
+// ProductID. Confidence is Count divided by the number of orders that
+// contained ProductID.
+type Pair struct {
+	ProductID  string    `json:"product_id" gorm:"primaryKey"`
+	RelatedID  string    `json:"related_id" gorm:"primaryKey"`
+	Count      int       `json:"count"`
+	Confidence float64   `json:"confidence"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (Pair) TableName() string {
+	return "product_co_occurrences"
+}
+
+type Repository interface {
+	// Baskets returns the distinct product IDs of every non-cancelled order
+	// placed since the given time.
+	Baskets(ctx context.Context, since time.Time) ([][]string, error)
+	ReplacePairs(ctx context.Context, pairs []*Pair) error
+	BoughtTogether(ctx context.Context, productID string, limit int) ([]*Pair, error)
+}
+
+// SimilarityIndex finds products similar to the given one, best match first.
+type SimilarityIndex interface {
+	SimilarProducts(ctx context.Context, p *product.Product, limit int) ([]string, error)
+}
+
+// MinePairs counts product co-occurrences across baskets and keeps, for each
+// product, the maxPerProduct related products seen together at least
+// minSupport times.
+func MinePairs(baskets [][]string, minSupport, maxPerProduct int) []*Pair {
+	occurrences := make(map[string]int)
+	together := make(map[string]map[string]int)
+
+	for _, basket := range baskets {
+		seen := make(map[string]bool, len(basket))
+		var items []string
+		for _, id := range basket {
+			if id != "" && !seen[id] {
+				seen[id] = true
+				items = append(items, id)
+			}
+		}
+
+		for _, a := range items {
+			occurrences[a]++
+			for _, b := range items {
+				if a == b {
+					continue
+				}
+				if together[a] == nil {
+					together[a] = make(map[string]int)
+				}
+				together[a][b]++
+			}
+		}
+	}
+
+	now := time.Now()
+	var pairs []*Pair
+	for a, related := range together {
+		var candidates []*Pair
+		for b, count := range related {
+			if count < minSupport {
+				continue
+			}
+			candidates = append(candidates, &Pair{
+				ProductID:  a,
+				RelatedID:  b,
+				Count:      count,
+				Confidence: float64(count) / float64(occurrences[a]),
+				UpdatedAt:  now,
+			})
+		}
+
+		sort.Slice(candidates, func(i, j int) bool {
+			if candidates[i].Count != candidates[j].Count {
+				return candidates[i].Count > candidates[j].Count
+			}
+			return candidates[i].RelatedID < candidates[j].RelatedID
+		})
+		if maxPerProduct > 0 && len(candidates) > maxPerProduct {
+			candidates = candidates[:maxPerProduct]
+		}
+		pairs = append(pairs, candidates...)
+	}
+
+	return pairs
+}
+
+// RankByPrice orders candidates by how close their price is to the target's,
+// dropping the target itself and inactive products. It is used when the
+// similarity index is unavailable.
+func RankByPrice(target *product.Product, candidates []*product.Product, limit int) []*product.Product {
+	var ranked []*product.Product
+	for _, p := range candidates {
+		if p.ID != target.ID && p.Status == product.StatusActive {
+			ranked = append(ranked, p)
+		}
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return math.Abs(ranked[i].Price-target.Price) < math.Abs(ranked[j].Price-target.Price)
+	})
+	if limit > 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
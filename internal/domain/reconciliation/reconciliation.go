@@ -0,0 +1,319 @@
+package reconciliation
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"online-shop/internal/domain/payment"
+
+	"online-shop/pkg/id"
+)
+
+// JobName is the scheduler task that runs pending reconciliations
+const JobName = "payment-reconciliation"
+
+var (
+	ErrNotFound = errors.New("reconciliation run not found")
+	// ErrInvalidPeriod refuses a day that is malformed or has not ended yet
+	ErrInvalidPeriod = errors.New("invalid reconciliation period")
+)
+
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusCompleted Status = "completed"
+	StatusFailed    Status = "failed"
+)
+
+type Trigger string
+
+const (
+	TriggerManual    Trigger = "manual"
+	TriggerScheduled Trigger = "scheduled"
+)
+
+type Kind string
+
+const (
+	// KindMissing is a payment recorded as paid that the provider has no
+	// record of
+	KindMissing Kind = "missing"
+	// KindAmountDrift is a payment the provider settled for a different
+	// amount
+	KindAmountDrift Kind = "amount_drift"
+	// KindStatusMismatch is a payment whose status differs from the
+	// provider's, such as one still pending that the provider settled
+	KindStatusMismatch Kind = "status_mismatch"
+	// KindOrphaned is a settlement at the provider with no payment here
+	KindOrphaned Kind = "orphaned"
+)
+
+// Run reconciles one day of payments against a provider. The counts are
+// the report; Mismatches lists each payment that needs a look.
+type Run struct {
+	ID             string    `json:"id" gorm:"primaryKey"`
+	Provider       string    `json:"provider"`
+	Trigger        Trigger   `json:"trigger"`
+	RequestedBy    string    `json:"requested_by,omitempty"`
+	PeriodStart    time.Time `json:"period_start" gorm:"index"`
+	PeriodEnd      time.Time `json:"period_end"`
+	Status         Status    `json:"status" gorm:"index"`
+	Checked        int       `json:"checked"`
+	Matched        int       `json:"matched"`
+	Missing        int       `json:"missing"`
+	AmountDrift    int       `json:"amount_drift"`
+	StatusMismatch int       `json:"status_mismatch"`
+	Orphaned       int       `json:"orphaned"`
+	// LocalTotal and ProviderTotal are what each side counts as settled
+	LocalTotal    float64    `json:"local_total"`
+	ProviderTotal float64    `json:"provider_total"`
+	Error         string     `json:"error,omitempty"`
+	Mismatches    []Mismatch `json:"mismatches,omitempty" gorm:"foreignKey:RunID"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"index"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	StartedAt     *time.Time `json:"started_at"`
+	CompletedAt   *time.Time `json:"completed_at"`
+}
+
+func (Run) TableName() string {
+	return "reconciliation_runs"
+}
+
+type Mismatch struct {
+	ID             string         `json:"id" gorm:"primaryKey"`
+	RunID          string         `json:"-" gorm:"index"`
+	Kind           Kind           `json:"kind" gorm:"index"`
+	PaymentID      string         `json:"payment_id,omitempty"`
+	OrderID        string         `json:"order_id,omitempty"`
+	ExternalID     string         `json:"external_id"`
+	LocalAmount    float64        `json:"local_amount"`
+	ProviderAmount float64        `json:"provider_amount"`
+	LocalStatus    payment.Status `json:"local_status,omitempty"`
+	ProviderStatus payment.Status `json:"provider_status,omitempty"`
+}
+
+func (Mismatch) TableName() string {
+	return "reconciliation_mismatches"
+}
+
+// Settlement is the provider's record of one transaction.
+type Settlement struct {
+	ExternalID    string
+	TransactionID string
+	Amount        float64
+	Status        payment.Status
+	SettledAt     *time.Time
+}
+
+// Source reads a provider's settlements for a period. A provider with a
+// settlement report returns everything it settled in the period; one that
+// can only be asked about single transactions looks up the given payments.
+type Source interface {
+	Provider() string
+	Settlements(ctx context.Context, from, to time.Time, payments []*payment.Payment) ([]Settlement, error)
+}
+
+// Scheduler wakes a registered job outside its interval.
+type Scheduler interface {
+	Trigger(name string) error
+}
+
+type Repository interface {
+	Create(ctx context.Context, run *Run) error
+	// GetByID returns the run with its mismatches
+	GetByID(ctx context.Context, id string) (*Run, error)
+	// Update saves the run along with its mismatches
+	Update(ctx context.Context, run *Run) error
+	List(ctx context.Context, limit, offset int) ([]*Run, error)
+	// ListByStatus returns runs in the given state, oldest first
+	ListByStatus(ctx context.Context, status Status) ([]*Run, error)
+	// LatestForPeriod returns the newest run for the period starting at
+	// start, or nil
+	LatestForPeriod(ctx context.Context, start time.Time) (*Run, error)
+}
+
+// Day is the UTC day containing at.
+func Day(at time.Time) (time.Time, time.Time) {
+	at = at.UTC()
+	start := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 0, 1)
+}
+
+func NewRun(provider string, trigger Trigger, requestedBy string, day time.Time) *Run {
+	now := time.Now()
+	start, end := Day(day)
+	return &Run{
+		ID:          id.New(),
+		Provider:    provider,
+		Trigger:     trigger,
+		RequestedBy: requestedBy,
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Status:      StatusPending,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+func (r *Run) IsActive() bool {
+	return r.Status == StatusPending || r.Status == StatusRunning
+}
+
+func (r *Run) Start() {
+	now := time.Now()
+	r.Status = StatusRunning
+	r.Error = ""
+	r.StartedAt = &now
+	r.UpdatedAt = now
+}
+
+// Complete records the outcome of Reconcile on the run.
+func (r *Run) Complete(result *Result) {
+	now := time.Now()
+	r.Status = StatusCompleted
+	r.Checked = result.Checked
+	r.Matched = result.Matched
+	r.LocalTotal = result.LocalTotal
+	r.ProviderTotal = result.ProviderTotal
+	r.Missing, r.AmountDrift, r.StatusMismatch, r.Orphaned = 0, 0, 0, 0
+	r.Mismatches = make([]Mismatch, 0, len(result.Mismatches))
+	for _, m := range result.Mismatches {
+		m.ID = id.New()
+		m.RunID = r.ID
+		switch m.Kind {
+		case KindMissing:
+			r.Missing++
+		case KindAmountDrift:
+			r.AmountDrift++
+		case KindStatusMismatch:
+			r.StatusMismatch++
+		case KindOrphaned:
+			r.Orphaned++
+		}
+		r.Mismatches = append(r.Mismatches, m)
+	}
+	r.CompletedAt = &now
+	r.UpdatedAt = now
+}
+
+func (r *Run) Fail(err error) {
+	now := time.Now()
+	r.Status = StatusFailed
+	r.Error = err.Error()
+	r.CompletedAt = &now
+	r.UpdatedAt = now
+}
+
+type Result struct {
+	Checked       int
+	Matched       int
+	LocalTotal    float64
+	ProviderTotal float64
+	Mismatches    []Mismatch
+}
+
+// Reconcile matches settlements to payments by external ID. A settlement
+// matching none of the payments is orphaned unless lookup finds its payment
+// outside the period, as happens when a payment settles the day after it
+// was created; with a nil lookup every unmatched settlement is orphaned.
+func Reconcile(payments []*payment.Payment, settlements []Settlement, lookup func(externalID string) *payment.Payment) *Result {
+	settled := make(map[string]Settlement, len(settlements))
+	for _, s := range settlements {
+		settled[s.ExternalID] = s
+	}
+
+	result := &Result{Checked: len(payments)}
+	seen := make(map[string]bool, len(payments))
+	for _, p := range payments {
+		seen[p.ExternalID] = true
+		s, ok := settled[p.ExternalID]
+		if m := compare(p, s, ok); m != nil {
+			result.Mismatches = append(result.Mismatches, *m)
+		} else {
+			result.Matched++
+		}
+		if counted(p.Status) {
+			result.LocalTotal += p.Amount
+		}
+		if ok && counted(s.Status) {
+			result.ProviderTotal += s.Amount
+		}
+	}
+
+	for _, s := range settlements {
+		if seen[s.ExternalID] {
+			continue
+		}
+		if lookup != nil {
+			if p := lookup(s.ExternalID); p != nil {
+				// Reconciled with the run for the day it was created
+				continue
+			}
+		}
+		result.Mismatches = append(result.Mismatches, Mismatch{
+			Kind:           KindOrphaned,
+			ExternalID:     s.ExternalID,
+			ProviderAmount: s.Amount,
+			ProviderStatus: s.Status,
+		})
+		if counted(s.Status) {
+			result.ProviderTotal += s.Amount
+		}
+	}
+
+	sort.SliceStable(result.Mismatches, func(i, j int) bool {
+		return result.Mismatches[i].Kind < result.Mismatches[j].Kind
+	})
+	return result
+}
+
+func compare(p *payment.Payment, s Settlement, found bool) *Mismatch {
+	m := &Mismatch{
+		PaymentID:   p.ID,
+		OrderID:     p.OrderID,
+		ExternalID:  p.ExternalID,
+		LocalAmount: p.Amount,
+		LocalStatus: p.Status,
+	}
+	if !found {
+		// A payment that never got as far as the provider is nothing to
+		// reconcile
+		if !counted(p.Status) {
+			return nil
+		}
+		m.Kind = KindMissing
+		return m
+	}
+
+	m.ProviderAmount = s.Amount
+	m.ProviderStatus = s.Status
+	switch {
+	case outcome(p.Status) != outcome(s.Status):
+		m.Kind = KindStatusMismatch
+	case counted(p.Status) && math.Abs(p.Amount-s.Amount) > payment.Tolerance:
+		m.Kind = KindAmountDrift
+	default:
+		return nil
+	}
+	return m
+}
+
+// counted reports whether money changed hands for a payment in this state.
+func counted(status payment.Status) bool {
+	return status == payment.StatusPaid || status == payment.StatusRefunded
+}
+
+// outcome folds the ways a payment can fail into one, since providers do
+// not tell an expired payment from a cancelled one.
+func outcome(status payment.Status) payment.Status {
+	switch status {
+	case payment.StatusCancelled, payment.StatusExpired:
+		return payment.StatusFailed
+	}
+	return status
+}
@@ -0,0 +1,91 @@
+package searchsync
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/domain/product"
+	"online-shop/pkg/id"
+)
+
+// JobName is the scheduler job that applies captured changes
+const JobName = "search_sync"
+
+// Tables whose row changes are captured
+const (
+	EntityProduct  = "products"
+	EntityCategory = "categories"
+)
+
+// Change records that a row was written. It is written in the same
+// transaction as the row, so every committed write, whatever path made it,
+// reaches the search index and the caches, and none that rolled back does.
+// It holds no data: the row is read again when the change is applied, so
+// changes can be applied late, out of order or twice and still leave the
+// index as the database is.
+type Change struct {
+	ID       string `json:"id" gorm:"primaryKey"`
+	Entity   string `json:"entity"`
+	EntityID string `json:"entity_id"`
+	// Attempts counts the failed tries to apply the change
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"last_error,omitempty"`
+	NextAttemptAt time.Time `json:"next_attempt_at" gorm:"index"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (Change) TableName() string {
+	return "search_sync_changes"
+}
+
+func NewChange(entity, entityID string, at time.Time) *Change {
+	return &Change{
+		ID:            id.New(),
+		Entity:        entity,
+		EntityID:      entityID,
+		NextAttemptAt: at,
+		CreatedAt:     at,
+	}
+}
+
+// Fail records a failed try; the change is tried again after a backoff
+// doubling from 5 seconds up to 10 minutes. Changes are never given up on,
+// as the index would stay wrong.
+func (c *Change) Fail(err error, at time.Time) {
+	delay := 5 * time.Second << uint(c.Attempts)
+	if c.Attempts > 7 || delay > 10*time.Minute {
+		delay = 10 * time.Minute
+	}
+	c.Attempts++
+	c.LastError = err.Error()
+	c.NextAttemptAt = at.Add(delay)
+}
+
+type Repository interface {
+	// ListDue returns changes due at or before at, the oldest first
+	ListDue(ctx context.Context, at time.Time, limit int) ([]*Change, error)
+	// Claim pushes the next attempt of a due change back to until, so that
+	// another instance running the job does not apply it as well. It
+	// reports false when the change was claimed or applied in the meantime.
+	Claim(ctx context.Context, c *Change, until time.Time) (bool, error)
+	// Delete removes applied changes
+	Delete(ctx context.Context, ids []string) error
+	Update(ctx context.Context, c *Change) error
+	// Pending counts the changes not applied yet
+	Pending(ctx context.Context) (int64, error)
+}
+
+// Index is the search index kept in step with the products table
+type Index interface {
+	IndexProduct(ctx context.Context, p *product.Product) error
+	DeleteProduct(ctx context.Context, productID string) error
+}
+
+// ProductCache holds products cached one by one
+type ProductCache interface {
+	InvalidateProduct(ctx context.Context, productID string) error
+}
+
+// ListingPatterns are the cache keys of product and category listings and
+// search results, dropped after changes are applied as any may list them
+var ListingPatterns = []string{"products:*", "categories:*", "search:*"}
@@ -0,0 +1,112 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"online-shop/pkg/id"
+)
+
+var ErrNotFound = errors.New("session not found")
+
+// TouchInterval limits how often a request updates LastSeenAt, so an active
+// client does not write to the store on every call.
+const TouchInterval = time.Minute
+
+// Session is one signed-in device. Access and refresh tokens carry its ID and
+// stop working once it is revoked.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	Device     string    `json:"device"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type Store interface {
+	// Save writes the session, keeps it for ttl and sets ExpiresAt
+	Save(ctx context.Context, session *Session, ttl time.Duration) error
+	Get(ctx context.Context, id string) (*Session, error)
+	// Touch updates LastSeenAt and the IP address without changing the expiry
+	Touch(ctx context.Context, session *Session) error
+	ListByUser(ctx context.Context, userID string) ([]*Session, error)
+	Delete(ctx context.Context, session *Session) error
+}
+
+// NewSession starts a session; the store sets ExpiresAt when it is saved.
+func NewSession(userID, userAgent, ipAddress string) *Session {
+	now := time.Now()
+	return &Session{
+		ID:         id.New(),
+		UserID:     userID,
+		Device:     DescribeUserAgent(userAgent),
+		UserAgent:  userAgent,
+		IPAddress:  ipAddress,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+}
+
+// Seen records a request from the session and reports whether it is due to
+// be written back to the store.
+func (s *Session) Seen(ipAddress string, at time.Time) bool {
+	if at.Sub(s.LastSeenAt) < TouchInterval && ipAddress == s.IPAddress {
+		return false
+	}
+	s.LastSeenAt = at
+	s.IPAddress = ipAddress
+	return true
+}
+
+var (
+	browsers = []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"okhttp", "Android app"},
+		{"CFNetwork", "iOS app"},
+	}
+	platforms = []struct{ token, name string }{
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	}
+)
+
+// DescribeUserAgent turns a User-Agent header into a short label such as
+// "Chrome on Windows" for the session list.
+func DescribeUserAgent(userAgent string) string {
+	browser, platform := "", ""
+	for _, b := range browsers {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, p := range platforms {
+		if strings.Contains(userAgent, p.token) {
+			platform = p.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	}
+	return "Unknown device"
+}
@@ -0,0 +1,122 @@
+package shipping
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"online-shop/internal/domain/order"
+)
+
+// DateLayout is how estimated delivery dates are written
+const DateLayout = "2006-01-02"
+
+// Processing is how long a warehouse takes to hand an order to the carrier:
+// Days working days, counted from the next working day for orders placed at
+// or after CutoffHour. A CutoffHour of 0 has no cutoff.
+type Processing struct {
+	Days       int `json:"days"`
+	CutoffHour int `json:"cutoff_hour,omitempty"`
+}
+
+// Schedule counts the working days in the shop's time zone. Warehouses and
+// carriers both rest on weekends, when it skips them, and on its holidays.
+type Schedule struct {
+	location     *time.Location
+	skipWeekends bool
+	holidays     map[string]bool
+}
+
+// NewSchedule takes holidays as dates written with DateLayout.
+func NewSchedule(location *time.Location, skipWeekends bool, holidays []string) (*Schedule, error) {
+	if location == nil {
+		location = time.UTC
+	}
+	s := &Schedule{location: location, skipWeekends: skipWeekends, holidays: make(map[string]bool, len(holidays))}
+	for _, h := range holidays {
+		day, err := time.ParseInLocation(DateLayout, h, location)
+		if err != nil {
+			return nil, fmt.Errorf("holiday %q is not a date such as 2026-12-25", h)
+		}
+		s.holidays[day.Format(DateLayout)] = true
+	}
+	return s, nil
+}
+
+func (s *Schedule) working(day time.Time) bool {
+	if s.skipWeekends && (day.Weekday() == time.Saturday || day.Weekday() == time.Sunday) {
+		return false
+	}
+	return !s.holidays[day.Format(DateLayout)]
+}
+
+// addWorkingDays moves n working days on from day
+func (s *Schedule) addWorkingDays(day time.Time, n int) time.Time {
+	for n > 0 {
+		day = day.AddDate(0, 0, 1)
+		if s.working(day) {
+			n--
+		}
+	}
+	return day
+}
+
+// Dispatch is the day an order placed at the given time leaves a warehouse
+// taking p to process it.
+func (s *Schedule) Dispatch(at time.Time, p Processing) time.Time {
+	local := at.In(s.location)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, s.location)
+	if !s.working(day) || (p.CutoffHour > 0 && local.Hour() >= p.CutoffHour) {
+		day = s.addWorkingDays(day, 1)
+	}
+	return s.addWorkingDays(day, p.Days)
+}
+
+// Estimate is when an order placed at the given time arrives, with a
+// carrier taking minDays to maxDays working days once it is dispatched.
+func (s *Schedule) Estimate(at time.Time, p Processing, minDays, maxDays int) order.DeliveryEstimate {
+	if maxDays < minDays {
+		maxDays = minDays
+	}
+	dispatch := s.Dispatch(at, p)
+	return order.DeliveryEstimate{
+		Earliest: s.addWorkingDays(dispatch, minDays).Format(DateLayout),
+		Latest:   s.addWorkingDays(dispatch, maxDays).Format(DateLayout),
+	}
+}
+
+// EstimateOptions sets the estimate of every option of the quote.
+func (s *Schedule) EstimateOptions(q *Quote, at time.Time, p Processing) {
+	for i := range q.Options {
+		estimate := s.Estimate(at, p, q.Options[i].MinDays, q.Options[i].MaxDays)
+		q.Options[i].Estimate = &estimate
+	}
+}
+
+// Fastest is the processing of ps that dispatches an order placed at the
+// given time first, and Slowest the one that dispatches it last.
+func (s *Schedule) Fastest(at time.Time, ps []Processing) Processing {
+	return s.pick(at, ps, func(a, b time.Time) bool { return a.Before(b) })
+}
+
+func (s *Schedule) Slowest(at time.Time, ps []Processing) Processing {
+	return s.pick(at, ps, func(a, b time.Time) bool { return a.After(b) })
+}
+
+func (s *Schedule) pick(at time.Time, ps []Processing, better func(a, b time.Time) bool) Processing {
+	var best Processing
+	var bestDay time.Time
+	for i, p := range ps {
+		day := s.Dispatch(at, p)
+		if i == 0 || better(day, bestDay) {
+			best, bestDay = p, day
+		}
+	}
+	return best
+}
+
+// Estimator adds delivery estimates to quotes, from how long the warehouses
+// holding the products take to dispatch them.
+type Estimator interface {
+	EstimateQuote(ctx context.Context, quote *Quote, productIDs []string) error
+}
@@ -0,0 +1,348 @@
+// Package shipping holds where the shop delivers and what it charges for
+// it: zones of countries, provinces and postal code ranges, each with the
+// rates offered there, and the address lookup that checks an address can be
+// found before an order ships to it.
+package shipping
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"online-shop/internal/domain/order"
+	"online-shop/pkg/id"
+)
+
+var (
+	ErrZoneNotFound = errors.New("shipping zone not found")
+	// ErrInvalidZone is a zone with bad countries, postal ranges or rates
+	ErrInvalidZone = errors.New("invalid shipping zone")
+	// ErrNotServiceable is returned for an address outside every active zone
+	ErrNotServiceable = errors.New("the address is outside the shipping zones")
+	ErrRateNotFound   = errors.New("shipping rate not offered for the address")
+	// ErrAddressNotFound is returned by a Geocoder that cannot find the
+	// address, and for an address found in another country than given
+	ErrAddressNotFound = errors.New("address could not be found")
+)
+
+// PostalRange holds the postal codes from From to To, both included. Codes
+// are compared character by character once spaces and dashes are removed,
+// so only codes as long as the bounds fall in the range.
+type PostalRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Contains reports whether the postal code falls in the range
+func (r PostalRange) Contains(code string) bool {
+	code = NormalizePostalCode(code)
+	from, to := NormalizePostalCode(r.From), NormalizePostalCode(r.To)
+	return len(code) == len(from) && from <= code && code <= to
+}
+
+// NormalizePostalCode upper-cases a postal code without its spaces and
+// dashes
+func NormalizePostalCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(code))
+}
+
+// Rate is a delivery service offered in a zone, such as Regular or Express.
+type Rate struct {
+	ID   string  `json:"id"`
+	Name string  `json:"name"`
+	Fee  float64 `json:"fee"`
+	// FreeOver waives the fee for orders whose items add up to at least
+	// it; 0 never waives it
+	FreeOver float64 `json:"free_over,omitempty"`
+	MinDays  int     `json:"min_days,omitempty"`
+	MaxDays  int     `json:"max_days,omitempty"`
+}
+
+// FeeFor is what the rate charges for items adding up to subtotal
+func (r Rate) FeeFor(subtotal float64) float64 {
+	if r.FreeOver > 0 && subtotal >= r.FreeOver {
+		return 0
+	}
+	return r.Fee
+}
+
+// Zone is an area the shop delivers to: the listed countries, narrowed to
+// the listed provinces and postal code ranges when there are any.
+type Zone struct {
+	ID        string   `json:"id" gorm:"primaryKey"`
+	Name      string   `json:"name"`
+	Countries []string `json:"countries" gorm:"serializer:json"`
+	// Provinces are matched against the address state, ignoring case
+	Provinces    []string      `json:"provinces,omitempty" gorm:"serializer:json"`
+	PostalRanges []PostalRange `json:"postal_ranges,omitempty" gorm:"serializer:json"`
+	Rates        []Rate        `json:"rates" gorm:"serializer:json"`
+	Active       bool          `json:"active" gorm:"index"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+}
+
+func (Zone) TableName() string {
+	return "shipping_zones"
+}
+
+func NewZone(name string, countries, provinces []string, postalRanges []PostalRange, rates []Rate) (*Zone, error) {
+	z := &Zone{
+		ID:           id.New(),
+		Name:         name,
+		Countries:    countries,
+		Provinces:    provinces,
+		PostalRanges: postalRanges,
+		Active:       true,
+	}
+	z.SetRates(rates)
+	if err := z.Check(); err != nil {
+		return nil, err
+	}
+	z.CreatedAt = time.Now()
+	z.UpdatedAt = z.CreatedAt
+	return z, nil
+}
+
+// SetRates replaces the rates of the zone, keeping the IDs of those that
+// have one and giving the others a new one.
+func (z *Zone) SetRates(rates []Rate) {
+	z.Rates = make([]Rate, len(rates))
+	for i, r := range rates {
+		if r.ID == "" {
+			r.ID = id.New()
+		}
+		z.Rates[i] = r
+	}
+}
+
+// Check validates a zone after it is created or edited.
+func (z *Zone) Check() error {
+	if strings.TrimSpace(z.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidZone)
+	}
+	if len(z.Countries) == 0 {
+		return fmt.Errorf("%w: at least one country is required", ErrInvalidZone)
+	}
+	for _, c := range z.Countries {
+		if len(c) != 2 {
+			return fmt.Errorf("%w: country %q is not an ISO 3166 code", ErrInvalidZone, c)
+		}
+	}
+	for _, r := range z.PostalRanges {
+		from, to := NormalizePostalCode(r.From), NormalizePostalCode(r.To)
+		if from == "" || len(from) != len(to) || from > to {
+			return fmt.Errorf("%w: postal range %s-%s must run between codes of the same length", ErrInvalidZone, r.From, r.To)
+		}
+	}
+	if len(z.Rates) == 0 {
+		return fmt.Errorf("%w: at least one rate is required", ErrInvalidZone)
+	}
+	names := make(map[string]bool, len(z.Rates))
+	for _, r := range z.Rates {
+		key := strings.ToLower(strings.TrimSpace(r.Name))
+		if key == "" || names[key] {
+			return fmt.Errorf("%w: every rate needs a name of its own", ErrInvalidZone)
+		}
+		names[key] = true
+		if r.Fee < 0 || r.FreeOver < 0 {
+			return fmt.Errorf("%w: rate %s cannot have a negative fee", ErrInvalidZone, r.Name)
+		}
+		if r.MinDays < 0 || (r.MaxDays > 0 && r.MaxDays < r.MinDays) {
+			return fmt.Errorf("%w: rate %s must deliver in min_days to max_days", ErrInvalidZone, r.Name)
+		}
+	}
+	return nil
+}
+
+// Covers reports whether the address falls in the zone
+func (z *Zone) Covers(a order.Address) bool {
+	if !containsFold(z.Countries, a.Country) {
+		return false
+	}
+	if len(z.Provinces) > 0 && !containsFold(z.Provinces, a.State) {
+		return false
+	}
+	if len(z.PostalRanges) == 0 {
+		return true
+	}
+	for _, r := range z.PostalRanges {
+		if r.Contains(a.PostalCode) {
+			return true
+		}
+	}
+	return false
+}
+
+// specificity ranks zones narrowed by postal codes above those narrowed by
+// provinces, and both above whole countries
+func (z *Zone) specificity() int {
+	n := 0
+	if len(z.Provinces) > 0 {
+		n++
+	}
+	if len(z.PostalRanges) > 0 {
+		n += 2
+	}
+	return n
+}
+
+// Rate finds a rate of the zone by ID
+func (z *Zone) Rate(rateID string) *Rate {
+	for i := range z.Rates {
+		if z.Rates[i].ID == rateID {
+			return &z.Rates[i]
+		}
+	}
+	return nil
+}
+
+// Match finds the active zone the address falls in. When several do, the
+// most specific one wins, then the one listed first.
+func Match(zones []*Zone, a order.Address) *Zone {
+	var best *Zone
+	for _, z := range zones {
+		if !z.Active || !z.Covers(a) {
+			continue
+		}
+		if best == nil || z.specificity() > best.specificity() {
+			best = z
+		}
+	}
+	return best
+}
+
+func containsFold(values []string, value string) bool {
+	value = strings.TrimSpace(value)
+	for _, v := range values {
+		if strings.EqualFold(strings.TrimSpace(v), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Option is a rate offered for an address, with its fee for the order.
+type Option struct {
+	RateID  string  `json:"rate_id"`
+	Name    string  `json:"name"`
+	Fee     float64 `json:"fee"`
+	MinDays int     `json:"min_days,omitempty"`
+	MaxDays int     `json:"max_days,omitempty"`
+	// Estimate is when the order arrives at the rate, for quotes made with
+	// an Estimator
+	Estimate *order.DeliveryEstimate `json:"estimate,omitempty"`
+}
+
+// Quote is what shipping to an address costs. A shop without zones ships
+// everywhere at no charge: its quotes have no zone and no options.
+type Quote struct {
+	ZoneID   string    `json:"zone_id,omitempty"`
+	ZoneName string    `json:"zone_name,omitempty"`
+	Options  []Option  `json:"options"`
+	Location *Location `json:"location,omitempty"`
+}
+
+// NewQuote lists the rates of the zone for items adding up to subtotal,
+// cheapest first.
+func NewQuote(z *Zone, subtotal float64) *Quote {
+	q := &Quote{ZoneID: z.ID, ZoneName: z.Name, Options: make([]Option, 0, len(z.Rates))}
+	for _, r := range z.Rates {
+		q.Options = append(q.Options, Option{
+			RateID:  r.ID,
+			Name:    r.Name,
+			Fee:     r.FeeFor(subtotal),
+			MinDays: r.MinDays,
+			MaxDays: r.MaxDays,
+		})
+	}
+	sort.SliceStable(q.Options, func(i, j int) bool { return q.Options[i].Fee < q.Options[j].Fee })
+	return q
+}
+
+// Option finds the option of the rate, or the cheapest one when rateID is
+// empty.
+func (q *Quote) Option(rateID string) (*Option, error) {
+	if len(q.Options) == 0 {
+		return nil, ErrRateNotFound
+	}
+	if rateID == "" {
+		return &q.Options[0], nil
+	}
+	for i := range q.Options {
+		if q.Options[i].RateID == rateID {
+			return &q.Options[i], nil
+		}
+	}
+	return nil, ErrRateNotFound
+}
+
+// Quoter quotes shipping the products to an address. It is given to the
+// order handler, which charges the chosen option and turns away addresses
+// it cannot serve.
+type Quoter interface {
+	Quote(ctx context.Context, address order.Address, subtotal float64, productIDs []string) (*Quote, error)
+}
+
+type Repository interface {
+	Create(ctx context.Context, zone *Zone) error
+	GetByID(ctx context.Context, id string) (*Zone, error)
+	Update(ctx context.Context, zone *Zone) error
+	Delete(ctx context.Context, id string) error
+	List(ctx context.Context, limit, offset int) ([]*Zone, error)
+	// ListActive lists the active zones, oldest first
+	ListActive(ctx context.Context) ([]*Zone, error)
+}
+
+// Location is where a geocoder found an address.
+type Location struct {
+	Latitude         float64 `json:"latitude"`
+	Longitude        float64 `json:"longitude"`
+	FormattedAddress string  `json:"formatted_address,omitempty"`
+	// Country is the ISO 3166 code of the country the address is in
+	Country    string `json:"country,omitempty"`
+	State      string `json:"state,omitempty"`
+	PostalCode string `json:"postal_code,omitempty"`
+}
+
+// Geocoder looks addresses up with a provider such as Google or
+// Nominatim. An address the provider cannot find is ErrAddressNotFound;
+// any other error means the provider could not be asked.
+type Geocoder interface {
+	Name() string
+	Geocode(ctx context.Context, address order.Address) (*Location, error)
+}
+
+// LocationCache keeps the locations found for addresses, by AddressKey;
+// Get returns nil on a miss.
+type LocationCache interface {
+	Get(ctx context.Context, key string) (*Location, error)
+	Set(ctx context.Context, key string, location *Location, ttl time.Duration) error
+}
+
+// AddressKey identifies an address in the location cache. It is a hash, so
+// the cache holds no address in the clear.
+func AddressKey(a order.Address) string {
+	fields := []string{a.Street, a.City, a.State, NormalizePostalCode(a.PostalCode), a.Country}
+	for i, f := range fields {
+		fields[i] = strings.ToLower(strings.Join(strings.Fields(f), " "))
+	}
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Resolve fills the state and postal code the customer left out from where
+// the address was found, so the address can be matched against zones.
+func (l *Location) Resolve(a order.Address) order.Address {
+	if strings.TrimSpace(a.State) == "" {
+		a.State = l.State
+	}
+	if strings.TrimSpace(a.PostalCode) == "" {
+		a.PostalCode = l.PostalCode
+	}
+	return a
+}
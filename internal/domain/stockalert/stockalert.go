@@ -0,0 +1,137 @@
+package stockalert
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"online-shop/pkg/id"
+)
+
+// JobName is the scheduler job that notifies subscribers of restocked
+// products
+const JobName = "stock-alerts"
+
+var (
+	ErrNotFound = errors.New("stock alert not found")
+	// ErrNotifierUnavailable is returned by UnavailableNotifier
+	ErrNotifierUnavailable = errors.New("notification queue unavailable")
+)
+
+// Channel is the notification worker channel an alert is sent over.
+type Channel string
+
+const (
+	ChannelEmail    Channel = "email"
+	ChannelPush     Channel = "push"
+	ChannelSMS      Channel = "sms"
+	ChannelInApp    Channel = "in-app"
+	ChannelWhatsApp Channel = "whatsapp"
+)
+
+type Status string
+
+const (
+	StatusActive Status = "active"
+	// StatusNotified subscriptions were sent their alert and are done
+	StatusNotified  Status = "notified"
+	StatusExpired   Status = "expired"
+	StatusCancelled Status = "cancelled"
+)
+
+// Subscription asks for one notification when an out of stock product can
+// be bought again. A user has at most one active subscription per product.
+type Subscription struct {
+	ID         string     `json:"id" gorm:"primaryKey"`
+	UserID     string     `json:"user_id" gorm:"index:idx_stock_alerts_active,unique,where:status = 'active'"`
+	ProductID  string     `json:"product_id" gorm:"index:idx_stock_alerts_active,unique,where:status = 'active';index"`
+	Channel    Channel    `json:"channel"`
+	Status     Status     `json:"status" gorm:"index"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	NotifiedAt *time.Time `json:"notified_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func (Subscription) TableName() string {
+	return "stock_alerts"
+}
+
+type Repository interface {
+	Create(ctx context.Context, s *Subscription) error
+	GetByID(ctx context.Context, id string) (*Subscription, error)
+	Update(ctx context.Context, s *Subscription) error
+	// FindActive returns the user's active subscription to the product, or
+	// ErrNotFound
+	FindActive(ctx context.Context, userID, productID string) (*Subscription, error)
+	ListByUser(ctx context.Context, userID string, limit, offset int) ([]*Subscription, error)
+	// ListProductIDs returns the products that have active subscriptions
+	ListProductIDs(ctx context.Context) ([]string, error)
+	// ListActiveByProduct returns a product's active subscriptions, oldest
+	// first so the earliest subscribers hear first
+	ListActiveByProduct(ctx context.Context, productID string, limit int) ([]*Subscription, error)
+	// ExpireBefore expires active subscriptions that ran out before at
+	ExpireBefore(ctx context.Context, at time.Time) (int64, error)
+}
+
+// Alert is a back in stock notification for one subscriber.
+type Alert struct {
+	Subscription *Subscription
+	ProductName  string
+	ProductURL   string
+	Price        float64
+	// Phone and Locale address the whatsapp channel
+	Phone  string
+	Locale string
+}
+
+// Notifier hands alerts to the notification worker.
+type Notifier interface {
+	BackInStock(ctx context.Context, alert Alert) error
+}
+
+// UnavailableNotifier fails every back in stock alert while there is no
+// notification queue, which keeps the subscriptions open for the next run.
+type UnavailableNotifier struct{}
+
+func (UnavailableNotifier) BackInStock(ctx context.Context, alert Alert) error {
+	return ErrNotifierUnavailable
+}
+
+func NewSubscription(userID, productID string, channel Channel, lifetime time.Duration) *Subscription {
+	now := time.Now()
+	return &Subscription{
+		ID:        id.New(),
+		UserID:    userID,
+		ProductID: productID,
+		Channel:   channel,
+		Status:    StatusActive,
+		ExpiresAt: now.Add(lifetime),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+func (s *Subscription) IsActive() bool {
+	return s.Status == StatusActive
+}
+
+// Notified closes the subscription once its alert is sent; a later restock
+// needs a new subscription.
+func (s *Subscription) Notified() {
+	now := time.Now()
+	s.Status = StatusNotified
+	s.NotifiedAt = &now
+	s.UpdatedAt = now
+}
+
+func (s *Subscription) Cancel() {
+	s.Status = StatusCancelled
+	s.UpdatedAt = time.Now()
+}
+
+// Expire closes a subscription whose user can no longer be notified.
+func (s *Subscription) Expire() {
+	s.Status = StatusExpired
+	s.UpdatedAt = time.Now()
+}
@@ -0,0 +1,115 @@
+package storefront
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SettingsID is the key of the only settings row: the shop is a single
+// tenant
+const SettingsID = "default"
+
+// ErrInvalidSettings is returned for defaults the storefront does not offer
+var ErrInvalidSettings = errors.New("invalid storefront settings")
+
+// Settings tell storefronts how to present the shop: its name and theme,
+// the currencies and locales it sells in, how to reach it and what to offer
+// at checkout. Admins edit them; storefronts read them on start-up.
+type Settings struct {
+	ID        string `json:"-" gorm:"primaryKey"`
+	StoreName string `json:"store_name"`
+	LogoURL   string `json:"logo_url,omitempty"`
+	Theme     Theme  `json:"theme" gorm:"embedded;embeddedPrefix:theme_"`
+	// Currencies and Locales list what the storefront offers, each with
+	// the default one among them
+	Currencies      []string `json:"currencies" gorm:"serializer:json"`
+	DefaultCurrency string   `json:"default_currency"`
+	Locales         []string `json:"locales" gorm:"serializer:json"`
+	DefaultLocale   string   `json:"default_locale"`
+	Contact         Contact  `json:"contact" gorm:"embedded;embeddedPrefix:contact_"`
+	// OrderNumberPrefix starts the numbers of orders placed from now on
+	OrderNumberPrefix string    `json:"order_number_prefix"`
+	Checkout          Checkout  `json:"checkout" gorm:"embedded;embeddedPrefix:checkout_"`
+	UpdatedBy         string    `json:"updated_by,omitempty"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+func (Settings) TableName() string {
+	return "storefront_settings"
+}
+
+// Theme is what storefronts style themselves with
+type Theme struct {
+	PrimaryColor string `json:"primary_color,omitempty"`
+	AccentColor  string `json:"accent_color,omitempty"`
+	FaviconURL   string `json:"favicon_url,omitempty"`
+}
+
+type Contact struct {
+	Email   string `json:"email,omitempty"`
+	Phone   string `json:"phone,omitempty"`
+	Address string `json:"address,omitempty"`
+}
+
+// Checkout tells storefronts what to show at checkout. The API does not
+// enforce it: orders are accepted as they come.
+type Checkout struct {
+	AllowOrderNotes        bool   `json:"allow_order_notes"`
+	RequireTermsAcceptance bool   `json:"require_terms_acceptance"`
+	TermsURL               string `json:"terms_url,omitempty"`
+}
+
+// Validate checks the rules spanning several fields, which the command's
+// tags cannot express.
+func (s *Settings) Validate() error {
+	if !contains(s.Currencies, s.DefaultCurrency) {
+		return fmt.Errorf("%w: default_currency must be one of currencies", ErrInvalidSettings)
+	}
+	if !contains(s.Locales, s.DefaultLocale) {
+		return fmt.Errorf("%w: default_locale must be one of locales", ErrInvalidSettings)
+	}
+	if s.Checkout.RequireTermsAcceptance && s.Checkout.TermsURL == "" {
+		return fmt.Errorf("%w: checkout.terms_url is required when terms must be accepted", ErrInvalidSettings)
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Repository keeps the settings. Get returns nil when they were never
+// saved, and the defaults apply.
+type Repository interface {
+	Get(ctx context.Context) (*Settings, error)
+	Save(ctx context.Context, settings *Settings) error
+}
+
+// Cache holds the settings every storefront reads; Get returns nil on a
+// miss. Saving the settings clears it.
+type Cache interface {
+	Get(ctx context.Context) (*Settings, error)
+	Set(ctx context.Context, settings *Settings, ttl time.Duration) error
+	Clear(ctx context.Context) error
+}
+
+// Defaults are the settings until an admin saves them: the shop sells in
+// one currency and every locale it has messages for.
+func Defaults(storeName, currency, orderNumberPrefix string, locales []string, defaultLocale string) *Settings {
+	return &Settings{
+		ID:                SettingsID,
+		StoreName:         storeName,
+		Currencies:        []string{currency},
+		DefaultCurrency:   currency,
+		Locales:           locales,
+		DefaultLocale:     defaultLocale,
+		OrderNumberPrefix: orderNumberPrefix,
+	}
+}
@@ -0,0 +1,80 @@
+package systemlog
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrInvalidLevel = errors.New("invalid log level")
+
+// levelRanks orders the level names written by logrus and zap
+var levelRanks = map[string]int{
+	"trace":   0,
+	"debug":   1,
+	"info":    2,
+	"warn":    3,
+	"warning": 3,
+	"error":   4,
+	"dpanic":  5,
+	"panic":   6,
+	"fatal":   7,
+}
+
+type Entry struct {
+	Time    time.Time              `json:"time"`
+	Level   string                 `json:"level"`
+	Service string                 `json:"service"`
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+	Source  string                 `json:"source"`
+}
+
+// Filter selects log entries. Level is a minimum: "warn" also matches errors.
+type Filter struct {
+	Level   string
+	Service string
+	Search  string
+	From    time.Time
+	To      time.Time
+}
+
+type Reader interface {
+	Query(ctx context.Context, filter Filter, limit, offset int) ([]*Entry, int64, error)
+}
+
+func (f Filter) Validate() error {
+	if f.Level == "" {
+		return nil
+	}
+	if _, ok := levelRanks[strings.ToLower(f.Level)]; !ok {
+		return ErrInvalidLevel
+	}
+	return nil
+}
+
+// Matches reports whether an entry passes every set condition of the filter.
+// Unknown entry levels only match when no level is requested.
+func (f Filter) Matches(e *Entry) bool {
+	if f.Level != "" {
+		want := levelRanks[strings.ToLower(f.Level)]
+		got, ok := levelRanks[strings.ToLower(e.Level)]
+		if !ok || got < want {
+			return false
+		}
+	}
+	if f.Service != "" && !strings.EqualFold(f.Service, e.Service) {
+		return false
+	}
+	if !f.From.IsZero() && e.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !e.Time.Before(f.To) {
+		return false
+	}
+	if f.Search != "" && !strings.Contains(strings.ToLower(e.Message), strings.ToLower(f.Search)) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	EventDeletionRequested = "account.deletion_requested"
+	EventErased            = "account.erased"
+)
+
+var ErrDeletionPending = errors.New("account deletion is already pending")
+
+// DeletionEvent tells other services about an account deletion so they can
+// drop their own copies of the user's data. It carries no personal data.
+type DeletionEvent struct {
+	Type       string     `json:"type"`
+	UserID     string     `json:"user_id"`
+	OccurredAt time.Time  `json:"occurred_at"`
+	EraseAfter *time.Time `json:"erase_after,omitempty"`
+}
+
+type DeletionPublisher interface {
+	PublishDeletion(ctx context.Context, event DeletionEvent) error
+}
+
+// NopDeletionPublisher drops events; used when no message broker is configured.
+type NopDeletionPublisher struct{}
+
+func (NopDeletionPublisher) PublishDeletion(ctx context.Context, event DeletionEvent) error {
+	return nil
+}
+
+// ErasureRepository removes a deleted user's personal data. Erase replaces
+// the user row with an anonymized tombstone, deletes addresses, carts,
+// wishlists and linked logins, and scrubs orders, which are kept for
+// accounting and still reference the tombstone.
+type ErasureRepository interface {
+	ListDueForErasure(ctx context.Context, requestedBefore time.Time, limit int) ([]*User, error)
+	Erase(ctx context.Context, user *User) error
+}
+
+// RequestDeletion disables the account straight away; its data is erased
+// once the grace period has passed.
+func (u *User) RequestDeletion(at time.Time) error {
+	if u.Status == StatusDeleted {
+		return ErrDeletionPending
+	}
+	u.Status = StatusDeleted
+	u.DeletionRequestedAt = &at
+	u.UpdatedAt = at
+	return nil
+}
+
+// Anonymize clears the user's personal data. The placeholder email keeps the
+// unique index satisfied and frees the original address for a new signup.
+func (u *User) Anonymize(at time.Time) {
+	u.Email = "erased-" + u.ID + "@erased.invalid"
+	u.Password = ""
+	u.FirstName = ""
+	u.LastName = ""
+	u.Phone = ""
+	u.Birthday = nil
+	u.AgeVerifiedAt = nil
+	u.Gender = ""
+	u.MarketingConsent = MarketingConsent{}
+	u.ErasedAt = &at
+	u.UpdatedAt = at
+}
@@ -0,0 +1,184 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrInvalidProfile is a birthday, gender or marketing channel the
+	// profile cannot take
+	ErrInvalidProfile = errors.New("invalid profile")
+	// ErrNoMarketingConsent is returned for marketing the user has not
+	// agreed to receive
+	ErrNoMarketingConsent = errors.New("user has not agreed to marketing over this channel")
+)
+
+// BirthdayLayout is the format of birthdays in profile updates
+const BirthdayLayout = "2006-01-02"
+
+// oldestAge bounds how long ago a birthday can be
+const oldestAge = 130
+
+type Gender string
+
+// Customers may leave their gender unsaid, which is the empty Gender.
+const (
+	GenderFemale Gender = "female"
+	GenderMale   Gender = "male"
+	GenderOther  Gender = "other"
+)
+
+// Channel is a way marketing reaches a customer. They are the notification
+// worker's channels that carry marketing.
+type Channel string
+
+const (
+	ChannelEmail    Channel = "email"
+	ChannelSMS      Channel = "sms"
+	ChannelPush     Channel = "push"
+	ChannelWhatsApp Channel = "whatsapp"
+)
+
+// Channels are the channels marketing consent is asked for
+var Channels = []Channel{ChannelEmail, ChannelSMS, ChannelPush, ChannelWhatsApp}
+
+// Consent is the customer's answer for one channel. UpdatedAt is when they
+// last gave or withdrew it, and is nil when they were never asked.
+type Consent struct {
+	Granted   bool       `json:"granted"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// MarketingConsent is what the customer agreed to be sent marketing over.
+// Nobody is sent marketing until they opt in; orders, alerts they set up
+// and other messages about their account are not marketing.
+type MarketingConsent struct {
+	Email    Consent `json:"email" gorm:"embedded;embeddedPrefix:email_"`
+	SMS      Consent `json:"sms" gorm:"embedded;embeddedPrefix:sms_"`
+	Push     Consent `json:"push" gorm:"embedded;embeddedPrefix:push_"`
+	WhatsApp Consent `json:"whatsapp" gorm:"embedded;embeddedPrefix:whatsapp_"`
+}
+
+// ConsentRepository finds who agreed to marketing.
+type ConsentRepository interface {
+	// HasMarketingConsent reports whether the user agreed to marketing over
+	// the channel; unknown users have not
+	HasMarketingConsent(ctx context.Context, userID string, channel Channel) (bool, error)
+	// ConsentedEmails returns those of the addresses, lowercased, whose
+	// accounts agreed to marketing emails
+	ConsentedEmails(ctx context.Context, emails []string) (map[string]bool, error)
+}
+
+func (g Gender) Valid() bool {
+	switch g {
+	case "", GenderFemale, GenderMale, GenderOther:
+		return true
+	}
+	return false
+}
+
+func (c Channel) Valid() bool {
+	for _, channel := range Channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// consent returns the consent of a channel
+func (m *MarketingConsent) consent(channel Channel) *Consent {
+	switch channel {
+	case ChannelEmail:
+		return &m.Email
+	case ChannelSMS:
+		return &m.SMS
+	case ChannelPush:
+		return &m.Push
+	case ChannelWhatsApp:
+		return &m.WhatsApp
+	}
+	return nil
+}
+
+// Granted reports whether the customer agreed to marketing over the
+// channel.
+func (m MarketingConsent) Granted(channel Channel) bool {
+	c := m.consent(channel)
+	return c != nil && c.Granted
+}
+
+// SetBirthday sets the customer's date of birth, which must be in the past,
+// or with nil clears it. Only the date is kept.
+func (u *User) SetBirthday(birthday *time.Time, at time.Time) error {
+	if birthday != nil {
+		date := time.Date(birthday.Year(), birthday.Month(), birthday.Day(), 0, 0, 0, 0, time.UTC)
+		if !date.Before(at) {
+			return fmt.Errorf("%w: birthday must be in the past", ErrInvalidProfile)
+		}
+		if date.Before(at.AddDate(-oldestAge, 0, 0)) {
+			return fmt.Errorf("%w: birthday must be within the last %d years", ErrInvalidProfile, oldestAge)
+		}
+		birthday = &date
+	}
+	// A verified age only stands for the birthday it was verified against
+	if birthday == nil || u.Birthday == nil || !birthday.Equal(*u.Birthday) {
+		u.AgeVerifiedAt = nil
+	}
+	u.Birthday = birthday
+	u.UpdatedAt = at
+	return nil
+}
+
+// VerifyAge records that the customer's birthday was checked against an
+// identity document, setting it to the one on the document.
+func (u *User) VerifyAge(birthday time.Time, at time.Time) error {
+	if err := u.SetBirthday(&birthday, at); err != nil {
+		return err
+	}
+	u.AgeVerifiedAt = &at
+	return nil
+}
+
+// VerifiedAge is how old the customer is at at, in whole years. It is false
+// when their age was not verified.
+func (u *User) VerifiedAge(at time.Time) (int, bool) {
+	if u.AgeVerifiedAt == nil || u.Birthday == nil {
+		return 0, false
+	}
+	b := *u.Birthday
+	age := at.Year() - b.Year()
+	if b.AddDate(age, 0, 0).After(at) {
+		age--
+	}
+	return age, true
+}
+
+func (u *User) SetGender(gender Gender, at time.Time) error {
+	if !gender.Valid() {
+		return fmt.Errorf("%w: gender must be female, male, other or empty", ErrInvalidProfile)
+	}
+	u.Gender = gender
+	u.UpdatedAt = at
+	return nil
+}
+
+// SetMarketingConsent records the customer giving or withdrawing consent
+// to marketing over the channel. Answering the same again keeps when it was
+// first given.
+func (u *User) SetMarketingConsent(channel Channel, granted bool, at time.Time) error {
+	c := u.MarketingConsent.consent(channel)
+	if c == nil {
+		return fmt.Errorf("%w: %q is not a marketing channel", ErrInvalidProfile, channel)
+	}
+	if c.Granted == granted && c.UpdatedAt != nil {
+		return nil
+	}
+	c.Granted = granted
+	c.UpdatedAt = &at
+	u.UpdatedAt = at
+	return nil
+}
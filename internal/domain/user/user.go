@@ -1,10 +1,11 @@
 package user
 
 import (
+	"context"
 	"errors"
 	"time"
 
-	"github.com/google/uuid"
+	"online-shop/pkg/id"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -14,11 +15,23 @@ type User struct {
 	Password  string    `json:"-"`
 	FirstName string    `json:"first_name"`
 	LastName  string    `json:"last_name"`
-	Phone     string    `json:"phone"`
+	Phone     string    `json:"phone" gorm:"serializer:encrypted"`
 	Role      Role      `json:"role"`
 	Status    Status    `json:"status"`
+	// Locale is the language the user gets emails and messages in; empty
+	// means the default locale
+	Locale    string    `json:"locale,omitempty" gorm:"size:16"`
+	// Birthday and Gender are optional and only ever given by the user
+	Birthday         *time.Time       `json:"birthday,omitempty" gorm:"type:date"`
+	// AgeVerifiedAt is when staff checked Birthday against an identity
+	// document, which age restricted products need
+	AgeVerifiedAt    *time.Time       `json:"age_verified_at,omitempty"`
+	Gender           Gender           `json:"gender,omitempty" gorm:"size:16"`
+	MarketingConsent MarketingConsent `json:"marketing_consent" gorm:"embedded;embeddedPrefix:marketing_"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	DeletionRequestedAt *time.Time `json:"deletion_requested_at,omitempty" gorm:"index"`
+	ErasedAt            *time.Time `json:"erased_at,omitempty"`
 }
 
 type Role string
@@ -27,6 +40,8 @@ const (
 	RoleCustomer Role = "customer"
 	RoleAdmin    Role = "admin"
 	RoleMerchant Role = "merchant"
+	// RoleFulfillment is warehouse staff, who pick, pack and ship orders
+	RoleFulfillment Role = "fulfillment"
 )
 
 type Status string
@@ -35,15 +50,16 @@ const (
 	StatusActive   Status = "active"
 	StatusInactive Status = "inactive"
 	StatusSuspended Status = "suspended"
+	StatusDeleted   Status = "deleted"
 )
 
 type Repository interface {
-	Create(user *User) error
-	GetByID(id string) (*User, error)
-	GetByEmail(email string) (*User, error)
-	Update(user *User) error
-	Delete(id string) error
-	List(limit, offset int) ([]*User, error)
+	Create(ctx context.Context, user *User) error
+	GetByID(ctx context.Context, id string) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	Update(ctx context.Context, user *User) error
+	Delete(ctx context.Context, id string) error
+	List(ctx context.Context, limit, offset int) ([]*User, error)
 }
 
 type Service interface {
@@ -66,7 +82,7 @@ func NewUser(email, password, firstName, lastName, phone string) (*User, error)
 	}
 
 	return &User{
-		ID:        uuid.New().String(),
+		ID:        id.New(),
 		Email:     email,
 		Password:  string(hashedPassword),
 		FirstName: firstName,
@@ -79,6 +95,26 @@ func NewUser(email, password, firstName, lastName, phone string) (*User, error)
 	}, nil
 }
 
+// NewExternalUser creates a user who signed up through an identity provider.
+// The account has no password until the user sets one, so password login
+// fails for it.
+func NewExternalUser(email, firstName, lastName string) (*User, error) {
+	if email == "" {
+		return nil, errors.New("email is required")
+	}
+
+	return &User{
+		ID:        id.New(),
+		Email:     email,
+		FirstName: firstName,
+		LastName:  lastName,
+		Role:      RoleCustomer,
+		Status:    StatusActive,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}, nil
+}
+
 func (u *User) ValidatePassword(password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
 }
@@ -0,0 +1,214 @@
+package warehouse
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/shipping"
+
+	"online-shop/pkg/id"
+)
+
+var ErrCannotFulfill = errors.New("insufficient stock across warehouses")
+
+// ErrOutOfStock is returned when taking stock a warehouse no longer holds,
+// such as when another order took it since it was routed
+var ErrOutOfStock = errors.New("warehouse is out of stock")
+
+type Warehouse struct {
+	ID       string        `json:"id" gorm:"primaryKey"`
+	Code     string        `json:"code" gorm:"uniqueIndex"`
+	Name     string        `json:"name"`
+	Address  order.Address `json:"address" gorm:"embedded;embeddedPrefix:address_"`
+	Priority int           `json:"priority"` // lower is preferred
+	// ProcessingDays and CutoffHour are how long the warehouse takes to
+	// dispatch an order; without them the shop-wide processing applies
+	ProcessingDays *int      `json:"processing_days,omitempty"`
+	CutoffHour     *int      `json:"cutoff_hour,omitempty"`
+	Active         bool      `json:"active"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Stock is the on-hand quantity of a product in a single warehouse.
+type Stock struct {
+	ID          string    `json:"id" gorm:"primaryKey"`
+	WarehouseID string    `json:"warehouse_id" gorm:"uniqueIndex:idx_warehouse_product"`
+	ProductID   string    `json:"product_id" gorm:"uniqueIndex:idx_warehouse_product"`
+	Quantity    int       `json:"quantity"`
+	Reserved    int       `json:"reserved"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (Stock) TableName() string {
+	return "warehouse_stocks"
+}
+
+type Repository interface {
+	Create(ctx context.Context, warehouse *Warehouse) error
+	GetByID(ctx context.Context, id string) (*Warehouse, error)
+	Update(ctx context.Context, warehouse *Warehouse) error
+	List(ctx context.Context, limit, offset int) ([]*Warehouse, error)
+	ListActive(ctx context.Context) ([]*Warehouse, error)
+}
+
+type StockRepository interface {
+	GetByWarehouse(ctx context.Context, warehouseID string, limit, offset int) ([]*Stock, error)
+	GetByProducts(ctx context.Context, productIDs []string) ([]*Stock, error)
+	SetQuantity(ctx context.Context, warehouseID, productID string, quantity int) error
+	// AdjustQuantity adds delta to the quantity held. A negative delta
+	// larger than the quantity available, what is held less what is
+	// reserved, fails with ErrOutOfStock, leaving it as it was
+	AdjustQuantity(ctx context.Context, warehouseID, productID string, delta int) error
+}
+
+// Line is a product quantity the fulfillment router needs to source.
+type Line struct {
+	ProductID string
+	Quantity  int
+}
+
+// Allocation assigns part (or all) of a line to a warehouse.
+type Allocation struct {
+	ProductID   string
+	WarehouseID string
+	Quantity    int
+}
+
+func NewWarehouse(code, name string, address order.Address, priority int) (*Warehouse, error) {
+	if code == "" || name == "" {
+		return nil, errors.New("warehouse code and name are required")
+	}
+
+	return &Warehouse{
+		ID:        id.New(),
+		Code:      code,
+		Name:      name,
+		Address:   address,
+		Priority:  priority,
+		Active:    true,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}, nil
+}
+
+// Processing is how long the warehouse takes to dispatch an order, falling
+// back to the shop-wide processing for what it does not set.
+func (w *Warehouse) Processing(fallback shipping.Processing) shipping.Processing {
+	p := fallback
+	if w.ProcessingDays != nil {
+		p.Days = *w.ProcessingDays
+	}
+	if w.CutoffHour != nil {
+		p.CutoffHour = *w.CutoffHour
+	}
+	return p
+}
+
+func (s *Stock) Available() int {
+	return s.Quantity - s.Reserved
+}
+
+// proximity scores how close a warehouse is to the destination using the
+// address hierarchy; higher is closer.
+func (w *Warehouse) proximity(dest order.Address) int {
+	score := 0
+	if w.Address.Country != "" && w.Address.Country == dest.Country {
+		score++
+		if w.Address.State != "" && w.Address.State == dest.State {
+			score++
+			if w.Address.City != "" && w.Address.City == dest.City {
+				score++
+			}
+		}
+	}
+	return score
+}
+
+// Route sources every line from the active warehouses. A single warehouse
+// that can ship the whole order is preferred to avoid split shipments;
+// otherwise each line is filled from the closest warehouses first and may be
+// split across several of them.
+func Route(lines []Line, warehouses []*Warehouse, stock []*Stock, dest order.Address) ([]Allocation, error) {
+	available := make(map[string]map[string]int)
+	for _, s := range stock {
+		if available[s.WarehouseID] == nil {
+			available[s.WarehouseID] = make(map[string]int)
+		}
+		available[s.WarehouseID][s.ProductID] += s.Available()
+	}
+
+	var candidates []*Warehouse
+	for _, w := range warehouses {
+		if w.Active {
+			candidates = append(candidates, w)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		pi, pj := candidates[i].proximity(dest), candidates[j].proximity(dest)
+		if pi != pj {
+			return pi > pj
+		}
+		return candidates[i].Priority < candidates[j].Priority
+	})
+
+	// Single-warehouse fulfillment; a product on several lines needs their
+	// quantities together
+	needed := make(map[string]int)
+	for _, line := range lines {
+		needed[line.ProductID] += line.Quantity
+	}
+	for _, w := range candidates {
+		canShip := true
+		for productID, quantity := range needed {
+			if available[w.ID][productID] < quantity {
+				canShip = false
+				break
+			}
+		}
+		if canShip {
+			allocations := make([]Allocation, 0, len(lines))
+			for _, line := range lines {
+				allocations = append(allocations, Allocation{
+					ProductID:   line.ProductID,
+					WarehouseID: w.ID,
+					Quantity:    line.Quantity,
+				})
+			}
+			return allocations, nil
+		}
+	}
+
+	// Split shipment
+	var allocations []Allocation
+	for _, line := range lines {
+		remaining := line.Quantity
+		for _, w := range candidates {
+			if remaining == 0 {
+				break
+			}
+			qty := available[w.ID][line.ProductID]
+			if qty <= 0 {
+				continue
+			}
+			if qty > remaining {
+				qty = remaining
+			}
+			available[w.ID][line.ProductID] -= qty
+			remaining -= qty
+			allocations = append(allocations, Allocation{
+				ProductID:   line.ProductID,
+				WarehouseID: w.ID,
+				Quantity:    qty,
+			})
+		}
+		if remaining > 0 {
+			return nil, ErrCannotFulfill
+		}
+	}
+
+	return allocations, nil
+}
@@ -0,0 +1,450 @@
+// Package webhook notifies third parties of what happens in the shop by
+// posting events to the endpoints they register. Each event is stored as a
+// delivery per subscribed endpoint and sent by the webhook job, which
+// retries failed deliveries with backoff and logs every attempt.
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	mathrand "math/rand"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"online-shop/pkg/id"
+)
+
+// JobName is the scheduler job that sends due deliveries
+const JobName = "webhooks"
+
+// SignatureHeader carries the delivery's signature, as
+// t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>"> with the endpoint's
+// secret
+const SignatureHeader = "X-Webhook-Signature"
+
+var (
+	ErrEndpointNotFound = errors.New("webhook endpoint not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+	// ErrInvalidEndpoint is a URL or event list deliveries cannot use
+	ErrInvalidEndpoint = errors.New("invalid webhook endpoint")
+	// ErrEndpointDisabled is returned when redelivering to an endpoint that
+	// was turned off
+	ErrEndpointDisabled = errors.New("webhook endpoint is disabled")
+	// ErrPrivateDestination is returned when a delivery would go to the
+	// shop's own network rather than to a third party
+	ErrPrivateDestination = errors.New("webhook destination is not a public address")
+)
+
+// Event is the kind of thing that happened, which endpoints subscribe to.
+type Event string
+
+const (
+	EventOrderCreated   Event = "order.created"
+	EventOrderShipped   Event = "order.shipped"
+	EventProductUpdated Event = "product.updated"
+	EventQuoteRequested Event = "quote.requested"
+	EventQuoteAccepted  Event = "quote.accepted"
+)
+
+// Events are the events endpoints can subscribe to
+var Events = []Event{EventOrderCreated, EventOrderShipped, EventProductUpdated, EventQuoteRequested, EventQuoteAccepted}
+
+func (e Event) Valid() bool {
+	for _, known := range Events {
+		if e == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Endpoint is a URL a third party receives events at. A merchant's endpoint
+// hears of their own orders and products only; one without a merchant,
+// registered by an admin for a partner, hears of everything.
+type Endpoint struct {
+	ID          string  `json:"id" gorm:"primaryKey"`
+	MerchantID  string  `json:"merchant_id,omitempty" gorm:"index"`
+	URL         string  `json:"url"`
+	Description string  `json:"description,omitempty"`
+	Events      []Event `json:"events" gorm:"serializer:json"`
+	// Secret signs the deliveries; it is shown once, when the endpoint is
+	// registered
+	Secret    string    `json:"-" gorm:"serializer:encrypted"`
+	Active    bool      `json:"active"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Endpoint) TableName() string {
+	return "webhook_endpoints"
+}
+
+// NewEndpoint checks the URL and events and generates the signing secret.
+// allowLocal is as for Configure.
+func NewEndpoint(merchantID, rawURL, description string, events []Event, createdBy string, allowLocal bool) (*Endpoint, error) {
+	e := &Endpoint{
+		ID:          id.New(),
+		MerchantID:  merchantID,
+		Description: strings.TrimSpace(description),
+		Active:      true,
+		CreatedBy:   createdBy,
+	}
+	if err := e.Configure(rawURL, events, allowLocal); err != nil {
+		return nil, err
+	}
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+	e.Secret = secret
+	e.CreatedAt = time.Now()
+	e.UpdatedAt = e.CreatedAt
+	return e, nil
+}
+
+// Configure sets where the endpoint is and what it subscribes to. Each
+// event is kept once, in the order given. The URL must be https and must
+// not name an address on the shop's own network; allowLocal, which only
+// development sets, accepts plain http and local addresses so deliveries
+// can reach a receiver on the developer's machine.
+func (e *Endpoint) Configure(rawURL string, events []Event, allowLocal bool) error {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidEndpoint)
+	}
+	if u.User != nil {
+		return fmt.Errorf("%w: url cannot hold credentials; deliveries are authenticated by their signature", ErrInvalidEndpoint)
+	}
+	if !allowLocal {
+		if u.Scheme != "https" {
+			return fmt.Errorf("%w: url must use https", ErrInvalidEndpoint)
+		}
+		host := u.Hostname()
+		if ip := net.ParseIP(host); (ip != nil && !PublicIP(ip)) || strings.EqualFold(host, "localhost") {
+			return fmt.Errorf("%w: %v", ErrInvalidEndpoint, ErrPrivateDestination)
+		}
+	}
+
+	subscribed := make([]Event, 0, len(events))
+	seen := make(map[Event]bool, len(events))
+	for _, event := range events {
+		if !event.Valid() {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidEndpoint, event)
+		}
+		if !seen[event] {
+			seen[event] = true
+			subscribed = append(subscribed, event)
+		}
+	}
+	if len(subscribed) == 0 {
+		return fmt.Errorf("%w: subscribe to at least one event", ErrInvalidEndpoint)
+	}
+
+	e.URL = u.String()
+	e.Events = subscribed
+	e.UpdatedAt = time.Now()
+	return nil
+}
+
+// PublicIP reports whether deliveries may be sent to ip: loopback, private,
+// link-local, multicast and unspecified addresses are the shop's own
+// network, or no one's.
+func PublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+// RotateSecret replaces the signing secret; deliveries sent from now on are
+// signed with the new one.
+func (e *Endpoint) RotateSecret() error {
+	secret, err := newSecret()
+	if err != nil {
+		return err
+	}
+	e.Secret = secret
+	e.UpdatedAt = time.Now()
+	return nil
+}
+
+// Subscribes reports whether the endpoint is sent the event about the
+// merchants' orders or products.
+func (e *Endpoint) Subscribes(event Event, merchantIDs []string) bool {
+	if !e.Active {
+		return false
+	}
+	found := false
+	for _, subscribed := range e.Events {
+		if subscribed == event {
+			found = true
+			break
+		}
+	}
+	if !found || e.MerchantID == "" {
+		return found
+	}
+	for _, merchantID := range merchantIDs {
+		if merchantID == e.MerchantID {
+			return true
+		}
+	}
+	return false
+}
+
+// ManagedBy reports whether the endpoint can be seen and changed in the
+// merchant's scope. An empty scope is an admin's, who manages every
+// endpoint.
+func (e *Endpoint) ManagedBy(scope string) bool {
+	return scope == "" || e.MerchantID == scope
+}
+
+// Registration is an endpoint as registered or updated, with its secret
+// when it is new or was rotated; it is not shown again
+type Registration struct {
+	*Endpoint
+	Secret string `json:"secret,omitempty"`
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Notification is one occurrence of an event. It is the body of every
+// delivery made for it, so its ID is the same at each endpoint.
+type Notification struct {
+	ID        string      `json:"id"`
+	Event     Event       `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+func NewNotification(event Event, data interface{}, at time.Time) Notification {
+	return Notification{ID: id.New(), Event: event, CreatedAt: at, Data: data}
+}
+
+type DeliveryStatus string
+
+const (
+	// DeliveryPending deliveries are sent by the job at NextAttemptAt
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	// DeliveryFailed deliveries ran out of attempts; they can be
+	// redelivered by hand
+	DeliveryFailed DeliveryStatus = "failed"
+)
+
+// Delivery is a notification on its way to one endpoint. Payload is the
+// body as first built, so a retry sends the same bytes.
+type Delivery struct {
+	ID             string         `json:"id" gorm:"primaryKey"`
+	EndpointID     string         `json:"endpoint_id" gorm:"index"`
+	NotificationID string         `json:"notification_id" gorm:"index"`
+	Event          Event          `json:"event"`
+	Payload        string         `json:"payload" gorm:"type:text"`
+	Status         DeliveryStatus `json:"status" gorm:"index:idx_webhook_deliveries_due,priority:1"`
+	// Attempts counts the tries since the delivery was queued or last
+	// redelivered
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty" gorm:"index:idx_webhook_deliveries_due,priority:2"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	// AttemptLog is every try made, newest first, when the delivery is
+	// fetched on its own
+	AttemptLog []Attempt `json:"attempt_log,omitempty" gorm:"foreignKey:DeliveryID"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (Delivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// NewDelivery queues the notification for the endpoint, due at once.
+func NewDelivery(endpointID string, n Notification) (*Delivery, error) {
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &Delivery{
+		ID:             id.New(),
+		EndpointID:     endpointID,
+		NotificationID: n.ID,
+		Event:          n.Event,
+		Payload:        string(payload),
+		Status:         DeliveryPending,
+		NextAttemptAt:  &now,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}, nil
+}
+
+// Attempt is one try at sending a delivery, as the delivery log shows it.
+// Response is the start of what the endpoint answered.
+type Attempt struct {
+	ID          string    `json:"id" gorm:"primaryKey"`
+	DeliveryID  string    `json:"delivery_id" gorm:"index"`
+	Manual      bool      `json:"manual"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Response    string    `json:"response,omitempty"`
+	DurationMS  int64     `json:"duration_ms"`
+	AttemptedAt time.Time `json:"attempted_at" gorm:"index"`
+}
+
+func (Attempt) TableName() string {
+	return "webhook_delivery_attempts"
+}
+
+// MaxResponseLength is how much of an endpoint's answer an attempt keeps
+const MaxResponseLength = 1024
+
+// Succeeded reports whether the endpoint accepted the delivery, by
+// answering with a 2xx status.
+func (a *Attempt) Succeeded() bool {
+	return a.Error == "" && a.StatusCode/100 == 2
+}
+
+// RetryPolicy retries failed deliveries with exponential backoff. Attempts
+// counts every try, so 1 or less never retries.
+type RetryPolicy struct {
+	Attempts  int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// Backoff is the wait after the given failed attempt, counted from 0: the
+// delay doubles each attempt up to MaxDelay, with a random half of it as
+// jitter so an endpoint coming back up is not sent its backlog at once.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := float64(p.BaseDelay) * math.Pow(2, float64(attempt))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	half := delay / 2
+	return time.Duration(half + mathrand.Float64()*half)
+}
+
+// Record adds the attempt's outcome to the delivery. A failure is retried
+// after a backoff until the policy's attempts are used up, and then the
+// delivery fails.
+func (d *Delivery) Record(a *Attempt, policy RetryPolicy) {
+	if a.ID == "" {
+		a.ID = id.New()
+	}
+	a.DeliveryID = d.ID
+	d.Attempts++
+	d.LastStatusCode = a.StatusCode
+	d.LastError = a.Error
+	d.UpdatedAt = a.AttemptedAt
+
+	switch {
+	case a.Succeeded():
+		d.Status = DeliverySucceeded
+		d.DeliveredAt = &a.AttemptedAt
+		d.NextAttemptAt = nil
+	case d.Attempts >= policy.Attempts:
+		d.Status = DeliveryFailed
+		d.NextAttemptAt = nil
+	default:
+		next := a.AttemptedAt.Add(policy.Backoff(d.Attempts - 1))
+		d.Status = DeliveryPending
+		d.NextAttemptAt = &next
+	}
+	if d.LastError == "" && !a.Succeeded() {
+		d.LastError = fmt.Sprintf("endpoint answered with status %d", a.StatusCode)
+	}
+}
+
+// Redeliver queues the delivery again with a fresh set of attempts, as when
+// an endpoint that failed has been fixed. A delivery that succeeded can be
+// redelivered too; receivers tell repeats apart by the delivery ID.
+func (d *Delivery) Redeliver(at time.Time) {
+	d.Status = DeliveryPending
+	d.Attempts = 0
+	d.NextAttemptAt = &at
+	d.UpdatedAt = at
+}
+
+// Abandon fails a pending delivery that can no longer be sent, such as one
+// to an endpoint that was disabled.
+func (d *Delivery) Abandon(reason string, at time.Time) {
+	d.Status = DeliveryFailed
+	d.LastError = reason
+	d.NextAttemptAt = nil
+	d.UpdatedAt = at
+}
+
+// Sign returns the SignatureHeader value for a body sent at the given time.
+// Receivers recompute the HMAC over the timestamp and the raw body, and
+// should refuse timestamps too far from their clock to stop replays.
+func Sign(secret string, at time.Time, body []byte) string {
+	t := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sender posts a delivery to its endpoint, signed with the endpoint's
+// secret. The attempt always comes back; a failure to send is recorded on
+// it.
+type Sender interface {
+	Send(ctx context.Context, e *Endpoint, d *Delivery) *Attempt
+}
+
+type EndpointRepository interface {
+	Create(ctx context.Context, e *Endpoint) error
+	GetByID(ctx context.Context, id string) (*Endpoint, error)
+	Update(ctx context.Context, e *Endpoint) error
+	// Delete removes the endpoint with its deliveries and their log
+	Delete(ctx context.Context, id string) error
+	// List returns endpoints newest first; an empty merchantID lists every
+	// endpoint
+	List(ctx context.Context, merchantID string, limit, offset int) ([]*Endpoint, error)
+	// ListActive returns every endpoint that is not disabled
+	ListActive(ctx context.Context) ([]*Endpoint, error)
+}
+
+// DeliveryFilter narrows a delivery listing; empty fields match all.
+type DeliveryFilter struct {
+	EndpointID string
+	Status     DeliveryStatus
+	Event      Event
+}
+
+type DeliveryRepository interface {
+	CreateBatch(ctx context.Context, deliveries []*Delivery) error
+	// GetByID returns the delivery with its attempt log, or
+	// ErrDeliveryNotFound
+	GetByID(ctx context.Context, id string) (*Delivery, error)
+	// ListDue returns pending deliveries whose next attempt is at or before
+	// at, the longest waiting first
+	ListDue(ctx context.Context, at time.Time, limit int) ([]*Delivery, error)
+	// Claim pushes the next attempt of a due delivery back to until, so that
+	// another instance running the job does not send it as well. It
+	// reports false when the delivery was claimed or changed in the
+	// meantime.
+	Claim(ctx context.Context, d *Delivery, until time.Time) (bool, error)
+	// Record saves the delivery with the attempt just made
+	Record(ctx context.Context, d *Delivery, a *Attempt) error
+	Update(ctx context.Context, d *Delivery) error
+	// List returns a page of deliveries, newest first, and how many match
+	List(ctx context.Context, filter DeliveryFilter, limit, offset int) ([]*Delivery, int64, error)
+}
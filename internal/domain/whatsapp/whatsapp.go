@@ -0,0 +1,376 @@
+// Package whatsapp sends notifications over WhatsApp Business. Messages
+// outside a customer's reply window must use a template Meta has approved,
+// so templates are submitted from the admin API and their review status is
+// tracked here; a notification is sent with the template named after its
+// type.
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"online-shop/internal/domain/user"
+	"online-shop/pkg/i18n"
+
+	"online-shop/pkg/id"
+)
+
+var (
+	ErrTemplateNotFound    = errors.New("whatsapp template not found")
+	ErrTemplateNotApproved = errors.New("whatsapp template is not approved")
+	ErrMessageNotFound     = errors.New("whatsapp message not found")
+	ErrNoPhone             = errors.New("recipient has no phone number")
+	// ErrInvalidTemplate covers a bad name, category or placeholders, and
+	// notification data missing a parameter; ErrRejected is WhatsApp
+	// refusing a send, with its reason
+	ErrInvalidTemplate = errors.New("invalid whatsapp template")
+	ErrRejected        = errors.New("whatsapp rejected the request")
+	ErrInvalidWebhook  = errors.New("whatsapp webhook could not be verified")
+	// ErrDisabled is returned by DisabledClient.
+	ErrDisabled = errors.New("whatsapp is not enabled")
+)
+
+// Template categories
+const (
+	CategoryMarketing      = "MARKETING"
+	CategoryUtility        = "UTILITY"
+	CategoryAuthentication = "AUTHENTICATION"
+)
+
+// Template review statuses, as the Cloud API reports them
+const (
+	StatusPending  = "PENDING"
+	StatusApproved = "APPROVED"
+	StatusRejected = "REJECTED"
+	StatusPaused   = "PAUSED"
+	StatusDisabled = "DISABLED"
+)
+
+// Message delivery statuses, in the order a message goes through them
+const (
+	MessageSent      = "sent"
+	MessageDelivered = "delivered"
+	MessageRead      = "read"
+	MessageFailed    = "failed"
+)
+
+var (
+	namePattern        = regexp.MustCompile(`^[a-z0-9_]{1,512}$`)
+	placeholderPattern = regexp.MustCompile(`\{\{(\d+)\}\}`)
+)
+
+// Parameter fills one {{n}} placeholder of a template's body from the
+// notification data field of the same name. Meta reviews the template with
+// the example filled in.
+type Parameter struct {
+	Name    string `json:"name"`
+	Example string `json:"example"`
+}
+
+// Template is a message template for one language. Its name matches the
+// type of the notifications sent with it.
+type Template struct {
+	ID         string      `json:"id" gorm:"primaryKey"`
+	Name       string      `json:"name" gorm:"uniqueIndex:idx_whatsapp_template_name_language"`
+	Language   string      `json:"language" gorm:"uniqueIndex:idx_whatsapp_template_name_language"`
+	Category   string      `json:"category"`
+	Body       string      `json:"body"`
+	Parameters []Parameter `json:"parameters" gorm:"serializer:json"`
+	// ProviderID is the template's ID at Meta
+	ProviderID     string    `json:"provider_id,omitempty"`
+	Status         string    `json:"status"`
+	RejectedReason string    `json:"rejected_reason,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func (Template) TableName() string {
+	return "whatsapp_templates"
+}
+
+// TemplateStatus is a template's review status at Meta.
+type TemplateStatus struct {
+	Name           string
+	Language       string
+	ProviderID     string
+	Status         string
+	RejectedReason string
+}
+
+type TemplateRepository interface {
+	// Save creates the template or replaces the one with its name and
+	// language
+	Save(ctx context.Context, t *Template) error
+	Get(ctx context.Context, name, language string) (*Template, error)
+	List(ctx context.Context, limit, offset int) ([]*Template, error)
+	// SetStatus returns ErrTemplateNotFound when the template is not
+	// stored, as for templates created in Meta's console
+	SetStatus(ctx context.Context, s TemplateStatus) error
+	// Delete removes the template in every language, as Meta does
+	Delete(ctx context.Context, name string) error
+}
+
+// Message is a notification sent over WhatsApp, with the last delivery
+// status Meta reported for it.
+type Message struct {
+	// ID is the message ID Meta assigned
+	ID        string    `json:"id" gorm:"primaryKey"`
+	To        string    `json:"to" gorm:"index"`
+	UserID    string    `json:"user_id,omitempty" gorm:"index"`
+	Template  string    `json:"template"`
+	Language  string    `json:"language"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Message) TableName() string {
+	return "whatsapp_messages"
+}
+
+type MessageRepository interface {
+	Create(ctx context.Context, m *Message) error
+	Get(ctx context.Context, id string) (*Message, error)
+	List(ctx context.Context, limit, offset int) ([]*Message, error)
+	Update(ctx context.Context, m *Message) error
+}
+
+// StatusUpdate is a delivery status Meta posted for a message.
+type StatusUpdate struct {
+	MessageID string
+	Recipient string
+	Status    string
+	Error     string
+	Timestamp time.Time
+}
+
+// Updates are the changes in one webhook notification.
+type Updates struct {
+	Statuses  []StatusUpdate
+	Templates []TemplateStatus
+}
+
+// Client is the WhatsApp Business Cloud API.
+type Client interface {
+	SendTemplate(ctx context.Context, to string, t *Template, values []string) (messageID string, err error)
+	// SubmitTemplate sends the template for review and returns its status
+	SubmitTemplate(ctx context.Context, t *Template) (TemplateStatus, error)
+	DeleteTemplate(ctx context.Context, name string) error
+	Templates(ctx context.Context) ([]TemplateStatus, error)
+}
+
+// Webhook verifies Meta's webhook subscription and notifications.
+type Webhook interface {
+	// Challenge answers the subscription request, returning the challenge
+	// when the verify token matches
+	Challenge(mode, token, challenge string) (string, error)
+	Updates(signature string, body []byte) (*Updates, error)
+}
+
+// DisabledClient stands in when WhatsApp is not configured, so the admin
+// API reports it instead of failing on a missing token.
+type DisabledClient struct{}
+
+func (DisabledClient) SendTemplate(ctx context.Context, to string, t *Template, values []string) (string, error) {
+	return "", ErrDisabled
+}
+
+func (DisabledClient) SubmitTemplate(ctx context.Context, t *Template) (TemplateStatus, error) {
+	return TemplateStatus{}, ErrDisabled
+}
+
+func (DisabledClient) DeleteTemplate(ctx context.Context, name string) error {
+	return ErrDisabled
+}
+
+func (DisabledClient) Templates(ctx context.Context) ([]TemplateStatus, error) {
+	return nil, ErrDisabled
+}
+
+func (DisabledClient) Challenge(mode, token, challenge string) (string, error) {
+	return "", ErrDisabled
+}
+
+func (DisabledClient) Updates(signature string, body []byte) (*Updates, error) {
+	return nil, ErrDisabled
+}
+
+// NewTemplate checks a template before it is submitted for review. The body
+// must number its placeholders {{1}} to {{n}}, one per parameter.
+func NewTemplate(name, language, category, body string, parameters []Parameter) (*Template, error) {
+	if !namePattern.MatchString(name) {
+		return nil, fmt.Errorf("%w: name must be lowercase letters, digits and underscores", ErrInvalidTemplate)
+	}
+	switch category {
+	case CategoryMarketing, CategoryUtility, CategoryAuthentication:
+	default:
+		return nil, fmt.Errorf("%w: category must be one of MARKETING, UTILITY, AUTHENTICATION", ErrInvalidTemplate)
+	}
+
+	found := make(map[string]bool)
+	for _, match := range placeholderPattern.FindAllStringSubmatch(body, -1) {
+		found[match[1]] = true
+	}
+	if len(found) != len(parameters) {
+		return nil, fmt.Errorf("%w: body has %d placeholders but %d parameters are given", ErrInvalidTemplate, len(found), len(parameters))
+	}
+	for i, p := range parameters {
+		if !found[fmt.Sprint(i+1)] {
+			return nil, fmt.Errorf("%w: body has no {{%d}} placeholder", ErrInvalidTemplate, i+1)
+		}
+		if p.Name == "" || p.Example == "" {
+			return nil, fmt.Errorf("%w: parameters[%d] needs a name and an example", ErrInvalidTemplate, i)
+		}
+	}
+
+	now := time.Now()
+	return &Template{
+		ID:         id.New(),
+		Name:       name,
+		Language:   language,
+		Category:   category,
+		Body:       body,
+		Parameters: parameters,
+		Status:     StatusPending,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}, nil
+}
+
+// Values fills the template's parameters from notification data.
+func (t *Template) Values(data map[string]interface{}) ([]string, error) {
+	values := make([]string, len(t.Parameters))
+	for i, p := range t.Parameters {
+		value, ok := data[p.Name]
+		if !ok || value == nil {
+			return nil, fmt.Errorf("%w: %s is missing from the notification data", ErrInvalidTemplate, p.Name)
+		}
+		values[i] = fmt.Sprint(value)
+	}
+	return values, nil
+}
+
+// Apply records a delivery status. Statuses can arrive out of order, so one
+// behind the message's current status is ignored; a failure always applies.
+func (m *Message) Apply(u StatusUpdate) bool {
+	if u.Status != MessageFailed && statusRank(u.Status) <= statusRank(m.Status) {
+		return false
+	}
+	m.Status = u.Status
+	m.Error = u.Error
+	m.UpdatedAt = u.Timestamp
+	if m.UpdatedAt.IsZero() {
+		m.UpdatedAt = time.Now()
+	}
+	return true
+}
+
+func statusRank(status string) int {
+	switch status {
+	case MessageSent:
+		return 1
+	case MessageDelivered:
+		return 2
+	case MessageRead:
+		return 3
+	}
+	return 0
+}
+
+// NormalizePhone turns a phone number into the international digits the
+// Cloud API expects. Indonesian numbers written with a leading 0, such as
+// 0812..., get the 62 country code.
+func NormalizePhone(phone string) string {
+	var digits strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	normalized := digits.String()
+	if strings.HasPrefix(normalized, "0") {
+		normalized = "62" + strings.TrimPrefix(normalized, "0")
+	}
+	return normalized
+}
+
+// Notification is what the notification worker sends over WhatsApp.
+type Notification struct {
+	UserID string
+	Type   string
+	Phone  string
+	Locale string
+	Data   map[string]interface{}
+}
+
+// Sender sends notifications with the approved template named after their
+// type, in the recipient's language or else the default one. Marketing
+// templates are only sent to users who agreed to marketing over WhatsApp.
+type Sender struct {
+	client    Client
+	templates TemplateRepository
+	messages  MessageRepository
+	consents  user.ConsentRepository
+}
+
+func NewSender(client Client, templates TemplateRepository, messages MessageRepository, consents user.ConsentRepository) *Sender {
+	return &Sender{client: client, templates: templates, messages: messages, consents: consents}
+}
+
+func (s *Sender) Send(ctx context.Context, n Notification) (*Message, error) {
+	to := NormalizePhone(n.Phone)
+	if to == "" {
+		return nil, ErrNoPhone
+	}
+
+	language := i18n.Negotiate("", n.Locale)
+	t, err := s.templates.Get(ctx, n.Type, language)
+	if errors.Is(err, ErrTemplateNotFound) && language != i18n.DefaultLocale {
+		t, err = s.templates.Get(ctx, n.Type, i18n.DefaultLocale)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.Status != StatusApproved {
+		return nil, fmt.Errorf("%w: %s (%s) is %s", ErrTemplateNotApproved, t.Name, t.Language, t.Status)
+	}
+	if t.Category == CategoryMarketing {
+		consented, err := s.consents.HasMarketingConsent(ctx, n.UserID, user.ChannelWhatsApp)
+		if err != nil {
+			return nil, err
+		}
+		if !consented {
+			return nil, user.ErrNoMarketingConsent
+		}
+	}
+
+	values, err := t.Values(n.Data)
+	if err != nil {
+		return nil, err
+	}
+	id, err := s.client.SendTemplate(ctx, to, t, values)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	message := &Message{
+		ID:        id,
+		To:        to,
+		UserID:    n.UserID,
+		Template:  t.Name,
+		Language:  t.Language,
+		Status:    MessageSent,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.messages.Create(ctx, message); err != nil {
+		return nil, fmt.Errorf("failed to record whatsapp message: %w", err)
+	}
+	return message, nil
+}
@@ -0,0 +1,99 @@
+package archive
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"time"
+
+	"online-shop/internal/domain/export"
+)
+
+// Archivers returns the archiver for every supported personal data format.
+func Archivers() map[export.Format]export.Archiver {
+	return map[export.Format]export.Archiver{
+		export.FormatJSON: NewJSONArchiver(),
+		export.FormatZIP:  NewZIPArchiver(),
+	}
+}
+
+// JSONArchiver writes the whole export as a single JSON document.
+type JSONArchiver struct{}
+
+func NewJSONArchiver() export.Archiver {
+	return JSONArchiver{}
+}
+
+func (JSONArchiver) ContentType() string {
+	return "application/json"
+}
+
+func (JSONArchiver) Extension() string {
+	return "json"
+}
+
+func (JSONArchiver) Archive(w io.Writer, data *export.PersonalData) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(data)
+}
+
+// ZIPArchiver writes one JSON file per section plus a manifest describing
+// them.
+type ZIPArchiver struct{}
+
+func NewZIPArchiver() export.Archiver {
+	return ZIPArchiver{}
+}
+
+func (ZIPArchiver) ContentType() string {
+	return "application/zip"
+}
+
+func (ZIPArchiver) Extension() string {
+	return "zip"
+}
+
+type manifestFile struct {
+	Name    string `json:"name"`
+	Records int    `json:"records"`
+}
+
+type manifest struct {
+	UserID      string         `json:"user_id"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	Files       []manifestFile `json:"files"`
+}
+
+func (ZIPArchiver) Archive(w io.Writer, data *export.PersonalData) error {
+	zw := zip.NewWriter(w)
+
+	m := manifest{UserID: data.UserID, GeneratedAt: data.GeneratedAt}
+	for _, section := range data.Sections() {
+		name := section.Name + ".json"
+		if err := writeJSON(zw, name, data.GeneratedAt, section.Data); err != nil {
+			return err
+		}
+		m.Files = append(m.Files, manifestFile{Name: name, Records: section.Records})
+	}
+
+	if err := writeJSON(zw, "manifest.json", data.GeneratedAt, m); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func writeJSON(zw *zip.Writer, name string, modified time.Time, v interface{}) error {
+	f, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
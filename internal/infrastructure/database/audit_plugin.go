@@ -0,0 +1,178 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+
+	"online-shop/internal/domain/audit"
+	"online-shop/pkg/fieldcrypt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const auditBeforeKey = "audit:before"
+
+// AuditedTables are the tables whose row changes are written to the audit log
+var AuditedTables = []string{"products", "categories", "orders", "payments", "users", "rules", "warehouse_stocks"}
+
+// AuditPlugin is a GORM plugin that snapshots audited rows around updates and
+// deletes and records the before/after diff. It sees every write path
+// (handlers, workers, payment callbacks); the actor is taken from the
+// statement context when one was attached and is "system" otherwise.
+type AuditPlugin struct {
+	tables map[string]bool
+}
+
+func NewAuditPlugin(tables ...string) *AuditPlugin {
+	p := &AuditPlugin{tables: make(map[string]bool, len(tables))}
+	for _, table := range tables {
+		p.tables[table] = true
+	}
+	return p
+}
+
+func (p *AuditPlugin) Name() string {
+	return "audit"
+}
+
+func (p *AuditPlugin) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Update().Before("gorm:update").Register("audit:before_update", p.snapshot); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After("gorm:update").Register("audit:after_update", p.recordUpdate); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().Before("gorm:delete").Register("audit:before_delete", p.snapshot); err != nil {
+		return err
+	}
+	return db.Callback().Delete().After("gorm:delete").Register("audit:after_delete", p.recordDelete)
+}
+
+func (p *AuditPlugin) snapshot(db *gorm.DB) {
+	field, id, ok := p.target(db)
+	if !ok {
+		return
+	}
+
+	row, err := p.load(db, field, id)
+	if err != nil {
+		return
+	}
+	db.InstanceSet(auditBeforeKey, row)
+}
+
+func (p *AuditPlugin) recordUpdate(db *gorm.DB) {
+	before, field, id, ok := p.before(db)
+	if !ok {
+		return
+	}
+
+	after, err := p.load(db, field, id)
+	if err != nil {
+		return
+	}
+	p.record(db, "update", id, audit.Diff(before, after))
+}
+
+func (p *AuditPlugin) recordDelete(db *gorm.DB) {
+	before, _, id, ok := p.before(db)
+	if !ok {
+		return
+	}
+	p.record(db, "delete", id, audit.Diff(before, nil))
+}
+
+// target returns the primary key of the single row a statement writes. Bulk
+// writes by condition are not audited at the row level.
+func (p *AuditPlugin) target(db *gorm.DB) (*schema.Field, interface{}, bool) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil || !p.tables[stmt.Table] {
+		return nil, nil, false
+	}
+	if stmt.ReflectValue.Kind() != reflect.Struct {
+		return nil, nil, false
+	}
+
+	field := stmt.Schema.PrioritizedPrimaryField
+	if field == nil {
+		return nil, nil, false
+	}
+	id, zero := field.ValueOf(stmt.Context, stmt.ReflectValue)
+	if zero {
+		return nil, nil, false
+	}
+	return field, id, true
+}
+
+func (p *AuditPlugin) before(db *gorm.DB) (map[string]interface{}, *schema.Field, interface{}, bool) {
+	if db.Error != nil || db.Statement.RowsAffected == 0 {
+		return nil, nil, nil, false
+	}
+	value, ok := db.InstanceGet(auditBeforeKey)
+	if !ok {
+		return nil, nil, nil, false
+	}
+	field, id, ok := p.target(db)
+	if !ok {
+		return nil, nil, nil, false
+	}
+	return value.(map[string]interface{}), field, id, true
+}
+
+func (p *AuditPlugin) load(db *gorm.DB, field *schema.Field, id interface{}) (map[string]interface{}, error) {
+	row := make(map[string]interface{})
+	err := db.Session(&gorm.Session{NewDB: true}).
+		Table(db.Statement.Table).
+		Where(fmt.Sprintf("%s = ?", field.DBName), id).
+		Take(&row).Error
+	if err != nil {
+		return row, err
+	}
+	return row, decryptColumns(db.Statement.Schema, row)
+}
+
+// decryptColumns replaces encrypted column values with their plaintext.
+// Every write seals a value afresh, so the ciphertexts of an unchanged value
+// would otherwise show up as a change.
+func decryptColumns(s *schema.Schema, row map[string]interface{}) error {
+	keyring := fieldcrypt.Current()
+	if keyring == nil {
+		return nil
+	}
+	for _, field := range s.Fields {
+		if _, ok := field.Serializer.(fieldcrypt.Serializer); !ok {
+			continue
+		}
+		value, ok := row[field.DBName].(string)
+		if !ok {
+			continue
+		}
+		plaintext, err := keyring.Decrypt(value)
+		if err != nil {
+			return err
+		}
+		row[field.DBName] = plaintext
+	}
+	return nil
+}
+
+func (p *AuditPlugin) record(db *gorm.DB, action string, id interface{}, changes map[string]audit.Change) {
+	if len(changes) == 0 {
+		return
+	}
+
+	stmt := db.Statement
+	actor, _ := audit.ActorFromContext(stmt.Context)
+	entry := audit.NewEntry(audit.SourceRepository, actor.ID, stmt.Table+"."+action, stmt.Table, fmt.Sprint(id))
+	entry.ActorRole = actor.Role
+	entry.ImpersonatorID = actor.ImpersonatorID
+	entry.RequestID = actor.RequestID
+	entry.Changes = changes
+
+	// Written on the same connection so the entry commits or rolls back with
+	// the change it describes
+	if err := db.Session(&gorm.Session{NewDB: true}).Create(entry).Error; err != nil {
+		db.Logger.Error(stmt.Context, "failed to write audit log for %s %v: %v", stmt.Table, id, err)
+	}
+}
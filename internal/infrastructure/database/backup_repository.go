@@ -0,0 +1,63 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"online-shop/internal/domain/backup"
+
+	"gorm.io/gorm"
+)
+
+type BackupRepository struct {
+	db *gorm.DB
+}
+
+func NewBackupRepository(db *gorm.DB) backup.Repository {
+	return &BackupRepository{db: db}
+}
+
+func (r *BackupRepository) Create(ctx context.Context, b *backup.Backup) error {
+	return conn(ctx, r.db).Create(b).Error
+}
+
+func (r *BackupRepository) GetByID(ctx context.Context, id string) (*backup.Backup, error) {
+	var b backup.Backup
+	err := conn(ctx, r.db).Where("id = ?", id).First(&b).Error
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (r *BackupRepository) Update(ctx context.Context, b *backup.Backup) error {
+	return conn(ctx, r.db).Save(b).Error
+}
+
+func (r *BackupRepository) List(ctx context.Context, limit, offset int) ([]*backup.Backup, error) {
+	var backups []*backup.Backup
+	err := conn(ctx, r.db).Order("created_at DESC").
+		Limit(limit).Offset(offset).
+		Find(&backups).Error
+	return backups, err
+}
+
+func (r *BackupRepository) ListByStatus(ctx context.Context, status backup.Status) ([]*backup.Backup, error) {
+	var backups []*backup.Backup
+	err := conn(ctx, r.db).Where("status = ?", status).
+		Order("created_at ASC").
+		Find(&backups).Error
+	return backups, err
+}
+
+func (r *BackupRepository) Latest(ctx context.Context, status backup.Status) (*backup.Backup, error) {
+	var b backup.Backup
+	err := conn(ctx, r.db).Where("status = ?", status).Order("created_at DESC").First(&b).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/domain/badge"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/product"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BadgeRepository struct {
+	db *gorm.DB
+}
+
+func NewBadgeRepository(db *gorm.DB) badge.Repository {
+	return &BadgeRepository{db: db}
+}
+
+func (r *BadgeRepository) ListDefinitions(ctx context.Context) ([]*badge.Definition, error) {
+	var definitions []*badge.Definition
+	err := conn(ctx, r.db).Order("priority ASC, code ASC").Find(&definitions).Error
+	return definitions, err
+}
+
+func (r *BadgeRepository) SaveDefinition(ctx context.Context, d *badge.Definition) error {
+	return conn(ctx, r.db).Save(d).Error
+}
+
+func (r *BadgeRepository) DeleteDefinition(ctx context.Context, code string) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("code = ?", code).Delete(&badge.Definition{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return badge.ErrBadgeNotFound
+		}
+		return tx.Where("code = ?", code).Delete(&badge.Assignment{}).Error
+	})
+}
+
+func (r *BadgeRepository) Assign(ctx context.Context, a *badge.Assignment) error {
+	return conn(ctx, r.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "product_id"}, {Name: "code"}},
+		DoUpdates: clause.AssignmentColumns([]string{"expires_at", "created_by", "created_at"}),
+	}).Create(a).Error
+}
+
+func (r *BadgeRepository) Unassign(ctx context.Context, productID, code string) error {
+	result := conn(ctx, r.db).Where("product_id = ? AND code = ?", productID, code).Delete(&badge.Assignment{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return badge.ErrBadgeNotFound
+	}
+	return nil
+}
+
+func (r *BadgeRepository) Assignments(ctx context.Context, productIDs []string, at time.Time) ([]*badge.Assignment, error) {
+	var assignments []*badge.Assignment
+	if len(productIDs) == 0 {
+		return assignments, nil
+	}
+	err := conn(ctx, r.db).
+		Where("product_id IN ?", productIDs).
+		Where("expires_at IS NULL OR expires_at > ?", at).
+		Find(&assignments).Error
+	return assignments, err
+}
+
+func (r *BadgeRepository) BestSellers(ctx context.Context, since time.Time, limit int) ([]string, error) {
+	var ids []string
+	err := conn(ctx, r.db).Table("order_items").
+		Select("order_items.product_id").
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("orders.created_at >= ? AND orders.status NOT IN ?", since, []order.Status{order.StatusCancelled, order.StatusRefunded}).
+		Group("order_items.product_id").
+		Order("SUM(order_items.quantity) DESC, order_items.product_id").
+		Limit(limit).
+		Pluck("order_items.product_id", &ids).Error
+	return ids, err
+}
+
+// SetProductBadges writes the badges column alone and leaves updated_at as
+// it is; the write is still captured, so the search index follows it.
+func (r *BadgeRepository) SetProductBadges(ctx context.Context, productID string, badges []product.Badge) error {
+	return conn(ctx, r.db).Model(&product.Product{}).
+		Where("id = ?", productID).
+		Select("badges").
+		UpdateColumns(&product.Product{Badges: badges}).Error
+}
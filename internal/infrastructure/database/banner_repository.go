@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/domain/banner"
+
+	"gorm.io/gorm"
+)
+
+type BannerRepository struct {
+	db *gorm.DB
+}
+
+func NewBannerRepository(db *gorm.DB) banner.Repository {
+	return &BannerRepository{db: db}
+}
+
+func (r *BannerRepository) Create(ctx context.Context, b *banner.Banner) error {
+	return conn(ctx, r.db).Create(b).Error
+}
+
+func (r *BannerRepository) GetByID(ctx context.Context, id string) (*banner.Banner, error) {
+	var b banner.Banner
+	err := conn(ctx, r.db).Where("id = ?", id).First(&b).Error
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (r *BannerRepository) Update(ctx context.Context, b *banner.Banner) error {
+	return conn(ctx, r.db).Save(b).Error
+}
+
+func (r *BannerRepository) Delete(ctx context.Context, id string) error {
+	return conn(ctx, r.db).Where("id = ?", id).Delete(&banner.Banner{}).Error
+}
+
+func (r *BannerRepository) List(ctx context.Context, limit, offset int) ([]*banner.Banner, error) {
+	var banners []*banner.Banner
+	err := conn(ctx, r.db).Order("position ASC, created_at DESC").Limit(limit).Offset(offset).Find(&banners).Error
+	return banners, err
+}
+
+func (r *BannerRepository) ListActive(ctx context.Context, at time.Time) ([]*banner.Banner, error) {
+	var banners []*banner.Banner
+	err := conn(ctx, r.db).
+		Where("active = ?", true).
+		Where("starts_at IS NULL OR starts_at <= ?", at).
+		Where("ends_at IS NULL OR ends_at > ?", at).
+		Order("position ASC").
+		Find(&banners).Error
+	return banners, err
+}
@@ -0,0 +1,180 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"online-shop/internal/domain/campaign"
+	"online-shop/internal/domain/user"
+	"online-shop/pkg/i18n"
+
+	"gorm.io/gorm"
+)
+
+type CampaignRepository struct {
+	db *gorm.DB
+}
+
+func NewCampaignRepository(db *gorm.DB) campaign.Repository {
+	return &CampaignRepository{db: db}
+}
+
+func NewAudienceRepository(db *gorm.DB) campaign.AudienceRepository {
+	return &CampaignRepository{db: db}
+}
+
+func (r *CampaignRepository) Create(ctx context.Context, c *campaign.Campaign) error {
+	return conn(ctx, r.db).Create(c).Error
+}
+
+func (r *CampaignRepository) Get(ctx context.Context, id string) (*campaign.Campaign, error) {
+	var c campaign.Campaign
+	err := conn(ctx, r.db).Where("id = ?", id).First(&c).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, campaign.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *CampaignRepository) Update(ctx context.Context, c *campaign.Campaign) error {
+	return conn(ctx, r.db).Save(c).Error
+}
+
+func (r *CampaignRepository) Progress(ctx context.Context, c *campaign.Campaign) (bool, error) {
+	result := conn(ctx, r.db).Model(&campaign.Campaign{}).
+		Where("id = ? AND status IN ?", c.ID, []campaign.Status{campaign.StatusScheduled, campaign.StatusSending}).
+		Updates(map[string]interface{}{
+			"status":       c.Status,
+			"cursor":       c.Cursor,
+			"recipients":   c.Recipients,
+			"batches":      c.Batches,
+			"started_at":   c.StartedAt,
+			"completed_at": c.CompletedAt,
+			"updated_at":   c.UpdatedAt,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *CampaignRepository) List(ctx context.Context, filter campaign.Filter) ([]*campaign.Campaign, int64, error) {
+	query := conn(ctx, r.db).Model(&campaign.Campaign{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var campaigns []*campaign.Campaign
+	err := query.Order("created_at DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&campaigns).Error
+	return campaigns, total, err
+}
+
+func (r *CampaignRepository) ListDue(ctx context.Context, at time.Time, limit int) ([]*campaign.Campaign, error) {
+	var campaigns []*campaign.Campaign
+	err := conn(ctx, r.db).
+		Where("status = ? OR (status = ? AND send_at <= ?)", campaign.StatusSending, campaign.StatusScheduled, at).
+		Order("send_at ASC").
+		Limit(limit).Find(&campaigns).Error
+	return campaigns, err
+}
+
+func (r *CampaignRepository) RecordEvent(ctx context.Context, e *campaign.Event) error {
+	return conn(ctx, r.db).Create(e).Error
+}
+
+// Stats counts the events of the campaign. The rates are left to the
+// caller, who has the campaign's recipients.
+func (r *CampaignRepository) Stats(ctx context.Context, campaignID string) (*campaign.Stats, error) {
+	stats := &campaign.Stats{CampaignID: campaignID, Links: []campaign.LinkStats{}}
+
+	var counts []struct {
+		Type   string
+		Total  int64
+		Unique int64
+	}
+	err := conn(ctx, r.db).Model(&campaign.Event{}).
+		Select("type, COUNT(*) AS total, COUNT(DISTINCT recipient) AS \"unique\"").
+		Where("campaign_id = ?", campaignID).
+		Group("type").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range counts {
+		switch c.Type {
+		case campaign.EventOpen:
+			stats.Opens, stats.UniqueOpens = c.Total, c.Unique
+		case campaign.EventClick:
+			stats.Clicks, stats.UniqueClicks = c.Total, c.Unique
+		}
+	}
+
+	err = conn(ctx, r.db).Model(&campaign.Event{}).
+		Select("url, COUNT(*) AS clicks").
+		Where("campaign_id = ? AND type = ?", campaignID, campaign.EventClick).
+		Group("url").
+		Order("clicks DESC").
+		Scan(&stats.Links).Error
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func (r *CampaignRepository) Audience(ctx context.Context, s campaign.Segment, at time.Time, afterID string, limit int) ([]*user.User, error) {
+	var users []*user.User
+	err := r.audience(ctx, s, at).
+		Where("id > ?", afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&users).Error
+	return users, err
+}
+
+func (r *CampaignRepository) CountAudience(ctx context.Context, s campaign.Segment, at time.Time) (int64, error) {
+	var count int64
+	err := r.audience(ctx, s, at).Count(&count).Error
+	return count, err
+}
+
+// audience selects the users campaign.Segment.Matches does
+func (r *CampaignRepository) audience(ctx context.Context, s campaign.Segment, at time.Time) *gorm.DB {
+	query := conn(ctx, r.db).Model(&user.User{}).
+		Where("status = ? AND role = ? AND marketing_email_granted", user.StatusActive, user.RoleCustomer)
+
+	if len(s.Locales) > 0 {
+		locales := make([]string, len(s.Locales))
+		for i, locale := range s.Locales {
+			locales[i] = i18n.Negotiate("", locale)
+		}
+		if s.DefaultLocale() {
+			query = query.Where("(locale IN ? OR locale = '' OR locale IS NULL)", locales)
+		} else {
+			query = query.Where("locale IN ?", locales)
+		}
+	}
+	if len(s.Genders) > 0 {
+		query = query.Where("gender IN ?", s.Genders)
+	}
+	if s.MinAge > 0 {
+		query = query.Where("birthday <= ?", s.BornBy(at))
+	}
+	if s.MaxAge > 0 {
+		query = query.Where("birthday > ?", s.BornAfter(at))
+	}
+	if s.BirthdayMonth > 0 {
+		query = query.Where("EXTRACT(MONTH FROM birthday) = ?", s.BirthdayMonth)
+	}
+	if s.RegisteredAfter != nil {
+		query = query.Where("created_at >= ?", *s.RegisteredAfter)
+	}
+	if s.RegisteredBefore != nil {
+		query = query.Where("created_at < ?", *s.RegisteredBefore)
+	}
+	return query
+}
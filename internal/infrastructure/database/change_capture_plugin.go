@@ -0,0 +1,145 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"online-shop/internal/domain/searchsync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const captureIDsKey = "capture:ids"
+
+// SearchSyncedTables are the tables the search index and caches follow
+var SearchSyncedTables = []string{searchsync.EntityProduct, searchsync.EntityCategory}
+
+// ChangeCapturePlugin is a GORM plugin writing a searchsync.Change for
+// every row of its tables that is created, updated or deleted, on the
+// statement's connection so the change commits or rolls back with the
+// write. Unlike the audit plugin it also sees writes by condition, such as
+// stock updates, by looking up the rows they match first. A change that
+// cannot be recorded fails the write, so none goes unnoticed. The captured
+// tables must have string primary keys.
+type ChangeCapturePlugin struct {
+	tables map[string]bool
+}
+
+func NewChangeCapturePlugin(tables ...string) *ChangeCapturePlugin {
+	p := &ChangeCapturePlugin{tables: make(map[string]bool, len(tables))}
+	for _, table := range tables {
+		p.tables[table] = true
+	}
+	return p
+}
+
+func (p *ChangeCapturePlugin) Name() string {
+	return "change_capture"
+}
+
+func (p *ChangeCapturePlugin) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Create().After("gorm:create").Register("capture:after_create", p.recordCreate); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register("capture:before_update", p.lookup); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After("gorm:update").Register("capture:after_update", p.record); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().Before("gorm:delete").Register("capture:before_delete", p.lookup); err != nil {
+		return err
+	}
+	return db.Callback().Delete().After("gorm:delete").Register("capture:after_delete", p.record)
+}
+
+func (p *ChangeCapturePlugin) primaryField(db *gorm.DB) (*schema.Field, bool) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil || !p.tables[stmt.Table] {
+		return nil, false
+	}
+	field := stmt.Schema.PrioritizedPrimaryField
+	return field, field != nil
+}
+
+func (p *ChangeCapturePlugin) recordCreate(db *gorm.DB) {
+	field, ok := p.primaryField(db)
+	if !ok || db.Statement.RowsAffected == 0 {
+		return
+	}
+	p.write(db, p.modelIDs(db, field))
+}
+
+// lookup keeps the keys of the rows an update or delete is about to write
+func (p *ChangeCapturePlugin) lookup(db *gorm.DB) {
+	field, ok := p.primaryField(db)
+	if !ok {
+		return
+	}
+
+	ids := p.modelIDs(db, field)
+	if len(ids) == 0 {
+		where, ok := db.Statement.Clauses["WHERE"]
+		if !ok {
+			return
+		}
+		err := db.Session(&gorm.Session{NewDB: true}).
+			Table(db.Statement.Table).
+			Clauses(where.Expression).
+			Pluck(field.DBName, &ids).Error
+		if err != nil {
+			db.AddError(fmt.Errorf("failed to look up %s rows to capture: %w", db.Statement.Table, err))
+			return
+		}
+	}
+	db.InstanceSet(captureIDsKey, ids)
+}
+
+func (p *ChangeCapturePlugin) record(db *gorm.DB) {
+	if db.Error != nil || db.Statement.RowsAffected == 0 {
+		return
+	}
+	ids, ok := db.InstanceGet(captureIDsKey)
+	if !ok {
+		return
+	}
+	p.write(db, ids.([]string))
+}
+
+// modelIDs returns the primary keys of the rows in the statement's model
+func (p *ChangeCapturePlugin) modelIDs(db *gorm.DB, field *schema.Field) []string {
+	stmt := db.Statement
+	var ids []string
+	add := func(value reflect.Value) {
+		if id, zero := field.ValueOf(stmt.Context, value); !zero {
+			ids = append(ids, fmt.Sprint(id))
+		}
+	}
+
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Struct:
+		add(stmt.ReflectValue)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			add(reflect.Indirect(stmt.ReflectValue.Index(i)))
+		}
+	}
+	return ids
+}
+
+func (p *ChangeCapturePlugin) write(db *gorm.DB, ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+
+	now := time.Now()
+	changes := make([]*searchsync.Change, 0, len(ids))
+	for _, id := range ids {
+		changes = append(changes, searchsync.NewChange(db.Statement.Table, id, now))
+	}
+	if err := db.Session(&gorm.Session{NewDB: true}).Create(changes).Error; err != nil {
+		db.AddError(fmt.Errorf("failed to capture %s change: %w", db.Statement.Table, err))
+	}
+}
@@ -0,0 +1,164 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"online-shop/internal/domain/cms"
+
+	"gorm.io/gorm"
+)
+
+type CMSBlockRepository struct {
+	db *gorm.DB
+}
+
+func NewCMSBlockRepository(db *gorm.DB) cms.BlockRepository {
+	return &CMSBlockRepository{db: db}
+}
+
+func (r *CMSBlockRepository) Create(ctx context.Context, b *cms.Block) error {
+	return conn(ctx, r.db).Create(b).Error
+}
+
+func (r *CMSBlockRepository) GetByID(ctx context.Context, id string) (*cms.Block, error) {
+	var b cms.Block
+	err := conn(ctx, r.db).Where("id = ?", id).First(&b).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, cms.ErrBlockNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (r *CMSBlockRepository) Update(ctx context.Context, b *cms.Block) error {
+	return conn(ctx, r.db).Save(b).Error
+}
+
+func (r *CMSBlockRepository) Delete(ctx context.Context, id string) error {
+	result := conn(ctx, r.db).Where("id = ?", id).Delete(&cms.Block{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return cms.ErrBlockNotFound
+	}
+	return nil
+}
+
+func (r *CMSBlockRepository) List(ctx context.Context, slot string, limit, offset int) ([]*cms.Block, error) {
+	query := conn(ctx, r.db).Order("slot, position ASC, created_at DESC")
+	if slot != "" {
+		query = query.Where("slot = ?", slot)
+	}
+	var blocks []*cms.Block
+	err := query.Limit(limit).Offset(offset).Find(&blocks).Error
+	return blocks, err
+}
+
+func (r *CMSBlockRepository) ListActive(ctx context.Context, slot string, at time.Time) ([]*cms.Block, error) {
+	var blocks []*cms.Block
+	err := conn(ctx, r.db).
+		Where("slot = ? AND active = ?", slot, true).
+		Where("ends_at IS NULL OR ends_at > ?", at).
+		Order("position ASC").
+		Find(&blocks).Error
+	return blocks, err
+}
+
+type CMSPageRepository struct {
+	db *gorm.DB
+}
+
+func NewCMSPageRepository(db *gorm.DB) cms.PageRepository {
+	return &CMSPageRepository{db: db}
+}
+
+func (r *CMSPageRepository) Create(ctx context.Context, p *cms.Page) error {
+	return conn(ctx, r.db).Create(p).Error
+}
+
+func (r *CMSPageRepository) GetByID(ctx context.Context, id string) (*cms.Page, error) {
+	return r.first(ctx, "id = ?", id)
+}
+
+func (r *CMSPageRepository) GetBySlug(ctx context.Context, slug string) (*cms.Page, error) {
+	return r.first(ctx, "slug = ?", slug)
+}
+
+func (r *CMSPageRepository) first(ctx context.Context, query string, arg interface{}) (*cms.Page, error) {
+	var p cms.Page
+	err := conn(ctx, r.db).Where(query, arg).First(&p).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, cms.ErrPageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *CMSPageRepository) Update(ctx context.Context, p *cms.Page) error {
+	return conn(ctx, r.db).Save(p).Error
+}
+
+func (r *CMSPageRepository) Delete(ctx context.Context, id string) error {
+	result := conn(ctx, r.db).Where("id = ?", id).Delete(&cms.Page{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return cms.ErrPageNotFound
+	}
+	return nil
+}
+
+func (r *CMSPageRepository) List(ctx context.Context, limit, offset int) ([]*cms.Page, error) {
+	var pages []*cms.Page
+	err := conn(ctx, r.db).Order("slug").Limit(limit).Offset(offset).Find(&pages).Error
+	return pages, err
+}
+
+type CMSAssetRepository struct {
+	db *gorm.DB
+}
+
+func NewCMSAssetRepository(db *gorm.DB) cms.AssetRepository {
+	return &CMSAssetRepository{db: db}
+}
+
+func (r *CMSAssetRepository) Create(ctx context.Context, a *cms.Asset) error {
+	return conn(ctx, r.db).Create(a).Error
+}
+
+func (r *CMSAssetRepository) Get(ctx context.Context, id string) (*cms.Asset, error) {
+	var a cms.Asset
+	err := conn(ctx, r.db).Where("id = ?", id).First(&a).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, cms.ErrAssetNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *CMSAssetRepository) List(ctx context.Context, limit, offset int) ([]*cms.Asset, error) {
+	var assets []*cms.Asset
+	err := conn(ctx, r.db).Order("created_at DESC").Limit(limit).Offset(offset).Find(&assets).Error
+	return assets, err
+}
+
+func (r *CMSAssetRepository) Delete(ctx context.Context, id string) error {
+	result := conn(ctx, r.db).Where("id = ?", id).Delete(&cms.Asset{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return cms.ErrAssetNotFound
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"online-shop/internal/domain/cod"
+	"online-shop/internal/domain/order"
+
+	"gorm.io/gorm"
+)
+
+type CODRepository struct {
+	db *gorm.DB
+}
+
+func NewCODRepository(db *gorm.DB) cod.Repository {
+	return &CODRepository{db: db}
+}
+
+func (r *CODRepository) Create(ctx context.Context, c *cod.Collection) error {
+	return conn(ctx, r.db).Create(c).Error
+}
+
+func (r *CODRepository) GetByOrderID(ctx context.Context, orderID string) (*cod.Collection, error) {
+	var c cod.Collection
+	err := conn(ctx, r.db).Where("order_id = ?", orderID).First(&c).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, cod.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *CODRepository) Update(ctx context.Context, c *cod.Collection) error {
+	return conn(ctx, r.db).Save(c).Error
+}
+
+func (r *CODRepository) ListByIDs(ctx context.Context, ids []string) ([]*cod.Collection, error) {
+	var collections []*cod.Collection
+	if len(ids) == 0 {
+		return collections, nil
+	}
+	err := conn(ctx, r.db).Where("id IN ?", ids).Order("collected_at ASC").Find(&collections).Error
+	return collections, err
+}
+
+func (r *CODRepository) List(ctx context.Context, filter cod.CollectionFilter, limit, offset int) ([]*cod.Collection, int64, error) {
+	query := conn(ctx, r.db).Model(&cod.Collection{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Carrier != "" {
+		query = query.Where("LOWER(carrier) = LOWER(?)", filter.Carrier)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var collections []*cod.Collection
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&collections).Error
+	return collections, total, err
+}
+
+func (r *CODRepository) Exposure(ctx context.Context, userID string) (cod.Exposure, error) {
+	var row struct {
+		Orders int
+		Amount float64
+	}
+	err := conn(ctx, r.db).Model(&cod.Collection{}).
+		Select("COUNT(*) AS orders, COALESCE(SUM(cod_collections.amount), 0) AS amount").
+		Joins("JOIN orders ON orders.id = cod_collections.order_id").
+		Where("cod_collections.user_id = ? AND cod_collections.status = ?", userID, cod.StatusAwaitingDelivery).
+		Where("orders.status NOT IN ?", []order.Status{order.StatusCancelled, order.StatusRefunded}).
+		Scan(&row).Error
+	return cod.Exposure{Orders: row.Orders, Amount: row.Amount}, err
+}
+
+func (r *CODRepository) Balances(ctx context.Context) ([]cod.CourierBalance, error) {
+	var balances []cod.CourierBalance
+	err := conn(ctx, r.db).Model(&cod.Collection{}).
+		Select("carrier, COUNT(*) AS collections, SUM(collected_amount) AS amount, MIN(collected_at) AS oldest_at").
+		Where("status = ?", cod.StatusCollected).
+		Group("carrier").
+		Order("amount DESC").
+		Scan(&balances).Error
+	return balances, err
+}
+
+func (r *CODRepository) CreateRemittance(ctx context.Context, rem *cod.Remittance, collections []*cod.Collection) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Collections").Create(rem).Error; err != nil {
+			return err
+		}
+		for _, c := range collections {
+			res := tx.Model(&cod.Collection{}).
+				Where("id = ? AND status = ?", c.ID, cod.StatusCollected).
+				Updates(map[string]interface{}{
+					"status":        c.Status,
+					"remittance_id": c.RemittanceID,
+					"settled_at":    c.SettledAt,
+					"updated_at":    c.UpdatedAt,
+				})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return fmt.Errorf("%w: collection %s was settled in the meantime", cod.ErrNotRemittable, c.ID)
+			}
+		}
+		return nil
+	})
+}
+
+func (r *CODRepository) GetRemittance(ctx context.Context, id string) (*cod.Remittance, error) {
+	var rem cod.Remittance
+	err := conn(ctx, r.db).Preload("Collections").Where("id = ?", id).First(&rem).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, cod.ErrRemittanceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rem, nil
+}
+
+func (r *CODRepository) ListRemittances(ctx context.Context, carrier string, limit, offset int) ([]*cod.Remittance, error) {
+	query := conn(ctx, r.db).Order("created_at DESC")
+	if carrier != "" {
+		query = query.Where("LOWER(carrier) = LOWER(?)", carrier)
+	}
+
+	var remittances []*cod.Remittance
+	err := query.Limit(limit).Offset(offset).Find(&remittances).Error
+	return remittances, err
+}
+
+type CODLimitRepository struct {
+	db *gorm.DB
+}
+
+func NewCODLimitRepository(db *gorm.DB) cod.LimitRepository {
+	return &CODLimitRepository{db: db}
+}
+
+func (r *CODLimitRepository) Get(ctx context.Context, userID string) (*cod.CustomerLimit, error) {
+	var l cod.CustomerLimit
+	err := conn(ctx, r.db).Where("user_id = ?", userID).First(&l).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (r *CODLimitRepository) Save(ctx context.Context, l *cod.CustomerLimit) error {
+	return conn(ctx, r.db).Save(l).Error
+}
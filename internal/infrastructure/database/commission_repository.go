@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+
+	"online-shop/internal/domain/commission"
+
+	"gorm.io/gorm"
+)
+
+type CommissionRepository struct {
+	db *gorm.DB
+}
+
+func NewCommissionRepository(db *gorm.DB) commission.Repository {
+	return &CommissionRepository{db: db}
+}
+
+func (r *CommissionRepository) Create(ctx context.Context, rule *commission.Rule) error {
+	return conn(ctx, r.db).Create(rule).Error
+}
+
+func (r *CommissionRepository) GetByID(ctx context.Context, id string) (*commission.Rule, error) {
+	var rule commission.Rule
+	err := conn(ctx, r.db).Where("id = ?", id).First(&rule).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *CommissionRepository) Update(ctx context.Context, rule *commission.Rule) error {
+	return conn(ctx, r.db).Save(rule).Error
+}
+
+func (r *CommissionRepository) Delete(ctx context.Context, id string) error {
+	return conn(ctx, r.db).Where("id = ?", id).Delete(&commission.Rule{}).Error
+}
+
+func (r *CommissionRepository) List(ctx context.Context, limit, offset int) ([]*commission.Rule, error) {
+	var rules []*commission.Rule
+	err := conn(ctx, r.db).Order("created_at DESC").Limit(limit).Offset(offset).Find(&rules).Error
+	return rules, err
+}
+
+func (r *CommissionRepository) ListActive(ctx context.Context) ([]*commission.Rule, error) {
+	var rules []*commission.Rule
+	err := conn(ctx, r.db).Where("active = ?", true).Order("updated_at DESC").Find(&rules).Error
+	return rules, err
+}
@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/domain/dashboard"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/payment"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/user"
+
+	"gorm.io/gorm"
+)
+
+type DashboardRepository struct {
+	db *gorm.DB
+}
+
+func NewDashboardRepository(db *gorm.DB) dashboard.Repository {
+	return &DashboardRepository{db: db}
+}
+
+func (r *DashboardRepository) Compute(ctx context.Context, since time.Time, lowStockThreshold int) (*dashboard.Stats, error) {
+	stats := &dashboard.Stats{
+		LowStockThreshold: lowStockThreshold,
+		Since:             since,
+		ComputedAt:        time.Now(),
+	}
+
+	var orders struct {
+		Orders  int64
+		Revenue float64
+	}
+	err := conn(ctx, r.db).Table("orders").
+		Select("COUNT(*) AS orders, COALESCE(SUM(total_amount), 0) AS revenue").
+		Where("created_at >= ? AND status NOT IN ?", since, []order.Status{order.StatusCancelled, order.StatusRefunded}).
+		Scan(&orders).Error
+	if err != nil {
+		return nil, err
+	}
+	stats.TodayOrders = orders.Orders
+	stats.TodayRevenue = orders.Revenue
+
+	if err := conn(ctx, r.db).Model(&order.Shipment{}).
+		Where("status IN ?", []order.ShipmentStatus{order.ShipmentStatusPending, order.ShipmentStatusPicking, order.ShipmentStatusPacked}).
+		Count(&stats.PendingShipments).Error; err != nil {
+		return nil, err
+	}
+
+	if err := conn(ctx, r.db).Model(&payment.Payment{}).
+		Where("status = ? AND updated_at >= ?", payment.StatusFailed, since).
+		Count(&stats.FailedPayments).Error; err != nil {
+		return nil, err
+	}
+
+	if err := conn(ctx, r.db).Model(&user.User{}).
+		Where("created_at >= ?", since).
+		Count(&stats.NewUsers).Error; err != nil {
+		return nil, err
+	}
+
+	if err := conn(ctx, r.db).Model(&product.Product{}).
+		Where("status = ? AND stock <= ?", product.StatusActive, lowStockThreshold).
+		Count(&stats.LowStockProducts).Error; err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"online-shop/internal/domain/draftorder"
+
+	"gorm.io/gorm"
+)
+
+type DraftOrderRepository struct {
+	db *gorm.DB
+}
+
+func NewDraftOrderRepository(db *gorm.DB) draftorder.Repository {
+	return &DraftOrderRepository{db: db}
+}
+
+func (r *DraftOrderRepository) Create(ctx context.Context, d *draftorder.Draft) error {
+	return conn(ctx, r.db).Create(d).Error
+}
+
+func (r *DraftOrderRepository) GetByID(ctx context.Context, id string) (*draftorder.Draft, error) {
+	return r.first(ctx, "id = ?", id)
+}
+
+func (r *DraftOrderRepository) GetByToken(ctx context.Context, token string) (*draftorder.Draft, error) {
+	return r.first(ctx, "token = ?", token)
+}
+
+func (r *DraftOrderRepository) first(ctx context.Context, query string, arg string) (*draftorder.Draft, error) {
+	var d draftorder.Draft
+	err := conn(ctx, r.db).Where(query, arg).First(&d).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, draftorder.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *DraftOrderRepository) Update(ctx context.Context, d *draftorder.Draft) error {
+	return conn(ctx, r.db).Save(d).Error
+}
+
+// Claim changes the status only while it is still open or sent, in one
+// statement, so concurrent deliveries cannot both claim the draft.
+func (r *DraftOrderRepository) Claim(ctx context.Context, id string, now time.Time) (bool, error) {
+	result := conn(ctx, r.db).Model(&draftorder.Draft{}).
+		Where("id = ? AND status IN ?", id, []draftorder.Status{draftorder.StatusOpen, draftorder.StatusSent}).
+		Updates(map[string]interface{}{"status": draftorder.StatusConverting, "updated_at": now})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *DraftOrderRepository) List(ctx context.Context, filter draftorder.Filter, limit, offset int) ([]*draftorder.Draft, error) {
+	query := conn(ctx, r.db)
+	if filter.MerchantID != "" {
+		query = query.Where("merchant_id = ?", filter.MerchantID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var drafts []*draftorder.Draft
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&drafts).Error
+	return drafts, err
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"online-shop/internal/domain/emaildelivery"
+
+	"gorm.io/gorm"
+)
+
+type EmailDeadLetterRepository struct {
+	db *gorm.DB
+}
+
+func NewEmailDeadLetterRepository(db *gorm.DB) emaildelivery.DeadLetterRepository {
+	return &EmailDeadLetterRepository{db: db}
+}
+
+func (r *EmailDeadLetterRepository) Create(ctx context.Context, d *emaildelivery.DeadLetter) error {
+	return conn(ctx, r.db).Create(d).Error
+}
+
+func (r *EmailDeadLetterRepository) Get(ctx context.Context, id string) (*emaildelivery.DeadLetter, error) {
+	var d emaildelivery.DeadLetter
+	err := conn(ctx, r.db).Where("id = ?", id).First(&d).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, emaildelivery.ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *EmailDeadLetterRepository) List(ctx context.Context, pending bool, limit, offset int) ([]*emaildelivery.DeadLetter, error) {
+	var deadLetters []*emaildelivery.DeadLetter
+	query := conn(ctx, r.db).Order("created_at DESC").Limit(limit).Offset(offset)
+	if pending {
+		query = query.Where("resent_at IS NULL")
+	}
+	err := query.Find(&deadLetters).Error
+	return deadLetters, err
+}
+
+func (r *EmailDeadLetterRepository) MarkResent(ctx context.Context, id string, at time.Time) error {
+	result := conn(ctx, r.db).Model(&emaildelivery.DeadLetter{}).Where("id = ?", id).Update("resent_at", at)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return emaildelivery.ErrDeadLetterNotFound
+	}
+	return nil
+}
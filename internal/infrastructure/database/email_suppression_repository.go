@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"online-shop/internal/domain/emaildelivery"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type EmailSuppressionRepository struct {
+	db *gorm.DB
+}
+
+func NewEmailSuppressionRepository(db *gorm.DB) emaildelivery.SuppressionRepository {
+	return &EmailSuppressionRepository{db: db}
+}
+
+// Add upserts, so a complaint about an address that bounced before
+// replaces the bounce
+func (r *EmailSuppressionRepository) Add(ctx context.Context, s *emaildelivery.Suppression) error {
+	return conn(ctx, r.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "email"}},
+		DoUpdates: clause.AssignmentColumns([]string{"reason", "provider", "detail", "created_at"}),
+	}).Create(s).Error
+}
+
+func (r *EmailSuppressionRepository) Get(ctx context.Context, email string) (*emaildelivery.Suppression, error) {
+	var s emaildelivery.Suppression
+	err := conn(ctx, r.db).Where("email = ?", email).First(&s).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, emaildelivery.ErrSuppressionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *EmailSuppressionRepository) List(ctx context.Context, limit, offset int) ([]*emaildelivery.Suppression, error) {
+	var suppressions []*emaildelivery.Suppression
+	err := conn(ctx, r.db).Order("created_at DESC").Limit(limit).Offset(offset).Find(&suppressions).Error
+	return suppressions, err
+}
+
+func (r *EmailSuppressionRepository) Remove(ctx context.Context, email string) error {
+	result := conn(ctx, r.db).Where("email = ?", email).Delete(&emaildelivery.Suppression{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return emaildelivery.ErrSuppressionNotFound
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"online-shop/internal/domain/emailtemplate"
+
+	"gorm.io/gorm"
+)
+
+type EmailTemplateRepository struct {
+	db *gorm.DB
+}
+
+func NewEmailTemplateRepository(db *gorm.DB) emailtemplate.Repository {
+	return &EmailTemplateRepository{db: db}
+}
+
+// Create deactivates the current version in the same transaction, so a
+// template never has two active versions
+func (r *EmailTemplateRepository) Create(ctx context.Context, t *emailtemplate.Template) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := deactivateEmailTemplate(tx, t.Name, t.Locale); err != nil {
+			return err
+		}
+		t.Active = true
+		return tx.Create(t).Error
+	})
+}
+
+func (r *EmailTemplateRepository) Active(ctx context.Context, name, locale string) (*emailtemplate.Template, error) {
+	var t emailtemplate.Template
+	err := conn(ctx, r.db).Where("name = ? AND locale = ? AND active = ?", name, locale, true).First(&t).Error
+	return emailTemplateResult(&t, err)
+}
+
+func (r *EmailTemplateRepository) GetVersion(ctx context.Context, name, locale string, version int) (*emailtemplate.Template, error) {
+	var t emailtemplate.Template
+	err := conn(ctx, r.db).Where("name = ? AND locale = ? AND version = ?", name, locale, version).First(&t).Error
+	return emailTemplateResult(&t, err)
+}
+
+func (r *EmailTemplateRepository) Versions(ctx context.Context, name, locale string) ([]*emailtemplate.Template, error) {
+	var versions []*emailtemplate.Template
+	err := conn(ctx, r.db).Where("name = ? AND locale = ?", name, locale).Order("version DESC").Find(&versions).Error
+	return versions, err
+}
+
+func (r *EmailTemplateRepository) Activate(ctx context.Context, name, locale string, version int) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := deactivateEmailTemplate(tx, name, locale); err != nil {
+			return err
+		}
+		result := tx.Model(&emailtemplate.Template{}).
+			Where("name = ? AND locale = ? AND version = ?", name, locale, version).
+			Update("active", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return emailtemplate.ErrNotFound
+		}
+		return nil
+	})
+}
+
+func (r *EmailTemplateRepository) List(ctx context.Context, limit, offset int) ([]*emailtemplate.Template, error) {
+	var templates []*emailtemplate.Template
+	err := conn(ctx, r.db).Where("active = ?", true).
+		Order("name ASC, locale ASC").
+		Limit(limit).Offset(offset).
+		Find(&templates).Error
+	return templates, err
+}
+
+func (r *EmailTemplateRepository) Delete(ctx context.Context, name, locale string) error {
+	result := conn(ctx, r.db).Where("name = ? AND locale = ?", name, locale).Delete(&emailtemplate.Template{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return emailtemplate.ErrNotFound
+	}
+	return nil
+}
+
+func deactivateEmailTemplate(tx *gorm.DB, name, locale string) error {
+	return tx.Model(&emailtemplate.Template{}).
+		Where("name = ? AND locale = ? AND active = ?", name, locale, true).
+		Update("active", false).Error
+}
+
+func emailTemplateResult(t *emailtemplate.Template, err error) (*emailtemplate.Template, error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, emailtemplate.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+
+	"online-shop/internal/domain/experiment"
+
+	"gorm.io/gorm"
+)
+
+type ExperimentRepository struct {
+	db *gorm.DB
+}
+
+func NewExperimentRepository(db *gorm.DB) experiment.Repository {
+	return &ExperimentRepository{db: db}
+}
+
+func (r *ExperimentRepository) Create(ctx context.Context, e *experiment.Experiment) error {
+	return conn(ctx, r.db).Create(e).Error
+}
+
+func (r *ExperimentRepository) GetByID(ctx context.Context, id string) (*experiment.Experiment, error) {
+	var e experiment.Experiment
+	err := conn(ctx, r.db).Where("id = ?", id).First(&e).Error
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *ExperimentRepository) GetByKey(ctx context.Context, key string) (*experiment.Experiment, error) {
+	var e experiment.Experiment
+	err := conn(ctx, r.db).Where("key = ?", key).First(&e).Error
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *ExperimentRepository) Update(ctx context.Context, e *experiment.Experiment) error {
+	return conn(ctx, r.db).Save(e).Error
+}
+
+func (r *ExperimentRepository) List(ctx context.Context, status string, limit, offset int) ([]*experiment.Experiment, error) {
+	var experiments []*experiment.Experiment
+	query := conn(ctx, r.db).Order("created_at DESC").Limit(limit).Offset(offset)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Find(&experiments).Error
+	return experiments, err
+}
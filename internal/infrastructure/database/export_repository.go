@@ -0,0 +1,43 @@
+package database
+
+import (
+	"context"
+
+	"online-shop/internal/domain/export"
+
+	"gorm.io/gorm"
+)
+
+type ExportRepository struct {
+	db *gorm.DB
+}
+
+func NewExportRepository(db *gorm.DB) export.Repository {
+	return &ExportRepository{db: db}
+}
+
+func (r *ExportRepository) Create(ctx context.Context, e *export.Export) error {
+	return conn(ctx, r.db).Create(e).Error
+}
+
+func (r *ExportRepository) GetByID(ctx context.Context, id string) (*export.Export, error) {
+	var e export.Export
+	err := conn(ctx, r.db).Where("id = ?", id).First(&e).Error
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *ExportRepository) Update(ctx context.Context, e *export.Export) error {
+	return conn(ctx, r.db).Save(e).Error
+}
+
+func (r *ExportRepository) ListByRequester(ctx context.Context, userID string, limit, offset int) ([]*export.Export, error) {
+	var exports []*export.Export
+	err := conn(ctx, r.db).Where("requested_by = ?", userID).
+		Order("created_at DESC").
+		Limit(limit).Offset(offset).
+		Find(&exports).Error
+	return exports, err
+}
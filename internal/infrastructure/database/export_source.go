@@ -0,0 +1,358 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"online-shop/internal/domain/export"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/payment"
+
+	"gorm.io/gorm"
+)
+
+// ExportSource reads report rows straight from the primary tables
+type ExportSource struct {
+	db         *gorm.DB
+	accounting export.Accounting
+}
+
+// NewExportSource lays accounting exports out as accounting sets.
+func NewExportSource(db *gorm.DB, accounting export.Accounting) export.Source {
+	return &ExportSource{db: db, accounting: accounting}
+}
+
+func (s *ExportSource) Load(ctx context.Context, e *export.Export) (*export.Table, error) {
+	switch e.Type {
+	case export.TypeOrders:
+		return s.orders(ctx, e)
+	case export.TypeProducts:
+		return s.products(ctx, e)
+	case export.TypeCustomers:
+		return s.customers(ctx, e)
+	case export.TypeReorderSuggestions:
+		return s.reorderSuggestions(ctx)
+	case export.TypeAccounting:
+		return s.accountingEntries(ctx, e)
+	default:
+		return nil, fmt.Errorf("unsupported export type: %s", e.Type)
+	}
+}
+
+func (s *ExportSource) orders(ctx context.Context, e *export.Export) (*export.Table, error) {
+	var rows []struct {
+		ID          string
+		UserID      string
+		Status      string
+		Items       int
+		TotalAmount float64
+		CreatedAt   time.Time
+	}
+	err := withRange(conn(ctx, s.db), "orders.created_at", e).
+		Table("orders").
+		Select("orders.id, orders.user_id, orders.status, COALESCE(SUM(order_items.quantity), 0) AS items, orders.total_amount, orders.created_at").
+		Joins("LEFT JOIN order_items ON order_items.order_id = orders.id").
+		Group("orders.id").
+		Order("orders.created_at").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	table := &export.Table{Header: []string{"order_id", "user_id", "status", "items", "total_amount", "created_at"}}
+	for _, row := range rows {
+		table.Rows = append(table.Rows, []string{
+			row.ID,
+			row.UserID,
+			row.Status,
+			strconv.Itoa(row.Items),
+			formatAmount(row.TotalAmount),
+			row.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	return table, nil
+}
+
+func (s *ExportSource) products(ctx context.Context, e *export.Export) (*export.Table, error) {
+	var rows []struct {
+		ID         string
+		Name       string
+		CategoryID string
+		MerchantID string
+		Price      float64
+		Stock      int
+		Status     string
+		CreatedAt  time.Time
+	}
+	err := withRange(conn(ctx, s.db), "created_at", e).
+		Table("products").
+		Select("id, name, category_id, merchant_id, price, stock, status, created_at").
+		Order("created_at").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	table := &export.Table{Header: []string{"product_id", "name", "category_id", "merchant_id", "price", "stock", "status", "created_at"}}
+	for _, row := range rows {
+		table.Rows = append(table.Rows, []string{
+			row.ID,
+			row.Name,
+			row.CategoryID,
+			row.MerchantID,
+			formatAmount(row.Price),
+			strconv.Itoa(row.Stock),
+			row.Status,
+			row.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	return table, nil
+}
+
+func (s *ExportSource) customers(ctx context.Context, e *export.Export) (*export.Table, error) {
+	var rows []struct {
+		ID         string
+		Email      string
+		FirstName  string
+		LastName   string
+		Status     string
+		Orders     int
+		TotalSpent float64
+		CreatedAt  time.Time
+	}
+	err := withRange(conn(ctx, s.db), "users.created_at", e).
+		Table("users").
+		Select("users.id, users.email, users.first_name, users.last_name, users.status, COUNT(orders.id) AS orders, COALESCE(SUM(orders.total_amount), 0) AS total_spent, users.created_at").
+		Joins("LEFT JOIN orders ON orders.user_id = users.id AND orders.status NOT IN ?", []order.Status{order.StatusCancelled, order.StatusRefunded}).
+		Group("users.id").
+		Order("users.created_at").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	table := &export.Table{Header: []string{"user_id", "email", "first_name", "last_name", "status", "orders", "total_spent", "created_at"}}
+	for _, row := range rows {
+		table.Rows = append(table.Rows, []string{
+			row.ID,
+			row.Email,
+			row.FirstName,
+			row.LastName,
+			row.Status,
+			strconv.Itoa(row.Orders),
+			formatAmount(row.TotalSpent),
+			row.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	return table, nil
+}
+
+// reorderSuggestions are those of the last forecast run, so the export's
+// range does not apply to them
+func (s *ExportSource) reorderSuggestions(ctx context.Context) (*export.Table, error) {
+	var rows []struct {
+		ProductID       string
+		Name            string
+		WarehouseID     string
+		WarehouseCode   string
+		OnHand          int
+		DailyVelocity   float64
+		DaysOfCover     *float64
+		StockoutAt      *time.Time
+		ReorderPoint    int
+		ReorderQuantity int
+		Status          string
+		ComputedAt      time.Time
+	}
+	err := conn(ctx, s.db).
+		Table("reorder_suggestions").
+		Select("reorder_suggestions.*, products.name, warehouses.code AS warehouse_code").
+		Joins("LEFT JOIN products ON products.id = reorder_suggestions.product_id").
+		Joins("LEFT JOIN warehouses ON warehouses.id = reorder_suggestions.warehouse_id").
+		Order("reorder_suggestions.stockout_at ASC NULLS LAST, reorder_suggestions.product_id, reorder_suggestions.warehouse_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	table := &export.Table{Header: []string{"product_id", "name", "warehouse_id", "warehouse_code", "on_hand", "daily_velocity", "days_of_cover", "stockout_at", "reorder_point", "reorder_quantity", "status", "computed_at"}}
+	for _, row := range rows {
+		cover, stockout := "", ""
+		if row.DaysOfCover != nil {
+			cover = strconv.FormatFloat(*row.DaysOfCover, 'f', 1, 64)
+		}
+		if row.StockoutAt != nil {
+			stockout = row.StockoutAt.Format(time.RFC3339)
+		}
+		table.Rows = append(table.Rows, []string{
+			row.ProductID,
+			row.Name,
+			row.WarehouseID,
+			row.WarehouseCode,
+			strconv.Itoa(row.OnHand),
+			strconv.FormatFloat(row.DailyVelocity, 'f', 2, 64),
+			cover,
+			stockout,
+			strconv.Itoa(row.ReorderPoint),
+			strconv.Itoa(row.ReorderQuantity),
+			row.Status,
+			row.ComputedAt.Format(time.RFC3339),
+		})
+	}
+	return table, nil
+}
+
+// accountingEntries are the sales placed, the payments received and the
+// refunds paid within the range. Orders cancelled before anything was paid
+// were never sales; those cancelled after are booked as sales and then
+// refunds.
+func (s *ExportSource) accountingEntries(ctx context.Context, e *export.Export) (*export.Table, error) {
+	layout, err := s.accounting.Layout(e.Layout)
+	if err != nil {
+		return nil, err
+	}
+
+	var sales []struct {
+		ID          string
+		Number      string
+		Status      string
+		TotalAmount float64
+		CreatedAt   time.Time
+		Email       string
+		FirstName   string
+		LastName    string
+	}
+	err = withRange(conn(ctx, s.db), "orders.created_at", e).
+		Table("orders").
+		Select("orders.id, orders.number, orders.status, orders.total_amount, orders.created_at, users.email, users.first_name, users.last_name").
+		Joins("LEFT JOIN users ON users.id = orders.user_id").
+		Where("NOT (orders.status = ? AND orders.payment_status = ?)", order.StatusCancelled, order.PaymentStatusUnpaid).
+		Order("orders.created_at").
+		Scan(&sales).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []export.Entry
+	for _, row := range sales {
+		o := &order.Order{ID: row.ID, Number: row.Number, CreatedAt: row.CreatedAt}
+		entries = append(entries, export.Entry{
+			Type:          export.EntrySale,
+			Date:          row.CreatedAt,
+			OrderID:       row.ID,
+			OrderNumber:   row.Number,
+			InvoiceNumber: o.InvoiceNumber(),
+			CustomerName:  strings.TrimSpace(row.FirstName + " " + row.LastName),
+			CustomerEmail: row.Email,
+			Description:   "Order " + orderReference(row.ID, row.Number),
+			Status:        row.Status,
+			Amount:        row.TotalAmount,
+		})
+	}
+
+	payments, err := s.accountingPayments(ctx, e, "payments.processed_at", "payments.status IN ?", []payment.Status{payment.StatusPaid, payment.StatusRefunded})
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range payments {
+		entries = append(entries, row.entry(export.EntryPayment, *row.ProcessedAt, row.Amount, "Payment"))
+	}
+
+	refunds, err := s.accountingPayments(ctx, e, "COALESCE(payments.refunded_at, payments.updated_at)", "payments.refunded_amount > 0")
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range refunds {
+		at := row.UpdatedAt
+		if row.RefundedAt != nil {
+			at = *row.RefundedAt
+		}
+		entries = append(entries, row.entry(export.EntryRefund, at, row.RefundedAmount, "Refund"))
+	}
+
+	return s.accounting.Table(layout, entries), nil
+}
+
+type accountingPayment struct {
+	ID             string
+	OrderID        string
+	Number         string
+	CreatedAt      time.Time
+	Method         string
+	Status         string
+	TransactionID  string
+	Amount         float64
+	RefundedAmount float64
+	Currency       string
+	ProcessedAt    *time.Time
+	RefundedAt     *time.Time
+	UpdatedAt      time.Time
+	Email          string
+	FirstName      string
+	LastName       string
+}
+
+// accountingPayments are the payments matching where, with their date
+// column within the range.
+func (s *ExportSource) accountingPayments(ctx context.Context, e *export.Export, dateColumn, where string, args ...interface{}) ([]accountingPayment, error) {
+	var rows []accountingPayment
+	err := withRange(conn(ctx, s.db), dateColumn, e).
+		Table("payments").
+		Select("payments.id, payments.order_id, orders.number, orders.created_at, payments.method, payments.status, payments.transaction_id, payments.amount, payments.refunded_amount, payments.currency, payments.processed_at, payments.refunded_at, payments.updated_at, users.email, users.first_name, users.last_name").
+		Joins("LEFT JOIN orders ON orders.id = payments.order_id").
+		Joins("LEFT JOIN users ON users.id = payments.user_id").
+		Where(where, args...).
+		Where(dateColumn + " IS NOT NULL").
+		Order(dateColumn).
+		Scan(&rows).Error
+	return rows, err
+}
+
+// entry is the payment booked as t, described as what it is of its order.
+func (p accountingPayment) entry(t export.EntryType, at time.Time, amount float64, what string) export.Entry {
+	entry := export.Entry{
+		Type:          t,
+		Date:          at,
+		OrderID:       p.OrderID,
+		OrderNumber:   p.Number,
+		CustomerName:  strings.TrimSpace(p.FirstName + " " + p.LastName),
+		CustomerEmail: p.Email,
+		Method:        p.Method,
+		TransactionID: p.TransactionID,
+		Description:   what,
+		Status:        p.Status,
+		Amount:        amount,
+		Currency:      p.Currency,
+	}
+	// Payments of draft orders that were never converted have no order
+	if p.OrderID != "" {
+		entry.InvoiceNumber = (&order.Order{ID: p.OrderID, Number: p.Number, CreatedAt: p.CreatedAt}).InvoiceNumber()
+		entry.Description += " of order " + orderReference(p.OrderID, p.Number)
+	}
+	return entry
+}
+
+// orderReference is the order's number, or its ID for orders without one.
+func orderReference(id, number string) string {
+	if number == "" {
+		return id
+	}
+	return number
+}
+
+func withRange(db *gorm.DB, column string, e *export.Export) *gorm.DB {
+	if !e.From.IsZero() {
+		db = db.Where(column+" >= ?", e.From)
+	}
+	if !e.To.IsZero() {
+		db = db.Where(column+" < ?", e.To)
+	}
+	return db
+}
+
+func formatAmount(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
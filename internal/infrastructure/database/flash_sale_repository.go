@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"online-shop/internal/domain/flashsale"
+
+	"gorm.io/gorm"
+)
+
+type FlashSaleRepository struct {
+	db *gorm.DB
+}
+
+func NewFlashSaleRepository(db *gorm.DB) flashsale.Repository {
+	return &FlashSaleRepository{db: db}
+}
+
+func (r *FlashSaleRepository) Create(ctx context.Context, s *flashsale.Sale) error {
+	return conn(ctx, r.db).Create(s).Error
+}
+
+func (r *FlashSaleRepository) GetByID(ctx context.Context, id string) (*flashsale.Sale, error) {
+	var s flashsale.Sale
+	err := conn(ctx, r.db).Preload("Items").Where("id = ?", id).First(&s).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, flashsale.ErrSaleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Update replaces the items in the same transaction, so a sale is never
+// served with half of its products
+func (r *FlashSaleRepository) Update(ctx context.Context, s *flashsale.Sale) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("sale_id = ?", s.ID).Delete(&flashsale.Item{}).Error; err != nil {
+			return err
+		}
+		return tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(s).Error
+	})
+}
+
+func (r *FlashSaleRepository) Delete(ctx context.Context, id string) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("sale_id = ?", id).Delete(&flashsale.Item{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("id = ?", id).Delete(&flashsale.Sale{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return flashsale.ErrSaleNotFound
+		}
+		return nil
+	})
+}
+
+func (r *FlashSaleRepository) List(ctx context.Context, limit, offset int) ([]*flashsale.Sale, error) {
+	var sales []*flashsale.Sale
+	err := conn(ctx, r.db).Preload("Items").
+		Order("starts_at DESC").
+		Limit(limit).Offset(offset).
+		Find(&sales).Error
+	return sales, err
+}
+
+func (r *FlashSaleRepository) ListCurrent(ctx context.Context, at time.Time, limit int) ([]*flashsale.Sale, error) {
+	var sales []*flashsale.Sale
+	err := conn(ctx, r.db).Preload("Items").
+		Where("active = ? AND ends_at > ?", true, at).
+		Order("starts_at ASC").
+		Limit(limit).
+		Find(&sales).Error
+	return sales, err
+}
+
+func (r *FlashSaleRepository) ListLive(ctx context.Context, productIDs []string, at time.Time) ([]*flashsale.Sale, error) {
+	if len(productIDs) == 0 {
+		return nil, nil
+	}
+	var sales []*flashsale.Sale
+	err := conn(ctx, r.db).
+		Preload("Items", "product_id IN ?", productIDs).
+		Where("active = ? AND starts_at <= ? AND ends_at > ?", true, at, at).
+		Where("id IN (?)", conn(ctx, r.db).Model(&flashsale.Item{}).Select("sale_id").Where("product_id IN ?", productIDs)).
+		Find(&sales).Error
+	return sales, err
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"context"
+
+	"online-shop/internal/domain/forecast"
+
+	"gorm.io/gorm"
+)
+
+type ForecastRepository struct {
+	db *gorm.DB
+}
+
+func NewForecastRepository(db *gorm.DB) forecast.Repository {
+	return &ForecastRepository{db: db}
+}
+
+func (r *ForecastRepository) Replace(ctx context.Context, suggestions []*forecast.Suggestion) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&forecast.Suggestion{}).Error; err != nil {
+			return err
+		}
+		if len(suggestions) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(suggestions, 500).Error
+	})
+}
+
+func (r *ForecastRepository) List(ctx context.Context, filter forecast.Filter) ([]*forecast.Suggestion, int64, error) {
+	query := conn(ctx, r.db).Model(&forecast.Suggestion{})
+	if filter.WarehouseID != "" {
+		query = query.Where("warehouse_id = ?", filter.WarehouseID)
+	}
+	if filter.ProductID != "" {
+		query = query.Where("product_id = ?", filter.ProductID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	query = query.Order("stockout_at ASC NULLS LAST, product_id, warehouse_id")
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit).Offset(filter.Offset)
+	}
+	var suggestions []*forecast.Suggestion
+	err := query.Find(&suggestions).Error
+	return suggestions, total, err
+}
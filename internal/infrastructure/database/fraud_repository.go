@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"online-shop/internal/domain/fraud"
+
+	"gorm.io/gorm"
+)
+
+type FraudRepository struct {
+	db *gorm.DB
+}
+
+func NewFraudRepository(db *gorm.DB) fraud.Repository {
+	return &FraudRepository{db: db}
+}
+
+func (r *FraudRepository) Create(ctx context.Context, a *fraud.Assessment) error {
+	return conn(ctx, r.db).Create(a).Error
+}
+
+func (r *FraudRepository) GetByOrderID(ctx context.Context, orderID string) (*fraud.Assessment, error) {
+	var a fraud.Assessment
+	err := conn(ctx, r.db).Where("order_id = ?", orderID).First(&a).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fraud.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *FraudRepository) Update(ctx context.Context, a *fraud.Assessment) error {
+	return conn(ctx, r.db).Save(a).Error
+}
+
+func (r *FraudRepository) List(ctx context.Context, status fraud.Status, limit, offset int) ([]*fraud.Assessment, error) {
+	query := conn(ctx, r.db).Order("created_at DESC")
+	if status != "" {
+		query = conn(ctx, r.db).Where("status = ?", status).Order("created_at ASC")
+	}
+
+	var assessments []*fraud.Assessment
+	err := query.Limit(limit).Offset(offset).Find(&assessments).Error
+	return assessments, err
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/domain/idempotency"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) idempotency.Store {
+	return &IdempotencyRepository{db: db}
+}
+
+func (r *IdempotencyRepository) Reserve(ctx context.Context, record *idempotency.Record, ttl time.Duration) (*idempotency.Record, error) {
+	db := conn(ctx, r.db)
+
+	// Expired keys can be reused
+	if err := db.Where("key = ? AND expires_at < ?", record.Key, time.Now()).
+		Delete(&idempotency.Record{}).Error; err != nil {
+		return nil, err
+	}
+
+	record.ExpiresAt = time.Now().Add(ttl)
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return nil, nil
+	}
+
+	var existing idempotency.Record
+	if err := db.Where("key = ?", record.Key).First(&existing).Error; err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+func (r *IdempotencyRepository) Complete(ctx context.Context, record *idempotency.Record, ttl time.Duration) error {
+	record.ExpiresAt = time.Now().Add(ttl)
+	return conn(ctx, r.db).Save(record).Error
+}
+
+func (r *IdempotencyRepository) Release(ctx context.Context, key string) error {
+	return conn(ctx, r.db).Where("key = ?", key).Delete(&idempotency.Record{}).Error
+}
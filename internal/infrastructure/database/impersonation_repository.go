@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"online-shop/internal/domain/impersonation"
+
+	"gorm.io/gorm"
+)
+
+type ImpersonationRepository struct {
+	db *gorm.DB
+}
+
+func NewImpersonationRepository(db *gorm.DB) impersonation.Repository {
+	return &ImpersonationRepository{db: db}
+}
+
+func (r *ImpersonationRepository) Create(ctx context.Context, grant *impersonation.Grant) error {
+	return conn(ctx, r.db).Create(grant).Error
+}
+
+func (r *ImpersonationRepository) GetByID(ctx context.Context, id string) (*impersonation.Grant, error) {
+	var grant impersonation.Grant
+	err := conn(ctx, r.db).Where("id = ?", id).First(&grant).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, impersonation.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+func (r *ImpersonationRepository) Update(ctx context.Context, grant *impersonation.Grant) error {
+	return conn(ctx, r.db).Save(grant).Error
+}
+
+func (r *ImpersonationRepository) List(ctx context.Context, filter impersonation.Filter, limit, offset int) ([]*impersonation.Grant, int64, error) {
+	query := conn(ctx, r.db).Model(&impersonation.Grant{})
+	if filter.AdminID != "" {
+		query = query.Where("admin_id = ?", filter.AdminID)
+	}
+	if filter.CustomerID != "" {
+		query = query.Where("customer_id = ?", filter.CustomerID)
+	}
+	if filter.Active {
+		query = query.Where("ended_at IS NULL AND expires_at > ?", time.Now())
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var grants []*impersonation.Grant
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&grants).Error
+	return grants, total, err
+}
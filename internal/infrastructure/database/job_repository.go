@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"online-shop/internal/domain/job"
+
+	"gorm.io/gorm"
+)
+
+type JobRepository struct {
+	db *gorm.DB
+}
+
+func NewJobRepository(db *gorm.DB) job.Repository {
+	return &JobRepository{db: db}
+}
+
+func (r *JobRepository) Get(ctx context.Context, id string) (*job.Job, error) {
+	var j job.Job
+	err := conn(ctx, r.db).Where("id = ?", id).First(&j).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, job.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func (r *JobRepository) Save(ctx context.Context, j *job.Job) error {
+	return conn(ctx, r.db).Save(j).Error
+}
+
+func (r *JobRepository) List(ctx context.Context, filter job.Filter, limit, offset int) ([]*job.Job, int64, error) {
+	query := conn(ctx, r.db).Model(&job.Job{})
+	if filter.Queue != "" {
+		query = query.Where("queue = ?", filter.Queue)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.State != "" {
+		query = query.Where("state = ?", filter.State)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var jobs []*job.Job
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&jobs).Error
+	return jobs, total, err
+}
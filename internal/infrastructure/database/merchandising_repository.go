@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"online-shop/internal/domain/merchandising"
+
+	"gorm.io/gorm"
+)
+
+type MerchandisingRuleRepository struct {
+	db *gorm.DB
+}
+
+func NewMerchandisingRuleRepository(db *gorm.DB) merchandising.Repository {
+	return &MerchandisingRuleRepository{db: db}
+}
+
+func (r *MerchandisingRuleRepository) Create(ctx context.Context, rule *merchandising.Rule) error {
+	return conn(ctx, r.db).Create(rule).Error
+}
+
+func (r *MerchandisingRuleRepository) GetByID(ctx context.Context, id string) (*merchandising.Rule, error) {
+	var rule merchandising.Rule
+	err := conn(ctx, r.db).Where("id = ?", id).First(&rule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, merchandising.ErrRuleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *MerchandisingRuleRepository) Update(ctx context.Context, rule *merchandising.Rule) error {
+	return conn(ctx, r.db).Save(rule).Error
+}
+
+func (r *MerchandisingRuleRepository) Delete(ctx context.Context, id string) error {
+	result := conn(ctx, r.db).Where("id = ?", id).Delete(&merchandising.Rule{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return merchandising.ErrRuleNotFound
+	}
+	return nil
+}
+
+func (r *MerchandisingRuleRepository) List(ctx context.Context, kind merchandising.Kind, limit, offset int) ([]*merchandising.Rule, int64, error) {
+	query := conn(ctx, r.db).Model(&merchandising.Rule{})
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var rules []*merchandising.Rule
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&rules).Error
+	return rules, total, err
+}
+
+func (r *MerchandisingRuleRepository) ListLive(ctx context.Context, at time.Time) ([]*merchandising.Rule, error) {
+	var rules []*merchandising.Rule
+	err := conn(ctx, r.db).
+		Where("active = ?", true).
+		Where("starts_at IS NULL OR starts_at <= ?", at).
+		Where("ends_at IS NULL OR ends_at > ?", at).
+		Order("created_at ASC, id ASC").
+		Find(&rules).Error
+	return rules, err
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"online-shop/internal/domain/moderation"
+
+	"gorm.io/gorm"
+)
+
+type ModerationRepository struct {
+	db *gorm.DB
+}
+
+func NewModerationRepository(db *gorm.DB) moderation.Repository {
+	return &ModerationRepository{db: db}
+}
+
+func (r *ModerationRepository) Create(ctx context.Context, s *moderation.Submission) error {
+	return conn(ctx, r.db).Create(s).Error
+}
+
+func (r *ModerationRepository) Update(ctx context.Context, s *moderation.Submission) error {
+	return conn(ctx, r.db).Save(s).Error
+}
+
+func (r *ModerationRepository) GetByID(ctx context.Context, id string) (*moderation.Submission, error) {
+	return r.first(conn(ctx, r.db).Where("id = ?", id))
+}
+
+func (r *ModerationRepository) Pending(ctx context.Context, productID string) (*moderation.Submission, error) {
+	return r.first(conn(ctx, r.db).Where("product_id = ? AND status = ?", productID, moderation.StatusPending))
+}
+
+func (r *ModerationRepository) first(query *gorm.DB) (*moderation.Submission, error) {
+	var s moderation.Submission
+	err := query.First(&s).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, moderation.ErrSubmissionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *ModerationRepository) List(ctx context.Context, filter moderation.Filter) ([]*moderation.Submission, int64, error) {
+	query := conn(ctx, r.db).Model(&moderation.Submission{})
+	if filter.MerchantID != "" {
+		query = query.Where("merchant_id = ?", filter.MerchantID)
+	}
+	if filter.ProductID != "" {
+		query = query.Where("product_id = ?", filter.ProductID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var submissions []*moderation.Submission
+	err := query.Order("submitted_at ASC").Limit(filter.Limit).Offset(filter.Offset).Find(&submissions).Error
+	return submissions, total, err
+}
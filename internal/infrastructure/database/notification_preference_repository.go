@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+
+	"online-shop/internal/domain/notification"
+	"online-shop/internal/domain/user"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationPreferenceRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationPreferenceRepository(db *gorm.DB) notification.Repository {
+	return &NotificationPreferenceRepository{db: db}
+}
+
+func (r *NotificationPreferenceRepository) List(ctx context.Context, userID string) ([]*notification.Preference, error) {
+	var prefs []*notification.Preference
+	err := conn(ctx, r.db).Where("user_id = ?", userID).Order("category ASC, channel ASC").Find(&prefs).Error
+	return prefs, err
+}
+
+func (r *NotificationPreferenceRepository) Save(ctx context.Context, prefs []*notification.Preference) error {
+	if len(prefs) == 0 {
+		return nil
+	}
+	return conn(ctx, r.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "category"}, {Name: "channel"}},
+		DoUpdates: clause.AssignmentColumns([]string{"enabled", "updated_at"}),
+	}).Create(prefs).Error
+}
+
+func (r *NotificationPreferenceRepository) Enabled(ctx context.Context, userID string, category notification.Category, channel user.Channel) (bool, error) {
+	var count int64
+	err := conn(ctx, r.db).Model(&notification.Preference{}).
+		Where("user_id = ? AND category = ? AND channel = ? AND NOT enabled", userID, category, channel).
+		Count(&count).Error
+	return count == 0, err
+}
+
+func (r *NotificationPreferenceRepository) EmailEnabled(ctx context.Context, email string, category notification.Category) (bool, error) {
+	var count int64
+	err := conn(ctx, r.db).Model(&notification.Preference{}).
+		Joins("JOIN users ON users.id = notification_preferences.user_id").
+		Where("LOWER(users.email) = LOWER(?) AND notification_preferences.category = ? AND notification_preferences.channel = ? AND NOT notification_preferences.enabled",
+			email, category, user.ChannelEmail).
+		Count(&count).Error
+	return count == 0, err
+}
@@ -0,0 +1,30 @@
+package database
+
+import (
+	"context"
+
+	"online-shop/internal/domain/oauth"
+
+	"gorm.io/gorm"
+)
+
+type OAuthAccountRepository struct {
+	db *gorm.DB
+}
+
+func NewOAuthAccountRepository(db *gorm.DB) oauth.AccountRepository {
+	return &OAuthAccountRepository{db: db}
+}
+
+func (r *OAuthAccountRepository) Create(ctx context.Context, account *oauth.Account) error {
+	return conn(ctx, r.db).Create(account).Error
+}
+
+func (r *OAuthAccountRepository) GetByProviderSubject(ctx context.Context, provider, subject string) (*oauth.Account, error) {
+	var account oauth.Account
+	err := conn(ctx, r.db).Where("provider = ? AND subject = ?", provider, subject).First(&account).Error
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/domain/order"
+
+	"gorm.io/gorm"
+)
+
+// orderNumberSequence is created by Migrate
+const orderNumberSequence = "order_number_seq"
+
+// OrderNumberSequence draws order numbers from a Postgres sequence, so
+// every API instance gets distinct numbers without coordinating. Numbers
+// start with the prefix from prefixes when it is set and can be read, and
+// with the configured prefix otherwise.
+type OrderNumberSequence struct {
+	db       *gorm.DB
+	prefix   string
+	digits   int
+	prefixes order.PrefixSource
+}
+
+func NewOrderNumberSequence(db *gorm.DB, prefix string, digits int, prefixes order.PrefixSource) order.NumberGenerator {
+	return &OrderNumberSequence{db: db, prefix: prefix, digits: digits, prefixes: prefixes}
+}
+
+func (s *OrderNumberSequence) Next(ctx context.Context, at time.Time) (string, error) {
+	var n int64
+	if err := conn(ctx, s.db).Raw("SELECT nextval(?)", orderNumberSequence).Scan(&n).Error; err != nil {
+		return "", err
+	}
+	return order.FormatNumber(s.numberPrefix(ctx), at, n, s.digits), nil
+}
+
+// numberPrefix does not fail the order when the prefix cannot be read: the
+// sequence keeps numbers unique whatever the prefix
+func (s *OrderNumberSequence) numberPrefix(ctx context.Context) string {
+	if s.prefixes == nil {
+		return s.prefix
+	}
+	if prefix, err := s.prefixes.NumberPrefix(ctx); err == nil && prefix != "" {
+		return prefix
+	}
+	return s.prefix
+}
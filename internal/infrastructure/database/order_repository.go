@@ -1,9 +1,16 @@
 package database
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
 	"online-shop/internal/domain/order"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type OrderRepository struct {
@@ -14,42 +21,280 @@ func NewOrderRepository(db *gorm.DB) order.Repository {
 	return &OrderRepository{db: db}
 }
 
-func (r *OrderRepository) Create(o *order.Order) error {
-	return r.db.Create(o).Error
+func NewPurchaseHistory(db *gorm.DB) order.PurchaseHistory {
+	return &OrderRepository{db: db}
+}
+
+func (r *OrderRepository) Create(ctx context.Context, o *order.Order) error {
+	return conn(ctx, r.db).Create(o).Error
+}
+
+func (r *OrderRepository) GetByID(ctx context.Context, id string) (*order.Order, error) {
+	var o order.Order
+	err := conn(ctx, r.db).Preload("Items").Preload("Shipments").Where("id = ?", id).First(&o).Error
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
 }
 
-func (r *OrderRepository) GetByID(id string) (*order.Order, error) {
+func (r *OrderRepository) GetByNumber(ctx context.Context, number string) (*order.Order, error) {
 	var o order.Order
-	err := r.db.Preload("Items").Where("id = ?", id).First(&o).Error
+	err := conn(ctx, r.db).Preload("Items").Preload("Shipments").Where("number = ?", number).First(&o).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, order.ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &o, nil
 }
 
-func (r *OrderRepository) GetByUserID(userID string, limit, offset int) ([]*order.Order, error) {
+func (r *OrderRepository) GetByUserID(ctx context.Context, userID string, limit, offset int) ([]*order.Order, error) {
 	var orders []*order.Order
-	err := r.db.Preload("Items").
+	err := conn(ctx, r.db).Preload("Items").Preload("Shipments").
 		Where("user_id = ?", userID).
 		Order("created_at DESC").
 		Limit(limit).Offset(offset).Find(&orders).Error
 	return orders, err
 }
 
-func (r *OrderRepository) Update(o *order.Order) error {
-	return r.db.Save(o).Error
+func (r *OrderRepository) Update(ctx context.Context, o *order.Order) error {
+	return conn(ctx, r.db).Save(o).Error
 }
 
-func (r *OrderRepository) UpdateStatus(orderID string, status order.Status) error {
-	return r.db.Model(&order.Order{}).
+func (r *OrderRepository) UpdateStatus(ctx context.Context, orderID string, status order.Status) error {
+	return conn(ctx, r.db).Model(&order.Order{}).
 		Where("id = ?", orderID).
 		Update("status", status).Error
 }
 
-func (r *OrderRepository) List(limit, offset int) ([]*order.Order, error) {
+// UpdateFrom saves the order's own columns; its items and shipments are
+// left as they are.
+func (r *OrderRepository) UpdateFrom(ctx context.Context, o *order.Order, from order.Status) (bool, error) {
+	res := conn(ctx, r.db).Model(&order.Order{}).
+		Where("id = ? AND status = ?", o.ID, from).
+		Select("*").Omit("id", "created_at", clause.Associations).
+		Updates(o)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (r *OrderRepository) List(ctx context.Context, limit, offset int) ([]*order.Order, error) {
 	var orders []*order.Order
-	err := r.db.Preload("Items").
+	err := conn(ctx, r.db).Preload("Items").Preload("Shipments").
 		Order("created_at DESC").
 		Limit(limit).Offset(offset).Find(&orders).Error
 	return orders, err
-}
\ No newline at end of file
+}
+func (r *OrderRepository) Search(ctx context.Context, filter order.ListFilter, keys []order.SortKey, after order.Position, limit int) ([]*order.Order, error) {
+	query := filterOrders(conn(ctx, r.db).Preload("Items").Preload("Shipments"), filter)
+
+	if after != nil {
+		clause, args, err := keysetClause(keys, after)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(clause, args...)
+	}
+
+	for _, k := range keys {
+		query = query.Order(orderColumns[k.Field] + direction(k.Descending))
+	}
+
+	var orders []*order.Order
+	err := query.Order("orders.id ASC").Limit(limit).Find(&orders).Error
+	return orders, err
+}
+
+var orderColumns = map[order.SortField]string{
+	order.SortCreatedAt:   "orders.created_at",
+	order.SortTotalAmount: "orders.total_amount",
+	order.SortStatus:      "orders.status",
+	order.SortNumber:      "orders.number",
+}
+
+func direction(descending bool) string {
+	if descending {
+		return " DESC"
+	}
+	return " ASC"
+}
+
+// keysetClause selects the rows that sort after the position. Each key may
+// run in its own direction, so rather than a row comparison it expands to
+// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ..., ending on the ID.
+func keysetClause(keys []order.SortKey, after order.Position) (string, []interface{}, error) {
+	values, err := after.Values(keys)
+	if err != nil {
+		return "", nil, err
+	}
+
+	columns := make([]string, 0, len(keys)+1)
+	ops := make([]string, 0, len(keys)+1)
+	for _, k := range keys {
+		columns = append(columns, orderColumns[k.Field])
+		if k.Descending {
+			ops = append(ops, "<")
+		} else {
+			ops = append(ops, ">")
+		}
+	}
+	columns = append(columns, "orders.id")
+	ops = append(ops, ">")
+
+	var ors []string
+	var args []interface{}
+	for i := range columns {
+		var ands []string
+		for j := 0; j < i; j++ {
+			ands = append(ands, columns[j]+" = ?")
+			args = append(args, values[j])
+		}
+		ands = append(ands, columns[i]+" "+ops[i]+" ?")
+		args = append(args, values[i])
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return "(" + strings.Join(ors, " OR ") + ")", args, nil
+}
+
+func (r *OrderRepository) ListByFilter(ctx context.Context, filter order.ListFilter, limit, offset int) ([]*order.Order, int64, error) {
+	query := filterOrders(conn(ctx, r.db).Model(&order.Order{}), filter)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var orders []*order.Order
+	err := query.Preload("Items").Preload("Shipments").
+		Order("orders.created_at DESC").Order("orders.id ASC").
+		Limit(limit).Offset(offset).Find(&orders).Error
+	return orders, total, err
+}
+
+func filterOrders(query *gorm.DB, filter order.ListFilter) *gorm.DB {
+	if len(filter.Statuses) > 0 {
+		query = query.Where("orders.status IN ?", filter.Statuses)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("orders.created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("orders.created_at < ?", filter.To)
+	}
+	if filter.MinAmount != nil {
+		query = query.Where("orders.total_amount >= ?", *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		query = query.Where("orders.total_amount <= ?", *filter.MaxAmount)
+	}
+	if filter.UserID != "" {
+		query = query.Where("orders.user_id = ?", filter.UserID)
+	}
+	if filter.OrganizationID != "" {
+		query = query.Where("orders.organization_id = ?", filter.OrganizationID)
+	}
+	if filter.PaymentMethod != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM payments WHERE payments.order_id = orders.id AND payments.method = ?)", filter.PaymentMethod)
+	}
+	if filter.MerchantID != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM order_items WHERE order_items.order_id = orders.id AND order_items.merchant_id = ?)", filter.MerchantID)
+	}
+	if filter.ProductName != "" {
+		// Items match by the name they were ordered under or the product's
+		// current one
+		pattern := "%" + filter.ProductName + "%"
+		query = query.Where("EXISTS (SELECT 1 FROM order_items LEFT JOIN products ON products.id = order_items.product_id WHERE order_items.order_id = orders.id AND (order_items.product_name ILIKE ? OR products.name ILIKE ?))", pattern, pattern)
+	}
+	return query
+}
+
+func (r *OrderRepository) GetByShipmentID(ctx context.Context, shipmentID string) (*order.Order, error) {
+	var s order.Shipment
+	err := conn(ctx, r.db).Select("order_id").Where("id = ?", shipmentID).First(&s).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, order.ErrShipmentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.GetByID(ctx, s.OrderID)
+}
+
+func (r *OrderRepository) ListForFulfillment(ctx context.Context, warehouseID string, status order.ShipmentStatus, limit int) ([]*order.Order, error) {
+	var orders []*order.Order
+	err := conn(ctx, r.db).Preload("Items").Preload("Shipments").
+		Where("orders.status IN ?", []order.Status{order.StatusConfirmed, order.StatusProcessing}).
+		Where("EXISTS (SELECT 1 FROM shipments WHERE shipments.order_id = orders.id AND shipments.warehouse_id = ? AND shipments.status = ?)", warehouseID, status).
+		Order("orders.created_at ASC").
+		Limit(limit).Find(&orders).Error
+	return orders, err
+}
+
+func (r *OrderRepository) SaveShipment(ctx context.Context, o *order.Order, s *order.Shipment, from order.ShipmentStatus) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&order.Shipment{}).
+			Where("id = ? AND status = ?", s.ID, from).
+			Select("*").Omit("id", "order_id", "warehouse_id", "created_at").
+			Updates(s)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return fmt.Errorf("%w: shipment is no longer %s", order.ErrShipmentState, from)
+		}
+
+		// An order cancelled in the meantime is not worked on any further
+		res = tx.Model(&order.Order{}).
+			Where("id = ? AND status NOT IN ?", o.ID, []order.Status{order.StatusCancelling, order.StatusCancelled}).
+			Updates(map[string]interface{}{"status": o.Status, "updated_at": o.UpdatedAt})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return fmt.Errorf("%w: order was cancelled", order.ErrShipmentState)
+		}
+		return nil
+	})
+}
+
+func (r *OrderRepository) CancellationStats(ctx context.Context, from, to time.Time) ([]order.CancellationStat, error) {
+	var stats []order.CancellationStat
+	err := conn(ctx, r.db).Model(&order.Order{}).
+		Select("cancel_reason AS reason, COUNT(*) AS orders, SUM(cancel_fee) AS fees, SUM(cancel_refunded) AS refunded").
+		Where("status = ? AND cancel_at >= ? AND cancel_at < ?", order.StatusCancelled, from, to).
+		Group("cancel_reason").
+		Order("orders DESC").
+		Scan(&stats).Error
+	return stats, err
+}
+
+// PurchasedQuantities counts refunded orders, as a refund does not always
+// take the goods back.
+func (r *OrderRepository) PurchasedQuantities(ctx context.Context, userID string, productIDs []string) (map[string]int, error) {
+	quantities := make(map[string]int, len(productIDs))
+	if len(productIDs) == 0 {
+		return quantities, nil
+	}
+
+	var rows []struct {
+		ProductID string
+		Quantity  int
+	}
+	err := conn(ctx, r.db).Model(&order.OrderItem{}).
+		Select("order_items.product_id, SUM(order_items.quantity) AS quantity").
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("orders.user_id = ? AND orders.status <> ? AND order_items.product_id IN ?", userID, order.StatusCancelled, productIDs).
+		Group("order_items.product_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		quantities[row.ProductID] = row.Quantity
+	}
+	return quantities, nil
+}
@@ -0,0 +1,192 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/organization"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OrganizationRepository struct {
+	db *gorm.DB
+}
+
+func NewOrganizationRepository(db *gorm.DB) organization.Repository {
+	return &OrganizationRepository{db: db}
+}
+
+func (r *OrganizationRepository) Create(ctx context.Context, o *organization.Organization) error {
+	return conn(ctx, r.db).Create(o).Error
+}
+
+func (r *OrganizationRepository) GetByID(ctx context.Context, id string) (*organization.Organization, error) {
+	var o organization.Organization
+	err := conn(ctx, r.db).Where("id = ?", id).First(&o).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, organization.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+func (r *OrganizationRepository) Lock(ctx context.Context, id string) (*organization.Organization, error) {
+	var o organization.Organization
+	err := conn(ctx, r.db).Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&o).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, organization.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+func (r *OrganizationRepository) Update(ctx context.Context, o *organization.Organization) error {
+	return conn(ctx, r.db).Save(o).Error
+}
+
+func (r *OrganizationRepository) List(ctx context.Context, limit, offset int) ([]*organization.Organization, error) {
+	var orgs []*organization.Organization
+	err := conn(ctx, r.db).Order("name ASC").Limit(limit).Offset(offset).Find(&orgs).Error
+	return orgs, err
+}
+
+func (r *OrganizationRepository) GetMember(ctx context.Context, userID string) (*organization.Member, error) {
+	var m organization.Member
+	err := conn(ctx, r.db).Where("user_id = ?", userID).First(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, organization.ErrMemberNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *OrganizationRepository) ListMembers(ctx context.Context, organizationID string) ([]*organization.Member, error) {
+	var members []*organization.Member
+	err := conn(ctx, r.db).Where("organization_id = ?", organizationID).Order("created_at ASC").Find(&members).Error
+	return members, err
+}
+
+func (r *OrganizationRepository) SaveMember(ctx context.Context, m *organization.Member) error {
+	return conn(ctx, r.db).Save(m).Error
+}
+
+func (r *OrganizationRepository) DeleteMember(ctx context.Context, userID string) error {
+	return conn(ctx, r.db).Where("user_id = ?", userID).Delete(&organization.Member{}).Error
+}
+
+type PurchaseApprovalRepository struct {
+	db *gorm.DB
+}
+
+func NewPurchaseApprovalRepository(db *gorm.DB) organization.ApprovalRepository {
+	return &PurchaseApprovalRepository{db: db}
+}
+
+func (r *PurchaseApprovalRepository) Create(ctx context.Context, a *organization.Approval) error {
+	return conn(ctx, r.db).Create(a).Error
+}
+
+func (r *PurchaseApprovalRepository) GetByID(ctx context.Context, id string) (*organization.Approval, error) {
+	return r.first(conn(ctx, r.db).Where("id = ?", id))
+}
+
+func (r *PurchaseApprovalRepository) GetPendingByOrderID(ctx context.Context, orderID string) (*organization.Approval, error) {
+	return r.first(conn(ctx, r.db).Where("order_id = ? AND status = ?", orderID, organization.ApprovalPending))
+}
+
+func (r *PurchaseApprovalRepository) first(query *gorm.DB) (*organization.Approval, error) {
+	var a organization.Approval
+	err := query.First(&a).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, organization.ErrApprovalNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *PurchaseApprovalRepository) Update(ctx context.Context, a *organization.Approval) error {
+	return conn(ctx, r.db).Save(a).Error
+}
+
+func (r *PurchaseApprovalRepository) List(ctx context.Context, filter organization.ApprovalFilter, limit, offset int) ([]*organization.Approval, error) {
+	query := conn(ctx, r.db).Where("organization_id = ?", filter.OrganizationID)
+	if filter.RequestedBy != "" {
+		query = query.Where("requested_by = ?", filter.RequestedBy)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var approvals []*organization.Approval
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&approvals).Error
+	return approvals, err
+}
+
+type InvoiceRepository struct {
+	db *gorm.DB
+}
+
+func NewInvoiceRepository(db *gorm.DB) organization.InvoiceRepository {
+	return &InvoiceRepository{db: db}
+}
+
+func (r *InvoiceRepository) Create(ctx context.Context, i *organization.Invoice) error {
+	return conn(ctx, r.db).Create(i).Error
+}
+
+func (r *InvoiceRepository) GetByID(ctx context.Context, id string) (*organization.Invoice, error) {
+	var i organization.Invoice
+	err := conn(ctx, r.db).Where("id = ?", id).First(&i).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, organization.ErrInvoiceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+func (r *InvoiceRepository) Update(ctx context.Context, i *organization.Invoice) error {
+	return conn(ctx, r.db).Save(i).Error
+}
+
+func (r *InvoiceRepository) List(ctx context.Context, filter organization.InvoiceFilter, limit, offset int) ([]*organization.Invoice, error) {
+	query := conn(ctx, r.db)
+	if filter.OrganizationID != "" {
+		query = query.Where("organization_id = ?", filter.OrganizationID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var invoices []*organization.Invoice
+	err := query.Order("due_at ASC").Limit(limit).Offset(offset).Find(&invoices).Error
+	return invoices, err
+}
+
+func (r *InvoiceRepository) Exposure(ctx context.Context, organizationID string, now time.Time) (organization.Exposure, error) {
+	var row struct {
+		Invoices int
+		Amount   float64
+		Overdue  int
+	}
+	err := conn(ctx, r.db).Model(&organization.Invoice{}).
+		Select("COUNT(*) AS invoices, COALESCE(SUM(organization_invoices.amount), 0) AS amount, COUNT(*) FILTER (WHERE organization_invoices.due_at < ?) AS overdue", now).
+		Joins("JOIN orders ON orders.id = organization_invoices.order_id").
+		Where("organization_invoices.organization_id = ? AND organization_invoices.status = ?", organizationID, organization.InvoiceOpen).
+		Where("orders.status NOT IN ?", []order.Status{order.StatusCancelled, order.StatusRefunded}).
+		Scan(&row).Error
+	return organization.Exposure{Invoices: row.Invoices, Amount: row.Amount, Overdue: row.Overdue}, err
+}
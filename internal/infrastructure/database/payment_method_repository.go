@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"online-shop/internal/domain/payment"
+
+	"gorm.io/gorm"
+)
+
+type PaymentMethodRepository struct {
+	db *gorm.DB
+}
+
+func NewPaymentMethodRepository(db *gorm.DB) payment.MethodRepository {
+	return &PaymentMethodRepository{db: db}
+}
+
+func (r *PaymentMethodRepository) Create(ctx context.Context, m *payment.SavedMethod) error {
+	return conn(ctx, r.db).Create(m).Error
+}
+
+func (r *PaymentMethodRepository) GetByID(ctx context.Context, id string) (*payment.SavedMethod, error) {
+	var m payment.SavedMethod
+	err := conn(ctx, r.db).Where("id = ?", id).First(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, payment.ErrMethodNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *PaymentMethodRepository) ListByUser(ctx context.Context, userID string) ([]*payment.SavedMethod, error) {
+	var methods []*payment.SavedMethod
+	err := conn(ctx, r.db).Where("user_id = ?", userID).
+		Order("is_default DESC, created_at DESC").
+		Find(&methods).Error
+	return methods, err
+}
+
+func (r *PaymentMethodRepository) Delete(ctx context.Context, id string) error {
+	result := conn(ctx, r.db).Where("id = ?", id).Delete(&payment.SavedMethod{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return payment.ErrMethodNotFound
+	}
+	return nil
+}
+
+// SetDefault moves the flag in one transaction, so a user never has two
+// defaults or, once they have a method, none
+func (r *PaymentMethodRepository) SetDefault(ctx context.Context, userID, id string) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&payment.SavedMethod{}).
+			Where("user_id = ? AND is_default = ?", userID, true).
+			Updates(map[string]interface{}{"is_default": false, "updated_at": now}).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&payment.SavedMethod{}).
+			Where("id = ? AND user_id = ?", id, userID).
+			Updates(map[string]interface{}{"is_default": true, "updated_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return payment.ErrMethodNotFound
+		}
+		return nil
+	})
+}
@@ -1,7 +1,11 @@
 package database
 
 import (
+	"context"
+	"time"
+
 	"online-shop/internal/domain/payment"
+	"online-shop/pkg/fieldcrypt"
 
 	"gorm.io/gorm"
 )
@@ -14,47 +18,119 @@ func NewPaymentRepository(db *gorm.DB) payment.Repository {
 	return &PaymentRepository{db: db}
 }
 
-func (r *PaymentRepository) Create(p *payment.Payment) error {
-	return r.db.Create(p).Error
+// NewReceiptRepository lists receipts from the payments table
+func NewReceiptRepository(db *gorm.DB) payment.ReceiptRepository {
+	return &PaymentRepository{db: db}
+}
+
+func (r *PaymentRepository) Create(ctx context.Context, p *payment.Payment) error {
+	p.ExternalIDIndex = fieldcrypt.Index(p.ExternalID)
+	return conn(ctx, r.db).Create(p).Error
 }
 
-func (r *PaymentRepository) GetByID(id string) (*payment.Payment, error) {
+func (r *PaymentRepository) GetByID(ctx context.Context, id string) (*payment.Payment, error) {
 	var p payment.Payment
-	err := r.db.Where("id = ?", id).First(&p).Error
+	err := conn(ctx, r.db).Where("id = ?", id).First(&p).Error
 	if err != nil {
 		return nil, err
 	}
 	return &p, nil
 }
 
-func (r *PaymentRepository) GetByOrderID(orderID string) (*payment.Payment, error) {
+func (r *PaymentRepository) GetByOrderID(ctx context.Context, orderID string) (*payment.Payment, error) {
 	var p payment.Payment
-	err := r.db.Where("order_id = ?", orderID).First(&p).Error
+	err := conn(ctx, r.db).Where("order_id = ?", orderID).First(&p).Error
 	if err != nil {
 		return nil, err
 	}
 	return &p, nil
 }
 
-func (r *PaymentRepository) GetByExternalID(externalID string) (*payment.Payment, error) {
+func (r *PaymentRepository) ListByOrderID(ctx context.Context, orderID string) ([]*payment.Payment, error) {
+	var payments []*payment.Payment
+	err := conn(ctx, r.db).Where("order_id = ?", orderID).
+		Order("installment ASC, created_at ASC").
+		Find(&payments).Error
+	return payments, err
+}
+
+func (r *PaymentRepository) GetByExternalID(ctx context.Context, externalID string) (*payment.Payment, error) {
+	// Rows written before encryption was enabled still hold the plain ID
+	query := conn(ctx, r.db).Where("external_id = ?", externalID)
+	if index := fieldcrypt.Index(externalID); index != "" {
+		query = conn(ctx, r.db).Where("external_id_index = ? OR external_id = ?", index, externalID)
+	}
+
 	var p payment.Payment
-	err := r.db.Where("external_id = ?", externalID).First(&p).Error
+	err := query.First(&p).Error
 	if err != nil {
 		return nil, err
 	}
 	return &p, nil
 }
 
-func (r *PaymentRepository) Update(p *payment.Payment) error {
-	return r.db.Save(p).Error
+func (r *PaymentRepository) ListCreatedBetween(ctx context.Context, from, to time.Time) ([]*payment.Payment, error) {
+	var payments []*payment.Payment
+	err := conn(ctx, r.db).Where("created_at >= ? AND created_at < ?", from, to).
+		Order("created_at ASC").
+		Find(&payments).Error
+	return payments, err
+}
+
+func (r *PaymentRepository) Update(ctx context.Context, p *payment.Payment) error {
+	p.ExternalIDIndex = fieldcrypt.Index(p.ExternalID)
+	return conn(ctx, r.db).Save(p).Error
 }
 
-func (r *PaymentRepository) UpdateStatus(paymentID string, status payment.Status, transactionID string) error {
+func (r *PaymentRepository) UpdateStatus(ctx context.Context, paymentID string, status payment.Status, transactionID string) error {
 	updates := map[string]interface{}{
 		"status": status,
 	}
 	if transactionID != "" {
 		updates["transaction_id"] = transactionID
 	}
-	return r.db.Model(&payment.Payment{}).Where("id = ?", paymentID).Updates(updates).Error
-}
\ No newline at end of file
+	if status == payment.StatusPaid {
+		updates["processed_at"] = time.Now()
+	}
+	return conn(ctx, r.db).Model(&payment.Payment{}).Where("id = ?", paymentID).Updates(updates).Error
+}
+func (r *PaymentRepository) ListReceipts(ctx context.Context, userID string, limit, offset int) ([]payment.Receipt, int64, error) {
+	query := conn(ctx, r.db).Model(&payment.Payment{}).
+		Where("user_id = ? AND status IN ?", userID, []payment.Status{payment.StatusPaid, payment.StatusRefunded})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var payments []*payment.Payment
+	err := query.Order("COALESCE(processed_at, updated_at) DESC").Order("id ASC").
+		Limit(limit).Offset(offset).Find(&payments).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	orderIDs := make([]string, 0, len(payments))
+	for _, p := range payments {
+		orderIDs = append(orderIDs, p.OrderID)
+	}
+	var orders []struct {
+		ID     string
+		Number string
+	}
+	if len(orderIDs) > 0 {
+		if err := conn(ctx, r.db).Table("orders").Select("id, number").Where("id IN ?", orderIDs).Find(&orders).Error; err != nil {
+			return nil, 0, err
+		}
+	}
+	numbers := make(map[string]string, len(orders))
+	for _, o := range orders {
+		numbers[o.ID] = o.Number
+	}
+
+	receipts := make([]payment.Receipt, len(payments))
+	for i, p := range payments {
+		receipts[i] = payment.NewReceipt(p, numbers[p.OrderID])
+	}
+	return receipts, total, nil
+}
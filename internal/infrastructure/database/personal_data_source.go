@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/domain/analytics"
+	"online-shop/internal/domain/export"
+	"online-shop/internal/domain/oauth"
+	"online-shop/internal/domain/user"
+
+	"gorm.io/gorm"
+)
+
+// PersonalDataSource compiles a user's data from the primary tables and, when
+// an event store is configured, their analytics events
+type PersonalDataSource struct {
+	db     *gorm.DB
+	events analytics.EventReader
+}
+
+// NewPersonalDataSource creates a personal data source; events may be nil
+// when the analytics event store is disabled.
+func NewPersonalDataSource(db *gorm.DB, events analytics.EventReader) export.PersonalDataSource {
+	return &PersonalDataSource{db: db, events: events}
+}
+
+func (s *PersonalDataSource) LoadPersonalData(ctx context.Context, userID string) (*export.PersonalData, error) {
+	db := conn(ctx, s.db)
+
+	var profile user.User
+	if err := db.Where("id = ?", userID).First(&profile).Error; err != nil {
+		return nil, err
+	}
+	data := &export.PersonalData{
+		UserID:      userID,
+		GeneratedAt: time.Now(),
+		Profile:     &profile,
+
+		AnalyticsEvents: []analytics.Event{},
+	}
+
+	var err error
+	if data.Addresses, err = userRows(db, "user_addresses", userID); err != nil {
+		return nil, err
+	}
+	if data.Reviews, err = userRows(db, "reviews", userID); err != nil {
+		return nil, err
+	}
+
+	err = db.Preload("Items").Preload("Shipments").
+		Where("user_id = ?", userID).
+		Order("created_at").
+		Find(&data.Orders).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var accounts []*oauth.Account
+	if err := db.Where("user_id = ?", userID).Order("created_at").Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+	data.LinkedAccounts = accounts
+
+	if s.events != nil {
+		if data.AnalyticsEvents, err = s.events.ListByUser(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+// userRows reads a table that has no domain model. Tables that only exist in
+// the SQL schema may be missing, in which case there is nothing to export.
+func userRows(db *gorm.DB, table, userID string) ([]map[string]interface{}, error) {
+	if !db.Migrator().HasTable(table) {
+		return []map[string]interface{}{}, nil
+	}
+
+	var rows []map[string]interface{}
+	err := db.Table(table).Where("user_id = ?", userID).Order("created_at").Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"online-shop/internal/domain/backup"
+	"online-shop/pkg/config"
+)
+
+// PGDumper runs pg_dump in custom format, which is compressed and can be
+// restored selectively with pg_restore.
+type PGDumper struct {
+	binary string
+	cfg    *config.DatabaseConfig
+}
+
+func NewPGDumper(cfg *config.DatabaseConfig, binary string) backup.Dumper {
+	if binary == "" {
+		binary = "pg_dump"
+	}
+	return &PGDumper{binary: binary, cfg: cfg}
+}
+
+func (d *PGDumper) Extension() string {
+	return "dump"
+}
+
+func (d *PGDumper) Dump(ctx context.Context) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, d.binary,
+		"--format=custom",
+		"--no-owner",
+		"--host", d.cfg.Host,
+		"--port", d.cfg.Port,
+		"--username", d.cfg.User,
+		"--dbname", d.cfg.DBName,
+	)
+	// The password is passed in the environment so it never shows up in the
+	// process list
+	cmd.Env = append(os.Environ(), "PGPASSWORD="+d.cfg.Password, "PGSSLMODE="+d.cfg.SSLMode)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("pg_dump failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
@@ -2,10 +2,46 @@ package database
 
 import (
 	"fmt"
+	"online-shop/internal/domain/audit"
+	"online-shop/internal/domain/backup"
+	"online-shop/internal/domain/badge"
+	"online-shop/internal/domain/banner"
+	"online-shop/internal/domain/campaign"
+	"online-shop/internal/domain/cms"
+	"online-shop/internal/domain/cod"
+	"online-shop/internal/domain/commission"
+	"online-shop/internal/domain/draftorder"
+	"online-shop/internal/domain/emaildelivery"
+	"online-shop/internal/domain/emailtemplate"
+	"online-shop/internal/domain/experiment"
+	"online-shop/internal/domain/export"
+	"online-shop/internal/domain/flashsale"
+	"online-shop/internal/domain/forecast"
+	"online-shop/internal/domain/fraud"
+	"online-shop/internal/domain/idempotency"
+	"online-shop/internal/domain/impersonation"
+	"online-shop/internal/domain/job"
+	"online-shop/internal/domain/merchandising"
+	"online-shop/internal/domain/moderation"
+	"online-shop/internal/domain/notification"
+	"online-shop/internal/domain/oauth"
 	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/organization"
 	"online-shop/internal/domain/payment"
+	"online-shop/internal/domain/pricealert"
 	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/productversion"
+	"online-shop/internal/domain/quote"
+	"online-shop/internal/domain/recommendation"
+	"online-shop/internal/domain/reconciliation"
+	"online-shop/internal/domain/searchsync"
+	"online-shop/internal/domain/shipping"
+	"online-shop/internal/domain/stockalert"
+	"online-shop/internal/domain/storefront"
 	"online-shop/internal/domain/user"
+	"online-shop/internal/domain/warehouse"
+	"online-shop/internal/domain/webhook"
+	"online-shop/internal/domain/whatsapp"
 	"online-shop/pkg/config"
 
 	"gorm.io/driver/postgres"
@@ -25,18 +61,95 @@ func NewDatabase(cfg *config.DatabaseConfig) (*Database, error) {
 		return nil, err
 	}
 
+	if err := db.Use(NewAuditPlugin(AuditedTables...)); err != nil {
+		return nil, err
+	}
+	if err := db.Use(NewVersionPlugin()); err != nil {
+		return nil, err
+	}
+
 	return &Database{DB: db}, nil
 }
 
 func (d *Database) Migrate() error {
-	return d.DB.AutoMigrate(
+	err := d.DB.AutoMigrate(
 		&user.User{},
 		&product.Category{},
 		&product.Product{},
+		&product.AttributeDefinition{},
+		&product.SlugRedirect{},
 		&order.Order{},
 		&order.OrderItem{},
 		&payment.Payment{},
+		&payment.SavedMethod{},
+		&commission.Rule{},
+		&warehouse.Warehouse{},
+		&warehouse.Stock{},
+		&order.Shipment{},
+		&idempotency.Record{},
+		&recommendation.Pair{},
+		&banner.Banner{},
+		&export.Export{},
+		&audit.Entry{},
+		&backup.Backup{},
+		&oauth.Account{},
+		&experiment.Experiment{},
+		&emailtemplate.Template{},
+		&emaildelivery.Suppression{},
+		&emaildelivery.DeadLetter{},
+		&whatsapp.Template{},
+		&whatsapp.Message{},
+		&cms.Block{},
+		&cms.Page{},
+		&cms.Asset{},
+		&flashsale.Sale{},
+		&flashsale.Item{},
+		&reconciliation.Run{},
+		&reconciliation.Mismatch{},
+		&fraud.Assessment{},
+		&cod.Collection{},
+		&cod.Remittance{},
+		&cod.CustomerLimit{},
+		&stockalert.Subscription{},
+		&product.PriceChange{},
+		&pricealert.Watch{},
+		&webhook.Endpoint{},
+		&webhook.Delivery{},
+		&webhook.Attempt{},
+		&impersonation.Grant{},
+		&job.Job{},
+		&searchsync.Change{},
+		&storefront.Settings{},
+		&shipping.Zone{},
+		&quote.Quote{},
+		&draftorder.Draft{},
+		&organization.Organization{},
+		&organization.Member{},
+		&organization.Approval{},
+		&organization.Invoice{},
+		&product.Translation{},
+		&product.CategoryTranslation{},
+		&merchandising.Rule{},
+		&badge.Definition{},
+		&badge.Assignment{},
+		&forecast.Suggestion{},
+		&moderation.Submission{},
+		&productversion.Version{},
+		&campaign.Campaign{},
+		&campaign.Event{},
+		&notification.Preference{},
 	)
+	if err != nil {
+		return err
+	}
+
+	// Products were inactive before they could be archived
+	err = d.DB.Model(&product.Product{}).Where("status = ?", "inactive").Update("status", product.StatusArchived).Error
+	if err != nil {
+		return err
+	}
+
+	return d.DB.Exec("CREATE SEQUENCE IF NOT EXISTS " + orderNumberSequence).Error
 }
 
 func (d *Database) Close() error {
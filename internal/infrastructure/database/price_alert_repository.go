@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"online-shop/internal/domain/pricealert"
+
+	"gorm.io/gorm"
+)
+
+type PriceAlertRepository struct {
+	db *gorm.DB
+}
+
+func NewPriceAlertRepository(db *gorm.DB) pricealert.Repository {
+	return &PriceAlertRepository{db: db}
+}
+
+func (r *PriceAlertRepository) Create(ctx context.Context, w *pricealert.Watch) error {
+	return conn(ctx, r.db).Create(w).Error
+}
+
+func (r *PriceAlertRepository) GetByID(ctx context.Context, id string) (*pricealert.Watch, error) {
+	return r.first(conn(ctx, r.db).Where("id = ?", id))
+}
+
+func (r *PriceAlertRepository) Update(ctx context.Context, w *pricealert.Watch) error {
+	return conn(ctx, r.db).Save(w).Error
+}
+
+func (r *PriceAlertRepository) FindActive(ctx context.Context, userID, productID string) (*pricealert.Watch, error) {
+	return r.first(conn(ctx, r.db).Where("user_id = ? AND product_id = ? AND status = ?", userID, productID, pricealert.StatusActive))
+}
+
+func (r *PriceAlertRepository) first(query *gorm.DB) (*pricealert.Watch, error) {
+	var w pricealert.Watch
+	err := query.First(&w).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, pricealert.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (r *PriceAlertRepository) ListByUser(ctx context.Context, userID string, limit, offset int) ([]*pricealert.Watch, error) {
+	var watches []*pricealert.Watch
+	err := conn(ctx, r.db).Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(limit).Offset(offset).
+		Find(&watches).Error
+	return watches, err
+}
+
+func (r *PriceAlertRepository) ListTriggered(ctx context.Context, productID string, price float64, limit int) ([]*pricealert.Watch, error) {
+	var watches []*pricealert.Watch
+	err := conn(ctx, r.db).Where("product_id = ? AND status = ? AND target_price >= ?", productID, pricealert.StatusActive, price).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&watches).Error
+	return watches, err
+}
+
+func (r *PriceAlertRepository) ExpireBefore(ctx context.Context, at time.Time) (int64, error) {
+	result := conn(ctx, r.db).Model(&pricealert.Watch{}).
+		Where("status = ? AND expires_at < ?", pricealert.StatusActive, at).
+		Updates(map[string]interface{}{"status": pricealert.StatusExpired, "updated_at": time.Now()})
+	return result.RowsAffected, result.Error
+}
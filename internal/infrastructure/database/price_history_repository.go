@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/domain/product"
+
+	"gorm.io/gorm"
+)
+
+type PriceHistoryRepository struct {
+	db *gorm.DB
+}
+
+func NewPriceHistoryRepository(db *gorm.DB) product.PriceHistoryRepository {
+	return &PriceHistoryRepository{db: db}
+}
+
+func (r *PriceHistoryRepository) Record(ctx context.Context, c *product.PriceChange) error {
+	return conn(ctx, r.db).Create(c).Error
+}
+
+func (r *PriceHistoryRepository) ListByProduct(ctx context.Context, productID string, limit int) ([]*product.PriceChange, error) {
+	var changes []*product.PriceChange
+	err := conn(ctx, r.db).Where("product_id = ?", productID).
+		Order("changed_at DESC").
+		Limit(limit).
+		Find(&changes).Error
+	return changes, err
+}
+
+func (r *PriceHistoryRepository) ListUnprocessedDrops(ctx context.Context, limit int) ([]*product.PriceChange, error) {
+	var changes []*product.PriceChange
+	err := conn(ctx, r.db).Where("processed_at IS NULL AND new_price < old_price").
+		Order("changed_at ASC").
+		Limit(limit).
+		Find(&changes).Error
+	return changes, err
+}
+
+func (r *PriceHistoryRepository) MarkProcessed(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return conn(ctx, r.db).Model(&product.PriceChange{}).
+		Where("id IN ?", ids).
+		Update("processed_at", time.Now()).Error
+}
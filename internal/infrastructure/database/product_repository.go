@@ -1,9 +1,16 @@
 package database
 
 import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
 	"online-shop/internal/domain/product"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ProductRepository struct {
@@ -14,74 +21,282 @@ func NewProductRepository(db *gorm.DB) product.Repository {
 	return &ProductRepository{db: db}
 }
 
-func (r *ProductRepository) Create(p *product.Product) error {
-	return r.db.Create(p).Error
+// NewBundleRepository finds bundles in the products table.
+func NewBundleRepository(db *gorm.DB) product.BundleRepository {
+	return &ProductRepository{db: db}
+}
+
+func NewScheduleRepository(db *gorm.DB) product.ScheduleRepository {
+	return &ProductRepository{db: db}
+}
+
+func (r *ProductRepository) Create(ctx context.Context, p *product.Product) error {
+	return conn(ctx, r.db).Create(p).Error
 }
 
-func (r *ProductRepository) GetByID(id string) (*product.Product, error) {
+func (r *ProductRepository) GetByID(ctx context.Context, id string) (*product.Product, error) {
 	var p product.Product
-	err := r.db.Preload("Category").Where("id = ?", id).First(&p).Error
+	err := conn(ctx, r.db).Preload("Category").Where("id = ?", id).First(&p).Error
 	if err != nil {
 		return nil, err
 	}
 	return &p, nil
 }
 
-func (r *ProductRepository) Update(p *product.Product) error {
-	return r.db.Save(p).Error
+func (r *ProductRepository) Update(ctx context.Context, p *product.Product) error {
+	return conn(ctx, r.db).Save(p).Error
 }
 
-func (r *ProductRepository) Delete(id string) error {
-	return r.db.Model(&product.Product{}).Where("id = ?", id).Update("status", product.StatusDeleted).Error
+func (r *ProductRepository) Delete(ctx context.Context, id string) error {
+	return conn(ctx, r.db).Model(&product.Product{}).Where("id = ?", id).Update("status", product.StatusDeleted).Error
 }
 
-func (r *ProductRepository) List(filter product.SearchFilter) ([]*product.Product, error) {
+func (r *ProductRepository) List(ctx context.Context, filter product.SearchFilter) ([]*product.Product, error) {
 	var products []*product.Product
-	query := r.db.Preload("Category")
+	db := conn(ctx, r.db)
+	query := applySearchFilter(db.Preload("Category"), filter)
+	if len(filter.Ranking.Pinned) > 0 {
+		// Pinned products are listed whatever the rest of the search, as
+		// long as they are active
+		matching := applySearchFilter(db.Session(&gorm.Session{NewDB: true}), filter)
+		query = db.Preload("Category").Where(matching).
+			Or("products.id IN ? AND products.status = ?", filter.Ranking.Pinned, product.StatusActive)
+	}
+	if !filter.Ranking.Empty() {
+		query = query.Clauses(rankingOrder(filter.Ranking))
+	}
 
-	if filter.Query != "" {
-		query = query.Where("name ILIKE ? OR description ILIKE ?", "%"+filter.Query+"%", "%"+filter.Query+"%")
+	err := query.Limit(filter.Limit).Offset(filter.Offset).Find(&products).Error
+	return products, err
+}
+
+// rankingOrder puts the pinned products first, in their order, then the
+// others by the product of the factors of the boosts they match, newest
+// first among equals.
+func rankingOrder(ranking product.Ranking) clause.OrderBy {
+	var terms []string
+	var vars []interface{}
+	if len(ranking.Pinned) > 0 {
+		pinned := "CASE products.id"
+		for i, productID := range ranking.Pinned {
+			pinned += fmt.Sprintf(" WHEN ? THEN %d", i)
+			vars = append(vars, productID)
+		}
+		terms = append(terms, pinned+fmt.Sprintf(" ELSE %d END", len(ranking.Pinned)))
+	}
+	if len(ranking.Boosts) > 0 {
+		factors := make([]string, 0, len(ranking.Boosts))
+		for _, b := range ranking.Boosts {
+			condition, args := attributeCondition(b.Filter)
+			factors = append(factors, "CASE WHEN "+condition+" THEN ?::float8 ELSE 1 END")
+			vars = append(append(vars, args...), b.Factor)
+		}
+		terms = append(terms, strings.Join(factors, " * ")+" DESC")
+	}
+	terms = append(terms, "products.created_at DESC")
+	return clause.OrderBy{Expression: clause.Expr{SQL: strings.Join(terms, ", "), Vars: vars, WithoutParentheses: true}}
+}
+
+func (r *ProductRepository) ListAfter(ctx context.Context, filter product.SearchFilter, after *product.Position, limit int) ([]*product.Product, error) {
+	var products []*product.Product
+	query := applySearchFilter(conn(ctx, r.db).Preload("Category"), filter)
+
+	if after != nil {
+		query = query.Where("(products.created_at < ?) OR (products.created_at = ? AND products.id < ?)", after.CreatedAt, after.CreatedAt, after.ID)
+	}
+
+	err := query.Order("products.created_at DESC").Order("products.id DESC").Limit(limit).Find(&products).Error
+	return products, err
+}
+
+func applySearchFilter(query *gorm.DB, filter product.SearchFilter) *gorm.DB {
+	if filter.Query != "" && filter.Locale != "" {
+		pattern := "%" + filter.Query + "%"
+		query = query.Where("products.name ILIKE ? OR products.description ILIKE ? OR EXISTS (SELECT 1 FROM product_translations pt WHERE pt.product_id = products.id AND pt.locale = ? AND (pt.name ILIKE ? OR pt.description ILIKE ?))",
+			pattern, pattern, filter.Locale, pattern, pattern)
+	} else if filter.Query != "" {
+		query = query.Where("products.name ILIKE ? OR products.description ILIKE ?", "%"+filter.Query+"%", "%"+filter.Query+"%")
 	}
 
 	if filter.CategoryID != "" {
-		query = query.Where("category_id = ?", filter.CategoryID)
+		query = query.Where("products.category_id = ?", filter.CategoryID)
 	}
 
 	if filter.MinPrice > 0 {
-		query = query.Where("price >= ?", filter.MinPrice)
+		query = query.Where("products.price >= ?", filter.MinPrice)
 	}
 
 	if filter.MaxPrice > 0 {
-		query = query.Where("price <= ?", filter.MaxPrice)
+		query = query.Where("products.price <= ?", filter.MaxPrice)
 	}
 
 	if filter.MerchantID != "" {
-		query = query.Where("merchant_id = ?", filter.MerchantID)
+		query = query.Where("products.merchant_id = ?", filter.MerchantID)
 	}
 
 	if filter.Status != "" {
-		query = query.Where("status = ?", filter.Status)
+		query = query.Where("products.status = ?", filter.Status)
 	}
 
-	err := query.Limit(filter.Limit).Offset(filter.Offset).Find(&products).Error
-	return products, err
+	for _, f := range filter.Attributes {
+		condition, args := attributeCondition(f)
+		query = query.Where(condition, args...)
+	}
+
+	return query
+}
+
+// productAttributes expands a product's attributes into rows. Products saved
+// without attributes hold a JSON null rather than an empty array.
+const productAttributes = "jsonb_array_elements(CASE jsonb_typeof(products.attributes) WHEN 'array' THEN products.attributes ELSE '[]'::jsonb END)"
+
+// attributeCondition matches products with an attribute satisfying the
+// filter. The CASE keeps text values from being cast to numeric.
+func attributeCondition(f product.AttributeFilter) (string, []interface{}) {
+	conds := []string{"pa->>'key' = ?"}
+	args := []interface{}{f.Key}
+	if len(f.Values) > 0 {
+		values := make([]string, len(f.Values))
+		for i, v := range f.Values {
+			values[i] = strings.ToLower(v)
+		}
+		conds = append(conds, "lower(pa->>'value') IN ?")
+		args = append(args, values)
+	}
+	number := "CASE WHEN pa->>'type' = 'number' THEN (pa->>'value')::numeric END"
+	if f.Min != nil {
+		conds = append(conds, number+" >= ?")
+		args = append(args, *f.Min)
+	}
+	if f.Max != nil {
+		conds = append(conds, number+" <= ?")
+		args = append(args, *f.Max)
+	}
+	return "EXISTS (SELECT 1 FROM " + productAttributes + " pa WHERE " + strings.Join(conds, " AND ") + ")", args
 }
 
-func (r *ProductRepository) Search(query string, limit, offset int) ([]*product.Product, error) {
+func (r *ProductRepository) Search(ctx context.Context, query string, limit, offset int) ([]*product.Product, error) {
 	var products []*product.Product
-	err := r.db.Preload("Category").
+	err := conn(ctx, r.db).Preload("Category").
 		Where("name ILIKE ? OR description ILIKE ?", "%"+query+"%", "%"+query+"%").
 		Where("status = ?", product.StatusActive).
 		Limit(limit).Offset(offset).Find(&products).Error
 	return products, err
 }
 
-func (r *ProductRepository) UpdateStock(productID string, quantity int) error {
-	return r.db.Model(&product.Product{}).
+func (r *ProductRepository) UpdateStock(ctx context.Context, productID string, quantity int) error {
+	return conn(ctx, r.db).Model(&product.Product{}).
 		Where("id = ?", productID).
 		Update("stock", gorm.Expr("stock + ?", quantity)).Error
 }
 
+func (r *ProductRepository) GetBySlug(ctx context.Context, slug string) (*product.Product, error) {
+	var p product.Product
+	err := conn(ctx, r.db).Preload("Category").Where("slug = ?", slug).First(&p).Error
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *ProductRepository) SlugExists(ctx context.Context, slug string) (bool, error) {
+	var count int64
+	err := conn(ctx, r.db).Model(&product.Product{}).Where("slug = ?", slug).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *ProductRepository) GetByIDs(ctx context.Context, ids []string) ([]*product.Product, error) {
+	var products []*product.Product
+	if len(ids) == 0 {
+		return products, nil
+	}
+	err := conn(ctx, r.db).Preload("Category").Where("id IN ?", ids).Find(&products).Error
+	return products, err
+}
+
+func (r *ProductRepository) ListFeatured(ctx context.Context, at time.Time, limit int) ([]*product.Product, error) {
+	var products []*product.Product
+	err := conn(ctx, r.db).Preload("Category").
+		Where("featured_enabled = ? AND status = ?", true, product.StatusActive).
+		Where("featured_from IS NULL OR featured_from <= ?", at).
+		Where("featured_until IS NULL OR featured_until > ?", at).
+		Order("featured_position ASC").
+		Order("updated_at DESC").
+		Limit(limit).Find(&products).Error
+	return products, err
+}
+
+func (r *ProductRepository) ListDueDrafts(ctx context.Context, at time.Time, limit int) ([]*product.Product, error) {
+	var products []*product.Product
+	err := conn(ctx, r.db).
+		Where("status = ? AND publish_at <= ?", product.StatusDraft, at).
+		Order("publish_at ASC").
+		Limit(limit).Find(&products).Error
+	return products, err
+}
+
+// productComponents expands a bundle's components into rows, as
+// productAttributes does its attributes.
+const productComponents = "jsonb_array_elements(CASE jsonb_typeof(products.components) WHEN 'array' THEN products.components ELSE '[]'::jsonb END)"
+
+func (r *ProductRepository) ListBundlesOf(ctx context.Context, productIDs []string) ([]*product.Product, error) {
+	var bundles []*product.Product
+	if len(productIDs) == 0 {
+		return bundles, nil
+	}
+	err := conn(ctx, r.db).
+		Where("status <> ?", product.StatusDeleted).
+		Where("EXISTS (SELECT 1 FROM "+productComponents+" pc WHERE pc->>'product_id' IN ?)", productIDs).
+		Find(&bundles).Error
+	return bundles, err
+}
+
+type facetRow struct {
+	Key   string
+	Label string
+	Type  product.AttributeType
+	Unit  string
+	Value string
+	Count int64
+}
+
+func (r *ProductRepository) AttributeFacets(ctx context.Context, filter product.SearchFilter) ([]product.Facet, error) {
+	var rows []facetRow
+	query := applySearchFilter(conn(ctx, r.db).Table("products, " + productAttributes + " a"), filter)
+	err := query.
+		Select("a->>'key' AS key, MAX(a->>'label') AS label, MAX(a->>'type') AS type, MAX(a->>'unit') AS unit, a->>'value' AS value, COUNT(*) AS count").
+		Where("a->>'type' <> ?", product.AttributeText).
+		Group("a->>'key'").Group("a->>'value'").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var facets []product.Facet
+	index := map[string]int{}
+	for _, row := range rows {
+		i, ok := index[row.Key]
+		if !ok {
+			i = len(facets)
+			index[row.Key] = i
+			facets = append(facets, product.Facet{Key: row.Key, Label: row.Label, Type: row.Type, Unit: row.Unit})
+		}
+		facets[i].Values = append(facets[i].Values, product.FacetValue{Value: row.Value, Count: row.Count})
+	}
+
+	sort.Slice(facets, func(i, j int) bool { return facets[i].Key < facets[j].Key })
+	for _, f := range facets {
+		values := f.Values
+		sort.Slice(values, func(i, j int) bool {
+			if values[i].Count != values[j].Count {
+				return values[i].Count > values[j].Count
+			}
+			return values[i].Value < values[j].Value
+		})
+	}
+	return facets, nil
+}
+
 type CategoryRepository struct {
 	db *gorm.DB
 }
@@ -90,29 +305,101 @@ func NewCategoryRepository(db *gorm.DB) product.CategoryRepository {
 	return &CategoryRepository{db: db}
 }
 
-func (r *CategoryRepository) Create(c *product.Category) error {
-	return r.db.Create(c).Error
+func (r *CategoryRepository) Create(ctx context.Context, c *product.Category) error {
+	return conn(ctx, r.db).Create(c).Error
 }
 
-func (r *CategoryRepository) GetByID(id string) (*product.Category, error) {
+func (r *CategoryRepository) GetByID(ctx context.Context, id string) (*product.Category, error) {
 	var c product.Category
-	err := r.db.Preload("Parent").Where("id = ?", id).First(&c).Error
+	err := conn(ctx, r.db).Preload("Parent").Preload("Attributes", orderByPosition).Where("id = ?", id).First(&c).Error
 	if err != nil {
 		return nil, err
 	}
 	return &c, nil
 }
 
-func (r *CategoryRepository) Update(c *product.Category) error {
-	return r.db.Save(c).Error
+// Update leaves the attribute template alone; it is changed through
+// AttributeTemplateRepository.Replace.
+func (r *CategoryRepository) Update(ctx context.Context, c *product.Category) error {
+	return conn(ctx, r.db).Omit("Attributes").Save(c).Error
 }
 
-func (r *CategoryRepository) Delete(id string) error {
-	return r.db.Where("id = ?", id).Delete(&product.Category{}).Error
+func (r *CategoryRepository) Delete(ctx context.Context, id string) error {
+	return conn(ctx, r.db).Where("id = ?", id).Delete(&product.Category{}).Error
 }
 
-func (r *CategoryRepository) List(limit, offset int) ([]*product.Category, error) {
+func (r *CategoryRepository) List(ctx context.Context, limit, offset int) ([]*product.Category, error) {
 	var categories []*product.Category
-	err := r.db.Preload("Parent").Limit(limit).Offset(offset).Find(&categories).Error
+	err := conn(ctx, r.db).Preload("Parent").Limit(limit).Offset(offset).Find(&categories).Error
 	return categories, err
-}
\ No newline at end of file
+}
+
+func (r *CategoryRepository) GetBySlug(ctx context.Context, slug string) (*product.Category, error) {
+	var c product.Category
+	err := conn(ctx, r.db).Preload("Parent").Preload("Attributes", orderByPosition).Where("slug = ?", slug).First(&c).Error
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *CategoryRepository) SlugExists(ctx context.Context, slug string) (bool, error) {
+	var count int64
+	err := conn(ctx, r.db).Model(&product.Category{}).Where("slug = ?", slug).Count(&count).Error
+	return count > 0, err
+}
+
+func orderByPosition(db *gorm.DB) *gorm.DB {
+	return db.Order("position ASC")
+}
+
+type AttributeTemplateRepository struct {
+	db *gorm.DB
+}
+
+func NewAttributeTemplateRepository(db *gorm.DB) product.AttributeTemplateRepository {
+	return &AttributeTemplateRepository{db: db}
+}
+
+func (r *AttributeTemplateRepository) ListByCategory(ctx context.Context, categoryID string) ([]*product.AttributeDefinition, error) {
+	var defs []*product.AttributeDefinition
+	err := conn(ctx, r.db).Where("category_id = ?", categoryID).Order("position ASC").Find(&defs).Error
+	return defs, err
+}
+
+func (r *AttributeTemplateRepository) Replace(ctx context.Context, categoryID string, defs []*product.AttributeDefinition) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("category_id = ?", categoryID).Delete(&product.AttributeDefinition{}).Error; err != nil {
+			return err
+		}
+		if len(defs) == 0 {
+			return nil
+		}
+		return tx.Create(defs).Error
+	})
+}
+
+type SlugRedirectRepository struct {
+	db *gorm.DB
+}
+
+func NewSlugRedirectRepository(db *gorm.DB) product.SlugRedirectRepository {
+	return &SlugRedirectRepository{db: db}
+}
+
+func (r *SlugRedirectRepository) Create(ctx context.Context, redirect *product.SlugRedirect) error {
+	return conn(ctx, r.db).Create(redirect).Error
+}
+
+func (r *SlugRedirectRepository) Find(ctx context.Context, entityType, oldSlug string) (*product.SlugRedirect, error) {
+	var redirect product.SlugRedirect
+	err := conn(ctx, r.db).Where("entity_type = ? AND old_slug = ?", entityType, oldSlug).First(&redirect).Error
+	if err != nil {
+		return nil, err
+	}
+	return &redirect, nil
+}
+
+func (r *SlugRedirectRepository) DeleteBySlug(ctx context.Context, entityType, slug string) error {
+	return conn(ctx, r.db).Where("entity_type = ? AND old_slug = ?", entityType, slug).Delete(&product.SlugRedirect{}).Error
+}
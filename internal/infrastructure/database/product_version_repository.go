@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"online-shop/internal/domain/productversion"
+
+	"gorm.io/gorm"
+)
+
+type ProductVersionRepository struct {
+	db *gorm.DB
+}
+
+func NewProductVersionRepository(db *gorm.DB) productversion.Repository {
+	return &ProductVersionRepository{db: db}
+}
+
+func (r *ProductVersionRepository) Get(ctx context.Context, productID string, number int) (*productversion.Version, error) {
+	return firstProductVersion(conn(ctx, r.db).Where("product_id = ? AND number = ?", productID, number))
+}
+
+func (r *ProductVersionRepository) Latest(ctx context.Context, productID string) (*productversion.Version, error) {
+	return firstProductVersion(conn(ctx, r.db).Where("product_id = ?", productID).Order("number DESC"))
+}
+
+func (r *ProductVersionRepository) List(ctx context.Context, productID string, limit, offset int) ([]*productversion.Version, int64, error) {
+	query := conn(ctx, r.db).Model(&productversion.Version{}).Where("product_id = ?", productID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var versions []*productversion.Version
+	err := query.Order("number DESC").Limit(limit).Offset(offset).Find(&versions).Error
+	return versions, total, err
+}
+
+func firstProductVersion(query *gorm.DB) (*productversion.Version, error) {
+	var v productversion.Version
+	err := query.First(&v).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, productversion.ErrVersionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
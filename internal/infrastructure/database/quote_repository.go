@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"online-shop/internal/domain/quote"
+
+	"gorm.io/gorm"
+)
+
+type QuoteRepository struct {
+	db *gorm.DB
+}
+
+func NewQuoteRepository(db *gorm.DB) quote.Repository {
+	return &QuoteRepository{db: db}
+}
+
+func (r *QuoteRepository) Create(ctx context.Context, q *quote.Quote) error {
+	return conn(ctx, r.db).Create(q).Error
+}
+
+func (r *QuoteRepository) GetByID(ctx context.Context, id string) (*quote.Quote, error) {
+	var q quote.Quote
+	err := conn(ctx, r.db).Where("id = ?", id).First(&q).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, quote.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+func (r *QuoteRepository) Update(ctx context.Context, q *quote.Quote) error {
+	return conn(ctx, r.db).Save(q).Error
+}
+
+func (r *QuoteRepository) List(ctx context.Context, filter quote.Filter, limit, offset int) ([]*quote.Quote, error) {
+	query := conn(ctx, r.db)
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.MerchantID != "" {
+		query = query.Where("merchant_id = ?", filter.MerchantID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var quotes []*quote.Quote
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&quotes).Error
+	return quotes, err
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/recommendation"
+
+	"gorm.io/gorm"
+)
+
+type RecommendationRepository struct {
+	db *gorm.DB
+}
+
+func NewRecommendationRepository(db *gorm.DB) recommendation.Repository {
+	return &RecommendationRepository{db: db}
+}
+
+func (r *RecommendationRepository) Baskets(ctx context.Context, since time.Time) ([][]string, error) {
+	var rows []struct {
+		OrderID   string
+		ProductID string
+	}
+	err := conn(ctx, r.db).Table("order_items").
+		Select("DISTINCT order_items.order_id, order_items.product_id").
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("orders.created_at >= ? AND orders.status NOT IN ?", since, []order.Status{order.StatusCancelled, order.StatusRefunded}).
+		Order("order_items.order_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var baskets [][]string
+	current := ""
+	for _, row := range rows {
+		if row.OrderID != current {
+			baskets = append(baskets, nil)
+			current = row.OrderID
+		}
+		baskets[len(baskets)-1] = append(baskets[len(baskets)-1], row.ProductID)
+	}
+	return baskets, nil
+}
+
+func (r *RecommendationRepository) ReplacePairs(ctx context.Context, pairs []*recommendation.Pair) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&recommendation.Pair{}).Error; err != nil {
+			return err
+		}
+		if len(pairs) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(pairs, 500).Error
+	})
+}
+
+func (r *RecommendationRepository) BoughtTogether(ctx context.Context, productID string, limit int) ([]*recommendation.Pair, error) {
+	var pairs []*recommendation.Pair
+	err := conn(ctx, r.db).Where("product_id = ?", productID).
+		Order("count DESC").
+		Limit(limit).
+		Find(&pairs).Error
+	return pairs, err
+}
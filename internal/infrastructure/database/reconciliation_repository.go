@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"online-shop/internal/domain/reconciliation"
+
+	"gorm.io/gorm"
+)
+
+type ReconciliationRepository struct {
+	db *gorm.DB
+}
+
+func NewReconciliationRepository(db *gorm.DB) reconciliation.Repository {
+	return &ReconciliationRepository{db: db}
+}
+
+func (r *ReconciliationRepository) Create(ctx context.Context, run *reconciliation.Run) error {
+	return conn(ctx, r.db).Create(run).Error
+}
+
+func (r *ReconciliationRepository) GetByID(ctx context.Context, id string) (*reconciliation.Run, error) {
+	var run reconciliation.Run
+	err := conn(ctx, r.db).Preload("Mismatches", func(db *gorm.DB) *gorm.DB {
+		return db.Order("kind ASC, external_id ASC")
+	}).Where("id = ?", id).First(&run).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, reconciliation.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// Update replaces the run's mismatches in the same transaction, so a rerun
+// never leaves the previous attempt's mismatches behind.
+func (r *ReconciliationRepository) Update(ctx context.Context, run *reconciliation.Run) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Mismatches").Save(run).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("run_id = ?", run.ID).Delete(&reconciliation.Mismatch{}).Error; err != nil {
+			return err
+		}
+		if len(run.Mismatches) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(run.Mismatches, 500).Error
+	})
+}
+
+func (r *ReconciliationRepository) List(ctx context.Context, limit, offset int) ([]*reconciliation.Run, error) {
+	var runs []*reconciliation.Run
+	err := conn(ctx, r.db).Order("period_start DESC, created_at DESC").
+		Limit(limit).Offset(offset).
+		Find(&runs).Error
+	return runs, err
+}
+
+func (r *ReconciliationRepository) ListByStatus(ctx context.Context, status reconciliation.Status) ([]*reconciliation.Run, error) {
+	var runs []*reconciliation.Run
+	err := conn(ctx, r.db).Where("status = ?", status).
+		Order("created_at ASC").
+		Find(&runs).Error
+	return runs, err
+}
+
+func (r *ReconciliationRepository) LatestForPeriod(ctx context.Context, start time.Time) (*reconciliation.Run, error) {
+	var run reconciliation.Run
+	err := conn(ctx, r.db).Where("period_start = ?", start).Order("created_at DESC").First(&run).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
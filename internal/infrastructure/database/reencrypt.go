@@ -0,0 +1,181 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/payment"
+	"online-shop/internal/domain/user"
+	"online-shop/internal/domain/webhook"
+	"online-shop/pkg/fieldcrypt"
+
+	"gorm.io/gorm"
+)
+
+// encryptedModels are the models with columns written through the
+// encrypted serializer
+var encryptedModels = []interface{}{&user.User{}, &order.Order{}, &payment.Payment{}, &payment.SavedMethod{}, &webhook.Endpoint{}}
+
+// encryptedTable is one table's encrypted columns. A column with a blind
+// index has it stored next to it as <column>_index.
+type encryptedTable struct {
+	name    string
+	key     string
+	columns []string
+	indexes map[string]string
+}
+
+// Reencryptor moves encrypted columns onto the active key after a rotation
+// and encrypts values written before encryption was enabled.
+type Reencryptor struct {
+	db        *gorm.DB
+	keyring   *fieldcrypt.Keyring
+	batchSize int
+}
+
+func NewReencryptor(db *gorm.DB, keyring *fieldcrypt.Keyring, batchSize int) *Reencryptor {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	return &Reencryptor{db: db, keyring: keyring, batchSize: batchSize}
+}
+
+// Run rewrites every row whose values are not yet on the active key and
+// returns how many rows it updated per table. Rows are updated by condition
+// on their old values, so a row changed by the application in the meantime
+// is left for the next run rather than overwritten. Running it again after
+// an interruption picks up where it stopped.
+func (r *Reencryptor) Run(ctx context.Context) (map[string]int, error) {
+	tables, err := r.tables()
+	if err != nil {
+		return nil, err
+	}
+
+	updated := make(map[string]int, len(tables))
+	for _, table := range tables {
+		n, err := r.table(ctx, table)
+		updated[table.name] = n
+		if err != nil {
+			return updated, fmt.Errorf("re-encrypt %s: %w", table.name, err)
+		}
+	}
+	return updated, nil
+}
+
+func (r *Reencryptor) tables() ([]encryptedTable, error) {
+	var tables []encryptedTable
+	for _, model := range encryptedModels {
+		stmt := &gorm.Statement{DB: r.db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, err
+		}
+
+		table := encryptedTable{
+			name:    stmt.Schema.Table,
+			key:     stmt.Schema.PrioritizedPrimaryField.DBName,
+			indexes: make(map[string]string),
+		}
+		for _, field := range stmt.Schema.Fields {
+			if _, ok := field.Serializer.(fieldcrypt.Serializer); !ok {
+				continue
+			}
+			table.columns = append(table.columns, field.DBName)
+			if index := stmt.Schema.LookUpField(field.DBName + "_index"); index != nil {
+				table.indexes[field.DBName] = index.DBName
+			}
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+func (r *Reencryptor) table(ctx context.Context, table encryptedTable) (int, error) {
+	columns := append([]string{table.key}, table.columns...)
+	for _, index := range table.indexes {
+		columns = append(columns, index)
+	}
+
+	updated := 0
+	last := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return updated, err
+		}
+
+		var rows []map[string]interface{}
+		err := conn(ctx, r.db).
+			Table(table.name).
+			Select(columns).
+			Where(table.key+" > ?", last).
+			Order(table.key).
+			Limit(r.batchSize).
+			Find(&rows).Error
+		if err != nil {
+			return updated, err
+		}
+
+		for _, row := range rows {
+			last = fmt.Sprint(row[table.key])
+			changes, err := r.rotate(table, row)
+			if err != nil {
+				return updated, fmt.Errorf("row %s: %w", last, err)
+			}
+			if len(changes) == 0 {
+				continue
+			}
+
+			// Updated by table name so the audit plugin does not log the
+			// rewrite and updated_at keeps its meaning
+			query := conn(ctx, r.db).Table(table.name).Where(table.key+" = ?", row[table.key])
+			for column := range changes {
+				if row[column] == nil {
+					query = query.Where(column + " IS NULL")
+				} else {
+					query = query.Where(column+" = ?", row[column])
+				}
+			}
+			result := query.Updates(changes)
+			if result.Error != nil {
+				return updated, result.Error
+			}
+			updated += int(result.RowsAffected)
+		}
+
+		if len(rows) < r.batchSize {
+			return updated, nil
+		}
+	}
+}
+
+// rotate returns the column values of a row that need rewriting
+func (r *Reencryptor) rotate(table encryptedTable, row map[string]interface{}) (map[string]interface{}, error) {
+	changes := make(map[string]interface{})
+	for _, column := range table.columns {
+		value, ok := row[column].(string)
+		if !ok {
+			continue
+		}
+
+		rotated, changed, err := r.keyring.Rotate(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", column, err)
+		}
+		if changed {
+			changes[column] = rotated
+		}
+
+		index, ok := table.indexes[column]
+		if !ok {
+			continue
+		}
+		plaintext, err := r.keyring.Decrypt(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", column, err)
+		}
+		if current, _ := row[index].(string); current != r.keyring.Index(plaintext) {
+			changes[index] = r.keyring.Index(plaintext)
+		}
+	}
+	return changes, nil
+}
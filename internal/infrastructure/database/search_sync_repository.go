@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/domain/searchsync"
+
+	"gorm.io/gorm"
+)
+
+type SearchSyncRepository struct {
+	db *gorm.DB
+}
+
+func NewSearchSyncRepository(db *gorm.DB) searchsync.Repository {
+	return &SearchSyncRepository{db: db}
+}
+
+func (r *SearchSyncRepository) ListDue(ctx context.Context, at time.Time, limit int) ([]*searchsync.Change, error) {
+	var changes []*searchsync.Change
+	err := conn(ctx, r.db).Where("next_attempt_at <= ?", at).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&changes).Error
+	return changes, err
+}
+
+func (r *SearchSyncRepository) Claim(ctx context.Context, c *searchsync.Change, until time.Time) (bool, error) {
+	res := conn(ctx, r.db).Model(&searchsync.Change{}).
+		Where("id = ? AND next_attempt_at = ?", c.ID, c.NextAttemptAt).
+		Update("next_attempt_at", until)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+	c.NextAttemptAt = until
+	return true, nil
+}
+
+func (r *SearchSyncRepository) Delete(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return conn(ctx, r.db).Where("id IN ?", ids).Delete(&searchsync.Change{}).Error
+}
+
+func (r *SearchSyncRepository) Update(ctx context.Context, c *searchsync.Change) error {
+	return conn(ctx, r.db).Save(c).Error
+}
+
+func (r *SearchSyncRepository) Pending(ctx context.Context) (int64, error) {
+	var count int64
+	err := conn(ctx, r.db).Model(&searchsync.Change{}).Count(&count).Error
+	return count, err
+}
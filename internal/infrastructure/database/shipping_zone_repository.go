@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"online-shop/internal/domain/shipping"
+
+	"gorm.io/gorm"
+)
+
+type ShippingZoneRepository struct {
+	db *gorm.DB
+}
+
+func NewShippingZoneRepository(db *gorm.DB) shipping.Repository {
+	return &ShippingZoneRepository{db: db}
+}
+
+func (r *ShippingZoneRepository) Create(ctx context.Context, z *shipping.Zone) error {
+	return conn(ctx, r.db).Create(z).Error
+}
+
+func (r *ShippingZoneRepository) GetByID(ctx context.Context, id string) (*shipping.Zone, error) {
+	var z shipping.Zone
+	err := conn(ctx, r.db).Where("id = ?", id).First(&z).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, shipping.ErrZoneNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &z, nil
+}
+
+func (r *ShippingZoneRepository) Update(ctx context.Context, z *shipping.Zone) error {
+	return conn(ctx, r.db).Save(z).Error
+}
+
+func (r *ShippingZoneRepository) Delete(ctx context.Context, id string) error {
+	result := conn(ctx, r.db).Where("id = ?", id).Delete(&shipping.Zone{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return shipping.ErrZoneNotFound
+	}
+	return nil
+}
+
+func (r *ShippingZoneRepository) List(ctx context.Context, limit, offset int) ([]*shipping.Zone, error) {
+	var zones []*shipping.Zone
+	err := conn(ctx, r.db).Order("created_at ASC").Limit(limit).Offset(offset).Find(&zones).Error
+	return zones, err
+}
+
+func (r *ShippingZoneRepository) ListActive(ctx context.Context) ([]*shipping.Zone, error) {
+	var zones []*shipping.Zone
+	err := conn(ctx, r.db).Where("active = ?", true).Order("created_at ASC, id ASC").Find(&zones).Error
+	return zones, err
+}
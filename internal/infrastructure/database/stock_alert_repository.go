@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"online-shop/internal/domain/stockalert"
+
+	"gorm.io/gorm"
+)
+
+type StockAlertRepository struct {
+	db *gorm.DB
+}
+
+func NewStockAlertRepository(db *gorm.DB) stockalert.Repository {
+	return &StockAlertRepository{db: db}
+}
+
+func (r *StockAlertRepository) Create(ctx context.Context, s *stockalert.Subscription) error {
+	return conn(ctx, r.db).Create(s).Error
+}
+
+func (r *StockAlertRepository) GetByID(ctx context.Context, id string) (*stockalert.Subscription, error) {
+	return r.first(conn(ctx, r.db).Where("id = ?", id))
+}
+
+func (r *StockAlertRepository) Update(ctx context.Context, s *stockalert.Subscription) error {
+	return conn(ctx, r.db).Save(s).Error
+}
+
+func (r *StockAlertRepository) FindActive(ctx context.Context, userID, productID string) (*stockalert.Subscription, error) {
+	return r.first(conn(ctx, r.db).Where("user_id = ? AND product_id = ? AND status = ?", userID, productID, stockalert.StatusActive))
+}
+
+func (r *StockAlertRepository) first(query *gorm.DB) (*stockalert.Subscription, error) {
+	var s stockalert.Subscription
+	err := query.First(&s).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, stockalert.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *StockAlertRepository) ListByUser(ctx context.Context, userID string, limit, offset int) ([]*stockalert.Subscription, error) {
+	var subscriptions []*stockalert.Subscription
+	err := conn(ctx, r.db).Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(limit).Offset(offset).
+		Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+func (r *StockAlertRepository) ListProductIDs(ctx context.Context) ([]string, error) {
+	var ids []string
+	err := conn(ctx, r.db).Model(&stockalert.Subscription{}).
+		Where("status = ?", stockalert.StatusActive).
+		Distinct().Pluck("product_id", &ids).Error
+	return ids, err
+}
+
+func (r *StockAlertRepository) ListActiveByProduct(ctx context.Context, productID string, limit int) ([]*stockalert.Subscription, error) {
+	var subscriptions []*stockalert.Subscription
+	err := conn(ctx, r.db).Where("product_id = ? AND status = ?", productID, stockalert.StatusActive).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+func (r *StockAlertRepository) ExpireBefore(ctx context.Context, at time.Time) (int64, error) {
+	result := conn(ctx, r.db).Model(&stockalert.Subscription{}).
+		Where("status = ? AND expires_at < ?", stockalert.StatusActive, at).
+		Updates(map[string]interface{}{"status": stockalert.StatusExpired, "updated_at": time.Now()})
+	return result.RowsAffected, result.Error
+}
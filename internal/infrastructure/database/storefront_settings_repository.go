@@ -0,0 +1,35 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"online-shop/internal/domain/storefront"
+
+	"gorm.io/gorm"
+)
+
+type StorefrontSettingsRepository struct {
+	db *gorm.DB
+}
+
+func NewStorefrontSettingsRepository(db *gorm.DB) storefront.Repository {
+	return &StorefrontSettingsRepository{db: db}
+}
+
+func (r *StorefrontSettingsRepository) Get(ctx context.Context) (*storefront.Settings, error) {
+	var settings storefront.Settings
+	err := conn(ctx, r.db).Where("id = ?", storefront.SettingsID).First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (r *StorefrontSettingsRepository) Save(ctx context.Context, settings *storefront.Settings) error {
+	settings.ID = storefront.SettingsID
+	return conn(ctx, r.db).Save(settings).Error
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type txKey struct{}
+
+// txState is a transaction begun on the first query made with its context,
+// so commands that never reach the database do not open one
+type txState struct {
+	mu  sync.Mutex
+	ctx context.Context
+	db  *gorm.DB
+	tx  *gorm.DB
+}
+
+func (s *txState) conn(ctx context.Context) *gorm.DB {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tx == nil {
+		// Begun with the command's context rather than the query's, which
+		// may be shorter lived
+		tx := s.db.WithContext(s.ctx).Begin()
+		if tx.Error != nil {
+			return tx
+		}
+		s.tx = tx
+	}
+	return s.tx.WithContext(ctx)
+}
+
+// Transactor runs commands in a transaction every repository joins through
+// the context
+type Transactor struct {
+	db *gorm.DB
+}
+
+func NewTransactor(db *gorm.DB) *Transactor {
+	return &Transactor{db: db}
+}
+
+// Transaction runs fn with a context whose queries share one transaction,
+// committed when fn returns nil and rolled back when it fails or panics.
+// Within a transaction fn runs in the outer one.
+func (t *Transactor) Transaction(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if _, ok := ctx.Value(txKey{}).(*txState); ok {
+		return fn(ctx)
+	}
+
+	state := &txState{ctx: ctx, db: t.db}
+	defer func() {
+		if r := recover(); r != nil {
+			if state.tx != nil {
+				state.tx.Rollback()
+			}
+			panic(r)
+		}
+	}()
+
+	err = fn(context.WithValue(ctx, txKey{}, state))
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.tx == nil {
+		return err
+	}
+	if err != nil {
+		state.tx.Rollback()
+		return err
+	}
+	if err := state.tx.Commit().Error; err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// conn returns db, or the transaction ctx runs in, bound to ctx
+func conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return state.conn(ctx)
+	}
+	return db.WithContext(ctx)
+}
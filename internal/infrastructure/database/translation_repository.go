@@ -0,0 +1,156 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/domain/product"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TranslationRepository struct {
+	db *gorm.DB
+}
+
+func NewTranslationRepository(db *gorm.DB) product.TranslationRepository {
+	return &TranslationRepository{db: db}
+}
+
+// SaveProduct upserts on product and locale. Writes touch the product, so
+// that the search index and caches follow its translations as they follow
+// the product.
+func (r *TranslationRepository) SaveProduct(ctx context.Context, t *product.Translation) error {
+	err := conn(ctx, r.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "product_id"}, {Name: "locale"}},
+		DoUpdates: clause.AssignmentColumns([]string{"name", "description", "updated_by", "updated_at"}),
+	}).Create(t).Error
+	if err != nil {
+		return err
+	}
+	return r.touch(ctx, &product.Product{}, t.ProductID)
+}
+
+func (r *TranslationRepository) DeleteProduct(ctx context.Context, productID, locale string) error {
+	result := conn(ctx, r.db).Where("product_id = ? AND locale = ?", productID, locale).Delete(&product.Translation{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return product.ErrTranslationNotFound
+	}
+	return r.touch(ctx, &product.Product{}, productID)
+}
+
+func (r *TranslationRepository) ListByProduct(ctx context.Context, productID string) ([]*product.Translation, error) {
+	var translations []*product.Translation
+	err := conn(ctx, r.db).Where("product_id = ?", productID).Order("locale ASC").Find(&translations).Error
+	return translations, err
+}
+
+func (r *TranslationRepository) ForProducts(ctx context.Context, locale string, productIDs []string) (map[string]*product.Translation, error) {
+	byID := make(map[string]*product.Translation, len(productIDs))
+	if len(productIDs) == 0 {
+		return byID, nil
+	}
+	var translations []*product.Translation
+	err := conn(ctx, r.db).Where("locale = ? AND product_id IN ?", locale, productIDs).Find(&translations).Error
+	for _, t := range translations {
+		byID[t.ProductID] = t
+	}
+	return byID, err
+}
+
+func (r *TranslationRepository) SaveCategory(ctx context.Context, t *product.CategoryTranslation) error {
+	err := conn(ctx, r.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "category_id"}, {Name: "locale"}},
+		DoUpdates: clause.AssignmentColumns([]string{"name", "description", "updated_by", "updated_at"}),
+	}).Create(t).Error
+	if err != nil {
+		return err
+	}
+	return r.touch(ctx, &product.Category{}, t.CategoryID)
+}
+
+func (r *TranslationRepository) DeleteCategory(ctx context.Context, categoryID, locale string) error {
+	result := conn(ctx, r.db).Where("category_id = ? AND locale = ?", categoryID, locale).Delete(&product.CategoryTranslation{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return product.ErrTranslationNotFound
+	}
+	return r.touch(ctx, &product.Category{}, categoryID)
+}
+
+func (r *TranslationRepository) ListByCategory(ctx context.Context, categoryID string) ([]*product.CategoryTranslation, error) {
+	var translations []*product.CategoryTranslation
+	err := conn(ctx, r.db).Where("category_id = ?", categoryID).Order("locale ASC").Find(&translations).Error
+	return translations, err
+}
+
+func (r *TranslationRepository) ForCategories(ctx context.Context, locale string, categoryIDs []string) (map[string]*product.CategoryTranslation, error) {
+	byID := make(map[string]*product.CategoryTranslation, len(categoryIDs))
+	if len(categoryIDs) == 0 {
+		return byID, nil
+	}
+	var translations []*product.CategoryTranslation
+	err := conn(ctx, r.db).Where("locale = ? AND category_id IN ?", locale, categoryIDs).Find(&translations).Error
+	for _, t := range translations {
+		byID[t.CategoryID] = t
+	}
+	return byID, err
+}
+
+const untranslatedProduct = "NOT EXISTS (SELECT 1 FROM product_translations pt WHERE pt.product_id = products.id AND pt.locale = ?)"
+
+const untranslatedCategory = "NOT EXISTS (SELECT 1 FROM category_translations ct WHERE ct.category_id = categories.id AND ct.locale = ?)"
+
+func (r *TranslationRepository) MissingProducts(ctx context.Context, locale string, limit, offset int) ([]*product.Product, int64, error) {
+	query := conn(ctx, r.db).Model(&product.Product{}).
+		Where("products.status = ?", product.StatusActive).
+		Where(untranslatedProduct, locale)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var products []*product.Product
+	err := query.Order("products.created_at DESC").Limit(limit).Offset(offset).Find(&products).Error
+	return products, total, err
+}
+
+func (r *TranslationRepository) MissingCategories(ctx context.Context, locale string) ([]*product.Category, error) {
+	var categories []*product.Category
+	err := conn(ctx, r.db).Where(untranslatedCategory, locale).Order("name ASC").Find(&categories).Error
+	return categories, err
+}
+
+func (r *TranslationRepository) Coverage(ctx context.Context, locale string) (product.TranslationCoverage, error) {
+	coverage := product.TranslationCoverage{Locale: locale}
+	err := conn(ctx, r.db).Model(&product.Product{}).Where("status = ?", product.StatusActive).Count(&coverage.Products).Error
+	if err != nil {
+		return coverage, err
+	}
+	var missing int64
+	err = conn(ctx, r.db).Model(&product.Product{}).Where("products.status = ?", product.StatusActive).Where(untranslatedProduct, locale).Count(&missing).Error
+	if err != nil {
+		return coverage, err
+	}
+	coverage.TranslatedProducts = coverage.Products - missing
+
+	if err := conn(ctx, r.db).Model(&product.Category{}).Count(&coverage.Categories).Error; err != nil {
+		return coverage, err
+	}
+	if err := conn(ctx, r.db).Model(&product.Category{}).Where(untranslatedCategory, locale).Count(&missing).Error; err != nil {
+		return coverage, err
+	}
+	coverage.TranslatedCategories = coverage.Categories - missing
+	return coverage, nil
+}
+
+// touch bumps the row's updated_at for the change to be captured
+func (r *TranslationRepository) touch(ctx context.Context, model interface{}, id string) error {
+	return conn(ctx, r.db).Model(model).Where("id = ?", id).Update("updated_at", time.Now()).Error
+}
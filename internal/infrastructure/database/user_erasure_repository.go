@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/domain/audit"
+	"online-shop/internal/domain/oauth"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/payment"
+	"online-shop/internal/domain/user"
+
+	"gorm.io/gorm"
+)
+
+// erasedUserTables hold rows that belong to a single user and are deleted
+// outright. They come from scripts/database.sql and are skipped when a
+// deployment does not have them.
+var erasedUserTables = []string{"user_addresses", "carts", "wishlists"}
+
+type UserErasureRepository struct {
+	db *gorm.DB
+}
+
+func NewUserErasureRepository(db *gorm.DB) user.ErasureRepository {
+	return &UserErasureRepository{db: db}
+}
+
+func (r *UserErasureRepository) ListDueForErasure(ctx context.Context, requestedBefore time.Time, limit int) ([]*user.User, error) {
+	var users []*user.User
+	err := conn(ctx, r.db).
+		Where("status = ? AND deletion_requested_at <= ? AND erased_at IS NULL", user.StatusDeleted, requestedBefore).
+		Order("deletion_requested_at ASC").
+		Limit(limit).
+		Find(&users).Error
+	return users, err
+}
+
+// Erase runs in one transaction so a failure leaves the user pending for the
+// next run. Rows are updated by condition rather than saved, which keeps the
+// audit plugin from copying the erased values into the audit log; the old
+// diffs of the user row are cleared for the same reason.
+func (r *UserErasureRepository) Erase(ctx context.Context, u *user.User) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		columns := map[string]interface{}{
+			"email":           u.Email,
+			"password":        u.Password,
+			"first_name":      u.FirstName,
+			"last_name":       u.LastName,
+			"phone":           u.Phone,
+			"birthday":        u.Birthday,
+			"age_verified_at": u.AgeVerifiedAt,
+			"gender":          u.Gender,
+			"erased_at":       u.ErasedAt,
+			"updated_at":      u.UpdatedAt,
+		}
+		for _, channel := range user.Channels {
+			columns["marketing_"+string(channel)+"_granted"] = false
+			columns["marketing_"+string(channel)+"_updated_at"] = nil
+		}
+		err := tx.Model(&user.User{}).Where("id = ?", u.ID).Updates(columns).Error
+		if err != nil {
+			return err
+		}
+
+		for _, table := range erasedUserTables {
+			if !tx.Migrator().HasTable(table) {
+				continue
+			}
+			if err := tx.Exec("DELETE FROM "+table+" WHERE user_id = ?", u.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("user_id = ?", u.ID).Delete(&oauth.Account{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", u.ID).Delete(&payment.SavedMethod{}).Error; err != nil {
+			return err
+		}
+
+		// Orders stay for tax and accounting; the street is the only part of
+		// the shipping address that identifies the customer
+		if err := tx.Model(&order.Order{}).Where("user_id = ?", u.ID).Update("shipping_street", "").Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&audit.Entry{}).
+			Where("resource_type = ? AND resource_id = ? AND source = ?", "users", u.ID, audit.SourceRepository).
+			Update("changes", gorm.Expr("NULL")).Error; err != nil {
+			return err
+		}
+
+		entry := audit.NewEntry(audit.SourceCommand, "", "users.erase", "users", u.ID)
+		return tx.Create(entry).Error
+	})
+}
@@ -1,6 +1,9 @@
 package database
 
 import (
+	"context"
+	"strings"
+
 	"online-shop/internal/domain/user"
 
 	"gorm.io/gorm"
@@ -14,38 +17,75 @@ func NewUserRepository(db *gorm.DB) user.Repository {
 	return &UserRepository{db: db}
 }
 
-func (r *UserRepository) Create(u *user.User) error {
-	return r.db.Create(u).Error
+func NewConsentRepository(db *gorm.DB) user.ConsentRepository {
+	return &UserRepository{db: db}
 }
 
-func (r *UserRepository) GetByID(id string) (*user.User, error) {
+func (r *UserRepository) Create(ctx context.Context, u *user.User) error {
+	return conn(ctx, r.db).Create(u).Error
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id string) (*user.User, error) {
 	var u user.User
-	err := r.db.Where("id = ?", id).First(&u).Error
+	err := conn(ctx, r.db).Where("id = ?", id).First(&u).Error
 	if err != nil {
 		return nil, err
 	}
 	return &u, nil
 }
 
-func (r *UserRepository) GetByEmail(email string) (*user.User, error) {
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*user.User, error) {
 	var u user.User
-	err := r.db.Where("email = ?", email).First(&u).Error
+	err := conn(ctx, r.db).Where("email = ?", email).First(&u).Error
 	if err != nil {
 		return nil, err
 	}
 	return &u, nil
 }
 
-func (r *UserRepository) Update(u *user.User) error {
-	return r.db.Save(u).Error
+func (r *UserRepository) Update(ctx context.Context, u *user.User) error {
+	return conn(ctx, r.db).Save(u).Error
 }
 
-func (r *UserRepository) Delete(id string) error {
-	return r.db.Where("id = ?", id).Delete(&user.User{}).Error
+func (r *UserRepository) Delete(ctx context.Context, id string) error {
+	return conn(ctx, r.db).Where("id = ?", id).Delete(&user.User{}).Error
 }
 
-func (r *UserRepository) List(limit, offset int) ([]*user.User, error) {
+func (r *UserRepository) List(ctx context.Context, limit, offset int) ([]*user.User, error) {
 	var users []*user.User
-	err := r.db.Limit(limit).Offset(offset).Find(&users).Error
+	err := conn(ctx, r.db).Limit(limit).Offset(offset).Find(&users).Error
 	return users, err
-}
\ No newline at end of file
+}
+func (r *UserRepository) HasMarketingConsent(ctx context.Context, userID string, channel user.Channel) (bool, error) {
+	var users []*user.User
+	if err := conn(ctx, r.db).Where("id = ?", userID).Limit(1).Find(&users).Error; err != nil {
+		return false, err
+	}
+	return len(users) == 1 && users[0].MarketingConsent.Granted(channel), nil
+}
+
+// ConsentedEmails matches addresses without regard to case, as campaign
+// recipient lists come from outside the shop.
+func (r *UserRepository) ConsentedEmails(ctx context.Context, emails []string) (map[string]bool, error) {
+	consented := make(map[string]bool)
+	if len(emails) == 0 {
+		return consented, nil
+	}
+
+	lowered := make([]string, len(emails))
+	for i, email := range emails {
+		lowered[i] = strings.ToLower(email)
+	}
+
+	var found []string
+	err := conn(ctx, r.db).Model(&user.User{}).
+		Where("LOWER(email) IN ? AND marketing_email_granted AND status = ?", lowered, user.StatusActive).
+		Pluck("LOWER(email)", &found).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, email := range found {
+		consented[email] = true
+	}
+	return consented, nil
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/productversion"
+
+	"gorm.io/gorm"
+)
+
+// VersionPlugin is a GORM plugin recording a product version each time a
+// product is created or its content changes, whichever code path wrote it.
+// Like the audit plugin it sees the products a statement writes by their
+// key; writes by condition, such as stock updates and deletes, do not
+// change the content. The version is written on the statement's connection
+// and a version that cannot be recorded fails the write, so rollbacks never
+// miss one.
+type VersionPlugin struct{}
+
+func NewVersionPlugin() *VersionPlugin {
+	return &VersionPlugin{}
+}
+
+func (p *VersionPlugin) Name() string {
+	return "product_version"
+}
+
+func (p *VersionPlugin) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Create().After("gorm:create").Register("version:after_create", p.recordCreate); err != nil {
+		return err
+	}
+	return db.Callback().Update().After("gorm:update").Register("version:after_update", p.recordUpdate)
+}
+
+func (p *VersionPlugin) recordCreate(db *gorm.DB) {
+	p.record(db, productversion.ActionCreate)
+}
+
+func (p *VersionPlugin) recordUpdate(db *gorm.DB) {
+	p.record(db, productversion.ActionUpdate)
+}
+
+func (p *VersionPlugin) record(db *gorm.DB, action productversion.Action) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.RowsAffected == 0 || stmt.Schema == nil || stmt.Table != "products" {
+		return
+	}
+	field := stmt.Schema.PrioritizedPrimaryField
+	if field == nil {
+		return
+	}
+
+	var ids []string
+	add := func(value reflect.Value) {
+		if id, zero := field.ValueOf(stmt.Context, value); !zero {
+			ids = append(ids, fmt.Sprint(id))
+		}
+	}
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Struct:
+		add(stmt.ReflectValue)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			add(reflect.Indirect(stmt.ReflectValue.Index(i)))
+		}
+	}
+
+	now := time.Now()
+	for _, id := range ids {
+		if err := p.version(db, id, action, now); err != nil {
+			db.AddError(fmt.Errorf("failed to record version of product %s: %w", id, err))
+			return
+		}
+	}
+}
+
+// version records the product's content as its next version, unless it is
+// the content of its latest one
+func (p *VersionPlugin) version(db *gorm.DB, productID string, action productversion.Action, at time.Time) error {
+	session := db.Session(&gorm.Session{NewDB: true})
+
+	var current product.Product
+	if err := session.Where("id = ?", productID).Take(&current).Error; err != nil {
+		return err
+	}
+	snapshot := productversion.Take(&current)
+
+	var latest *productversion.Version
+	var stored productversion.Version
+	err := session.Where("product_id = ?", productID).Order("number DESC").Take(&stored).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+	case err != nil:
+		return err
+	default:
+		if stored.Snapshot.Equal(snapshot) {
+			return nil
+		}
+		latest = &stored
+	}
+
+	v := productversion.Next(db.Statement.Context, latest, productID, snapshot, action, at)
+	return session.Create(v).Error
+}
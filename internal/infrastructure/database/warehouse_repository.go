@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/domain/warehouse"
+
+	"online-shop/pkg/id"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WarehouseRepository struct {
+	db *gorm.DB
+}
+
+func NewWarehouseRepository(db *gorm.DB) warehouse.Repository {
+	return &WarehouseRepository{db: db}
+}
+
+func (r *WarehouseRepository) Create(ctx context.Context, w *warehouse.Warehouse) error {
+	return conn(ctx, r.db).Create(w).Error
+}
+
+func (r *WarehouseRepository) GetByID(ctx context.Context, id string) (*warehouse.Warehouse, error) {
+	var w warehouse.Warehouse
+	err := conn(ctx, r.db).Where("id = ?", id).First(&w).Error
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (r *WarehouseRepository) Update(ctx context.Context, w *warehouse.Warehouse) error {
+	return conn(ctx, r.db).Save(w).Error
+}
+
+func (r *WarehouseRepository) List(ctx context.Context, limit, offset int) ([]*warehouse.Warehouse, error) {
+	var warehouses []*warehouse.Warehouse
+	err := conn(ctx, r.db).Order("priority ASC").Limit(limit).Offset(offset).Find(&warehouses).Error
+	return warehouses, err
+}
+
+func (r *WarehouseRepository) ListActive(ctx context.Context) ([]*warehouse.Warehouse, error) {
+	var warehouses []*warehouse.Warehouse
+	err := conn(ctx, r.db).Where("active = ?", true).Order("priority ASC").Find(&warehouses).Error
+	return warehouses, err
+}
+
+type StockRepository struct {
+	db *gorm.DB
+}
+
+func NewStockRepository(db *gorm.DB) warehouse.StockRepository {
+	return &StockRepository{db: db}
+}
+
+func (r *StockRepository) GetByWarehouse(ctx context.Context, warehouseID string, limit, offset int) ([]*warehouse.Stock, error) {
+	var stock []*warehouse.Stock
+	err := conn(ctx, r.db).Where("warehouse_id = ?", warehouseID).
+		Order("product_id").
+		Limit(limit).
+		Offset(offset).
+		Find(&stock).Error
+	return stock, err
+}
+
+func (r *StockRepository) GetByProducts(ctx context.Context, productIDs []string) ([]*warehouse.Stock, error) {
+	var stock []*warehouse.Stock
+	err := conn(ctx, r.db).Where("product_id IN ?", productIDs).Find(&stock).Error
+	return stock, err
+}
+
+func (r *StockRepository) SetQuantity(ctx context.Context, warehouseID, productID string, quantity int) error {
+	stock := &warehouse.Stock{
+		ID:          id.New(),
+		WarehouseID: warehouseID,
+		ProductID:   productID,
+		Quantity:    quantity,
+		UpdatedAt:   time.Now(),
+	}
+	return conn(ctx, r.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "warehouse_id"}, {Name: "product_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"quantity", "updated_at"}),
+	}).Create(stock).Error
+}
+
+// AdjustQuantity takes stock only while enough is available, in the same
+// statement, so concurrent orders cannot take it below what is reserved.
+func (r *StockRepository) AdjustQuantity(ctx context.Context, warehouseID, productID string, delta int) error {
+	query := conn(ctx, r.db).Model(&warehouse.Stock{}).
+		Where("warehouse_id = ? AND product_id = ?", warehouseID, productID)
+	if delta < 0 {
+		query = query.Where("quantity - reserved >= ?", -delta)
+	}
+	result := query.Updates(map[string]interface{}{
+		"quantity":   gorm.Expr("quantity + ?", delta),
+		"updated_at": time.Now(),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if delta < 0 && result.RowsAffected == 0 {
+		return warehouse.ErrOutOfStock
+	}
+	return nil
+}
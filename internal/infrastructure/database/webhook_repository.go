@@ -0,0 +1,162 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"online-shop/internal/domain/webhook"
+
+	"gorm.io/gorm"
+)
+
+type WebhookEndpointRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookEndpointRepository(db *gorm.DB) webhook.EndpointRepository {
+	return &WebhookEndpointRepository{db: db}
+}
+
+func (r *WebhookEndpointRepository) Create(ctx context.Context, e *webhook.Endpoint) error {
+	return conn(ctx, r.db).Create(e).Error
+}
+
+func (r *WebhookEndpointRepository) GetByID(ctx context.Context, id string) (*webhook.Endpoint, error) {
+	var e webhook.Endpoint
+	err := conn(ctx, r.db).Where("id = ?", id).First(&e).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, webhook.ErrEndpointNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *WebhookEndpointRepository) Update(ctx context.Context, e *webhook.Endpoint) error {
+	return conn(ctx, r.db).Save(e).Error
+}
+
+func (r *WebhookEndpointRepository) Delete(ctx context.Context, id string) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		deliveries := tx.Model(&webhook.Delivery{}).Select("id").Where("endpoint_id = ?", id)
+		if err := tx.Where("delivery_id IN (?)", deliveries).Delete(&webhook.Attempt{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("endpoint_id = ?", id).Delete(&webhook.Delivery{}).Error; err != nil {
+			return err
+		}
+		res := tx.Where("id = ?", id).Delete(&webhook.Endpoint{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return webhook.ErrEndpointNotFound
+		}
+		return nil
+	})
+}
+
+func (r *WebhookEndpointRepository) List(ctx context.Context, merchantID string, limit, offset int) ([]*webhook.Endpoint, error) {
+	query := conn(ctx, r.db).Order("created_at DESC")
+	if merchantID != "" {
+		query = query.Where("merchant_id = ?", merchantID)
+	}
+
+	var endpoints []*webhook.Endpoint
+	err := query.Limit(limit).Offset(offset).Find(&endpoints).Error
+	return endpoints, err
+}
+
+func (r *WebhookEndpointRepository) ListActive(ctx context.Context) ([]*webhook.Endpoint, error) {
+	var endpoints []*webhook.Endpoint
+	err := conn(ctx, r.db).Where("active = ?", true).Find(&endpoints).Error
+	return endpoints, err
+}
+
+type WebhookDeliveryRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookDeliveryRepository(db *gorm.DB) webhook.DeliveryRepository {
+	return &WebhookDeliveryRepository{db: db}
+}
+
+func (r *WebhookDeliveryRepository) CreateBatch(ctx context.Context, deliveries []*webhook.Delivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return conn(ctx, r.db).Omit("AttemptLog").Create(deliveries).Error
+}
+
+func (r *WebhookDeliveryRepository) GetByID(ctx context.Context, id string) (*webhook.Delivery, error) {
+	var d webhook.Delivery
+	err := conn(ctx, r.db).Preload("AttemptLog", func(db *gorm.DB) *gorm.DB {
+		return db.Order("attempted_at DESC")
+	}).Where("id = ?", id).First(&d).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, webhook.ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *WebhookDeliveryRepository) ListDue(ctx context.Context, at time.Time, limit int) ([]*webhook.Delivery, error) {
+	var deliveries []*webhook.Delivery
+	err := conn(ctx, r.db).Where("status = ? AND next_attempt_at <= ?", webhook.DeliveryPending, at).
+		Order("next_attempt_at ASC").
+		Limit(limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
+
+func (r *WebhookDeliveryRepository) Claim(ctx context.Context, d *webhook.Delivery, until time.Time) (bool, error) {
+	res := conn(ctx, r.db).Model(&webhook.Delivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at = ?", d.ID, webhook.DeliveryPending, d.NextAttemptAt).
+		Update("next_attempt_at", until)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+	d.NextAttemptAt = &until
+	return true, nil
+}
+
+func (r *WebhookDeliveryRepository) Record(ctx context.Context, d *webhook.Delivery, a *webhook.Attempt) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(a).Error; err != nil {
+			return err
+		}
+		return tx.Omit("AttemptLog").Save(d).Error
+	})
+}
+
+func (r *WebhookDeliveryRepository) Update(ctx context.Context, d *webhook.Delivery) error {
+	return conn(ctx, r.db).Omit("AttemptLog").Save(d).Error
+}
+
+func (r *WebhookDeliveryRepository) List(ctx context.Context, filter webhook.DeliveryFilter, limit, offset int) ([]*webhook.Delivery, int64, error) {
+	query := conn(ctx, r.db).Model(&webhook.Delivery{})
+	if filter.EndpointID != "" {
+		query = query.Where("endpoint_id = ?", filter.EndpointID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Event != "" {
+		query = query.Where("event = ?", filter.Event)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var deliveries []*webhook.Delivery
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&deliveries).Error
+	return deliveries, total, err
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"online-shop/internal/domain/whatsapp"
+
+	"gorm.io/gorm"
+)
+
+type WhatsAppMessageRepository struct {
+	db *gorm.DB
+}
+
+func NewWhatsAppMessageRepository(db *gorm.DB) whatsapp.MessageRepository {
+	return &WhatsAppMessageRepository{db: db}
+}
+
+func (r *WhatsAppMessageRepository) Create(ctx context.Context, m *whatsapp.Message) error {
+	return conn(ctx, r.db).Create(m).Error
+}
+
+func (r *WhatsAppMessageRepository) Get(ctx context.Context, id string) (*whatsapp.Message, error) {
+	var m whatsapp.Message
+	err := conn(ctx, r.db).Where("id = ?", id).First(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, whatsapp.ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *WhatsAppMessageRepository) List(ctx context.Context, limit, offset int) ([]*whatsapp.Message, error) {
+	var messages []*whatsapp.Message
+	err := conn(ctx, r.db).Order("created_at DESC").Limit(limit).Offset(offset).Find(&messages).Error
+	return messages, err
+}
+
+func (r *WhatsAppMessageRepository) Update(ctx context.Context, m *whatsapp.Message) error {
+	return conn(ctx, r.db).Model(m).Select("status", "error", "updated_at").Updates(m).Error
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"online-shop/internal/domain/whatsapp"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WhatsAppTemplateRepository struct {
+	db *gorm.DB
+}
+
+func NewWhatsAppTemplateRepository(db *gorm.DB) whatsapp.TemplateRepository {
+	return &WhatsAppTemplateRepository{db: db}
+}
+
+// Save upserts on name and language, so resubmitting a rejected template
+// replaces it
+func (r *WhatsAppTemplateRepository) Save(ctx context.Context, t *whatsapp.Template) error {
+	return conn(ctx, r.db).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "name"}, {Name: "language"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"category", "body", "parameters", "provider_id", "status", "rejected_reason", "updated_at",
+		}),
+	}).Create(t).Error
+}
+
+func (r *WhatsAppTemplateRepository) Get(ctx context.Context, name, language string) (*whatsapp.Template, error) {
+	var t whatsapp.Template
+	err := conn(ctx, r.db).Where("name = ? AND language = ?", name, language).First(&t).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, whatsapp.ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *WhatsAppTemplateRepository) List(ctx context.Context, limit, offset int) ([]*whatsapp.Template, error) {
+	var templates []*whatsapp.Template
+	err := conn(ctx, r.db).Order("name, language").Limit(limit).Offset(offset).Find(&templates).Error
+	return templates, err
+}
+
+func (r *WhatsAppTemplateRepository) SetStatus(ctx context.Context, s whatsapp.TemplateStatus) error {
+	updates := map[string]interface{}{
+		"status":          s.Status,
+		"rejected_reason": s.RejectedReason,
+		"updated_at":      time.Now(),
+	}
+	if s.ProviderID != "" {
+		updates["provider_id"] = s.ProviderID
+	}
+
+	result := conn(ctx, r.db).Model(&whatsapp.Template{}).
+		Where("name = ? AND language = ?", s.Name, s.Language).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return whatsapp.ErrTemplateNotFound
+	}
+	return nil
+}
+
+func (r *WhatsAppTemplateRepository) Delete(ctx context.Context, name string) error {
+	result := conn(ctx, r.db).Where("name = ?", name).Delete(&whatsapp.Template{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return whatsapp.ErrTemplateNotFound
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package elasticsearch
+
+import (
+	"errors"
+	"net/http"
+
+	"online-shop/pkg/resilience"
+)
+
+// errServerStatus counts a 5xx answer against the cluster while still
+// handing the response to the client, which reads the error from it
+var errServerStatus = errors.New("elasticsearch answered with a server error")
+
+// breakerTransport sends every request to the cluster through a breaker.
+// Requests the cluster rejects, such as a bad query, do not count against it.
+type breakerTransport struct {
+	next    http.RoundTripper
+	breaker *resilience.Breaker
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var res *http.Response
+	err := t.breaker.Do(func() error {
+		var err error
+		res, err = t.next.RoundTrip(req)
+		if err == nil && res.StatusCode >= http.StatusInternalServerError {
+			return errServerStatus
+		}
+		return err
+	})
+	if errors.Is(err, errServerStatus) {
+		return res, nil
+	}
+	return res, err
+}
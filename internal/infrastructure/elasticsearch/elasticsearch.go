@@ -5,8 +5,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"online-shop/internal/domain/product"
 	"online-shop/pkg/config"
+	"online-shop/pkg/i18n"
+	"online-shop/pkg/resilience"
 	"strings"
 
 	"github.com/elastic/go-elasticsearch/v8"
@@ -22,6 +25,10 @@ func NewClient(cfg *config.ElasticsearchConfig) (*Client, error) {
 		Addresses: []string{cfg.URL},
 		Username:  cfg.Username,
 		Password:  cfg.Password,
+		Transport: &breakerTransport{
+			next:    http.DefaultTransport,
+			breaker: resilience.New("elasticsearch", cfg.Resilience, nil),
+		},
 	})
 	if err != nil {
 		return nil, err
@@ -45,16 +52,41 @@ func (c *Client) Ping(ctx context.Context) error {
 }
 
 type ProductDocument struct {
-	ID          string   `json:"id"`
-	Name        string   `json:"name"`
-	Description string   `json:"description"`
-	Price       float64  `json:"price"`
-	CategoryID  string   `json:"category_id"`
-	Category    string   `json:"category"`
-	MerchantID  string   `json:"merchant_id"`
-	Images      []string `json:"images"`
-	Status      string   `json:"status"`
-	CreatedAt   string   `json:"created_at"`
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Slug         string   `json:"slug"`
+	Description  string   `json:"description"`
+	Price        float64  `json:"price"`
+	CategoryID   string   `json:"category_id"`
+	Category     string   `json:"category"`
+	CategorySlug string   `json:"category_slug"`
+	MerchantID   string   `json:"merchant_id"`
+	Images       []string `json:"images"`
+	Status       string   `json:"status"`
+	CreatedAt    string   `json:"created_at"`
+	// Attributes is a nested field so filters match key and value of the
+	// same attribute
+	Attributes []AttributeDocument `json:"attributes"`
+	// Badges are indexed as the badge job last stored them on the product
+	Badges []product.Badge `json:"badges"`
+}
+
+type AttributeDocument struct {
+	Key    string   `json:"key"`
+	Label  string   `json:"label"`
+	Type   string   `json:"type"`
+	Value  string   `json:"value"`
+	Unit   string   `json:"unit,omitempty"`
+	Number *float64 `json:"number,omitempty"`
+}
+
+// ProductAttributes turns the indexed attributes back into product ones.
+func (d *ProductDocument) ProductAttributes() []product.Attribute {
+	attrs := make([]product.Attribute, len(d.Attributes))
+	for i, a := range d.Attributes {
+		attrs[i] = product.Attribute{Key: a.Key, Label: a.Label, Type: product.AttributeType(a.Type), Value: a.Value, Unit: a.Unit}
+	}
+	return attrs
 }
 
 type SearchService struct {
@@ -65,10 +97,25 @@ func NewSearchService(client *Client) *SearchService {
 	return &SearchService{client: client}
 }
 
+// IndexName is the index holding the catalog in locale: the default locale,
+// which products are written in, is indexed in "products" and each locale
+// they are translated to in "products_<locale>".
+func IndexName(locale string) string {
+	if locale == "" || locale == i18n.DefaultLocale {
+		return "products"
+	}
+	return "products_" + strings.ToLower(locale)
+}
+
 func (s *SearchService) IndexProduct(ctx context.Context, product *product.Product) error {
+	return s.indexProduct(ctx, IndexName(""), product)
+}
+
+func (s *SearchService) indexProduct(ctx context.Context, index string, product *product.Product) error {
 	doc := ProductDocument{
 		ID:          product.ID,
 		Name:        product.Name,
+		Slug:        product.Slug,
 		Description: product.Description,
 		Price:       product.Price,
 		CategoryID:  product.CategoryID,
@@ -76,10 +123,20 @@ func (s *SearchService) IndexProduct(ctx context.Context, product *product.Produ
 		Images:      product.Images,
 		Status:      string(product.Status),
 		CreatedAt:   product.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		Badges:      product.Badges,
+	}
+
+	for _, a := range product.Attributes {
+		attr := AttributeDocument{Key: a.Key, Label: a.Label, Type: string(a.Type), Value: a.Value, Unit: a.Unit}
+		if n, ok := a.Number(); ok {
+			attr.Number = &n
+		}
+		doc.Attributes = append(doc.Attributes, attr)
 	}
 
 	if product.Category != nil {
 		doc.Category = product.Category.Name
+		doc.CategorySlug = product.Category.Slug
 	}
 
 	data, err := json.Marshal(doc)
@@ -88,7 +145,7 @@ func (s *SearchService) IndexProduct(ctx context.Context, product *product.Produ
 	}
 
 	req := esapi.IndexRequest{
-		Index:      "products",
+		Index:      index,
 		DocumentID: product.ID,
 		Body:       bytes.NewReader(data),
 		Refresh:    "true",
@@ -108,8 +165,12 @@ func (s *SearchService) IndexProduct(ctx context.Context, product *product.Produ
 }
 
 func (s *SearchService) DeleteProduct(ctx context.Context, productID string) error {
+	return s.deleteProduct(ctx, IndexName(""), productID)
+}
+
+func (s *SearchService) deleteProduct(ctx context.Context, index, productID string) error {
 	req := esapi.DeleteRequest{
-		Index:      "products",
+		Index:      index,
 		DocumentID: productID,
 		Refresh:    "true",
 	}
@@ -128,20 +189,30 @@ func (s *SearchService) DeleteProduct(ctx context.Context, productID string) err
 }
 
 type SearchQuery struct {
-	Query      string
+	Query string
+	// Locale searches the catalog as translated to it, in its index
+	Locale     string
 	CategoryID string
 	MinPrice   float64
 	MaxPrice   float64
 	MerchantID string
-	From       int
-	Size       int
+	Attributes []product.AttributeFilter
+	// Ranking pins and boosts products among the results
+	Ranking product.Ranking
+	// Facets asks for value counts of the non-text attributes
+	Facets bool
+	From   int
+	Size   int
 }
 
 type SearchResult struct {
 	Products []*ProductDocument `json:"products"`
 	Total    int64              `json:"total"`
+	Facets   []product.Facet    `json:"facets,omitempty"`
 }
 
+const maxFacetValues = 50
+
 func (s *SearchService) SearchProducts(ctx context.Context, query SearchQuery) (*SearchResult, error) {
 	var buf bytes.Buffer
 
@@ -152,7 +223,7 @@ func (s *SearchService) SearchProducts(ctx context.Context, query SearchQuery) (
 				"filter": []interface{}{
 					map[string]interface{}{
 						"term": map[string]interface{}{
-							"status": "active",
+							"status": string(product.StatusActive),
 						},
 					},
 				},
@@ -218,13 +289,25 @@ func (s *SearchService) SearchProducts(ctx context.Context, query SearchQuery) (
 		})
 	}
 
+	for _, f := range query.Attributes {
+		boolQuery["filter"] = append(boolQuery["filter"].([]interface{}), attributeFilter(f))
+	}
+
+	if !query.Ranking.Empty() {
+		searchQuery["query"] = rankedQuery(searchQuery["query"].(map[string]interface{}), query.Ranking)
+	}
+
+	if query.Facets {
+		searchQuery["aggs"] = facetAggregation()
+	}
+
 	if err := json.NewEncoder(&buf).Encode(searchQuery); err != nil {
 		return nil, err
 	}
 
 	res, err := s.client.es.Search(
 		s.client.es.Search.WithContext(ctx),
-		s.client.es.Search.WithIndex("products"),
+		s.client.es.Search.WithIndex(IndexName(query.Locale)),
 		s.client.es.Search.WithBody(&buf),
 		s.client.es.Search.WithTrackTotalHits(true),
 	)
@@ -254,13 +337,312 @@ func (s *SearchService) SearchProducts(ctx context.Context, query SearchQuery) (
 		products = append(products, &product)
 	}
 
-	return &SearchResult{
+	result := &SearchResult{
 		Products: products,
 		Total:    total,
-	}, nil
+	}
+	if aggs, ok := response["aggregations"]; ok {
+		result.Facets = parseFacets(aggs)
+	}
+	return result, nil
+}
+
+// rankedQuery applies a ranking to a search. Boosts go through a
+// function_score multiplying the scores of the products matching their
+// filter, and pins through a pinned query putting the products first
+// whatever they score; pinned products still have to be active.
+func rankedQuery(query map[string]interface{}, ranking product.Ranking) map[string]interface{} {
+	if len(ranking.Boosts) > 0 {
+		functions := make([]interface{}, 0, len(ranking.Boosts))
+		for _, b := range ranking.Boosts {
+			functions = append(functions, map[string]interface{}{
+				"filter": attributeFilter(b.Filter),
+				"weight": b.Factor,
+			})
+		}
+		query = map[string]interface{}{
+			"function_score": map[string]interface{}{
+				"query":      query,
+				"functions":  functions,
+				"score_mode": "multiply",
+				"boost_mode": "multiply",
+			},
+		}
+	}
+	if len(ranking.Pinned) > 0 {
+		query = map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must": map[string]interface{}{
+					"pinned": map[string]interface{}{
+						"ids":     ranking.Pinned,
+						"organic": query,
+					},
+				},
+				"filter": map[string]interface{}{
+					"term": map[string]interface{}{"status": string(product.StatusActive)},
+				},
+			},
+		}
+	}
+	return query
+}
+
+// attributeFilter matches documents with one attribute satisfying f. Values
+// are compared on the lowercase keyword subfield.
+func attributeFilter(f product.AttributeFilter) map[string]interface{} {
+	must := []interface{}{
+		map[string]interface{}{"term": map[string]interface{}{"attributes.key": f.Key}},
+	}
+	if len(f.Values) > 0 {
+		values := make([]string, len(f.Values))
+		for i, v := range f.Values {
+			values[i] = strings.ToLower(v)
+		}
+		must = append(must, map[string]interface{}{
+			"terms": map[string]interface{}{"attributes.value.lowercase": values},
+		})
+	}
+	if f.Min != nil || f.Max != nil {
+		numberRange := map[string]interface{}{}
+		if f.Min != nil {
+			numberRange["gte"] = *f.Min
+		}
+		if f.Max != nil {
+			numberRange["lte"] = *f.Max
+		}
+		must = append(must, map[string]interface{}{
+			"range": map[string]interface{}{"attributes.number": numberRange},
+		})
+	}
+
+	return map[string]interface{}{
+		"nested": map[string]interface{}{
+			"path":  "attributes",
+			"query": map[string]interface{}{"bool": map[string]interface{}{"must": must}},
+		},
+	}
+}
+
+func facetAggregation() map[string]interface{} {
+	return map[string]interface{}{
+		"attributes": map[string]interface{}{
+			"nested": map[string]interface{}{"path": "attributes"},
+			"aggs": map[string]interface{}{
+				"facetable": map[string]interface{}{
+					"filter": map[string]interface{}{
+						"bool": map[string]interface{}{
+							"must_not": map[string]interface{}{
+								"term": map[string]interface{}{"attributes.type": string(product.AttributeText)},
+							},
+						},
+					},
+					"aggs": map[string]interface{}{
+						"keys": map[string]interface{}{
+							"terms": map[string]interface{}{"field": "attributes.key", "size": maxFacetValues},
+							"aggs": map[string]interface{}{
+								"values": map[string]interface{}{
+									"terms": map[string]interface{}{"field": "attributes.value", "size": maxFacetValues},
+								},
+								"meta": map[string]interface{}{
+									"top_hits": map[string]interface{}{
+										"size":    1,
+										"_source": []string{"attributes.label", "attributes.type", "attributes.unit"},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func parseFacets(aggs interface{}) []product.Facet {
+	var parsed struct {
+		Attributes struct {
+			Facetable struct {
+				Keys struct {
+					Buckets []struct {
+						Key    string `json:"key"`
+						Values struct {
+							Buckets []struct {
+								Key      string `json:"key"`
+								DocCount int64  `json:"doc_count"`
+							} `json:"buckets"`
+						} `json:"values"`
+						Meta struct {
+							Hits struct {
+								Hits []struct {
+									Source AttributeDocument `json:"_source"`
+								} `json:"hits"`
+							} `json:"hits"`
+						} `json:"meta"`
+					} `json:"buckets"`
+				} `json:"keys"`
+			} `json:"facetable"`
+		} `json:"attributes"`
+	}
+	data, _ := json.Marshal(aggs)
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil
+	}
+
+	var facets []product.Facet
+	for _, bucket := range parsed.Attributes.Facetable.Keys.Buckets {
+		facet := product.Facet{Key: bucket.Key, Label: bucket.Key}
+		if hits := bucket.Meta.Hits.Hits; len(hits) > 0 {
+			facet.Label = hits[0].Source.Label
+			facet.Type = product.AttributeType(hits[0].Source.Type)
+			facet.Unit = hits[0].Source.Unit
+		}
+		for _, v := range bucket.Values.Buckets {
+			facet.Values = append(facet.Values, product.FacetValue{Value: v.Key, Count: v.DocCount})
+		}
+		facets = append(facets, facet)
+	}
+	return facets
+}
+
+// SimilarProducts uses more_like_this over the product text, boosted by a
+// shared category and a price within 25% of the source product.
+func (s *SearchService) SimilarProducts(ctx context.Context, p *product.Product, limit int) ([]string, error) {
+	var buf bytes.Buffer
+
+	searchQuery := map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must": []interface{}{
+					map[string]interface{}{
+						"more_like_this": map[string]interface{}{
+							"fields": []string{"name", "description", "category"},
+							"like": []interface{}{
+								map[string]interface{}{"_index": "products", "_id": p.ID},
+								p.Name,
+							},
+							"min_term_freq":   1,
+							"min_doc_freq":    1,
+							"max_query_terms": 25,
+						},
+					},
+				},
+				"should": []interface{}{
+					map[string]interface{}{
+						"term": map[string]interface{}{
+							"category_id": map[string]interface{}{
+								"value": p.CategoryID,
+								"boost": 2.0,
+							},
+						},
+					},
+					map[string]interface{}{
+						"range": map[string]interface{}{
+							"price": map[string]interface{}{
+								"gte":   p.Price * 0.75,
+								"lte":   p.Price * 1.25,
+								"boost": 1.0,
+							},
+						},
+					},
+				},
+				"filter": []interface{}{
+					map[string]interface{}{
+						"term": map[string]interface{}{
+							"status": string(product.StatusActive),
+						},
+					},
+				},
+				"must_not": []interface{}{
+					map[string]interface{}{
+						"ids": map[string]interface{}{
+							"values": []string{p.ID},
+						},
+					},
+				},
+			},
+		},
+		"size":    limit,
+		"_source": []string{"id"},
+	}
+
+	if err := json.NewEncoder(&buf).Encode(searchQuery); err != nil {
+		return nil, err
+	}
+
+	res, err := s.client.es.Search(
+		s.client.es.Search.WithContext(ctx),
+		s.client.es.Search.WithIndex("products"),
+		s.client.es.Search.WithBody(&buf),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("similar products error: %s", res.String())
+	}
+
+	var response struct {
+		Hits struct {
+			Hits []struct {
+				ID string `json:"_id"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(response.Hits.Hits))
+	for _, hit := range response.Hits.Hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids, nil
+}
+
+// languageAnalyzers are the Elasticsearch language analyzers the text of a
+// locale's index is analyzed with, so that searches match word stems and
+// skip stop words. Other locales use the standard analyzer.
+var languageAnalyzers = map[string]string{
+	"ar": "arabic",
+	"de": "german",
+	"en": "english",
+	"es": "spanish",
+	"fr": "french",
+	"id": "indonesian",
+	"it": "italian",
+	"nl": "dutch",
+	"pt": "portuguese",
+	"ru": "russian",
+	"th": "thai",
+	"tr": "turkish",
 }
 
 func (s *SearchService) CreateIndex(ctx context.Context) error {
+	return s.createIndex(ctx, IndexName(""), "standard")
+}
+
+// CreateLocaleIndices creates the index of each locale the catalog is
+// translated to, analyzing its text in the locale's language.
+func (s *SearchService) CreateLocaleIndices(ctx context.Context, locales []string) error {
+	for _, locale := range locales {
+		language := strings.ToLower(locale)
+		if i := strings.IndexAny(language, "-_"); i > 0 {
+			language = language[:i]
+		}
+		analyzer, ok := languageAnalyzers[language]
+		if !ok {
+			analyzer = "standard"
+		}
+		if err := s.createIndex(ctx, IndexName(locale), analyzer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SearchService) createIndex(ctx context.Context, index, analyzer string) error {
 	mapping := `{
 		"mappings": {
 			"properties": {
@@ -272,6 +654,7 @@ func (s *SearchService) CreateIndex(ctx context.Context) error {
 						"keyword": {"type": "keyword"}
 					}
 				},
+				"slug": {"type": "keyword"},
 				"description": {"type": "text", "analyzer": "standard"},
 				"price": {"type": "float"},
 				"category_id": {"type": "keyword"},
@@ -282,16 +665,48 @@ func (s *SearchService) CreateIndex(ctx context.Context) error {
 						"keyword": {"type": "keyword"}
 					}
 				},
+				"category_slug": {"type": "keyword"},
 				"merchant_id": {"type": "keyword"},
 				"images": {"type": "keyword"},
 				"status": {"type": "keyword"},
-				"created_at": {"type": "date"}
+				"created_at": {"type": "date"},
+				"attributes": {
+					"type": "nested",
+					"properties": {
+						"key": {"type": "keyword"},
+						"label": {"type": "keyword"},
+						"type": {"type": "keyword"},
+						"value": {
+							"type": "keyword",
+							"fields": {
+								"lowercase": {"type": "keyword", "normalizer": "lowercase"}
+							}
+						},
+						"unit": {"type": "keyword"},
+						"number": {"type": "double"}
+					}
+				},
+				"badges": {
+					"properties": {
+						"code": {"type": "keyword"},
+						"label": {"type": "keyword", "index": false}
+					}
+				}
+			}
+		},
+		"settings": {
+			"analysis": {
+				"normalizer": {
+					"lowercase": {"type": "custom", "filter": ["lowercase"]}
+				}
 			}
 		}
 	}`
 
+	mapping = strings.ReplaceAll(mapping, `"analyzer": "standard"`, `"analyzer": "`+analyzer+`"`)
+
 	req := esapi.IndicesCreateRequest{
-		Index: "products",
+		Index: index,
 		Body:  strings.NewReader(mapping),
 	}
 
@@ -0,0 +1,75 @@
+package elasticsearch
+
+import (
+	"context"
+
+	"online-shop/internal/domain/product"
+)
+
+// LocalizedIndex keeps the catalog indexed in the default locale and in
+// every locale it is translated to. Products are indexed in a locale's index
+// in the text of their translation to it, or in their own where they have
+// none, so a search in any locale covers the whole catalog.
+type LocalizedIndex struct {
+	search       *SearchService
+	translations product.TranslationRepository
+	locales      []string
+}
+
+func NewLocalizedIndex(search *SearchService, translations product.TranslationRepository, locales []string) *LocalizedIndex {
+	return &LocalizedIndex{search: search, translations: translations, locales: locales}
+}
+
+func (x *LocalizedIndex) IndexProduct(ctx context.Context, p *product.Product) error {
+	if err := x.search.IndexProduct(ctx, p); err != nil {
+		return err
+	}
+	if len(x.locales) == 0 {
+		return nil
+	}
+
+	translations, err := x.translations.ListByProduct(ctx, p.ID)
+	if err != nil {
+		return err
+	}
+	var categoryTranslations []*product.CategoryTranslation
+	if p.Category != nil {
+		if categoryTranslations, err = x.translations.ListByCategory(ctx, p.Category.ID); err != nil {
+			return err
+		}
+	}
+
+	for _, locale := range x.locales {
+		localized := *p
+		for _, t := range translations {
+			if t.Locale == locale {
+				localized.Translate(t)
+			}
+		}
+		if p.Category != nil {
+			category := *p.Category
+			for _, t := range categoryTranslations {
+				if t.Locale == locale {
+					category.Translate(t)
+				}
+			}
+			localized.Category = &category
+		}
+		if err := x.search.indexProduct(ctx, IndexName(locale), &localized); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *LocalizedIndex) DeleteProduct(ctx context.Context, productID string) error {
+	if err := x.search.DeleteProduct(ctx, productID); err != nil {
+		return err
+	}
+	for _, locale := range x.locales {
+		if err := x.search.deleteProduct(ctx, IndexName(locale), productID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
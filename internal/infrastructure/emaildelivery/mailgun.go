@@ -0,0 +1,139 @@
+package emaildelivery
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"online-shop/internal/domain/emaildelivery"
+	"online-shop/pkg/config"
+	"online-shop/pkg/httpclient"
+)
+
+// MailgunProvider sends through the Mailgun messages API. Its webhooks,
+// signed with the account's webhook signing key, report failed deliveries
+// and complaints.
+type MailgunProvider struct {
+	endpoint   string
+	domain     string
+	apiKey     string
+	signingKey string
+	client     *http.Client
+}
+
+func NewMailgunProvider(cfg *config.MailgunConfig) *MailgunProvider {
+	return &MailgunProvider{
+		endpoint:   strings.TrimRight(firstNonEmpty(cfg.Endpoint, "https://api.mailgun.net"), "/"),
+		domain:     cfg.Domain,
+		apiKey:     cfg.APIKey,
+		signingKey: cfg.WebhookSigningKey,
+		client:     httpclient.New(httpclient.Options{Name: "mailgun", Retries: httpclient.DefaultRetries}),
+	}
+}
+
+func (p *MailgunProvider) Name() string {
+	return "mailgun"
+}
+
+func (p *MailgunProvider) Send(ctx context.Context, msg *emaildelivery.Message) error {
+	form := url.Values{}
+	form.Set("from", msg.From)
+	form.Set("to", msg.To)
+	form.Set("subject", msg.Subject)
+	form.Set("html", msg.HTML)
+	if msg.Locale != "" {
+		form.Set("h:Content-Language", msg.Locale)
+	}
+	for name, value := range msg.Headers {
+		form.Set("h:"+name, value)
+	}
+
+	target := p.endpoint + "/v3/" + url.PathEscape(p.domain) + "/messages"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth("api", p.apiKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return statusError(resp.StatusCode, fmt.Errorf("mailgun returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body))))
+	}
+	return nil
+}
+
+type mailgunWebhook struct {
+	Signature struct {
+		Timestamp string `json:"timestamp"`
+		Token     string `json:"token"`
+		Signature string `json:"signature"`
+	} `json:"signature"`
+	EventData struct {
+		Event string `json:"event"`
+		// Severity is permanent or temporary for failed deliveries
+		Severity       string  `json:"severity"`
+		Reason         string  `json:"reason"`
+		Recipient      string  `json:"recipient"`
+		Timestamp      float64 `json:"timestamp"`
+		DeliveryStatus struct {
+			Description string `json:"description"`
+			Message     string `json:"message"`
+		} `json:"delivery-status"`
+	} `json:"event-data"`
+}
+
+// Events verifies the HMAC of the timestamp and token, then reads the
+// event. Mailgun posts one event per request.
+func (p *MailgunProvider) Events(ctx context.Context, header http.Header, body []byte) ([]emaildelivery.Event, error) {
+	if p.signingKey == "" {
+		return nil, fmt.Errorf("%w: no signing key is configured", emaildelivery.ErrInvalidWebhook)
+	}
+	var webhook mailgunWebhook
+	if err := json.Unmarshal(body, &webhook); err != nil {
+		return nil, fmt.Errorf("%w: %v", emaildelivery.ErrInvalidWebhook, err)
+	}
+
+	mac := hmac.New(sha256.New, []byte(p.signingKey))
+	mac.Write([]byte(webhook.Signature.Timestamp + webhook.Signature.Token))
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(webhook.Signature.Signature)) {
+		return nil, fmt.Errorf("%w: signature mismatch", emaildelivery.ErrInvalidWebhook)
+	}
+
+	data := webhook.EventData
+	event := emaildelivery.Event{
+		Provider: p.Name(),
+		Email:    data.Recipient,
+		Detail:   firstNonEmpty(data.DeliveryStatus.Description, data.DeliveryStatus.Message, data.Reason),
+	}
+	if data.Timestamp > 0 {
+		seconds, fraction := math.Modf(data.Timestamp)
+		event.OccurredAt = time.Unix(int64(seconds), int64(fraction*1e9))
+	}
+	switch data.Event {
+	case "failed":
+		event.Type = emaildelivery.EventBounce
+		event.Permanent = data.Severity == "permanent"
+	case "complained":
+		event.Type = emaildelivery.EventComplaint
+	default:
+		return nil, nil
+	}
+	return []emaildelivery.Event{event}, nil
+}
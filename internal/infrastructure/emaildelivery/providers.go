@@ -0,0 +1,75 @@
+package emaildelivery
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"online-shop/internal/domain/emaildelivery"
+	"online-shop/pkg/config"
+)
+
+// Providers builds the providers listed in email.providers, in the order
+// they are tried.
+func Providers(cfg *config.Config) ([]emaildelivery.Provider, error) {
+	providers := make([]emaildelivery.Provider, 0, len(cfg.Email.Providers))
+	for _, name := range cfg.Email.Providers {
+		switch strings.ToLower(name) {
+		case "smtp":
+			providers = append(providers, NewSMTPProvider(&cfg.SMTP))
+		case "ses":
+			ses, err := NewSESProvider(&cfg.Email.SES)
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, ses)
+		case "sendgrid":
+			sendGrid, err := NewSendGridProvider(&cfg.Email.SendGrid)
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, sendGrid)
+		case "mailgun":
+			providers = append(providers, NewMailgunProvider(&cfg.Email.Mailgun))
+		default:
+			return nil, fmt.Errorf("unsupported email provider: %s", name)
+		}
+	}
+	return providers, nil
+}
+
+// Webhooks builds a webhook for every provider whose verification setting
+// is filled in. The SES webhook also needs the SES region and credentials.
+func Webhooks(cfg *config.EmailConfig) (map[string]emaildelivery.Webhook, error) {
+	webhooks := make(map[string]emaildelivery.Webhook)
+
+	if cfg.SES.TopicARN != "" {
+		ses, err := NewSESProvider(&cfg.SES)
+		if err != nil {
+			return nil, err
+		}
+		webhooks[ses.Name()] = ses
+	}
+	if cfg.SendGrid.WebhookPublicKey != "" {
+		sendGrid, err := NewSendGridProvider(&cfg.SendGrid)
+		if err != nil {
+			return nil, err
+		}
+		webhooks[sendGrid.Name()] = sendGrid
+	}
+	if cfg.Mailgun.WebhookSigningKey != "" {
+		mailgun := NewMailgunProvider(&cfg.Mailgun)
+		webhooks[mailgun.Name()] = mailgun
+	}
+	return webhooks, nil
+}
+
+// statusError marks an API failure permanent unless the status says to try
+// again later: throttling and server errors are retried, any other rejection
+// of the request is not.
+func statusError(status int, err error) error {
+	if status == http.StatusTooManyRequests || status >= 500 {
+		return err
+	}
+	return emaildelivery.Permanent(err)
+}
@@ -0,0 +1,150 @@
+package emaildelivery
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"online-shop/internal/domain/emaildelivery"
+	"online-shop/pkg/config"
+	"online-shop/pkg/httpclient"
+)
+
+const (
+	sendGridSignatureHeader = "X-Twilio-Email-Event-Webhook-Signature"
+	sendGridTimestampHeader = "X-Twilio-Email-Event-Webhook-Timestamp"
+)
+
+// SendGridProvider sends through the SendGrid v3 mail API. Its signed event
+// webhook reports bounces and spam reports.
+type SendGridProvider struct {
+	endpoint   string
+	apiKey     string
+	webhookKey *ecdsa.PublicKey
+	client     *http.Client
+}
+
+func NewSendGridProvider(cfg *config.SendGridConfig) (*SendGridProvider, error) {
+	p := &SendGridProvider{
+		endpoint: strings.TrimRight(firstNonEmpty(cfg.Endpoint, "https://api.sendgrid.com"), "/"),
+		apiKey:   cfg.APIKey,
+		client:   httpclient.New(httpclient.Options{Name: "sendgrid", Retries: httpclient.DefaultRetries}),
+	}
+	if cfg.WebhookPublicKey != "" {
+		der, err := base64.StdEncoding.DecodeString(cfg.WebhookPublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("sendgrid webhook public key must be base64: %w", err)
+		}
+		key, err := x509.ParsePKIXPublicKey(der)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sendgrid webhook public key: %w", err)
+		}
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return nil, errors.New("sendgrid webhook public key must be an ECDSA key")
+		}
+		p.webhookKey = ecKey
+	}
+	return p, nil
+}
+
+func (p *SendGridProvider) Name() string {
+	return "sendgrid"
+}
+
+func (p *SendGridProvider) Send(ctx context.Context, msg *emaildelivery.Message) error {
+	request := map[string]interface{}{
+		"personalizations": []map[string]interface{}{
+			{"to": []map[string]string{{"email": msg.To}}},
+		},
+		"from":    map[string]string{"email": msg.From},
+		"subject": msg.Subject,
+		"content": []map[string]string{{"type": "text/html", "value": msg.HTML}},
+	}
+	if len(msg.Headers) > 0 {
+		request["headers"] = msg.Headers
+	}
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint+"/v3/mail/send", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return statusError(resp.StatusCode, fmt.Errorf("sendgrid returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body))))
+	}
+	return nil
+}
+
+type sendGridEvent struct {
+	Email     string `json:"email"`
+	Timestamp int64  `json:"timestamp"`
+	Event     string `json:"event"`
+	// Type tells bounces ("bounce") from deferrals SendGrid gave up on
+	// ("blocked")
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+// Events verifies the ECDSA signature over the timestamp and body, then
+// reads the batch of events.
+func (p *SendGridProvider) Events(ctx context.Context, header http.Header, body []byte) ([]emaildelivery.Event, error) {
+	if p.webhookKey == nil {
+		return nil, fmt.Errorf("%w: no verification key is configured", emaildelivery.ErrInvalidWebhook)
+	}
+	signature, err := base64.StdEncoding.DecodeString(header.Get(sendGridSignatureHeader))
+	if err != nil || len(signature) == 0 {
+		return nil, fmt.Errorf("%w: missing signature", emaildelivery.ErrInvalidWebhook)
+	}
+	digest := sha256.Sum256(append([]byte(header.Get(sendGridTimestampHeader)), body...))
+	if !ecdsa.VerifyASN1(p.webhookKey, digest[:], signature) {
+		return nil, fmt.Errorf("%w: signature mismatch", emaildelivery.ErrInvalidWebhook)
+	}
+
+	var batch []sendGridEvent
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return nil, fmt.Errorf("%w: %v", emaildelivery.ErrInvalidWebhook, err)
+	}
+
+	var events []emaildelivery.Event
+	for _, e := range batch {
+		event := emaildelivery.Event{Provider: p.Name(), Email: e.Email, Detail: e.Reason}
+		if e.Timestamp > 0 {
+			event.OccurredAt = time.Unix(e.Timestamp, 0)
+		}
+		switch e.Event {
+		case "bounce":
+			event.Type = emaildelivery.EventBounce
+			event.Permanent = e.Type != "blocked"
+		case "spamreport":
+			event.Type = emaildelivery.EventComplaint
+		default:
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
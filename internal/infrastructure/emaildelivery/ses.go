@@ -0,0 +1,416 @@
+package emaildelivery
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"online-shop/internal/domain/emaildelivery"
+	"online-shop/pkg/config"
+	"online-shop/pkg/httpclient"
+)
+
+const sesService = "ses"
+
+// snsHost matches the hosts SNS serves signing certificates and
+// subscription links from
+var snsHost = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// SESProvider sends through the Amazon SES v2 API. Bounce and complaint
+// notifications reach its webhook through an SNS topic subscribed to the
+// webhook URL; the subscription is confirmed automatically.
+type SESProvider struct {
+	region           string
+	endpoint         string
+	accessKeyID      string
+	secretKey        string
+	sessionToken     string
+	configurationSet string
+	topicARN         string
+	client           *http.Client
+	now              func() time.Time
+
+	mu    sync.Mutex
+	certs map[string]*x509.Certificate
+}
+
+// NewSESProvider falls back to the standard AWS_* environment variables for
+// the credentials.
+func NewSESProvider(cfg *config.SESConfig) (*SESProvider, error) {
+	p := &SESProvider{
+		region:           cfg.Region,
+		endpoint:         cfg.Endpoint,
+		accessKeyID:      firstNonEmpty(cfg.AccessKeyID, os.Getenv("AWS_ACCESS_KEY_ID")),
+		secretKey:        firstNonEmpty(cfg.SecretAccessKey, os.Getenv("AWS_SECRET_ACCESS_KEY")),
+		sessionToken:     firstNonEmpty(cfg.SessionToken, os.Getenv("AWS_SESSION_TOKEN")),
+		configurationSet: cfg.ConfigurationSet,
+		topicARN:         cfg.TopicARN,
+		client:           httpclient.New(httpclient.Options{Name: "ses", Retries: httpclient.DefaultRetries}),
+		now:              time.Now,
+		certs:            make(map[string]*x509.Certificate),
+	}
+	if p.region == "" {
+		return nil, errors.New("ses region is required")
+	}
+	if p.accessKeyID == "" || p.secretKey == "" {
+		return nil, errors.New("ses credentials are required")
+	}
+	if p.endpoint == "" {
+		p.endpoint = fmt.Sprintf("https://email.%s.amazonaws.com", p.region)
+	}
+	p.endpoint = strings.TrimRight(p.endpoint, "/")
+	return p, nil
+}
+
+func (p *SESProvider) Name() string {
+	return "ses"
+}
+
+func (p *SESProvider) Send(ctx context.Context, msg *emaildelivery.Message) error {
+	simple := map[string]interface{}{
+		"Subject": map[string]string{"Data": msg.Subject, "Charset": "UTF-8"},
+		"Body": map[string]interface{}{
+			"Html": map[string]string{"Data": msg.HTML, "Charset": "UTF-8"},
+		},
+	}
+	if len(msg.Headers) > 0 {
+		headers := make([]map[string]string, 0, len(msg.Headers))
+		for name, value := range msg.Headers {
+			headers = append(headers, map[string]string{"Name": name, "Value": value})
+		}
+		simple["Headers"] = headers
+	}
+	request := map[string]interface{}{
+		"FromEmailAddress": msg.From,
+		"Destination":      map[string]interface{}{"ToAddresses": []string{msg.To}},
+		"Content":          map[string]interface{}{"Simple": simple},
+	}
+	if p.configurationSet != "" {
+		request["ConfigurationSetName"] = p.configurationSet
+	}
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint+"/v2/email/outbound-emails", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	p.sign(req, payload)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		var failure struct {
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(body, &failure)
+		return statusError(resp.StatusCode, fmt.Errorf("ses returned status %d: %s %s", resp.StatusCode, resp.Header.Get("X-Amzn-ErrorType"), failure.Message))
+	}
+	return nil
+}
+
+// snsMessage is the envelope SNS posts to HTTP subscriptions
+type snsMessage struct {
+	Type             string `json:"Type"`
+	MessageID        string `json:"MessageId"`
+	Token            string `json:"Token"`
+	TopicARN         string `json:"TopicArn"`
+	Subject          string `json:"Subject"`
+	Message          string `json:"Message"`
+	SubscribeURL     string `json:"SubscribeURL"`
+	Timestamp        string `json:"Timestamp"`
+	SignatureVersion string `json:"SignatureVersion"`
+	Signature        string `json:"Signature"`
+	SigningCertURL   string `json:"SigningCertURL"`
+}
+
+// sesNotification covers both SES notifications (notificationType) and
+// configuration set events (eventType)
+type sesNotification struct {
+	NotificationType string `json:"notificationType"`
+	EventType        string `json:"eventType"`
+	Bounce           struct {
+		BounceType        string    `json:"bounceType"`
+		BounceSubType     string    `json:"bounceSubType"`
+		Timestamp         time.Time `json:"timestamp"`
+		BouncedRecipients []struct {
+			EmailAddress   string `json:"emailAddress"`
+			DiagnosticCode string `json:"diagnosticCode"`
+		} `json:"bouncedRecipients"`
+	} `json:"bounce"`
+	Complaint struct {
+		ComplaintFeedbackType string    `json:"complaintFeedbackType"`
+		Timestamp             time.Time `json:"timestamp"`
+		ComplainedRecipients  []struct {
+			EmailAddress string `json:"emailAddress"`
+		} `json:"complainedRecipients"`
+	} `json:"complaint"`
+}
+
+// Events verifies the SNS signature and that the message comes from the
+// configured topic, then confirms subscriptions or reads the SES
+// notification.
+func (p *SESProvider) Events(ctx context.Context, header http.Header, body []byte) ([]emaildelivery.Event, error) {
+	var message snsMessage
+	if err := json.Unmarshal(body, &message); err != nil {
+		return nil, fmt.Errorf("%w: %v", emaildelivery.ErrInvalidWebhook, err)
+	}
+	if message.TopicARN != p.topicARN {
+		return nil, fmt.Errorf("%w: unexpected topic %q", emaildelivery.ErrInvalidWebhook, message.TopicARN)
+	}
+	if err := p.verify(ctx, &message); err != nil {
+		return nil, fmt.Errorf("%w: %v", emaildelivery.ErrInvalidWebhook, err)
+	}
+
+	switch message.Type {
+	case "SubscriptionConfirmation":
+		return nil, p.confirm(ctx, message.SubscribeURL)
+	case "Notification":
+	default:
+		return nil, nil
+	}
+
+	var notification sesNotification
+	if err := json.Unmarshal([]byte(message.Message), &notification); err != nil {
+		return nil, fmt.Errorf("%w: %v", emaildelivery.ErrInvalidWebhook, err)
+	}
+
+	var events []emaildelivery.Event
+	switch firstNonEmpty(notification.NotificationType, notification.EventType) {
+	case "Bounce":
+		bounce := notification.Bounce
+		for _, r := range bounce.BouncedRecipients {
+			events = append(events, emaildelivery.Event{
+				Provider:   p.Name(),
+				Type:       emaildelivery.EventBounce,
+				Email:      r.EmailAddress,
+				Permanent:  bounce.BounceType == "Permanent",
+				Detail:     firstNonEmpty(r.DiagnosticCode, bounce.BounceType+"/"+bounce.BounceSubType),
+				OccurredAt: bounce.Timestamp,
+			})
+		}
+	case "Complaint":
+		complaint := notification.Complaint
+		for _, r := range complaint.ComplainedRecipients {
+			events = append(events, emaildelivery.Event{
+				Provider:   p.Name(),
+				Type:       emaildelivery.EventComplaint,
+				Email:      r.EmailAddress,
+				Detail:     complaint.ComplaintFeedbackType,
+				OccurredAt: complaint.Timestamp,
+			})
+		}
+	}
+	return events, nil
+}
+
+// verify checks the message signature against the SNS signing certificate
+func (p *SESProvider) verify(ctx context.Context, message *snsMessage) error {
+	fields := []string{"Message", message.Message, "MessageId", message.MessageID}
+	if message.Type == "Notification" {
+		if message.Subject != "" {
+			fields = append(fields, "Subject", message.Subject)
+		}
+		fields = append(fields, "Timestamp", message.Timestamp, "TopicArn", message.TopicARN, "Type", message.Type)
+	} else {
+		fields = append(fields, "SubscribeURL", message.SubscribeURL, "Timestamp", message.Timestamp,
+			"Token", message.Token, "TopicArn", message.TopicARN, "Type", message.Type)
+	}
+	var stringToSign strings.Builder
+	for _, field := range fields {
+		stringToSign.WriteString(field + "\n")
+	}
+
+	var hash crypto.Hash
+	var digest []byte
+	switch message.SignatureVersion {
+	case "1":
+		sum := sha1.Sum([]byte(stringToSign.String()))
+		hash, digest = crypto.SHA1, sum[:]
+	case "2":
+		sum := sha256.Sum256([]byte(stringToSign.String()))
+		hash, digest = crypto.SHA256, sum[:]
+	default:
+		return fmt.Errorf("unsupported signature version %q", message.SignatureVersion)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(message.Signature)
+	if err != nil {
+		return err
+	}
+	cert, err := p.certificate(ctx, message.SigningCertURL)
+	if err != nil {
+		return err
+	}
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("signing certificate does not hold an RSA key")
+	}
+	return rsa.VerifyPKCS1v15(key, hash, digest, signature)
+}
+
+// certificate fetches a signing certificate once; SNS rotates them rarely
+func (p *SESProvider) certificate(ctx context.Context, certURL string) (*x509.Certificate, error) {
+	if err := checkSNSURL(certURL); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	cert, ok := p.certs[certURL]
+	p.mu.Unlock()
+	if ok {
+		return cert, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, certURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("signing certificate returned status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("signing certificate is not PEM encoded")
+	}
+	cert, err = x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.certs[certURL] = cert
+	p.mu.Unlock()
+	return cert, nil
+}
+
+// confirm visits the subscription link SNS sends once the webhook URL is
+// subscribed to the topic
+func (p *SESProvider) confirm(ctx context.Context, subscribeURL string) error {
+	if err := checkSNSURL(subscribeURL); err != nil {
+		return fmt.Errorf("%w: %v", emaildelivery.ErrInvalidWebhook, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, subscribeURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to confirm sns subscription: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("sns subscription confirmation returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func checkSNSURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || !snsHost.MatchString(u.Hostname()) {
+		return fmt.Errorf("%q is not an SNS URL", raw)
+	}
+	return nil
+}
+
+// sign adds an AWS Signature Version 4 authorization header
+func (p *SESProvider) sign(req *http.Request, payload []byte) {
+	now := p.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	headers := []string{"content-type", "host", "x-amz-date"}
+	if p.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", p.sessionToken)
+		headers = append(headers, "x-amz-security-token")
+		sort.Strings(headers)
+	}
+	var canonicalHeaders strings.Builder
+	for _, h := range headers {
+		value := req.Header.Get(h)
+		if h == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(h + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signedHeaders := strings.Join(headers, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"",
+		canonicalHeaders.String(),
+		signedHeaders,
+		hashHex(payload),
+	}, "\n")
+
+	scope := date + "/" + p.region + "/" + sesService + "/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hashHex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+p.secretKey), date)
+	key = hmacSHA256(key, p.region)
+	key = hmacSHA256(key, sesService)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		p.accessKeyID, scope, signedHeaders, signature))
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
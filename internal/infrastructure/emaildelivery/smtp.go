@@ -0,0 +1,157 @@
+package emaildelivery
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/smtp"
+	"net/textproto"
+	"sort"
+	"sync"
+
+	"online-shop/internal/domain/emaildelivery"
+	"online-shop/pkg/config"
+)
+
+// SMTPProvider sends through an SMTP relay. It has no webhook: bounces come
+// back to the sender's mailbox.
+type SMTPProvider struct {
+	config *config.SMTPConfig
+
+	mu       sync.RWMutex
+	password string
+}
+
+func NewSMTPProvider(cfg *config.SMTPConfig) *SMTPProvider {
+	return &SMTPProvider{config: cfg, password: cfg.Password}
+}
+
+func (p *SMTPProvider) Name() string {
+	return "smtp"
+}
+
+// SetPassword switches to a rotated SMTP password without a restart
+func (p *SMTPProvider) SetPassword(password string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.password = password
+}
+
+func (p *SMTPProvider) currentPassword() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.password
+}
+
+func (p *SMTPProvider) Send(ctx context.Context, msg *emaildelivery.Message) error {
+	// SMTP server address
+	addr := fmt.Sprintf("%s:%d", p.config.Host, p.config.Port)
+
+	// SMTP authentication (only if username is provided)
+	var auth smtp.Auth
+	if p.config.Username != "" {
+		auth = smtp.PlainAuth("",
+			p.config.Username,
+			p.currentPassword(),
+			p.config.Host,
+		)
+	}
+
+	// Send email with or without TLS
+	var err error
+	data := []byte(buildMessage(msg))
+	if p.config.UseTLS {
+		err = p.sendWithTLS(addr, auth, msg.From, []string{msg.To}, data)
+	} else {
+		err = smtp.SendMail(addr, auth, msg.From, []string{msg.To}, data)
+	}
+
+	if err != nil {
+		err = fmt.Errorf("failed to send email via SMTP: %w", err)
+		// 5xx replies are final, 4xx ones such as greylisting are not
+		var reply *textproto.Error
+		if errors.As(err, &reply) && reply.Code >= 500 {
+			return emaildelivery.Permanent(err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// sendWithTLS sends email using TLS connection
+func (p *SMTPProvider) sendWithTLS(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+	// Create TLS configuration
+	tlsConfig := &tls.Config{
+		ServerName: p.config.Host,
+	}
+
+	// Connect to SMTP server
+	conn, err := tls.Dial("tcp", addr, tlsConfig)
+	if err != nil {
+		return fmt.Errorf("failed to connect with TLS: %w", err)
+	}
+	defer conn.Close()
+
+	// Create SMTP client
+	client, err := smtp.NewClient(conn, p.config.Host)
+	if err != nil {
+		return fmt.Errorf("failed to create SMTP client: %w", err)
+	}
+	defer client.Quit()
+
+	// Authenticate if auth is provided
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	// Set sender
+	if err := client.Mail(from); err != nil {
+		return fmt.Errorf("failed to set sender: %w", err)
+	}
+
+	// Set recipients
+	for _, recipient := range to {
+		if err := client.Rcpt(recipient); err != nil {
+			return fmt.Errorf("failed to set recipient %s: %w", recipient, err)
+		}
+	}
+
+	// Send message
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to get data writer: %w", err)
+	}
+	defer writer.Close()
+
+	if _, err := writer.Write(msg); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+
+	return nil
+}
+
+// buildMessage builds the email message with headers
+func buildMessage(msg *emaildelivery.Message) string {
+	data := fmt.Sprintf("From: %s\r\n", msg.From)
+	data += fmt.Sprintf("To: %s\r\n", msg.To)
+	data += fmt.Sprintf("Subject: %s\r\n", msg.Subject)
+	data += "MIME-Version: 1.0\r\n"
+	data += "Content-Type: text/html; charset=UTF-8\r\n"
+	data += fmt.Sprintf("Content-Language: %s\r\n", msg.Locale)
+	names := make([]string, 0, len(msg.Headers))
+	for name := range msg.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		data += fmt.Sprintf("%s: %s\r\n", textproto.CanonicalMIMEHeaderKey(name), msg.Headers[name])
+	}
+	data += "\r\n"
+	data += msg.HTML
+
+	return data
+}
@@ -0,0 +1,125 @@
+package eventstore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"online-shop/internal/domain/analytics"
+
+	"go.uber.org/zap"
+)
+
+// maxBufferedBatches bounds how much a failing store can make us hold in
+// memory; beyond it the oldest events are dropped.
+const maxBufferedBatches = 10
+
+// BatchWriter buffers events in memory and hands them to the underlying
+// writer once the batch is full or the flush interval elapses. Events still
+// buffered when the process dies are lost, so Close must be called on
+// shutdown.
+type BatchWriter struct {
+	writer    analytics.Writer
+	batchSize int
+	interval  time.Duration
+	logger    *zap.Logger
+
+	mu     sync.Mutex
+	buffer []analytics.Event
+
+	started bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewBatchWriter creates a new batching event writer
+func NewBatchWriter(writer analytics.Writer, batchSize int, interval time.Duration, logger *zap.Logger) *BatchWriter {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	return &BatchWriter{
+		writer:    writer,
+		batchSize: batchSize,
+		interval:  interval,
+		logger:    logger,
+		buffer:    make([]analytics.Event, 0, batchSize),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start begins flushing on the configured interval
+func (b *BatchWriter) Start() {
+	b.started = true
+	go func() {
+		defer close(b.done)
+
+		ticker := time.NewTicker(b.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-b.stop:
+				return
+			case <-ticker.C:
+				if err := b.Flush(context.Background()); err != nil {
+					b.logger.Error("Failed to flush analytics events", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// Add buffers an event and flushes synchronously when the batch is full
+func (b *BatchWriter) Add(ctx context.Context, event analytics.Event) error {
+	b.mu.Lock()
+	b.buffer = append(b.buffer, event)
+	full := len(b.buffer) >= b.batchSize
+	b.mu.Unlock()
+
+	if full {
+		return b.Flush(ctx)
+	}
+	return nil
+}
+
+// Flush writes all buffered events. On failure the events are put back so
+// the next flush retries them.
+func (b *BatchWriter) Flush(ctx context.Context) error {
+	b.mu.Lock()
+	if len(b.buffer) == 0 {
+		b.mu.Unlock()
+		return nil
+	}
+	batch := b.buffer
+	b.buffer = make([]analytics.Event, 0, b.batchSize)
+	b.mu.Unlock()
+
+	if err := b.writer.Write(ctx, batch); err != nil {
+		b.mu.Lock()
+		b.buffer = append(batch, b.buffer...)
+		if limit := b.batchSize * maxBufferedBatches; len(b.buffer) > limit {
+			dropped := len(b.buffer) - limit
+			b.buffer = b.buffer[dropped:]
+			b.logger.Warn("Dropped analytics events after repeated flush failures", zap.Int("count", dropped))
+		}
+		b.mu.Unlock()
+		return err
+	}
+
+	b.logger.Debug("Flushed analytics events", zap.Int("count", len(batch)))
+	return nil
+}
+
+// Close stops the flush loop and writes any remaining events
+func (b *BatchWriter) Close(ctx context.Context) error {
+	if b.started {
+		close(b.stop)
+		<-b.done
+	}
+	return b.Flush(ctx)
+}
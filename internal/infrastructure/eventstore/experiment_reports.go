@@ -0,0 +1,52 @@
+package eventstore
+
+import (
+	"context"
+
+	"online-shop/internal/domain/analytics"
+	"online-shop/internal/domain/experiment"
+
+	"gorm.io/gorm"
+)
+
+// TimescaleExperimentReports answers experiment reports from the
+// analytics_events hypertable
+type TimescaleExperimentReports struct {
+	db *gorm.DB
+}
+
+func NewTimescaleExperimentReports(db *gorm.DB) experiment.ReportRepository {
+	return &TimescaleExperimentReports{db: db}
+}
+
+// VariantCounts attributes each visitor to the variant of their first
+// exposure in the range, and counts them as converted when a conversion
+// event follows that exposure.
+func (r *TimescaleExperimentReports) VariantCounts(ctx context.Context, e *experiment.Experiment, rng analytics.ReportRange) ([]experiment.VariantCounts, error) {
+	var counts []experiment.VariantCounts
+	err := r.db.WithContext(ctx).Raw(`
+		WITH exposures AS (
+			SELECT DISTINCT ON (visitor) visitor, variant, first_seen
+			FROM (
+				SELECT `+visitorExpr+` AS visitor, properties->>'variant' AS variant, occurred_at AS first_seen
+				FROM analytics_events
+				WHERE event_type = ? AND event_name = ? AND properties->>'experiment_key' = ?
+					AND occurred_at >= ? AND occurred_at < ?
+			) e
+			ORDER BY visitor, first_seen
+		), conversions AS (
+			SELECT `+visitorExpr+` AS visitor, MAX(occurred_at) AS last_converted
+			FROM analytics_events
+			WHERE event_name = ? AND occurred_at >= ? AND occurred_at < ?
+				AND (event_name <> ? OR properties->>'experiment_key' = ?)
+			GROUP BY 1
+		)
+		SELECT x.variant, COUNT(*) AS exposed, COUNT(c.visitor) AS converted
+		FROM exposures x
+		LEFT JOIN conversions c ON c.visitor = x.visitor AND c.last_converted >= x.first_seen
+		GROUP BY x.variant`,
+		analytics.EventTypeExperiment, analytics.EventExperimentExposure, e.Key, rng.From, rng.To,
+		e.ConversionEvent, rng.From, rng.To, analytics.EventExperimentConversion, e.Key,
+	).Scan(&counts).Error
+	return counts, err
+}
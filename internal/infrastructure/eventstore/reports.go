@@ -0,0 +1,206 @@
+package eventstore
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"online-shop/internal/domain/analytics"
+
+	"gorm.io/gorm"
+)
+
+// visitorExpr identifies a visitor across signed-in and anonymous events
+const visitorExpr = `COALESCE(NULLIF(user_id, ''), 'session:' || session_id)`
+
+// productSortColumns whitelists the columns TopProducts can order by
+var productSortColumns = map[string]string{
+	"views":      "views",
+	"cart":       "added_to_cart",
+	"units":      "units_sold",
+	"revenue":    "revenue",
+	"conversion": "conversion_rate",
+}
+
+// TimescaleReports answers admin reports from the analytics_events hypertable
+type TimescaleReports struct {
+	db *gorm.DB
+}
+
+// NewTimescaleReports creates a new report repository over the event store
+func NewTimescaleReports(db *gorm.DB) analytics.ReportRepository {
+	return &TimescaleReports{db: db}
+}
+
+func (r *TimescaleReports) Revenue(ctx context.Context, rng analytics.ReportRange) ([]analytics.RevenuePoint, error) {
+	var points []analytics.RevenuePoint
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT date_trunc(?, occurred_at) AS period,
+			COUNT(DISTINCT properties->>'order_id') AS orders,
+			COALESCE(SUM((properties->>'quantity')::int), 0) AS items,
+			COALESCE(SUM((properties->>'quantity')::numeric * (properties->>'price')::numeric), 0) AS revenue,
+			COALESCE(SUM((properties->>'commission')::numeric), 0) AS commission
+		FROM analytics_events
+		WHERE event_name = ? AND occurred_at >= ? AND occurred_at < ?
+		GROUP BY 1
+		ORDER BY 1`,
+		rng.Interval, analytics.EventProductPurchased, rng.From, rng.To,
+	).Scan(&points).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range points {
+		points[i].Commission = math.Round(points[i].Commission*100) / 100
+		points[i].MerchantPayout = math.Round((points[i].Revenue-points[i].Commission)*100) / 100
+		if points[i].Orders > 0 {
+			points[i].AverageOrderValue = points[i].Revenue / float64(points[i].Orders)
+		}
+	}
+	return points, nil
+}
+
+func (r *TimescaleReports) FunnelCounts(ctx context.Context, rng analytics.ReportRange, steps []string) (map[string]int64, error) {
+	var rows []struct {
+		EventName string
+		Visitors  int64
+	}
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT event_name, COUNT(DISTINCT `+visitorExpr+`) AS visitors
+		FROM analytics_events
+		WHERE event_name IN ? AND occurred_at >= ? AND occurred_at < ?
+		GROUP BY event_name`,
+		steps, rng.From, rng.To,
+	).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.EventName] = row.Visitors
+	}
+	return counts, nil
+}
+
+func (r *TimescaleReports) TopProducts(ctx context.Context, rng analytics.ReportRange, sortBy string, limit int) ([]analytics.ProductStat, error) {
+	column, ok := productSortColumns[sortBy]
+	if !ok {
+		column = productSortColumns["revenue"]
+	}
+
+	var stats []analytics.ProductStat
+	err := r.db.WithContext(ctx).Raw(fmt.Sprintf(`
+		SELECT product_id, views, added_to_cart, units_sold, revenue,
+			CASE WHEN views > 0 THEN units_sold::float / views ELSE 0 END AS conversion_rate
+		FROM (
+			SELECT properties->>'product_id' AS product_id,
+				COUNT(*) FILTER (WHERE event_name = ?) AS views,
+				COUNT(*) FILTER (WHERE event_name = ?) AS added_to_cart,
+				COALESCE(SUM((properties->>'quantity')::int) FILTER (WHERE event_name = ?), 0) AS units_sold,
+				COALESCE(SUM((properties->>'quantity')::numeric * (properties->>'price')::numeric) FILTER (WHERE event_name = ?), 0) AS revenue
+			FROM analytics_events
+			WHERE event_type = ? AND occurred_at >= ? AND occurred_at < ?
+			GROUP BY 1
+		) stats
+		ORDER BY %s DESC
+		LIMIT ?`, column),
+		analytics.EventProductViewed, analytics.EventProductAddedToCart,
+		analytics.EventProductPurchased, analytics.EventProductPurchased,
+		analytics.EventTypeProduct, rng.From, rng.To, limit,
+	).Scan(&stats).Error
+	return stats, err
+}
+
+func (r *TimescaleReports) UserActivity(ctx context.Context, rng analytics.ReportRange) ([]analytics.UserActivityPoint, error) {
+	var active []struct {
+		Period time.Time
+		Users  int64
+	}
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT date_trunc(?, occurred_at) AS period, COUNT(DISTINCT user_id) AS users
+		FROM analytics_events
+		WHERE user_id <> '' AND occurred_at >= ? AND occurred_at < ?
+		GROUP BY 1
+		ORDER BY 1`,
+		rng.Interval, rng.From, rng.To,
+	).Scan(&active).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var newUsers []struct {
+		Period time.Time
+		Users  int64
+	}
+	err = r.db.WithContext(ctx).Raw(`
+		SELECT date_trunc(?, first_seen) AS period, COUNT(*) AS users
+		FROM (
+			SELECT user_id, MIN(occurred_at) AS first_seen
+			FROM analytics_events
+			WHERE user_id <> ''
+			GROUP BY user_id
+		) users
+		WHERE first_seen >= ? AND first_seen < ?
+		GROUP BY 1`,
+		rng.Interval, rng.From, rng.To,
+	).Scan(&newUsers).Error
+	if err != nil {
+		return nil, err
+	}
+
+	joined := make(map[time.Time]int64, len(newUsers))
+	for _, row := range newUsers {
+		joined[row.Period] = row.Users
+	}
+
+	points := make([]analytics.UserActivityPoint, 0, len(active))
+	for _, row := range active {
+		points = append(points, analytics.UserActivityPoint{
+			Period:      row.Period,
+			ActiveUsers: row.Users,
+			NewUsers:    joined[row.Period],
+		})
+	}
+	return points, nil
+}
+
+func (r *TimescaleReports) CohortCells(ctx context.Context, rng analytics.ReportRange) ([]analytics.CohortCell, error) {
+	var cells []analytics.CohortCell
+	err := r.db.WithContext(ctx).Raw(`
+		WITH cohorts AS (
+			SELECT user_id, date_trunc(?, MIN(occurred_at)) AS cohort
+			FROM analytics_events
+			WHERE user_id <> ''
+			GROUP BY user_id
+		), activity AS (
+			SELECT DISTINCT user_id, date_trunc(?, occurred_at) AS period
+			FROM analytics_events
+			WHERE user_id <> '' AND occurred_at >= ? AND occurred_at < ?
+		)
+		SELECT c.cohort, a.period, COUNT(*) AS users
+		FROM cohorts c
+		JOIN activity a ON a.user_id = c.user_id
+		WHERE c.cohort >= date_trunc(?, ?::timestamptz)
+		GROUP BY 1, 2
+		ORDER BY 1, 2`,
+		rng.Interval, rng.Interval, rng.From, rng.To, rng.Interval, rng.From,
+	).Scan(&cells).Error
+	return cells, err
+}
+
+func (r *TimescaleReports) UnitsSold(ctx context.Context, rng analytics.ReportRange) ([]analytics.ProductUnits, error) {
+	var units []analytics.ProductUnits
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT properties->>'product_id' AS product_id,
+			COALESCE(properties->>'warehouse_id', '') AS warehouse_id,
+			COALESCE(SUM((properties->>'quantity')::int), 0) AS units
+		FROM analytics_events
+		WHERE event_name = ? AND occurred_at >= ? AND occurred_at < ?
+		GROUP BY 1, 2
+		ORDER BY 1, 2`,
+		analytics.EventProductPurchased, rng.From, rng.To,
+	).Scan(&units).Error
+	return units, err
+}
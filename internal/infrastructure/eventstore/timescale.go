@@ -0,0 +1,188 @@
+package eventstore
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/domain/analytics"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// timescaleSchema creates the events hypertable. The primary key includes the
+// time column because TimescaleDB requires it on partitioned tables; it also
+// makes redelivered events idempotent.
+const timescaleSchema = `
+CREATE TABLE IF NOT EXISTS analytics_events (
+	event_id    TEXT        NOT NULL,
+	event_type  TEXT        NOT NULL,
+	event_name  TEXT        NOT NULL,
+	user_id     TEXT,
+	session_id  TEXT,
+	properties  JSONB       NOT NULL DEFAULT '{}',
+	ip_address  TEXT,
+	user_agent  TEXT,
+	referrer    TEXT,
+	page_url    TEXT,
+	device_type TEXT,
+	platform    TEXT,
+	country     TEXT,
+	city        TEXT,
+	occurred_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (event_id, occurred_at)
+);
+SELECT create_hypertable('analytics_events', 'occurred_at', if_not_exists => TRUE);
+CREATE INDEX IF NOT EXISTS idx_analytics_events_name_time ON analytics_events (event_name, occurred_at DESC);
+CREATE INDEX IF NOT EXISTS idx_analytics_events_user_time ON analytics_events (user_id, occurred_at DESC);
+CREATE INDEX IF NOT EXISTS idx_analytics_events_experiment ON analytics_events ((properties->>'experiment_key'), occurred_at DESC) WHERE event_type = 'experiment';
+`
+
+type eventRow struct {
+	EventID    string                 `gorm:"column:event_id"`
+	EventType  string                 `gorm:"column:event_type"`
+	EventName  string                 `gorm:"column:event_name"`
+	UserID     string                 `gorm:"column:user_id"`
+	SessionID  string                 `gorm:"column:session_id"`
+	Properties map[string]interface{} `gorm:"column:properties;serializer:json"`
+	IPAddress  string                 `gorm:"column:ip_address"`
+	UserAgent  string                 `gorm:"column:user_agent"`
+	Referrer   string                 `gorm:"column:referrer"`
+	PageURL    string                 `gorm:"column:page_url"`
+	DeviceType string                 `gorm:"column:device_type"`
+	Platform   string                 `gorm:"column:platform"`
+	Country    string                 `gorm:"column:country"`
+	City       string                 `gorm:"column:city"`
+	OccurredAt time.Time              `gorm:"column:occurred_at"`
+}
+
+func (eventRow) TableName() string {
+	return "analytics_events"
+}
+
+// TimescaleWriter stores analytics events in a TimescaleDB hypertable
+type TimescaleWriter struct {
+	db *gorm.DB
+}
+
+// NewTimescaleWriter creates a new TimescaleDB event writer
+func NewTimescaleWriter(db *gorm.DB) *TimescaleWriter {
+	return &TimescaleWriter{db: db}
+}
+
+// Migrate creates the events hypertable and its indexes
+func (w *TimescaleWriter) Migrate() error {
+	return w.db.Exec(timescaleSchema).Error
+}
+
+// Write inserts events in a single batch, skipping ones already stored
+func (w *TimescaleWriter) Write(ctx context.Context, events []analytics.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	rows := make([]eventRow, 0, len(events))
+	for _, e := range events {
+		properties := e.Properties
+		if properties == nil {
+			properties = map[string]interface{}{}
+		}
+		rows = append(rows, eventRow{
+			EventID:    e.EventID,
+			EventType:  e.EventType,
+			EventName:  e.EventName,
+			UserID:     e.UserID,
+			SessionID:  e.SessionID,
+			Properties: properties,
+			IPAddress:  e.IPAddress,
+			UserAgent:  e.UserAgent,
+			Referrer:   e.Referrer,
+			PageURL:    e.PageURL,
+			DeviceType: e.DeviceType,
+			Platform:   e.Platform,
+			Country:    e.Country,
+			City:       e.City,
+			OccurredAt: e.Timestamp,
+		})
+	}
+
+	return w.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		CreateInBatches(rows, 500).Error
+}
+
+// TimescaleEventReader reads raw events back out of the hypertable
+type TimescaleEventReader struct {
+	db *gorm.DB
+}
+
+// NewTimescaleEventReader creates a new TimescaleDB event reader
+func NewTimescaleEventReader(db *gorm.DB) analytics.EventReader {
+	return &TimescaleEventReader{db: db}
+}
+
+// ListByUser returns every event recorded for a user, oldest first
+func (r *TimescaleEventReader) ListByUser(ctx context.Context, userID string) ([]analytics.Event, error) {
+	var rows []eventRow
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("occurred_at").
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return toEvents(rows), nil
+}
+
+// ListAfter pages through the events of a replay with a keyset on the time
+// and ID, the hypertable's primary key
+func (r *TimescaleEventReader) ListAfter(ctx context.Context, filter analytics.ReplayFilter, after *analytics.Event, limit int) ([]analytics.Event, error) {
+	query := r.db.WithContext(ctx).
+		Where("occurred_at >= ? AND occurred_at < ?", filter.From, filter.To)
+	if filter.EventType != "" {
+		query = query.Where("event_type = ?", filter.EventType)
+	}
+	if filter.ProductID != "" {
+		query = query.Where("properties->>'product_id' = ?", filter.ProductID)
+	}
+	if filter.OrderID != "" {
+		query = query.Where("properties->>'order_id' = ?", filter.OrderID)
+	}
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if after != nil {
+		query = query.Where("(occurred_at, event_id) > (?, ?)", after.Timestamp, after.EventID)
+	}
+
+	var rows []eventRow
+	if err := query.Order("occurred_at, event_id").Limit(limit).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return toEvents(rows), nil
+}
+
+func toEvents(rows []eventRow) []analytics.Event {
+	events := make([]analytics.Event, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, analytics.Event{
+			EventID:    row.EventID,
+			UserID:     row.UserID,
+			SessionID:  row.SessionID,
+			EventType:  row.EventType,
+			EventName:  row.EventName,
+			Properties: row.Properties,
+			Timestamp:  row.OccurredAt,
+			IPAddress:  row.IPAddress,
+			UserAgent:  row.UserAgent,
+			Referrer:   row.Referrer,
+			PageURL:    row.PageURL,
+			DeviceType: row.DeviceType,
+			Platform:   row.Platform,
+			Country:    row.Country,
+			City:       row.City,
+		})
+	}
+	return events
+}
@@ -0,0 +1,24 @@
+package geocoding
+
+import (
+	"fmt"
+	"strings"
+
+	"online-shop/internal/domain/shipping"
+	"online-shop/pkg/config"
+)
+
+// New builds the geocoder named by shipping.geocoder, or returns nil for
+// none.
+func New(cfg *config.ShippingConfig) (shipping.Geocoder, error) {
+	switch strings.ToLower(cfg.Geocoder) {
+	case "", "none":
+		return nil, nil
+	case "google":
+		return NewGoogleGeocoder(&cfg.Google), nil
+	case "nominatim":
+		return NewNominatimGeocoder(&cfg.Nominatim), nil
+	default:
+		return nil, fmt.Errorf("unsupported geocoder: %s", cfg.Geocoder)
+	}
+}
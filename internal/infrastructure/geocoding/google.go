@@ -0,0 +1,123 @@
+package geocoding
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/shipping"
+	"online-shop/pkg/config"
+	"online-shop/pkg/httpclient"
+)
+
+// GoogleGeocoder looks addresses up with the Google Maps Geocoding API,
+// restricted to the country of the address.
+type GoogleGeocoder struct {
+	endpoint string
+	apiKey   string
+	client   *http.Client
+}
+
+func NewGoogleGeocoder(cfg *config.GoogleGeocodingConfig) *GoogleGeocoder {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://maps.googleapis.com"
+	}
+	return &GoogleGeocoder{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		apiKey:   cfg.APIKey,
+		client:   httpclient.New(httpclient.Options{Name: "google-geocoding", Retries: httpclient.DefaultRetries}),
+	}
+}
+
+func (g *GoogleGeocoder) Name() string {
+	return "google"
+}
+
+type googleResponse struct {
+	Status       string `json:"status"`
+	ErrorMessage string `json:"error_message"`
+	Results      []struct {
+		FormattedAddress  string `json:"formatted_address"`
+		AddressComponents []struct {
+			LongName  string   `json:"long_name"`
+			ShortName string   `json:"short_name"`
+			Types     []string `json:"types"`
+		} `json:"address_components"`
+		Geometry struct {
+			Location struct {
+				Lat float64 `json:"lat"`
+				Lng float64 `json:"lng"`
+			} `json:"location"`
+		} `json:"geometry"`
+	} `json:"results"`
+}
+
+func (g *GoogleGeocoder) Geocode(ctx context.Context, a order.Address) (*shipping.Location, error) {
+	query := url.Values{}
+	query.Set("address", strings.Join(nonEmpty(a.Street, a.City, a.State, a.PostalCode), ", "))
+	query.Set("components", "country:"+a.Country)
+	query.Set("key", g.apiKey)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.endpoint+"/maps/api/geocode/json?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("google geocoding returned status %d", resp.StatusCode)
+	}
+
+	var body googleResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	switch body.Status {
+	case "OK":
+	case "ZERO_RESULTS":
+		return nil, shipping.ErrAddressNotFound
+	default:
+		return nil, fmt.Errorf("google geocoding answered %s: %s", body.Status, body.ErrorMessage)
+	}
+	if len(body.Results) == 0 {
+		return nil, shipping.ErrAddressNotFound
+	}
+
+	result := body.Results[0]
+	location := &shipping.Location{
+		Latitude:         result.Geometry.Location.Lat,
+		Longitude:        result.Geometry.Location.Lng,
+		FormattedAddress: result.FormattedAddress,
+	}
+	for _, c := range result.AddressComponents {
+		for _, t := range c.Types {
+			switch t {
+			case "country":
+				location.Country = c.ShortName
+			case "administrative_area_level_1":
+				location.State = c.LongName
+			case "postal_code":
+				location.PostalCode = c.LongName
+			}
+		}
+	}
+	return location, nil
+}
+
+func nonEmpty(values ...string) []string {
+	kept := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
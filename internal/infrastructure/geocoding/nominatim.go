@@ -0,0 +1,107 @@
+package geocoding
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/shipping"
+	"online-shop/pkg/config"
+	"online-shop/pkg/httpclient"
+)
+
+// NominatimGeocoder looks addresses up with the structured search of an
+// OpenStreetMap Nominatim server.
+type NominatimGeocoder struct {
+	endpoint  string
+	userAgent string
+	email     string
+	client    *http.Client
+}
+
+func NewNominatimGeocoder(cfg *config.NominatimConfig) *NominatimGeocoder {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://nominatim.openstreetmap.org"
+	}
+	return &NominatimGeocoder{
+		endpoint:  strings.TrimRight(endpoint, "/"),
+		userAgent: cfg.UserAgent,
+		email:     cfg.Email,
+		client:    httpclient.New(httpclient.Options{Name: "nominatim", Retries: httpclient.DefaultRetries}),
+	}
+}
+
+func (g *NominatimGeocoder) Name() string {
+	return "nominatim"
+}
+
+type nominatimPlace struct {
+	Lat         string `json:"lat"`
+	Lon         string `json:"lon"`
+	DisplayName string `json:"display_name"`
+	Address     struct {
+		CountryCode string `json:"country_code"`
+		State       string `json:"state"`
+		Postcode    string `json:"postcode"`
+	} `json:"address"`
+}
+
+func (g *NominatimGeocoder) Geocode(ctx context.Context, a order.Address) (*shipping.Location, error) {
+	query := url.Values{}
+	query.Set("format", "jsonv2")
+	query.Set("addressdetails", "1")
+	query.Set("limit", "1")
+	query.Set("street", a.Street)
+	query.Set("city", a.City)
+	if a.State != "" {
+		query.Set("state", a.State)
+	}
+	query.Set("postalcode", a.PostalCode)
+	query.Set("countrycodes", strings.ToLower(a.Country))
+	if g.email != "" {
+		query.Set("email", g.email)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.endpoint+"/search?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", g.userAgent)
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("nominatim returned status %d", resp.StatusCode)
+	}
+
+	var places []nominatimPlace
+	if err := json.NewDecoder(resp.Body).Decode(&places); err != nil {
+		return nil, err
+	}
+	if len(places) == 0 {
+		return nil, shipping.ErrAddressNotFound
+	}
+
+	place := places[0]
+	location := &shipping.Location{
+		FormattedAddress: place.DisplayName,
+		Country:          strings.ToUpper(place.Address.CountryCode),
+		State:            place.Address.State,
+		PostalCode:       place.Address.Postcode,
+	}
+	if location.Latitude, err = strconv.ParseFloat(place.Lat, 64); err != nil {
+		return nil, fmt.Errorf("nominatim returned latitude %q", place.Lat)
+	}
+	if location.Longitude, err = strconv.ParseFloat(place.Lon, 64); err != nil {
+		return nil, fmt.Errorf("nominatim returned longitude %q", place.Lon)
+	}
+	return location, nil
+}
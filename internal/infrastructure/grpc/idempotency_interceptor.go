@@ -0,0 +1,93 @@
+package grpc
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"online-shop/internal/domain/idempotency"
+	pb "online-shop/online-shop/proto/order"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// IdempotencyKeyMetadata is the gRPC equivalent of the Idempotency-Key header
+const IdempotencyKeyMetadata = "idempotency-key"
+
+// IdempotentMethods lists the RPCs that honour idempotency keys, with a
+// constructor for the response type used when replaying.
+var IdempotentMethods = map[string]func() proto.Message{
+	"/order.OrderService/CreateOrder":    func() proto.Message { return &pb.CreateOrderResponse{} },
+	"/order.OrderService/ProcessPayment": func() proto.Message { return &pb.ProcessPaymentResponse{} },
+}
+
+// IdempotencyInterceptor replays the stored response for repeated calls that
+// carry the same idempotency-key metadata within the TTL.
+func IdempotencyInterceptor(store idempotency.Store, ttl time.Duration, logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		newResponse, ok := IdempotentMethods[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		keys := md.Get(IdempotencyKeyMetadata)
+		if len(keys) == 0 || keys[0] == "" {
+			return handler(ctx, req)
+		}
+
+		payload, err := proto.MarshalOptions{Deterministic: true}.Marshal(req.(proto.Message))
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "Failed to read request")
+		}
+
+		record := idempotency.NewRecord(info.FullMethod, keys[0], payload, ttl)
+		existing, err := store.Reserve(ctx, record, ttl)
+		if err != nil {
+			logger.Error("Failed to check idempotency key", zap.Error(err))
+			return nil, status.Error(codes.Unavailable, "Failed to check idempotency key")
+		}
+
+		if existing != nil {
+			switch existing.Check(record.RequestHash) {
+			case idempotency.ErrKeyMismatch:
+				return nil, status.Error(codes.FailedPrecondition, idempotency.ErrKeyMismatch.Error())
+			case idempotency.ErrKeyInProgress:
+				return nil, status.Error(codes.Aborted, idempotency.ErrKeyInProgress.Error())
+			}
+
+			resp := newResponse()
+			if err := proto.Unmarshal(existing.Body, resp); err != nil {
+				return nil, status.Error(codes.Internal, "Failed to replay response")
+			}
+			grpc.SetHeader(ctx, metadata.Pairs("idempotent-replayed", "true"))
+			return resp, nil
+		}
+
+		resp, err := handler(ctx, req)
+		if err != nil {
+			// Failed calls are not stored so the client can retry them
+			store.Release(context.Background(), record.Key)
+			return resp, err
+		}
+
+		body, err := proto.Marshal(resp.(proto.Message))
+		if err != nil {
+			store.Release(context.Background(), record.Key)
+			return resp, nil
+		}
+		record.StatusCode = http.StatusOK
+		record.Body = body
+		record.ContentType = "application/grpc+proto"
+		if err := store.Complete(context.Background(), record, ttl); err != nil {
+			logger.Warn("Failed to store idempotent response", zap.Error(err))
+		}
+
+		return resp, nil
+	}
+}
@@ -3,17 +3,21 @@ package grpc
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
+	"online-shop/internal/application/commands"
 	"online-shop/internal/domain/order"
 	paymentDomain "online-shop/internal/domain/payment"
 	"online-shop/internal/infrastructure/redis"
 	"online-shop/internal/infrastructure/database"
 	"online-shop/internal/infrastructure/payment"
 	pb "online-shop/online-shop/proto/order"
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/pagination"
 	"go.uber.org/zap"
 
-	"github.com/google/uuid"
+	"online-shop/pkg/id"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
@@ -27,6 +31,10 @@ type OrderServiceServer struct {
 	paymentRepo     *database.PaymentRepository
 	cacheClient     *redis.RedisClient
 	paymentProvider *payment.MidtransProvider
+	payOnDelivery   *commands.PayOnDeliveryCommandHandler
+	cancelOrder     *commands.CancelOrderCommandHandler
+	numbers         order.NumberGenerator
+	webhooks        *commands.WebhookPublisher
 	logger          *zap.Logger
 }
 
@@ -37,6 +45,10 @@ func NewOrderServiceServer(
 	paymentRepo *database.PaymentRepository,
 	cacheClient *redis.RedisClient,
 	paymentProvider *payment.MidtransProvider,
+	payOnDelivery *commands.PayOnDeliveryCommandHandler,
+	cancelOrder *commands.CancelOrderCommandHandler,
+	numbers order.NumberGenerator,
+	webhooks *commands.WebhookPublisher,
 	logger *zap.Logger,
 ) *OrderServiceServer {
 	return &OrderServiceServer{
@@ -46,6 +58,10 @@ func NewOrderServiceServer(
 		paymentRepo:     paymentRepo,
 		cacheClient:     cacheClient,
 		paymentProvider: paymentProvider,
+		payOnDelivery:   payOnDelivery,
+		cancelOrder:     cancelOrder,
+		numbers:         numbers,
+		webhooks:        webhooks,
 		logger:          logger,
 	}
 }
@@ -55,24 +71,18 @@ func (s *OrderServiceServer) CreateOrder(ctx context.Context, req *pb.CreateOrde
 
 	// Validate input
 	if req.UserId == "" || len(req.Items) == 0 {
-		return &pb.CreateOrderResponse{
-			Success: false,
-			Message: "User ID and items are required",
-		}, nil
+		return nil, apperror.ErrInvalidRequest.WithDetail("user ID and items are required")
 	}
 
 	// Verify user exists
-	user, err := s.userRepo.GetByID(req.UserId)
+	user, err := s.userRepo.GetByID(ctx, req.UserId)
 	if err != nil || user == nil {
-		return &pb.CreateOrderResponse{
-			Success: false,
-			Message: "User not found",
-		}, nil
+		return nil, commands.ErrUserNotFound
 	}
 
 	// Create order entity
 	orderEntity := &order.Order{
-		ID:     uuid.New().String(),
+		ID:     id.New(),
 		UserID: req.UserId,
 		Status: order.StatusPending,
 		ShippingAddress: order.Address{
@@ -81,6 +91,10 @@ func (s *OrderServiceServer) CreateOrder(ctx context.Context, req *pb.CreateOrde
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
+	if orderEntity.Number, err = s.numbers.Next(ctx, orderEntity.CreatedAt); err != nil {
+		s.logger.Error("Failed to number order", zap.Error(err))
+		return nil, status.Error(codes.Internal, "Failed to create order")
+	}
 
 	var totalAmount float64
 	var orderItems []*order.OrderItem
@@ -88,30 +102,26 @@ func (s *OrderServiceServer) CreateOrder(ctx context.Context, req *pb.CreateOrde
 	// Process each item
 	for _, item := range req.Items {
 		// Get product details
-		product, err := s.productRepo.GetByID(item.ProductId)
+		product, err := s.productRepo.GetByID(ctx, item.ProductId)
 		if err != nil || product == nil {
-			return &pb.CreateOrderResponse{
-				Success: false,
-				Message: fmt.Sprintf("Product %s not found", item.ProductId),
-			}, nil
+			return nil, commands.ErrProductNotFound.WithDetail("product %s not found", item.ProductId)
 		}
 
 		// Check stock availability
 		if product.Stock < int(item.Quantity) {
-			return &pb.CreateOrderResponse{
-				Success: false,
-				Message: fmt.Sprintf("Insufficient stock for product %s", product.Name),
-			}, nil
+			return nil, commands.ErrInsufficientStock.WithDetail("insufficient stock for product %s", product.Name)
 		}
 
 		// Create order item
 		orderItem := &order.OrderItem{
-			ID:        uuid.New().String(),
+			ID:        id.New(),
 			OrderID:   orderEntity.ID,
 			ProductID: item.ProductId,
+			MerchantID: product.MerchantID,
 			Price:     product.Price,
 			Quantity:  int(item.Quantity),
 			Subtotal:  product.Price * float64(item.Quantity),
+			Product:   order.NewProductSnapshot(product),
 		}
 
 		orderItems = append(orderItems, orderItem)
@@ -119,7 +129,7 @@ func (s *OrderServiceServer) CreateOrder(ctx context.Context, req *pb.CreateOrde
 
 		// Update product stock
 		product.Stock -= int(item.Quantity)
-		if err := s.productRepo.Update(product); err != nil {
+		if err := s.productRepo.Update(ctx, product); err != nil {
 			s.logger.Error("Failed to update product stock", zap.String("product_id", product.ID), zap.Error(err))
 			return nil, status.Error(codes.Internal, "Failed to update product stock")
 		}
@@ -138,14 +148,25 @@ func (s *OrderServiceServer) CreateOrder(ctx context.Context, req *pb.CreateOrde
 	}
 
 	// Save order to database
-	if err := s.orderRepo.Create(orderEntity); err != nil {
+	if err := s.orderRepo.Create(ctx, orderEntity); err != nil {
 		s.logger.Error("Failed to create order", zap.Error(err))
 		return nil, status.Error(codes.Internal, "Failed to create order")
 	}
 
+	// Cash on delivery is collected by the courier; a customer over their
+	// limits is refused and the order is left pending to be paid online
+	if req.PaymentMethod == string(paymentDomain.MethodCashOnDelivery) {
+		if _, err := s.payOnDelivery.Handle(ctx, commands.PayOnDeliveryCommand{OrderID: orderEntity.ID, UserID: req.UserId}); err != nil {
+			return nil, err
+		}
+		if orderEntity, err = s.orderRepo.GetByID(ctx, orderEntity.ID); err != nil {
+			return nil, status.Error(codes.Internal, "Failed to load order")
+		}
+	}
+
 	// Create payment with Midtrans
 	var paymentURL string
-	if req.PaymentMethod != "cash_on_delivery" {
+	if req.PaymentMethod != string(paymentDomain.MethodCashOnDelivery) {
 		// Create payment entity
 		paymentEntity := paymentDomain.NewPayment(
 			orderEntity.ID,
@@ -155,7 +176,7 @@ func (s *OrderServiceServer) CreateOrder(ctx context.Context, req *pb.CreateOrde
 		)
 
 		// Create payment with provider
-		paymentResp, err := s.paymentProvider.CreatePayment(paymentEntity)
+		paymentResp, err := s.paymentProvider.CreatePayment(ctx, paymentEntity)
 		if err != nil {
 			s.logger.Error("Failed to create payment", zap.Error(err))
 			// Don't fail the order creation, just log the error
@@ -169,16 +190,23 @@ func (s *OrderServiceServer) CreateOrder(ctx context.Context, req *pb.CreateOrde
 			paymentEntity.ExpiresAt = paymentResp.ExpiresAt
 
 			// Save payment to database
-			if err := s.paymentRepo.Create(paymentEntity); err != nil {
+			if err := s.paymentRepo.Create(ctx, paymentEntity); err != nil {
 				s.logger.Error("Failed to save payment", zap.Error(err))
 			} else {
 				// Update order with payment ID
 				orderEntity.PaymentID = paymentEntity.ID
-				s.orderRepo.Update(orderEntity)
+				s.orderRepo.Update(ctx, orderEntity)
 			}
 		}
 	}
 
+	// Tell the third parties subscribed to new orders
+	if s.webhooks != nil {
+		if err := s.webhooks.OrderCreated(ctx, orderEntity); err != nil {
+			s.logger.Warn("Failed to publish order webhook", zap.Error(err))
+		}
+	}
+
 	// Cache order
 	orderKey := fmt.Sprintf("order:%s", orderEntity.ID)
 	if err := s.cacheClient.Set(orderKey, orderEntity, 24*time.Hour); err != nil {
@@ -191,8 +219,6 @@ func (s *OrderServiceServer) CreateOrder(ctx context.Context, req *pb.CreateOrde
 	s.logger.Info("Order created successfully", zap.String("order_id", orderEntity.ID), zap.String("user_id", req.UserId), zap.Float64("total_amount", totalAmount))
 
 	return &pb.CreateOrderResponse{
-		Success:    true,
-		Message:    "Order created successfully",
 		Order:      s.entityToProto(orderEntity),
 		PaymentUrl: paymentURL,
 	}, nil
@@ -208,12 +234,9 @@ func (s *OrderServiceServer) GetOrder(ctx context.Context, req *pb.GetOrderReque
 	if err := s.cacheClient.Get(orderKey, &orderEntity); err != nil {
 		// Cache miss, get from database
 		var err error
-		orderEntity, err = s.orderRepo.GetByID(req.OrderId)
+		orderEntity, err = s.orderRepo.GetByID(ctx, req.OrderId)
 		if err != nil || orderEntity == nil {
-			return &pb.GetOrderResponse{
-				Success: false,
-				Message: "Order not found",
-			}, nil
+			return nil, commands.ErrOrderNotFound
 		}
 
 		// Cache the order
@@ -224,37 +247,45 @@ func (s *OrderServiceServer) GetOrder(ctx context.Context, req *pb.GetOrderReque
 
 	// Check if user has access to this order
 	if orderEntity.UserID != req.UserId {
-		return &pb.GetOrderResponse{
-			Success: false,
-			Message: "Access denied",
-		}, nil
+		return nil, commands.ErrOrderAccessDenied
 	}
 
 	return &pb.GetOrderResponse{
-		Success: true,
-		Message: "Order retrieved successfully",
-		Order:   s.entityToProto(orderEntity),
+		Order: s.entityToProto(orderEntity),
 	}, nil
 }
 
 func (s *OrderServiceServer) GetUserOrders(ctx context.Context, req *pb.GetUserOrdersRequest) (*pb.GetUserOrdersResponse, error) {
 	s.logger.Info("Get user orders request", zap.String("user_id", req.UserId))
 
-	// Set default values
-	limit := int(req.Limit)
-	if limit <= 0 || limit > 100 {
-		limit = 20
+	page, err := pageFromRequest(req.Page, req.Limit, req.Offset)
+	if err != nil {
+		return nil, err
+	}
+	limit, offset := page.Limit(), page.Offset()
+
+	statuses, err := order.ParseStatuses(req.Status)
+	if err != nil {
+		return nil, err
+	}
+	filter := order.ListFilter{
+		Statuses:    statuses,
+		UserID:      req.UserId,
+		ProductName: strings.TrimSpace(req.ProductName),
+	}
+	if req.From != nil {
+		filter.From = req.From.AsTime()
 	}
-	offset := int(req.Offset)
-	if offset < 0 {
-		offset = 0
+	if req.To != nil {
+		filter.To = req.To.AsTime()
 	}
 
 	// Try cache first
-	cacheKey := fmt.Sprintf("user_orders:%s:%d:%d:%s", req.UserId, limit, offset, req.Status)
+	cacheKey := fmt.Sprintf("user_orders:%s:%d:%d:%s:%d:%d:%s", req.UserId, limit, offset,
+		req.Status, filter.From.Unix(), filter.To.Unix(), strings.ToLower(filter.ProductName))
 	var cachedResult struct {
 		Orders []*order.Order `json:"orders"`
-		Total  int64           `json:"total"`
+		Total  int64          `json:"total"`
 	}
 
 	if err := s.cacheClient.Get(cacheKey, &cachedResult); err == nil {
@@ -265,52 +296,35 @@ func (s *OrderServiceServer) GetUserOrders(ctx context.Context, req *pb.GetUserO
 		}
 
 		return &pb.GetUserOrdersResponse{
-			Success: true,
-			Message: "Orders retrieved successfully",
-			Orders:  protoOrders,
-			Total:   cachedResult.Total,
+			Orders: protoOrders,
+			Meta:   pageMetaToProto(page.Meta(len(cachedResult.Orders), pagination.Total(cachedResult.Total))),
 		}, nil
 	}
 
-	// Get from database
-	orders, err := s.orderRepo.GetByUserID(req.UserId, limit, offset)
+	// Get from database, filtered and counted there so a page is always full
+	// and the total is exact
+	orders, total, err := s.orderRepo.ListByFilter(ctx, filter, limit, offset)
 	if err != nil {
 		s.logger.Error("Failed to get user orders", zap.Error(err))
 		return nil, status.Error(codes.Internal, "Failed to get user orders")
 	}
 
-	// Filter by status if provided
-	var filteredOrders []*order.Order
-	if req.Status != "" {
-		for _, o := range orders {
-			if string(o.Status) == req.Status {
-				filteredOrders = append(filteredOrders, o)
-			}
-		}
-	} else {
-		filteredOrders = orders
-	}
-
-	total := int64(len(filteredOrders))
-
 	// Cache the result
-	cachedResult.Orders = filteredOrders
+	cachedResult.Orders = orders
 	cachedResult.Total = total
 	if err := s.cacheClient.Set(cacheKey, cachedResult, 10*time.Minute); err != nil {
 		s.logger.Warn("Failed to cache user orders", zap.Error(err))
 	}
 
 	// Convert to proto
-	protoOrders := make([]*pb.Order, len(filteredOrders))
-	for i, order := range filteredOrders {
+	protoOrders := make([]*pb.Order, len(orders))
+	for i, order := range orders {
 		protoOrders[i] = s.entityToProto(order)
 	}
 
 	return &pb.GetUserOrdersResponse{
-		Success: true,
-		Message: "Orders retrieved successfully",
-		Orders:  protoOrders,
-		Total:   total,
+		Orders: protoOrders,
+		Meta:   pageMetaToProto(page.Meta(len(orders), pagination.Total(total))),
 	}, nil
 }
 
@@ -318,12 +332,9 @@ func (s *OrderServiceServer) UpdateOrderStatus(ctx context.Context, req *pb.Upda
 	s.logger.Info("Update order status request", zap.String("order_id", req.OrderId), zap.String("status", req.Status), zap.String("updated_by", req.UpdatedBy))
 
 	// Get order from database
-	orderEntity, err := s.orderRepo.GetByID(req.OrderId)
+	orderEntity, err := s.orderRepo.GetByID(ctx, req.OrderId)
 	if err != nil || orderEntity == nil {
-		return &pb.UpdateOrderStatusResponse{
-			Success: false,
-			Message: "Order not found",
-		}, nil
+		return nil, commands.ErrOrderNotFound
 	}
 
 	// Validate status transition
@@ -337,10 +348,7 @@ func (s *OrderServiceServer) UpdateOrderStatus(ctx context.Context, req *pb.Upda
 	}
 
 	if !isValidStatus {
-		return &pb.UpdateOrderStatusResponse{
-			Success: false,
-			Message: "Invalid order status",
-		}, nil
+		return nil, commands.ErrInvalidOrderData.WithDetail("invalid order status %q", req.Status)
 	}
 
 	// Update order status
@@ -348,7 +356,7 @@ func (s *OrderServiceServer) UpdateOrderStatus(ctx context.Context, req *pb.Upda
 	orderEntity.UpdatedAt = time.Now()
 
 	// Save to database
-	if err := s.orderRepo.Update(orderEntity); err != nil {
+	if err := s.orderRepo.Update(ctx, orderEntity); err != nil {
 		s.logger.Error("Failed to update order status", zap.Error(err))
 		return nil, status.Error(codes.Internal, "Failed to update order status")
 	}
@@ -365,67 +373,37 @@ func (s *OrderServiceServer) UpdateOrderStatus(ctx context.Context, req *pb.Upda
 	s.logger.Info("Order status updated successfully", zap.String("order_id", orderEntity.ID), zap.String("new_status", string(orderEntity.Status)))
 
 	return &pb.UpdateOrderStatusResponse{
-		Success: true,
-		Message: "Order status updated successfully",
-		Order:   s.entityToProto(orderEntity),
+		Order: s.entityToProto(orderEntity),
 	}, nil
 }
 
 func (s *OrderServiceServer) CancelOrder(ctx context.Context, req *pb.CancelOrderRequest) (*pb.CancelOrderResponse, error) {
 	s.logger.Info("Cancel order request", zap.String("order_id", req.OrderId), zap.String("user_id", req.UserId), zap.String("reason", req.Reason))
 
-	// Get order from database
-	orderEntity, err := s.orderRepo.GetByID(req.OrderId)
-	if err != nil || orderEntity == nil {
-		return &pb.CancelOrderResponse{
-			Success: false,
-			Message: "Order not found",
-		}, nil
-	}
-
-	// Check if user has access to this order
-	if orderEntity.UserID != req.UserId {
-		return &pb.CancelOrderResponse{
-			Success: false,
-			Message: "Access denied",
-		}, nil
+	// The cancellation policy prices it and pays the customer back; the
+	// free text reason is kept as the note
+	err := s.cancelOrder.Handle(ctx, commands.CancelOrderCommand{
+		OrderID: req.OrderId,
+		UserID:  req.UserId,
+		Reason:  order.CancelReasonOther,
+		Note:    req.Reason,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Check if order can be cancelled
-	if orderEntity.Status == order.StatusDelivered || orderEntity.Status == order.StatusCancelled {
-		return &pb.CancelOrderResponse{
-			Success: false,
-			Message: "Order cannot be cancelled",
-		}, nil
+	orderEntity, err := s.orderRepo.GetByID(ctx, req.OrderId)
+	if err != nil {
+		return nil, commands.ErrOrderNotFound
 	}
 
-	// Restore product stock
+	// Restored stock changes the cached products
 	for _, item := range orderEntity.Items {
-		product, err := s.productRepo.GetByID(item.ProductID)
-		if err == nil && product != nil {
-			product.Stock += item.Quantity
-			if err := s.productRepo.Update(product); err != nil {
-				s.logger.Error("Failed to restore product stock", zap.String("product_id", product.ID), zap.Error(err))
-			}
-
-			// Update product cache
-			productKey := fmt.Sprintf("product:%s", product.ID)
-			if err := s.cacheClient.Set(productKey, product, 24*time.Hour); err != nil {
-				s.logger.Warn("Failed to update product cache", zap.Error(err))
-			}
+		if err := s.cacheClient.Delete(fmt.Sprintf("product:%s", item.ProductID)); err != nil {
+			s.logger.Warn("Failed to invalidate product cache", zap.Error(err))
 		}
 	}
 
-	// Update order status
-	orderEntity.Status = order.StatusCancelled
-	orderEntity.UpdatedAt = time.Now()
-
-	// Save to database
-	if err := s.orderRepo.Update(orderEntity); err != nil {
-		s.logger.Error("Failed to cancel order", zap.Error(err))
-		return nil, status.Error(codes.Internal, "Failed to cancel order")
-	}
-
 	// Update cache
 	orderKey := fmt.Sprintf("order:%s", orderEntity.ID)
 	if err := s.cacheClient.Set(orderKey, orderEntity, 24*time.Hour); err != nil {
@@ -438,9 +416,7 @@ func (s *OrderServiceServer) CancelOrder(ctx context.Context, req *pb.CancelOrde
 	s.logger.Info("Order cancelled successfully", zap.String("order_id", orderEntity.ID), zap.String("reason", req.Reason))
 
 	return &pb.CancelOrderResponse{
-		Success: true,
-		Message: "Order cancelled successfully",
-		Order:   s.entityToProto(orderEntity),
+		Order: s.entityToProto(orderEntity),
 	}, nil
 }
 
@@ -448,23 +424,16 @@ func (s *OrderServiceServer) ProcessPayment(ctx context.Context, req *pb.Process
 	s.logger.Info("Process payment request", zap.String("order_id", req.OrderId), zap.String("payment_method", req.PaymentMethod))
 
 	// Get order from database
-	orderEntity, err := s.orderRepo.GetByID(req.OrderId)
+	orderEntity, err := s.orderRepo.GetByID(ctx, req.OrderId)
 	if err != nil || orderEntity == nil {
-		return &pb.ProcessPaymentResponse{
-			Success: false,
-			Message: "Order not found",
-		}, nil
+		return nil, commands.ErrOrderNotFound
 	}
 
 	// Get payment status from Midtrans
-	paymentResp, err := s.paymentProvider.GetPaymentStatus(orderEntity.ID)
+	paymentResp, err := s.paymentProvider.GetPaymentStatus(ctx, orderEntity.ID)
 	if err != nil {
 		s.logger.Error("Failed to get payment status", zap.Error(err))
-		return &pb.ProcessPaymentResponse{
-			Success:       false,
-			Message:       "Payment status check failed",
-			PaymentStatus: "failed",
-		}, nil
+		return nil, apperror.ErrUnavailable.Wrap(err).WithDetail("payment status check failed")
 	}
 
 	// Update order status based on payment
@@ -474,7 +443,7 @@ func (s *OrderServiceServer) ProcessPayment(ctx context.Context, req *pb.Process
 	orderEntity.UpdatedAt = time.Now()
 
 	// Save to database
-	if err := s.orderRepo.Update(orderEntity); err != nil {
+	if err := s.orderRepo.Update(ctx, orderEntity); err != nil {
 		s.logger.Error("Failed to update order payment status", zap.Error(err))
 		return nil, status.Error(codes.Internal, "Failed to update order")
 	}
@@ -491,8 +460,6 @@ func (s *OrderServiceServer) ProcessPayment(ctx context.Context, req *pb.Process
 	s.logger.Info("Payment processed successfully", zap.String("order_id", orderEntity.ID), zap.String("payment_status", string(paymentResp.Status)), zap.String("transaction_id", paymentResp.TransactionID))
 
 	return &pb.ProcessPaymentResponse{
-		Success:       true,
-		Message:       "Payment processed successfully",
 		PaymentStatus: string(paymentResp.Status),
 		TransactionId: paymentResp.TransactionID,
 	}, nil
@@ -504,7 +471,7 @@ func (s *OrderServiceServer) entityToProto(orderEntity *order.Order) *pb.Order {
 		protoItems[i] = &pb.OrderItem{
 			Id:          item.ID,
 			ProductId:   item.ProductID,
-			ProductName: "", // TODO: Get from product repository
+			ProductName: item.Product.Name,
 			Price:       item.Price,
 			Quantity:    int32(item.Quantity),
 			Subtotal:    item.Subtotal,
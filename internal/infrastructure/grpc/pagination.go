@@ -0,0 +1,27 @@
+package grpc
+
+import (
+	commonPb "online-shop/online-shop/proto/common"
+	"online-shop/pkg/pagination"
+)
+
+// pageFromRequest reads the page a list call asks for. The deprecated limit
+// and offset fields are used when the request has no page.
+func pageFromRequest(req *commonPb.PageRequest, limit, offset int32) (pagination.Page, error) {
+	if req == nil {
+		return pagination.FromOffset(int(limit), int(offset)), nil
+	}
+	if req.Cursor != "" {
+		return pagination.FromCursor(req.Cursor, int(req.PerPage))
+	}
+	return pagination.New(int(req.Page), int(req.PerPage)), nil
+}
+
+func pageMetaToProto(m pagination.Meta) *commonPb.PageMeta {
+	return &commonPb.PageMeta{
+		Page:       int32(m.Page),
+		PerPage:    int32(m.PerPage),
+		Total:      m.Total,
+		NextCursor: m.NextCursor,
+	}
+}
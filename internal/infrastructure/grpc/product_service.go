@@ -5,14 +5,20 @@ import (
 	"fmt"
 	"time"
 
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/merchandising"
 	productDomain "online-shop/internal/domain/product"
+	"online-shop/internal/domain/recommendation"
 	"online-shop/internal/infrastructure/redis"
 	"online-shop/internal/infrastructure/database"
 	"online-shop/internal/infrastructure/elasticsearch"
 	pb "online-shop/online-shop/proto/product"
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/pagination"
 	"go.uber.org/zap"
 
-	"github.com/google/uuid"
+	"online-shop/pkg/id"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
@@ -20,11 +26,17 @@ import (
 
 type ProductServiceServer struct {
 	pb.UnimplementedProductServiceServer
-	productRepo   *database.ProductRepository
-	categoryRepo  *database.CategoryRepository
-	cacheClient   *redis.RedisClient
-	searchClient  *elasticsearch.SearchService
-	logger        *zap.Logger
+	productRepo     *database.ProductRepository
+	categoryRepo    *database.CategoryRepository
+	cacheClient     *redis.RedisClient
+	searchClient    *elasticsearch.SearchService
+	similarProducts *queries.GetSimilarProductsQueryHandler
+	searchRanking   *queries.GetSearchRankingQueryHandler
+	boughtTogether  *queries.GetBoughtTogetherQueryHandler
+	recordPrice     *commands.RecordPriceChangeCommandHandler
+	webhooks        *commands.WebhookPublisher
+	logger          *zap.Logger
+	outboxSync      bool
 }
 
 func NewProductServiceServer(
@@ -32,14 +44,28 @@ func NewProductServiceServer(
 	categoryRepo *database.CategoryRepository,
 	cacheClient *redis.RedisClient,
 	searchClient *elasticsearch.SearchService,
+	recommendationRepo recommendation.Repository,
+	priceHistoryRepo productDomain.PriceHistoryRepository,
+	ruleRepo merchandising.Repository,
+	webhooks *commands.WebhookPublisher,
 	logger *zap.Logger,
 ) *ProductServiceServer {
+	var index recommendation.SimilarityIndex
+	if searchClient != nil {
+		index = searchClient
+	}
+
 	return &ProductServiceServer{
-		productRepo:  productRepo,
-		categoryRepo: categoryRepo,
-		cacheClient:  cacheClient,
-		searchClient: searchClient,
-		logger:       logger,
+		productRepo:     productRepo,
+		categoryRepo:    categoryRepo,
+		cacheClient:     cacheClient,
+		searchClient:    searchClient,
+		similarProducts: queries.NewGetSimilarProductsQueryHandler(productRepo, index),
+		searchRanking:   queries.NewGetSearchRankingQueryHandler(ruleRepo),
+		boughtTogether:  queries.NewGetBoughtTogetherQueryHandler(recommendationRepo, productRepo),
+		recordPrice:     commands.NewRecordPriceChangeCommandHandler(priceHistoryRepo),
+		webhooks:        webhooks,
+		logger:          logger,
 	}
 }
 
@@ -52,45 +78,42 @@ func (s *ProductServiceServer) CreateProduct(ctx context.Context, req *pb.Create
 	}
 
 	// Check if category exists
-	category, err := s.categoryRepo.GetByID(req.CategoryId)
+	category, err := s.categoryRepo.GetByID(ctx, req.CategoryId)
 	if err != nil || category == nil {
 		return nil, status.Error(codes.NotFound, "Category not found")
 	}
 
+	slug, err := productDomain.UniqueSlug(productDomain.Slugify(req.Name), func(slug string) (bool, error) {
+		return s.productRepo.SlugExists(ctx, slug)
+	})
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Product name cannot be used as a slug")
+	}
+
 	// Create product entity
 	productEntity := &productDomain.Product{
-		ID:          uuid.New().String(),
+		ID:          id.New(),
 		Name:        req.Name,
+		Slug:        slug,
 		Description: req.Description,
 		Price:       req.Price,
 		Stock:       int(req.Stock),
 		CategoryID:  req.CategoryId,
 		MerchantID:  req.MerchantId,
 		Images:      req.Images,
-		Status:      "active",
+		Status:      productDomain.StatusActive,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
 
 	// Save to database
-	if err := s.productRepo.Create(productEntity); err != nil {
+	if err := s.productRepo.Create(ctx, productEntity); err != nil {
 		s.logger.Error("Failed to create product", zap.Error(err))
 		return nil, status.Error(codes.Internal, "Failed to create product")
 	}
 
-	// Index in Elasticsearch
-	if err := s.searchClient.IndexProduct(ctx, productEntity); err != nil {
-		s.logger.Warn("Failed to index product in Elasticsearch", zap.Error(err))
-	}
-
-	// Cache product
-	productKey := fmt.Sprintf("product:%s", productEntity.ID)
-	if err := s.cacheClient.Set(productKey, productEntity, 24*time.Hour); err != nil {
-		s.logger.Warn("Failed to cache product", zap.Error(err))
-	}
-
-	// Invalidate products list cache
-	s.invalidateProductsCache()
+	// Index in Elasticsearch and cache product
+	s.syncProduct(ctx, productEntity)
 
 	s.logger.Info("Product created successfully", zap.String("product_id", productEntity.ID), zap.String("name", productEntity.Name))
 
@@ -109,7 +132,7 @@ func (s *ProductServiceServer) GetProduct(ctx context.Context, req *pb.GetProduc
 	if err := s.cacheClient.Get(productKey, &productEntity); err != nil {
 		// Cache miss, get from database
 		var err error
-		productEntity, err = s.productRepo.GetByID(req.ProductId)
+		productEntity, err = s.productRepo.GetByID(ctx, req.ProductId)
 		if err != nil || productEntity == nil {
 			return nil, status.Error(codes.NotFound, "Product not found")
 		}
@@ -121,7 +144,7 @@ func (s *ProductServiceServer) GetProduct(ctx context.Context, req *pb.GetProduc
 	}
 
 	// Get category
-	category, err := s.categoryRepo.GetByID(productEntity.CategoryID)
+	category, err := s.categoryRepo.GetByID(ctx, productEntity.CategoryID)
 	if err != nil {
 		s.logger.Warn("Failed to get category", zap.String("category_id", productEntity.CategoryID), zap.Error(err))
 	}
@@ -134,15 +157,11 @@ func (s *ProductServiceServer) GetProduct(ctx context.Context, req *pb.GetProduc
 func (s *ProductServiceServer) GetProducts(ctx context.Context, req *pb.GetProductsRequest) (*pb.GetProductsResponse, error) {
 	s.logger.Info("Get products request", zap.Int32("limit", req.Limit), zap.Int32("offset", req.Offset))
 
-	// Set default values
-	limit := int(req.Limit)
-	if limit <= 0 || limit > 100 {
-		limit = 20
-	}
-	offset := int(req.Offset)
-	if offset < 0 {
-		offset = 0
+	page, err := pageFromRequest(req.Page, req.Limit, req.Offset)
+	if err != nil {
+		return nil, err
 	}
+	limit, offset := page.Limit(), page.Offset()
 
 	// Try to get from cache first
 	cacheKey := fmt.Sprintf("products:list:%d:%d:%s:%s", limit, offset, req.SortBy, req.SortOrder)
@@ -155,13 +174,13 @@ func (s *ProductServiceServer) GetProducts(ctx context.Context, req *pb.GetProdu
 		// Cache hit
 		protoProducts := make([]*pb.Product, len(cachedResult.Products))
 		for i, product := range cachedResult.Products {
-			category, _ := s.categoryRepo.GetByID(product.CategoryID)
+			category, _ := s.categoryRepo.GetByID(ctx, product.CategoryID)
 			protoProducts[i] = s.entityToProto(product, category)
 		}
 
 		return &pb.GetProductsResponse{
 			Products: protoProducts,
-			Total:    cachedResult.Total,
+			Meta:     pageMetaToProto(page.Meta(len(protoProducts), nil)),
 		}, nil
 	}
 
@@ -180,7 +199,7 @@ func (s *ProductServiceServer) GetProducts(ctx context.Context, req *pb.GetProdu
 		Offset: offset,
 	}
 	
-	products, err := s.productRepo.List(filter)
+	products, err := s.productRepo.List(ctx, filter)
 	if err != nil {
 		s.logger.Error("Failed to get products", zap.Error(err))
 		return nil, status.Error(codes.Internal, "Failed to get products")
@@ -198,13 +217,13 @@ func (s *ProductServiceServer) GetProducts(ctx context.Context, req *pb.GetProdu
 	// Convert to proto
 	protoProducts := make([]*pb.Product, len(products))
 	for i, product := range products {
-		category, _ := s.categoryRepo.GetByID(product.CategoryID)
+		category, _ := s.categoryRepo.GetByID(ctx, product.CategoryID)
 		protoProducts[i] = s.entityToProto(product, category)
 	}
 
 	return &pb.GetProductsResponse{
 		Products: protoProducts,
-		Total:    int64(cachedResult.Total),
+		Meta:     pageMetaToProto(page.Meta(len(protoProducts), nil)),
 	}, nil
 }
 
@@ -212,10 +231,11 @@ func (s *ProductServiceServer) UpdateProduct(ctx context.Context, req *pb.Update
 	s.logger.Info("Update product request", zap.String("product_id", req.ProductId))
 
 	// Get product from database
-	product, err := s.productRepo.GetByID(req.ProductId)
+	product, err := s.productRepo.GetByID(ctx, req.ProductId)
 	if err != nil || product == nil {
 		return nil, status.Error(codes.NotFound, "Product not found")
 	}
+	oldPrice := product.Price
 
 	// Update fields
 	if req.Name != "" {
@@ -236,27 +256,32 @@ func (s *ProductServiceServer) UpdateProduct(ctx context.Context, req *pb.Update
 	product.UpdatedAt = time.Now()
 
 	// Save to database
-	if err := s.productRepo.Update(product); err != nil {
+	if err := s.productRepo.Update(ctx, product); err != nil {
 		s.logger.Error("Failed to update product", zap.Error(err))
 		return nil, status.Error(codes.Internal, "Failed to update product")
 	}
 
-	// Update in Elasticsearch
-	if err := s.searchClient.IndexProduct(ctx, product); err != nil {
-		s.logger.Warn("Failed to update product in Elasticsearch", zap.Error(err))
+	// Record the price history; price alerts are triggered by its drops
+	if err := s.recordPrice.Handle(ctx, commands.RecordPriceChangeCommand{
+		ProductID: product.ID,
+		OldPrice:  oldPrice,
+		NewPrice:  product.Price,
+	}); err != nil {
+		s.logger.Warn("Failed to record price change", zap.Error(err))
 	}
 
-	// Update cache
-	productKey := fmt.Sprintf("product:%s", product.ID)
-	if err := s.cacheClient.Set(productKey, product, 24*time.Hour); err != nil {
-		s.logger.Warn("Failed to update product cache", zap.Error(err))
+	// Tell the third parties subscribed to product changes
+	if s.webhooks != nil {
+		if err := s.webhooks.ProductUpdated(ctx, product); err != nil {
+			s.logger.Warn("Failed to publish product webhook", zap.Error(err))
+		}
 	}
 
-	// Invalidate products list cache
-	s.invalidateProductsCache()
+	// Update in Elasticsearch and cache
+	s.syncProduct(ctx, product)
 
 	// Get category
-	category, err := s.categoryRepo.GetByID(product.CategoryID)
+	category, err := s.categoryRepo.GetByID(ctx, product.CategoryID)
 	if err != nil {
 		s.logger.Warn("Failed to get category", zap.String("category_id", product.CategoryID), zap.Error(err))
 	}
@@ -272,50 +297,33 @@ func (s *ProductServiceServer) DeleteProduct(ctx context.Context, req *pb.Delete
 	s.logger.Info("Delete product request", zap.String("product_id", req.ProductId))
 
 	// Check if product exists
-	product, err := s.productRepo.GetByID(req.ProductId)
+	product, err := s.productRepo.GetByID(ctx, req.ProductId)
 	if err != nil || product == nil {
 		return nil, status.Error(codes.NotFound, "Product not found")
 	}
 
 	// Delete from database
-	if err := s.productRepo.Delete(req.ProductId); err != nil {
+	if err := s.productRepo.Delete(ctx, req.ProductId); err != nil {
 		s.logger.Error("Failed to delete product", zap.Error(err))
 		return nil, status.Error(codes.Internal, "Failed to delete product")
 	}
 
-	// Delete from Elasticsearch
-	if err := s.searchClient.DeleteProduct(ctx, req.ProductId); err != nil {
-		s.logger.Warn("Failed to delete product from Elasticsearch", zap.Error(err))
-	}
-
-	// Delete from cache
-	productKey := fmt.Sprintf("product:%s", req.ProductId)
-	if err := s.cacheClient.Delete(productKey); err != nil {
-		s.logger.Warn("Failed to delete product from cache", zap.Error(err))
-	}
-
-	// Invalidate products list cache
-	s.invalidateProductsCache()
+	// Delete from Elasticsearch and cache
+	s.unsyncProduct(ctx, req.ProductId)
 
 	s.logger.Info("Product deleted successfully", zap.String("product_id", req.ProductId))
 
-	return &pb.DeleteProductResponse{
-		Success: true,
-	}, nil
+	return &pb.DeleteProductResponse{}, nil
 }
 
 func (s *ProductServiceServer) SearchProducts(ctx context.Context, req *pb.SearchProductsRequest) (*pb.SearchProductsResponse, error) {
 	s.logger.Info("Search products request", zap.String("query", req.Query))
 
-	// Set default values
-	limit := int(req.Limit)
-	if limit <= 0 || limit > 100 {
-		limit = 20
-	}
-	offset := int(req.Offset)
-	if offset < 0 {
-		offset = 0
+	page, err := pageFromRequest(req.Page, req.Limit, req.Offset)
+	if err != nil {
+		return nil, err
 	}
+	limit, offset := page.Limit(), page.Offset()
 
 	// Try cache first for search results
 	cacheKey := fmt.Sprintf("search:%s:%s:%f:%f:%s:%d:%d", 
@@ -330,23 +338,29 @@ func (s *ProductServiceServer) SearchProducts(ctx context.Context, req *pb.Searc
 		// Cache hit
 		protoProducts := make([]*pb.Product, len(cachedResult.Products))
 		for i, product := range cachedResult.Products {
-			category, _ := s.categoryRepo.GetByID(product.CategoryID)
+			category, _ := s.categoryRepo.GetByID(ctx, product.CategoryID)
 			protoProducts[i] = s.entityToProto(product, category)
 		}
 
 		return &pb.SearchProductsResponse{
 			Products: protoProducts,
-			Total:    cachedResult.Total,
+			Meta:     pageMetaToProto(page.Meta(len(protoProducts), pagination.Total(cachedResult.Total))),
 		}, nil
 	}
 
-	// Search in Elasticsearch
+	// Search in Elasticsearch, ranked by the live merchandising rules. The
+	// search is served unmerchandised when the rules cannot be read.
+	ranking, err := s.searchRanking.Handle(ctx, queries.GetSearchRankingQuery{Query: req.Query, CategoryID: req.CategoryId})
+	if err != nil {
+		s.logger.Warn("Failed to read merchandising rules", zap.Error(err))
+	}
 	searchQuery := elasticsearch.SearchQuery{
 		Query:      req.Query,
 		CategoryID: req.CategoryId,
 		MinPrice:   req.MinPrice,
 		MaxPrice:   req.MaxPrice,
 		MerchantID: req.MerchantId,
+		Ranking:    ranking,
 		From:       offset,
 		Size:       limit,
 	}
@@ -369,6 +383,8 @@ func (s *ProductServiceServer) SearchProducts(ctx context.Context, req *pb.Searc
 			MerchantID:  doc.MerchantID,
 			Images:      doc.Images,
 			Status:      productDomain.Status(doc.Status),
+			Attributes:  doc.ProductAttributes(),
+			Badges:      doc.Badges,
 		}
 	}
 
@@ -382,28 +398,24 @@ func (s *ProductServiceServer) SearchProducts(ctx context.Context, req *pb.Searc
 	// Convert to proto
 	protoProducts := make([]*pb.Product, len(products))
 	for i, product := range products {
-		category, _ := s.categoryRepo.GetByID(product.CategoryID)
+		category, _ := s.categoryRepo.GetByID(ctx, product.CategoryID)
 		protoProducts[i] = s.entityToProto(product, category)
 	}
 
 	return &pb.SearchProductsResponse{
 		Products: protoProducts,
-		Total:    int64(cachedResult.Total),
+		Meta:     pageMetaToProto(page.Meta(len(protoProducts), pagination.Total(cachedResult.Total))),
 	}, nil
 }
 
 func (s *ProductServiceServer) ListCategories(ctx context.Context, req *pb.ListCategoriesRequest) (*pb.ListCategoriesResponse, error) {
 	s.logger.Info("List categories request")
 
-	// Set default values
-	limit := int(req.Limit)
-	if limit <= 0 || limit > 100 {
-		limit = 50
-	}
-	offset := int(req.Offset)
-	if offset < 0 {
-		offset = 0
+	page, err := pageFromRequest(req.Page, req.Limit, req.Offset)
+	if err != nil {
+		return nil, err
 	}
+	limit, offset := page.Limit(), page.Offset()
 
 	// Try cache first
 	cacheKey := fmt.Sprintf("categories:list:%d:%d", limit, offset)
@@ -412,7 +424,7 @@ func (s *ProductServiceServer) ListCategories(ctx context.Context, req *pb.ListC
 	if err := s.cacheClient.Get(cacheKey, &categories); err != nil {
 		// Cache miss, get from database
 		var err error
-		categories, err = s.categoryRepo.List(limit, offset)
+		categories, err = s.categoryRepo.List(ctx, limit, offset)
 		if err != nil {
 			s.logger.Error("Failed to get categories", zap.Error(err))
 			return nil, status.Error(codes.Internal, "Failed to get categories")
@@ -432,6 +444,7 @@ func (s *ProductServiceServer) ListCategories(ctx context.Context, req *pb.ListC
 
 	return &pb.ListCategoriesResponse{
 		Categories: protoCategories,
+		Meta:       pageMetaToProto(page.Meta(len(protoCategories), nil)),
 	}, nil
 }
 
@@ -439,12 +452,9 @@ func (s *ProductServiceServer) UpdateStock(ctx context.Context, req *pb.UpdateSt
 	s.logger.Info("Update stock request", zap.String("product_id", req.ProductId), zap.Int32("stock", req.Stock))
 
 	// Get product from database
-	product, err := s.productRepo.GetByID(req.ProductId)
+	product, err := s.productRepo.GetByID(ctx, req.ProductId)
 	if err != nil || product == nil {
-		return &pb.UpdateStockResponse{
-			Success: false,
-			Message: "Product not found",
-		}, nil
+		return nil, commands.ErrProductNotFound
 	}
 
 	// Update stock
@@ -452,30 +462,16 @@ func (s *ProductServiceServer) UpdateStock(ctx context.Context, req *pb.UpdateSt
 	product.UpdatedAt = time.Now()
 
 	// Save to database
-	if err := s.productRepo.Update(product); err != nil {
+	if err := s.productRepo.Update(ctx, product); err != nil {
 		s.logger.Error("Failed to update product stock", zap.Error(err))
-		return &pb.UpdateStockResponse{
-			Success: false,
-			Message: "Failed to update stock",
-		}, nil
+		return nil, apperror.ErrInternal.Wrap(err)
 	}
 
-	// Update in Elasticsearch
-	if err := s.searchClient.IndexProduct(ctx, product); err != nil {
-		s.logger.Warn("Failed to update product in Elasticsearch", zap.Error(err))
-	}
-
-	// Update cache
-	productKey := fmt.Sprintf("product:%s", product.ID)
-	if err := s.cacheClient.Set(productKey, product, 24*time.Hour); err != nil {
-		s.logger.Warn("Failed to update product cache", zap.Error(err))
-	}
-
-	// Invalidate products list cache
-	s.invalidateProductsCache()
+	// Update in Elasticsearch and cache
+	s.syncProduct(ctx, product)
 
 	// Get category
-	category, err := s.categoryRepo.GetByID(product.CategoryID)
+	category, err := s.categoryRepo.GetByID(ctx, product.CategoryID)
 	if err != nil {
 		s.logger.Warn("Failed to get category", zap.String("category_id", product.CategoryID), zap.Error(err))
 	}
@@ -483,8 +479,6 @@ func (s *ProductServiceServer) UpdateStock(ctx context.Context, req *pb.UpdateSt
 	s.logger.Info("Product stock updated successfully", zap.String("product_id", product.ID), zap.Int("new_stock", product.Stock))
 
 	return &pb.UpdateStockResponse{
-		Success: true,
-		Message: "Stock updated successfully",
 		Product: s.entityToProto(product, category),
 	}, nil
 }
@@ -492,15 +486,11 @@ func (s *ProductServiceServer) UpdateStock(ctx context.Context, req *pb.UpdateSt
 func (s *ProductServiceServer) GetProductsByCategory(ctx context.Context, req *pb.GetProductsByCategoryRequest) (*pb.GetProductsByCategoryResponse, error) {
 	s.logger.Info("Get products by category request", zap.String("category_id", req.CategoryId))
 
-	// Set default values
-	limit := int(req.Limit)
-	if limit <= 0 || limit > 100 {
-		limit = 20
-	}
-	offset := int(req.Offset)
-	if offset < 0 {
-		offset = 0
+	page, err := pageFromRequest(req.Page, req.Limit, req.Offset)
+	if err != nil {
+		return nil, err
 	}
+	limit, offset := page.Limit(), page.Offset()
 
 	// Try cache first
 	cacheKey := fmt.Sprintf("products:category:%s:%d:%d", req.CategoryId, limit, offset)
@@ -513,13 +503,13 @@ func (s *ProductServiceServer) GetProductsByCategory(ctx context.Context, req *p
 		// Cache hit
 		protoProducts := make([]*pb.Product, len(cachedResult.Products))
 		for i, product := range cachedResult.Products {
-			category, _ := s.categoryRepo.GetByID(product.CategoryID)
+			category, _ := s.categoryRepo.GetByID(ctx, product.CategoryID)
 			protoProducts[i] = s.entityToProto(product, category)
 		}
 
 		return &pb.GetProductsByCategoryResponse{
 			Products: protoProducts,
-			Total:    cachedResult.Total,
+			Meta:     pageMetaToProto(page.Meta(len(protoProducts), nil)),
 		}, nil
 	}
 
@@ -529,7 +519,7 @@ func (s *ProductServiceServer) GetProductsByCategory(ctx context.Context, req *p
 		Limit:      limit,
 		Offset:     offset,
 	}
-	products, err := s.productRepo.List(filter)
+	products, err := s.productRepo.List(ctx, filter)
 	if err != nil {
 		s.logger.Error("Failed to get products by category", zap.Error(err))
 		return nil, status.Error(codes.Internal, "Failed to get products by category")
@@ -545,16 +535,65 @@ func (s *ProductServiceServer) GetProductsByCategory(ctx context.Context, req *p
 	// Convert to proto
 	protoProducts := make([]*pb.Product, len(products))
 	for i, product := range products {
-		category, _ := s.categoryRepo.GetByID(product.CategoryID)
+		category, _ := s.categoryRepo.GetByID(ctx, product.CategoryID)
 		protoProducts[i] = s.entityToProto(product, category)
 	}
 
 	return &pb.GetProductsByCategoryResponse{
 		Products: protoProducts,
-		Total:    cachedResult.Total,
+		Meta:     pageMetaToProto(page.Meta(len(protoProducts), nil)),
 	}, nil
 }
 
+func (s *ProductServiceServer) GetSimilarProducts(ctx context.Context, req *pb.GetRecommendationsRequest) (*pb.GetRecommendationsResponse, error) {
+	s.logger.Info("Get similar products request", zap.String("product_id", req.ProductId))
+
+	if req.ProductId == "" {
+		return nil, status.Error(codes.InvalidArgument, "Product ID is required")
+	}
+
+	products, err := s.similarProducts.Handle(ctx, queries.GetSimilarProductsQuery{
+		ProductID: req.ProductId,
+		Limit:     int(req.Limit),
+	})
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "Product not found")
+	}
+
+	return &pb.GetRecommendationsResponse{
+		Products: s.productsToProto(products),
+	}, nil
+}
+
+func (s *ProductServiceServer) GetFrequentlyBoughtTogether(ctx context.Context, req *pb.GetRecommendationsRequest) (*pb.GetRecommendationsResponse, error) {
+	s.logger.Info("Get frequently bought together request", zap.String("product_id", req.ProductId))
+
+	if req.ProductId == "" {
+		return nil, status.Error(codes.InvalidArgument, "Product ID is required")
+	}
+
+	products, err := s.boughtTogether.Handle(ctx, queries.GetBoughtTogetherQuery{
+		ProductID: req.ProductId,
+		Limit:     int(req.Limit),
+	})
+	if err != nil {
+		s.logger.Error("Failed to get frequently bought together products", zap.Error(err))
+		return nil, status.Error(codes.Internal, "Failed to get recommendations")
+	}
+
+	return &pb.GetRecommendationsResponse{
+		Products: s.productsToProto(products),
+	}, nil
+}
+
+func (s *ProductServiceServer) productsToProto(products []*productDomain.Product) []*pb.Product {
+	protoProducts := make([]*pb.Product, len(products))
+	for i, product := range products {
+		protoProducts[i] = s.entityToProto(product, product.Category)
+	}
+	return protoProducts
+}
+
 func (s *ProductServiceServer) entityToProto(product *productDomain.Product, category *productDomain.Category) *pb.Product {
 	protoProduct := &pb.Product{
 		Id:          product.ID,
@@ -588,6 +627,49 @@ func (s *ProductServiceServer) categoryEntityToProto(category *productDomain.Cat
 	}
 }
 
+// SyncThroughOutbox leaves the search index and caches to the search sync
+// job, which applies the changes captured with each write
+func (s *ProductServiceServer) SyncThroughOutbox() {
+	s.outboxSync = true
+}
+
+// syncProduct writes a created or updated product to Elasticsearch and the
+// cache. Failures are logged: the product is saved already.
+func (s *ProductServiceServer) syncProduct(ctx context.Context, product *productDomain.Product) {
+	if s.outboxSync {
+		return
+	}
+
+	if err := s.searchClient.IndexProduct(ctx, product); err != nil {
+		s.logger.Warn("Failed to index product in Elasticsearch", zap.Error(err))
+	}
+
+	productKey := fmt.Sprintf("product:%s", product.ID)
+	if err := s.cacheClient.Set(productKey, product, 24*time.Hour); err != nil {
+		s.logger.Warn("Failed to cache product", zap.Error(err))
+	}
+
+	s.invalidateProductsCache()
+}
+
+// unsyncProduct removes a deleted product from Elasticsearch and the cache
+func (s *ProductServiceServer) unsyncProduct(ctx context.Context, productID string) {
+	if s.outboxSync {
+		return
+	}
+
+	if err := s.searchClient.DeleteProduct(ctx, productID); err != nil {
+		s.logger.Warn("Failed to delete product from Elasticsearch", zap.Error(err))
+	}
+
+	productKey := fmt.Sprintf("product:%s", productID)
+	if err := s.cacheClient.Delete(productKey); err != nil {
+		s.logger.Warn("Failed to delete product from cache", zap.Error(err))
+	}
+
+	s.invalidateProductsCache()
+}
+
 func (s *ProductServiceServer) invalidateProductsCache() {
 	// Delete all products list cache entries
 	pattern := "products:list:*"
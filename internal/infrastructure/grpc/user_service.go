@@ -2,65 +2,81 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"time"
 
 
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/session"
 	"online-shop/internal/domain/user"
 	"online-shop/internal/infrastructure/redis"
 	"online-shop/internal/infrastructure/database"
 	pb "online-shop/online-shop/proto/user"
+	"online-shop/pkg/apperror"
 	"online-shop/pkg/jwt"
+	"online-shop/pkg/logger"
 	
 	"go.uber.org/zap"
 
-	"github.com/google/uuid"
+	"online-shop/pkg/id"
 	"golang.org/x/crypto/bcrypt"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type UserServiceServer struct {
 	pb.UnimplementedUserServiceServer
-	userRepo    *database.UserRepository
-	cacheClient *redis.RedisClient
-	jwtService  *jwt.JWTManager
-	logger      *zap.Logger
+	userRepo       *database.UserRepository
+	cacheClient    *redis.RedisClient
+	jwtService     *jwt.JWTManager
+	sessions       session.Store
+	startSession   *commands.StartSessionCommandHandler
+	refreshSession *commands.RefreshSessionCommandHandler
+	revokeSession  *commands.RevokeSessionCommandHandler
+	logger         *zap.Logger
 }
 
+// NewUserServiceServer signs users in to sessions like the HTTP API does, so
+// tokens issued over gRPC are listed and revoked with the others.
 func NewUserServiceServer(
 	userRepo *database.UserRepository,
 	cacheClient *redis.RedisClient,
 	jwtService *jwt.JWTManager,
+	sessions session.Store,
+	startSession *commands.StartSessionCommandHandler,
+	refreshSession *commands.RefreshSessionCommandHandler,
+	revokeSession *commands.RevokeSessionCommandHandler,
 	logger *zap.Logger,
 ) *UserServiceServer {
 	return &UserServiceServer{
-		userRepo:    userRepo,
-		cacheClient: cacheClient,
-		jwtService:  jwtService,
-		logger:      logger,
+		userRepo:       userRepo,
+		cacheClient:    cacheClient,
+		jwtService:     jwtService,
+		sessions:       sessions,
+		startSession:   startSession,
+		refreshSession: refreshSession,
+		revokeSession:  revokeSession,
+		logger:         logger,
 	}
 }
 
 func (s *UserServiceServer) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.RegisterResponse, error) {
-	s.logger.Info("User registration request", zap.String("email", req.Email))
+	s.logger.Info("User registration request", logger.Email("email", req.Email))
 
 	// Validate input
 	if req.Email == "" || req.Password == "" || req.FirstName == "" {
-		return &pb.RegisterResponse{
-			Success: false,
-			Message: "Email, password, and first name are required",
-		}, nil
+		return nil, apperror.ErrInvalidRequest.WithDetail("email, password, and first name are required")
 	}
 
 	// Check if user already exists
-	existingUser, err := s.userRepo.GetByEmail(req.Email)
+	existingUser, err := s.userRepo.GetByEmail(ctx, req.Email)
 	if err == nil && existingUser != nil {
-		return &pb.RegisterResponse{
-			Success: false,
-			Message: "User with this email already exists",
-		}, nil
+		return nil, commands.ErrUserAlreadyExists
 	}
 
 	// Hash password
@@ -72,7 +88,7 @@ func (s *UserServiceServer) Register(ctx context.Context, req *pb.RegisterReques
 
 	// Create user entity
 	user := &user.User{
-		ID:        uuid.New().String(),
+		ID:        id.New(),
 		Email:     req.Email,
 		Password:  string(hashedPassword),
 		FirstName: req.FirstName,
@@ -85,22 +101,15 @@ func (s *UserServiceServer) Register(ctx context.Context, req *pb.RegisterReques
 	}
 
 	// Save user to database
-	if err := s.userRepo.Create(user); err != nil {
+	if err := s.userRepo.Create(ctx, user); err != nil {
 		s.logger.Error("Failed to create user", zap.Error(err))
 		return nil, status.Error(codes.Internal, "Failed to create user")
 	}
 
-	// Generate tokens
-	accessToken, err := s.jwtService.GenerateToken(user.ID, user.Email, string(user.Role))
-	if err != nil {
-		s.logger.Error("Failed to generate access token", zap.Error(err))
-		return nil, status.Error(codes.Internal, "Failed to generate access token")
-	}
-
-	refreshToken, err := s.jwtService.GenerateToken(user.ID, user.Email, string(user.Role))
+	// Sign the new user in to a session
+	_, tokens, err := s.signIn(ctx, user)
 	if err != nil {
-		s.logger.Error("Failed to generate refresh token", zap.Error(err))
-		return nil, status.Error(codes.Internal, "Failed to generate refresh token")
+		return nil, err
 	}
 
 	// Cache user data
@@ -111,106 +120,68 @@ func (s *UserServiceServer) Register(ctx context.Context, req *pb.RegisterReques
 
 	// Cache refresh token
 	refreshKey := fmt.Sprintf("refresh_token:%s", user.ID)
-	if err := s.cacheClient.Set(refreshKey, refreshToken, 7*24*time.Hour); err != nil {
+	if err := s.cacheClient.Set(refreshKey, tokens.RefreshToken, 7*24*time.Hour); err != nil {
 		s.logger.Warn("Failed to cache refresh token", zap.Error(err))
 	}
 
-	s.logger.Info("User registered successfully", zap.String("user_id", user.ID), zap.String("email", user.Email))
+	s.logger.Info("User registered successfully", zap.String("user_id", user.ID), logger.Email("email", user.Email))
 
 	return &pb.RegisterResponse{
-		Success:      true,
-		Message:      "User registered successfully",
 		User:         s.entityToProto(user),
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
 	}, nil
 }
 
 func (s *UserServiceServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
-	s.logger.Info("User login request", zap.String("email", req.Email))
+	s.logger.Info("User login request", logger.Email("email", req.Email))
 
 	// Validate input
 	if req.Email == "" || req.Password == "" {
-		return &pb.LoginResponse{
-			Success: false,
-			Message: "Email and password are required",
-		}, nil
+		return nil, apperror.ErrInvalidRequest.WithDetail("email and password are required")
 	}
 
 	// Get user from database
-	user, err := s.userRepo.GetByEmail(req.Email)
+	user, err := s.userRepo.GetByEmail(ctx, req.Email)
 	if err != nil || user == nil {
-		return &pb.LoginResponse{
-			Success: false,
-			Message: "Invalid email or password",
-		}, nil
+		return nil, commands.ErrInvalidCredentials
 	}
 
 	// Verify password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return &pb.LoginResponse{
-			Success: false,
-			Message: "Invalid email or password",
-		}, nil
+		return nil, commands.ErrInvalidCredentials
 	}
 
 	// Check user status
 	if user.Status != "active" {
-		return &pb.LoginResponse{
-			Success: false,
-			Message: "Account is not active",
-		}, nil
-	}
-
-	// Generate tokens
-	accessToken, err := s.jwtService.GenerateToken(user.ID, user.Email, string(user.Role))
-	if err != nil {
-		s.logger.Error("Failed to generate access token", zap.Error(err))
-		return nil, status.Error(codes.Internal, "Failed to generate access token")
+		return nil, commands.ErrUserInactive
 	}
 
-	refreshToken, err := s.jwtService.GenerateToken(user.ID, user.Email, string(user.Role))
+	// Start a session and issue the tokens bound to it
+	sess, tokens, err := s.signIn(ctx, user)
 	if err != nil {
-		s.logger.Error("Failed to generate refresh token", zap.Error(err))
-		return nil, status.Error(codes.Internal, "Failed to generate refresh token")
+		return nil, err
 	}
 
-	// Generate session ID
-	sessionID := uuid.New().String()
-
 	// Cache user data
 	userKey := fmt.Sprintf("user:%s", user.ID)
 	if err := s.cacheClient.Set(userKey, user, 24*time.Hour); err != nil {
 		s.logger.Warn("Failed to cache user data", zap.Error(err))
 	}
 
-	// Cache session
-	sessionKey := fmt.Sprintf("session:%s", sessionID)
-	sessionData := map[string]interface{}{
-		"user_id":    user.ID,
-		"email":      user.Email,
-		"role":       user.Role,
-		"created_at": time.Now(),
-	}
-	if err := s.cacheClient.Set(sessionKey, sessionData, 24*time.Hour); err != nil {
-		s.logger.Warn("Failed to cache session", zap.Error(err))
-	}
-
 	// Cache refresh token
 	refreshKey := fmt.Sprintf("refresh_token:%s", user.ID)
-	if err := s.cacheClient.Set(refreshKey, refreshToken, 7*24*time.Hour); err != nil {
+	if err := s.cacheClient.Set(refreshKey, tokens.RefreshToken, 7*24*time.Hour); err != nil {
 		s.logger.Warn("Failed to cache refresh token", zap.Error(err))
 	}
 
-	s.logger.Info("User logged in successfully", zap.String("user_id", user.ID), zap.String("email", user.Email))
+	s.logger.Info("User logged in successfully", zap.String("user_id", user.ID), logger.Email("email", user.Email))
 
 	return &pb.LoginResponse{
-		Success:      true,
-		Message:      "Login successful",
 		User:         s.entityToProto(user),
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		SessionId:    sessionID,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		SessionId:    sess.ID,
 	}, nil
 }
 
@@ -218,12 +189,9 @@ func (s *UserServiceServer) RefreshToken(ctx context.Context, req *pb.RefreshTok
 	s.logger.Info("Refresh token request")
 
 	// Validate refresh token
-	claims, err := s.jwtService.ValidateToken(req.RefreshToken)
+	claims, err := s.jwtService.ValidateRefreshToken(req.RefreshToken)
 	if err != nil {
-		return &pb.RefreshTokenResponse{
-			Success: false,
-			Message: "Invalid refresh token",
-		}, nil
+		return nil, commands.ErrInvalidRefreshToken.Wrap(err)
 	}
 
 	userID := claims.UserID
@@ -232,49 +200,88 @@ func (s *UserServiceServer) RefreshToken(ctx context.Context, req *pb.RefreshTok
 	refreshKey := fmt.Sprintf("refresh_token:%s", userID)
 	cachedToken, err := s.cacheClient.GetString(refreshKey)
 	if err != nil || cachedToken != req.RefreshToken {
-		return &pb.RefreshTokenResponse{
-			Success: false,
-			Message: "Refresh token not found or expired",
-		}, nil
+		return nil, commands.ErrInvalidRefreshToken.WithDetail("refresh token not found or expired")
 	}
 
 	// Get user from database
-	user, err := s.userRepo.GetByID(userID)
+	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil || user == nil {
-		return &pb.RefreshTokenResponse{
-			Success: false,
-			Message: "User not found",
-		}, nil
+		return nil, commands.ErrUserNotFound
 	}
 
-	// Generate new tokens
-	newAccessToken, err := s.jwtService.GenerateToken(user.ID, user.Email, string(user.Role))
+	// Extend the token's session; a revoked one, or none, ends here
+	userAgent, ip := clientInfo(ctx)
+	sess, err := s.refreshSession.Handle(ctx, commands.RefreshSessionCommand{
+		UserID:    user.ID,
+		SessionID: claims.SessionID,
+		UserAgent: userAgent,
+		IPAddress: ip,
+	})
+	if errors.Is(err, session.ErrNotFound) {
+		return nil, commands.ErrInvalidRefreshToken.WithDetail("the session has been revoked")
+	}
 	if err != nil {
-		s.logger.Error("Failed to generate new access token", zap.Error(err))
-		return nil, status.Error(codes.Internal, "Failed to generate access token")
+		return nil, err
 	}
 
-	newRefreshToken, err := s.jwtService.GenerateToken(user.ID, user.Email, string(user.Role))
+	// Generate new tokens
+	tokens, err := s.jwtService.GenerateTokenPair(user.ID, user.Email, string(user.Role), sess.ID)
 	if err != nil {
-		s.logger.Error("Failed to generate new refresh token", zap.Error(err))
-		return nil, status.Error(codes.Internal, "Failed to generate refresh token")
+		s.logger.Error("Failed to generate new tokens", zap.Error(err))
+		return nil, status.Error(codes.Internal, "Failed to generate tokens")
 	}
 
 	// Update refresh token in cache
-	if err := s.cacheClient.Set(refreshKey, newRefreshToken, 7*24*time.Hour); err != nil {
+	if err := s.cacheClient.Set(refreshKey, tokens.RefreshToken, 7*24*time.Hour); err != nil {
 		s.logger.Warn("Failed to update refresh token in cache", zap.Error(err))
 	}
 
 	s.logger.Info("Token refreshed successfully", zap.String("user_id", userID))
 
 	return &pb.RefreshTokenResponse{
-		Success:      true,
-		Message:      "Token refreshed successfully",
-		AccessToken:  newAccessToken,
-		RefreshToken: newRefreshToken,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
 	}, nil
 }
 
+// signIn opens a session for a user who just signed in and issues the token
+// pair bound to it.
+func (s *UserServiceServer) signIn(ctx context.Context, u *user.User) (*session.Session, *jwt.TokenPair, error) {
+	userAgent, ip := clientInfo(ctx)
+	sess, err := s.startSession.Handle(ctx, commands.StartSessionCommand{
+		UserID:    u.ID,
+		UserAgent: userAgent,
+		IPAddress: ip,
+	})
+	if err != nil {
+		s.logger.Error("Failed to start session", zap.Error(err))
+		return nil, nil, status.Error(codes.Internal, "Failed to start session")
+	}
+
+	tokens, err := s.jwtService.GenerateTokenPair(u.ID, u.Email, string(u.Role), sess.ID)
+	if err != nil {
+		s.logger.Error("Failed to generate tokens", zap.Error(err))
+		return nil, nil, status.Error(codes.Internal, "Failed to generate tokens")
+	}
+	return sess, tokens, nil
+}
+
+// clientInfo is the caller's user agent and address, shown on its session
+func clientInfo(ctx context.Context) (userAgent, ip string) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if agents := md.Get("user-agent"); len(agents) > 0 {
+			userAgent = agents[0]
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		ip = p.Addr.String()
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+	}
+	return userAgent, ip
+}
+
 func (s *UserServiceServer) GetProfile(ctx context.Context, req *pb.GetProfileRequest) (*pb.GetProfileResponse, error) {
 	s.logger.Info("Get profile request", zap.String("user_id", req.UserId))
 
@@ -285,7 +292,7 @@ func (s *UserServiceServer) GetProfile(ctx context.Context, req *pb.GetProfileRe
 	if err := s.cacheClient.Get(userKey, &user); err != nil {
 		// Cache miss, get from database
 		var err error
-		user, err = s.userRepo.GetByID(req.UserId)
+		user, err = s.userRepo.GetByID(ctx, req.UserId)
 		if err != nil || user == nil {
 			return nil, status.Error(codes.NotFound, "User not found")
 		}
@@ -305,7 +312,7 @@ func (s *UserServiceServer) UpdateProfile(ctx context.Context, req *pb.UpdatePro
 	s.logger.Info("Update profile request", zap.String("user_id", req.UserId))
 
 	// Get user from database
-	user, err := s.userRepo.GetByID(req.UserId)
+	user, err := s.userRepo.GetByID(ctx, req.UserId)
 	if err != nil || user == nil {
 		return nil, status.Error(codes.NotFound, "User not found")
 	}
@@ -323,7 +330,7 @@ func (s *UserServiceServer) UpdateProfile(ctx context.Context, req *pb.UpdatePro
 	user.UpdatedAt = time.Now()
 
 	// Save to database
-	if err := s.userRepo.Update(user); err != nil {
+	if err := s.userRepo.Update(ctx, user); err != nil {
 		s.logger.Error("Failed to update user", zap.Error(err))
 		return nil, status.Error(codes.Internal, "Failed to update user")
 	}
@@ -345,18 +352,14 @@ func (s *UserServiceServer) ChangePassword(ctx context.Context, req *pb.ChangePa
 	s.logger.Info("Change password request", zap.String("user_id", req.UserId))
 
 	// Get user from database
-	user, err := s.userRepo.GetByID(req.UserId)
+	user, err := s.userRepo.GetByID(ctx, req.UserId)
 	if err != nil || user == nil {
-		return &pb.ChangePasswordResponse{
-			Success: false,
-		}, status.Error(codes.NotFound, "User not found")
+		return nil, commands.ErrUserNotFound
 	}
 
 	// Verify old password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.OldPassword)); err != nil {
-		return &pb.ChangePasswordResponse{
-			Success: false,
-		}, status.Error(codes.InvalidArgument, "Invalid old password")
+		return nil, commands.ErrIncorrectPassword
 	}
 
 	// Hash new password
@@ -370,7 +373,7 @@ func (s *UserServiceServer) ChangePassword(ctx context.Context, req *pb.ChangePa
 	user.Password = string(hashedPassword)
 	user.UpdatedAt = time.Now()
 
-	if err := s.userRepo.Update(user); err != nil {
+	if err := s.userRepo.Update(ctx, user); err != nil {
 		s.logger.Error("Failed to update password", zap.Error(err))
 		return nil, status.Error(codes.Internal, "Failed to update password")
 	}
@@ -381,18 +384,18 @@ func (s *UserServiceServer) ChangePassword(ctx context.Context, req *pb.ChangePa
 
 	s.logger.Info("Password changed successfully", zap.String("user_id", user.ID))
 
-	return &pb.ChangePasswordResponse{
-		Success: true,
-	}, nil
+	return &pb.ChangePasswordResponse{}, nil
 }
 
 func (s *UserServiceServer) Logout(ctx context.Context, req *pb.LogoutRequest) (*pb.LogoutResponse, error) {
 	s.logger.Info("Logout request", zap.String("user_id", req.UserId), zap.String("session_id", req.SessionId))
 
-	// Delete session from cache
-	sessionKey := fmt.Sprintf("session:%s", req.SessionId)
-	if err := s.cacheClient.Delete(sessionKey); err != nil {
-		s.logger.Warn("Failed to delete session from cache", zap.Error(err))
+	// Revoke the session like the HTTP API does; another user's is not found
+	if err := s.revokeSession.Handle(ctx, commands.RevokeSessionCommand{
+		UserID:    req.UserId,
+		SessionID: req.SessionId,
+	}); err != nil {
+		return nil, err
 	}
 
 	// Delete refresh token from cache
@@ -403,10 +406,7 @@ func (s *UserServiceServer) Logout(ctx context.Context, req *pb.LogoutRequest) (
 
 	s.logger.Info("User logged out successfully", zap.String("user_id", req.UserId))
 
-	return &pb.LogoutResponse{
-		Success: true,
-		Message: "Logged out successfully",
-	}, nil
+	return &pb.LogoutResponse{}, nil
 }
 
 func (s *UserServiceServer) ValidateToken(ctx context.Context, req *pb.ValidateTokenRequest) (*pb.ValidateTokenResponse, error) {
@@ -418,6 +418,17 @@ func (s *UserServiceServer) ValidateToken(ctx context.Context, req *pb.ValidateT
 		}, nil
 	}
 
+	// A revoked session invalidates its tokens before they expire
+	if err := s.checkSession(ctx, claims); err != nil {
+		if errors.Is(err, session.ErrNotFound) {
+			return &pb.ValidateTokenResponse{
+				Valid:   false,
+				Message: "Session has been revoked",
+			}, nil
+		}
+		return nil, err
+	}
+
 	userID := claims.UserID
 	role := claims.Role
 
@@ -429,6 +440,26 @@ func (s *UserServiceServer) ValidateToken(ctx context.Context, req *pb.ValidateT
 	}, nil
 }
 
+// checkSession verifies the token's session is still live, as the HTTP auth
+// middleware does. Only impersonation tokens carry no session.
+func (s *UserServiceServer) checkSession(ctx context.Context, claims *jwt.Claims) error {
+	if claims.SessionID == "" && claims.Actor != nil {
+		return nil
+	}
+	if claims.SessionID == "" {
+		return session.ErrNotFound
+	}
+
+	sess, err := s.sessions.Get(ctx, claims.SessionID)
+	if err != nil {
+		return err
+	}
+	if sess.UserID != claims.UserID {
+		return session.ErrNotFound
+	}
+	return nil
+}
+
 func (s *UserServiceServer) entityToProto(user *user.User) *pb.User {
 	return &pb.User{
 		Id:        user.ID,
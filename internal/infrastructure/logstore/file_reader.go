@@ -0,0 +1,192 @@
+package logstore
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"online-shop/internal/domain/systemlog"
+)
+
+const (
+	maxLineSize   = 1 << 20
+	logrusTimeFmt = "2006-01-02 15:04:05"
+)
+
+// FileReader searches the JSON log files written by logrus and zap, including
+// the rotated backups lumberjack keeps next to them. Lines that are not JSON
+// (the text format used in local development) are skipped.
+type FileReader struct {
+	paths []string
+}
+
+func NewFileReader(paths ...string) systemlog.Reader {
+	return &FileReader{paths: paths}
+}
+
+func (r *FileReader) Query(ctx context.Context, filter systemlog.Filter, limit, offset int) ([]*systemlog.Entry, int64, error) {
+	var matches []*systemlog.Entry
+	for _, file := range r.files() {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		// Nothing in a file last written before the window can match
+		if !filter.From.IsZero() && info.ModTime().Before(filter.From) {
+			continue
+		}
+
+		entries, err := readFile(file, filter)
+		if err != nil {
+			return nil, 0, err
+		}
+		matches = append(matches, entries...)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Time.After(matches[j].Time)
+	})
+
+	total := int64(len(matches))
+	if offset >= len(matches) {
+		return []*systemlog.Entry{}, total, nil
+	}
+	end := offset + limit
+	if end > len(matches) {
+		end = len(matches)
+	}
+	return matches[offset:end], total, nil
+}
+
+// files expands the configured paths with their rotated backups, e.g.
+// app.log also covers app-2024-05-01T10-00-00.000.log.gz
+func (r *FileReader) files() []string {
+	seen := make(map[string]bool)
+	var files []string
+	add := func(pattern string) {
+		found, _ := filepath.Glob(pattern)
+		for _, f := range found {
+			if !seen[f] {
+				seen[f] = true
+				files = append(files, f)
+			}
+		}
+	}
+
+	for _, path := range r.paths {
+		ext := filepath.Ext(path)
+		base := strings.TrimSuffix(path, ext)
+		add(path)
+		add(base + "-*" + ext)
+		add(base + "-*" + ext + ".gz")
+	}
+	return files
+}
+
+func readFile(path string, filter systemlog.Filter) ([]*systemlog.Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var reader io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	service := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if i := strings.Index(service, "-"); i > 0 {
+		service = service[:i]
+	}
+
+	var entries []*systemlog.Entry
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		entry, ok := parseLine(scanner.Bytes())
+		if !ok {
+			continue
+		}
+		if entry.Service == "" {
+			entry.Service = service
+		}
+		entry.Source = filepath.Base(path)
+		if filter.Matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// parseLine reads one logrus or zap JSON entry
+func parseLine(line []byte) (*systemlog.Entry, bool) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return nil, false
+	}
+
+	entry := &systemlog.Entry{
+		Level:   takeString(fields, "level"),
+		Service: takeString(fields, "service"),
+		Message: takeString(fields, "msg"),
+	}
+	if entry.Message == "" {
+		entry.Message = takeString(fields, "message")
+	}
+
+	if t, ok := parseTime(fields["time"]); ok {
+		entry.Time = t
+	} else if t, ok := parseTime(fields["ts"]); ok {
+		entry.Time = t
+	} else {
+		return nil, false
+	}
+	delete(fields, "time")
+	delete(fields, "ts")
+
+	if len(fields) > 0 {
+		entry.Fields = fields
+	}
+	return entry, true
+}
+
+func takeString(fields map[string]interface{}, key string) string {
+	value, _ := fields[key].(string)
+	delete(fields, key)
+	return value
+}
+
+func parseTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t, true
+		}
+		if t, err := time.ParseInLocation(logrusTimeFmt, v, time.Local); err == nil {
+			return t, true
+		}
+	case float64:
+		// zap's production encoder writes epoch seconds
+		sec, frac := math.Modf(v)
+		return time.Unix(int64(sec), int64(frac*1e9)), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,267 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"online-shop/internal/domain/oauth"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const jwksRefreshInterval = time.Hour
+
+// OIDCConfig describes an OpenID Connect provider. ClientSecret is called for
+// each exchange because some providers (Apple) require a short-lived signed
+// secret instead of a static one.
+type OIDCConfig struct {
+	Name         string
+	ClientID     string
+	ClientSecret func() (string, error)
+	RedirectURL  string
+	AuthURL      string
+	TokenURL     string
+	JWKSURL      string
+	Issuers      []string
+	Scopes       []string
+	UsePKCE      bool
+	AuthParams   map[string]string
+}
+
+// OIDCProvider runs the authorization code flow and trusts only what is in
+// the ID token, verified against the provider's published keys.
+type OIDCProvider struct {
+	cfg    OIDCConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+func NewOIDCProvider(cfg OIDCConfig) *OIDCProvider {
+	return &OIDCProvider{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+		keys:   make(map[string]*rsa.PublicKey),
+	}
+}
+
+func (p *OIDCProvider) Name() string {
+	return p.cfg.Name
+}
+
+func (p *OIDCProvider) AuthCodeURL(state *oauth.State) string {
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.cfg.ClientID)
+	params.Set("redirect_uri", p.cfg.RedirectURL)
+	params.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	params.Set("state", state.ID)
+	params.Set("nonce", state.Nonce)
+	if p.cfg.UsePKCE {
+		params.Set("code_challenge", state.CodeChallenge())
+		params.Set("code_challenge_method", "S256")
+	}
+	for k, v := range p.cfg.AuthParams {
+		params.Set(k, v)
+	}
+	return p.cfg.AuthURL + "?" + params.Encode()
+}
+
+// FormPost reports whether the callback is posted back as a form
+func (p *OIDCProvider) FormPost() bool {
+	return p.cfg.AuthParams["response_mode"] == "form_post"
+}
+
+func (p *OIDCProvider) Exchange(ctx context.Context, code string, state *oauth.State) (*oauth.Identity, error) {
+	secret, err := p.cfg.ClientSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("client_id", p.cfg.ClientID)
+	form.Set("client_secret", secret)
+	if p.cfg.UsePKCE {
+		form.Set("code_verifier", state.CodeVerifier)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s token exchange failed with status %d: %s", p.cfg.Name, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var token struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, err
+	}
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("%s token response has no id_token", p.cfg.Name)
+	}
+
+	return p.verify(ctx, token.IDToken, state.Nonce)
+}
+
+type idTokenClaims struct {
+	Nonce         string      `json:"nonce"`
+	Email         string      `json:"email"`
+	EmailVerified interface{} `json:"email_verified"`
+	GivenName     string      `json:"given_name"`
+	FamilyName    string      `json:"family_name"`
+	jwt.RegisteredClaims
+}
+
+func (p *OIDCProvider) verify(ctx context.Context, raw, nonce string) (*oauth.Identity, error) {
+	var claims idTokenClaims
+	_, err := jwt.ParseWithClaims(raw, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	}, jwt.WithValidMethods([]string{"RS256"}), jwt.WithAudience(p.cfg.ClientID))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s id token: %w", p.cfg.Name, err)
+	}
+
+	if !p.trustedIssuer(claims.Issuer) {
+		return nil, fmt.Errorf("invalid %s id token: unexpected issuer %q", p.cfg.Name, claims.Issuer)
+	}
+	if claims.Nonce != nonce {
+		return nil, fmt.Errorf("invalid %s id token: nonce mismatch", p.cfg.Name)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("invalid %s id token: missing subject", p.cfg.Name)
+	}
+
+	return &oauth.Identity{
+		Provider:      p.cfg.Name,
+		Subject:       claims.Subject,
+		Email:         strings.ToLower(claims.Email),
+		EmailVerified: isTrue(claims.EmailVerified),
+		FirstName:     claims.GivenName,
+		LastName:      claims.FamilyName,
+	}, nil
+}
+
+func (p *OIDCProvider) trustedIssuer(issuer string) bool {
+	for _, trusted := range p.cfg.Issuers {
+		if issuer == trusted {
+			return true
+		}
+	}
+	return false
+}
+
+// key returns the signing key with the given id, refetching the key set when
+// the id is unknown (providers rotate keys) but at most once a minute.
+func (p *OIDCProvider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok && time.Since(p.fetchedAt) < jwksRefreshInterval {
+		return key, nil
+	}
+	if time.Since(p.fetchedAt) > time.Minute || len(p.keys) == 0 {
+		keys, err := p.fetchKeys(ctx)
+		if err != nil {
+			return nil, err
+		}
+		p.keys = keys
+		p.fetchedAt = time.Now()
+	}
+
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, errors.New("unknown signing key " + kid)
+	}
+	return key, nil
+}
+
+func (p *OIDCProvider) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.cfg.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s key set request failed with status %d", p.cfg.Name, resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+// isTrue reads email_verified, which Apple sends as a string
+func isTrue(v interface{}) bool {
+	switch value := v.(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package oauth
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"os"
+	"time"
+
+	"online-shop/internal/domain/oauth"
+	"online-shop/pkg/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	appleIssuer       = "https://appleid.apple.com"
+	appleSecretExpiry = 5 * time.Minute
+)
+
+// Providers builds an adapter for every provider with a client ID configured.
+func Providers(cfg *config.OAuthConfig) (map[string]oauth.Provider, error) {
+	providers := make(map[string]oauth.Provider)
+
+	if cfg.Google.ClientID != "" {
+		providers["google"] = NewGoogleProvider(&cfg.Google)
+	}
+	if cfg.Apple.ClientID != "" {
+		apple, err := NewAppleProvider(&cfg.Apple)
+		if err != nil {
+			return nil, err
+		}
+		providers["apple"] = apple
+	}
+	return providers, nil
+}
+
+func NewGoogleProvider(cfg *config.GoogleOAuthConfig) oauth.Provider {
+	secret := cfg.ClientSecret
+	return NewOIDCProvider(OIDCConfig{
+		Name:         "google",
+		ClientID:     cfg.ClientID,
+		ClientSecret: func() (string, error) { return secret, nil },
+		RedirectURL:  cfg.RedirectURL,
+		AuthURL:      "https://accounts.google.com/o/oauth2/v2/auth",
+		TokenURL:     "https://oauth2.googleapis.com/token",
+		JWKSURL:      "https://www.googleapis.com/oauth2/v3/certs",
+		Issuers:      []string{"https://accounts.google.com", "accounts.google.com"},
+		Scopes:       []string{"openid", "email", "profile"},
+		UsePKCE:      true,
+		AuthParams:   map[string]string{"prompt": "select_account"},
+	})
+}
+
+// NewAppleProvider signs in with Apple. Apple's client secret is an ES256 JWT
+// signed with the key downloaded from the developer account, and requesting
+// the email scope requires the callback to be a form POST.
+func NewAppleProvider(cfg *config.AppleOAuthConfig) (oauth.Provider, error) {
+	pem, err := os.ReadFile(cfg.PrivateKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	key, err := jwt.ParseECPrivateKeyFromPEM(pem)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.TeamID == "" || cfg.KeyID == "" {
+		return nil, errors.New("apple sign in needs a team id and key id")
+	}
+
+	return NewOIDCProvider(OIDCConfig{
+		Name:     "apple",
+		ClientID: cfg.ClientID,
+		ClientSecret: func() (string, error) {
+			return appleClientSecret(key, cfg.TeamID, cfg.KeyID, cfg.ClientID)
+		},
+		RedirectURL: cfg.RedirectURL,
+		AuthURL:     "https://appleid.apple.com/auth/authorize",
+		TokenURL:    "https://appleid.apple.com/auth/token",
+		JWKSURL:     "https://appleid.apple.com/auth/keys",
+		Issuers:     []string{appleIssuer},
+		Scopes:      []string{"name", "email"},
+		AuthParams:  map[string]string{"response_mode": "form_post"},
+	}), nil
+}
+
+func appleClientSecret(key *ecdsa.PrivateKey, teamID, keyID, clientID string) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.RegisteredClaims{
+		Issuer:    teamID,
+		Subject:   clientID,
+		Audience:  jwt.ClaimStrings{appleIssuer},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(appleSecretExpiry)),
+	})
+	token.Header["kid"] = keyID
+	return token.SignedString(key)
+}
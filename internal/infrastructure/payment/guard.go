@@ -0,0 +1,144 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"online-shop/internal/domain/payment"
+	"online-shop/pkg/config"
+	"online-shop/pkg/resilience"
+
+	"github.com/midtrans/midtrans-go"
+)
+
+// errAttemptTimeout marks an attempt that ran out of its own deadline, as
+// opposed to the caller's context ending
+var errAttemptTimeout = errors.New("provider call timed out")
+
+type GuardSettings struct {
+	// Timeout bounds each attempt
+	Timeout time.Duration
+	// Retries is how many more times an idempotent call is tried after a
+	// transient failure
+	Retries int
+	// Backoff is the wait before the first retry; it doubles for each one
+	// after that
+	Backoff time.Duration
+	// Resilience sizes the provider's bulkhead and circuit breaker
+	Resilience config.DependencyConfig
+}
+
+// Guard bounds the calls made to a payment provider. Each attempt gets a
+// deadline, idempotent calls are retried on transient failures, and once
+// the provider keeps failing or is saturated calls fail straight away with
+// payment.ErrProviderUnavailable so checkout does not hang on it.
+type Guard struct {
+	breaker  *resilience.Breaker
+	settings GuardSettings
+}
+
+func NewGuard(name string, settings GuardSettings) *Guard {
+	// A provider that answers, even with a refusal, is healthy
+	return &Guard{
+		breaker:  resilience.New(name, settings.Resilience, transient),
+		settings: settings,
+	}
+}
+
+// State reports the breaker's state: closed, half-open or open
+func (g *Guard) State() string {
+	return g.breaker.State()
+}
+
+// Call runs fn under the guard. A call that is not idempotent is tried only
+// once, since a request that timed out may still have reached the provider.
+func (g *Guard) Call(ctx context.Context, idempotent bool, fn func() (interface{}, error)) (interface{}, error) {
+	attempts := 1
+	if idempotent {
+		attempts += g.settings.Retries
+	}
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(g.settings.Backoff << (attempt - 1)):
+			}
+		}
+
+		var result interface{}
+		err = g.breaker.Do(func() error {
+			var err error
+			result, err = g.attempt(ctx, fn)
+			return err
+		})
+		if errors.Is(err, resilience.ErrUnavailable) {
+			return nil, fmt.Errorf("%w: %s %v", payment.ErrProviderUnavailable, g.breaker.Name(), err)
+		}
+		if !transient(err) {
+			return result, err
+		}
+	}
+	return nil, fmt.Errorf("%w: %v", payment.ErrProviderUnavailable, err)
+}
+
+// attempt runs fn with the guard's timeout. fn keeps running in the
+// background if it overruns, so it must be bounded by its own client
+// timeout too.
+func (g *Guard) attempt(ctx context.Context, fn func() (interface{}, error)) (interface{}, error) {
+	deadline, cancel := context.WithTimeout(ctx, g.settings.Timeout)
+	defer cancel()
+
+	type outcome struct {
+		result interface{}
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := fn()
+		done <- outcome{result, err}
+	}()
+
+	select {
+	case o := <-done:
+		return o.result, o.err
+	case <-deadline.Done():
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errAttemptTimeout
+	}
+}
+
+// transient reports whether err is worth retrying: a timeout, a request
+// that never got an answer, rate limiting or a server error
+func transient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, errAttemptTimeout) {
+		return true
+	}
+	var merr *midtrans.Error
+	if errors.As(err, &merr) {
+		code := merr.GetStatusCode()
+		return (code == 0 && merr.RawError != nil) ||
+			code == http.StatusRequestTimeout ||
+			code == http.StatusTooManyRequests ||
+			code >= http.StatusInternalServerError
+	}
+	return false
+}
+
+// midtransErr keeps a nil *midtrans.Error from becoming a non-nil error
+func midtransErr(err *midtrans.Error) error {
+	if err == nil {
+		return nil
+	}
+	return err
+}
@@ -1,42 +1,103 @@
 package payment
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
+	"net/http"
 	"online-shop/internal/domain/payment"
+	"online-shop/internal/domain/reconciliation"
 	"online-shop/pkg/config"
+	"online-shop/pkg/httpclient"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/midtrans/midtrans-go"
+	"github.com/midtrans/midtrans-go/coreapi"
 	"github.com/midtrans/midtrans-go/snap"
 )
 
+// ProviderMidtrans names Midtrans on the payment methods saved with it
+const ProviderMidtrans = "midtrans"
+
 type MidtransProvider struct {
-	client snap.Client
-	config *config.MidtransConfig
+	mu         sync.RWMutex
+	client     snap.Client
+	coreClient coreapi.Client
+	config     *config.MidtransConfig
+	guard      *Guard
 }
 
 func NewMidtransProvider(cfg *config.MidtransConfig) *MidtransProvider {
-	var env midtrans.EnvironmentType
-	if cfg.Environment == "production" {
-		env = midtrans.Production
-	} else {
-		env = midtrans.Sandbox
+	return &MidtransProvider{
+		client:     newSnapClient(cfg),
+		coreClient: newCoreClient(cfg),
+		config:     cfg,
+		guard: NewGuard(ProviderMidtrans, GuardSettings{
+			Timeout:    cfg.Timeout(),
+			Retries:    cfg.Retries,
+			Backoff:    cfg.RetryBackoff(),
+			Resilience: cfg.Resilience,
+		}),
 	}
+}
 
-	client := snap.Client{}
-	client.New(cfg.ServerKey, env)
+// Guard exposes the breaker that Midtrans calls go through
+func (p *MidtransProvider) Guard() *Guard {
+	return p.guard
+}
 
-	return &MidtransProvider{
-		client: client,
-		config: cfg,
+func midtransEnvironment(environment string) midtrans.EnvironmentType {
+	if environment == "production" {
+		return midtrans.Production
+	}
+	return midtrans.Sandbox
+}
+
+// midtransHTTPClient times requests out by itself: the library ignores the
+// context set on its options, so the guard's deadline cannot stop a request
+// that hangs. The guard does the retrying.
+func midtransHTTPClient(cfg *config.MidtransConfig) *midtrans.HttpClientImplementation {
+	env := midtransEnvironment(cfg.Environment)
+	return &midtrans.HttpClientImplementation{
+		HttpClient: httpclient.New(httpclient.Options{Name: ProviderMidtrans, Timeout: cfg.Timeout()}),
+		Logger:     midtrans.GetDefaultLogger(env),
 	}
 }
 
-func (p *MidtransProvider) CreatePayment(pay *payment.Payment) (*payment.PaymentResponse, error) {
+func newSnapClient(cfg *config.MidtransConfig) snap.Client {
+	client := snap.Client{}
+	client.New(cfg.ServerKey, midtransEnvironment(cfg.Environment))
+	client.HttpClient = midtransHTTPClient(cfg)
+	return client
+}
+
+func newCoreClient(cfg *config.MidtransConfig) coreapi.Client {
+	client := coreapi.Client{}
+	client.New(cfg.ServerKey, midtransEnvironment(cfg.Environment))
+	client.HttpClient = midtransHTTPClient(cfg)
+	return client
+}
+
+// SetServerKey switches to a rotated server key without a restart
+func (p *MidtransProvider) SetServerKey(serverKey string) {
+	cfg := *p.config
+	cfg.ServerKey = serverKey
+	client := newSnapClient(&cfg)
+	coreClient := newCoreClient(&cfg)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.client = client
+	p.coreClient = coreClient
+}
+
+func (p *MidtransProvider) CreatePayment(ctx context.Context, pay *payment.Payment) (*payment.PaymentResponse, error) {
 	req := &snap.Request{
 		TransactionDetails: midtrans.TransactionDetails{
 			OrderID:  pay.ID,
-			GrossAmt: int64(pay.Amount),
+			GrossAmt: grossAmount(pay.Amount),
 		},
 		CreditCard: &snap.CreditCardDetails{
 			Secure: true,
@@ -53,10 +114,20 @@ func (p *MidtransProvider) CreatePayment(pay *payment.Payment) (*payment.Payment
 		},
 	}
 
-	snapResp, err := p.client.CreateTransaction(req)
+	p.mu.RLock()
+	client := p.client
+	p.mu.RUnlock()
+
+	// Midtrans refuses a second transaction for the same order ID, so a
+	// create that timed out is not retried
+	result, err := p.guard.Call(ctx, false, func() (interface{}, error) {
+		resp, merr := client.CreateTransaction(req)
+		return resp, midtransErr(merr)
+	})
 	if err != nil {
 		return nil, err
 	}
+	snapResp := result.(*snap.Response)
 
 	return &payment.PaymentResponse{
 		PaymentURL:    snapResp.RedirectURL,
@@ -66,7 +137,144 @@ func (p *MidtransProvider) CreatePayment(pay *payment.Payment) (*payment.Payment
 	}, nil
 }
 
-func (p *MidtransProvider) GetPaymentStatus(externalID string) (*payment.PaymentStatus, error) {
+// ChargeToken charges a saved card token through the Core API. Midtrans
+// answers a card that needs 3-D Secure with a pending status and the URL
+// the customer completes it on.
+func (p *MidtransProvider) ChargeToken(ctx context.Context, pay *payment.Payment, token string) (*payment.ChargeResult, error) {
+	req := &coreapi.ChargeReq{
+		PaymentType: coreapi.PaymentTypeCreditCard,
+		TransactionDetails: midtrans.TransactionDetails{
+			OrderID:  pay.ID,
+			GrossAmt: grossAmount(pay.Amount),
+		},
+		CreditCard: &coreapi.CreditCardDetails{
+			TokenID: token,
+		},
+	}
+
+	p.mu.RLock()
+	client := p.coreClient
+	p.mu.RUnlock()
+
+	// The payment ID as idempotency key lets a retried charge return the
+	// first charge's result instead of charging the card twice
+	client.Options = &midtrans.ConfigOptions{}
+	client.Options.SetPaymentIdempotencyKey(pay.ID)
+	charged, err := p.guard.Call(ctx, true, func() (interface{}, error) {
+		resp, merr := client.ChargeTransaction(req)
+		return resp, midtransErr(merr)
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := charged.(*coreapi.ChargeResponse)
+
+	result := &payment.ChargeResult{
+		TransactionID: resp.TransactionID,
+		RedirectURL:   resp.RedirectURL,
+	}
+	switch {
+	case resp.FraudStatus == "deny":
+		return nil, fmt.Errorf("%w: flagged by fraud detection", payment.ErrChargeDeclined)
+	case resp.TransactionStatus == "capture" || resp.TransactionStatus == "settlement":
+		result.Status = payment.StatusPaid
+	case resp.TransactionStatus == "pending":
+		result.Status = payment.StatusPending
+	case resp.TransactionStatus == "deny":
+		return nil, fmt.Errorf("%w: %s", payment.ErrChargeDeclined, resp.StatusMessage)
+	default:
+		result.Status = payment.StatusFailed
+	}
+	return result, nil
+}
+
+// grossAmount is the amount in whole rupiah, which is all Midtrans takes.
+// It is rounded rather than truncated, so 999.99 is not charged as 999.
+func grossAmount(amount float64) int64 {
+	return int64(math.Round(amount))
+}
+
+func (p *MidtransProvider) Provider() string {
+	return ProviderMidtrans
+}
+
+// midtransTime is the zone Midtrans reports times in
+var midtransTime = time.FixedZone("WIB", 7*60*60)
+
+// Settlements looks up each payment's transaction, since Midtrans has no
+// settlement report API. A transaction Midtrans does not know is left out,
+// so settlements orphaned at Midtrans are only caught from its dashboard.
+func (p *MidtransProvider) Settlements(ctx context.Context, from, to time.Time, payments []*payment.Payment) ([]reconciliation.Settlement, error) {
+	p.mu.RLock()
+	client := p.coreClient
+	p.mu.RUnlock()
+
+	settlements := make([]reconciliation.Settlement, 0, len(payments))
+	for _, pay := range payments {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if pay.ExternalID == "" {
+			continue
+		}
+
+		externalID := pay.ExternalID
+		checked, err := p.guard.Call(ctx, true, func() (interface{}, error) {
+			resp, merr := client.CheckTransaction(externalID)
+			return resp, midtransErr(merr)
+		})
+		if err != nil {
+			var merr *midtrans.Error
+			if errors.As(err, &merr) && merr.GetStatusCode() == http.StatusNotFound {
+				continue
+			}
+			return nil, fmt.Errorf("checking transaction %s: %w", pay.ExternalID, err)
+		}
+		resp := checked.(*coreapi.TransactionStatusResponse)
+		if resp.StatusCode == strconv.Itoa(http.StatusNotFound) {
+			continue
+		}
+
+		amount, err := strconv.ParseFloat(resp.GrossAmount, 64)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %s has gross amount %q: %w", pay.ExternalID, resp.GrossAmount, err)
+		}
+		s := reconciliation.Settlement{
+			ExternalID:    pay.ExternalID,
+			TransactionID: resp.TransactionID,
+			Amount:        amount,
+			Status:        midtransStatus(resp.TransactionStatus, resp.FraudStatus),
+		}
+		if settledAt, err := time.ParseInLocation("2006-01-02 15:04:05", resp.SettlementTime, midtransTime); err == nil {
+			s.SettledAt = &settledAt
+		}
+		settlements = append(settlements, s)
+	}
+	return settlements, nil
+}
+
+// midtransStatus maps a transaction status the way HandleWebhook does. A
+// status it does not know is kept as is, so it shows up as a mismatch.
+func midtransStatus(transactionStatus, fraudStatus string) payment.Status {
+	switch transactionStatus {
+	case "capture":
+		if fraudStatus == "challenge" {
+			return payment.StatusPending
+		}
+		return payment.StatusPaid
+	case "settlement":
+		return payment.StatusPaid
+	case "pending", "authorize":
+		return payment.StatusPending
+	case "deny", "cancel", "expire", "failure":
+		return payment.StatusFailed
+	case "refund", "partial_refund":
+		return payment.StatusRefunded
+	}
+	return payment.Status(transactionStatus)
+}
+
+func (p *MidtransProvider) GetPaymentStatus(ctx context.Context, externalID string) (*payment.PaymentStatus, error) {
 	// In a real implementation, you would call Midtrans API to get transaction status
 	// For now, we'll return a mock response
 	return &payment.PaymentStatus{
@@ -76,14 +284,44 @@ func (p *MidtransProvider) GetPaymentStatus(externalID string) (*payment.Payment
 	}, nil
 }
 
-func (p *MidtransProvider) RefundPayment(externalID string, amount float64) error {
-	// Implement refund logic using Midtrans API
-	return fmt.Errorf("refund not implemented")
+// RefundPayment pays amount of a settled transaction back, sending key as
+// Midtrans' refund key.
+func (p *MidtransProvider) RefundPayment(ctx context.Context, externalID string, amount float64, key string) error {
+	req := &coreapi.RefundReq{
+		RefundKey: key,
+		Amount:    int64(amount),
+	}
+
+	p.mu.RLock()
+	client := p.coreClient
+	p.mu.RUnlock()
+
+	refunded, err := p.guard.Call(ctx, true, func() (interface{}, error) {
+		resp, merr := client.RefundTransaction(externalID, req)
+		return resp, midtransErr(merr)
+	})
+	if err != nil {
+		var merr *midtrans.Error
+		if errors.As(err, &merr) {
+			return fmt.Errorf("%w: %s", payment.ErrRefundDeclined, merr.GetMessage())
+		}
+		return err
+	}
+	resp := refunded.(*coreapi.RefundResponse)
+	if resp.StatusCode != strconv.Itoa(http.StatusOK) {
+		return fmt.Errorf("%w: %s", payment.ErrRefundDeclined, resp.StatusMessage)
+	}
+	return nil
 }
 
 type PaymentService struct {
 	provider payment.PaymentProvider
 	repo     payment.Repository
+	// onStatusChange is told the order of every payment whose status changes
+	onStatusChange func(ctx context.Context, orderID string) error
+	// onDraftPayment is told every payment of a draft order whose status
+	// changes
+	onDraftPayment func(ctx context.Context, paymentID string) error
 }
 
 func NewPaymentService(provider payment.PaymentProvider, repo payment.Repository) *PaymentService {
@@ -93,11 +331,41 @@ func NewPaymentService(provider payment.PaymentProvider, repo payment.Repository
 	}
 }
 
-func (s *PaymentService) CreatePayment(orderID, userID string, amount float64, method payment.Method) (*payment.Payment, error) {
+// OnStatusChange registers fn to run with the order ID whenever a payment's
+// status changes, so an order paid in several parts is reconciled as each
+// part settles. An error from fn fails the webhook for the provider to
+// retry.
+func (s *PaymentService) OnStatusChange(fn func(ctx context.Context, orderID string) error) {
+	s.onStatusChange = fn
+}
+
+// OnDraftPayment registers fn to run with the payment ID whenever the
+// status of a payment started through a draft order's link changes, so the
+// draft is converted into an order once it is paid. An error from fn fails
+// the webhook for the provider to retry.
+func (s *PaymentService) OnDraftPayment(fn func(ctx context.Context, paymentID string) error) {
+	s.onDraftPayment = fn
+}
+
+func (s *PaymentService) statusChanged(ctx context.Context, pay *payment.Payment) error {
+	// A draft order's payment has no order until the draft is converted
+	if pay.OrderID == "" && pay.DraftOrderID != "" {
+		if s.onDraftPayment == nil {
+			return nil
+		}
+		return s.onDraftPayment(ctx, pay.ID)
+	}
+	if s.onStatusChange == nil {
+		return nil
+	}
+	return s.onStatusChange(ctx, pay.OrderID)
+}
+
+func (s *PaymentService) CreatePayment(ctx context.Context, orderID, userID string, amount float64, method payment.Method) (*payment.Payment, error) {
 	pay := payment.NewPayment(orderID, userID, amount, method)
 
 	// Create payment with provider
-	response, err := s.provider.CreatePayment(pay)
+	response, err := s.provider.CreatePayment(ctx, pay)
 	if err != nil {
 		return nil, err
 	}
@@ -109,21 +377,21 @@ func (s *PaymentService) CreatePayment(orderID, userID string, amount float64, m
 	pay.ExpiresAt = response.ExpiresAt
 
 	// Save to database
-	if err := s.repo.Create(pay); err != nil {
+	if err := s.repo.Create(ctx, pay); err != nil {
 		return nil, err
 	}
 
 	return pay, nil
 }
 
-func (s *PaymentService) ProcessPayment(paymentID string) (*payment.Payment, error) {
-	pay, err := s.repo.GetByID(paymentID)
+func (s *PaymentService) ProcessPayment(ctx context.Context, paymentID string) (*payment.Payment, error) {
+	pay, err := s.repo.GetByID(ctx, paymentID)
 	if err != nil {
 		return nil, err
 	}
 
 	// Get status from provider
-	status, err := s.provider.GetPaymentStatus(pay.ExternalID)
+	status, err := s.provider.GetPaymentStatus(ctx, pay.ExternalID)
 	if err != nil {
 		return nil, err
 	}
@@ -139,14 +407,17 @@ func (s *PaymentService) ProcessPayment(paymentID string) (*payment.Payment, err
 	}
 
 	// Save updated payment
-	if err := s.repo.Update(pay); err != nil {
+	if err := s.repo.Update(ctx, pay); err != nil {
+		return nil, err
+	}
+	if err := s.statusChanged(ctx, pay); err != nil {
 		return nil, err
 	}
 
 	return pay, nil
 }
 
-func (s *PaymentService) HandleWebhook(data map[string]interface{}) error {
+func (s *PaymentService) HandleWebhook(ctx context.Context, data map[string]interface{}) error {
 	// Extract transaction data from webhook
 	orderID, ok := data["order_id"].(string)
 	if !ok {
@@ -159,7 +430,7 @@ func (s *PaymentService) HandleWebhook(data map[string]interface{}) error {
 	}
 
 	// Get payment by external ID (order_id in Midtrans)
-	pay, err := s.repo.GetByExternalID(orderID)
+	pay, err := s.repo.GetByExternalID(ctx, orderID)
 	if err != nil {
 		return err
 	}
@@ -173,6 +444,9 @@ func (s *PaymentService) HandleWebhook(data map[string]interface{}) error {
 		status = payment.StatusPending
 	case "deny", "cancel", "expire":
 		status = payment.StatusFailed
+	case "refund", "partial_refund":
+		// Refunds are recorded on the payment when they are issued
+		return nil
 	default:
 		return fmt.Errorf("unknown transaction status: %s", transactionStatus)
 	}
@@ -182,11 +456,14 @@ func (s *PaymentService) HandleWebhook(data map[string]interface{}) error {
 		transactionID = tid
 	}
 
-	return s.repo.UpdateStatus(pay.ID, status, transactionID)
+	if err := s.repo.UpdateStatus(ctx, pay.ID, status, transactionID); err != nil {
+		return err
+	}
+	return s.statusChanged(ctx, pay)
 }
 
-func (s *PaymentService) RefundPayment(paymentID string, amount float64) error {
-	pay, err := s.repo.GetByID(paymentID)
+func (s *PaymentService) RefundPayment(ctx context.Context, paymentID string, amount float64) error {
+	pay, err := s.repo.GetByID(ctx, paymentID)
 	if err != nil {
 		return err
 	}
@@ -195,15 +472,20 @@ func (s *PaymentService) RefundPayment(paymentID string, amount float64) error {
 		return fmt.Errorf("payment cannot be refunded")
 	}
 
-	// Process refund with provider
-	if err := s.provider.RefundPayment(pay.ExternalID, amount); err != nil {
+	// The key is the total refunded with this refund, so a retry of it is
+	// not paid out again while a later refund gets a key of its own
+	key := fmt.Sprintf("%s-refund-%d", pay.ID, int64(pay.RefundedAmount+amount))
+	if err := s.provider.RefundPayment(ctx, pay.ExternalID, amount, key); err != nil {
 		return err
 	}
 
-	// Update payment status
-	return s.repo.UpdateStatus(pay.ID, payment.StatusRefunded, "")
+	pay.MarkAsRefunded(amount)
+	if err := s.repo.Update(ctx, pay); err != nil {
+		return err
+	}
+	return s.statusChanged(ctx, pay)
 }
 
-func (s *PaymentService) GetPayment(id string) (*payment.Payment, error) {
-	return s.repo.GetByID(id)
+func (s *PaymentService) GetPayment(ctx context.Context, id string) (*payment.Payment, error) {
+	return s.repo.GetByID(ctx, id)
 }
\ No newline at end of file
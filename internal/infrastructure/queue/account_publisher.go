@@ -0,0 +1,33 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+
+	"online-shop/internal/domain/user"
+)
+
+// AccountPublisher sends account deletion events to the account events queue
+type AccountPublisher struct {
+	rabbitmq *RabbitMQ
+}
+
+// NewAccountPublisher creates a new account event publisher
+func NewAccountPublisher(rabbitmq *RabbitMQ) user.DeletionPublisher {
+	return &AccountPublisher{rabbitmq: rabbitmq}
+}
+
+// PublishDeletion publishes an account deletion event
+func (p *AccountPublisher) PublishDeletion(ctx context.Context, event user.DeletionEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+
+	return p.rabbitmq.PublishAccountEvent(ctx, payload)
+}
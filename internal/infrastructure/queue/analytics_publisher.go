@@ -0,0 +1,34 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+
+	"online-shop/internal/domain/analytics"
+)
+
+// AnalyticsPublisher sends analytics events to the analytics queue over
+// the configured event broker
+type AnalyticsPublisher struct {
+	broker Broker
+}
+
+// NewAnalyticsPublisher creates a new analytics publisher
+func NewAnalyticsPublisher(broker Broker) analytics.Publisher {
+	return &AnalyticsPublisher{broker: broker}
+}
+
+// Publish publishes an analytics event
+func (p *AnalyticsPublisher) Publish(ctx context.Context, event analytics.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+
+	return p.broker.Publish(ctx, AnalyticsQueue, analyticsMessage(payload))
+}
@@ -0,0 +1,56 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"online-shop/pkg/config"
+)
+
+// Broker carries a queue's messages from the processes publishing them to
+// the workers consuming them. A message handled without error is done with;
+// one that fails is delivered again until its MaxRetries are used up.
+type Broker interface {
+	Publish(ctx context.Context, queueName string, message Message) error
+	Consume(ctx context.Context, queueName string, handler func(Message) error) error
+	HealthCheck() error
+	Close() error
+}
+
+// Publish publishes a message to a queue
+func (r *RabbitMQ) Publish(ctx context.Context, queueName string, message Message) error {
+	return r.publishMessage(ctx, queueName, message)
+}
+
+// Consume consumes a queue's messages like ConsumeMessages
+func (r *RabbitMQ) Consume(ctx context.Context, queueName string, handler func(Message) error) error {
+	return r.ConsumeMessages(ctx, queueName, handler)
+}
+
+// NewEventBroker returns the broker carrying analytics events, picked by
+// analytics.transport: rabbitmq, which may be nil when it could not be
+// connected, kafka or nats. The returned func closes a broker opened here.
+func NewEventBroker(cfg *config.Config, rabbitmq *RabbitMQ, logger *zap.Logger) (Broker, func(), error) {
+	switch strings.ToLower(cfg.Analytics.Transport) {
+	case "kafka":
+		kafka, err := NewKafka(&cfg.Kafka, logger)
+		if err != nil {
+			return nil, func() {}, err
+		}
+		return kafka, func() { kafka.Close() }, nil
+	case "nats":
+		nats, err := NewNATS(&cfg.NATS, logger)
+		if err != nil {
+			return nil, func() {}, err
+		}
+		return nats, func() { nats.Close() }, nil
+	default:
+		if rabbitmq == nil {
+			return nil, func() {}, fmt.Errorf("rabbitmq is not connected")
+		}
+		return rabbitmq, func() {}, nil
+	}
+}
@@ -0,0 +1,201 @@
+package queue
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/streadway/amqp"
+
+	"online-shop/pkg/config"
+)
+
+var (
+	queueConsumers = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "queue_consumers",
+			Help: "Consumers handling each queue's messages",
+		},
+		[]string{"queue"},
+	)
+
+	queueBacklog = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "queue_backlog_messages",
+			Help: "Messages waiting in each queue when it was last inspected",
+		},
+		[]string{"queue"},
+	)
+)
+
+// Email priorities, for an email queue declared with a max priority:
+// transactional emails such as receipts and password resets are sent
+// before campaign batches. An email's own Priority is added on top.
+const (
+	PriorityBulk          = 0
+	PriorityTransactional = 5
+)
+
+// ConsumerCount is how many consumers a queue gets with backlog messages
+// waiting: the minimum, and one more for every BacklogPerConsumer
+// messages, up to the maximum
+func ConsumerCount(backlog int, cfg config.QueueConsumerConfig) int {
+	count := cfg.MinConsumers
+	if cfg.BacklogPerConsumer > 0 {
+		count += backlog / cfg.BacklogPerConsumer
+	}
+	if count > cfg.MaxConsumers {
+		count = cfg.MaxConsumers
+	}
+	return count
+}
+
+// consumerConfig is the queue's consumer config, defaulting to the worker
+// count configured for it before queues could be tuned on their own
+func (r *RabbitMQ) consumerConfig(queueName string) config.QueueConsumerConfig {
+	workers := r.config.Workers
+	counts := map[string]int{
+		EmailQueue:        workers.EmailWorkers,
+		InvoiceQueue:      workers.InvoiceWorkers,
+		NotificationQueue: workers.NotificationWorkers,
+		AnalyticsQueue:    workers.AnalyticsWorkers,
+		ExportQueue:       workers.ExportWorkers,
+	}
+	return workers.Queue(queueName, counts[queueName])
+}
+
+// priority caps a message's priority at what its queue was declared with
+func (r *RabbitMQ) priority(queueName string, priority int) uint8 {
+	highest := r.consumerConfig(queueName).MaxPriority
+	if priority > highest {
+		priority = highest
+	}
+	if priority < 0 {
+		priority = 0
+	}
+	return uint8(priority)
+}
+
+// consumerGroup runs the goroutines handling one queue's deliveries. It is
+// scaled from a single goroutine; a consumer stopped by scaling down
+// finishes the message it is handling first.
+type consumerGroup struct {
+	deliveries <-chan amqp.Delivery
+	handle     func(amqp.Delivery)
+	stops      []chan struct{}
+	wg         sync.WaitGroup
+	// closed is closed once the deliveries channel is, when the channel
+	// or connection to the broker went away
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newConsumerGroup(deliveries <-chan amqp.Delivery, handle func(amqp.Delivery)) *consumerGroup {
+	return &consumerGroup{deliveries: deliveries, handle: handle, closed: make(chan struct{})}
+}
+
+func (g *consumerGroup) size() int {
+	return len(g.stops)
+}
+
+func (g *consumerGroup) scale(n int) {
+	for len(g.stops) < n {
+		stop := make(chan struct{})
+		g.stops = append(g.stops, stop)
+		g.wg.Add(1)
+		go g.run(stop)
+	}
+	for len(g.stops) > n {
+		close(g.stops[len(g.stops)-1])
+		g.stops = g.stops[:len(g.stops)-1]
+	}
+}
+
+// stop stops every consumer and waits for the messages being handled
+func (g *consumerGroup) stop() {
+	g.scale(0)
+	g.wg.Wait()
+}
+
+func (g *consumerGroup) run(stop chan struct{}) {
+	defer g.wg.Done()
+	for {
+		select {
+		case <-stop:
+			return
+		case delivery, ok := <-g.deliveries:
+			if !ok {
+				g.closeOnce.Do(func() { close(g.closed) })
+				return
+			}
+			g.handle(delivery)
+		}
+	}
+}
+
+// ConsumerState is what a queue's consumers are doing, for debugging
+type ConsumerState struct {
+	Queue        string     `json:"queue"`
+	Consumers    int        `json:"consumers"`
+	MinConsumers int        `json:"min_consumers"`
+	MaxConsumers int        `json:"max_consumers"`
+	Prefetch     int        `json:"prefetch"`
+	Backlog      int        `json:"backlog"`
+	InspectedAt  *time.Time `json:"inspected_at,omitempty"`
+}
+
+// consumerStates holds the state of the queues being consumed
+type consumerStates struct {
+	mu     sync.Mutex
+	queues map[string]ConsumerState
+}
+
+func newConsumerStates() *consumerStates {
+	return &consumerStates{queues: map[string]ConsumerState{}}
+}
+
+func (s *consumerStates) started(queueName string, cfg config.QueueConsumerConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queues[queueName] = ConsumerState{
+		Queue:        queueName,
+		Consumers:    cfg.MinConsumers,
+		MinConsumers: cfg.MinConsumers,
+		MaxConsumers: cfg.MaxConsumers,
+		Prefetch:     cfg.Prefetch,
+	}
+	queueConsumers.WithLabelValues(queueName).Set(float64(cfg.MinConsumers))
+}
+
+func (s *consumerStates) inspected(queueName string, backlog, consumers int, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.queues[queueName]
+	state.Backlog = backlog
+	state.Consumers = consumers
+	state.InspectedAt = &at
+	s.queues[queueName] = state
+	queueBacklog.WithLabelValues(queueName).Set(float64(backlog))
+	queueConsumers.WithLabelValues(queueName).Set(float64(consumers))
+}
+
+func (s *consumerStates) stopped(queueName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.queues, queueName)
+	queueConsumers.WithLabelValues(queueName).Set(0)
+}
+
+// ConsumerStates returns the state of each queue being consumed, by name
+func (r *RabbitMQ) ConsumerStates() []ConsumerState {
+	r.consumers.mu.Lock()
+	defer r.consumers.mu.Unlock()
+	states := make([]ConsumerState, 0, len(r.consumers.queues))
+	for _, state := range r.consumers.queues {
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Queue < states[j].Queue })
+	return states
+}
@@ -0,0 +1,52 @@
+package queue
+
+import (
+	"context"
+
+	"online-shop/internal/domain/draftorder"
+	"online-shop/internal/domain/notification"
+)
+
+// DraftOrderPublisher queues the email with a draft order's payment link
+type DraftOrderPublisher struct {
+	rabbitmq *RabbitMQ
+}
+
+// NewDraftOrderPublisher creates a new draft order publisher
+func NewDraftOrderPublisher(rabbitmq *RabbitMQ) draftorder.Notifier {
+	return &DraftOrderPublisher{rabbitmq: rabbitmq}
+}
+
+// LinkSent emails the customer the link to pay their draft order
+func (p *DraftOrderPublisher) LinkSent(ctx context.Context, n draftorder.LinkNotice) error {
+	d := n.Draft
+	items := make([]map[string]interface{}, len(d.Items))
+	for i, item := range d.Items {
+		items[i] = map[string]interface{}{
+			"ProductName": item.Name,
+			"Quantity":    item.Quantity,
+			"UnitPrice":   item.Price,
+			"TotalPrice":  item.Price * float64(item.Quantity),
+		}
+	}
+
+	data := map[string]interface{}{
+		"CustomerName": n.CustomerName,
+		"Items":        items,
+		"TotalAmount":  d.TotalAmount,
+		"ExpiresAt":    d.ExpiresAt.Format("January 2, 2006"),
+		"Link":         n.Link,
+	}
+	if d.ShippingFee > 0 {
+		data["ShippingAmount"] = d.ShippingFee
+	}
+
+	return p.rabbitmq.PublishEmail(ctx, EmailMessage{
+		To:       d.Email,
+		Template: "draft_order",
+		Data:     data,
+		Priority: 2,
+		Locale:   n.Locale,
+		Category: string(notification.CategoryOrders),
+	})
+}
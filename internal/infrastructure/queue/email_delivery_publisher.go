@@ -0,0 +1,49 @@
+package queue
+
+import (
+	"context"
+
+	"online-shop/internal/domain/emaildelivery"
+)
+
+// EmailDeliveryPublisher queues dead letter resends and campaign batches
+type EmailDeliveryPublisher struct {
+	rabbitmq *RabbitMQ
+}
+
+// NewEmailDeliveryPublisher creates a new email delivery publisher
+func NewEmailDeliveryPublisher(rabbitmq *RabbitMQ) emaildelivery.Publisher {
+	return &EmailDeliveryPublisher{rabbitmq: rabbitmq}
+}
+
+// Resend queues the email as it was first queued, pinned to the same
+// template version
+func (p *EmailDeliveryPublisher) Resend(ctx context.Context, d *emaildelivery.DeadLetter) error {
+	return p.rabbitmq.PublishEmail(ctx, EmailMessage{
+		To:         d.To,
+		Subject:    d.Subject,
+		Template:   d.Template,
+		Data:       d.Data,
+		Locale:     d.Locale,
+		Version:    d.Version,
+		CampaignID: d.CampaignID,
+		Category:   d.Category,
+	})
+}
+
+func (p *EmailDeliveryPublisher) PublishBatch(ctx context.Context, b *emaildelivery.Batch) error {
+	recipients := make([]EmailRecipient, len(b.Recipients))
+	for i, r := range b.Recipients {
+		recipients[i] = EmailRecipient{To: r.To, Data: r.Data}
+	}
+	return p.rabbitmq.PublishEmailBatch(ctx, EmailBatchMessage{
+		CampaignID: b.CampaignID,
+		Template:   b.Template,
+		Subject:    b.Subject,
+		Locale:     b.Locale,
+		Data:       b.Data,
+		Recipients: recipients,
+		Tracked:    b.Tracked,
+		Category:   b.Category,
+	})
+}
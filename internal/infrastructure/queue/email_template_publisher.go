@@ -0,0 +1,29 @@
+package queue
+
+import (
+	"context"
+
+	"online-shop/internal/domain/emailtemplate"
+)
+
+// EmailTemplatePublisher queues test sends of email templates
+type EmailTemplatePublisher struct {
+	rabbitmq *RabbitMQ
+}
+
+// NewEmailTemplatePublisher creates a new email template publisher
+func NewEmailTemplatePublisher(rabbitmq *RabbitMQ) emailtemplate.Publisher {
+	return &EmailTemplatePublisher{rabbitmq: rabbitmq}
+}
+
+// SendTest queues the template at its version, so the worker renders the
+// version being tested even when another one is active
+func (p *EmailTemplatePublisher) SendTest(ctx context.Context, to string, t *emailtemplate.Template, locale string, data map[string]interface{}) error {
+	return p.rabbitmq.PublishEmail(ctx, EmailMessage{
+		To:       to,
+		Template: t.Name,
+		Data:     data,
+		Locale:   locale,
+		Version:  t.Version,
+	})
+}
@@ -0,0 +1,63 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+
+	"online-shop/internal/domain/export"
+	"online-shop/internal/domain/notification"
+)
+
+// ExportPublisher queues export jobs and notifies requesters when they finish
+type ExportPublisher struct {
+	rabbitmq *RabbitMQ
+}
+
+// NewExportPublisher creates a new export publisher
+func NewExportPublisher(rabbitmq *RabbitMQ) *ExportPublisher {
+	return &ExportPublisher{rabbitmq: rabbitmq}
+}
+
+// Enqueue publishes an export request for the export workers
+func (p *ExportPublisher) Enqueue(ctx context.Context, exportID string) error {
+	return p.rabbitmq.PublishExport(ctx, map[string]interface{}{
+		"export_id": exportID,
+	})
+}
+
+// ExportFinished sends the requester an email and in-app notification
+func (p *ExportPublisher) ExportFinished(ctx context.Context, e *export.Export, downloadURL string) error {
+	notification := map[string]interface{}{
+		"user_id":  e.RequestedBy,
+		"type":     "export_ready",
+		"title":    fmt.Sprintf("Your %s export is ready", e.Type),
+		"message":  fmt.Sprintf("The %s report you requested has %d rows and is ready to download.", e.Type, e.RowCount),
+		"priority": 3,
+		"category": notification.CategoryAccount,
+		"channels": []string{"email", "in-app"},
+		"data": map[string]interface{}{
+			"export_id":    e.ID,
+			"format":       e.Format,
+			"download_url": downloadURL,
+		},
+	}
+
+	if e.Type == export.TypePersonalData {
+		notification["type"] = "data_export_ready"
+		notification["title"] = "Your personal data export is ready"
+		notification["message"] = "A copy of the personal data we hold about you is ready to download. The link expires, but you can request a new export at any time."
+	}
+
+	if e.Status == export.StatusFailed {
+		notification["type"] = "export_failed"
+		notification["title"] = fmt.Sprintf("Your %s export failed", e.Type)
+		notification["message"] = "The report could not be generated: " + e.Error
+		if e.Type == export.TypePersonalData {
+			notification["type"] = "data_export_failed"
+			notification["title"] = "Your personal data export failed"
+			notification["message"] = "We could not prepare your data export. Please try again later."
+		}
+	}
+
+	return p.rabbitmq.PublishNotification(ctx, notification)
+}
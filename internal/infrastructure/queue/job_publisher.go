@@ -0,0 +1,30 @@
+package queue
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/domain/job"
+)
+
+// JobPublisher puts retried jobs back on the queue they came from
+type JobPublisher struct {
+	rabbitmq *RabbitMQ
+}
+
+// NewJobPublisher creates a new job publisher
+func NewJobPublisher(rabbitmq *RabbitMQ) *JobPublisher {
+	return &JobPublisher{rabbitmq: rabbitmq}
+}
+
+// Requeue publishes the job's message again under the same ID, with a fresh
+// set of attempts
+func (p *JobPublisher) Requeue(ctx context.Context, j *job.Job) error {
+	return p.rabbitmq.publishMessage(ctx, j.Queue, Message{
+		ID:         j.ID,
+		Type:       j.Type,
+		Payload:    j.Payload,
+		Timestamp:  time.Now(),
+		MaxRetries: j.MaxAttempts,
+	})
+}
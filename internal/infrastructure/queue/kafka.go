@@ -0,0 +1,261 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"online-shop/pkg/config"
+	"online-shop/pkg/resilience"
+)
+
+// Kafka carries queue messages over Kafka topics, for deployments with
+// more event volume than suits RabbitMQ. Each queue is a topic; the
+// workers consume it in a consumer group, so the topic's partitions are
+// split between the worker processes, and commit a message's offset once
+// it was handled.
+type Kafka struct {
+	producer *kgo.Client
+	config   *config.KafkaConfig
+	logger   *zap.Logger
+	breaker  *resilience.Breaker
+}
+
+// NewKafka creates a new Kafka broker, connecting its producer
+func NewKafka(cfg *config.KafkaConfig, logger *zap.Logger) (*Kafka, error) {
+	producer, err := kgo.NewClient(kafkaOptions(cfg)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka client: %w", err)
+	}
+
+	return &Kafka{
+		producer: producer,
+		config:   cfg,
+		logger:   logger,
+		breaker:  resilience.New("kafka", cfg.Resilience, nil),
+	}, nil
+}
+
+// kafkaOptions are the options every client of the cluster is created with
+func kafkaOptions(cfg *config.KafkaConfig) []kgo.Opt {
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(cfg.Brokers...),
+		kgo.ClientID(cfg.ClientID),
+	}
+	if cfg.AutoCreateTopics {
+		opts = append(opts, kgo.AllowAutoTopicCreation())
+	}
+	return opts
+}
+
+// startOffset is where a consumer group without committed offsets starts
+func startOffset(cfg *config.KafkaConfig) kgo.Offset {
+	if cfg.StartOffset == "latest" {
+		return kgo.NewOffset().AtEnd()
+	}
+	return kgo.NewOffset().AtStart()
+}
+
+// Publish publishes a message to the queue's topic, keyed by its ID
+func (k *Kafka) Publish(ctx context.Context, queueName string, message Message) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	record := &kgo.Record{
+		Topic:     k.config.Topic(queueName),
+		Key:       []byte(message.ID),
+		Value:     body,
+		Timestamp: time.Now(),
+	}
+	err = k.breaker.Do(func() error {
+		return k.producer.ProduceSync(ctx, record).FirstErr()
+	})
+	if err != nil {
+		k.logger.Error("Failed to publish message",
+			zap.String("topic", record.Topic),
+			zap.String("message_id", message.ID),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+
+	k.logger.Debug("Message published successfully",
+		zap.String("topic", record.Topic),
+		zap.String("message_id", message.ID),
+		zap.String("type", message.Type),
+	)
+	return nil
+}
+
+// Consume consumes the queue's topic in the configured consumer group. The
+// partitions assigned are handled side by side, each in order; after every
+// poll the offsets of the messages handled are committed. When ctx is done
+// the messages being handled are finished and the rest are left for the
+// next consumer.
+func (k *Kafka) Consume(ctx context.Context, queueName string, handler func(Message) error) error {
+	topic := k.config.Topic(queueName)
+	opts := append(kafkaOptions(k.config),
+		kgo.ConsumerGroup(k.config.ConsumerGroup),
+		kgo.ConsumeTopics(topic),
+		kgo.DisableAutoCommit(),
+		kgo.BlockRebalanceOnPoll(),
+		kgo.ConsumeResetOffset(startOffset(k.config)),
+	)
+	consumer, err := kgo.NewClient(opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create kafka consumer: %w", err)
+	}
+	defer consumer.Close()
+
+	k.logger.Info("Started consuming messages",
+		zap.String("topic", topic),
+		zap.String("consumer_group", k.config.ConsumerGroup),
+	)
+
+	for {
+		fetches := consumer.PollRecords(ctx, k.config.MaxPollRecords)
+		if fetches.IsClientClosed() || ctx.Err() != nil {
+			k.logger.Info("Stopping message consumption", zap.String("topic", topic))
+			return ctx.Err()
+		}
+		for _, fetchErr := range fetches.Errors() {
+			k.logger.Warn("Failed to fetch messages",
+				zap.String("topic", fetchErr.Topic),
+				zap.Int32("partition", fetchErr.Partition),
+				zap.Error(fetchErr.Err),
+			)
+		}
+
+		handled := k.handlePartitions(ctx, queueName, fetches, handler)
+		if len(handled) > 0 {
+			commitCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := consumer.CommitRecords(commitCtx, handled...); err != nil {
+				k.logger.Warn("Failed to commit offsets, messages will be handled again",
+					zap.String("topic", topic), zap.Error(err))
+			}
+			cancel()
+		}
+		consumer.AllowRebalance()
+	}
+}
+
+// handlePartitions handles a poll's records and returns the ones handled:
+// in each partition, the records up to where ctx was done
+func (k *Kafka) handlePartitions(ctx context.Context, queueName string, fetches kgo.Fetches, handler func(Message) error) []*kgo.Record {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		handled []*kgo.Record
+	)
+	fetches.EachPartition(func(partition kgo.FetchTopicPartition) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, record := range partition.Records {
+				if ctx.Err() != nil {
+					return
+				}
+				k.handle(ctx, queueName, record, handler)
+				mu.Lock()
+				handled = append(handled, record)
+				mu.Unlock()
+			}
+		}()
+	})
+	wg.Wait()
+	return handled
+}
+
+// handle runs a record's message until it succeeds or its retries are used
+// up, when it goes to the queue's dead letter topic. A partition is read in
+// order, so a failed message is retried in place rather than requeued.
+func (k *Kafka) handle(ctx context.Context, queueName string, record *kgo.Record, handler func(Message) error) {
+	var message Message
+	if err := json.Unmarshal(record.Value, &message); err != nil {
+		k.logger.Error("Failed to unmarshal message", zap.String("topic", record.Topic), zap.Error(err))
+		k.deadLetter(queueName, record, err)
+		return
+	}
+
+	for {
+		message.Attempts++
+		err := handler(message)
+		if err == nil {
+			k.logger.Debug("Message processed successfully", zap.String("message_id", message.ID))
+			return
+		}
+
+		k.logger.Error("Failed to process message",
+			zap.String("message_id", message.ID),
+			zap.Error(err),
+		)
+		if message.Attempts >= message.MaxRetries {
+			k.logger.Error("Message exceeded max retries, sending to dead letter topic",
+				zap.String("message_id", message.ID),
+			)
+			k.deadLetter(queueName, record, err)
+			return
+		}
+
+		k.logger.Info("Retrying message",
+			zap.String("message_id", message.ID),
+			zap.Int("attempt", message.Attempts),
+			zap.Int("max_retries", message.MaxRetries),
+		)
+		select {
+		case <-ctx.Done():
+			// Finish on shutdown rather than wait: the message gets its
+			// last attempt now
+			message.MaxRetries = message.Attempts + 1
+		case <-time.After(time.Duration(message.Attempts) * time.Second):
+		}
+	}
+}
+
+// deadLetter copies a record the workers gave up on to the queue's dead
+// letter topic, with the error in its headers
+func (k *Kafka) deadLetter(queueName string, record *kgo.Record, cause error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	dead := &kgo.Record{
+		Topic: k.config.DeadLetterTopic(queueName),
+		Key:   record.Key,
+		Value: record.Value,
+		Headers: append(record.Headers,
+			kgo.RecordHeader{Key: "error", Value: []byte(cause.Error())},
+			kgo.RecordHeader{Key: "partition", Value: []byte(fmt.Sprint(record.Partition))},
+			kgo.RecordHeader{Key: "offset", Value: []byte(fmt.Sprint(record.Offset))},
+		),
+	}
+	if err := k.producer.ProduceSync(ctx, dead).FirstErr(); err != nil {
+		k.logger.Error("Failed to send message to dead letter topic, dropping it",
+			zap.String("topic", dead.Topic), zap.Error(err))
+	}
+}
+
+// HealthCheck checks a broker can be reached
+func (k *Kafka) HealthCheck() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := k.producer.Ping(ctx); err != nil {
+		return fmt.Errorf("kafka is unreachable: %w", err)
+	}
+	return nil
+}
+
+// Close flushes and closes the producer
+func (k *Kafka) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := k.producer.Flush(ctx)
+	k.producer.Close()
+	return err
+}
@@ -0,0 +1,288 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.uber.org/zap"
+
+	"online-shop/pkg/config"
+	"online-shop/pkg/resilience"
+)
+
+// NATS carries queue messages over a NATS JetStream stream, for
+// deployments too small to run RabbitMQ or Kafka alongside the shop. Each
+// queue is a subject of the stream, read by the workers through a durable
+// consumer per queue, so a restarted worker carries on where it left off.
+// The stream keeps messages for the configured retention, so they can be
+// delivered again with nats.replay_from.
+type NATS struct {
+	conn    *nats.Conn
+	js      jetstream.JetStream
+	config  *config.NATSConfig
+	logger  *zap.Logger
+	breaker *resilience.Breaker
+}
+
+// NewNATS connects to NATS and creates the stream, or updates it to the config
+func NewNATS(cfg *config.NATSConfig, logger *zap.Logger) (*NATS, error) {
+	conn, err := nats.Connect(cfg.URL, nats.Name("online-shop"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to nats: %w", err)
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open jetstream: %w", err)
+	}
+
+	n := &NATS{
+		conn:    conn,
+		js:      js,
+		config:  cfg,
+		logger:  logger,
+		breaker: resilience.New("nats", cfg.Resilience, nil),
+	}
+	if err := n.ensureStream(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return n, nil
+}
+
+// ensureStream creates the stream holding every queue's subject
+func (n *NATS) ensureStream() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	streamConfig := jetstream.StreamConfig{
+		Name:     n.config.Stream,
+		Subjects: []string{n.config.SubjectPrefix + ".>"},
+		MaxAge:   n.config.Retention(),
+		Storage:  jetstream.FileStorage,
+		// Publishes are deduplicated by message ID in this window, so a
+		// publish retried after a lost ack is stored once
+		Duplicates: 2 * time.Minute,
+	}
+	_, err := n.js.CreateStream(ctx, streamConfig)
+	if errors.Is(err, jetstream.ErrStreamNameAlreadyInUse) {
+		_, err = n.js.UpdateStream(ctx, streamConfig)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create stream %s: %w", n.config.Stream, err)
+	}
+	return nil
+}
+
+// Publish publishes a message to the queue's subject
+func (n *NATS) Publish(ctx context.Context, queueName string, message Message) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	subject := n.config.Subject(queueName)
+	err = n.breaker.Do(func() error {
+		_, err := n.js.Publish(ctx, subject, body, jetstream.WithMsgID(message.ID))
+		return err
+	})
+	if err != nil {
+		n.logger.Error("Failed to publish message",
+			zap.String("subject", subject),
+			zap.String("message_id", message.ID),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+
+	n.logger.Debug("Message published successfully",
+		zap.String("subject", subject),
+		zap.String("message_id", message.ID),
+		zap.String("type", message.Type),
+	)
+	return nil
+}
+
+// Consume consumes the queue's subject through its durable consumer until
+// ctx is done. A message is acked once handled; a failed one is delivered
+// again after a backoff until its MaxRetries are used up.
+func (n *NATS) Consume(ctx context.Context, queueName string, handler func(Message) error) error {
+	consumer, err := n.consumer(ctx, queueName)
+	if err != nil {
+		return err
+	}
+
+	messages, err := consumer.Messages()
+	if err != nil {
+		return fmt.Errorf("failed to consume %s: %w", queueName, err)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			messages.Stop()
+		case <-done:
+			messages.Stop()
+		}
+	}()
+
+	n.logger.Info("Started consuming messages",
+		zap.String("subject", n.config.Subject(queueName)),
+		zap.String("durable", consumer.CachedInfo().Name),
+	)
+
+	for {
+		msg, err := messages.Next()
+		if errors.Is(err, jetstream.ErrMsgIteratorClosed) {
+			n.logger.Info("Stopping message consumption", zap.String("queue", queueName))
+			return ctx.Err()
+		}
+		if err != nil {
+			n.logger.Warn("Failed to receive message", zap.String("queue", queueName), zap.Error(err))
+			continue
+		}
+		n.handle(queueName, msg, handler)
+	}
+}
+
+// consumer returns the queue's durable consumer, created on first use. When
+// nats.replay_from is set and the consumer does not start from it yet, it
+// is recreated to deliver the messages kept since then again.
+func (n *NATS) consumer(ctx context.Context, queueName string) (jetstream.Consumer, error) {
+	consumerConfig := jetstream.ConsumerConfig{
+		Durable:       n.config.Durable + "-" + queueName,
+		FilterSubject: n.config.Subject(queueName),
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       n.config.AckWait(),
+		DeliverPolicy: jetstream.DeliverAllPolicy,
+	}
+
+	var replayFrom *time.Time
+	if n.config.ReplayFrom != "" {
+		// Validated with the rest of the config
+		from, _ := time.Parse(time.RFC3339, n.config.ReplayFrom)
+		replayFrom = &from
+	}
+
+	existing, err := n.js.Consumer(ctx, n.config.Stream, consumerConfig.Durable)
+	switch {
+	case errors.Is(err, jetstream.ErrConsumerNotFound):
+	case err != nil:
+		return nil, fmt.Errorf("failed to look up consumer %s: %w", consumerConfig.Durable, err)
+	case replayFrom != nil && !startsFrom(existing.CachedInfo().Config, *replayFrom):
+		n.logger.Info("Replaying messages",
+			zap.String("queue", queueName),
+			zap.Time("from", *replayFrom),
+		)
+		if err := n.js.DeleteConsumer(ctx, n.config.Stream, consumerConfig.Durable); err != nil {
+			return nil, fmt.Errorf("failed to reset consumer %s: %w", consumerConfig.Durable, err)
+		}
+	default:
+		// Where a consumer starts cannot change, so keep its own
+		consumerConfig.DeliverPolicy = existing.CachedInfo().Config.DeliverPolicy
+		consumerConfig.OptStartTime = existing.CachedInfo().Config.OptStartTime
+		replayFrom = nil
+	}
+	if replayFrom != nil {
+		consumerConfig.DeliverPolicy = jetstream.DeliverByStartTimePolicy
+		consumerConfig.OptStartTime = replayFrom
+	}
+
+	consumer, err := n.js.CreateOrUpdateConsumer(ctx, n.config.Stream, consumerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer %s: %w", consumerConfig.Durable, err)
+	}
+	return consumer, nil
+}
+
+// startsFrom reports whether a consumer delivers from the time
+func startsFrom(cfg jetstream.ConsumerConfig, from time.Time) bool {
+	return cfg.DeliverPolicy == jetstream.DeliverByStartTimePolicy &&
+		cfg.OptStartTime != nil && cfg.OptStartTime.Equal(from)
+}
+
+// handle runs a delivered message and acks it, or has it delivered again
+func (n *NATS) handle(queueName string, msg jetstream.Msg, handler func(Message) error) {
+	var message Message
+	if err := json.Unmarshal(msg.Data(), &message); err != nil {
+		n.logger.Error("Failed to unmarshal message", zap.String("subject", msg.Subject()), zap.Error(err))
+		n.deadLetter(queueName, msg, err)
+		return
+	}
+
+	if metadata, err := msg.Metadata(); err == nil {
+		message.Attempts = int(metadata.NumDelivered)
+	}
+
+	err := handler(message)
+	if err == nil {
+		if err := msg.Ack(); err != nil {
+			n.logger.Warn("Failed to ack message", zap.String("message_id", message.ID), zap.Error(err))
+		}
+		n.logger.Debug("Message processed successfully", zap.String("message_id", message.ID))
+		return
+	}
+
+	n.logger.Error("Failed to process message",
+		zap.String("message_id", message.ID),
+		zap.Error(err),
+	)
+	if message.Attempts >= message.MaxRetries {
+		n.logger.Error("Message exceeded max retries, sending to dead letter subject",
+			zap.String("message_id", message.ID),
+		)
+		n.deadLetter(queueName, msg, err)
+		return
+	}
+
+	n.logger.Info("Retrying message",
+		zap.String("message_id", message.ID),
+		zap.Int("attempt", message.Attempts),
+		zap.Int("max_retries", message.MaxRetries),
+	)
+	msg.NakWithDelay(time.Duration(message.Attempts) * time.Second)
+}
+
+// deadLetter moves a message the workers gave up on to the queue's dead
+// letter subject, with the error in its headers. It is acked only once
+// stored there, so it is not lost when that fails.
+func (n *NATS) deadLetter(queueName string, msg jetstream.Msg, cause error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	dead := nats.NewMsg(n.config.DeadLetterSubject(queueName))
+	dead.Data = msg.Data()
+	dead.Header.Set("error", cause.Error())
+	if metadata, err := msg.Metadata(); err == nil {
+		dead.Header.Set("stream-sequence", fmt.Sprint(metadata.Sequence.Stream))
+	}
+	if _, err := n.js.PublishMsg(ctx, dead); err != nil {
+		n.logger.Error("Failed to send message to dead letter subject",
+			zap.String("subject", dead.Subject), zap.Error(err))
+		msg.NakWithDelay(time.Minute)
+		return
+	}
+	msg.Ack()
+}
+
+// HealthCheck checks JetStream can be reached
+func (n *NATS) HealthCheck() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := n.js.AccountInfo(ctx); err != nil {
+		return fmt.Errorf("nats is unreachable: %w", err)
+	}
+	return nil
+}
+
+// Close drains the connection, finishing pending publishes
+func (n *NATS) Close() error {
+	return n.conn.Drain()
+}
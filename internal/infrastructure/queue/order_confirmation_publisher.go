@@ -0,0 +1,57 @@
+package queue
+
+import (
+	"context"
+
+	"online-shop/internal/domain/notification"
+	"online-shop/internal/domain/order"
+)
+
+// OrderConfirmationPublisher queues the order_confirmation email
+type OrderConfirmationPublisher struct {
+	rabbitmq *RabbitMQ
+}
+
+// NewOrderConfirmationPublisher creates a new order confirmation publisher
+func NewOrderConfirmationPublisher(rabbitmq *RabbitMQ) order.ConfirmationNotifier {
+	return &OrderConfirmationPublisher{rabbitmq: rabbitmq}
+}
+
+// OrderConfirmed emails the customer their order with its estimated delivery
+func (p *OrderConfirmationPublisher) OrderConfirmed(ctx context.Context, c order.Confirmation) error {
+	o := c.Order
+	number := o.Number
+	if number == "" {
+		number = o.ID
+	}
+
+	items := make([]map[string]interface{}, 0, len(o.Items))
+	for _, item := range o.Items {
+		items = append(items, map[string]interface{}{
+			"ProductName": c.ProductNames[item.ProductID],
+			"Quantity":    item.Quantity,
+			"TotalPrice":  item.Subtotal,
+		})
+	}
+	data := map[string]interface{}{
+		"CustomerName":   c.CustomerName,
+		"OrderNumber":    number,
+		"Items":          items,
+		"TotalAmount":    o.TotalAmount,
+		"ShippingRate":   o.Shipping.Rate,
+		"ShippingAmount": o.Shipping.Fee,
+	}
+	if !o.Delivery.IsZero() {
+		data["DeliveryFrom"] = o.Delivery.Earliest
+		data["DeliveryTo"] = o.Delivery.Latest
+	}
+
+	return p.rabbitmq.PublishEmail(ctx, EmailMessage{
+		To:       c.Email,
+		Template: "order_confirmation",
+		Data:     data,
+		Priority: 2,
+		Locale:   c.Locale,
+		Category: string(notification.CategoryOrders),
+	})
+}
@@ -0,0 +1,46 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+
+	"online-shop/internal/domain/notification"
+	"online-shop/internal/domain/pricealert"
+)
+
+// PriceAlertPublisher sends price drop alerts to the notification worker
+type PriceAlertPublisher struct {
+	rabbitmq *RabbitMQ
+}
+
+// NewPriceAlertPublisher creates a new price alert publisher
+func NewPriceAlertPublisher(rabbitmq *RabbitMQ) pricealert.Notifier {
+	return &PriceAlertPublisher{rabbitmq: rabbitmq}
+}
+
+// PriceDropped notifies the watcher over the channel they picked
+func (p *PriceAlertPublisher) PriceDropped(ctx context.Context, alert pricealert.Alert) error {
+	w := alert.Watch
+	notification := map[string]interface{}{
+		"user_id":  w.UserID,
+		"type":     "price_drop",
+		"title":    fmt.Sprintf("%s dropped in price", alert.ProductName),
+		"message":  fmt.Sprintf("%s now costs %.0f, down from %.0f when you started watching it.", alert.ProductName, alert.Price, w.WatchedPrice),
+		"priority": 2,
+		"category": notification.CategoryAlerts,
+		"channels": []string{string(w.Channel)},
+		"phone":    alert.Phone,
+		"locale":   alert.Locale,
+		"data": map[string]interface{}{
+			"watch_id":      w.ID,
+			"product_id":    w.ProductID,
+			"product_name":  alert.ProductName,
+			"product_url":   alert.ProductURL,
+			"price":         alert.Price,
+			"watched_price": w.WatchedPrice,
+			"target_price":  w.TargetPrice,
+		},
+	}
+
+	return p.rabbitmq.PublishNotification(ctx, notification)
+}
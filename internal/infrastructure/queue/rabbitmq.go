@@ -10,6 +10,7 @@ import (
 	"go.uber.org/zap"
 
 	"online-shop/pkg/config"
+	"online-shop/pkg/resilience"
 )
 
 // RabbitMQ represents a RabbitMQ connection
@@ -18,6 +19,10 @@ type RabbitMQ struct {
 	channel *amqp.Channel
 	config  *config.Config
 	logger  *zap.Logger
+	// breaker guards publishes; consumers reconnect on their own
+	breaker *resilience.Breaker
+	// consumers is the state of each queue being consumed
+	consumers *consumerStates
 }
 
 // Message represents a queue message
@@ -28,6 +33,9 @@ type Message struct {
 	Timestamp time.Time              `json:"timestamp"`
 	Attempts  int                    `json:"attempts"`
 	MaxRetries int                   `json:"max_retries"`
+	// Priority orders messages on a queue declared with a max priority;
+	// higher is taken first
+	Priority int `json:"priority,omitempty"`
 }
 
 // EmailMessage represents an email message
@@ -36,7 +44,39 @@ type EmailMessage struct {
 	Subject  string            `json:"subject"`
 	Template string            `json:"template"`
 	Data     map[string]interface{} `json:"data"`
+	// Priority raises the email above other transactional emails
 	Priority int               `json:"priority"`
+	// Locale picks the translation of the template and of the default
+	// subject; empty means the default locale
+	Locale   string            `json:"locale,omitempty"`
+	// Version pins a stored template version instead of the active one,
+	// for test sends
+	Version  int               `json:"version,omitempty"`
+	// CampaignID is kept on dead letters of campaign emails
+	CampaignID string          `json:"campaign_id,omitempty"`
+	// Tracked adds the campaign's open and click tracking
+	Tracked    bool            `json:"tracked,omitempty"`
+	// Category is the notification category the email belongs to, checked
+	// against the recipient's preferences; empty is always sent
+	Category   string          `json:"category,omitempty"`
+}
+
+// EmailBatchMessage carries a batch of a campaign's recipients. Each one is
+// sent the template with their data merged over the shared data.
+type EmailBatchMessage struct {
+	CampaignID string                 `json:"campaign_id"`
+	Template   string                 `json:"template"`
+	Subject    string                 `json:"subject"`
+	Locale     string                 `json:"locale,omitempty"`
+	Data       map[string]interface{} `json:"data"`
+	Recipients []EmailRecipient       `json:"recipients"`
+	Tracked    bool                   `json:"tracked,omitempty"`
+	Category   string                 `json:"category,omitempty"`
+}
+
+type EmailRecipient struct {
+	To   string                 `json:"to"`
+	Data map[string]interface{} `json:"data,omitempty"`
 }
 
 // InvoiceMessage represents an invoice message
@@ -46,6 +86,17 @@ type InvoiceMessage struct {
 	OrderNumber string  `json:"order_number"`
 	TotalAmount float64 `json:"total_amount"`
 	Items       []InvoiceItem `json:"items"`
+	// Payments lists each payment made against the order when it is paid
+	// in parts
+	Payments    []InvoicePayment `json:"payments,omitempty"`
+	AmountPaid  float64 `json:"amount_paid"`
+	Locale      string  `json:"locale,omitempty"`
+	// Note is the customer's note on the order, and the gift fields how
+	// it is sent as a present; GiftWrapFee is included in TotalAmount
+	Note        string  `json:"note,omitempty"`
+	GiftWrap    bool    `json:"gift_wrap,omitempty"`
+	GiftWrapFee float64 `json:"gift_wrap_fee,omitempty"`
+	GiftMessage string  `json:"gift_message,omitempty"`
 }
 
 // InvoiceItem represents an invoice item
@@ -56,12 +107,24 @@ type InvoiceItem struct {
 	TotalPrice  float64 `json:"total_price"`
 }
 
+// InvoicePayment is one payment towards an invoiced order
+type InvoicePayment struct {
+	Method      string     `json:"method"`
+	Amount      float64    `json:"amount"`
+	Status      string     `json:"status"`
+	Installment int        `json:"installment,omitempty"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+	PaidAt      *time.Time `json:"paid_at,omitempty"`
+}
+
 // Queue names
 const (
 	EmailQueue   = "email_queue"
 	InvoiceQueue = "invoice_queue"
 	NotificationQueue = "notification_queue"
 	AnalyticsQueue = "analytics_queue"
+	ExportQueue = "export_queue"
+	AccountEventsQueue = "account_events_queue"
 )
 
 // NewRabbitMQ creates a new RabbitMQ connection
@@ -88,10 +151,12 @@ func NewRabbitMQ(cfg *config.Config, logger *zap.Logger) (*RabbitMQ, error) {
 	}
 
 	rabbitmq := &RabbitMQ{
-		conn:    conn,
-		channel: channel,
-		config:  cfg,
-		logger:  logger,
+		conn:      conn,
+		channel:   channel,
+		config:    cfg,
+		logger:    logger,
+		breaker:   resilience.New("rabbitmq", cfg.RabbitMQ.Resilience, nil),
+		consumers: newConsumerStates(),
 	}
 
 	// Setup queues
@@ -111,19 +176,26 @@ func (r *RabbitMQ) setupQueues() error {
 		InvoiceQueue,
 		NotificationQueue,
 		AnalyticsQueue,
+		ExportQueue,
+		AccountEventsQueue,
 	}
 
 	for _, queueName := range queues {
+		args := amqp.Table{
+			"x-message-ttl": 3600000, // 1 hour TTL
+			"x-max-retries": 3,
+		}
+		if maxPriority := r.consumerConfig(queueName).MaxPriority; maxPriority > 0 {
+			args["x-max-priority"] = maxPriority
+		}
+
 		_, err := r.channel.QueueDeclare(
 			queueName, // name
 			true,      // durable
 			false,     // delete when unused
 			false,     // exclusive
 			false,     // no-wait
-			amqp.Table{
-				"x-message-ttl": 3600000, // 1 hour TTL
-				"x-max-retries": 3,
-			},
+			args,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to declare queue %s: %w", queueName, err)
@@ -156,6 +228,23 @@ func (r *RabbitMQ) PublishEmail(ctx context.Context, email EmailMessage) error {
 		Timestamp: time.Now(),
 		Attempts:  0,
 		MaxRetries: 3,
+		Priority:  PriorityTransactional + email.Priority,
+	}
+
+	return r.publishMessage(ctx, EmailQueue, message)
+}
+
+// PublishEmailBatch publishes a campaign batch to the email queue, behind
+// transactional emails
+func (r *RabbitMQ) PublishEmailBatch(ctx context.Context, batch EmailBatchMessage) error {
+	message := Message{
+		ID:        generateMessageID(),
+		Type:      "email_batch",
+		Payload:   structToMap(batch),
+		Timestamp: time.Now(),
+		Attempts:  0,
+		MaxRetries: 3,
+		Priority:  PriorityBulk,
 	}
 
 	return r.publishMessage(ctx, EmailQueue, message)
@@ -185,13 +274,21 @@ func (r *RabbitMQ) PublishNotification(ctx context.Context, notification map[str
 		Attempts:  0,
 		MaxRetries: 3,
 	}
+	if priority, ok := notification["priority"].(int); ok {
+		message.Priority = priority
+	}
 
 	return r.publishMessage(ctx, NotificationQueue, message)
 }
 
 // PublishAnalytics publishes an analytics event to the queue
 func (r *RabbitMQ) PublishAnalytics(ctx context.Context, event map[string]interface{}) error {
-	message := Message{
+	return r.publishMessage(ctx, AnalyticsQueue, analyticsMessage(event))
+}
+
+// analyticsMessage wraps an analytics event for the analytics queue
+func analyticsMessage(event map[string]interface{}) Message {
+	return Message{
 		ID:        generateMessageID(),
 		Type:      "analytics",
 		Payload:   event,
@@ -199,8 +296,34 @@ func (r *RabbitMQ) PublishAnalytics(ctx context.Context, event map[string]interf
 		Attempts:  0,
 		MaxRetries: 1, // Analytics events don't need retries
 	}
+}
 
-	return r.publishMessage(ctx, AnalyticsQueue, message)
+// PublishExport publishes a report export request to the queue
+func (r *RabbitMQ) PublishExport(ctx context.Context, request map[string]interface{}) error {
+	message := Message{
+		ID:        generateMessageID(),
+		Type:      "export",
+		Payload:   request,
+		Timestamp: time.Now(),
+		Attempts:  0,
+		MaxRetries: 3,
+	}
+
+	return r.publishMessage(ctx, ExportQueue, message)
+}
+
+// PublishAccountEvent publishes an account lifecycle event to the queue
+func (r *RabbitMQ) PublishAccountEvent(ctx context.Context, event map[string]interface{}) error {
+	message := Message{
+		ID:        generateMessageID(),
+		Type:      "account",
+		Payload:   event,
+		Timestamp: time.Now(),
+		Attempts:  0,
+		MaxRetries: 3,
+	}
+
+	return r.publishMessage(ctx, AccountEventsQueue, message)
 }
 
 // publishMessage publishes a message to the specified queue
@@ -210,19 +333,22 @@ func (r *RabbitMQ) publishMessage(ctx context.Context, queueName string, message
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
 
-	err = r.channel.Publish(
-		"",        // exchange
-		queueName, // routing key
-		false,     // mandatory
-		false,     // immediate
-		amqp.Publishing{
-			ContentType:  "application/json",
-			Body:         body,
-			DeliveryMode: amqp.Persistent, // Make message persistent
-			Timestamp:    time.Now(),
-			MessageId:    message.ID,
-		},
-	)
+	err = r.breaker.Do(func() error {
+		return r.channel.Publish(
+			"",        // exchange
+			queueName, // routing key
+			false,     // mandatory
+			false,     // immediate
+			amqp.Publishing{
+				ContentType:  "application/json",
+				Body:         body,
+				DeliveryMode: amqp.Persistent, // Make message persistent
+				Timestamp:    time.Now(),
+				MessageId:    message.ID,
+				Priority:     r.priority(queueName, message.Priority),
+			},
+		)
+	})
 
 	if err != nil {
 		r.logger.Error("Failed to publish message",
@@ -242,9 +368,25 @@ func (r *RabbitMQ) publishMessage(ctx context.Context, queueName string, message
 	return nil
 }
 
-// ConsumeMessages consumes messages from a queue
+// ConsumeMessages consumes messages from a queue on a channel of its own,
+// with the prefetch and consumers configured for the queue. Every
+// workers.scale_interval_seconds the consumers are scaled to the messages
+// waiting. When ctx is done the messages being handled are finished and
+// the ones prefetched go back to the queue.
 func (r *RabbitMQ) ConsumeMessages(ctx context.Context, queueName string, handler func(Message) error) error {
-	msgs, err := r.channel.Consume(
+	cfg := r.consumerConfig(queueName)
+
+	channel, err := r.conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to create channel: %w", err)
+	}
+	defer channel.Close()
+
+	if err := channel.Qos(cfg.Prefetch, 0, false); err != nil {
+		return fmt.Errorf("failed to set prefetch: %w", err)
+	}
+
+	msgs, err := channel.Consume(
 		queueName, // queue
 		"",        // consumer
 		false,     // auto-ack
@@ -257,20 +399,49 @@ func (r *RabbitMQ) ConsumeMessages(ctx context.Context, queueName string, handle
 		return fmt.Errorf("failed to register consumer: %w", err)
 	}
 
-	r.logger.Info("Started consuming messages", zap.String("queue", queueName))
+	consumers := newConsumerGroup(msgs, func(delivery amqp.Delivery) {
+		r.processMessage(delivery, handler)
+	})
+	consumers.scale(cfg.MinConsumers)
+	defer consumers.stop()
+	r.consumers.started(queueName, cfg)
+	defer r.consumers.stopped(queueName)
+
+	r.logger.Info("Started consuming messages",
+		zap.String("queue", queueName),
+		zap.Int("prefetch", cfg.Prefetch),
+		zap.Int("min_consumers", cfg.MinConsumers),
+		zap.Int("max_consumers", cfg.MaxConsumers),
+	)
+
+	ticker := time.NewTicker(r.config.Workers.ScaleInterval())
+	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			r.logger.Info("Stopping message consumption", zap.String("queue", queueName))
 			return ctx.Err()
-		case msg, ok := <-msgs:
-			if !ok {
-				r.logger.Warn("Message channel closed", zap.String("queue", queueName))
-				return fmt.Errorf("message channel closed")
+		case <-consumers.closed:
+			r.logger.Warn("Message channel closed", zap.String("queue", queueName))
+			return fmt.Errorf("message channel closed")
+		case <-ticker.C:
+			state, err := channel.QueueInspect(queueName)
+			if err != nil {
+				r.logger.Warn("Failed to inspect queue backlog", zap.String("queue", queueName), zap.Error(err))
+				continue
+			}
+			want := ConsumerCount(state.Messages, cfg)
+			r.consumers.inspected(queueName, state.Messages, want, time.Now())
+			if want != consumers.size() {
+				r.logger.Info("Scaling queue consumers",
+					zap.String("queue", queueName),
+					zap.Int("backlog", state.Messages),
+					zap.Int("from", consumers.size()),
+					zap.Int("to", want),
+				)
+				consumers.scale(want)
 			}
-
-			r.processMessage(msg, handler)
 		}
 	}
 }
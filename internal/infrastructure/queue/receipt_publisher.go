@@ -0,0 +1,52 @@
+package queue
+
+import (
+	"context"
+
+	"online-shop/internal/domain/notification"
+	"online-shop/internal/domain/payment"
+)
+
+// ReceiptPublisher queues the receipt email
+type ReceiptPublisher struct {
+	rabbitmq *RabbitMQ
+}
+
+// NewReceiptPublisher creates a new receipt publisher
+func NewReceiptPublisher(rabbitmq *RabbitMQ) payment.ReceiptNotifier {
+	return &ReceiptPublisher{rabbitmq: rabbitmq}
+}
+
+// PaymentReceived emails the customer the receipt of their payment
+func (p *ReceiptPublisher) PaymentReceived(ctx context.Context, n payment.ReceiptNotice) error {
+	r := n.Receipt
+	number := r.OrderNumber
+	if number == "" {
+		number = r.OrderID
+	}
+
+	data := map[string]interface{}{
+		"CustomerName":  n.CustomerName,
+		"ReceiptNumber": r.Number,
+		"OrderNumber":   number,
+		"Date":          r.PaidAt.Format("January 2, 2006"),
+		"Amount":        r.Amount,
+		"Method":        string(r.Method),
+		"Outstanding":   n.Outstanding,
+	}
+	if r.Installment > 0 {
+		data["Installment"] = r.Installment
+	}
+	if r.TransactionID != "" {
+		data["TransactionID"] = r.TransactionID
+	}
+
+	return p.rabbitmq.PublishEmail(ctx, EmailMessage{
+		To:       n.Email,
+		Template: "receipt",
+		Data:     data,
+		Priority: 2,
+		Locale:   n.Locale,
+		Category: string(notification.CategoryOrders),
+	})
+}
@@ -0,0 +1,44 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+
+	"online-shop/internal/domain/notification"
+	"online-shop/internal/domain/stockalert"
+)
+
+// StockAlertPublisher sends back in stock alerts to the notification worker
+type StockAlertPublisher struct {
+	rabbitmq *RabbitMQ
+}
+
+// NewStockAlertPublisher creates a new stock alert publisher
+func NewStockAlertPublisher(rabbitmq *RabbitMQ) stockalert.Notifier {
+	return &StockAlertPublisher{rabbitmq: rabbitmq}
+}
+
+// BackInStock notifies the subscriber over the channel they subscribed with
+func (p *StockAlertPublisher) BackInStock(ctx context.Context, alert stockalert.Alert) error {
+	s := alert.Subscription
+	notification := map[string]interface{}{
+		"user_id":  s.UserID,
+		"type":     "back_in_stock",
+		"title":    fmt.Sprintf("%s is back in stock", alert.ProductName),
+		"message":  fmt.Sprintf("%s is available again. Stock can run out quickly, so order soon if you still want it.", alert.ProductName),
+		"priority": 2,
+		"category": notification.CategoryAlerts,
+		"channels": []string{string(s.Channel)},
+		"phone":    alert.Phone,
+		"locale":   alert.Locale,
+		"data": map[string]interface{}{
+			"subscription_id": s.ID,
+			"product_id":      s.ProductID,
+			"product_name":    alert.ProductName,
+			"product_url":     alert.ProductURL,
+			"price":           alert.Price,
+		},
+	}
+
+	return p.rabbitmq.PublishNotification(ctx, notification)
+}
@@ -0,0 +1,47 @@
+package redis
+
+import (
+	"context"
+	"errors"
+
+	"online-shop/pkg/resilience"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// breakerHook sends every command and pipeline through a breaker, so a
+// Redis that is down or overloaded fails calls at once instead of letting
+// each one wait out its timeout.
+type breakerHook struct {
+	breaker *resilience.Breaker
+}
+
+func (h breakerHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h breakerHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		return h.breaker.Do(func() error {
+			return next(ctx, cmd)
+		})
+	}
+}
+
+func (h breakerHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		return h.breaker.Do(func() error {
+			return next(ctx, cmds)
+		})
+	}
+}
+
+// redisFailure leaves out replies from Redis itself, such as a missing key
+// or a wrong type, since Redis answered them
+func redisFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var reply redis.Error
+	return !errors.As(err, &reply)
+}
@@ -0,0 +1,48 @@
+package redis
+
+import (
+	"context"
+
+	"online-shop/internal/domain/cache"
+)
+
+const invalidateBatchSize = 500
+
+type CacheInvalidator struct {
+	client *Client
+}
+
+func NewCacheInvalidator(client *Client) cache.Invalidator {
+	return &CacheInvalidator{client: client}
+}
+
+// DeletePattern walks the keyspace with SCAN so Redis is never blocked the way
+// KEYS would block it, and unlinks matches in batches.
+func (i *CacheInvalidator) DeletePattern(ctx context.Context, pattern string) (int64, error) {
+	var deleted int64
+	batch := make([]string, 0, invalidateBatchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n, err := i.client.rdb.Unlink(ctx, batch...).Result()
+		deleted += n
+		batch = batch[:0]
+		return err
+	}
+
+	iter := i.client.rdb.Scan(ctx, 0, pattern, invalidateBatchSize).Iterator()
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == invalidateBatchSize {
+			if err := flush(); err != nil {
+				return deleted, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return deleted, err
+	}
+	return deleted, flush()
+}
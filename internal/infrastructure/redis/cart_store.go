@@ -0,0 +1,62 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"online-shop/internal/domain/cart"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// CartStore keeps each cart under cart:user:<id> or cart:session:<token>.
+// Carts expire when left alone for their TTL, anonymous ones sooner.
+type CartStore struct {
+	client     *Client
+	sessionTTL time.Duration
+	userTTL    time.Duration
+}
+
+func NewCartStore(client *Client, sessionTTL, userTTL time.Duration) cart.Repository {
+	return &CartStore{client: client, sessionTTL: sessionTTL, userTTL: userTTL}
+}
+
+func (s *CartStore) Get(ctx context.Context, owner cart.Owner) (*cart.Cart, error) {
+	data, err := s.client.rdb.Get(ctx, cartKey(owner)).Bytes()
+	if err == redis.Nil {
+		return &cart.Cart{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var c cart.Cart
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Save drops empty carts rather than keeping them until they expire.
+func (s *CartStore) Save(ctx context.Context, owner cart.Owner, c *cart.Cart) error {
+	if len(c.Items) == 0 {
+		return s.Delete(ctx, owner)
+	}
+	ttl := s.userTTL
+	if owner.Anonymous() {
+		ttl = s.sessionTTL
+	}
+	return s.client.Set(ctx, cartKey(owner), c, ttl)
+}
+
+func (s *CartStore) Delete(ctx context.Context, owner cart.Owner) error {
+	return s.client.Delete(ctx, cartKey(owner))
+}
+
+func cartKey(owner cart.Owner) string {
+	if owner.Anonymous() {
+		return "cart:session:" + owner.Token
+	}
+	return "cart:user:" + owner.UserID
+}
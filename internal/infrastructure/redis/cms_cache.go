@@ -0,0 +1,41 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/domain/cms"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// cmsKeyPrefix is also the pattern of the cms cache scope
+const cmsKeyPrefix = "cms:"
+
+type CMSCache struct {
+	client *Client
+}
+
+func NewCMSCache(client *Client) cms.Cache {
+	return &CMSCache{client: client}
+}
+
+func (c *CMSCache) Get(ctx context.Context, key string, dest interface{}) (bool, error) {
+	err := c.client.Get(ctx, cmsKeyPrefix+key, dest)
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *CMSCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	return c.client.Set(ctx, cmsKeyPrefix+key, value, ttl)
+}
+
+func (c *CMSCache) Clear(ctx context.Context) error {
+	_, err := (&CacheInvalidator{client: c.client}).DeletePattern(ctx, cmsKeyPrefix+"*")
+	return err
+}
@@ -0,0 +1,30 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/domain/dashboard"
+)
+
+const dashboardStatsKey = "admin:dashboard:stats"
+
+type DashboardStatsStore struct {
+	client *Client
+}
+
+func NewDashboardStatsStore(client *Client) dashboard.Cache {
+	return &DashboardStatsStore{client: client}
+}
+
+func (s *DashboardStatsStore) Save(ctx context.Context, stats *dashboard.Stats, ttl time.Duration) error {
+	return s.client.Set(ctx, dashboardStatsKey, stats, ttl)
+}
+
+func (s *DashboardStatsStore) Load(ctx context.Context) (*dashboard.Stats, error) {
+	var stats dashboard.Stats
+	if err := s.client.Get(ctx, dashboardStatsKey, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
@@ -0,0 +1,48 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/domain/emailtemplate"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type EmailTemplateCache struct {
+	client *Client
+}
+
+func NewEmailTemplateCache(client *Client) emailtemplate.Cache {
+	return &EmailTemplateCache{client: client}
+}
+
+// cachedEmailTemplate wraps the template so "nothing stored" can be cached
+// as a nil template
+type cachedEmailTemplate struct {
+	Template *emailtemplate.Template `json:"template"`
+}
+
+func (c *EmailTemplateCache) Get(ctx context.Context, name, locale string) (*emailtemplate.Template, bool, error) {
+	var cached cachedEmailTemplate
+	err := c.client.Get(ctx, emailTemplateKey(name, locale), &cached)
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return cached.Template, true, nil
+}
+
+func (c *EmailTemplateCache) Set(ctx context.Context, name, locale string, t *emailtemplate.Template, ttl time.Duration) error {
+	return c.client.Set(ctx, emailTemplateKey(name, locale), cachedEmailTemplate{Template: t}, ttl)
+}
+
+func (c *EmailTemplateCache) Invalidate(ctx context.Context, name, locale string) error {
+	return c.client.Delete(ctx, emailTemplateKey(name, locale))
+}
+
+func emailTemplateKey(name, locale string) string {
+	return "email_template:" + name + ":" + locale
+}
@@ -0,0 +1,123 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"online-shop/internal/domain/flashsale"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// flashSaleRetention keeps the counters after a sale ends so late
+// cancellations are still given back and admins can see what sold.
+const flashSaleRetention = 7 * 24 * time.Hour
+
+// reserveScript checks the item's allocation and the user's limit and
+// counts the quantity against both in one step, so concurrent checkouts
+// cannot oversell. KEYS are the sold and per-user counters; ARGV are the
+// quantity, allocation, per-user limit (0 for none) and expiry in seconds.
+// It returns 1 when reserved, -1 when sold out and -2 over the limit.
+var reserveScript = redis.NewScript(`
+local qty = tonumber(ARGV[1])
+local allocation = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+local ttl = tonumber(ARGV[4])
+
+local sold = tonumber(redis.call('GET', KEYS[1]) or '0')
+if sold + qty > allocation then
+  return -1
+end
+if limit > 0 then
+  local bought = tonumber(redis.call('GET', KEYS[2]) or '0')
+  if bought + qty > limit then
+    return -2
+  end
+end
+
+redis.call('INCRBY', KEYS[1], qty)
+redis.call('EXPIRE', KEYS[1], ttl)
+if limit > 0 then
+  redis.call('INCRBY', KEYS[2], qty)
+  redis.call('EXPIRE', KEYS[2], ttl)
+end
+return 1
+`)
+
+// releaseScript takes the quantity back off both counters without letting
+// them go below zero.
+var releaseScript = redis.NewScript(`
+for _, key in ipairs(KEYS) do
+  if redis.call('DECRBY', key, ARGV[1]) <= 0 then
+    redis.call('DEL', key)
+  end
+end
+return 1
+`)
+
+type FlashSaleCounter struct {
+	client *Client
+}
+
+func NewFlashSaleCounter(client *Client) flashsale.Counter {
+	return &FlashSaleCounter{client: client}
+}
+
+func (c *FlashSaleCounter) Reserve(ctx context.Context, item *flashsale.Item, userID string, quantity int, until time.Time) error {
+	ttl := time.Until(until) + flashSaleRetention
+	result, err := reserveScript.Run(ctx, c.client.rdb,
+		[]string{flashSaleSoldKey(item), flashSaleUserKey(item, userID)},
+		quantity, item.Quantity, item.PerUserLimit, int64(ttl.Seconds())).Int()
+	if err != nil {
+		return err
+	}
+
+	switch result {
+	case -1:
+		return flashsale.ErrSoldOut
+	case -2:
+		return flashsale.ErrLimitReached
+	}
+	return nil
+}
+
+func (c *FlashSaleCounter) Release(ctx context.Context, item *flashsale.Item, userID string, quantity int) error {
+	// The user's counter is released even when the item has no limit, as
+	// the limit may have been removed since; releasing a missing counter
+	// is a no-op
+	keys := []string{flashSaleSoldKey(item), flashSaleUserKey(item, userID)}
+	return releaseScript.Run(ctx, c.client.rdb, keys, quantity).Err()
+}
+
+func (c *FlashSaleCounter) Sold(ctx context.Context, items []*flashsale.Item) error {
+	if len(items) == 0 {
+		return nil
+	}
+	keys := make([]string, len(items))
+	for i, item := range items {
+		keys[i] = flashSaleSoldKey(item)
+	}
+
+	values, err := c.client.rdb.MGet(ctx, keys...).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+	for i, v := range values {
+		items[i].Sold = 0
+		if s, ok := v.(string); ok {
+			items[i].Sold, _ = strconv.Atoi(s)
+		}
+	}
+	return nil
+}
+
+// The keys of an item share a hash tag so the scripts also run on a cluster.
+func flashSaleSoldKey(item *flashsale.Item) string {
+	return "flashsale:{" + item.SaleID + ":" + item.ProductID + "}:sold"
+}
+
+func flashSaleUserKey(item *flashsale.Item, userID string) string {
+	return "flashsale:{" + item.SaleID + ":" + item.ProductID + "}:user:" + userID
+}
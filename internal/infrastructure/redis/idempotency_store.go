@@ -0,0 +1,44 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/domain/idempotency"
+)
+
+type IdempotencyStore struct {
+	client *Client
+}
+
+func NewIdempotencyStore(client *Client) idempotency.Store {
+	return &IdempotencyStore{client: client}
+}
+
+func (s *IdempotencyStore) Reserve(ctx context.Context, record *idempotency.Record, ttl time.Duration) (*idempotency.Record, error) {
+	ok, err := s.client.SetNX(ctx, idempotencyKey(record.Key), record, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return nil, nil
+	}
+
+	var existing idempotency.Record
+	if err := s.client.Get(ctx, idempotencyKey(record.Key), &existing); err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+func (s *IdempotencyStore) Complete(ctx context.Context, record *idempotency.Record, ttl time.Duration) error {
+	return s.client.Set(ctx, idempotencyKey(record.Key), record, ttl)
+}
+
+func (s *IdempotencyStore) Release(ctx context.Context, key string) error {
+	return s.client.Delete(ctx, idempotencyKey(key))
+}
+
+func idempotencyKey(key string) string {
+	return "idempotency:" + key
+}
@@ -0,0 +1,37 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/domain/shipping"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// locationKeyPrefix is followed by the hash of the address
+const locationKeyPrefix = "geocode:"
+
+type LocationCache struct {
+	client *Client
+}
+
+func NewLocationCache(client *Client) shipping.LocationCache {
+	return &LocationCache{client: client}
+}
+
+func (c *LocationCache) Get(ctx context.Context, key string) (*shipping.Location, error) {
+	var location shipping.Location
+	err := c.client.Get(ctx, locationKeyPrefix+key, &location)
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &location, nil
+}
+
+func (c *LocationCache) Set(ctx context.Context, key string, location *shipping.Location, ttl time.Duration) error {
+	return c.client.Set(ctx, locationKeyPrefix+key, location, ttl)
+}
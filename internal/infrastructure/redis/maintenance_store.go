@@ -0,0 +1,37 @@
+package redis
+
+import (
+	"context"
+
+	"online-shop/internal/domain/maintenance"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const maintenanceKey = "system:maintenance"
+
+type MaintenanceStore struct {
+	client *Client
+}
+
+func NewMaintenanceStore(client *Client) maintenance.Store {
+	return &MaintenanceStore{client: client}
+}
+
+func (s *MaintenanceStore) Get(ctx context.Context) (*maintenance.State, error) {
+	var state maintenance.State
+	err := s.client.Get(ctx, maintenanceKey, &state)
+	if err == redis.Nil {
+		return &maintenance.State{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Save keeps the state until it is replaced; windows that have ended are
+// pruned by the next change.
+func (s *MaintenanceStore) Save(ctx context.Context, state *maintenance.State) error {
+	return s.client.Set(ctx, maintenanceKey, state, 0)
+}
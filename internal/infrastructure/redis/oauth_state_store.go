@@ -0,0 +1,52 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"online-shop/internal/domain/oauth"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type OAuthStateStore struct {
+	client *Client
+}
+
+func NewOAuthStateStore(client *Client) oauth.StateStore {
+	return &OAuthStateStore{client: client}
+}
+
+func (s *OAuthStateStore) Save(ctx context.Context, state *oauth.State, ttl time.Duration) error {
+	return s.client.Set(ctx, oauthStateKey(state.ID), state, ttl)
+}
+
+func (s *OAuthStateStore) Get(ctx context.Context, id string) (*oauth.State, error) {
+	return decodeOAuthState(s.client.rdb.Get(ctx, oauthStateKey(id)).Bytes())
+}
+
+// Take reads and deletes the state in one step, so a callback URL cannot be
+// replayed.
+func (s *OAuthStateStore) Take(ctx context.Context, id string) (*oauth.State, error) {
+	return decodeOAuthState(s.client.rdb.GetDel(ctx, oauthStateKey(id)).Bytes())
+}
+
+func decodeOAuthState(data []byte, err error) (*oauth.State, error) {
+	if err == redis.Nil {
+		return nil, oauth.ErrInvalidState
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state oauth.State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func oauthStateKey(id string) string {
+	return "oauth_state:" + id
+}
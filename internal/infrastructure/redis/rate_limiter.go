@@ -0,0 +1,60 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/domain/ratelimit"
+
+	"online-shop/pkg/id"
+	"github.com/redis/go-redis/v9"
+)
+
+// slidingWindowScript keeps one sorted-set member per accepted request, scored
+// by the Redis server time in milliseconds, so every API instance shares the
+// same window and clock. It returns {allowed, remaining, retry_after_ms}.
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+if count < limit then
+  redis.call('ZADD', key, now, ARGV[3])
+  redis.call('PEXPIRE', key, window)
+  return {1, limit - count - 1, 0}
+end
+
+local retry = window
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+  retry = tonumber(oldest[2]) + window - now
+end
+return {0, 0, retry}
+`)
+
+type RateLimiter struct {
+	client *Client
+}
+
+func NewRateLimiter(client *Client) ratelimit.Limiter {
+	return &RateLimiter{client: client}
+}
+
+func (l *RateLimiter) Allow(ctx context.Context, key string, policy ratelimit.Policy) (*ratelimit.Result, error) {
+	values, err := slidingWindowScript.Run(ctx, l.client.rdb, []string{key},
+		policy.Limit, policy.Window.Milliseconds(), id.New()).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ratelimit.Result{
+		Allowed:    values[0] == 1,
+		Limit:      policy.Limit,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,42 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/domain/product"
+)
+
+const (
+	recentlyViewedLimit = 50
+	recentlyViewedTTL   = 30 * 24 * time.Hour
+)
+
+type RecentlyViewedStore struct {
+	client *Client
+}
+
+func NewRecentlyViewedStore(client *Client) product.RecentlyViewedRepository {
+	return &RecentlyViewedStore{client: client}
+}
+
+// Add moves productID to the front of the viewer's list, dropping any earlier
+// view of the same product and trimming the list to its cap.
+func (s *RecentlyViewedStore) Add(ctx context.Context, viewer, productID string) error {
+	key := recentlyViewedKey(viewer)
+	pipe := s.client.rdb.TxPipeline()
+	pipe.LRem(ctx, key, 0, productID)
+	pipe.LPush(ctx, key, productID)
+	pipe.LTrim(ctx, key, 0, recentlyViewedLimit-1)
+	pipe.Expire(ctx, key, recentlyViewedTTL)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *RecentlyViewedStore) List(ctx context.Context, viewer string, limit int) ([]string, error) {
+	return s.client.rdb.LRange(ctx, recentlyViewedKey(viewer), 0, int64(limit-1)).Result()
+}
+
+func recentlyViewedKey(viewer string) string {
+	return "recently_viewed:" + viewer
+}
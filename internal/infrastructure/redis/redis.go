@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"online-shop/pkg/config"
+	"online-shop/pkg/resilience"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
@@ -27,6 +28,7 @@ func NewRedisClient(redisURL string, logger *zap.Logger) *RedisClient {
 	}
 
 	client := redis.NewClient(opts)
+	client.AddHook(breakerHook{breaker: resilience.New("redis", config.DependencyConfig{}, redisFailure)})
 	
 	// Test connection
 	ctx := context.Background()
@@ -52,6 +54,7 @@ func NewClient(cfg *config.RedisConfig) *Client {
 		Password: cfg.Password,
 		DB:       cfg.DB,
 	})
+	rdb.AddHook(breakerHook{breaker: resilience.New("redis", cfg.Resilience, redisFailure)})
 
 	return &Client{rdb: rdb}
 }
@@ -0,0 +1,122 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"online-shop/internal/domain/session"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SessionStore keeps each session under session:<id>, the key the gRPC login
+// already uses, plus a per-user set of session IDs for listing. Both fall
+// under the "sessions" cache clear scope.
+type SessionStore struct {
+	client *Client
+}
+
+func NewSessionStore(client *Client) session.Store {
+	return &SessionStore{client: client}
+}
+
+func (s *SessionStore) Save(ctx context.Context, sess *session.Session, ttl time.Duration) error {
+	sess.ExpiresAt = time.Now().Add(ttl)
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, sessionKey(sess.ID), data, ttl)
+		pipe.SAdd(ctx, userSessionsKey(sess.UserID), sess.ID)
+		pipe.Expire(ctx, userSessionsKey(sess.UserID), ttl)
+		return nil
+	})
+	return err
+}
+
+func (s *SessionStore) Get(ctx context.Context, id string) (*session.Session, error) {
+	data, err := s.client.rdb.Get(ctx, sessionKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, session.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sess session.Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, err
+	}
+	return &sess, nil
+}
+
+func (s *SessionStore) Touch(ctx context.Context, sess *session.Session) error {
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return err
+	}
+	// XX so a session revoked in the meantime is not brought back
+	return s.client.rdb.SetArgs(ctx, sessionKey(sess.ID), data, redis.SetArgs{KeepTTL: true, Mode: "XX"}).Err()
+}
+
+// ListByUser returns the user's live sessions and drops IDs whose session has
+// expired from the index.
+func (s *SessionStore) ListByUser(ctx context.Context, userID string) ([]*session.Session, error) {
+	ids, err := s.client.rdb.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return []*session.Session{}, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = sessionKey(id)
+	}
+	values, err := s.client.rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*session.Session, 0, len(ids))
+	var stale []interface{}
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			stale = append(stale, ids[i])
+			continue
+		}
+		var sess session.Session
+		if err := json.Unmarshal([]byte(data), &sess); err != nil || sess.UserID != userID {
+			stale = append(stale, ids[i])
+			continue
+		}
+		sessions = append(sessions, &sess)
+	}
+
+	if len(stale) > 0 {
+		s.client.rdb.SRem(ctx, userSessionsKey(userID), stale...)
+	}
+	return sessions, nil
+}
+
+func (s *SessionStore) Delete(ctx context.Context, sess *session.Session) error {
+	_, err := s.client.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, sessionKey(sess.ID))
+		pipe.SRem(ctx, userSessionsKey(sess.UserID), sess.ID)
+		return nil
+	})
+	return err
+}
+
+func sessionKey(id string) string {
+	return "session:" + id
+}
+
+func userSessionsKey(userID string) string {
+	return "user:" + userID + ":sessions"
+}
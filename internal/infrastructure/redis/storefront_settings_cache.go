@@ -0,0 +1,41 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"online-shop/internal/domain/storefront"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// storefrontSettingsKey falls in the cms cache scope
+const storefrontSettingsKey = "storefront:settings"
+
+type StorefrontSettingsCache struct {
+	client *Client
+}
+
+func NewStorefrontSettingsCache(client *Client) storefront.Cache {
+	return &StorefrontSettingsCache{client: client}
+}
+
+func (c *StorefrontSettingsCache) Get(ctx context.Context) (*storefront.Settings, error) {
+	var settings storefront.Settings
+	err := c.client.Get(ctx, storefrontSettingsKey, &settings)
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (c *StorefrontSettingsCache) Set(ctx context.Context, settings *storefront.Settings, ttl time.Duration) error {
+	return c.client.Set(ctx, storefrontSettingsKey, settings, ttl)
+}
+
+func (c *StorefrontSettingsCache) Clear(ctx context.Context) error {
+	return c.client.Delete(ctx, storefrontSettingsKey)
+}
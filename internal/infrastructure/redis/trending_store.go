@@ -0,0 +1,81 @@
+package redis
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"online-shop/internal/domain/product"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	trendingKey = "trending:products"
+
+	// Scores are kept in hourly buckets and merged with exponential decay
+	trendingWindow   = 24
+	trendingHalfLife = 6.0
+)
+
+type TrendingStore struct {
+	client *Client
+}
+
+func NewTrendingStore(client *Client) product.TrendingRepository {
+	return &TrendingStore{client: client}
+}
+
+func (s *TrendingStore) Record(ctx context.Context, productID string, score float64, at time.Time) error {
+	key := trendingBucketKey(at)
+	pipe := s.client.rdb.TxPipeline()
+	pipe.ZIncrBy(ctx, key, score, productID)
+	pipe.Expire(ctx, key, (trendingWindow+1)*time.Hour)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Refresh rebuilds the trending ranking from the hourly buckets of the last
+// day, weighting older hours down.
+func (s *TrendingStore) Refresh(ctx context.Context, at time.Time) error {
+	keys := make([]string, 0, trendingWindow)
+	weights := make([]float64, 0, trendingWindow)
+	for age := 0; age < trendingWindow; age++ {
+		keys = append(keys, trendingBucketKey(at.Add(-time.Duration(age)*time.Hour)))
+		weights = append(weights, math.Pow(0.5, float64(age)/trendingHalfLife))
+	}
+
+	return s.client.rdb.ZUnionStore(ctx, trendingKey, &redis.ZStore{
+		Keys:      keys,
+		Weights:   weights,
+		Aggregate: "SUM",
+	}).Err()
+}
+
+func (s *TrendingStore) Top(ctx context.Context, limit, offset int) ([]string, error) {
+	return s.client.rdb.ZRevRange(ctx, trendingKey, int64(offset), int64(offset+limit-1)).Result()
+}
+
+func (s *TrendingStore) Forget(ctx context.Context, from, to time.Time, productID string) error {
+	var keys []string
+	for at := from.Truncate(time.Hour); at.Before(to); at = at.Add(time.Hour) {
+		keys = append(keys, trendingBucketKey(at))
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	if productID == "" {
+		return s.client.rdb.Del(ctx, keys...).Err()
+	}
+	pipe := s.client.rdb.TxPipeline()
+	for _, key := range keys {
+		pipe.ZRem(ctx, key, productID)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func trendingBucketKey(at time.Time) string {
+	return "trending:bucket:" + at.UTC().Format("2006010215")
+}
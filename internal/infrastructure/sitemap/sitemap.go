@@ -0,0 +1,296 @@
+package sitemap
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"online-shop/internal/domain/product"
+)
+
+const (
+	xmlns = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+	// MaxURLsPerFile is the limit set by the sitemap protocol
+	MaxURLsPerFile = 50000
+
+	IndexFile = "sitemap.xml"
+)
+
+type urlSet struct {
+	XMLName xml.Name `xml:"urlset"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	URLs    []url    `xml:"url"`
+}
+
+type url struct {
+	Loc        string  `xml:"loc"`
+	LastMod    string  `xml:"lastmod,omitempty"`
+	ChangeFreq string  `xml:"changefreq,omitempty"`
+	Priority   float64 `xml:"priority,omitempty"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	Xmlns    string       `xml:"xmlns,attr"`
+	Sitemaps []indexEntry `xml:"sitemap"`
+}
+
+type indexEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// Generator writes a sitemap index plus gzipped, paginated sitemaps for
+// products and categories into a directory.
+type Generator struct {
+	productRepo  product.Repository
+	categoryRepo product.CategoryRepository
+	siteURL      string
+	outputDir    string
+	pageSize     int
+}
+
+func NewGenerator(productRepo product.Repository, categoryRepo product.CategoryRepository, siteURL, outputDir string, pageSize int) *Generator {
+	if pageSize <= 0 || pageSize > MaxURLsPerFile {
+		pageSize = MaxURLsPerFile
+	}
+	return &Generator{
+		productRepo:  productRepo,
+		categoryRepo: categoryRepo,
+		siteURL:      strings.TrimSuffix(siteURL, "/"),
+		outputDir:    outputDir,
+		pageSize:     pageSize,
+	}
+}
+
+// Generate rebuilds every sitemap file. Each run writes its pages under a
+// generation prefix of their own and switches the index to them last, in a
+// single rename, so a reader never sees an index naming pages that are not
+// there yet. The pages of the generation before are kept for readers who
+// fetched its index just before the switch; older ones are removed.
+func (g *Generator) Generate(ctx context.Context) error {
+	if err := os.MkdirAll(g.outputDir, 0755); err != nil {
+		return err
+	}
+
+	generation := strconv.FormatInt(time.Now().UnixNano(), 10)
+	var files []string
+	switched := false
+	defer func() {
+		if !switched {
+			for _, name := range files {
+				os.Remove(filepath.Join(g.outputDir, name))
+			}
+		}
+	}()
+
+	productFiles, err := g.writeProducts(ctx, generation)
+	files = append(files, productFiles...)
+	if err != nil {
+		return err
+	}
+
+	categoryFiles, err := g.writeCategories(ctx, generation)
+	files = append(files, categoryFiles...)
+	if err != nil {
+		return err
+	}
+
+	if err := g.writeIndex(files); err != nil {
+		return err
+	}
+	switched = true
+	return g.removeStale(generation)
+}
+
+// pageName names a page of a generation. Generations are nanosecond
+// timestamps, so they sort in the order they were written.
+func pageName(generation, kind string, page int) string {
+	return fmt.Sprintf("sitemap-%s-%s-%d.xml.gz", generation, kind, page)
+}
+
+// removeStale removes the pages of every generation but the current one and
+// the one it replaced, along with pages written before generations were
+// used.
+func (g *Generator) removeStale(current string) error {
+	entries, err := os.ReadDir(g.outputDir)
+	if err != nil {
+		return err
+	}
+
+	pages := make(map[string][]string)
+	previous := ""
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, "sitemap-") || !strings.HasSuffix(name, ".xml.gz") {
+			continue
+		}
+		generation := strings.SplitN(strings.TrimPrefix(name, "sitemap-"), "-", 2)[0]
+		if _, err := strconv.ParseInt(generation, 10, 64); err != nil {
+			generation = ""
+		}
+		pages[generation] = append(pages[generation], name)
+		if generation != "" && generation < current && generation > previous {
+			previous = generation
+		}
+	}
+
+	for generation, names := range pages {
+		if generation == current || generation == previous {
+			continue
+		}
+		for _, name := range names {
+			if err := os.Remove(filepath.Join(g.outputDir, name)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (g *Generator) writeProducts(ctx context.Context, generation string) ([]string, error) {
+	var files []string
+	for page := 1; ; page++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		products, err := g.productRepo.List(ctx, product.SearchFilter{
+			Status: product.StatusActive,
+			Limit:  g.pageSize,
+			Offset: (page - 1) * g.pageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(products) == 0 {
+			break
+		}
+
+		urls := make([]url, 0, len(products))
+		for _, p := range products {
+			loc := p.SEO.CanonicalURL
+			if loc == "" {
+				loc = g.siteURL + p.URLPath()
+			}
+			urls = append(urls, url{
+				Loc:        loc,
+				LastMod:    p.UpdatedAt.UTC().Format(time.RFC3339),
+				ChangeFreq: "daily",
+				Priority:   0.8,
+			})
+		}
+
+		name := pageName(generation, "products", page)
+		files = append(files, name)
+		if err := writeGzip(filepath.Join(g.outputDir, name), urlSet{Xmlns: xmlns, URLs: urls}); err != nil {
+			return files, err
+		}
+
+		if len(products) < g.pageSize {
+			break
+		}
+	}
+	return files, nil
+}
+
+func (g *Generator) writeCategories(ctx context.Context, generation string) ([]string, error) {
+	var files []string
+	for page := 1; ; page++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		categories, err := g.categoryRepo.List(ctx, g.pageSize, (page-1)*g.pageSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(categories) == 0 {
+			break
+		}
+
+		urls := make([]url, 0, len(categories))
+		for _, c := range categories {
+			urls = append(urls, url{
+				Loc:        g.siteURL + c.URLPath(),
+				LastMod:    c.UpdatedAt.UTC().Format(time.RFC3339),
+				ChangeFreq: "weekly",
+				Priority:   0.6,
+			})
+		}
+
+		name := pageName(generation, "categories", page)
+		files = append(files, name)
+		if err := writeGzip(filepath.Join(g.outputDir, name), urlSet{Xmlns: xmlns, URLs: urls}); err != nil {
+			return files, err
+		}
+
+		if len(categories) < g.pageSize {
+			break
+		}
+	}
+	return files, nil
+}
+
+// writeIndex writes the index beside the one it replaces and renames it
+// into place.
+func (g *Generator) writeIndex(files []string) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	index := sitemapIndex{Xmlns: xmlns}
+	for _, name := range files {
+		index.Sitemaps = append(index.Sitemaps, indexEntry{
+			Loc:     g.siteURL + "/sitemaps/" + name,
+			LastMod: now,
+		})
+	}
+
+	f, err := os.CreateTemp(g.outputDir, ".sitemap-*.xml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := encode(f, index); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(g.outputDir, IndexFile))
+}
+
+func writeGzip(path string, v interface{}) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	if err := encode(gz, v); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func encode(w io.Writer, v interface{}) error {
+	if _, err := w.Write([]byte(xml.Header)); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,36 @@
+package spreadsheet
+
+import (
+	"fmt"
+
+	"online-shop/internal/domain/export"
+	"online-shop/pkg/config"
+)
+
+// Accounting reads the accounting export layouts of the configuration, in
+// the shop's currency.
+func Accounting(cfg *config.AccountingConfig, currency string) (export.Accounting, error) {
+	location, err := cfg.Location()
+	if err != nil {
+		return export.Accounting{}, err
+	}
+	layouts := make([]export.Layout, len(cfg.Layouts))
+	for i, l := range cfg.Layouts {
+		layout := export.Layout{Name: l.Name, DateFormat: l.DateFormat}
+		for _, t := range l.Entries {
+			layout.Entries = append(layout.Entries, export.EntryType(t))
+		}
+		if l.Delimiter != "" {
+			delimiter := []rune(l.Delimiter)
+			if len(delimiter) != 1 {
+				return export.Accounting{}, fmt.Errorf("accounting layout %s: delimiter must be one character", l.Name)
+			}
+			layout.Delimiter = delimiter[0]
+		}
+		for _, c := range l.Columns {
+			layout.Columns = append(layout.Columns, export.Column{Header: c.Header, Field: c.Field, Value: c.Value})
+		}
+		layouts[i] = layout
+	}
+	return export.NewAccounting(cfg.TaxRate, currency, location, layouts)
+}
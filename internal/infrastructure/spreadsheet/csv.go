@@ -0,0 +1,59 @@
+package spreadsheet
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+
+	"online-shop/internal/domain/export"
+)
+
+type CSVEncoder struct{}
+
+func NewCSVEncoder() export.Encoder {
+	return CSVEncoder{}
+}
+
+func (CSVEncoder) ContentType() string {
+	return "text/csv"
+}
+
+func (CSVEncoder) Extension() string {
+	return "csv"
+}
+
+func (CSVEncoder) Encode(w io.Writer, table *export.Table) error {
+	out := csv.NewWriter(w)
+	if table.Delimiter != 0 {
+		out.Comma = table.Delimiter
+	}
+	if err := out.Write(table.Header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(table.Header))
+	for _, row := range table.Rows {
+		record = record[:0]
+		for _, cell := range row {
+			record = append(record, escapeFormula(cell))
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// escapeFormula stops spreadsheet apps from evaluating user-supplied text
+// such as names or emails as formulas when the CSV is opened.
+func escapeFormula(cell string) string {
+	if cell == "" || !strings.ContainsAny(cell[:1], "=+-@") {
+		return cell
+	}
+	if isNumber(cell) {
+		return cell
+	}
+	return "'" + cell
+}
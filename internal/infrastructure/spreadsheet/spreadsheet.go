@@ -0,0 +1,35 @@
+package spreadsheet
+
+import "online-shop/internal/domain/export"
+
+// Encoders returns the encoder for every supported export format.
+func Encoders() map[export.Format]export.Encoder {
+	return map[export.Format]export.Encoder{
+		export.FormatCSV:  NewCSVEncoder(),
+		export.FormatXLSX: NewXLSXEncoder(),
+	}
+}
+
+// isNumber reports whether a cell is a plain decimal such as "-12.50".
+// Identifiers with leading zeros are left as text so they survive intact.
+func isNumber(cell string) bool {
+	digits := cell
+	if len(digits) > 0 && digits[0] == '-' {
+		digits = digits[1:]
+	}
+	if digits == "" || (len(digits) > 1 && digits[0] == '0' && digits[1] != '.') {
+		return false
+	}
+
+	seenDot := false
+	for i, r := range digits {
+		switch {
+		case r >= '0' && r <= '9':
+		case r == '.' && !seenDot && i > 0 && i < len(digits)-1:
+			seenDot = true
+		default:
+			return false
+		}
+	}
+	return true
+}
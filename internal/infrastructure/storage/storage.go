@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"fmt"
+
+	"online-shop/internal/domain/export"
+	"online-shop/pkg/config"
+)
+
+// New builds the object store selected by the storage driver setting.
+func New(cfg *config.StorageConfig) (export.Storage, error) {
+	switch cfg.Driver {
+	case "s3":
+		return NewS3Store(cfg)
+	case "local", "":
+		return NewLocalStore(cfg)
+	default:
+		return nil, fmt.Errorf("unsupported storage driver: %s", cfg.Driver)
+	}
+}
@@ -0,0 +1,102 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"online-shop/internal/domain/webhook"
+	"online-shop/pkg/httpclient"
+)
+
+// HTTPSender posts deliveries as JSON, signed with the endpoint's secret.
+// Receivers tell repeats of a delivery apart by its X-Webhook-Delivery ID.
+type HTTPSender struct {
+	client *http.Client
+}
+
+// NewHTTPSender sends each delivery once; failed deliveries are retried by
+// the webhook job with backoff. Redirects are not followed, so an endpoint
+// that moved fails until its URL is updated.
+//
+// Outside development, connections are only made to public addresses. The
+// address is checked as it is dialled, after the name is resolved, so an
+// endpoint whose name is later pointed at the shop's own network is refused
+// too. No proxy is used, since it would dial on the sender's behalf.
+func NewHTTPSender(timeout time.Duration, environment string) webhook.Sender {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}
+	if environment != "development" {
+		dialer.Control = publicOnly
+	}
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	opts := httpclient.Options{Name: "webhooks", Timeout: timeout, Retries: 0}
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: httpclient.NewTransport(opts, transport),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return &HTTPSender{client: client}
+}
+
+// publicOnly refuses connections to addresses deliveries may not go to
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !webhook.PublicIP(ip) {
+		return fmt.Errorf("%w: %s", webhook.ErrPrivateDestination, host)
+	}
+	return nil
+}
+
+func (s *HTTPSender) Send(ctx context.Context, e *webhook.Endpoint, d *webhook.Delivery) *webhook.Attempt {
+	start := time.Now()
+	a := &webhook.Attempt{AttemptedAt: start}
+	body := []byte(d.Payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "online-shop-webhooks/1.0")
+	req.Header.Set("X-Webhook-Event", string(d.Event))
+	req.Header.Set("X-Webhook-Delivery", d.ID)
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign(e.Secret, start, body))
+
+	resp, err := s.client.Do(req)
+	a.DurationMS = time.Since(start).Milliseconds()
+	if err != nil {
+		a.Error = err.Error()
+		// A refused destination is logged without the address it resolved
+		// to, which would map out the shop's network
+		if errors.Is(err, webhook.ErrPrivateDestination) {
+			a.Error = webhook.ErrPrivateDestination.Error()
+		}
+		return a
+	}
+	defer resp.Body.Close()
+
+	a.StatusCode = resp.StatusCode
+	response, _ := io.ReadAll(io.LimitReader(resp.Body, webhook.MaxResponseLength))
+	a.Response = strings.ToValidUTF8(string(bytes.TrimSpace(response)), "")
+	return a
+}
@@ -0,0 +1,323 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"online-shop/internal/domain/whatsapp"
+	"online-shop/pkg/config"
+	"online-shop/pkg/httpclient"
+)
+
+// CloudAPI talks to the WhatsApp Business Cloud API on Meta's Graph API:
+// messages are sent from the business phone number and templates are
+// managed on the business account. It also verifies the webhook, whose
+// notifications are signed with the app secret.
+type CloudAPI struct {
+	endpoint    string
+	phoneID     string
+	accountID   string
+	token       string
+	appSecret   string
+	verifyToken string
+	client      *http.Client
+}
+
+func NewCloudAPI(cfg *config.WhatsAppConfig) *CloudAPI {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://graph.facebook.com"
+	}
+	version := cfg.APIVersion
+	if version == "" {
+		version = "v19.0"
+	}
+	return &CloudAPI{
+		endpoint:    strings.TrimRight(endpoint, "/") + "/" + version,
+		phoneID:     cfg.PhoneNumberID,
+		accountID:   cfg.BusinessAccountID,
+		token:       cfg.AccessToken,
+		appSecret:   cfg.AppSecret,
+		verifyToken: cfg.VerifyToken,
+		client:      httpclient.New(httpclient.Options{Name: "whatsapp", Retries: httpclient.DefaultRetries}),
+	}
+}
+
+type textParameter struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func (c *CloudAPI) SendTemplate(ctx context.Context, to string, t *whatsapp.Template, values []string) (string, error) {
+	template := map[string]interface{}{
+		"name":     t.Name,
+		"language": map[string]string{"code": t.Language},
+	}
+	if len(values) > 0 {
+		parameters := make([]textParameter, len(values))
+		for i, value := range values {
+			parameters[i] = textParameter{Type: "text", Text: value}
+		}
+		template["components"] = []map[string]interface{}{{"type": "body", "parameters": parameters}}
+	}
+
+	var sent struct {
+		Messages []struct {
+			ID string `json:"id"`
+		} `json:"messages"`
+	}
+	err := c.do(ctx, http.MethodPost, "/"+c.phoneID+"/messages", map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"to":                to,
+		"type":              "template",
+		"template":          template,
+	}, &sent)
+	if err != nil {
+		return "", err
+	}
+	if len(sent.Messages) == 0 {
+		return "", fmt.Errorf("whatsapp returned no message id")
+	}
+	return sent.Messages[0].ID, nil
+}
+
+func (c *CloudAPI) SubmitTemplate(ctx context.Context, t *whatsapp.Template) (whatsapp.TemplateStatus, error) {
+	body := map[string]interface{}{"type": "BODY", "text": t.Body}
+	if len(t.Parameters) > 0 {
+		examples := make([]string, len(t.Parameters))
+		for i, p := range t.Parameters {
+			examples[i] = p.Example
+		}
+		body["example"] = map[string]interface{}{"body_text": [][]string{examples}}
+	}
+
+	var created struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+	err := c.do(ctx, http.MethodPost, "/"+c.accountID+"/message_templates", map[string]interface{}{
+		"name":       t.Name,
+		"language":   t.Language,
+		"category":   t.Category,
+		"components": []interface{}{body},
+	}, &created)
+	if err != nil {
+		return whatsapp.TemplateStatus{}, err
+	}
+	return whatsapp.TemplateStatus{Name: t.Name, Language: t.Language, ProviderID: created.ID, Status: created.Status}, nil
+}
+
+func (c *CloudAPI) DeleteTemplate(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/"+c.accountID+"/message_templates?name="+url.QueryEscape(name), nil, nil)
+}
+
+// Templates lists every template on the business account, following the
+// pages of the listing
+func (c *CloudAPI) Templates(ctx context.Context) ([]whatsapp.TemplateStatus, error) {
+	var statuses []whatsapp.TemplateStatus
+	path := "/" + c.accountID + "/message_templates?fields=id,name,language,status,rejected_reason&limit=100"
+	for path != "" {
+		var page struct {
+			Data []struct {
+				ID             string `json:"id"`
+				Name           string `json:"name"`
+				Language       string `json:"language"`
+				Status         string `json:"status"`
+				RejectedReason string `json:"rejected_reason"`
+			} `json:"data"`
+			Paging struct {
+				Next string `json:"next"`
+			} `json:"paging"`
+		}
+		if err := c.do(ctx, http.MethodGet, path, nil, &page); err != nil {
+			return nil, err
+		}
+		for _, t := range page.Data {
+			statuses = append(statuses, whatsapp.TemplateStatus{
+				Name:           t.Name,
+				Language:       t.Language,
+				ProviderID:     t.ID,
+				Status:         t.Status,
+				RejectedReason: rejectedReason(t.RejectedReason),
+			})
+		}
+		path = page.Paging.Next
+	}
+	return statuses, nil
+}
+
+// Challenge answers the GET Meta sends when the webhook is subscribed
+func (c *CloudAPI) Challenge(mode, token, challenge string) (string, error) {
+	if mode != "subscribe" || c.verifyToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(c.verifyToken)) != 1 {
+		return "", fmt.Errorf("%w: verify token mismatch", whatsapp.ErrInvalidWebhook)
+	}
+	return challenge, nil
+}
+
+// Updates verifies the X-Hub-Signature-256 header, an HMAC-SHA256 of the
+// body with the app secret, and collects the message statuses and template
+// reviews in the notification
+func (c *CloudAPI) Updates(signature string, body []byte) (*whatsapp.Updates, error) {
+	if c.appSecret == "" {
+		return nil, fmt.Errorf("%w: no app secret is configured", whatsapp.ErrInvalidWebhook)
+	}
+	sum, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || !strings.HasPrefix(signature, "sha256=") {
+		return nil, fmt.Errorf("%w: missing signature", whatsapp.ErrInvalidWebhook)
+	}
+	mac := hmac.New(sha256.New, []byte(c.appSecret))
+	mac.Write(body)
+	if !hmac.Equal(sum, mac.Sum(nil)) {
+		return nil, fmt.Errorf("%w: signature mismatch", whatsapp.ErrInvalidWebhook)
+	}
+
+	var notification struct {
+		Entry []struct {
+			Changes []struct {
+				Field string          `json:"field"`
+				Value json.RawMessage `json:"value"`
+			} `json:"changes"`
+		} `json:"entry"`
+	}
+	if err := json.Unmarshal(body, &notification); err != nil {
+		return nil, fmt.Errorf("%w: %v", whatsapp.ErrInvalidWebhook, err)
+	}
+
+	updates := &whatsapp.Updates{}
+	for _, entry := range notification.Entry {
+		for _, change := range entry.Changes {
+			switch change.Field {
+			case "messages":
+				statuses, err := parseStatuses(change.Value)
+				if err != nil {
+					return nil, fmt.Errorf("%w: %v", whatsapp.ErrInvalidWebhook, err)
+				}
+				updates.Statuses = append(updates.Statuses, statuses...)
+			case "message_template_status_update":
+				var review struct {
+					Event      string      `json:"event"`
+					TemplateID json.Number `json:"message_template_id"`
+					Name       string      `json:"message_template_name"`
+					Language   string      `json:"message_template_language"`
+					Reason     string      `json:"reason"`
+				}
+				if err := json.Unmarshal(change.Value, &review); err != nil {
+					return nil, fmt.Errorf("%w: %v", whatsapp.ErrInvalidWebhook, err)
+				}
+				updates.Templates = append(updates.Templates, whatsapp.TemplateStatus{
+					Name:           review.Name,
+					Language:       review.Language,
+					ProviderID:     review.TemplateID.String(),
+					Status:         review.Event,
+					RejectedReason: rejectedReason(review.Reason),
+				})
+			}
+		}
+	}
+	return updates, nil
+}
+
+func parseStatuses(value json.RawMessage) ([]whatsapp.StatusUpdate, error) {
+	var messages struct {
+		Statuses []struct {
+			ID          string `json:"id"`
+			Status      string `json:"status"`
+			Timestamp   string `json:"timestamp"`
+			RecipientID string `json:"recipient_id"`
+			Errors      []struct {
+				Code  int    `json:"code"`
+				Title string `json:"title"`
+			} `json:"errors"`
+		} `json:"statuses"`
+	}
+	if err := json.Unmarshal(value, &messages); err != nil {
+		return nil, err
+	}
+
+	updates := make([]whatsapp.StatusUpdate, 0, len(messages.Statuses))
+	for _, s := range messages.Statuses {
+		update := whatsapp.StatusUpdate{MessageID: s.ID, Recipient: s.RecipientID, Status: s.Status}
+		if seconds, err := strconv.ParseInt(s.Timestamp, 10, 64); err == nil && seconds > 0 {
+			update.Timestamp = time.Unix(seconds, 0)
+		}
+		if len(s.Errors) > 0 {
+			update.Error = fmt.Sprintf("%d: %s", s.Errors[0].Code, s.Errors[0].Title)
+		}
+		updates = append(updates, update)
+	}
+	return updates, nil
+}
+
+// rejectedReason drops the NONE Meta sends for templates that were not
+// rejected
+func rejectedReason(reason string) string {
+	if reason == "NONE" {
+		return ""
+	}
+	return reason
+}
+
+// do sends a Graph API request to a path, or to the full URL of a next page.
+// The API's own error message is kept, and 4xx responses other than
+// throttling are ErrRejected.
+func (c *CloudAPI) do(ctx context.Context, method, path string, payload, result interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	target := path
+	if !strings.HasPrefix(path, "https://") && !strings.HasPrefix(path, "http://") {
+		target = c.endpoint + path
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		var failure struct {
+			Error struct {
+				Message string `json:"message"`
+				Code    int    `json:"code"`
+			} `json:"error"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		_ = json.Unmarshal(data, &failure)
+		if resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusTooManyRequests {
+			return fmt.Errorf("%w: %s", whatsapp.ErrRejected, failure.Error.Message)
+		}
+		return fmt.Errorf("whatsapp returned status %d: %s", resp.StatusCode, failure.Error.Message)
+	}
+
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
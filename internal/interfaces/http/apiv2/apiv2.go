@@ -0,0 +1,273 @@
+// Package apiv2 holds what the v2 API answers with and maps the domain to
+// it. The v1 API answers with the domain entities themselves, so their JSON
+// is frozen for v1's sake; v2 is free of them and changes only here. Lists
+// are paged by keyset cursor only, without page numbers or totals.
+package apiv2
+
+import (
+	"time"
+
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/product"
+)
+
+// Currency is the currency amounts are in; the shop only sells in rupiah
+const Currency = "IDR"
+
+// Money is an amount with its currency, where v1 sends bare numbers.
+type Money struct {
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+}
+
+func money(amount float64) Money {
+	return Money{Amount: amount, Currency: Currency}
+}
+
+type Product struct {
+	ID           string       `json:"id"`
+	Slug         string       `json:"slug"`
+	Name         string       `json:"name"`
+	Description  string       `json:"description"`
+	Price        Money        `json:"price"`
+	Availability Availability `json:"availability"`
+	Category     *Category    `json:"category,omitempty"`
+	MerchantID   string       `json:"merchant_id"`
+	Images       []string     `json:"images"`
+	Status       string       `json:"status"`
+	Attributes   []Attribute  `json:"attributes"`
+	// FlashSale is set while the product is in a flash sale; Price is then
+	// the sale price
+	FlashSale *FlashSale `json:"flash_sale,omitempty"`
+	SEO       SEO        `json:"seo"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type Availability struct {
+	InStock  bool `json:"in_stock"`
+	Quantity int  `json:"quantity"`
+}
+
+type Category struct {
+	ID   string `json:"id"`
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+type Attribute struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	Unit  string `json:"unit,omitempty"`
+}
+
+type FlashSale struct {
+	ID              string    `json:"id"`
+	Name            string    `json:"name"`
+	OriginalPrice   Money     `json:"original_price"`
+	DiscountPercent int       `json:"discount_percent"`
+	Remaining       int       `json:"remaining"`
+	PerUserLimit    int       `json:"per_user_limit,omitempty"`
+	EndsAt          time.Time `json:"ends_at"`
+}
+
+type SEO struct {
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	CanonicalURL string `json:"canonical_url"`
+}
+
+// MapProduct maps a product as served, after its SEO defaults and flash
+// sale have been applied.
+func MapProduct(p *product.Product) Product {
+	dto := Product{
+		ID:           p.ID,
+		Slug:         p.Slug,
+		Name:         p.Name,
+		Description:  p.Description,
+		Price:        money(p.Price),
+		Availability: Availability{InStock: p.Stock > 0, Quantity: p.Stock},
+		MerchantID:   p.MerchantID,
+		Images:       p.Images,
+		Status:       string(p.Status),
+		Attributes:   make([]Attribute, len(p.Attributes)),
+		SEO: SEO{
+			Title:        p.SEO.MetaTitle,
+			Description:  p.SEO.MetaDescription,
+			CanonicalURL: p.SEO.CanonicalURL,
+		},
+		CreatedAt: p.CreatedAt.UTC(),
+		UpdatedAt: p.UpdatedAt.UTC(),
+	}
+	if dto.Images == nil {
+		dto.Images = []string{}
+	}
+	if p.Category != nil {
+		dto.Category = &Category{ID: p.Category.ID, Slug: p.Category.Slug, Name: p.Category.Name}
+	}
+	for i, a := range p.Attributes {
+		dto.Attributes[i] = Attribute{Key: a.Key, Label: a.Label, Type: string(a.Type), Value: a.Value, Unit: a.Unit}
+	}
+	if sale := p.FlashSale; sale != nil {
+		dto.Price = money(sale.Price)
+		dto.FlashSale = &FlashSale{
+			ID:              sale.SaleID,
+			Name:            sale.Name,
+			OriginalPrice:   money(sale.OriginalPrice),
+			DiscountPercent: sale.DiscountPercent,
+			Remaining:       sale.Remaining,
+			PerUserLimit:    sale.PerUserLimit,
+			EndsAt:          sale.EndsAt.UTC(),
+		}
+	}
+	return dto
+}
+
+func MapProducts(products []*product.Product) []Product {
+	dtos := make([]Product, len(products))
+	for i, p := range products {
+		dtos[i] = MapProduct(p)
+	}
+	return dtos
+}
+
+// Order leaves out the commission taken on each item and the payment and
+// parcel details v1 carries, which are for the shop and its merchants.
+type Order struct {
+	ID              string        `json:"id"`
+	Number          string        `json:"number"`
+	Status          string        `json:"status"`
+	PaymentStatus   string        `json:"payment_status"`
+	Total           Money         `json:"total"`
+	Paid            Money         `json:"paid"`
+	Items           []OrderItem   `json:"items"`
+	ShippingAddress Address       `json:"shipping_address"`
+	Shipping        *Shipping     `json:"shipping,omitempty"`
+	Shipments       []Shipment    `json:"shipments"`
+	Cancellation    *Cancellation `json:"cancellation,omitempty"`
+	CreatedAt       time.Time     `json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+}
+
+type OrderItem struct {
+	ProductID string `json:"product_id"`
+	// ProductName and ProductImage are as the product was ordered; items
+	// ordered before they were recorded have none
+	ProductName  string `json:"product_name,omitempty"`
+	ProductImage string `json:"product_image,omitempty"`
+	MerchantID   string `json:"merchant_id"`
+	Quantity     int    `json:"quantity"`
+	UnitPrice    Money  `json:"unit_price"`
+	Subtotal     Money  `json:"subtotal"`
+	ShipmentID   string `json:"shipment_id,omitempty"`
+	FlashSaleID  string `json:"flash_sale_id,omitempty"`
+}
+
+type Address struct {
+	Street     string `json:"street"`
+	City       string `json:"city"`
+	State      string `json:"state,omitempty"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"`
+}
+
+// Shipping is the rate the order ships at, with its fee included in the
+// total
+type Shipping struct {
+	Rate string `json:"rate"`
+	Fee  Money  `json:"fee"`
+	// EstimatedFrom and EstimatedTo are the dates, as 2006-01-02, the order
+	// should arrive between
+	EstimatedFrom string `json:"estimated_from,omitempty"`
+	EstimatedTo   string `json:"estimated_to,omitempty"`
+}
+
+type Shipment struct {
+	ID             string     `json:"id"`
+	Status         string     `json:"status"`
+	Carrier        string     `json:"carrier,omitempty"`
+	TrackingNumber string     `json:"tracking_number,omitempty"`
+	ShippedAt      *time.Time `json:"shipped_at,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+type Cancellation struct {
+	Reason   string     `json:"reason"`
+	Note     string     `json:"note,omitempty"`
+	Fee      Money      `json:"fee"`
+	Refunded Money      `json:"refunded"`
+	At       *time.Time `json:"at,omitempty"`
+}
+
+func MapOrder(o *order.Order) Order {
+	dto := Order{
+		ID:            o.ID,
+		Number:        o.Number,
+		Status:        string(o.Status),
+		PaymentStatus: string(o.PaymentStatus),
+		Total:         money(o.TotalAmount),
+		Paid:          money(o.PaidAmount),
+		Items:         make([]OrderItem, len(o.Items)),
+		ShippingAddress: Address{
+			Street:     o.ShippingAddress.Street,
+			City:       o.ShippingAddress.City,
+			State:      o.ShippingAddress.State,
+			PostalCode: o.ShippingAddress.PostalCode,
+			Country:    o.ShippingAddress.Country,
+		},
+		Shipments: make([]Shipment, len(o.Shipments)),
+		CreatedAt: o.CreatedAt.UTC(),
+		UpdatedAt: o.UpdatedAt.UTC(),
+	}
+	for i, item := range o.Items {
+		dto.Items[i] = OrderItem{
+			ProductID:    item.ProductID,
+			ProductName:  item.Product.Name,
+			ProductImage: item.Product.Image,
+			MerchantID:   item.MerchantID,
+			Quantity:     item.Quantity,
+			UnitPrice:    money(item.Price),
+			Subtotal:     money(item.Subtotal),
+			ShipmentID:   item.ShipmentID,
+			FlashSaleID:  item.FlashSaleID,
+		}
+	}
+	for i, s := range o.Shipments {
+		dto.Shipments[i] = Shipment{
+			ID:             s.ID,
+			Status:         string(s.Status),
+			Carrier:        s.Carrier,
+			TrackingNumber: s.TrackingNumber,
+			ShippedAt:      s.ShippedAt,
+			DeliveredAt:    s.DeliveredAt,
+		}
+	}
+	if o.Shipping.ZoneID != "" {
+		dto.Shipping = &Shipping{
+			Rate:          o.Shipping.Rate,
+			Fee:           money(o.Shipping.Fee),
+			EstimatedFrom: o.Delivery.Earliest,
+			EstimatedTo:   o.Delivery.Latest,
+		}
+	}
+	if o.Status == order.StatusCancelled {
+		dto.Cancellation = &Cancellation{
+			Reason:   string(o.Cancellation.Reason),
+			Note:     o.Cancellation.Note,
+			Fee:      money(o.Cancellation.Fee),
+			Refunded: money(o.Cancellation.Refunded),
+			At:       o.Cancellation.At,
+		}
+	}
+	return dto
+}
+
+func MapOrders(orders []*order.Order) []Order {
+	dtos := make([]Order, len(orders))
+	for i, o := range orders {
+		dtos[i] = MapOrder(o)
+	}
+	return dtos
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"context"
+
+	"online-shop/internal/domain/analytics"
+	"online-shop/internal/domain/order"
+
+	"github.com/gin-gonic/gin"
+)
+
+const SessionIDHeader = "X-Session-ID"
+
+// publishProductEvent sends a product event without holding up the response.
+// Analytics is best effort, so publish errors are ignored.
+func publishProductEvent(c *gin.Context, publisher analytics.Publisher, name, productID string, quantity int) {
+	userID := c.GetString("user_id")
+	sessionID := c.GetHeader(SessionIDHeader)
+	event := analytics.NewProductEvent(name, productID, userID, sessionID, quantity)
+
+	go publisher.Publish(context.Background(), event)
+}
+
+// publishPurchaseEvents records one purchase event per order line.
+func publishPurchaseEvents(c *gin.Context, publisher analytics.Publisher, o *order.Order) {
+	sessionID := c.GetHeader(SessionIDHeader)
+	for _, item := range o.Items {
+		event := analytics.NewPurchaseEvent(o.ID, item.ProductID, item.WarehouseID, o.UserID, sessionID, item.Quantity, item.Price, item.CommissionAmount)
+		go publisher.Publish(context.Background(), event)
+	}
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/audit"
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/pagination"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditHandler struct {
+	listAuditLogsHandler *queries.ListAuditLogsQueryHandler
+}
+
+func NewAuditHandler(listAuditLogsHandler *queries.ListAuditLogsQueryHandler) *AuditHandler {
+	return &AuditHandler{listAuditLogsHandler: listAuditLogsHandler}
+}
+
+func (h *AuditHandler) ListAuditLogs(c *gin.Context) {
+	query := queries.ListAuditLogsQuery{
+		Filter: audit.Filter{
+			ActorID:        c.Query("actor_id"),
+			ImpersonatorID: c.Query("impersonator_id"),
+			Source:         c.Query("source"),
+			Action:         c.Query("action"),
+			ResourceType:   c.Query("resource_type"),
+			ResourceID:     c.Query("resource_id"),
+		},
+	}
+
+	if from := c.Query("from"); from != "" {
+		t, _, err := parseReportTime(from)
+		if err != nil {
+			respondError(c, apperror.ErrInvalidRequest.WithDetail("invalid from date"))
+			return
+		}
+		query.Filter.From = t
+	}
+
+	if to := c.Query("to"); to != "" {
+		t, dateOnly, err := parseReportTime(to)
+		if err != nil {
+			respondError(c, apperror.ErrInvalidRequest.WithDetail("invalid to date"))
+			return
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		query.Filter.To = t
+	}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	logs, err := h.listAuditLogsHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, logs.Entries, page.Meta(len(logs.Entries), pagination.Total(logs.Total)))
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"net/http"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BadgeHandler serves the admin API for product badges: the badge rules and
+// the badges set on products by hand.
+type BadgeHandler struct {
+	listBadgesHandler        *queries.ListBadgesQueryHandler
+	listProductBadgesHandler *queries.ListProductBadgesQueryHandler
+	createBadgeHandler       *commands.CreateBadgeCommandHandler
+	updateBadgeHandler       *commands.UpdateBadgeCommandHandler
+	deleteBadgeHandler       *commands.DeleteBadgeCommandHandler
+	assignHandler            *commands.AssignProductBadgeCommandHandler
+	unassignHandler          *commands.UnassignProductBadgeCommandHandler
+}
+
+func NewBadgeHandler(
+	listBadgesHandler *queries.ListBadgesQueryHandler,
+	listProductBadgesHandler *queries.ListProductBadgesQueryHandler,
+	createBadgeHandler *commands.CreateBadgeCommandHandler,
+	updateBadgeHandler *commands.UpdateBadgeCommandHandler,
+	deleteBadgeHandler *commands.DeleteBadgeCommandHandler,
+	assignHandler *commands.AssignProductBadgeCommandHandler,
+	unassignHandler *commands.UnassignProductBadgeCommandHandler,
+) *BadgeHandler {
+	return &BadgeHandler{
+		listBadgesHandler:        listBadgesHandler,
+		listProductBadgesHandler: listProductBadgesHandler,
+		createBadgeHandler:       createBadgeHandler,
+		updateBadgeHandler:       updateBadgeHandler,
+		deleteBadgeHandler:       deleteBadgeHandler,
+		assignHandler:            assignHandler,
+		unassignHandler:          unassignHandler,
+	}
+}
+
+func (h *BadgeHandler) ListBadges(c *gin.Context) {
+	badges, err := h.listBadgesHandler.Handle(c.Request.Context(), queries.ListBadgesQuery{})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, badges)
+}
+
+func (h *BadgeHandler) CreateBadge(c *gin.Context) {
+	var cmd commands.CreateBadgeCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	badge, err := h.createBadgeHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, badge)
+}
+
+func (h *BadgeHandler) UpdateBadge(c *gin.Context) {
+	var cmd commands.UpdateBadgeCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.Code = c.Param("code")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	badge, err := h.updateBadgeHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, badge)
+}
+
+func (h *BadgeHandler) DeleteBadge(c *gin.Context) {
+	if err := h.deleteBadgeHandler.Handle(c.Request.Context(), commands.DeleteBadgeCommand{Code: c.Param("code")}); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *BadgeHandler) ListProductBadges(c *gin.Context) {
+	assignments, err := h.listProductBadgesHandler.Handle(c.Request.Context(), queries.ListProductBadgesQuery{ProductID: c.Param("id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, assignments)
+}
+
+func (h *BadgeHandler) AssignProductBadge(c *gin.Context) {
+	var cmd commands.AssignProductBadgeCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.ProductID = c.Param("id")
+	cmd.CreatedBy = c.GetString("user_id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	assignment, err := h.assignHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, assignment)
+}
+
+func (h *BadgeHandler) UnassignProductBadge(c *gin.Context) {
+	cmd := commands.UnassignProductBadgeCommand{ProductID: c.Param("id"), Code: c.Param("code")}
+	if err := h.unassignHandler.Handle(c.Request.Context(), cmd); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/pkg/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BannerHandler struct {
+	createBannerHandler *commands.CreateBannerCommandHandler
+	updateBannerHandler *commands.UpdateBannerCommandHandler
+	deleteBannerHandler *commands.DeleteBannerCommandHandler
+	listBannersHandler  *queries.ListBannersQueryHandler
+}
+
+func NewBannerHandler(
+	createBannerHandler *commands.CreateBannerCommandHandler,
+	updateBannerHandler *commands.UpdateBannerCommandHandler,
+	deleteBannerHandler *commands.DeleteBannerCommandHandler,
+	listBannersHandler *queries.ListBannersQueryHandler,
+) *BannerHandler {
+	return &BannerHandler{
+		createBannerHandler: createBannerHandler,
+		updateBannerHandler: updateBannerHandler,
+		deleteBannerHandler: deleteBannerHandler,
+		listBannersHandler:  listBannersHandler,
+	}
+}
+
+func (h *BannerHandler) ListBanners(c *gin.Context) {
+	query := queries.ListBannersQuery{}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	banners, err := h.listBannersHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, banners, page.Meta(len(banners), nil))
+}
+
+func (h *BannerHandler) CreateBanner(c *gin.Context) {
+	var cmd commands.CreateBannerCommand
+	if err := c.ShouldBindJSON(&cmd); err != nil {
+		respondError(c, apperror.ErrInvalidRequest.WithDetail(err.Error()))
+		return
+	}
+
+	banner, err := h.createBannerHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, banner)
+}
+
+func (h *BannerHandler) UpdateBanner(c *gin.Context) {
+	var cmd commands.UpdateBannerCommand
+	if err := c.ShouldBindJSON(&cmd); err != nil {
+		respondError(c, apperror.ErrInvalidRequest.WithDetail(err.Error()))
+		return
+	}
+	cmd.BannerID = c.Param("id")
+
+	banner, err := h.updateBannerHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, banner)
+}
+
+func (h *BannerHandler) DeleteBanner(c *gin.Context) {
+	cmd := commands.DeleteBannerCommand{BannerID: c.Param("id")}
+
+	if err := h.deleteBannerHandler.Handle(c.Request.Context(), cmd); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "Banner deleted successfully"})
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"online-shop/internal/application/commands"
+	"online-shop/internal/interfaces/http/middleware"
+	"online-shop/pkg/apperror"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CacheHandler struct {
+	clearCacheHandler *commands.ClearCacheCommandHandler
+}
+
+func NewCacheHandler(clearCacheHandler *commands.ClearCacheCommandHandler) *CacheHandler {
+	return &CacheHandler{clearCacheHandler: clearCacheHandler}
+}
+
+func (h *CacheHandler) ClearCache(c *gin.Context) {
+	var cmd commands.ClearCacheCommand
+	if err := c.ShouldBindJSON(&cmd); err != nil {
+		respondError(c, apperror.ErrInvalidRequest.WithDetail(err.Error()))
+		return
+	}
+	cmd.ActorID = c.GetString("user_id")
+	cmd.ActorRole = c.GetString("user_role")
+	cmd.RequestID = middleware.GetRequestID(c)
+
+	result, err := h.clearCacheHandler.Handle(c.Request.Context(), cmd)
+	if result != nil {
+		middleware.RecordCacheInvalidation(string(result.Scope), result.KeysInvalidated)
+	}
+	if err != nil {
+		// Scopes cleared before the failure stay cleared
+		if result != nil && result.KeysInvalidated > 0 {
+			err = apperror.From(err).WithMeta("keys_invalidated", strconv.FormatInt(result.KeysInvalidated, 10))
+		}
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, result)
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"net/http"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/campaign"
+	"online-shop/pkg/pagination"
+
+	"github.com/gin-gonic/gin"
+)
+
+// trackingPixel is a transparent 1x1 GIF, the open beacon of campaign emails
+var trackingPixel = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// CampaignHandler serves the marketing email campaigns: admins compose,
+// schedule and follow them, and the tracking endpoints their emails link
+// to record opens and clicks.
+type CampaignHandler struct {
+	trackHandler    *commands.TrackCampaignEventCommandHandler
+	listHandler     *queries.ListCampaignsQueryHandler
+	getHandler      *queries.GetCampaignQueryHandler
+	statsHandler    *queries.GetCampaignStatsQueryHandler
+	audienceHandler *queries.CountCampaignAudienceQueryHandler
+	createHandler   *commands.CreateCampaignCommandHandler
+	updateHandler   *commands.UpdateCampaignCommandHandler
+	scheduleHandler *commands.ScheduleCampaignCommandHandler
+	cancelHandler   *commands.CancelCampaignCommandHandler
+}
+
+func NewCampaignHandler(
+	trackHandler *commands.TrackCampaignEventCommandHandler,
+	listHandler *queries.ListCampaignsQueryHandler,
+	getHandler *queries.GetCampaignQueryHandler,
+	statsHandler *queries.GetCampaignStatsQueryHandler,
+	audienceHandler *queries.CountCampaignAudienceQueryHandler,
+	createHandler *commands.CreateCampaignCommandHandler,
+	updateHandler *commands.UpdateCampaignCommandHandler,
+	scheduleHandler *commands.ScheduleCampaignCommandHandler,
+	cancelHandler *commands.CancelCampaignCommandHandler,
+) *CampaignHandler {
+	return &CampaignHandler{
+		trackHandler:    trackHandler,
+		listHandler:     listHandler,
+		getHandler:      getHandler,
+		statsHandler:    statsHandler,
+		audienceHandler: audienceHandler,
+		createHandler:   createHandler,
+		updateHandler:   updateHandler,
+		scheduleHandler: scheduleHandler,
+		cancelHandler:   cancelHandler,
+	}
+}
+
+// TrackOpen records an open and always answers with the pixel, so a bad
+// token never shows a broken image in the email.
+func (h *CampaignHandler) TrackOpen(c *gin.Context) {
+	cmd := commands.TrackCampaignEventCommand{Token: c.Param("token"), Type: campaign.EventOpen}
+	_, _ = h.trackHandler.Handle(c.Request.Context(), cmd)
+
+	c.Header("Cache-Control", "no-store, no-cache, must-revalidate, private")
+	c.Header("Pragma", "no-cache")
+	c.Data(http.StatusOK, "image/gif", trackingPixel)
+}
+
+// TrackClick records a click and redirects to the link's URL.
+func (h *CampaignHandler) TrackClick(c *gin.Context) {
+	cmd := commands.TrackCampaignEventCommand{Token: c.Param("token"), Type: campaign.EventClick}
+	link, err := h.trackHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, link.URL)
+}
+
+func (h *CampaignHandler) ListCampaigns(c *gin.Context) {
+	query := queries.ListCampaignsQuery{Status: c.Query("status")}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+	if !validateRequest(c, &query) {
+		return
+	}
+
+	campaigns, err := h.listHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, campaigns.Campaigns, page.Meta(len(campaigns.Campaigns), pagination.Total(campaigns.Total)))
+}
+
+func (h *CampaignHandler) CreateCampaign(c *gin.Context) {
+	var cmd commands.CreateCampaignCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.CreatedBy = c.GetString("user_id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	created, err := h.createHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, created)
+}
+
+func (h *CampaignHandler) GetCampaign(c *gin.Context) {
+	found, err := h.getHandler.Handle(c.Request.Context(), queries.GetCampaignQuery{CampaignID: c.Param("id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, found)
+}
+
+func (h *CampaignHandler) UpdateCampaign(c *gin.Context) {
+	var cmd commands.UpdateCampaignCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.ID = c.Param("id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	updated, err := h.updateHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, updated)
+}
+
+// ScheduleCampaign schedules the campaign for send_at, or sends it on the
+// next run of the campaign job without a body.
+func (h *CampaignHandler) ScheduleCampaign(c *gin.Context) {
+	var cmd commands.ScheduleCampaignCommand
+	if c.Request.ContentLength > 0 && !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.ID = c.Param("id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	scheduled, err := h.scheduleHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, scheduled)
+}
+
+func (h *CampaignHandler) CancelCampaign(c *gin.Context) {
+	canceled, err := h.cancelHandler.Handle(c.Request.Context(), commands.CancelCampaignCommand{ID: c.Param("id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, canceled)
+}
+
+func (h *CampaignHandler) GetCampaignStats(c *gin.Context) {
+	stats, err := h.statsHandler.Handle(c.Request.Context(), queries.GetCampaignStatsQuery{CampaignID: c.Param("id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, stats)
+}
+
+func (h *CampaignHandler) CountAudience(c *gin.Context) {
+	audience, err := h.audienceHandler.Handle(c.Request.Context(), queries.CountCampaignAudienceQuery{CampaignID: c.Param("id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, audience)
+}
@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/analytics"
+	"online-shop/internal/domain/cart"
+	"online-shop/pkg/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CartTokenHeader carries an anonymous visitor's cart token. Browsers can
+// rely on the cart_token cookie instead.
+const CartTokenHeader = "X-Cart-Token"
+
+const cartTokenCookie = "cart_token"
+
+// CartHandler serves the shopping cart of signed-in customers and of
+// anonymous visitors. A visitor's first change to the cart issues a token,
+// sent back in the X-Cart-Token header and the cart_token cookie; signing
+// in merges that cart into the account cart.
+type CartHandler struct {
+	getHandler      *queries.GetCartQueryHandler
+	addHandler      *commands.AddToCartCommandHandler
+	updateHandler   *commands.UpdateCartItemCommandHandler
+	removeHandler   *commands.RemoveFromCartCommandHandler
+	clearHandler    *commands.ClearCartCommandHandler
+	mergeHandler    *commands.MergeCartCommandHandler
+	checkoutHandler *commands.CheckoutCartCommandHandler
+	previewHandler  *commands.PreviewCheckoutCommandHandler
+	analytics       analytics.Publisher
+	sessionTTL      time.Duration
+	secureCookie    bool
+}
+
+func NewCartHandler(
+	getHandler *queries.GetCartQueryHandler,
+	addHandler *commands.AddToCartCommandHandler,
+	updateHandler *commands.UpdateCartItemCommandHandler,
+	removeHandler *commands.RemoveFromCartCommandHandler,
+	clearHandler *commands.ClearCartCommandHandler,
+	mergeHandler *commands.MergeCartCommandHandler,
+	checkoutHandler *commands.CheckoutCartCommandHandler,
+	previewHandler *commands.PreviewCheckoutCommandHandler,
+	analytics analytics.Publisher,
+	sessionTTL time.Duration,
+	secureCookie bool,
+) *CartHandler {
+	return &CartHandler{
+		getHandler:      getHandler,
+		addHandler:      addHandler,
+		updateHandler:   updateHandler,
+		removeHandler:   removeHandler,
+		clearHandler:    clearHandler,
+		mergeHandler:    mergeHandler,
+		checkoutHandler: checkoutHandler,
+		previewHandler:  previewHandler,
+		analytics:       analytics,
+		sessionTTL:      sessionTTL,
+		secureCookie:    secureCookie,
+	}
+}
+
+// cartToken is the anonymous cart token the request carries, if valid
+func cartToken(c *gin.Context) string {
+	token := c.GetHeader(CartTokenHeader)
+	if token == "" {
+		token, _ = c.Cookie(cartTokenCookie)
+	}
+	if !cart.ValidToken(token) {
+		return ""
+	}
+	return token
+}
+
+// owner is whose cart the request is for. Anonymous visitors without a
+// token get a new one when issue is set.
+func (h *CartHandler) owner(c *gin.Context, issue bool) (cart.Owner, error) {
+	if userID := c.GetString("user_id"); userID != "" {
+		return cart.Owner{UserID: userID}, nil
+	}
+	token := cartToken(c)
+	if token == "" && issue {
+		var err error
+		if token, err = cart.NewToken(); err != nil {
+			return cart.Owner{}, err
+		}
+	}
+	if token != "" {
+		// Sent on every response so the cookie's expiry follows the cart's
+		c.Header(CartTokenHeader, token)
+		h.setCookie(c, token, int(h.sessionTTL.Seconds()))
+	}
+	return cart.Owner{Token: token}, nil
+}
+
+func (h *CartHandler) setCookie(c *gin.Context, token string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(cartTokenCookie, token, maxAge, "/", "", h.secureCookie, true)
+}
+
+// merge moves the visitor's anonymous cart into the account cart when they
+// sign in. A cart that fails to merge is kept for POST /cart/merge.
+func (h *CartHandler) merge(c *gin.Context, userID string) {
+	token := cartToken(c)
+	if token == "" {
+		return
+	}
+	if _, err := h.mergeHandler.Handle(c.Request.Context(), commands.MergeCartCommand{UserID: userID, Token: token}); err == nil {
+		h.setCookie(c, "", -1)
+	}
+}
+
+func (h *CartHandler) GetCart(c *gin.Context) {
+	owner, err := h.owner(c, false)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	h.respondCart(c, owner)
+}
+
+func (h *CartHandler) respondCart(c *gin.Context, owner cart.Owner) {
+	view, err := h.getHandler.Handle(c.Request.Context(), queries.GetCartQuery{Owner: owner})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, view)
+}
+
+func (h *CartHandler) AddToCart(c *gin.Context) {
+	var cmd commands.AddToCartCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	if !validateRequest(c, &cmd) {
+		return
+	}
+	owner, err := h.owner(c, true)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	cmd.Owner = owner
+
+	if _, err := h.addHandler.Handle(c.Request.Context(), cmd); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	publishProductEvent(c, h.analytics, analytics.EventProductAddedToCart, cmd.ProductID, cmd.Quantity)
+	h.respondCart(c, owner)
+}
+
+// UpdateCartItem sets the quantity of the product with the id in the cart.
+func (h *CartHandler) UpdateCartItem(c *gin.Context) {
+	var cmd commands.UpdateCartItemCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.ProductID = c.Param("id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+	owner, err := h.owner(c, false)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	cmd.Owner = owner
+
+	if _, err := h.updateHandler.Handle(c.Request.Context(), cmd); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	h.respondCart(c, owner)
+}
+
+func (h *CartHandler) RemoveFromCart(c *gin.Context) {
+	owner, err := h.owner(c, false)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	cmd := commands.RemoveFromCartCommand{Owner: owner, ProductID: c.Param("id")}
+	if _, err := h.removeHandler.Handle(c.Request.Context(), cmd); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	h.respondCart(c, owner)
+}
+
+func (h *CartHandler) ClearCart(c *gin.Context) {
+	owner, err := h.owner(c, false)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if owner.UserID != "" || owner.Token != "" {
+		if err := h.clearHandler.Handle(c.Request.Context(), commands.ClearCartCommand{Owner: owner}); err != nil {
+			respondError(c, err)
+			return
+		}
+	}
+
+	h.respondCart(c, owner)
+}
+
+// MergeCart merges the anonymous cart of the token the signed-in user sends
+// into their account cart, for clients that signed in without it.
+func (h *CartHandler) MergeCart(c *gin.Context) {
+	token := cartToken(c)
+	if token == "" {
+		respondError(c, apperror.ErrInvalidRequest.WithDetail("a valid %s is required", CartTokenHeader))
+		return
+	}
+
+	userID := c.GetString("user_id")
+	if _, err := h.mergeHandler.Handle(c.Request.Context(), commands.MergeCartCommand{UserID: userID, Token: token}); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	h.setCookie(c, "", -1)
+	h.respondCart(c, cart.Owner{UserID: userID})
+}
+
+// Checkout places an order for the signed-in user's cart and empties it.
+func (h *CartHandler) Checkout(c *gin.Context) {
+	var cmd commands.CheckoutCartCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.UserID = c.GetString("user_id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	order, err := h.checkoutHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	publishPurchaseEvents(c, h.analytics, order)
+
+	respond(c, http.StatusCreated, order)
+}
+
+// PreviewCheckout prices the items, or the signed-in user's cart when there
+// are none, with the totals an order for them would be placed at.
+func (h *CartHandler) PreviewCheckout(c *gin.Context) {
+	var cmd commands.PreviewCheckoutCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.UserID = c.GetString("user_id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	preview, err := h.previewHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, preview)
+}
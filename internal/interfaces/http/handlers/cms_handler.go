@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/cms"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// CMSAssetPath is where content links to uploaded images
+	CMSAssetPath = "/api/v1/cms/assets/"
+	// contentMaxAge lets browsers and CDNs keep public content briefly
+	contentMaxAge = 60
+	// assetRedirectMaxAge keeps a cached redirect well inside the signed
+	// link's lifetime
+	assetRedirectMaxAge = 600
+)
+
+// ContentHandler serves the public, cached storefront content: banners,
+// promo blocks, static pages and their images.
+type ContentHandler struct {
+	liveBannersHandler   *queries.GetLiveBannersQueryHandler
+	slotHandler          *queries.GetCMSSlotQueryHandler
+	publishedPageHandler *queries.GetPublishedPageQueryHandler
+	assetLinkHandler     *queries.GetCMSAssetLinkQueryHandler
+}
+
+func NewContentHandler(
+	liveBannersHandler *queries.GetLiveBannersQueryHandler,
+	slotHandler *queries.GetCMSSlotQueryHandler,
+	publishedPageHandler *queries.GetPublishedPageQueryHandler,
+	assetLinkHandler *queries.GetCMSAssetLinkQueryHandler,
+) *ContentHandler {
+	return &ContentHandler{
+		liveBannersHandler:   liveBannersHandler,
+		slotHandler:          slotHandler,
+		publishedPageHandler: publishedPageHandler,
+		assetLinkHandler:     assetLinkHandler,
+	}
+}
+
+func (h *ContentHandler) GetBanners(c *gin.Context) {
+	banners, err := h.liveBannersHandler.Handle(c.Request.Context(), queries.GetLiveBannersQuery{})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", contentMaxAge))
+	respond(c, http.StatusOK, banners)
+}
+
+func (h *ContentHandler) GetSlot(c *gin.Context) {
+	blocks, err := h.slotHandler.Handle(c.Request.Context(), queries.GetCMSSlotQuery{Slot: c.Param("slot")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", contentMaxAge))
+	respond(c, http.StatusOK, blocks)
+}
+
+func (h *ContentHandler) GetPage(c *gin.Context) {
+	page, err := h.publishedPageHandler.Handle(c.Request.Context(), queries.GetPublishedPageQuery{Slug: c.Param("slug")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", contentMaxAge))
+	respond(c, http.StatusOK, page)
+}
+
+// GetAsset redirects to a short-lived signed link to the image.
+func (h *ContentHandler) GetAsset(c *gin.Context) {
+	link, err := h.assetLinkHandler.Handle(c.Request.Context(), queries.GetCMSAssetLinkQuery{AssetID: c.Param("id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", assetRedirectMaxAge))
+	c.Redirect(http.StatusFound, link)
+}
+
+// CMSHandler serves the admin API for promo blocks, static pages and
+// uploaded images.
+type CMSHandler struct {
+	createBlockHandler *commands.CreateCMSBlockCommandHandler
+	updateBlockHandler *commands.UpdateCMSBlockCommandHandler
+	deleteBlockHandler *commands.DeleteCMSBlockCommandHandler
+	listBlocksHandler  *queries.ListCMSBlocksQueryHandler
+	createPageHandler  *commands.CreateCMSPageCommandHandler
+	updatePageHandler  *commands.UpdateCMSPageCommandHandler
+	deletePageHandler  *commands.DeleteCMSPageCommandHandler
+	listPagesHandler   *queries.ListCMSPagesQueryHandler
+	getPageHandler     *queries.GetCMSPageQueryHandler
+	uploadAssetHandler *commands.UploadCMSAssetCommandHandler
+	deleteAssetHandler *commands.DeleteCMSAssetCommandHandler
+	listAssetsHandler  *queries.ListCMSAssetsQueryHandler
+}
+
+func NewCMSHandler(
+	createBlockHandler *commands.CreateCMSBlockCommandHandler,
+	updateBlockHandler *commands.UpdateCMSBlockCommandHandler,
+	deleteBlockHandler *commands.DeleteCMSBlockCommandHandler,
+	listBlocksHandler *queries.ListCMSBlocksQueryHandler,
+	createPageHandler *commands.CreateCMSPageCommandHandler,
+	updatePageHandler *commands.UpdateCMSPageCommandHandler,
+	deletePageHandler *commands.DeleteCMSPageCommandHandler,
+	listPagesHandler *queries.ListCMSPagesQueryHandler,
+	getPageHandler *queries.GetCMSPageQueryHandler,
+	uploadAssetHandler *commands.UploadCMSAssetCommandHandler,
+	deleteAssetHandler *commands.DeleteCMSAssetCommandHandler,
+	listAssetsHandler *queries.ListCMSAssetsQueryHandler,
+) *CMSHandler {
+	return &CMSHandler{
+		createBlockHandler: createBlockHandler,
+		updateBlockHandler: updateBlockHandler,
+		deleteBlockHandler: deleteBlockHandler,
+		listBlocksHandler:  listBlocksHandler,
+		createPageHandler:  createPageHandler,
+		updatePageHandler:  updatePageHandler,
+		deletePageHandler:  deletePageHandler,
+		listPagesHandler:   listPagesHandler,
+		getPageHandler:     getPageHandler,
+		uploadAssetHandler: uploadAssetHandler,
+		deleteAssetHandler: deleteAssetHandler,
+		listAssetsHandler:  listAssetsHandler,
+	}
+}
+
+// ListBlocks lists every block, or one slot's with ?slot=.
+func (h *CMSHandler) ListBlocks(c *gin.Context) {
+	query := queries.ListCMSBlocksQuery{Slot: c.Query("slot")}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	blocks, err := h.listBlocksHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, blocks, page.Meta(len(blocks), nil))
+}
+
+func (h *CMSHandler) CreateBlock(c *gin.Context) {
+	var cmd commands.CreateCMSBlockCommand
+	if !bindJSON(c, &cmd) {
+		return
+	}
+
+	block, err := h.createBlockHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, block)
+}
+
+func (h *CMSHandler) UpdateBlock(c *gin.Context) {
+	var cmd commands.UpdateCMSBlockCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.BlockID = c.Param("id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	block, err := h.updateBlockHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, block)
+}
+
+func (h *CMSHandler) DeleteBlock(c *gin.Context) {
+	if err := h.deleteBlockHandler.Handle(c.Request.Context(), commands.DeleteCMSBlockCommand{BlockID: c.Param("id")}); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "Content block deleted"})
+}
+
+func (h *CMSHandler) ListPages(c *gin.Context) {
+	query := queries.ListCMSPagesQuery{}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	pages, err := h.listPagesHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, pages, page.Meta(len(pages), nil))
+}
+
+func (h *CMSHandler) GetPage(c *gin.Context) {
+	page, err := h.getPageHandler.Handle(c.Request.Context(), queries.GetCMSPageQuery{PageID: c.Param("id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, page)
+}
+
+func (h *CMSHandler) CreatePage(c *gin.Context) {
+	var cmd commands.CreateCMSPageCommand
+	if !bindJSON(c, &cmd) {
+		return
+	}
+
+	page, err := h.createPageHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, page)
+}
+
+func (h *CMSHandler) UpdatePage(c *gin.Context) {
+	var cmd commands.UpdateCMSPageCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.PageID = c.Param("id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	page, err := h.updatePageHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, page)
+}
+
+func (h *CMSHandler) DeletePage(c *gin.Context) {
+	if err := h.deletePageHandler.Handle(c.Request.Context(), commands.DeleteCMSPageCommand{PageID: c.Param("id")}); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "Page deleted"})
+}
+
+func (h *CMSHandler) ListAssets(c *gin.Context) {
+	query := queries.ListCMSAssetsQuery{}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	assets, err := h.listAssetsHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	for _, a := range assets {
+		setAssetURL(a)
+	}
+
+	respondPage(c, http.StatusOK, assets, page.Meta(len(assets), nil))
+}
+
+// UploadAsset stores the image in the multipart "file" field. The response's
+// url is what banners, blocks and pages should use as their image_url.
+func (h *CMSHandler) UploadAsset(c *gin.Context) {
+	filename, data, err := readUpload(c, "file", h.uploadAssetHandler.MaxSize())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	asset, err := h.uploadAssetHandler.Handle(c.Request.Context(), commands.UploadCMSAssetCommand{
+		Filename:   filename,
+		Data:       data,
+		UploadedBy: c.GetString("user_id"),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	setAssetURL(asset)
+
+	respond(c, http.StatusCreated, asset)
+}
+
+func (h *CMSHandler) DeleteAsset(c *gin.Context) {
+	if err := h.deleteAssetHandler.Handle(c.Request.Context(), commands.DeleteCMSAssetCommand{AssetID: c.Param("id")}); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "Asset deleted"})
+}
+
+func setAssetURL(a *cms.Asset) {
+	a.URL = CMSAssetPath + a.ID
+}
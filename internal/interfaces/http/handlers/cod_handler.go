@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"net/http"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CODHandler serves cash on delivery: customers choosing it for an order,
+// and admins reconciling the cash couriers collect.
+type CODHandler struct {
+	payOnDeliveryHandler    *commands.PayOnDeliveryCommandHandler
+	recordRemittanceHandler *commands.RecordRemittanceCommandHandler
+	setLimitHandler         *commands.SetCODLimitCommandHandler
+	listCollectionsHandler  *queries.ListCODCollectionsQueryHandler
+	listBalancesHandler     *queries.ListCourierBalancesQueryHandler
+	listRemittancesHandler  *queries.ListRemittancesQueryHandler
+	getRemittanceHandler    *queries.GetRemittanceQueryHandler
+	getCustomerHandler      *queries.GetCODCustomerQueryHandler
+}
+
+func NewCODHandler(
+	payOnDeliveryHandler *commands.PayOnDeliveryCommandHandler,
+	recordRemittanceHandler *commands.RecordRemittanceCommandHandler,
+	setLimitHandler *commands.SetCODLimitCommandHandler,
+	listCollectionsHandler *queries.ListCODCollectionsQueryHandler,
+	listBalancesHandler *queries.ListCourierBalancesQueryHandler,
+	listRemittancesHandler *queries.ListRemittancesQueryHandler,
+	getRemittanceHandler *queries.GetRemittanceQueryHandler,
+	getCustomerHandler *queries.GetCODCustomerQueryHandler,
+) *CODHandler {
+	return &CODHandler{
+		payOnDeliveryHandler:    payOnDeliveryHandler,
+		recordRemittanceHandler: recordRemittanceHandler,
+		setLimitHandler:         setLimitHandler,
+		listCollectionsHandler:  listCollectionsHandler,
+		listBalancesHandler:     listBalancesHandler,
+		listRemittancesHandler:  listRemittancesHandler,
+		getRemittanceHandler:    getRemittanceHandler,
+		getCustomerHandler:      getCustomerHandler,
+	}
+}
+
+// PayOnDelivery has the customer's pending order paid in cash when it is
+// delivered.
+func (h *CODHandler) PayOnDelivery(c *gin.Context) {
+	cmd := commands.PayOnDeliveryCommand{OrderID: c.Param("id"), UserID: c.GetString("user_id")}
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	collection, err := h.payOnDeliveryHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, collection)
+}
+
+func (h *CODHandler) ListCollections(c *gin.Context) {
+	query := queries.ListCODCollectionsQuery{Status: c.Query("status"), Carrier: c.Query("carrier")}
+	if !validateRequest(c, &query) {
+		return
+	}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	result, err := h.listCollectionsHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, result.Collections, page.Meta(len(result.Collections), &result.Total))
+}
+
+// ListCourierBalances shows the cash each courier holds and has yet to hand
+// over.
+func (h *CODHandler) ListCourierBalances(c *gin.Context) {
+	balances, err := h.listBalancesHandler.Handle(c.Request.Context(), queries.ListCourierBalancesQuery{})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, balances)
+}
+
+func (h *CODHandler) ListRemittances(c *gin.Context) {
+	query := queries.ListRemittancesQuery{Carrier: c.Query("carrier")}
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	remittances, err := h.listRemittancesHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, remittances, page.Meta(len(remittances), nil))
+}
+
+func (h *CODHandler) GetRemittance(c *gin.Context) {
+	remittance, err := h.getRemittanceHandler.Handle(c.Request.Context(), queries.GetRemittanceQuery{ID: c.Param("id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, remittance)
+}
+
+// RecordRemittance books cash a courier handed over and settles the
+// collections it pays for.
+func (h *CODHandler) RecordRemittance(c *gin.Context) {
+	var cmd commands.RecordRemittanceCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.RecordedBy = c.GetString("user_id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	remittance, err := h.recordRemittanceHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, remittance)
+}
+
+func (h *CODHandler) GetCustomer(c *gin.Context) {
+	customer, err := h.getCustomerHandler.Handle(c.Request.Context(), queries.GetCODCustomerQuery{UserID: c.Param("id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, customer)
+}
+
+func (h *CODHandler) SetCustomerLimit(c *gin.Context) {
+	var cmd commands.SetCODLimitCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.UserID = c.Param("id")
+	cmd.UpdatedBy = c.GetString("user_id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	limit, err := h.setLimitHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, limit)
+}
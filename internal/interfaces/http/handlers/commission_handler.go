@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/pkg/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CommissionHandler struct {
+	createRuleHandler *commands.CreateCommissionRuleCommandHandler
+	updateRuleHandler *commands.UpdateCommissionRuleCommandHandler
+	deleteRuleHandler *commands.DeleteCommissionRuleCommandHandler
+	listRulesHandler  *queries.ListCommissionRulesQueryHandler
+}
+
+func NewCommissionHandler(
+	createRuleHandler *commands.CreateCommissionRuleCommandHandler,
+	updateRuleHandler *commands.UpdateCommissionRuleCommandHandler,
+	deleteRuleHandler *commands.DeleteCommissionRuleCommandHandler,
+	listRulesHandler *queries.ListCommissionRulesQueryHandler,
+) *CommissionHandler {
+	return &CommissionHandler{
+		createRuleHandler: createRuleHandler,
+		updateRuleHandler: updateRuleHandler,
+		deleteRuleHandler: deleteRuleHandler,
+		listRulesHandler:  listRulesHandler,
+	}
+}
+
+func (h *CommissionHandler) ListRules(c *gin.Context) {
+	query := queries.ListCommissionRulesQuery{}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	rules, err := h.listRulesHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, rules, page.Meta(len(rules), nil))
+}
+
+func (h *CommissionHandler) CreateRule(c *gin.Context) {
+	var cmd commands.CreateCommissionRuleCommand
+	if err := c.ShouldBindJSON(&cmd); err != nil {
+		respondError(c, apperror.ErrInvalidRequest.WithDetail(err.Error()))
+		return
+	}
+
+	rule, err := h.createRuleHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, rule)
+}
+
+func (h *CommissionHandler) UpdateRule(c *gin.Context) {
+	var cmd commands.UpdateCommissionRuleCommand
+	if err := c.ShouldBindJSON(&cmd); err != nil {
+		respondError(c, apperror.ErrInvalidRequest.WithDetail(err.Error()))
+		return
+	}
+	cmd.RuleID = c.Param("id")
+
+	rule, err := h.updateRuleHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, rule)
+}
+
+func (h *CommissionHandler) DeleteRule(c *gin.Context) {
+	cmd := commands.DeleteCommissionRuleCommand{RuleID: c.Param("id")}
+
+	if err := h.deleteRuleHandler.Handle(c.Request.Context(), cmd); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "Commission rule deleted successfully"})
+}
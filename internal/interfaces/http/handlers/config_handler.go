@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+	"online-shop/pkg/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ConfigHandler struct {
+	watcher *config.Watcher
+}
+
+func NewConfigHandler(watcher *config.Watcher) *ConfigHandler {
+	return &ConfigHandler{watcher: watcher}
+}
+
+// GetConfig shows the config the service is running with, including values
+// applied by hot reloads. Passwords, keys and tokens are redacted.
+func (h *ConfigHandler) GetConfig(c *gin.Context) {
+	cfg := h.watcher.Current()
+	respond(c, http.StatusOK, gin.H{
+		"environment": cfg.Environment,
+		"files":       h.watcher.Files(),
+		"loaded_at":   h.watcher.LoadedAt(),
+		"config":      cfg.Redacted(),
+	})
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"online-shop/internal/application/commands"
+	"online-shop/pkg/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CSPReportHandler takes the Content-Security-Policy violation reports
+// browsers post to the policy's report-uri.
+type CSPReportHandler struct {
+	recordHandler *commands.RecordCSPReportCommandHandler
+}
+
+func NewCSPReportHandler(recordHandler *commands.RecordCSPReportCommandHandler) *CSPReportHandler {
+	return &CSPReportHandler{recordHandler: recordHandler}
+}
+
+// ReportViolation records the violations in the analytics pipeline. Browsers
+// send application/csp-report or application/reports+json rather than
+// application/json, so the body is read whatever its content type.
+func (h *CSPReportHandler) ReportViolation(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		respondError(c, apperror.ErrInvalidRequest.WithDetail(err.Error()))
+		return
+	}
+
+	if _, err := h.recordHandler.Handle(c.Request.Context(), commands.RecordCSPReportCommand{
+		Body:      body,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+	"online-shop/internal/application/queries"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DashboardHandler struct {
+	getDashboardStatsHandler *queries.GetDashboardStatsQueryHandler
+}
+
+func NewDashboardHandler(getDashboardStatsHandler *queries.GetDashboardStatsQueryHandler) *DashboardHandler {
+	return &DashboardHandler{getDashboardStatsHandler: getDashboardStatsHandler}
+}
+
+func (h *DashboardHandler) GetStats(c *gin.Context) {
+	stats, err := h.getDashboardStatsHandler.Handle(c.Request.Context(), queries.GetDashboardStatsQuery{})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, stats)
+}
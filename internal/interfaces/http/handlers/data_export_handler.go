@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+	"online-shop/internal/application/commands"
+	"online-shop/pkg/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DataExportHandler struct {
+	requestDataExportHandler *commands.RequestDataExportCommandHandler
+}
+
+func NewDataExportHandler(requestDataExportHandler *commands.RequestDataExportCommandHandler) *DataExportHandler {
+	return &DataExportHandler{
+		requestDataExportHandler: requestDataExportHandler,
+	}
+}
+
+// RequestDataExport queues an archive of the caller's personal data. The
+// download link is sent by email once the archive has been built.
+func (h *DataExportHandler) RequestDataExport(c *gin.Context) {
+	var cmd commands.RequestDataExportCommand
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&cmd); err != nil {
+			respondError(c, apperror.ErrInvalidRequest.WithDetail(err.Error()))
+			return
+		}
+	}
+	cmd.UserID = c.GetString("user_id")
+
+	e, err := h.requestDataExportHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusAccepted, e)
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"online-shop/internal/infrastructure/storage"
+	"online-shop/pkg/apperror"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DownloadHandler serves files kept by the local export store. The signed
+// query string is the only credential, so the route sits outside auth.
+type DownloadHandler struct {
+	store *storage.LocalStore
+}
+
+func NewDownloadHandler(store *storage.LocalStore) *DownloadHandler {
+	return &DownloadHandler{store: store}
+}
+
+func (h *DownloadHandler) Download(c *gin.Context) {
+	if h.store == nil {
+		respondError(c, apperror.ErrNotFound.WithDetail("File not found"))
+		return
+	}
+
+	path, err := h.store.Open(c.Param("key"), c.Query("expires"), c.Query("signature"))
+	if err != nil {
+		respondError(c, ErrDownloadLinkInvalid.Wrap(err))
+		return
+	}
+
+	c.FileAttachment(path, filepath.Base(path))
+}
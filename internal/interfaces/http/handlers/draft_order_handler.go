@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/draftorder"
+	"online-shop/internal/domain/payment"
+	"online-shop/internal/interfaces/http/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DraftOrderHandler lets admins and merchants draft orders for customers,
+// who pay them through a link before they become orders.
+type DraftOrderHandler struct {
+	createHandler  *commands.CreateDraftOrderCommandHandler
+	sendHandler    *commands.SendDraftOrderCommandHandler
+	cancelHandler  *commands.CancelDraftOrderCommandHandler
+	payHandler     *commands.PayDraftOrderCommandHandler
+	listHandler    *queries.ListDraftOrdersQueryHandler
+	getHandler     *queries.GetDraftOrderQueryHandler
+	summaryHandler *queries.GetDraftOrderSummaryQueryHandler
+}
+
+func NewDraftOrderHandler(
+	createHandler *commands.CreateDraftOrderCommandHandler,
+	sendHandler *commands.SendDraftOrderCommandHandler,
+	cancelHandler *commands.CancelDraftOrderCommandHandler,
+	payHandler *commands.PayDraftOrderCommandHandler,
+	listHandler *queries.ListDraftOrdersQueryHandler,
+	getHandler *queries.GetDraftOrderQueryHandler,
+	summaryHandler *queries.GetDraftOrderSummaryQueryHandler,
+) *DraftOrderHandler {
+	return &DraftOrderHandler{
+		createHandler:  createHandler,
+		sendHandler:    sendHandler,
+		cancelHandler:  cancelHandler,
+		payHandler:     payHandler,
+		listHandler:    listHandler,
+		getHandler:     getHandler,
+		summaryHandler: summaryHandler,
+	}
+}
+
+// DraftOrderPayment is the payment started through a draft's link, without
+// what only staff see of it.
+type DraftOrderPayment struct {
+	PaymentID  string         `json:"payment_id"`
+	Method     payment.Method `json:"method"`
+	Amount     float64        `json:"amount"`
+	PaymentURL string         `json:"payment_url"`
+	ExpiresAt  time.Time      `json:"expires_at"`
+}
+
+func (h *DraftOrderHandler) CreateDraft(c *gin.Context) {
+	var cmd commands.CreateDraftOrderCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.ActorID = c.GetString("user_id")
+	cmd.ActorRole = c.GetString("user_role")
+	cmd.RequestID = middleware.GetRequestID(c)
+	cmd.MerchantID = webhookScope(c)
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	d, err := h.createHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, d)
+}
+
+// ListDrafts lists the merchant's drafts, or every merchant's for an
+// admin, optionally in one ?status=.
+func (h *DraftOrderHandler) ListDrafts(c *gin.Context) {
+	query := queries.ListDraftOrdersQuery{MerchantID: webhookScope(c), Status: draftorder.Status(c.Query("status"))}
+	if !validateRequest(c, &query) {
+		return
+	}
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	drafts, err := h.listHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, drafts, page.Meta(len(drafts), nil))
+}
+
+func (h *DraftOrderHandler) GetDraft(c *gin.Context) {
+	d, err := h.getHandler.Handle(c.Request.Context(), queries.GetDraftOrderQuery{DraftOrderID: c.Param("id"), MerchantID: webhookScope(c)})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, d)
+}
+
+// SendDraft emails the customer the draft's payment link.
+func (h *DraftOrderHandler) SendDraft(c *gin.Context) {
+	d, err := h.sendHandler.Handle(c.Request.Context(), commands.SendDraftOrderCommand{DraftOrderID: c.Param("id"), MerchantID: webhookScope(c)})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, d)
+}
+
+func (h *DraftOrderHandler) CancelDraft(c *gin.Context) {
+	d, err := h.cancelHandler.Handle(c.Request.Context(), commands.CancelDraftOrderCommand{DraftOrderID: c.Param("id"), MerchantID: webhookScope(c)})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, d)
+}
+
+// GetSummary is what the draft's payment link opens. Browsers asking for
+// HTML get the page the API hosts, whose form pays the draft; anything else
+// gets the summary as JSON, for a storefront page of its own.
+func (h *DraftOrderHandler) GetSummary(c *gin.Context) {
+	html := c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML
+	summary, err := h.summaryHandler.Handle(c.Request.Context(), queries.GetDraftOrderSummaryQuery{
+		Token:  c.Param("token"),
+		Locale: middleware.LocaleOf(c),
+		HTML:   html,
+		PayURL: c.Request.URL.Path + "/pay",
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "private, no-store")
+	if html {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(summary.HTML))
+		return
+	}
+	respond(c, http.StatusOK, summary)
+}
+
+// Pay starts paying the draft with the chosen method. The hosted page's
+// form is redirected to the payment provider; JSON requests are answered
+// with the payment's link.
+func (h *DraftOrderHandler) Pay(c *gin.Context) {
+	var cmd commands.PayDraftOrderCommand
+	form := c.ContentType() == gin.MIMEPOSTForm
+	if form {
+		cmd.Method = payment.Method(c.PostForm("method"))
+	} else if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.Token = c.Param("token")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	pay, err := h.payHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if form {
+		c.Redirect(http.StatusSeeOther, pay.PaymentURL)
+		return
+	}
+	respond(c, http.StatusCreated, DraftOrderPayment{
+		PaymentID:  pay.ID,
+		Method:     pay.Method,
+		Amount:     pay.Amount,
+		PaymentURL: pay.PaymentURL,
+		ExpiresAt:  pay.ExpiresAt,
+	})
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/pkg/apperror"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxWebhookBody caps provider notifications; SendGrid batches are the
+// largest
+const maxWebhookBody = 1 << 20
+
+// EmailDeliveryHandler receives the providers' bounce and complaint webhooks
+// and serves the admin API for the suppression list, dead letters and
+// campaigns.
+type EmailDeliveryHandler struct {
+	ingestEventsHandler      *commands.IngestEmailEventsCommandHandler
+	addSuppressionHandler    *commands.AddEmailSuppressionCommandHandler
+	removeSuppressionHandler *commands.RemoveEmailSuppressionCommandHandler
+	listSuppressionsHandler  *queries.ListEmailSuppressionsQueryHandler
+	getSuppressionHandler    *queries.GetEmailSuppressionQueryHandler
+	resendDeadLetterHandler  *commands.ResendEmailDeadLetterCommandHandler
+	listDeadLettersHandler   *queries.ListEmailDeadLettersQueryHandler
+	getDeadLetterHandler     *queries.GetEmailDeadLetterQueryHandler
+	sendCampaignHandler      *commands.SendEmailCampaignCommandHandler
+}
+
+func NewEmailDeliveryHandler(
+	ingestEventsHandler *commands.IngestEmailEventsCommandHandler,
+	addSuppressionHandler *commands.AddEmailSuppressionCommandHandler,
+	removeSuppressionHandler *commands.RemoveEmailSuppressionCommandHandler,
+	listSuppressionsHandler *queries.ListEmailSuppressionsQueryHandler,
+	getSuppressionHandler *queries.GetEmailSuppressionQueryHandler,
+	resendDeadLetterHandler *commands.ResendEmailDeadLetterCommandHandler,
+	listDeadLettersHandler *queries.ListEmailDeadLettersQueryHandler,
+	getDeadLetterHandler *queries.GetEmailDeadLetterQueryHandler,
+	sendCampaignHandler *commands.SendEmailCampaignCommandHandler,
+) *EmailDeliveryHandler {
+	return &EmailDeliveryHandler{
+		ingestEventsHandler:      ingestEventsHandler,
+		addSuppressionHandler:    addSuppressionHandler,
+		removeSuppressionHandler: removeSuppressionHandler,
+		listSuppressionsHandler:  listSuppressionsHandler,
+		getSuppressionHandler:    getSuppressionHandler,
+		resendDeadLetterHandler:  resendDeadLetterHandler,
+		listDeadLettersHandler:   listDeadLettersHandler,
+		getDeadLetterHandler:     getDeadLetterHandler,
+		sendCampaignHandler:      sendCampaignHandler,
+	}
+}
+
+// IngestEvents handles a provider's webhook. It is not authenticated; the
+// provider's signature is verified instead.
+func (h *EmailDeliveryHandler) IngestEvents(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBody))
+	if err != nil {
+		respondError(c, apperror.ErrInvalidRequest.WithDetail(err.Error()))
+		return
+	}
+
+	result, err := h.ingestEventsHandler.Handle(c.Request.Context(), commands.IngestEmailEventsCommand{
+		Provider: c.Param("provider"),
+		Header:   c.Request.Header,
+		Body:     body,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, result)
+}
+
+func (h *EmailDeliveryHandler) ListSuppressions(c *gin.Context) {
+	query := queries.ListEmailSuppressionsQuery{}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	suppressions, err := h.listSuppressionsHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, suppressions, page.Meta(len(suppressions), nil))
+}
+
+// GetSuppression tells support why an address gets no email.
+func (h *EmailDeliveryHandler) GetSuppression(c *gin.Context) {
+	suppression, err := h.getSuppressionHandler.Handle(c.Request.Context(), queries.GetEmailSuppressionQuery{Email: c.Param("email")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, suppression)
+}
+
+func (h *EmailDeliveryHandler) AddSuppression(c *gin.Context) {
+	var cmd commands.AddEmailSuppressionCommand
+	if !bindJSON(c, &cmd) {
+		return
+	}
+
+	suppression, err := h.addSuppressionHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, suppression)
+}
+
+func (h *EmailDeliveryHandler) RemoveSuppression(c *gin.Context) {
+	if err := h.removeSuppressionHandler.Handle(c.Request.Context(), commands.RemoveEmailSuppressionCommand{Email: c.Param("email")}); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "Address removed from the suppression list"})
+}
+
+// ListDeadLetters lists the emails the worker gave up on; ?pending=true
+// leaves out the ones already resent.
+func (h *EmailDeliveryHandler) ListDeadLetters(c *gin.Context) {
+	query := queries.ListEmailDeadLettersQuery{}
+	if v := c.Query("pending"); v != "" {
+		pending, err := strconv.ParseBool(v)
+		if err != nil {
+			respondError(c, apperror.ErrInvalidRequest.WithDetail("pending must be true or false"))
+			return
+		}
+		query.Pending = pending
+	}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	deadLetters, err := h.listDeadLettersHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, deadLetters, page.Meta(len(deadLetters), nil))
+}
+
+func (h *EmailDeliveryHandler) GetDeadLetter(c *gin.Context) {
+	deadLetter, err := h.getDeadLetterHandler.Handle(c.Request.Context(), queries.GetEmailDeadLetterQuery{ID: c.Param("id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, deadLetter)
+}
+
+// ResendDeadLetter queues the email again; the response is 202 as it is
+// only sent once the worker picks it up.
+func (h *EmailDeliveryHandler) ResendDeadLetter(c *gin.Context) {
+	deadLetter, err := h.resendDeadLetterHandler.Handle(c.Request.Context(), commands.ResendEmailDeadLetterCommand{ID: c.Param("id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusAccepted, deadLetter)
+}
+
+func (h *EmailDeliveryHandler) SendCampaign(c *gin.Context) {
+	var cmd commands.SendEmailCampaignCommand
+	if !bindJSON(c, &cmd) {
+		return
+	}
+
+	campaign, err := h.sendCampaignHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusAccepted, campaign)
+}
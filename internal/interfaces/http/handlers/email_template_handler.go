@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"net/http"
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/i18n"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EmailTemplateHandler serves the admin API for the templates the email
+// worker sends. Routes act on one locale of a template, given by the locale
+// query parameter or field and defaulting to the default locale.
+type EmailTemplateHandler struct {
+	saveTemplateHandler     *commands.SaveEmailTemplateCommandHandler
+	activateTemplateHandler *commands.ActivateEmailTemplateCommandHandler
+	deleteTemplateHandler   *commands.DeleteEmailTemplateCommandHandler
+	sendTestEmailHandler    *commands.SendTestEmailCommandHandler
+	listTemplatesHandler    *queries.ListEmailTemplatesQueryHandler
+	getTemplateHandler      *queries.GetEmailTemplateQueryHandler
+	listVersionsHandler     *queries.ListEmailTemplateVersionsQueryHandler
+	previewTemplateHandler  *queries.PreviewEmailTemplateQueryHandler
+}
+
+func NewEmailTemplateHandler(
+	saveTemplateHandler *commands.SaveEmailTemplateCommandHandler,
+	activateTemplateHandler *commands.ActivateEmailTemplateCommandHandler,
+	deleteTemplateHandler *commands.DeleteEmailTemplateCommandHandler,
+	sendTestEmailHandler *commands.SendTestEmailCommandHandler,
+	listTemplatesHandler *queries.ListEmailTemplatesQueryHandler,
+	getTemplateHandler *queries.GetEmailTemplateQueryHandler,
+	listVersionsHandler *queries.ListEmailTemplateVersionsQueryHandler,
+	previewTemplateHandler *queries.PreviewEmailTemplateQueryHandler,
+) *EmailTemplateHandler {
+	return &EmailTemplateHandler{
+		saveTemplateHandler:     saveTemplateHandler,
+		activateTemplateHandler: activateTemplateHandler,
+		deleteTemplateHandler:   deleteTemplateHandler,
+		sendTestEmailHandler:    sendTestEmailHandler,
+		listTemplatesHandler:    listTemplatesHandler,
+		getTemplateHandler:      getTemplateHandler,
+		listVersionsHandler:     listVersionsHandler,
+		previewTemplateHandler:  previewTemplateHandler,
+	}
+}
+
+// ListTemplates lists the active version of every stored template.
+// Templates that were never edited use their embedded default and are not
+// listed.
+func (h *EmailTemplateHandler) ListTemplates(c *gin.Context) {
+	query := queries.ListEmailTemplatesQuery{}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	templates, err := h.listTemplatesHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, templates, page.Meta(len(templates), nil))
+}
+
+// GetTemplate returns the active version, or the one in the version query
+// parameter. Version 0 is the embedded default.
+func (h *EmailTemplateHandler) GetTemplate(c *gin.Context) {
+	locale, ok := localeQuery(c)
+	if !ok {
+		return
+	}
+	query := queries.GetEmailTemplateQuery{Name: c.Param("name"), Locale: locale}
+	if v := c.Query("version"); v != "" {
+		version, err := strconv.Atoi(v)
+		if err != nil || version < 1 {
+			respondError(c, apperror.ErrInvalidRequest.WithDetail("version must be a positive integer"))
+			return
+		}
+		query.Version = version
+	}
+
+	t, err := h.getTemplateHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, t)
+}
+
+func (h *EmailTemplateHandler) ListVersions(c *gin.Context) {
+	locale, ok := localeQuery(c)
+	if !ok {
+		return
+	}
+
+	versions, err := h.listVersionsHandler.Handle(c.Request.Context(), queries.ListEmailTemplateVersionsQuery{
+		Name:   c.Param("name"),
+		Locale: locale,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, versions)
+}
+
+// SaveTemplate stores a new version and makes it active straight away.
+func (h *EmailTemplateHandler) SaveTemplate(c *gin.Context) {
+	var cmd commands.SaveEmailTemplateCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.Name = c.Param("name")
+	cmd.ActorID = c.GetString("user_id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	t, err := h.saveTemplateHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, t)
+}
+
+// ActivateVersion rolls the template back, or forward, to a stored version.
+func (h *EmailTemplateHandler) ActivateVersion(c *gin.Context) {
+	locale, ok := localeQuery(c)
+	if !ok {
+		return
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		respondError(c, apperror.ErrInvalidRequest.WithDetail("version must be a positive integer"))
+		return
+	}
+
+	cmd := commands.ActivateEmailTemplateCommand{Name: c.Param("name"), Locale: locale, Version: version}
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	t, err := h.activateTemplateHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, t)
+}
+
+// DeleteTemplate removes every stored version of the locale, so the
+// embedded default is sent again.
+func (h *EmailTemplateHandler) DeleteTemplate(c *gin.Context) {
+	locale, ok := localeQuery(c)
+	if !ok {
+		return
+	}
+
+	if err := h.deleteTemplateHandler.Handle(c.Request.Context(), commands.DeleteEmailTemplateCommand{Name: c.Param("name"), Locale: locale}); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "Email template deleted, the default is used again"})
+}
+
+// PreviewTemplate renders a stored version, or a draft sent in the body,
+// without sending anything.
+func (h *EmailTemplateHandler) PreviewTemplate(c *gin.Context) {
+	var query queries.PreviewEmailTemplateQuery
+	if c.Request.ContentLength > 0 && !decodeJSON(c, &query) {
+		return
+	}
+	query.Name = c.Param("name")
+	if !validateRequest(c, &query) {
+		return
+	}
+
+	preview, err := h.previewTemplateHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, preview)
+}
+
+// SendTestEmail queues the template to an address, usually the admin's own.
+func (h *EmailTemplateHandler) SendTestEmail(c *gin.Context) {
+	var cmd commands.SendTestEmailCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.Name = c.Param("name")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	t, err := h.sendTestEmailHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusAccepted, gin.H{"message": "Test email queued", "to": cmd.To, "version": t.Version})
+}
+
+// localeQuery reads the locale query parameter, responding with an error
+// and returning false when it is not supported.
+func localeQuery(c *gin.Context) (string, bool) {
+	locale := c.Query("locale")
+	if locale != "" && !i18n.Supported(locale) {
+		respondError(c, commands.ErrUnsupportedLocale.WithMeta("locale", locale))
+		return "", false
+	}
+	return locale, true
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+	"online-shop/pkg/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorCatalogHandler publishes the error codes the API can return. Problem
+// documents link here through their type.
+type ErrorCatalogHandler struct{}
+
+func NewErrorCatalogHandler() *ErrorCatalogHandler {
+	return &ErrorCatalogHandler{}
+}
+
+func (h *ErrorCatalogHandler) ListErrors(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=3600")
+	respond(c, http.StatusOK, apperror.Describe())
+}
+
+func (h *ErrorCatalogHandler) GetError(c *gin.Context) {
+	e, ok := apperror.Lookup(c.Param("code"))
+	if !ok {
+		respondError(c, apperror.ErrNotFound.WithDetail("unknown error code %q", c.Param("code")))
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=3600")
+	respond(c, http.StatusOK, e.Entry())
+}
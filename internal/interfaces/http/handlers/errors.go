@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"online-shop/internal/interfaces/http/middleware"
+	"online-shop/pkg/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Request errors raised by the handlers themselves
+var (
+	ErrTokenGeneration        = apperror.Define(apperror.KindInternal, "token_generation_failed", "Failed to generate token")
+	ErrOAuthDenied            = apperror.Define(apperror.KindUnauthenticated, "oauth_denied", "the provider did not authorize the login")
+	ErrOAuthProviderFailed    = apperror.Define(apperror.KindUnavailable, "oauth_provider_failed", "Failed to complete login")
+	ErrHomeSectionUnavailable = apperror.Define(apperror.KindUnavailable, "home_section_unavailable", "this section of the home page is unavailable")
+	ErrDownloadLinkInvalid    = apperror.Define(apperror.KindPermissionDenied, "download_link_invalid", "download link is invalid or has expired")
+)
+
+// respondError writes err as a problem document. Errors without a catalog
+// code are reported as internal errors; their text only reaches the logs.
+func respondError(c *gin.Context, err error) {
+	middleware.AbortWithError(c, err)
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"net/http"
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/analytics"
+	"online-shop/internal/domain/experiment"
+	"online-shop/pkg/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TrackExperimentRequest is the optional body of an exposure or conversion.
+// The value, such as an order total, is recorded with the event.
+type TrackExperimentRequest struct {
+	Value float64 `json:"value"`
+}
+
+type ExperimentHandler struct {
+	createExperimentHandler    *commands.CreateExperimentCommandHandler
+	updateExperimentHandler    *commands.UpdateExperimentCommandHandler
+	trackExperimentHandler     *commands.TrackExperimentCommandHandler
+	listExperimentsHandler     *queries.ListExperimentsQueryHandler
+	getAssignmentsHandler      *queries.GetAssignmentsQueryHandler
+	getExperimentReportHandler *queries.GetExperimentReportQueryHandler
+}
+
+func NewExperimentHandler(
+	createExperimentHandler *commands.CreateExperimentCommandHandler,
+	updateExperimentHandler *commands.UpdateExperimentCommandHandler,
+	trackExperimentHandler *commands.TrackExperimentCommandHandler,
+	listExperimentsHandler *queries.ListExperimentsQueryHandler,
+	getAssignmentsHandler *queries.GetAssignmentsQueryHandler,
+	getExperimentReportHandler *queries.GetExperimentReportQueryHandler,
+) *ExperimentHandler {
+	return &ExperimentHandler{
+		createExperimentHandler:    createExperimentHandler,
+		updateExperimentHandler:    updateExperimentHandler,
+		trackExperimentHandler:     trackExperimentHandler,
+		listExperimentsHandler:     listExperimentsHandler,
+		getAssignmentsHandler:      getAssignmentsHandler,
+		getExperimentReportHandler: getExperimentReportHandler,
+	}
+}
+
+// GetAssignments returns the caller's variant in each running experiment.
+// Anonymous visitors are identified by the X-Session-ID header.
+func (h *ExperimentHandler) GetAssignments(c *gin.Context) {
+	query := queries.GetAssignmentsQuery{
+		UserID:    c.GetString("user_id"),
+		SessionID: c.GetHeader(SessionIDHeader),
+	}
+	if experiment.Unit(query.UserID, query.SessionID) == "" {
+		respondError(c, commands.ErrExperimentVisitorUnknown)
+		return
+	}
+
+	assignments, err := h.getAssignmentsHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, assignments)
+}
+
+func (h *ExperimentHandler) TrackExposure(c *gin.Context) {
+	h.track(c, analytics.EventExperimentExposure)
+}
+
+func (h *ExperimentHandler) TrackConversion(c *gin.Context) {
+	h.track(c, analytics.EventExperimentConversion)
+}
+
+func (h *ExperimentHandler) track(c *gin.Context, event string) {
+	var body TrackExperimentRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			respondError(c, apperror.ErrInvalidRequest.WithDetail(err.Error()))
+			return
+		}
+	}
+
+	assignment, err := h.trackExperimentHandler.Handle(c.Request.Context(), commands.TrackExperimentCommand{
+		ExperimentKey: c.Param("key"),
+		UserID:        c.GetString("user_id"),
+		SessionID:     c.GetHeader(SessionIDHeader),
+		Event:         event,
+		Value:         body.Value,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusAccepted, assignment)
+}
+
+func (h *ExperimentHandler) ListExperiments(c *gin.Context) {
+	query := queries.ListExperimentsQuery{Status: c.Query("status")}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	experiments, err := h.listExperimentsHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, experiments, page.Meta(len(experiments), nil))
+}
+
+func (h *ExperimentHandler) CreateExperiment(c *gin.Context) {
+	var cmd commands.CreateExperimentCommand
+	if err := c.ShouldBindJSON(&cmd); err != nil {
+		respondError(c, apperror.ErrInvalidRequest.WithDetail(err.Error()))
+		return
+	}
+
+	e, err := h.createExperimentHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, e)
+}
+
+func (h *ExperimentHandler) UpdateExperiment(c *gin.Context) {
+	var cmd commands.UpdateExperimentCommand
+	if err := c.ShouldBindJSON(&cmd); err != nil {
+		respondError(c, apperror.ErrInvalidRequest.WithDetail(err.Error()))
+		return
+	}
+	cmd.ExperimentID = c.Param("id")
+
+	e, err := h.updateExperimentHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, e)
+}
+
+func (h *ExperimentHandler) GetExperimentReport(c *gin.Context) {
+	rng, err := parseReportRange(c)
+	if err != nil {
+		respondError(c, apperror.ErrInvalidRequest.WithDetail(err.Error()))
+		return
+	}
+
+	report, err := h.getExperimentReportHandler.Handle(c.Request.Context(), queries.GetExperimentReportQuery{
+		ExperimentID: c.Param("id"),
+		Range:        rng,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, report)
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/pkg/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ExportHandler struct {
+	requestExportHandler *commands.RequestExportCommandHandler
+	listExportsHandler   *queries.ListExportsQueryHandler
+	getExportHandler     *queries.GetExportQueryHandler
+}
+
+func NewExportHandler(
+	requestExportHandler *commands.RequestExportCommandHandler,
+	listExportsHandler *queries.ListExportsQueryHandler,
+	getExportHandler *queries.GetExportQueryHandler,
+) *ExportHandler {
+	return &ExportHandler{
+		requestExportHandler: requestExportHandler,
+		listExportsHandler:   listExportsHandler,
+		getExportHandler:     getExportHandler,
+	}
+}
+
+func (h *ExportHandler) RequestExport(c *gin.Context) {
+	var cmd commands.RequestExportCommand
+	if err := c.ShouldBindJSON(&cmd); err != nil {
+		respondError(c, apperror.ErrInvalidRequest.WithDetail(err.Error()))
+		return
+	}
+	cmd.RequestedBy = c.GetString("user_id")
+
+	export, err := h.requestExportHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusAccepted, export)
+}
+
+func (h *ExportHandler) ListExports(c *gin.Context) {
+	query := queries.ListExportsQuery{RequestedBy: c.GetString("user_id")}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	exports, err := h.listExportsHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, exports, page.Meta(len(exports), nil))
+}
+
+func (h *ExportHandler) GetExport(c *gin.Context) {
+	export, err := h.getExportHandler.Handle(c.Request.Context(), queries.GetExportQuery{
+		ExportID:    c.Param("id"),
+		RequestedBy: c.GetString("user_id"),
+	})
+	if err != nil {
+		respondError(c, commands.ErrExportNotFound)
+		return
+	}
+
+	respond(c, http.StatusOK, export)
+}
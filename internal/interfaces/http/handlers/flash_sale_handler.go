@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/flashsale"
+	"online-shop/internal/domain/product"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FlashSaleHandler struct {
+	createSaleHandler   *commands.CreateFlashSaleCommandHandler
+	updateSaleHandler   *commands.UpdateFlashSaleCommandHandler
+	deleteSaleHandler   *commands.DeleteFlashSaleCommandHandler
+	listSalesHandler    *queries.ListFlashSalesQueryHandler
+	getSaleHandler      *queries.GetFlashSaleQueryHandler
+	currentSalesHandler *queries.GetCurrentFlashSalesQueryHandler
+	siteURL             string
+}
+
+func NewFlashSaleHandler(
+	createSaleHandler *commands.CreateFlashSaleCommandHandler,
+	updateSaleHandler *commands.UpdateFlashSaleCommandHandler,
+	deleteSaleHandler *commands.DeleteFlashSaleCommandHandler,
+	listSalesHandler *queries.ListFlashSalesQueryHandler,
+	getSaleHandler *queries.GetFlashSaleQueryHandler,
+	currentSalesHandler *queries.GetCurrentFlashSalesQueryHandler,
+	siteURL string,
+) *FlashSaleHandler {
+	return &FlashSaleHandler{
+		createSaleHandler:   createSaleHandler,
+		updateSaleHandler:   updateSaleHandler,
+		deleteSaleHandler:   deleteSaleHandler,
+		listSalesHandler:    listSalesHandler,
+		getSaleHandler:      getSaleHandler,
+		currentSalesHandler: currentSalesHandler,
+		siteURL:             siteURL,
+	}
+}
+
+// GetCurrentSales lists the live and upcoming sales with their countdowns.
+func (h *FlashSaleHandler) GetCurrentSales(c *gin.Context) {
+	query := queries.GetCurrentFlashSalesQuery{}
+	if limit := c.Query("limit"); limit != "" {
+		if l, err := strconv.Atoi(limit); err == nil {
+			query.Limit = l
+		}
+	}
+
+	sales, err := h.currentSalesHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	for _, s := range sales {
+		h.applySEODefaults(s)
+	}
+
+	respond(c, http.StatusOK, sales)
+}
+
+func (h *FlashSaleHandler) GetPublicSale(c *gin.Context) {
+	sale, err := h.getSaleHandler.Handle(c.Request.Context(), queries.GetFlashSaleQuery{SaleID: c.Param("id"), ActiveOnly: true})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	h.applySEODefaults(sale)
+
+	respond(c, http.StatusOK, sale)
+}
+
+func (h *FlashSaleHandler) ListSales(c *gin.Context) {
+	query := queries.ListFlashSalesQuery{}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	sales, err := h.listSalesHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, sales, page.Meta(len(sales), nil))
+}
+
+// GetSale shows a sale as configured, with what each item has sold.
+func (h *FlashSaleHandler) GetSale(c *gin.Context) {
+	sale, err := h.getSaleHandler.Handle(c.Request.Context(), queries.GetFlashSaleQuery{SaleID: c.Param("id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, sale)
+}
+
+func (h *FlashSaleHandler) CreateSale(c *gin.Context) {
+	var cmd commands.CreateFlashSaleCommand
+	if !bindJSON(c, &cmd) {
+		return
+	}
+
+	sale, err := h.createSaleHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, sale)
+}
+
+func (h *FlashSaleHandler) UpdateSale(c *gin.Context) {
+	var cmd commands.UpdateFlashSaleCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.SaleID = c.Param("id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	sale, err := h.updateSaleHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, sale)
+}
+
+func (h *FlashSaleHandler) DeleteSale(c *gin.Context) {
+	if err := h.deleteSaleHandler.Handle(c.Request.Context(), commands.DeleteFlashSaleCommand{SaleID: c.Param("id")}); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "Flash sale deleted"})
+}
+
+func (h *FlashSaleHandler) applySEODefaults(s *flashsale.Sale) {
+	for _, item := range s.Items {
+		if item.Product != nil {
+			item.Product.ApplySEODefaults(h.siteURL)
+		}
+	}
+}
+
+// applyFlashSales sets the live flash sale offer on products being served.
+// When sales cannot be read the products are served at their regular price.
+func applyFlashSales(ctx context.Context, offersHandler *queries.GetFlashSaleOffersQueryHandler, products ...*product.Product) {
+	_ = offersHandler.Handle(ctx, queries.GetFlashSaleOffersQuery{Products: products})
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+
+	"online-shop/internal/application/queries"
+	"online-shop/pkg/pagination"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ForecastHandler serves the reorder suggestions of the inventory forecast.
+type ForecastHandler struct {
+	listSuggestionsHandler *queries.ListReorderSuggestionsQueryHandler
+}
+
+func NewForecastHandler(listSuggestionsHandler *queries.ListReorderSuggestionsQueryHandler) *ForecastHandler {
+	return &ForecastHandler{listSuggestionsHandler: listSuggestionsHandler}
+}
+
+func (h *ForecastHandler) ListReorderSuggestions(c *gin.Context) {
+	query := queries.ListReorderSuggestionsQuery{
+		WarehouseID: c.Query("warehouse_id"),
+		ProductID:   c.Query("product_id"),
+		Status:      c.Query("status"),
+	}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+	if !validateRequest(c, &query) {
+		return
+	}
+
+	suggestions, err := h.listSuggestionsHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, suggestions.Suggestions, page.Meta(len(suggestions.Suggestions), pagination.Total(suggestions.Total)))
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FraudHandler struct {
+	approveHandler *commands.ApproveOrderReviewCommandHandler
+	declineHandler *commands.DeclineOrderReviewCommandHandler
+	listHandler    *queries.ListFraudReviewsQueryHandler
+	getHandler     *queries.GetFraudReviewQueryHandler
+}
+
+func NewFraudHandler(
+	approveHandler *commands.ApproveOrderReviewCommandHandler,
+	declineHandler *commands.DeclineOrderReviewCommandHandler,
+	listHandler *queries.ListFraudReviewsQueryHandler,
+	getHandler *queries.GetFraudReviewQueryHandler,
+) *FraudHandler {
+	return &FraudHandler{
+		approveHandler: approveHandler,
+		declineHandler: declineHandler,
+		listHandler:    listHandler,
+		getHandler:     getHandler,
+	}
+}
+
+// ListReviews is the review queue, oldest first; ?status= lists other
+// assessments.
+func (h *FraudHandler) ListReviews(c *gin.Context) {
+	query := queries.ListFraudReviewsQuery{Status: c.Query("status")}
+	if !validateRequest(c, &query) {
+		return
+	}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	assessments, err := h.listHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, assessments, page.Meta(len(assessments), nil))
+}
+
+func (h *FraudHandler) GetReview(c *gin.Context) {
+	a, err := h.getHandler.Handle(c.Request.Context(), queries.GetFraudReviewQuery{OrderID: c.Param("id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, a)
+}
+
+func (h *FraudHandler) ApproveOrder(c *gin.Context) {
+	cmd, ok := reviewOrderCommand(c)
+	if !ok {
+		return
+	}
+
+	a, err := h.approveHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, a)
+}
+
+func (h *FraudHandler) DeclineOrder(c *gin.Context) {
+	cmd, ok := reviewOrderCommand(c)
+	if !ok {
+		return
+	}
+
+	a, err := h.declineHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, a)
+}
+
+// reviewOrderCommand reads a review decision; the note is optional, and so
+// is the body.
+func reviewOrderCommand(c *gin.Context) (commands.ReviewOrderCommand, bool) {
+	var cmd commands.ReviewOrderCommand
+	if c.Request.ContentLength > 0 && !decodeJSON(c, &cmd) {
+		return cmd, false
+	}
+	cmd.OrderID = c.Param("id")
+	cmd.ReviewerID = c.GetString("user_id")
+	return cmd, validateRequest(c, &cmd)
+}
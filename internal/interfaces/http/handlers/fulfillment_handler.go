@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"net/http"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FulfillmentHandler serves warehouse staff picking, packing and shipping
+// orders.
+type FulfillmentHandler struct {
+	generatePickListHandler *commands.GeneratePickListCommandHandler
+	packShipmentHandler     *commands.PackShipmentCommandHandler
+	shipShipmentHandler     *commands.ShipShipmentCommandHandler
+	deliverShipmentHandler  *commands.DeliverShipmentCommandHandler
+	getPickListHandler      *queries.GetPickListQueryHandler
+}
+
+func NewFulfillmentHandler(
+	generatePickListHandler *commands.GeneratePickListCommandHandler,
+	packShipmentHandler *commands.PackShipmentCommandHandler,
+	shipShipmentHandler *commands.ShipShipmentCommandHandler,
+	deliverShipmentHandler *commands.DeliverShipmentCommandHandler,
+	getPickListHandler *queries.GetPickListQueryHandler,
+) *FulfillmentHandler {
+	return &FulfillmentHandler{
+		generatePickListHandler: generatePickListHandler,
+		packShipmentHandler:     packShipmentHandler,
+		shipShipmentHandler:     shipShipmentHandler,
+		deliverShipmentHandler:  deliverShipmentHandler,
+		getPickListHandler:      getPickListHandler,
+	}
+}
+
+// GetPickList lists what the warehouse has picking and not packed yet.
+func (h *FulfillmentHandler) GetPickList(c *gin.Context) {
+	list, err := h.getPickListHandler.Handle(c.Request.Context(), queries.GetPickListQuery{WarehouseID: c.Param("id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, list)
+}
+
+// GeneratePickList starts picking the warehouse's oldest pending shipments
+// and returns the new list; the body is optional.
+func (h *FulfillmentHandler) GeneratePickList(c *gin.Context) {
+	var cmd commands.GeneratePickListCommand
+	if c.Request.ContentLength > 0 && !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.WarehouseID = c.Param("id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	list, err := h.generatePickListHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, list)
+}
+
+func (h *FulfillmentHandler) PackShipment(c *gin.Context) {
+	var cmd commands.PackShipmentCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.ShipmentID = c.Param("id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	shipment, err := h.packShipmentHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, shipment)
+}
+
+// ShipShipment prints the label of a packed shipment, or takes the carrier
+// and tracking number of one printed elsewhere, and marks it shipped.
+func (h *FulfillmentHandler) ShipShipment(c *gin.Context) {
+	var cmd commands.ShipShipmentCommand
+	if c.Request.ContentLength > 0 && !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.ShipmentID = c.Param("id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	shipment, err := h.shipShipmentHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, shipment)
+}
+
+// DeliverShipment records the courier's proof of delivery, with the cash
+// collected for an order paid on delivery.
+func (h *FulfillmentHandler) DeliverShipment(c *gin.Context) {
+	var cmd commands.DeliverShipmentCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.ShipmentID = c.Param("id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	shipment, err := h.deliverShipmentHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, shipment)
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/product"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HomeHandler struct {
+	getHomeFeedHandler *queries.GetHomeFeedQueryHandler
+	siteURL            string
+}
+
+func NewHomeHandler(getHomeFeedHandler *queries.GetHomeFeedQueryHandler, siteURL string) *HomeHandler {
+	return &HomeHandler{
+		getHomeFeedHandler: getHomeFeedHandler,
+		siteURL:            siteURL,
+	}
+}
+
+func (h *HomeHandler) GetHome(c *gin.Context) {
+	feed, err := h.getHomeFeedHandler.Handle(c.Request.Context(), queries.GetHomeFeedQuery{
+		UserID:    c.GetString("user_id"),
+		SessionID: c.GetHeader(SessionIDHeader),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	for _, section := range [][]*product.Product{feed.Featured, feed.Trending, feed.RecentlyViewed, feed.Recommended} {
+		for _, p := range section {
+			p.ApplySEODefaults(h.siteURL)
+		}
+	}
+
+	// Sections that could not be loaded are reported next to the feed so
+	// clients can hide or retry them
+	var unavailable []error
+	for _, section := range feed.Unavailable {
+		unavailable = append(unavailable, ErrHomeSectionUnavailable.WithMeta("section", section))
+	}
+	c.JSON(http.StatusOK, Envelope{Data: feed, Errors: problems(c, unavailable...), Impersonation: impersonationBanner(c)})
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/impersonation"
+	"online-shop/internal/domain/user"
+	"online-shop/internal/interfaces/http/middleware"
+	"online-shop/pkg/jwt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImpersonationHandler lets support admins sign in as a customer to see and
+// fix what the customer sees.
+type ImpersonationHandler struct {
+	startHandler *commands.StartImpersonationCommandHandler
+	endHandler   *commands.EndImpersonationCommandHandler
+	listHandler  *queries.ListImpersonationsQueryHandler
+	jwtManager   *jwt.JWTManager
+}
+
+func NewImpersonationHandler(
+	startHandler *commands.StartImpersonationCommandHandler,
+	endHandler *commands.EndImpersonationCommandHandler,
+	listHandler *queries.ListImpersonationsQueryHandler,
+	jwtManager *jwt.JWTManager,
+) *ImpersonationHandler {
+	return &ImpersonationHandler{
+		startHandler: startHandler,
+		endHandler:   endHandler,
+		listHandler:  listHandler,
+		jwtManager:   jwtManager,
+	}
+}
+
+// ImpersonationToken is the access token an admin acts as the customer
+// with. It cannot be refreshed and stops working when the impersonation
+// ends.
+type ImpersonationToken struct {
+	Token         string               `json:"token"`
+	ExpiresIn     int64                `json:"expires_in"`
+	Impersonation *impersonation.Grant `json:"impersonation"`
+	Customer      *user.User           `json:"customer"`
+}
+
+// ImpersonationBanner is added to every response to an impersonated
+// request, so clients can show that an admin is acting as the customer.
+type ImpersonationBanner struct {
+	ID         string              `json:"id"`
+	AdminID    string              `json:"admin_id"`
+	CustomerID string              `json:"customer_id"`
+	Scope      impersonation.Scope `json:"scope"`
+	ExpiresAt  time.Time           `json:"expires_at"`
+}
+
+// impersonationBanner is nil unless the request is impersonated
+func impersonationBanner(c *gin.Context) *ImpersonationBanner {
+	grant, ok := middleware.Impersonation(c)
+	if !ok {
+		return nil
+	}
+	return &ImpersonationBanner{
+		ID:         grant.ID,
+		AdminID:    grant.AdminID,
+		CustomerID: grant.CustomerID,
+		Scope:      grant.Scope,
+		ExpiresAt:  grant.ExpiresAt,
+	}
+}
+
+// StartImpersonation issues the admin a token for the customer. The reason
+// is required and recorded with the impersonation.
+func (h *ImpersonationHandler) StartImpersonation(c *gin.Context) {
+	var cmd commands.StartImpersonationCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.AdminID = c.GetString("user_id")
+	cmd.AdminRole = c.GetString("user_role")
+	cmd.CustomerID = c.Param("id")
+	cmd.RequestID = middleware.GetRequestID(c)
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	started, err := h.startHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	grant, customer := started.Grant, started.Customer
+	token, err := h.jwtManager.GenerateImpersonationToken(customer.ID, customer.Email, string(customer.Role), jwt.Actor{
+		Subject: grant.AdminID,
+		GrantID: grant.ID,
+		Scope:   string(grant.Scope),
+	}, grant.ExpiresAt)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, ImpersonationToken{
+		Token:         token,
+		ExpiresIn:     int64(time.Until(grant.ExpiresAt).Seconds()),
+		Impersonation: grant,
+		Customer:      customer,
+	})
+}
+
+func (h *ImpersonationHandler) ListImpersonations(c *gin.Context) {
+	query := queries.ListImpersonationsQuery{
+		AdminID:    c.Query("admin_id"),
+		CustomerID: c.Query("customer_id"),
+		Active:     c.Query("active") == "true",
+	}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	result, err := h.listHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, result.Grants, page.Meta(len(result.Grants), &result.Total))
+}
+
+// EndImpersonation stops an impersonation early; its token is refused from
+// the next request on.
+func (h *ImpersonationHandler) EndImpersonation(c *gin.Context) {
+	cmd := commands.EndImpersonationCommand{
+		ID:        c.Param("id"),
+		ActorID:   c.GetString("user_id"),
+		ActorRole: c.GetString("user_role"),
+		RequestID: middleware.GetRequestID(c),
+	}
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	grant, err := h.endHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, grant)
+}
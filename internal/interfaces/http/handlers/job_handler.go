@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JobHandler serves the admin job dashboard: what the background workers
+// ran, and retrying or cancelling it
+type JobHandler struct {
+	listJobsHandler  *queries.ListJobsQueryHandler
+	getJobHandler    *queries.GetJobQueryHandler
+	retryJobHandler  *commands.RetryJobCommandHandler
+	cancelJobHandler *commands.CancelJobCommandHandler
+}
+
+func NewJobHandler(
+	listJobsHandler *queries.ListJobsQueryHandler,
+	getJobHandler *queries.GetJobQueryHandler,
+	retryJobHandler *commands.RetryJobCommandHandler,
+	cancelJobHandler *commands.CancelJobCommandHandler,
+) *JobHandler {
+	return &JobHandler{
+		listJobsHandler:  listJobsHandler,
+		getJobHandler:    getJobHandler,
+		retryJobHandler:  retryJobHandler,
+		cancelJobHandler: cancelJobHandler,
+	}
+}
+
+// ListJobs lists jobs newest first, filtered by ?queue=, ?type= and ?state=
+func (h *JobHandler) ListJobs(c *gin.Context) {
+	query := queries.ListJobsQuery{
+		Queue: c.Query("queue"),
+		Type:  c.Query("type"),
+		State: c.Query("state"),
+	}
+	if !validateRequest(c, &query) {
+		return
+	}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	result, err := h.listJobsHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, result.Jobs, page.Meta(len(result.Jobs), &result.Total))
+}
+
+// GetJob shows a job with its payload and last error
+func (h *JobHandler) GetJob(c *gin.Context) {
+	j, err := h.getJobHandler.Handle(c.Request.Context(), queries.GetJobQuery{ID: c.Param("id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, j)
+}
+
+// RetryJob queues a failed or cancelled job again; the response is 202 as
+// it only runs once a worker picks it up.
+func (h *JobHandler) RetryJob(c *gin.Context) {
+	j, err := h.retryJobHandler.Handle(c.Request.Context(), commands.RetryJobCommand{ID: c.Param("id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusAccepted, j)
+}
+
+// CancelJob stops a pending job from running
+func (h *JobHandler) CancelJob(c *gin.Context) {
+	cmd := commands.CancelJobCommand{ID: c.Param("id"), UserID: c.GetString("user_id")}
+	j, err := h.cancelJobHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, j)
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http"
+	"online-shop/pkg/jwt"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JWKSHandler struct {
+	jwtManager *jwt.JWTManager
+}
+
+func NewJWKSHandler(jwtManager *jwt.JWTManager) *JWKSHandler {
+	return &JWKSHandler{jwtManager: jwtManager}
+}
+
+// GetKeys publishes the token signing keys. Verifiers cache the set, so a
+// new key must be listed here before it becomes the active one.
+func (h *JWKSHandler) GetKeys(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.jwtManager.JWKS())
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/interfaces/http/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaintenanceHandler lets admins take the API down for maintenance, now or
+// in scheduled windows.
+type MaintenanceHandler struct {
+	getHandler      *queries.GetMaintenanceQueryHandler
+	setHandler      *commands.SetMaintenanceCommandHandler
+	scheduleHandler *commands.ScheduleMaintenanceCommandHandler
+	cancelHandler   *commands.CancelMaintenanceWindowCommandHandler
+}
+
+func NewMaintenanceHandler(
+	getHandler *queries.GetMaintenanceQueryHandler,
+	setHandler *commands.SetMaintenanceCommandHandler,
+	scheduleHandler *commands.ScheduleMaintenanceCommandHandler,
+	cancelHandler *commands.CancelMaintenanceWindowCommandHandler,
+) *MaintenanceHandler {
+	return &MaintenanceHandler{
+		getHandler:      getHandler,
+		setHandler:      setHandler,
+		scheduleHandler: scheduleHandler,
+		cancelHandler:   cancelHandler,
+	}
+}
+
+func (h *MaintenanceHandler) GetMaintenance(c *gin.Context) {
+	view, err := h.getHandler.Handle(c.Request.Context(), queries.GetMaintenanceQuery{})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, view)
+}
+
+// SetMaintenance switches maintenance mode on or off. Every API instance
+// follows within its refresh interval.
+func (h *MaintenanceHandler) SetMaintenance(c *gin.Context) {
+	var cmd commands.SetMaintenanceCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.ActorID = c.GetString("user_id")
+	cmd.ActorRole = c.GetString("user_role")
+	cmd.RequestID = middleware.GetRequestID(c)
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	state, err := h.setHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, state)
+}
+
+func (h *MaintenanceHandler) ScheduleWindow(c *gin.Context) {
+	var cmd commands.ScheduleMaintenanceCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.ActorID = c.GetString("user_id")
+	cmd.ActorRole = c.GetString("user_role")
+	cmd.RequestID = middleware.GetRequestID(c)
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	window, err := h.scheduleHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, window)
+}
+
+func (h *MaintenanceHandler) CancelWindow(c *gin.Context) {
+	cmd := commands.CancelMaintenanceWindowCommand{
+		ID:        c.Param("id"),
+		ActorID:   c.GetString("user_id"),
+		ActorRole: c.GetString("user_role"),
+		RequestID: middleware.GetRequestID(c),
+	}
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	if err := h.cancelHandler.Handle(c.Request.Context(), cmd); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "Maintenance window cancelled"})
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/interfaces/http/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ManualOrderHandler serves the orders admins place for customers, such as
+// phone orders, and the payments they take for orders offline.
+type ManualOrderHandler struct {
+	createHandler         *commands.CreateManualOrderCommandHandler
+	offlinePaymentHandler *commands.RecordOfflinePaymentCommandHandler
+}
+
+func NewManualOrderHandler(
+	createHandler *commands.CreateManualOrderCommandHandler,
+	offlinePaymentHandler *commands.RecordOfflinePaymentCommandHandler,
+) *ManualOrderHandler {
+	return &ManualOrderHandler{
+		createHandler:         createHandler,
+		offlinePaymentHandler: offlinePaymentHandler,
+	}
+}
+
+// CreateOrder places an order on a customer's behalf.
+func (h *ManualOrderHandler) CreateOrder(c *gin.Context) {
+	var cmd commands.CreateManualOrderCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.ActorID = c.GetString("user_id")
+	cmd.ActorRole = c.GetString("user_role")
+	cmd.RequestID = middleware.GetRequestID(c)
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	result, err := h.createHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, result)
+}
+
+// RecordOfflinePayment records a payment taken for an order outside the
+// payment provider.
+func (h *ManualOrderHandler) RecordOfflinePayment(c *gin.Context) {
+	var cmd commands.RecordOfflinePaymentCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.OrderID = c.Param("id")
+	cmd.ActorID = c.GetString("user_id")
+	cmd.ActorRole = c.GetString("user_role")
+	cmd.RequestID = middleware.GetRequestID(c)
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	summary, err := h.offlinePaymentHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, summary)
+}
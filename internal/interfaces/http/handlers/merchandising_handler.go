@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/pagination"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MerchandisingHandler serves the admin API for the rules that pin, boost
+// and bury products in search results and curate category landing pages.
+type MerchandisingHandler struct {
+	listRulesHandler  *queries.ListMerchandisingRulesQueryHandler
+	previewHandler    *queries.PreviewMerchandisingQueryHandler
+	createRuleHandler *commands.CreateMerchandisingRuleCommandHandler
+	updateRuleHandler *commands.UpdateMerchandisingRuleCommandHandler
+	deleteRuleHandler *commands.DeleteMerchandisingRuleCommandHandler
+}
+
+func NewMerchandisingHandler(
+	listRulesHandler *queries.ListMerchandisingRulesQueryHandler,
+	previewHandler *queries.PreviewMerchandisingQueryHandler,
+	createRuleHandler *commands.CreateMerchandisingRuleCommandHandler,
+	updateRuleHandler *commands.UpdateMerchandisingRuleCommandHandler,
+	deleteRuleHandler *commands.DeleteMerchandisingRuleCommandHandler,
+) *MerchandisingHandler {
+	return &MerchandisingHandler{
+		listRulesHandler:  listRulesHandler,
+		previewHandler:    previewHandler,
+		createRuleHandler: createRuleHandler,
+		updateRuleHandler: updateRuleHandler,
+		deleteRuleHandler: deleteRuleHandler,
+	}
+}
+
+func (h *MerchandisingHandler) ListRules(c *gin.Context) {
+	query := queries.ListMerchandisingRulesQuery{Kind: c.Query("kind")}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+	if !validateRequest(c, &query) {
+		return
+	}
+
+	rules, err := h.listRulesHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, rules.Rules, page.Meta(len(rules.Rules), pagination.Total(rules.Total)))
+}
+
+func (h *MerchandisingHandler) CreateRule(c *gin.Context) {
+	var cmd commands.CreateMerchandisingRuleCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.CreatedBy = c.GetString("user_id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	rule, err := h.createRuleHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, rule)
+}
+
+func (h *MerchandisingHandler) UpdateRule(c *gin.Context) {
+	var cmd commands.UpdateMerchandisingRuleCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.RuleID = c.Param("id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	rule, err := h.updateRuleHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, rule)
+}
+
+func (h *MerchandisingHandler) DeleteRule(c *gin.Context) {
+	if err := h.deleteRuleHandler.Handle(c.Request.Context(), commands.DeleteMerchandisingRuleCommand{RuleID: c.Param("id")}); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Preview shows the rules applying to a search for ?q= in ?category_id=,
+// now or at the RFC 3339 time in ?at=, and the ranking they give it.
+func (h *MerchandisingHandler) Preview(c *gin.Context) {
+	query := queries.PreviewMerchandisingQuery{Query: c.Query("q"), CategoryID: c.Query("category_id")}
+	if at := c.Query("at"); at != "" {
+		t, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			respondError(c, apperror.ErrInvalidRequest.WithDetail("at must be an RFC 3339 time"))
+			return
+		}
+		query.At = &t
+	}
+
+	preview, err := h.previewHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, preview)
+}
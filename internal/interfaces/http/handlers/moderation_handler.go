@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"net/http"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/pkg/pagination"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ModerationHandler serves the review of merchant products: merchants
+// submit their products and follow their submissions, admins approve or
+// reject them.
+type ModerationHandler struct {
+	listSubmissionsHandler *queries.ListProductSubmissionsQueryHandler
+	getSubmissionHandler   *queries.GetProductSubmissionQueryHandler
+	submitHandler          *commands.SubmitProductCommandHandler
+	reviewHandler          *commands.ReviewProductSubmissionCommandHandler
+}
+
+func NewModerationHandler(
+	listSubmissionsHandler *queries.ListProductSubmissionsQueryHandler,
+	getSubmissionHandler *queries.GetProductSubmissionQueryHandler,
+	submitHandler *commands.SubmitProductCommandHandler,
+	reviewHandler *commands.ReviewProductSubmissionCommandHandler,
+) *ModerationHandler {
+	return &ModerationHandler{
+		listSubmissionsHandler: listSubmissionsHandler,
+		getSubmissionHandler:   getSubmissionHandler,
+		submitHandler:          submitHandler,
+		reviewHandler:          reviewHandler,
+	}
+}
+
+func (h *ModerationHandler) SubmitNewProduct(c *gin.Context) {
+	var cmd commands.SubmitProductCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.MerchantID = c.GetString("user_id")
+	h.submit(c, cmd, http.StatusCreated)
+}
+
+func (h *ModerationHandler) SubmitProductChanges(c *gin.Context) {
+	var cmd commands.SubmitProductCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.MerchantID = webhookScope(c)
+	cmd.ProductID = c.Param("id")
+	h.submit(c, cmd, http.StatusAccepted)
+}
+
+func (h *ModerationHandler) submit(c *gin.Context, cmd commands.SubmitProductCommand, status int) {
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	submission, err := h.submitHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, status, submission)
+}
+
+func (h *ModerationHandler) ListMerchantSubmissions(c *gin.Context) {
+	h.list(c, webhookScope(c))
+}
+
+func (h *ModerationHandler) ListSubmissions(c *gin.Context) {
+	h.list(c, "")
+}
+
+func (h *ModerationHandler) list(c *gin.Context, merchantID string) {
+	query := queries.ListProductSubmissionsQuery{
+		MerchantID: merchantID,
+		ProductID:  c.Query("product_id"),
+		Status:     c.Query("status"),
+	}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+	if !validateRequest(c, &query) {
+		return
+	}
+
+	submissions, err := h.listSubmissionsHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, submissions.Submissions, page.Meta(len(submissions.Submissions), pagination.Total(submissions.Total)))
+}
+
+func (h *ModerationHandler) GetMerchantSubmission(c *gin.Context) {
+	h.get(c, webhookScope(c))
+}
+
+func (h *ModerationHandler) GetSubmission(c *gin.Context) {
+	h.get(c, "")
+}
+
+func (h *ModerationHandler) get(c *gin.Context, merchantID string) {
+	submission, err := h.getSubmissionHandler.Handle(c.Request.Context(), queries.GetProductSubmissionQuery{SubmissionID: c.Param("id"), MerchantID: merchantID})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, submission)
+}
+
+func (h *ModerationHandler) ApproveSubmission(c *gin.Context) {
+	h.review(c, true)
+}
+
+func (h *ModerationHandler) RejectSubmission(c *gin.Context) {
+	h.review(c, false)
+}
+
+func (h *ModerationHandler) review(c *gin.Context, approve bool) {
+	var cmd commands.ReviewProductSubmissionCommand
+	if c.Request.ContentLength > 0 && !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.SubmissionID = c.Param("id")
+	cmd.ReviewedBy = c.GetString("user_id")
+	cmd.Approve = approve
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	submission, err := h.reviewHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, submission)
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NotificationPreferenceHandler serves the preference center, where users
+// choose which notifications they get over which channel, and the
+// unsubscribe links of their emails.
+type NotificationPreferenceHandler struct {
+	getHandler            *queries.GetNotificationPreferencesQueryHandler
+	updateHandler         *commands.UpdateNotificationPreferencesCommandHandler
+	getUnsubscribeHandler *queries.GetUnsubscribeQueryHandler
+	unsubscribeHandler    *commands.UnsubscribeCommandHandler
+}
+
+func NewNotificationPreferenceHandler(
+	getHandler *queries.GetNotificationPreferencesQueryHandler,
+	updateHandler *commands.UpdateNotificationPreferencesCommandHandler,
+	getUnsubscribeHandler *queries.GetUnsubscribeQueryHandler,
+	unsubscribeHandler *commands.UnsubscribeCommandHandler,
+) *NotificationPreferenceHandler {
+	return &NotificationPreferenceHandler{
+		getHandler:            getHandler,
+		updateHandler:         updateHandler,
+		getUnsubscribeHandler: getUnsubscribeHandler,
+		unsubscribeHandler:    unsubscribeHandler,
+	}
+}
+
+func (h *NotificationPreferenceHandler) GetPreferences(c *gin.Context) {
+	query := queries.GetNotificationPreferencesQuery{UserID: c.GetString("user_id")}
+	settings, err := h.getHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, settings)
+}
+
+func (h *NotificationPreferenceHandler) UpdatePreferences(c *gin.Context) {
+	var cmd commands.UpdateNotificationPreferencesCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.UserID = c.GetString("user_id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	settings, err := h.updateHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, settings)
+}
+
+// GetUnsubscribe returns what the link unsubscribes from, for the page
+// asking the recipient to confirm.
+func (h *NotificationPreferenceHandler) GetUnsubscribe(c *gin.Context) {
+	link, err := h.getUnsubscribeHandler.Handle(c.Request.Context(), queries.GetUnsubscribeQuery{Token: c.Param("token")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, link)
+}
+
+// Unsubscribe turns off what the link is for. Mail clients post here
+// without a body when the recipient unsubscribes in one click (RFC 8058).
+func (h *NotificationPreferenceHandler) Unsubscribe(c *gin.Context) {
+	link, err := h.unsubscribeHandler.Handle(c.Request.Context(), commands.UnsubscribeCommand{Token: c.Param("token")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, link)
+}
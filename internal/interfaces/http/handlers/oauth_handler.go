@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"online-shop/internal/application/commands"
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/jwt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OAuthLogin answers a social login with the user and their tokens, and
+// whether the account was created or linked by it.
+type OAuthLogin struct {
+	AuthResponse
+	Created bool `json:"created"`
+	Linked  bool `json:"linked"`
+}
+
+type OAuthHandler struct {
+	startHandler    *commands.StartOAuthLoginCommandHandler
+	completeHandler *commands.CompleteOAuthLoginCommandHandler
+	sessionHandler  *commands.StartSessionCommandHandler
+	jwtManager      *jwt.JWTManager
+	carts           *CartHandler
+	successURL      string
+}
+
+// NewOAuthHandler builds the social login endpoints. When successURL is set
+// the callback redirects there with the token in the URL fragment, otherwise
+// it answers with JSON like the password login.
+func NewOAuthHandler(
+	startHandler *commands.StartOAuthLoginCommandHandler,
+	completeHandler *commands.CompleteOAuthLoginCommandHandler,
+	sessionHandler *commands.StartSessionCommandHandler,
+	jwtManager *jwt.JWTManager,
+	carts *CartHandler,
+	successURL string,
+) *OAuthHandler {
+	return &OAuthHandler{
+		startHandler:    startHandler,
+		completeHandler: completeHandler,
+		sessionHandler:  sessionHandler,
+		jwtManager:      jwtManager,
+		carts:           carts,
+		successURL:      successURL,
+	}
+}
+
+// oauthStateCookie holds the binding of the login the browser started
+const oauthStateCookie = "oauth_state"
+
+// Start sends the browser to the provider, keeping the login's binding in a
+// cookie scoped to the provider's routes. It is SameSite=Lax, except for
+// providers that post the callback back from their site, which a Lax cookie
+// is not sent with; the binding protects the login either way.
+func (h *OAuthHandler) Start(c *gin.Context) {
+	start, err := h.startHandler.Handle(c.Request.Context(), commands.StartOAuthLoginCommand{Provider: c.Param("provider")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	if start.FormPost && secure {
+		c.SetSameSite(http.SameSiteNoneMode)
+	} else {
+		c.SetSameSite(http.SameSiteLaxMode)
+	}
+	path := strings.TrimSuffix(c.Request.URL.Path, "/start")
+	c.SetCookie(oauthStateCookie, start.Binding, int(start.TTL.Seconds()), path, "", secure, true)
+	c.Redirect(http.StatusFound, start.URL)
+}
+
+// Callback accepts the query string redirect used by Google and the form
+// post Apple sends with response_mode=form_post.
+func (h *OAuthHandler) Callback(c *gin.Context) {
+	if providerErr := c.Request.FormValue("error"); providerErr != "" {
+		respondError(c, ErrOAuthDenied.WithDetail("%s", providerErr))
+		return
+	}
+
+	binding, _ := c.Cookie(oauthStateCookie)
+	cmd := commands.CompleteOAuthLoginCommand{
+		Provider: c.Param("provider"),
+		Code:     c.Request.FormValue("code"),
+		State:    c.Request.FormValue("state"),
+		Binding:  binding,
+	}
+	if cmd.Code == "" || cmd.State == "" {
+		respondError(c, apperror.ErrInvalidRequest.WithDetail("code and state are required"))
+		return
+	}
+
+	result, err := h.completeHandler.Handle(c.Request.Context(), cmd)
+	if binding != "" {
+		c.SetCookie(oauthStateCookie, "", -1, strings.TrimSuffix(c.Request.URL.Path, "/callback"), "", false, true)
+	}
+	if err != nil {
+		// Anything without a code comes from the exchange with the provider
+		if e := apperror.From(err); e.Kind != apperror.KindInternal {
+			respondError(c, e)
+			return
+		}
+		respondError(c, ErrOAuthProviderFailed.Wrap(err))
+		return
+	}
+
+	user := result.User
+	tokens, err := startSession(c, h.sessionHandler, h.jwtManager, user)
+	if err != nil {
+		respondError(c, ErrTokenGeneration.Wrap(err))
+		return
+	}
+	h.carts.merge(c, user.ID)
+
+	if h.successURL != "" {
+		fragment := url.Values{
+			"token":         {tokens.AccessToken},
+			"refresh_token": {tokens.RefreshToken},
+		}
+		c.Redirect(http.StatusFound, h.successURL+"#"+fragment.Encode())
+		return
+	}
+
+	status := http.StatusOK
+	if result.Created {
+		status = http.StatusCreated
+	}
+	respond(c, status, OAuthLogin{
+		AuthResponse: AuthResponse{User: user, TokenResponse: tokenResponse(tokens)},
+		Created:      result.Created,
+		Linked:       result.Linked,
+	})
+}
@@ -0,0 +1,743 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/analytics"
+	"online-shop/internal/domain/audit"
+	"online-shop/internal/domain/backup"
+	"online-shop/internal/domain/badge"
+	"online-shop/internal/domain/banner"
+	"online-shop/internal/domain/campaign"
+	"online-shop/internal/domain/cms"
+	"online-shop/internal/domain/cod"
+	"online-shop/internal/domain/commission"
+	"online-shop/internal/domain/dashboard"
+	"online-shop/internal/domain/draftorder"
+	"online-shop/internal/domain/emaildelivery"
+	"online-shop/internal/domain/emailtemplate"
+	"online-shop/internal/domain/experiment"
+	"online-shop/internal/domain/export"
+	"online-shop/internal/domain/flashsale"
+	"online-shop/internal/domain/forecast"
+	"online-shop/internal/domain/fraud"
+	"online-shop/internal/domain/impersonation"
+	"online-shop/internal/domain/job"
+	"online-shop/internal/domain/maintenance"
+	"online-shop/internal/domain/merchandising"
+	"online-shop/internal/domain/moderation"
+	"online-shop/internal/domain/notification"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/organization"
+	"online-shop/internal/domain/payment"
+	"online-shop/internal/domain/pricealert"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/productversion"
+	"online-shop/internal/domain/quote"
+	"online-shop/internal/domain/reconciliation"
+	"online-shop/internal/domain/shipping"
+	"online-shop/internal/domain/stockalert"
+	"online-shop/internal/domain/storefront"
+	"online-shop/internal/domain/systemlog"
+	"online-shop/internal/domain/user"
+	"online-shop/internal/domain/warehouse"
+	"online-shop/internal/domain/whatsapp"
+	"online-shop/internal/interfaces/http/apiv2"
+	"online-shop/internal/interfaces/http/middleware"
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/jwt"
+	"online-shop/pkg/openapi"
+	"online-shop/pkg/pagination"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAPIPath is where the document of the API is served.
+const OpenAPIPath = "/openapi.json"
+
+// bearerAuth is the security scheme of the access tokens
+const bearerAuth = "bearerAuth"
+
+// Message is the body of actions that answer with a confirmation only.
+type Message struct {
+	Message string `json:"message"`
+}
+
+// Status is the body of the health check and the payment webhook.
+type Status struct {
+	Status string `json:"status"`
+}
+
+// authLevel is whether an operation needs a signed-in user
+type authLevel int
+
+const (
+	authNone authLevel = iota
+	// authOptional operations personalize their answer for a valid token
+	// and ignore one that is not
+	authOptional
+	authRequired
+)
+
+// listing is how a list operation pages
+type listing int
+
+const (
+	notPaged listing = iota
+	// pagedByOffset lists take page and per_page, or the cursor of the
+	// previous page
+	pagedByOffset
+	// pagedByKeyset lists only take the cursor of the previous page
+	pagedByKeyset
+)
+
+type param struct {
+	name        string
+	typ         string
+	description string
+}
+
+// operation documents a route of the API server. Request bodies and the
+// data of responses are given as values of the types the handler decodes
+// and encodes; their schemas are generated from those types.
+type operation struct {
+	method  string
+	path    string
+	id      string
+	summary string
+	tag     string
+	auth    authLevel
+	query   []param
+	headers []param
+	// body is the request body, optionalBody when it may be left out
+	body         interface{}
+	optionalBody bool
+	// status and data are the success response, wrapped in the Envelope
+	// unless bare is set
+	status int
+	data   interface{}
+	bare   bool
+	list   listing
+	// partial lists answer with the problems of the parts that failed in
+	// the envelope's errors
+	partial    bool
+	idempotent bool
+	// media is the content type of a success response that is not JSON
+	media string
+	// redirect is the status of a redirect answered instead of or besides
+	// the success response
+	redirect int
+}
+
+var sessionHeader = param{SessionIDHeader, "string", "Identifies an anonymous visitor's browsing session"}
+var cartHeader = param{CartTokenHeader, "string", "Anonymous visitor's cart token, issued by their first change to the cart"}
+
+var searchParams = []param{
+	{"q", "string", "Full text query"},
+	{"category_id", "string", ""},
+	{"merchant_id", "string", ""},
+	{"min_price", "number", ""},
+	{"max_price", "number", ""},
+	{"attr", "object", "Attribute filters: attr[key]=value,value or, for numbers, attr[key]=min..max"},
+	localeParam,
+}
+
+var orderFilterParams = []param{
+	{"status", "string", "Comma separated order statuses"},
+	{"from", "string", "Orders placed from this date or time (RFC 3339)"},
+	{"to", "string", "Orders placed before this time; a date includes that day"},
+	{"product", "string", "Orders containing a product whose name matches"},
+}
+
+var reportRangeParams = []param{
+	{"from", "string", "Start of the report, a date or time (RFC 3339)"},
+	{"to", "string", "End of the report; a date includes that day"},
+	{"group_by", "string", "day, week or month"},
+}
+
+var limitParam = param{"limit", "integer", "Number of items"}
+
+var localeParam = param{"locale", "string", "Locale to serve the catalog in, instead of the request's"}
+
+// operations are the routes the API server registers. The unit tests check
+// them against cmd/api/main.go.
+var operations = []operation{
+	{method: http.MethodGet, path: "/health", id: "getHealth", summary: "Health check", tag: "system", data: Status{}, bare: true},
+	{method: http.MethodGet, path: "/.well-known/jwks.json", id: "getJWKS", summary: "Public keys that sign access tokens", tag: "system", data: jwt.JWKS{}, bare: true},
+	{method: http.MethodGet, path: OpenAPIPath, id: "getOpenAPI", summary: "This document", tag: "system", data: map[string]interface{}{}, bare: true},
+	{method: http.MethodPost, path: "/csp-reports", id: "reportCSPViolation", summary: "Content-Security-Policy violation reports posted by browsers", tag: "system", body: json.RawMessage{}, status: http.StatusNoContent},
+	{method: http.MethodGet, path: "/errors", id: "listErrors", summary: "Error catalog", tag: "system", data: []apperror.Entry{}},
+	{method: http.MethodGet, path: "/errors/:code", id: "getError", summary: "Error catalog entry", tag: "system", data: apperror.Entry{}},
+	{method: http.MethodGet, path: "/sitemap.xml", id: "getSitemapIndex", summary: "Sitemap index", tag: "system", media: "application/xml"},
+	{method: http.MethodGet, path: "/sitemaps/:file", id: "getSitemap", summary: "Gzipped sitemap", tag: "system", media: "application/gzip"},
+	{method: http.MethodGet, path: "/downloads/*key", id: "download", summary: "Download a file by signed link", tag: "system", media: "application/octet-stream",
+		query: []param{{"expires", "integer", "Expiry of the link (Unix time)"}, {"signature", "string", "Signature of the link"}}},
+
+	{method: http.MethodPost, path: "/api/v1/users/register", id: "registerUser", summary: "Sign up", tag: "users", body: commands.RegisterUserCommand{}, status: http.StatusCreated, data: AuthResponse{}},
+	{method: http.MethodPost, path: "/api/v1/users/login", id: "loginUser", summary: "Sign in with email and password", tag: "users", body: commands.LoginUserCommand{}, data: AuthResponse{}},
+	{method: http.MethodPost, path: "/api/v1/users/refresh", id: "refreshToken", summary: "Exchange a refresh token for a new token pair", tag: "users", body: RefreshTokenRequest{}, data: TokenResponse{}},
+	{method: http.MethodGet, path: "/api/v1/users/profile", id: "getProfile", summary: "Signed-in user's profile", tag: "users", auth: authRequired, data: user.User{}},
+	{method: http.MethodPut, path: "/api/v1/users/profile", id: "updateProfile", summary: "Update first_name, last_name, phone, locale, birthday, gender or marketing_consent", tag: "users", auth: authRequired, body: map[string]interface{}{}, data: user.User{}},
+	{method: http.MethodPut, path: "/api/v1/users/password", id: "changePassword", summary: "Change password", tag: "users", auth: authRequired, body: ChangePasswordRequest{}, data: Message{}},
+	{method: http.MethodDelete, path: "/api/v1/users/account", id: "deleteAccount", summary: "Schedule the account for deletion", tag: "users", auth: authRequired, status: http.StatusAccepted, data: AccountDeletion{}},
+	{method: http.MethodGet, path: "/api/v1/users/notification-preferences", id: "getNotificationPreferences", summary: "Which notifications the user gets over which channel", tag: "users", auth: authRequired, data: []notification.Settings{}},
+	{method: http.MethodPut, path: "/api/v1/users/notification-preferences", id: "updateNotificationPreferences", summary: "Turn notification categories on or off per channel", tag: "users", auth: authRequired, body: commands.UpdateNotificationPreferencesCommand{}, data: []notification.Settings{}},
+
+	{method: http.MethodGet, path: "/api/v1/user/sessions", id: "listSessions", summary: "Signed-in devices", tag: "sessions", auth: authRequired, data: SessionList{}},
+	{method: http.MethodDelete, path: "/api/v1/user/sessions", id: "revokeAllSessions", summary: "Sign out every other device", tag: "sessions", auth: authRequired, data: RevokedSessions{},
+		query: []param{{"include_current", "boolean", "Sign out the calling session as well"}}},
+	{method: http.MethodDelete, path: "/api/v1/user/sessions/:id", id: "revokeSession", summary: "Sign out a device", tag: "sessions", auth: authRequired, data: Message{}},
+
+	{method: http.MethodGet, path: "/api/v1/user/payment-methods", id: "listPaymentMethods", summary: "Saved cards", tag: "payment methods", auth: authRequired, data: []*payment.SavedMethod{}},
+	{method: http.MethodPost, path: "/api/v1/user/payment-methods", id: "addPaymentMethod", summary: "Save a tokenized card", tag: "payment methods", auth: authRequired, body: commands.AddPaymentMethodCommand{}, status: http.StatusCreated, data: payment.SavedMethod{}},
+	{method: http.MethodDelete, path: "/api/v1/user/payment-methods/:id", id: "deletePaymentMethod", summary: "Remove a saved card", tag: "payment methods", auth: authRequired, data: Message{}},
+	{method: http.MethodPut, path: "/api/v1/user/payment-methods/:id/default", id: "setDefaultPaymentMethod", summary: "Make a saved card the default", tag: "payment methods", auth: authRequired, data: Message{}},
+	{method: http.MethodGet, path: "/api/v1/user/receipts", id: "listReceipts", summary: "Receipts of the user's payments", tag: "payments", auth: authRequired, data: []payment.Receipt{}, list: pagedByOffset},
+
+	{method: http.MethodGet, path: "/api/v1/user/stock-alerts", id: "listStockAlerts", summary: "Back in stock alerts", tag: "alerts", auth: authRequired, data: []*stockalert.Subscription{}, list: pagedByOffset},
+	{method: http.MethodPost, path: "/api/v1/user/stock-alerts", id: "subscribeStockAlert", summary: "Get told when a product is back in stock", tag: "alerts", auth: authRequired, body: commands.SubscribeStockAlertCommand{}, status: http.StatusCreated, data: stockalert.Subscription{}},
+	{method: http.MethodDelete, path: "/api/v1/user/stock-alerts/:id", id: "cancelStockAlert", summary: "Cancel a back in stock alert", tag: "alerts", auth: authRequired, data: Message{}},
+	{method: http.MethodGet, path: "/api/v1/user/price-alerts", id: "listPriceAlerts", summary: "Price drop alerts", tag: "alerts", auth: authRequired, data: []*pricealert.Watch{}, list: pagedByOffset},
+	{method: http.MethodPost, path: "/api/v1/user/price-alerts", id: "watchPrice", summary: "Get told when a product drops to a price", tag: "alerts", auth: authRequired, body: commands.WatchPriceCommand{}, status: http.StatusCreated, data: pricealert.Watch{}},
+	{method: http.MethodDelete, path: "/api/v1/user/price-alerts/:id", id: "cancelPriceAlert", summary: "Cancel a price drop alert", tag: "alerts", auth: authRequired, data: Message{}},
+	{method: http.MethodGet, path: "/api/v1/user/quotes", id: "listQuotes", summary: "The user's requests for quotes", tag: "quotes", auth: authRequired, data: []*quote.Quote{}, list: pagedByOffset},
+	{method: http.MethodPost, path: "/api/v1/user/quotes", id: "requestQuote", summary: "Ask a merchant for a price on large quantities", tag: "quotes", auth: authRequired, body: commands.RequestQuoteCommand{}, status: http.StatusCreated, data: quote.Quote{}},
+	{method: http.MethodGet, path: "/api/v1/user/quotes/:id", id: "getQuote", summary: "A request for a quote and its offer", tag: "quotes", auth: authRequired, data: quote.Quote{}},
+	{method: http.MethodPost, path: "/api/v1/user/quotes/:id/accept", id: "acceptQuote", summary: "Place an order at the offered prices", tag: "quotes", auth: authRequired, body: commands.AcceptQuoteCommand{}, status: http.StatusCreated, data: order.Order{}},
+	{method: http.MethodPost, path: "/api/v1/user/quotes/:id/cancel", id: "cancelQuote", summary: "Withdraw a request, or turn its offer down", tag: "quotes", auth: authRequired, data: quote.Quote{}},
+	{method: http.MethodGet, path: "/api/v1/user/organization", id: "getOrganization", summary: "The organization the user buys for and its credit", tag: "organizations", auth: authRequired, data: queries.OrganizationAccount{}},
+	{method: http.MethodPost, path: "/api/v1/user/organization", id: "createOrganization", summary: "Open a business account", tag: "organizations", auth: authRequired, body: commands.CreateOrganizationCommand{}, status: http.StatusCreated, data: organization.Organization{}},
+	{method: http.MethodGet, path: "/api/v1/user/organization/members", id: "listOrganizationMembers", summary: "Members of the user's organization", tag: "organizations", auth: authRequired, data: []*organization.Member{}},
+	{method: http.MethodPost, path: "/api/v1/user/organization/members", id: "addOrganizationMember", summary: "Add a user to the organization", tag: "organizations", auth: authRequired, body: commands.AddOrganizationMemberCommand{}, status: http.StatusCreated, data: organization.Member{}},
+	{method: http.MethodPut, path: "/api/v1/user/organization/members/:userId", id: "updateOrganizationMember", summary: "Change a member's role", tag: "organizations", auth: authRequired, body: commands.UpdateOrganizationMemberCommand{}, data: organization.Member{}},
+	{method: http.MethodDelete, path: "/api/v1/user/organization/members/:userId", id: "removeOrganizationMember", summary: "Remove a member, or leave the organization", tag: "organizations", auth: authRequired, status: http.StatusNoContent},
+	{method: http.MethodPut, path: "/api/v1/user/organization/approval-chain", id: "setApprovalChain", summary: "Set the approvals purchases on invoice need", tag: "organizations", auth: authRequired, body: commands.SetApprovalChainCommand{}, data: organization.Organization{}},
+	{method: http.MethodGet, path: "/api/v1/user/organization/orders", id: "listOrganizationOrders", summary: "Orders bought for the organization", tag: "organizations", auth: authRequired, query: orderFilterParams, data: []*order.Order{}, list: pagedByOffset},
+	{method: http.MethodGet, path: "/api/v1/user/organization/approvals", id: "listPurchaseApprovals", summary: "Purchases sent for approval", tag: "organizations", auth: authRequired, data: []*organization.Approval{}, list: pagedByOffset},
+	{method: http.MethodPost, path: "/api/v1/user/organization/approvals/:id/approve", id: "approvePurchase", summary: "Approve the next step of a purchase", tag: "organizations", auth: authRequired, body: commands.DecidePurchaseApprovalCommand{}, data: organization.Purchase{}},
+	{method: http.MethodPost, path: "/api/v1/user/organization/approvals/:id/reject", id: "rejectPurchase", summary: "Reject a purchase", tag: "organizations", auth: authRequired, body: commands.DecidePurchaseApprovalCommand{}, data: organization.Purchase{}},
+	{method: http.MethodGet, path: "/api/v1/user/organization/invoices", id: "listOrganizationInvoices", summary: "Invoices owed by the organization, soonest due first", tag: "organizations", auth: authRequired, data: []*organization.Invoice{}, list: pagedByOffset},
+	{method: http.MethodPost, path: "/api/v1/user/data-export", id: "requestDataExport", summary: "Request a copy of the user's personal data", tag: "users", auth: authRequired, body: commands.RequestDataExportCommand{}, optionalBody: true, status: http.StatusAccepted, data: export.Export{}},
+
+	{method: http.MethodGet, path: "/api/v1/auth/oauth/:provider/start", id: "startOAuthLogin", summary: "Redirect to the provider's sign in", tag: "users", redirect: http.StatusFound},
+	{method: http.MethodGet, path: "/api/v1/auth/oauth/:provider/callback", id: "completeOAuthLogin", summary: "Complete a social login (201 when the account was created)", tag: "users", data: OAuthLogin{}, redirect: http.StatusFound,
+		query: []param{{"code", "string", ""}, {"state", "string", ""}, {"error", "string", "Set by the provider when the user declined"}}},
+	{method: http.MethodPost, path: "/api/v1/auth/oauth/:provider/callback", id: "completeOAuthLoginFormPost", summary: "Complete a social login posted as a form", tag: "users", data: OAuthLogin{}, redirect: http.StatusFound},
+
+	{method: http.MethodGet, path: "/api/v1/products/search", id: "searchProducts", summary: "Search products", tag: "products", query: searchParams, data: []*product.Product{}, list: pagedByOffset},
+	{method: http.MethodGet, path: "/api/v1/products/search/facets", id: "getSearchFacets", summary: "Facet counts for a search", tag: "products", query: searchParams, data: []product.Facet{}},
+	{method: http.MethodGet, path: "/api/v1/products/compare", id: "compareProducts", summary: "Compare products attribute by attribute", tag: "products", data: product.Comparison{},
+		query: []param{{"ids", "string", "Comma separated product IDs"}, localeParam}},
+	{method: http.MethodGet, path: "/api/v1/products/featured", id: "getFeaturedProducts", summary: "Featured products", tag: "products", query: []param{limitParam, localeParam}, data: []*product.Product{}},
+	{method: http.MethodGet, path: "/api/v1/products/trending", id: "getTrendingProducts", summary: "Trending products", tag: "products", query: []param{localeParam}, data: []*product.Product{}, list: pagedByOffset},
+	{method: http.MethodGet, path: "/api/v1/products/:id", id: "getProduct", summary: "Product by ID or slug; a retired slug redirects", tag: "products", auth: authOptional, query: []param{localeParam}, data: product.Product{}, redirect: http.StatusMovedPermanently},
+	{method: http.MethodGet, path: "/api/v1/products/:id/similar", id: "getSimilarProducts", summary: "Similar products", tag: "products", query: []param{limitParam}, data: []*product.Product{}},
+	{method: http.MethodGet, path: "/api/v1/products/:id/bought-together", id: "getBoughtTogether", summary: "Products often bought together", tag: "products", query: []param{limitParam}, data: []*product.Product{}},
+	{method: http.MethodGet, path: "/api/v1/products/:id/delivery-estimate", id: "getDeliveryEstimate", summary: "When the product arrives at each shipping rate offered in an area", tag: "products", data: shipping.Quote{},
+		query: []param{{"country", "string", "ISO 3166 country code"}, {"state", "string", "State or province"}, {"postal_code", "string", "Postal code"}}},
+	{method: http.MethodGet, path: "/api/v1/products/categories", id: "listCategories", summary: "Categories", tag: "products", query: []param{localeParam}, data: []*product.Category{}, list: pagedByOffset},
+	{method: http.MethodGet, path: "/api/v1/products/category/:slug", id: "getProductsByCategory", summary: "Products in a category; a retired slug redirects", tag: "products", query: []param{localeParam}, data: CategoryProducts{}, list: pagedByOffset, redirect: http.StatusMovedPermanently},
+	{method: http.MethodGet, path: "/api/v1/categories/:slug", id: "getCategory", summary: "Category by slug; a retired slug redirects", tag: "products", query: []param{localeParam}, data: product.Category{}, redirect: http.StatusMovedPermanently},
+	{method: http.MethodGet, path: "/api/v1/user/recently-viewed", id: "getRecentlyViewed", summary: "Products the visitor viewed last", tag: "products", auth: authOptional, headers: []param{sessionHeader}, query: []param{limitParam}, data: []*product.Product{}},
+
+	{method: http.MethodGet, path: "/api/v1/home", id: "getHome", summary: "Home page feed", tag: "content", auth: authOptional, headers: []param{sessionHeader}, data: queries.HomeFeed{}, partial: true},
+	{method: http.MethodGet, path: "/api/v1/cms/banners", id: "getBanners", summary: "Live banners", tag: "content", data: []*banner.Banner{}},
+	{method: http.MethodGet, path: "/api/v1/cms/blocks/:slot", id: "getContentSlot", summary: "Published blocks of a slot", tag: "content", data: []*cms.Block{}},
+	{method: http.MethodGet, path: "/api/v1/cms/pages/:slug", id: "getContentPage", summary: "Published page", tag: "content", data: cms.Page{}},
+	{method: http.MethodGet, path: "/api/v1/cms/assets/:id", id: "getContentAsset", summary: "Redirect to an asset", tag: "content", redirect: http.StatusFound},
+	{method: http.MethodGet, path: "/api/v1/storefront/settings", id: "getStorefrontSettings", summary: "Store name, theme, currencies and locales", tag: "content", data: storefront.Settings{}},
+
+	{method: http.MethodGet, path: "/api/v1/flash-sales", id: "getCurrentFlashSales", summary: "Running and upcoming flash sales", tag: "flash sales", query: []param{limitParam}, data: []*flashsale.Sale{}},
+	{method: http.MethodGet, path: "/api/v1/flash-sales/:id", id: "getFlashSale", summary: "Flash sale", tag: "flash sales", data: flashsale.Sale{}},
+
+	{method: http.MethodGet, path: "/api/v1/experiments", id: "getExperimentAssignments", summary: "Visitor's experiment variants", tag: "experiments", auth: authOptional, headers: []param{sessionHeader}, data: []experiment.Assignment{}},
+	{method: http.MethodPost, path: "/api/v1/experiments/:key/exposures", id: "trackExperimentExposure", summary: "Record that the visitor saw their variant", tag: "experiments", auth: authOptional, headers: []param{sessionHeader}, body: TrackExperimentRequest{}, optionalBody: true, status: http.StatusAccepted, data: experiment.Assignment{}},
+	{method: http.MethodPost, path: "/api/v1/experiments/:key/conversions", id: "trackExperimentConversion", summary: "Record a conversion of the visitor's variant", tag: "experiments", auth: authOptional, headers: []param{sessionHeader}, body: TrackExperimentRequest{}, optionalBody: true, status: http.StatusAccepted, data: experiment.Assignment{}},
+
+	{method: http.MethodPost, path: "/api/v1/email/webhooks/:provider", id: "ingestEmailEvents", summary: "Email provider bounce and complaint webhook, verified by its signature", tag: "provider webhooks", body: json.RawMessage{}, data: commands.IngestEmailEventsResult{}},
+	{method: http.MethodGet, path: "/api/v1/whatsapp/webhook", id: "verifyWhatsAppWebhook", summary: "WhatsApp webhook verification", tag: "provider webhooks", media: "text/plain",
+		query: []param{{"hub.mode", "string", ""}, {"hub.verify_token", "string", ""}, {"hub.challenge", "string", "Echoed back when the token matches"}}},
+	{method: http.MethodPost, path: "/api/v1/whatsapp/webhook", id: "receiveWhatsAppWebhook", summary: "WhatsApp status and message webhook, verified by its signature", tag: "provider webhooks",
+		headers: []param{{"X-Hub-Signature-256", "string", "HMAC of the body"}}, body: json.RawMessage{}, data: commands.IngestWhatsAppWebhookResult{}},
+	{method: http.MethodGet, path: "/api/v1/campaigns/track/open/:token", id: "trackCampaignOpen", summary: "Open beacon of a campaign email, a 1x1 GIF", tag: "campaigns", media: "image/gif"},
+	{method: http.MethodGet, path: "/api/v1/campaigns/track/click/:token", id: "trackCampaignClick", summary: "Record a click on a campaign email link and redirect to it", tag: "campaigns", redirect: http.StatusFound},
+	{method: http.MethodGet, path: "/api/v1/notifications/unsubscribe/:token", id: "getUnsubscribe", summary: "What an email's unsubscribe link turns off", tag: "users", data: notification.Unsubscribe{}},
+	{method: http.MethodPost, path: "/api/v1/notifications/unsubscribe/:token", id: "unsubscribe", summary: "Unsubscribe from an email's category, also in one click from mail clients", tag: "users", data: notification.Unsubscribe{}},
+	{method: http.MethodGet, path: "/api/v1/draft-orders/:token", id: "getDraftOrderSummary", summary: "What a draft order's payment link is for; browsers asking for HTML get a hosted page to pay it", tag: "payments", data: queries.DraftOrderSummary{}},
+	{method: http.MethodPost, path: "/api/v1/draft-orders/:token/pay", id: "payDraftOrder", summary: "Start paying a draft order through its link; the hosted page's form is redirected to the provider", tag: "payments", body: commands.PayDraftOrderCommand{}, status: http.StatusCreated, data: DraftOrderPayment{}},
+	{method: http.MethodPost, path: "/api/v1/payments/webhook", id: "receivePaymentWebhook", summary: "Payment gateway notification", tag: "provider webhooks", body: map[string]interface{}{}, data: Status{}, bare: true},
+
+	{method: http.MethodPost, path: "/api/v1/shipping/quote", id: "quoteShipping", summary: "Check an address and list the shipping rates offered there", tag: "orders", body: queries.GetShippingQuoteQuery{}, data: shipping.Quote{}},
+	{method: http.MethodGet, path: "/api/v1/orders/track", id: "trackOrder", summary: "Track an order by number and email without signing in", tag: "orders", data: order.Tracking{},
+		query: []param{{"number", "string", "Order number"}, {"email", "string", "Email the order was placed with"}}},
+	{method: http.MethodGet, path: "/api/v1/cart", id: "getCart", summary: "Visitor's cart with its products", tag: "cart", auth: authOptional, headers: []param{cartHeader}, data: queries.CartView{}},
+	{method: http.MethodPost, path: "/api/v1/cart/items", id: "addToCart", summary: "Add a product to the cart, issuing a cart token to anonymous visitors", tag: "cart", auth: authOptional, headers: []param{cartHeader}, body: commands.AddToCartCommand{}, data: queries.CartView{}},
+	{method: http.MethodPut, path: "/api/v1/cart/items/:id", id: "updateCartItem", summary: "Change the quantity of a product in the cart", tag: "cart", auth: authOptional, headers: []param{cartHeader}, body: commands.UpdateCartItemCommand{}, data: queries.CartView{}},
+	{method: http.MethodDelete, path: "/api/v1/cart/items/:id", id: "removeFromCart", summary: "Remove a product from the cart", tag: "cart", auth: authOptional, headers: []param{cartHeader}, data: queries.CartView{}},
+	{method: http.MethodDelete, path: "/api/v1/cart", id: "clearCart", summary: "Empty the cart", tag: "cart", auth: authOptional, headers: []param{cartHeader}, data: queries.CartView{}},
+	{method: http.MethodPost, path: "/api/v1/cart/merge", id: "mergeCart", summary: "Merge an anonymous cart into the account cart", tag: "cart", auth: authRequired, headers: []param{cartHeader}, data: queries.CartView{}},
+	{method: http.MethodPost, path: "/api/v1/cart/checkout", id: "checkoutCart", summary: "Place an order for the cart and empty it", tag: "cart", auth: authRequired, body: commands.CheckoutCartCommand{}, status: http.StatusCreated, data: order.Order{}, idempotent: true},
+	{method: http.MethodPost, path: "/api/v1/checkout/preview", id: "previewCheckout", summary: "Totals and shipping options of an order for the items or the cart, without placing it", tag: "cart", auth: authRequired, body: commands.PreviewCheckoutCommand{}, data: commands.CheckoutPreview{}},
+	{method: http.MethodPost, path: "/api/v1/orders", id: "createOrder", summary: "Place an order", tag: "orders", auth: authRequired, body: commands.CreateOrderCommand{}, status: http.StatusCreated, data: order.Order{}, idempotent: true},
+	{method: http.MethodPost, path: "/api/v1/orders/one-click", id: "oneClickCheckout", summary: "Place and pay an order with a saved card", tag: "orders", auth: authRequired, body: commands.OneClickCheckoutCommand{}, status: http.StatusCreated, data: commands.OneClickCheckoutResult{}, idempotent: true},
+	{method: http.MethodGet, path: "/api/v1/orders", id: "listUserOrders", summary: "Signed-in customer's orders", tag: "orders", auth: authRequired, query: orderFilterParams, data: []*order.Order{}, list: pagedByOffset},
+	{method: http.MethodGet, path: "/api/v1/orders/:id", id: "getOrder", summary: "Order", tag: "orders", auth: authRequired, data: order.Order{}},
+	{method: http.MethodPut, path: "/api/v1/orders/:id/cancel", id: "cancelOrder", summary: "Cancel an order", tag: "orders", auth: authRequired, body: CancelOrderRequest{}, optionalBody: true, data: Message{}},
+	{method: http.MethodGet, path: "/api/v1/orders/:id/payments", id: "getOrderPayments", summary: "Payments of an order", tag: "payments", auth: authRequired, data: payment.Summary{}},
+	{method: http.MethodGet, path: "/api/v1/orders/:id/invoice", id: "getOrderInvoice", summary: "Invoice of an order as an HTML document", tag: "payments", auth: authRequired, media: "text/html",
+		query: []param{{"download", "boolean", "Send the invoice as an attachment to save"}}},
+	{method: http.MethodPost, path: "/api/v1/orders/:id/payments", id: "createOrderPayments", summary: "Split an order's payment or pay it in installments", tag: "payments", auth: authRequired, body: commands.CreateOrderPaymentsCommand{}, status: http.StatusCreated, data: payment.Summary{}, idempotent: true},
+	{method: http.MethodPost, path: "/api/v1/orders/:id/payments/:paymentId/pay", id: "payOrderPayment", summary: "Payment link for a pending payment", tag: "payments", auth: authRequired, data: payment.Payment{}, idempotent: true},
+	{method: http.MethodPost, path: "/api/v1/orders/:id/cash-on-delivery", id: "payOnDelivery", summary: "Pay an order in cash on delivery", tag: "payments", auth: authRequired, status: http.StatusCreated, data: cod.Collection{}, idempotent: true},
+	{method: http.MethodPost, path: "/api/v1/orders/:id/pay-on-invoice", id: "payOnInvoice", summary: "Buy an order on the organization's account; 202 while it awaits approval", tag: "payments", auth: authRequired, body: commands.PayOnInvoiceCommand{}, status: http.StatusCreated, data: organization.Purchase{}, idempotent: true},
+
+	{method: http.MethodGet, path: "/api/v2/products", id: "listProductsV2", summary: "Products, newest first", tag: "products", query: searchParams, data: []apiv2.Product{}, list: pagedByKeyset},
+	{method: http.MethodGet, path: "/api/v2/products/:id", id: "getProductV2", summary: "Product by ID or slug; a retired slug redirects", tag: "products", auth: authOptional, query: []param{localeParam}, data: apiv2.Product{}, redirect: http.StatusMovedPermanently},
+	{method: http.MethodPost, path: "/api/v2/orders", id: "createOrderV2", summary: "Place an order", tag: "orders", auth: authRequired, body: commands.CreateOrderCommand{}, status: http.StatusCreated, data: apiv2.Order{}, idempotent: true},
+	{method: http.MethodGet, path: "/api/v2/orders", id: "listOrdersV2", summary: "Signed-in customer's orders", tag: "orders", auth: authRequired, data: []apiv2.Order{}, list: pagedByKeyset,
+		query: append([]param{{"sort", "string", "Sort key, - prefixed for descending"}}, orderFilterParams...)},
+	{method: http.MethodGet, path: "/api/v2/orders/:id", id: "getOrderV2", summary: "Order", tag: "orders", auth: authRequired, data: apiv2.Order{}},
+	{method: http.MethodPut, path: "/api/v2/orders/:id/cancel", id: "cancelOrderV2", summary: "Cancel an order", tag: "orders", auth: authRequired, body: CancelOrderRequest{}, optionalBody: true, data: apiv2.Order{}},
+
+	{method: http.MethodGet, path: "/admin/dashboard", id: "adminGetDashboard", summary: "Sales, orders and customers at a glance", tag: "admin reports", auth: authRequired, data: dashboard.Stats{}},
+	{method: http.MethodGet, path: "/admin/orders/cancellations", id: "adminGetCancellationReport", summary: "Cancelled orders by reason, with the fees kept and the amounts refunded", tag: "admin reports", auth: authRequired, query: reportRangeParams, data: queries.CancellationReport{}},
+	{method: http.MethodGet, path: "/admin/analytics/sales", id: "adminGetSalesAnalytics", summary: "Sales over a range", tag: "admin reports", auth: authRequired, query: reportRangeParams, data: queries.SalesSummary{}},
+	{method: http.MethodGet, path: "/admin/analytics/products", id: "adminGetProductAnalytics", summary: "Best selling products over a range", tag: "admin reports", auth: authRequired, data: []analytics.ProductStat{},
+		query: append([]param{{"sort_by", "string", "revenue, the default, or another stat to rank by"}, limitParam}, reportRangeParams...)},
+	{method: http.MethodGet, path: "/admin/analytics/users", id: "adminGetUserAnalytics", summary: "Active customers and cohorts over a range", tag: "admin reports", auth: authRequired, query: reportRangeParams, data: queries.UserAnalytics{}},
+	{method: http.MethodGet, path: "/admin/analytics/revenue", id: "adminGetRevenueAnalytics", summary: "Revenue per interval over a range", tag: "admin reports", auth: authRequired, query: reportRangeParams, data: map[string]interface{}{}},
+
+	{method: http.MethodPut, path: "/admin/users/:id/age-verification", id: "adminVerifyUserAge", summary: "Record a customer's checked date of birth", tag: "admin users", auth: authRequired, body: commands.VerifyUserAgeCommand{}, data: user.User{}},
+	{method: http.MethodPost, path: "/admin/users/:id/impersonate", id: "adminStartImpersonation", summary: "Act as a customer with a short lived token", tag: "admin users", auth: authRequired, body: commands.StartImpersonationCommand{}, status: http.StatusCreated, data: ImpersonationToken{}},
+	{method: http.MethodGet, path: "/admin/impersonations", id: "adminListImpersonations", summary: "Impersonation grants, newest first", tag: "admin users", auth: authRequired, data: []*impersonation.Grant{}, list: pagedByOffset,
+		query: []param{{"admin_id", "string", ""}, {"customer_id", "string", ""}, {"active", "boolean", "Only grants that have not ended or expired"}}},
+	{method: http.MethodPost, path: "/admin/impersonations/:id/end", id: "adminEndImpersonation", summary: "End an impersonation grant", tag: "admin users", auth: authRequired, data: impersonation.Grant{}},
+
+	{method: http.MethodPut, path: "/admin/products/:id/slug", id: "adminUpdateProductSlug", summary: "Change a product's slug, redirecting the old one", tag: "admin catalog", auth: authRequired, body: commands.UpdateProductSlugCommand{}, data: product.Product{}},
+	{method: http.MethodPut, path: "/admin/products/:id/featured", id: "adminSetProductFeatured", summary: "Feature a product or stop featuring it", tag: "admin catalog", auth: authRequired, body: commands.SetProductFeaturedCommand{}, data: product.Product{}},
+	{method: http.MethodPut, path: "/admin/products/:id/attributes", id: "adminSetProductAttributes", summary: "Set a product's attributes", tag: "admin catalog", auth: authRequired, body: commands.SetProductAttributesCommand{}, data: product.Product{}},
+	{method: http.MethodPut, path: "/admin/products/:id/bundle", id: "adminSetProductBundle", summary: "Set the products a bundle is made of", tag: "admin catalog", auth: authRequired, body: commands.SetProductBundleCommand{}, data: product.Product{}},
+	{method: http.MethodPut, path: "/admin/products/:id/purchase-limits", id: "adminSetPurchaseLimits", summary: "Set how many of a product a customer may buy", tag: "admin catalog", auth: authRequired, body: commands.SetProductPurchaseLimitsCommand{}, data: product.Product{}},
+	{method: http.MethodPost, path: "/admin/products/:id/activate", id: "adminActivateProduct", summary: "Put a product on sale", tag: "admin catalog", auth: authRequired, data: product.Product{}},
+	{method: http.MethodPost, path: "/admin/products/:id/deactivate", id: "adminDeactivateProduct", summary: "Take a product off sale", tag: "admin catalog", auth: authRequired, data: product.Product{}},
+	{method: http.MethodPut, path: "/admin/products/:id/status", id: "adminSetProductStatus", summary: "Set a product's status", tag: "admin catalog", auth: authRequired, body: commands.SetProductStatusCommand{}, data: product.Product{}},
+	{method: http.MethodPut, path: "/admin/products/:id/publish-at", id: "adminScheduleProductPublish", summary: "Publish a draft product at a time", tag: "admin catalog", auth: authRequired, body: commands.ScheduleProductPublishCommand{}, data: product.Product{}},
+	{method: http.MethodPost, path: "/admin/products/:id/duplicate", id: "adminDuplicateProduct", summary: "Copy a product as a new draft", tag: "admin catalog", auth: authRequired, body: commands.DuplicateProductCommand{}, optionalBody: true, status: http.StatusCreated, data: product.Product{}},
+	{method: http.MethodGet, path: "/admin/products/:id/versions", id: "adminListProductVersions", summary: "Saved versions of a product, newest first", tag: "admin catalog", auth: authRequired, data: []*productversion.Version{}, list: pagedByOffset},
+	{method: http.MethodGet, path: "/admin/products/:id/versions/:version", id: "adminGetProductVersion", summary: "Saved version of a product", tag: "admin catalog", auth: authRequired, data: productversion.Version{}},
+	{method: http.MethodGet, path: "/admin/products/:id/versions/:version/diff", id: "adminDiffProductVersions", summary: "Changes between a version and a later one or the current product", tag: "admin catalog", auth: authRequired, data: productversion.Comparison{},
+		query: []param{{"to", "integer", "Version to compare with, the current product when left out"}}},
+	{method: http.MethodPost, path: "/admin/products/:id/versions/:version/rollback", id: "adminRollbackProduct", summary: "Restore a product to a saved version", tag: "admin catalog", auth: authRequired, data: product.Product{}},
+	{method: http.MethodGet, path: "/admin/products/:id/translations", id: "adminListProductTranslations", summary: "Translations of a product", tag: "admin catalog", auth: authRequired, data: []*product.Translation{}},
+	{method: http.MethodPut, path: "/admin/products/:id/translations/:locale", id: "adminSetProductTranslation", summary: "Translate a product into a locale", tag: "admin catalog", auth: authRequired, body: commands.SetProductTranslationCommand{}, data: product.Translation{}},
+	{method: http.MethodDelete, path: "/admin/products/:id/translations/:locale", id: "adminDeleteProductTranslation", summary: "Remove a product's translation", tag: "admin catalog", auth: authRequired, status: http.StatusNoContent},
+	{method: http.MethodGet, path: "/admin/products/:id/badges", id: "adminListProductBadges", summary: "Badges shown on a product", tag: "admin catalog", auth: authRequired, data: []*badge.Assignment{}},
+	{method: http.MethodPost, path: "/admin/products/:id/badges", id: "adminAssignProductBadge", summary: "Show a badge on a product", tag: "admin catalog", auth: authRequired, body: commands.AssignProductBadgeCommand{}, status: http.StatusCreated, data: badge.Assignment{}},
+	{method: http.MethodDelete, path: "/admin/products/:id/badges/:code", id: "adminUnassignProductBadge", summary: "Stop showing a badge on a product", tag: "admin catalog", auth: authRequired, status: http.StatusNoContent},
+	{method: http.MethodPut, path: "/admin/categories/:id/slug", id: "adminUpdateCategorySlug", summary: "Change a category's slug, redirecting the old one", tag: "admin catalog", auth: authRequired, body: commands.UpdateCategorySlugCommand{}, data: product.Category{}},
+	{method: http.MethodPut, path: "/admin/categories/:id/attributes", id: "adminSetCategoryAttributes", summary: "Set the attributes products of a category have", tag: "admin catalog", auth: authRequired, body: commands.SetCategoryAttributesCommand{}, data: []*product.AttributeDefinition{}},
+	{method: http.MethodPut, path: "/admin/categories/:id/minimum-age", id: "adminSetCategoryMinimumAge", summary: "Set the age a customer must be to buy from a category", tag: "admin catalog", auth: authRequired, body: commands.SetCategoryMinimumAgeCommand{}, data: product.Category{}},
+	{method: http.MethodGet, path: "/admin/categories/:id/translations", id: "adminListCategoryTranslations", summary: "Translations of a category", tag: "admin catalog", auth: authRequired, data: []*product.CategoryTranslation{}},
+	{method: http.MethodPut, path: "/admin/categories/:id/translations/:locale", id: "adminSetCategoryTranslation", summary: "Translate a category into a locale", tag: "admin catalog", auth: authRequired, body: commands.SetCategoryTranslationCommand{}, data: product.CategoryTranslation{}},
+	{method: http.MethodDelete, path: "/admin/categories/:id/translations/:locale", id: "adminDeleteCategoryTranslation", summary: "Remove a category's translation", tag: "admin catalog", auth: authRequired, status: http.StatusNoContent},
+	{method: http.MethodGet, path: "/admin/translations", id: "adminGetTranslationReport", summary: "How much of the catalog each locale covers", tag: "admin catalog", auth: authRequired, data: []product.TranslationCoverage{}},
+	{method: http.MethodGet, path: "/admin/translations/missing", id: "adminListMissingTranslations", summary: "Products and categories not translated into a locale", tag: "admin catalog", auth: authRequired, query: []param{{"locale", "string", ""}}, data: queries.MissingTranslations{}, list: pagedByOffset},
+	{method: http.MethodGet, path: "/admin/merchandising/rules", id: "adminListMerchandisingRules", summary: "Search merchandising rules", tag: "admin catalog", auth: authRequired, query: []param{{"kind", "string", ""}}, data: []*merchandising.Rule{}, list: pagedByOffset},
+	{method: http.MethodPost, path: "/admin/merchandising/rules", id: "adminCreateMerchandisingRule", summary: "Pin, boost or bury products in searches", tag: "admin catalog", auth: authRequired, body: commands.CreateMerchandisingRuleCommand{}, status: http.StatusCreated, data: merchandising.Rule{}},
+	{method: http.MethodPut, path: "/admin/merchandising/rules/:id", id: "adminUpdateMerchandisingRule", summary: "Change a merchandising rule", tag: "admin catalog", auth: authRequired, body: commands.UpdateMerchandisingRuleCommand{}, data: merchandising.Rule{}},
+	{method: http.MethodDelete, path: "/admin/merchandising/rules/:id", id: "adminDeleteMerchandisingRule", summary: "Delete a merchandising rule", tag: "admin catalog", auth: authRequired, status: http.StatusNoContent},
+	{method: http.MethodGet, path: "/admin/merchandising/preview", id: "adminPreviewMerchandising", summary: "Rules applying to a search and the ranking they give it", tag: "admin catalog", auth: authRequired, data: queries.MerchandisingPreview{},
+		query: []param{{"q", "string", "Full text query"}, {"category_id", "string", ""}, {"at", "string", "Time to preview at (RFC 3339), now when left out"}}},
+	{method: http.MethodGet, path: "/admin/badges", id: "adminListBadges", summary: "Badge definitions", tag: "admin catalog", auth: authRequired, data: []*badge.Definition{}},
+	{method: http.MethodPost, path: "/admin/badges", id: "adminCreateBadge", summary: "Define a badge", tag: "admin catalog", auth: authRequired, body: commands.CreateBadgeCommand{}, status: http.StatusCreated, data: badge.Definition{}},
+	{method: http.MethodPut, path: "/admin/badges/:code", id: "adminUpdateBadge", summary: "Change a badge", tag: "admin catalog", auth: authRequired, body: commands.UpdateBadgeCommand{}, data: badge.Definition{}},
+	{method: http.MethodDelete, path: "/admin/badges/:code", id: "adminDeleteBadge", summary: "Delete a badge", tag: "admin catalog", auth: authRequired, status: http.StatusNoContent},
+	{method: http.MethodGet, path: "/admin/product-submissions", id: "adminListProductSubmissions", summary: "Merchant product changes sent for review", tag: "admin catalog", auth: authRequired, data: []*moderation.Submission{}, list: pagedByOffset,
+		query: []param{{"product_id", "string", ""}, {"status", "string", ""}}},
+	{method: http.MethodGet, path: "/admin/product-submissions/:id", id: "adminGetProductSubmission", summary: "Product change sent for review", tag: "admin catalog", auth: authRequired, data: moderation.Submission{}},
+	{method: http.MethodPost, path: "/admin/product-submissions/:id/approve", id: "adminApproveProductSubmission", summary: "Approve and apply a product change", tag: "admin catalog", auth: authRequired, body: commands.ReviewProductSubmissionCommand{}, optionalBody: true, data: moderation.Submission{}},
+	{method: http.MethodPost, path: "/admin/product-submissions/:id/reject", id: "adminRejectProductSubmission", summary: "Reject a product change", tag: "admin catalog", auth: authRequired, body: commands.ReviewProductSubmissionCommand{}, optionalBody: true, data: moderation.Submission{}},
+	{method: http.MethodGet, path: "/admin/inventory/reorder-suggestions", id: "adminListReorderSuggestions", summary: "Stock to reorder before it runs out, by the sales forecast", tag: "admin catalog", auth: authRequired, data: []*forecast.Suggestion{}, list: pagedByOffset,
+		query: []param{{"warehouse_id", "string", ""}, {"product_id", "string", ""}, {"status", "string", ""}}},
+	{method: http.MethodGet, path: "/admin/warehouses", id: "adminListWarehouses", summary: "Warehouses", tag: "admin catalog", auth: authRequired, data: []*warehouse.Warehouse{}, list: pagedByOffset},
+	{method: http.MethodPost, path: "/admin/warehouses", id: "adminCreateWarehouse", summary: "Add a warehouse", tag: "admin catalog", auth: authRequired, body: commands.CreateWarehouseCommand{}, status: http.StatusCreated, data: warehouse.Warehouse{}},
+	{method: http.MethodPut, path: "/admin/warehouses/:id", id: "adminUpdateWarehouse", summary: "Change a warehouse", tag: "admin catalog", auth: authRequired, body: commands.UpdateWarehouseCommand{}, data: warehouse.Warehouse{}},
+	{method: http.MethodGet, path: "/admin/warehouses/:id/stock", id: "adminGetWarehouseStock", summary: "Stock held in a warehouse", tag: "admin catalog", auth: authRequired, data: []*warehouse.Stock{}, list: pagedByOffset},
+	{method: http.MethodPut, path: "/admin/warehouses/:id/stock/:product_id", id: "adminSetWarehouseStock", summary: "Set the stock of a product in a warehouse", tag: "admin catalog", auth: authRequired, body: commands.SetWarehouseStockCommand{}, data: Message{}},
+
+	{method: http.MethodGet, path: "/admin/orders", id: "adminListOrders", summary: "Orders of every customer", tag: "admin orders", auth: authRequired, data: []*order.Order{}, list: pagedByKeyset,
+		query: append([]param{
+			{"sort", "string", "Sort key, - prefixed for descending"},
+			{"email", "string", "Email of the customer"},
+			{"payment_method", "string", ""},
+			{"merchant_id", "string", ""},
+			{"min_amount", "number", ""},
+			{"max_amount", "number", ""},
+		}, orderFilterParams...)},
+	{method: http.MethodPost, path: "/admin/orders", id: "adminCreateOrder", summary: "Place an order on a customer's behalf", tag: "admin orders", auth: authRequired, body: commands.CreateManualOrderCommand{}, status: http.StatusCreated, data: commands.ManualOrder{}},
+	{method: http.MethodGet, path: "/admin/orders/:id", id: "adminGetOrder", summary: "Order", tag: "admin orders", auth: authRequired, data: order.Order{}},
+	{method: http.MethodPost, path: "/admin/orders/:id/offline-payments", id: "adminRecordOfflinePayment", summary: "Record a payment received outside the gateway", tag: "admin orders", auth: authRequired, body: commands.RecordOfflinePaymentCommand{}, status: http.StatusCreated, data: payment.Summary{}},
+	{method: http.MethodGet, path: "/admin/fraud/reviews", id: "adminListFraudReviews", summary: "Orders held for a fraud review", tag: "admin orders", auth: authRequired, query: []param{{"status", "string", ""}}, data: []*fraud.Assessment{}, list: pagedByOffset},
+	{method: http.MethodGet, path: "/admin/fraud/reviews/:id", id: "adminGetFraudReview", summary: "Fraud assessment of an order", tag: "admin orders", auth: authRequired, data: fraud.Assessment{}},
+	{method: http.MethodPost, path: "/admin/fraud/reviews/:id/approve", id: "adminApproveFraudReview", summary: "Release a held order", tag: "admin orders", auth: authRequired, body: commands.ReviewOrderCommand{}, optionalBody: true, data: fraud.Assessment{}},
+	{method: http.MethodPost, path: "/admin/fraud/reviews/:id/decline", id: "adminDeclineFraudReview", summary: "Cancel a held order", tag: "admin orders", auth: authRequired, body: commands.ReviewOrderCommand{}, optionalBody: true, data: fraud.Assessment{}},
+
+	{method: http.MethodGet, path: "/admin/commissions", id: "adminListCommissionRules", summary: "Commission rules", tag: "admin payments", auth: authRequired, data: []*commission.Rule{}, list: pagedByOffset},
+	{method: http.MethodPost, path: "/admin/commissions", id: "adminCreateCommissionRule", summary: "Add a commission rule", tag: "admin payments", auth: authRequired, body: commands.CreateCommissionRuleCommand{}, status: http.StatusCreated, data: commission.Rule{}},
+	{method: http.MethodPut, path: "/admin/commissions/:id", id: "adminUpdateCommissionRule", summary: "Change a commission rule", tag: "admin payments", auth: authRequired, body: commands.UpdateCommissionRuleCommand{}, data: commission.Rule{}},
+	{method: http.MethodDelete, path: "/admin/commissions/:id", id: "adminDeleteCommissionRule", summary: "Delete a commission rule", tag: "admin payments", auth: authRequired, data: Message{}},
+	{method: http.MethodGet, path: "/admin/reconciliation/runs", id: "adminListReconciliationRuns", summary: "Runs matching payments against the gateway's settlements", tag: "admin payments", auth: authRequired, data: []*reconciliation.Run{}, list: pagedByOffset},
+	{method: http.MethodPost, path: "/admin/reconciliation/runs", id: "adminTriggerReconciliation", summary: "Start a reconciliation run", tag: "admin payments", auth: authRequired, body: commands.TriggerReconciliationCommand{}, optionalBody: true, status: http.StatusAccepted, data: reconciliation.Run{}},
+	{method: http.MethodGet, path: "/admin/reconciliation/runs/:id", id: "adminGetReconciliationRun", summary: "Reconciliation run and its mismatches", tag: "admin payments", auth: authRequired, data: reconciliation.Run{}},
+	{method: http.MethodGet, path: "/admin/cod/collections", id: "adminListCODCollections", summary: "Cash on delivery collections", tag: "admin payments", auth: authRequired, data: []*cod.Collection{}, list: pagedByOffset,
+		query: []param{{"status", "string", ""}, {"carrier", "string", ""}}},
+	{method: http.MethodGet, path: "/admin/cod/couriers", id: "adminListCourierBalances", summary: "Cash each carrier holds for the shop", tag: "admin payments", auth: authRequired, data: []cod.CourierBalance{}},
+	{method: http.MethodGet, path: "/admin/cod/remittances", id: "adminListRemittances", summary: "Cash handed over by carriers", tag: "admin payments", auth: authRequired, query: []param{{"carrier", "string", ""}}, data: []*cod.Remittance{}, list: pagedByOffset},
+	{method: http.MethodPost, path: "/admin/cod/remittances", id: "adminRecordRemittance", summary: "Record cash handed over by a carrier", tag: "admin payments", auth: authRequired, body: commands.RecordRemittanceCommand{}, status: http.StatusCreated, data: cod.Remittance{}},
+	{method: http.MethodGet, path: "/admin/cod/remittances/:id", id: "adminGetRemittance", summary: "Remittance", tag: "admin payments", auth: authRequired, data: cod.Remittance{}},
+	{method: http.MethodGet, path: "/admin/cod/customers/:id", id: "adminGetCODCustomer", summary: "A customer's cash on delivery record and limit", tag: "admin payments", auth: authRequired, data: queries.CODCustomer{}},
+	{method: http.MethodPut, path: "/admin/cod/customers/:id", id: "adminSetCODLimit", summary: "Set or lift a customer's cash on delivery limit", tag: "admin payments", auth: authRequired, body: commands.SetCODLimitCommand{}, data: cod.CustomerLimit{}},
+	{method: http.MethodGet, path: "/admin/organizations", id: "adminListOrganizations", summary: "Business accounts", tag: "admin payments", auth: authRequired, data: []*organization.Organization{}, list: pagedByOffset},
+	{method: http.MethodPut, path: "/admin/organizations/:id/credit", id: "adminSetOrganizationCredit", summary: "Set an organization's credit limit and payment terms", tag: "admin payments", auth: authRequired, body: commands.SetOrganizationCreditCommand{}, data: organization.Organization{}},
+	{method: http.MethodGet, path: "/admin/organizations/invoices", id: "adminListInvoices", summary: "Invoices of every organization, soonest due first", tag: "admin payments", auth: authRequired, data: []*organization.Invoice{}, list: pagedByOffset,
+		query: []param{{"organization_id", "string", ""}, {"status", "string", ""}}},
+	{method: http.MethodPost, path: "/admin/organizations/invoices/:id/pay", id: "adminRecordInvoicePayment", summary: "Record a payment of an invoice", tag: "admin payments", auth: authRequired, body: commands.RecordInvoicePaymentCommand{}, data: organization.Invoice{}},
+
+	{method: http.MethodGet, path: "/admin/banners", id: "adminListBanners", summary: "Banners", tag: "admin content", auth: authRequired, data: []*banner.Banner{}, list: pagedByOffset},
+	{method: http.MethodPost, path: "/admin/banners", id: "adminCreateBanner", summary: "Add a banner", tag: "admin content", auth: authRequired, body: commands.CreateBannerCommand{}, status: http.StatusCreated, data: banner.Banner{}},
+	{method: http.MethodPut, path: "/admin/banners/:id", id: "adminUpdateBanner", summary: "Change a banner", tag: "admin content", auth: authRequired, body: commands.UpdateBannerCommand{}, data: banner.Banner{}},
+	{method: http.MethodDelete, path: "/admin/banners/:id", id: "adminDeleteBanner", summary: "Delete a banner", tag: "admin content", auth: authRequired, data: Message{}},
+	{method: http.MethodGet, path: "/admin/cms/blocks", id: "adminListContentBlocks", summary: "Content blocks, published or not", tag: "admin content", auth: authRequired, query: []param{{"slot", "string", ""}}, data: []*cms.Block{}, list: pagedByOffset},
+	{method: http.MethodPost, path: "/admin/cms/blocks", id: "adminCreateContentBlock", summary: "Add a content block to a slot", tag: "admin content", auth: authRequired, body: commands.CreateCMSBlockCommand{}, status: http.StatusCreated, data: cms.Block{}},
+	{method: http.MethodPut, path: "/admin/cms/blocks/:id", id: "adminUpdateContentBlock", summary: "Change a content block", tag: "admin content", auth: authRequired, body: commands.UpdateCMSBlockCommand{}, data: cms.Block{}},
+	{method: http.MethodDelete, path: "/admin/cms/blocks/:id", id: "adminDeleteContentBlock", summary: "Delete a content block", tag: "admin content", auth: authRequired, data: Message{}},
+	{method: http.MethodGet, path: "/admin/cms/pages", id: "adminListContentPages", summary: "Content pages, published or not", tag: "admin content", auth: authRequired, data: []*cms.Page{}, list: pagedByOffset},
+	{method: http.MethodPost, path: "/admin/cms/pages", id: "adminCreateContentPage", summary: "Add a content page", tag: "admin content", auth: authRequired, body: commands.CreateCMSPageCommand{}, status: http.StatusCreated, data: cms.Page{}},
+	{method: http.MethodGet, path: "/admin/cms/pages/:id", id: "adminGetContentPage", summary: "Content page", tag: "admin content", auth: authRequired, data: cms.Page{}},
+	{method: http.MethodPut, path: "/admin/cms/pages/:id", id: "adminUpdateContentPage", summary: "Change a content page", tag: "admin content", auth: authRequired, body: commands.UpdateCMSPageCommand{}, data: cms.Page{}},
+	{method: http.MethodDelete, path: "/admin/cms/pages/:id", id: "adminDeleteContentPage", summary: "Delete a content page", tag: "admin content", auth: authRequired, data: Message{}},
+	{method: http.MethodGet, path: "/admin/cms/assets", id: "adminListContentAssets", summary: "Uploaded images and files", tag: "admin content", auth: authRequired, data: []*cms.Asset{}, list: pagedByOffset},
+	{method: http.MethodPost, path: "/admin/cms/assets", id: "adminUploadContentAsset", summary: "Upload an image or file as the multipart field file", tag: "admin content", auth: authRequired, status: http.StatusCreated, data: cms.Asset{}},
+	{method: http.MethodDelete, path: "/admin/cms/assets/:id", id: "adminDeleteContentAsset", summary: "Delete an uploaded asset", tag: "admin content", auth: authRequired, data: Message{}},
+	{method: http.MethodGet, path: "/admin/storefront/settings", id: "adminGetStorefrontSettings", summary: "Store name, theme, currencies and locales", tag: "admin content", auth: authRequired, data: storefront.Settings{}},
+	{method: http.MethodPut, path: "/admin/storefront/settings", id: "adminUpdateStorefrontSettings", summary: "Change the store's name, theme, currencies or locales", tag: "admin content", auth: authRequired, body: commands.UpdateStorefrontSettingsCommand{}, data: storefront.Settings{}},
+	{method: http.MethodGet, path: "/admin/shipping/zones", id: "adminListShippingZones", summary: "Shipping zones and their rates", tag: "admin content", auth: authRequired, data: []*shipping.Zone{}, list: pagedByOffset},
+	{method: http.MethodPost, path: "/admin/shipping/zones", id: "adminCreateShippingZone", summary: "Add a shipping zone", tag: "admin content", auth: authRequired, body: commands.CreateShippingZoneCommand{}, status: http.StatusCreated, data: shipping.Zone{}},
+	{method: http.MethodPut, path: "/admin/shipping/zones/:id", id: "adminUpdateShippingZone", summary: "Change a shipping zone", tag: "admin content", auth: authRequired, body: commands.UpdateShippingZoneCommand{}, data: shipping.Zone{}},
+	{method: http.MethodDelete, path: "/admin/shipping/zones/:id", id: "adminDeleteShippingZone", summary: "Delete a shipping zone", tag: "admin content", auth: authRequired, data: Message{}},
+
+	{method: http.MethodGet, path: "/admin/flash-sales", id: "adminListFlashSales", summary: "Flash sales, past ones included", tag: "admin marketing", auth: authRequired, data: []*flashsale.Sale{}, list: pagedByOffset},
+	{method: http.MethodPost, path: "/admin/flash-sales", id: "adminCreateFlashSale", summary: "Schedule a flash sale", tag: "admin marketing", auth: authRequired, body: commands.CreateFlashSaleCommand{}, status: http.StatusCreated, data: flashsale.Sale{}},
+	{method: http.MethodGet, path: "/admin/flash-sales/:id", id: "adminGetFlashSale", summary: "Flash sale", tag: "admin marketing", auth: authRequired, data: flashsale.Sale{}},
+	{method: http.MethodPut, path: "/admin/flash-sales/:id", id: "adminUpdateFlashSale", summary: "Change a flash sale", tag: "admin marketing", auth: authRequired, body: commands.UpdateFlashSaleCommand{}, data: flashsale.Sale{}},
+	{method: http.MethodDelete, path: "/admin/flash-sales/:id", id: "adminDeleteFlashSale", summary: "Delete a flash sale", tag: "admin marketing", auth: authRequired, data: Message{}},
+	{method: http.MethodGet, path: "/admin/experiments", id: "adminListExperiments", summary: "Experiments", tag: "admin marketing", auth: authRequired, query: []param{{"status", "string", ""}}, data: []*experiment.Experiment{}, list: pagedByOffset},
+	{method: http.MethodPost, path: "/admin/experiments", id: "adminCreateExperiment", summary: "Set up an experiment and its variants", tag: "admin marketing", auth: authRequired, body: commands.CreateExperimentCommand{}, status: http.StatusCreated, data: experiment.Experiment{}},
+	{method: http.MethodPut, path: "/admin/experiments/:id", id: "adminUpdateExperiment", summary: "Change, start or stop an experiment", tag: "admin marketing", auth: authRequired, body: commands.UpdateExperimentCommand{}, data: experiment.Experiment{}},
+	{method: http.MethodGet, path: "/admin/experiments/:id/report", id: "adminGetExperimentReport", summary: "Exposures and conversions of each variant", tag: "admin marketing", auth: authRequired, query: reportRangeParams, data: experiment.Report{}},
+	{method: http.MethodGet, path: "/admin/campaigns", id: "adminListCampaigns", summary: "Email campaigns", tag: "admin marketing", auth: authRequired, query: []param{{"status", "string", ""}}, data: []*campaign.Campaign{}, list: pagedByOffset},
+	{method: http.MethodPost, path: "/admin/campaigns", id: "adminCreateCampaign", summary: "Draft an email campaign", tag: "admin marketing", auth: authRequired, body: commands.CreateCampaignCommand{}, status: http.StatusCreated, data: campaign.Campaign{}},
+	{method: http.MethodGet, path: "/admin/campaigns/:id", id: "adminGetCampaign", summary: "Email campaign", tag: "admin marketing", auth: authRequired, data: campaign.Campaign{}},
+	{method: http.MethodPut, path: "/admin/campaigns/:id", id: "adminUpdateCampaign", summary: "Change a draft campaign", tag: "admin marketing", auth: authRequired, body: commands.UpdateCampaignCommand{}, data: campaign.Campaign{}},
+	{method: http.MethodPost, path: "/admin/campaigns/:id/schedule", id: "adminScheduleCampaign", summary: "Send a campaign at send_at, or on the next run of the campaign job without a body", tag: "admin marketing", auth: authRequired, body: commands.ScheduleCampaignCommand{}, optionalBody: true, data: campaign.Campaign{}},
+	{method: http.MethodPost, path: "/admin/campaigns/:id/cancel", id: "adminCancelCampaign", summary: "Cancel a scheduled campaign", tag: "admin marketing", auth: authRequired, data: campaign.Campaign{}},
+	{method: http.MethodGet, path: "/admin/campaigns/:id/stats", id: "adminGetCampaignStats", summary: "Sends, opens and clicks of a campaign", tag: "admin marketing", auth: authRequired, data: campaign.Stats{}},
+	{method: http.MethodGet, path: "/admin/campaigns/:id/audience", id: "adminCountCampaignAudience", summary: "How many customers a campaign would reach", tag: "admin marketing", auth: authRequired, data: queries.CampaignAudience{}},
+	{method: http.MethodPost, path: "/admin/email-campaigns", id: "adminSendEmailCampaign", summary: "Send an email template to a list of recipients", tag: "admin marketing", auth: authRequired, body: commands.SendEmailCampaignCommand{}, status: http.StatusAccepted, data: commands.EmailCampaign{}},
+
+	{method: http.MethodGet, path: "/admin/email-templates", id: "adminListEmailTemplates", summary: "Email templates and their active versions", tag: "admin messaging", auth: authRequired, data: []*emailtemplate.Template{}, list: pagedByOffset},
+	{method: http.MethodGet, path: "/admin/email-templates/:name", id: "adminGetEmailTemplate", summary: "Active or given version of an email template", tag: "admin messaging", auth: authRequired, data: emailtemplate.Template{},
+		query: []param{{"locale", "string", ""}, {"version", "integer", ""}}},
+	{method: http.MethodPut, path: "/admin/email-templates/:name", id: "adminSaveEmailTemplate", summary: "Save a new version of an email template", tag: "admin messaging", auth: authRequired, body: commands.SaveEmailTemplateCommand{}, status: http.StatusCreated, data: emailtemplate.Template{}},
+	{method: http.MethodDelete, path: "/admin/email-templates/:name", id: "adminDeleteEmailTemplate", summary: "Delete an email template, falling back to the default", tag: "admin messaging", auth: authRequired, query: []param{{"locale", "string", ""}}, data: Message{}},
+	{method: http.MethodGet, path: "/admin/email-templates/:name/versions", id: "adminListEmailTemplateVersions", summary: "Saved versions of an email template", tag: "admin messaging", auth: authRequired, query: []param{{"locale", "string", ""}}, data: []*emailtemplate.Template{}},
+	{method: http.MethodPost, path: "/admin/email-templates/:name/versions/:version/activate", id: "adminActivateEmailTemplateVersion", summary: "Send emails with a saved version", tag: "admin messaging", auth: authRequired, query: []param{{"locale", "string", ""}}, data: emailtemplate.Template{}},
+	{method: http.MethodPost, path: "/admin/email-templates/:name/preview", id: "adminPreviewEmailTemplate", summary: "Render an email template with sample data", tag: "admin messaging", auth: authRequired, body: queries.PreviewEmailTemplateQuery{}, optionalBody: true, data: queries.EmailTemplatePreview{}},
+	{method: http.MethodPost, path: "/admin/email-templates/:name/test", id: "adminSendTestEmail", summary: "Send an email template to an address", tag: "admin messaging", auth: authRequired, body: commands.SendTestEmailCommand{}, status: http.StatusAccepted, data: map[string]interface{}{}},
+	{method: http.MethodGet, path: "/admin/email-suppressions", id: "adminListEmailSuppressions", summary: "Addresses emails are no longer sent to", tag: "admin messaging", auth: authRequired, data: []*emaildelivery.Suppression{}, list: pagedByOffset},
+	{method: http.MethodPost, path: "/admin/email-suppressions", id: "adminAddEmailSuppression", summary: "Stop sending emails to an address", tag: "admin messaging", auth: authRequired, body: commands.AddEmailSuppressionCommand{}, status: http.StatusCreated, data: emaildelivery.Suppression{}},
+	{method: http.MethodGet, path: "/admin/email-suppressions/:email", id: "adminGetEmailSuppression", summary: "Why an address is suppressed", tag: "admin messaging", auth: authRequired, data: emaildelivery.Suppression{}},
+	{method: http.MethodDelete, path: "/admin/email-suppressions/:email", id: "adminRemoveEmailSuppression", summary: "Send emails to an address again", tag: "admin messaging", auth: authRequired, data: Message{}},
+	{method: http.MethodGet, path: "/admin/email-dead-letters", id: "adminListEmailDeadLetters", summary: "Emails the worker gave up on", tag: "admin messaging", auth: authRequired, data: []*emaildelivery.DeadLetter{}, list: pagedByOffset,
+		query: []param{{"pending", "boolean", "Leave out the ones already resent"}}},
+	{method: http.MethodGet, path: "/admin/email-dead-letters/:id", id: "adminGetEmailDeadLetter", summary: "Email the worker gave up on", tag: "admin messaging", auth: authRequired, data: emaildelivery.DeadLetter{}},
+	{method: http.MethodPost, path: "/admin/email-dead-letters/:id/resend", id: "adminResendEmailDeadLetter", summary: "Queue a failed email again", tag: "admin messaging", auth: authRequired, status: http.StatusAccepted, data: emaildelivery.DeadLetter{}},
+	{method: http.MethodGet, path: "/admin/whatsapp-templates", id: "adminListWhatsAppTemplates", summary: "WhatsApp message templates", tag: "admin messaging", auth: authRequired, data: []*whatsapp.Template{}, list: pagedByOffset},
+	{method: http.MethodPost, path: "/admin/whatsapp-templates", id: "adminCreateWhatsAppTemplate", summary: "Submit a WhatsApp template for approval", tag: "admin messaging", auth: authRequired, body: commands.CreateWhatsAppTemplateCommand{}, status: http.StatusCreated, data: whatsapp.Template{}},
+	{method: http.MethodPost, path: "/admin/whatsapp-templates/sync", id: "adminSyncWhatsAppTemplates", summary: "Fetch the templates and their approval from WhatsApp", tag: "admin messaging", auth: authRequired, data: commands.SyncWhatsAppTemplatesResult{}},
+	{method: http.MethodGet, path: "/admin/whatsapp-templates/:name/:language", id: "adminGetWhatsAppTemplate", summary: "WhatsApp template in a language", tag: "admin messaging", auth: authRequired, data: whatsapp.Template{}},
+	{method: http.MethodDelete, path: "/admin/whatsapp-templates/:name", id: "adminDeleteWhatsAppTemplate", summary: "Delete a WhatsApp template in every language", tag: "admin messaging", auth: authRequired, data: Message{}},
+	{method: http.MethodGet, path: "/admin/whatsapp-messages", id: "adminListWhatsAppMessages", summary: "WhatsApp messages sent and their delivery", tag: "admin messaging", auth: authRequired, data: []*whatsapp.Message{}, list: pagedByOffset},
+	{method: http.MethodGet, path: "/admin/whatsapp-messages/:id", id: "adminGetWhatsAppMessage", summary: "WhatsApp message", tag: "admin messaging", auth: authRequired, data: whatsapp.Message{}},
+
+	{method: http.MethodPost, path: "/admin/exports", id: "adminRequestExport", summary: "Export data to a file", tag: "admin system", auth: authRequired, body: commands.RequestExportCommand{}, status: http.StatusAccepted, data: export.Export{}},
+	{method: http.MethodGet, path: "/admin/exports", id: "adminListExports", summary: "Exports, newest first", tag: "admin system", auth: authRequired, data: []*export.Export{}, list: pagedByOffset},
+	{method: http.MethodGet, path: "/admin/exports/:id", id: "adminGetExport", summary: "Export and its download link once done", tag: "admin system", auth: authRequired, data: export.Export{}},
+	{method: http.MethodGet, path: "/admin/jobs", id: "adminListJobs", summary: "Background jobs", tag: "admin system", auth: authRequired, data: []*job.Job{}, list: pagedByOffset,
+		query: []param{{"queue", "string", ""}, {"type", "string", ""}, {"state", "string", ""}}},
+	{method: http.MethodGet, path: "/admin/jobs/:id", id: "adminGetJob", summary: "Background job", tag: "admin system", auth: authRequired, data: job.Job{}},
+	{method: http.MethodPost, path: "/admin/jobs/:id/retry", id: "adminRetryJob", summary: "Queue a failed job again", tag: "admin system", auth: authRequired, status: http.StatusAccepted, data: job.Job{}},
+	{method: http.MethodPost, path: "/admin/jobs/:id/cancel", id: "adminCancelJob", summary: "Stop a pending job from running", tag: "admin system", auth: authRequired, data: job.Job{}},
+	{method: http.MethodGet, path: "/admin/audit-logs", id: "adminListAuditLogs", summary: "Who changed what, newest first", tag: "admin system", auth: authRequired, data: []*audit.Entry{}, list: pagedByOffset,
+		query: []param{
+			{"actor_id", "string", ""},
+			{"impersonator_id", "string", ""},
+			{"source", "string", ""},
+			{"action", "string", ""},
+			{"resource_type", "string", ""},
+			{"resource_id", "string", ""},
+			{"from", "string", "Entries from this date or time (RFC 3339)"},
+			{"to", "string", "Entries before this time; a date includes that day"},
+		}},
+	{method: http.MethodGet, path: "/admin/config", id: "adminGetConfig", summary: "Running config, secrets redacted", tag: "admin system", auth: authRequired, data: map[string]interface{}{}},
+	{method: http.MethodGet, path: "/admin/system/logs", id: "adminSearchLogs", summary: "Search the service logs", tag: "admin system", auth: authRequired, data: []*systemlog.Entry{}, list: pagedByOffset,
+		query: []param{
+			{"q", "string", "Text the message contains"},
+			{"level", "string", ""},
+			{"service", "string", ""},
+			{"from", "string", "Entries from this date or time (RFC 3339)"},
+			{"to", "string", "Entries before this time; a date includes that day"},
+		}},
+	{method: http.MethodPost, path: "/admin/system/backup", id: "adminTriggerBackup", summary: "Back up the database now", tag: "admin system", auth: authRequired, status: http.StatusAccepted, data: backup.Backup{}},
+	{method: http.MethodGet, path: "/admin/system/backups", id: "adminListBackups", summary: "Database backups, newest first", tag: "admin system", auth: authRequired, data: []*backup.Backup{}, list: pagedByOffset},
+	{method: http.MethodGet, path: "/admin/system/backups/:id", id: "adminGetBackup", summary: "Database backup", tag: "admin system", auth: authRequired, data: backup.Backup{}},
+	{method: http.MethodGet, path: "/admin/system/health", id: "adminGetSystemHealth", summary: "Health of the service and its backups", tag: "admin system", auth: authRequired, data: map[string]interface{}{}},
+	{method: http.MethodPost, path: "/admin/system/cache/clear", id: "adminClearCache", summary: "Clear a scope of the cache", tag: "admin system", auth: authRequired, body: commands.ClearCacheCommand{}, data: commands.CacheClearResult{}},
+	{method: http.MethodGet, path: "/admin/system/maintenance", id: "adminGetMaintenance", summary: "Maintenance mode and the scheduled windows", tag: "admin system", auth: authRequired, data: queries.MaintenanceView{}},
+	{method: http.MethodPut, path: "/admin/system/maintenance", id: "adminSetMaintenance", summary: "Turn maintenance mode on or off", tag: "admin system", auth: authRequired, body: commands.SetMaintenanceCommand{}, data: maintenance.State{}},
+	{method: http.MethodPost, path: "/admin/system/maintenance/windows", id: "adminScheduleMaintenance", summary: "Schedule a maintenance window", tag: "admin system", auth: authRequired, body: commands.ScheduleMaintenanceCommand{}, status: http.StatusCreated, data: maintenance.Window{}},
+	{method: http.MethodDelete, path: "/admin/system/maintenance/windows/:id", id: "adminCancelMaintenance", summary: "Cancel a maintenance window", tag: "admin system", auth: authRequired, data: Message{}},
+
+	{method: http.MethodGet, path: "/merchant/quotes", id: "merchantListQuotes", summary: "Requests for quotes asked of the merchant, or of every merchant for admins", tag: "merchant", auth: authRequired, query: []param{{"status", "string", ""}}, data: []*quote.Quote{}, list: pagedByOffset},
+	{method: http.MethodGet, path: "/merchant/quotes/:id", id: "merchantGetQuote", summary: "A request for a quote asked of the merchant", tag: "merchant", auth: authRequired, data: quote.Quote{}},
+	{method: http.MethodPost, path: "/merchant/quotes/:id/offer", id: "merchantOfferQuote", summary: "Price a request for a quote", tag: "merchant", auth: authRequired, body: commands.OfferQuoteCommand{}, data: quote.Quote{}},
+	{method: http.MethodPost, path: "/merchant/quotes/:id/decline", id: "merchantDeclineQuote", summary: "Turn a request for a quote down", tag: "merchant", auth: authRequired, body: commands.DeclineQuoteCommand{}, data: quote.Quote{}},
+	{method: http.MethodGet, path: "/merchant/draft-orders", id: "merchantListDraftOrders", summary: "Orders the merchant drafted for customers to pay by link, or every merchant's for admins", tag: "merchant", auth: authRequired, query: []param{{"status", "string", ""}}, data: []*draftorder.Draft{}, list: pagedByOffset},
+	{method: http.MethodPost, path: "/merchant/draft-orders", id: "merchantCreateDraftOrder", summary: "Draft an order for a customer to pay by link", tag: "merchant", auth: authRequired, body: commands.CreateDraftOrderCommand{}, status: http.StatusCreated, data: draftorder.Draft{}},
+	{method: http.MethodGet, path: "/merchant/draft-orders/:id", id: "merchantGetDraftOrder", summary: "Draft order", tag: "merchant", auth: authRequired, data: draftorder.Draft{}},
+	{method: http.MethodPost, path: "/merchant/draft-orders/:id/send", id: "merchantSendDraftOrder", summary: "Email the customer the draft's payment link", tag: "merchant", auth: authRequired, data: draftorder.Draft{}},
+	{method: http.MethodPost, path: "/merchant/draft-orders/:id/cancel", id: "merchantCancelDraftOrder", summary: "Cancel a draft so its link can no longer be paid", tag: "merchant", auth: authRequired, data: draftorder.Draft{}},
+}
+
+// OpenAPI builds the OpenAPI document of the API server. Error codes are
+// read from the catalog, so it is built once every package has defined its
+// errors.
+func OpenAPI() *openapi.Document {
+	g := openapi.NewGenerator()
+	doc := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:       "Online Shop API",
+			Description: "Versions " + middleware.APIv1 + " and " + middleware.APIv2 + " of the public API are served under /api/v1 and /api/v2, the admin API under /admin and the merchant API under /merchant.",
+			Version:     "1.0",
+		},
+		Paths: make(map[string]openapi.PathItem),
+		Components: openapi.Components{
+			SecuritySchemes: map[string]*openapi.SecurityScheme{
+				bearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT", Description: "Access token from sign in or refresh"},
+			},
+		},
+	}
+
+	problem := problemSchema(g)
+	doc.Components.Responses = problemResponses(problem)
+
+	tags := make(map[string]bool)
+	for _, op := range operations {
+		path, _ := openapi.Path(op.path)
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = make(openapi.PathItem)
+			doc.Paths[path] = item
+		}
+		item[strings.ToLower(op.method)] = op.build(g, problem)
+		tags[op.tag] = true
+	}
+
+	for tag := range tags {
+		doc.Tags = append(doc.Tags, openapi.Tag{Name: tag})
+	}
+	sort.Slice(doc.Tags, func(i, j int) bool { return doc.Tags[i].Name < doc.Tags[j].Name })
+	doc.Components.Schemas = g.Schemas()
+	return doc
+}
+
+// problemSchema is the problem document, its code one of the catalog's
+func problemSchema(g *openapi.Generator) *openapi.Schema {
+	ref := g.Schema(apperror.Problem{})
+	s := g.Schemas()[strings.TrimPrefix(ref.Ref, openapi.SchemaRef(""))]
+	var codes []interface{}
+	for _, e := range apperror.Catalog() {
+		codes = append(codes, e.Code)
+	}
+	s.Properties["code"].Enum = codes
+	s.Required = []string{"type", "title", "status", "code"}
+	return ref
+}
+
+// problemResponses are the errors most operations share
+func problemResponses(problem *openapi.Schema) map[string]*openapi.Response {
+	content := map[string]openapi.MediaType{apperror.ProblemContentType: {Schema: problem}}
+	return map[string]*openapi.Response{
+		"BadRequest":      {Description: "The request is malformed or fails validation; fields lists each invalid field", Content: content},
+		"Unauthorized":    {Description: "The access token is missing, invalid or its session was revoked", Content: content},
+		"NotFound":        {Description: "The resource does not exist", Content: content},
+		"TooManyRequests": {Description: "The caller's rate limit is exhausted", Content: content},
+		"Error":           {Description: "Any other error, described by its code", Content: content},
+	}
+}
+
+func (op operation) build(g *openapi.Generator, problem *openapi.Schema) *openapi.Operation {
+	o := &openapi.Operation{
+		OperationID: op.id,
+		Summary:     op.summary,
+		Tags:        []string{op.tag},
+		Responses:   make(map[string]*openapi.Response),
+		Security:    []openapi.SecurityRequirement{},
+	}
+	switch op.auth {
+	case authOptional:
+		o.Security = []openapi.SecurityRequirement{{}, {bearerAuth: {}}}
+	case authRequired:
+		o.Security = []openapi.SecurityRequirement{{bearerAuth: {}}}
+	}
+
+	_, pathParams := openapi.Path(op.path)
+	for _, name := range pathParams {
+		o.Parameters = append(o.Parameters, openapi.Parameter{Name: name, In: openapi.InPath, Required: true, Schema: &openapi.Schema{Type: "string"}})
+	}
+	for _, p := range op.query {
+		o.Parameters = append(o.Parameters, p.parameter(openapi.InQuery))
+	}
+	switch op.list {
+	case pagedByOffset:
+		o.Parameters = append(o.Parameters,
+			param{"page", "integer", "Page number, from 1"}.parameter(openapi.InQuery),
+			param{"per_page", "integer", "Items per page, at most " + strconv.Itoa(pagination.MaxPerPage)}.parameter(openapi.InQuery),
+			param{"cursor", "string", "next_cursor of the previous page"}.parameter(openapi.InQuery))
+	case pagedByKeyset:
+		o.Parameters = append(o.Parameters,
+			param{"per_page", "integer", "Items per page, at most " + strconv.Itoa(pagination.MaxPerPage)}.parameter(openapi.InQuery),
+			param{"cursor", "string", "next_cursor of the previous page"}.parameter(openapi.InQuery))
+	}
+	for _, p := range op.headers {
+		o.Parameters = append(o.Parameters, p.parameter(openapi.InHeader))
+	}
+	if op.idempotent {
+		o.Parameters = append(o.Parameters, param{middleware.IdempotencyKeyHeader, "string",
+			"Repeating the key replays the first response instead of acting again"}.parameter(openapi.InHeader))
+	}
+
+	if op.body != nil {
+		o.RequestBody = &openapi.RequestBody{
+			Required: !op.optionalBody,
+			Content:  map[string]openapi.MediaType{"application/json": {Schema: g.Schema(op.body)}},
+		}
+	}
+
+	status := op.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if op.data != nil || op.media != "" {
+		o.Responses[strconv.Itoa(status)] = op.success(g, problem)
+	} else if status == http.StatusNoContent {
+		o.Responses[strconv.Itoa(status)] = &openapi.Response{Description: "Success"}
+	}
+	if op.redirect != 0 {
+		o.Responses[strconv.Itoa(op.redirect)] = &openapi.Response{
+			Description: http.StatusText(op.redirect),
+			Headers:     map[string]*openapi.Header{"Location": {Schema: &openapi.Schema{Type: "string"}}},
+		}
+	}
+
+	if op.body != nil || len(op.query) > 0 || op.list != notPaged {
+		o.Responses["400"] = &openapi.Response{Ref: openapi.ResponseRef("BadRequest")}
+	}
+	if op.auth == authRequired {
+		o.Responses["401"] = &openapi.Response{Ref: openapi.ResponseRef("Unauthorized")}
+	}
+	if len(pathParams) > 0 {
+		o.Responses["404"] = &openapi.Response{Ref: openapi.ResponseRef("NotFound")}
+	}
+	o.Responses["429"] = &openapi.Response{Ref: openapi.ResponseRef("TooManyRequests")}
+	o.Responses["default"] = &openapi.Response{Ref: openapi.ResponseRef("Error")}
+	return o
+}
+
+// success is the response with the operation's data, in the Envelope
+// respond and respondPage write
+func (op operation) success(g *openapi.Generator, problem *openapi.Schema) *openapi.Response {
+	r := &openapi.Response{Description: "Success"}
+	if op.idempotent {
+		r.Headers = map[string]*openapi.Header{middleware.IdempotentReplayedHeader: {
+			Description: "Set to true when the response is a replay",
+			Schema:      &openapi.Schema{Type: "string"},
+		}}
+	}
+	if op.media != "" {
+		schema := &openapi.Schema{Type: "string"}
+		if op.media != "text/plain" {
+			schema.Format = "binary"
+		}
+		r.Content = map[string]openapi.MediaType{op.media: {Schema: schema}}
+		return r
+	}
+
+	data := g.Schema(op.data)
+	if op.bare {
+		r.Content = map[string]openapi.MediaType{"application/json": {Schema: data}}
+		return r
+	}
+	envelope := &openapi.Schema{
+		Type:       "object",
+		Required:   []string{"data"},
+		Properties: map[string]*openapi.Schema{"data": data},
+	}
+	if op.list != notPaged {
+		envelope.Properties["meta"] = g.Schema(pagination.Meta{})
+		envelope.Required = append(envelope.Required, "meta")
+	}
+	if op.partial {
+		envelope.Properties["errors"] = &openapi.Schema{Type: "array", Items: problem, Description: "Parts of the response that failed"}
+	}
+	if op.auth != authNone {
+		envelope.Properties["impersonation"] = g.Schema(ImpersonationBanner{})
+	}
+	r.Content = map[string]openapi.MediaType{"application/json": {Schema: envelope}}
+	return r
+}
+
+func (p param) parameter(in string) openapi.Parameter {
+	parameter := openapi.Parameter{Name: p.name, In: in, Description: p.description, Schema: &openapi.Schema{Type: p.typ}}
+	if p.typ == "object" {
+		parameter.Style, parameter.Explode = "deepObject", true
+		parameter.Schema.AdditionalProperties = &openapi.Schema{Type: "string"}
+	}
+	return parameter
+}
+
+// DocsHandler serves the OpenAPI document, which the API docs UI reads.
+type DocsHandler struct {
+	spec []byte
+	err  error
+}
+
+func NewDocsHandler() *DocsHandler {
+	spec, err := json.Marshal(OpenAPI())
+	return &DocsHandler{spec: spec, err: err}
+}
+
+func (h *DocsHandler) GetSpec(c *gin.Context) {
+	if h.err != nil {
+		respondError(c, apperror.ErrInternal.Wrap(h.err))
+		return
+	}
+	c.Data(http.StatusOK, "application/json", h.spec)
+}
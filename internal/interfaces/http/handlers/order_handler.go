@@ -4,16 +4,38 @@ import (
 	"net/http"
 	"online-shop/internal/application/commands"
 	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/analytics"
+	"online-shop/internal/domain/order"
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/pagination"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
 type OrderHandler struct {
-	createOrderHandler    *commands.CreateOrderCommandHandler
-	cancelOrderHandler    *commands.CancelOrderCommandHandler
-	getOrderHandler       *queries.GetOrderQueryHandler
-	getUserOrdersHandler  *queries.GetUserOrdersQueryHandler
+	createOrderHandler   *commands.CreateOrderCommandHandler
+	cancelOrderHandler   *commands.CancelOrderCommandHandler
+	getOrderHandler      *queries.GetOrderQueryHandler
+	getUserOrdersHandler *queries.GetUserOrdersQueryHandler
+	trackOrderHandler    *queries.TrackOrderQueryHandler
+	listOrdersHandler    *queries.ListOrdersQueryHandler
+	cancellationsHandler *queries.GetCancellationReportQueryHandler
+	analytics            analytics.Publisher
+}
+
+// CancelOrderRequest is the optional body of a customer's cancellation.
+// Customers only give reasons of their own; the shop's are left to the
+// flows that cancel for them.
+type CancelOrderRequest struct {
+	Reason order.CancelReason `json:"reason" validate:"omitempty,oneof=changed_mind ordered_by_mistake found_better_price delivery_too_slow wrong_address other"`
+	Note   string             `json:"note" validate:"max=500"`
+}
+
+// command cancels orderID for userID as the request asks
+func (r CancelOrderRequest) command(orderID, userID string) commands.CancelOrderCommand {
+	return commands.CancelOrderCommand{OrderID: orderID, UserID: userID, Reason: r.Reason, Note: r.Note}
 }
 
 func NewOrderHandler(
@@ -21,119 +43,263 @@ func NewOrderHandler(
 	cancelOrderHandler *commands.CancelOrderCommandHandler,
 	getOrderHandler *queries.GetOrderQueryHandler,
 	getUserOrdersHandler *queries.GetUserOrdersQueryHandler,
+	trackOrderHandler *queries.TrackOrderQueryHandler,
+	listOrdersHandler *queries.ListOrdersQueryHandler,
+	cancellationsHandler *queries.GetCancellationReportQueryHandler,
+	analytics analytics.Publisher,
 ) *OrderHandler {
 	return &OrderHandler{
 		createOrderHandler:   createOrderHandler,
 		cancelOrderHandler:   cancelOrderHandler,
 		getOrderHandler:      getOrderHandler,
 		getUserOrdersHandler: getUserOrdersHandler,
+		trackOrderHandler:    trackOrderHandler,
+		listOrdersHandler:    listOrdersHandler,
+		cancellationsHandler: cancellationsHandler,
+		analytics:            analytics,
 	}
 }
 
 func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		respondError(c, apperror.ErrUnauthenticated)
 		return
 	}
 
 	var cmd commands.CreateOrderCommand
-	if err := c.ShouldBindJSON(&cmd); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !decodeJSON(c, &cmd) {
 		return
 	}
 
 	cmd.UserID = userID.(string)
+	if !validateRequest(c, &cmd) {
+		return
+	}
 
-	order, err := h.createOrderHandler.Handle(cmd)
+	order, err := h.createOrderHandler.Handle(c.Request.Context(), cmd)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"order": order})
+	publishPurchaseEvents(c, h.analytics, order)
+
+	respond(c, http.StatusCreated, order)
 }
 
 func (h *OrderHandler) GetOrder(c *gin.Context) {
 	orderID := c.Param("id")
 	if orderID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Order ID is required"})
+		respondError(c, apperror.ErrInvalidRequest.WithDetail("Order ID is required"))
 		return
 	}
 
 	query := queries.GetOrderQuery{OrderID: orderID}
-	order, err := h.getOrderHandler.Handle(query)
+	order, err := h.getOrderHandler.Handle(c.Request.Context(), query)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		respondError(c, commands.ErrOrderNotFound.WithMeta("order_id", orderID))
 		return
 	}
 
 	// Check if user owns the order or is admin
 	userID, _ := c.Get("user_id")
 	userRole, _ := c.Get("user_role")
-	
+
 	if order.UserID != userID.(string) && userRole.(string) != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		respondError(c, commands.ErrOrderAccessDenied)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"order": order})
+	respond(c, http.StatusOK, order)
 }
 
+// GetUserOrders is the signed-in customer's order history, newest first. It
+// filters on status, from and to as the admin list does, and on product,
+// part of the name of a product in the order.
 func (h *OrderHandler) GetUserOrders(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		respondError(c, apperror.ErrUnauthenticated)
 		return
 	}
 
 	query := queries.GetUserOrdersQuery{
 		UserID: userID.(string),
+		Filter: order.ListFilter{ProductName: strings.TrimSpace(c.Query("product"))},
 	}
-
-	if limit := c.Query("limit"); limit != "" {
-		if l, err := strconv.Atoi(limit); err == nil {
-			query.Limit = l
-		}
+	if err := readOrderFilter(c, &query.Filter); err != nil {
+		respondError(c, err)
+		return
 	}
 
-	if offset := c.Query("offset"); offset != "" {
-		if o, err := strconv.Atoi(offset); err == nil {
-			query.Offset = o
-		}
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
 	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
 
-	orders, err := h.getUserOrdersHandler.Handle(query)
+	orders, err := h.getUserOrdersHandler.Handle(c.Request.Context(), query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"orders": orders})
+	respondPage(c, http.StatusOK, orders.Orders, page.Meta(len(orders.Orders), pagination.Total(orders.Total)))
 }
 
 func (h *OrderHandler) CancelOrder(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		respondError(c, apperror.ErrUnauthenticated)
 		return
 	}
 
 	orderID := c.Param("id")
 	if orderID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Order ID is required"})
+		respondError(c, apperror.ErrInvalidRequest.WithDetail("Order ID is required"))
+		return
+	}
+
+	// The reason and note are optional
+	var req CancelOrderRequest
+	if c.Request.ContentLength > 0 && !bindJSON(c, &req) {
+		return
+	}
+	cmd := req.command(orderID, userID.(string))
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	if err := h.cancelOrderHandler.Handle(c.Request.Context(), cmd); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "Order cancelled successfully"})
+}
+
+// GetCancellationReport counts the orders cancelled between from and to by
+// reason, with the fees kept and the amounts refunded.
+func (h *OrderHandler) GetCancellationReport(c *gin.Context) {
+	rng, err := parseReportRange(c)
+	if err != nil {
+		respondError(c, apperror.ErrInvalidRequest.WithDetail(err.Error()))
 		return
 	}
 
-	cmd := commands.CancelOrderCommand{
-		OrderID: orderID,
-		UserID:  userID.(string),
+	report, err := h.cancellationsHandler.Handle(c.Request.Context(), queries.GetCancellationReportQuery{Range: rng})
+	if err != nil {
+		respondError(c, err)
+		return
 	}
 
-	if err := h.cancelOrderHandler.Handle(cmd); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	respond(c, http.StatusOK, report)
+}
+
+// TrackOrder lets someone without an account follow an order by its number
+// and the email it was placed with. Only the tracking view is returned.
+func (h *OrderHandler) TrackOrder(c *gin.Context) {
+	query := queries.TrackOrderQuery{
+		Number: c.Query("number"),
+		Email:  c.Query("email"),
+	}
+	if !validateRequest(c, &query) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Order cancelled successfully"})
-}
\ No newline at end of file
+	tracking, err := h.trackOrderHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, tracking)
+}
+
+// GetOrders is the admin order list. It filters on status (comma
+// separated), from and to, min_amount and max_amount, payment_method,
+// merchant_id, product and email, sorts by sort such as "-created_at,total_amount"
+// and pages with the cursor from the previous response.
+func (h *OrderHandler) GetOrders(c *gin.Context) {
+	query := queries.ListOrdersQuery{
+		Filter: order.ListFilter{
+			PaymentMethod: c.Query("payment_method"),
+			MerchantID:    c.Query("merchant_id"),
+			ProductName:   strings.TrimSpace(c.Query("product")),
+		},
+		Email:  c.Query("email"),
+		Sort:   c.Query("sort"),
+		Cursor: c.Query("cursor"),
+		Limit:  pagination.New(1, queryInt(c, "per_page")).PerPage,
+	}
+
+	if err := readOrderFilter(c, &query.Filter); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	minAmount, err := queryAmount(c, "min_amount")
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	maxAmount, err := queryAmount(c, "max_amount")
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Filter.MinAmount, query.Filter.MaxAmount = minAmount, maxAmount
+
+	list, err := h.listOrdersHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, list.Orders, pagination.KeysetMeta(query.Limit, list.NextCursor))
+}
+
+// readOrderFilter reads the status (comma separated), from and to
+// parameters both order lists take. A to given as a date includes that day.
+func readOrderFilter(c *gin.Context, filter *order.ListFilter) error {
+	statuses, err := order.ParseStatuses(c.Query("status"))
+	if err != nil {
+		return err
+	}
+	filter.Statuses = statuses
+
+	if from := c.Query("from"); from != "" {
+		t, _, err := parseReportTime(from)
+		if err != nil {
+			return apperror.ErrInvalidRequest.WithDetail("invalid from date")
+		}
+		filter.From = t
+	}
+
+	if to := c.Query("to"); to != "" {
+		t, dateOnly, err := parseReportTime(to)
+		if err != nil {
+			return apperror.ErrInvalidRequest.WithDetail("invalid to date")
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		filter.To = t
+	}
+	return nil
+}
+
+// queryAmount reads an optional non-negative amount parameter
+func queryAmount(c *gin.Context, key string) (*float64, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil || amount < 0 {
+		return nil, apperror.ErrInvalidRequest.WithDetail("invalid %s", key)
+	}
+	return &amount, nil
+}
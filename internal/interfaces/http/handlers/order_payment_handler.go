@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/interfaces/http/middleware"
+	"online-shop/pkg/pagination"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OrderPaymentHandler struct {
+	createPaymentsHandler *commands.CreateOrderPaymentsCommandHandler
+	payPaymentHandler     *commands.PayOrderPaymentCommandHandler
+	getOrderHandler       *queries.GetOrderQueryHandler
+	getPaymentsHandler    *queries.GetOrderPaymentsQueryHandler
+	getInvoiceHandler     *queries.GetOrderInvoiceQueryHandler
+	listReceiptsHandler   *queries.ListReceiptsQueryHandler
+}
+
+func NewOrderPaymentHandler(
+	createPaymentsHandler *commands.CreateOrderPaymentsCommandHandler,
+	payPaymentHandler *commands.PayOrderPaymentCommandHandler,
+	getOrderHandler *queries.GetOrderQueryHandler,
+	getPaymentsHandler *queries.GetOrderPaymentsQueryHandler,
+	getInvoiceHandler *queries.GetOrderInvoiceQueryHandler,
+	listReceiptsHandler *queries.ListReceiptsQueryHandler,
+) *OrderPaymentHandler {
+	return &OrderPaymentHandler{
+		createPaymentsHandler: createPaymentsHandler,
+		payPaymentHandler:     payPaymentHandler,
+		getOrderHandler:       getOrderHandler,
+		getPaymentsHandler:    getPaymentsHandler,
+		getInvoiceHandler:     getInvoiceHandler,
+		listReceiptsHandler:   listReceiptsHandler,
+	}
+}
+
+// GetPayments shows how an order is being paid: each payment with its
+// status, and what is paid and still outstanding.
+func (h *OrderPaymentHandler) GetPayments(c *gin.Context) {
+	orderID := c.Param("id")
+	o, err := h.getOrderHandler.Handle(c.Request.Context(), queries.GetOrderQuery{OrderID: orderID})
+	if err != nil {
+		respondError(c, commands.ErrOrderNotFound.WithMeta("order_id", orderID))
+		return
+	}
+	if o.UserID != c.GetString("user_id") && c.GetString("user_role") != "admin" {
+		respondError(c, commands.ErrOrderAccessDenied)
+		return
+	}
+
+	summary, err := h.getPaymentsHandler.Handle(c.Request.Context(), queries.GetOrderPaymentsQuery{Order: o})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, summary)
+}
+
+// CreatePayments splits what is outstanding on an order across several
+// instruments or into installments.
+func (h *OrderPaymentHandler) CreatePayments(c *gin.Context) {
+	var cmd commands.CreateOrderPaymentsCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.OrderID = c.Param("id")
+	cmd.UserID = c.GetString("user_id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	summary, err := h.createPaymentsHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, summary)
+}
+
+// PayPayment returns a live payment link for one of the order's pending
+// payments.
+func (h *OrderPaymentHandler) PayPayment(c *gin.Context) {
+	pay, err := h.payPaymentHandler.Handle(c.Request.Context(), commands.PayOrderPaymentCommand{
+		OrderID:   c.Param("id"),
+		UserID:    c.GetString("user_id"),
+		PaymentID: c.Param("paymentId"),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, pay)
+}
+
+// GetInvoice returns the order's invoice as an HTML document, to view in
+// the browser or, with ?download=true, to save.
+func (h *OrderPaymentHandler) GetInvoice(c *gin.Context) {
+	orderID := c.Param("id")
+	o, err := h.getOrderHandler.Handle(c.Request.Context(), queries.GetOrderQuery{OrderID: orderID})
+	if err != nil {
+		respondError(c, commands.ErrOrderNotFound.WithMeta("order_id", orderID))
+		return
+	}
+	if o.UserID != c.GetString("user_id") && c.GetString("user_role") != "admin" {
+		respondError(c, commands.ErrOrderAccessDenied)
+		return
+	}
+
+	invoice, err := h.getInvoiceHandler.Handle(c.Request.Context(), queries.GetOrderInvoiceQuery{
+		Order:  o,
+		Locale: middleware.LocaleOf(c),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	disposition := "inline"
+	if c.Query("download") == "true" {
+		disposition = "attachment"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("%s; filename=%q", disposition, invoice.Number+".html"))
+	c.Header("Cache-Control", "private, no-store")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(invoice.HTML))
+}
+
+// ListReceipts returns the receipts of the user's payments, newest first.
+func (h *OrderPaymentHandler) ListReceipts(c *gin.Context) {
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	receipts, err := h.listReceiptsHandler.Handle(c.Request.Context(), queries.ListReceiptsQuery{
+		UserID: c.GetString("user_id"),
+		Limit:  page.Limit(),
+		Offset: page.Offset(),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, receipts.Receipts, page.Meta(len(receipts.Receipts), pagination.Total(receipts.Total)))
+}
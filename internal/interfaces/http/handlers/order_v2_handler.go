@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/interfaces/http/apiv2"
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/pagination"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OrderV2Handler serves the signed-in customer's orders in the v2 API. It
+// runs the same commands and queries as v1 and answers with the v2
+// representation of orders.
+type OrderV2Handler struct {
+	orders *OrderHandler
+}
+
+func NewOrderV2Handler(orders *OrderHandler) *OrderV2Handler {
+	return &OrderV2Handler{orders: orders}
+}
+
+// CreateOrder takes the same body as v1.
+func (h *OrderV2Handler) CreateOrder(c *gin.Context) {
+	var cmd commands.CreateOrderCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.UserID = c.GetString("user_id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	created, err := h.orders.createOrderHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	publishPurchaseEvents(c, h.orders.analytics, created)
+
+	respond(c, http.StatusCreated, apiv2.MapOrder(created))
+}
+
+// ListOrders is the customer's order history, newest first by default.
+// It filters on status, from, to and product as v1 does, sorts by sort as
+// the admin list does, and pages by the cursor of the previous response.
+func (h *OrderV2Handler) ListOrders(c *gin.Context) {
+	query := queries.ListOrdersQuery{
+		Filter: order.ListFilter{
+			UserID:      c.GetString("user_id"),
+			ProductName: strings.TrimSpace(c.Query("product")),
+		},
+		Sort:   c.Query("sort"),
+		Cursor: c.Query("cursor"),
+		Limit:  pagination.New(1, queryInt(c, "per_page")).PerPage,
+	}
+	if err := readOrderFilter(c, &query.Filter); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	list, err := h.orders.listOrdersHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, apiv2.MapOrders(list.Orders), pagination.KeysetMeta(query.Limit, list.NextCursor))
+}
+
+func (h *OrderV2Handler) GetOrder(c *gin.Context) {
+	o, ok := h.ownOrder(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	respond(c, http.StatusOK, apiv2.MapOrder(o))
+}
+
+// CancelOrder takes the same optional body as v1 and answers with the
+// cancelled order, showing the fee kept and the amount refunded.
+func (h *OrderV2Handler) CancelOrder(c *gin.Context) {
+	var req CancelOrderRequest
+	if c.Request.ContentLength > 0 && !bindJSON(c, &req) {
+		return
+	}
+	cmd := req.command(c.Param("id"), c.GetString("user_id"))
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	if err := h.orders.cancelOrderHandler.Handle(c.Request.Context(), cmd); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	o, ok := h.ownOrder(c, cmd.OrderID)
+	if !ok {
+		return
+	}
+	respond(c, http.StatusOK, apiv2.MapOrder(o))
+}
+
+// ownOrder loads an order of the signed-in customer. Another customer's
+// order is not found, where v1 answers that access is denied.
+func (h *OrderV2Handler) ownOrder(c *gin.Context, orderID string) (*order.Order, bool) {
+	if orderID == "" {
+		respondError(c, apperror.ErrInvalidRequest.WithDetail("Order ID is required"))
+		return nil, false
+	}
+
+	o, err := h.orders.getOrderHandler.Handle(c.Request.Context(), queries.GetOrderQuery{OrderID: orderID})
+	if err != nil || o.UserID != c.GetString("user_id") {
+		respondError(c, commands.ErrOrderNotFound.WithMeta("order_id", orderID))
+		return nil, false
+	}
+	return o, true
+}
@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/order"
+	"online-shop/pkg/pagination"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OrganizationHandler serves business accounts: their members and approval
+// chains, orders bought on invoice and the invoices owed for them.
+type OrganizationHandler struct {
+	createHandler       *commands.CreateOrganizationCommandHandler
+	addMemberHandler    *commands.AddOrganizationMemberCommandHandler
+	updateMemberHandler *commands.UpdateOrganizationMemberCommandHandler
+	removeMemberHandler *commands.RemoveOrganizationMemberCommandHandler
+	chainHandler        *commands.SetApprovalChainCommandHandler
+	creditHandler       *commands.SetOrganizationCreditCommandHandler
+	payHandler          *commands.PayOnInvoiceCommandHandler
+	decideHandler       *commands.DecidePurchaseApprovalCommandHandler
+	recordHandler       *commands.RecordInvoicePaymentCommandHandler
+	getHandler          *queries.GetOrganizationQueryHandler
+	listHandler         *queries.ListOrganizationsQueryHandler
+	membersHandler      *queries.ListOrganizationMembersQueryHandler
+	ordersHandler       *queries.ListOrganizationOrdersQueryHandler
+	approvalsHandler    *queries.ListPurchaseApprovalsQueryHandler
+	invoicesHandler     *queries.ListInvoicesQueryHandler
+}
+
+func NewOrganizationHandler(
+	createHandler *commands.CreateOrganizationCommandHandler,
+	addMemberHandler *commands.AddOrganizationMemberCommandHandler,
+	updateMemberHandler *commands.UpdateOrganizationMemberCommandHandler,
+	removeMemberHandler *commands.RemoveOrganizationMemberCommandHandler,
+	chainHandler *commands.SetApprovalChainCommandHandler,
+	creditHandler *commands.SetOrganizationCreditCommandHandler,
+	payHandler *commands.PayOnInvoiceCommandHandler,
+	decideHandler *commands.DecidePurchaseApprovalCommandHandler,
+	recordHandler *commands.RecordInvoicePaymentCommandHandler,
+	getHandler *queries.GetOrganizationQueryHandler,
+	listHandler *queries.ListOrganizationsQueryHandler,
+	membersHandler *queries.ListOrganizationMembersQueryHandler,
+	ordersHandler *queries.ListOrganizationOrdersQueryHandler,
+	approvalsHandler *queries.ListPurchaseApprovalsQueryHandler,
+	invoicesHandler *queries.ListInvoicesQueryHandler,
+) *OrganizationHandler {
+	return &OrganizationHandler{
+		createHandler:       createHandler,
+		addMemberHandler:    addMemberHandler,
+		updateMemberHandler: updateMemberHandler,
+		removeMemberHandler: removeMemberHandler,
+		chainHandler:        chainHandler,
+		creditHandler:       creditHandler,
+		payHandler:          payHandler,
+		decideHandler:       decideHandler,
+		recordHandler:       recordHandler,
+		getHandler:          getHandler,
+		listHandler:         listHandler,
+		membersHandler:      membersHandler,
+		ordersHandler:       ordersHandler,
+		approvalsHandler:    approvalsHandler,
+		invoicesHandler:     invoicesHandler,
+	}
+}
+
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	var cmd commands.CreateOrganizationCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.UserID = c.GetString("user_id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	org, err := h.createHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, org)
+}
+
+// GetOrganization is the organization the signed-in user buys for, with the
+// credit it has left.
+func (h *OrganizationHandler) GetOrganization(c *gin.Context) {
+	account, err := h.getHandler.Handle(c.Request.Context(), queries.GetOrganizationQuery{UserID: c.GetString("user_id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, account)
+}
+
+func (h *OrganizationHandler) ListMembers(c *gin.Context) {
+	members, err := h.membersHandler.Handle(c.Request.Context(), queries.ListOrganizationMembersQuery{UserID: c.GetString("user_id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, members)
+}
+
+func (h *OrganizationHandler) AddMember(c *gin.Context) {
+	var cmd commands.AddOrganizationMemberCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.ActorID = c.GetString("user_id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	m, err := h.addMemberHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, m)
+}
+
+func (h *OrganizationHandler) UpdateMember(c *gin.Context) {
+	var cmd commands.UpdateOrganizationMemberCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.ActorID = c.GetString("user_id")
+	cmd.UserID = c.Param("userId")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	m, err := h.updateMemberHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, m)
+}
+
+func (h *OrganizationHandler) RemoveMember(c *gin.Context) {
+	err := h.removeMemberHandler.Handle(c.Request.Context(), commands.RemoveOrganizationMemberCommand{
+		ActorID: c.GetString("user_id"),
+		UserID:  c.Param("userId"),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *OrganizationHandler) SetApprovalChain(c *gin.Context) {
+	var cmd commands.SetApprovalChainCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.ActorID = c.GetString("user_id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	org, err := h.chainHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, org)
+}
+
+// ListOrders is the organization's order history, filtered as the signed-in
+// customer's own; buyers see only the orders they placed.
+func (h *OrganizationHandler) ListOrders(c *gin.Context) {
+	query := queries.ListOrganizationOrdersQuery{
+		UserID: c.GetString("user_id"),
+		Filter: order.ListFilter{ProductName: strings.TrimSpace(c.Query("product"))},
+	}
+	if err := readOrderFilter(c, &query.Filter); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	orders, err := h.ordersHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, orders.Orders, page.Meta(len(orders.Orders), pagination.Total(orders.Total)))
+}
+
+func (h *OrganizationHandler) ListApprovals(c *gin.Context) {
+	query := queries.ListPurchaseApprovalsQuery{UserID: c.GetString("user_id"), Status: c.Query("status")}
+	if !validateRequest(c, &query) {
+		return
+	}
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	approvals, err := h.approvalsHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, approvals, page.Meta(len(approvals), nil))
+}
+
+func (h *OrganizationHandler) ApprovePurchase(c *gin.Context) {
+	h.decide(c, true)
+}
+
+func (h *OrganizationHandler) RejectPurchase(c *gin.Context) {
+	h.decide(c, false)
+}
+
+// decide answers with the approval and, once its last step is approved, the
+// invoice the order was bought on.
+func (h *OrganizationHandler) decide(c *gin.Context, approve bool) {
+	var cmd commands.DecidePurchaseApprovalCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.ApprovalID = c.Param("id")
+	cmd.UserID = c.GetString("user_id")
+	cmd.Approve = approve
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	purchase, err := h.decideHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, purchase)
+}
+
+// ListInvoices lists the invoices of the signed-in user's organization, for
+// members who oversee its purchases.
+func (h *OrganizationHandler) ListInvoices(c *gin.Context) {
+	h.listInvoices(c, queries.ListInvoicesQuery{UserID: c.GetString("user_id"), Status: c.Query("status")})
+}
+
+// PayOnInvoice buys the order on the account of the customer's organization.
+// It answers 201 with the invoice, or 202 with the approval the purchase
+// waits for.
+func (h *OrganizationHandler) PayOnInvoice(c *gin.Context) {
+	var cmd commands.PayOnInvoiceCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.OrderID = c.Param("id")
+	cmd.UserID = c.GetString("user_id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	purchase, err := h.payHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if purchase.Invoice == nil {
+		respond(c, http.StatusAccepted, purchase)
+		return
+	}
+	respond(c, http.StatusCreated, purchase)
+}
+
+func (h *OrganizationHandler) ListOrganizations(c *gin.Context) {
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	orgs, err := h.listHandler.Handle(c.Request.Context(), queries.ListOrganizationsQuery{Limit: page.Limit(), Offset: page.Offset()})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, orgs, page.Meta(len(orgs), nil))
+}
+
+func (h *OrganizationHandler) SetCredit(c *gin.Context) {
+	var cmd commands.SetOrganizationCreditCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.OrganizationID = c.Param("id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	org, err := h.creditHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, org)
+}
+
+// ListAllInvoices lists the invoices of every organization, or of the one
+// in ?organization_id=, optionally in one ?status=.
+func (h *OrganizationHandler) ListAllInvoices(c *gin.Context) {
+	h.listInvoices(c, queries.ListInvoicesQuery{OrganizationID: c.Query("organization_id"), Status: c.Query("status")})
+}
+
+// RecordInvoicePayment books the bank transfer an invoice was paid with,
+// which pays its order.
+func (h *OrganizationHandler) RecordInvoicePayment(c *gin.Context) {
+	var cmd commands.RecordInvoicePaymentCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.InvoiceID = c.Param("id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	invoice, err := h.recordHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, invoice)
+}
+
+func (h *OrganizationHandler) listInvoices(c *gin.Context, query queries.ListInvoicesQuery) {
+	if !validateRequest(c, &query) {
+		return
+	}
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	invoices, err := h.invoicesHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, invoices, page.Meta(len(invoices), nil))
+}
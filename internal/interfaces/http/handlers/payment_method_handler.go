@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"net/http"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/analytics"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PaymentMethodHandler struct {
+	addMethodHandler        *commands.AddPaymentMethodCommandHandler
+	deleteMethodHandler     *commands.DeletePaymentMethodCommandHandler
+	setDefaultMethodHandler *commands.SetDefaultPaymentMethodCommandHandler
+	listMethodsHandler      *queries.ListPaymentMethodsQueryHandler
+	checkoutHandler         *commands.OneClickCheckoutCommandHandler
+	analytics               analytics.Publisher
+}
+
+func NewPaymentMethodHandler(
+	addMethodHandler *commands.AddPaymentMethodCommandHandler,
+	deleteMethodHandler *commands.DeletePaymentMethodCommandHandler,
+	setDefaultMethodHandler *commands.SetDefaultPaymentMethodCommandHandler,
+	listMethodsHandler *queries.ListPaymentMethodsQueryHandler,
+	checkoutHandler *commands.OneClickCheckoutCommandHandler,
+	analytics analytics.Publisher,
+) *PaymentMethodHandler {
+	return &PaymentMethodHandler{
+		addMethodHandler:        addMethodHandler,
+		deleteMethodHandler:     deleteMethodHandler,
+		setDefaultMethodHandler: setDefaultMethodHandler,
+		listMethodsHandler:      listMethodsHandler,
+		checkoutHandler:         checkoutHandler,
+		analytics:               analytics,
+	}
+}
+
+func (h *PaymentMethodHandler) ListMethods(c *gin.Context) {
+	methods, err := h.listMethodsHandler.Handle(c.Request.Context(), queries.ListPaymentMethodsQuery{UserID: c.GetString("user_id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, methods)
+}
+
+// AddMethod saves a card tokenized by the provider in the browser.
+func (h *PaymentMethodHandler) AddMethod(c *gin.Context) {
+	var cmd commands.AddPaymentMethodCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.UserID = c.GetString("user_id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	method, err := h.addMethodHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, method)
+}
+
+func (h *PaymentMethodHandler) DeleteMethod(c *gin.Context) {
+	err := h.deleteMethodHandler.Handle(c.Request.Context(), commands.DeletePaymentMethodCommand{
+		UserID:   c.GetString("user_id"),
+		MethodID: c.Param("id"),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "Payment method deleted"})
+}
+
+func (h *PaymentMethodHandler) SetDefaultMethod(c *gin.Context) {
+	err := h.setDefaultMethodHandler.Handle(c.Request.Context(), commands.SetDefaultPaymentMethodCommand{
+		UserID:   c.GetString("user_id"),
+		MethodID: c.Param("id"),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "Default payment method updated"})
+}
+
+// OneClickCheckout places an order and charges a saved method. When the
+// card needs 3-D Secure the payment comes back pending with its
+// payment_url.
+func (h *PaymentMethodHandler) OneClickCheckout(c *gin.Context) {
+	var cmd commands.OneClickCheckoutCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.UserID = c.GetString("user_id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	result, err := h.checkoutHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	publishPurchaseEvents(c, h.analytics, result.Order)
+
+	respond(c, http.StatusCreated, result)
+}
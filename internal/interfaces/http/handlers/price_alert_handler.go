@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PriceAlertHandler struct {
+	watchHandler  *commands.WatchPriceCommandHandler
+	cancelHandler *commands.CancelPriceAlertCommandHandler
+	listHandler   *queries.ListPriceAlertsQueryHandler
+}
+
+func NewPriceAlertHandler(
+	watchHandler *commands.WatchPriceCommandHandler,
+	cancelHandler *commands.CancelPriceAlertCommandHandler,
+	listHandler *queries.ListPriceAlertsQueryHandler,
+) *PriceAlertHandler {
+	return &PriceAlertHandler{
+		watchHandler:  watchHandler,
+		cancelHandler: cancelHandler,
+		listHandler:   listHandler,
+	}
+}
+
+func (h *PriceAlertHandler) ListAlerts(c *gin.Context) {
+	query := queries.ListPriceAlertsQuery{UserID: c.GetString("user_id")}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	watches, err := h.listHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, watches, page.Meta(len(watches), nil))
+}
+
+// Watch asks to be told once a product's price falls to a target.
+func (h *PriceAlertHandler) Watch(c *gin.Context) {
+	var cmd commands.WatchPriceCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.UserID = c.GetString("user_id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	watch, err := h.watchHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, watch)
+}
+
+func (h *PriceAlertHandler) Cancel(c *gin.Context) {
+	err := h.cancelHandler.Handle(c.Request.Context(), commands.CancelPriceAlertCommand{
+		UserID:  c.GetString("user_id"),
+		WatchID: c.Param("id"),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "Price alert cancelled"})
+}
@@ -2,92 +2,313 @@ package handlers
 
 import (
 	"net/http"
+	"online-shop/internal/application/commands"
 	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/analytics"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/interfaces/http/middleware"
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/id"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
+// CategoryProducts is a page of the products in a category.
+type CategoryProducts struct {
+	Category *product.Category  `json:"category"`
+	Products []*product.Product `json:"products"`
+}
+
 type ProductHandler struct {
-	getProductHandler     *queries.GetProductQueryHandler
-	searchProductsHandler *queries.SearchProductsQueryHandler
-	listCategoriesHandler *queries.ListCategoriesQueryHandler
+	getProductHandler            *queries.GetProductQueryHandler
+	getProductBySlugHandler      *queries.GetProductBySlugQueryHandler
+	searchProductsHandler        *queries.SearchProductsQueryHandler
+	listCategoriesHandler        *queries.ListCategoriesQueryHandler
+	getCategoryHandler           *queries.GetCategoryQueryHandler
+	getProductsByCategoryHandler *queries.GetProductsByCategoryQueryHandler
+	updateProductSlugHandler     *commands.UpdateProductSlugCommandHandler
+	updateCategorySlugHandler    *commands.UpdateCategorySlugCommandHandler
+	getFeaturedProductsHandler   *queries.GetFeaturedProductsQueryHandler
+	getTrendingProductsHandler   *queries.GetTrendingProductsQueryHandler
+	setProductFeaturedHandler    *commands.SetProductFeaturedCommandHandler
+	flashSaleOffersHandler       *queries.GetFlashSaleOffersQueryHandler
+	getProductFacetsHandler      *queries.GetProductFacetsQueryHandler
+	compareProductsHandler       *queries.CompareProductsQueryHandler
+	setProductAttributesHandler  *commands.SetProductAttributesCommandHandler
+	setCategoryAttributesHandler *commands.SetCategoryAttributesCommandHandler
+	setProductBundleHandler      *commands.SetProductBundleCommandHandler
+	setProductStatusHandler      *commands.SetProductStatusCommandHandler
+	schedulePublishHandler       *commands.ScheduleProductPublishCommandHandler
+	duplicateProductHandler      *commands.DuplicateProductCommandHandler
+	setPurchaseLimitsHandler     *commands.SetProductPurchaseLimitsCommandHandler
+	setMinimumAgeHandler         *commands.SetCategoryMinimumAgeCommandHandler
+	localizeHandler              *queries.LocalizeCatalogQueryHandler
+	analytics                    analytics.Publisher
+	siteURL                      string
 }
 
 func NewProductHandler(
 	getProductHandler *queries.GetProductQueryHandler,
+	getProductBySlugHandler *queries.GetProductBySlugQueryHandler,
 	searchProductsHandler *queries.SearchProductsQueryHandler,
 	listCategoriesHandler *queries.ListCategoriesQueryHandler,
+	getCategoryHandler *queries.GetCategoryQueryHandler,
+	getProductsByCategoryHandler *queries.GetProductsByCategoryQueryHandler,
+	updateProductSlugHandler *commands.UpdateProductSlugCommandHandler,
+	updateCategorySlugHandler *commands.UpdateCategorySlugCommandHandler,
+	getFeaturedProductsHandler *queries.GetFeaturedProductsQueryHandler,
+	getTrendingProductsHandler *queries.GetTrendingProductsQueryHandler,
+	setProductFeaturedHandler *commands.SetProductFeaturedCommandHandler,
+	flashSaleOffersHandler *queries.GetFlashSaleOffersQueryHandler,
+	getProductFacetsHandler *queries.GetProductFacetsQueryHandler,
+	compareProductsHandler *queries.CompareProductsQueryHandler,
+	setProductAttributesHandler *commands.SetProductAttributesCommandHandler,
+	setCategoryAttributesHandler *commands.SetCategoryAttributesCommandHandler,
+	setProductBundleHandler *commands.SetProductBundleCommandHandler,
+	setProductStatusHandler *commands.SetProductStatusCommandHandler,
+	schedulePublishHandler *commands.ScheduleProductPublishCommandHandler,
+	duplicateProductHandler *commands.DuplicateProductCommandHandler,
+	setPurchaseLimitsHandler *commands.SetProductPurchaseLimitsCommandHandler,
+	setMinimumAgeHandler *commands.SetCategoryMinimumAgeCommandHandler,
+	localizeHandler *queries.LocalizeCatalogQueryHandler,
+	analytics analytics.Publisher,
+	siteURL string,
 ) *ProductHandler {
 	return &ProductHandler{
-		getProductHandler:     getProductHandler,
-		searchProductsHandler: searchProductsHandler,
-		listCategoriesHandler: listCategoriesHandler,
+		getProductHandler:            getProductHandler,
+		getProductBySlugHandler:      getProductBySlugHandler,
+		searchProductsHandler:        searchProductsHandler,
+		listCategoriesHandler:        listCategoriesHandler,
+		getCategoryHandler:           getCategoryHandler,
+		getProductsByCategoryHandler: getProductsByCategoryHandler,
+		updateProductSlugHandler:     updateProductSlugHandler,
+		updateCategorySlugHandler:    updateCategorySlugHandler,
+		getFeaturedProductsHandler:   getFeaturedProductsHandler,
+		getTrendingProductsHandler:   getTrendingProductsHandler,
+		setProductFeaturedHandler:    setProductFeaturedHandler,
+		flashSaleOffersHandler:       flashSaleOffersHandler,
+		getProductFacetsHandler:      getProductFacetsHandler,
+		compareProductsHandler:       compareProductsHandler,
+		setProductAttributesHandler:  setProductAttributesHandler,
+		setCategoryAttributesHandler: setCategoryAttributesHandler,
+		setProductBundleHandler:      setProductBundleHandler,
+		setProductStatusHandler:      setProductStatusHandler,
+		schedulePublishHandler:       schedulePublishHandler,
+		duplicateProductHandler:      duplicateProductHandler,
+		setPurchaseLimitsHandler:     setPurchaseLimitsHandler,
+		setMinimumAgeHandler:         setMinimumAgeHandler,
+		localizeHandler:              localizeHandler,
+		analytics:                    analytics,
+		siteURL:                      siteURL,
 	}
 }
 
 func (h *ProductHandler) GetProduct(c *gin.Context) {
+	product, ok := h.lookupProduct(c)
+	if !ok {
+		return
+	}
+	respond(c, http.StatusOK, product)
+}
+
+// lookupProduct finds the product in the path by ID or slug and prepares it
+// to be served. It reports false when it has answered instead: the product
+// was not found, or was asked for by a retired slug and redirected.
+func (h *ProductHandler) lookupProduct(c *gin.Context) (*product.Product, bool) {
 	productID := c.Param("id")
 	if productID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Product ID is required"})
+		respondError(c, apperror.ErrInvalidRequest.WithDetail("Product ID is required"))
+		return nil, false
+	}
+	locale, ok := catalogLocale(c)
+	if !ok {
+		return nil, false
+	}
+
+	var found *product.Product
+	var err error
+	// Anything that is neither a generated ID nor an older UUID is treated as a slug
+	if !isProductID(productID) {
+		found, err = h.getProductBySlugHandler.Handle(c.Request.Context(), queries.GetProductBySlugQuery{Slug: productID})
+		if err == nil && found.Slug != productID {
+			redirectToSlug(c, productID, found.Slug)
+			return nil, false
+		}
+	} else {
+		found, err = h.getProductHandler.Handle(c.Request.Context(), queries.GetProductQuery{ProductID: productID})
+	}
+	// Drafts are not served until they are published, nor products until
+	// their review
+	if err != nil || found.Status == product.StatusDraft || found.Status == product.StatusPendingReview {
+		respondError(c, commands.ErrProductNotFound)
+		return nil, false
+	}
+
+	h.localize(c, locale, []*product.Product{found})
+	found.ApplySEODefaults(h.siteURL)
+	applyFlashSales(c.Request.Context(), h.flashSaleOffersHandler, found)
+	publishProductEvent(c, h.analytics, analytics.EventProductViewed, found.ID, 1)
+	return found, true
+}
+
+func (h *ProductHandler) SearchProducts(c *gin.Context) {
+	query, err := searchQueryFromRequest(c)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
+	locale, ok := catalogLocale(c)
+	if !ok {
+		return
+	}
+	query.Locale = locale
 
-	query := queries.GetProductQuery{ProductID: productID}
-	product, err := h.getProductHandler.Handle(query)
+	page, err := pageFromQuery(c)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		respondError(c, err)
 		return
 	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
 
-	c.JSON(http.StatusOK, gin.H{"product": product})
+	products, err := h.searchProductsHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	h.localize(c, locale, products)
+	for _, product := range products {
+		product.ApplySEODefaults(h.siteURL)
+	}
+	applyFlashSales(c.Request.Context(), h.flashSaleOffersHandler, products...)
+
+	respondPage(c, http.StatusOK, products, page.Meta(len(products), nil))
 }
 
-func (h *ProductHandler) SearchProducts(c *gin.Context) {
-	query := queries.SearchProductsQuery{
-		Query:      c.Query("q"),
-		CategoryID: c.Query("category_id"),
-		MerchantID: c.Query("merchant_id"),
+func (h *ProductHandler) ListCategories(c *gin.Context) {
+	query := queries.ListCategoriesQuery{}
+	locale, ok := catalogLocale(c)
+	if !ok {
+		return
 	}
 
-	if minPrice := c.Query("min_price"); minPrice != "" {
-		if price, err := strconv.ParseFloat(minPrice, 64); err == nil {
-			query.MinPrice = price
-		}
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
 	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
 
-	if maxPrice := c.Query("max_price"); maxPrice != "" {
-		if price, err := strconv.ParseFloat(maxPrice, 64); err == nil {
-			query.MaxPrice = price
-		}
+	categories, err := h.listCategoriesHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
 	}
+	h.localize(c, locale, nil, categories...)
 
-	if limit := c.Query("limit"); limit != "" {
-		if l, err := strconv.Atoi(limit); err == nil {
-			query.Limit = l
-		}
+	respondPage(c, http.StatusOK, categories, page.Meta(len(categories), nil))
+}
+
+func (h *ProductHandler) GetCategory(c *gin.Context) {
+	slug := c.Param("slug")
+	locale, ok := catalogLocale(c)
+	if !ok {
+		return
 	}
 
-	if offset := c.Query("offset"); offset != "" {
-		if o, err := strconv.Atoi(offset); err == nil {
-			query.Offset = o
-		}
+	category, err := h.getCategoryHandler.Handle(c.Request.Context(), queries.GetCategoryQuery{Slug: slug})
+	if err != nil {
+		respondError(c, commands.ErrCategoryNotFound)
+		return
+	}
+	if category.Slug != slug {
+		redirectToSlug(c, slug, category.Slug)
+		return
+	}
+	h.localize(c, locale, nil, category)
+
+	respond(c, http.StatusOK, category)
+}
+
+func (h *ProductHandler) GetProductsByCategory(c *gin.Context) {
+	query := queries.GetProductsByCategoryQuery{Slug: c.Param("slug")}
+	locale, ok := catalogLocale(c)
+	if !ok {
+		return
+	}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	category, products, err := h.getProductsByCategoryHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, commands.ErrCategoryNotFound)
+		return
+	}
+	if category.Slug != query.Slug {
+		redirectToSlug(c, query.Slug, category.Slug)
+		return
 	}
+	h.localize(c, locale, products, category)
+	for _, product := range products {
+		product.ApplySEODefaults(h.siteURL)
+	}
+	applyFlashSales(c.Request.Context(), h.flashSaleOffersHandler, products...)
 
-	products, err := h.searchProductsHandler.Handle(query)
+	respondPage(c, http.StatusOK, CategoryProducts{Category: category, Products: products}, page.Meta(len(products), nil))
+}
+
+func (h *ProductHandler) UpdateProductSlug(c *gin.Context) {
+	var cmd commands.UpdateProductSlugCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.ProductID = c.Param("id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	product, err := h.updateProductSlugHandler.Handle(c.Request.Context(), cmd)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"products": products,
-		"total":    len(products),
-	})
+	respond(c, http.StatusOK, product)
 }
 
-func (h *ProductHandler) ListCategories(c *gin.Context) {
-	query := queries.ListCategoriesQuery{}
+func (h *ProductHandler) UpdateCategorySlug(c *gin.Context) {
+	var cmd commands.UpdateCategorySlugCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.CategoryID = c.Param("id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	category, err := h.updateCategorySlugHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, category)
+}
+
+func (h *ProductHandler) GetFeaturedProducts(c *gin.Context) {
+	query := queries.GetFeaturedProductsQuery{}
+	locale, ok := catalogLocale(c)
+	if !ok {
+		return
+	}
 
 	if limit := c.Query("limit"); limit != "" {
 		if l, err := strconv.Atoi(limit); err == nil {
@@ -95,17 +316,355 @@ func (h *ProductHandler) ListCategories(c *gin.Context) {
 		}
 	}
 
-	if offset := c.Query("offset"); offset != "" {
-		if o, err := strconv.Atoi(offset); err == nil {
-			query.Offset = o
-		}
+	products, err := h.getFeaturedProductsHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	h.localize(c, locale, products)
+	for _, product := range products {
+		product.ApplySEODefaults(h.siteURL)
+	}
+	applyFlashSales(c.Request.Context(), h.flashSaleOffersHandler, products...)
+
+	respond(c, http.StatusOK, products)
+}
+
+func (h *ProductHandler) GetTrendingProducts(c *gin.Context) {
+	query := queries.GetTrendingProductsQuery{}
+	locale, ok := catalogLocale(c)
+	if !ok {
+		return
+	}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	products, err := h.getTrendingProductsHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	h.localize(c, locale, products)
+	for _, product := range products {
+		product.ApplySEODefaults(h.siteURL)
+	}
+	applyFlashSales(c.Request.Context(), h.flashSaleOffersHandler, products...)
+
+	respondPage(c, http.StatusOK, products, page.Meta(len(products), nil))
+}
+
+func (h *ProductHandler) SetFeatured(c *gin.Context) {
+	var cmd commands.SetProductFeaturedCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.ProductID = c.Param("id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	product, err := h.setProductFeaturedHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, product)
+}
+
+// GetSearchFacets counts the products a search matches per attribute value.
+// It takes the same filters as SearchProducts.
+func (h *ProductHandler) GetSearchFacets(c *gin.Context) {
+	query, err := searchQueryFromRequest(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	locale, ok := catalogLocale(c)
+	if !ok {
+		return
+	}
+	query.Locale = locale
+
+	facets, err := h.getProductFacetsHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, facets)
+}
+
+func (h *ProductHandler) CompareProducts(c *gin.Context) {
+	query := queries.CompareProductsQuery{ProductIDs: strings.Split(c.Query("ids"), ",")}
+	locale, ok := catalogLocale(c)
+	if !ok {
+		return
+	}
+
+	comparison, err := h.compareProductsHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	h.localize(c, locale, comparison.Products)
+	for _, product := range comparison.Products {
+		product.ApplySEODefaults(h.siteURL)
+	}
+	applyFlashSales(c.Request.Context(), h.flashSaleOffersHandler, comparison.Products...)
+
+	respond(c, http.StatusOK, comparison)
+}
+
+func (h *ProductHandler) SetProductAttributes(c *gin.Context) {
+	var cmd commands.SetProductAttributesCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.ProductID = c.Param("id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	product, err := h.setProductAttributesHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, product)
+}
+
+// SetProductBundle makes a product a bundle of the components given, or a
+// product of its own again when none are.
+func (h *ProductHandler) SetProductBundle(c *gin.Context) {
+	var cmd commands.SetProductBundleCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.ProductID = c.Param("id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	product, err := h.setProductBundleHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, product)
+}
+
+func (h *ProductHandler) SetProductStatus(c *gin.Context) {
+	var cmd commands.SetProductStatusCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.ProductID = c.Param("id")
+	h.setStatus(c, cmd)
+}
+
+func (h *ProductHandler) ActivateProduct(c *gin.Context) {
+	h.setStatus(c, commands.SetProductStatusCommand{ProductID: c.Param("id"), Status: product.StatusActive})
+}
+
+func (h *ProductHandler) DeactivateProduct(c *gin.Context) {
+	h.setStatus(c, commands.SetProductStatusCommand{ProductID: c.Param("id"), Status: product.StatusArchived})
+}
+
+func (h *ProductHandler) setStatus(c *gin.Context, cmd commands.SetProductStatusCommand) {
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	product, err := h.setProductStatusHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, product)
+}
+
+func (h *ProductHandler) ScheduleProductPublish(c *gin.Context) {
+	var cmd commands.ScheduleProductPublishCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.ProductID = c.Param("id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	product, err := h.schedulePublishHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, product)
+}
+
+func (h *ProductHandler) DuplicateProduct(c *gin.Context) {
+	var cmd commands.DuplicateProductCommand
+	if c.Request.ContentLength > 0 && !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.ProductID = c.Param("id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	product, err := h.duplicateProductHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, product)
+}
+
+func (h *ProductHandler) SetCategoryAttributes(c *gin.Context) {
+	var cmd commands.SetCategoryAttributesCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.CategoryID = c.Param("id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	template, err := h.setCategoryAttributesHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, template)
+}
+
+// SetPurchaseLimits caps how many of the product a customer can buy.
+func (h *ProductHandler) SetPurchaseLimits(c *gin.Context) {
+	var cmd commands.SetProductPurchaseLimitsCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.ProductID = c.Param("id")
+	if !validateRequest(c, &cmd) {
+		return
 	}
 
-	categories, err := h.listCategoriesHandler.Handle(query)
+	product, err := h.setPurchaseLimitsHandler.Handle(c.Request.Context(), cmd)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"categories": categories})
-}
\ No newline at end of file
+	respond(c, http.StatusOK, product)
+}
+
+// SetCategoryMinimumAge restricts the category's products to customers of
+// a verified age.
+func (h *ProductHandler) SetCategoryMinimumAge(c *gin.Context) {
+	var cmd commands.SetCategoryMinimumAgeCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.CategoryID = c.Param("id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	category, err := h.setMinimumAgeHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, category)
+}
+
+// catalogLocale is the locale the catalog is served in: the one asked for
+// with ?locale=, or else the request's. It reports false when it has
+// answered instead, the locale asked for not being supported.
+func catalogLocale(c *gin.Context) (string, bool) {
+	locale, ok := localeQuery(c)
+	if !ok {
+		return "", false
+	}
+	if locale == "" {
+		locale = middleware.LocaleOf(c)
+	}
+	c.Header("Content-Language", locale)
+	return locale, true
+}
+
+// localize translates the products and categories being served to locale.
+// When translations cannot be read they are served as written.
+func (h *ProductHandler) localize(c *gin.Context, locale string, products []*product.Product, categories ...*product.Category) {
+	_ = h.localizeHandler.Handle(c.Request.Context(), queries.LocalizeCatalogQuery{Locale: locale, Products: products, Categories: categories})
+}
+
+// searchQueryFromRequest reads the search filters. Attribute filters are
+// given as attr[key]=value,value or, for numbers, attr[key]=min..max.
+func searchQueryFromRequest(c *gin.Context) (queries.SearchProductsQuery, error) {
+	query := queries.SearchProductsQuery{
+		Query:      c.Query("q"),
+		CategoryID: c.Query("category_id"),
+		MerchantID: c.Query("merchant_id"),
+	}
+
+	if minPrice := c.Query("min_price"); minPrice != "" {
+		if price, err := strconv.ParseFloat(minPrice, 64); err == nil {
+			query.MinPrice = price
+		}
+	}
+
+	if maxPrice := c.Query("max_price"); maxPrice != "" {
+		if price, err := strconv.ParseFloat(maxPrice, 64); err == nil {
+			query.MaxPrice = price
+		}
+	}
+
+	attrs := c.QueryMap("attr")
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		filter, err := product.ParseAttributeFilter(key, attrs[key])
+		if err != nil {
+			return query, err
+		}
+		query.Attributes = append(query.Attributes, filter)
+	}
+
+	return query, nil
+}
+
+// redirectToSlug sends a permanent redirect from a retired slug to the
+// current one, keeping the rest of the path and query string.
+func redirectToSlug(c *gin.Context, oldSlug, newSlug string) {
+	location := strings.TrimSuffix(c.Request.URL.Path, oldSlug) + newSlug
+	if c.Request.URL.RawQuery != "" {
+		location += "?" + c.Request.URL.RawQuery
+	}
+	c.Redirect(http.StatusMovedPermanently, location)
+}
+
+// isProductID tells product IDs from slugs. Products created before IDs
+// came from pkg/id still have UUIDs.
+func isProductID(s string) bool {
+	if id.Valid(s) {
+		return true
+	}
+	_, err := uuid.Parse(s)
+	return err == nil
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+
+	"online-shop/internal/application/queries"
+	"online-shop/internal/interfaces/http/apiv2"
+	"online-shop/pkg/pagination"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProductV2Handler serves the products of the v2 API. It looks products up
+// as v1 does and answers with their v2 representation.
+type ProductV2Handler struct {
+	products            *ProductHandler
+	listProductsHandler *queries.ListProductsQueryHandler
+}
+
+func NewProductV2Handler(products *ProductHandler, listProductsHandler *queries.ListProductsQueryHandler) *ProductV2Handler {
+	return &ProductV2Handler{products: products, listProductsHandler: listProductsHandler}
+}
+
+// ListProducts searches the products, newest first, with the filters of the
+// v1 search. It pages by the cursor of the previous response.
+func (h *ProductV2Handler) ListProducts(c *gin.Context) {
+	search, err := searchQueryFromRequest(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	locale, ok := catalogLocale(c)
+	if !ok {
+		return
+	}
+	search.Locale = locale
+	query := queries.ListProductsQuery{
+		Search: search,
+		Cursor: c.Query("cursor"),
+		Limit:  pagination.New(1, queryInt(c, "per_page")).PerPage,
+	}
+
+	list, err := h.listProductsHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	h.products.localize(c, locale, list.Products)
+	for _, product := range list.Products {
+		product.ApplySEODefaults(h.products.siteURL)
+	}
+	applyFlashSales(c.Request.Context(), h.products.flashSaleOffersHandler, list.Products...)
+
+	respondPage(c, http.StatusOK, apiv2.MapProducts(list.Products), pagination.KeysetMeta(query.Limit, list.NextCursor))
+}
+
+// GetProduct takes an ID or slug, like v1.
+func (h *ProductV2Handler) GetProduct(c *gin.Context) {
+	product, ok := h.products.lookupProduct(c)
+	if !ok {
+		return
+	}
+	respond(c, http.StatusOK, apiv2.MapProduct(product))
+}
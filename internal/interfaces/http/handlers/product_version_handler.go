@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/interfaces/http/middleware"
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/pagination"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProductVersionHandler serves the version history of products to admins:
+// listing and comparing versions and rolling a product back to one.
+type ProductVersionHandler struct {
+	listVersionsHandler *queries.ListProductVersionsQueryHandler
+	getVersionHandler   *queries.GetProductVersionQueryHandler
+	diffVersionsHandler *queries.DiffProductVersionsQueryHandler
+	rollbackHandler     *commands.RollbackProductCommandHandler
+}
+
+func NewProductVersionHandler(
+	listVersionsHandler *queries.ListProductVersionsQueryHandler,
+	getVersionHandler *queries.GetProductVersionQueryHandler,
+	diffVersionsHandler *queries.DiffProductVersionsQueryHandler,
+	rollbackHandler *commands.RollbackProductCommandHandler,
+) *ProductVersionHandler {
+	return &ProductVersionHandler{
+		listVersionsHandler: listVersionsHandler,
+		getVersionHandler:   getVersionHandler,
+		diffVersionsHandler: diffVersionsHandler,
+		rollbackHandler:     rollbackHandler,
+	}
+}
+
+func (h *ProductVersionHandler) ListVersions(c *gin.Context) {
+	query := queries.ListProductVersionsQuery{ProductID: c.Param("id")}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+	if !validateRequest(c, &query) {
+		return
+	}
+
+	versions, err := h.listVersionsHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, versions.Versions, page.Meta(len(versions.Versions), pagination.Total(versions.Total)))
+}
+
+func (h *ProductVersionHandler) GetVersion(c *gin.Context) {
+	version, ok := versionParam(c)
+	if !ok {
+		return
+	}
+
+	query := queries.GetProductVersionQuery{ProductID: c.Param("id"), Version: version}
+	if !validateRequest(c, &query) {
+		return
+	}
+
+	v, err := h.getVersionHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, v)
+}
+
+// DiffVersions compares a version with the one given by ?to=, or with the
+// latest version.
+func (h *ProductVersionHandler) DiffVersions(c *gin.Context) {
+	version, ok := versionParam(c)
+	if !ok {
+		return
+	}
+
+	query := queries.DiffProductVersionsQuery{ProductID: c.Param("id"), From: version}
+	if to := c.Query("to"); to != "" {
+		n, err := strconv.Atoi(to)
+		if err != nil {
+			respondError(c, apperror.ErrInvalidRequest.WithDetail("to must be a positive integer"))
+			return
+		}
+		query.To = n
+	}
+	if !validateRequest(c, &query) {
+		return
+	}
+
+	comparison, err := h.diffVersionsHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, comparison)
+}
+
+// RollbackProduct restores the product's content as of a version.
+func (h *ProductVersionHandler) RollbackProduct(c *gin.Context) {
+	version, ok := versionParam(c)
+	if !ok {
+		return
+	}
+
+	cmd := commands.RollbackProductCommand{
+		ProductID: c.Param("id"),
+		Version:   version,
+		ActorID:   c.GetString("user_id"),
+		ActorRole: c.GetString("user_role"),
+		RequestID: middleware.GetRequestID(c),
+	}
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	p, err := h.rollbackHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, p)
+}
+
+func versionParam(c *gin.Context) (int, bool) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		respondError(c, apperror.ErrInvalidRequest.WithDetail("version must be a positive integer"))
+		return 0, false
+	}
+	return version, true
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"net/http"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/quote"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QuoteHandler lets business buyers ask merchants for a price on large
+// quantities, and merchants answer with an offer the buyer can turn into an
+// order.
+type QuoteHandler struct {
+	requestHandler *commands.RequestQuoteCommandHandler
+	offerHandler   *commands.OfferQuoteCommandHandler
+	declineHandler *commands.DeclineQuoteCommandHandler
+	cancelHandler  *commands.CancelQuoteCommandHandler
+	acceptHandler  *commands.AcceptQuoteCommandHandler
+	listHandler    *queries.ListQuotesQueryHandler
+	getHandler     *queries.GetQuoteQueryHandler
+}
+
+func NewQuoteHandler(
+	requestHandler *commands.RequestQuoteCommandHandler,
+	offerHandler *commands.OfferQuoteCommandHandler,
+	declineHandler *commands.DeclineQuoteCommandHandler,
+	cancelHandler *commands.CancelQuoteCommandHandler,
+	acceptHandler *commands.AcceptQuoteCommandHandler,
+	listHandler *queries.ListQuotesQueryHandler,
+	getHandler *queries.GetQuoteQueryHandler,
+) *QuoteHandler {
+	return &QuoteHandler{
+		requestHandler: requestHandler,
+		offerHandler:   offerHandler,
+		declineHandler: declineHandler,
+		cancelHandler:  cancelHandler,
+		acceptHandler:  acceptHandler,
+		listHandler:    listHandler,
+		getHandler:     getHandler,
+	}
+}
+
+func (h *QuoteHandler) RequestQuote(c *gin.Context) {
+	var cmd commands.RequestQuoteCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.UserID = c.GetString("user_id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	q, err := h.requestHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, q)
+}
+
+func (h *QuoteHandler) ListMyQuotes(c *gin.Context) {
+	h.list(c, queries.ListQuotesQuery{UserID: c.GetString("user_id")})
+}
+
+func (h *QuoteHandler) GetQuote(c *gin.Context) {
+	h.get(c, queries.GetQuoteQuery{QuoteID: c.Param("id"), UserID: c.GetString("user_id")})
+}
+
+// AcceptQuote places the order for an offered quote and answers with it.
+func (h *QuoteHandler) AcceptQuote(c *gin.Context) {
+	var cmd commands.AcceptQuoteCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.QuoteID = c.Param("id")
+	cmd.UserID = c.GetString("user_id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	o, err := h.acceptHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, o)
+}
+
+func (h *QuoteHandler) CancelQuote(c *gin.Context) {
+	q, err := h.cancelHandler.Handle(c.Request.Context(), commands.CancelQuoteCommand{
+		QuoteID: c.Param("id"),
+		UserID:  c.GetString("user_id"),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, q)
+}
+
+// ListMerchantQuotes lists the quotes asked of the merchant, or of every
+// merchant for an admin, optionally in one ?status=.
+func (h *QuoteHandler) ListMerchantQuotes(c *gin.Context) {
+	h.list(c, queries.ListQuotesQuery{MerchantID: webhookScope(c), Status: quote.Status(c.Query("status"))})
+}
+
+func (h *QuoteHandler) GetMerchantQuote(c *gin.Context) {
+	h.get(c, queries.GetQuoteQuery{QuoteID: c.Param("id"), MerchantID: webhookScope(c)})
+}
+
+func (h *QuoteHandler) OfferQuote(c *gin.Context) {
+	var cmd commands.OfferQuoteCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.QuoteID = c.Param("id")
+	cmd.MerchantID = webhookScope(c)
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	q, err := h.offerHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, q)
+}
+
+func (h *QuoteHandler) DeclineQuote(c *gin.Context) {
+	var cmd commands.DeclineQuoteCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.QuoteID = c.Param("id")
+	cmd.MerchantID = webhookScope(c)
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	q, err := h.declineHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, q)
+}
+
+func (h *QuoteHandler) list(c *gin.Context, query queries.ListQuotesQuery) {
+	if !validateRequest(c, &query) {
+		return
+	}
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	quotes, err := h.listHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, quotes, page.Meta(len(quotes), nil))
+}
+
+func (h *QuoteHandler) get(c *gin.Context, query queries.GetQuoteQuery) {
+	q, err := h.getHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, q)
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"online-shop/internal/application/queries"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RecentlyViewedHandler struct {
+	getRecentlyViewedHandler *queries.GetRecentlyViewedQueryHandler
+	siteURL                  string
+}
+
+func NewRecentlyViewedHandler(getRecentlyViewedHandler *queries.GetRecentlyViewedQueryHandler, siteURL string) *RecentlyViewedHandler {
+	return &RecentlyViewedHandler{
+		getRecentlyViewedHandler: getRecentlyViewedHandler,
+		siteURL:                  siteURL,
+	}
+}
+
+func (h *RecentlyViewedHandler) GetRecentlyViewed(c *gin.Context) {
+	query := queries.GetRecentlyViewedQuery{
+		UserID:    c.GetString("user_id"),
+		SessionID: c.GetHeader(SessionIDHeader),
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		if l, err := strconv.Atoi(limit); err == nil {
+			query.Limit = l
+		}
+	}
+
+	products, err := h.getRecentlyViewedHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	for _, product := range products {
+		product.ApplySEODefaults(h.siteURL)
+	}
+
+	respond(c, http.StatusOK, products)
+}
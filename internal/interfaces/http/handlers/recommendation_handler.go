@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RecommendationHandler struct {
+	getSimilarProductsHandler *queries.GetSimilarProductsQueryHandler
+	getBoughtTogetherHandler  *queries.GetBoughtTogetherQueryHandler
+	siteURL                   string
+}
+
+func NewRecommendationHandler(
+	getSimilarProductsHandler *queries.GetSimilarProductsQueryHandler,
+	getBoughtTogetherHandler *queries.GetBoughtTogetherQueryHandler,
+	siteURL string,
+) *RecommendationHandler {
+	return &RecommendationHandler{
+		getSimilarProductsHandler: getSimilarProductsHandler,
+		getBoughtTogetherHandler:  getBoughtTogetherHandler,
+		siteURL:                   siteURL,
+	}
+}
+
+func (h *RecommendationHandler) GetSimilarProducts(c *gin.Context) {
+	query := queries.GetSimilarProductsQuery{ProductID: c.Param("id")}
+
+	if limit := c.Query("limit"); limit != "" {
+		if l, err := strconv.Atoi(limit); err == nil {
+			query.Limit = l
+		}
+	}
+
+	products, err := h.getSimilarProductsHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, commands.ErrProductNotFound)
+		return
+	}
+	for _, product := range products {
+		product.ApplySEODefaults(h.siteURL)
+	}
+
+	respond(c, http.StatusOK, products)
+}
+
+func (h *RecommendationHandler) GetBoughtTogether(c *gin.Context) {
+	query := queries.GetBoughtTogetherQuery{ProductID: c.Param("id")}
+
+	if limit := c.Query("limit"); limit != "" {
+		if l, err := strconv.Atoi(limit); err == nil {
+			query.Limit = l
+		}
+	}
+
+	products, err := h.getBoughtTogetherHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	for _, product := range products {
+		product.ApplySEODefaults(h.siteURL)
+	}
+
+	respond(c, http.StatusOK, products)
+}
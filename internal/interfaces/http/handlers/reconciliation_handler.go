@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReconciliationHandler struct {
+	triggerHandler *commands.TriggerReconciliationCommandHandler
+	listHandler    *queries.ListReconciliationRunsQueryHandler
+	getHandler     *queries.GetReconciliationRunQueryHandler
+}
+
+func NewReconciliationHandler(
+	triggerHandler *commands.TriggerReconciliationCommandHandler,
+	listHandler *queries.ListReconciliationRunsQueryHandler,
+	getHandler *queries.GetReconciliationRunQueryHandler,
+) *ReconciliationHandler {
+	return &ReconciliationHandler{
+		triggerHandler: triggerHandler,
+		listHandler:    listHandler,
+		getHandler:     getHandler,
+	}
+}
+
+// TriggerRun reconciles a day again, yesterday unless the body names one.
+func (h *ReconciliationHandler) TriggerRun(c *gin.Context) {
+	var cmd commands.TriggerReconciliationCommand
+	if c.Request.ContentLength > 0 && !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.RequestedBy = c.GetString("user_id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	run, err := h.triggerHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusAccepted, run)
+}
+
+func (h *ReconciliationHandler) ListRuns(c *gin.Context) {
+	query := queries.ListReconciliationRunsQuery{}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	runs, err := h.listHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, runs, page.Meta(len(runs), nil))
+}
+
+// GetRun is the reconciliation report: the run's counts and totals with
+// every mismatch found.
+func (h *ReconciliationHandler) GetRun(c *gin.Context) {
+	run, err := h.getHandler.Handle(c.Request.Context(), queries.GetReconciliationRunQuery{RunID: c.Param("id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, run)
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/analytics"
+	"online-shop/pkg/apperror"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReportHandler struct {
+	getSalesAnalyticsHandler   *queries.GetSalesAnalyticsQueryHandler
+	getRevenueAnalyticsHandler *queries.GetRevenueAnalyticsQueryHandler
+	getProductAnalyticsHandler *queries.GetProductAnalyticsQueryHandler
+	getUserAnalyticsHandler    *queries.GetUserAnalyticsQueryHandler
+}
+
+func NewReportHandler(
+	getSalesAnalyticsHandler *queries.GetSalesAnalyticsQueryHandler,
+	getRevenueAnalyticsHandler *queries.GetRevenueAnalyticsQueryHandler,
+	getProductAnalyticsHandler *queries.GetProductAnalyticsQueryHandler,
+	getUserAnalyticsHandler *queries.GetUserAnalyticsQueryHandler,
+) *ReportHandler {
+	return &ReportHandler{
+		getSalesAnalyticsHandler:   getSalesAnalyticsHandler,
+		getRevenueAnalyticsHandler: getRevenueAnalyticsHandler,
+		getProductAnalyticsHandler: getProductAnalyticsHandler,
+		getUserAnalyticsHandler:    getUserAnalyticsHandler,
+	}
+}
+
+func (h *ReportHandler) GetSalesAnalytics(c *gin.Context) {
+	rng, err := parseReportRange(c)
+	if err != nil {
+		respondError(c, apperror.ErrInvalidRequest.WithDetail(err.Error()))
+		return
+	}
+
+	summary, err := h.getSalesAnalyticsHandler.Handle(c.Request.Context(), queries.GetSalesAnalyticsQuery{Range: rng})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, summary)
+}
+
+func (h *ReportHandler) GetRevenueAnalytics(c *gin.Context) {
+	rng, err := parseReportRange(c)
+	if err != nil {
+		respondError(c, apperror.ErrInvalidRequest.WithDetail(err.Error()))
+		return
+	}
+
+	points, err := h.getRevenueAnalyticsHandler.Handle(c.Request.Context(), queries.GetRevenueAnalyticsQuery{Range: rng})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{
+		"group_by": rng.Interval,
+		"revenue":  points,
+	})
+}
+
+func (h *ReportHandler) GetProductAnalytics(c *gin.Context) {
+	rng, err := parseReportRange(c)
+	if err != nil {
+		respondError(c, apperror.ErrInvalidRequest.WithDetail(err.Error()))
+		return
+	}
+
+	query := queries.GetProductAnalyticsQuery{
+		Range:  rng,
+		SortBy: c.DefaultQuery("sort_by", "revenue"),
+	}
+	if limit := c.Query("limit"); limit != "" {
+		if l, err := strconv.Atoi(limit); err == nil {
+			query.Limit = l
+		}
+	}
+
+	stats, err := h.getProductAnalyticsHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, stats)
+}
+
+func (h *ReportHandler) GetUserAnalytics(c *gin.Context) {
+	rng, err := parseReportRange(c)
+	if err != nil {
+		respondError(c, apperror.ErrInvalidRequest.WithDetail(err.Error()))
+		return
+	}
+
+	users, err := h.getUserAnalyticsHandler.Handle(c.Request.Context(), queries.GetUserAnalyticsQuery{Range: rng})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, users)
+}
+
+// parseReportRange reads from, to and group_by. Dates may be plain
+// YYYY-MM-DD, in which case "to" includes the whole day, or RFC3339.
+func parseReportRange(c *gin.Context) (analytics.ReportRange, error) {
+	rng := analytics.ReportRange{Interval: c.Query("group_by")}
+
+	if from := c.Query("from"); from != "" {
+		t, _, err := parseReportTime(from)
+		if err != nil {
+			return rng, errors.New("invalid from date")
+		}
+		rng.From = t
+	}
+
+	if to := c.Query("to"); to != "" {
+		t, dateOnly, err := parseReportTime(to)
+		if err != nil {
+			return rng, errors.New("invalid to date")
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		rng.To = t
+	}
+
+	return rng, nil
+}
+
+func parseReportTime(value string) (time.Time, bool, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
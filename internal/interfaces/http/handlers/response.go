@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"strconv"
+
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/pagination"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Envelope is the body of every successful JSON response. Errors lists
+// problems with parts of a response that did not fail the request as a
+// whole; failed requests are answered with a problem document instead.
+// Impersonation is set when a support admin made the request as the user.
+type Envelope struct {
+	Data          interface{}          `json:"data"`
+	Meta          *pagination.Meta     `json:"meta,omitempty"`
+	Errors        []apperror.Problem   `json:"errors,omitempty"`
+	Impersonation *ImpersonationBanner `json:"impersonation,omitempty"`
+}
+
+func respond(c *gin.Context, status int, data interface{}) {
+	c.JSON(status, Envelope{Data: data, Impersonation: impersonationBanner(c)})
+}
+
+// respondPage writes one page of a list with its pagination meta.
+func respondPage(c *gin.Context, status int, data interface{}, meta pagination.Meta) {
+	c.JSON(status, Envelope{Data: data, Meta: &meta, Impersonation: impersonationBanner(c)})
+}
+
+// problems renders errors for the Errors of an envelope. They are also
+// attached to the context so the request log shows them.
+func problems(c *gin.Context, errs ...error) []apperror.Problem {
+	list := make([]apperror.Problem, len(errs))
+	for i, err := range errs {
+		c.Error(err)
+		list[i] = apperror.From(err).Problem(c.Request.URL.Path)
+	}
+	return list
+}
+
+// pageFromQuery reads the page a list request asks for: a cursor from an
+// earlier response, page and per_page, or the older limit and offset.
+func pageFromQuery(c *gin.Context) (pagination.Page, error) {
+	perPage := queryInt(c, "per_page")
+	if perPage == 0 {
+		perPage = queryInt(c, "limit")
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		return pagination.FromCursor(cursor, perPage)
+	}
+	if c.Query("page") == "" {
+		if offset := queryInt(c, "offset"); offset > 0 {
+			return pagination.FromOffset(perPage, offset), nil
+		}
+	}
+	return pagination.New(queryInt(c, "page"), perPage), nil
+}
+
+// queryInt returns a numeric query parameter, or 0 when it is missing or
+// not a number
+func queryInt(c *gin.Context, key string) int {
+	n, err := strconv.Atoi(c.Query(key))
+	if err != nil {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/session"
+	"online-shop/internal/domain/user"
+	"online-shop/pkg/jwt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionList is the user's signed in devices, marking the calling one.
+type SessionList struct {
+	Sessions         []*session.Session `json:"sessions"`
+	CurrentSessionID string             `json:"current_session_id"`
+}
+
+type RevokedSessions struct {
+	Revoked int `json:"revoked"`
+}
+
+type SessionHandler struct {
+	listHandler      *queries.ListSessionsQueryHandler
+	revokeHandler    *commands.RevokeSessionCommandHandler
+	revokeAllHandler *commands.RevokeAllSessionsCommandHandler
+}
+
+func NewSessionHandler(
+	listHandler *queries.ListSessionsQueryHandler,
+	revokeHandler *commands.RevokeSessionCommandHandler,
+	revokeAllHandler *commands.RevokeAllSessionsCommandHandler,
+) *SessionHandler {
+	return &SessionHandler{
+		listHandler:      listHandler,
+		revokeHandler:    revokeHandler,
+		revokeAllHandler: revokeAllHandler,
+	}
+}
+
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	sessions, err := h.listHandler.Handle(c.Request.Context(), queries.ListSessionsQuery{UserID: c.GetString("user_id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, SessionList{Sessions: sessions, CurrentSessionID: c.GetString("session_id")})
+}
+
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	err := h.revokeHandler.Handle(c.Request.Context(), commands.RevokeSessionCommand{
+		UserID:    c.GetString("user_id"),
+		SessionID: c.Param("id"),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// RevokeAllSessions signs out every other device. With include_current=true
+// the calling session is revoked as well.
+func (h *SessionHandler) RevokeAllSessions(c *gin.Context) {
+	cmd := commands.RevokeAllSessionsCommand{UserID: c.GetString("user_id")}
+	if c.Query("include_current") != "true" {
+		cmd.KeepSessionID = c.GetString("session_id")
+	}
+
+	revoked, err := h.revokeAllHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, RevokedSessions{Revoked: revoked})
+}
+
+// startSession opens a session for a user who just signed in and issues the
+// token pair bound to it.
+func startSession(c *gin.Context, handler *commands.StartSessionCommandHandler, jwtManager *jwt.JWTManager, u *user.User) (*jwt.TokenPair, error) {
+	sess, err := handler.Handle(c.Request.Context(), commands.StartSessionCommand{
+		UserID:    u.ID,
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jwtManager.GenerateTokenPair(u.ID, u.Email, string(u.Role), sess.ID)
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"net/http"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ShippingHandler quotes shipping and delivery dates to storefronts and
+// lets admins manage the shipping zones.
+type ShippingHandler struct {
+	quoteHandler      *queries.GetShippingQuoteQueryHandler
+	estimateHandler   *queries.GetDeliveryEstimateQueryHandler
+	listZonesHandler  *queries.ListShippingZonesQueryHandler
+	createZoneHandler *commands.CreateShippingZoneCommandHandler
+	updateZoneHandler *commands.UpdateShippingZoneCommandHandler
+	deleteZoneHandler *commands.DeleteShippingZoneCommandHandler
+}
+
+func NewShippingHandler(
+	quoteHandler *queries.GetShippingQuoteQueryHandler,
+	estimateHandler *queries.GetDeliveryEstimateQueryHandler,
+	listZonesHandler *queries.ListShippingZonesQueryHandler,
+	createZoneHandler *commands.CreateShippingZoneCommandHandler,
+	updateZoneHandler *commands.UpdateShippingZoneCommandHandler,
+	deleteZoneHandler *commands.DeleteShippingZoneCommandHandler,
+) *ShippingHandler {
+	return &ShippingHandler{
+		quoteHandler:      quoteHandler,
+		estimateHandler:   estimateHandler,
+		listZonesHandler:  listZonesHandler,
+		createZoneHandler: createZoneHandler,
+		updateZoneHandler: updateZoneHandler,
+		deleteZoneHandler: deleteZoneHandler,
+	}
+}
+
+// Quote checks an address and lists the rates offered there, cheapest
+// first, with their fees for the subtotal and when the products arrive
+func (h *ShippingHandler) Quote(c *gin.Context) {
+	var query queries.GetShippingQuoteQuery
+	if !bindJSON(c, &query) {
+		return
+	}
+
+	quote, err := h.quoteHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, quote)
+}
+
+// DeliveryEstimate tells product pages when the product arrives in the area
+// given by ?country=, ?state= and ?postal_code=, at each rate offered there
+func (h *ShippingHandler) DeliveryEstimate(c *gin.Context) {
+	query := queries.GetDeliveryEstimateQuery{
+		ProductID:  c.Param("id"),
+		Country:    c.Query("country"),
+		State:      c.Query("state"),
+		PostalCode: c.Query("postal_code"),
+	}
+
+	quote, err := h.estimateHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, quote)
+}
+
+func (h *ShippingHandler) ListZones(c *gin.Context) {
+	query := queries.ListShippingZonesQuery{}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	zones, err := h.listZonesHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, zones, page.Meta(len(zones), nil))
+}
+
+func (h *ShippingHandler) CreateZone(c *gin.Context) {
+	var cmd commands.CreateShippingZoneCommand
+	if !bindJSON(c, &cmd) {
+		return
+	}
+
+	zone, err := h.createZoneHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, zone)
+}
+
+func (h *ShippingHandler) UpdateZone(c *gin.Context) {
+	var cmd commands.UpdateShippingZoneCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.ZoneID = c.Param("id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	zone, err := h.updateZoneHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, zone)
+}
+
+func (h *ShippingHandler) DeleteZone(c *gin.Context) {
+	if err := h.deleteZoneHandler.Handle(c.Request.Context(), commands.DeleteShippingZoneCommand{ZoneID: c.Param("id")}); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "Shipping zone deleted"})
+}
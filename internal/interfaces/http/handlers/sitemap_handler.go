@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"online-shop/internal/infrastructure/sitemap"
+	"online-shop/pkg/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SitemapHandler struct {
+	dir string
+}
+
+func NewSitemapHandler(dir string) *SitemapHandler {
+	return &SitemapHandler{dir: dir}
+}
+
+func (h *SitemapHandler) GetIndex(c *gin.Context) {
+	h.serve(c, sitemap.IndexFile, "application/xml")
+}
+
+func (h *SitemapHandler) GetSitemap(c *gin.Context) {
+	name := filepath.Base(c.Param("file"))
+	if !strings.HasPrefix(name, "sitemap-") || !strings.HasSuffix(name, ".xml.gz") {
+		respondError(c, apperror.ErrNotFound.WithDetail("Sitemap not found"))
+		return
+	}
+	h.serve(c, name, "application/gzip")
+}
+
+func (h *SitemapHandler) serve(c *gin.Context, name, contentType string) {
+	path := filepath.Join(h.dir, name)
+	if _, err := os.Stat(path); err != nil {
+		respondError(c, apperror.ErrNotFound.WithDetail("Sitemap not found"))
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.File(path)
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+
+	"github.com/gin-gonic/gin"
+)
+
+type StockAlertHandler struct {
+	subscribeHandler *commands.SubscribeStockAlertCommandHandler
+	cancelHandler    *commands.CancelStockAlertCommandHandler
+	listHandler      *queries.ListStockAlertsQueryHandler
+}
+
+func NewStockAlertHandler(
+	subscribeHandler *commands.SubscribeStockAlertCommandHandler,
+	cancelHandler *commands.CancelStockAlertCommandHandler,
+	listHandler *queries.ListStockAlertsQueryHandler,
+) *StockAlertHandler {
+	return &StockAlertHandler{
+		subscribeHandler: subscribeHandler,
+		cancelHandler:    cancelHandler,
+		listHandler:      listHandler,
+	}
+}
+
+func (h *StockAlertHandler) ListAlerts(c *gin.Context) {
+	query := queries.ListStockAlertsQuery{UserID: c.GetString("user_id")}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	subscriptions, err := h.listHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, subscriptions, page.Meta(len(subscriptions), nil))
+}
+
+// Subscribe asks to be told once an out of stock product is back.
+func (h *StockAlertHandler) Subscribe(c *gin.Context) {
+	var cmd commands.SubscribeStockAlertCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.UserID = c.GetString("user_id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	subscription, err := h.subscribeHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, subscription)
+}
+
+func (h *StockAlertHandler) Cancel(c *gin.Context) {
+	err := h.cancelHandler.Handle(c.Request.Context(), commands.CancelStockAlertCommand{
+		UserID:         c.GetString("user_id"),
+		SubscriptionID: c.Param("id"),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "Stock alert cancelled"})
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/interfaces/http/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StorefrontHandler serves the storefront settings to storefronts and lets
+// admins edit them.
+type StorefrontHandler struct {
+	getHandler    *queries.GetStorefrontSettingsQueryHandler
+	updateHandler *commands.UpdateStorefrontSettingsCommandHandler
+}
+
+func NewStorefrontHandler(getHandler *queries.GetStorefrontSettingsQueryHandler, updateHandler *commands.UpdateStorefrontSettingsCommandHandler) *StorefrontHandler {
+	return &StorefrontHandler{getHandler: getHandler, updateHandler: updateHandler}
+}
+
+// GetPublicSettings serves the settings without who last changed them
+func (h *StorefrontHandler) GetPublicSettings(c *gin.Context) {
+	settings, err := h.getHandler.Handle(c.Request.Context(), queries.GetStorefrontSettingsQuery{})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	public := *settings
+	public.UpdatedBy = ""
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", contentMaxAge))
+	respond(c, http.StatusOK, public)
+}
+
+func (h *StorefrontHandler) GetSettings(c *gin.Context) {
+	settings, err := h.getHandler.Handle(c.Request.Context(), queries.GetStorefrontSettingsQuery{})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, settings)
+}
+
+// UpdateSettings replaces the settings; storefronts see them once their
+// cached copy expires
+func (h *StorefrontHandler) UpdateSettings(c *gin.Context) {
+	var cmd commands.UpdateStorefrontSettingsCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.ActorID = c.GetString("user_id")
+	cmd.ActorRole = c.GetString("user_role")
+	cmd.RequestID = middleware.GetRequestID(c)
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	settings, err := h.updateHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, settings)
+}
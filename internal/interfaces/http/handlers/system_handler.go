@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SystemHandler struct {
+	triggerBackupHandler   *commands.TriggerBackupCommandHandler
+	listBackupsHandler     *queries.ListBackupsQueryHandler
+	getBackupHandler       *queries.GetBackupQueryHandler
+	getBackupStatusHandler *queries.GetBackupStatusQueryHandler
+}
+
+func NewSystemHandler(
+	triggerBackupHandler *commands.TriggerBackupCommandHandler,
+	listBackupsHandler *queries.ListBackupsQueryHandler,
+	getBackupHandler *queries.GetBackupQueryHandler,
+	getBackupStatusHandler *queries.GetBackupStatusQueryHandler,
+) *SystemHandler {
+	return &SystemHandler{
+		triggerBackupHandler:   triggerBackupHandler,
+		listBackupsHandler:     listBackupsHandler,
+		getBackupHandler:       getBackupHandler,
+		getBackupStatusHandler: getBackupStatusHandler,
+	}
+}
+
+func (h *SystemHandler) TriggerBackup(c *gin.Context) {
+	b, err := h.triggerBackupHandler.Handle(c.Request.Context(), commands.TriggerBackupCommand{
+		RequestedBy: c.GetString("user_id"),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusAccepted, b)
+}
+
+func (h *SystemHandler) ListBackups(c *gin.Context) {
+	query := queries.ListBackupsQuery{}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	backups, err := h.listBackupsHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, backups, page.Meta(len(backups), nil))
+}
+
+func (h *SystemHandler) GetBackup(c *gin.Context) {
+	b, err := h.getBackupHandler.Handle(c.Request.Context(), queries.GetBackupQuery{BackupID: c.Param("id")})
+	if err != nil {
+		respondError(c, commands.ErrBackupNotFound)
+		return
+	}
+
+	respond(c, http.StatusOK, b)
+}
+
+func (h *SystemHandler) GetHealth(c *gin.Context) {
+	backupStatus, err := h.getBackupStatusHandler.Handle(c.Request.Context(), queries.GetBackupStatusQuery{})
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, Envelope{
+			Data:   gin.H{"status": "degraded"},
+			Errors: problems(c, err),
+		})
+		return
+	}
+
+	status := "ok"
+	if backupStatus.Stale {
+		status = "degraded"
+	}
+
+	respond(c, http.StatusOK, gin.H{
+		"status":    status,
+		"timestamp": time.Now(),
+		"backup":    backupStatus,
+	})
+}
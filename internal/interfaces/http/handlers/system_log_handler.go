@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/systemlog"
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/pagination"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SystemLogHandler struct {
+	searchSystemLogsHandler *queries.SearchSystemLogsQueryHandler
+}
+
+func NewSystemLogHandler(searchSystemLogsHandler *queries.SearchSystemLogsQueryHandler) *SystemLogHandler {
+	return &SystemLogHandler{searchSystemLogsHandler: searchSystemLogsHandler}
+}
+
+func (h *SystemLogHandler) SearchLogs(c *gin.Context) {
+	query := queries.SearchSystemLogsQuery{
+		Filter: systemlog.Filter{
+			Level:   c.Query("level"),
+			Service: c.Query("service"),
+			Search:  c.Query("q"),
+		},
+	}
+
+	if from := c.Query("from"); from != "" {
+		t, _, err := parseReportTime(from)
+		if err != nil {
+			respondError(c, apperror.ErrInvalidRequest.WithDetail("invalid from date"))
+			return
+		}
+		query.Filter.From = t
+	}
+
+	if to := c.Query("to"); to != "" {
+		t, dateOnly, err := parseReportTime(to)
+		if err != nil {
+			respondError(c, apperror.ErrInvalidRequest.WithDetail("invalid to date"))
+			return
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		query.Filter.To = t
+	}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	logs, err := h.searchSystemLogsHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, logs.Entries, page.Meta(len(logs.Entries), pagination.Total(logs.Total)))
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"net/http"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/pkg/pagination"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TranslationHandler serves the admin API for the catalog's translations.
+// Products and categories are written in the default locale; their
+// translations to the other supported locales are managed here.
+type TranslationHandler struct {
+	setProductTranslationHandler     *commands.SetProductTranslationCommandHandler
+	deleteProductTranslationHandler  *commands.DeleteProductTranslationCommandHandler
+	setCategoryTranslationHandler    *commands.SetCategoryTranslationCommandHandler
+	deleteCategoryTranslationHandler *commands.DeleteCategoryTranslationCommandHandler
+	listProductTranslationsHandler   *queries.ListProductTranslationsQueryHandler
+	listCategoryTranslationsHandler  *queries.ListCategoryTranslationsQueryHandler
+	reportHandler                    *queries.GetTranslationReportQueryHandler
+	missingHandler                   *queries.ListMissingTranslationsQueryHandler
+}
+
+func NewTranslationHandler(
+	setProductTranslationHandler *commands.SetProductTranslationCommandHandler,
+	deleteProductTranslationHandler *commands.DeleteProductTranslationCommandHandler,
+	setCategoryTranslationHandler *commands.SetCategoryTranslationCommandHandler,
+	deleteCategoryTranslationHandler *commands.DeleteCategoryTranslationCommandHandler,
+	listProductTranslationsHandler *queries.ListProductTranslationsQueryHandler,
+	listCategoryTranslationsHandler *queries.ListCategoryTranslationsQueryHandler,
+	reportHandler *queries.GetTranslationReportQueryHandler,
+	missingHandler *queries.ListMissingTranslationsQueryHandler,
+) *TranslationHandler {
+	return &TranslationHandler{
+		setProductTranslationHandler:     setProductTranslationHandler,
+		deleteProductTranslationHandler:  deleteProductTranslationHandler,
+		setCategoryTranslationHandler:    setCategoryTranslationHandler,
+		deleteCategoryTranslationHandler: deleteCategoryTranslationHandler,
+		listProductTranslationsHandler:   listProductTranslationsHandler,
+		listCategoryTranslationsHandler:  listCategoryTranslationsHandler,
+		reportHandler:                    reportHandler,
+		missingHandler:                   missingHandler,
+	}
+}
+
+func (h *TranslationHandler) ListProductTranslations(c *gin.Context) {
+	translations, err := h.listProductTranslationsHandler.Handle(c.Request.Context(), queries.ListProductTranslationsQuery{ProductID: c.Param("id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, translations)
+}
+
+func (h *TranslationHandler) SetProductTranslation(c *gin.Context) {
+	var cmd commands.SetProductTranslationCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.ProductID = c.Param("id")
+	cmd.Locale = c.Param("locale")
+	cmd.UpdatedBy = c.GetString("user_id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	translation, err := h.setProductTranslationHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, translation)
+}
+
+func (h *TranslationHandler) DeleteProductTranslation(c *gin.Context) {
+	err := h.deleteProductTranslationHandler.Handle(c.Request.Context(), commands.DeleteProductTranslationCommand{
+		ProductID: c.Param("id"),
+		Locale:    c.Param("locale"),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *TranslationHandler) ListCategoryTranslations(c *gin.Context) {
+	translations, err := h.listCategoryTranslationsHandler.Handle(c.Request.Context(), queries.ListCategoryTranslationsQuery{CategoryID: c.Param("id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, translations)
+}
+
+func (h *TranslationHandler) SetCategoryTranslation(c *gin.Context) {
+	var cmd commands.SetCategoryTranslationCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.CategoryID = c.Param("id")
+	cmd.Locale = c.Param("locale")
+	cmd.UpdatedBy = c.GetString("user_id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	translation, err := h.setCategoryTranslationHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, translation)
+}
+
+func (h *TranslationHandler) DeleteCategoryTranslation(c *gin.Context) {
+	err := h.deleteCategoryTranslationHandler.Handle(c.Request.Context(), commands.DeleteCategoryTranslationCommand{
+		CategoryID: c.Param("id"),
+		Locale:     c.Param("locale"),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetReport tells, per locale, how much of the catalog is translated.
+func (h *TranslationHandler) GetReport(c *gin.Context) {
+	report, err := h.reportHandler.Handle(c.Request.Context(), queries.GetTranslationReportQuery{})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, report)
+}
+
+// ListMissing pages through the active products not translated to the
+// locale query parameter yet, and lists every such category with them.
+func (h *TranslationHandler) ListMissing(c *gin.Context) {
+	query := queries.ListMissingTranslationsQuery{Locale: c.Query("locale")}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+	if !validateRequest(c, &query) {
+		return
+	}
+
+	missing, err := h.missingHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, missing, page.Meta(len(missing.Products), pagination.Total(missing.Total)))
+}
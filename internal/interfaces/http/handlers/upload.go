@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"io"
+	"strconv"
+
+	"online-shop/pkg/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readUpload reads the file in the multipart field straight off the request
+// body. Unlike c.FormFile nothing else is buffered or written to temporary
+// files, and at most maxSize bytes of the file are held in memory; a larger
+// file is refused as soon as it is known to be larger.
+func readUpload(c *gin.Context, field string, maxSize int) (string, []byte, error) {
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		return "", nil, apperror.ErrInvalidRequest.WithDetail("a multipart file field is required")
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return "", nil, apperror.ErrInvalidRequest.WithDetail("a multipart file field is required")
+		}
+		if err != nil {
+			return "", nil, apperror.ErrInvalidRequest.WithDetail(err.Error())
+		}
+		if part.FormName() != field || part.FileName() == "" {
+			// NextPart discards what is left of the part
+			continue
+		}
+
+		// Read one byte past the limit so an oversized file is rejected
+		// without being read in full
+		data, err := io.ReadAll(io.LimitReader(part, int64(maxSize)+1))
+		part.Close()
+		if err != nil {
+			return "", nil, apperror.ErrInvalidRequest.WithDetail(err.Error())
+		}
+		if len(data) > maxSize {
+			return "", nil, apperror.ErrRequestTooLarge.
+				WithDetail("the file is larger than %d bytes", maxSize).
+				WithMeta("limit", strconv.Itoa(maxSize))
+		}
+		return part.FileName(), data, nil
+	}
+}
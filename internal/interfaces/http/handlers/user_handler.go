@@ -1,10 +1,16 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"online-shop/internal/application/commands"
 	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/session"
+	"online-shop/internal/domain/user"
+	"online-shop/internal/interfaces/http/middleware"
+	"online-shop/pkg/apperror"
 	"online-shop/pkg/jwt"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,7 +21,45 @@ type UserHandler struct {
 	updateProfileHandler  *commands.UpdateUserProfileCommandHandler
 	changePasswordHandler *commands.ChangePasswordCommandHandler
 	getProfileHandler     *queries.GetUserProfileQueryHandler
+	startSessionHandler   *commands.StartSessionCommandHandler
+	refreshSessionHandler *commands.RefreshSessionCommandHandler
+	deleteAccountHandler  *commands.RequestAccountDeletionCommandHandler
+	verifyAgeHandler      *commands.VerifyUserAgeCommandHandler
 	jwtManager            *jwt.JWTManager
+	// carts merges the visitor's anonymous cart when they sign up or in
+	carts *CartHandler
+}
+
+// TokenResponse is the token pair issued for a session.
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+func tokenResponse(tokens *jwt.TokenPair) TokenResponse {
+	return TokenResponse{Token: tokens.AccessToken, RefreshToken: tokens.RefreshToken, ExpiresIn: tokens.ExpiresIn}
+}
+
+// AuthResponse answers a sign up or sign in with the user and their tokens.
+type AuthResponse struct {
+	User *user.User `json:"user"`
+	TokenResponse
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=8,max=72"`
+}
+
+// AccountDeletion answers a deletion request with when the data is erased.
+type AccountDeletion struct {
+	Message    string    `json:"message"`
+	EraseAfter time.Time `json:"erase_after"`
 }
 
 func NewUserHandler(
@@ -24,7 +68,12 @@ func NewUserHandler(
 	updateProfileHandler *commands.UpdateUserProfileCommandHandler,
 	changePasswordHandler *commands.ChangePasswordCommandHandler,
 	getProfileHandler *queries.GetUserProfileQueryHandler,
+	startSessionHandler *commands.StartSessionCommandHandler,
+	refreshSessionHandler *commands.RefreshSessionCommandHandler,
+	deleteAccountHandler *commands.RequestAccountDeletionCommandHandler,
+	verifyAgeHandler *commands.VerifyUserAgeCommandHandler,
 	jwtManager *jwt.JWTManager,
+	carts *CartHandler,
 ) *UserHandler {
 	return &UserHandler{
 		registerHandler:       registerHandler,
@@ -32,87 +81,135 @@ func NewUserHandler(
 		updateProfileHandler:  updateProfileHandler,
 		changePasswordHandler: changePasswordHandler,
 		getProfileHandler:     getProfileHandler,
+		startSessionHandler:   startSessionHandler,
+		refreshSessionHandler: refreshSessionHandler,
+		deleteAccountHandler:  deleteAccountHandler,
+		verifyAgeHandler:      verifyAgeHandler,
 		jwtManager:            jwtManager,
+		carts:                 carts,
 	}
 }
 
 func (h *UserHandler) Register(c *gin.Context) {
 	var cmd commands.RegisterUserCommand
-	if err := c.ShouldBindJSON(&cmd); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &cmd) {
 		return
 	}
+	// Without an explicit choice the user keeps the language they signed
+	// up in
+	if cmd.Locale == "" && c.GetHeader("Accept-Language") != "" {
+		cmd.Locale = middleware.LocaleOf(c)
+	}
 
-	user, err := h.registerHandler.Handle(cmd)
+	user, err := h.registerHandler.Handle(c.Request.Context(), cmd)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	token, err := h.jwtManager.GenerateToken(user.ID, user.Email, string(user.Role))
+	tokens, err := startSession(c, h.startSessionHandler, h.jwtManager, user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		respondError(c, ErrTokenGeneration.Wrap(err))
 		return
 	}
+	h.carts.merge(c, user.ID)
 
-	c.JSON(http.StatusCreated, gin.H{
-		"user":  user,
-		"token": token,
-	})
+	respond(c, http.StatusCreated, AuthResponse{User: user, TokenResponse: tokenResponse(tokens)})
 }
 
 func (h *UserHandler) Login(c *gin.Context) {
 	var cmd commands.LoginUserCommand
-	if err := c.ShouldBindJSON(&cmd); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &cmd) {
 		return
 	}
 
-	user, err := h.loginHandler.Handle(cmd)
+	user, err := h.loginHandler.Handle(c.Request.Context(), cmd)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	token, err := h.jwtManager.GenerateToken(user.ID, user.Email, string(user.Role))
+	tokens, err := startSession(c, h.startSessionHandler, h.jwtManager, user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		respondError(c, ErrTokenGeneration.Wrap(err))
 		return
 	}
+	h.carts.merge(c, user.ID)
+
+	respond(c, http.StatusOK, AuthResponse{User: user, TokenResponse: tokenResponse(tokens)})
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"user":  user,
-		"token": token,
+// RefreshToken exchanges a refresh token for a new token pair in the same
+// session. The user is reloaded so role changes and deactivations take effect
+// on refresh, and a revoked session cannot be refreshed.
+func (h *UserHandler) RefreshToken(c *gin.Context) {
+	var req RefreshTokenRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	claims, err := h.jwtManager.ValidateRefreshToken(req.RefreshToken)
+	if err != nil {
+		respondError(c, commands.ErrInvalidRefreshToken.Wrap(err))
+		return
+	}
+
+	user, err := h.getProfileHandler.Handle(c.Request.Context(), queries.GetUserProfileQuery{UserID: claims.UserID})
+	if err != nil || !user.IsActive() {
+		respondError(c, commands.ErrInvalidRefreshToken)
+		return
+	}
+
+	sess, err := h.refreshSessionHandler.Handle(c.Request.Context(), commands.RefreshSessionCommand{
+		UserID:    user.ID,
+		SessionID: claims.SessionID,
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
 	})
+	if errors.Is(err, session.ErrNotFound) {
+		respondError(c, middleware.ErrSessionRevoked)
+		return
+	}
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	tokens, err := h.jwtManager.GenerateTokenPair(user.ID, user.Email, string(user.Role), sess.ID)
+	if err != nil {
+		respondError(c, ErrTokenGeneration.Wrap(err))
+		return
+	}
+
+	respond(c, http.StatusOK, tokenResponse(tokens))
 }
 
 func (h *UserHandler) GetProfile(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		respondError(c, apperror.ErrUnauthenticated)
 		return
 	}
 
 	query := queries.GetUserProfileQuery{UserID: userID.(string)}
-	user, err := h.getProfileHandler.Handle(query)
+	user, err := h.getProfileHandler.Handle(c.Request.Context(), query)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondError(c, commands.ErrUserNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"user": user})
+	respond(c, http.StatusOK, user)
 }
 
 func (h *UserHandler) UpdateProfile(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		respondError(c, apperror.ErrUnauthenticated)
 		return
 	}
 
 	var updates map[string]interface{}
-	if err := c.ShouldBindJSON(&updates); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !decodeJSON(c, &updates) {
 		return
 	}
 
@@ -121,29 +218,24 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 		Updates: updates,
 	}
 
-	user, err := h.updateProfileHandler.Handle(cmd)
+	user, err := h.updateProfileHandler.Handle(c.Request.Context(), cmd)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"user": user})
+	respond(c, http.StatusOK, user)
 }
 
 func (h *UserHandler) ChangePassword(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		respondError(c, apperror.ErrUnauthenticated)
 		return
 	}
 
-	var req struct {
-		OldPassword string `json:"old_password" binding:"required"`
-		NewPassword string `json:"new_password" binding:"required,min=8"`
-	}
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var req ChangePasswordRequest
+	if !bindJSON(c, &req) {
 		return
 	}
 
@@ -153,10 +245,49 @@ func (h *UserHandler) ChangePassword(c *gin.Context) {
 		NewPassword: req.NewPassword,
 	}
 
-	if err := h.changePasswordHandler.Handle(cmd); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := h.changePasswordHandler.Handle(c.Request.Context(), cmd); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
+
+// DeleteAccount disables the account and signs it out everywhere. Personal
+// data is erased after the grace period; orders are kept anonymized.
+func (h *UserHandler) DeleteAccount(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		respondError(c, apperror.ErrUnauthenticated)
+		return
+	}
+
+	result, err := h.deleteAccountHandler.Handle(c.Request.Context(), commands.RequestAccountDeletionCommand{UserID: userID.(string)})
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
-}
\ No newline at end of file
+	respond(c, http.StatusAccepted, AccountDeletion{Message: "Account scheduled for deletion", EraseAfter: result.EraseAfter})
+}
+
+// VerifyAge records, for admins, the birthday on the identity document
+// they checked the user's age against.
+func (h *UserHandler) VerifyAge(c *gin.Context) {
+	var cmd commands.VerifyUserAgeCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.UserID = c.Param("id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	u, err := h.verifyAgeHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, u)
+}
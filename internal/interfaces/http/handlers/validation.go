@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"online-shop/internal/interfaces/http/middleware"
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/validation"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bindJSON decodes and validates the request body, responding with the
+// errors and returning false when it is not acceptable.
+func bindJSON(c *gin.Context, obj interface{}) bool {
+	return decodeJSON(c, obj) && validateRequest(c, obj)
+}
+
+// decodeJSON only decodes the body, for handlers that fill in fields from
+// the path or the session before validating.
+func decodeJSON(c *gin.Context, obj interface{}) bool {
+	err := c.ShouldBindJSON(obj)
+	if err == nil {
+		return true
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr):
+		respondValidation(c, validation.TypeError(typeErr.Field, jsonType(typeErr.Type)))
+	case errors.Is(err, io.EOF):
+		respondError(c, apperror.ErrInvalidRequest.WithDetail("Request body is required"))
+	default:
+		respondError(c, apperror.ErrInvalidRequest.WithDetail("Request body must be valid JSON"))
+	}
+	return false
+}
+
+// validateRequest checks obj against its validate tags.
+func validateRequest(c *gin.Context, obj interface{}) bool {
+	err := validation.Struct(obj)
+	if err == nil {
+		return true
+	}
+
+	var verr *validation.Error
+	if !errors.As(err, &verr) {
+		respondError(c, err)
+		return false
+	}
+	respondValidation(c, verr)
+	return false
+}
+
+func respondValidation(c *gin.Context, verr *validation.Error) {
+	localized := verr.Localize(middleware.LocaleOf(c))
+	respondError(c, apperror.ErrValidationFailed.WithFields(localized.Fields))
+}
+
+// jsonType names the JSON type a Go type is decoded from
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	}
+	return t.String()
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"net/http"
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/pkg/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WarehouseHandler struct {
+	createWarehouseHandler *commands.CreateWarehouseCommandHandler
+	updateWarehouseHandler *commands.UpdateWarehouseCommandHandler
+	setStockHandler        *commands.SetWarehouseStockCommandHandler
+	listWarehousesHandler  *queries.ListWarehousesQueryHandler
+	getStockHandler        *queries.GetWarehouseStockQueryHandler
+}
+
+func NewWarehouseHandler(
+	createWarehouseHandler *commands.CreateWarehouseCommandHandler,
+	updateWarehouseHandler *commands.UpdateWarehouseCommandHandler,
+	setStockHandler *commands.SetWarehouseStockCommandHandler,
+	listWarehousesHandler *queries.ListWarehousesQueryHandler,
+	getStockHandler *queries.GetWarehouseStockQueryHandler,
+) *WarehouseHandler {
+	return &WarehouseHandler{
+		createWarehouseHandler: createWarehouseHandler,
+		updateWarehouseHandler: updateWarehouseHandler,
+		setStockHandler:        setStockHandler,
+		listWarehousesHandler:  listWarehousesHandler,
+		getStockHandler:        getStockHandler,
+	}
+}
+
+func (h *WarehouseHandler) ListWarehouses(c *gin.Context) {
+	query := queries.ListWarehousesQuery{}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	warehouses, err := h.listWarehousesHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, warehouses, page.Meta(len(warehouses), nil))
+}
+
+func (h *WarehouseHandler) CreateWarehouse(c *gin.Context) {
+	var cmd commands.CreateWarehouseCommand
+	if err := c.ShouldBindJSON(&cmd); err != nil {
+		respondError(c, apperror.ErrInvalidRequest.WithDetail(err.Error()))
+		return
+	}
+
+	warehouse, err := h.createWarehouseHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, warehouse)
+}
+
+func (h *WarehouseHandler) UpdateWarehouse(c *gin.Context) {
+	var cmd commands.UpdateWarehouseCommand
+	if err := c.ShouldBindJSON(&cmd); err != nil {
+		respondError(c, apperror.ErrInvalidRequest.WithDetail(err.Error()))
+		return
+	}
+	cmd.WarehouseID = c.Param("id")
+
+	warehouse, err := h.updateWarehouseHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, warehouse)
+}
+
+func (h *WarehouseHandler) GetStock(c *gin.Context) {
+	query := queries.GetWarehouseStockQuery{WarehouseID: c.Param("id")}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	stock, err := h.getStockHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, stock, page.Meta(len(stock), nil))
+}
+
+func (h *WarehouseHandler) SetStock(c *gin.Context) {
+	var cmd commands.SetWarehouseStockCommand
+	if err := c.ShouldBindJSON(&cmd); err != nil {
+		respondError(c, apperror.ErrInvalidRequest.WithDetail(err.Error()))
+		return
+	}
+	cmd.WarehouseID = c.Param("id")
+	cmd.ProductID = c.Param("product_id")
+
+	if err := h.setStockHandler.Handle(c.Request.Context(), cmd); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "Warehouse stock updated successfully"})
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"net/http"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/user"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookHandler lets merchants register the endpoints their events are
+// posted to, and admins those of partners, with the log of what was sent.
+type WebhookHandler struct {
+	registerHandler       *commands.RegisterWebhookEndpointCommandHandler
+	updateHandler         *commands.UpdateWebhookEndpointCommandHandler
+	deleteHandler         *commands.DeleteWebhookEndpointCommandHandler
+	redeliverHandler      *commands.RedeliverWebhookCommandHandler
+	listEndpointsHandler  *queries.ListWebhookEndpointsQueryHandler
+	getEndpointHandler    *queries.GetWebhookEndpointQueryHandler
+	listDeliveriesHandler *queries.ListWebhookDeliveriesQueryHandler
+	getDeliveryHandler    *queries.GetWebhookDeliveryQueryHandler
+}
+
+func NewWebhookHandler(
+	registerHandler *commands.RegisterWebhookEndpointCommandHandler,
+	updateHandler *commands.UpdateWebhookEndpointCommandHandler,
+	deleteHandler *commands.DeleteWebhookEndpointCommandHandler,
+	redeliverHandler *commands.RedeliverWebhookCommandHandler,
+	listEndpointsHandler *queries.ListWebhookEndpointsQueryHandler,
+	getEndpointHandler *queries.GetWebhookEndpointQueryHandler,
+	listDeliveriesHandler *queries.ListWebhookDeliveriesQueryHandler,
+	getDeliveryHandler *queries.GetWebhookDeliveryQueryHandler,
+) *WebhookHandler {
+	return &WebhookHandler{
+		registerHandler:       registerHandler,
+		updateHandler:         updateHandler,
+		deleteHandler:         deleteHandler,
+		redeliverHandler:      redeliverHandler,
+		listEndpointsHandler:  listEndpointsHandler,
+		getEndpointHandler:    getEndpointHandler,
+		listDeliveriesHandler: listDeliveriesHandler,
+		getDeliveryHandler:    getDeliveryHandler,
+	}
+}
+
+// webhookScope is the merchant whose endpoints the caller manages, or empty
+// for an admin, who manages all of them
+func webhookScope(c *gin.Context) string {
+	if c.GetString("user_role") == string(user.RoleAdmin) {
+		return ""
+	}
+	return c.GetString("user_id")
+}
+
+// RegisterEndpoint answers with the endpoint's signing secret, which is not
+// shown again.
+func (h *WebhookHandler) RegisterEndpoint(c *gin.Context) {
+	var cmd commands.RegisterWebhookEndpointCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	if scope := webhookScope(c); scope != "" {
+		cmd.MerchantID = scope
+	}
+	cmd.CreatedBy = c.GetString("user_id")
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	registration, err := h.registerHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, registration)
+}
+
+func (h *WebhookHandler) ListEndpoints(c *gin.Context) {
+	query := queries.ListWebhookEndpointsQuery{Scope: webhookScope(c)}
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	endpoints, err := h.listEndpointsHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, endpoints, page.Meta(len(endpoints), nil))
+}
+
+func (h *WebhookHandler) GetEndpoint(c *gin.Context) {
+	endpoint, err := h.getEndpointHandler.Handle(c.Request.Context(), queries.GetWebhookEndpointQuery{ID: c.Param("id"), Scope: webhookScope(c)})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, endpoint)
+}
+
+// UpdateEndpoint changes, disables or rotates the secret of an endpoint.
+func (h *WebhookHandler) UpdateEndpoint(c *gin.Context) {
+	var cmd commands.UpdateWebhookEndpointCommand
+	if !decodeJSON(c, &cmd) {
+		return
+	}
+	cmd.ID = c.Param("id")
+	cmd.Scope = webhookScope(c)
+	if !validateRequest(c, &cmd) {
+		return
+	}
+
+	registration, err := h.updateHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, registration)
+}
+
+func (h *WebhookHandler) DeleteEndpoint(c *gin.Context) {
+	cmd := commands.DeleteWebhookEndpointCommand{ID: c.Param("id"), Scope: webhookScope(c)}
+	if err := h.deleteHandler.Handle(c.Request.Context(), cmd); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "Webhook endpoint deleted"})
+}
+
+// ListDeliveries is the endpoint's delivery log, newest first.
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	query := queries.ListWebhookDeliveriesQuery{
+		EndpointID: c.Param("id"),
+		Scope:      webhookScope(c),
+		Status:     c.Query("status"),
+		Event:      c.Query("event"),
+	}
+	if !validateRequest(c, &query) {
+		return
+	}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	result, err := h.listDeliveriesHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, result.Deliveries, page.Meta(len(result.Deliveries), &result.Total))
+}
+
+// GetDelivery shows a delivery with each attempt made to send it.
+func (h *WebhookHandler) GetDelivery(c *gin.Context) {
+	delivery, err := h.getDeliveryHandler.Handle(c.Request.Context(), queries.GetWebhookDeliveryQuery{ID: c.Param("id"), Scope: webhookScope(c)})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, delivery)
+}
+
+// Redeliver sends a delivery again now and answers with how it went.
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	cmd := commands.RedeliverWebhookCommand{DeliveryID: c.Param("id"), Scope: webhookScope(c)}
+	delivery, err := h.redeliverHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, delivery)
+}
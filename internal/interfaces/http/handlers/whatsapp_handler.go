@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/whatsapp"
+	"online-shop/pkg/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WhatsAppHandler receives Meta's WhatsApp webhook and serves the admin API
+// for message templates and sent messages.
+type WhatsAppHandler struct {
+	webhook               whatsapp.Webhook
+	ingestWebhookHandler  *commands.IngestWhatsAppWebhookCommandHandler
+	createTemplateHandler *commands.CreateWhatsAppTemplateCommandHandler
+	deleteTemplateHandler *commands.DeleteWhatsAppTemplateCommandHandler
+	syncTemplatesHandler  *commands.SyncWhatsAppTemplatesCommandHandler
+	listTemplatesHandler  *queries.ListWhatsAppTemplatesQueryHandler
+	getTemplateHandler    *queries.GetWhatsAppTemplateQueryHandler
+	listMessagesHandler   *queries.ListWhatsAppMessagesQueryHandler
+	getMessageHandler     *queries.GetWhatsAppMessageQueryHandler
+}
+
+func NewWhatsAppHandler(
+	webhook whatsapp.Webhook,
+	ingestWebhookHandler *commands.IngestWhatsAppWebhookCommandHandler,
+	createTemplateHandler *commands.CreateWhatsAppTemplateCommandHandler,
+	deleteTemplateHandler *commands.DeleteWhatsAppTemplateCommandHandler,
+	syncTemplatesHandler *commands.SyncWhatsAppTemplatesCommandHandler,
+	listTemplatesHandler *queries.ListWhatsAppTemplatesQueryHandler,
+	getTemplateHandler *queries.GetWhatsAppTemplateQueryHandler,
+	listMessagesHandler *queries.ListWhatsAppMessagesQueryHandler,
+	getMessageHandler *queries.GetWhatsAppMessageQueryHandler,
+) *WhatsAppHandler {
+	return &WhatsAppHandler{
+		webhook:               webhook,
+		ingestWebhookHandler:  ingestWebhookHandler,
+		createTemplateHandler: createTemplateHandler,
+		deleteTemplateHandler: deleteTemplateHandler,
+		syncTemplatesHandler:  syncTemplatesHandler,
+		listTemplatesHandler:  listTemplatesHandler,
+		getTemplateHandler:    getTemplateHandler,
+		listMessagesHandler:   listMessagesHandler,
+		getMessageHandler:     getMessageHandler,
+	}
+}
+
+// VerifyWebhook answers the check Meta makes when the webhook is
+// subscribed by echoing hub.challenge as plain text.
+func (h *WhatsAppHandler) VerifyWebhook(c *gin.Context) {
+	challenge, err := h.webhook.Challenge(c.Query("hub.mode"), c.Query("hub.verify_token"), c.Query("hub.challenge"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.String(http.StatusOK, challenge)
+}
+
+// ReceiveWebhook handles delivery statuses and template reviews. It is not
+// authenticated; the app secret signature is verified instead.
+func (h *WhatsAppHandler) ReceiveWebhook(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBody))
+	if err != nil {
+		respondError(c, apperror.ErrInvalidRequest.WithDetail(err.Error()))
+		return
+	}
+
+	result, err := h.ingestWebhookHandler.Handle(c.Request.Context(), commands.IngestWhatsAppWebhookCommand{
+		Signature: c.GetHeader("X-Hub-Signature-256"),
+		Body:      body,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, result)
+}
+
+func (h *WhatsAppHandler) ListTemplates(c *gin.Context) {
+	query := queries.ListWhatsAppTemplatesQuery{}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	templates, err := h.listTemplatesHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, templates, page.Meta(len(templates), nil))
+}
+
+func (h *WhatsAppHandler) GetTemplate(c *gin.Context) {
+	t, err := h.getTemplateHandler.Handle(c.Request.Context(), queries.GetWhatsAppTemplateQuery{Name: c.Param("name"), Language: c.Param("language")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, t)
+}
+
+func (h *WhatsAppHandler) CreateTemplate(c *gin.Context) {
+	var cmd commands.CreateWhatsAppTemplateCommand
+	if !bindJSON(c, &cmd) {
+		return
+	}
+
+	t, err := h.createTemplateHandler.Handle(c.Request.Context(), cmd)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusCreated, t)
+}
+
+func (h *WhatsAppHandler) DeleteTemplate(c *gin.Context) {
+	if err := h.deleteTemplateHandler.Handle(c.Request.Context(), commands.DeleteWhatsAppTemplateCommand{Name: c.Param("name")}); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"message": "WhatsApp template deleted"})
+}
+
+// SyncTemplates pulls the review status of every template from Meta.
+func (h *WhatsAppHandler) SyncTemplates(c *gin.Context) {
+	result, err := h.syncTemplatesHandler.Handle(c.Request.Context(), commands.SyncWhatsAppTemplatesCommand{})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, result)
+}
+
+func (h *WhatsAppHandler) ListMessages(c *gin.Context) {
+	query := queries.ListWhatsAppMessagesQuery{}
+
+	page, err := pageFromQuery(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	query.Limit, query.Offset = page.Limit(), page.Offset()
+
+	messages, err := h.listMessagesHandler.Handle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondPage(c, http.StatusOK, messages, page.Meta(len(messages), nil))
+}
+
+// GetMessage shows a message's delivery status.
+func (h *WhatsAppHandler) GetMessage(c *gin.Context) {
+	message, err := h.getMessageHandler.Handle(c.Request.Context(), queries.GetWhatsAppMessageQuery{ID: c.Param("id")})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, message)
+}
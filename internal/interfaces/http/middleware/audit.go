@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"online-shop/internal/domain/audit"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Audit records who called a state-changing route and how it ended. The actor
+// is also attached to the request context so repository writes made with it
+// are attributed to the same user and request. It must run after RequireAuth.
+func Audit(repo audit.Repository, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !mutates(c.Request.Method) {
+			c.Next()
+			return
+		}
+
+		actor := audit.Actor{
+			ID:        c.GetString("user_id"),
+			Role:      c.GetString("user_role"),
+			RequestID: GetRequestID(c),
+		}
+		if grant, ok := Impersonation(c); ok {
+			actor.ImpersonatorID = grant.AdminID
+		}
+		c.Request = c.Request.WithContext(audit.WithActor(c.Request.Context(), actor))
+		c.Set(auditedKey, true)
+
+		c.Next()
+
+		writeAudit(repo, logger, requestEntry(c, actor))
+	}
+}
+
+// auditedKey marks requests the Audit middleware records
+const auditedKey = "audited"
+
+func requestEntry(c *gin.Context, actor audit.Actor) *audit.Entry {
+	entry := audit.NewEntry(audit.SourceHTTP, actor.ID, c.Request.Method+" "+c.FullPath(), resourceType(c.FullPath()), c.Param("id"))
+	entry.ActorRole = actor.Role
+	entry.ImpersonatorID = actor.ImpersonatorID
+	entry.RequestID = actor.RequestID
+	entry.Method = c.Request.Method
+	entry.Path = c.Request.URL.Path
+	entry.StatusCode = c.Writer.Status()
+	entry.IPAddress = c.ClientIP()
+	entry.UserAgent = c.Request.UserAgent()
+	return entry
+}
+
+// writeAudit writes the entry without holding up the response
+func writeAudit(repo audit.Repository, logger *zap.Logger, entry *audit.Entry) {
+	go func() {
+		if err := repo.Create(context.Background(), entry); err != nil {
+			logger.Error("Failed to write audit log",
+				zap.String("request_id", entry.RequestID),
+				zap.String("action", entry.Action),
+				zap.Error(err),
+			)
+		}
+	}()
+}
+
+func mutates(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// resourceType is the first route segment after the admin or API prefix,
+// e.g. "products" for /admin/products/:id.
+func resourceType(route string) string {
+	route = strings.TrimPrefix(route, "/admin")
+	route = strings.TrimPrefix(route, "/api/v1")
+	route = strings.TrimPrefix(route, "/api/v2")
+	route = strings.TrimPrefix(route, "/")
+	if i := strings.Index(route, "/"); i >= 0 {
+		route = route[:i]
+	}
+	return route
+}
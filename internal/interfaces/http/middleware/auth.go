@@ -1,48 +1,84 @@
 package middleware
 
 import (
-	"net/http"
+	"errors"
+	"online-shop/internal/domain/audit"
+	"online-shop/internal/domain/impersonation"
+	"online-shop/internal/domain/session"
+	"online-shop/pkg/apperror"
 	"online-shop/pkg/jwt"
+	"online-shop/pkg/logger"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Authentication errors
+var (
+	ErrAuthRequired            = apperror.Define(apperror.KindUnauthenticated, "auth_required", "Authorization header required")
+	ErrBearerRequired          = apperror.Define(apperror.KindUnauthenticated, "bearer_token_required", "Bearer token required")
+	ErrInvalidToken            = apperror.Define(apperror.KindUnauthenticated, "invalid_token", "Invalid token")
+	ErrSessionRevoked          = apperror.Define(apperror.KindUnauthenticated, "session_revoked", "Session expired or revoked")
+	ErrSessionCheckFailed      = apperror.Define(apperror.KindUnavailable, "session_check_failed", "Unable to verify session")
+	ErrInsufficientPermissions = apperror.Define(apperror.KindPermissionDenied, "insufficient_permissions", "Insufficient permissions")
 )
 
 type AuthMiddleware struct {
 	jwtManager *jwt.JWTManager
+	sessions   session.Store
+	grants     impersonation.Repository
+	auditRepo  audit.Repository
+	logger     *zap.Logger
 }
 
-func NewAuthMiddleware(jwtManager *jwt.JWTManager) *AuthMiddleware {
-	return &AuthMiddleware{jwtManager: jwtManager}
+// NewAuthMiddleware checks tokens against the session store so revoking a
+// session signs its device out. A nil store skips the check.
+func NewAuthMiddleware(jwtManager *jwt.JWTManager, sessions session.Store) *AuthMiddleware {
+	return &AuthMiddleware{jwtManager: jwtManager, sessions: sessions}
 }
 
 func (m *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
+			AbortWithError(c, ErrAuthRequired)
 			return
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 		if tokenString == authHeader {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Bearer token required"})
-			c.Abort()
+			AbortWithError(c, ErrBearerRequired)
 			return
 		}
 
 		claims, err := m.jwtManager.ValidateToken(tokenString)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+			AbortWithError(c, ErrInvalidToken.Wrap(err))
+			return
+		}
+
+		if err := m.checkSession(c, claims); err != nil {
+			if errors.Is(err, session.ErrNotFound) {
+				AbortWithError(c, ErrSessionRevoked)
+			} else {
+				AbortWithError(c, ErrSessionCheckFailed.Wrap(err))
+			}
+			return
+		}
+
+		grant, err := m.checkImpersonation(c, claims)
+		if err != nil {
+			AbortWithError(c, err)
 			return
 		}
 
 		c.Set("user_id", claims.UserID)
 		c.Set("user_email", claims.Email)
 		c.Set("user_role", claims.Role)
-		c.Next()
+		logger.SetUserID(c.Request.Context(), claims.UserID)
+		m.next(c, grant)
 	}
 }
 
@@ -50,8 +86,7 @@ func (m *AuthMiddleware) RequireRole(roles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userRole, exists := c.Get("user_role")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User role not found"})
-			c.Abort()
+			AbortWithError(c, ErrAuthRequired)
 			return
 		}
 
@@ -63,8 +98,7 @@ func (m *AuthMiddleware) RequireRole(roles ...string) gin.HandlerFunc {
 			}
 		}
 
-		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
-		c.Abort()
+		AbortWithError(c, ErrInsufficientPermissions)
 	}
 }
 
@@ -87,10 +121,50 @@ func (m *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
 			c.Next()
 			return
 		}
+		if err := m.checkSession(c, claims); err != nil {
+			c.Next()
+			return
+		}
+		grant, err := m.checkImpersonation(c, claims)
+		if err != nil {
+			c.Next()
+			return
+		}
 
 		c.Set("user_id", claims.UserID)
 		c.Set("user_email", claims.Email)
 		c.Set("user_role", claims.Role)
-		c.Next()
+		logger.SetUserID(c.Request.Context(), claims.UserID)
+		m.next(c, grant)
+	}
+}
+
+// checkSession verifies the token's session is still live, records the
+// request on it and exposes its ID as session_id. A token without a session
+// could not be revoked, so it is rejected; only impersonation tokens carry
+// none, and their grant is checked instead.
+func (m *AuthMiddleware) checkSession(c *gin.Context, claims *jwt.Claims) error {
+	if m.sessions == nil || (claims.SessionID == "" && claims.Actor != nil) {
+		return nil
+	}
+	if claims.SessionID == "" {
+		return session.ErrNotFound
 	}
-}
\ No newline at end of file
+
+	ctx := c.Request.Context()
+	sess, err := m.sessions.Get(ctx, claims.SessionID)
+	if err != nil {
+		return err
+	}
+	if sess.UserID != claims.UserID {
+		return session.ErrNotFound
+	}
+
+	if sess.Seen(c.ClientIP(), time.Now()) {
+		// Last-seen is informational, a failed write does not block the request
+		_ = m.sessions.Touch(ctx, sess)
+	}
+
+	c.Set("session_id", sess.ID)
+	return nil
+}
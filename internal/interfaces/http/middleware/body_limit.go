@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+const bodyLimitExceededKey = "body_limit_exceeded"
+
+type bodyLimitGroup struct {
+	prefix string
+	limit  int64
+}
+
+// BodyLimit caps request bodies at the limit configured for the route's
+// group. A body declared larger than that is refused before it is read;
+// one that turns out larger is cut off at the limit, and the handler's
+// failure to read it is answered as request_too_large rather than as a
+// malformed request. It must run after Errors.
+func BodyLimit(cfg *config.RequestLimitsConfig) gin.HandlerFunc {
+	groups := make([]bodyLimitGroup, 0, len(cfg.Groups))
+	for _, group := range cfg.Groups {
+		groups = append(groups, bodyLimitGroup{
+			prefix: strings.TrimSuffix(group.Prefix, "/"),
+			limit:  int64(group.BodyKilobytes) << 10,
+		})
+	}
+	// The most specific group is tried first
+	sort.Slice(groups, func(i, j int) bool { return len(groups[i].prefix) > len(groups[j].prefix) })
+	defaultLimit := cfg.BodyLimit()
+
+	return func(c *gin.Context) {
+		limit := defaultLimit
+		path := c.Request.URL.Path
+		for _, group := range groups {
+			if path == group.prefix || strings.HasPrefix(path, group.prefix+"/") {
+				limit = group.limit
+				break
+			}
+		}
+
+		if c.Request.ContentLength > limit {
+			AbortWithError(c, tooLarge(limit))
+			return
+		}
+		if c.Request.Body != nil && c.Request.Body != http.NoBody {
+			c.Request.Body = &limitedBody{ReadCloser: http.MaxBytesReader(c.Writer, c.Request.Body, limit), c: c}
+		}
+
+		c.Next()
+	}
+}
+
+func tooLarge(limit int64) *apperror.Error {
+	return apperror.ErrRequestTooLarge.
+		WithDetail("the request body is larger than %d bytes", limit).
+		WithMeta("limit", strconv.FormatInt(limit, 10))
+}
+
+// limitedBody notes on the context that the body was cut off, so the error
+// the handler reports for it can be replaced
+type limitedBody struct {
+	io.ReadCloser
+	c *gin.Context
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		b.c.Set(bodyLimitExceededKey, maxErr.Limit)
+	}
+	return n, err
+}
+
+// bodyLimitProblem is the error to report instead of e when the failure
+// came from reading a body that was cut off
+func bodyLimitProblem(c *gin.Context, e *apperror.Error) *apperror.Error {
+	limit, ok := c.Get(bodyLimitExceededKey)
+	if !ok || e.Kind != apperror.KindInvalidArgument {
+		return e
+	}
+	return tooLarge(limit.(int64))
+}
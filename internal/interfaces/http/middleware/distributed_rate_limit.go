@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"online-shop/internal/domain/ratelimit"
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/config"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	RateLimitLimitHeader     = "X-RateLimit-Limit"
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+	RetryAfterHeader         = "Retry-After"
+)
+
+// RateLimitRules builds the policies configured under rate_limit.
+func RateLimitRules(cfg *config.RateLimitConfig) (*ratelimit.Rules, error) {
+	def, err := ratelimit.NewPolicy("default", cfg.Requests, time.Duration(cfg.WindowSeconds)*time.Second, cfg.By)
+	if err != nil {
+		return nil, err
+	}
+
+	routes := make([]ratelimit.Route, 0, len(cfg.Routes))
+	for _, route := range cfg.Routes {
+		policy, err := ratelimit.NewPolicy(route.Method+" "+route.Path, route.Requests, time.Duration(route.WindowSeconds)*time.Second, route.By)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, ratelimit.Route{Method: route.Method, Path: route.Path, Policy: policy})
+	}
+
+	return ratelimit.NewRules(def, routes, cfg.ExemptRoles, cfg.ExemptCIDRs)
+}
+
+// DistributedRateLimit enforces the rate limit rules with counters shared by
+// every API instance. Per-user policies need the caller's identity, so it must
+// run after OptionalAuth or RequireAuth. When the limiter store is unavailable
+// requests are let through rather than failing the whole API.
+func DistributedRateLimit(limiter ratelimit.Limiter, rules *ratelimit.Rules, logger *zap.Logger) gin.HandlerFunc {
+	return ReloadableRateLimit(limiter, ratelimit.NewRuleSet(rules), logger)
+}
+
+// ReloadableRateLimit is DistributedRateLimit with rules that can be replaced
+// while the API is running.
+func ReloadableRateLimit(limiter ratelimit.Limiter, ruleSet *ratelimit.RuleSet, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rules := ruleSet.Rules()
+		if rules.Exempt(c.GetString("user_role"), c.ClientIP()) {
+			c.Next()
+			return
+		}
+
+		path := c.FullPath()
+		if path == "" {
+			path = c.Request.URL.Path
+		}
+		policy := rules.PolicyFor(c.Request.Method, path)
+
+		result, err := limiter.Allow(c.Request.Context(), policy.Key(c.GetString("user_id"), c.ClientIP()), policy)
+		if err != nil {
+			logger.Warn("Rate limiter unavailable, allowing request",
+				zap.String("policy", policy.Name),
+				zap.Error(err),
+			)
+			c.Next()
+			return
+		}
+
+		c.Header(RateLimitLimitHeader, strconv.Itoa(result.Limit))
+		c.Header(RateLimitRemainingHeader, strconv.Itoa(result.Remaining))
+
+		if !result.Allowed {
+			retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			c.Header(RetryAfterHeader, strconv.Itoa(retryAfter))
+			AbortWithError(c, apperror.ErrRateLimited.WithMeta("retry_after", strconv.Itoa(retryAfter)))
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/i18n"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Errors renders the last error a handler attached with c.Error as an RFC
+// 7807 problem, unless the handler already wrote a response. It should run
+// before any middleware that can fail a request.
+func Errors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		writeProblem(c, apperror.From(c.Errors.Last().Err))
+	}
+}
+
+// AbortWithError responds with the problem for err and stops the chain. The
+// original error is kept on the context so the request log shows its cause.
+func AbortWithError(c *gin.Context, err error) {
+	c.Error(err)
+	writeProblem(c, apperror.From(err))
+	c.Abort()
+}
+
+// writeProblem renders the problem in the request's locale. Only the title
+// is translated; details describe a single occurrence and stay in English.
+func writeProblem(c *gin.Context, e *apperror.Error) {
+	e = bodyLimitProblem(c, e)
+	problem := e.Problem(c.Request.URL.Path)
+	problem.RequestID = GetRequestID(c)
+
+	locale := LocaleOf(c)
+	if title, ok := i18n.Lookup(locale, "error."+e.Code); ok {
+		problem.Title = title
+	}
+
+	c.Header("Content-Type", apperror.ProblemContentType)
+	c.Header("Content-Language", locale)
+	c.JSON(problem.Status, problem)
+}
@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"online-shop/internal/domain/idempotency"
+	"online-shop/pkg/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// Idempotency errors
+var (
+	ErrIdempotencyKeyMismatch   = apperror.Define(apperror.KindFailedPrecondition, "idempotency_key_mismatch", idempotency.ErrKeyMismatch.Error())
+	ErrIdempotencyKeyInProgress = apperror.Define(apperror.KindConflict, "idempotency_key_in_progress", idempotency.ErrKeyInProgress.Error())
+)
+
+// responseRecorder keeps a copy of the response body so it can be replayed
+type responseRecorder struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency replays the first response for requests that repeat an
+// Idempotency-Key within the TTL. Keys are scoped to the authenticated user
+// and route, so it must run after RequireAuth.
+func Idempotency(store idempotency.Store, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			AbortWithError(c, apperror.ErrInvalidRequest.WithDetail("Idempotency-Key is too long"))
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			AbortWithError(c, apperror.ErrInvalidRequest.WithDetail("Failed to read request body"))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		userID, _ := c.Get("user_id")
+		scope := fmt.Sprintf("%v:%s:%s", userID, c.Request.Method, c.FullPath())
+		record := idempotency.NewRecord(scope, key, body, ttl)
+
+		ctx := c.Request.Context()
+		existing, err := store.Reserve(ctx, record, ttl)
+		if err != nil {
+			AbortWithError(c, apperror.ErrUnavailable.WithDetail("Failed to check idempotency key").Wrap(err))
+			return
+		}
+
+		if existing != nil {
+			switch existing.Check(record.RequestHash) {
+			case idempotency.ErrKeyMismatch:
+				AbortWithError(c, ErrIdempotencyKeyMismatch)
+			case idempotency.ErrKeyInProgress:
+				AbortWithError(c, ErrIdempotencyKeyInProgress)
+			default:
+				c.Header(IdempotentReplayedHeader, "true")
+				c.Data(existing.StatusCode, existing.ContentType, existing.Body)
+			}
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = recorder
+
+		// Server errors are not stored so the client can retry them; nor is
+		// a handler that panicked or wrote nothing, which would otherwise
+		// hold the key in progress until it expires
+		completed := false
+		defer func() {
+			if !completed {
+				store.Release(context.Background(), record.Key)
+			}
+		}()
+
+		c.Next()
+
+		status := recorder.Status()
+		if !recorder.Written() || status >= http.StatusInternalServerError {
+			return
+		}
+
+		record.StatusCode = status
+		record.Body = recorder.body.Bytes()
+		record.ContentType = recorder.Header().Get("Content-Type")
+		store.Complete(context.Background(), record, ttl)
+		completed = true
+	}
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"errors"
+	"time"
+
+	"online-shop/internal/domain/audit"
+	"online-shop/internal/domain/impersonation"
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/jwt"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Impersonation errors
+var (
+	ErrImpersonationEnded       = apperror.Define(apperror.KindUnauthenticated, "impersonation_ended", "Impersonation has ended or expired")
+	ErrImpersonationCheckFailed = apperror.Define(apperror.KindUnavailable, "impersonation_check_failed", "Unable to verify impersonation")
+	ErrImpersonationReadOnly    = apperror.Define(apperror.KindPermissionDenied, "impersonation_read_only", "Impersonation only allows reading")
+	ErrImpersonationDenied      = apperror.Define(apperror.KindPermissionDenied, "impersonation_denied", "Not allowed while impersonating")
+)
+
+// Responses to requests made while impersonating name the admin and when
+// the impersonation ends, whatever their body
+const (
+	ImpersonatedByHeader       = "X-Impersonated-By"
+	ImpersonationExpiresHeader = "X-Impersonation-Expires"
+)
+
+const impersonationKey = "impersonation"
+
+// WithImpersonation accepts the tokens support admins act as customers with.
+// Each request made with one is checked against its grant and recorded in
+// the audit log. Without it those tokens are refused.
+func (m *AuthMiddleware) WithImpersonation(grants impersonation.Repository, auditRepo audit.Repository, logger *zap.Logger) *AuthMiddleware {
+	m.grants = grants
+	m.auditRepo = auditRepo
+	m.logger = logger
+	return m
+}
+
+// Impersonation returns the grant the request is made with when a support
+// admin is acting as the user.
+func Impersonation(c *gin.Context) (*impersonation.Grant, bool) {
+	grant, ok := c.Get(impersonationKey)
+	if !ok {
+		return nil, false
+	}
+	return grant.(*impersonation.Grant), true
+}
+
+// DenyImpersonation refuses impersonated requests to routes that change how
+// an account is secured or move money. It must run after RequireAuth.
+func DenyImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := Impersonation(c); ok {
+			AbortWithError(c, ErrImpersonationDenied)
+			return
+		}
+		c.Next()
+	}
+}
+
+// checkImpersonation verifies the grant of an impersonation token is still
+// active and allows the request. It returns no grant for other tokens.
+func (m *AuthMiddleware) checkImpersonation(c *gin.Context, claims *jwt.Claims) (*impersonation.Grant, error) {
+	if claims.Actor == nil {
+		return nil, nil
+	}
+	if m.grants == nil {
+		return nil, ErrImpersonationEnded
+	}
+
+	grant, err := m.grants.GetByID(c.Request.Context(), claims.Actor.GrantID)
+	if errors.Is(err, impersonation.ErrNotFound) {
+		return nil, ErrImpersonationEnded
+	}
+	if err != nil {
+		return nil, ErrImpersonationCheckFailed.Wrap(err)
+	}
+	if grant.CustomerID != claims.UserID || grant.AdminID != claims.Actor.Subject || !grant.Active(time.Now()) {
+		return nil, ErrImpersonationEnded
+	}
+	if grant.ReadOnly() && mutates(c.Request.Method) {
+		return nil, ErrImpersonationReadOnly
+	}
+	return grant, nil
+}
+
+// next continues the request, marking it as impersonated when it is made
+// with a grant. Routes that are not audited by the Audit middleware, reads
+// included, are recorded here once the request is done.
+func (m *AuthMiddleware) next(c *gin.Context, grant *impersonation.Grant) {
+	if grant == nil {
+		c.Next()
+		return
+	}
+	if _, seen := Impersonation(c); seen {
+		// Recorded by the auth middleware that ran first
+		c.Next()
+		return
+	}
+
+	c.Set(impersonationKey, grant)
+	c.Header(ImpersonatedByHeader, grant.AdminID)
+	c.Header(ImpersonationExpiresHeader, grant.ExpiresAt.UTC().Format(time.RFC3339))
+	actor := audit.Actor{
+		ID:             grant.CustomerID,
+		Role:           c.GetString("user_role"),
+		ImpersonatorID: grant.AdminID,
+		RequestID:      GetRequestID(c),
+	}
+	c.Request = c.Request.WithContext(audit.WithActor(c.Request.Context(), actor))
+
+	c.Next()
+
+	if !c.GetBool(auditedKey) {
+		writeAudit(m.auditRepo, m.logger, requestEntry(c, actor))
+	}
+}
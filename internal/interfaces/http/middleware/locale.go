@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"context"
+
+	"online-shop/pkg/i18n"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	localeKey      = "locale"
+	localePrefsKey = "locale_preferences"
+)
+
+// LocalePreferences returns the locale a user chose on their profile, or ""
+// when they have not chosen one.
+type LocalePreferences interface {
+	PreferredLocale(ctx context.Context, userID string) string
+}
+
+// LocalePreferencesFunc adapts a function to LocalePreferences.
+type LocalePreferencesFunc func(ctx context.Context, userID string) string
+
+func (f LocalePreferencesFunc) PreferredLocale(ctx context.Context, userID string) string {
+	return f(ctx, userID)
+}
+
+// Locale lets LocaleOf consult the signed-in user's preference before the
+// Accept-Language header. The locale is only worked out when something is
+// rendered in it, so this can run before the auth middleware. prefs may be
+// nil to only honour the header.
+func Locale(prefs LocalePreferences) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if prefs != nil {
+			c.Set(localePrefsKey, prefs)
+		}
+		c.Next()
+	}
+}
+
+// LocaleOf returns the locale to respond to the request in.
+func LocaleOf(c *gin.Context) string {
+	if locale := c.GetString(localeKey); locale != "" {
+		return locale
+	}
+
+	// Until auth has run the user is unknown, so the header's locale is
+	// not kept
+	var preferred string
+	final := true
+	if prefs, ok := c.Get(localePrefsKey); ok {
+		if userID := c.GetString("user_id"); userID != "" {
+			preferred = prefs.(LocalePreferences).PreferredLocale(c.Request.Context(), userID)
+		} else {
+			final = false
+		}
+	}
+
+	locale := i18n.Negotiate(c.GetHeader("Accept-Language"), preferred)
+	if final {
+		c.Set(localeKey, locale)
+	}
+	return locale
+}
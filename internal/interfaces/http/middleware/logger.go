@@ -5,10 +5,12 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+
+	"online-shop/pkg/logger"
 )
 
 // RequestLogger returns a middleware that logs HTTP requests
-func RequestLogger(logger *zap.Logger) gin.HandlerFunc {
+func RequestLogger(log *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
 		path := c.Request.URL.Path
@@ -20,12 +22,9 @@ func RequestLogger(logger *zap.Logger) gin.HandlerFunc {
 		// Calculate latency
 		latency := time.Since(start)
 
-		// Get request ID
-		requestID := GetRequestID(c)
-
-		// Build log fields
-		fields := []zap.Field{
-			zap.String("request_id", requestID),
+		// Build log fields; the request-scoped ones carry the request,
+		// user and trace ID
+		fields := append(logger.Fields(c.Request.Context()),
 			zap.String("method", c.Request.Method),
 			zap.String("path", path),
 			zap.String("query", raw),
@@ -34,12 +33,7 @@ func RequestLogger(logger *zap.Logger) gin.HandlerFunc {
 			zap.String("ip", c.ClientIP()),
 			zap.String("user_agent", c.Request.UserAgent()),
 			zap.Int("body_size", c.Writer.Size()),
-		}
-
-		// Add user ID if available
-		if userID, exists := c.Get("UserID"); exists {
-			fields = append(fields, zap.Any("user_id", userID))
-		}
+		)
 
 		// Add error if any
 		if len(c.Errors) > 0 {
@@ -49,11 +43,11 @@ func RequestLogger(logger *zap.Logger) gin.HandlerFunc {
 		// Log based on status code
 		switch {
 		case c.Writer.Status() >= 500:
-			logger.Error("HTTP Request", fields...)
+			log.Error("HTTP Request", fields...)
 		case c.Writer.Status() >= 400:
-			logger.Warn("HTTP Request", fields...)
+			log.Warn("HTTP Request", fields...)
 		default:
-			logger.Info("HTTP Request", fields...)
+			log.Info("HTTP Request", fields...)
 		}
 	}
-}
\ No newline at end of file
+}
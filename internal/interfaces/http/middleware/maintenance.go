@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"online-shop/internal/domain/maintenance"
+	"online-shop/internal/domain/user"
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/config"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+var ErrMaintenance = apperror.Define(apperror.KindUnavailable, "maintenance_mode", "The service is down for maintenance")
+
+// maintenanceExempt are served during maintenance whatever is configured, so
+// the API can be monitored and maintenance switched off again
+var maintenanceExempt = []string{"/health", "/metrics", "/admin"}
+
+// Maintenance answers requests with 503 and Retry-After while maintenance is
+// switched on or a scheduled window is under way. Health checks, the admin
+// API, the configured exempt paths and admins are still served; admins are
+// only recognised when an auth middleware ran first.
+//
+// The shared state is reread every refresh interval rather than on every
+// request. When it cannot be read the last state read is kept, and requests
+// are served if there is none.
+func Maintenance(store maintenance.Store, cfg *config.MaintenanceConfig, logger *zap.Logger) gin.HandlerFunc {
+	exempt := append(append([]string{}, maintenanceExempt...), cfg.ExemptPaths...)
+	cache := &maintenanceCache{store: store, refresh: cfg.Refresh(), logger: logger}
+	defaultRetry := cfg.RetryAfter()
+
+	return func(c *gin.Context) {
+		if maintenanceExempted(c, exempt) {
+			c.Next()
+			return
+		}
+
+		now := time.Now()
+		status := cache.state(c, now).Status(now)
+		if !status.Active {
+			c.Next()
+			return
+		}
+
+		wait := defaultRetry
+		if status.Until != nil {
+			wait = status.Until.Sub(now)
+		}
+		retryAfter := int(math.Ceil(wait.Seconds()))
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+		c.Header(RetryAfterHeader, strconv.Itoa(retryAfter))
+
+		err := ErrMaintenance.WithMeta("retry_after", strconv.Itoa(retryAfter))
+		if status.Message != "" {
+			err = err.WithDetail("%s", status.Message)
+		}
+		AbortWithError(c, err)
+	}
+}
+
+func maintenanceExempted(c *gin.Context, exempt []string) bool {
+	if c.GetString("user_role") == string(user.RoleAdmin) {
+		return true
+	}
+	path := c.Request.URL.Path
+	for _, prefix := range exempt {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// maintenanceCache keeps the last state read from the store for one API
+// instance
+type maintenanceCache struct {
+	store   maintenance.Store
+	refresh time.Duration
+	logger  *zap.Logger
+
+	mu      sync.Mutex
+	current *maintenance.State
+	readAt  time.Time
+}
+
+func (m *maintenanceCache) state(c *gin.Context, now time.Time) *maintenance.State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.current != nil && now.Sub(m.readAt) < m.refresh {
+		return m.current
+	}
+
+	state, err := m.store.Get(c.Request.Context())
+	if err != nil {
+		m.logger.Warn("Maintenance state unavailable, keeping last known state", zap.Error(err))
+		if m.current == nil {
+			m.current = &maintenance.State{}
+		}
+	} else {
+		m.current = state
+	}
+	// A failed read is retried after the same interval, not on every request
+	m.readAt = now
+	return m.current
+}
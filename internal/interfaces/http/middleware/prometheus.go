@@ -122,6 +122,14 @@ var (
 		[]string{"operation", "result"}, // get/set/delete, hit/miss/error
 	)
 
+	cacheKeysInvalidated = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_keys_invalidated_total",
+			Help: "Total number of cache keys removed by admin cache clears",
+		},
+		[]string{"scope"},
+	)
+
 	cacheSize = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "cache_size_bytes",
@@ -137,7 +145,7 @@ func PrometheusMetrics() gin.HandlerFunc {
 		start := time.Now()
 
 		// Get request size
-		requestSize := computeApproximateRequestSize(c.Request)
+		requestSize := computeApproximateRequestSize(c)
 
 		// Process request
 		c.Next()
@@ -206,6 +214,10 @@ func RecordCacheOperation(operation, result string) {
 	cacheOperations.WithLabelValues(operation, result).Inc()
 }
 
+func RecordCacheInvalidation(scope string, keys int64) {
+	cacheKeysInvalidated.WithLabelValues(scope).Add(float64(keys))
+}
+
 func RecordCacheSize(cacheType string, size int64) {
 	cacheSize.WithLabelValues(cacheType).Set(float64(size))
 }
@@ -2,26 +2,35 @@ package middleware
 
 import (
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
+	"online-shop/pkg/id"
+	"online-shop/pkg/logger"
 )
 
-const RequestIDHeader = "X-Request-ID"
+const (
+	RequestIDHeader = "X-Request-ID"
+	// TraceparentHeader carries the caller's W3C trace context
+	TraceparentHeader = "traceparent"
+)
 
 // RequestID adds a unique request ID to each request
 func RequestID() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Check if request ID already exists in headers
 		requestID := c.GetHeader(RequestIDHeader)
-		
+
 		// If not, generate a new one
 		if requestID == "" {
-			requestID = uuid.New().String()
+			requestID = id.New()
 		}
 
 		// Set the request ID in the context and response header
 		c.Set("RequestID", requestID)
 		c.Header(RequestIDHeader, requestID)
 
+		// Entries logged for the request carry its request and trace ID
+		traceID := logger.TraceID(c.GetHeader(TraceparentHeader))
+		c.Request = c.Request.WithContext(logger.NewContext(c.Request.Context(), requestID, traceID))
+
 		c.Next()
 	}
 }
@@ -32,4 +41,4 @@ func GetRequestID(c *gin.Context) string {
 		return requestID.(string)
 	}
 	return ""
-}
\ No newline at end of file
+}
@@ -1,33 +1,63 @@
 package middleware
 
 import (
+	"strconv"
+
+	"online-shop/pkg/config"
+
 	"github.com/gin-gonic/gin"
 )
 
-// SecurityHeaders adds security headers to responses
-func SecurityHeaders() gin.HandlerFunc {
+// cspReportGroup names the Reporting API endpoint CSP violations are sent to
+const cspReportGroup = "csp-endpoint"
+
+// SecurityHeaders adds security headers to responses. The values are built
+// once from the environment's configuration.
+func SecurityHeaders(cfg *config.SecurityHeadersConfig) gin.HandlerFunc {
+	headers := securityHeaders(cfg)
+
 	return func(c *gin.Context) {
-		// Prevent MIME type sniffing
-		c.Header("X-Content-Type-Options", "nosniff")
+		for _, h := range headers {
+			c.Header(h[0], h[1])
+		}
+		c.Next()
+	}
+}
 
+func securityHeaders(cfg *config.SecurityHeadersConfig) [][2]string {
+	headers := [][2]string{
+		// Prevent MIME type sniffing
+		{"X-Content-Type-Options", "nosniff"},
 		// Prevent clickjacking
-		c.Header("X-Frame-Options", "DENY")
-
+		{"X-Frame-Options", cfg.Frame()},
 		// Enable XSS protection
-		c.Header("X-XSS-Protection", "1; mode=block")
-
-		// Strict transport security (HTTPS only)
-		c.Header("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
-
-		// Content security policy
-		c.Header("Content-Security-Policy", "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; img-src 'self' data: https:; font-src 'self' data:; connect-src 'self'; frame-ancestors 'none'")
-
-		// Referrer policy
-		c.Header("Referrer-Policy", "strict-origin-when-cross-origin")
+		{"X-XSS-Protection", "1; mode=block"},
+		{"Referrer-Policy", cfg.Referrer()},
+		{"Permissions-Policy", cfg.Permissions()},
+	}
 
-		// Permissions policy
-		c.Header("Permissions-Policy", "geolocation=(), microphone=(), camera=()")
+	// Strict transport security (HTTPS only)
+	if cfg.HSTS.Enabled {
+		hsts := "max-age=" + strconv.Itoa(cfg.HSTS.MaxAge())
+		if cfg.HSTS.IncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if cfg.HSTS.Preload {
+			hsts += "; preload"
+		}
+		headers = append(headers, [2]string{"Strict-Transport-Security", hsts})
+	}
 
-		c.Next()
+	// Content security policy; browsers that know the Reporting API use
+	// report-to, older ones report-uri
+	csp := cfg.CSP()
+	if cfg.CSPReportURI != "" {
+		csp += "; report-uri " + cfg.CSPReportURI + "; report-to " + cspReportGroup
+		headers = append(headers, [2]string{"Reporting-Endpoints", cspReportGroup + `="` + cfg.CSPReportURI + `"`})
+	}
+	cspHeader := "Content-Security-Policy"
+	if cfg.CSPReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
 	}
-}
\ No newline at end of file
+	return append(headers, [2]string{cspHeader, csp})
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"online-shop/pkg/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Timeout gives every request a deadline. Handlers pass the request's
+// context down to repositories and clients, so their work is cancelled once
+// it passes, and with it when the client goes away. A request that ran out
+// of time without a response is answered as a timeout. It must run after
+// Errors.
+func Timeout(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			AbortWithError(c, apperror.ErrTimeout)
+		}
+	}
+}
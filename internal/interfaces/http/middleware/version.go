@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// APIVersionHeader tells the client which version of the API answered
+const APIVersionHeader = "API-Version"
+
+// Versions of the public API. Each is served from its own route group,
+// /api/v1 and /api/v2; a version's routes and responses do not change once
+// clients use them, and breaking changes go into the next version.
+const (
+	APIv1 = "v1"
+	APIv2 = "v2"
+)
+
+// APIVersion marks the responses of a version's route group.
+func APIVersion(version string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header(APIVersionHeader, version)
+		c.Next()
+	}
+}
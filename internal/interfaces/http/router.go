@@ -12,8 +12,14 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"go.uber.org/zap"
 
+	"online-shop/internal/domain/audit"
+	"online-shop/internal/domain/idempotency"
+	"online-shop/internal/domain/maintenance"
+	"online-shop/internal/domain/ratelimit"
+	"online-shop/internal/domain/user"
 	"online-shop/internal/interfaces/http/handlers"
 	"online-shop/internal/interfaces/http/middleware"
+	"online-shop/pkg/apperror"
 	"online-shop/pkg/config"
 )
 
@@ -25,7 +31,68 @@ type Router struct {
 	userHandler *handlers.UserHandler
 	productHandler *handlers.ProductHandler
 	orderHandler *handlers.OrderHandler
+	commissionHandler *handlers.CommissionHandler
+	warehouseHandler *handlers.WarehouseHandler
+	sitemapHandler *handlers.SitemapHandler
+	recommendationHandler *handlers.RecommendationHandler
+	recentlyViewedHandler *handlers.RecentlyViewedHandler
+	homeHandler *handlers.HomeHandler
+	bannerHandler *handlers.BannerHandler
+	reportHandler *handlers.ReportHandler
+	dashboardHandler *handlers.DashboardHandler
+	exportHandler *handlers.ExportHandler
+	downloadHandler *handlers.DownloadHandler
+	auditHandler *handlers.AuditHandler
+	systemLogHandler *handlers.SystemLogHandler
+	systemHandler *handlers.SystemHandler
+	cacheHandler *handlers.CacheHandler
+	oauthHandler *handlers.OAuthHandler
+	jwksHandler *handlers.JWKSHandler
+	sessionHandler *handlers.SessionHandler
+	dataExportHandler *handlers.DataExportHandler
+	configHandler *handlers.ConfigHandler
+	experimentHandler *handlers.ExperimentHandler
+	emailTemplateHandler *handlers.EmailTemplateHandler
+	emailDeliveryHandler *handlers.EmailDeliveryHandler
+	whatsAppHandler *handlers.WhatsAppHandler
+	contentHandler *handlers.ContentHandler
+	cmsHandler *handlers.CMSHandler
+	flashSaleHandler *handlers.FlashSaleHandler
+	paymentMethodHandler *handlers.PaymentMethodHandler
+	orderPaymentHandler *handlers.OrderPaymentHandler
+	reconciliationHandler *handlers.ReconciliationHandler
+	fraudHandler *handlers.FraudHandler
+	stockAlertHandler *handlers.StockAlertHandler
+	priceAlertHandler *handlers.PriceAlertHandler
+	fulfillmentHandler *handlers.FulfillmentHandler
+	codHandler *handlers.CODHandler
+	webhookHandler *handlers.WebhookHandler
+	impersonationHandler *handlers.ImpersonationHandler
+	maintenanceHandler *handlers.MaintenanceHandler
+	cspReportHandler *handlers.CSPReportHandler
+	jobHandler *handlers.JobHandler
+	productV2Handler *handlers.ProductV2Handler
+	orderV2Handler *handlers.OrderV2Handler
+	storefrontHandler *handlers.StorefrontHandler
+	shippingHandler *handlers.ShippingHandler
+	quoteHandler *handlers.QuoteHandler
+	organizationHandler *handlers.OrganizationHandler
+	translationHandler *handlers.TranslationHandler
+	merchandisingHandler *handlers.MerchandisingHandler
+	badgeHandler *handlers.BadgeHandler
+	forecastHandler *handlers.ForecastHandler
+	moderationHandler *handlers.ModerationHandler
+	productVersionHandler *handlers.ProductVersionHandler
+	cartHandler *handlers.CartHandler
+	campaignHandler *handlers.CampaignHandler
+	notificationPreferenceHandler *handlers.NotificationPreferenceHandler
+	manualOrderHandler *handlers.ManualOrderHandler
+	draftOrderHandler *handlers.DraftOrderHandler
 	authMiddleware *middleware.AuthMiddleware
+	idempotencyStore idempotency.Store
+	auditRepo audit.Repository
+	rateLimiter ratelimit.Limiter
+	maintenanceStore maintenance.Store
 }
 
 // NewRouter creates a new HTTP router
@@ -35,7 +102,68 @@ func NewRouter(
 	userHandler *handlers.UserHandler,
 	productHandler *handlers.ProductHandler,
 	orderHandler *handlers.OrderHandler,
+	commissionHandler *handlers.CommissionHandler,
+	warehouseHandler *handlers.WarehouseHandler,
+	sitemapHandler *handlers.SitemapHandler,
+	recommendationHandler *handlers.RecommendationHandler,
+	recentlyViewedHandler *handlers.RecentlyViewedHandler,
+	homeHandler *handlers.HomeHandler,
+	bannerHandler *handlers.BannerHandler,
+	reportHandler *handlers.ReportHandler,
+	dashboardHandler *handlers.DashboardHandler,
+	exportHandler *handlers.ExportHandler,
+	downloadHandler *handlers.DownloadHandler,
+	auditHandler *handlers.AuditHandler,
+	systemLogHandler *handlers.SystemLogHandler,
+	systemHandler *handlers.SystemHandler,
+	cacheHandler *handlers.CacheHandler,
+	oauthHandler *handlers.OAuthHandler,
+	jwksHandler *handlers.JWKSHandler,
+	sessionHandler *handlers.SessionHandler,
+	dataExportHandler *handlers.DataExportHandler,
+	configHandler *handlers.ConfigHandler,
+	experimentHandler *handlers.ExperimentHandler,
+	emailTemplateHandler *handlers.EmailTemplateHandler,
+	emailDeliveryHandler *handlers.EmailDeliveryHandler,
+	whatsAppHandler *handlers.WhatsAppHandler,
+	contentHandler *handlers.ContentHandler,
+	cmsHandler *handlers.CMSHandler,
+	flashSaleHandler *handlers.FlashSaleHandler,
+	paymentMethodHandler *handlers.PaymentMethodHandler,
+	orderPaymentHandler *handlers.OrderPaymentHandler,
+	reconciliationHandler *handlers.ReconciliationHandler,
+	fraudHandler *handlers.FraudHandler,
+	stockAlertHandler *handlers.StockAlertHandler,
+	priceAlertHandler *handlers.PriceAlertHandler,
+	fulfillmentHandler *handlers.FulfillmentHandler,
+	codHandler *handlers.CODHandler,
+	webhookHandler *handlers.WebhookHandler,
+	impersonationHandler *handlers.ImpersonationHandler,
+	maintenanceHandler *handlers.MaintenanceHandler,
+	cspReportHandler *handlers.CSPReportHandler,
+	jobHandler *handlers.JobHandler,
+	productV2Handler *handlers.ProductV2Handler,
+	orderV2Handler *handlers.OrderV2Handler,
+	storefrontHandler *handlers.StorefrontHandler,
+	shippingHandler *handlers.ShippingHandler,
+	quoteHandler *handlers.QuoteHandler,
+	organizationHandler *handlers.OrganizationHandler,
+	translationHandler *handlers.TranslationHandler,
+	merchandisingHandler *handlers.MerchandisingHandler,
+	badgeHandler *handlers.BadgeHandler,
+	forecastHandler *handlers.ForecastHandler,
+	moderationHandler *handlers.ModerationHandler,
+	productVersionHandler *handlers.ProductVersionHandler,
+	cartHandler *handlers.CartHandler,
+	campaignHandler *handlers.CampaignHandler,
+	notificationPreferenceHandler *handlers.NotificationPreferenceHandler,
+	manualOrderHandler *handlers.ManualOrderHandler,
+	draftOrderHandler *handlers.DraftOrderHandler,
 	authMiddleware *middleware.AuthMiddleware,
+	idempotencyStore idempotency.Store,
+	auditRepo audit.Repository,
+	rateLimiter ratelimit.Limiter,
+	maintenanceStore maintenance.Store,
 ) *Router {
 	// Set Gin mode based on environment
 	if cfg.Environment == "production" {
@@ -53,7 +181,68 @@ func NewRouter(
 		userHandler:    userHandler,
 		productHandler: productHandler,
 		orderHandler:   orderHandler,
+		commissionHandler: commissionHandler,
+		warehouseHandler: warehouseHandler,
+		sitemapHandler: sitemapHandler,
+		recommendationHandler: recommendationHandler,
+		recentlyViewedHandler: recentlyViewedHandler,
+		homeHandler: homeHandler,
+		bannerHandler: bannerHandler,
+		reportHandler: reportHandler,
+		dashboardHandler: dashboardHandler,
+		exportHandler: exportHandler,
+		downloadHandler: downloadHandler,
+		auditHandler: auditHandler,
+		systemLogHandler: systemLogHandler,
+		systemHandler: systemHandler,
+		cacheHandler: cacheHandler,
+		oauthHandler: oauthHandler,
+		jwksHandler: jwksHandler,
+		sessionHandler: sessionHandler,
+		dataExportHandler: dataExportHandler,
+		configHandler: configHandler,
+		experimentHandler: experimentHandler,
+		emailTemplateHandler: emailTemplateHandler,
+		emailDeliveryHandler: emailDeliveryHandler,
+		whatsAppHandler: whatsAppHandler,
+		contentHandler: contentHandler,
+		cmsHandler: cmsHandler,
+		flashSaleHandler: flashSaleHandler,
+		paymentMethodHandler: paymentMethodHandler,
+		orderPaymentHandler: orderPaymentHandler,
+		reconciliationHandler: reconciliationHandler,
+		fraudHandler: fraudHandler,
+		stockAlertHandler: stockAlertHandler,
+		priceAlertHandler: priceAlertHandler,
+		fulfillmentHandler: fulfillmentHandler,
+		codHandler: codHandler,
+		webhookHandler: webhookHandler,
+		impersonationHandler: impersonationHandler,
+		maintenanceHandler: maintenanceHandler,
+		cspReportHandler: cspReportHandler,
+		jobHandler: jobHandler,
+		productV2Handler: productV2Handler,
+		orderV2Handler: orderV2Handler,
+		storefrontHandler: storefrontHandler,
+		shippingHandler: shippingHandler,
+		quoteHandler: quoteHandler,
+		organizationHandler: organizationHandler,
+		translationHandler: translationHandler,
+		merchandisingHandler: merchandisingHandler,
+		badgeHandler: badgeHandler,
+		forecastHandler: forecastHandler,
+		moderationHandler: moderationHandler,
+		productVersionHandler: productVersionHandler,
+		cartHandler: cartHandler,
+		campaignHandler: campaignHandler,
+		notificationPreferenceHandler: notificationPreferenceHandler,
+		manualOrderHandler: manualOrderHandler,
+		draftOrderHandler: draftOrderHandler,
 		authMiddleware: authMiddleware,
+		idempotencyStore: idempotencyStore,
+		auditRepo: auditRepo,
+		rateLimiter: rateLimiter,
+		maintenanceStore: maintenanceStore,
 	}
 }
 
@@ -74,6 +263,27 @@ func (r *Router) SetupRoutes() {
 	// Admin routes
 	r.setupAdminRoutes()
 
+	// Warehouse fulfillment routes
+	r.setupFulfillmentRoutes()
+
+	// Outbound webhook routes
+	r.setupWebhookRoutes()
+
+	// Merchant routes
+	r.setupMerchantRoutes()
+
+	// Sitemap routes
+	r.setupSitemapRoutes()
+
+	// Export download routes
+	r.setupDownloadRoutes()
+
+	// Token signing key routes
+	r.setupJWKSRoutes()
+
+	// Error catalog routes
+	r.setupErrorRoutes()
+
 	// Documentation routes
 	r.setupDocumentationRoutes()
 }
@@ -83,29 +293,50 @@ func (r *Router) setupGlobalMiddleware() {
 	// Recovery middleware
 	r.engine.Use(gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
 		r.logger.Error("Panic recovered", zap.Any("error", recovered))
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Internal server error",
-		})
+		middleware.AbortWithError(c, apperror.ErrInternal)
 	}))
 
 	// Request logging middleware
 	r.engine.Use(middleware.RequestLogger(r.logger))
 
+	// Error middleware; errors attached with c.Error are rendered as
+	// problem documents before the request is logged
+	r.engine.Use(middleware.Errors())
+
+	// Body limit middleware; request bodies are capped per route group
+	r.engine.MaxMultipartMemory = r.config.RequestLimits.MultipartMemory()
+	r.engine.Use(middleware.BodyLimit(&r.config.RequestLimits))
+
+	// Locale middleware; problems and validation messages follow
+	// Accept-Language
+	r.engine.Use(middleware.Locale(nil))
+
 	// CORS middleware
 	r.engine.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"}, // Configure based on your needs
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization", "X-Requested-With"},
-		ExposeHeaders:    []string{"Content-Length", "X-Total-Count"},
+		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization", "X-Requested-With", middleware.IdempotencyKeyHeader, handlers.SessionIDHeader, handlers.CartTokenHeader},
+		ExposeHeaders:    []string{"Content-Length", "X-Total-Count", middleware.IdempotentReplayedHeader, middleware.RateLimitLimitHeader, middleware.RateLimitRemainingHeader, middleware.RetryAfterHeader, handlers.CartTokenHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
-	// Rate limiting middleware
-	r.engine.Use(middleware.RateLimit())
+	// Rate limiting middleware; the caller is identified first so per-user
+	// policies and admin exemptions apply
+	if r.config.RateLimit.Enabled {
+		rules, err := middleware.RateLimitRules(&r.config.RateLimit)
+		if err != nil {
+			r.logger.Fatal("Invalid rate limit configuration", zap.Error(err))
+		}
+		r.engine.Use(r.authMiddleware.OptionalAuth())
+		r.engine.Use(middleware.DistributedRateLimit(r.rateLimiter, rules, r.logger))
+	}
+
+	// Maintenance mode middleware; health checks and the admin API stay up
+	r.engine.Use(middleware.Maintenance(r.maintenanceStore, &r.config.Maintenance, r.logger))
 
 	// Security headers middleware
-	r.engine.Use(middleware.SecurityHeaders())
+	r.engine.Use(middleware.SecurityHeaders(&r.config.SecurityHeaders))
 
 	// Request ID middleware
 	r.engine.Use(middleware.RequestID())
@@ -143,6 +374,9 @@ func (r *Router) setupMonitoringRoutes() {
 	// Prometheus metrics endpoint
 	r.engine.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
+	// Content-Security-Policy violation reports from browsers
+	r.engine.POST("/csp-reports", r.cspReportHandler.ReportViolation)
+
 	// pprof endpoints for profiling (only in development)
 	if r.config.Environment != "production" {
 		pprof.Register(r.engine)
@@ -154,6 +388,7 @@ func (r *Router) setupAPIRoutes() {
 	api := r.engine.Group("/api")
 	{
 		v1 := api.Group("/v1")
+		v1.Use(middleware.APIVersion(middleware.APIv1))
 		{
 			// Public routes (no authentication required)
 			r.setupPublicRoutes(v1)
@@ -161,6 +396,30 @@ func (r *Router) setupAPIRoutes() {
 			// Protected routes (authentication required)
 			r.setupProtectedRoutes(v1)
 		}
+
+		v2 := api.Group("/v2")
+		v2.Use(middleware.APIVersion(middleware.APIv2))
+		r.setupV2Routes(v2)
+	}
+}
+
+// setupV2Routes configures the v2 API, which answers with its own
+// representations of products and orders and pages by keyset cursor
+func (r *Router) setupV2Routes(rg *gin.RouterGroup) {
+	products := rg.Group("/products")
+	{
+		products.GET("", r.productV2Handler.ListProducts)
+		products.GET("/:id", r.authMiddleware.OptionalAuth(), r.productV2Handler.GetProduct)
+	}
+
+	orders := rg.Group("/orders")
+	orders.Use(r.authMiddleware.RequireAuth())
+	orders.Use(middleware.Audit(r.auditRepo, r.logger))
+	{
+		orders.POST("", middleware.Idempotency(r.idempotencyStore, r.config.Idempotency.TTL()), r.orderV2Handler.CreateOrder)
+		orders.GET("", r.orderV2Handler.ListOrders)
+		orders.GET("/:id", r.orderV2Handler.GetOrder)
+		orders.PUT("/:id/cancel", r.orderV2Handler.CancelOrder)
 	}
 }
 
@@ -175,21 +434,102 @@ func (r *Router) setupPublicRoutes(rg *gin.RouterGroup) {
 		auth.POST("/forgot-password", r.userHandler.ForgotPassword)
 		auth.POST("/reset-password", r.userHandler.ResetPassword)
 		auth.GET("/verify-email/:token", r.userHandler.VerifyEmail)
+		auth.GET("/oauth/:provider/start", r.oauthHandler.Start)
+		auth.GET("/oauth/:provider/callback", r.oauthHandler.Callback)
+		auth.POST("/oauth/:provider/callback", r.oauthHandler.Callback)
 	}
 
 	// Public product routes
 	products := rg.Group("/products")
 	{
 		products.GET("", r.productHandler.GetProducts)
-		products.GET("/:id", r.productHandler.GetProduct)
+		products.GET("/:id", r.authMiddleware.OptionalAuth(), r.productHandler.GetProduct)
 		products.GET("/search", r.productHandler.SearchProducts)
+		products.GET("/search/facets", r.productHandler.GetSearchFacets)
+		products.GET("/compare", r.productHandler.CompareProducts)
 		products.GET("/categories", r.productHandler.GetCategories)
 		products.GET("/category/:slug", r.productHandler.GetProductsByCategory)
 		products.GET("/:id/reviews", r.productHandler.GetProductReviews)
+		products.GET("/:id/similar", r.recommendationHandler.GetSimilarProducts)
+		products.GET("/:id/bought-together", r.recommendationHandler.GetBoughtTogether)
+		products.GET("/:id/delivery-estimate", r.shippingHandler.DeliveryEstimate)
 		products.GET("/featured", r.productHandler.GetFeaturedProducts)
 		products.GET("/trending", r.productHandler.GetTrendingProducts)
 	}
 
+	// Home feed, personalised when the viewer is known
+	rg.GET("/home", r.authMiddleware.OptionalAuth(), r.homeHandler.GetHome)
+
+	// Storefront content, cached and only while scheduled
+	content := rg.Group("/cms")
+	{
+		content.GET("/banners", r.contentHandler.GetBanners)
+		content.GET("/blocks/:slot", r.contentHandler.GetSlot)
+		content.GET("/pages/:slug", r.contentHandler.GetPage)
+		content.GET("/assets/:id", r.contentHandler.GetAsset)
+	}
+
+	// Storefront name, theme, currencies and locales
+	rg.GET("/storefront/settings", r.storefrontHandler.GetPublicSettings)
+
+	// Shipping rates for an address, which is checked first
+	rg.POST("/shipping/quote", r.shippingHandler.Quote)
+
+	// Live and upcoming flash sales with their countdowns
+	flashSales := rg.Group("/flash-sales")
+	{
+		flashSales.GET("", r.flashSaleHandler.GetCurrentSales)
+		flashSales.GET("/:id", r.flashSaleHandler.GetPublicSale)
+	}
+
+	// Recently viewed works for signed-in users and anonymous sessions
+	rg.GET("/user/recently-viewed", r.authMiddleware.OptionalAuth(), r.recentlyViewedHandler.GetRecentlyViewed)
+
+	// Experiment assignments and tracking for signed-in users and anonymous sessions
+	experiments := rg.Group("/experiments", r.authMiddleware.OptionalAuth())
+	{
+		experiments.GET("", r.experimentHandler.GetAssignments)
+		experiments.POST("/:key/exposures", r.experimentHandler.TrackExposure)
+		experiments.POST("/:key/conversions", r.experimentHandler.TrackConversion)
+	}
+
+	// Bounce and complaint notifications, verified by the provider's signature
+	rg.POST("/email/webhooks/:provider", r.emailDeliveryHandler.IngestEvents)
+
+	// Campaign email opens and clicks, from the signed links in the emails
+	rg.GET("/campaigns/track/open/:token", r.campaignHandler.TrackOpen)
+	rg.GET("/campaigns/track/click/:token", r.campaignHandler.TrackClick)
+
+	// Unsubscribe links of alert and marketing emails, signed; mail clients
+	// post to them for one-click unsubscribes
+	rg.GET("/notifications/unsubscribe/:token", r.notificationPreferenceHandler.GetUnsubscribe)
+	rg.POST("/notifications/unsubscribe/:token", r.notificationPreferenceHandler.Unsubscribe)
+
+	// Payment links of draft orders: the summary, hosted as a page for
+	// browsers, and the payment started from it
+	rg.GET("/draft-orders/:token", r.draftOrderHandler.GetSummary)
+	rg.POST("/draft-orders/:token/pay", r.draftOrderHandler.Pay)
+
+	// WhatsApp webhook: the subscription check, then delivery statuses and
+	// template reviews signed with the app secret
+	rg.GET("/whatsapp/webhook", r.whatsAppHandler.VerifyWebhook)
+	rg.POST("/whatsapp/webhook", r.whatsAppHandler.ReceiveWebhook)
+
+	// Carts of signed-in customers and, by their cart token, anonymous
+	// visitors
+	cart := rg.Group("/cart", r.authMiddleware.OptionalAuth())
+	{
+		cart.GET("", r.cartHandler.GetCart)
+		cart.POST("/items", r.cartHandler.AddToCart)
+		cart.PUT("/items/:id", r.cartHandler.UpdateCartItem)
+		cart.DELETE("/items/:id", r.cartHandler.RemoveFromCart)
+		cart.DELETE("", r.cartHandler.ClearCart)
+	}
+
+	// Order tracking by order number and email, for customers who are not
+	// signed in
+	rg.GET("/orders/track", r.orderHandler.TrackOrder)
+
 	// Public category routes
 	categories := rg.Group("/categories")
 	{
@@ -202,15 +542,33 @@ func (r *Router) setupPublicRoutes(rg *gin.RouterGroup) {
 func (r *Router) setupProtectedRoutes(rg *gin.RouterGroup) {
 	protected := rg.Group("")
 	protected.Use(r.authMiddleware.RequireAuth())
+	protected.Use(middleware.Audit(r.auditRepo, r.logger))
+
+	// Admins impersonating the user cannot change how the account is
+	// secured or move money
+	notImpersonated := middleware.DenyImpersonation()
 
 	// User profile routes
 	user := protected.Group("/user")
 	{
 		user.GET("/profile", r.userHandler.GetProfile)
 		user.PUT("/profile", r.userHandler.UpdateProfile)
-		user.POST("/change-password", r.userHandler.ChangePassword)
+		user.POST("/change-password", notImpersonated, r.userHandler.ChangePassword)
 		user.POST("/logout", r.userHandler.Logout)
-		user.DELETE("/account", r.userHandler.DeleteAccount)
+		user.DELETE("/account", notImpersonated, r.userHandler.DeleteAccount)
+		user.POST("/data-export", notImpersonated, r.dataExportHandler.RequestDataExport)
+
+		// Which notifications the user gets over which channel
+		user.GET("/notification-preferences", r.notificationPreferenceHandler.GetPreferences)
+		user.PUT("/notification-preferences", r.notificationPreferenceHandler.UpdatePreferences)
+
+		// Sessions
+		sessions := user.Group("/sessions")
+		{
+			sessions.GET("", r.sessionHandler.ListSessions)
+			sessions.DELETE("", notImpersonated, r.sessionHandler.RevokeAllSessions)
+			sessions.DELETE("/:id", notImpersonated, r.sessionHandler.RevokeSession)
+		}
 
 		// User addresses
 		addresses := user.Group("/addresses")
@@ -230,6 +588,61 @@ func (r *Router) setupProtectedRoutes(rg *gin.RouterGroup) {
 			orders.POST("/:id/cancel", r.orderHandler.CancelOrder)
 		}
 
+		// Saved payment methods
+		paymentMethods := user.Group("/payment-methods")
+		{
+			paymentMethods.GET("", r.paymentMethodHandler.ListMethods)
+			paymentMethods.POST("", notImpersonated, r.paymentMethodHandler.AddMethod)
+			paymentMethods.DELETE("/:id", notImpersonated, r.paymentMethodHandler.DeleteMethod)
+			paymentMethods.PUT("/:id/default", notImpersonated, r.paymentMethodHandler.SetDefaultMethod)
+		}
+
+		// Receipts of what the user has paid
+		user.GET("/receipts", r.orderPaymentHandler.ListReceipts)
+
+		// Back in stock alerts
+		stockAlerts := user.Group("/stock-alerts")
+		{
+			stockAlerts.GET("", r.stockAlertHandler.ListAlerts)
+			stockAlerts.POST("", r.stockAlertHandler.Subscribe)
+			stockAlerts.DELETE("/:id", r.stockAlertHandler.Cancel)
+		}
+
+		// Price drop alerts
+		priceAlerts := user.Group("/price-alerts")
+		{
+			priceAlerts.GET("", r.priceAlertHandler.ListAlerts)
+			priceAlerts.POST("", r.priceAlertHandler.Watch)
+			priceAlerts.DELETE("/:id", r.priceAlertHandler.Cancel)
+		}
+
+		// Requests for quotes
+		quotes := user.Group("/quotes")
+		{
+			quotes.GET("", r.quoteHandler.ListMyQuotes)
+			quotes.POST("", r.quoteHandler.RequestQuote)
+			quotes.GET("/:id", r.quoteHandler.GetQuote)
+			quotes.POST("/:id/accept", r.quoteHandler.AcceptQuote)
+			quotes.POST("/:id/cancel", r.quoteHandler.CancelQuote)
+		}
+
+		// Business accounts buying on invoice
+		organization := user.Group("/organization")
+		{
+			organization.GET("", r.organizationHandler.GetOrganization)
+			organization.POST("", r.organizationHandler.CreateOrganization)
+			organization.GET("/members", r.organizationHandler.ListMembers)
+			organization.POST("/members", r.organizationHandler.AddMember)
+			organization.PUT("/members/:userId", r.organizationHandler.UpdateMember)
+			organization.DELETE("/members/:userId", r.organizationHandler.RemoveMember)
+			organization.PUT("/approval-chain", r.organizationHandler.SetApprovalChain)
+			organization.GET("/orders", r.organizationHandler.ListOrders)
+			organization.GET("/approvals", r.organizationHandler.ListApprovals)
+			organization.POST("/approvals/:id/approve", r.organizationHandler.ApprovePurchase)
+			organization.POST("/approvals/:id/reject", r.organizationHandler.RejectPurchase)
+			organization.GET("/invoices", r.organizationHandler.ListInvoices)
+		}
+
 		// User wishlist
 		wishlist := user.Group("/wishlist")
 		{
@@ -239,23 +652,27 @@ func (r *Router) setupProtectedRoutes(rg *gin.RouterGroup) {
 		}
 	}
 
-	// Cart routes
-	cart := protected.Group("/cart")
-	{
-		cart.GET("", r.orderHandler.GetCart)
-		cart.POST("/items", r.orderHandler.AddToCart)
-		cart.PUT("/items/:id", r.orderHandler.UpdateCartItem)
-		cart.DELETE("/items/:id", r.orderHandler.RemoveFromCart)
-		cart.DELETE("", r.orderHandler.ClearCart)
-	}
-
 	// Order routes
+	idempotent := middleware.Idempotency(r.idempotencyStore, r.config.Idempotency.TTL())
+
+	// Merging an anonymous cart and checking out need an account; the rest
+	// of the cart is served to anonymous visitors too
+	protected.POST("/cart/merge", r.cartHandler.MergeCart)
+	protected.POST("/cart/checkout", idempotent, r.cartHandler.Checkout)
+	protected.POST("/checkout/preview", r.cartHandler.PreviewCheckout)
+
 	orders := protected.Group("/orders")
 	{
-		orders.POST("", r.orderHandler.CreateOrder)
+		orders.POST("", idempotent, r.orderHandler.CreateOrder)
+		orders.POST("/one-click", notImpersonated, idempotent, r.paymentMethodHandler.OneClickCheckout)
 		orders.GET("/:id", r.orderHandler.GetOrder)
-		orders.POST("/:id/payment", r.orderHandler.ProcessPayment)
-		orders.GET("/:id/invoice", r.orderHandler.GetInvoice)
+		orders.POST("/:id/payment", notImpersonated, idempotent, r.orderHandler.ProcessPayment)
+		orders.GET("/:id/payments", r.orderPaymentHandler.GetPayments)
+		orders.POST("/:id/payments", notImpersonated, idempotent, r.orderPaymentHandler.CreatePayments)
+		orders.POST("/:id/payments/:paymentId/pay", notImpersonated, idempotent, r.orderPaymentHandler.PayPayment)
+		orders.POST("/:id/cash-on-delivery", notImpersonated, idempotent, r.codHandler.PayOnDelivery)
+		orders.POST("/:id/pay-on-invoice", notImpersonated, idempotent, r.organizationHandler.PayOnInvoice)
+		orders.GET("/:id/invoice", r.orderPaymentHandler.GetInvoice)
 	}
 
 	// Review routes
@@ -273,9 +690,10 @@ func (r *Router) setupAdminRoutes() {
 	admin := r.engine.Group("/admin")
 	admin.Use(r.authMiddleware.RequireAuth())
 	admin.Use(r.authMiddleware.RequireRole("admin"))
+	admin.Use(middleware.Audit(r.auditRepo, r.logger))
 
 	// Admin dashboard
-	admin.GET("/dashboard", r.getDashboardStats)
+	admin.GET("/dashboard", r.dashboardHandler.GetStats)
 
 	// Admin user management
 	users := admin.Group("/users")
@@ -286,6 +704,15 @@ func (r *Router) setupAdminRoutes() {
 		users.DELETE("/:id", r.userHandler.DeleteUser)
 		users.POST("/:id/suspend", r.userHandler.SuspendUser)
 		users.POST("/:id/activate", r.userHandler.ActivateUser)
+		users.POST("/:id/impersonate", r.impersonationHandler.StartImpersonation)
+		users.PUT("/:id/age-verification", r.userHandler.VerifyAge)
+	}
+
+	// Support admins acting as customers
+	impersonations := admin.Group("/impersonations")
+	{
+		impersonations.GET("", r.impersonationHandler.ListImpersonations)
+		impersonations.POST("/:id/end", r.impersonationHandler.EndImpersonation)
 	}
 
 	// Admin product management
@@ -294,10 +721,28 @@ func (r *Router) setupAdminRoutes() {
 		products.POST("", r.productHandler.CreateProduct)
 		products.PUT("/:id", r.productHandler.UpdateProduct)
 		products.DELETE("/:id", r.productHandler.DeleteProduct)
+		products.PUT("/:id/slug", r.productHandler.UpdateProductSlug)
+		products.PUT("/:id/featured", r.productHandler.SetFeatured)
+		products.PUT("/:id/attributes", r.productHandler.SetProductAttributes)
+		products.PUT("/:id/bundle", r.productHandler.SetProductBundle)
+		products.PUT("/:id/purchase-limits", r.productHandler.SetPurchaseLimits)
 		products.POST("/:id/activate", r.productHandler.ActivateProduct)
 		products.POST("/:id/deactivate", r.productHandler.DeactivateProduct)
+		products.PUT("/:id/status", r.productHandler.SetProductStatus)
+		products.PUT("/:id/publish-at", r.productHandler.ScheduleProductPublish)
+		products.POST("/:id/duplicate", r.productHandler.DuplicateProduct)
+		products.GET("/:id/versions", r.productVersionHandler.ListVersions)
+		products.GET("/:id/versions/:version", r.productVersionHandler.GetVersion)
+		products.GET("/:id/versions/:version/diff", r.productVersionHandler.DiffVersions)
+		products.POST("/:id/versions/:version/rollback", r.productVersionHandler.RollbackProduct)
 		products.GET("/:id/inventory", r.productHandler.GetInventoryMovements)
 		products.POST("/:id/inventory", r.productHandler.AdjustInventory)
+		products.GET("/:id/translations", r.translationHandler.ListProductTranslations)
+		products.PUT("/:id/translations/:locale", r.translationHandler.SetProductTranslation)
+		products.DELETE("/:id/translations/:locale", r.translationHandler.DeleteProductTranslation)
+		products.GET("/:id/badges", r.badgeHandler.ListProductBadges)
+		products.POST("/:id/badges", r.badgeHandler.AssignProductBadge)
+		products.DELETE("/:id/badges/:code", r.badgeHandler.UnassignProductBadge)
 	}
 
 	// Admin category management
@@ -306,16 +751,143 @@ func (r *Router) setupAdminRoutes() {
 		categories.POST("", r.productHandler.CreateCategory)
 		categories.PUT("/:id", r.productHandler.UpdateCategory)
 		categories.DELETE("/:id", r.productHandler.DeleteCategory)
+		categories.PUT("/:id/slug", r.productHandler.UpdateCategorySlug)
+		categories.PUT("/:id/attributes", r.productHandler.SetCategoryAttributes)
+		categories.PUT("/:id/minimum-age", r.productHandler.SetCategoryMinimumAge)
+		categories.GET("/:id/translations", r.translationHandler.ListCategoryTranslations)
+		categories.PUT("/:id/translations/:locale", r.translationHandler.SetCategoryTranslation)
+		categories.DELETE("/:id/translations/:locale", r.translationHandler.DeleteCategoryTranslation)
+	}
+
+	// Admin catalog translation reports
+	translations := admin.Group("/translations")
+	{
+		translations.GET("", r.translationHandler.GetReport)
+		translations.GET("/missing", r.translationHandler.ListMissing)
+	}
+
+	// Admin merchandising rules for search results and category pages
+	merchandising := admin.Group("/merchandising")
+	{
+		merchandising.GET("/rules", r.merchandisingHandler.ListRules)
+		merchandising.POST("/rules", r.merchandisingHandler.CreateRule)
+		merchandising.PUT("/rules/:id", r.merchandisingHandler.UpdateRule)
+		merchandising.DELETE("/rules/:id", r.merchandisingHandler.DeleteRule)
+		merchandising.GET("/preview", r.merchandisingHandler.Preview)
+	}
+
+	// Admin product badges and the rules computing them
+	badges := admin.Group("/badges")
+	{
+		badges.GET("", r.badgeHandler.ListBadges)
+		badges.POST("", r.badgeHandler.CreateBadge)
+		badges.PUT("/:code", r.badgeHandler.UpdateBadge)
+		badges.DELETE("/:code", r.badgeHandler.DeleteBadge)
+	}
+
+	// Admin review of merchant products
+	submissions := admin.Group("/product-submissions")
+	{
+		submissions.GET("", r.moderationHandler.ListSubmissions)
+		submissions.GET("/:id", r.moderationHandler.GetSubmission)
+		submissions.POST("/:id/approve", r.moderationHandler.ApproveSubmission)
+		submissions.POST("/:id/reject", r.moderationHandler.RejectSubmission)
+	}
+
+	// Admin inventory forecast
+	inventory := admin.Group("/inventory")
+	{
+		inventory.GET("/reorder-suggestions", r.forecastHandler.ListReorderSuggestions)
 	}
 
 	// Admin order management
 	orders := admin.Group("/orders")
 	{
 		orders.GET("", r.orderHandler.GetOrders)
+		orders.POST("", r.manualOrderHandler.CreateOrder)
+		orders.GET("/cancellations", r.orderHandler.GetCancellationReport)
 		orders.GET("/:id", r.orderHandler.GetOrder)
 		orders.PUT("/:id/status", r.orderHandler.UpdateOrderStatus)
 		orders.POST("/:id/ship", r.orderHandler.ShipOrder)
 		orders.POST("/:id/refund", r.orderHandler.RefundOrder)
+		orders.POST("/:id/offline-payments", r.manualOrderHandler.RecordOfflinePayment)
+	}
+
+	// Admin commission rules
+	commissions := admin.Group("/commissions")
+	{
+		commissions.GET("", r.commissionHandler.ListRules)
+		commissions.POST("", r.commissionHandler.CreateRule)
+		commissions.PUT("/:id", r.commissionHandler.UpdateRule)
+		commissions.DELETE("/:id", r.commissionHandler.DeleteRule)
+	}
+
+	// Admin warehouse management
+	warehouses := admin.Group("/warehouses")
+	{
+		warehouses.GET("", r.warehouseHandler.ListWarehouses)
+		warehouses.POST("", r.warehouseHandler.CreateWarehouse)
+		warehouses.PUT("/:id", r.warehouseHandler.UpdateWarehouse)
+		warehouses.GET("/:id/stock", r.warehouseHandler.GetStock)
+		warehouses.PUT("/:id/stock/:product_id", r.warehouseHandler.SetStock)
+	}
+
+	// Admin home banners
+	banners := admin.Group("/banners")
+	{
+		banners.GET("", r.bannerHandler.ListBanners)
+		banners.POST("", r.bannerHandler.CreateBanner)
+		banners.PUT("/:id", r.bannerHandler.UpdateBanner)
+		banners.DELETE("/:id", r.bannerHandler.DeleteBanner)
+	}
+
+	// Admin content blocks, static pages and images
+	cmsBlocks := admin.Group("/cms/blocks")
+	{
+		cmsBlocks.GET("", r.cmsHandler.ListBlocks)
+		cmsBlocks.POST("", r.cmsHandler.CreateBlock)
+		cmsBlocks.PUT("/:id", r.cmsHandler.UpdateBlock)
+		cmsBlocks.DELETE("/:id", r.cmsHandler.DeleteBlock)
+	}
+	cmsPages := admin.Group("/cms/pages")
+	{
+		cmsPages.GET("", r.cmsHandler.ListPages)
+		cmsPages.POST("", r.cmsHandler.CreatePage)
+		cmsPages.GET("/:id", r.cmsHandler.GetPage)
+		cmsPages.PUT("/:id", r.cmsHandler.UpdatePage)
+		cmsPages.DELETE("/:id", r.cmsHandler.DeletePage)
+	}
+	cmsAssets := admin.Group("/cms/assets")
+	{
+		cmsAssets.GET("", r.cmsHandler.ListAssets)
+		cmsAssets.POST("", r.cmsHandler.UploadAsset)
+		cmsAssets.DELETE("/:id", r.cmsHandler.DeleteAsset)
+	}
+
+	// Admin storefront settings
+	storefront := admin.Group("/storefront")
+	{
+		storefront.GET("/settings", r.storefrontHandler.GetSettings)
+		storefront.PUT("/settings", r.storefrontHandler.UpdateSettings)
+	}
+
+	// Admin shipping zones and their rates
+	shippingZones := admin.Group("/shipping/zones")
+	{
+		shippingZones.GET("", r.shippingHandler.ListZones)
+		shippingZones.POST("", r.shippingHandler.CreateZone)
+		shippingZones.PUT("/:id", r.shippingHandler.UpdateZone)
+		shippingZones.DELETE("/:id", r.shippingHandler.DeleteZone)
+	}
+
+	// Admin flash sale management
+	adminFlashSales := admin.Group("/flash-sales")
+	{
+		adminFlashSales.GET("", r.flashSaleHandler.ListSales)
+		adminFlashSales.POST("", r.flashSaleHandler.CreateSale)
+		adminFlashSales.GET("/:id", r.flashSaleHandler.GetSale)
+		adminFlashSales.PUT("/:id", r.flashSaleHandler.UpdateSale)
+		adminFlashSales.DELETE("/:id", r.flashSaleHandler.DeleteSale)
 	}
 
 	// Admin review management
@@ -329,98 +901,253 @@ func (r *Router) setupAdminRoutes() {
 	// Admin analytics
 	analytics := admin.Group("/analytics")
 	{
-		analytics.GET("/sales", r.getSalesAnalytics)
-		analytics.GET("/products", r.getProductAnalytics)
-		analytics.GET("/users", r.getUserAnalytics)
-		analytics.GET("/revenue", r.getRevenueAnalytics)
+		analytics.GET("/sales", r.reportHandler.GetSalesAnalytics)
+		analytics.GET("/products", r.reportHandler.GetProductAnalytics)
+		analytics.GET("/users", r.reportHandler.GetUserAnalytics)
+		analytics.GET("/revenue", r.reportHandler.GetRevenueAnalytics)
 	}
 
+	// Admin experiments
+	adminExperiments := admin.Group("/experiments")
+	{
+		adminExperiments.GET("", r.experimentHandler.ListExperiments)
+		adminExperiments.POST("", r.experimentHandler.CreateExperiment)
+		adminExperiments.PUT("/:id", r.experimentHandler.UpdateExperiment)
+		adminExperiments.GET("/:id/report", r.experimentHandler.GetExperimentReport)
+	}
+
+	// Admin email templates
+	emailTemplates := admin.Group("/email-templates")
+	{
+		emailTemplates.GET("", r.emailTemplateHandler.ListTemplates)
+		emailTemplates.GET("/:name", r.emailTemplateHandler.GetTemplate)
+		emailTemplates.PUT("/:name", r.emailTemplateHandler.SaveTemplate)
+		emailTemplates.DELETE("/:name", r.emailTemplateHandler.DeleteTemplate)
+		emailTemplates.GET("/:name/versions", r.emailTemplateHandler.ListVersions)
+		emailTemplates.POST("/:name/versions/:version/activate", r.emailTemplateHandler.ActivateVersion)
+		emailTemplates.POST("/:name/preview", r.emailTemplateHandler.PreviewTemplate)
+		emailTemplates.POST("/:name/test", r.emailTemplateHandler.SendTestEmail)
+	}
+
+	// Admin email suppression list
+	emailSuppressions := admin.Group("/email-suppressions")
+	{
+		emailSuppressions.GET("", r.emailDeliveryHandler.ListSuppressions)
+		emailSuppressions.POST("", r.emailDeliveryHandler.AddSuppression)
+		emailSuppressions.GET("/:email", r.emailDeliveryHandler.GetSuppression)
+		emailSuppressions.DELETE("/:email", r.emailDeliveryHandler.RemoveSuppression)
+	}
+
+	// Admin email dead letters and campaigns
+	emailDeadLetters := admin.Group("/email-dead-letters")
+	{
+		emailDeadLetters.GET("", r.emailDeliveryHandler.ListDeadLetters)
+		emailDeadLetters.GET("/:id", r.emailDeliveryHandler.GetDeadLetter)
+		emailDeadLetters.POST("/:id/resend", r.emailDeliveryHandler.ResendDeadLetter)
+	}
+	admin.POST("/email-campaigns", r.emailDeliveryHandler.SendCampaign)
+
+	// Admin scheduled marketing campaigns to customer segments
+	campaigns := admin.Group("/campaigns")
+	{
+		campaigns.GET("", r.campaignHandler.ListCampaigns)
+		campaigns.POST("", r.campaignHandler.CreateCampaign)
+		campaigns.GET("/:id", r.campaignHandler.GetCampaign)
+		campaigns.PUT("/:id", r.campaignHandler.UpdateCampaign)
+		campaigns.POST("/:id/schedule", r.campaignHandler.ScheduleCampaign)
+		campaigns.POST("/:id/cancel", r.campaignHandler.CancelCampaign)
+		campaigns.GET("/:id/stats", r.campaignHandler.GetCampaignStats)
+		campaigns.GET("/:id/audience", r.campaignHandler.CountAudience)
+	}
+
+	// Admin WhatsApp templates and sent messages
+	whatsAppTemplates := admin.Group("/whatsapp-templates")
+	{
+		whatsAppTemplates.GET("", r.whatsAppHandler.ListTemplates)
+		whatsAppTemplates.POST("", r.whatsAppHandler.CreateTemplate)
+		whatsAppTemplates.POST("/sync", r.whatsAppHandler.SyncTemplates)
+		whatsAppTemplates.GET("/:name/:language", r.whatsAppHandler.GetTemplate)
+		whatsAppTemplates.DELETE("/:name", r.whatsAppHandler.DeleteTemplate)
+	}
+	whatsAppMessages := admin.Group("/whatsapp-messages")
+	{
+		whatsAppMessages.GET("", r.whatsAppHandler.ListMessages)
+		whatsAppMessages.GET("/:id", r.whatsAppHandler.GetMessage)
+	}
+
+	// Admin report exports
+	exports := admin.Group("/exports")
+	{
+		exports.POST("", r.exportHandler.RequestExport)
+		exports.GET("", r.exportHandler.ListExports)
+		exports.GET("/:id", r.exportHandler.GetExport)
+	}
+
+	// Admin payment reconciliation against the provider
+	reconciliationRuns := admin.Group("/reconciliation/runs")
+	{
+		reconciliationRuns.GET("", r.reconciliationHandler.ListRuns)
+		reconciliationRuns.POST("", r.reconciliationHandler.TriggerRun)
+		reconciliationRuns.GET("/:id", r.reconciliationHandler.GetRun)
+	}
+
+	// Admin fraud review queue; reviews are keyed by order
+	fraudReviews := admin.Group("/fraud/reviews")
+	{
+		fraudReviews.GET("", r.fraudHandler.ListReviews)
+		fraudReviews.GET("/:id", r.fraudHandler.GetReview)
+		fraudReviews.POST("/:id/approve", r.fraudHandler.ApproveOrder)
+		fraudReviews.POST("/:id/decline", r.fraudHandler.DeclineOrder)
+	}
+
+	// Admin cash on delivery: courier cash and customer limits
+	cashOnDelivery := admin.Group("/cod")
+	{
+		cashOnDelivery.GET("/collections", r.codHandler.ListCollections)
+		cashOnDelivery.GET("/couriers", r.codHandler.ListCourierBalances)
+		cashOnDelivery.GET("/remittances", r.codHandler.ListRemittances)
+		cashOnDelivery.POST("/remittances", r.codHandler.RecordRemittance)
+		cashOnDelivery.GET("/remittances/:id", r.codHandler.GetRemittance)
+		cashOnDelivery.GET("/customers/:id", r.codHandler.GetCustomer)
+		cashOnDelivery.PUT("/customers/:id", r.codHandler.SetCustomerLimit)
+	}
+
+	// Admin business accounts: credit terms and invoices paid by transfer
+	organizations := admin.Group("/organizations")
+	{
+		organizations.GET("", r.organizationHandler.ListOrganizations)
+		organizations.PUT("/:id/credit", r.organizationHandler.SetCredit)
+		organizations.GET("/invoices", r.organizationHandler.ListAllInvoices)
+		organizations.POST("/invoices/:id/pay", r.organizationHandler.RecordInvoicePayment)
+	}
+
+	// Admin background jobs taken off the queues
+	jobs := admin.Group("/jobs")
+	{
+		jobs.GET("", r.jobHandler.ListJobs)
+		jobs.GET("/:id", r.jobHandler.GetJob)
+		jobs.POST("/:id/retry", r.jobHandler.RetryJob)
+		jobs.POST("/:id/cancel", r.jobHandler.CancelJob)
+	}
+
+	// Admin audit trail
+	admin.GET("/audit-logs", r.auditHandler.ListAuditLogs)
+
+	// Admin config inspection
+	admin.GET("/config", r.configHandler.GetConfig)
+
 	// Admin system management
 	system := admin.Group("/system")
 	{
-		system.GET("/logs", r.getSystemLogs)
-		system.POST("/backup", r.triggerBackup)
-		system.GET("/health", r.getSystemHealth)
-		system.POST("/cache/clear", r.clearCache)
+		system.GET("/logs", r.systemLogHandler.SearchLogs)
+		system.POST("/backup", r.systemHandler.TriggerBackup)
+		system.GET("/backups", r.systemHandler.ListBackups)
+		system.GET("/backups/:id", r.systemHandler.GetBackup)
+		system.GET("/health", r.systemHandler.GetHealth)
+		system.POST("/cache/clear", r.cacheHandler.ClearCache)
+		system.GET("/maintenance", r.maintenanceHandler.GetMaintenance)
+		system.PUT("/maintenance", r.maintenanceHandler.SetMaintenance)
+		system.POST("/maintenance/windows", r.maintenanceHandler.ScheduleWindow)
+		system.DELETE("/maintenance/windows/:id", r.maintenanceHandler.CancelWindow)
 	}
 }
 
-// setupDocumentationRoutes configures documentation routes
-func (r *Router) setupDocumentationRoutes() {
-	// Swagger documentation
-	r.engine.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+// setupFulfillmentRoutes configures the picking and packing workflow for
+// warehouse staff, who hold the fulfillment role; admins may use it too
+func (r *Router) setupFulfillmentRoutes() {
+	fulfillment := r.engine.Group("/fulfillment")
+	fulfillment.Use(r.authMiddleware.RequireAuth())
+	fulfillment.Use(r.authMiddleware.RequireRole(string(user.RoleFulfillment), string(user.RoleAdmin)))
+	fulfillment.Use(middleware.Audit(r.auditRepo, r.logger))
 
-	// API documentation
-	r.engine.GET("/", func(c *gin.Context) {
-		c.Redirect(http.StatusMovedPermanently, "/docs/index.html")
-	})
+	fulfillment.GET("/warehouses/:id/pick-list", r.fulfillmentHandler.GetPickList)
+	fulfillment.POST("/warehouses/:id/pick-list", r.fulfillmentHandler.GeneratePickList)
+	fulfillment.POST("/shipments/:id/pack", r.fulfillmentHandler.PackShipment)
+	fulfillment.POST("/shipments/:id/ship", r.fulfillmentHandler.ShipShipment)
+	fulfillment.POST("/shipments/:id/deliver", r.fulfillmentHandler.DeliverShipment)
 }
 
-// GetEngine returns the Gin engine
-func (r *Router) GetEngine() *gin.Engine {
-	return r.engine
-}
+// setupWebhookRoutes configures the endpoints merchants are posted their
+// events at; admins manage everyone's, and register partners' endpoints
+func (r *Router) setupWebhookRoutes() {
+	webhooks := r.engine.Group("/webhooks")
+	webhooks.Use(r.authMiddleware.RequireAuth())
+	webhooks.Use(r.authMiddleware.RequireRole(string(user.RoleMerchant), string(user.RoleAdmin)))
+	webhooks.Use(middleware.Audit(r.auditRepo, r.logger))
 
-// Dashboard and analytics handlers
-func (r *Router) getDashboardStats(c *gin.Context) {
-	// Implementation for dashboard statistics
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Dashboard stats endpoint",
-	})
+	webhooks.GET("/endpoints", r.webhookHandler.ListEndpoints)
+	webhooks.POST("/endpoints", r.webhookHandler.RegisterEndpoint)
+	webhooks.GET("/endpoints/:id", r.webhookHandler.GetEndpoint)
+	webhooks.PUT("/endpoints/:id", r.webhookHandler.UpdateEndpoint)
+	webhooks.DELETE("/endpoints/:id", r.webhookHandler.DeleteEndpoint)
+	webhooks.GET("/endpoints/:id/deliveries", r.webhookHandler.ListDeliveries)
+	webhooks.GET("/deliveries/:id", r.webhookHandler.GetDelivery)
+	webhooks.POST("/deliveries/:id/redeliver", r.webhookHandler.Redeliver)
 }
 
-func (r *Router) getSalesAnalytics(c *gin.Context) {
-	// Implementation for sales analytics
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Sales analytics endpoint",
-	})
-}
+// setupMerchantRoutes configures the quotes merchants price, the orders they
+// draft for customers to pay by link and the products they submit for
+// review; admins see and price everyone's quotes and drafts and may submit
+// changes to any product
+func (r *Router) setupMerchantRoutes() {
+	merchant := r.engine.Group("/merchant")
+	merchant.Use(r.authMiddleware.RequireAuth())
+	merchant.Use(r.authMiddleware.RequireRole(string(user.RoleMerchant), string(user.RoleAdmin)))
+	merchant.Use(middleware.Audit(r.auditRepo, r.logger))
 
-func (r *Router) getProductAnalytics(c *gin.Context) {
-	// Implementation for product analytics
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Product analytics endpoint",
-	})
+	merchant.GET("/quotes", r.quoteHandler.ListMerchantQuotes)
+	merchant.GET("/quotes/:id", r.quoteHandler.GetMerchantQuote)
+	merchant.POST("/quotes/:id/offer", r.quoteHandler.OfferQuote)
+	merchant.POST("/quotes/:id/decline", r.quoteHandler.DeclineQuote)
+
+	merchant.GET("/draft-orders", r.draftOrderHandler.ListDrafts)
+	merchant.POST("/draft-orders", r.draftOrderHandler.CreateDraft)
+	merchant.GET("/draft-orders/:id", r.draftOrderHandler.GetDraft)
+	merchant.POST("/draft-orders/:id/send", r.draftOrderHandler.SendDraft)
+	merchant.POST("/draft-orders/:id/cancel", r.draftOrderHandler.CancelDraft)
+
+	merchant.POST("/products", r.moderationHandler.SubmitNewProduct)
+	merchant.PUT("/products/:id", r.moderationHandler.SubmitProductChanges)
+	merchant.GET("/product-submissions", r.moderationHandler.ListMerchantSubmissions)
+	merchant.GET("/product-submissions/:id", r.moderationHandler.GetMerchantSubmission)
 }
 
-func (r *Router) getUserAnalytics(c *gin.Context) {
-	// Implementation for user analytics
-	c.JSON(http.StatusOK, gin.H{
-		"message": "User analytics endpoint",
-	})
+// setupSitemapRoutes serves the generated sitemap index and pages
+func (r *Router) setupSitemapRoutes() {
+	r.engine.GET("/sitemap.xml", r.sitemapHandler.GetIndex)
+	r.engine.GET("/sitemaps/:file", r.sitemapHandler.GetSitemap)
 }
 
-func (r *Router) getRevenueAnalytics(c *gin.Context) {
-	// Implementation for revenue analytics
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Revenue analytics endpoint",
-	})
+// setupDownloadRoutes serves signed links to locally stored export files
+func (r *Router) setupDownloadRoutes() {
+	r.engine.GET("/downloads/*key", r.downloadHandler.Download)
 }
 
-func (r *Router) getSystemLogs(c *gin.Context) {
-	// Implementation for system logs
-	c.JSON(http.StatusOK, gin.H{
-		"message": "System logs endpoint",
-	})
+// setupJWKSRoutes publishes the keys other services validate tokens with
+func (r *Router) setupJWKSRoutes() {
+	r.engine.GET("/.well-known/jwks.json", r.jwksHandler.GetKeys)
 }
 
-func (r *Router) triggerBackup(c *gin.Context) {
-	// Implementation for triggering backup
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Backup triggered",
-	})
+// setupErrorRoutes configures the error catalog routes problem types point to
+func (r *Router) setupErrorRoutes() {
+	errorCatalog := handlers.NewErrorCatalogHandler()
+	r.engine.GET("/errors", errorCatalog.ListErrors)
+	r.engine.GET("/errors/:code", errorCatalog.GetError)
 }
 
-func (r *Router) getSystemHealth(c *gin.Context) {
-	// Implementation for system health check
-	c.JSON(http.StatusOK, gin.H{
-		"message": "System health endpoint",
+// setupDocumentationRoutes configures documentation routes
+func (r *Router) setupDocumentationRoutes() {
+	// Swagger UI over the OpenAPI document built from the handlers
+	r.engine.GET(handlers.OpenAPIPath, handlers.NewDocsHandler().GetSpec)
+	r.engine.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, ginSwagger.URL(handlers.OpenAPIPath)))
+
+	// API documentation
+	r.engine.GET("/", func(c *gin.Context) {
+		c.Redirect(http.StatusMovedPermanently, "/docs/index.html")
 	})
 }
 
-func (r *Router) clearCache(c *gin.Context) {
-	// Implementation for clearing cache
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Cache cleared",
-	})
-}
\ No newline at end of file
+// GetEngine returns the Gin engine
+func (r *Router) GetEngine() *gin.Engine {
+	return r.engine
+}
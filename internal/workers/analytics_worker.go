@@ -1,20 +1,31 @@
 package workers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
+	"online-shop/internal/domain/analytics"
+	"online-shop/internal/domain/product"
 	"online-shop/internal/infrastructure/queue"
 	"online-shop/pkg/config"
 )
 
+// EventSink receives processed events for persistence in the analytics store
+type EventSink interface {
+	Add(ctx context.Context, event analytics.Event) error
+}
+
 // AnalyticsWorker handles analytics event processing
 type AnalyticsWorker struct {
-	config *config.Config
-	logger *logrus.Logger
+	config         *config.Config
+	logger         *logrus.Logger
+	events         EventSink
+	trending       product.TrendingRepository
+	recentlyViewed product.RecentlyViewedRepository
 }
 
 // AnalyticsEvent represents an analytics event
@@ -36,16 +47,40 @@ type AnalyticsEvent struct {
 	City        string                 `json:"city,omitempty"`
 }
 
+// toDomain converts the consumed payload into the stored event shape
+func (e AnalyticsEvent) toDomain() analytics.Event {
+	return analytics.Event{
+		EventID:    e.EventID,
+		UserID:     e.UserID,
+		SessionID:  e.SessionID,
+		EventType:  e.EventType,
+		EventName:  e.EventName,
+		Properties: e.Properties,
+		Timestamp:  e.Timestamp,
+		IPAddress:  e.IPAddress,
+		UserAgent:  e.UserAgent,
+		Referrer:   e.Referrer,
+		PageURL:    e.PageURL,
+		DeviceType: e.DeviceType,
+		Platform:   e.Platform,
+		Country:    e.Country,
+		City:       e.City,
+	}
+}
+
 // NewAnalyticsWorker creates a new analytics worker
-func NewAnalyticsWorker(cfg *config.Config, logger *logrus.Logger) *AnalyticsWorker {
+func NewAnalyticsWorker(cfg *config.Config, logger *logrus.Logger, events EventSink, trending product.TrendingRepository, recentlyViewed product.RecentlyViewedRepository) *AnalyticsWorker {
 	return &AnalyticsWorker{
-		config: cfg,
-		logger: logger,
+		config:         cfg,
+		logger:         logger,
+		events:         events,
+		trending:       trending,
+		recentlyViewed: recentlyViewed,
 	}
 }
 
 // ProcessMessage processes an analytics message
-func (w *AnalyticsWorker) ProcessMessage(message queue.Message) error {
+func (w *AnalyticsWorker) ProcessMessage(ctx context.Context, message queue.Message) error {
 	startTime := time.Now()
 	w.logger.Debug("Processing analytics message", logrus.Fields{"message_id": message.ID})
 
@@ -61,7 +96,7 @@ func (w *AnalyticsWorker) ProcessMessage(message queue.Message) error {
 	}
 
 	// Process event
-	if err := w.processEvent(event); err != nil {
+	if err := w.processEvent(ctx, event); err != nil {
 		return fmt.Errorf("failed to process analytics event: %w", err)
 	}
 
@@ -111,7 +146,7 @@ func (w *AnalyticsWorker) validateEvent(event AnalyticsEvent) error {
 }
 
 // processEvent processes the analytics event
-func (w *AnalyticsWorker) processEvent(event AnalyticsEvent) error {
+func (w *AnalyticsWorker) processEvent(ctx context.Context, event AnalyticsEvent) error {
 	w.logger.Debug("Processing analytics event",
 		logrus.Fields{
 			"event_id":   event.EventID,
@@ -121,12 +156,12 @@ func (w *AnalyticsWorker) processEvent(event AnalyticsEvent) error {
 		})
 
 	// Store event data
-	if err := w.storeEvent(event); err != nil {
+	if err := w.storeEvent(ctx, event); err != nil {
 		return fmt.Errorf("failed to store event: %w", err)
 	}
 
 	// Update real-time metrics
-	if err := w.updateRealTimeMetrics(event); err != nil {
+	if err := w.updateRealTimeMetrics(ctx, event); err != nil {
 		w.logger.Warn("Failed to update real-time metrics",
 			logrus.Fields{
 				"event_id": event.EventID,
@@ -139,7 +174,7 @@ func (w *AnalyticsWorker) processEvent(event AnalyticsEvent) error {
 }
 
 // storeEvent stores the analytics event
-func (w *AnalyticsWorker) storeEvent(event AnalyticsEvent) error {
+func (w *AnalyticsWorker) storeEvent(ctx context.Context, event AnalyticsEvent) error {
 	w.logger.Debug("Storing analytics event",
 		logrus.Fields{
 			"event_id":   event.EventID,
@@ -147,13 +182,13 @@ func (w *AnalyticsWorker) storeEvent(event AnalyticsEvent) error {
 			"event_name": event.EventName,
 		})
 
-	// In a real implementation, you would:
-	// 1. Store in time-series database (InfluxDB, TimescaleDB)
-	// 2. Store in data warehouse (BigQuery, Redshift, Snowflake)
-	// 3. Send to analytics platforms (Google Analytics, Mixpanel, etc.)
+	if w.events == nil {
+		return nil
+	}
 
-	// Simulate data storage
-	time.Sleep(50 * time.Millisecond)
+	if err := w.events.Add(ctx, event.toDomain()); err != nil {
+		return err
+	}
 
 	w.logger.Debug("Analytics event stored successfully",
 		logrus.Fields{
@@ -164,25 +199,61 @@ func (w *AnalyticsWorker) storeEvent(event AnalyticsEvent) error {
 }
 
 // updateRealTimeMetrics updates real-time metrics
-func (w *AnalyticsWorker) updateRealTimeMetrics(event AnalyticsEvent) error {
+func (w *AnalyticsWorker) updateRealTimeMetrics(ctx context.Context, event AnalyticsEvent) error {
 	w.logger.Debug("Updating real-time metrics",
 		logrus.Fields{
 			"event_id":   event.EventID,
 			"event_type": event.EventType,
 		})
 
-	// In a real implementation, you would:
-	// 1. Update Redis counters
-	// 2. Send to real-time analytics dashboard
-	// 3. Update WebSocket connections for live data
-	// 4. Trigger alerts if thresholds are met
+	// Update trending scores
+	if err := w.updateTrending(ctx, event); err != nil {
+		return err
+	}
+
+	// Update the viewer's recently viewed list
+	if err := w.updateRecentlyViewed(ctx, event); err != nil {
+		return err
+	}
 
-	// Simulate real-time metrics update
-	time.Sleep(10 * time.Millisecond)
+	// In a real implementation, you would:
+	// 1. Send to real-time analytics dashboard
+	// 2. Update WebSocket connections for live data
+	// 3. Trigger alerts if thresholds are met
 
 	return nil
 }
 
+// updateTrending adds product events to the trending buckets
+func (w *AnalyticsWorker) updateTrending(ctx context.Context, event AnalyticsEvent) error {
+	if w.trending == nil {
+		return nil
+	}
+
+	scored := event.toDomain()
+	score, ok := scored.TrendingScore()
+	if !ok {
+		return nil
+	}
+
+	return w.trending.Record(ctx, scored.ProductID(), score, event.Timestamp)
+}
+
+// updateRecentlyViewed records product views against the user or session
+func (w *AnalyticsWorker) updateRecentlyViewed(ctx context.Context, event AnalyticsEvent) error {
+	if event.EventName != analytics.EventProductViewed || w.recentlyViewed == nil {
+		return nil
+	}
+
+	viewer := product.ViewerKey(event.UserID, event.SessionID)
+	productID, _ := event.Properties["product_id"].(string)
+	if viewer == "" || productID == "" {
+		return nil
+	}
+
+	return w.recentlyViewed.Add(ctx, viewer, productID)
+}
+
 // Helper function to convert map to struct
 func mapToStruct(m map[string]interface{}, v interface{}) error {
 	data, err := json.Marshal(m)
@@ -2,17 +2,26 @@ package workers
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
-	"net/smtp"
+	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/campaign"
+	"online-shop/internal/domain/emaildelivery"
+	"online-shop/internal/domain/emailtemplate"
+	"online-shop/internal/domain/notification"
+	emailprovider "online-shop/internal/infrastructure/emaildelivery"
 	"online-shop/internal/infrastructure/queue"
 	"online-shop/pkg/config"
+	"online-shop/pkg/i18n"
 )
 
 // EmailWorker handles email processing
@@ -20,9 +29,19 @@ type EmailWorker struct {
 	config    *config.Config
 	logger    *logrus.Logger
 	templates map[string]*template.Template
+	store     *queries.GetEmailTemplateQueryHandler
+
+	providers    []emaildelivery.Provider
+	suppressions emaildelivery.SuppressionRepository
+	deadLetters  emaildelivery.DeadLetterRepository
+	sender       *emaildelivery.Sender
+	tracker      *campaign.Tracker
+	policy       *notification.Policy
+	unsubscribe  *notification.Links
 }
 
-// NewEmailWorker creates a new email worker
+// NewEmailWorker creates a new email worker sending through the providers
+// in email.providers, throttled to email.rate_limits
 func NewEmailWorker(cfg *config.Config, logger *logrus.Logger) *EmailWorker {
 	worker := &EmailWorker{
 		config:    cfg,
@@ -30,366 +49,360 @@ func NewEmailWorker(cfg *config.Config, logger *logrus.Logger) *EmailWorker {
 		templates: make(map[string]*template.Template),
 	}
 
+	providers, err := emailprovider.Providers(cfg)
+	if err != nil {
+		logger.Error("Failed to initialize email providers", logrus.Fields{"error": err.Error()})
+	}
+	worker.providers = providers
+	worker.buildSender()
+
 	// Load email templates
 	worker.loadTemplates()
 
 	return worker
 }
 
+// SetTemplateStore makes the worker send the templates admins edit; without
+// a store it only uses template files and the embedded defaults
+func (w *EmailWorker) SetTemplateStore(store *queries.GetEmailTemplateQueryHandler) {
+	w.store = store
+}
+
+// SetSuppressions makes the worker skip addresses that bounced or
+// complained
+func (w *EmailWorker) SetSuppressions(suppressions emaildelivery.SuppressionRepository) {
+	w.suppressions = suppressions
+	w.buildSender()
+}
+
+// SetDeadLetters keeps emails that could not be sent for an admin to
+// resend; without it failed messages go back to the queue
+func (w *EmailWorker) SetDeadLetters(deadLetters emaildelivery.DeadLetterRepository) {
+	w.deadLetters = deadLetters
+}
+
+// SetTracker adds open and click tracking to the emails of campaigns that
+// ask for it
+func (w *EmailWorker) SetTracker(tracker *campaign.Tracker) {
+	w.tracker = tracker
+}
+
+// SetPolicy skips emails whose recipients turned off their category;
+// without a policy every email is sent
+func (w *EmailWorker) SetPolicy(policy *notification.Policy) {
+	w.policy = policy
+}
+
+// SetUnsubscribeLinks adds an unsubscribe link and the List-Unsubscribe
+// headers to the emails of categories customers can unsubscribe from
+func (w *EmailWorker) SetUnsubscribeLinks(links *notification.Links) {
+	w.unsubscribe = links
+}
+
+func (w *EmailWorker) buildSender() {
+	providers := make([]emaildelivery.Provider, len(w.providers))
+	for i, p := range w.providers {
+		providers[i] = p
+		if limit, ok := w.config.Email.RateLimits[p.Name()]; ok && limit > 0 {
+			providers[i] = emaildelivery.Throttle(p, limit)
+		}
+	}
+
+	w.sender = emaildelivery.NewSender(w.suppressions, providers...)
+	w.sender.SetRetry(emaildelivery.RetryPolicy{
+		Attempts:  w.config.Email.Retry.Attempts,
+		BaseDelay: w.config.Email.Retry.BaseDelay(),
+		MaxDelay:  w.config.Email.Retry.MaxDelay(),
+	})
+}
+
+// SetSMTPPassword switches to a rotated SMTP password without a restart
+func (w *EmailWorker) SetSMTPPassword(password string) {
+	for _, p := range w.providers {
+		if smtp, ok := p.(*emailprovider.SMTPProvider); ok {
+			smtp.SetPassword(password)
+		}
+	}
+}
+
 // ProcessMessage processes an email message
-func (w *EmailWorker) ProcessMessage(message queue.Message) error {
+func (w *EmailWorker) ProcessMessage(ctx context.Context, message queue.Message) error {
 	w.logger.Info("Processing email message", logrus.Fields{"message_id": message.ID})
 
+	if message.Type == "email_batch" {
+		return w.processBatch(ctx, message)
+	}
+
 	// Parse email data
 	var emailData queue.EmailMessage
 	if err := mapToStruct(message.Payload, &emailData); err != nil {
 		return fmt.Errorf("failed to parse email data: %w", err)
 	}
 
-	// Send email
-	if err := w.sendEmail(emailData); err != nil {
-		return fmt.Errorf("failed to send email: %w", err)
+	return w.deliver(ctx, message.ID, emailData)
+}
+
+// processBatch sends a campaign batch one recipient at a time. A recipient
+// that fails is dead-lettered on its own, so the batch is never requeued and
+// nobody gets the campaign twice.
+func (w *EmailWorker) processBatch(ctx context.Context, message queue.Message) error {
+	var batch queue.EmailBatchMessage
+	if err := mapToStruct(message.Payload, &batch); err != nil {
+		return fmt.Errorf("failed to parse email batch: %w", err)
+	}
+
+	failed := 0
+	for _, recipient := range batch.Recipients {
+		err := w.deliver(ctx, message.ID, queue.EmailMessage{
+			To:         recipient.To,
+			Subject:    batch.Subject,
+			Template:   batch.Template,
+			Data:       emaildelivery.MergeData(batch.Data, recipient.Data),
+			Locale:     batch.Locale,
+			CampaignID: batch.CampaignID,
+			Tracked:    batch.Tracked,
+			Category:   batch.Category,
+		})
+		if err != nil {
+			failed++
+			w.logger.Error("Failed to send campaign email",
+				logrus.Fields{
+					"message_id":  message.ID,
+					"campaign_id": batch.CampaignID,
+					"to":          recipient.To,
+					"error":       err.Error(),
+				})
+		}
 	}
 
-	w.logger.Info("Email sent successfully",
+	w.logger.Info("Email batch processed",
 		logrus.Fields{
-			"message_id": message.ID,
-			"to":         emailData.To,
-			"subject":    emailData.Subject,
+			"message_id":  message.ID,
+			"campaign_id": batch.CampaignID,
+			"recipients":  len(batch.Recipients),
+			"failed":      failed,
 		})
-
 	return nil
 }
 
-// sendEmail sends an email using SMTP
-func (w *EmailWorker) sendEmail(email queue.EmailMessage) error {
-	// Render email content
-	body, err := w.renderTemplate(email.Template, email.Data)
-	if err != nil {
-		return fmt.Errorf("failed to render template: %w", err)
+// deliver sends an email, dead-lettering it once the retries are exhausted
+// or the failure is permanent. It only returns an error when the email is
+// neither sent nor stored.
+func (w *EmailWorker) deliver(ctx context.Context, messageID string, email queue.EmailMessage) error {
+	provider, err := w.send(ctx, email)
+	if errors.Is(err, emaildelivery.ErrSuppressed) {
+		w.logger.Info("Skipped email to suppressed recipient",
+			logrus.Fields{
+				"message_id": messageID,
+				"to":         email.To,
+			})
+		return nil
 	}
-
-	// Prepare email message
-	msg := w.buildEmailMessage(email.To, email.Subject, body)
-
-	// SMTP server address
-	addr := fmt.Sprintf("%s:%d", w.config.SMTP.Host, w.config.SMTP.Port)
-
-	// SMTP authentication (only if username is provided)
-	var auth smtp.Auth
-	if w.config.SMTP.Username != "" {
-		auth = smtp.PlainAuth("",
-			w.config.SMTP.Username,
-			w.config.SMTP.Password,
-			w.config.SMTP.Host,
-		)
+	if errors.Is(err, notification.ErrTurnedOff) {
+		w.logger.Info("Skipped email the recipient turned off",
+			logrus.Fields{
+				"message_id": messageID,
+				"to":         email.To,
+				"category":   email.Category,
+			})
+		return nil
 	}
-
-	// Send email with or without TLS
-	if w.config.SMTP.UseTLS {
-		err = w.sendEmailWithTLS(addr, auth, w.config.SMTP.From, []string{email.To}, []byte(msg))
-	} else {
-		err = smtp.SendMail(addr, auth, w.config.SMTP.From, []string{email.To}, []byte(msg))
-	}
-
 	if err != nil {
-		return fmt.Errorf("failed to send email via SMTP: %w", err)
+		if w.deadLetters == nil {
+			return fmt.Errorf("failed to send email: %w", err)
+		}
+		deadLetter := emaildelivery.NewDeadLetter(email.To, email.Subject, email.Template, email.Locale, email.Version, email.Data, email.CampaignID, err)
+		deadLetter.Category = email.Category
+		if storeErr := w.deadLetters.Create(ctx, deadLetter); storeErr != nil {
+			return fmt.Errorf("failed to send email: %w (and failed to dead-letter it: %v)", err, storeErr)
+		}
+		w.logger.Warn("Email dead-lettered",
+			logrus.Fields{
+				"message_id":     messageID,
+				"dead_letter_id": deadLetter.ID,
+				"to":             email.To,
+				"permanent":      deadLetter.Permanent,
+				"error":          err.Error(),
+			})
+		return nil
 	}
 
+	w.logger.Info("Email sent successfully",
+		logrus.Fields{
+			"message_id": messageID,
+			"to":         email.To,
+			"subject":    email.Subject,
+			"provider":   provider,
+		})
+
 	return nil
 }
 
-// sendEmailWithTLS sends email using TLS connection
-func (w *EmailWorker) sendEmailWithTLS(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
-	// Create TLS configuration
-	tlsConfig := &tls.Config{
-		ServerName: w.config.SMTP.Host,
-	}
-
-	// Connect to SMTP server
-	conn, err := tls.Dial("tcp", addr, tlsConfig)
-	if err != nil {
-		return fmt.Errorf("failed to connect with TLS: %w", err)
-	}
-	defer conn.Close()
-
-	// Create SMTP client
-	client, err := smtp.NewClient(conn, w.config.SMTP.Host)
-	if err != nil {
-		return fmt.Errorf("failed to create SMTP client: %w", err)
-	}
-	defer client.Quit()
+// sendEmail sends an email through the first provider that accepts it
+func (w *EmailWorker) sendEmail(ctx context.Context, email queue.EmailMessage) error {
+	_, err := w.send(ctx, email)
+	return err
+}
 
-	// Authenticate if auth is provided
-	if auth != nil {
-		if err := client.Auth(auth); err != nil {
-			return fmt.Errorf("SMTP authentication failed: %w", err)
+func (w *EmailWorker) send(ctx context.Context, email queue.EmailMessage) (string, error) {
+	category := notification.Category(email.Category)
+	if w.policy != nil {
+		allowed, err := w.policy.AllowedEmail(ctx, email.To, category)
+		if err != nil {
+			return "", fmt.Errorf("failed to check notification preferences: %w", err)
+		}
+		if !allowed {
+			return "", notification.ErrTurnedOff
 		}
 	}
 
-	// Set sender
-	if err := client.Mail(from); err != nil {
-		return fmt.Errorf("failed to set sender: %w", err)
-	}
-
-	// Set recipients
-	for _, recipient := range to {
-		if err := client.Rcpt(recipient); err != nil {
-			return fmt.Errorf("failed to set recipient %s: %w", recipient, err)
-		}
+	var unsubscribeURL string
+	var headers map[string]string
+	if w.unsubscribe != nil && category.Unsubscribable() {
+		unsubscribeURL = w.unsubscribe.URL(email.To, category)
+		headers = w.unsubscribe.Headers(email.To, category)
+		email.Data = emaildelivery.MergeData(email.Data, map[string]interface{}{"UnsubscribeURL": unsubscribeURL})
 	}
 
-	// Send message
-	writer, err := client.Data()
+	// Render email content
+	subject, body, err := w.Render(ctx, email)
 	if err != nil {
-		return fmt.Errorf("failed to get data writer: %w", err)
+		return "", emaildelivery.Permanent(fmt.Errorf("failed to render template: %w", err))
 	}
-	defer writer.Close()
-
-	if _, err := writer.Write(msg); err != nil {
-		return fmt.Errorf("failed to write message: %w", err)
+	if email.Tracked && w.tracker != nil {
+		body = w.tracker.Instrument(body, email.CampaignID, email.To, unsubscribeURL)
+	}
+	if unsubscribeURL != "" {
+		body = unsubscribeFooter(body, unsubscribeURL, i18n.Negotiate("", email.Locale))
 	}
 
-	return nil
+	return w.sender.Send(ctx, &emaildelivery.Message{
+		From:    w.config.SMTP.From,
+		To:      email.To,
+		Subject: subject,
+		HTML:    body,
+		Locale:  i18n.Negotiate("", email.Locale),
+		Headers: headers,
+	})
 }
 
-// renderTemplate renders an email template with data
-func (w *EmailWorker) renderTemplate(templateName string, data map[string]interface{}) (string, error) {
-	tmpl, exists := w.templates[templateName]
-	if !exists {
-		// Use default template if specific template not found
-		return w.renderDefaultTemplate(templateName, data)
+// unsubscribeFooter adds the unsubscribe link at the end of the body,
+// unless the template already shows it
+func unsubscribeFooter(body, unsubscribeURL, locale string) string {
+	escaped := template.HTMLEscapeString(unsubscribeURL)
+	if strings.Contains(body, escaped) {
+		return body
 	}
 
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return "", fmt.Errorf("failed to execute template: %w", err)
+	footer := `<p style="font-size:12px;color:#888888">` + template.HTMLEscapeString(i18n.T(locale, "email.unsubscribe.reason")) +
+		` <a href="` + escaped + `">` + template.HTMLEscapeString(i18n.T(locale, "email.unsubscribe.link")) + `</a></p>`
+	if i := strings.LastIndex(strings.ToLower(body), "</body>"); i >= 0 {
+		return body[:i] + footer + body[i:]
 	}
-
-	return buf.String(), nil
+	return body + footer
 }
 
-// renderDefaultTemplate renders a default template
-func (w *EmailWorker) renderDefaultTemplate(templateName string, data map[string]interface{}) (string, error) {
-	switch templateName {
-	case "welcome":
-		return w.renderWelcomeTemplate(data)
-	case "order_confirmation":
-		return w.renderOrderConfirmationTemplate(data)
-	case "invoice":
-		return w.renderInvoiceTemplate(data)
-	case "password_reset":
-		return w.renderPasswordResetTemplate(data)
-	default:
-		return w.renderGenericTemplate(data)
-	}
-}
+// Render localizes an email. A stored template wins over the files in
+// templates/email, which win over the embedded defaults. The subject is the
+// template's, in the email's locale, unless the sender set one.
+func (w *EmailWorker) Render(ctx context.Context, email queue.EmailMessage) (subject, body string, err error) {
+	locale := i18n.Negotiate("", email.Locale)
 
-// Template renderers
-func (w *EmailWorker) renderWelcomeTemplate(data map[string]interface{}) (string, error) {
-	tmpl := `
-<!DOCTYPE html>
-<html>
-<head>
-    <title>Welcome to Online Shop</title>
-</head>
-<body>
-    <h1>Welcome {{.FirstName}}!</h1>
-    <p>Thank you for joining our online shop. We're excited to have you as a customer.</p>
-    <p>You can now browse our products and start shopping.</p>
-    <p>Best regards,<br>The Online Shop Team</p>
-</body>
-</html>`
-
-	t, err := template.New("welcome").Parse(tmpl)
+	t, err := w.storedTemplate(ctx, email, locale)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-
-	var buf bytes.Buffer
-	if err := t.Execute(&buf, data); err != nil {
-		return "", err
+	if t == nil {
+		if body, ok, err := w.renderFileTemplate(locale, email.Template, email.Data); ok || err != nil {
+			return w.fileSubject(email, locale), body, err
+		}
+		if t, _ = emailtemplate.Default(email.Template); t == nil {
+			t, _ = emailtemplate.Default(emailtemplate.GenericName)
+		}
 	}
 
-	return buf.String(), nil
-}
-
-func (w *EmailWorker) renderOrderConfirmationTemplate(data map[string]interface{}) (string, error) {
-	tmpl := `
-<!DOCTYPE html>
-<html>
-<head>
-    <title>Order Confirmation</title>
-</head>
-<body>
-    <h1>Order Confirmation</h1>
-    <p>Dear {{.CustomerName}},</p>
-    <p>Thank you for your order! Your order #{{.OrderNumber}} has been confirmed.</p>
-    <p><strong>Order Details:</strong></p>
-    <ul>
-        {{range .Items}}
-        <li>{{.ProductName}} - Quantity: {{.Quantity}} - ${{.TotalPrice}}</li>
-        {{end}}
-    </ul>
-    <p><strong>Total: ${{.TotalAmount}}</strong></p>
-    <p>We'll send you another email when your order ships.</p>
-    <p>Best regards,<br>The Online Shop Team</p>
-</body>
-</html>`
-
-	t, err := template.New("order_confirmation").Parse(tmpl)
+	subject, body, err = t.Render(locale, email.Data)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-
-	var buf bytes.Buffer
-	if err := t.Execute(&buf, data); err != nil {
-		return "", err
+	if email.Subject != "" {
+		subject = email.Subject
 	}
-
-	return buf.String(), nil
+	return subject, body, nil
 }
 
-func (w *EmailWorker) renderInvoiceTemplate(data map[string]interface{}) (string, error) {
-	tmpl := `
-<!DOCTYPE html>
-<html>
-<head>
-    <title>Invoice</title>
-    <style>
-        table { border-collapse: collapse; width: 100%; }
-        th, td { border: 1px solid #ddd; padding: 8px; text-align: left; }
-        th { background-color: #f2f2f2; }
-        .total { font-weight: bold; }
-    </style>
-</head>
-<body>
-    <h1>Invoice</h1>
-    <p><strong>Order Number:</strong> {{.OrderNumber}}</p>
-    <p><strong>Date:</strong> {{.Date}}</p>
-    
-    <table>
-        <thead>
-            <tr>
-                <th>Product</th>
-                <th>Quantity</th>
-                <th>Unit Price</th>
-                <th>Total</th>
-            </tr>
-        </thead>
-        <tbody>
-            {{range .Items}}
-            <tr>
-                <td>{{.ProductName}}</td>
-                <td>{{.Quantity}}</td>
-                <td>${{.UnitPrice}}</td>
-                <td>${{.TotalPrice}}</td>
-            </tr>
-            {{end}}
-        </tbody>
-        <tfoot>
-            <tr class="total">
-                <td colspan="3">Total Amount</td>
-                <td>${{.TotalAmount}}</td>
-            </tr>
-        </tfoot>
-    </table>
-    
-    <p>Thank you for your business!</p>
-</body>
-</html>`
-
-	t, err := template.New("invoice").Parse(tmpl)
-	if err != nil {
-		return "", err
+// storedTemplate returns the stored template for the email, or nil to fall
+// back to the files and defaults. Only a pinned version that cannot be
+// loaded is an error; otherwise the email still goes out with the default.
+func (w *EmailWorker) storedTemplate(ctx context.Context, email queue.EmailMessage, locale string) (*emailtemplate.Template, error) {
+	if w.store == nil {
+		return nil, nil
 	}
 
-	var buf bytes.Buffer
-	if err := t.Execute(&buf, data); err != nil {
-		return "", err
+	t, err := w.store.Handle(ctx, queries.GetEmailTemplateQuery{Name: email.Template, Locale: locale, Version: email.Version})
+	if err != nil {
+		if email.Version > 0 {
+			return nil, fmt.Errorf("failed to load template version %d: %w", email.Version, err)
+		}
+		if err != emailtemplate.ErrNotFound {
+			w.logger.Warn("Failed to load stored email template, using the default",
+				logrus.Fields{
+					"template": email.Template,
+					"error":    err.Error(),
+				})
+		}
+		return nil, nil
 	}
-
-	return buf.String(), nil
+	if t.Version == 0 {
+		return nil, nil
+	}
+	return t, nil
 }
 
-func (w *EmailWorker) renderPasswordResetTemplate(data map[string]interface{}) (string, error) {
-	tmpl := `
-<!DOCTYPE html>
-<html>
-<head>
-    <title>Password Reset</title>
-</head>
-<body>
-    <h1>Password Reset Request</h1>
-    <p>Dear {{.FirstName}},</p>
-    <p>You requested a password reset for your account.</p>
-    <p>Click the link below to reset your password:</p>
-    <p><a href="{{.ResetLink}}">Reset Password</a></p>
-    <p>This link will expire in 24 hours.</p>
-    <p>If you didn't request this, please ignore this email.</p>
-    <p>Best regards,<br>The Online Shop Team</p>
-</body>
-</html>`
-
-	t, err := template.New("password_reset").Parse(tmpl)
-	if err != nil {
-		return "", err
+// renderFileTemplate renders a file template, preferring the one for the
+// locale over the unlocalized one. ok is false when there is no file.
+func (w *EmailWorker) renderFileTemplate(locale, templateName string, data map[string]interface{}) (string, bool, error) {
+	tmpl, exists := w.templates[locale+"/"+templateName]
+	if !exists {
+		tmpl, exists = w.templates[templateName]
 	}
-
-	var buf bytes.Buffer
-	if err := t.Execute(&buf, data); err != nil {
-		return "", err
+	if !exists {
+		return "", false, nil
 	}
 
-	return buf.String(), nil
-}
-
-func (w *EmailWorker) renderGenericTemplate(data map[string]interface{}) (string, error) {
-	tmpl := `
-<!DOCTYPE html>
-<html>
-<head>
-    <title>{{.Subject}}</title>
-</head>
-<body>
-    <h1>{{.Subject}}</h1>
-    <p>{{.Message}}</p>
-    <p>Best regards,<br>The Online Shop Team</p>
-</body>
-</html>`
-
-	t, err := template.New("generic").Parse(tmpl)
+	localized, err := tmpl.Clone()
 	if err != nil {
-		return "", err
+		return "", true, fmt.Errorf("failed to clone template: %w", err)
 	}
 
 	var buf bytes.Buffer
-	if err := t.Execute(&buf, data); err != nil {
-		return "", err
+	if err := localized.Funcs(emailtemplate.Funcs(locale, data)).Execute(&buf, data); err != nil {
+		return "", true, fmt.Errorf("failed to execute template: %w", err)
 	}
 
-	return buf.String(), nil
+	return buf.String(), true, nil
 }
 
-// buildEmailMessage builds the email message with headers
-func (w *EmailWorker) buildEmailMessage(to, subject, body string) string {
-	msg := fmt.Sprintf("From: %s\r\n", w.config.SMTP.From)
-	msg += fmt.Sprintf("To: %s\r\n", to)
-	msg += fmt.Sprintf("Subject: %s\r\n", subject)
-	msg += "MIME-Version: 1.0\r\n"
-	msg += "Content-Type: text/html; charset=UTF-8\r\n"
-	msg += "\r\n"
-	msg += body
-
-	return msg
+// fileSubject is the subject of an email rendered from a file, which has no
+// subject of its own
+func (w *EmailWorker) fileSubject(email queue.EmailMessage, locale string) string {
+	if email.Subject != "" {
+		return email.Subject
+	}
+	if template, ok := i18n.Lookup(locale, "email."+email.Template+".subject"); ok {
+		return i18n.Render(template, emailtemplate.Args(email.Data)...)
+	}
+	subject, _ := email.Data["Subject"].(string)
+	return subject
 }
 
-// loadTemplates loads email templates from files
+// loadTemplates loads email templates from files. Translations live in a
+// directory per locale, for example templates/email/id/welcome.html.
 func (w *EmailWorker) loadTemplates() {
 	templateDir := "templates/email"
 	
 	// Check if template directory exists
-	if _, err := filepath.Glob(templateDir + "/*.html"); err != nil {
+	if _, err := os.Stat(templateDir); err != nil {
 		w.logger.Warn("Email template directory not found, using default templates")
 		return
 	}
@@ -398,20 +411,30 @@ func (w *EmailWorker) loadTemplates() {
 	templates := []string{"welcome", "order_confirmation", "invoice", "password_reset"}
 	
 	for _, name := range templates {
-		templatePath := filepath.Join(templateDir, name+".html")
-		if tmpl, err := template.ParseFiles(templatePath); err == nil {
-			w.templates[name] = tmpl
-			w.logger.Info("Loaded email template", logrus.Fields{"template": name})
-		} else {
-			w.logger.Warn("Failed to load email template", 
-				logrus.Fields{
-					"template": name,
-					"error":    err.Error(),
-				})
+		w.loadTemplate(name, filepath.Join(templateDir, name+".html"))
+		for _, locale := range i18n.Locales() {
+			path := filepath.Join(templateDir, locale, name+".html")
+			if _, err := os.Stat(path); err == nil {
+				w.loadTemplate(locale+"/"+name, path)
+			}
 		}
 	}
 }
 
+func (w *EmailWorker) loadTemplate(key, path string) {
+	tmpl, err := template.New(filepath.Base(path)).Funcs(emailtemplate.Funcs(i18n.DefaultLocale, nil)).ParseFiles(path)
+	if err != nil {
+		w.logger.Warn("Failed to load email template", 
+			logrus.Fields{
+				"template": key,
+				"error":    err.Error(),
+			})
+		return
+	}
+	w.templates[key] = tmpl
+	w.logger.Info("Loaded email template", logrus.Fields{"template": key})
+}
+
 // Helper function to convert map to struct
 func mapToStruct(m map[string]interface{}, v interface{}) error {
 	data, err := json.Marshal(m)
@@ -0,0 +1,55 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/infrastructure/queue"
+	"online-shop/pkg/config"
+)
+
+// ExportWorker generates report export files
+type ExportWorker struct {
+	config    *config.Config
+	logger    *logrus.Logger
+	generator *commands.GenerateExportCommandHandler
+}
+
+// ExportRequest represents a queued export request
+type ExportRequest struct {
+	ExportID string `json:"export_id"`
+}
+
+// NewExportWorker creates a new export worker
+func NewExportWorker(cfg *config.Config, logger *logrus.Logger, generator *commands.GenerateExportCommandHandler) *ExportWorker {
+	return &ExportWorker{
+		config:    cfg,
+		logger:    logger,
+		generator: generator,
+	}
+}
+
+// ProcessMessage processes an export message
+func (w *ExportWorker) ProcessMessage(ctx context.Context, message queue.Message) error {
+	w.logger.Info("Processing export message", logrus.Fields{"message_id": message.ID})
+
+	var request ExportRequest
+	if err := mapToStruct(message.Payload, &request); err != nil {
+		return fmt.Errorf("failed to parse export request: %w", err)
+	}
+
+	if err := w.generator.Handle(ctx, commands.GenerateExportCommand{ExportID: request.ExportID}); err != nil {
+		return fmt.Errorf("failed to generate export %s: %w", request.ExportID, err)
+	}
+
+	w.logger.Info("Export processed successfully",
+		logrus.Fields{
+			"message_id": message.ID,
+			"export_id":  request.ExportID,
+		})
+
+	return nil
+}
@@ -2,6 +2,7 @@ package workers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"time"
@@ -35,7 +36,7 @@ func (w *InvoiceWorker) SetRabbitMQ(rabbitmq *queue.RabbitMQ) {
 }
 
 // ProcessMessage processes an invoice message
-func (w *InvoiceWorker) ProcessMessage(message queue.Message) error {
+func (w *InvoiceWorker) ProcessMessage(ctx context.Context, message queue.Message) error {
 	w.logger.Info("Processing invoice message", logrus.Fields{"message_id": message.ID})
 
 	// Parse invoice data
@@ -51,7 +52,7 @@ func (w *InvoiceWorker) ProcessMessage(message queue.Message) error {
 	}
 
 	// Send invoice via email
-	if err := w.sendInvoiceEmail(invoiceData, invoice); err != nil {
+	if err := w.sendInvoiceEmail(ctx, invoiceData, invoice); err != nil {
 		return fmt.Errorf("failed to send invoice email: %w", err)
 	}
 
@@ -74,6 +75,16 @@ func (w *InvoiceWorker) generateInvoice(data queue.InvoiceMessage) (*Invoice, er
 		Date:        time.Now(),
 		Items:       make([]InvoiceLineItem, len(data.Items)),
 		TotalAmount: data.TotalAmount,
+		Payments:    data.Payments,
+		AmountPaid:  data.AmountPaid,
+		Note:        data.Note,
+		GiftWrap:    data.GiftWrap,
+		GiftWrapFee: data.GiftWrapFee,
+		GiftMessage: data.GiftMessage,
+	}
+	invoice.BalanceDue = invoice.TotalAmount - invoice.AmountPaid
+	if invoice.BalanceDue < 0.01 {
+		invoice.BalanceDue = 0
 	}
 
 	// Convert items
@@ -98,7 +109,7 @@ func (w *InvoiceWorker) generateInvoice(data queue.InvoiceMessage) (*Invoice, er
 }
 
 // sendInvoiceEmail sends the invoice via email
-func (w *InvoiceWorker) sendInvoiceEmail(data queue.InvoiceMessage, invoice *Invoice) error {
+func (w *InvoiceWorker) sendInvoiceEmail(ctx context.Context, data queue.InvoiceMessage, invoice *Invoice) error {
 	// Prepare email data
 	emailData := map[string]interface{}{
 		"OrderNumber":    data.OrderNumber,
@@ -109,25 +120,32 @@ func (w *InvoiceWorker) sendInvoiceEmail(data queue.InvoiceMessage, invoice *Inv
 		"TaxAmount":      invoice.TaxAmount,
 		"ShippingAmount": invoice.ShippingAmount,
 		"TotalAmount":    invoice.TotalAmount,
+		"Payments":       invoice.Payments,
+		"AmountPaid":     invoice.AmountPaid,
+		"BalanceDue":     invoice.BalanceDue,
 		"CustomerEmail":  data.UserEmail,
+		"Note":           invoice.Note,
+		"GiftWrap":       invoice.GiftWrap,
+		"GiftWrapFee":    invoice.GiftWrapFee,
+		"GiftMessage":    invoice.GiftMessage,
 	}
 
 	// Create email message
 	emailMessage := queue.EmailMessage{
 		To:       data.UserEmail,
-		Subject:  fmt.Sprintf("Invoice for Order #%s", data.OrderNumber),
 		Template: "invoice",
 		Data:     emailData,
 		Priority: 2, // High priority for invoices
+		Locale:   data.Locale,
 	}
 
 	// Send email directly or queue it
 	if w.rabbitmq != nil {
 		// Queue the email for processing
-		return w.rabbitmq.PublishEmail(nil, emailMessage)
+		return w.rabbitmq.PublishEmail(ctx, emailMessage)
 	} else {
 		// Send email directly
-		return w.emailWorker.sendEmail(emailMessage)
+		return w.emailWorker.sendEmail(ctx, emailMessage)
 	}
 }
 
@@ -190,7 +208,33 @@ func (w *InvoiceWorker) generatePDFInvoice(invoice *Invoice) ([]byte, error) {
 	buf.WriteString(fmt.Sprintf("Subtotal: $%.2f\n", invoice.Subtotal))
 	buf.WriteString(fmt.Sprintf("Tax: $%.2f\n", invoice.TaxAmount))
 	buf.WriteString(fmt.Sprintf("Shipping: $%.2f\n", invoice.ShippingAmount))
+	if invoice.GiftWrap {
+		buf.WriteString(fmt.Sprintf("Gift wrapping: $%.2f\n", invoice.GiftWrapFee))
+	}
 	buf.WriteString(fmt.Sprintf("TOTAL: $%.2f\n", invoice.TotalAmount))
+
+	// An order paid in parts lists each payment and what is still due
+	if len(invoice.Payments) > 0 {
+		buf.WriteString("\nPAYMENTS:\n")
+		buf.WriteString("----------------------------------------\n")
+		for _, p := range invoice.Payments {
+			label := p.Method
+			if p.Installment > 0 {
+				label = fmt.Sprintf("Installment %d (%s)", p.Installment, p.Method)
+			}
+			buf.WriteString(fmt.Sprintf("%-28s %-8s $%.2f\n", label, p.Status, p.Amount))
+		}
+		buf.WriteString("----------------------------------------\n")
+		buf.WriteString(fmt.Sprintf("Paid: $%.2f\n", invoice.AmountPaid))
+		buf.WriteString(fmt.Sprintf("Balance due: $%.2f\n", invoice.BalanceDue))
+	}
+
+	if invoice.GiftMessage != "" {
+		buf.WriteString(fmt.Sprintf("\nGift message: %s\n", invoice.GiftMessage))
+	}
+	if invoice.Note != "" {
+		buf.WriteString(fmt.Sprintf("\nNote: %s\n", invoice.Note))
+	}
 	
 	return buf.Bytes(), nil
 }
@@ -224,6 +268,13 @@ type Invoice struct {
 	TaxAmount      float64            `json:"tax_amount"`
 	ShippingAmount float64            `json:"shipping_amount"`
 	TotalAmount    float64            `json:"total_amount"`
+	Payments       []queue.InvoicePayment `json:"payments,omitempty"`
+	AmountPaid     float64            `json:"amount_paid"`
+	BalanceDue     float64            `json:"balance_due"`
+	Note           string             `json:"note,omitempty"`
+	GiftWrap       bool               `json:"gift_wrap,omitempty"`
+	GiftWrapFee    float64            `json:"gift_wrap_fee,omitempty"`
+	GiftMessage    string             `json:"gift_message,omitempty"`
 }
 
 // InvoiceLineItem represents a line item in an invoice
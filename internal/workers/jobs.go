@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/product"
 	"online-shop/internal/infrastructure/queue"
 	"online-shop/pkg/config"
 	"online-shop/pkg/workerpool"
@@ -32,21 +34,17 @@ func (j *EmailJob) Execute(ctx context.Context) error {
 
 	// Create email worker and process
 	emailWorker := NewEmailWorker(j.Config, j.Logger)
-	if err := emailWorker.ProcessMessage(j.Message); err != nil {
+	if err := emailWorker.ProcessMessage(ctx, j.Message); err != nil {
 		return fmt.Errorf("failed to process email: %w", err)
 	}
 
 	return nil
 }
 
-// GetPriority returns the priority of the email job
+// GetPriority returns the priority of the email job: the message's, so
+// transactional emails run before campaign batches
 func (j *EmailJob) GetPriority() int {
-	// Parse email data to get priority
-	var emailData queue.EmailMessage
-	if err := mapToStruct(j.Message.Payload, &emailData); err == nil {
-		return emailData.Priority
-	}
-	return 0 // Default priority
+	return j.Message.Priority
 }
 
 // InvoiceJob represents an invoice processing job
@@ -69,7 +67,7 @@ func (j *InvoiceJob) Execute(ctx context.Context) error {
 
 	// Create invoice worker and process
 	invoiceWorker := NewInvoiceWorker(j.Config, j.Logger)
-	if err := invoiceWorker.ProcessMessage(j.Message); err != nil {
+	if err := invoiceWorker.ProcessMessage(ctx, j.Message); err != nil {
 		return fmt.Errorf("failed to process invoice: %w", err)
 	}
 
@@ -95,7 +93,7 @@ func (j *NotificationJob) Execute(ctx context.Context) error {
 
 	// Create notification worker and process
 	notificationWorker := NewNotificationWorker(j.Config, j.Logger)
-	if err := notificationWorker.ProcessMessage(j.Message); err != nil {
+	if err := notificationWorker.ProcessMessage(ctx, j.Message); err != nil {
 		return fmt.Errorf("failed to process notification: %w", err)
 	}
 
@@ -110,9 +108,12 @@ func (j *NotificationJob) GetPriority() int {
 // AnalyticsJob represents an analytics processing job
 type AnalyticsJob struct {
 	workerpool.BaseJob
-	Message queue.Message
-	Config  *config.Config
-	Logger  *logrus.Logger
+	Message        queue.Message
+	Config         *config.Config
+	Logger         *logrus.Logger
+	Events         EventSink
+	Trending       product.TrendingRepository
+	RecentlyViewed product.RecentlyViewedRepository
 }
 
 // Execute processes the analytics job
@@ -120,8 +121,8 @@ func (j *AnalyticsJob) Execute(ctx context.Context) error {
 	j.Logger.Debug("Executing analytics job", logrus.Fields{"job_id": j.ID})
 
 	// Create analytics worker and process
-	analyticsWorker := NewAnalyticsWorker(j.Config, j.Logger)
-	if err := analyticsWorker.ProcessMessage(j.Message); err != nil {
+	analyticsWorker := NewAnalyticsWorker(j.Config, j.Logger, j.Events, j.Trending, j.RecentlyViewed)
+	if err := analyticsWorker.ProcessMessage(ctx, j.Message); err != nil {
 		return fmt.Errorf("failed to process analytics: %w", err)
 	}
 
@@ -133,6 +134,33 @@ func (j *AnalyticsJob) GetPriority() int {
 	return 1 // Low priority for analytics
 }
 
+// ExportJob represents a report export job
+type ExportJob struct {
+	workerpool.BaseJob
+	Message   queue.Message
+	Config    *config.Config
+	Logger    *logrus.Logger
+	Generator *commands.GenerateExportCommandHandler
+}
+
+// Execute processes the export job
+func (j *ExportJob) Execute(ctx context.Context) error {
+	j.Logger.Debug("Executing export job", logrus.Fields{"job_id": j.ID})
+
+	// Create export worker and process
+	exportWorker := NewExportWorker(j.Config, j.Logger, j.Generator)
+	if err := exportWorker.ProcessMessage(ctx, j.Message); err != nil {
+		return fmt.Errorf("failed to process export: %w", err)
+	}
+
+	return nil
+}
+
+// GetPriority returns the priority of the export job
+func (j *ExportJob) GetPriority() int {
+	return 2 // Exports are requested by admins but are not urgent
+}
+
 // BatchJob represents a batch processing job
 type BatchJob struct {
 	workerpool.BaseJob
@@ -297,6 +325,12 @@ func (j *ScheduledJob) GetPriority() int {
 	return j.OriginalJob.GetPriority()
 }
 
+// GetRunAfter holds the job in the pool's queue until its scheduled time,
+// rather than have it wait on a worker
+func (j *ScheduledJob) GetRunAfter() time.Time {
+	return j.ScheduledTime
+}
+
 // Helper function to convert map to struct
 func mapToStruct(m map[string]interface{}, v interface{}) error {
 	data, err := json.Marshal(m)
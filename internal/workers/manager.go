@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/product"
 	"online-shop/internal/infrastructure/queue"
 	"online-shop/pkg/config"
 	"online-shop/pkg/workerpool"
@@ -18,7 +20,13 @@ type WorkerManager struct {
 	invoicePool      *workerpool.WorkerPool
 	notificationPool *workerpool.WorkerPool
 	analyticsPool    *workerpool.WorkerPool
+	exportPool       *workerpool.WorkerPool
 	rabbitmq         *queue.RabbitMQ
+	events           EventSink
+	trending         product.TrendingRepository
+	recentlyViewed   product.RecentlyViewedRepository
+	exports          *commands.GenerateExportCommandHandler
+	jobs             *JobTracker
 	config           *config.Config
 	logger           *logrus.Logger
 	ctx              context.Context
@@ -27,15 +35,19 @@ type WorkerManager struct {
 }
 
 // NewWorkerManager creates a new worker manager
-func NewWorkerManager(cfg *config.Config, rabbitmq *queue.RabbitMQ, logger *logrus.Logger) *WorkerManager {
+func NewWorkerManager(cfg *config.Config, rabbitmq *queue.RabbitMQ, events EventSink, trending product.TrendingRepository, recentlyViewed product.RecentlyViewedRepository, exports *commands.GenerateExportCommandHandler, logger *logrus.Logger) *WorkerManager {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	manager := &WorkerManager{
-		rabbitmq: rabbitmq,
-		config:   cfg,
-		logger:   logger,
-		ctx:      ctx,
-		cancel:   cancel,
+		rabbitmq:       rabbitmq,
+		events:         events,
+		trending:       trending,
+		recentlyViewed: recentlyViewed,
+		exports:        exports,
+		config:         cfg,
+		logger:         logger,
+		ctx:            ctx,
+		cancel:         cancel,
 	}
 
 	// Initialize worker pools
@@ -44,53 +56,73 @@ func NewWorkerManager(cfg *config.Config, rabbitmq *queue.RabbitMQ, logger *logr
 	return manager
 }
 
+// SetJobTracker records the jobs run by the pools in the jobs table
+func (m *WorkerManager) SetJobTracker(tracker *JobTracker) {
+	m.jobs = tracker
+}
+
+// track wraps a pool job so its attempts are recorded, when a tracker is set
+func (m *WorkerManager) track(queueName string, message queue.Message, job workerpool.Job) workerpool.Job {
+	if m.jobs == nil {
+		return job
+	}
+	return m.jobs.TrackJob(queueName, message, job)
+}
+
 // initializePools creates all worker pools
 func (m *WorkerManager) initializePools() {
 	// Email worker pool
-	m.emailPool = workerpool.NewWorkerPool(workerpool.PoolConfig{
-		MaxWorkers: m.config.Workers.EmailWorkers,
-		MaxQueue:   m.config.Workers.EmailWorkers * 50,
-		Logger:     m.logger,
-	})
+	m.emailPool = workerpool.NewWorkerPool(m.poolConfig(queue.EmailQueue, m.config.Workers.EmailWorkers, 50))
 
 	// Invoice worker pool
-	m.invoicePool = workerpool.NewWorkerPool(workerpool.PoolConfig{
-		MaxWorkers: m.config.Workers.InvoiceWorkers,
-		MaxQueue:   m.config.Workers.InvoiceWorkers * 30,
-		Logger:     m.logger,
-	})
+	m.invoicePool = workerpool.NewWorkerPool(m.poolConfig(queue.InvoiceQueue, m.config.Workers.InvoiceWorkers, 30))
 
 	// Notification worker pool
-	m.notificationPool = workerpool.NewWorkerPool(workerpool.PoolConfig{
-		MaxWorkers: m.config.Workers.NotificationWorkers,
-		MaxQueue:   m.config.Workers.NotificationWorkers * 40,
-		Logger:     m.logger,
-	})
+	m.notificationPool = workerpool.NewWorkerPool(m.poolConfig(queue.NotificationQueue, m.config.Workers.NotificationWorkers, 40))
 
 	// Analytics worker pool
-	m.analyticsPool = workerpool.NewWorkerPool(workerpool.PoolConfig{
-		MaxWorkers: m.config.Workers.AnalyticsWorkers,
-		MaxQueue:   m.config.Workers.AnalyticsWorkers * 20,
-		Logger:     m.logger,
-	})
+	m.analyticsPool = workerpool.NewWorkerPool(m.poolConfig(queue.AnalyticsQueue, m.config.Workers.AnalyticsWorkers, 20))
+
+	// Export worker pool
+	m.exportPool = workerpool.NewWorkerPool(m.poolConfig(queue.ExportQueue, m.config.Workers.ExportWorkers, 10))
+}
+
+// poolConfig is the config of the pool running a queue's jobs, queueing up
+// to perWorker jobs for each of its workers
+func (m *WorkerManager) poolConfig(queueName string, workers, perWorker int) workerpool.PoolConfig {
+	q := m.config.Workers.Queues[queueName]
+	return workerpool.PoolConfig{
+		Name:           queueName,
+		MaxWorkers:     workers,
+		MaxQueue:       workers * perWorker,
+		JobTimeout:     m.config.Workers.JobTimeout(queueName),
+		BulkPriority:   queue.PriorityBulk,
+		MaxBulkWorkers: q.BulkWorkers,
+		MaxBulkQueued:  q.BulkQueued,
+		PriorityAging:  time.Duration(q.PriorityAgingSeconds) * time.Second,
+		Logger:         m.logger,
+	}
 }
 
 // Start starts all worker pools and consumers
 func (m *WorkerManager) Start() error {
 	m.logger.Info("Starting worker manager...")
 
-	// Start all worker pools
-	m.emailPool.Start(m.ctx)
-	m.invoicePool.Start(m.ctx)
-	m.notificationPool.Start(m.ctx)
-	m.analyticsPool.Start(m.ctx)
+	// Start all worker pools. They outlive the consumers' context: Stop
+	// lets their running jobs finish and cancels the queued ones.
+	m.emailPool.Start(context.Background())
+	m.invoicePool.Start(context.Background())
+	m.notificationPool.Start(context.Background())
+	m.analyticsPool.Start(context.Background())
+	m.exportPool.Start(context.Background())
 
 	// Start queue consumers
-	m.wg.Add(4)
+	m.wg.Add(5)
 	go m.startEmailConsumer()
 	go m.startInvoiceConsumer()
 	go m.startNotificationConsumer()
 	go m.startAnalyticsConsumer()
+	go m.startExportConsumer()
 
 	// Start metrics reporter
 	go m.startMetricsReporter()
@@ -114,6 +146,7 @@ func (m *WorkerManager) Stop() {
 	m.invoicePool.Stop()
 	m.notificationPool.Stop()
 	m.analyticsPool.Stop()
+	m.exportPool.Stop()
 
 	m.logger.Info("Worker manager stopped")
 }
@@ -135,7 +168,7 @@ func (m *WorkerManager) startEmailConsumer() {
 			Logger:  m.logger,
 		}
 
-		return m.emailPool.Submit(job)
+		return m.emailPool.Submit(m.track(queue.EmailQueue, message, job))
 	})
 
 	if err != nil && err != context.Canceled {
@@ -160,7 +193,7 @@ func (m *WorkerManager) startInvoiceConsumer() {
 			Logger:  m.logger,
 		}
 
-		return m.invoicePool.Submit(job)
+		return m.invoicePool.Submit(m.track(queue.InvoiceQueue, message, job))
 	})
 
 	if err != nil && err != context.Canceled {
@@ -185,7 +218,7 @@ func (m *WorkerManager) startNotificationConsumer() {
 			Logger:  m.logger,
 		}
 
-		return m.notificationPool.Submit(job)
+		return m.notificationPool.Submit(m.track(queue.NotificationQueue, message, job))
 	})
 
 	if err != nil && err != context.Canceled {
@@ -205,12 +238,15 @@ func (m *WorkerManager) startAnalyticsConsumer() {
 				ID:   message.ID,
 				Type: "analytics",
 			},
-			Message: message,
-			Config:  m.config,
-			Logger:  m.logger,
+			Message:        message,
+			Config:         m.config,
+			Logger:         m.logger,
+			Events:         m.events,
+			Trending:       m.trending,
+			RecentlyViewed: m.recentlyViewed,
 		}
 
-		return m.analyticsPool.Submit(job)
+		return m.analyticsPool.Submit(m.track(queue.AnalyticsQueue, message, job))
 	})
 
 	if err != nil && err != context.Canceled {
@@ -218,6 +254,32 @@ func (m *WorkerManager) startAnalyticsConsumer() {
 	}
 }
 
+// startExportConsumer starts the export queue consumer
+func (m *WorkerManager) startExportConsumer() {
+	defer m.wg.Done()
+
+	m.logger.Info("Starting export consumer")
+
+	err := m.rabbitmq.ConsumeMessages(m.ctx, queue.ExportQueue, func(message queue.Message) error {
+		job := &ExportJob{
+			BaseJob: workerpool.BaseJob{
+				ID:   message.ID,
+				Type: "export",
+			},
+			Message:   message,
+			Config:    m.config,
+			Logger:    m.logger,
+			Generator: m.exports,
+		}
+
+		return m.exportPool.Submit(m.track(queue.ExportQueue, message, job))
+	})
+
+	if err != nil && err != context.Canceled {
+		m.logger.Error("Export consumer error", logrus.Fields{"error": err})
+	}
+}
+
 // startMetricsReporter starts the metrics reporting goroutine
 func (m *WorkerManager) startMetricsReporter() {
 	ticker := time.NewTicker(30 * time.Second)
@@ -239,6 +301,7 @@ func (m *WorkerManager) reportMetrics() {
 	invoiceMetrics := m.invoicePool.GetMetrics()
 	notificationMetrics := m.notificationPool.GetMetrics()
 	analyticsMetrics := m.analyticsPool.GetMetrics()
+	exportMetrics := m.exportPool.GetMetrics()
 
 	m.logger.Info("Worker pool metrics",
 		logrus.Fields{
@@ -270,6 +333,13 @@ func (m *WorkerManager) reportMetrics() {
 				"active_workers":  analyticsMetrics.ActiveWorkers,
 				"avg_job_time":    analyticsMetrics.AverageJobTime,
 			},
+			"export_pool": logrus.Fields{
+				"jobs_processed":  exportMetrics.JobsProcessed,
+				"jobs_failed":     exportMetrics.JobsFailed,
+				"jobs_in_queue":   exportMetrics.JobsInQueue,
+				"active_workers":  exportMetrics.ActiveWorkers,
+				"avg_job_time":    exportMetrics.AverageJobTime,
+			},
 		})
 }
 
@@ -280,6 +350,7 @@ func (m *WorkerManager) GetPoolMetrics() map[string]workerpool.PoolMetrics {
 		"invoice":      m.invoicePool.GetMetrics(),
 		"notification": m.notificationPool.GetMetrics(),
 		"analytics":    m.analyticsPool.GetMetrics(),
+		"export":       m.exportPool.GetMetrics(),
 	}
 }
 
@@ -312,5 +383,9 @@ func (m *WorkerManager) HealthCheck() error {
 		return fmt.Errorf("analytics queue is too full")
 	}
 
+	if m.exportPool.GetQueueSize() > m.config.Workers.ExportWorkers*8 {
+		return fmt.Errorf("export queue is too full")
+	}
+
 	return nil
 }
\ No newline at end of file
@@ -1,20 +1,26 @@
 package workers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
+	"online-shop/internal/domain/notification"
+	"online-shop/internal/domain/user"
+	"online-shop/internal/domain/whatsapp"
 	"online-shop/internal/infrastructure/queue"
 	"online-shop/pkg/config"
 )
 
 // NotificationWorker handles notification processing
 type NotificationWorker struct {
-	config *config.Config
-	logger *logrus.Logger
+	config   *config.Config
+	logger   *logrus.Logger
+	whatsApp *whatsapp.Sender
+	policy   *notification.Policy
 }
 
 // NotificationData represents notification data
@@ -26,7 +32,24 @@ type NotificationData struct {
 	Data        map[string]interface{} `json:"data"`
 	Priority    int                    `json:"priority"`
 	ScheduledAt *time.Time             `json:"scheduled_at,omitempty"`
-	Channels    []string               `json:"channels"` // email, sms, push, in-app
+	Channels    []string               `json:"channels"` // email, sms, push, in-app, whatsapp
+	// Phone and Locale address the whatsapp channel
+	Phone  string `json:"phone,omitempty"`
+	Locale string `json:"locale,omitempty"`
+	// Category is checked against the user's notification preferences for
+	// each channel. Marketing is the marketing category, for senders from
+	// before categories.
+	Category  string `json:"category,omitempty"`
+	Marketing bool   `json:"marketing,omitempty"`
+}
+
+// category is the notification's category; uncategorized notifications
+// are always sent
+func (d NotificationData) category() notification.Category {
+	if d.Marketing {
+		return notification.CategoryMarketing
+	}
+	return notification.Category(d.Category)
 }
 
 // NewNotificationWorker creates a new notification worker
@@ -37,8 +60,19 @@ func NewNotificationWorker(cfg *config.Config, logger *logrus.Logger) *Notificat
 	}
 }
 
+// SetWhatsApp enables the whatsapp channel
+func (w *NotificationWorker) SetWhatsApp(sender *whatsapp.Sender) {
+	w.whatsApp = sender
+}
+
+// SetPolicy checks notifications against the users' preferences.
+// Categorized notifications are dropped without a policy to check them.
+func (w *NotificationWorker) SetPolicy(policy *notification.Policy) {
+	w.policy = policy
+}
+
 // ProcessMessage processes a notification message
-func (w *NotificationWorker) ProcessMessage(message queue.Message) error {
+func (w *NotificationWorker) ProcessMessage(ctx context.Context, message queue.Message) error {
 	w.logger.Info("Processing notification message", logrus.Fields{"message_id": message.ID})
 
 	// Parse notification data
@@ -61,7 +95,17 @@ func (w *NotificationWorker) ProcessMessage(message queue.Message) error {
 
 	// Process notification for each channel
 	for _, channel := range notificationData.Channels {
-		if err := w.processNotificationChannel(notificationData, channel); err != nil {
+		if !w.allowed(ctx, notificationData.UserID, notificationData.category(), channel) {
+			w.logger.Info("Skipping notification the user turned off",
+				logrus.Fields{
+					"message_id": message.ID,
+					"user_id":    notificationData.UserID,
+					"category":   notificationData.category(),
+					"channel":    channel,
+				})
+			continue
+		}
+		if err := w.processNotificationChannel(ctx, notificationData, channel); err != nil {
 			w.logger.Error("Failed to process notification channel",
 				logrus.Fields{
 					"message_id": message.ID,
@@ -84,8 +128,31 @@ func (w *NotificationWorker) ProcessMessage(message queue.Message) error {
 	return nil
 }
 
+// allowed reports whether the user wants the category over the channel.
+// In-app notifications are shown inside the shop and are always allowed.
+func (w *NotificationWorker) allowed(ctx context.Context, userID string, category notification.Category, channel string) bool {
+	if channel == "in-app" || category == "" || category.Required() {
+		return true
+	}
+	if w.policy == nil {
+		return false
+	}
+	allowed, err := w.policy.Allowed(ctx, userID, category, user.Channel(channel))
+	if err != nil {
+		w.logger.Error("Failed to check notification preferences",
+			logrus.Fields{
+				"user_id":  userID,
+				"category": category,
+				"channel":  channel,
+				"error":    err.Error(),
+			})
+		return false
+	}
+	return allowed
+}
+
 // processNotificationChannel processes notification for a specific channel
-func (w *NotificationWorker) processNotificationChannel(data NotificationData, channel string) error {
+func (w *NotificationWorker) processNotificationChannel(ctx context.Context, data NotificationData, channel string) error {
 	switch channel {
 	case "email":
 		return w.sendEmailNotification(data)
@@ -95,6 +162,8 @@ func (w *NotificationWorker) processNotificationChannel(data NotificationData, c
 		return w.sendPushNotification(data)
 	case "in-app":
 		return w.sendInAppNotification(data)
+	case "whatsapp":
+		return w.sendWhatsAppNotification(ctx, data)
 	default:
 		return fmt.Errorf("unsupported notification channel: %s", channel)
 	}
@@ -200,6 +269,34 @@ func (w *NotificationWorker) sendInAppNotification(data NotificationData) error
 	return nil
 }
 
+// sendWhatsAppNotification sends the approved template named after the
+// notification's type
+func (w *NotificationWorker) sendWhatsAppNotification(ctx context.Context, data NotificationData) error {
+	if w.whatsApp == nil {
+		return whatsapp.ErrDisabled
+	}
+
+	message, err := w.whatsApp.Send(ctx, whatsapp.Notification{
+		UserID: data.UserID,
+		Type:   data.Type,
+		Phone:  data.Phone,
+		Locale: data.Locale,
+		Data:   data.Data,
+	})
+	if err != nil {
+		return err
+	}
+
+	w.logger.Debug("WhatsApp notification sent successfully",
+		logrus.Fields{
+			"user_id":    data.UserID,
+			"type":       data.Type,
+			"message_id": message.ID,
+		})
+
+	return nil
+}
+
 // Helper function to convert map to struct
 func mapToStruct(m map[string]interface{}, v interface{}) error {
 	data, err := json.Marshal(m)
@@ -0,0 +1,210 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"online-shop/internal/domain/job"
+	"online-shop/internal/infrastructure/queue"
+	"online-shop/pkg/workerpool"
+)
+
+// recordTimeout bounds recording a job once its own context is done
+const recordTimeout = 10 * time.Second
+
+// JobTracker records the queue messages the workers handle as jobs, with
+// each attempt and its outcome. When the jobs table cannot be reached the
+// message is handled untracked rather than held up.
+type JobTracker struct {
+	jobs   job.Repository
+	logger *logrus.Logger
+}
+
+// NewJobTracker creates a new job tracker
+func NewJobTracker(jobs job.Repository, logger *logrus.Logger) *JobTracker {
+	return &JobTracker{jobs: jobs, logger: logger}
+}
+
+// Track wraps a queue's message handler. Messages of cancelled jobs, and of
+// jobs that already succeeded, are acknowledged without running.
+//
+// The queue requeues a failed message as it was first published, so it
+// cannot count attempts itself; the job does. Once a job has failed its
+// last attempt the message is acknowledged and the job is left failed, to
+// be retried from the admin API.
+//
+// Each message is handled with a context that ends after timeout, zero
+// leaving it unbounded. It is not tied to the consumer's, as the consumers
+// finish the messages in flight when they stop.
+func (t *JobTracker) Track(queueName string, timeout time.Duration, handle func(context.Context, queue.Message) error) func(queue.Message) error {
+	return func(message queue.Message) error {
+		ctx, cancel := context.WithCancel(context.Background())
+		if timeout > 0 {
+			ctx, cancel = context.WithTimeout(context.Background(), timeout)
+		}
+		defer cancel()
+
+		j, run := t.begin(ctx, queueName, message)
+		if !run {
+			return nil
+		}
+
+		err := handle(ctx, message)
+		if t.finish(ctx, j, err, false) {
+			return nil
+		}
+		return err
+	}
+}
+
+// TrackJob wraps a worker pool job run for a queue message
+func (t *JobTracker) TrackJob(queueName string, message queue.Message, original workerpool.Job) workerpool.Job {
+	return &TrackedJob{
+		BaseJob:     workerpool.BaseJob{ID: original.GetID(), Type: original.GetType()},
+		OriginalJob: original,
+		Message:     message,
+		Queue:       queueName,
+		Tracker:     t,
+	}
+}
+
+// begin records the start of an attempt and reports whether it should run.
+// The job is nil when it could not be loaded or created.
+func (t *JobTracker) begin(ctx context.Context, queueName string, message queue.Message) (*job.Job, bool) {
+	now := time.Now()
+	j, err := t.jobs.Get(ctx, message.ID)
+	if errors.Is(err, job.ErrNotFound) {
+		j = job.New(message.ID, queueName, message.Type, message.Payload, message.MaxRetries, now)
+	} else if err != nil {
+		t.logger.Warn("Failed to load job, running it untracked",
+			logrus.Fields{"job_id": message.ID, "queue": queueName, "error": err.Error()})
+		return nil, true
+	}
+
+	if !j.Runnable() {
+		t.logger.Info("Skipped job",
+			logrus.Fields{"job_id": j.ID, "queue": queueName, "state": j.State})
+		return j, false
+	}
+
+	j.Start(now)
+	t.save(ctx, j)
+	return j, true
+}
+
+// finish records the outcome of an attempt and reports whether the job is
+// out of attempts. last is set when the message will not be delivered
+// again whatever the outcome.
+func (t *JobTracker) finish(ctx context.Context, j *job.Job, err error, last bool) bool {
+	if j == nil {
+		return false
+	}
+
+	now := time.Now()
+	if err == nil {
+		j.Succeed(now)
+		t.save(ctx, j)
+		return false
+	}
+
+	final := last || j.Attempts >= j.MaxAttempts
+	j.Fail(err, final, now)
+	t.save(ctx, j)
+	if final {
+		t.logger.Error("Job failed its last attempt",
+			logrus.Fields{"job_id": j.ID, "queue": j.Queue, "attempts": j.Attempts, "error": err.Error()})
+	}
+	return final
+}
+
+// abandon records the job of a message that will not be run, failed with
+// err, so it can be retried from the admin API
+func (t *JobTracker) abandon(ctx context.Context, queueName string, message queue.Message, err error) {
+	now := time.Now()
+	j, getErr := t.jobs.Get(ctx, message.ID)
+	if errors.Is(getErr, job.ErrNotFound) {
+		j = job.New(message.ID, queueName, message.Type, message.Payload, message.MaxRetries, now)
+	} else if getErr != nil {
+		t.logger.Warn("Failed to load job, dropping it untracked",
+			logrus.Fields{"job_id": message.ID, "queue": queueName, "error": getErr.Error()})
+		return
+	}
+	if !j.Runnable() {
+		return
+	}
+
+	j.Fail(err, true, now)
+	t.save(ctx, j)
+}
+
+// save records the job. An attempt that ran out of time still has its
+// outcome recorded, with a context of its own.
+func (t *JobTracker) save(ctx context.Context, j *job.Job) {
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.Background(), recordTimeout)
+		defer cancel()
+	}
+	if err := t.jobs.Save(ctx, j); err != nil {
+		t.logger.Warn("Failed to record job",
+			logrus.Fields{"job_id": j.ID, "state": j.State, "error": err.Error()})
+	}
+}
+
+// TrackedJob records a worker pool job's attempts like JobTracker.Track.
+// Its message was acknowledged when the job was submitted, so the job fails
+// on its first failed attempt, a panic or timeout included, and when the
+// pool stops before running it.
+type TrackedJob struct {
+	workerpool.BaseJob
+	OriginalJob workerpool.Job
+	Message     queue.Message
+	Queue       string
+	Tracker     *JobTracker
+}
+
+// Execute runs the original job unless its job was cancelled
+func (j *TrackedJob) Execute(ctx context.Context) error {
+	tracked, run := j.Tracker.begin(ctx, j.Queue, j.Message)
+	if !run {
+		return nil
+	}
+
+	err := workerpool.Run(ctx, j.OriginalJob)
+	j.Tracker.finish(ctx, tracked, err, true)
+	return err
+}
+
+// Cancel records the job failed, as the pool stopped before running it
+func (j *TrackedJob) Cancel(err error) {
+	if canceller, ok := j.OriginalJob.(workerpool.Canceller); ok {
+		canceller.Cancel(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+	defer cancel()
+	j.Tracker.abandon(ctx, j.Queue, j.Message, err)
+}
+
+// GetRunAfter returns when the original job may run
+func (j *TrackedJob) GetRunAfter() time.Time {
+	if delayed, ok := j.OriginalJob.(workerpool.DelayedJob); ok {
+		return delayed.GetRunAfter()
+	}
+	return time.Time{}
+}
+
+// GetTimeout returns the timeout of the original job
+func (j *TrackedJob) GetTimeout() time.Duration {
+	if timed, ok := j.OriginalJob.(workerpool.TimeoutJob); ok {
+		return timed.GetTimeout()
+	}
+	return 0
+}
+
+// GetPriority returns the priority of the original job
+func (j *TrackedJob) GetPriority() int {
+	return j.OriginalJob.GetPriority()
+}
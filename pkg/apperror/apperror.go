@@ -0,0 +1,271 @@
+// Package apperror is the shop's error model. Every error a client can see
+// has a stable code and a kind; the kind decides the HTTP status and gRPC
+// code, so both transports report the same failure the same way. Errors are
+// defined once as package-level values and together form the error catalog.
+package apperror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Kind classifies an error independently of the transport.
+type Kind string
+
+const (
+	KindInvalidArgument    Kind = "invalid_argument"
+	KindUnauthenticated    Kind = "unauthenticated"
+	KindPermissionDenied   Kind = "permission_denied"
+	KindNotFound           Kind = "not_found"
+	KindConflict           Kind = "conflict"
+	KindFailedPrecondition Kind = "failed_precondition"
+	KindRateLimited        Kind = "rate_limited"
+	KindTooLarge           Kind = "too_large"
+	KindUnavailable        Kind = "unavailable"
+	KindTimeout            Kind = "timeout"
+	KindInternal           Kind = "internal"
+)
+
+var kindStatus = map[Kind]int{
+	KindInvalidArgument:    http.StatusBadRequest,
+	KindUnauthenticated:    http.StatusUnauthorized,
+	KindPermissionDenied:   http.StatusForbidden,
+	KindNotFound:           http.StatusNotFound,
+	KindConflict:           http.StatusConflict,
+	KindFailedPrecondition: http.StatusUnprocessableEntity,
+	KindRateLimited:        http.StatusTooManyRequests,
+	KindTooLarge:           http.StatusRequestEntityTooLarge,
+	KindUnavailable:        http.StatusServiceUnavailable,
+	KindTimeout:            http.StatusGatewayTimeout,
+	KindInternal:           http.StatusInternalServerError,
+}
+
+var kindCode = map[Kind]codes.Code{
+	KindInvalidArgument:    codes.InvalidArgument,
+	KindUnauthenticated:    codes.Unauthenticated,
+	KindPermissionDenied:   codes.PermissionDenied,
+	KindNotFound:           codes.NotFound,
+	KindConflict:           codes.AlreadyExists,
+	KindFailedPrecondition: codes.FailedPrecondition,
+	KindRateLimited:        codes.ResourceExhausted,
+	KindTooLarge:           codes.ResourceExhausted,
+	KindUnavailable:        codes.Unavailable,
+	KindTimeout:            codes.DeadlineExceeded,
+	KindInternal:           codes.Internal,
+}
+
+// HTTPStatus is the status code errors of this kind are served with.
+func (k Kind) HTTPStatus() int {
+	if status, ok := kindStatus[k]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// GRPCCode is the gRPC code errors of this kind are returned with.
+func (k Kind) GRPCCode() codes.Code {
+	if code, ok := kindCode[k]; ok {
+		return code
+	}
+	return codes.Internal
+}
+
+// FieldError is a problem with one request field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Param   string `json:"param,omitempty"`
+}
+
+// Error is an error with a catalog code. Values built with Define are the
+// catalog entries; With* methods return copies carrying request specifics,
+// which still match the entry with errors.Is.
+type Error struct {
+	Code    string
+	Kind    Kind
+	Message string
+	// Detail explains this occurrence; it defaults to Message
+	Detail string
+	Fields []FieldError
+	Meta   map[string]string
+	cause  error
+}
+
+func (e *Error) Error() string {
+	if e.Detail != "" {
+		return e.Detail
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is matches errors with the same code, so copies made by With* match the
+// catalog entry they came from.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+func (e *Error) clone() *Error {
+	c := *e
+	if e.Meta != nil {
+		c.Meta = make(map[string]string, len(e.Meta))
+		for k, v := range e.Meta {
+			c.Meta[k] = v
+		}
+	}
+	return &c
+}
+
+// WithDetail describes this occurrence of the error.
+func (e *Error) WithDetail(format string, args ...interface{}) *Error {
+	c := e.clone()
+	c.Detail = fmt.Sprintf(format, args...)
+	return c
+}
+
+// WithFields attaches field-level problems.
+func (e *Error) WithFields(fields []FieldError) *Error {
+	c := e.clone()
+	c.Fields = fields
+	return c
+}
+
+// WithMeta attaches a machine-readable value, such as the ID that was not
+// found.
+func (e *Error) WithMeta(key, value string) *Error {
+	c := e.clone()
+	if c.Meta == nil {
+		c.Meta = map[string]string{}
+	}
+	c.Meta[key] = value
+	return c
+}
+
+// Wrap keeps the underlying error for logs; clients only see the code and
+// message.
+func (e *Error) Wrap(cause error) *Error {
+	c := e.clone()
+	c.cause = cause
+	return c
+}
+
+var (
+	catalogMu sync.RWMutex
+	catalog   = map[string]*Error{}
+	mappings  []mapping
+)
+
+type mapping struct {
+	target error
+	err    *Error
+	detail bool
+}
+
+// Define adds an error to the catalog. Codes are snake_case, unique and
+// never reused for a different meaning.
+func Define(kind Kind, code, message string) *Error {
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+
+	if _, exists := catalog[code]; exists {
+		panic("apperror: code " + code + " is defined twice")
+	}
+	e := &Error{Code: code, Kind: kind, Message: message}
+	catalog[code] = e
+	return e
+}
+
+// Map reports errors matching target (with errors.Is) as err. It is how
+// errors of packages that do not depend on apperror get a code.
+func Map(target error, err *Error) {
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+	mappings = append(mappings, mapping{target: target, err: err})
+}
+
+// MapWithDetail is Map for domain errors whose text is written for clients,
+// such as why a template was rejected; the text becomes the detail.
+func MapWithDetail(target error, err *Error) {
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+	mappings = append(mappings, mapping{target: target, err: err, detail: true})
+}
+
+// Lookup returns the catalog entry for a code.
+func Lookup(code string) (*Error, bool) {
+	catalogMu.RLock()
+	defer catalogMu.RUnlock()
+	e, ok := catalog[code]
+	return e, ok
+}
+
+// Catalog lists every defined error ordered by code.
+func Catalog() []*Error {
+	catalogMu.RLock()
+	defer catalogMu.RUnlock()
+
+	entries := make([]*Error, 0, len(catalog))
+	for _, e := range catalog {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Code < entries[j].Code })
+	return entries
+}
+
+// Errors shared by every transport
+var (
+	ErrInternal         = Define(KindInternal, "internal", "an unexpected error occurred")
+	ErrInvalidRequest   = Define(KindInvalidArgument, "invalid_request", "the request is malformed")
+	ErrValidationFailed = Define(KindInvalidArgument, "validation_failed", "one or more fields are invalid")
+	ErrUnauthenticated  = Define(KindUnauthenticated, "unauthenticated", "authentication is required")
+	ErrPermissionDenied = Define(KindPermissionDenied, "permission_denied", "you do not have permission to do this")
+	ErrNotFound         = Define(KindNotFound, "not_found", "the resource was not found")
+	ErrRateLimited      = Define(KindRateLimited, "rate_limited", "too many requests")
+	ErrRequestTooLarge  = Define(KindTooLarge, "request_too_large", "the request is too large")
+	ErrUnavailable      = Define(KindUnavailable, "unavailable", "the service is temporarily unavailable")
+	ErrTimeout          = Define(KindTimeout, "timeout", "the request timed out")
+)
+
+// From turns any error into an *Error. Errors without a code become
+// ErrInternal wrapping the original, so nothing internal leaks to clients.
+func From(err error) *Error {
+	if err == nil {
+		return nil
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+
+	catalogMu.RLock()
+	for _, m := range mappings {
+		if errors.Is(err, m.target) {
+			catalogMu.RUnlock()
+			if m.detail {
+				return m.err.WithDetail("%s", err.Error()).Wrap(err)
+			}
+			return m.err.Wrap(err)
+		}
+	}
+	catalogMu.RUnlock()
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrTimeout.Wrap(err)
+	case errors.Is(err, context.Canceled):
+		return ErrUnavailable.Wrap(err)
+	}
+	return ErrInternal.Wrap(err)
+}
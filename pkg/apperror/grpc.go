@@ -0,0 +1,118 @@
+package apperror
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"online-shop/pkg/i18n"
+
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Domain identifies the shop in gRPC ErrorInfo details.
+const Domain = "online-shop"
+
+// GRPCStatus makes *Error usable as a gRPC error: status.FromError and the
+// gRPC server pick it up directly. The code travels as ErrorInfo.Reason and
+// field errors as BadRequest violations.
+func (e *Error) GRPCStatus() *status.Status {
+	st := status.New(e.Kind.GRPCCode(), e.Error())
+
+	info := &errdetails.ErrorInfo{Reason: e.Code, Domain: Domain, Metadata: e.Meta}
+	if len(e.Fields) == 0 {
+		if withDetails, err := st.WithDetails(info); err == nil {
+			return withDetails
+		}
+		return st
+	}
+
+	badRequest := &errdetails.BadRequest{}
+	for _, f := range e.Fields {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       f.Field,
+			Description: f.Message,
+		})
+	}
+	if withDetails, err := st.WithDetails(info, badRequest); err == nil {
+		return withDetails
+	}
+	return st
+}
+
+// FromStatus reads the catalog error back out of a gRPC error, for clients.
+// Statuses without ErrorInfo keep their message under a generic code.
+func FromStatus(err error) *Error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return From(err)
+	}
+
+	for _, detail := range st.Details() {
+		info, ok := detail.(*errdetails.ErrorInfo)
+		if !ok || info.Domain != Domain {
+			continue
+		}
+		if e, ok := Lookup(info.Reason); ok {
+			c := e.clone()
+			c.Detail = st.Message()
+			c.Meta = info.Metadata
+			return c
+		}
+	}
+
+	for kind, code := range kindCode {
+		if code == st.Code() {
+			return &Error{Code: string(kind), Kind: kind, Message: st.Message()}
+		}
+	}
+	return ErrInternal.WithDetail("%s", st.Message())
+}
+
+// UnaryServerInterceptor converts errors returned by handlers to catalog
+// statuses. Errors that already are gRPC statuses pass through; anything
+// else without a code is logged and reported as internal.
+func UnaryServerInterceptor(logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+		var e *Error
+		if !errors.As(err, &e) {
+			if _, ok := status.FromError(err); ok {
+				return resp, err
+			}
+			e = From(err)
+		}
+		if e.Kind == KindInternal {
+			logger.Error("gRPC call failed", zap.String("method", info.FullMethod), zap.Error(err))
+		}
+		return resp, e.LocalizedStatus(callerLocale(ctx)).Err()
+	}
+}
+
+// LocalizedStatus is GRPCStatus with a LocalizedMessage detail carrying the
+// translated title, when the locale has one.
+func (e *Error) LocalizedStatus(locale string) *status.Status {
+	st := e.GRPCStatus()
+	title, ok := i18n.Lookup(locale, "error."+e.Code)
+	if !ok {
+		return st
+	}
+	if withDetails, err := st.WithDetails(&errdetails.LocalizedMessage{Locale: locale, Message: title}); err == nil {
+		return withDetails
+	}
+	return st
+}
+
+// callerLocale negotiates the locale from the accept-language metadata of
+// an incoming call
+func callerLocale(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return i18n.Negotiate(strings.Join(md.Get("accept-language"), ","))
+}
@@ -0,0 +1,71 @@
+package apperror
+
+// ProblemContentType is the media type of RFC 7807 problem documents.
+const ProblemContentType = "application/problem+json"
+
+// TypeBase prefixes the code in a problem's type URI. The catalog is served
+// under it, so the type of every problem resolves to its documentation.
+var TypeBase = "/errors/"
+
+// Problem is an RFC 7807 problem document. Code, Fields, Meta and RequestID
+// are extension members.
+type Problem struct {
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Status    int               `json:"status"`
+	Detail    string            `json:"detail,omitempty"`
+	Instance  string            `json:"instance,omitempty"`
+	Code      string            `json:"code"`
+	Fields    []FieldError      `json:"fields,omitempty"`
+	Meta      map[string]string `json:"meta,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+}
+
+// Problem renders the error for an HTTP response. The title is the catalog
+// message; the detail is only set when it adds to it.
+func (e *Error) Problem(instance string) Problem {
+	p := Problem{
+		Type:     TypeBase + e.Code,
+		Title:    e.Message,
+		Status:   e.Kind.HTTPStatus(),
+		Instance: instance,
+		Code:     e.Code,
+		Fields:   e.Fields,
+		Meta:     e.Meta,
+	}
+	if e.Detail != "" && e.Detail != e.Message {
+		p.Detail = e.Detail
+	}
+	return p
+}
+
+// Entry is how an error is listed in the published catalog.
+type Entry struct {
+	Code       string `json:"code"`
+	Kind       Kind   `json:"kind"`
+	Message    string `json:"message"`
+	HTTPStatus int    `json:"http_status"`
+	GRPCCode   string `json:"grpc_code"`
+	Type       string `json:"type"`
+}
+
+// Describe lists the catalog in its published form.
+func Describe() []Entry {
+	errs := Catalog()
+	entries := make([]Entry, len(errs))
+	for i, e := range errs {
+		entries[i] = e.Entry()
+	}
+	return entries
+}
+
+func (e *Error) Entry() Entry {
+	return Entry{
+		Code:       e.Code,
+		Kind:       e.Kind,
+		Message:    e.Message,
+		HTTPStatus: e.Kind.HTTPStatus(),
+		GRPCCode:   e.Kind.GRPCCode().String(),
+		Type:       TypeBase + e.Code,
+	}
+}
@@ -1,28 +1,92 @@
 package config
 
 import (
-	"fmt"
+	"strings"
+	"time"
+	// Delivery time zones load on hosts without a zoneinfo database
+	_ "time/tzdata"
+
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Environment   string             `mapstructure:"environment"`
-	Server        ServerConfig       `mapstructure:"server"`
-	Database      DatabaseConfig     `mapstructure:"database"`
-	Redis         RedisConfig        `mapstructure:"redis"`
-	Elasticsearch ElasticsearchConfig `mapstructure:"elasticsearch"`
-	JWT           JWTConfig          `mapstructure:"jwt"`
-	Midtrans      MidtransConfig     `mapstructure:"midtrans"`
-	GRPC          GRPCConfig         `mapstructure:"grpc"`
-	SMTP          SMTPConfig         `mapstructure:"smtp"`
-	RabbitMQ      RabbitMQConfig     `mapstructure:"rabbitmq"`
-	Logger        LoggerConfig       `mapstructure:"logger"`
-	Workers       WorkersConfig      `mapstructure:"workers"`
+	Environment    string               `mapstructure:"environment"`
+	Server         ServerConfig         `mapstructure:"server"`
+	ID             IDConfig             `mapstructure:"id"`
+	Database       DatabaseConfig       `mapstructure:"database"`
+	Redis          RedisConfig          `mapstructure:"redis"`
+	Elasticsearch  ElasticsearchConfig  `mapstructure:"elasticsearch"`
+	JWT            JWTConfig            `mapstructure:"jwt"`
+	Midtrans       MidtransConfig       `mapstructure:"midtrans"`
+	GRPC           GRPCConfig           `mapstructure:"grpc"`
+	SMTP           SMTPConfig           `mapstructure:"smtp"`
+	Email          EmailConfig          `mapstructure:"email"`
+	WhatsApp       WhatsAppConfig       `mapstructure:"whatsapp"`
+	RabbitMQ       RabbitMQConfig       `mapstructure:"rabbitmq"`
+	Kafka          KafkaConfig          `mapstructure:"kafka"`
+	NATS           NATSConfig           `mapstructure:"nats"`
+	Logger         LoggerConfig         `mapstructure:"logger"`
+	Workers        WorkersConfig        `mapstructure:"workers"`
+	Idempotency    IdempotencyConfig    `mapstructure:"idempotency"`
+	SEO            SEOConfig            `mapstructure:"seo"`
+	Recommendation RecommendationConfig `mapstructure:"recommendation"`
+	Analytics      AnalyticsConfig      `mapstructure:"analytics"`
+	Dashboard      DashboardConfig      `mapstructure:"dashboard"`
+	Storage        StorageConfig        `mapstructure:"storage"`
+	Export         ExportConfig         `mapstructure:"export"`
+	CMS            CMSConfig            `mapstructure:"cms"`
+	Storefront     StorefrontConfig     `mapstructure:"storefront"`
+	Shipping       ShippingConfig       `mapstructure:"shipping"`
+	Backup         BackupConfig         `mapstructure:"backup"`
+	Reconciliation ReconciliationConfig `mapstructure:"reconciliation"`
+	Orders         OrdersConfig         `mapstructure:"orders"`
+	Fraud          FraudConfig          `mapstructure:"fraud"`
+	Fulfillment    FulfillmentConfig    `mapstructure:"fulfillment"`
+	COD            CODConfig            `mapstructure:"cod"`
+	Quotes         QuotesConfig         `mapstructure:"quotes"`
+	DraftOrders    DraftOrdersConfig    `mapstructure:"draft_orders"`
+	Organizations  OrganizationsConfig  `mapstructure:"organizations"`
+	StockAlerts    StockAlertsConfig    `mapstructure:"stock_alerts"`
+	PriceAlerts    PriceAlertsConfig    `mapstructure:"price_alerts"`
+	Webhooks       WebhooksConfig       `mapstructure:"webhooks"`
+	SearchSync     SearchSyncConfig     `mapstructure:"search_sync"`
+	Badges         BadgesConfig         `mapstructure:"badges"`
+	InventoryForecast InventoryForecastConfig `mapstructure:"inventory_forecast"`
+	ProductPublish ProductPublishConfig `mapstructure:"product_publish"`
+	Campaigns      CampaignsConfig      `mapstructure:"campaigns"`
+	Notifications  NotificationsConfig  `mapstructure:"notifications"`
+	Cart           CartConfig           `mapstructure:"cart"`
+	RateLimit      RateLimitConfig      `mapstructure:"rate_limit"`
+	RequestLimits  RequestLimitsConfig  `mapstructure:"request_limits"`
+	SecurityHeaders SecurityHeadersConfig `mapstructure:"security_headers"`
+	OAuth          OAuthConfig          `mapstructure:"oauth"`
+	AccountDeletion AccountDeletionConfig `mapstructure:"account_deletion"`
+	Impersonation  ImpersonationConfig  `mapstructure:"impersonation"`
+	Maintenance    MaintenanceConfig    `mapstructure:"maintenance"`
+	Encryption     EncryptionConfig     `mapstructure:"encryption"`
+	Secrets        SecretsConfig        `mapstructure:"secrets"`
+	Pipeline       PipelineConfig       `mapstructure:"pipeline"`
 }
 
+// ServerConfig bounds every HTTP request to RequestTimeoutSeconds: its
+// context is cancelled then, so database and downstream calls stop too.
 type ServerConfig struct {
-	Host string `mapstructure:"host"`
-	Port string `mapstructure:"port"`
+	Host                  string `mapstructure:"host"`
+	Port                  string `mapstructure:"port"`
+	RequestTimeoutSeconds int    `mapstructure:"request_timeout_seconds"`
+}
+
+func (c ServerConfig) RequestTimeout() time.Duration {
+	if c.RequestTimeoutSeconds <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.RequestTimeoutSeconds) * time.Second
+}
+
+// IDConfig sets the node ID embedded in every generated ID. Each process
+// writing to the same database needs a different NodeID, from 0 to 1023.
+type IDConfig struct {
+	NodeID int `mapstructure:"node_id"`
 }
 
 type DatabaseConfig struct {
@@ -35,32 +99,105 @@ type DatabaseConfig struct {
 }
 
 type RedisConfig struct {
-	Host     string `mapstructure:"host"`
-	Port     string `mapstructure:"port"`
-	Password string `mapstructure:"password"`
-	DB       int    `mapstructure:"db"`
+	Host       string           `mapstructure:"host"`
+	Port       string           `mapstructure:"port"`
+	Password   string           `mapstructure:"password"`
+	DB         int              `mapstructure:"db"`
+	Resilience DependencyConfig `mapstructure:"resilience"`
 }
 
 type ElasticsearchConfig struct {
-	URL      string `mapstructure:"url"`
-	Username string `mapstructure:"username"`
-	Password string `mapstructure:"password"`
+	URL        string           `mapstructure:"url"`
+	Username   string           `mapstructure:"username"`
+	Password   string           `mapstructure:"password"`
+	Resilience DependencyConfig `mapstructure:"resilience"`
+}
+
+// DependencyConfig guards calls to a downstream service. At most
+// MaxConcurrent calls run at once (0 means no limit) and the rest fail
+// straight away; after BreakerFailures failures in a row every call fails
+// straight away for BreakerCooldownSeconds, then a single call is let
+// through to see whether the service is back.
+type DependencyConfig struct {
+	MaxConcurrent          int `mapstructure:"max_concurrent"`
+	BreakerFailures        int `mapstructure:"breaker_failures"`
+	BreakerCooldownSeconds int `mapstructure:"breaker_cooldown_seconds"`
+}
+
+func (c DependencyConfig) Failures() int {
+	if c.BreakerFailures <= 0 {
+		return 5
+	}
+	return c.BreakerFailures
+}
+
+func (c DependencyConfig) Cooldown() time.Duration {
+	if c.BreakerCooldownSeconds <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.BreakerCooldownSeconds) * time.Second
 }
 
 type JWTConfig struct {
 	SecretKey string `mapstructure:"secret_key"`
 	ExpiryHours int  `mapstructure:"expiry_hours"`
+	RefreshExpiryHours int `mapstructure:"refresh_expiry_hours"`
+	Issuer string `mapstructure:"issuer"`
+	// ActiveKeyID picks the key new tokens are signed with; the other keys
+	// are only used to verify tokens issued before a rotation.
+	ActiveKeyID string         `mapstructure:"active_key_id"`
+	Keys        []JWTKeyConfig `mapstructure:"keys"`
 }
 
+// JWTKeyConfig is an asymmetric signing key. Retired keys can be listed with
+// only a public key so their tokens stay valid until they expire.
+type JWTKeyConfig struct {
+	ID             string `mapstructure:"id"`
+	Algorithm      string `mapstructure:"algorithm"` // RS256 or EdDSA
+	PrivateKeyPath string `mapstructure:"private_key_path"`
+	PublicKeyPath  string `mapstructure:"public_key_path"`
+}
+
+// MidtransConfig also bounds calls to Midtrans: each attempt gets
+// TimeoutSeconds, and reads and idempotent charges are retried up to
+// Retries more times.
 type MidtransConfig struct {
-	ServerKey    string `mapstructure:"server_key"`
-	ClientKey    string `mapstructure:"client_key"`
-	Environment  string `mapstructure:"environment"` // sandbox or production
+	ServerKey          string           `mapstructure:"server_key"`
+	ClientKey          string           `mapstructure:"client_key"`
+	Environment        string           `mapstructure:"environment"` // sandbox or production
+	TimeoutSeconds     int              `mapstructure:"timeout_seconds"`
+	Retries            int              `mapstructure:"retries"`
+	RetryBackoffMillis int              `mapstructure:"retry_backoff_ms"`
+	Resilience         DependencyConfig `mapstructure:"resilience"`
+}
+
+func (c MidtransConfig) Timeout() time.Duration {
+	if c.TimeoutSeconds <= 0 {
+		return 15 * time.Second
+	}
+	return time.Duration(c.TimeoutSeconds) * time.Second
 }
 
+func (c MidtransConfig) RetryBackoff() time.Duration {
+	if c.RetryBackoffMillis <= 0 {
+		return 200 * time.Millisecond
+	}
+	return time.Duration(c.RetryBackoffMillis) * time.Millisecond
+}
+
+// GRPCConfig bounds every call to RequestTimeoutSeconds, or to the caller's
+// deadline when that is sooner.
 type GRPCConfig struct {
-	Host string `mapstructure:"host"`
-	Port string `mapstructure:"port"`
+	Host                  string `mapstructure:"host"`
+	Port                  string `mapstructure:"port"`
+	RequestTimeoutSeconds int    `mapstructure:"request_timeout_seconds"`
+}
+
+func (c GRPCConfig) RequestTimeout() time.Duration {
+	if c.RequestTimeoutSeconds <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.RequestTimeoutSeconds) * time.Second
 }
 
 type SMTPConfig struct {
@@ -70,18 +207,171 @@ type SMTPConfig struct {
 	Password string `mapstructure:"password"`
 	From     string `mapstructure:"from"`
 	UseTLS   bool   `mapstructure:"use_tls"`
+
+	// TemplateCacheMinutes is how long the email worker caches stored
+	// templates; saving one in the admin API clears its cached copy
+	TemplateCacheMinutes int `mapstructure:"template_cache_minutes"`
+}
+
+func (c SMTPConfig) TemplateCacheTTL() time.Duration {
+	if c.TemplateCacheMinutes <= 0 {
+		return 10 * time.Minute
+	}
+	return time.Duration(c.TemplateCacheMinutes) * time.Minute
+}
+
+// EmailConfig lists the providers the email worker sends through, in order:
+// when one fails the next is tried. Every provider sends from smtp.from. A
+// provider's webhook accepts bounce and complaint notifications once its
+// verification setting is filled in.
+type EmailConfig struct {
+	Providers []string       `mapstructure:"providers"` // smtp, ses, sendgrid or mailgun
+	SES       SESConfig      `mapstructure:"ses"`
+	SendGrid  SendGridConfig `mapstructure:"sendgrid"`
+	Mailgun   MailgunConfig  `mapstructure:"mailgun"`
+
+	// RateLimits caps the sends per second of the providers listed;
+	// the others are not limited
+	RateLimits map[string]float64 `mapstructure:"rate_limits"`
+	Retry      EmailRetryConfig   `mapstructure:"retry"`
+	// BatchSize is how many campaign recipients are queued per message
+	BatchSize int `mapstructure:"batch_size"`
+}
+
+// EmailRetryConfig retries transient failures, such as throttling or a
+// greylisting SMTP server, with exponential backoff and jitter. Once the
+// attempts run out the email is dead-lettered.
+type EmailRetryConfig struct {
+	Attempts        int `mapstructure:"attempts"`
+	BaseDelayMillis int `mapstructure:"base_delay_millis"`
+	MaxDelaySeconds int `mapstructure:"max_delay_seconds"`
+}
+
+func (c EmailRetryConfig) BaseDelay() time.Duration {
+	if c.BaseDelayMillis <= 0 {
+		return 500 * time.Millisecond
+	}
+	return time.Duration(c.BaseDelayMillis) * time.Millisecond
+}
+
+func (c EmailRetryConfig) MaxDelay() time.Duration {
+	if c.MaxDelaySeconds <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.MaxDelaySeconds) * time.Second
+}
+
+// SESConfig sends through the Amazon SES v2 API. Bounces and complaints
+// arrive as SNS notifications from TopicARN.
+type SESConfig struct {
+	Region           string `mapstructure:"region"`
+	AccessKeyID      string `mapstructure:"access_key_id"`
+	SecretAccessKey  string `mapstructure:"secret_access_key"`
+	SessionToken     string `mapstructure:"session_token"`
+	ConfigurationSet string `mapstructure:"configuration_set"`
+	TopicARN         string `mapstructure:"topic_arn"`
+	Endpoint         string `mapstructure:"endpoint"`
+}
+
+type SendGridConfig struct {
+	APIKey string `mapstructure:"api_key"`
+	// WebhookPublicKey is the base64 verification key of the signed event
+	// webhook
+	WebhookPublicKey string `mapstructure:"webhook_public_key"`
+	Endpoint         string `mapstructure:"endpoint"`
+}
+
+type MailgunConfig struct {
+	Domain            string `mapstructure:"domain"`
+	APIKey            string `mapstructure:"api_key"`
+	WebhookSigningKey string `mapstructure:"webhook_signing_key"`
+	Endpoint          string `mapstructure:"endpoint"` // https://api.eu.mailgun.net for EU domains
 }
 
 type RabbitMQConfig struct {
-	Host     string `mapstructure:"host"`
-	Port     int    `mapstructure:"port"`
-	Username string `mapstructure:"username"`
-	Password string `mapstructure:"password"`
-	VHost    string `mapstructure:"vhost"`
+	Host       string           `mapstructure:"host"`
+	Port       int              `mapstructure:"port"`
+	Username   string           `mapstructure:"username"`
+	Password   string           `mapstructure:"password"`
+	VHost      string           `mapstructure:"vhost"`
+	Resilience DependencyConfig `mapstructure:"resilience"`
+}
+
+// KafkaConfig connects to the Kafka cluster carrying analytics events when
+// analytics.transport is kafka. Each queue is a topic named TopicPrefix and
+// the queue name; the workers consume it in ConsumerGroup and commit the
+// offsets of the messages they handled, starting from StartOffset
+// (earliest or latest) when the group has none yet.
+type KafkaConfig struct {
+	Brokers          []string         `mapstructure:"brokers"`
+	ClientID         string           `mapstructure:"client_id"`
+	TopicPrefix      string           `mapstructure:"topic_prefix"`
+	ConsumerGroup    string           `mapstructure:"consumer_group"`
+	StartOffset      string           `mapstructure:"start_offset"`
+	MaxPollRecords   int              `mapstructure:"max_poll_records"`
+	AutoCreateTopics bool             `mapstructure:"auto_create_topics"`
+	Resilience       DependencyConfig `mapstructure:"resilience"`
+}
+
+// Topic is the topic carrying a queue's messages
+func (c KafkaConfig) Topic(queueName string) string {
+	return c.TopicPrefix + queueName
+}
+
+// DeadLetterTopic is the topic a queue's messages go to once their retries
+// are used up, named like the RabbitMQ dead letter queues
+func (c KafkaConfig) DeadLetterTopic(queueName string) string {
+	return c.Topic(queueName + "_dlq")
+}
+
+// NATSConfig connects to the NATS server carrying analytics events when
+// analytics.transport is nats, a lighter option than Kafka. Each queue is
+// the subject SubjectPrefix.<queue> in Stream, which keeps the messages for
+// RetentionHours; the workers read it through a durable consumer and ack
+// what they handled. Setting ReplayFrom, an RFC 3339 time, makes the
+// consumers deliver the stream's messages again from that time, once for
+// each time set.
+type NATSConfig struct {
+	URL            string           `mapstructure:"url"`
+	Stream         string           `mapstructure:"stream"`
+	SubjectPrefix  string           `mapstructure:"subject_prefix"`
+	Durable        string           `mapstructure:"durable"`
+	RetentionHours int              `mapstructure:"retention_hours"`
+	AckWaitSeconds int              `mapstructure:"ack_wait_seconds"`
+	ReplayFrom     string           `mapstructure:"replay_from"`
+	Resilience     DependencyConfig `mapstructure:"resilience"`
+}
+
+// Subject is the subject carrying a queue's messages
+func (c NATSConfig) Subject(queueName string) string {
+	return c.SubjectPrefix + "." + queueName
+}
+
+// DeadLetterSubject is the subject a queue's messages go to once their
+// retries are used up, named like the RabbitMQ dead letter queues
+func (c NATSConfig) DeadLetterSubject(queueName string) string {
+	return c.Subject(queueName + "_dlq")
+}
+
+// Retention is how long the stream keeps messages, a week by default
+func (c NATSConfig) Retention() time.Duration {
+	if c.RetentionHours <= 0 {
+		return 7 * 24 * time.Hour
+	}
+	return time.Duration(c.RetentionHours) * time.Hour
+}
+
+// AckWait is how long a delivered message may go unacked before it is
+// delivered again
+func (c NATSConfig) AckWait() time.Duration {
+	if c.AckWaitSeconds <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.AckWaitSeconds) * time.Second
 }
 
 type LoggerConfig struct {
-	Level      string `mapstructure:"level"`
+	Level      string `mapstructure:"level" reload:"true"`
 	Format     string `mapstructure:"format"`
 	Output     string `mapstructure:"output"`
 	FilePath   string `mapstructure:"file_path"`
@@ -89,6 +379,40 @@ type LoggerConfig struct {
 	MaxBackups int    `mapstructure:"max_backups"`
 	MaxAge     int    `mapstructure:"max_age"`
 	Compress   bool   `mapstructure:"compress"`
+	// Service is added to every entry so logs from several processes can be
+	// told apart when searched together
+	Service     string             `mapstructure:"service"`
+	SearchPaths []string           `mapstructure:"search_paths"`
+	Sampling    LogSamplingConfig  `mapstructure:"sampling"`
+	Redaction   LogRedactionConfig `mapstructure:"redaction"`
+}
+
+// LogRedactionConfig sets how emails, phone numbers and addresses are kept
+// out of the logs. Mode is mask (the default), which keeps a hint of the
+// value, or hash, which lets entries about the same person be matched.
+// Allow lists the kinds logged in plain text, for debugging environments
+// only.
+type LogRedactionConfig struct {
+	Mode  string   `mapstructure:"mode"`
+	Allow []string `mapstructure:"allow"`
+}
+
+// LogSamplingConfig caps repeated entries: of the entries with the same
+// level and message each second, the first Initial are written and then
+// every Thereafter-th. Errors are never sampled; Initial 0 turns sampling
+// off.
+type LogSamplingConfig struct {
+	Initial    int `mapstructure:"initial"`
+	Thereafter int `mapstructure:"thereafter"`
+}
+
+// SearchFiles returns the log files searched from the admin panel. Rotated
+// backups next to each file are included by the reader.
+func (c LoggerConfig) SearchFiles() []string {
+	if len(c.SearchPaths) == 0 {
+		return []string{c.FilePath}
+	}
+	return c.SearchPaths
 }
 
 type WorkersConfig struct {
@@ -96,119 +420,1277 @@ type WorkersConfig struct {
 	InvoiceWorkers      int `mapstructure:"invoice_workers"`
 	NotificationWorkers int `mapstructure:"notification_workers"`
 	AnalyticsWorkers    int `mapstructure:"analytics_workers"`
+	ExportWorkers       int `mapstructure:"export_workers"`
 	MaxRetries          int `mapstructure:"max_retries"`
 	RetryDelay          int `mapstructure:"retry_delay"`
+	// Queues tunes how each queue is consumed, keyed by queue name
+	Queues map[string]QueueConsumerConfig `mapstructure:"queues"`
+	// ScaleIntervalSeconds is how often each queue's consumers are scaled
+	// to its backlog
+	ScaleIntervalSeconds int `mapstructure:"scale_interval_seconds"`
+	// JobTimeoutSeconds bounds each job the worker pools run; a queue's
+	// job_timeout_seconds overrides it
+	JobTimeoutSeconds int `mapstructure:"job_timeout_seconds"`
+	// DebugAddr is where the worker serves its Prometheus metrics on
+	// /metrics and the live state of its consumers and pools on /debug;
+	// empty turns both off
+	DebugAddr string `mapstructure:"debug_addr"`
+}
+
+// QueueConsumerConfig sets how one queue is consumed. Between MinConsumers
+// and MaxConsumers messages are handled at once, one more for every
+// BacklogPerConsumer messages waiting. Prefetch bounds the messages taken
+// off the queue but not yet acknowledged. A MaxPriority above zero declares
+// the queue with that many priority levels, taking higher priority
+// messages first; RabbitMQ cannot change it on a queue that exists.
+// JobTimeoutSeconds overrides the workers' job timeout for the queue.
+type QueueConsumerConfig struct {
+	Prefetch           int `mapstructure:"prefetch"`
+	MinConsumers       int `mapstructure:"min_consumers"`
+	MaxConsumers       int `mapstructure:"max_consumers"`
+	BacklogPerConsumer int `mapstructure:"backlog_per_consumer"`
+	MaxPriority        int `mapstructure:"max_priority"`
+	JobTimeoutSeconds  int `mapstructure:"job_timeout_seconds"`
+	// BulkWorkers and BulkQueued cap the campaign emails and other bulk
+	// jobs running and waiting in the queue's worker pool, keeping workers
+	// and places free for the rest; zero leaves them uncapped.
+	// PriorityAgingSeconds raises a waiting job's priority a level for
+	// every interval, so bulk jobs still run when others keep coming.
+	BulkWorkers          int `mapstructure:"bulk_workers"`
+	BulkQueued           int `mapstructure:"bulk_queued"`
+	PriorityAgingSeconds int `mapstructure:"priority_aging_seconds"`
+}
+
+// Queue returns the consumer config of a queue with its defaults filled in.
+// workers is the consumer count used when none is configured, such as
+// email_workers for the email queue.
+func (c WorkersConfig) Queue(name string, workers int) QueueConsumerConfig {
+	q := c.Queues[name]
+	if q.MaxConsumers <= 0 {
+		q.MaxConsumers = workers
+	}
+	if q.MaxConsumers <= 0 {
+		q.MaxConsumers = 1
+	}
+	if q.MinConsumers <= 0 {
+		q.MinConsumers = 1
+	}
+	if q.MinConsumers > q.MaxConsumers {
+		q.MinConsumers = q.MaxConsumers
+	}
+	if q.Prefetch <= 0 {
+		q.Prefetch = q.MaxConsumers
+	}
+	if q.BacklogPerConsumer <= 0 {
+		q.BacklogPerConsumer = 10
+	}
+	return q
+}
+
+// JobTimeout is how long a job from the queue may run before it fails
+func (c WorkersConfig) JobTimeout(name string) time.Duration {
+	if seconds := c.Queues[name].JobTimeoutSeconds; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if c.JobTimeoutSeconds <= 0 {
+		return 5 * time.Minute
+	}
+	return time.Duration(c.JobTimeoutSeconds) * time.Second
+}
+
+func (c WorkersConfig) ScaleInterval() time.Duration {
+	if c.ScaleIntervalSeconds <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(c.ScaleIntervalSeconds) * time.Second
+}
+
+// WhatsAppConfig connects the notification worker's whatsapp channel to the
+// WhatsApp Business Cloud API. AppSecret verifies the signatures of the
+// delivery status webhook and VerifyToken answers its subscription check.
+type WhatsAppConfig struct {
+	Enabled           bool   `mapstructure:"enabled"`
+	PhoneNumberID     string `mapstructure:"phone_number_id"`
+	BusinessAccountID string `mapstructure:"business_account_id"`
+	AccessToken       string `mapstructure:"access_token"`
+	AppSecret         string `mapstructure:"app_secret"`
+	VerifyToken       string `mapstructure:"verify_token"`
+	APIVersion        string `mapstructure:"api_version"`
+	Endpoint          string `mapstructure:"endpoint"`
+}
+
+type IdempotencyConfig struct {
+	Store    string `mapstructure:"store"` // redis or postgres
+	TTLHours int    `mapstructure:"ttl_hours"`
+}
+
+func (c IdempotencyConfig) TTL() time.Duration {
+	if c.TTLHours <= 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration(c.TTLHours) * time.Hour
+}
+
+type SEOConfig struct {
+	SiteURL                string `mapstructure:"site_url"`
+	SitemapDir             string `mapstructure:"sitemap_dir"`
+	SitemapIntervalMinutes int    `mapstructure:"sitemap_interval_minutes"`
+}
+
+func (c SEOConfig) SitemapInterval() time.Duration {
+	if c.SitemapIntervalMinutes <= 0 {
+		return 6 * time.Hour
+	}
+	return time.Duration(c.SitemapIntervalMinutes) * time.Minute
+}
+
+type RecommendationConfig struct {
+	LookbackDays           int `mapstructure:"lookback_days"`
+	MinSupport             int `mapstructure:"min_support"`
+	MaxRelated             int `mapstructure:"max_related"`
+	RefreshIntervalMinutes int `mapstructure:"refresh_interval_minutes"`
+}
+
+func (c RecommendationConfig) RefreshInterval() time.Duration {
+	if c.RefreshIntervalMinutes <= 0 {
+		return 12 * time.Hour
+	}
+	return time.Duration(c.RefreshIntervalMinutes) * time.Minute
+}
+
+type AnalyticsConfig struct {
+	Sink                 string         `mapstructure:"sink"`      // timescale or none
+	Transport            string         `mapstructure:"transport"` // rabbitmq, kafka or nats
+	BatchSize            int            `mapstructure:"batch_size"`
+	FlushIntervalSeconds int            `mapstructure:"flush_interval_seconds"`
+	Database             DatabaseConfig `mapstructure:"database"`
+}
+
+func (c AnalyticsConfig) FlushInterval() time.Duration {
+	if c.FlushIntervalSeconds <= 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(c.FlushIntervalSeconds) * time.Second
+}
+
+type DashboardConfig struct {
+	RefreshIntervalMinutes int `mapstructure:"refresh_interval_minutes"`
+	LowStockThreshold      int `mapstructure:"low_stock_threshold"`
+}
+
+func (c DashboardConfig) RefreshInterval() time.Duration {
+	if c.RefreshIntervalMinutes <= 0 {
+		return 5 * time.Minute
+	}
+	return time.Duration(c.RefreshIntervalMinutes) * time.Minute
+}
+
+// StorageConfig selects the object store for generated files. The local
+// driver serves files through the API; s3 works with AWS S3 and MinIO.
+type StorageConfig struct {
+	Driver     string `mapstructure:"driver"` // local or s3
+	Endpoint   string `mapstructure:"endpoint"`
+	Region     string `mapstructure:"region"`
+	Bucket     string `mapstructure:"bucket"`
+	AccessKey  string `mapstructure:"access_key"`
+	SecretKey  string `mapstructure:"secret_key"`
+	LocalDir   string `mapstructure:"local_dir"`
+	PublicURL  string `mapstructure:"public_url"`
+	SigningKey string `mapstructure:"signing_key"`
+}
+
+type ExportConfig struct {
+	LinkTTLMinutes int              `mapstructure:"link_ttl_minutes"`
+	Accounting     AccountingConfig `mapstructure:"accounting"`
+}
+
+// AccountingConfig is how accounting exports are written. Prices include
+// tax at TaxRate, such as 0.11 for 11% VAT; zero books no tax. Entries are
+// dated in Timezone. Layouts add to the built-in default, xero and accurate
+// layouts, or replace them by name.
+type AccountingConfig struct {
+	TaxRate  float64                  `mapstructure:"tax_rate"`
+	Timezone string                   `mapstructure:"timezone"`
+	Layouts  []AccountingLayoutConfig `mapstructure:"layouts"`
+}
+
+func (c AccountingConfig) Location() (*time.Location, error) {
+	if c.Timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(c.Timezone)
+}
+
+// AccountingLayoutConfig lays accounting entries out for an accounting app.
+// Entries are the types exported, sale, payment or refund, or all of them
+// when empty. Delimiter separates CSV cells, a comma when empty, and
+// DateFormat is a Go time layout such as "02/01/2006".
+type AccountingLayoutConfig struct {
+	Name       string                   `mapstructure:"name"`
+	Entries    []string                 `mapstructure:"entries"`
+	Delimiter  string                   `mapstructure:"delimiter"`
+	DateFormat string                   `mapstructure:"date_format"`
+	Columns    []AccountingColumnConfig `mapstructure:"columns"`
+}
+
+// AccountingColumnConfig is a column of the entries' Field, or of Value for
+// every entry when no field is given.
+type AccountingColumnConfig struct {
+	Header string `mapstructure:"header"`
+	Field  string `mapstructure:"field"`
+	Value  string `mapstructure:"value"`
+}
+
+func (c ExportConfig) LinkTTL() time.Duration {
+	if c.LinkTTLMinutes <= 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration(c.LinkTTLMinutes) * time.Minute
+}
+
+// CMSConfig tunes the content module. Public content is cached for
+// CacheSeconds, and admin edits clear the cache. Uploaded images are kept in
+// the storage service.
+type CMSConfig struct {
+	CacheSeconds      int `mapstructure:"cache_seconds"`
+	MaxAssetMegabytes int `mapstructure:"max_asset_megabytes"`
+	// AllowedAssetTypes narrows the image types accepted as assets; empty
+	// accepts PNG, JPEG, GIF and WebP
+	AllowedAssetTypes []string `mapstructure:"allowed_asset_types"`
+}
+
+func (c CMSConfig) CacheTTL() time.Duration {
+	if c.CacheSeconds <= 0 {
+		return 5 * time.Minute
+	}
+	return time.Duration(c.CacheSeconds) * time.Second
+}
+
+func (c CMSConfig) MaxAssetSize() int {
+	if c.MaxAssetMegabytes <= 0 {
+		return 5 << 20
+	}
+	return c.MaxAssetMegabytes << 20
+}
+
+// StorefrontConfig gives the storefront settings that apply until an admin
+// saves them, with the store sold in Currency and every built-in locale.
+// Settings are cached for CacheSeconds; saving them clears the cache.
+type StorefrontConfig struct {
+	StoreName    string `mapstructure:"store_name"`
+	Currency     string `mapstructure:"currency"`
+	CacheSeconds int    `mapstructure:"cache_seconds"`
+}
+
+func (c StorefrontConfig) CacheTTL() time.Duration {
+	if c.CacheSeconds <= 0 {
+		return 10 * time.Minute
+	}
+	return time.Duration(c.CacheSeconds) * time.Second
+}
+
+// ShippingConfig picks the Geocoder that checks addresses are real before
+// they are quoted and ordered to: none, google or nominatim. The locations
+// found are cached for CacheHours.
+type ShippingConfig struct {
+	Geocoder   string                `mapstructure:"geocoder"`
+	CacheHours int                   `mapstructure:"cache_hours"`
+	Google     GoogleGeocodingConfig `mapstructure:"google"`
+	Nominatim  NominatimConfig       `mapstructure:"nominatim"`
+	Delivery   DeliveryConfig        `mapstructure:"delivery"`
+}
+
+func (c ShippingConfig) CacheTTL() time.Duration {
+	if c.CacheHours <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return time.Duration(c.CacheHours) * time.Hour
+}
+
+type GoogleGeocodingConfig struct {
+	APIKey   string `mapstructure:"api_key"`
+	Endpoint string `mapstructure:"endpoint"`
+}
+
+// NominatimConfig points at an OpenStreetMap Nominatim server. The public
+// one asks for a UserAgent naming the application and an Email to contact,
+// and serves one request a second.
+type NominatimConfig struct {
+	Endpoint  string `mapstructure:"endpoint"`
+	UserAgent string `mapstructure:"user_agent"`
+	Email     string `mapstructure:"email"`
+}
+
+// DeliveryConfig sets how delivery dates are estimated. Working days are
+// counted in Timezone, skipping weekends when SkipWeekends is set and the
+// Holidays, given as 2006-01-02. Warehouses that set no processing time of
+// their own dispatch in ProcessingDays, counted from the next working day
+// for orders placed at or after CutoffHour.
+type DeliveryConfig struct {
+	Timezone       string   `mapstructure:"timezone"`
+	ProcessingDays int      `mapstructure:"processing_days"`
+	CutoffHour     int      `mapstructure:"cutoff_hour"`
+	SkipWeekends   bool     `mapstructure:"skip_weekends"`
+	Holidays       []string `mapstructure:"holidays"`
+}
+
+func (c DeliveryConfig) Location() (*time.Location, error) {
+	if c.Timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(c.Timezone)
+}
+
+type BackupConfig struct {
+	IntervalHours  int    `mapstructure:"interval_hours"` // 0 disables scheduled backups
+	RetentionDays  int    `mapstructure:"retention_days"`
+	KeepMinimum    int    `mapstructure:"keep_minimum"`
+	TimeoutMinutes int    `mapstructure:"timeout_minutes"`
+	PGDumpPath     string `mapstructure:"pg_dump_path"`
+}
+
+func (c BackupConfig) Interval() time.Duration {
+	return time.Duration(c.IntervalHours) * time.Hour
+}
+
+func (c BackupConfig) Retention() time.Duration {
+	return time.Duration(c.RetentionDays) * 24 * time.Hour
+}
+
+func (c BackupConfig) Timeout() time.Duration {
+	if c.TimeoutMinutes <= 0 {
+		return time.Hour
+	}
+	return time.Duration(c.TimeoutMinutes) * time.Minute
+}
+
+// ReconciliationConfig schedules the nightly reconciliation of payments
+// against the provider. DelayHours after midnight UTC gives the provider
+// time to settle the previous day.
+type ReconciliationConfig struct {
+	Enabled        bool `mapstructure:"enabled"`
+	DelayHours     int  `mapstructure:"delay_hours"`
+	TimeoutMinutes int  `mapstructure:"timeout_minutes"`
+}
+
+func (c ReconciliationConfig) Delay() time.Duration {
+	return time.Duration(c.DelayHours) * time.Hour
+}
+
+func (c ReconciliationConfig) Timeout() time.Duration {
+	if c.TimeoutMinutes <= 0 {
+		return 30 * time.Minute
+	}
+	return time.Duration(c.TimeoutMinutes) * time.Minute
+}
+
+// OrdersConfig formats order numbers as NumberPrefix-year-sequence, with the
+// sequence zero padded to NumberDigits. Orders whose items come to less than
+// MinOrderValue, before shipping, cannot be placed; zero takes any order.
+// GiftWrapFee is charged for orders the customer asks to have gift wrapped.
+type OrdersConfig struct {
+	NumberPrefix  string             `mapstructure:"number_prefix"`
+	NumberDigits  int                `mapstructure:"number_digits"`
+	MinOrderValue float64            `mapstructure:"min_order_value"`
+	GiftWrapFee   float64            `mapstructure:"gift_wrap_fee"`
+	Cancellation  CancellationConfig `mapstructure:"cancellation"`
+}
+
+// CancellationConfig prices cancelling an order that has shipped; before
+// that it is free. The fee is FlatFee plus FeeRate of the order's total and
+// is kept from the refund.
+type CancellationConfig struct {
+	AfterShipment bool    `mapstructure:"after_shipment"` // false refuses to cancel shipped orders
+	FlatFee       float64 `mapstructure:"flat_fee"`
+	FeeRate       float64 `mapstructure:"fee_rate"` // 0.05 is 5%
+}
+
+// FraudConfig sets how orders are scored at checkout. Orders scoring
+// ReviewScore or more are held until an admin approves or declines them.
+type FraudConfig struct {
+	Enabled               bool     `mapstructure:"enabled"`
+	ReviewScore           int      `mapstructure:"review_score"`
+	VelocityWindowMinutes int      `mapstructure:"velocity_window_minutes"`
+	VelocityLimit         int      `mapstructure:"velocity_limit"` // orders a customer can place within the window
+	HighValueAmount       float64  `mapstructure:"high_value_amount"`
+	DisposableDomains     []string `mapstructure:"disposable_domains"` // on top of the built-in list
+}
+
+func (c FraudConfig) VelocityWindow() time.Duration {
+	return time.Duration(c.VelocityWindowMinutes) * time.Minute
+}
+
+// FulfillmentConfig sets up picking and packing. Labels for packed
+// shipments are bought by posting them to LabelWebhookURL; without one,
+// staff enter the carrier and tracking number of a label made elsewhere.
+type FulfillmentConfig struct {
+	PickListSize        int    `mapstructure:"pick_list_size"` // shipments added to a pick list at a time
+	LabelWebhookURL     string `mapstructure:"label_webhook_url"`
+	LabelWebhookToken   string `mapstructure:"label_webhook_token"`
+	LabelTimeoutSeconds int    `mapstructure:"label_timeout_seconds"`
 }
 
-func LoadConfig() (*Config, error) {
-	// Get environment from ENV variable or default to "development"
-	env := viper.GetString("ENVIRONMENT")
-	if env == "" {
-		env = "development"
+func (c FulfillmentConfig) LabelTimeout() time.Duration {
+	if c.LabelTimeoutSeconds <= 0 {
+		return 15 * time.Second
 	}
+	return time.Duration(c.LabelTimeoutSeconds) * time.Second
+}
+
+// CODConfig sets whether orders can be paid in cash on delivery and how
+// much each customer can owe that way; a zero limit is no limit. Admins can
+// override the amounts for a customer.
+type CODConfig struct {
+	Enabled              bool    `mapstructure:"enabled"`
+	MaxOrderAmount       float64 `mapstructure:"max_order_amount"`
+	MaxOutstandingAmount float64 `mapstructure:"max_outstanding_amount"` // across the customer's undelivered orders
+	MaxOpenOrders        int     `mapstructure:"max_open_orders"`
+}
 
-	// Set config file name based on environment
-	configName := "config"
-	if env != "production" {
-		configName = fmt.Sprintf("config.%s", env)
+// QuotesConfig bounds requests for quotes: how many units they are for at
+// least, and how long merchants can hold their offers, ValidityDays when
+// they do not say.
+type QuotesConfig struct {
+	MinQuantity     int `mapstructure:"min_quantity"`
+	ValidityDays    int `mapstructure:"validity_days"`
+	MaxValidityDays int `mapstructure:"max_validity_days"`
+}
+
+func (c QuotesConfig) Validity() time.Duration {
+	if c.ValidityDays <= 0 {
+		return 14 * 24 * time.Hour
 	}
+	return time.Duration(c.ValidityDays) * 24 * time.Hour
+}
 
-	viper.SetConfigName(configName)
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath(".")
-	viper.AddConfigPath("./config")
-	viper.AddConfigPath("./configs")
+func (c QuotesConfig) MaxValidity() time.Duration {
+	if c.MaxValidityDays <= 0 {
+		return 90 * 24 * time.Hour
+	}
+	return time.Duration(c.MaxValidityDays) * 24 * time.Hour
+}
+
+// DraftOrdersConfig sets how long the payment links of draft orders can be
+// paid. The links open PaymentPageURL, the storefront's page for them, with
+// the token; without a page they open the summary the API hosts under
+// notifications.base_url.
+type DraftOrdersConfig struct {
+	ValidityDays   int    `mapstructure:"validity_days"`
+	PaymentPageURL string `mapstructure:"payment_page_url"`
+}
 
-	// Set default values
-	setDefaults()
+func (c DraftOrdersConfig) Validity() time.Duration {
+	if c.ValidityDays <= 0 {
+		return 7 * 24 * time.Hour
+	}
+	return time.Duration(c.ValidityDays) * 24 * time.Hour
+}
 
-	// Read environment variables
-	viper.AutomaticEnv()
+// OrganizationsConfig sets up business accounts: the payment terms new
+// organizations are invoiced on until an admin changes them, and how many
+// members each can have.
+type OrganizationsConfig struct {
+	PaymentTermsDays int `mapstructure:"payment_terms_days"`
+	MaxMembers       int `mapstructure:"max_members"`
+}
 
-	// Try to read environment-specific config first
-	if err := viper.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); ok && env != "development" {
-			// Fallback to default config if environment-specific config not found
-			viper.SetConfigName("config")
-			if err := viper.ReadInConfig(); err != nil {
-				if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-					return nil, err
-				}
-			}
-		} else if !ok {
-			return nil, err
-		}
+func (c OrganizationsConfig) PaymentTerms() int {
+	if c.PaymentTermsDays <= 0 {
+		return 30
 	}
+	return c.PaymentTermsDays
+}
+
+func (c OrganizationsConfig) Members() int {
+	if c.MaxMembers <= 0 {
+		return 100
+	}
+	return c.MaxMembers
+}
+
+// StockAlertsConfig controls back in stock alerts. Subscriptions that are
+// not notified within ExpiryDays are expired.
+type StockAlertsConfig struct {
+	Enabled         bool `mapstructure:"enabled"`
+	IntervalMinutes int  `mapstructure:"interval_minutes"`
+	BatchSize       int  `mapstructure:"batch_size"` // subscribers notified per product and run
+	ExpiryDays      int  `mapstructure:"expiry_days"`
+}
 
-	var config Config
-	if err := viper.Unmarshal(&config); err != nil {
-		return nil, err
+func (c StockAlertsConfig) Interval() time.Duration {
+	if c.IntervalMinutes <= 0 {
+		return 5 * time.Minute
 	}
+	return time.Duration(c.IntervalMinutes) * time.Minute
+}
+
+func (c StockAlertsConfig) Expiry() time.Duration {
+	if c.ExpiryDays <= 0 {
+		return 90 * 24 * time.Hour
+	}
+	return time.Duration(c.ExpiryDays) * 24 * time.Hour
+}
+
+// PriceAlertsConfig controls price drop alerts. Watches that are not
+// notified within ExpiryDays are expired.
+type PriceAlertsConfig struct {
+	Enabled         bool `mapstructure:"enabled"`
+	IntervalMinutes int  `mapstructure:"interval_minutes"`
+	BatchSize       int  `mapstructure:"batch_size"` // watchers notified per product and run
+	ExpiryDays      int  `mapstructure:"expiry_days"`
+}
 
-	// Set environment in config
-	config.Environment = env
+func (c PriceAlertsConfig) Interval() time.Duration {
+	if c.IntervalMinutes <= 0 {
+		return 5 * time.Minute
+	}
+	return time.Duration(c.IntervalMinutes) * time.Minute
+}
+
+func (c PriceAlertsConfig) Expiry() time.Duration {
+	if c.ExpiryDays <= 0 {
+		return 90 * 24 * time.Hour
+	}
+	return time.Duration(c.ExpiryDays) * 24 * time.Hour
+}
+
+// WebhooksConfig controls the events posted to the endpoints merchants and
+// partners register. A delivery the endpoint does not accept is retried
+// after RetryBaseSeconds, doubling each time up to RetryMaxMinutes, until
+// MaxAttempts tries have been made.
+type WebhooksConfig struct {
+	Enabled          bool `mapstructure:"enabled"`
+	IntervalSeconds  int  `mapstructure:"interval_seconds"`
+	BatchSize        int  `mapstructure:"batch_size"` // deliveries sent per run
+	TimeoutSeconds   int  `mapstructure:"timeout_seconds"`
+	MaxAttempts      int  `mapstructure:"max_attempts"`
+	RetryBaseSeconds int  `mapstructure:"retry_base_seconds"`
+	RetryMaxMinutes  int  `mapstructure:"retry_max_minutes"`
+}
+
+func (c WebhooksConfig) Interval() time.Duration {
+	if c.IntervalSeconds <= 0 {
+		return 15 * time.Second
+	}
+	return time.Duration(c.IntervalSeconds) * time.Second
+}
+
+func (c WebhooksConfig) Timeout() time.Duration {
+	if c.TimeoutSeconds <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(c.TimeoutSeconds) * time.Second
+}
+
+func (c WebhooksConfig) RetryBase() time.Duration {
+	if c.RetryBaseSeconds <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.RetryBaseSeconds) * time.Second
+}
+
+func (c WebhooksConfig) RetryMax() time.Duration {
+	if c.RetryMaxMinutes <= 0 {
+		return 6 * time.Hour
+	}
+	return time.Duration(c.RetryMaxMinutes) * time.Minute
+}
+
+// BadgesConfig controls the badge job, which evaluates the badge rules
+// against the catalog every IntervalMinutes, BatchSize products at a time.
+type BadgesConfig struct {
+	Enabled         bool `mapstructure:"enabled"`
+	IntervalMinutes int  `mapstructure:"interval_minutes"`
+	BatchSize       int  `mapstructure:"batch_size"`
+}
+
+func (c BadgesConfig) Interval() time.Duration {
+	if c.IntervalMinutes <= 0 {
+		return 15 * time.Minute
+	}
+	return time.Duration(c.IntervalMinutes) * time.Minute
+}
+
+// InventoryForecastConfig controls the forecast job, which works out the
+// reorder suggestions every IntervalMinutes from the sales recorded in the
+// analytics store. It only runs with the timescale analytics sink. Days of
+// 0 fall back to the forecast defaults.
+type InventoryForecastConfig struct {
+	Enabled         bool `mapstructure:"enabled"`
+	IntervalMinutes int  `mapstructure:"interval_minutes"`
+	LookbackDays    int  `mapstructure:"lookback_days"`
+	LeadTimeDays    int  `mapstructure:"lead_time_days"`
+	SafetyDays      int  `mapstructure:"safety_days"`
+	CoverDays       int  `mapstructure:"cover_days"`
+	BatchSize       int  `mapstructure:"batch_size"`
+}
+
+func (c InventoryForecastConfig) Interval() time.Duration {
+	if c.IntervalMinutes <= 0 {
+		return 6 * time.Hour
+	}
+	return time.Duration(c.IntervalMinutes) * time.Minute
+}
+
+// ProductPublishConfig controls the publish job, which publishes the drafts
+// whose publish date has come every IntervalSeconds, BatchSize at a time.
+type ProductPublishConfig struct {
+	Enabled         bool `mapstructure:"enabled"`
+	IntervalSeconds int  `mapstructure:"interval_seconds"`
+	BatchSize       int  `mapstructure:"batch_size"`
+}
+
+func (c ProductPublishConfig) Interval() time.Duration {
+	if c.IntervalSeconds <= 0 {
+		return time.Minute
+	}
+	return time.Duration(c.IntervalSeconds) * time.Second
+}
+
+// CampaignsConfig controls the campaign job, which looks for due marketing
+// campaigns every IntervalSeconds and queues them BatchSize recipients at a
+// time, at most BatchesPerSecond batches a second. Tracking links in the
+// emails point at TrackingURL, the API's public base URL, and are signed
+// with TrackingSecret; the worker needs the same values.
+type CampaignsConfig struct {
+	Enabled          bool    `mapstructure:"enabled"`
+	IntervalSeconds  int     `mapstructure:"interval_seconds"`
+	BatchSize        int     `mapstructure:"batch_size"`
+	BatchesPerSecond float64 `mapstructure:"batches_per_second"`
+	TrackingURL      string  `mapstructure:"tracking_url"`
+	TrackingSecret   string  `mapstructure:"tracking_secret"`
+}
+
+func (c CampaignsConfig) Interval() time.Duration {
+	if c.IntervalSeconds <= 0 {
+		return time.Minute
+	}
+	return time.Duration(c.IntervalSeconds) * time.Second
+}
+
+// NotificationsConfig signs the unsubscribe links of alert and marketing
+// emails with UnsubscribeSecret. The links open UnsubscribePageURL, the
+// storefront's page confirming it, with the token; without a page, and for
+// one-click unsubscribes, they point at the API under BaseURL. The worker
+// needs the same values; links are left out while the secret is empty.
+type NotificationsConfig struct {
+	BaseURL            string `mapstructure:"base_url"`
+	UnsubscribePageURL string `mapstructure:"unsubscribe_page_url"`
+	UnsubscribeSecret  string `mapstructure:"unsubscribe_secret"`
+}
+
+// CartConfig sets how long carts are kept after their last change: anonymous
+// visitors' for SessionTTLHours, signed-in customers' for UserTTLDays. The
+// cart_token cookie is only sent over HTTPS with SecureCookie.
+type CartConfig struct {
+	SessionTTLHours int  `mapstructure:"session_ttl_hours"`
+	UserTTLDays     int  `mapstructure:"user_ttl_days"`
+	SecureCookie    bool `mapstructure:"secure_cookie"`
+}
+
+func (c CartConfig) SessionTTL() time.Duration {
+	if c.SessionTTLHours <= 0 {
+		return 7 * 24 * time.Hour
+	}
+	return time.Duration(c.SessionTTLHours) * time.Hour
+}
+
+func (c CartConfig) UserTTL() time.Duration {
+	if c.UserTTLDays <= 0 {
+		return 90 * 24 * time.Hour
+	}
+	return time.Duration(c.UserTTLDays) * 24 * time.Hour
+}
+
+// SearchSyncConfig picks how the search index and product caches follow
+// the database. With Mode "inline" the gRPC product service writes them as
+// it writes a product, and a failed write leaves them behind. With Mode
+// "outbox" every product and category write records a change in the same
+// transaction, and the API applies the changes every IntervalSeconds, up to
+// BatchSize a run, retrying those that fail.
+type SearchSyncConfig struct {
+	Mode            string `mapstructure:"mode"`
+	IntervalSeconds int    `mapstructure:"interval_seconds"`
+	BatchSize       int    `mapstructure:"batch_size"`
+}
+
+// Outbox reports whether changes are captured and applied by the job
+func (c SearchSyncConfig) Outbox() bool {
+	return strings.EqualFold(c.Mode, "outbox")
+}
+
+func (c SearchSyncConfig) Interval() time.Duration {
+	if c.IntervalSeconds <= 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(c.IntervalSeconds) * time.Second
+}
+
+// RateLimitConfig holds the default policy and per-route overrides. By is
+// "ip" or "user"; user policies fall back to the IP for anonymous callers.
+type RateLimitConfig struct {
+	Enabled       bool                   `mapstructure:"enabled"`
+	Requests      int                    `mapstructure:"requests" reload:"true"`
+	WindowSeconds int                    `mapstructure:"window_seconds" reload:"true"`
+	By            string                 `mapstructure:"by" reload:"true"`
+	ExemptRoles   []string               `mapstructure:"exempt_roles" reload:"true"`
+	ExemptCIDRs   []string               `mapstructure:"exempt_cidrs" reload:"true"`
+	Routes        []RouteRateLimitConfig `mapstructure:"routes" reload:"true"`
+}
+
+type RouteRateLimitConfig struct {
+	Method        string `mapstructure:"method"`
+	Path          string `mapstructure:"path"`
+	Requests      int    `mapstructure:"requests"`
+	WindowSeconds int    `mapstructure:"window_seconds"`
+	By            string `mapstructure:"by"`
+}
+
+// RequestLimitsConfig caps the size of request bodies. BodyKilobytes applies
+// to every route unless a group matching the path sets its own; the longest
+// prefix wins. Multipart forms parsed by gin keep MultipartMemoryKilobytes in
+// memory and spill the rest to temporary files.
+type RequestLimitsConfig struct {
+	BodyKilobytes            int                    `mapstructure:"body_kilobytes"`
+	MultipartMemoryKilobytes int                    `mapstructure:"multipart_memory_kilobytes"`
+	Groups                   []BodyLimitGroupConfig `mapstructure:"groups"`
+}
+
+type BodyLimitGroupConfig struct {
+	Prefix        string `mapstructure:"prefix"`
+	BodyKilobytes int    `mapstructure:"body_kilobytes"`
+}
+
+func (c RequestLimitsConfig) BodyLimit() int64 {
+	if c.BodyKilobytes <= 0 {
+		return 1 << 20
+	}
+	return int64(c.BodyKilobytes) << 10
+}
+
+func (c RequestLimitsConfig) MultipartMemory() int64 {
+	if c.MultipartMemoryKilobytes <= 0 {
+		return 8 << 20
+	}
+	return int64(c.MultipartMemoryKilobytes) << 10
+}
+
+// SecurityHeadersConfig sets the security headers of every response; each
+// environment's file has its own. Empty values fall back to strict defaults.
+// CSPReportOnly sends the policy as Content-Security-Policy-Report-Only, to
+// try a policy out; violations are reported to CSPReportURI when it is set.
+type SecurityHeadersConfig struct {
+	ContentSecurityPolicy string     `mapstructure:"content_security_policy"`
+	CSPReportOnly         bool       `mapstructure:"csp_report_only"`
+	CSPReportURI          string     `mapstructure:"csp_report_uri"`
+	HSTS                  HSTSConfig `mapstructure:"hsts"`
+	ReferrerPolicy        string     `mapstructure:"referrer_policy"`
+	// FrameOptions is DENY or SAMEORIGIN
+	FrameOptions      string `mapstructure:"frame_options"`
+	PermissionsPolicy string `mapstructure:"permissions_policy"`
+}
 
-	return &config, nil
+// HSTSConfig tells browsers to use HTTPS only. Leave it off where the API
+// is served over plain HTTP.
+type HSTSConfig struct {
+	Enabled           bool `mapstructure:"enabled"`
+	MaxAgeSeconds     int  `mapstructure:"max_age_seconds"`
+	IncludeSubdomains bool `mapstructure:"include_subdomains"`
+	Preload           bool `mapstructure:"preload"`
 }
 
-func setDefaults() {
+func (c SecurityHeadersConfig) CSP() string {
+	if c.ContentSecurityPolicy == "" {
+		return "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; img-src 'self' data: https:; font-src 'self' data:; connect-src 'self'; frame-ancestors 'none'"
+	}
+	return c.ContentSecurityPolicy
+}
+
+func (c SecurityHeadersConfig) Referrer() string {
+	if c.ReferrerPolicy == "" {
+		return "strict-origin-when-cross-origin"
+	}
+	return c.ReferrerPolicy
+}
+
+func (c SecurityHeadersConfig) Frame() string {
+	if c.FrameOptions == "" {
+		return "DENY"
+	}
+	return strings.ToUpper(c.FrameOptions)
+}
+
+func (c SecurityHeadersConfig) Permissions() string {
+	if c.PermissionsPolicy == "" {
+		return "geolocation=(), microphone=(), camera=()"
+	}
+	return c.PermissionsPolicy
+}
+
+func (c HSTSConfig) MaxAge() int {
+	if c.MaxAgeSeconds <= 0 {
+		return 31536000
+	}
+	return c.MaxAgeSeconds
+}
+
+// OAuthConfig enables social login; a provider is active once its client ID
+// is set. After a successful callback the user is redirected to
+// SuccessRedirectURL with the token in the URL fragment, or the token is
+// returned as JSON when it is empty.
+type OAuthConfig struct {
+	StateTTLMinutes    int               `mapstructure:"state_ttl_minutes"`
+	SuccessRedirectURL string            `mapstructure:"success_redirect_url"`
+	Google             GoogleOAuthConfig `mapstructure:"google"`
+	Apple              AppleOAuthConfig  `mapstructure:"apple"`
+}
+
+type GoogleOAuthConfig struct {
+	ClientID     string `mapstructure:"client_id"`
+	ClientSecret string `mapstructure:"client_secret"`
+	RedirectURL  string `mapstructure:"redirect_url"`
+}
+
+type AppleOAuthConfig struct {
+	ClientID       string `mapstructure:"client_id"` // the Services ID
+	TeamID         string `mapstructure:"team_id"`
+	KeyID          string `mapstructure:"key_id"`
+	PrivateKeyPath string `mapstructure:"private_key_path"`
+	RedirectURL    string `mapstructure:"redirect_url"`
+}
+
+func (c OAuthConfig) StateTTL() time.Duration {
+	if c.StateTTLMinutes <= 0 {
+		return 10 * time.Minute
+	}
+	return time.Duration(c.StateTTLMinutes) * time.Minute
+}
+
+// AccountDeletionConfig controls how long a deleted account can still be
+// restored by support before its personal data is erased.
+type AccountDeletionConfig struct {
+	GracePeriodDays int `mapstructure:"grace_period_days"`
+	IntervalMinutes int `mapstructure:"interval_minutes"`
+	BatchSize       int `mapstructure:"batch_size"`
+}
+
+func (c AccountDeletionConfig) GracePeriod() time.Duration {
+	return time.Duration(c.GracePeriodDays) * 24 * time.Hour
+}
+
+func (c AccountDeletionConfig) Interval() time.Duration {
+	if c.IntervalMinutes <= 0 {
+		return time.Hour
+	}
+	return time.Duration(c.IntervalMinutes) * time.Minute
+}
+
+// ImpersonationConfig controls support admins acting as a customer. A token
+// lasts TTLMinutes unless the admin asks for less, and never more than
+// MaxTTLMinutes.
+type ImpersonationConfig struct {
+	Enabled       bool `mapstructure:"enabled"`
+	TTLMinutes    int  `mapstructure:"ttl_minutes"`
+	MaxTTLMinutes int  `mapstructure:"max_ttl_minutes"`
+}
+
+func (c ImpersonationConfig) TTL() time.Duration {
+	if c.TTLMinutes <= 0 {
+		return 15 * time.Minute
+	}
+	return time.Duration(c.TTLMinutes) * time.Minute
+}
+
+func (c ImpersonationConfig) MaxTTL() time.Duration {
+	if c.MaxTTLMinutes <= 0 {
+		return time.Hour
+	}
+	return time.Duration(c.MaxTTLMinutes) * time.Minute
+}
+
+// MaintenanceConfig controls maintenance mode. Each API instance rereads the
+// shared state every RefreshSeconds, and tells clients to retry after
+// RetryAfterSeconds when maintenance has no planned end. ExemptPaths are
+// served during maintenance, besides health checks and the admin API.
+type MaintenanceConfig struct {
+	RefreshSeconds    int      `mapstructure:"refresh_seconds"`
+	RetryAfterSeconds int      `mapstructure:"retry_after_seconds"`
+	ExemptPaths       []string `mapstructure:"exempt_paths"`
+}
+
+func (c MaintenanceConfig) Refresh() time.Duration {
+	if c.RefreshSeconds <= 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(c.RefreshSeconds) * time.Second
+}
+
+func (c MaintenanceConfig) RetryAfter() time.Duration {
+	if c.RetryAfterSeconds <= 0 {
+		return 5 * time.Minute
+	}
+	return time.Duration(c.RetryAfterSeconds) * time.Second
+}
+
+// PipelineConfig sets up the behaviors every command and query handler runs
+// through. Queries failing on an unavailable dependency are retried up to
+// Retries more times, waiting RetryBackoffMillis longer before each attempt.
+type PipelineConfig struct {
+	Retries            int                        `mapstructure:"retries"`
+	RetryBackoffMillis int                        `mapstructure:"retry_backoff_ms"`
+	Transactions       PipelineTransactionsConfig `mapstructure:"transactions"`
+}
+
+// PipelineTransactionsConfig runs each command in a database transaction,
+// except those whose type implements pipeline.NonTransactional.
+type PipelineTransactionsConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+}
+
+func (c PipelineConfig) RetryBackoff() time.Duration {
+	if c.RetryBackoffMillis <= 0 {
+		return 100 * time.Millisecond
+	}
+	return time.Duration(c.RetryBackoffMillis) * time.Millisecond
+}
+
+// EncryptionConfig holds the key-encryption keys for encrypted columns. Each
+// value is sealed with its own data key, which is wrapped by the active key;
+// older keys stay listed so values written before a rotation can be read.
+type EncryptionConfig struct {
+	ActiveKeyID string                `mapstructure:"active_key_id"`
+	Keys        []EncryptionKeyConfig `mapstructure:"keys"`
+	// IndexKey derives the blind indexes used to look up encrypted values
+	IndexKey string `mapstructure:"index_key"`
+	BatchSize int   `mapstructure:"batch_size"`
+}
+
+type EncryptionKeyConfig struct {
+	ID  string `mapstructure:"id"`
+	Key string `mapstructure:"key"` // base64-encoded 32-byte AES key
+}
+
+// SecretsConfig selects the store that config values written as
+// secret://<path>#<key> are read from. Referenced secrets are fetched again
+// every refresh interval so rotated values apply without a restart.
+type SecretsConfig struct {
+	Provider       string           `mapstructure:"provider"` // vault, aws or empty to disable
+	RefreshMinutes int              `mapstructure:"refresh_minutes"`
+	Vault          VaultConfig      `mapstructure:"vault"`
+	AWS            AWSSecretsConfig `mapstructure:"aws"`
+}
+
+func (c SecretsConfig) RefreshInterval() time.Duration {
+	if c.RefreshMinutes <= 0 {
+		return 5 * time.Minute
+	}
+	return time.Duration(c.RefreshMinutes) * time.Minute
+}
+
+type VaultConfig struct {
+	Address   string `mapstructure:"address"`
+	Token     string `mapstructure:"token"`
+	Mount     string `mapstructure:"mount"`
+	Namespace string `mapstructure:"namespace"`
+}
+
+type AWSSecretsConfig struct {
+	Region          string `mapstructure:"region"`
+	AccessKeyID     string `mapstructure:"access_key_id"`
+	SecretAccessKey string `mapstructure:"secret_access_key"`
+	SessionToken    string `mapstructure:"session_token"`
+	Endpoint        string `mapstructure:"endpoint"`
+}
+
+func setDefaults(v *viper.Viper) {
 	// Environment
-	viper.SetDefault("environment", "development")
+	v.SetDefault("environment", "development")
 
 	// Server defaults
-	viper.SetDefault("server.host", "0.0.0.0")
-	viper.SetDefault("server.port", "12000")
+	v.SetDefault("server.host", "0.0.0.0")
+	v.SetDefault("server.port", "12000")
+	v.SetDefault("server.request_timeout_seconds", 30)
+
+	// ID defaults
+	v.SetDefault("id.node_id", 0)
 
 	// Database defaults
-	viper.SetDefault("database.host", "localhost")
-	viper.SetDefault("database.port", "5432")
-	viper.SetDefault("database.sslmode", "disable")
+	v.SetDefault("database.host", "localhost")
+	v.SetDefault("database.port", "5432")
+	v.SetDefault("database.sslmode", "disable")
 
 	// Redis defaults
-	viper.SetDefault("redis.host", "localhost")
-	viper.SetDefault("redis.port", "6379")
-	viper.SetDefault("redis.db", 0)
+	v.SetDefault("redis.host", "localhost")
+	v.SetDefault("redis.port", "6379")
+	v.SetDefault("redis.db", 0)
+	v.SetDefault("redis.resilience.max_concurrent", 200)
+	v.SetDefault("redis.resilience.breaker_failures", 10)
+	v.SetDefault("redis.resilience.breaker_cooldown_seconds", 10)
 
 	// Elasticsearch defaults
-	viper.SetDefault("elasticsearch.url", "http://localhost:9200")
+	v.SetDefault("elasticsearch.url", "http://localhost:9200")
+	v.SetDefault("elasticsearch.resilience.max_concurrent", 50)
+	v.SetDefault("elasticsearch.resilience.breaker_failures", 5)
+	v.SetDefault("elasticsearch.resilience.breaker_cooldown_seconds", 30)
 
 	// JWT defaults
-	viper.SetDefault("jwt.expiry_hours", 24)
+	v.SetDefault("jwt.expiry_hours", 24)
+	v.SetDefault("jwt.refresh_expiry_hours", 720)
+	v.SetDefault("jwt.issuer", "online-shop")
 
 	// Midtrans defaults
-	viper.SetDefault("midtrans.environment", "sandbox")
+	v.SetDefault("midtrans.environment", "sandbox")
+	v.SetDefault("midtrans.timeout_seconds", 15)
+	v.SetDefault("midtrans.retries", 2)
+	v.SetDefault("midtrans.retry_backoff_ms", 200)
+	v.SetDefault("midtrans.resilience.max_concurrent", 50)
+	v.SetDefault("midtrans.resilience.breaker_failures", 5)
+	v.SetDefault("midtrans.resilience.breaker_cooldown_seconds", 30)
 
 	// GRPC defaults
-	viper.SetDefault("grpc.host", "0.0.0.0")
-	viper.SetDefault("grpc.port", "12001")
+	v.SetDefault("grpc.host", "0.0.0.0")
+	v.SetDefault("grpc.port", "12001")
+	v.SetDefault("grpc.request_timeout_seconds", 30)
 
 	// SMTP defaults
-	viper.SetDefault("smtp.host", "localhost")
-	viper.SetDefault("smtp.port", 587)
-	viper.SetDefault("smtp.use_tls", true)
+	v.SetDefault("smtp.host", "localhost")
+	v.SetDefault("smtp.port", 587)
+	v.SetDefault("smtp.use_tls", true)
+	v.SetDefault("smtp.template_cache_minutes", 10)
+
+	// Email delivery defaults
+	v.SetDefault("email.providers", []string{"smtp"})
+	v.SetDefault("email.sendgrid.endpoint", "https://api.sendgrid.com")
+	v.SetDefault("email.mailgun.endpoint", "https://api.mailgun.net")
+	v.SetDefault("email.retry.attempts", 4)
+	v.SetDefault("email.batch_size", 100)
+	v.SetDefault("whatsapp.api_version", "v19.0")
+	v.SetDefault("whatsapp.endpoint", "https://graph.facebook.com")
 
 	// RabbitMQ defaults
-	viper.SetDefault("rabbitmq.host", "localhost")
-	viper.SetDefault("rabbitmq.port", 5672)
-	viper.SetDefault("rabbitmq.username", "guest")
-	viper.SetDefault("rabbitmq.password", "guest")
-	viper.SetDefault("rabbitmq.vhost", "/")
+	v.SetDefault("rabbitmq.host", "localhost")
+	v.SetDefault("rabbitmq.port", 5672)
+	v.SetDefault("rabbitmq.username", "guest")
+	v.SetDefault("rabbitmq.password", "guest")
+	v.SetDefault("rabbitmq.vhost", "/")
+	v.SetDefault("rabbitmq.resilience.max_concurrent", 100)
+	v.SetDefault("rabbitmq.resilience.breaker_failures", 5)
+	v.SetDefault("rabbitmq.resilience.breaker_cooldown_seconds", 15)
 
 	// Logger defaults
-	viper.SetDefault("logger.level", "info")
-	viper.SetDefault("logger.format", "json")
-	viper.SetDefault("logger.output", "stdout")
-	viper.SetDefault("logger.file_path", "/var/log/online-shop/app.log")
-	viper.SetDefault("logger.max_size", 100)
-	viper.SetDefault("logger.max_backups", 3)
-	viper.SetDefault("logger.max_age", 28)
-	viper.SetDefault("logger.compress", true)
+	v.SetDefault("logger.level", "info")
+	v.SetDefault("logger.format", "json")
+	v.SetDefault("logger.output", "stdout")
+	v.SetDefault("logger.file_path", "/var/log/online-shop/app.log")
+	v.SetDefault("logger.max_size", 100)
+	v.SetDefault("logger.max_backups", 3)
+	v.SetDefault("logger.max_age", 28)
+	v.SetDefault("logger.compress", true)
+	v.SetDefault("logger.service", "api")
 
 	// Workers defaults
-	viper.SetDefault("workers.email_workers", 5)
-	viper.SetDefault("workers.invoice_workers", 3)
-	viper.SetDefault("workers.notification_workers", 3)
-	viper.SetDefault("workers.analytics_workers", 2)
-	viper.SetDefault("workers.max_retries", 3)
-	viper.SetDefault("workers.retry_delay", 5)
+	v.SetDefault("workers.email_workers", 5)
+	v.SetDefault("workers.invoice_workers", 3)
+	v.SetDefault("workers.notification_workers", 3)
+	v.SetDefault("workers.analytics_workers", 2)
+	v.SetDefault("workers.export_workers", 2)
+	v.SetDefault("workers.max_retries", 3)
+	v.SetDefault("workers.retry_delay", 5)
+	v.SetDefault("workers.scale_interval_seconds", 10)
+	v.SetDefault("workers.job_timeout_seconds", 300)
+	v.SetDefault("workers.debug_addr", ":9101")
+
+	// Idempotency defaults
+	v.SetDefault("idempotency.store", "redis")
+	v.SetDefault("idempotency.ttl_hours", 24)
+
+	// SEO defaults
+	v.SetDefault("seo.site_url", "http://localhost:12000")
+	v.SetDefault("seo.sitemap_dir", "./data/sitemaps")
+	v.SetDefault("seo.sitemap_interval_minutes", 360)
+
+	// Recommendation defaults
+	v.SetDefault("recommendation.lookback_days", 90)
+	v.SetDefault("recommendation.min_support", 2)
+	v.SetDefault("recommendation.max_related", 20)
+	v.SetDefault("recommendation.refresh_interval_minutes", 720)
+
+	// Analytics defaults
+	v.SetDefault("analytics.sink", "none")
+	v.SetDefault("analytics.transport", "rabbitmq")
+	v.SetDefault("kafka.client_id", "online-shop")
+	v.SetDefault("kafka.topic_prefix", "online-shop.")
+	v.SetDefault("kafka.consumer_group", "online-shop-workers")
+	v.SetDefault("kafka.start_offset", "earliest")
+	v.SetDefault("kafka.max_poll_records", 500)
+	v.SetDefault("kafka.resilience.max_concurrent", 100)
+	v.SetDefault("kafka.resilience.breaker_failures", 5)
+	v.SetDefault("kafka.resilience.breaker_cooldown_seconds", 15)
+	v.SetDefault("nats.url", "nats://localhost:4222")
+	v.SetDefault("nats.stream", "ONLINE_SHOP")
+	v.SetDefault("nats.subject_prefix", "online-shop")
+	v.SetDefault("nats.durable", "online-shop-workers")
+	v.SetDefault("nats.retention_hours", 168)
+	v.SetDefault("nats.ack_wait_seconds", 30)
+	v.SetDefault("nats.resilience.max_concurrent", 100)
+	v.SetDefault("nats.resilience.breaker_failures", 5)
+	v.SetDefault("nats.resilience.breaker_cooldown_seconds", 15)
+	v.SetDefault("analytics.batch_size", 500)
+	v.SetDefault("analytics.flush_interval_seconds", 5)
+
+	// Dashboard defaults
+	v.SetDefault("dashboard.refresh_interval_minutes", 5)
+	v.SetDefault("dashboard.low_stock_threshold", 10)
+
+	// Storage and export defaults
+	v.SetDefault("storage.driver", "local")
+	v.SetDefault("storage.local_dir", "./data/exports")
+	v.SetDefault("storage.public_url", "http://localhost:12000/downloads")
+	v.SetDefault("export.link_ttl_minutes", 1440)
+	v.SetDefault("export.accounting.tax_rate", 0)
+	v.SetDefault("export.accounting.timezone", "Asia/Jakarta")
+
+	// CMS defaults
+	v.SetDefault("cms.cache_seconds", 300)
+	v.SetDefault("cms.max_asset_megabytes", 5)
+
+	// Storefront defaults
+	v.SetDefault("storefront.store_name", "Online Shop")
+	v.SetDefault("storefront.currency", "IDR")
+	v.SetDefault("storefront.cache_seconds", 600)
+
+	// Shipping defaults
+	v.SetDefault("shipping.geocoder", "none")
+	v.SetDefault("shipping.cache_hours", 720)
+	v.SetDefault("shipping.google.endpoint", "https://maps.googleapis.com")
+	v.SetDefault("shipping.nominatim.endpoint", "https://nominatim.openstreetmap.org")
+	v.SetDefault("shipping.nominatim.user_agent", "online-shop")
+	v.SetDefault("shipping.delivery.timezone", "Asia/Jakarta")
+	v.SetDefault("shipping.delivery.processing_days", 1)
+	v.SetDefault("shipping.delivery.cutoff_hour", 14)
+	v.SetDefault("shipping.delivery.skip_weekends", true)
+
+	// Backup defaults
+	v.SetDefault("backup.interval_hours", 24)
+	v.SetDefault("backup.retention_days", 14)
+	v.SetDefault("backup.keep_minimum", 3)
+	v.SetDefault("backup.timeout_minutes", 60)
+	v.SetDefault("backup.pg_dump_path", "pg_dump")
+
+	// Reconciliation defaults
+	v.SetDefault("reconciliation.enabled", true)
+	v.SetDefault("reconciliation.delay_hours", 2)
+	v.SetDefault("reconciliation.timeout_minutes", 30)
+
+	// Order number defaults
+	v.SetDefault("orders.number_prefix", "ORD")
+	v.SetDefault("orders.number_digits", 6)
+	v.SetDefault("orders.min_order_value", 0)
+	v.SetDefault("orders.gift_wrap_fee", 15000)
+	v.SetDefault("orders.cancellation.after_shipment", true)
+	v.SetDefault("orders.cancellation.flat_fee", 25000)
+	v.SetDefault("orders.cancellation.fee_rate", 0)
+
+	// Fraud defaults
+	v.SetDefault("fraud.enabled", true)
+	v.SetDefault("fraud.review_score", 60)
+	v.SetDefault("fraud.velocity_window_minutes", 60)
+	v.SetDefault("fraud.velocity_limit", 3)
+	v.SetDefault("fraud.high_value_amount", 10000000)
+
+	// Fulfillment defaults
+	v.SetDefault("fulfillment.pick_list_size", 50)
+	v.SetDefault("fulfillment.label_timeout_seconds", 15)
+
+	// Cash on delivery defaults
+	v.SetDefault("cod.enabled", false)
+	v.SetDefault("cod.max_order_amount", 5000000)
+	v.SetDefault("cod.max_outstanding_amount", 10000000)
+	v.SetDefault("cod.max_open_orders", 3)
+
+	// Quotes defaults
+	v.SetDefault("quotes.min_quantity", 10)
+	v.SetDefault("quotes.validity_days", 14)
+	v.SetDefault("quotes.max_validity_days", 90)
+
+	// Draft orders defaults
+	v.SetDefault("draft_orders.validity_days", 7)
+	v.SetDefault("draft_orders.payment_page_url", "")
+
+	// Organization defaults
+	v.SetDefault("organizations.payment_terms_days", 30)
+	v.SetDefault("organizations.max_members", 100)
+
+	// Stock alert defaults
+	v.SetDefault("stock_alerts.enabled", true)
+	v.SetDefault("stock_alerts.interval_minutes", 5)
+	v.SetDefault("stock_alerts.batch_size", 100)
+	v.SetDefault("stock_alerts.expiry_days", 90)
+
+	// Price alert defaults
+	v.SetDefault("price_alerts.enabled", true)
+	v.SetDefault("price_alerts.interval_minutes", 5)
+	v.SetDefault("price_alerts.batch_size", 100)
+	v.SetDefault("price_alerts.expiry_days", 90)
+
+	// Webhook defaults
+	v.SetDefault("webhooks.enabled", true)
+	v.SetDefault("webhooks.interval_seconds", 15)
+	v.SetDefault("webhooks.batch_size", 100)
+	v.SetDefault("webhooks.timeout_seconds", 10)
+	v.SetDefault("webhooks.max_attempts", 10)
+	v.SetDefault("webhooks.retry_base_seconds", 30)
+	v.SetDefault("webhooks.retry_max_minutes", 360)
+	v.SetDefault("search_sync.mode", "inline")
+	v.SetDefault("search_sync.interval_seconds", 5)
+	v.SetDefault("search_sync.batch_size", 200)
+	v.SetDefault("badges.enabled", true)
+	v.SetDefault("badges.interval_minutes", 15)
+	v.SetDefault("badges.batch_size", 500)
+	v.SetDefault("inventory_forecast.enabled", true)
+	v.SetDefault("inventory_forecast.interval_minutes", 360)
+	v.SetDefault("inventory_forecast.lookback_days", 28)
+	v.SetDefault("inventory_forecast.lead_time_days", 7)
+	v.SetDefault("inventory_forecast.safety_days", 7)
+	v.SetDefault("inventory_forecast.cover_days", 30)
+	v.SetDefault("inventory_forecast.batch_size", 500)
+	v.SetDefault("product_publish.enabled", true)
+	v.SetDefault("product_publish.interval_seconds", 60)
+	v.SetDefault("product_publish.batch_size", 100)
+	v.SetDefault("campaigns.enabled", false)
+	v.SetDefault("campaigns.interval_seconds", 60)
+	v.SetDefault("campaigns.batch_size", 500)
+	v.SetDefault("campaigns.batches_per_second", 2)
+	v.SetDefault("campaigns.tracking_url", "http://localhost:12000")
+	v.SetDefault("notifications.base_url", "http://localhost:12000")
+	v.SetDefault("cart.session_ttl_hours", 168)
+	v.SetDefault("cart.user_ttl_days", 90)
+	v.SetDefault("cart.secure_cookie", false)
+
+	// Rate limit defaults
+	v.SetDefault("rate_limit.enabled", true)
+	v.SetDefault("rate_limit.requests", 600)
+	v.SetDefault("rate_limit.window_seconds", 60)
+	v.SetDefault("rate_limit.by", "ip")
+	v.SetDefault("rate_limit.exempt_roles", []string{"admin"})
+
+	// OAuth defaults
+	v.SetDefault("oauth.state_ttl_minutes", 10)
+
+	// Account deletion defaults
+	v.SetDefault("account_deletion.grace_period_days", 30)
+	v.SetDefault("account_deletion.interval_minutes", 60)
+	v.SetDefault("account_deletion.batch_size", 100)
+
+	// Encryption defaults
+	v.SetDefault("encryption.batch_size", 500)
+
+	// Secrets defaults
+	v.SetDefault("secrets.refresh_minutes", 5)
+	v.SetDefault("secrets.vault.mount", "secret")
+
+	// Pipeline defaults
+	v.SetDefault("pipeline.retries", 2)
+	v.SetDefault("pipeline.retry_backoff_ms", 100)
+	v.SetDefault("pipeline.transactions.enabled", true)
 }
\ No newline at end of file
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Load reads config.yaml and overlays config.<environment>.yaml on top of it,
+// then environment variables named after the keys (DATABASE_PASSWORD
+// overrides database.password). The environment is taken from ENVIRONMENT
+// and defaults to development. Files are looked up in CONFIG_DIR when it is
+// set, otherwise in ., ./config and ./configs; without either file the
+// defaults and environment variables are used. An invalid config is an
+// error, nothing falls back to built-in values.
+func Load() (*Config, error) {
+	cfg, _, err := load()
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// load returns the config without validating it, together with the files it
+// was read from
+func load() (*Config, []string, error) {
+	env := os.Getenv("ENVIRONMENT")
+	if env == "" {
+		env = "development"
+	}
+
+	v := viper.New()
+	setDefaults(v)
+	v.SetConfigType("yaml")
+	for _, dir := range configDirs() {
+		v.AddConfigPath(dir)
+	}
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	var files []string
+	v.SetConfigName("config")
+	if err := v.ReadInConfig(); err != nil {
+		if !isNotFound(err) {
+			return nil, nil, err
+		}
+	} else {
+		files = append(files, v.ConfigFileUsed())
+	}
+
+	v.SetConfigName("config." + env)
+	if err := v.MergeInConfig(); err != nil {
+		if !isNotFound(err) {
+			return nil, nil, err
+		}
+	} else {
+		files = append(files, v.ConfigFileUsed())
+	}
+
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, nil, err
+	}
+	cfg.Environment = env
+	return &cfg, files, nil
+}
+
+func configDirs() []string {
+	if dir := os.Getenv("CONFIG_DIR"); dir != "" {
+		return []string{dir}
+	}
+	return []string{".", "./config", "./configs"}
+}
+
+func isNotFound(err error) bool {
+	var notFound viper.ConfigFileNotFoundError
+	return errors.As(err, &notFound)
+}
+
+// absolute resolves the files a config was read from so they can be watched
+// regardless of the working directory
+func absolute(files []string) []string {
+	resolved := make([]string, 0, len(files))
+	for _, f := range files {
+		if abs, err := filepath.Abs(f); err == nil {
+			f = abs
+		}
+		resolved = append(resolved, f)
+	}
+	return resolved
+}
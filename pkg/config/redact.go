@@ -0,0 +1,79 @@
+package config
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+const redacted = "[redacted]"
+
+// Redacted returns the config keyed by its file names with credentials
+// masked, for inspecting what a running service was configured with.
+func (c *Config) Redacted() map[string]interface{} {
+	return redactStruct(reflect.ValueOf(c).Elem())
+}
+
+func redactStruct(v reflect.Value) map[string]interface{} {
+	out := make(map[string]interface{}, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Tag.Get("mapstructure")
+		if name == "" || name == "-" {
+			continue
+		}
+		out[name] = redactValue(name, v.Field(i))
+	}
+	return out
+}
+
+func redactValue(name string, v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Struct:
+		return redactStruct(v)
+	case reflect.Slice:
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = redactValue(name, v.Index(i))
+		}
+		return items
+	case reflect.String:
+		s := v.String()
+		if s != "" && isSensitive(name) {
+			return redacted
+		}
+		return redactURL(s)
+	}
+	return v.Interface()
+}
+
+// isSensitive matches passwords, tokens and keys but not the ids, paths and
+// sizes that describe them
+func isSensitive(name string) bool {
+	for _, suffix := range []string{"_id", "_path", "_prefix", "_size"} {
+		if strings.HasSuffix(name, suffix) {
+			return false
+		}
+	}
+	for _, word := range []string{"password", "secret", "token", "key"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactURL masks the password of URLs carrying credentials
+func redactURL(s string) string {
+	if !strings.Contains(s, "://") || !strings.Contains(s, "@") {
+		return s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	if _, ok := u.User.Password(); !ok {
+		return s
+	}
+	u.User = url.UserPassword(u.User.Username(), redacted)
+	return u.String()
+}
@@ -0,0 +1,343 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidationError lists every problem found in a config so they can be fixed
+// in one go.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks the fields the services cannot start without and the ones
+// whose values must fall in a known range.
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	v.port("server.port", c.Server.Port, true)
+	v.port("grpc.port", c.GRPC.Port, false)
+	if c.Server.RequestTimeoutSeconds < 0 {
+		v.add("server.request_timeout_seconds must not be negative")
+	}
+	if c.GRPC.RequestTimeoutSeconds < 0 {
+		v.add("grpc.request_timeout_seconds must not be negative")
+	}
+	if c.ID.NodeID < 0 || c.ID.NodeID > 1023 {
+		v.add(fmt.Sprintf("id.node_id must be between 0 and 1023, got %d", c.ID.NodeID))
+	}
+
+	v.required("database.host", c.Database.Host)
+	v.required("database.user", c.Database.User)
+	v.required("database.dbname", c.Database.DBName)
+	v.port("database.port", c.Database.Port, true)
+
+	v.required("redis.host", c.Redis.Host)
+	v.port("redis.port", c.Redis.Port, true)
+
+	v.portNumber("smtp.port", c.SMTP.Port)
+	v.emailProviders(&c.Email)
+	if c.WhatsApp.Enabled {
+		v.required("whatsapp.phone_number_id", c.WhatsApp.PhoneNumberID)
+		v.required("whatsapp.business_account_id", c.WhatsApp.BusinessAccountID)
+		v.required("whatsapp.access_token", c.WhatsApp.AccessToken)
+		v.required("whatsapp.app_secret", c.WhatsApp.AppSecret)
+		v.required("whatsapp.verify_token", c.WhatsApp.VerifyToken)
+	}
+	if c.Campaigns.Enabled {
+		v.required("campaigns.tracking_url", c.Campaigns.TrackingURL)
+		v.required("campaigns.tracking_secret", c.Campaigns.TrackingSecret)
+		v.required("notifications.unsubscribe_secret", c.Notifications.UnsubscribeSecret)
+	}
+	v.portNumber("rabbitmq.port", c.RabbitMQ.Port)
+	v.oneOf("analytics.transport", c.Analytics.Transport, "rabbitmq", "kafka", "nats")
+	if strings.EqualFold(c.Analytics.Transport, "kafka") {
+		if len(c.Kafka.Brokers) == 0 {
+			v.add("kafka.brokers is required")
+		}
+		v.required("kafka.consumer_group", c.Kafka.ConsumerGroup)
+	}
+	v.oneOf("kafka.start_offset", c.Kafka.StartOffset, "earliest", "latest")
+	if c.Kafka.MaxPollRecords < 0 {
+		v.add("kafka.max_poll_records must not be negative")
+	}
+	if strings.EqualFold(c.Analytics.Transport, "nats") {
+		v.required("nats.url", c.NATS.URL)
+		v.required("nats.stream", c.NATS.Stream)
+		v.required("nats.subject_prefix", c.NATS.SubjectPrefix)
+		v.required("nats.durable", c.NATS.Durable)
+	}
+	if strings.ContainsAny(c.NATS.Stream, ". *>") {
+		v.add("nats.stream must not contain '.', '*', '>' or spaces")
+	}
+	if strings.ContainsAny(c.NATS.Durable, ". *>") {
+		v.add("nats.durable must not contain '.', '*', '>' or spaces")
+	}
+	if c.NATS.ReplayFrom != "" {
+		if _, err := time.Parse(time.RFC3339, c.NATS.ReplayFrom); err != nil {
+			v.add(fmt.Sprintf("nats.replay_from must be an RFC 3339 time, got %q", c.NATS.ReplayFrom))
+		}
+	}
+
+	if c.Workers.JobTimeoutSeconds < 0 {
+		v.add("workers.job_timeout_seconds must not be negative")
+	}
+	if addr := c.Workers.DebugAddr; addr != "" {
+		if _, port, err := net.SplitHostPort(addr); err != nil {
+			v.add(fmt.Sprintf("workers.debug_addr must be host:port, got %q", addr))
+		} else {
+			v.port("workers.debug_addr", port, true)
+		}
+	}
+	queueNames := make([]string, 0, len(c.Workers.Queues))
+	for name := range c.Workers.Queues {
+		queueNames = append(queueNames, name)
+	}
+	sort.Strings(queueNames)
+	for _, name := range queueNames {
+		q := c.Workers.Queues[name]
+		if q.Prefetch < 0 || q.MinConsumers < 0 || q.MaxConsumers < 0 || q.BacklogPerConsumer < 0 || q.JobTimeoutSeconds < 0 ||
+			q.BulkWorkers < 0 || q.BulkQueued < 0 || q.PriorityAgingSeconds < 0 {
+			v.add(fmt.Sprintf("workers.queues.%s: values must not be negative", name))
+		}
+		if q.MaxConsumers > 0 && q.MinConsumers > q.MaxConsumers {
+			v.add(fmt.Sprintf("workers.queues.%s: min_consumers cannot be more than max_consumers", name))
+		}
+		if q.MaxPriority < 0 || q.MaxPriority > 255 {
+			v.add(fmt.Sprintf("workers.queues.%s.max_priority must be between 0 and 255", name))
+		}
+	}
+
+	if c.JWT.SecretKey == "" && len(c.JWT.Keys) == 0 {
+		v.add("jwt: secret_key or keys is required")
+	}
+	if c.JWT.ExpiryHours <= 0 {
+		v.add("jwt.expiry_hours must be positive")
+	}
+
+	v.oneOf("logger.level", c.Logger.Level, "trace", "debug", "info", "warn", "warning", "error", "fatal", "panic")
+	if c.Logger.Sampling.Initial < 0 || c.Logger.Sampling.Thereafter < 0 {
+		v.add("logger.sampling: initial and thereafter must not be negative")
+	}
+	v.oneOf("logger.redaction.mode", c.Logger.Redaction.Mode, "mask", "hash")
+	for _, kind := range c.Logger.Redaction.Allow {
+		v.oneOf("logger.redaction.allow", kind, "email", "phone", "address")
+	}
+	if len(c.Logger.Redaction.Allow) > 0 && strings.EqualFold(c.Environment, "production") {
+		v.add("logger.redaction.allow must be empty in production")
+	}
+	v.oneOf("midtrans.environment", c.Midtrans.Environment, "sandbox", "production")
+	v.oneOf("idempotency.store", c.Idempotency.Store, "redis", "postgres")
+	v.oneOf("secrets.provider", c.Secrets.Provider, "vault", "aws")
+
+	if c.RateLimit.Enabled {
+		if c.RateLimit.Requests <= 0 || c.RateLimit.WindowSeconds <= 0 {
+			v.add("rate_limit: requests and window_seconds must be positive")
+		}
+		for i, route := range c.RateLimit.Routes {
+			if route.Path == "" || route.Requests <= 0 || route.WindowSeconds <= 0 {
+				v.add(fmt.Sprintf("rate_limit.routes[%d]: path, requests and window_seconds are required", i))
+			}
+		}
+	}
+
+	if u := c.Fulfillment.LabelWebhookURL; u != "" && !strings.HasPrefix(u, "https://") && !strings.HasPrefix(u, "http://") {
+		v.add(fmt.Sprintf("fulfillment.label_webhook_url must be an http or https URL, got %q", u))
+	}
+
+	if c.Orders.MinOrderValue < 0 {
+		v.add("orders.min_order_value cannot be negative")
+	}
+	if c.Orders.GiftWrapFee < 0 {
+		v.add("orders.gift_wrap_fee cannot be negative")
+	}
+	if cancel := c.Orders.Cancellation; cancel.FlatFee < 0 || cancel.FeeRate < 0 || cancel.FeeRate > 1 {
+		v.add("orders.cancellation: flat_fee cannot be negative and fee_rate must be between 0 and 1")
+	}
+
+	if c.Webhooks.Enabled && c.Webhooks.MaxAttempts < 1 {
+		v.add("webhooks.max_attempts must be at least 1")
+	}
+
+	if currency := c.Storefront.Currency; currency != "" && !currencyCode(currency) {
+		v.add(fmt.Sprintf("storefront.currency must be an ISO 4217 code such as IDR, got %q", currency))
+	}
+
+	v.oneOf("shipping.geocoder", c.Shipping.Geocoder, "none", "google", "nominatim")
+	switch strings.ToLower(c.Shipping.Geocoder) {
+	case "google":
+		v.required("shipping.google.api_key", c.Shipping.Google.APIKey)
+	case "nominatim":
+		v.required("shipping.nominatim.user_agent", c.Shipping.Nominatim.UserAgent)
+	}
+	delivery := c.Shipping.Delivery
+	if _, err := delivery.Location(); err != nil {
+		v.add(fmt.Sprintf("shipping.delivery.timezone must be an IANA time zone such as Asia/Jakarta, got %q", delivery.Timezone))
+	}
+	if delivery.ProcessingDays < 0 || delivery.ProcessingDays > 30 {
+		v.add("shipping.delivery.processing_days must be between 0 and 30")
+	}
+	if delivery.CutoffHour < 0 || delivery.CutoffHour > 23 {
+		v.add("shipping.delivery.cutoff_hour must be between 0 and 23")
+	}
+	for _, holiday := range delivery.Holidays {
+		if _, err := time.Parse("2006-01-02", holiday); err != nil {
+			v.add(fmt.Sprintf("shipping.delivery.holidays must be dates such as 2026-12-25, got %q", holiday))
+		}
+	}
+
+	v.oneOf("search_sync.mode", c.SearchSync.Mode, "inline", "outbox")
+	if c.SearchSync.BatchSize < 0 {
+		v.add("search_sync.batch_size must not be negative")
+	}
+
+	for _, group := range c.RequestLimits.Groups {
+		if !strings.HasPrefix(group.Prefix, "/") || group.BodyKilobytes <= 0 {
+			v.add("request_limits.groups: each group needs a prefix starting with / and a positive body_kilobytes")
+			break
+		}
+	}
+
+	if frame := c.SecurityHeaders.Frame(); frame != "DENY" && frame != "SAMEORIGIN" {
+		v.add("security_headers.frame_options must be DENY or SAMEORIGIN")
+	}
+	if c.SecurityHeaders.HSTS.Preload && (!c.SecurityHeaders.HSTS.IncludeSubdomains || c.SecurityHeaders.HSTS.MaxAge() < 31536000) {
+		v.add("security_headers.hsts: preload needs include_subdomains and a max_age_seconds of at least a year")
+	}
+
+	if c.Impersonation.TTL() > c.Impersonation.MaxTTL() {
+		v.add("impersonation.ttl_minutes cannot be longer than max_ttl_minutes")
+	}
+
+	if c.COD.MaxOrderAmount < 0 || c.COD.MaxOutstandingAmount < 0 || c.COD.MaxOpenOrders < 0 {
+		v.add("cod: limits cannot be negative")
+	}
+
+	if c.Quotes.MinQuantity < 0 {
+		v.add("quotes.min_quantity must not be negative")
+	}
+	if c.Quotes.Validity() > c.Quotes.MaxValidity() {
+		v.add("quotes.validity_days cannot be longer than max_validity_days")
+	}
+
+	if c.Organizations.PaymentTermsDays < 0 || c.Organizations.PaymentTermsDays > 180 {
+		v.add("organizations.payment_terms_days must be between 0 and 180")
+	}
+	if c.Organizations.MaxMembers < 0 {
+		v.add("organizations.max_members must not be negative")
+	}
+
+	if c.Pipeline.Retries < 0 {
+		v.add("pipeline.retries must not be negative")
+	}
+
+	return v.err()
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) add(problem string) {
+	v.problems = append(v.problems, problem)
+}
+
+func (v *validator) required(name, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.add(name + " is required")
+	}
+}
+
+func (v *validator) port(name, value string, required bool) {
+	if value == "" {
+		if required {
+			v.add(name + " is required")
+		}
+		return
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil {
+		v.add(fmt.Sprintf("%s must be a number, got %q", name, value))
+		return
+	}
+	v.portNumber(name, port)
+}
+
+// portNumber accepts zero as "not set"
+func (v *validator) portNumber(name string, port int) {
+	if port < 0 || port > 65535 {
+		v.add(fmt.Sprintf("%s must be between 1 and 65535, got %d", name, port))
+	}
+}
+
+// oneOf accepts an empty value, which leaves the default in place
+func (v *validator) oneOf(name, value string, allowed ...string) {
+	if value == "" {
+		return
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(value, a) {
+			return
+		}
+	}
+	v.add(fmt.Sprintf("%s must be one of %s, got %q", name, strings.Join(allowed, ", "), value))
+}
+
+// emailProviders checks every listed provider is known and has the
+// settings it cannot send without
+func (v *validator) emailProviders(c *EmailConfig) {
+	for i, name := range c.Providers {
+		switch strings.ToLower(name) {
+		case "smtp":
+		case "ses":
+			v.required("email.ses.region", c.SES.Region)
+		case "sendgrid":
+			v.required("email.sendgrid.api_key", c.SendGrid.APIKey)
+		case "mailgun":
+			v.required("email.mailgun.domain", c.Mailgun.Domain)
+			v.required("email.mailgun.api_key", c.Mailgun.APIKey)
+		default:
+			v.add(fmt.Sprintf("email.providers[%d] must be one of smtp, ses, sendgrid, mailgun, got %q", i, name))
+		}
+	}
+	for name, limit := range c.RateLimits {
+		if limit <= 0 {
+			v.add(fmt.Sprintf("email.rate_limits.%s must be positive", name))
+		}
+	}
+	if c.Retry.Attempts < 1 {
+		v.add("email.retry.attempts must be at least 1")
+	}
+	if c.BatchSize <= 0 {
+		v.add("email.batch_size must be positive")
+	}
+}
+
+// currencyCode reports whether code looks like an ISO 4217 code
+func currencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
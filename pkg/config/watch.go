@@ -0,0 +1,231 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDebounce groups the several write events editors emit for one save
+const reloadDebounce = 500 * time.Millisecond
+
+// Watcher reloads the config files when they change. Only fields tagged
+// `reload:"true"` are applied to the running config; changes to any other
+// section are reported as needing a restart. A config that fails validation
+// is rejected and the running one is kept.
+type Watcher struct {
+	mu       sync.RWMutex
+	current  *Config
+	file     *Config
+	files    []string
+	loadedAt time.Time
+	handlers []func(*Config)
+
+	fs   *fsnotify.Watcher
+	done chan struct{}
+}
+
+// ReloadResult describes one reload.
+type ReloadResult struct {
+	// Applied is true when at least one reloadable field changed
+	Applied bool
+	// Restart lists the sections that changed but only apply on restart
+	Restart []string
+}
+
+// NewWatcher starts from the config the process is running with, which may
+// hold resolved secrets and so differ from the files on disk.
+func NewWatcher(current *Config) (*Watcher, error) {
+	file, files, err := load()
+	if err != nil {
+		return nil, err
+	}
+	return &Watcher{
+		current:  current,
+		file:     file,
+		files:    absolute(files),
+		loadedAt: time.Now(),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Current returns the config in force. The returned value must not be
+// modified; reloads replace it rather than change it in place.
+func (w *Watcher) Current() *Config {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.current
+}
+
+// Files lists the config files in use, base file first.
+func (w *Watcher) Files() []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return append([]string(nil), w.files...)
+}
+
+// LoadedAt is when the config was last loaded or reloaded.
+func (w *Watcher) LoadedAt() time.Time {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.loadedAt
+}
+
+// OnChange registers fn to be called with the new config after a reload
+// applied reloadable fields.
+func (w *Watcher) OnChange(fn func(*Config)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers = append(w.handlers, fn)
+}
+
+// Reload reads the files again and applies the reloadable fields that
+// changed.
+func (w *Watcher) Reload() (*ReloadResult, error) {
+	fresh, files, err := load()
+	if err != nil {
+		return nil, err
+	}
+	if err := fresh.Validate(); err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	next := *w.current
+	applied := copyReloadable(reflect.ValueOf(&next).Elem(), reflect.ValueOf(fresh).Elem())
+	restart := changedSections(w.file, fresh)
+	w.file = fresh
+	w.files = absolute(files)
+	w.loadedAt = time.Now()
+	if applied {
+		w.current = &next
+	}
+	handlers := make([]func(*Config), len(w.handlers))
+	copy(handlers, w.handlers)
+	w.mu.Unlock()
+
+	if applied {
+		for _, fn := range handlers {
+			fn(&next)
+		}
+	}
+	return &ReloadResult{Applied: applied, Restart: restart}, nil
+}
+
+// Start watches the config directories and reloads on every change until
+// Stop is called. report receives the outcome of each reload.
+func (w *Watcher) Start(report func(*ReloadResult, error)) error {
+	fs, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// Directories are watched rather than files so editors that replace the
+	// file on save keep being followed
+	dirs := make(map[string]bool)
+	for _, f := range w.Files() {
+		dirs[filepath.Dir(f)] = true
+	}
+	for _, dir := range configDirs() {
+		if abs, err := filepath.Abs(dir); err == nil {
+			dirs[abs] = true
+		}
+	}
+	for dir := range dirs {
+		// Lookup directories that do not exist are skipped
+		_ = fs.Add(dir)
+	}
+	w.fs = fs
+
+	go func() {
+		var pending <-chan time.Time
+		for {
+			select {
+			case <-w.done:
+				return
+			case event, ok := <-fs.Events:
+				if !ok {
+					return
+				}
+				if isConfigFile(event.Name) && event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) != 0 {
+					pending = time.After(reloadDebounce)
+				}
+			case err, ok := <-fs.Errors:
+				if !ok {
+					return
+				}
+				report(nil, err)
+			case <-pending:
+				pending = nil
+				report(w.Reload())
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop ends watching.
+func (w *Watcher) Stop() {
+	if w.fs == nil {
+		return
+	}
+	close(w.done)
+	w.fs.Close()
+	w.fs = nil
+}
+
+func isConfigFile(name string) bool {
+	base := filepath.Base(name)
+	return strings.HasPrefix(base, "config.") && strings.HasSuffix(base, ".yaml")
+}
+
+// copyReloadable copies the fields tagged reload:"true" from src into dst and
+// reports whether any of them differed.
+func copyReloadable(dst, src reflect.Value) bool {
+	changed := false
+	for i := 0; i < dst.NumField(); i++ {
+		field := dst.Type().Field(i)
+		if field.Tag.Get("reload") == "true" {
+			if !reflect.DeepEqual(dst.Field(i).Interface(), src.Field(i).Interface()) {
+				dst.Field(i).Set(src.Field(i))
+				changed = true
+			}
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct && copyReloadable(dst.Field(i), src.Field(i)) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// changedSections lists the top-level sections whose non-reloadable fields
+// differ between two configs.
+func changedSections(before, after *Config) []string {
+	var sections []string
+	b, a := reflect.ValueOf(before).Elem(), reflect.ValueOf(after).Elem()
+	for i := 0; i < b.NumField(); i++ {
+		if !equalIgnoringReloadable(b.Field(i), a.Field(i), b.Type().Field(i)) {
+			sections = append(sections, b.Type().Field(i).Tag.Get("mapstructure"))
+		}
+	}
+	return sections
+}
+
+func equalIgnoringReloadable(b, a reflect.Value, field reflect.StructField) bool {
+	if field.Tag.Get("reload") == "true" {
+		return true
+	}
+	if field.Type.Kind() != reflect.Struct {
+		return reflect.DeepEqual(b.Interface(), a.Interface())
+	}
+	for i := 0; i < b.NumField(); i++ {
+		if !equalIgnoringReloadable(b.Field(i), a.Field(i), field.Type.Field(i)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,295 @@
+// Package fieldcrypt encrypts individual database columns with envelope
+// encryption: every value is sealed with AES-GCM under its own random data
+// key, and the data key is wrapped by a configured key-encryption key.
+// Rotating the key-encryption key only needs the data keys rewrapped.
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"online-shop/pkg/config"
+)
+
+// prefix marks encrypted values so plaintext written before encryption was
+// enabled can still be read
+const prefix = "enc:v1:"
+
+const dataKeySize = 32
+
+var (
+	ErrNotConfigured = errors.New("fieldcrypt: encryption keys are not configured")
+	ErrUnknownKey    = errors.New("fieldcrypt: value was encrypted with an unknown key")
+	ErrMalformed     = errors.New("fieldcrypt: malformed encrypted value")
+)
+
+// Keyring holds the key new values are wrapped with and every key that can
+// still unwrap older ones.
+type Keyring struct {
+	active   string
+	keys     map[string]cipher.AEAD
+	indexKey []byte
+}
+
+func NewKeyring(cfg *config.EncryptionConfig) (*Keyring, error) {
+	if len(cfg.Keys) == 0 {
+		return nil, ErrNotConfigured
+	}
+
+	k := &Keyring{active: cfg.ActiveKeyID, keys: make(map[string]cipher.AEAD)}
+	for _, keyCfg := range cfg.Keys {
+		if keyCfg.ID == "" || strings.Contains(keyCfg.ID, ":") {
+			return nil, fmt.Errorf("fieldcrypt: invalid key id %q", keyCfg.ID)
+		}
+		if _, exists := k.keys[keyCfg.ID]; exists {
+			return nil, fmt.Errorf("fieldcrypt: key %q is configured twice", keyCfg.ID)
+		}
+		raw, err := base64.StdEncoding.DecodeString(keyCfg.Key)
+		if err != nil || len(raw) != 32 {
+			return nil, fmt.Errorf("fieldcrypt: key %q must be 32 base64-encoded bytes", keyCfg.ID)
+		}
+		aead, err := newAEAD(raw)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[keyCfg.ID] = aead
+	}
+
+	if k.active == "" {
+		k.active = cfg.Keys[0].ID
+	}
+	if _, ok := k.keys[k.active]; !ok {
+		return nil, fmt.Errorf("fieldcrypt: active key %q is not configured", k.active)
+	}
+
+	if cfg.IndexKey == "" {
+		return nil, errors.New("fieldcrypt: index key is required")
+	}
+	k.indexKey = []byte(cfg.IndexKey)
+	return k, nil
+}
+
+// ActiveKeyID is the key new values are wrapped with.
+func (k *Keyring) ActiveKeyID() string {
+	return k.active
+}
+
+// Encrypt seals a value under a fresh data key. Empty values are stored as
+// they are so "not set" stays distinguishable in queries.
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", err
+	}
+	wrapped, err := seal(k.keys[k.active], dataKey, []byte(k.active))
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	body, err := seal(aead, []byte(plaintext), nil)
+	if err != nil {
+		return "", err
+	}
+
+	return prefix + k.active + ":" + encode(wrapped) + ":" + encode(body), nil
+}
+
+// Decrypt opens a value written by Encrypt. Plaintext values are returned
+// unchanged.
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	keyID, wrapped, body, err := parse(value)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := k.unwrap(keyID, wrapped)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(aead, body, nil)
+	if err != nil {
+		return "", ErrMalformed
+	}
+	return string(plaintext), nil
+}
+
+// Rotate moves a value onto the active key. Encrypted values only have their
+// data key rewrapped; plaintext values are encrypted. The second result
+// reports whether the value changed.
+func (k *Keyring) Rotate(value string) (string, bool, error) {
+	if value == "" {
+		return value, false, nil
+	}
+	if !IsEncrypted(value) {
+		encrypted, err := k.Encrypt(value)
+		return encrypted, err == nil, err
+	}
+
+	keyID, wrapped, body, err := parse(value)
+	if err != nil {
+		return "", false, err
+	}
+	if keyID == k.active {
+		return value, false, nil
+	}
+
+	dataKey, err := k.unwrap(keyID, wrapped)
+	if err != nil {
+		return "", false, err
+	}
+	rewrapped, err := seal(k.keys[k.active], dataKey, []byte(k.active))
+	if err != nil {
+		return "", false, err
+	}
+
+	return prefix + k.active + ":" + encode(rewrapped) + ":" + encode(body), true, nil
+}
+
+// Index is a keyed hash of a value for equality lookups on an encrypted
+// column. It does not change when the encryption keys are rotated.
+func (k *Keyring) Index(value string) string {
+	if value == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, k.indexKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (k *Keyring) unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	kek, ok := k.keys[keyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	dataKey, err := open(kek, wrapped, []byte(keyID))
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	return dataKey, nil
+}
+
+// IsEncrypted reports whether a stored value was written by Encrypt.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+var (
+	mu      sync.RWMutex
+	current *Keyring
+)
+
+// Configure installs the keyring used by the GORM serializer. Without keys
+// encryption stays off: values are written as plaintext and reading an
+// encrypted value fails.
+func Configure(cfg *config.EncryptionConfig) error {
+	if len(cfg.Keys) == 0 {
+		Use(nil)
+		return nil
+	}
+	k, err := NewKeyring(cfg)
+	if err != nil {
+		return err
+	}
+	Use(k)
+	return nil
+}
+
+// Use installs a keyring directly.
+func Use(k *Keyring) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = k
+}
+
+// Current returns the installed keyring, or nil when encryption is off.
+func Current() *Keyring {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Index hashes a value with the installed keyring. It returns an empty
+// string when encryption is off, in which case columns are compared as
+// plaintext.
+func Index(value string) string {
+	k := Current()
+	if k == nil {
+		return ""
+	}
+	return k.Index(value)
+}
+
+func parse(value string) (string, []byte, []byte, error) {
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if !IsEncrypted(value) || len(parts) != 3 {
+		return "", nil, nil, ErrMalformed
+	}
+	wrapped, err := decode(parts[1])
+	if err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+	body, err := decode(parts[2])
+	if err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+	return parts[0], wrapped, body, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal prefixes the ciphertext with its random nonce
+func seal(aead cipher.AEAD, plaintext, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+func open(aead cipher.AEAD, sealed, additional []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additional)
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
@@ -0,0 +1,63 @@
+package fieldcrypt
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+// SerializerName is used in model tags: `gorm:"serializer:encrypted"`
+const SerializerName = "encrypted"
+
+func init() {
+	schema.RegisterSerializer(SerializerName, Serializer{})
+}
+
+// Serializer encrypts string columns on write and decrypts them on read
+// with the installed keyring. Rows still holding plaintext are read as they
+// are, so a column can be switched to encryption before its data is
+// migrated.
+type Serializer struct{}
+
+func (Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var value string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	default:
+		return fmt.Errorf("fieldcrypt: cannot scan %T into %s", dbValue, field.Name)
+	}
+
+	if IsEncrypted(value) {
+		k := Current()
+		if k == nil {
+			return ErrNotConfigured
+		}
+		plaintext, err := k.Decrypt(value)
+		if err != nil {
+			return err
+		}
+		value = plaintext
+	}
+
+	field.ReflectValueOf(ctx, dst).SetString(value)
+	return nil
+}
+
+func (Serializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	value, ok := fieldValue.(string)
+	if !ok {
+		return nil, fmt.Errorf("fieldcrypt: %s must be a string", field.Name)
+	}
+
+	k := Current()
+	if k == nil {
+		return value, nil
+	}
+	return k.Encrypt(value)
+}
@@ -0,0 +1,149 @@
+package httpclient
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const (
+	DefaultTimeout = 15 * time.Second
+	DefaultRetries = 2
+	DefaultBackoff = 200 * time.Millisecond
+)
+
+// Options describe a client for one outbound service. Zero Timeout and
+// Backoff take the defaults; Retries is used as given, so 0 means a client
+// that never retries, for callers that retry on their own.
+type Options struct {
+	// Name labels the client's log lines and trace spans
+	Name string
+	// Timeout bounds a whole request, retries included
+	Timeout time.Duration
+	// Retries is how many more times an idempotent request is sent after a
+	// connection failure, throttling or an unavailable server
+	Retries int
+	// Backoff is the wait before the first retry; it doubles for each one
+	// after that
+	Backoff time.Duration
+}
+
+// pooled is shared by every client so that connections to a host are
+// reused across providers and requests
+var pooled = &http.Transport{
+	Proxy: http.ProxyFromEnvironment,
+	DialContext: (&net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	ForceAttemptHTTP2:     true,
+	MaxIdleConns:          200,
+	MaxIdleConnsPerHost:   20,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   5 * time.Second,
+	ExpectContinueTimeout: time.Second,
+}
+
+// New returns a client that pools connections, times requests out, retries
+// idempotent requests, logs each attempt with credentials redacted and
+// records a trace span per request.
+func New(opts Options) *http.Client {
+	return &http.Client{
+		Timeout:   orDefault(opts.Timeout, DefaultTimeout),
+		Transport: newTransport(opts, pooled),
+	}
+}
+
+// NewTransport wraps base the way New does, for libraries that take a
+// transport rather than a client.
+func NewTransport(opts Options, base http.RoundTripper) http.RoundTripper {
+	return newTransport(opts, base)
+}
+
+func newTransport(opts Options, base http.RoundTripper) http.RoundTripper {
+	var rt http.RoundTripper = &logTransport{name: opts.Name, next: base}
+	if opts.Retries > 0 {
+		rt = &retryTransport{
+			next:    rt,
+			retries: opts.Retries,
+			backoff: orDefault(opts.Backoff, DefaultBackoff),
+		}
+	}
+	return otelhttp.NewTransport(rt,
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return strings.TrimSpace(opts.Name + " " + r.Method)
+		}),
+	)
+}
+
+func orDefault(d, fallback time.Duration) time.Duration {
+	if d <= 0 {
+		return fallback
+	}
+	return d
+}
+
+// retryTransport resends a request whose answer says nothing happened. Only
+// requests that are safe to repeat are resent: the idempotent methods, and
+// any request carrying an Idempotency-Key header.
+type retryTransport struct {
+	next    http.RoundTripper
+	retries int
+	backoff time.Duration
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !idempotent(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	for attempt := 0; ; attempt++ {
+		res, err := t.next.RoundTrip(req)
+		if attempt == t.retries || req.Context().Err() != nil || !retryable(res, err) {
+			return res, err
+		}
+		if req.Body != nil && req.GetBody == nil {
+			return res, err
+		}
+		if res != nil {
+			res.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(t.backoff << attempt):
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+func retryable(res *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"online-shop/pkg/logger"
+)
+
+const redacted = "[redacted]"
+
+// sensitiveHeaders carry credentials and are never logged
+var sensitiveHeaders = map[string]bool{
+	"Authorization":        true,
+	"Proxy-Authorization":  true,
+	"Cookie":               true,
+	"Set-Cookie":           true,
+	"X-Api-Key":            true,
+	"X-Amz-Security-Token": true,
+}
+
+// logTransport logs every attempt at debug level, and failures at warn.
+// Bodies are not logged: they carry customer data.
+type logTransport struct {
+	name string
+	next http.RoundTripper
+}
+
+func (t *logTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	started := time.Now()
+	res, err := t.next.RoundTrip(req)
+
+	fields := logrus.Fields{
+		"client":      t.name,
+		"method":      req.Method,
+		"url":         RedactURL(req.URL),
+		"headers":     RedactHeaders(req.Header),
+		"duration_ms": time.Since(started).Milliseconds(),
+	}
+	log := logger.WithFields(fields)
+	switch {
+	case err != nil:
+		log.WithError(err).Warn("Outbound request failed")
+	case res.StatusCode >= http.StatusInternalServerError:
+		log.WithField("status", res.StatusCode).Warn("Outbound request answered with a server error")
+	default:
+		log.WithField("status", res.StatusCode).Debug("Outbound request")
+	}
+	return res, err
+}
+
+// RedactHeaders copies h with credential headers masked
+func RedactHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for name, values := range h {
+		if sensitiveHeaders[http.CanonicalHeaderKey(name)] {
+			out[name] = redacted
+			continue
+		}
+		out[name] = strings.Join(values, ", ")
+	}
+	return out
+}
+
+// RedactURL masks userinfo passwords and query parameters that look like
+// credentials, such as access_token or api_key
+func RedactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	masked := *u
+	if masked.User != nil {
+		if _, ok := masked.User.Password(); ok {
+			masked.User = url.UserPassword(masked.User.Username(), redacted)
+		}
+	}
+	if masked.RawQuery != "" {
+		query := masked.Query()
+		for name := range query {
+			if sensitiveParam(name) {
+				query.Set(name, redacted)
+			}
+		}
+		masked.RawQuery = query.Encode()
+	}
+	return masked.String()
+}
+
+func sensitiveParam(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range []string{"token", "key", "secret", "password", "signature", "sig"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
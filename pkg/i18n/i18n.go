@@ -0,0 +1,200 @@
+// Package i18n holds the shop's translated strings and picks the locale a
+// client is served in. Strings are looked up by key, such as
+// "error.order_not_found" or "email.welcome.subject", and fall back to the
+// default locale when a locale has no translation.
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultLocale is used when nothing the client asks for is supported.
+const DefaultLocale = "en"
+
+// Messages maps keys to message templates. {name} placeholders are replaced
+// with the arguments passed to T.
+type Messages map[string]string
+
+var (
+	mu      sync.RWMutex
+	locales = map[string]Messages{}
+)
+
+// Register adds or overrides the messages of a locale.
+func Register(locale string, m Messages) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	locale = normalize(locale)
+	if locales[locale] == nil {
+		locales[locale] = Messages{}
+	}
+	for key, template := range m {
+		locales[locale][key] = template
+	}
+}
+
+// Supported reports whether locale, or its language without the region, has
+// messages.
+func Supported(locale string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return match(locale) != ""
+}
+
+// Locales lists the locales with messages.
+func Locales() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	list := make([]string, 0, len(locales))
+	for locale := range locales {
+		list = append(list, locale)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// TranslatedLocales lists the locales other than the default one: those
+// content written in the default locale, such as the catalog, is
+// translated to.
+func TranslatedLocales() []string {
+	var list []string
+	for _, locale := range Locales() {
+		if locale != DefaultLocale {
+			list = append(list, locale)
+		}
+	}
+	return list
+}
+
+// Lookup returns the template for key in locale, then in its language, then
+// in the default locale.
+func Lookup(locale, key string) (string, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	locale = normalize(locale)
+	candidates := []string{locale}
+	if lang := language(locale); lang != locale {
+		candidates = append(candidates, lang)
+	}
+	candidates = append(candidates, DefaultLocale)
+
+	for _, candidate := range candidates {
+		if template, ok := locales[candidate][key]; ok {
+			return template, true
+		}
+	}
+	return "", false
+}
+
+// T renders key in locale. args are name, value pairs for the template's
+// placeholders. A key without any translation renders as itself, so a
+// missing string is visible rather than blank.
+func T(locale, key string, args ...string) string {
+	template, ok := Lookup(locale, key)
+	if !ok {
+		return key
+	}
+	return Render(template, args...)
+}
+
+// Render replaces the {name} placeholders of template.
+func Render(template string, args ...string) string {
+	if len(args) == 0 {
+		return template
+	}
+	pairs := make([]string, 0, len(args))
+	for i := 0; i+1 < len(args); i += 2 {
+		pairs = append(pairs, "{"+args[i]+"}", args[i+1])
+	}
+	return strings.NewReplacer(pairs...).Replace(template)
+}
+
+// Negotiate picks the locale to serve. Preferred locales, such as the one a
+// user saved on their profile, win over the Accept-Language header; the
+// header's languages are tried in order of their quality values.
+func Negotiate(acceptLanguage string, preferred ...string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, locale := range preferred {
+		if m := match(locale); m != "" {
+			return m
+		}
+	}
+	for _, locale := range parseAcceptLanguage(acceptLanguage) {
+		if m := match(locale); m != "" {
+			return m
+		}
+	}
+	return DefaultLocale
+}
+
+// match returns the registered locale serving locale, if any. The caller
+// holds mu.
+func match(locale string) string {
+	locale = normalize(locale)
+	if locale == "" {
+		return ""
+	}
+	if _, ok := locales[locale]; ok {
+		return locale
+	}
+	if lang := language(locale); lang != locale {
+		if _, ok := locales[lang]; ok {
+			return lang
+		}
+	}
+	return ""
+}
+
+// parseAcceptLanguage lists the tags of the header from the most to the
+// least preferred, leaving out those with q=0.
+func parseAcceptLanguage(header string) []string {
+	type tag struct {
+		name string
+		q    float64
+	}
+	var tags []tag
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.TrimSpace(fields[0])
+		if name == "" || name == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			tags = append(tags, tag{name: name, q: q})
+		}
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	names := make([]string, len(tags))
+	for i, t := range tags {
+		names[i] = t.name
+	}
+	return names
+}
+
+// normalize lowercases a tag and uses - between its parts, so en_US, EN-us
+// and en-US are the same locale.
+func normalize(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+func language(locale string) string {
+	return strings.SplitN(locale, "-", 2)[0]
+}
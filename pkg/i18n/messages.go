@@ -0,0 +1,213 @@
+package i18n
+
+// Error titles are keyed by "error." and the error code; codes without a
+// translation keep the English catalog message. Email strings are keyed by
+// "email." and the template name, and their placeholders are the keys of the
+// email's data.
+var builtin = map[string]Messages{
+	"en": {
+		"email.signoff": "Best regards,",
+		"email.team":    "The Online Shop Team",
+
+		"email.unsubscribe.reason": "You are receiving this email because of your notification preferences.",
+		"email.unsubscribe.link":   "Unsubscribe",
+
+		"email.welcome.subject": "Welcome to Online Shop",
+		"email.welcome.heading": "Welcome {FirstName}!",
+		"email.welcome.intro":   "Thank you for joining our online shop. We're excited to have you as a customer.",
+		"email.welcome.next":    "You can now browse our products and start shopping.",
+
+		"email.order_confirmation.subject":  "Order #{OrderNumber} confirmed",
+		"email.order_confirmation.heading":  "Order Confirmation",
+		"email.order_confirmation.greeting": "Dear {CustomerName},",
+		"email.order_confirmation.intro":    "Thank you for your order! Your order #{OrderNumber} has been confirmed.",
+		"email.order_confirmation.details":  "Order Details:",
+		"email.order_confirmation.quantity": "Quantity",
+		"email.order_confirmation.total":    "Total",
+		"email.order_confirmation.shipping": "We'll send you another email when your order ships.",
+		"email.order_confirmation.delivery": "Estimated delivery: {DeliveryFrom} to {DeliveryTo}",
+
+		"email.receipt.subject":      "Receipt for your payment on order #{OrderNumber}",
+		"email.receipt.heading":      "Payment Receipt",
+		"email.receipt.greeting":     "Dear {CustomerName},",
+		"email.receipt.intro":        "We have received your payment. Please keep this receipt for your records.",
+		"email.receipt.number":       "Receipt Number",
+		"email.receipt.order_number": "Order Number",
+		"email.receipt.date":         "Date",
+		"email.receipt.method":       "Method",
+		"email.receipt.transaction":  "Transaction",
+		"email.receipt.amount":       "Amount Paid",
+		"email.receipt.outstanding":  "Still to pay on this order",
+		"email.receipt.paid_in_full": "Your order is paid in full.",
+
+		"email.invoice.subject":        "Invoice for Order #{OrderNumber}",
+		"email.invoice.heading":        "Invoice",
+		"email.invoice.invoice_number": "Invoice Number",
+		"email.invoice.order_number":   "Order Number",
+		"email.invoice.date":           "Date",
+		"email.invoice.product":        "Product",
+		"email.invoice.quantity":       "Quantity",
+		"email.invoice.unit_price":     "Unit Price",
+		"email.invoice.total":          "Total",
+		"email.invoice.subtotal":       "Subtotal",
+		"email.invoice.shipping":       "Shipping",
+		"email.invoice.total_amount":   "Total Amount",
+		"email.invoice.payments":       "Payments",
+		"email.invoice.method":         "Method",
+		"email.invoice.installment":    "Installment",
+		"email.invoice.status":         "Status",
+		"email.invoice.amount":         "Amount",
+		"email.invoice.amount_paid":    "Amount Paid",
+		"email.invoice.balance_due":    "Balance Due",
+		"email.invoice.gift_wrapping":  "Gift Wrapping",
+		"email.invoice.gift_message":   "Gift Message",
+		"email.invoice.note":           "Note",
+		"email.invoice.thanks":         "Thank you for your business!",
+
+		"email.draft_order.subject":  "Your order is ready to pay",
+		"email.draft_order.heading":  "Your Order",
+		"email.draft_order.greeting": "Dear {CustomerName},",
+		"email.draft_order.intro":    "We have put your order together. Review it and pay through the link below to place it.",
+		"email.draft_order.pay":      "Review and pay",
+		"email.draft_order.expires":  "The order can be paid until {ExpiresAt}.",
+
+		"email.draft_order_page.heading":         "Order Summary",
+		"email.draft_order_page.method":          "Payment method",
+		"email.draft_order_page.credit_card":     "Credit card",
+		"email.draft_order_page.bank_transfer":   "Bank transfer",
+		"email.draft_order_page.e_wallet":        "E-wallet",
+		"email.draft_order_page.virtual_account": "Virtual account",
+		"email.draft_order_page.pay":             "Pay now",
+		"email.draft_order_page.unavailable":     "This order can no longer be paid here.",
+
+		"email.password_reset.subject":  "Reset your password",
+		"email.password_reset.heading":  "Password Reset Request",
+		"email.password_reset.greeting": "Dear {FirstName},",
+		"email.password_reset.intro":    "You requested a password reset for your account.",
+		"email.password_reset.action":   "Click the link below to reset your password:",
+		"email.password_reset.link":     "Reset Password",
+		"email.password_reset.expiry":   "This link will expire in 24 hours.",
+		"email.password_reset.ignore":   "If you didn't request this, please ignore this email.",
+	},
+	"id": {
+		"error.internal":              "terjadi kesalahan yang tidak terduga",
+		"error.invalid_request":       "permintaan tidak valid",
+		"error.validation_failed":     "satu atau beberapa isian tidak valid",
+		"error.unauthenticated":       "autentikasi diperlukan",
+		"error.permission_denied":     "Anda tidak memiliki izin untuk melakukan ini",
+		"error.not_found":             "data tidak ditemukan",
+		"error.rate_limited":          "terlalu banyak permintaan",
+		"error.request_too_large":     "permintaan terlalu besar",
+		"error.unavailable":           "layanan sedang tidak tersedia",
+		"error.timeout":               "waktu permintaan habis",
+		"error.invalid_cursor":        "cursor tidak valid",
+		"error.auth_required":         "header Authorization wajib diisi",
+		"error.invalid_token":         "token tidak valid",
+		"error.session_revoked":       "sesi telah berakhir atau dicabut",
+		"error.user_already_exists":   "pengguna sudah terdaftar",
+		"error.user_not_found":        "pengguna tidak ditemukan",
+		"error.invalid_credentials":   "email atau kata sandi salah",
+		"error.user_inactive":         "akun pengguna tidak aktif",
+		"error.incorrect_password":    "kata sandi saat ini salah",
+		"error.product_not_found":     "produk tidak ditemukan",
+		"error.insufficient_stock":    "stok tidak mencukupi",
+		"error.category_not_found":    "kategori tidak ditemukan",
+		"error.order_not_found":       "pesanan tidak ditemukan",
+		"error.order_not_cancellable": "pesanan tidak dapat dibatalkan",
+		"error.order_access_denied":   "akses ditolak",
+		"error.payment_not_found":     "pembayaran tidak ditemukan",
+		"error.payment_failed":        "pembayaran gagal",
+		"error.payment_expired":       "pembayaran telah kedaluwarsa",
+		"error.cannot_fulfill":        "stok di semua gudang tidak mencukupi",
+		"error.download_link_invalid": "tautan unduhan tidak valid atau telah kedaluwarsa",
+
+		"email.signoff": "Salam hangat,",
+		"email.team":    "Tim Online Shop",
+
+		"email.unsubscribe.reason": "Anda menerima email ini sesuai pengaturan notifikasi Anda.",
+		"email.unsubscribe.link":   "Berhenti berlangganan",
+
+		"email.welcome.subject": "Selamat datang di Online Shop",
+		"email.welcome.heading": "Selamat datang, {FirstName}!",
+		"email.welcome.intro":   "Terima kasih telah bergabung dengan toko online kami. Kami senang Anda menjadi pelanggan kami.",
+		"email.welcome.next":    "Sekarang Anda dapat melihat produk kami dan mulai berbelanja.",
+
+		"email.order_confirmation.subject":  "Pesanan #{OrderNumber} dikonfirmasi",
+		"email.order_confirmation.heading":  "Konfirmasi Pesanan",
+		"email.order_confirmation.greeting": "Yth. {CustomerName},",
+		"email.order_confirmation.intro":    "Terima kasih atas pesanan Anda! Pesanan #{OrderNumber} telah dikonfirmasi.",
+		"email.order_confirmation.details":  "Rincian Pesanan:",
+		"email.order_confirmation.quantity": "Jumlah",
+		"email.order_confirmation.total":    "Total",
+		"email.order_confirmation.shipping": "Kami akan mengirim email lagi saat pesanan Anda dikirim.",
+		"email.order_confirmation.delivery": "Perkiraan tiba: {DeliveryFrom} sampai {DeliveryTo}",
+
+		"email.receipt.subject":      "Tanda terima pembayaran pesanan #{OrderNumber}",
+		"email.receipt.heading":      "Tanda Terima Pembayaran",
+		"email.receipt.greeting":     "Yth. {CustomerName},",
+		"email.receipt.intro":        "Kami telah menerima pembayaran Anda. Simpan tanda terima ini sebagai arsip Anda.",
+		"email.receipt.number":       "Nomor Tanda Terima",
+		"email.receipt.order_number": "Nomor Pesanan",
+		"email.receipt.date":         "Tanggal",
+		"email.receipt.method":       "Metode",
+		"email.receipt.transaction":  "Transaksi",
+		"email.receipt.amount":       "Jumlah Dibayar",
+		"email.receipt.outstanding":  "Sisa yang harus dibayar",
+		"email.receipt.paid_in_full": "Pesanan Anda telah lunas.",
+
+		"email.invoice.subject":        "Faktur untuk Pesanan #{OrderNumber}",
+		"email.invoice.heading":        "Faktur",
+		"email.invoice.invoice_number": "Nomor Faktur",
+		"email.invoice.order_number":   "Nomor Pesanan",
+		"email.invoice.date":           "Tanggal",
+		"email.invoice.product":        "Produk",
+		"email.invoice.quantity":       "Jumlah",
+		"email.invoice.unit_price":     "Harga Satuan",
+		"email.invoice.total":          "Total",
+		"email.invoice.subtotal":       "Subtotal",
+		"email.invoice.shipping":       "Ongkos Kirim",
+		"email.invoice.total_amount":   "Jumlah Total",
+		"email.invoice.payments":       "Pembayaran",
+		"email.invoice.method":         "Metode",
+		"email.invoice.installment":    "Cicilan",
+		"email.invoice.status":         "Status",
+		"email.invoice.amount":         "Nominal",
+		"email.invoice.amount_paid":    "Jumlah Dibayar",
+		"email.invoice.balance_due":    "Sisa Tagihan",
+		"email.invoice.gift_wrapping":  "Bungkus Kado",
+		"email.invoice.gift_message":   "Pesan Hadiah",
+		"email.invoice.note":           "Catatan",
+		"email.invoice.thanks":         "Terima kasih atas kepercayaan Anda!",
+
+		"email.draft_order.subject":  "Pesanan Anda siap dibayar",
+		"email.draft_order.heading":  "Pesanan Anda",
+		"email.draft_order.greeting": "Yth. {CustomerName},",
+		"email.draft_order.intro":    "Kami telah menyiapkan pesanan Anda. Periksa dan bayar melalui tautan di bawah ini untuk membuatnya.",
+		"email.draft_order.pay":      "Periksa dan bayar",
+		"email.draft_order.expires":  "Pesanan dapat dibayar hingga {ExpiresAt}.",
+
+		"email.draft_order_page.heading":         "Ringkasan Pesanan",
+		"email.draft_order_page.method":          "Metode pembayaran",
+		"email.draft_order_page.credit_card":     "Kartu kredit",
+		"email.draft_order_page.bank_transfer":   "Transfer bank",
+		"email.draft_order_page.e_wallet":        "Dompet digital",
+		"email.draft_order_page.virtual_account": "Virtual account",
+		"email.draft_order_page.pay":             "Bayar sekarang",
+		"email.draft_order_page.unavailable":     "Pesanan ini tidak dapat dibayar lagi di sini.",
+
+		"email.password_reset.subject":  "Atur ulang kata sandi Anda",
+		"email.password_reset.heading":  "Permintaan Atur Ulang Kata Sandi",
+		"email.password_reset.greeting": "Yth. {FirstName},",
+		"email.password_reset.intro":    "Anda meminta untuk mengatur ulang kata sandi akun Anda.",
+		"email.password_reset.action":   "Klik tautan di bawah ini untuk mengatur ulang kata sandi Anda:",
+		"email.password_reset.link":     "Atur Ulang Kata Sandi",
+		"email.password_reset.expiry":   "Tautan ini akan kedaluwarsa dalam 24 jam.",
+		"email.password_reset.ignore":   "Jika Anda tidak meminta ini, abaikan email ini.",
+	},
+}
+
+func init() {
+	for locale, m := range builtin {
+		Register(locale, m)
+	}
+}
@@ -0,0 +1,176 @@
+package id
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// IDs are ULID-formatted: 26 Crockford base32 characters encoding a 48-bit
+// millisecond timestamp followed by 80 bits that, here, hold a 10-bit node ID
+// and a 70-bit sequence. The sequence starts at a random value each
+// millisecond and counts up within it, so IDs from one generator sort in the
+// order they were made and IDs from different nodes never collide. Sorting
+// by time keeps new rows together at the end of primary key indexes instead
+// of scattering them the way random UUIDs do.
+
+const (
+	// MaxNode is the highest node ID that fits in an ID
+	MaxNode = 1<<nodeBits - 1
+
+	Length = 26
+
+	nodeBits = 10
+	seqBits  = 70
+	seqHiMax = 1<<(seqBits-64) - 1
+
+	alphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+)
+
+var ErrInvalid = errors.New("invalid id")
+
+var decoding = func() [256]byte {
+	var d [256]byte
+	for i := range d {
+		d[i] = 0xFF
+	}
+	for i := 0; i < len(alphabet); i++ {
+		d[alphabet[i]] = byte(i)
+	}
+	return d
+}()
+
+// Generator hands out IDs for one node. It is safe for concurrent use.
+type Generator struct {
+	mu    sync.Mutex
+	node  uint64
+	last  uint64
+	seqHi uint64
+	seqLo uint64
+	now   func() time.Time
+}
+
+func NewGenerator(node uint16) (*Generator, error) {
+	if node > MaxNode {
+		return nil, fmt.Errorf("node id %d is above %d", node, MaxNode)
+	}
+	return &Generator{node: uint64(node), now: time.Now}, nil
+}
+
+// New returns an ID that sorts after every ID this generator made before.
+// If the clock goes backwards it keeps counting on from the last
+// millisecond rather than reusing earlier ones.
+func (g *Generator) New() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(g.now().UnixMilli())
+	switch {
+	case ms > g.last:
+		g.last = ms
+		g.reseed()
+	case g.seqLo == ^uint64(0) && g.seqHi == seqHiMax:
+		// the sequence ran out within a millisecond; borrow the next one
+		g.last++
+		g.reseed()
+	default:
+		g.seqLo++
+		if g.seqLo == 0 {
+			g.seqHi++
+		}
+	}
+
+	hi := g.last<<16 | g.node<<(seqBits-64) | g.seqHi
+	return encode(hi, g.seqLo)
+}
+
+// reseed starts the sequence at a random value with its top bit clear, which
+// leaves room to count up within the millisecond
+func (g *Generator) reseed() {
+	var b [10]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand does not fail on supported platforms; a zero start
+		// still yields unique, ordered IDs
+		b = [10]byte{}
+	}
+	g.seqHi = uint64(b[0]) & (seqHiMax >> 1)
+	g.seqLo = binary.BigEndian.Uint64(b[2:])
+}
+
+func encode(hi, lo uint64) string {
+	var out [Length]byte
+	for i := 0; i < Length; i++ {
+		shift := uint(5 * (Length - 1 - i))
+		var v uint64
+		switch {
+		case shift >= 64:
+			v = hi >> (shift - 64)
+		case shift+5 > 64:
+			v = lo>>shift | hi<<(64-shift)
+		default:
+			v = lo >> shift
+		}
+		out[i] = alphabet[v&31]
+	}
+	return string(out[:])
+}
+
+func decode(s string) (hi, lo uint64, err error) {
+	if len(s) != Length || s[0] > '7' {
+		return 0, 0, ErrInvalid
+	}
+	for i := 0; i < Length; i++ {
+		v := decoding[s[i]]
+		if v == 0xFF {
+			return 0, 0, ErrInvalid
+		}
+		hi = hi<<5 | lo>>59
+		lo = lo<<5 | uint64(v)
+	}
+	return hi, lo, nil
+}
+
+// Valid reports whether s is an ID in this package's format
+func Valid(s string) bool {
+	_, _, err := decode(s)
+	return err == nil
+}
+
+// Time returns the millisecond an ID was made in
+func Time(s string) (time.Time, error) {
+	hi, _, err := decode(s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(int64(hi >> 16)), nil
+}
+
+// Node returns the node ID an ID was made on
+func Node(s string) (uint16, error) {
+	hi, _, err := decode(s)
+	if err != nil {
+		return 0, err
+	}
+	return uint16(hi >> (seqBits - 64) & MaxNode), nil
+}
+
+var defaultGenerator, _ = NewGenerator(0)
+
+// SetNode sets the node ID used by New. Call it once at startup, before
+// any IDs are made; every instance writing to the same tables needs its own.
+func SetNode(node uint16) error {
+	g, err := NewGenerator(node)
+	if err != nil {
+		return err
+	}
+	defaultGenerator = g
+	return nil
+}
+
+// New returns an ID from the process-wide generator
+func New() string {
+	return defaultGenerator.New()
+}
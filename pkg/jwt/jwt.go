@@ -2,79 +2,253 @@ package jwt
 
 import (
 	"errors"
+	"sync"
 	"time"
 
+	"online-shop/pkg/config"
+
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/google/uuid"
+	"online-shop/pkg/id"
 )
 
+type TokenType string
+
+const (
+	TokenTypeAccess  TokenType = "access"
+	TokenTypeRefresh TokenType = "refresh"
+)
+
+var (
+	ErrInvalidToken   = errors.New("invalid token")
+	ErrWrongTokenType = errors.New("wrong token type")
+)
+
+// Claims are shared by both token types. Refresh tokens only carry the user
+// ID, so the role is always read fresh from the user when they are redeemed.
 type Claims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
-	Role   string `json:"role"`
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email,omitempty"`
+	Role      string    `json:"role,omitempty"`
+	TokenType TokenType `json:"token_type,omitempty"`
+	SessionID string    `json:"sid,omitempty"`
+	// Actor is set on tokens a support admin acts as the user with
+	Actor *Actor `json:"act,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// Actor is who really holds a token issued for another user, named after
+// the act claim of RFC 8693. GrantID is the impersonation the token belongs
+// to, and Scope what it allows.
+type Actor struct {
+	Subject string `json:"sub"`
+	GrantID string `json:"grant_id"`
+	Scope   string `json:"scope"`
+}
+
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
 type JWTManager struct {
-	secretKey   string
-	expiryHours int
+	mu            sync.RWMutex
+	keys          *keySet
+	issuer        string
+	accessExpiry  time.Duration
+	refreshExpiry time.Duration
 }
 
-func NewJWTManager(secretKey string, expiryHours int) *JWTManager {
-	return &JWTManager{
-		secretKey:   secretKey,
-		expiryHours: expiryHours,
+func NewJWTManager(cfg *config.JWTConfig) (*JWTManager, error) {
+	keys, err := loadKeySet(cfg)
+	if err != nil {
+		return nil, err
 	}
+
+	return &JWTManager{
+		keys:          keys,
+		issuer:        cfg.Issuer,
+		accessExpiry:  time.Duration(cfg.ExpiryHours) * time.Hour,
+		refreshExpiry: time.Duration(cfg.RefreshExpiryHours) * time.Hour,
+	}, nil
 }
 
+// GenerateToken issues an access token that is not bound to a session. The
+// auth middleware rejects such tokens once sessions are enabled.
 func (j *JWTManager) GenerateToken(userID, email, role string) (string, error) {
-	claims := &Claims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ID:        uuid.New().String(),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(j.expiryHours) * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "online-shop",
-			Subject:   userID,
-		},
+	return j.sign(j.accessClaims(userID, email, role, ""))
+}
+
+func (j *JWTManager) GenerateRefreshToken(userID string) (string, error) {
+	return j.sign(j.refreshClaims(userID, ""))
+}
+
+// GenerateTokenPair issues both tokens for a session. Revoking the session
+// invalidates them together.
+func (j *JWTManager) GenerateTokenPair(userID, email, role, sessionID string) (*TokenPair, error) {
+	accessToken, err := j.sign(j.accessClaims(userID, email, role, sessionID))
+	if err != nil {
+		return nil, err
 	}
+	refreshToken, err := j.sign(j.refreshClaims(userID, sessionID))
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(j.accessExpiry.Seconds()),
+	}, nil
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(j.secretKey))
+// GenerateImpersonationToken issues an access token for the user that is held
+// by actor until expiresAt. There is no refresh token; the admin asks for a
+// new impersonation once it expires.
+func (j *JWTManager) GenerateImpersonationToken(userID, email, role string, actor Actor, expiresAt time.Time) (string, error) {
+	claims := j.accessClaims(userID, email, role, "")
+	claims.Actor = &actor
+	claims.ExpiresAt = jwt.NewNumericDate(expiresAt)
+	return j.sign(claims)
 }
 
+// RefreshExpiry is how long a refresh token, and so a session, lasts
+func (j *JWTManager) RefreshExpiry() time.Duration {
+	return j.refreshExpiry
+}
+
+// ValidateToken accepts access tokens only. Tokens issued before token types
+// existed have none and are treated as access tokens.
 func (j *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(j.secretKey), nil
-	})
+	claims, err := j.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != TokenTypeAccess && claims.TokenType != "" {
+		return nil, ErrWrongTokenType
+	}
+	return claims, nil
+}
 
+func (j *JWTManager) ValidateRefreshToken(tokenString string) (*Claims, error) {
+	claims, err := j.parse(tokenString)
 	if err != nil {
 		return nil, err
 	}
+	if claims.TokenType != TokenTypeRefresh {
+		return nil, ErrWrongTokenType
+	}
+	return claims, nil
+}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
+// JWKS returns the public keys other services use to validate tokens
+func (j *JWTManager) JWKS() JWKS {
+	return j.keySet().jwks()
+}
+
+// SetSecret replaces the shared secret after it was rotated in the secrets
+// store. Tokens signed with the previous secret stay valid until they expire
+// or the secret is rotated again.
+func (j *JWTManager) SetSecret(secret string) {
+	if secret == "" {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.keys.legacy != nil && string(j.keys.legacy.verifyKey.([]byte)) == secret {
+		return
+	}
+	j.keys = j.keys.withSecret([]byte(secret))
+}
+
+func (j *JWTManager) keySet() *keySet {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.keys
+}
+
+func (j *JWTManager) accessClaims(userID, email, role, sessionID string) *Claims {
+	return &Claims{
+		UserID:           userID,
+		Email:            email,
+		Role:             role,
+		TokenType:        TokenTypeAccess,
+		SessionID:        sessionID,
+		RegisteredClaims: j.registeredClaims(userID, j.accessExpiry),
 	}
+}
 
-	return nil, errors.New("invalid token")
+func (j *JWTManager) refreshClaims(userID, sessionID string) *Claims {
+	return &Claims{
+		UserID:           userID,
+		TokenType:        TokenTypeRefresh,
+		SessionID:        sessionID,
+		RegisteredClaims: j.registeredClaims(userID, j.refreshExpiry),
+	}
+}
+
+func (j *JWTManager) registeredClaims(userID string, expiry time.Duration) jwt.RegisteredClaims {
+	now := time.Now()
+	return jwt.RegisteredClaims{
+		ID:        id.New(),
+		ExpiresAt: jwt.NewNumericDate(now.Add(expiry)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		Issuer:    j.issuer,
+		Subject:   userID,
+	}
 }
 
-func (j *JWTManager) RefreshToken(tokenString string) (string, error) {
-	claims, err := j.ValidateToken(tokenString)
+func (j *JWTManager) sign(claims *Claims) (string, error) {
+	key := j.keySet().active
+	token := jwt.NewWithClaims(key.method, claims)
+	if key.id != "" {
+		token.Header["kid"] = key.id
+	}
+	return token.SignedString(key.signKey)
+}
+
+func (j *JWTManager) parse(tokenString string) (*Claims, error) {
+	keys := j.keySet()
+	claims, err := j.parseWith(tokenString, keys, keys.legacy)
+	// Tokens without a kid may be signed with the secret used before the
+	// last rotation
+	if errors.Is(err, jwt.ErrTokenSignatureInvalid) && keys.retired != nil {
+		return j.parseWith(tokenString, keys, keys.retired)
+	}
+	return claims, err
+}
+
+// parseWith verifies tokens that carry no kid with the given shared secret
+func (j *JWTManager) parseWith(tokenString string, keys *keySet, secret *signingKey) (*Claims, error) {
+	options := []jwt.ParserOption{jwt.WithValidMethods(keys.methods())}
+	if j.issuer != "" {
+		options = append(options, jwt.WithIssuer(j.issuer))
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := keys.lookup(kid)
+		if err != nil {
+			return nil, err
+		}
+		if kid == "" {
+			key = secret
+		}
+		// A key only verifies tokens made with its own algorithm, so a public
+		// key can never be used as an HMAC secret
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, errors.New("unexpected signing method")
+		}
+		return key.verifyKey, nil
+	}, options...)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// Check if token is close to expiry (within 1 hour)
-	if time.Until(claims.ExpiresAt.Time) > time.Hour {
-		return tokenString, nil // Token is still valid for more than 1 hour
+	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+		return claims, nil
 	}
 
-	return j.GenerateToken(claims.UserID, claims.Email, claims.Role)
-}
\ No newline at end of file
+	return nil, ErrInvalidToken
+}
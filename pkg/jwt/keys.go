@@ -0,0 +1,237 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"online-shop/pkg/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// JWK is a public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+type signingKey struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+	public    crypto.PublicKey
+}
+
+// keySet holds the key new tokens are signed with and every key whose tokens
+// are still accepted. The shared secret, when configured, has no key ID and
+// verifies tokens that carry no kid header, which is how tokens were issued
+// before asymmetric keys were introduced.
+type keySet struct {
+	active *signingKey
+	byID   map[string]*signingKey
+	legacy *signingKey
+	// retired is the shared secret replaced by the last rotation; it still
+	// verifies tokens signed before the rotation
+	retired *signingKey
+}
+
+func loadKeySet(cfg *config.JWTConfig) (*keySet, error) {
+	set := &keySet{byID: make(map[string]*signingKey)}
+
+	if cfg.SecretKey != "" {
+		secret := []byte(cfg.SecretKey)
+		set.legacy = &signingKey{method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}
+	}
+
+	for _, keyCfg := range cfg.Keys {
+		key, err := loadKey(keyCfg)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %q: %w", keyCfg.ID, err)
+		}
+		if _, exists := set.byID[key.id]; exists {
+			return nil, fmt.Errorf("jwt key %q is configured twice", key.id)
+		}
+		set.byID[key.id] = key
+
+		if cfg.ActiveKeyID == "" && set.active == nil && key.signKey != nil {
+			set.active = key
+		}
+	}
+
+	if cfg.ActiveKeyID != "" {
+		key, ok := set.byID[cfg.ActiveKeyID]
+		if !ok {
+			return nil, fmt.Errorf("active jwt key %q is not configured", cfg.ActiveKeyID)
+		}
+		if key.signKey == nil {
+			return nil, fmt.Errorf("active jwt key %q has no private key", cfg.ActiveKeyID)
+		}
+		set.active = key
+	}
+
+	if set.active == nil {
+		set.active = set.legacy
+	}
+	if set.active == nil {
+		return nil, errors.New("jwt requires a secret key or a signing key")
+	}
+	return set, nil
+}
+
+func loadKey(cfg config.JWTKeyConfig) (*signingKey, error) {
+	if cfg.ID == "" {
+		return nil, errors.New("id is required")
+	}
+	if cfg.PrivateKeyPath == "" && cfg.PublicKeyPath == "" {
+		return nil, errors.New("private_key_path or public_key_path is required")
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+	if cfg.PrivateKeyPath != "" {
+		data, err = os.ReadFile(cfg.PrivateKeyPath)
+	} else {
+		data, err = os.ReadFile(cfg.PublicKeyPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	hasPrivate := cfg.PrivateKeyPath != ""
+
+	key := &signingKey{id: cfg.ID}
+	switch cfg.Algorithm {
+	case AlgorithmRS256:
+		key.method = jwt.SigningMethodRS256
+		if hasPrivate {
+			private, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			key.signKey = private
+			key.public = &private.PublicKey
+		} else if key.public, err = jwt.ParseRSAPublicKeyFromPEM(data); err != nil {
+			return nil, err
+		}
+	case AlgorithmEdDSA:
+		key.method = jwt.SigningMethodEdDSA
+		if hasPrivate {
+			private, err := jwt.ParseEdPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			key.signKey = private
+			key.public = private.(ed25519.PrivateKey).Public()
+		} else if key.public, err = jwt.ParseEdPublicKeyFromPEM(data); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", cfg.Algorithm)
+	}
+
+	key.verifyKey = key.public
+	return key, nil
+}
+
+func (s *keySet) lookup(kid string) (*signingKey, error) {
+	if kid == "" {
+		if s.legacy == nil {
+			return nil, ErrUnknownKey
+		}
+		return s.legacy, nil
+	}
+	key, ok := s.byID[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// withSecret returns a copy of the set using a new shared secret. If the
+// secret was signing new tokens, the new one takes over.
+func (s *keySet) withSecret(secret []byte) *keySet {
+	next := *s
+	next.legacy = &signingKey{method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}
+	next.retired = s.legacy
+	if s.active == s.legacy {
+		next.active = next.legacy
+	}
+	return &next
+}
+
+func (s *keySet) methods() []string {
+	seen := make(map[string]bool)
+	var methods []string
+	add := func(key *signingKey) {
+		if key != nil && !seen[key.method.Alg()] {
+			seen[key.method.Alg()] = true
+			methods = append(methods, key.method.Alg())
+		}
+	}
+	add(s.legacy)
+	for _, key := range s.byID {
+		add(key)
+	}
+	return methods
+}
+
+// jwks publishes the asymmetric keys; the shared secret is never exposed
+func (s *keySet) jwks() JWKS {
+	ids := make([]string, 0, len(s.byID))
+	for id := range s.byID {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	set := JWKS{Keys: []JWK{}}
+	for _, id := range ids {
+		key := s.byID[id]
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "RSA",
+				Use: "sig",
+				Alg: AlgorithmRS256,
+				Kid: key.id,
+				N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "OKP",
+				Use: "sig",
+				Alg: AlgorithmEdDSA,
+				Kid: key.id,
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+	return set
+}
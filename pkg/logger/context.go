@@ -0,0 +1,91 @@
+package logger
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// scope holds the request-scoped fields added to entries logged for a
+// request. The user ID is only known once the caller is authenticated, so
+// it is filled in later on the same scope.
+type scope struct {
+	mu        sync.Mutex
+	requestID string
+	traceID   string
+	userID    string
+}
+
+type scopeKey struct{}
+
+// NewContext returns ctx carrying the request's ID and, when the caller sent
+// one, its trace ID
+func NewContext(ctx context.Context, requestID, traceID string) context.Context {
+	return context.WithValue(ctx, scopeKey{}, &scope{requestID: requestID, traceID: traceID})
+}
+
+// SetUserID adds the authenticated user to the request's entries
+func SetUserID(ctx context.Context, userID string) {
+	if s, ok := ctx.Value(scopeKey{}).(*scope); ok {
+		s.mu.Lock()
+		s.userID = userID
+		s.mu.Unlock()
+	}
+}
+
+// TraceID reads the trace ID of a W3C traceparent header, empty when the
+// header is malformed
+func TraceID(traceparent string) string {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 {
+		return ""
+	}
+	if _, err := trace.TraceIDFromHex(parts[1]); err != nil {
+		return ""
+	}
+	return parts[1]
+}
+
+// Fields returns the request-scoped fields of ctx. A trace ID of an active
+// span wins over the one the caller sent.
+func Fields(ctx context.Context) []zap.Field {
+	var fields []zap.Field
+	traceID := ""
+	if s, ok := ctx.Value(scopeKey{}).(*scope); ok {
+		s.mu.Lock()
+		if s.requestID != "" {
+			fields = append(fields, zap.String("request_id", s.requestID))
+		}
+		if s.userID != "" {
+			fields = append(fields, zap.String("user_id", s.userID))
+		}
+		traceID = s.traceID
+		s.mu.Unlock()
+	}
+	if span := trace.SpanContextFromContext(ctx); span.HasTraceID() {
+		traceID = span.TraceID().String()
+	}
+	if traceID != "" {
+		fields = append(fields, zap.String("trace_id", traceID))
+	}
+	return fields
+}
+
+// FromContext returns the zap logger with the request-scoped fields of ctx
+func FromContext(ctx context.Context) *zap.Logger {
+	return Zap().With(Fields(ctx)...)
+}
+
+// EntryFromContext returns a logrus entry with the request-scoped fields of
+// ctx
+func EntryFromContext(ctx context.Context) *logrus.Entry {
+	fields := logrus.Fields{}
+	for _, f := range Fields(ctx) {
+		fields[f.Key] = f.String
+	}
+	return GetLogger().WithFields(fields)
+}
@@ -4,132 +4,254 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"gopkg.in/natefinch/lumberjack.v2"
 	"online-shop/pkg/config"
 )
 
+// Log is the logrus-compatible logger for code written against logrus. Its
+// entries go through the same zap core as Zap's.
 var Log *logrus.Logger
 
-// Init initializes the logger with configuration
+var (
+	mu      sync.Mutex
+	zapLog  *zap.Logger
+	level   = zap.NewAtomicLevel()
+	rotator *lumberjack.Logger
+)
+
+// Init initializes the logger with configuration. Every binary calls it once
+// its config is loaded; until then the default config is used.
 func Init(cfg *config.LoggerConfig) error {
-	Log = logrus.New()
+	z, file, err := New(cfg, level)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	zapLog, rotator = z, file
+	Log = NewLogrus(z)
+	Log.SetLevel(logrusLevel(level.Level()))
+	mu.Unlock()
+
+	z.Info("Logger initialized successfully",
+		zap.String("level", cfg.Level),
+		zap.String("format", cfg.Format),
+		zap.String("output", cfg.Output))
+
+	return nil
+}
 
+// New builds a zap logger from cfg whose level follows lvl. The lumberjack
+// logger of file output is returned so it can be rotated, nil otherwise.
+func New(cfg *config.LoggerConfig, lvl zap.AtomicLevel) (*zap.Logger, *lumberjack.Logger, error) {
 	// Set log level
-	level, err := logrus.ParseLevel(cfg.Level)
+	parsed, err := parseLevel(cfg.Level)
 	if err != nil {
-		level = logrus.InfoLevel
-	}
-	Log.SetLevel(level)
-
-	// Set formatter
-	switch strings.ToLower(cfg.Format) {
-	case "json":
-		Log.SetFormatter(&logrus.JSONFormatter{
-			TimestampFormat: "2006-01-02 15:04:05",
-		})
-	case "text":
-		Log.SetFormatter(&logrus.TextFormatter{
-			FullTimestamp:   true,
-			TimestampFormat: "2006-01-02 15:04:05",
-		})
-	default:
-		Log.SetFormatter(&logrus.JSONFormatter{
-			TimestampFormat: "2006-01-02 15:04:05",
-		})
+		parsed = zapcore.InfoLevel
 	}
+	lvl.SetLevel(parsed)
 
 	// Set output
+	var file *lumberjack.Logger
+	var out io.Writer
 	switch strings.ToLower(cfg.Output) {
-	case "stdout":
-		Log.SetOutput(os.Stdout)
 	case "stderr":
-		Log.SetOutput(os.Stderr)
+		out = os.Stderr
 	case "file":
-		if err := setupFileOutput(cfg); err != nil {
-			return fmt.Errorf("failed to setup file output: %w", err)
+		if file, err = fileOutput(cfg); err != nil {
+			return nil, nil, fmt.Errorf("failed to setup file output: %w", err)
 		}
+		out = file
 	case "both":
-		if err := setupBothOutput(cfg); err != nil {
-			return fmt.Errorf("failed to setup both output: %w", err)
+		if file, err = fileOutput(cfg); err != nil {
+			return nil, nil, fmt.Errorf("failed to setup both output: %w", err)
 		}
+		out = io.MultiWriter(os.Stdout, file)
 	default:
-		Log.SetOutput(os.Stdout)
+		out = os.Stdout
 	}
 
-	Log.Info("Logger initialized successfully",
-		logrus.Fields{
-			"level":  cfg.Level,
-			"format": cfg.Format,
-			"output": cfg.Output,
-		})
+	// PII is redacted before entries are encoded; repeated entries are
+	// sampled but errors are never dropped
+	var core zapcore.Core = redactCore{
+		Core:     zapcore.NewCore(encoder(cfg.Format), zapcore.Lock(zapcore.AddSync(out)), lvl),
+		redactor: newRedactor(&cfg.Redaction),
+	}
+	if cfg.Sampling.Initial > 0 {
+		core = errorsUnsampled{
+			Core:   zapcore.NewSamplerWithOptions(core, time.Second, cfg.Sampling.Initial, cfg.Sampling.Thereafter),
+			errors: core,
+		}
+	}
 
-	return nil
+	options := []zap.Option{zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel)}
+	// Tag entries with the emitting service
+	if cfg.Service != "" {
+		options = append(options, zap.Fields(zap.String("service", cfg.Service)))
+	}
+	return zap.New(core, options...), file, nil
+}
+
+// encoder writes the keys the admin log search reads: time, level and msg
+func encoder(format string) zapcore.Encoder {
+	encoderConfig := zapcore.EncoderConfig{
+		TimeKey:        "time",
+		LevelKey:       "level",
+		NameKey:        "logger",
+		CallerKey:      "caller",
+		MessageKey:     "msg",
+		StacktraceKey:  "stacktrace",
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    zapcore.LowercaseLevelEncoder,
+		EncodeTime:     zapcore.RFC3339NanoTimeEncoder,
+		EncodeDuration: zapcore.MillisDurationEncoder,
+		EncodeCaller:   zapcore.ShortCallerEncoder,
+	}
+	if strings.ToLower(format) == "text" {
+		encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		encoderConfig.EncodeTime = zapcore.TimeEncoderOfLayout("2006-01-02 15:04:05")
+		return zapcore.NewConsoleEncoder(encoderConfig)
+	}
+	return zapcore.NewJSONEncoder(encoderConfig)
+}
+
+// errorsUnsampled samples entries below error level only
+type errorsUnsampled struct {
+	zapcore.Core
+	errors zapcore.Core
+}
+
+func (c errorsUnsampled) With(fields []zapcore.Field) zapcore.Core {
+	return errorsUnsampled{Core: c.Core.With(fields), errors: c.errors.With(fields)}
+}
+
+func (c errorsUnsampled) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if entry.Level >= zapcore.ErrorLevel {
+		return c.errors.Check(entry, checked)
+	}
+	return c.Core.Check(entry, checked)
 }
 
-// setupFileOutput configures file output with rotation
-func setupFileOutput(cfg *config.LoggerConfig) error {
+// fileOutput configures file output with rotation
+func fileOutput(cfg *config.LoggerConfig) (*lumberjack.Logger, error) {
 	// Ensure log directory exists
 	logDir := filepath.Dir(cfg.FilePath)
 	if err := os.MkdirAll(logDir, 0755); err != nil {
-		return fmt.Errorf("failed to create log directory: %w", err)
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
 	}
 
 	// Setup lumberjack for log rotation
-	lumberjackLogger := &lumberjack.Logger{
+	return &lumberjack.Logger{
 		Filename:   cfg.FilePath,
-		MaxSize:    cfg.MaxSize,    // megabytes
+		MaxSize:    cfg.MaxSize, // megabytes
 		MaxBackups: cfg.MaxBackups,
-		MaxAge:     cfg.MaxAge,     // days
+		MaxAge:     cfg.MaxAge, // days
 		Compress:   cfg.Compress,
+	}, nil
+}
+
+// SetLevel changes the level of the initialized logger, e.g. after the
+// config was reloaded
+func SetLevel(lvl string) error {
+	parsed, err := parseLevel(lvl)
+	if err != nil {
+		return err
 	}
+	level.SetLevel(parsed)
 
-	Log.SetOutput(lumberjackLogger)
+	mu.Lock()
+	defer mu.Unlock()
+	if Log != nil {
+		Log.SetLevel(logrusLevel(parsed))
+	}
 	return nil
 }
 
-// setupBothOutput configures output to both stdout and file
-func setupBothOutput(cfg *config.LoggerConfig) error {
-	// Ensure log directory exists
-	logDir := filepath.Dir(cfg.FilePath)
-	if err := os.MkdirAll(logDir, 0755); err != nil {
-		return fmt.Errorf("failed to create log directory: %w", err)
+// parseLevel also takes the logrus names zap has no level for
+func parseLevel(lvl string) (zapcore.Level, error) {
+	switch strings.ToLower(lvl) {
+	case "trace":
+		return zapcore.DebugLevel, nil
+	case "warning":
+		return zapcore.WarnLevel, nil
 	}
+	return zapcore.ParseLevel(lvl)
+}
 
-	// Setup lumberjack for log rotation
-	lumberjackLogger := &lumberjack.Logger{
-		Filename:   cfg.FilePath,
-		MaxSize:    cfg.MaxSize,
-		MaxBackups: cfg.MaxBackups,
-		MaxAge:     cfg.MaxAge,
-		Compress:   cfg.Compress,
+// Rotate starts a new log file, when logging to one
+func Rotate() error {
+	mu.Lock()
+	file := rotator
+	mu.Unlock()
+	if file == nil {
+		return nil
 	}
+	return file.Rotate()
+}
 
-	// Create multi-writer for both stdout and file
-	multiWriter := io.MultiWriter(os.Stdout, lumberjackLogger)
-	Log.SetOutput(multiWriter)
-	return nil
+// RotateOn rotates the log file whenever one of the signals is received, so
+// external tools such as logrotate can ask for it with SIGHUP
+func RotateOn(signals ...os.Signal) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+	go func() {
+		for range ch {
+			if err := Rotate(); err != nil {
+				Zap().Error("Failed to rotate log file", zap.Error(err))
+			}
+		}
+	}()
 }
 
-// GetLogger returns the logger instance
-func GetLogger() *logrus.Logger {
-	if Log == nil {
+// Sync flushes buffered entries; binaries defer it in main
+func Sync() error {
+	return Zap().Sync()
+}
+
+// defaultConfig is used when logging starts before the config is loaded
+var defaultConfig = &config.LoggerConfig{
+	Level:      "info",
+	Format:     "json",
+	Output:     "stdout",
+	FilePath:   "./logs/app.log",
+	MaxSize:    100,
+	MaxBackups: 3,
+	MaxAge:     28,
+	Compress:   true,
+}
+
+func initDefault() {
+	mu.Lock()
+	initialized := zapLog != nil
+	mu.Unlock()
+	if !initialized {
 		// Initialize with default config if not initialized
-		defaultConfig := &config.LoggerConfig{
-			Level:      "info",
-			Format:     "json",
-			Output:     "stdout",
-			FilePath:   "./logs/app.log",
-			MaxSize:    100,
-			MaxBackups: 3,
-			MaxAge:     28,
-			Compress:   true,
-		}
-		Init(defaultConfig)
+		_ = Init(defaultConfig)
 	}
+}
+
+// Zap returns the zap logger
+func Zap() *zap.Logger {
+	initDefault()
+	mu.Lock()
+	defer mu.Unlock()
+	return zapLog
+}
+
+// GetLogger returns the logrus-compatible logger instance
+func GetLogger() *logrus.Logger {
+	initDefault()
+	mu.Lock()
+	defer mu.Unlock()
 	return Log
 }
 
@@ -206,4 +328,4 @@ func Panic(args ...interface{}) {
 // Panicf logs a formatted panic message and panics
 func Panicf(format string, args ...interface{}) {
 	GetLogger().Panicf(format, args...)
-}
\ No newline at end of file
+}
@@ -0,0 +1,95 @@
+package logger
+
+import (
+	"io"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// NewLogrus returns a logrus logger whose entries are written by z, so code
+// using the logrus API shares the zap core's encoding, output and sampling
+func NewLogrus(z *zap.Logger) *logrus.Logger {
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	l.SetFormatter(discardFormatter{})
+	l.AddHook(zapHook{core: z.Core()})
+	return l
+}
+
+// zapHook writes logrus entries to a zap core
+type zapHook struct {
+	core zapcore.Core
+}
+
+func (h zapHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h zapHook) Fire(entry *logrus.Entry) error {
+	// Checking the core directly leaves exiting and panicking to logrus
+	checked := h.core.Check(zapcore.Entry{
+		Level:   zapLevel(entry.Level),
+		Time:    entry.Time,
+		Message: entry.Message,
+	}, nil)
+	if checked == nil {
+		return nil
+	}
+
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	fields := make([]zap.Field, 0, len(keys))
+	for _, key := range keys {
+		fields = append(fields, zap.Any(key, entry.Data[key]))
+	}
+	checked.Write(fields...)
+	return nil
+}
+
+// discardFormatter skips formatting entries the hook has already written
+type discardFormatter struct{}
+
+func (discardFormatter) Format(*logrus.Entry) ([]byte, error) {
+	return nil, nil
+}
+
+func zapLevel(level logrus.Level) zapcore.Level {
+	switch level {
+	case logrus.PanicLevel:
+		return zapcore.PanicLevel
+	case logrus.FatalLevel:
+		return zapcore.FatalLevel
+	case logrus.ErrorLevel:
+		return zapcore.ErrorLevel
+	case logrus.WarnLevel:
+		return zapcore.WarnLevel
+	case logrus.InfoLevel:
+		return zapcore.InfoLevel
+	default:
+		return zapcore.DebugLevel
+	}
+}
+
+// logrusLevel keeps logrus from building entries zap would drop
+func logrusLevel(level zapcore.Level) logrus.Level {
+	switch level {
+	case zapcore.DebugLevel:
+		return logrus.TraceLevel
+	case zapcore.InfoLevel:
+		return logrus.InfoLevel
+	case zapcore.WarnLevel:
+		return logrus.WarnLevel
+	case zapcore.ErrorLevel:
+		return logrus.ErrorLevel
+	case zapcore.FatalLevel:
+		return logrus.FatalLevel
+	default:
+		return logrus.PanicLevel
+	}
+}
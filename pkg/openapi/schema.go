@@ -0,0 +1,309 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     bool               `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	rawType     = reflect.TypeOf(json.RawMessage{})
+	marshalType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// Generator turns Go types into schemas the way encoding/json encodes them.
+// Each named struct becomes a component, named by its package and type as
+// in order.Order, and is referred to from wherever it is used.
+//
+// Fields required by their validate (or binding) tag are listed as
+// required, and the min, max, len, gt, gte, lt, lte, oneof, email, url and
+// notblank rules become the matching constraints.
+type Generator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func NewGenerator() *Generator {
+	return &Generator{schemas: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+}
+
+// Schema returns the schema of v's type.
+func (g *Generator) Schema(v interface{}) *Schema {
+	if v == nil {
+		return &Schema{}
+	}
+	return g.schema(reflect.TypeOf(v))
+}
+
+// Schemas are the components generated so far.
+func (g *Generator) Schemas() map[string]*Schema {
+	return g.schemas
+}
+
+// Define adds a component that is not generated from a type.
+func (g *Generator) Define(name string, s *Schema) *Schema {
+	g.schemas[name] = s
+	return &Schema{Ref: SchemaRef(name)}
+}
+
+func (g *Generator) schema(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Ptr {
+		// A reference cannot be marked nullable; a nil struct is only
+		// written where the field says so
+		s := g.schema(t.Elem())
+		if s.Ref == "" {
+			s.Nullable = true
+		}
+		return s
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawType:
+		return &Schema{}
+	case t.Implements(marshalType) || reflect.PtrTo(t).Implements(marshalType):
+		// Encodes itself, so nothing is known of its shape
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return g.component(t)
+	}
+	// Interfaces hold anything
+	return &Schema{}
+}
+
+func (g *Generator) component(t reflect.Type) *Schema {
+	name, ok := g.names[t]
+	if !ok {
+		name = path.Base(t.PkgPath()) + "." + t.Name()
+		for i := 2; g.schemas[name] != nil; i++ {
+			name = fmt.Sprintf("%s.%s%d", path.Base(t.PkgPath()), t.Name(), i)
+		}
+		g.names[t] = name
+		// Reserved before the fields are read, for types that contain
+		// themselves
+		g.schemas[name] = &Schema{Type: "object"}
+		*g.schemas[name] = *g.object(t)
+	}
+	return &Schema{Ref: SchemaRef(name)}
+}
+
+func (g *Generator) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.fields(t, s)
+	return s
+}
+
+func (g *Generator) fields(t reflect.Type, s *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if j := strings.Index(tag, ","); j >= 0 {
+			name, opts = tag[:j], tag[j:]
+		}
+
+		if f.Anonymous && name == "" {
+			et := f.Type
+			if et.Kind() == reflect.Ptr {
+				et = et.Elem()
+			}
+			if et.Kind() == reflect.Struct {
+				g.fields(et, s)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		var field *Schema
+		if strings.Contains(opts, ",string") {
+			field = &Schema{Type: "string"}
+		} else if strings.Contains(opts, ",omitempty") && f.Type.Kind() == reflect.Ptr {
+			// Left out rather than null when unset
+			field = g.schema(f.Type.Elem())
+		} else if f.Type.Kind() == reflect.Ptr && f.Type.Elem().Kind() == reflect.Struct && f.Type.Elem() != timeType {
+			field = &Schema{AllOf: []*Schema{g.schema(f.Type)}, Nullable: true}
+		} else {
+			field = g.schema(f.Type)
+		}
+
+		rules := f.Tag.Get("validate")
+		if rules == "" {
+			rules = f.Tag.Get("binding")
+		}
+		if constrain(field, f.Type, rules) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = field
+	}
+}
+
+// constrain applies validation rules to a field's schema and reports
+// whether they require it. Rules after dive apply to the elements.
+func constrain(s *Schema, t reflect.Type, rules string) bool {
+	if rules == "" {
+		return false
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	// Constraints next to a reference are ignored by readers
+	ref := s.Ref != "" || len(s.AllOf) > 0
+
+	required := false
+	list := strings.Split(rules, ",")
+	for i, rule := range list {
+		name, param := rule, ""
+		if j := strings.Index(rule, "="); j >= 0 {
+			name, param = rule[:j], rule[j+1:]
+		}
+		if ref && name != "required" {
+			continue
+		}
+		switch name {
+		case "required":
+			required = true
+		case "dive":
+			if s.Items != nil {
+				constrain(s.Items, t.Elem(), strings.Join(list[i+1:], ","))
+			}
+			return required
+		case "min", "gte":
+			bound(s, param, true, false)
+		case "max", "lte":
+			bound(s, param, false, false)
+		case "gt":
+			bound(s, param, true, true)
+		case "lt":
+			bound(s, param, false, true)
+		case "len":
+			bound(s, param, true, false)
+			bound(s, param, false, false)
+		case "notblank":
+			if s.Type == "string" && s.MinLength == nil {
+				one := 1
+				s.MinLength = &one
+			}
+		case "oneof":
+			s.Enum = nil
+			for _, v := range strings.Fields(param) {
+				s.Enum = append(s.Enum, enumValue(s.Type, v))
+			}
+		case "email":
+			s.Format = "email"
+		case "url":
+			s.Format = "uri"
+		}
+	}
+	return required
+}
+
+// bound sets the lower or upper limit of a number, the length of a string
+// or the size of an array
+func bound(s *Schema, param string, lower, exclusive bool) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	switch s.Type {
+	case "integer", "number":
+		if lower {
+			s.Minimum, s.ExclusiveMinimum = &n, exclusive
+		} else {
+			s.Maximum, s.ExclusiveMaximum = &n, exclusive
+		}
+	case "string", "array":
+		size := int(n)
+		if exclusive {
+			if lower {
+				size++
+			} else {
+				size--
+			}
+		}
+		switch {
+		case s.Type == "string" && lower:
+			s.MinLength = &size
+		case s.Type == "string":
+			s.MaxLength = &size
+		case lower:
+			s.MinItems = &size
+		default:
+			s.MaxItems = &size
+		}
+	}
+}
+
+func enumValue(typ, v string) interface{} {
+	switch typ {
+	case "integer":
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	}
+	return v
+}
@@ -0,0 +1,41 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// keysetPrefix marks a cursor holding the position of the last item of a
+// page instead of an offset, for lists too large to skip through
+const keysetPrefix = "k1:"
+
+type keyset struct {
+	Scope    string   `json:"s"`
+	Position []string `json:"p"`
+}
+
+// EncodeKeyset makes an opaque cursor from the position of the last item of
+// a page. The position only means something under scope, such as the sort
+// it was taken in, and DecodeKeyset rejects the cursor under any other.
+func EncodeKeyset(scope string, position []string) string {
+	raw, _ := json.Marshal(keyset{Scope: scope, Position: position})
+	return base64.RawURLEncoding.EncodeToString(append([]byte(keysetPrefix), raw...))
+}
+
+func DecodeKeyset(cursor, scope string) ([]string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), keysetPrefix) {
+		return nil, ErrInvalidCursor
+	}
+	var k keyset
+	if err := json.Unmarshal(raw[len(keysetPrefix):], &k); err != nil || k.Scope != scope || len(k.Position) == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return k.Position, nil
+}
+
+// KeysetMeta builds the meta for a keyset page; next is empty on the last.
+func KeysetMeta(perPage int, next string) Meta {
+	return Meta{PerPage: perPage, NextCursor: next}
+}
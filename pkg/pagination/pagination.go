@@ -0,0 +1,122 @@
+// Package pagination turns page, per_page and cursor parameters into the
+// limit and offset the repositories take, and builds the pagination meta
+// returned with every list on both the HTTP and gRPC APIs.
+package pagination
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"online-shop/pkg/apperror"
+)
+
+const (
+	DefaultPerPage = 20
+	MaxPerPage     = 100
+)
+
+// cursorPrefix versions the cursor format so it can change without old
+// cursors being misread
+const cursorPrefix = "o1:"
+
+var ErrInvalidCursor = apperror.Define(apperror.KindInvalidArgument, "invalid_cursor", "cursor is invalid")
+
+// Page is the slice of a list a client asked for. Page numbers start at 1.
+type Page struct {
+	Page    int
+	PerPage int
+	offset  int
+}
+
+// New clamps the requested page into range: a missing or non-positive page
+// is the first, a missing per_page is DefaultPerPage and it never exceeds
+// MaxPerPage.
+func New(page, perPage int) Page {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = DefaultPerPage
+	}
+	if perPage > MaxPerPage {
+		perPage = MaxPerPage
+	}
+	return Page{Page: page, PerPage: perPage, offset: (page - 1) * perPage}
+}
+
+// FromOffset converts a legacy limit and offset. The offset is kept as it
+// is; Page reports the page it falls in.
+func FromOffset(limit, offset int) Page {
+	p := New(1, limit)
+	if offset > 0 {
+		p.offset = offset
+		p.Page = offset/p.PerPage + 1
+	}
+	return p
+}
+
+// FromCursor resumes a list from a cursor returned in an earlier meta.
+func FromCursor(cursor string, perPage int) (Page, error) {
+	offset, err := DecodeCursor(cursor)
+	if err != nil {
+		return Page{}, err
+	}
+	return FromOffset(perPage, offset), nil
+}
+
+func (p Page) Limit() int {
+	return p.PerPage
+}
+
+func (p Page) Offset() int {
+	return p.offset
+}
+
+// Meta describes a page of results. Total is only set by lists that count
+// their rows; NextCursor is empty on the last page. Page is left out of
+// keyset pages, which have no number.
+type Meta struct {
+	Page       int    `json:"page,omitempty"`
+	PerPage    int    `json:"per_page"`
+	Total      *int64 `json:"total,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Meta builds the meta for a page that returned count items. Without a total
+// a full page is assumed to have more after it.
+func (p Page) Meta(count int, total *int64) Meta {
+	m := Meta{Page: p.Page, PerPage: p.PerPage, Total: total}
+
+	next := p.Offset() + count
+	hasMore := count >= p.PerPage
+	if total != nil {
+		hasMore = int64(next) < *total
+	}
+	if hasMore {
+		m.NextCursor = EncodeCursor(next)
+	}
+	return m
+}
+
+// Total is a helper for passing a known count to Meta.
+func Total(n int64) *int64 {
+	return &n
+}
+
+// EncodeCursor makes an opaque cursor for the item at offset.
+func EncodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(offset)))
+}
+
+func DecodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, ErrInvalidCursor
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(raw), cursorPrefix))
+	if err != nil || offset < 0 {
+		return 0, ErrInvalidCursor
+	}
+	return offset, nil
+}
@@ -0,0 +1,32 @@
+package resilience
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	circuitState = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "dependency_circuit_state",
+			Help: "Circuit breaker state per dependency: 0 closed, 1 half-open, 2 open",
+		},
+		[]string{"dependency"},
+	)
+
+	callsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "dependency_calls_total",
+			Help: "Calls to downstream dependencies by outcome: success, failure or rejected",
+		},
+		[]string{"dependency", "outcome"},
+	)
+
+	inFlight = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "dependency_calls_in_flight",
+			Help: "Calls currently running against each dependency",
+		},
+		[]string{"dependency"},
+	)
+)
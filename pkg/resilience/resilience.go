@@ -0,0 +1,114 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"online-shop/pkg/config"
+
+	"github.com/sony/gobreaker"
+)
+
+var (
+	// ErrUnavailable is matched with errors.Is by callers that only care
+	// that the call was not made
+	ErrUnavailable = errors.New("dependency unavailable")
+	// ErrOpen means the dependency failed too often and calls to it are
+	// paused
+	ErrOpen = fmt.Errorf("%w: circuit open", ErrUnavailable)
+	// ErrSaturated means the dependency already has as many calls in flight
+	// as it is allowed
+	ErrSaturated = fmt.Errorf("%w: too many calls in flight", ErrUnavailable)
+)
+
+// Breaker guards one downstream dependency with a circuit breaker and a
+// bulkhead. It reports its state and calls to Prometheus under its name.
+type Breaker struct {
+	name      string
+	cb        *gobreaker.CircuitBreaker
+	slots     chan struct{}
+	isFailure func(error) bool
+}
+
+// New builds a Breaker from cfg. isFailure decides which errors count
+// against the dependency; a nil isFailure counts every error except the
+// caller's own cancellation. Errors that mean the dependency answered, such
+// as a missing key or a rejected request, should not count.
+func New(name string, cfg config.DependencyConfig, isFailure func(error) bool) *Breaker {
+	if isFailure == nil {
+		isFailure = func(err error) bool {
+			return err != nil && !errors.Is(err, context.Canceled)
+		}
+	}
+	failures := uint32(cfg.Failures())
+	b := &Breaker{name: name, isFailure: isFailure}
+	b.cb = gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:    name,
+		Timeout: cfg.Cooldown(),
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= failures
+		},
+		IsSuccessful: func(err error) bool {
+			return !b.isFailure(err)
+		},
+		OnStateChange: func(name string, from, to gobreaker.State) {
+			circuitState.WithLabelValues(name).Set(stateValue(to))
+		},
+	})
+	if cfg.MaxConcurrent > 0 {
+		b.slots = make(chan struct{}, cfg.MaxConcurrent)
+	}
+	circuitState.WithLabelValues(name).Set(stateValue(gobreaker.StateClosed))
+	return b
+}
+
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State is closed, half-open or open
+func (b *Breaker) State() string {
+	return b.cb.State().String()
+}
+
+// Do runs fn unless the circuit is open or the bulkhead is full, in which
+// case it returns ErrOpen or ErrSaturated without calling fn.
+func (b *Breaker) Do(fn func() error) error {
+	if b.slots != nil {
+		select {
+		case b.slots <- struct{}{}:
+			defer func() { <-b.slots }()
+		default:
+			callsTotal.WithLabelValues(b.name, "rejected").Inc()
+			return ErrSaturated
+		}
+	}
+
+	inFlight.WithLabelValues(b.name).Inc()
+	defer inFlight.WithLabelValues(b.name).Dec()
+
+	_, err := b.cb.Execute(func() (interface{}, error) {
+		return nil, fn()
+	})
+	switch {
+	case errors.Is(err, gobreaker.ErrOpenState), errors.Is(err, gobreaker.ErrTooManyRequests):
+		callsTotal.WithLabelValues(b.name, "rejected").Inc()
+		return ErrOpen
+	case b.isFailure(err):
+		callsTotal.WithLabelValues(b.name, "failure").Inc()
+	default:
+		callsTotal.WithLabelValues(b.name, "success").Inc()
+	}
+	return err
+}
+
+func stateValue(s gobreaker.State) float64 {
+	switch s {
+	case gobreaker.StateHalfOpen:
+		return 1
+	case gobreaker.StateOpen:
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,127 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Task is a unit of periodic work
+type Task func(ctx context.Context) error
+
+type job struct {
+	name       string
+	interval   time.Duration
+	runOnStart bool
+	task       Task
+	trigger    chan struct{}
+}
+
+// Scheduler runs registered tasks at fixed intervals
+type Scheduler struct {
+	jobs   []job
+	logger *logrus.Logger
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates a new scheduler
+func New(logger *logrus.Logger) *Scheduler {
+	if logger == nil {
+		logger = logrus.New()
+	}
+	return &Scheduler{logger: logger}
+}
+
+// Every registers a task that runs once per interval. It must be called
+// before Start.
+func (s *Scheduler) Every(name string, interval time.Duration, task Task) {
+	s.jobs = append(s.jobs, job{name: name, interval: interval, task: task, trigger: make(chan struct{}, 1)})
+}
+
+// EveryNow registers a task that also runs immediately on Start
+func (s *Scheduler) EveryNow(name string, interval time.Duration, task Task) {
+	s.jobs = append(s.jobs, job{name: name, interval: interval, runOnStart: true, task: task, trigger: make(chan struct{}, 1)})
+}
+
+// Trigger asks a registered task to run now instead of waiting for its next
+// tick. Triggers received while the task is busy collapse into one extra run,
+// so a task never runs concurrently with itself.
+func (s *Scheduler) Trigger(name string) error {
+	for _, j := range s.jobs {
+		if j.name != name {
+			continue
+		}
+		select {
+		case j.trigger <- struct{}{}:
+		default:
+		}
+		return nil
+	}
+	return fmt.Errorf("scheduler: unknown task %q", name)
+}
+
+// Start launches one goroutine per task
+func (s *Scheduler) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.run(ctx, j)
+	}
+
+	s.logger.WithField("tasks", len(s.jobs)).Info("Scheduler started")
+}
+
+// Stop cancels all tasks and waits for running ones to finish
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+	s.logger.Info("Scheduler stopped")
+}
+
+func (s *Scheduler) run(ctx context.Context, j job) {
+	defer s.wg.Done()
+
+	if j.runOnStart {
+		s.execute(ctx, j)
+	}
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.execute(ctx, j)
+		case <-j.trigger:
+			s.execute(ctx, j)
+		}
+	}
+}
+
+func (s *Scheduler) execute(ctx context.Context, j job) {
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.WithFields(logrus.Fields{"task": j.name, "panic": r}).Error("Scheduled task panicked")
+		}
+	}()
+
+	if err := j.task(ctx); err != nil {
+		s.logger.WithFields(logrus.Fields{"task": j.name, "error": err}).Error("Scheduled task failed")
+		return
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"task":     j.name,
+		"duration": time.Since(start),
+	}).Debug("Scheduled task completed")
+}
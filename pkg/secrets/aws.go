@@ -0,0 +1,194 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"online-shop/pkg/config"
+)
+
+const awsService = "secretsmanager"
+
+// AWSProvider reads secrets from AWS Secrets Manager. Secrets stored as a
+// JSON object expose each of its keys; any other secret string is returned
+// under the empty key.
+type AWSProvider struct {
+	region       string
+	endpoint     string
+	accessKeyID  string
+	secretKey    string
+	sessionToken string
+	client       *http.Client
+	now          func() time.Time
+}
+
+// NewAWSProvider falls back to the standard AWS_* environment variables for
+// the region and credentials.
+func NewAWSProvider(cfg *config.AWSSecretsConfig) (*AWSProvider, error) {
+	p := &AWSProvider{
+		region:       firstNonEmpty(cfg.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION")),
+		endpoint:     cfg.Endpoint,
+		accessKeyID:  firstNonEmpty(cfg.AccessKeyID, os.Getenv("AWS_ACCESS_KEY_ID")),
+		secretKey:    firstNonEmpty(cfg.SecretAccessKey, os.Getenv("AWS_SECRET_ACCESS_KEY")),
+		sessionToken: firstNonEmpty(cfg.SessionToken, os.Getenv("AWS_SESSION_TOKEN")),
+		client:       &http.Client{Timeout: 10 * time.Second},
+		now:          time.Now,
+	}
+	if p.region == "" {
+		return nil, errors.New("secrets: aws region is required")
+	}
+	if p.accessKeyID == "" || p.secretKey == "" {
+		return nil, errors.New("secrets: aws credentials are required")
+	}
+	if p.endpoint == "" {
+		p.endpoint = fmt.Sprintf("https://%s.%s.amazonaws.com", awsService, p.region)
+	}
+	p.endpoint = strings.TrimRight(p.endpoint, "/") + "/"
+	return p, nil
+}
+
+func (p *AWSProvider) Fetch(ctx context.Context, path string) (map[string]string, error) {
+	payload, err := json.Marshal(map[string]string{"SecretId": path})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	p.sign(req, payload)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(body, &failure)
+		if strings.HasSuffix(failure.Type, "ResourceNotFoundException") {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("secrets manager returned status %d: %s %s", resp.StatusCode, failure.Type, failure.Message)
+	}
+
+	var secret struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return nil, fmt.Errorf("decode secrets manager response: %w", err)
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(secret.SecretString), &values); err != nil {
+		return map[string]string{"": secret.SecretString}, nil
+	}
+	result := make(map[string]string, len(values))
+	for key, value := range values {
+		if s, ok := value.(string); ok {
+			result[key] = s
+		} else {
+			result[key] = fmt.Sprint(value)
+		}
+	}
+	return result, nil
+}
+
+// sign adds an AWS Signature Version 4 authorization header
+func (p *AWSProvider) sign(req *http.Request, payload []byte) {
+	now := p.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if p.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", p.sessionToken)
+	}
+
+	headers := []string{"content-type", "host", "x-amz-date", "x-amz-target"}
+	if p.sessionToken != "" {
+		headers = append(headers, "x-amz-security-token")
+		sort.Strings(headers)
+	}
+	var canonicalHeaders strings.Builder
+	for _, h := range headers {
+		value := req.Header.Get(h)
+		if h == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(h + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signedHeaders := strings.Join(headers, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hashHex(payload),
+	}, "\n")
+
+	scope := date + "/" + p.region + "/" + awsService + "/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hashHex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+p.secretKey), date)
+	key = hmacSHA256(key, p.region)
+	key = hmacSHA256(key, awsService)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		p.accessKeyID, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(values url.Values) string {
+	return strings.ReplaceAll(values.Encode(), "+", "%20")
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,261 @@
+// Package secrets resolves config values kept in an external secrets store.
+// A value of the form secret://<path>#<key> is replaced by the key of the
+// secret stored at path; any other value is used as it is. Secrets are
+// fetched on first use, cached, and can be refreshed while the process runs
+// so rotated values are picked up without a restart.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"online-shop/pkg/config"
+)
+
+// Scheme prefixes config values that reference a secret
+const Scheme = "secret://"
+
+var (
+	ErrNotConfigured = errors.New("secrets: no secrets provider is configured")
+	ErrNotFound      = errors.New("secrets: secret not found")
+)
+
+// Provider reads a secret from a store. A secret holds one or more named
+// values; a secret holding a single plain string is returned under the
+// empty key.
+type Provider interface {
+	Fetch(ctx context.Context, path string) (map[string]string, error)
+}
+
+// IsReference reports whether a config value points at a secret.
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, Scheme)
+}
+
+func parseReference(value string) (string, string, error) {
+	path, key := strings.TrimPrefix(value, Scheme), ""
+	if i := strings.LastIndex(path, "#"); i >= 0 {
+		path, key = path[:i], path[i+1:]
+	}
+	if path == "" {
+		return "", "", fmt.Errorf("secrets: invalid reference %q", value)
+	}
+	return path, key, nil
+}
+
+type entry struct {
+	values  map[string]string
+	fetched time.Time
+}
+
+type watcher struct {
+	reference string
+	last      string
+	fn        func(string)
+}
+
+// Manager resolves references through a provider.
+type Manager struct {
+	provider Provider
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	cache    map[string]*entry
+	watchers []*watcher
+}
+
+// NewManager caches fetched secrets for ttl. A nil provider leaves plain
+// values working and fails on references.
+func NewManager(provider Provider, ttl time.Duration) *Manager {
+	return &Manager{
+		provider: provider,
+		ttl:      ttl,
+		now:      time.Now,
+		cache:    make(map[string]*entry),
+	}
+}
+
+// New builds the manager for the configured provider.
+func New(cfg *config.SecretsConfig) (*Manager, error) {
+	var provider Provider
+	switch cfg.Provider {
+	case "":
+	case "vault":
+		provider = NewVaultProvider(&cfg.Vault)
+	case "aws":
+		p, err := NewAWSProvider(&cfg.AWS)
+		if err != nil {
+			return nil, err
+		}
+		provider = p
+	default:
+		return nil, fmt.Errorf("secrets: unsupported provider %q", cfg.Provider)
+	}
+	return NewManager(provider, cfg.RefreshInterval()), nil
+}
+
+// Resolve returns the secret a value references, or the value itself when it
+// is not a reference. A cached secret past its ttl is fetched again; if that
+// fails the cached value keeps being served.
+func (m *Manager) Resolve(ctx context.Context, value string) (string, error) {
+	if !IsReference(value) {
+		return value, nil
+	}
+	path, key, err := parseReference(value)
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	cached := m.cache[path]
+	m.mu.Unlock()
+
+	if cached == nil || m.now().Sub(cached.fetched) >= m.ttl {
+		fetched, err := m.fetch(ctx, path)
+		if err != nil && cached == nil {
+			return "", err
+		}
+		if err == nil {
+			cached = fetched
+		}
+	}
+	return lookup(cached, path, key)
+}
+
+// ResolveConfig replaces every reference in the string fields of a config
+// struct, following nested structs, pointers and slices.
+func (m *Manager) ResolveConfig(ctx context.Context, cfg interface{}) error {
+	return m.resolveValue(ctx, reflect.ValueOf(cfg), "")
+}
+
+func (m *Manager) resolveValue(ctx context.Context, v reflect.Value, name string) error {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return m.resolveValue(ctx, v.Elem(), name)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			field := v.Type().Field(i).Name
+			if name != "" {
+				field = name + "." + field
+			}
+			if err := m.resolveValue(ctx, v.Field(i), field); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := m.resolveValue(ctx, v.Index(i), fmt.Sprintf("%s[%d]", name, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.String:
+		if !IsReference(v.String()) || !v.CanSet() {
+			return nil
+		}
+		resolved, err := m.Resolve(ctx, v.String())
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		v.SetString(resolved)
+	}
+	return nil
+}
+
+// Watch calls fn with the new value whenever Refresh sees the referenced
+// secret change. Plain values never change, so watching one does nothing.
+func (m *Manager) Watch(value string, fn func(string)) {
+	if !IsReference(value) {
+		return
+	}
+	last, _ := m.Resolve(context.Background(), value)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.watchers = append(m.watchers, &watcher{reference: value, last: last, fn: fn})
+}
+
+// Refresh fetches every watched secret again and notifies the watchers of
+// values that changed. A secret that cannot be fetched keeps its old value
+// and is retried on the next refresh.
+func (m *Manager) Refresh(ctx context.Context) error {
+	m.mu.Lock()
+	watchers := append([]*watcher(nil), m.watchers...)
+	m.mu.Unlock()
+
+	fetched := make(map[string]*entry)
+	var errs []string
+	for _, w := range watchers {
+		path, key, err := parseReference(w.reference)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		e, ok := fetched[path]
+		if !ok {
+			if e, err = m.fetch(ctx, path); err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			fetched[path] = e
+		}
+		value, err := lookup(e, path, key)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+
+		m.mu.Lock()
+		changed := value != w.last
+		w.last = value
+		m.mu.Unlock()
+		if changed {
+			w.fn(value)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (m *Manager) fetch(ctx context.Context, path string) (*entry, error) {
+	if m.provider == nil {
+		return nil, ErrNotConfigured
+	}
+	values, err := m.provider.Fetch(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("secrets: fetch %s: %w", path, err)
+	}
+
+	e := &entry{values: values, fetched: m.now()}
+	m.mu.Lock()
+	m.cache[path] = e
+	m.mu.Unlock()
+	return e, nil
+}
+
+func lookup(e *entry, path, key string) (string, error) {
+	value, ok := e.values[key]
+	if !ok && key == "" && len(e.values) == 1 {
+		for _, v := range e.values {
+			value, ok = v, true
+		}
+	}
+	if !ok {
+		return "", fmt.Errorf("%w: %s#%s", ErrNotFound, path, key)
+	}
+	return value, nil
+}
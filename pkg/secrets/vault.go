@@ -0,0 +1,90 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"online-shop/pkg/config"
+)
+
+// VaultProvider reads secrets from a HashiCorp Vault KV version 2 engine.
+type VaultProvider struct {
+	address   string
+	token     string
+	mount     string
+	namespace string
+	client    *http.Client
+}
+
+// NewVaultProvider falls back to VAULT_ADDR and VAULT_TOKEN from the
+// environment when they are not configured.
+func NewVaultProvider(cfg *config.VaultConfig) *VaultProvider {
+	address, token := cfg.Address, cfg.Token
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	mount := cfg.Mount
+	if mount == "" {
+		mount = "secret"
+	}
+	return &VaultProvider{
+		address:   strings.TrimRight(address, "/"),
+		token:     token,
+		mount:     strings.Trim(mount, "/"),
+		namespace: cfg.Namespace,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *VaultProvider) Fetch(ctx context.Context, path string) (map[string]string, error) {
+	endpoint := fmt.Sprintf("%s/v1/%s/data/%s", p.address, p.mount, (&url.URL{Path: strings.Trim(path, "/")}).EscapedPath())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+	if p.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decode vault response: %w", err)
+	}
+
+	values := make(map[string]string, len(body.Data.Data))
+	for key, value := range body.Data.Data {
+		if s, ok := value.(string); ok {
+			values[key] = s
+		} else {
+			values[key] = fmt.Sprint(value)
+		}
+	}
+	return values, nil
+}
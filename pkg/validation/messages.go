@@ -0,0 +1,84 @@
+package validation
+
+import (
+	"strings"
+
+	"online-shop/pkg/i18n"
+)
+
+// Messages maps error codes to message templates. {field} and {param} are
+// replaced with the field path and the rule's parameter.
+type Messages map[string]string
+
+// messageKey prefixes codes in the i18n catalog, which holds the messages
+const messageKey = "validation."
+
+var messages = map[string]Messages{
+	"en": {
+		CodeRequired:          "{field} is required",
+		CodeInvalid:           "{field} is invalid",
+		CodeInvalidType:       "{field} must be a {param}",
+		CodeInvalidEmail:      "{field} must be a valid email address",
+		CodeInvalidSlug:       "{field} may only contain lowercase letters, digits and single hyphens",
+		CodeInvalidPhone:      "{field} must be a valid phone number",
+		CodeInvalidUUID:       "{field} must be a valid ID",
+		CodeInvalidChoice:     "{field} must be one of: {param}",
+		CodeTooShort:          "{field} must be at least {param} characters",
+		CodeTooLong:           "{field} must be at most {param} characters",
+		CodeTooSmall:          "{field} must be at least {param}",
+		CodeTooLarge:          "{field} must be at most {param}",
+		CodeTooFew:            "{field} must contain at least {param} items",
+		CodeTooMany:           "{field} must contain at most {param} items",
+		CodeBlank:             "{field} must not be blank",
+		CodeUnsupportedLocale: "{field} must be a supported language",
+	},
+	"id": {
+		CodeRequired:          "{field} wajib diisi",
+		CodeInvalid:           "{field} tidak valid",
+		CodeInvalidType:       "{field} harus berupa {param}",
+		CodeInvalidEmail:      "{field} harus berupa alamat email yang valid",
+		CodeInvalidSlug:       "{field} hanya boleh berisi huruf kecil, angka, dan tanda hubung tunggal",
+		CodeInvalidPhone:      "{field} harus berupa nomor telepon yang valid",
+		CodeInvalidUUID:       "{field} harus berupa ID yang valid",
+		CodeInvalidChoice:     "{field} harus salah satu dari: {param}",
+		CodeTooShort:          "{field} minimal {param} karakter",
+		CodeTooLong:           "{field} maksimal {param} karakter",
+		CodeTooSmall:          "{field} minimal {param}",
+		CodeTooLarge:          "{field} maksimal {param}",
+		CodeTooFew:            "{field} minimal berisi {param} item",
+		CodeTooMany:           "{field} maksimal berisi {param} item",
+		CodeBlank:             "{field} tidak boleh kosong",
+		CodeUnsupportedLocale: "{field} harus berupa bahasa yang didukung",
+	},
+}
+
+func init() {
+	for locale, m := range messages {
+		RegisterMessages(locale, m)
+	}
+}
+
+// RegisterMessages adds or overrides the messages of a locale. Codes missing
+// from a locale fall back to the default locale.
+func RegisterMessages(locale string, m Messages) {
+	prefixed := make(i18n.Messages, len(m))
+	for code, template := range m {
+		prefixed[messageKey+code] = template
+	}
+	i18n.Register(locale, prefixed)
+}
+
+// Message renders a field error in the given locale.
+func Message(locale string, f FieldError) string {
+	template, ok := i18n.Lookup(locale, messageKey+f.Code)
+	if !ok {
+		template, _ = i18n.Lookup(DefaultLocale, messageKey+CodeInvalid)
+	}
+	return i18n.Render(template, "field", f.Field, "param", strings.ReplaceAll(f.Param, " ", ", "))
+}
+
+// Locale picks the locale of an Accept-Language header that messages are
+// served in, and the default locale when none of its languages has any.
+func Locale(acceptLanguage string) string {
+	return i18n.Negotiate(acceptLanguage)
+}
@@ -0,0 +1,227 @@
+// Package validation checks request structs against their `validate` tags
+// and reports failures as field-level errors with stable codes, so clients
+// can map them to form fields and their own copy.
+package validation
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/i18n"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Error codes returned to clients. Codes are part of the API and do not
+// change with the message wording or locale.
+const (
+	CodeRequired          = "required"
+	CodeInvalid           = "invalid"
+	CodeInvalidType       = "invalid_type"
+	CodeInvalidEmail      = "invalid_email"
+	CodeInvalidSlug       = "invalid_slug"
+	CodeInvalidPhone      = "invalid_phone"
+	CodeInvalidUUID       = "invalid_uuid"
+	CodeInvalidChoice     = "invalid_choice"
+	CodeTooShort          = "too_short"
+	CodeTooLong           = "too_long"
+	CodeTooSmall          = "too_small"
+	CodeTooLarge          = "too_large"
+	CodeTooFew            = "too_few"
+	CodeTooMany           = "too_many"
+	CodeBlank             = "blank"
+	CodeUnsupportedLocale = "unsupported_locale"
+)
+
+// DefaultLocale is used when a request asks for a locale without messages.
+const DefaultLocale = i18n.DefaultLocale
+
+var (
+	slugPattern  = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	phonePattern = regexp.MustCompile(`^\+?[0-9][0-9 ()-]{5,18}[0-9]$`)
+)
+
+// FieldError is one failed rule. Field is the JSON path of the value, for
+// example items[0].quantity.
+type FieldError = apperror.FieldError
+
+// Error lists every field that failed validation.
+type Error struct {
+	Fields []FieldError
+}
+
+func (e *Error) Error() string {
+	parts := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		parts[i] = f.Field + ": " + f.Message
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+// Localize renders the messages in the given locale, falling back to the
+// default locale for codes it has no message for.
+func (e *Error) Localize(locale string) *Error {
+	localized := &Error{Fields: make([]FieldError, len(e.Fields))}
+	for i, f := range e.Fields {
+		f.Message = Message(locale, f)
+		localized.Fields[i] = f
+	}
+	return localized
+}
+
+// Validator wraps go-playground/validator with the shop's custom rules and
+// JSON field naming.
+type Validator struct {
+	validate *validator.Validate
+}
+
+func New() *Validator {
+	v := validator.New()
+	v.SetTagName("validate")
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	v.RegisterValidation("slug", func(fl validator.FieldLevel) bool {
+		return slugPattern.MatchString(fl.Field().String())
+	})
+	v.RegisterValidation("phone", func(fl validator.FieldLevel) bool {
+		return phonePattern.MatchString(fl.Field().String())
+	})
+	v.RegisterValidation("notblank", func(fl validator.FieldLevel) bool {
+		return strings.TrimSpace(fl.Field().String()) != ""
+	})
+	v.RegisterValidation("locale", func(fl validator.FieldLevel) bool {
+		return i18n.Supported(fl.Field().String())
+	})
+
+	return &Validator{validate: v}
+}
+
+// RegisterRule adds a custom rule usable in `validate` tags. code is what
+// clients receive when the rule fails.
+func (v *Validator) RegisterRule(tag, code string, fn func(value interface{}) bool) error {
+	err := v.validate.RegisterValidation(tag, func(fl validator.FieldLevel) bool {
+		return fn(fl.Field().Interface())
+	})
+	if err != nil {
+		return err
+	}
+	ruleCodesMu.Lock()
+	ruleCodes[tag] = code
+	ruleCodesMu.Unlock()
+	return nil
+}
+
+// Struct validates obj and returns an *Error listing every failed field,
+// with messages in the default locale.
+func (v *Validator) Struct(obj interface{}) error {
+	err := v.validate.Struct(obj)
+	if err == nil {
+		return nil
+	}
+
+	var failures validator.ValidationErrors
+	if !errors.As(err, &failures) {
+		return err
+	}
+
+	verr := &Error{Fields: make([]FieldError, 0, len(failures))}
+	for _, failure := range failures {
+		f := FieldError{
+			Field: fieldPath(failure.Namespace()),
+			Code:  code(failure),
+			Param: failure.Param(),
+		}
+		f.Message = Message(DefaultLocale, f)
+		verr.Fields = append(verr.Fields, f)
+	}
+	return verr
+}
+
+// fieldPath drops the struct name validator puts in front of the path
+func fieldPath(namespace string) string {
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+var (
+	ruleCodesMu sync.RWMutex
+	ruleCodes   = map[string]string{
+		"required": CodeRequired,
+		"email":    CodeInvalidEmail,
+		"slug":     CodeInvalidSlug,
+		"phone":    CodeInvalidPhone,
+		"uuid":     CodeInvalidUUID,
+		"uuid4":    CodeInvalidUUID,
+		"oneof":    CodeInvalidChoice,
+		"notblank": CodeBlank,
+		"locale":   CodeUnsupportedLocale,
+		"gt":       CodeTooSmall,
+		"gte":      CodeTooSmall,
+		"lt":       CodeTooLarge,
+		"lte":      CodeTooLarge,
+	}
+)
+
+func code(failure validator.FieldError) string {
+	switch failure.Tag() {
+	case "min", "len":
+		return sizeCode(failure.Kind(), CodeTooShort, CodeTooSmall, CodeTooFew)
+	case "max":
+		return sizeCode(failure.Kind(), CodeTooLong, CodeTooLarge, CodeTooMany)
+	}
+
+	ruleCodesMu.RLock()
+	defer ruleCodesMu.RUnlock()
+	if c, ok := ruleCodes[failure.Tag()]; ok {
+		return c
+	}
+	return CodeInvalid
+}
+
+// sizeCode picks the code for min and max, which mean length for strings,
+// count for collections and value for numbers
+func sizeCode(kind reflect.Kind, text, number, collection string) string {
+	switch kind {
+	case reflect.String:
+		return text
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return collection
+	default:
+		return number
+	}
+}
+
+var std = New()
+
+// Default returns the validator used by the package-level functions, for
+// registering extra rules.
+func Default() *Validator {
+	return std
+}
+
+// Struct validates obj with the default validator.
+func Struct(obj interface{}) error {
+	return Default().Struct(obj)
+}
+
+// TypeError reports a JSON value of the wrong type as a field error.
+func TypeError(field, expected string) *Error {
+	f := FieldError{Field: field, Code: CodeInvalidType, Param: expected}
+	f.Message = Message(DefaultLocale, f)
+	return &Error{Fields: []FieldError{f}}
+}
@@ -0,0 +1,168 @@
+package workerpool
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Job outcomes counted by worker_pool_jobs_total
+const (
+	outcomeSucceeded = "succeeded"
+	outcomeFailed    = "failed"
+	outcomePanicked  = "panicked"
+	outcomeTimedOut  = "timed_out"
+	outcomeCancelled = "cancelled"
+)
+
+var (
+	jobsSubmitted = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "worker_pool_jobs_submitted_total",
+			Help: "Jobs submitted to each worker pool",
+		},
+		[]string{"pool", "type"},
+	)
+
+	jobsFinished = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "worker_pool_jobs_total",
+			Help: "Jobs run or cancelled by each worker pool by outcome: succeeded, failed, panicked, timed_out or cancelled",
+		},
+		[]string{"pool", "type", "outcome"},
+	)
+
+	jobDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "worker_pool_job_duration_seconds",
+			Help:    "Time jobs took to run, cancelled jobs excluded",
+			Buckets: []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 1800},
+		},
+		[]string{"pool", "type"},
+	)
+
+	queueDepth = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "worker_pool_queue_depth",
+			Help: "Jobs waiting in each worker pool's queue, delayed jobs included",
+		},
+		[]string{"pool"},
+	)
+
+	activeWorkers = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "worker_pool_active_workers",
+			Help: "Workers running a job in each worker pool",
+		},
+		[]string{"pool"},
+	)
+
+	poolWorkers = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "worker_pool_workers",
+			Help: "Workers started by each worker pool",
+		},
+		[]string{"pool"},
+	)
+
+	averageJobTime = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "worker_pool_average_job_seconds",
+			Help: "Moving average of the time each worker pool's jobs took",
+		},
+		[]string{"pool"},
+	)
+)
+
+// PoolState is a running pool's state, for debugging
+type PoolState struct {
+	Name              string     `json:"name"`
+	Workers           int        `json:"workers"`
+	ActiveWorkers     int64      `json:"active_workers"`
+	Queued            int        `json:"queued"`
+	Delayed           int        `json:"delayed"`
+	BulkRunning       int        `json:"bulk_running"`
+	JobsProcessed     int64      `json:"jobs_processed"`
+	JobsFailed        int64      `json:"jobs_failed"`
+	JobsPanicked      int64      `json:"jobs_panicked"`
+	JobsTimedOut      int64      `json:"jobs_timed_out"`
+	JobsCancelled     int64      `json:"jobs_cancelled"`
+	AverageJobSeconds float64    `json:"average_job_seconds"`
+	LastJobAt         *time.Time `json:"last_job_at,omitempty"`
+}
+
+// running holds the pools started and not yet stopped
+var running = struct {
+	sync.Mutex
+	pools map[*WorkerPool]struct{}
+}{pools: map[*WorkerPool]struct{}{}}
+
+// States returns the state of every running pool, by name
+func States() []PoolState {
+	running.Lock()
+	pools := make([]*WorkerPool, 0, len(running.pools))
+	for pool := range running.pools {
+		pools = append(pools, pool)
+	}
+	running.Unlock()
+
+	states := make([]PoolState, 0, len(pools))
+	for _, pool := range pools {
+		states = append(states, pool.State())
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states
+}
+
+// State returns the pool's current state
+func (p *WorkerPool) State() PoolState {
+	p.mu.Lock()
+	state := PoolState{
+		Name:        p.name,
+		Workers:     p.maxWorkers,
+		Queued:      p.queue.len(),
+		Delayed:     p.queue.delayed.Len(),
+		BulkRunning: p.bulkRunning,
+	}
+	p.mu.Unlock()
+
+	metrics := p.GetMetrics()
+	state.ActiveWorkers = metrics.ActiveWorkers
+	state.JobsProcessed = metrics.JobsProcessed
+	state.JobsFailed = metrics.JobsFailed
+	state.JobsPanicked = metrics.JobsPanicked
+	state.JobsTimedOut = metrics.JobsTimedOut
+	state.JobsCancelled = metrics.JobsCancelled
+	state.AverageJobSeconds = metrics.AverageJobTime.Seconds()
+	if !metrics.LastJobTime.IsZero() {
+		state.LastJobAt = &metrics.LastJobTime
+	}
+	return state
+}
+
+func (p *WorkerPool) register() {
+	poolWorkers.WithLabelValues(p.name).Set(float64(p.maxWorkers))
+	running.Lock()
+	running.pools[p] = struct{}{}
+	running.Unlock()
+}
+
+func (p *WorkerPool) unregister() {
+	running.Lock()
+	delete(running.pools, p)
+	running.Unlock()
+}
+
+// observeQueue records the queue depth; p.mu must be held
+func (p *WorkerPool) observeQueue() {
+	queueDepth.WithLabelValues(p.name).Set(float64(p.queue.len()))
+}
+
+// observeJob records a job that ran
+func (p *WorkerPool) observeJob(job Job, outcome string, duration time.Duration) {
+	jobsFinished.WithLabelValues(p.name, job.GetType(), outcome).Inc()
+	jobDuration.WithLabelValues(p.name, job.GetType()).Observe(duration.Seconds())
+}
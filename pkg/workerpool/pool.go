@@ -2,8 +2,10 @@ package workerpool
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"runtime"
+	"runtime/debug"
 	"sync"
 	"time"
 
@@ -18,44 +20,105 @@ type Job interface {
 	GetPriority() int
 }
 
+// TimeoutJob is implemented by jobs that set their own timeout; zero keeps
+// the pool's
+type TimeoutJob interface {
+	GetTimeout() time.Duration
+}
+
+// DelayedJob is implemented by jobs that must not run before a time
+type DelayedJob interface {
+	GetRunAfter() time.Time
+}
+
+// Canceller is implemented by jobs that need to know the pool stopped
+// before running them
+type Canceller interface {
+	Cancel(err error)
+}
+
+// ErrPoolStopped is returned for jobs submitted to, or still queued in, a
+// stopped pool
+var ErrPoolStopped = errors.New("worker pool stopped")
+
+// ErrQueueFull is returned for jobs submitted to a pool whose queue is full
+var ErrQueueFull = errors.New("job queue is full")
+
+// PanicError is returned for a job that panicked
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("job panicked: %v", e.Value)
+}
+
 // Worker represents a worker in the pool
 type Worker struct {
 	ID       int
-	JobQueue chan Job
 	QuitChan chan bool
 	Logger   *logrus.Logger
 }
 
 // WorkerPool represents a pool of workers
 type WorkerPool struct {
-	workers    []*Worker
-	jobQueue   chan Job
-	quitChan   chan bool
-	wg         sync.WaitGroup
-	logger     *logrus.Logger
-	maxWorkers int
-	maxQueue   int
-	metrics    *PoolMetrics
-	mu         sync.RWMutex
+	name           string
+	workers        []*Worker
+	wg             sync.WaitGroup
+	logger         *logrus.Logger
+	maxWorkers     int
+	maxQueue       int
+	jobTimeout     time.Duration
+	bulkPriority   int
+	maxBulkWorkers int
+	maxBulkQueued  int
+	metrics        *PoolMetrics
+	// mu guards the queue, the bulk jobs running and stopped
+	mu          sync.Mutex
+	queue       *jobQueue
+	bulkRunning int
+	stopped     bool
+	// changed is closed, and replaced, whenever a job may have become
+	// available to a waiting worker or a place in the queue freed
+	changed chan struct{}
 }
 
 // PoolMetrics tracks pool performance
 type PoolMetrics struct {
-	JobsProcessed   int64
-	JobsFailed      int64
-	JobsInQueue     int64
-	ActiveWorkers   int64
-	TotalWorkers    int64
-	AverageJobTime  time.Duration
-	LastJobTime     time.Time
-	mu              sync.RWMutex
+	JobsProcessed  int64
+	JobsFailed     int64
+	JobsPanicked   int64
+	JobsTimedOut   int64
+	JobsCancelled  int64
+	JobsInQueue    int64
+	ActiveWorkers  int64
+	TotalWorkers   int64
+	AverageJobTime time.Duration
+	LastJobTime    time.Time
+	mu             sync.RWMutex
 }
 
-// PoolConfig contains configuration for the worker pool
+// PoolConfig contains configuration for the worker pool. A job running
+// longer than JobTimeout has its context cancelled, and fails if it then
+// returns an error; zero lets jobs run for as long as they take. Name labels the pool's Prometheus metrics.
+//
+// Jobs run highest priority first, and delayed jobs once they are due. Jobs
+// at or below BulkPriority are bulk: at most MaxBulkWorkers of them run at
+// once and MaxBulkQueued wait in the queue, so a pile of them cannot hold
+// up the rest. Zero leaves either unlimited, and bulk jobs unset apart.
+// PriorityAging raises a waiting job's priority by one for every interval
+// it waited, so bulk jobs are not starved in turn.
 type PoolConfig struct {
-	MaxWorkers int
-	MaxQueue   int
-	Logger     *logrus.Logger
+	Name           string
+	MaxWorkers     int
+	MaxQueue       int
+	JobTimeout     time.Duration
+	BulkPriority   int
+	MaxBulkWorkers int
+	MaxBulkQueued  int
+	PriorityAging  time.Duration
+	Logger         *logrus.Logger
 }
 
 // NewWorkerPool creates a new worker pool
@@ -69,14 +132,22 @@ func NewWorkerPool(config PoolConfig) *WorkerPool {
 	if config.Logger == nil {
 		config.Logger = logrus.New()
 	}
+	if config.Name == "" {
+		config.Name = "default"
+	}
 
 	pool := &WorkerPool{
-		workers:    make([]*Worker, 0, config.MaxWorkers),
-		jobQueue:   make(chan Job, config.MaxQueue),
-		quitChan:   make(chan bool),
-		logger:     config.Logger,
-		maxWorkers: config.MaxWorkers,
-		maxQueue:   config.MaxQueue,
+		name:           config.Name,
+		workers:        make([]*Worker, 0, config.MaxWorkers),
+		logger:         config.Logger,
+		maxWorkers:     config.MaxWorkers,
+		maxQueue:       config.MaxQueue,
+		jobTimeout:     config.JobTimeout,
+		bulkPriority:   config.BulkPriority,
+		maxBulkWorkers: config.MaxBulkWorkers,
+		maxBulkQueued:  config.MaxBulkQueued,
+		queue:          newJobQueue(config.PriorityAging),
+		changed:        make(chan struct{}),
 		metrics: &PoolMetrics{
 			TotalWorkers: int64(config.MaxWorkers),
 		},
@@ -89,6 +160,7 @@ func NewWorkerPool(config PoolConfig) *WorkerPool {
 func (p *WorkerPool) Start(ctx context.Context) {
 	p.logger.Info("Starting worker pool",
 		logrus.Fields{
+			"pool":        p.name,
 			"max_workers": p.maxWorkers,
 			"max_queue":   p.maxQueue,
 		})
@@ -96,7 +168,6 @@ func (p *WorkerPool) Start(ctx context.Context) {
 	for i := 0; i < p.maxWorkers; i++ {
 		worker := &Worker{
 			ID:       i + 1,
-			JobQueue: make(chan Job),
 			QuitChan: make(chan bool),
 			Logger:   p.logger,
 		}
@@ -106,53 +177,132 @@ func (p *WorkerPool) Start(ctx context.Context) {
 		go p.startWorker(ctx, worker)
 	}
 
-	// Start job dispatcher
-	go p.dispatch(ctx)
+	p.register()
 
 	p.logger.Info("Worker pool started successfully")
 }
 
-// Stop gracefully stops all workers
+// Stop gracefully stops all workers. Running jobs are waited for, up to
+// their timeout; jobs still queued are cancelled.
 func (p *WorkerPool) Stop() {
-	p.logger.Info("Stopping worker pool...")
+	p.mu.Lock()
+	if p.stopped {
+		p.mu.Unlock()
+		return
+	}
+	p.stopped = true
+	p.notify()
+	p.mu.Unlock()
 
-	close(p.quitChan)
+	p.logger.Info("Stopping worker pool...")
 
 	// Stop all workers
 	for _, worker := range p.workers {
-		worker.QuitChan <- true
+		close(worker.QuitChan)
 	}
 
 	// Wait for all workers to finish
 	p.wg.Wait()
 
+	p.mu.Lock()
+	queued := p.queue.drain()
+	p.observeQueue()
+	p.mu.Unlock()
+	for _, e := range queued {
+		p.cancel(e.job)
+	}
+	p.unregister()
+
 	p.logger.Info("Worker pool stopped")
 }
 
+// cancel tells a job the pool stopped before running it
+func (p *WorkerPool) cancel(job Job) {
+	p.updateMetrics(func(m *PoolMetrics) {
+		m.JobsInQueue--
+		m.JobsCancelled++
+	})
+	jobsFinished.WithLabelValues(p.name, job.GetType(), outcomeCancelled).Inc()
+
+	if canceller, ok := job.(Canceller); ok {
+		canceller.Cancel(ErrPoolStopped)
+	}
+
+	p.logger.Warn("Cancelled queued job",
+		logrus.Fields{
+			"job_id":   job.GetID(),
+			"job_type": job.GetType(),
+		})
+}
+
 // Submit adds a job to the queue
 func (p *WorkerPool) Submit(job Job) error {
-	select {
-	case p.jobQueue <- job:
-		p.updateMetrics(func(m *PoolMetrics) {
-			m.JobsInQueue++
-		})
-		return nil
-	default:
-		return fmt.Errorf("job queue is full")
-	}
+	return p.submit(job, time.Time{})
+}
+
+// SubmitAfter adds a job to the queue to run once delay has passed
+func (p *WorkerPool) SubmitAfter(job Job, delay time.Duration) error {
+	return p.submit(job, time.Now().Add(delay))
 }
 
 // SubmitWithTimeout adds a job to the queue with timeout
 func (p *WorkerPool) SubmitWithTimeout(job Job, timeout time.Duration) error {
-	select {
-	case p.jobQueue <- job:
-		p.updateMetrics(func(m *PoolMetrics) {
-			m.JobsInQueue++
-		})
-		return nil
-	case <-time.After(timeout):
-		return fmt.Errorf("timeout submitting job")
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		p.mu.Lock()
+		changed := p.changed
+		p.mu.Unlock()
+
+		err := p.submit(job, time.Time{})
+		if !errors.Is(err, ErrQueueFull) {
+			return err
+		}
+
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return fmt.Errorf("timeout submitting job")
+		}
+	}
+}
+
+func (p *WorkerPool) submit(job Job, runAfter time.Time) error {
+	if delayed, ok := job.(DelayedJob); ok && delayed.GetRunAfter().After(runAfter) {
+		runAfter = delayed.GetRunAfter()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return ErrPoolStopped
 	}
+	if p.queue.len() >= p.maxQueue {
+		return ErrQueueFull
+	}
+
+	priority := job.GetPriority()
+	bulk := (p.maxBulkWorkers > 0 || p.maxBulkQueued > 0) && priority <= p.bulkPriority
+	if bulk && p.maxBulkQueued > 0 && p.queue.bulkLen() >= p.maxBulkQueued {
+		return fmt.Errorf("%w: bulk jobs take up their share of it", ErrQueueFull)
+	}
+
+	p.queue.push(&entry{job: job, priority: priority, runAfter: runAfter, bulk: bulk}, time.Now())
+	p.observeQueue()
+	p.notify()
+	jobsSubmitted.WithLabelValues(p.name, job.GetType()).Inc()
+	p.updateMetrics(func(m *PoolMetrics) {
+		m.JobsInQueue++
+	})
+	return nil
+}
+
+// notify wakes the workers waiting for a job and the submitters waiting
+// for a place in the queue; p.mu must be held
+func (p *WorkerPool) notify() {
+	close(p.changed)
+	p.changed = make(chan struct{})
 }
 
 // GetMetrics returns current pool metrics
@@ -164,7 +314,9 @@ func (p *WorkerPool) GetMetrics() PoolMetrics {
 
 // GetQueueSize returns current queue size
 func (p *WorkerPool) GetQueueSize() int {
-	return len(p.jobQueue)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.queue.len()
 }
 
 // GetActiveWorkers returns number of active workers
@@ -181,17 +333,68 @@ func (p *WorkerPool) startWorker(ctx context.Context, worker *Worker) {
 	p.logger.Debug("Starting worker", logrus.Fields{"worker_id": worker.ID})
 
 	for {
-		select {
-		case <-ctx.Done():
-			p.logger.Debug("Worker stopping due to context cancellation",
+		e, ok := p.next(ctx, worker)
+		if !ok {
+			p.logger.Debug("Worker stopping",
 				logrus.Fields{"worker_id": worker.ID})
 			return
+		}
+
+		p.processJob(ctx, worker, e.job)
+
+		if e.bulk {
+			p.mu.Lock()
+			p.bulkRunning--
+			p.notify()
+			p.mu.Unlock()
+		}
+	}
+}
+
+// next waits for the next job a worker may run: the highest priority ready
+// job, passing over bulk jobs while MaxBulkWorkers of them run. It reports
+// false once the worker is to stop.
+func (p *WorkerPool) next(ctx context.Context, worker *Worker) (*entry, bool) {
+	for {
+		p.mu.Lock()
+		if p.stopped {
+			p.mu.Unlock()
+			return nil, false
+		}
+
+		now := time.Now()
+		allowBulk := p.maxBulkWorkers <= 0 || p.bulkRunning < p.maxBulkWorkers
+		if e := p.queue.pop(now, allowBulk); e != nil {
+			if e.bulk {
+				p.bulkRunning++
+			}
+			p.observeQueue()
+			p.notify()
+			p.mu.Unlock()
+			return e, true
+		}
+
+		changed := p.changed
+		due, delayed := p.queue.nextDue()
+		p.mu.Unlock()
+
+		var wake <-chan time.Time
+		var timer *time.Timer
+		if delayed {
+			timer = time.NewTimer(due.Sub(now))
+			wake = timer.C
+		}
+
+		select {
+		case <-changed:
+		case <-wake:
+		case <-ctx.Done():
+			return nil, false
 		case <-worker.QuitChan:
-			p.logger.Debug("Worker stopping due to quit signal",
-				logrus.Fields{"worker_id": worker.ID})
-			return
-		case job := <-worker.JobQueue:
-			p.processJob(ctx, worker, job)
+			return nil, false
+		}
+		if timer != nil {
+			timer.Stop()
 		}
 	}
 }
@@ -204,9 +407,13 @@ func (p *WorkerPool) processJob(ctx context.Context, worker *Worker, job Job) {
 		m.ActiveWorkers++
 		m.JobsInQueue--
 	})
+	activeWorkers.WithLabelValues(p.name).Inc()
 
+	outcome := outcomeSucceeded
 	defer func() {
 		duration := time.Since(startTime)
+		activeWorkers.WithLabelValues(p.name).Dec()
+		p.observeJob(job, outcome, duration)
 		p.updateMetrics(func(m *PoolMetrics) {
 			m.ActiveWorkers--
 			m.JobsProcessed++
@@ -217,6 +424,7 @@ func (p *WorkerPool) processJob(ctx context.Context, worker *Worker, job Job) {
 			} else {
 				m.AverageJobTime = (m.AverageJobTime + duration) / 2
 			}
+			averageJobTime.WithLabelValues(p.name).Set(m.AverageJobTime.Seconds())
 		})
 	}()
 
@@ -227,19 +435,42 @@ func (p *WorkerPool) processJob(ctx context.Context, worker *Worker, job Job) {
 			"job_type":  job.GetType(),
 		})
 
-	if err := job.Execute(ctx); err != nil {
-		p.updateMetrics(func(m *PoolMetrics) {
-			m.JobsFailed++
-		})
+	jobCtx, cancel := p.jobContext(ctx, job)
+	defer cancel()
 
-		worker.Logger.Error("Job execution failed",
-			logrus.Fields{
-				"worker_id": worker.ID,
-				"job_id":    job.GetID(),
-				"job_type":  job.GetType(),
-				"error":     err.Error(),
-				"duration":  time.Since(startTime),
+	if err := Run(jobCtx, job); err != nil {
+		fields := logrus.Fields{
+			"worker_id": worker.ID,
+			"job_id":    job.GetID(),
+			"job_type":  job.GetType(),
+			"error":     err.Error(),
+			"duration":  time.Since(startTime),
+		}
+
+		var panicErr *PanicError
+		switch {
+		case errors.As(err, &panicErr):
+			p.updateMetrics(func(m *PoolMetrics) {
+				m.JobsFailed++
+				m.JobsPanicked++
+			})
+			outcome = outcomePanicked
+			fields["stack"] = string(panicErr.Stack)
+			worker.Logger.Error("Job panicked", fields)
+		case errors.Is(jobCtx.Err(), context.DeadlineExceeded):
+			p.updateMetrics(func(m *PoolMetrics) {
+				m.JobsFailed++
+				m.JobsTimedOut++
 			})
+			outcome = outcomeTimedOut
+			worker.Logger.Error("Job timed out", fields)
+		default:
+			p.updateMetrics(func(m *PoolMetrics) {
+				m.JobsFailed++
+			})
+			outcome = outcomeFailed
+			worker.Logger.Error("Job execution failed", fields)
+		}
 		return
 	}
 
@@ -252,55 +483,28 @@ func (p *WorkerPool) processJob(ctx context.Context, worker *Worker, job Job) {
 		})
 }
 
-// dispatch distributes jobs to workers
-func (p *WorkerPool) dispatch(ctx context.Context) {
-	for {
-		select {
-		case <-ctx.Done():
-			p.logger.Info("Job dispatcher stopping due to context cancellation")
-			return
-		case <-p.quitChan:
-			p.logger.Info("Job dispatcher stopping due to quit signal")
-			return
-		case job := <-p.jobQueue:
-			// Find available worker
-			go func(j Job) {
-				select {
-				case <-ctx.Done():
-					return
-				default:
-					// Round-robin job assignment
-					workerIndex := int(p.metrics.JobsProcessed) % len(p.workers)
-					worker := p.workers[workerIndex]
-					
-					select {
-					case worker.JobQueue <- j:
-						// Job assigned successfully
-					case <-time.After(5 * time.Second):
-						// Worker is busy, try next worker
-						for i := 0; i < len(p.workers); i++ {
-							nextIndex := (workerIndex + i + 1) % len(p.workers)
-							nextWorker := p.workers[nextIndex]
-							select {
-							case nextWorker.JobQueue <- j:
-								return
-							default:
-								continue
-							}
-						}
-						// All workers are busy, log warning
-						p.logger.Warn("All workers are busy, job may be delayed",
-							logrus.Fields{
-								"job_id":   j.GetID(),
-								"job_type": j.GetType(),
-							})
-						// Try to assign to first available worker (blocking)
-						p.workers[0].JobQueue <- j
-					}
-				}
-			}(job)
-		}
+// jobContext bounds a job by its own timeout, or else the pool's
+func (p *WorkerPool) jobContext(ctx context.Context, job Job) (context.Context, context.CancelFunc) {
+	timeout := p.jobTimeout
+	if timed, ok := job.(TimeoutJob); ok && timed.GetTimeout() > 0 {
+		timeout = timed.GetTimeout()
 	}
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Run executes a job, returning a panic as a *PanicError. It always waits
+// for the job to return, so a worker never picks up another job while one
+// is still running: a job must watch ctx to stop at its deadline.
+func Run(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return job.Execute(ctx)
 }
 
 // updateMetrics safely updates pool metrics
@@ -320,6 +524,10 @@ type PriorityJob struct {
 type BaseJob struct {
 	ID   string
 	Type string
+	// Timeout overrides the pool's job timeout when set
+	Timeout time.Duration
+	// RunAfter holds the job in the queue until then
+	RunAfter time.Time
 }
 
 func (b BaseJob) GetID() string {
@@ -334,6 +542,14 @@ func (b BaseJob) GetPriority() int {
 	return 0 // Default priority
 }
 
+func (b BaseJob) GetTimeout() time.Duration {
+	return b.Timeout
+}
+
+func (b BaseJob) GetRunAfter() time.Time {
+	return b.RunAfter
+}
+
 func (p PriorityJob) GetPriority() int {
 	return p.Priority
-}
\ No newline at end of file
+}
@@ -0,0 +1,167 @@
+package workerpool
+
+import (
+	"container/heap"
+	"time"
+)
+
+// entry is a job waiting in the pool's queue
+type entry struct {
+	job      Job
+	priority int
+	// runAfter is when a delayed job becomes ready
+	runAfter time.Time
+	// readyAt is when the job became ready, for aging its priority
+	readyAt time.Time
+	seq     uint64
+	bulk    bool
+}
+
+// entryHeap is a heap of entries ordered by less
+type entryHeap struct {
+	entries []*entry
+	less    func(a, b *entry) bool
+}
+
+func (h *entryHeap) Len() int           { return len(h.entries) }
+func (h *entryHeap) Less(i, j int) bool { return h.less(h.entries[i], h.entries[j]) }
+func (h *entryHeap) Swap(i, j int)      { h.entries[i], h.entries[j] = h.entries[j], h.entries[i] }
+func (h *entryHeap) Push(x interface{}) { h.entries = append(h.entries, x.(*entry)) }
+
+func (h *entryHeap) Pop() interface{} {
+	last := h.entries[len(h.entries)-1]
+	h.entries[len(h.entries)-1] = nil
+	h.entries = h.entries[:len(h.entries)-1]
+	return last
+}
+
+func (h *entryHeap) peek() *entry {
+	if len(h.entries) == 0 {
+		return nil
+	}
+	return h.entries[0]
+}
+
+// jobQueue orders the pool's jobs: ready jobs by priority, first come first
+// served within a priority, and delayed jobs by when they become ready.
+//
+// With aging a ready job gains a priority level for every aging interval it
+// waits. Comparing two jobs at any moment then comes down to comparing
+// priority*aging - readyAt, which does not change as time passes, so the
+// jobs stay in a heap.
+type jobQueue struct {
+	// ready and bulk hold the ready jobs, split so the next job that is not
+	// bulk can be found while bulk jobs are held back
+	ready   entryHeap
+	bulk    entryHeap
+	delayed entryHeap
+	aging   time.Duration
+	seq     uint64
+}
+
+func newJobQueue(aging time.Duration) *jobQueue {
+	q := &jobQueue{aging: aging}
+	q.ready.less = q.before
+	q.bulk.less = q.before
+	q.delayed.less = func(a, b *entry) bool {
+		if !a.runAfter.Equal(b.runAfter) {
+			return a.runAfter.Before(b.runAfter)
+		}
+		return a.seq < b.seq
+	}
+	return q
+}
+
+// before reports whether ready job a runs before b
+func (q *jobQueue) before(a, b *entry) bool {
+	if q.aging > 0 {
+		ka := int64(a.priority)*int64(q.aging) - a.readyAt.UnixNano()
+		kb := int64(b.priority)*int64(q.aging) - b.readyAt.UnixNano()
+		if ka != kb {
+			return ka > kb
+		}
+	} else if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	return a.seq < b.seq
+}
+
+func (q *jobQueue) push(e *entry, now time.Time) {
+	q.seq++
+	e.seq = q.seq
+	if e.runAfter.After(now) {
+		heap.Push(&q.delayed, e)
+		return
+	}
+	e.readyAt = now
+	q.pushReady(e)
+}
+
+func (q *jobQueue) pushReady(e *entry) {
+	if e.bulk {
+		heap.Push(&q.bulk, e)
+		return
+	}
+	heap.Push(&q.ready, e)
+}
+
+// promote readies the delayed jobs that are due, aged from when they were due
+func (q *jobQueue) promote(now time.Time) {
+	for {
+		e := q.delayed.peek()
+		if e == nil || e.runAfter.After(now) {
+			return
+		}
+		heap.Pop(&q.delayed)
+		e.readyAt = e.runAfter
+		q.pushReady(e)
+	}
+}
+
+// pop takes the next ready job, leaving bulk jobs unless allowBulk
+func (q *jobQueue) pop(now time.Time, allowBulk bool) *entry {
+	q.promote(now)
+
+	next, bulk := q.ready.peek(), q.bulk.peek()
+	if bulk != nil && allowBulk && (next == nil || q.before(bulk, next)) {
+		return heap.Pop(&q.bulk).(*entry)
+	}
+	if next != nil {
+		return heap.Pop(&q.ready).(*entry)
+	}
+	return nil
+}
+
+// nextDue is when the first delayed job becomes ready
+func (q *jobQueue) nextDue() (time.Time, bool) {
+	e := q.delayed.peek()
+	if e == nil {
+		return time.Time{}, false
+	}
+	return e.runAfter, true
+}
+
+func (q *jobQueue) len() int {
+	return q.ready.Len() + q.bulk.Len() + q.delayed.Len()
+}
+
+// bulkLen counts the bulk jobs, delayed ones included
+func (q *jobQueue) bulkLen() int {
+	n := q.bulk.Len()
+	for _, e := range q.delayed.entries {
+		if e.bulk {
+			n++
+		}
+	}
+	return n
+}
+
+// drain empties the queue
+func (q *jobQueue) drain() []*entry {
+	entries := make([]*entry, 0, q.len())
+	entries = append(entries, q.ready.entries...)
+	entries = append(entries, q.bulk.entries...)
+	entries = append(entries, q.delayed.entries...)
+	q.ready.entries, q.bulk.entries, q.delayed.entries = nil, nil, nil
+	return entries
+}
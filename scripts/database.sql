@@ -22,7 +22,7 @@ CREATE TABLE users (
     password_hash VARCHAR(255) NOT NULL,
     first_name VARCHAR(100) NOT NULL,
     last_name VARCHAR(100) NOT NULL,
-    phone VARCHAR(20),
+    phone TEXT, -- encrypted
     role user_role DEFAULT 'customer',
     status user_status DEFAULT 'active',
     email_verified BOOLEAN DEFAULT FALSE,
@@ -0,0 +1,118 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/user"
+)
+
+type deletionPublisherStub struct {
+	events []user.DeletionEvent
+}
+
+func (p *deletionPublisherStub) PublishDeletion(ctx context.Context, event user.DeletionEvent) error {
+	p.events = append(p.events, event)
+	return nil
+}
+
+type erasureRepoStub struct {
+	users  map[string]*user.User
+	erased []*user.User
+}
+
+func (r *erasureRepoStub) ListDueForErasure(ctx context.Context, requestedBefore time.Time, limit int) ([]*user.User, error) {
+	var due []*user.User
+	for _, u := range r.users {
+		if u.ErasedAt == nil && u.DeletionRequestedAt != nil && !u.DeletionRequestedAt.After(requestedBefore) {
+			due = append(due, u)
+		}
+	}
+	return due, nil
+}
+
+func (r *erasureRepoStub) Erase(ctx context.Context, u *user.User) error {
+	r.erased = append(r.erased, u)
+	return nil
+}
+
+type deletionUserRepoStub struct {
+	user.Repository
+	users map[string]*user.User
+}
+
+func (r *deletionUserRepoStub) GetByID(ctx context.Context, id string) (*user.User, error) {
+	u, ok := r.users[id]
+	if !ok {
+		return nil, assert.AnError
+	}
+	return u, nil
+}
+
+func (r *deletionUserRepoStub) Update(ctx context.Context, u *user.User) error {
+	r.users[u.ID] = u
+	return nil
+}
+
+func TestRequestAccountDeletionDisablesAndSignsOut(t *testing.T) {
+	u, err := user.NewUser("jane@example.com", "secret123", "Jane", "Doe", "555-0100")
+	require.NoError(t, err)
+	users := &deletionUserRepoStub{users: map[string]*user.User{u.ID: u}}
+	sessions := newMemorySessionStore()
+	startTestSession(t, sessions, u.ID)
+	startTestSession(t, sessions, u.ID)
+	publisher := &deletionPublisherStub{}
+
+	handler := commands.NewRequestAccountDeletionCommandHandler(users, sessions, publisher, 30*24*time.Hour)
+	result, err := handler.Handle(context.Background(), commands.RequestAccountDeletionCommand{UserID: u.ID})
+	require.NoError(t, err)
+
+	assert.False(t, u.IsActive())
+	assert.Equal(t, user.StatusDeleted, u.Status)
+	assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), result.EraseAfter, time.Minute)
+	assert.Equal(t, "jane@example.com", u.Email, "data is kept during the grace period")
+
+	remaining, err := sessions.ListByUser(context.Background(), u.ID)
+	require.NoError(t, err)
+	assert.Empty(t, remaining)
+
+	require.Len(t, publisher.events, 1)
+	assert.Equal(t, user.EventDeletionRequested, publisher.events[0].Type)
+
+	_, err = handler.Handle(context.Background(), commands.RequestAccountDeletionCommand{UserID: u.ID})
+	assert.ErrorIs(t, err, user.ErrDeletionPending)
+}
+
+func TestEraseAccountsAfterGracePeriod(t *testing.T) {
+	due, err := user.NewUser("due@example.com", "secret123", "Due", "User", "555-0101")
+	require.NoError(t, err)
+	require.NoError(t, due.RequestDeletion(time.Now().Add(-31*24*time.Hour)))
+	recent, err := user.NewUser("recent@example.com", "secret123", "Recent", "User", "")
+	require.NoError(t, err)
+	require.NoError(t, recent.RequestDeletion(time.Now().Add(-time.Hour)))
+
+	repo := &erasureRepoStub{users: map[string]*user.User{due.ID: due, recent.ID: recent}}
+	publisher := &deletionPublisherStub{}
+
+	erased, err := commands.NewEraseAccountsCommandHandler(repo, publisher).Handle(context.Background(), commands.EraseAccountsCommand{GracePeriod: 30 * 24 * time.Hour})
+	require.NoError(t, err)
+	assert.Equal(t, 1, erased)
+
+	require.Len(t, repo.erased, 1)
+	assert.Equal(t, due.ID, repo.erased[0].ID)
+	assert.Equal(t, "erased-"+due.ID+"@erased.invalid", due.Email)
+	assert.Empty(t, due.FirstName)
+	assert.Empty(t, due.Phone)
+	assert.Empty(t, due.Password)
+	assert.NotNil(t, due.ErasedAt)
+	assert.Equal(t, "recent@example.com", recent.Email)
+
+	require.Len(t, publisher.events, 1)
+	assert.Equal(t, user.EventErased, publisher.events[0].Type)
+	assert.Equal(t, due.ID, publisher.events[0].UserID)
+}
@@ -0,0 +1,108 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/export"
+	"online-shop/internal/infrastructure/spreadsheet"
+)
+
+func accountingEntries() []export.Entry {
+	return []export.Entry{
+		{Type: export.EntryRefund, Date: time.Date(2026, 3, 5, 9, 0, 0, 0, time.UTC), OrderID: "o1", OrderNumber: "ORD-2026-000001", TransactionID: "trx-1", Description: "Refund of order ORD-2026-000001", Amount: 55500, Currency: "IDR"},
+		{Type: export.EntrySale, Date: time.Date(2026, 3, 1, 20, 0, 0, 0, time.UTC), OrderID: "o1", OrderNumber: "ORD-2026-000001", InvoiceNumber: "INV-20260301-ORD-2026-000001", CustomerName: "Budi Santoso", CustomerEmail: "budi@example.com", Description: "Order ORD-2026-000001", Status: "refunded", Amount: 111000},
+		{Type: export.EntryPayment, Date: time.Date(2026, 3, 2, 1, 0, 0, 0, time.UTC), OrderID: "o1", TransactionID: "trx-1", Method: "e_wallet", Amount: 111000, Currency: "IDR"},
+	}
+}
+
+func TestAccountingLayoutsSplitOutTax(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	require.NoError(t, err)
+	accounting, err := export.NewAccounting(0.11, "IDR", jakarta, nil)
+	require.NoError(t, err)
+
+	layout, err := accounting.Layout("")
+	require.NoError(t, err)
+	assert.Equal(t, export.DefaultLayout, layout.Name)
+	table := accounting.Table(layout, accountingEntries())
+	require.Len(t, table.Rows, 3)
+	column := func(row []string, header string) string {
+		for i, h := range table.Header {
+			if h == header {
+				return row[i]
+			}
+		}
+		t.Fatalf("no %s column", header)
+		return ""
+	}
+	assert.Equal(t, []string{"sale", "payment", "refund"}, []string{table.Rows[0][0], table.Rows[1][0], table.Rows[2][0]}, "oldest first")
+	assert.Equal(t, "11000.00", column(table.Rows[0], "tax_amount"))
+	assert.Equal(t, "100000.00", column(table.Rows[0], "net_amount"))
+	assert.Equal(t, "IDR", column(table.Rows[0], "currency"), "the shop's currency when the entry has none")
+	assert.Equal(t, "0.00", column(table.Rows[1], "tax_amount"), "payments carry no tax of their own")
+	assert.Equal(t, "5500.00", column(table.Rows[2], "tax_amount"))
+	assert.Equal(t, "trx-1", column(table.Rows[2], "reference"))
+
+	xero, err := accounting.Layout("xero")
+	require.NoError(t, err)
+	table = accounting.Table(xero, accountingEntries())
+	require.Len(t, table.Rows, 1, "only sales are imported as invoices")
+	assert.Equal(t, []string{"Budi Santoso", "budi@example.com", "INV-20260301-ORD-2026-000001", "ORD-2026-000001", "02/03/2026", "02/03/2026", "Order ORD-2026-000001", "1", "111000.00", "200", "11000.00", "IDR"}, table.Rows[0], "dated in Jakarta, where it was already the 2nd")
+
+	_, err = accounting.Layout("myob")
+	assert.Error(t, err)
+}
+
+func TestCustomAccountingLayout(t *testing.T) {
+	accounting, err := export.NewAccounting(0, "IDR", time.UTC, []export.Layout{{
+		Name:       "ledger",
+		Entries:    []export.EntryType{export.EntryPayment, export.EntryRefund},
+		Delimiter:  ';',
+		DateFormat: "2006-01-02",
+		Columns:    []export.Column{{Header: "Date", Field: "date"}, {Header: "Account", Value: "1100"}, {Header: "Amount", Field: "amount"}, {Header: "Tax", Field: "tax_amount"}},
+	}})
+	require.NoError(t, err)
+	layout, err := accounting.Layout("ledger")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, spreadsheet.NewCSVEncoder().Encode(&buf, accounting.Table(layout, accountingEntries())))
+	assert.Equal(t, "Date;Account;Amount;Tax\n2026-03-02;1100;111000.00;0.00\n2026-03-05;1100;55500.00;0.00\n", buf.String())
+
+	_, err = export.NewAccounting(0, "IDR", time.UTC, []export.Layout{{Name: "broken", Columns: []export.Column{{Header: "Profit", Field: "profit"}}}})
+	assert.Error(t, err)
+	_, err = export.NewAccounting(1.1, "IDR", time.UTC, nil)
+	assert.Error(t, err)
+}
+
+func TestRequestAccountingExport(t *testing.T) {
+	accounting, err := export.NewAccounting(0, "IDR", time.UTC, nil)
+	require.NoError(t, err)
+	publisher := &exportPublisherStub{}
+	handler := commands.NewRequestExportCommandHandler(&exportRepoStub{exports: map[string]*export.Export{}}, publisher, accounting)
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	cmd := commands.RequestExportCommand{RequestedBy: "admin-1", Type: export.TypeAccounting, Format: export.FormatCSV}
+
+	_, err = handler.Handle(context.Background(), cmd)
+	assert.ErrorIs(t, err, commands.ErrInvalidExportData, "accounting exports cover a period")
+
+	cmd.From, cmd.To, cmd.Layout = from, from.AddDate(0, 1, 0), "myob"
+	_, err = handler.Handle(context.Background(), cmd)
+	assert.ErrorIs(t, err, commands.ErrInvalidExportData)
+
+	cmd.Layout = ""
+	e, err := handler.Handle(context.Background(), cmd)
+	require.NoError(t, err)
+	assert.Equal(t, export.DefaultLayout, e.Layout)
+	assert.Equal(t, []string{e.ID}, publisher.queued)
+
+	_, err = handler.Handle(context.Background(), commands.RequestExportCommand{RequestedBy: "admin-1", Type: export.TypeOrders, Format: export.FormatCSV, Layout: "xero"})
+	assert.ErrorIs(t, err, commands.ErrInvalidExportData, "only accounting exports have a layout")
+}
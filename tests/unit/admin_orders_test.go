@@ -0,0 +1,146 @@
+package unit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/user"
+	"online-shop/pkg/pagination"
+)
+
+type listingOrderRepo struct {
+	order.Repository
+	orders   []*order.Order
+	searches int
+	filter   order.ListFilter
+	keys     []order.SortKey
+	after    order.Position
+	limit    int
+}
+
+func (r *listingOrderRepo) Search(ctx context.Context, filter order.ListFilter, keys []order.SortKey, after order.Position, limit int) ([]*order.Order, error) {
+	r.searches++
+	r.filter, r.keys, r.after, r.limit = filter, keys, after, limit
+	if limit < len(r.orders) {
+		return r.orders[:limit], nil
+	}
+	return r.orders, nil
+}
+
+func (r *listingOrderRepo) ListByFilter(ctx context.Context, filter order.ListFilter, limit, offset int) ([]*order.Order, int64, error) {
+	r.searches++
+	r.filter, r.limit = filter, limit
+	if offset >= len(r.orders) {
+		return []*order.Order{}, int64(len(r.orders)), nil
+	}
+	page := r.orders[offset:]
+	if limit < len(page) {
+		page = page[:limit]
+	}
+	return page, int64(len(r.orders)), nil
+}
+
+func TestParseOrderSort(t *testing.T) {
+	keys, err := order.ParseSort("")
+	require.NoError(t, err)
+	assert.Equal(t, order.DefaultSort, keys)
+
+	keys, err = order.ParseSort("-total_amount, created_at")
+	require.NoError(t, err)
+	assert.Equal(t, []order.SortKey{
+		{Field: order.SortTotalAmount, Descending: true},
+		{Field: order.SortCreatedAt},
+	}, keys)
+	assert.Equal(t, "-total_amount,created_at", order.FormatSort(keys))
+
+	for _, bad := range []string{"user_id", "created_at,-created_at", "-"} {
+		_, err := order.ParseSort(bad)
+		assert.ErrorIs(t, err, order.ErrInvalidListing, bad)
+	}
+
+	statuses, err := order.ParseStatuses("pending, shipped")
+	require.NoError(t, err)
+	assert.Equal(t, []order.Status{order.StatusPending, order.StatusShipped}, statuses)
+	_, err = order.ParseStatuses("pending,lost")
+	assert.ErrorIs(t, err, order.ErrInvalidListing)
+}
+
+func TestOrderPositionRoundTrip(t *testing.T) {
+	placed := time.Date(2026, 3, 14, 9, 26, 53, 589793000, time.UTC)
+	o := &order.Order{ID: "o1", CreatedAt: placed, TotalAmount: 125000.5, Status: order.StatusConfirmed}
+	keys := []order.SortKey{{Field: order.SortCreatedAt, Descending: true}, {Field: order.SortTotalAmount}, {Field: order.SortStatus}}
+
+	values, err := order.PositionOf(o, keys).Values(keys)
+	require.NoError(t, err)
+	assert.True(t, placed.Equal(values[0].(time.Time)))
+	assert.Equal(t, 125000.5, values[1])
+	assert.Equal(t, string(o.Status), values[2])
+	assert.Equal(t, "o1", values[3], "the ID breaks ties")
+
+	_, err = order.PositionOf(o, keys).Values(order.DefaultSort)
+	assert.ErrorIs(t, err, order.ErrInvalidListing)
+}
+
+func TestListOrdersPagesByKeyset(t *testing.T) {
+	var orders []*order.Order
+	for i := 0; i < 3; i++ {
+		orders = append(orders, &order.Order{ID: fmt.Sprintf("o%d", i), CreatedAt: time.Now().Add(-time.Duration(i) * time.Hour)})
+	}
+	repo := &listingOrderRepo{orders: orders}
+	users := &oauthUserRepoStub{users: map[string]*user.User{"u1": {ID: "u1", Email: "budi@example.com"}}}
+	handler := queries.NewListOrdersQueryHandler(repo, users)
+
+	list, err := handler.Handle(context.Background(), queries.ListOrdersQuery{Email: " budi@example.com ", Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, "u1", repo.filter.UserID)
+	assert.Equal(t, 3, repo.limit, "one more than the page is read to see if another follows")
+	assert.Nil(t, repo.after)
+	require.Len(t, list.Orders, 2)
+	require.NotEmpty(t, list.NextCursor)
+
+	_, err = handler.Handle(context.Background(), queries.ListOrdersQuery{Cursor: list.NextCursor, Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, order.PositionOf(orders[1], order.DefaultSort), repo.after)
+
+	list, err = handler.Handle(context.Background(), queries.ListOrdersQuery{Limit: 5})
+	require.NoError(t, err)
+	assert.Len(t, list.Orders, 3)
+	assert.Empty(t, list.NextCursor, "the last page has no cursor")
+
+	_, err = handler.Handle(context.Background(), queries.ListOrdersQuery{Cursor: "not-a-cursor", Limit: 2})
+	assert.ErrorIs(t, err, pagination.ErrInvalidCursor)
+	cursor := pagination.EncodeKeyset(order.FormatSort(order.DefaultSort), []string{"x", "o1"})
+	_, err = handler.Handle(context.Background(), queries.ListOrdersQuery{Cursor: cursor, Sort: "total_amount", Limit: 2})
+	assert.ErrorIs(t, err, pagination.ErrInvalidCursor, "a cursor only resumes the sort it was made for")
+
+	searches := repo.searches
+	list, err = handler.Handle(context.Background(), queries.ListOrdersQuery{Email: "nobody@example.com", Limit: 2})
+	require.NoError(t, err)
+	assert.Empty(t, list.Orders)
+	assert.Equal(t, searches, repo.searches, "an email without an account has no orders")
+}
+
+func TestUserOrdersAreFilteredAndCountedByTheRepository(t *testing.T) {
+	repo := &listingOrderRepo{orders: []*order.Order{{ID: "o1"}, {ID: "o2"}, {ID: "o3"}}}
+	handler := queries.NewGetUserOrdersQueryHandler(repo)
+
+	page, err := handler.Handle(context.Background(), queries.GetUserOrdersQuery{
+		UserID: "u1",
+		Filter: order.ListFilter{UserID: "u2", Statuses: []order.Status{order.StatusShipped}, ProductName: "kopi"},
+		Limit:  2,
+		Offset: 2,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "u1", repo.filter.UserID, "a customer only searches their own orders")
+	assert.Equal(t, []order.Status{order.StatusShipped}, repo.filter.Statuses)
+	assert.Equal(t, "kopi", repo.filter.ProductName)
+	assert.Len(t, page.Orders, 1)
+	assert.EqualValues(t, 3, page.Total)
+}
@@ -0,0 +1,64 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/domain/analytics"
+)
+
+func TestReportRangeNormalize(t *testing.T) {
+	var r analytics.ReportRange
+	require.NoError(t, r.Normalize())
+	assert.Equal(t, analytics.IntervalDay, r.Interval)
+	assert.Equal(t, r.To.AddDate(0, 0, -30), r.From)
+
+	now := time.Now()
+	inverted := analytics.ReportRange{From: now, To: now.Add(-time.Hour)}
+	assert.ErrorIs(t, inverted.Normalize(), analytics.ErrInvalidRange)
+
+	tooLong := analytics.ReportRange{From: now.AddDate(-2, 0, 0), To: now}
+	assert.ErrorIs(t, tooLong.Normalize(), analytics.ErrInvalidRange)
+
+	unknown := analytics.ReportRange{Interval: "hour"}
+	assert.ErrorIs(t, unknown.Normalize(), analytics.ErrInvalidRange)
+}
+
+func TestBuildFunnel(t *testing.T) {
+	funnel := analytics.BuildFunnel(analytics.FunnelSteps, map[string]int64{
+		analytics.EventProductViewed:      200,
+		analytics.EventProductAddedToCart: 50,
+		analytics.EventProductPurchased:   10,
+	})
+
+	require.Len(t, funnel.Steps, 3)
+	assert.Equal(t, 1.0, funnel.Steps[0].Rate)
+	assert.InDelta(t, 0.25, funnel.Steps[1].Rate, 0.0001)
+	assert.InDelta(t, 0.2, funnel.Steps[2].Rate, 0.0001)
+	assert.InDelta(t, 0.05, funnel.Conversion, 0.0001)
+
+	empty := analytics.BuildFunnel(analytics.FunnelSteps, nil)
+	assert.Zero(t, empty.Conversion)
+}
+
+func TestBuildCohorts(t *testing.T) {
+	jan := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	feb := jan.AddDate(0, 1, 0)
+	mar := jan.AddDate(0, 2, 0)
+
+	cohorts := analytics.BuildCohorts([]analytics.CohortCell{
+		{Cohort: feb, Period: feb, Users: 5},
+		{Cohort: jan, Period: jan, Users: 10},
+		{Cohort: jan, Period: mar, Users: 2},
+		{Cohort: jan, Period: feb, Users: 4},
+	}, analytics.IntervalMonth)
+
+	require.Len(t, cohorts, 2)
+	assert.Equal(t, jan, cohorts[0].Cohort)
+	assert.Equal(t, int64(10), cohorts[0].Size)
+	assert.Equal(t, []float64{1, 0.4, 0.2}, cohorts[0].Retention)
+	assert.Equal(t, []float64{1}, cohorts[1].Retention)
+}
@@ -0,0 +1,129 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/interfaces/http/apiv2"
+	"online-shop/internal/interfaces/http/middleware"
+	"online-shop/pkg/pagination"
+)
+
+// keysetProductRepo lists its products, which are newest first, after a
+// position
+type keysetProductRepo struct {
+	product.Repository
+	products []*product.Product
+}
+
+func (r *keysetProductRepo) ListAfter(ctx context.Context, filter product.SearchFilter, after *product.Position, limit int) ([]*product.Product, error) {
+	var list []*product.Product
+	for _, p := range r.products {
+		if after != nil && !p.CreatedAt.Before(after.CreatedAt) {
+			continue
+		}
+		if len(list) < limit {
+			list = append(list, p)
+		}
+	}
+	return list, nil
+}
+
+func TestListProductsPagesByKeyset(t *testing.T) {
+	now := time.Now()
+	repo := &keysetProductRepo{}
+	for i := 0; i < 5; i++ {
+		repo.products = append(repo.products, &product.Product{ID: string(rune('a' + i)), CreatedAt: now.Add(-time.Duration(i) * time.Hour)})
+	}
+	handler := queries.NewListProductsQueryHandler(repo)
+
+	first, err := handler.Handle(context.Background(), queries.ListProductsQuery{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, first.Products, 2)
+	assert.Equal(t, "a", first.Products[0].ID)
+	require.NotEmpty(t, first.NextCursor)
+
+	second, err := handler.Handle(context.Background(), queries.ListProductsQuery{Limit: 2, Cursor: first.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, second.Products, 2)
+	assert.Equal(t, "c", second.Products[0].ID)
+
+	last, err := handler.Handle(context.Background(), queries.ListProductsQuery{Limit: 2, Cursor: second.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, last.Products, 1)
+	assert.Empty(t, last.NextCursor)
+
+	// An offset cursor from a v1 list is not a position
+	_, err = handler.Handle(context.Background(), queries.ListProductsQuery{Cursor: pagination.EncodeCursor(20)})
+	assert.ErrorIs(t, err, pagination.ErrInvalidCursor)
+}
+
+func TestMapProductV2(t *testing.T) {
+	p := &product.Product{
+		ID:       "p-1",
+		Name:     "Kopi",
+		Price:    50000,
+		Stock:    0,
+		Category: &product.Category{ID: "c-1", Slug: "drinks", Name: "Drinks"},
+		FlashSale: &product.FlashSaleOffer{
+			SaleID:        "s-1",
+			Price:         40000,
+			OriginalPrice: 50000,
+		},
+	}
+
+	dto := apiv2.MapProduct(p)
+	assert.Equal(t, apiv2.Money{Amount: 40000, Currency: "IDR"}, dto.Price)
+	assert.Equal(t, apiv2.Money{Amount: 50000, Currency: "IDR"}, dto.FlashSale.OriginalPrice)
+	assert.False(t, dto.Availability.InStock)
+	assert.Equal(t, "drinks", dto.Category.Slug)
+	assert.NotNil(t, dto.Images)
+}
+
+func TestMapOrderV2(t *testing.T) {
+	at := time.Now()
+	o := &order.Order{
+		ID:          "o-1",
+		Status:      order.StatusCancelled,
+		TotalAmount: 100000,
+		Items:       []order.OrderItem{{ProductID: "p-1", Quantity: 2, Price: 50000, Subtotal: 100000, CommissionAmount: 5000}},
+		Cancellation: order.Cancellation{
+			Reason:   order.CancelReason("changed_mind"),
+			Refunded: 100000,
+			At:       &at,
+		},
+	}
+
+	dto := apiv2.MapOrder(o)
+	assert.Equal(t, apiv2.Money{Amount: 100000, Currency: "IDR"}, dto.Total)
+	require.Len(t, dto.Items, 1)
+	assert.Equal(t, 50000.0, dto.Items[0].UnitPrice.Amount)
+	require.NotNil(t, dto.Cancellation)
+	assert.Equal(t, 100000.0, dto.Cancellation.Refunded.Amount)
+	assert.NotNil(t, dto.Shipments)
+
+	o.Status = order.StatusConfirmed
+	assert.Nil(t, apiv2.MapOrder(o).Cancellation)
+}
+
+func TestAPIVersionHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/v2/ping", middleware.APIVersion(middleware.APIv2), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v2/ping", nil))
+	assert.Equal(t, "v2", w.Header().Get(middleware.APIVersionHeader))
+}
@@ -0,0 +1,149 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/analytics"
+	"online-shop/internal/interfaces/http/middleware"
+	"online-shop/pkg/apperror"
+)
+
+func TestFromKeepsCodedErrors(t *testing.T) {
+	err := fmt.Errorf("create order: %w", commands.ErrInsufficientStock.WithMeta("product_id", "p-1"))
+
+	e := apperror.From(err)
+	assert.Equal(t, "insufficient_stock", e.Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, e.Kind.HTTPStatus())
+	assert.Equal(t, "p-1", e.Meta["product_id"])
+
+	assert.ErrorIs(t, err, commands.ErrInsufficientStock, "copies match the catalog entry")
+	assert.NotErrorIs(t, err, commands.ErrProductNotFound)
+	assert.Empty(t, commands.ErrInsufficientStock.Meta, "WithMeta does not change the catalog entry")
+}
+
+func TestFromMapsDomainAndContextErrors(t *testing.T) {
+	e := apperror.From(fmt.Errorf("sales report: %w", analytics.ErrInvalidRange))
+	assert.Equal(t, commands.ErrInvalidReportRange.Code, e.Code)
+	assert.ErrorIs(t, e, analytics.ErrInvalidRange, "the mapped error keeps its cause")
+
+	assert.Equal(t, apperror.ErrTimeout.Code, apperror.From(context.DeadlineExceeded).Code)
+	assert.Nil(t, apperror.From(nil))
+}
+
+func TestFromHidesUncodedErrors(t *testing.T) {
+	cause := errors.New("pq: connection refused to 10.0.0.5")
+
+	e := apperror.From(cause)
+	assert.Equal(t, apperror.ErrInternal.Code, e.Code)
+	assert.NotContains(t, e.Error(), "10.0.0.5")
+	assert.ErrorIs(t, e, cause)
+
+	problem := e.Problem("/api/v1/orders")
+	assert.Equal(t, http.StatusInternalServerError, problem.Status)
+	assert.Empty(t, problem.Detail)
+}
+
+func TestProblemRendering(t *testing.T) {
+	problem := commands.ErrOrderNotFound.WithDetail("order %s does not exist", "o-1").Problem("/api/v1/orders/o-1")
+
+	assert.Equal(t, "/errors/order_not_found", problem.Type)
+	assert.Equal(t, commands.ErrOrderNotFound.Message, problem.Title)
+	assert.Equal(t, http.StatusNotFound, problem.Status)
+	assert.Equal(t, "order o-1 does not exist", problem.Detail)
+	assert.Equal(t, "/api/v1/orders/o-1", problem.Instance)
+	assert.Equal(t, "order_not_found", problem.Code)
+}
+
+func TestGRPCStatusCarriesCode(t *testing.T) {
+	err := apperror.ErrValidationFailed.WithFields([]apperror.FieldError{
+		{Field: "email", Code: "invalid_email", Message: "email must be a valid email address"},
+	})
+
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+
+	var info *errdetails.ErrorInfo
+	var badRequest *errdetails.BadRequest
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			info = d
+		case *errdetails.BadRequest:
+			badRequest = d
+		}
+	}
+	require.NotNil(t, info)
+	assert.Equal(t, "validation_failed", info.Reason)
+	assert.Equal(t, apperror.Domain, info.Domain)
+	require.NotNil(t, badRequest)
+	assert.Equal(t, "email", badRequest.FieldViolations[0].Field)
+
+	back := apperror.FromStatus(st.Err())
+	assert.ErrorIs(t, back, apperror.ErrValidationFailed)
+
+	unknown := apperror.FromStatus(status.Error(codes.NotFound, "no such thing"))
+	assert.Equal(t, apperror.KindNotFound, unknown.Kind)
+}
+
+func TestCatalogIsSortedAndUnique(t *testing.T) {
+	entries := apperror.Describe()
+	require.NotEmpty(t, entries)
+
+	seen := map[string]bool{}
+	for i, entry := range entries {
+		assert.False(t, seen[entry.Code], "duplicate code %s", entry.Code)
+		seen[entry.Code] = true
+		assert.Regexp(t, `^[a-z][a-z0-9_]*$`, entry.Code)
+		assert.NotZero(t, entry.HTTPStatus)
+		if i > 0 {
+			assert.Less(t, entries[i-1].Code, entry.Code)
+		}
+	}
+	assert.True(t, seen["user_not_found"])
+
+	assert.Panics(t, func() { apperror.Define(apperror.KindNotFound, "user_not_found", "again") })
+}
+
+func TestAuthFailuresAreProblems(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.Errors())
+	router.GET("/profile", middleware.NewAuthMiddleware(nil, nil).RequireAuth(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/fail", func(c *gin.Context) {
+		c.Error(errors.New("boom"))
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/profile", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, apperror.ProblemContentType, w.Header().Get("Content-Type"))
+
+	var problem apperror.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, middleware.ErrAuthRequired.Code, problem.Code)
+	assert.Equal(t, "/profile", problem.Instance)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fail", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, apperror.ErrInternal.Code, problem.Code)
+	assert.NotContains(t, w.Body.String(), "boom")
+}
@@ -0,0 +1,80 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/audit"
+)
+
+type auditRepoStub struct {
+	limit int
+}
+
+func (r *auditRepoStub) Create(ctx context.Context, entry *audit.Entry) error {
+	return nil
+}
+
+func (r *auditRepoStub) List(ctx context.Context, filter audit.Filter, limit, offset int) ([]*audit.Entry, int64, error) {
+	r.limit = limit
+	return []*audit.Entry{audit.NewEntry(audit.SourceHTTP, "", "POST /admin/products", "products", "")}, 1, nil
+}
+
+func TestDiffReportsChangedColumns(t *testing.T) {
+	shipped := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	before := map[string]interface{}{
+		"price":      100.0,
+		"status":     "pending",
+		"password":   "old-hash",
+		"shipped_at": shipped,
+		"updated_at": shipped,
+	}
+	after := map[string]interface{}{
+		"price":      120.0,
+		"status":     "pending",
+		"password":   "new-hash",
+		"shipped_at": shipped.In(time.FixedZone("WIB", 7*3600)),
+		"updated_at": shipped.Add(time.Minute),
+	}
+
+	changes := audit.Diff(before, after)
+
+	require.Len(t, changes, 2)
+	assert.Equal(t, audit.Change{Before: 100.0, After: 120.0}, changes["price"])
+	assert.NotEqual(t, "new-hash", changes["password"].After, "redacted values are not logged")
+}
+
+func TestDiffOfDeletedRow(t *testing.T) {
+	changes := audit.Diff(map[string]interface{}{"name": "Phones"}, nil)
+
+	require.Contains(t, changes, "name")
+	assert.Equal(t, "Phones", changes["name"].Before)
+	assert.Nil(t, changes["name"].After)
+}
+
+func TestActorFromContext(t *testing.T) {
+	_, ok := audit.ActorFromContext(context.Background())
+	assert.False(t, ok)
+
+	ctx := audit.WithActor(context.Background(), audit.Actor{ID: "admin-1", Role: "admin", RequestID: "req-1"})
+	actor, ok := audit.ActorFromContext(ctx)
+	require.True(t, ok)
+	assert.Equal(t, "admin-1", actor.ID)
+
+	assert.Equal(t, audit.SystemActor, audit.NewEntry(audit.SourceRepository, "", "orders.update", "orders", "o1").ActorID)
+}
+
+func TestListAuditLogsCapsLimit(t *testing.T) {
+	repo := &auditRepoStub{}
+	handler := queries.NewListAuditLogsQueryHandler(repo)
+
+	page, err := handler.Handle(context.Background(), queries.ListAuditLogsQuery{Limit: 1000})
+	require.NoError(t, err)
+	assert.Equal(t, 200, repo.limit)
+	assert.Equal(t, int64(1), page.Total)
+}
@@ -0,0 +1,166 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/backup"
+	"online-shop/pkg/scheduler"
+)
+
+type backupRepoStub struct {
+	backups map[string]*backup.Backup
+}
+
+func newBackupRepoStub(backups ...*backup.Backup) *backupRepoStub {
+	r := &backupRepoStub{backups: map[string]*backup.Backup{}}
+	for _, b := range backups {
+		r.backups[b.ID] = b
+	}
+	return r
+}
+
+func (r *backupRepoStub) Create(ctx context.Context, b *backup.Backup) error {
+	r.backups[b.ID] = b
+	return nil
+}
+
+func (r *backupRepoStub) GetByID(ctx context.Context, id string) (*backup.Backup, error) {
+	if b, ok := r.backups[id]; ok {
+		return b, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func (r *backupRepoStub) Update(ctx context.Context, b *backup.Backup) error {
+	r.backups[b.ID] = b
+	return nil
+}
+
+func (r *backupRepoStub) List(ctx context.Context, limit, offset int) ([]*backup.Backup, error) {
+	return nil, nil
+}
+
+func (r *backupRepoStub) ListByStatus(ctx context.Context, status backup.Status) ([]*backup.Backup, error) {
+	var found []*backup.Backup
+	for _, b := range r.backups {
+		if b.Status == status {
+			found = append(found, b)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].CreatedAt.Before(found[j].CreatedAt) })
+	return found, nil
+}
+
+func (r *backupRepoStub) Latest(ctx context.Context, status backup.Status) (*backup.Backup, error) {
+	found, _ := r.ListByStatus(ctx, status)
+	if len(found) == 0 {
+		return nil, nil
+	}
+	return found[len(found)-1], nil
+}
+
+type dumperStub struct {
+	err error
+}
+
+func (d dumperStub) Dump(ctx context.Context) ([]byte, error) {
+	return []byte("PGDMP"), d.err
+}
+
+func (d dumperStub) Extension() string {
+	return "dump"
+}
+
+type schedulerStub struct {
+	triggered []string
+}
+
+func (s *schedulerStub) Trigger(name string) error {
+	s.triggered = append(s.triggered, name)
+	return nil
+}
+
+func completedBackup(age time.Duration) *backup.Backup {
+	b := backup.NewBackup(backup.TriggerScheduled, "")
+	b.CreatedAt = time.Now().Add(-age)
+	b.Complete("backups/shop-"+b.ID+".dump", 10)
+	return b
+}
+
+func TestExpiredKeepsNewestBackups(t *testing.T) {
+	day := 24 * time.Hour
+	old1, old2, old3 := completedBackup(40*day), completedBackup(30*day), completedBackup(20*day)
+
+	expired := backup.Expired([]*backup.Backup{old2, old1, old3}, time.Now(), 7*day, 2)
+
+	require.Len(t, expired, 1)
+	assert.Equal(t, old1.ID, expired[0].ID)
+}
+
+func TestTriggerBackupRejectsConcurrentRuns(t *testing.T) {
+	repo := newBackupRepoStub()
+	jobs := &schedulerStub{}
+	handler := commands.NewTriggerBackupCommandHandler(repo, jobs)
+
+	b, err := handler.Handle(context.Background(), commands.TriggerBackupCommand{RequestedBy: "admin-1"})
+	require.NoError(t, err)
+	assert.Equal(t, backup.StatusPending, b.Status)
+	assert.Equal(t, []string{backup.JobName}, jobs.triggered)
+
+	_, err = handler.Handle(context.Background(), commands.TriggerBackupCommand{RequestedBy: "admin-1"})
+	assert.ErrorIs(t, err, commands.ErrBackupInProgress)
+}
+
+func TestRunBackupUploadsAndAppliesRetention(t *testing.T) {
+	day := 24 * time.Hour
+	stale := completedBackup(60 * day)
+	pending := backup.NewBackup(backup.TriggerManual, "admin-1")
+	repo := newBackupRepoStub(stale, pending)
+	store := &memoryStorage{objects: map[string][]byte{stale.ObjectKey: []byte("old")}}
+
+	handler := commands.NewRunBackupCommandHandler(repo, dumperStub{}, store)
+	ran, err := handler.Handle(context.Background(), commands.RunBackupCommand{Database: "shop", Retention: 30 * day, Keep: 1})
+	require.NoError(t, err)
+
+	require.Len(t, ran, 1)
+	assert.Equal(t, backup.StatusCompleted, pending.Status)
+	assert.Equal(t, []byte("PGDMP"), store.objects[pending.ObjectKey])
+	assert.Equal(t, backup.StatusExpired, stale.Status)
+	assert.NotContains(t, store.objects, stale.ObjectKey)
+}
+
+func TestRunBackupRecordsFailure(t *testing.T) {
+	repo := newBackupRepoStub()
+	handler := commands.NewRunBackupCommandHandler(repo, dumperStub{err: errors.New("connection refused")}, &memoryStorage{objects: map[string][]byte{}})
+
+	ran, err := handler.Handle(context.Background(), commands.RunBackupCommand{Database: "shop", Interval: time.Hour})
+	assert.Error(t, err)
+
+	require.Len(t, ran, 1, "no completed backup yet, so a scheduled one is started")
+	assert.Equal(t, backup.StatusFailed, ran[0].Status)
+	assert.Equal(t, "connection refused", ran[0].Error)
+}
+
+func TestSchedulerTriggerRunsTaskEarly(t *testing.T) {
+	var runs int32
+	jobs := scheduler.New(nil)
+	jobs.Every("backup", time.Hour, func(ctx context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	})
+	jobs.Start(context.Background())
+	defer jobs.Stop()
+
+	require.NoError(t, jobs.Trigger("backup"))
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&runs) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Error(t, jobs.Trigger("missing"))
+}
@@ -0,0 +1,163 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/badge"
+	"online-shop/internal/domain/flashsale"
+	"online-shop/internal/domain/product"
+	"online-shop/pkg/apperror"
+)
+
+func TestBadgeDefinitions(t *testing.T) {
+	definitions := badge.Definitions([]*badge.Definition{
+		{Code: badge.LowStock, Label: "Almost gone", Enabled: true, Priority: 5, Threshold: 3},
+		{Code: "eco", Label: "Eco", Enabled: true, Computed: true, Priority: 60},
+	})
+
+	codes := make([]string, len(definitions))
+	for i, d := range definitions {
+		codes[i] = d.Code
+	}
+	assert.Equal(t, []string{badge.LowStock, badge.Sale, badge.New, badge.BestSeller, badge.FreeShipping, "eco"}, codes)
+	assert.Equal(t, "Almost gone", definitions[0].Label)
+	assert.True(t, definitions[0].Computed, "a built-in badge keeps its rule")
+	assert.False(t, definitions[5].Computed, "a badge of their own has no rule")
+
+	freeShipping, err := badge.Find(definitions, badge.FreeShipping)
+	require.NoError(t, err)
+	freeShipping.Enabled = true
+	assert.True(t, errors.Is(freeShipping.Check(), badge.ErrInvalidBadge), "free shipping needs a price")
+
+	_, err = badge.NewDefinition("new", "New!", 0)
+	assert.True(t, errors.Is(err, badge.ErrInvalidBadge), "built-in code")
+	_, err = badge.NewDefinition("Eco Friendly", "Eco", 0)
+	assert.True(t, errors.Is(err, badge.ErrInvalidBadge), "code with spaces")
+}
+
+func TestBadgeEvaluate(t *testing.T) {
+	now := time.Now()
+	definitions := badge.Definitions([]*badge.Definition{
+		{Code: badge.FreeShipping, Label: "Free Shipping", Enabled: true, Priority: 40, MinPrice: 100},
+		{Code: "eco", Label: "Eco", Enabled: true, Priority: 60},
+	})
+	p := &product.Product{ID: "p1", Price: 150, Stock: 2, CreatedAt: now.AddDate(0, 0, -40)}
+	expired := now.Add(-time.Minute)
+	assignments := []*badge.Assignment{
+		{ProductID: "p1", Code: "eco"},
+		{ProductID: "p1", Code: badge.New, ExpiresAt: &expired},
+		{ProductID: "p2", Code: badge.BestSeller},
+	}
+	facts := badge.Facts{OnSale: map[string]bool{"p1": true}}
+
+	badges := badge.Evaluate(definitions, p, assignments, facts, now)
+	assert.Equal(t, []product.Badge{
+		{Code: badge.Sale, Label: "Sale"},
+		{Code: badge.FreeShipping, Label: "Free Shipping"},
+		{Code: badge.LowStock, Label: "Low Stock"},
+		{Code: "eco", Label: "Eco"},
+	}, badges)
+
+	p.Stock = 0
+	p.CreatedAt = now
+	badges = badge.Evaluate(definitions, p, nil, badge.Facts{}, now)
+	assert.Equal(t, []product.Badge{{Code: badge.New, Label: "New"}, {Code: badge.FreeShipping, Label: "Free Shipping"}}, badges)
+}
+
+type memoryBadgeRepo struct {
+	badge.Repository
+	definitions []*badge.Definition
+	assignments []*badge.Assignment
+	bestSellers []string
+	stored      map[string][]product.Badge
+}
+
+func (r *memoryBadgeRepo) ListDefinitions(ctx context.Context) ([]*badge.Definition, error) {
+	return r.definitions, nil
+}
+
+func (r *memoryBadgeRepo) Assignments(ctx context.Context, productIDs []string, at time.Time) ([]*badge.Assignment, error) {
+	return r.assignments, nil
+}
+
+func (r *memoryBadgeRepo) BestSellers(ctx context.Context, since time.Time, limit int) ([]string, error) {
+	return r.bestSellers, nil
+}
+
+func (r *memoryBadgeRepo) SetProductBadges(ctx context.Context, productID string, badges []product.Badge) error {
+	r.stored[productID] = badges
+	return nil
+}
+
+type pagedProductRepo struct {
+	product.Repository
+	products []*product.Product
+}
+
+func (r *pagedProductRepo) ListAfter(ctx context.Context, filter product.SearchFilter, after *product.Position, limit int) ([]*product.Product, error) {
+	start := 0
+	if after != nil {
+		for i, p := range r.products {
+			if p.ID == after.ID {
+				start = i + 1
+			}
+		}
+	}
+	end := start + limit
+	if end > len(r.products) {
+		end = len(r.products)
+	}
+	return r.products[start:end], nil
+}
+
+type liveSaleRepo struct {
+	flashsale.Repository
+	sales []*flashsale.Sale
+}
+
+func (r *liveSaleRepo) ListLive(ctx context.Context, productIDs []string, at time.Time) ([]*flashsale.Sale, error) {
+	return r.sales, nil
+}
+
+func TestRefreshBadgesStoresChangedBadges(t *testing.T) {
+	old := time.Now().AddDate(-1, 0, 0)
+	badges := &memoryBadgeRepo{bestSellers: []string{"p2"}, stored: map[string][]product.Badge{}}
+	products := &pagedProductRepo{products: []*product.Product{
+		{ID: "p1", Stock: 50, CreatedAt: old, Badges: []product.Badge{}},
+		{ID: "p2", Stock: 50, CreatedAt: old},
+		{ID: "p3", Stock: 50, CreatedAt: old, Badges: []product.Badge{{Code: badge.New, Label: "New"}}},
+	}}
+	sales := &liveSaleRepo{sales: []*flashsale.Sale{{Items: []flashsale.Item{{ProductID: "p1"}}}}}
+	handler := commands.NewRefreshBadgesCommandHandler(badges, products, sales)
+
+	updated, err := handler.Handle(context.Background(), commands.RefreshBadgesCommand{BatchSize: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 3, updated)
+	assert.Equal(t, []product.Badge{{Code: badge.Sale, Label: "Sale"}}, badges.stored["p1"])
+	assert.Equal(t, []product.Badge{{Code: badge.BestSeller, Label: "Best Seller"}}, badges.stored["p2"])
+	assert.Empty(t, badges.stored["p3"], "no longer new")
+
+	badges.stored = map[string][]product.Badge{}
+	products.products[0].Badges = []product.Badge{{Code: badge.Sale, Label: "Sale"}}
+	products.products[1].Badges = []product.Badge{{Code: badge.BestSeller, Label: "Best Seller"}}
+	products.products[2].Badges = []product.Badge{}
+	sales.sales = nil
+	updated, err = handler.Handle(context.Background(), commands.RefreshBadgesCommand{BatchSize: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 1, updated, "only the product whose sale ended")
+	assert.Empty(t, badges.stored["p1"])
+	assert.Contains(t, badges.stored, "p1")
+}
+
+func TestDeleteBuiltInBadgeIsRefused(t *testing.T) {
+	handler := commands.NewDeleteBadgeCommandHandler(&memoryBadgeRepo{}, nil)
+	err := handler.Handle(context.Background(), commands.DeleteBadgeCommand{Code: badge.Sale})
+	assert.Equal(t, "invalid_badge", apperror.From(err).Code)
+}
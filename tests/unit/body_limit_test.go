@@ -0,0 +1,145 @@
+package unit
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/cms"
+	"online-shop/internal/interfaces/http/handlers"
+	"online-shop/internal/interfaces/http/middleware"
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/config"
+)
+
+func newBodyLimitRouter(cfg *config.RequestLimitsConfig, routes func(r *gin.Engine)) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Errors())
+	r.Use(middleware.BodyLimit(cfg))
+	routes(r)
+	return r
+}
+
+// echoBody answers with the size of the body, failing like handlers do when
+// it cannot be read
+func echoBody(c *gin.Context) {
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		middleware.AbortWithError(c, apperror.ErrInvalidRequest.WithDetail("%s", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"size": len(data)})
+}
+
+// unsizedBody hides the length of a body, as with chunked uploads
+type unsizedBody struct{ io.Reader }
+
+func TestBodyLimitPerGroup(t *testing.T) {
+	cfg := &config.RequestLimitsConfig{
+		BodyKilobytes: 1,
+		Groups: []config.BodyLimitGroupConfig{
+			{Prefix: "/uploads", BodyKilobytes: 4},
+			{Prefix: "/uploads/small", BodyKilobytes: 2},
+		},
+	}
+	r := newBodyLimitRouter(cfg, func(r *gin.Engine) {
+		r.POST("/echo", echoBody)
+		r.POST("/uploads/large", echoBody)
+		r.POST("/uploads/small/one", echoBody)
+	})
+	post := func(path string, size int) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(make([]byte, size))))
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, post("/echo", 1024).Code)
+	w := post("/echo", 1025)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), "request_too_large")
+	assert.Contains(t, w.Body.String(), `"limit":"1024"`)
+
+	assert.Equal(t, http.StatusOK, post("/uploads/large", 3000).Code)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, post("/uploads/small/one", 3000).Code, "the longest prefix wins")
+}
+
+func TestBodyLimitCutsOffUndeclaredBodies(t *testing.T) {
+	r := newBodyLimitRouter(&config.RequestLimitsConfig{BodyKilobytes: 1}, func(r *gin.Engine) {
+		r.POST("/echo", echoBody)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/echo", unsizedBody{bytes.NewReader(make([]byte, 4096))})
+	req.ContentLength = -1
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code, "the read failure is reported as too large, not malformed")
+	assert.Contains(t, w.Body.String(), "request_too_large")
+}
+
+func newAssetUploadRouter(t *testing.T, allowedTypes []string) (*gin.Engine, *memoryAssetStorage) {
+	t.Helper()
+	assets := &memoryAssetRepo{assets: map[string]*cms.Asset{}}
+	files := &memoryAssetStorage{files: map[string][]byte{}}
+	upload := commands.NewUploadCMSAssetCommandHandler(assets, files, 1024, allowedTypes)
+	h := handlers.NewCMSHandler(nil, nil, nil, nil, nil, nil, nil, nil, nil, upload, nil, nil)
+
+	r := newBodyLimitRouter(&config.RequestLimitsConfig{BodyKilobytes: 8}, func(r *gin.Engine) {
+		r.POST("/admin/cms/assets", h.UploadAsset)
+	})
+	return r, files
+}
+
+func uploadAsset(r *gin.Engine, fields map[string]string, filename string, data []byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		_ = form.WriteField(name, value)
+	}
+	if filename != "" {
+		part, _ := form.CreateFormFile("file", filename)
+		_, _ = part.Write(data)
+	}
+	_ = form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/cms/assets", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAssetUploadIsStreamed(t *testing.T) {
+	r, files := newAssetUploadRouter(t, nil)
+
+	w := uploadAsset(r, map[string]string{"note": "summer sale"}, "sale.png", pngHeader)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Len(t, files.files, 1)
+
+	w = uploadAsset(r, nil, "huge.png", append(pngHeader, make([]byte, 2048)...))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), `"limit":"1024"`)
+
+	w = uploadAsset(r, map[string]string{"file": "not a file"}, "", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "multipart file field is required")
+}
+
+func TestAssetUploadAllowList(t *testing.T) {
+	r, files := newAssetUploadRouter(t, []string{"image/jpeg"})
+
+	w := uploadAsset(r, nil, "sale.png", pngHeader)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.True(t, strings.Contains(w.Body.String(), "image/png images are not accepted"), w.Body.String())
+	assert.Empty(t, files.files)
+}
@@ -0,0 +1,119 @@
+package unit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/product"
+	"online-shop/pkg/apperror"
+)
+
+// bundleRepoStub finds bundles among the products of a flashProductRepo.
+type bundleRepoStub struct {
+	products *flashProductRepo
+}
+
+func (r *bundleRepoStub) ListBundlesOf(ctx context.Context, productIDs []string) ([]*product.Product, error) {
+	var bundles []*product.Product
+	for _, p := range r.products.products {
+		for _, c := range p.Components {
+			if containsString(productIDs, c.ProductID) {
+				bundles = append(bundles, p)
+				break
+			}
+		}
+	}
+	return bundles, nil
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestBundleComponentsAreChecked(t *testing.T) {
+	parts := map[string]*product.Product{
+		"beans":  {ID: "beans", MerchantID: "m1", Price: 50000, Stock: 10},
+		"mug":    {ID: "mug", MerchantID: "m1", Price: 25000, Stock: 5},
+		"other":  {ID: "other", MerchantID: "m2", Price: 10000, Stock: 5},
+		"bundle": {ID: "bundle", MerchantID: "m1", Components: []product.Component{{ProductID: "beans", Quantity: 1}}},
+	}
+	kit := &product.Product{ID: "kit", MerchantID: "m1", Price: 80000}
+
+	for name, components := range map[string][]product.Component{
+		"no quantity":    {{ProductID: "beans"}},
+		"itself":         {{ProductID: "kit", Quantity: 1}},
+		"listed twice":   {{ProductID: "beans", Quantity: 1}, {ProductID: "beans", Quantity: 1}},
+		"missing":        {{ProductID: "gone", Quantity: 1}},
+		"nested bundle":  {{ProductID: "bundle", Quantity: 1}},
+		"another seller": {{ProductID: "other", Quantity: 1}},
+	} {
+		assert.ErrorIs(t, kit.SetComponents(components, parts), product.ErrInvalidBundle, name)
+	}
+
+	require.NoError(t, kit.SetComponents([]product.Component{{ProductID: "beans", Quantity: 1}, {ProductID: "mug", Quantity: 2}}, parts))
+	assert.True(t, kit.IsBundle())
+	assert.Equal(t, 2, kit.Stock, "five mugs make two kits")
+	assert.Equal(t, []float64{40000, 20000}, kit.SplitPrice(kit.Price, parts), "shared in proportion to the list prices")
+
+	require.NoError(t, kit.SetComponents(nil, parts))
+	assert.False(t, kit.IsBundle())
+	assert.Equal(t, 0, kit.Stock, "the stock was the components'")
+}
+
+func TestBundleOrdersTakeAndRestoreComponentStock(t *testing.T) {
+	products := &flashProductRepo{products: map[string]*product.Product{
+		"beans": {ID: "beans", MerchantID: "m1", Price: 50000, Stock: 10, Status: product.StatusActive},
+		"mug":   {ID: "mug", MerchantID: "m1", Price: 25000, Stock: 3, Status: product.StatusActive},
+		"kit": {ID: "kit", MerchantID: "m1", Price: 80000, Stock: 1, Status: product.StatusActive, Components: []product.Component{
+			{ProductID: "beans", Quantity: 1},
+			{ProductID: "mug", Quantity: 2},
+		}},
+	}}
+	stocker := commands.NewBundleStocker(products, &bundleRepoStub{products: products})
+	orders := &flashOrderRepo{orders: map[string]*order.Order{}}
+	counter := newMemoryFlashCounter()
+	create := commands.NewCreateOrderCommandHandler(orders, products, noCommissionRepo{}, noWarehouseRepo{}, nil, &flashSaleRepoStub{}, counter, commands.CreateOrderOptions{Bundles: stocker})
+	cancel := commands.NewCancelOrderCommandHandler(orders, products, nil, counter, &paymentRepoStub{}, nil, order.CancellationPolicy{}, stocker)
+	address := order.Address{Street: "Jl. Merdeka 1", City: "Jakarta", PostalCode: "10110", Country: "ID"}
+
+	_, err := create.Handle(context.Background(), commands.CreateOrderCommand{UserID: "u1", ShippingAddress: address, Items: []commands.CreateOrderItemCmd{
+		{ProductID: "kit", Quantity: 1},
+		{ProductID: "mug", Quantity: 2},
+	}})
+	assert.Equal(t, commands.ErrInsufficientStock.Code, apperror.From(err).Code, "the mugs of the kit count against the mugs ordered alone")
+
+	placed, err := create.Handle(context.Background(), commands.CreateOrderCommand{UserID: "u1", ShippingAddress: address, Items: []commands.CreateOrderItemCmd{
+		{ProductID: "kit", Quantity: 1},
+		{ProductID: "mug", Quantity: 1},
+	}})
+	require.NoError(t, err)
+	require.Len(t, placed.Items, 3)
+	beans := placed.Items[0]
+	assert.Equal(t, "beans", beans.ProductID)
+	assert.Equal(t, "kit", beans.BundleID)
+	assert.Equal(t, 1, beans.BundleQuantity)
+	assert.Equal(t, 40000.0, beans.Price, "priced at its share of the kit")
+	assert.Equal(t, "kit", placed.Items[1].BundleID)
+	assert.Equal(t, 2, placed.Items[1].Quantity, "two mugs to a kit")
+	assert.Empty(t, placed.Items[2].BundleID)
+	assert.Equal(t, 105000.0, placed.TotalAmount, "the kit at its bundle price and a mug at its own")
+
+	assert.Equal(t, 9, products.products["beans"].Stock)
+	assert.Equal(t, 0, products.products["mug"].Stock)
+	assert.Equal(t, 0, products.products["kit"].Stock, "no mugs are left for another kit")
+
+	require.NoError(t, cancel.Handle(context.Background(), commands.CancelOrderCommand{OrderID: placed.ID, UserID: "u1"}))
+	assert.Equal(t, 10, products.products["beans"].Stock)
+	assert.Equal(t, 3, products.products["mug"].Stock)
+	assert.Equal(t, 1, products.products["kit"].Stock, "the kit counts its stock from the restored components")
+}
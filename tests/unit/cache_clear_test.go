@@ -0,0 +1,70 @@
+package unit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/audit"
+	"online-shop/internal/domain/cache"
+)
+
+type invalidatorStub struct {
+	keys     map[string]int64
+	patterns []string
+}
+
+func (s *invalidatorStub) DeletePattern(ctx context.Context, pattern string) (int64, error) {
+	s.patterns = append(s.patterns, pattern)
+	return s.keys[pattern], nil
+}
+
+type recordingAuditRepo struct {
+	auditRepoStub
+	entries []*audit.Entry
+}
+
+func (r *recordingAuditRepo) Create(ctx context.Context, entry *audit.Entry) error {
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+func TestClearCacheScopedToProducts(t *testing.T) {
+	invalidator := &invalidatorStub{keys: map[string]int64{"product:*": 3, "products:*": 2}}
+	auditRepo := &recordingAuditRepo{}
+	handler := commands.NewClearCacheCommandHandler(invalidator, auditRepo, "production")
+
+	result, err := handler.Handle(context.Background(), commands.ClearCacheCommand{Scope: cache.ScopeProducts, ActorID: "admin-1"})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(5), result.KeysInvalidated)
+	assert.Equal(t, []string{"product:*", "products:*"}, invalidator.patterns)
+	require.Len(t, auditRepo.entries, 1)
+	assert.Equal(t, "cache.clear", auditRepo.entries[0].Action)
+	assert.Equal(t, "products", auditRepo.entries[0].ResourceID)
+	assert.Equal(t, "admin-1", auditRepo.entries[0].ActorID)
+}
+
+func TestClearCacheRequiresConfirmationInProduction(t *testing.T) {
+	invalidator := &invalidatorStub{}
+	handler := commands.NewClearCacheCommandHandler(invalidator, &recordingAuditRepo{}, "production")
+
+	_, err := handler.Handle(context.Background(), commands.ClearCacheCommand{Scope: cache.ScopeAll})
+	assert.ErrorIs(t, err, commands.ErrCacheFlushNotConfirmed)
+	assert.Empty(t, invalidator.patterns)
+
+	_, err = handler.Handle(context.Background(), commands.ClearCacheCommand{Scope: cache.ScopeAll, Confirm: "all"})
+	require.NoError(t, err)
+	assert.Contains(t, invalidator.patterns, "session:*")
+	assert.NotContains(t, invalidator.patterns, "idempotency:*")
+}
+
+func TestClearCacheRejectsUnknownScope(t *testing.T) {
+	handler := commands.NewClearCacheCommandHandler(&invalidatorStub{}, &recordingAuditRepo{}, "development")
+
+	_, err := handler.Handle(context.Background(), commands.ClearCacheCommand{Scope: "everything"})
+	assert.ErrorIs(t, err, cache.ErrUnknownScope)
+}
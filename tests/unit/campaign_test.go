@@ -0,0 +1,287 @@
+package unit
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/campaign"
+	"online-shop/internal/domain/user"
+	"online-shop/pkg/apperror"
+)
+
+type memoryCampaignRepo struct {
+	campaign.Repository
+	campaigns map[string]*campaign.Campaign
+	events    []*campaign.Event
+	// onProgress runs before each progress is saved
+	onProgress func(c *campaign.Campaign)
+}
+
+func newMemoryCampaignRepo() *memoryCampaignRepo {
+	return &memoryCampaignRepo{campaigns: map[string]*campaign.Campaign{}}
+}
+
+func (r *memoryCampaignRepo) Create(ctx context.Context, c *campaign.Campaign) error {
+	copied := *c
+	r.campaigns[c.ID] = &copied
+	return nil
+}
+
+func (r *memoryCampaignRepo) Get(ctx context.Context, id string) (*campaign.Campaign, error) {
+	c, ok := r.campaigns[id]
+	if !ok {
+		return nil, campaign.ErrNotFound
+	}
+	copied := *c
+	return &copied, nil
+}
+
+func (r *memoryCampaignRepo) Update(ctx context.Context, c *campaign.Campaign) error {
+	return r.Create(ctx, c)
+}
+
+func (r *memoryCampaignRepo) Progress(ctx context.Context, c *campaign.Campaign) (bool, error) {
+	if r.onProgress != nil {
+		r.onProgress(c)
+	}
+	stored := r.campaigns[c.ID]
+	if stored.Status != campaign.StatusScheduled && stored.Status != campaign.StatusSending {
+		return false, nil
+	}
+	return true, r.Create(ctx, c)
+}
+
+func (r *memoryCampaignRepo) ListDue(ctx context.Context, at time.Time, limit int) ([]*campaign.Campaign, error) {
+	var due []*campaign.Campaign
+	for _, c := range r.campaigns {
+		if c.Status == campaign.StatusSending || (c.Status == campaign.StatusScheduled && !c.SendAt.After(at)) {
+			copied := *c
+			due = append(due, &copied)
+		}
+	}
+	return due, nil
+}
+
+func (r *memoryCampaignRepo) RecordEvent(ctx context.Context, e *campaign.Event) error {
+	r.events = append(r.events, e)
+	return nil
+}
+
+// memoryAudienceRepo selects its users with Segment.Matches
+type memoryAudienceRepo struct {
+	users []*user.User
+}
+
+func (r *memoryAudienceRepo) Audience(ctx context.Context, s campaign.Segment, at time.Time, afterID string, limit int) ([]*user.User, error) {
+	var members []*user.User
+	for _, u := range r.sorted() {
+		if u.ID > afterID && s.Matches(u, at) && len(members) < limit {
+			members = append(members, u)
+		}
+	}
+	return members, nil
+}
+
+func (r *memoryAudienceRepo) CountAudience(ctx context.Context, s campaign.Segment, at time.Time) (int64, error) {
+	members, err := r.Audience(ctx, s, at, "", len(r.users))
+	return int64(len(members)), err
+}
+
+func (r *memoryAudienceRepo) sorted() []*user.User {
+	users := append([]*user.User(nil), r.users...)
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users
+}
+
+func campaignCustomer(t *testing.T, id string, consent bool) *user.User {
+	u := &user.User{ID: id, Email: id + "@example.com", FirstName: strings.ToUpper(id[:1]) + id[1:], Role: user.RoleCustomer, Status: user.StatusActive, CreatedAt: time.Now()}
+	require.NoError(t, u.SetMarketingConsent(user.ChannelEmail, consent, time.Now()))
+	return u
+}
+
+func TestCampaignSegmentMatches(t *testing.T) {
+	now := time.Date(2026, 6, 15, 12, 0, 0, 0, time.UTC)
+	u := campaignCustomer(t, "ani", true)
+	u.Locale = "id"
+	u.Gender = user.GenderFemale
+	birthday := time.Date(1996, 6, 15, 0, 0, 0, 0, time.UTC)
+	u.Birthday = &birthday
+
+	assert.True(t, campaign.Segment{}.Matches(u, now))
+	assert.True(t, campaign.Segment{Locales: []string{"id"}, Genders: []user.Gender{user.GenderFemale}, MinAge: 30, MaxAge: 30, BirthdayMonth: 6}.Matches(u, now), "turns 30 today")
+	assert.False(t, campaign.Segment{MinAge: 31}.Matches(u, now))
+	assert.False(t, campaign.Segment{MaxAge: 29}.Matches(u, now))
+	assert.False(t, campaign.Segment{Locales: []string{"en"}}.Matches(u, now))
+	assert.False(t, campaign.Segment{Genders: []user.Gender{user.GenderMale}}.Matches(u, now))
+
+	noBirthday := campaignCustomer(t, "budi", true)
+	assert.False(t, campaign.Segment{BirthdayMonth: 6}.Matches(noBirthday, now))
+	assert.True(t, campaign.Segment{Locales: []string{"en"}}.Matches(noBirthday, now), "no locale gets the default one")
+
+	assert.False(t, campaign.Segment{}.Matches(campaignCustomer(t, "citra", false), now), "no consent")
+	admin := campaignCustomer(t, "dewi", true)
+	admin.Role = user.RoleAdmin
+	assert.False(t, campaign.Segment{}.Matches(admin, now))
+
+	for _, s := range []campaign.Segment{
+		{Locales: []string{"xx"}},
+		{Genders: []user.Gender{"unknown"}},
+		{MinAge: 40, MaxAge: 30},
+		{BirthdayMonth: 13},
+		{RegisteredAfter: &now, RegisteredBefore: &now},
+	} {
+		assert.ErrorIs(t, s.Validate(), campaign.ErrInvalidCampaign, "%+v", s)
+	}
+}
+
+func TestCampaignLifecycle(t *testing.T) {
+	repo := newMemoryCampaignRepo()
+	create := commands.NewCreateCampaignCommandHandler(repo, &memoryEmailTemplateRepo{})
+
+	_, err := create.Handle(context.Background(), commands.CreateCampaignCommand{Name: "Sale", Template: "welcome", Segment: campaign.Segment{MinAge: -1}})
+	assert.Equal(t, commands.ErrInvalidCampaign.Code, apperror.From(err).Code)
+
+	c, err := create.Handle(context.Background(), commands.CreateCampaignCommand{Name: "Sale", Template: "welcome", CreatedBy: "admin-1"})
+	require.NoError(t, err)
+	assert.Equal(t, campaign.StatusDraft, c.Status)
+
+	sendAt := time.Now().Add(time.Hour)
+	scheduled, err := commands.NewScheduleCampaignCommandHandler(repo).Handle(context.Background(), commands.ScheduleCampaignCommand{ID: c.ID, SendAt: &sendAt})
+	require.NoError(t, err)
+	assert.Equal(t, campaign.StatusScheduled, scheduled.Status)
+
+	cancel := commands.NewCancelCampaignCommandHandler(repo)
+	_, err = cancel.Handle(context.Background(), commands.CancelCampaignCommand{ID: c.ID})
+	require.NoError(t, err)
+	_, err = cancel.Handle(context.Background(), commands.CancelCampaignCommand{ID: c.ID})
+	assert.Equal(t, commands.ErrCampaignWrongStatus.Code, apperror.From(err).Code)
+
+	_, err = commands.NewUpdateCampaignCommandHandler(repo, &memoryEmailTemplateRepo{}).Handle(context.Background(), commands.UpdateCampaignCommand{ID: c.ID, Name: "Sale", Template: "welcome"})
+	assert.Equal(t, commands.ErrCampaignWrongStatus.Code, apperror.From(err).Code)
+
+	_, err = cancel.Handle(context.Background(), commands.CancelCampaignCommand{ID: "missing"})
+	assert.Equal(t, commands.ErrCampaignNotFound.Code, apperror.From(err).Code)
+}
+
+func TestSendDueCampaignsQueuesTheSegmentInBatches(t *testing.T) {
+	repo := newMemoryCampaignRepo()
+	c, err := campaign.New(campaign.Content{Name: "Sale", Template: "welcome", Data: map[string]interface{}{"Coupon": "SALE10"}}, "admin-1")
+	require.NoError(t, err)
+	require.NoError(t, c.Schedule(time.Now(), time.Now()))
+	require.NoError(t, repo.Create(context.Background(), c))
+
+	later, err := campaign.New(campaign.Content{Name: "Later", Template: "welcome"}, "admin-1")
+	require.NoError(t, err)
+	require.NoError(t, later.Schedule(time.Now().Add(time.Hour), time.Now()))
+	require.NoError(t, repo.Create(context.Background(), later))
+
+	audience := &memoryAudienceRepo{users: []*user.User{
+		campaignCustomer(t, "ani", true),
+		campaignCustomer(t, "budi", true),
+		campaignCustomer(t, "citra", false),
+		campaignCustomer(t, "dewi", true),
+	}}
+	publisher := &recordingDeliveryPublisher{}
+	send := commands.NewSendDueCampaignsCommandHandler(repo, audience, publisher)
+
+	queued, err := send.Handle(context.Background(), commands.SendDueCampaignsCommand{BatchSize: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 3, queued)
+	require.Len(t, publisher.batches, 2)
+	assert.True(t, publisher.batches[0].Tracked)
+	assert.Equal(t, c.ID, publisher.batches[0].CampaignID)
+	assert.Equal(t, "SALE10", publisher.batches[0].Data["Coupon"])
+	assert.Equal(t, "Ani", publisher.batches[0].Recipients[0].Data["FirstName"])
+	assert.Equal(t, "dewi@example.com", publisher.batches[1].Recipients[0].To)
+
+	sent, _ := repo.Get(context.Background(), c.ID)
+	assert.Equal(t, campaign.StatusSent, sent.Status)
+	assert.Equal(t, 3, sent.Recipients)
+	assert.Equal(t, 2, sent.Batches)
+	notYet, _ := repo.Get(context.Background(), later.ID)
+	assert.Equal(t, campaign.StatusScheduled, notYet.Status)
+
+	queued, err = send.Handle(context.Background(), commands.SendDueCampaignsCommand{BatchSize: 2})
+	require.NoError(t, err)
+	assert.Zero(t, queued, "a sent campaign is not sent again")
+}
+
+func TestSendDueCampaignsStopsWhenCanceled(t *testing.T) {
+	repo := newMemoryCampaignRepo()
+	c, err := campaign.New(campaign.Content{Name: "Sale", Template: "welcome"}, "admin-1")
+	require.NoError(t, err)
+	require.NoError(t, c.Schedule(time.Now(), time.Now()))
+	require.NoError(t, repo.Create(context.Background(), c))
+
+	audience := &memoryAudienceRepo{users: []*user.User{
+		campaignCustomer(t, "ani", true),
+		campaignCustomer(t, "budi", true),
+		campaignCustomer(t, "citra", true),
+	}}
+	publisher := &recordingDeliveryPublisher{}
+	repo.onProgress = func(progress *campaign.Campaign) {
+		if progress.Batches == 1 {
+			repo.campaigns[c.ID].Status = campaign.StatusCanceled
+		}
+	}
+
+	queued, err := commands.NewSendDueCampaignsCommandHandler(repo, audience, publisher).Handle(context.Background(), commands.SendDueCampaignsCommand{BatchSize: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 1, queued)
+	assert.Len(t, publisher.batches, 1)
+	canceled, _ := repo.Get(context.Background(), c.ID)
+	assert.Equal(t, campaign.StatusCanceled, canceled.Status)
+}
+
+var trackingLink = regexp.MustCompile(`(?:href|src)="([^"]+)"`)
+
+func TestCampaignTrackingLinks(t *testing.T) {
+	tracker := campaign.NewTracker("https://shop.example.com/", "secret")
+	body := tracker.Instrument(`<html><body><a href="https://shop.example.com/products?a=1&amp;b=2">Shop</a></body></html>`, "campaign-1", "Ani@Example.com")
+
+	links := trackingLink.FindAllStringSubmatch(body, -1)
+	require.Len(t, links, 2)
+	click, open := links[0][1], links[1][1]
+	assert.True(t, strings.HasPrefix(click, "https://shop.example.com"+campaign.ClickPath))
+	assert.True(t, strings.HasPrefix(open, "https://shop.example.com"+campaign.OpenPath))
+	assert.True(t, strings.HasSuffix(body, "</body></html>"))
+
+	repo := newMemoryCampaignRepo()
+	track := commands.NewTrackCampaignEventCommandHandler(repo, tracker)
+	clickToken := strings.TrimPrefix(click, "https://shop.example.com"+campaign.ClickPath)
+	link, err := track.Handle(context.Background(), commands.TrackCampaignEventCommand{Token: clickToken, Type: campaign.EventClick})
+	require.NoError(t, err)
+	assert.Equal(t, "https://shop.example.com/products?a=1&b=2", link.URL)
+	assert.Equal(t, campaign.RecipientKey("ani@example.com"), link.Recipient)
+
+	openToken := strings.TrimPrefix(open, "https://shop.example.com"+campaign.OpenPath)
+	_, err = track.Handle(context.Background(), commands.TrackCampaignEventCommand{Token: openToken, Type: campaign.EventOpen})
+	require.NoError(t, err)
+	require.Len(t, repo.events, 2)
+	assert.Equal(t, campaign.EventClick, repo.events[0].Type)
+	assert.Equal(t, campaign.EventOpen, repo.events[1].Type)
+	assert.Empty(t, repo.events[1].URL)
+
+	_, err = track.Handle(context.Background(), commands.TrackCampaignEventCommand{Token: openToken, Type: campaign.EventClick})
+	assert.Equal(t, commands.ErrInvalidTrackingLink.Code, apperror.From(err).Code, "an open beacon does not redirect")
+
+	payload, signature, _ := strings.Cut(clickToken, ".")
+	tampered := payload[:len(payload)-2] + "xx." + signature
+	_, err = track.Handle(context.Background(), commands.TrackCampaignEventCommand{Token: tampered, Type: campaign.EventClick})
+	assert.Equal(t, commands.ErrInvalidTrackingLink.Code, apperror.From(err).Code)
+	_, err = campaign.NewTracker("https://shop.example.com", "other").Parse(clickToken)
+	assert.ErrorIs(t, err, campaign.ErrInvalidLink)
+	assert.Len(t, repo.events, 2)
+
+	_, err = url.Parse(click)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,173 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/cart"
+	"online-shop/internal/domain/product"
+	"online-shop/pkg/apperror"
+)
+
+type memoryCartRepo struct {
+	carts map[cart.Owner]*cart.Cart
+}
+
+func newMemoryCartRepo() *memoryCartRepo {
+	return &memoryCartRepo{carts: map[cart.Owner]*cart.Cart{}}
+}
+
+func (r *memoryCartRepo) Get(ctx context.Context, owner cart.Owner) (*cart.Cart, error) {
+	c, ok := r.carts[owner]
+	if !ok {
+		return &cart.Cart{}, nil
+	}
+	copied := cart.Cart{UpdatedAt: c.UpdatedAt}
+	for _, item := range c.Items {
+		i := *item
+		copied.Items = append(copied.Items, &i)
+	}
+	return &copied, nil
+}
+
+func (r *memoryCartRepo) Save(ctx context.Context, owner cart.Owner, c *cart.Cart) error {
+	r.carts[owner] = c
+	return nil
+}
+
+func (r *memoryCartRepo) Delete(ctx context.Context, owner cart.Owner) error {
+	delete(r.carts, owner)
+	return nil
+}
+
+func cartProducts() *flashProductRepo {
+	return &flashProductRepo{products: map[string]*product.Product{
+		"p1": {ID: "p1", Name: "Kopi", Price: 50000, Stock: 10, Status: product.StatusActive},
+		"p2": {ID: "p2", Name: "Teh", Price: 20000, Stock: 3, Status: product.StatusActive},
+		"p3": {ID: "p3", Name: "Old", Price: 10000, Stock: 5, Status: product.StatusArchived},
+	}}
+}
+
+func TestCartTokens(t *testing.T) {
+	token, err := cart.NewToken()
+	require.NoError(t, err)
+	assert.True(t, cart.ValidToken(token))
+
+	other, err := cart.NewToken()
+	require.NoError(t, err)
+	assert.NotEqual(t, token, other)
+
+	assert.False(t, cart.ValidToken(""))
+	assert.False(t, cart.ValidToken("short"))
+	assert.False(t, cart.ValidToken(token[:42]+"!"))
+}
+
+func TestCartItems(t *testing.T) {
+	now := time.Now()
+	c := &cart.Cart{}
+
+	require.NoError(t, c.Add("p1", 2, now))
+	require.NoError(t, c.Add("p1", 3, now))
+	assert.Equal(t, 5, c.Quantity("p1"))
+	assert.Len(t, c.Items, 1)
+
+	assert.ErrorIs(t, c.Add("p2", 0, now), cart.ErrInvalidItem)
+	assert.ErrorIs(t, c.Add("p1", cart.MaxQuantity, now), cart.ErrInvalidItem)
+	assert.ErrorIs(t, c.Set("p2", 1, now), cart.ErrItemNotFound)
+
+	require.NoError(t, c.Set("p1", 1, now))
+	assert.Equal(t, 1, c.Quantity("p1"))
+	require.NoError(t, c.Remove("p1", now))
+	assert.Empty(t, c.Items)
+	assert.ErrorIs(t, c.Remove("p1", now), cart.ErrItemNotFound)
+
+	for i := 0; i < cart.MaxItems; i++ {
+		require.NoError(t, c.Add(string(rune('a'+i%26))+string(rune('a'+i/26)), 1, now))
+	}
+	assert.ErrorIs(t, c.Add("one-more", 1, now), cart.ErrFull)
+}
+
+func TestCartMergeKeepsLargerQuantity(t *testing.T) {
+	now := time.Now()
+	mine := &cart.Cart{}
+	require.NoError(t, mine.Add("p1", 2, now))
+	require.NoError(t, mine.Add("p2", 4, now))
+
+	theirs := &cart.Cart{}
+	require.NoError(t, theirs.Add("p1", 3, now))
+	require.NoError(t, theirs.Add("p2", 1, now))
+	require.NoError(t, theirs.Add("p3", 1, now))
+
+	dropped := mine.Merge(theirs, now)
+	assert.Zero(t, dropped)
+	assert.Equal(t, 3, mine.Quantity("p1"), "the anonymous cart had more")
+	assert.Equal(t, 4, mine.Quantity("p2"), "the account cart had more")
+	assert.Equal(t, 1, mine.Quantity("p3"))
+	assert.Len(t, mine.Items, 3)
+}
+
+func TestAddToCartChecksProductAndStock(t *testing.T) {
+	carts := newMemoryCartRepo()
+	handler := commands.NewAddToCartCommandHandler(carts, cartProducts(), nil)
+	owner := cart.Owner{Token: "token"}
+
+	_, err := handler.Handle(context.Background(), commands.AddToCartCommand{Owner: owner, ProductID: "p2", Quantity: 2})
+	require.NoError(t, err)
+
+	_, err = handler.Handle(context.Background(), commands.AddToCartCommand{Owner: owner, ProductID: "p2", Quantity: 2})
+	assert.Equal(t, "insufficient_stock", apperror.From(err).Code, "the cart already has 2 of the 3")
+
+	_, err = handler.Handle(context.Background(), commands.AddToCartCommand{Owner: owner, ProductID: "p3", Quantity: 1})
+	assert.Equal(t, "product_not_found", apperror.From(err).Code)
+
+	update := commands.NewUpdateCartItemCommandHandler(carts, cartProducts(), nil)
+	_, err = update.Handle(context.Background(), commands.UpdateCartItemCommand{Owner: owner, ProductID: "p1", Quantity: 1})
+	assert.Equal(t, "cart_item_not_found", apperror.From(err).Code)
+
+	c, err := update.Handle(context.Background(), commands.UpdateCartItemCommand{Owner: owner, ProductID: "p2", Quantity: 3})
+	require.NoError(t, err)
+	assert.Equal(t, 3, c.Quantity("p2"))
+}
+
+func TestMergeCartOnSignIn(t *testing.T) {
+	carts := newMemoryCartRepo()
+	now := time.Now()
+	anonymous := cart.Owner{Token: "token"}
+	account := cart.Owner{UserID: "user-1"}
+
+	theirs := &cart.Cart{}
+	require.NoError(t, theirs.Add("p1", 2, now))
+	require.NoError(t, theirs.Add("p2", 1, now))
+	carts.carts[anonymous] = theirs
+	mine := &cart.Cart{}
+	require.NoError(t, mine.Add("p2", 3, now))
+	carts.carts[account] = mine
+
+	handler := commands.NewMergeCartCommandHandler(carts)
+	merged, err := handler.Handle(context.Background(), commands.MergeCartCommand{UserID: "user-1", Token: "token"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, merged.Quantity("p1"))
+	assert.Equal(t, 3, merged.Quantity("p2"))
+	assert.NotContains(t, carts.carts, anonymous, "the anonymous cart is gone")
+
+	// Signing in again with the same token merges nothing more
+	again, err := handler.Handle(context.Background(), commands.MergeCartCommand{UserID: "user-1", Token: "token"})
+	require.NoError(t, err)
+	assert.Len(t, again.Items, 2)
+
+	products := cartProducts()
+	products.products["p2"].Stock = 2
+	view, err := queries.NewGetCartQueryHandler(carts, products).Handle(context.Background(), queries.GetCartQuery{Owner: account})
+	require.NoError(t, err)
+	require.Len(t, view.Items, 2)
+	assert.Equal(t, "Teh", view.Items[0].Product.Name)
+	assert.False(t, view.Items[0].Available, "3 in the cart, 2 in stock")
+	assert.True(t, view.Items[1].Available)
+	assert.Equal(t, 100000.0, view.Total)
+}
@@ -0,0 +1,105 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/cart"
+	"online-shop/internal/domain/flashsale"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/shipping"
+)
+
+func TestCheckoutPreviewMatchesPlacedOrder(t *testing.T) {
+	now := time.Now()
+	sale, err := flashsale.NewSale("Payday", now.Add(-time.Minute), now.Add(time.Hour), []flashsale.Item{
+		{ProductID: "p1", SalePrice: 80000, Quantity: 10},
+	})
+	require.NoError(t, err)
+
+	products := &flashProductRepo{products: map[string]*product.Product{
+		"p1": {ID: "p1", Name: "Kopi", Price: 100000, Stock: 50, Status: product.StatusActive},
+		"p2": {ID: "p2", Name: "Teh", Price: 25000, Stock: 50, Status: product.StatusActive},
+	}}
+	orders := &flashOrderRepo{orders: map[string]*order.Order{}}
+	counter := newMemoryFlashCounter()
+	zones := shippingZones(t)
+	quoter := queries.NewGetShippingQuoteQueryHandler(&shippingZoneRepo{zones: zones}, nil, nil, time.Hour, nil)
+	create := commands.NewCreateOrderCommandHandler(orders, products, noCommissionRepo{}, noWarehouseRepo{}, nil, &flashSaleRepoStub{sales: []*flashsale.Sale{sale}}, counter, commands.CreateOrderOptions{ShippingQuoter: quoter})
+	preview := commands.NewPreviewCheckoutCommandHandler(newMemoryCartRepo(), create)
+
+	address := order.Address{Street: "Jl. Asia Afrika 8", City: "Bandung", PostalCode: "40111", Country: "ID"}
+	items := []commands.CreateOrderItemCmd{{ProductID: "p1", Quantity: 2}, {ProductID: "p2", Quantity: 1}}
+	previewed, err := preview.Handle(context.Background(), commands.PreviewCheckoutCommand{
+		UserID:          "u1",
+		Items:           items,
+		ShippingAddress: address,
+		ShippingRateID:  zones[0].Rates[1].ID,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 185000.0, previewed.Subtotal)
+	assert.Equal(t, 40000.0, previewed.Discount, "the flash sale price against the list price")
+	assert.Equal(t, sale.ID, previewed.Items[0].FlashSaleID)
+	assert.Len(t, previewed.ShippingOptions, 2)
+	assert.Equal(t, "Express", previewed.Shipping.Rate)
+	assert.Zero(t, previewed.Tax)
+	assert.Empty(t, orders.orders, "a preview places nothing")
+	assert.Zero(t, counter.sold[sale.ID+":p1"], "a preview reserves no flash sale units")
+
+	placed, err := create.Handle(context.Background(), commands.CreateOrderCommand{
+		UserID:          "u1",
+		Items:           items,
+		ShippingAddress: address,
+		ShippingRateID:  zones[0].Rates[1].ID,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, placed.TotalAmount, previewed.Total)
+	assert.Equal(t, placed.Shipping, previewed.Shipping)
+	require.Len(t, previewed.Items, len(placed.Items))
+	for i, item := range placed.Items {
+		assert.Equal(t, item.Price, previewed.Items[i].Price)
+		assert.Equal(t, item.Subtotal, previewed.Items[i].Subtotal)
+	}
+
+	_, err = preview.Handle(context.Background(), commands.PreviewCheckoutCommand{
+		UserID:          "u1",
+		Items:           items,
+		ShippingAddress: order.Address{Street: "1 Raffles Place", City: "Singapore", PostalCode: "048616", Country: "SG"},
+	})
+	assert.ErrorIs(t, err, shipping.ErrNotServiceable, "turned away like the order")
+}
+
+func TestCheckoutPreviewOfCart(t *testing.T) {
+	products := cartProducts()
+	orders := &flashOrderRepo{orders: map[string]*order.Order{}}
+	create := commands.NewCreateOrderCommandHandler(orders, products, noCommissionRepo{}, noWarehouseRepo{}, nil, &flashSaleRepoStub{}, newMemoryFlashCounter(), commands.CreateOrderOptions{})
+	carts := newMemoryCartRepo()
+	preview := commands.NewPreviewCheckoutCommandHandler(carts, create)
+	cmd := commands.PreviewCheckoutCommand{
+		UserID:          "u1",
+		ShippingAddress: order.Address{Street: "Jl. Merdeka 1", City: "Jakarta", PostalCode: "10110", Country: "ID"},
+	}
+
+	_, err := preview.Handle(context.Background(), cmd)
+	assert.ErrorIs(t, err, cart.ErrEmpty)
+
+	c := &cart.Cart{}
+	require.NoError(t, c.Add("p1", 1, time.Now()))
+	require.NoError(t, c.Add("p2", 2, time.Now()))
+	carts.carts[cart.Owner{UserID: "u1"}] = c
+
+	previewed, err := preview.Handle(context.Background(), cmd)
+	require.NoError(t, err)
+	assert.Len(t, previewed.Items, 2)
+	assert.Equal(t, 90000.0, previewed.Total)
+	assert.Zero(t, previewed.Discount)
+	assert.Empty(t, previewed.ShippingOptions, "no shipping zones")
+	assert.Len(t, carts.carts, 1, "the cart is kept")
+}
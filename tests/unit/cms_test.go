@@ -0,0 +1,293 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/cms"
+	"online-shop/pkg/apperror"
+)
+
+type memoryCMSCache struct {
+	entries map[string][]byte
+	clears  int
+}
+
+func newMemoryCMSCache() *memoryCMSCache {
+	return &memoryCMSCache{entries: map[string][]byte{}}
+}
+
+func (c *memoryCMSCache) Get(ctx context.Context, key string, dest interface{}) (bool, error) {
+	data, ok := c.entries[key]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, dest)
+}
+
+func (c *memoryCMSCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	c.entries[key] = data
+	return err
+}
+
+func (c *memoryCMSCache) Clear(ctx context.Context) error {
+	c.entries = map[string][]byte{}
+	c.clears++
+	return nil
+}
+
+type memoryBlockRepo struct {
+	blocks map[string]*cms.Block
+	reads  int
+}
+
+func (r *memoryBlockRepo) Create(ctx context.Context, b *cms.Block) error {
+	r.blocks[b.ID] = b
+	return nil
+}
+
+func (r *memoryBlockRepo) GetByID(ctx context.Context, id string) (*cms.Block, error) {
+	b, ok := r.blocks[id]
+	if !ok {
+		return nil, cms.ErrBlockNotFound
+	}
+	return b, nil
+}
+
+func (r *memoryBlockRepo) Update(ctx context.Context, b *cms.Block) error {
+	r.blocks[b.ID] = b
+	return nil
+}
+
+func (r *memoryBlockRepo) Delete(ctx context.Context, id string) error {
+	if _, ok := r.blocks[id]; !ok {
+		return cms.ErrBlockNotFound
+	}
+	delete(r.blocks, id)
+	return nil
+}
+
+func (r *memoryBlockRepo) List(ctx context.Context, slot string, limit, offset int) ([]*cms.Block, error) {
+	var blocks []*cms.Block
+	for _, b := range r.blocks {
+		if slot == "" || b.Slot == slot {
+			blocks = append(blocks, b)
+		}
+	}
+	return blocks, nil
+}
+
+func (r *memoryBlockRepo) ListActive(ctx context.Context, slot string, at time.Time) ([]*cms.Block, error) {
+	r.reads++
+	var blocks []*cms.Block
+	for _, b := range r.blocks {
+		if b.Slot == slot && b.Active && (b.EndsAt == nil || b.EndsAt.After(at)) {
+			blocks = append(blocks, b)
+		}
+	}
+	return blocks, nil
+}
+
+type memoryPageRepo struct {
+	pages map[string]*cms.Page
+}
+
+func (r *memoryPageRepo) Create(ctx context.Context, p *cms.Page) error {
+	r.pages[p.ID] = p
+	return nil
+}
+
+func (r *memoryPageRepo) GetByID(ctx context.Context, id string) (*cms.Page, error) {
+	p, ok := r.pages[id]
+	if !ok {
+		return nil, cms.ErrPageNotFound
+	}
+	return p, nil
+}
+
+func (r *memoryPageRepo) GetBySlug(ctx context.Context, slug string) (*cms.Page, error) {
+	for _, p := range r.pages {
+		if p.Slug == slug {
+			return p, nil
+		}
+	}
+	return nil, cms.ErrPageNotFound
+}
+
+func (r *memoryPageRepo) Update(ctx context.Context, p *cms.Page) error {
+	r.pages[p.ID] = p
+	return nil
+}
+
+func (r *memoryPageRepo) Delete(ctx context.Context, id string) error {
+	delete(r.pages, id)
+	return nil
+}
+
+func (r *memoryPageRepo) List(ctx context.Context, limit, offset int) ([]*cms.Page, error) {
+	var pages []*cms.Page
+	for _, p := range r.pages {
+		pages = append(pages, p)
+	}
+	return pages, nil
+}
+
+type memoryAssetRepo struct {
+	assets map[string]*cms.Asset
+}
+
+func (r *memoryAssetRepo) Create(ctx context.Context, a *cms.Asset) error {
+	r.assets[a.ID] = a
+	return nil
+}
+
+func (r *memoryAssetRepo) Get(ctx context.Context, id string) (*cms.Asset, error) {
+	a, ok := r.assets[id]
+	if !ok {
+		return nil, cms.ErrAssetNotFound
+	}
+	return a, nil
+}
+
+func (r *memoryAssetRepo) List(ctx context.Context, limit, offset int) ([]*cms.Asset, error) {
+	var assets []*cms.Asset
+	for _, a := range r.assets {
+		assets = append(assets, a)
+	}
+	return assets, nil
+}
+
+func (r *memoryAssetRepo) Delete(ctx context.Context, id string) error {
+	delete(r.assets, id)
+	return nil
+}
+
+type memoryAssetStorage struct {
+	files map[string][]byte
+}
+
+func (s *memoryAssetStorage) Put(ctx context.Context, key, contentType string, data []byte) error {
+	s.files[key] = data
+	return nil
+}
+
+func (s *memoryAssetStorage) SignedURL(key string, ttl time.Duration) (string, error) {
+	return "https://files.example.com/" + key + "?signature=x", nil
+}
+
+func (s *memoryAssetStorage) Delete(ctx context.Context, key string) error {
+	delete(s.files, key)
+	return nil
+}
+
+// pngHeader is enough of a PNG for content sniffing
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01")
+
+func TestCMSBlockValidation(t *testing.T) {
+	start := time.Now()
+	end := start.Add(-time.Hour)
+	_, err := cms.NewBlock("home-top", "Sale", "Up to 50% off", "", "", 0, cms.Window{StartsAt: &start, EndsAt: &end})
+	assert.Equal(t, commands.ErrInvalidCMSContent.Code, apperror.From(err).Code)
+
+	_, err = cms.NewBlock("home-top", "Sale", "", "", "/sale", 0, cms.Window{})
+	assert.ErrorIs(t, err, cms.ErrInvalidContent, "a block needs a body or an image")
+
+	block, err := cms.NewBlock("home-top", "Sale", "Up to 50% off", "", "/sale", 0, cms.Window{})
+	require.NoError(t, err)
+	assert.True(t, block.Live(time.Now()))
+	block.Active = false
+	assert.False(t, block.Live(time.Now()))
+}
+
+func TestCMSSlotIsCachedAndFollowsSchedule(t *testing.T) {
+	blocks := &memoryBlockRepo{blocks: map[string]*cms.Block{}}
+	cache := newMemoryCMSCache()
+	create := commands.NewCreateCMSBlockCommandHandler(blocks, cache)
+	slot := queries.NewGetCMSSlotQueryHandler(blocks, cache, time.Minute)
+
+	soon := time.Now().Add(50 * time.Millisecond)
+	_, err := create.Handle(context.Background(), commands.CreateCMSBlockCommand{Slot: "home-top", Title: "Now", Body: "Live now", Position: 1})
+	require.NoError(t, err)
+	_, err = create.Handle(context.Background(), commands.CreateCMSBlockCommand{Slot: "home-top", Title: "Soon", Body: "Starting soon", Position: 2, StartsAt: &soon})
+	require.NoError(t, err)
+	assert.Equal(t, 2, cache.clears, "each write clears the cache")
+
+	live, err := slot.Handle(context.Background(), queries.GetCMSSlotQuery{Slot: "home-top"})
+	require.NoError(t, err)
+	require.Len(t, live, 1)
+	assert.Equal(t, "Now", live[0].Title)
+
+	time.Sleep(60 * time.Millisecond)
+	live, err = slot.Handle(context.Background(), queries.GetCMSSlotQuery{Slot: "home-top"})
+	require.NoError(t, err)
+	assert.Len(t, live, 2, "a scheduled block goes live without waiting for the cache")
+	assert.Equal(t, 1, blocks.reads, "the second read is served from the cache")
+
+	empty, err := slot.Handle(context.Background(), queries.GetCMSSlotQuery{Slot: "checkout"})
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+}
+
+func TestCMSPagesArePublishedBySlug(t *testing.T) {
+	pages := &memoryPageRepo{pages: map[string]*cms.Page{}}
+	cache := newMemoryCMSCache()
+	create := commands.NewCreateCMSPageCommandHandler(pages, cache)
+	update := commands.NewUpdateCMSPageCommandHandler(pages, cache)
+	published := queries.NewGetPublishedPageQueryHandler(pages, cache, time.Minute)
+
+	page, err := create.Handle(context.Background(), commands.CreateCMSPageCommand{Slug: "about-us", Title: "About us", Body: "We sell things."})
+	require.NoError(t, err)
+	assert.False(t, page.Published)
+
+	_, err = published.Handle(context.Background(), queries.GetPublishedPageQuery{Slug: "about-us"})
+	assert.Equal(t, commands.ErrCMSPageNotFound.Code, apperror.From(err).Code, "drafts are not served")
+
+	publish := true
+	_, err = update.Handle(context.Background(), commands.UpdateCMSPageCommand{PageID: page.ID, Published: &publish})
+	require.NoError(t, err)
+	served, err := published.Handle(context.Background(), queries.GetPublishedPageQuery{Slug: "about-us"})
+	require.NoError(t, err)
+	assert.Equal(t, "About us", served.Title)
+
+	_, err = create.Handle(context.Background(), commands.CreateCMSPageCommand{Slug: "about-us", Title: "Again", Body: "Duplicate"})
+	assert.ErrorIs(t, err, commands.ErrSlugTaken)
+}
+
+func TestCMSAssetUploadAndLink(t *testing.T) {
+	assets := &memoryAssetRepo{assets: map[string]*cms.Asset{}}
+	files := &memoryAssetStorage{files: map[string][]byte{}}
+	cache := newMemoryCMSCache()
+	upload := commands.NewUploadCMSAssetCommandHandler(assets, files, 1024, nil)
+	link := queries.NewGetCMSAssetLinkQueryHandler(assets, files, cache, time.Minute)
+
+	_, err := upload.Handle(context.Background(), commands.UploadCMSAssetCommand{Filename: "notes.txt", Data: []byte("just some text")})
+	assert.Equal(t, commands.ErrInvalidCMSAsset.Code, apperror.From(err).Code)
+	_, err = upload.Handle(context.Background(), commands.UploadCMSAssetCommand{Filename: "huge.png", Data: append(pngHeader, make([]byte, 2048)...)})
+	assert.ErrorIs(t, err, cms.ErrInvalidAsset)
+
+	asset, err := upload.Handle(context.Background(), commands.UploadCMSAssetCommand{Filename: `C:\banners\sale.png`, Data: pngHeader, UploadedBy: "admin-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", asset.ContentType)
+	assert.Equal(t, "sale.png", asset.Filename)
+	assert.Equal(t, pngHeader, files.files[asset.Key])
+
+	url, err := link.Handle(context.Background(), queries.GetCMSAssetLinkQuery{AssetID: asset.ID})
+	require.NoError(t, err)
+	assert.Equal(t, "https://files.example.com/"+asset.Key+"?signature=x", url)
+
+	require.NoError(t, commands.NewDeleteCMSAssetCommandHandler(assets, files, cache).Handle(context.Background(), commands.DeleteCMSAssetCommand{AssetID: asset.ID}))
+	assert.Empty(t, files.files)
+	_, err = link.Handle(context.Background(), queries.GetCMSAssetLinkQuery{AssetID: asset.ID})
+	assert.Equal(t, commands.ErrCMSAssetNotFound.Code, apperror.From(err).Code, "deleting clears the cached link")
+
+	_, err = commands.NewUploadCMSAssetCommandHandler(assets, cms.UnavailableStorage{}, 1024, nil).Handle(context.Background(), commands.UploadCMSAssetCommand{Filename: "sale.png", Data: pngHeader})
+	assert.Equal(t, commands.ErrCMSStorageUnavailable.Code, apperror.From(err).Code)
+}
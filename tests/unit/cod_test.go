@@ -0,0 +1,244 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/cod"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/payment"
+)
+
+type codOrderRepo struct {
+	order.Repository
+	order *order.Order
+	saved order.Status
+}
+
+func (r *codOrderRepo) GetByID(ctx context.Context, id string) (*order.Order, error) {
+	if r.order.ID != id {
+		return nil, order.ErrNotFound
+	}
+	return r.order, nil
+}
+
+func (r *codOrderRepo) GetByShipmentID(ctx context.Context, shipmentID string) (*order.Order, error) {
+	if r.order.Shipment(shipmentID) == nil {
+		return nil, order.ErrShipmentNotFound
+	}
+	return r.order, nil
+}
+
+func (r *codOrderRepo) Update(ctx context.Context, o *order.Order) error { return nil }
+
+// UpdateFrom compares from with the status the order was last saved in, as
+// the order the handler holds has moved on already
+func (r *codOrderRepo) UpdateFrom(ctx context.Context, o *order.Order, from order.Status) (bool, error) {
+	if r.saved != "" && r.saved != from {
+		return false, nil
+	}
+	r.saved = o.Status
+	return true, nil
+}
+
+func (r *codOrderRepo) SaveShipment(ctx context.Context, o *order.Order, s *order.Shipment, from order.ShipmentStatus) error {
+	return nil
+}
+
+type codPaymentRepo struct {
+	payment.Repository
+	payments []*payment.Payment
+}
+
+func (r *codPaymentRepo) Create(ctx context.Context, p *payment.Payment) error {
+	r.payments = append(r.payments, p)
+	return nil
+}
+
+func (r *codPaymentRepo) GetByID(ctx context.Context, id string) (*payment.Payment, error) {
+	for _, p := range r.payments {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return nil, order.ErrNotFound
+}
+
+func (r *codPaymentRepo) ListByOrderID(ctx context.Context, orderID string) ([]*payment.Payment, error) {
+	return r.payments, nil
+}
+
+func (r *codPaymentRepo) Update(ctx context.Context, p *payment.Payment) error { return nil }
+
+type codCollectionRepo struct {
+	cod.Repository
+	collection *cod.Collection
+	exposure   cod.Exposure
+}
+
+func (r *codCollectionRepo) Create(ctx context.Context, c *cod.Collection) error {
+	r.collection = c
+	return nil
+}
+
+func (r *codCollectionRepo) GetByOrderID(ctx context.Context, orderID string) (*cod.Collection, error) {
+	if r.collection == nil || r.collection.OrderID != orderID {
+		return nil, cod.ErrNotFound
+	}
+	return r.collection, nil
+}
+
+func (r *codCollectionRepo) Update(ctx context.Context, c *cod.Collection) error { return nil }
+
+func (r *codCollectionRepo) Exposure(ctx context.Context, userID string) (cod.Exposure, error) {
+	return r.exposure, nil
+}
+
+type codLimitRepo struct {
+	cod.LimitRepository
+	limit *cod.CustomerLimit
+}
+
+func (r *codLimitRepo) Get(ctx context.Context, userID string) (*cod.CustomerLimit, error) {
+	return r.limit, nil
+}
+
+func pendingCODOrder() *order.Order {
+	return &order.Order{
+		ID:            "o1",
+		UserID:        "u1",
+		TotalAmount:   250000,
+		Status:        order.StatusPending,
+		PaymentStatus: order.PaymentStatusUnpaid,
+		Shipments:     []order.Shipment{{ID: "s1", WarehouseID: "w1", Status: order.ShipmentStatusPending}},
+	}
+}
+
+func TestCODLimits(t *testing.T) {
+	limits := cod.Limits{MaxOrderAmount: 1000000, MaxOutstandingAmount: 1500000, MaxOpenOrders: 2}
+
+	assert.NoError(t, limits.Check(nil, 1000000, cod.Exposure{}))
+	assert.ErrorIs(t, limits.Check(nil, 1000001, cod.Exposure{}), cod.ErrLimitExceeded)
+	assert.ErrorIs(t, limits.Check(nil, 100000, cod.Exposure{Orders: 2, Amount: 200000}), cod.ErrLimitExceeded)
+	assert.ErrorIs(t, limits.Check(nil, 600000, cod.Exposure{Orders: 1, Amount: 1000000}), cod.ErrLimitExceeded)
+
+	raised := 3000000.0
+	customer := &cod.CustomerLimit{UserID: "u1", MaxOrderAmount: &raised}
+	assert.NoError(t, limits.Check(customer, 1400000, cod.Exposure{}))
+	assert.Equal(t, 1500000.0, limits.For(customer).MaxOutstandingAmount, "unset amounts follow the shop")
+
+	customer.Blocked = true
+	assert.ErrorIs(t, limits.Check(customer, 1000, cod.Exposure{}), cod.ErrLimitExceeded)
+}
+
+func TestPayOnDelivery(t *testing.T) {
+	o := pendingCODOrder()
+	orders := &codOrderRepo{order: o}
+	payments := &codPaymentRepo{}
+	collections := &codCollectionRepo{}
+	limits := &cod.Limits{MaxOrderAmount: 1000000}
+
+	off := commands.NewPayOnDeliveryCommandHandler(orders, payments, collections, &codLimitRepo{}, nil, nil)
+	_, err := off.Handle(context.Background(), commands.PayOnDeliveryCommand{OrderID: "o1", UserID: "u1"})
+	assert.ErrorIs(t, err, cod.ErrNotEligible)
+
+	handler := commands.NewPayOnDeliveryCommandHandler(orders, payments, collections, &codLimitRepo{}, limits, nil)
+	_, err = handler.Handle(context.Background(), commands.PayOnDeliveryCommand{OrderID: "o1", UserID: "u2"})
+	assert.ErrorIs(t, err, commands.ErrForbidden)
+
+	o.TotalAmount = 2000000
+	_, err = handler.Handle(context.Background(), commands.PayOnDeliveryCommand{OrderID: "o1", UserID: "u1"})
+	assert.ErrorIs(t, err, cod.ErrLimitExceeded)
+	o.TotalAmount = 250000
+
+	collection, err := handler.Handle(context.Background(), commands.PayOnDeliveryCommand{OrderID: "o1", UserID: "u1"})
+	require.NoError(t, err)
+	assert.Equal(t, cod.StatusAwaitingDelivery, collection.Status)
+	assert.Equal(t, 250000.0, collection.Amount)
+	assert.Equal(t, order.StatusConfirmed, o.Status)
+	assert.Equal(t, order.PaymentStatusCashOnDelivery, o.PaymentStatus)
+	require.Len(t, payments.payments, 1)
+	assert.Equal(t, payment.MethodCashOnDelivery, payments.payments[0].Method)
+	assert.Equal(t, collection.PaymentID, payments.payments[0].ID)
+
+	_, err = handler.Handle(context.Background(), commands.PayOnDeliveryCommand{OrderID: "o1", UserID: "u1"})
+	assert.ErrorIs(t, err, commands.ErrOrderNotPayable, "an order is chosen for cash on delivery once")
+}
+
+func TestDeliverCODShipment(t *testing.T) {
+	o := pendingCODOrder()
+	orders := &codOrderRepo{order: o}
+	payments := &codPaymentRepo{}
+	collections := &codCollectionRepo{}
+	_, err := commands.NewPayOnDeliveryCommandHandler(orders, payments, collections, &codLimitRepo{}, &cod.Limits{}, nil).
+		Handle(context.Background(), commands.PayOnDeliveryCommand{OrderID: "o1", UserID: "u1"})
+	require.NoError(t, err)
+
+	now := time.Now()
+	o.Shipments[0].Carrier = "jne"
+	// Failed attempts leave the stored order alone; this stub shares it
+	shipped := func() {
+		o.Shipments[0].Status = order.ShipmentStatusShipped
+		o.Status = order.StatusShipped
+	}
+	shipped()
+	deliver := commands.NewDeliverShipmentCommandHandler(orders, payments, collections)
+
+	_, err = deliver.Handle(context.Background(), commands.DeliverShipmentCommand{ShipmentID: "s1", ReceivedBy: "Budi"})
+	assert.ErrorIs(t, err, order.ErrInvalidProof, "a photo or signature is required")
+
+	short := 200000.0
+	_, err = deliver.Handle(context.Background(), commands.DeliverShipmentCommand{ShipmentID: "s1", ReceivedBy: "Budi", PhotoURL: "https://proof.example/s1.jpg", CollectedAmount: &short})
+	assert.ErrorIs(t, err, cod.ErrAmountMismatch)
+	shipped()
+	_, err = deliver.Handle(context.Background(), commands.DeliverShipmentCommand{ShipmentID: "s1", ReceivedBy: "Budi", PhotoURL: "https://proof.example/s1.jpg"})
+	assert.ErrorIs(t, err, cod.ErrAmountMismatch, "the collected amount is required")
+
+	shipped()
+	due := 250000.0
+	s, err := deliver.Handle(context.Background(), commands.DeliverShipmentCommand{ShipmentID: "s1", ReceivedBy: "Budi", SignatureURL: "https://proof.example/s1.png", CollectedAmount: &due})
+	require.NoError(t, err)
+	assert.Equal(t, order.ShipmentStatusDelivered, s.Status)
+	assert.Equal(t, "Budi", s.Proof.ReceivedBy)
+	assert.Equal(t, order.StatusDelivered, o.Status)
+	assert.Equal(t, order.PaymentStatusPaid, o.PaymentStatus)
+	assert.Equal(t, cod.StatusCollected, collections.collection.Status)
+	assert.Equal(t, "jne", collections.collection.Carrier)
+	assert.Equal(t, payment.StatusPaid, payments.payments[0].Status)
+	assert.WithinDuration(t, now, *s.DeliveredAt, time.Minute)
+}
+
+func TestRemitCourierCash(t *testing.T) {
+	at := time.Now()
+	collected := func(id, carrier string, amount float64) *cod.Collection {
+		c := cod.NewCollection("order-"+id, "u1", "pay-"+id, amount)
+		c.ID = id
+		require.NoError(t, c.Collect(carrier, amount, at))
+		return c
+	}
+
+	a, b := collected("c1", "jne", 100000), collected("c2", "jne", 50000)
+	r, err := cod.Remit("JNE", "slip-7", 140000, []*cod.Collection{a, b}, "admin", "", at)
+	require.NoError(t, err)
+	assert.Equal(t, 150000.0, r.Expected)
+	assert.Equal(t, -10000.0, r.Difference)
+	assert.Equal(t, cod.RemittanceShort, r.Status)
+	assert.Equal(t, cod.StatusSettled, a.Status)
+	assert.Equal(t, r.ID, b.RemittanceID)
+
+	_, err = cod.Remit("jne", "", 100000, []*cod.Collection{a}, "admin", "", at)
+	assert.ErrorIs(t, err, cod.ErrNotRemittable, "settled collections are not remitted twice")
+
+	other := collected("c3", "sicepat", 75000)
+	_, err = cod.Remit("jne", "", 75000, []*cod.Collection{other}, "admin", "", at)
+	assert.ErrorIs(t, err, cod.ErrNotRemittable, "a courier remits only their own collections")
+
+	r, err = cod.Remit("sicepat", "", 75000.004, []*cod.Collection{other}, "admin", "", at)
+	require.NoError(t, err)
+	assert.Equal(t, cod.RemittanceBalanced, r.Status)
+}
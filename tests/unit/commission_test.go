@@ -0,0 +1,113 @@
+package unit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/commission"
+	"online-shop/internal/domain/order"
+)
+
+type commissionRepoStub struct {
+	commission.Repository
+	rules []*commission.Rule
+}
+
+func (r commissionRepoStub) ListActive(ctx context.Context) ([]*commission.Rule, error) {
+	return r.rules, nil
+}
+
+func TestResolveCommissionRule(t *testing.T) {
+	global := &commission.Rule{ID: "global", Scope: commission.ScopeGlobal, Active: true}
+	category := &commission.Rule{ID: "category", Scope: commission.ScopeCategory, ScopeID: "c1", Active: true}
+	merchant := &commission.Rule{ID: "merchant", Scope: commission.ScopeMerchant, ScopeID: "m1", Active: true}
+	inactive := &commission.Rule{ID: "inactive", Scope: commission.ScopeMerchant, ScopeID: "m2"}
+	newerGlobal := &commission.Rule{ID: "newer-global", Scope: commission.ScopeGlobal, Active: true}
+	newerCategory := &commission.Rule{ID: "newer-category", Scope: commission.ScopeCategory, ScopeID: "c1", Active: true}
+
+	tests := []struct {
+		name       string
+		rules      []*commission.Rule
+		categoryID string
+		merchantID string
+		want       string
+	}{
+		{"merchant wins over category", []*commission.Rule{global, category, merchant}, "c1", "m1", "merchant"},
+		{"merchant wins whatever the order", []*commission.Rule{merchant, category, global}, "c1", "m1", "merchant"},
+		{"category wins over global", []*commission.Rule{category, global}, "c1", "m1", "category"},
+		{"global for other categories", []*commission.Rule{global, category, merchant}, "c2", "m3", "global"},
+		{"inactive rules are skipped", []*commission.Rule{global, inactive}, "", "m2", "global"},
+		{"no rule", []*commission.Rule{category, merchant}, "c2", "m3", ""},
+		{"items without a merchant", []*commission.Rule{merchant, global}, "", "", "global"},
+		{"first category rule wins", []*commission.Rule{newerCategory, category, global}, "c1", "m3", "newer-category"},
+		{"first global rule wins", []*commission.Rule{newerGlobal, global}, "c2", "m3", "newer-global"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := commission.Resolve(tt.rules, tt.categoryID, tt.merchantID)
+			if tt.want == "" {
+				assert.Nil(t, rule)
+				return
+			}
+			require.NotNil(t, rule)
+			assert.Equal(t, tt.want, rule.ID)
+		})
+	}
+}
+
+func TestCommissionIsCalculatedOnTheSubtotal(t *testing.T) {
+	tests := []struct {
+		name     string
+		rate     float64
+		fixedFee float64
+		subtotal float64
+		want     float64
+	}{
+		{"rate", 10, 0, 50000, 5000},
+		{"rate and fixed fee", 5, 1000, 20000, 2000},
+		{"never more than the subtotal", 50, 8000, 10000, 10000},
+		{"no commission", 0, 0, 10000, 0},
+		{"rounded to the cent", 2.5, 0, 333.33, 8.33},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &commission.Rule{Rate: tt.rate, FixedFee: tt.fixedFee}
+			assert.Equal(t, tt.want, rule.Calculate(tt.subtotal))
+		})
+	}
+}
+
+func TestOrderItemsCarryTheirCommission(t *testing.T) {
+	products := cartProducts()
+	products.products["p1"].MerchantID = "m1"
+	products.products["p2"].CategoryID = "c1"
+	rules := commissionRepoStub{rules: []*commission.Rule{
+		{ID: "global", Scope: commission.ScopeGlobal, Rate: 5, Active: true},
+		{ID: "drinks", Scope: commission.ScopeCategory, ScopeID: "c1", Rate: 10, FixedFee: 500, Active: true},
+		{ID: "kopi-merchant", Scope: commission.ScopeMerchant, ScopeID: "m1", Rate: 2, Active: true},
+	}}
+	orders := &flashOrderRepo{orders: map[string]*order.Order{}}
+	create := commands.NewCreateOrderCommandHandler(orders, products, rules, noWarehouseRepo{}, nil, &flashSaleRepoStub{}, newMemoryFlashCounter(), commands.CreateOrderOptions{})
+
+	o, err := create.Handle(context.Background(), commands.CreateOrderCommand{
+		UserID:          "u1",
+		Items:           []commands.CreateOrderItemCmd{{ProductID: "p1", Quantity: 2}, {ProductID: "p2", Quantity: 1}},
+		ShippingAddress: order.Address{Street: "Jl. Merdeka 1", City: "Jakarta", PostalCode: "10110", Country: "ID"},
+	})
+	require.NoError(t, err)
+	require.Len(t, o.Items, 2)
+	byProduct := map[string]order.OrderItem{}
+	for _, item := range o.Items {
+		byProduct[item.ProductID] = item
+	}
+	assert.Equal(t, "kopi-merchant", byProduct["p1"].CommissionRuleID)
+	assert.Equal(t, 2000.0, byProduct["p1"].CommissionAmount, "2% of 100000")
+	assert.Equal(t, "drinks", byProduct["p2"].CommissionRuleID)
+	assert.Equal(t, 2500.0, byProduct["p2"].CommissionAmount, "10% of 20000 and the fixed fee")
+	assert.Equal(t, 4500.0, o.CommissionTotal)
+	assert.Equal(t, 115500.0, o.MerchantPayout, "the item subtotals less the commission")
+}
@@ -0,0 +1,81 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/dashboard"
+)
+
+type dashboardRepoStub struct {
+	computed int
+	since    time.Time
+}
+
+func (r *dashboardRepoStub) Compute(ctx context.Context, since time.Time, lowStockThreshold int) (*dashboard.Stats, error) {
+	r.computed++
+	r.since = since
+	return &dashboard.Stats{TodayOrders: 7, LowStockThreshold: lowStockThreshold, Since: since}, nil
+}
+
+type dashboardCacheStub struct {
+	stats *dashboard.Stats
+	ttl   time.Duration
+}
+
+func (c *dashboardCacheStub) Save(ctx context.Context, stats *dashboard.Stats, ttl time.Duration) error {
+	c.stats = stats
+	c.ttl = ttl
+	return nil
+}
+
+func (c *dashboardCacheStub) Load(ctx context.Context) (*dashboard.Stats, error) {
+	if c.stats == nil {
+		return nil, errors.New("cache miss")
+	}
+	return c.stats, nil
+}
+
+func TestRefreshDashboardStatsCachesSnapshot(t *testing.T) {
+	repo := &dashboardRepoStub{}
+	cache := &dashboardCacheStub{}
+	handler := commands.NewRefreshDashboardStatsCommandHandler(repo, cache)
+
+	stats, err := handler.Handle(context.Background(), commands.RefreshDashboardStatsCommand{LowStockThreshold: 3, TTL: time.Minute})
+	require.NoError(t, err)
+
+	assert.Equal(t, dashboard.StartOfDay(time.Now()), repo.since)
+	assert.Equal(t, 3, stats.LowStockThreshold)
+	assert.Same(t, stats, cache.stats)
+	assert.Equal(t, time.Minute, cache.ttl)
+}
+
+func TestGetDashboardStatsServesTodaysSnapshot(t *testing.T) {
+	repo := &dashboardRepoStub{}
+	cache := &dashboardCacheStub{stats: &dashboard.Stats{TodayOrders: 42, Since: dashboard.StartOfDay(time.Now())}}
+	handler := queries.NewGetDashboardStatsQueryHandler(cache, repo, 10)
+
+	stats, err := handler.Handle(context.Background(), queries.GetDashboardStatsQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), stats.TodayOrders)
+	assert.Zero(t, repo.computed)
+}
+
+func TestGetDashboardStatsRecomputesStaleSnapshot(t *testing.T) {
+	repo := &dashboardRepoStub{}
+	yesterday := dashboard.StartOfDay(time.Now()).AddDate(0, 0, -1)
+	cache := &dashboardCacheStub{stats: &dashboard.Stats{TodayOrders: 42, Since: yesterday}}
+	handler := queries.NewGetDashboardStatsQueryHandler(cache, repo, 10)
+
+	stats, err := handler.Handle(context.Background(), queries.GetDashboardStatsQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), stats.TodayOrders)
+	assert.Equal(t, 1, repo.computed)
+}
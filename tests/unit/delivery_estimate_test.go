@@ -0,0 +1,175 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/payment"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/shipping"
+	"online-shop/internal/domain/user"
+	"online-shop/internal/domain/warehouse"
+)
+
+type estimateWarehouseRepo struct {
+	warehouse.Repository
+	warehouses []*warehouse.Warehouse
+}
+
+func (r *estimateWarehouseRepo) ListActive(ctx context.Context) ([]*warehouse.Warehouse, error) {
+	return r.warehouses, nil
+}
+
+func (r *estimateWarehouseRepo) GetByID(ctx context.Context, id string) (*warehouse.Warehouse, error) {
+	for _, w := range r.warehouses {
+		if w.ID == id {
+			return w, nil
+		}
+	}
+	return nil, assert.AnError
+}
+
+type estimateStockRepo struct {
+	warehouse.StockRepository
+	stock []*warehouse.Stock
+}
+
+func (r *estimateStockRepo) GetByProducts(ctx context.Context, productIDs []string) ([]*warehouse.Stock, error) {
+	return r.stock, nil
+}
+
+type confirmationNotifierStub struct {
+	sent []order.Confirmation
+}
+
+func (n *confirmationNotifierStub) OrderConfirmed(ctx context.Context, c order.Confirmation) error {
+	n.sent = append(n.sent, c)
+	return nil
+}
+
+func jakartaSchedule(t *testing.T, holidays ...string) *shipping.Schedule {
+	location, err := time.LoadLocation("Asia/Jakarta")
+	require.NoError(t, err)
+	schedule, err := shipping.NewSchedule(location, true, holidays)
+	require.NoError(t, err)
+	return schedule
+}
+
+func days(n int) *int { return &n }
+
+func TestDeliveryEstimateCountsWorkingDays(t *testing.T) {
+	schedule := jakartaSchedule(t)
+	jakarta, _ := time.LoadLocation("Asia/Jakarta")
+	processing := shipping.Processing{Days: 1, CutoffHour: 14}
+
+	wednesdayMorning := time.Date(2026, 10, 14, 10, 0, 0, 0, jakarta)
+	assert.Equal(t, "2026-10-15", schedule.Dispatch(wednesdayMorning, processing).Format(shipping.DateLayout))
+	assert.Equal(t, order.DeliveryEstimate{Earliest: "2026-10-20", Latest: "2026-10-22"},
+		schedule.Estimate(wednesdayMorning, processing, 3, 5), "the weekend is not counted")
+
+	fridayAfternoon := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC) // 15:00 in Jakarta
+	assert.Equal(t, "2026-10-20", schedule.Dispatch(fridayAfternoon, processing).Format(shipping.DateLayout), "after the cutoff processing starts on Monday")
+	assert.Equal(t, order.DeliveryEstimate{Earliest: "2026-10-21", Latest: "2026-10-21"}, schedule.Estimate(fridayAfternoon, processing, 1, 0))
+
+	holiday := jakartaSchedule(t, "2026-10-21")
+	assert.Equal(t, order.DeliveryEstimate{Earliest: "2026-10-22", Latest: "2026-10-23"}, holiday.Estimate(fridayAfternoon, processing, 1, 2))
+
+	sameDay := shipping.Processing{}
+	assert.Equal(t, shipping.Processing{}, schedule.Fastest(fridayAfternoon, []shipping.Processing{processing, sameDay}))
+	assert.Equal(t, processing, schedule.Slowest(fridayAfternoon, []shipping.Processing{sameDay, processing}))
+
+	_, err := shipping.NewSchedule(jakarta, true, []string{"25/12/2026"})
+	assert.Error(t, err)
+}
+
+func TestWarehouseProcessingFallsBackToTheShop(t *testing.T) {
+	fallback := shipping.Processing{Days: 1, CutoffHour: 14}
+	assert.Equal(t, fallback, (&warehouse.Warehouse{}).Processing(fallback))
+	assert.Equal(t, shipping.Processing{Days: 0, CutoffHour: 14}, (&warehouse.Warehouse{ProcessingDays: days(0)}).Processing(fallback))
+}
+
+func TestDeliveryEstimateFromQuickestWarehouse(t *testing.T) {
+	schedule := jakartaSchedule(t)
+	fallback := shipping.Processing{Days: 1}
+	products := &flashProductRepo{products: map[string]*product.Product{
+		"p1": {ID: "p1", Price: 100000, Status: product.StatusActive},
+	}}
+	warehouses := &estimateWarehouseRepo{warehouses: []*warehouse.Warehouse{
+		{ID: "slow", ProcessingDays: days(4), Active: true},
+		{ID: "quick", ProcessingDays: days(2), Active: true},
+		{ID: "empty", ProcessingDays: days(0), Active: true},
+	}}
+	stock := &estimateStockRepo{stock: []*warehouse.Stock{
+		{WarehouseID: "slow", ProductID: "p1", Quantity: 10},
+		{WarehouseID: "quick", ProductID: "p1", Quantity: 1},
+		{WarehouseID: "empty", ProductID: "p1", Quantity: 3, Reserved: 3},
+	}}
+	zones := &shippingZoneRepo{zones: shippingZones(t)}
+	handler := queries.NewGetDeliveryEstimateQueryHandler(products, zones, warehouses, stock, schedule, fallback)
+
+	quote, err := handler.Handle(context.Background(), queries.GetDeliveryEstimateQuery{ProductID: "p1", Country: "id", PostalCode: "40111"})
+	require.NoError(t, err)
+	assert.Equal(t, "Indonesia", quote.ZoneName)
+	require.Len(t, quote.Options, 2)
+	regular := quote.Options[0]
+	require.NotNil(t, regular.Estimate)
+	expected := schedule.Estimate(time.Now(), shipping.Processing{Days: 2}, 3, 5)
+	assert.Equal(t, expected, *regular.Estimate, "the product leaves from the quickest warehouse holding it")
+
+	_, err = handler.Handle(context.Background(), queries.GetDeliveryEstimateQuery{ProductID: "p1", Country: "SG"})
+	assert.ErrorIs(t, err, shipping.ErrNotServiceable)
+
+	quoter := queries.NewGetShippingQuoteQueryHandler(zones, nil, nil, time.Hour, handler)
+	quote, err = quoter.Handle(context.Background(), queries.GetShippingQuoteQuery{Address: jakartaAddress, ProductIDs: []string{"p1"}})
+	require.NoError(t, err)
+	require.NotNil(t, quote.Options[0].Estimate, "checkout quotes carry estimates")
+}
+
+func TestOrderConfirmationReestimatesAndNotifies(t *testing.T) {
+	schedule := jakartaSchedule(t)
+	warehouses := &estimateWarehouseRepo{warehouses: []*warehouse.Warehouse{
+		{ID: "w1", ProcessingDays: days(1)},
+		{ID: "w2", ProcessingDays: days(3)},
+	}}
+	users := &deletionUserRepoStub{users: map[string]*user.User{
+		"u1": {ID: "u1", Email: "budi@example.com", FirstName: "Budi", LastName: "Santoso", Locale: "id"},
+	}}
+	products := &flashProductRepo{products: map[string]*product.Product{"p1": {ID: "p1", Name: "Coffee Beans"}}}
+	notifier := &confirmationNotifierStub{}
+	confirmer := commands.NewOrderConfirmer(warehouses, users, products, notifier, schedule, shipping.Processing{Days: 1})
+
+	orders := &flashOrderRepo{orders: map[string]*order.Order{}}
+	payments := &memoryPaymentRepo{}
+	o := newPayableOrder(orders, 500)
+	o.Items = []order.OrderItem{
+		{ID: "i1", ProductID: "p1", Quantity: 1, Subtotal: 250, WarehouseID: "w1"},
+		{ID: "i2", ProductID: "p1", Quantity: 1, Subtotal: 250, WarehouseID: "w2"},
+	}
+	o.Shipping = order.ShippingCharge{ZoneID: "z1", Rate: "Regular", MinDays: 2, MaxDays: 4}
+	o.Delivery = order.DeliveryEstimate{Earliest: "2026-01-01", Latest: "2026-01-02"}
+
+	reconcile := commands.NewReconcileOrderPaymentsCommandHandler(orders, payments, confirmer, nil)
+	pay := payment.NewPayment(o.ID, "u1", 500, payment.MethodEWallet)
+	pay.MarkAsPaid("trx-1")
+	require.NoError(t, payments.Create(context.Background(), pay))
+	require.NoError(t, reconcile.Handle(context.Background(), commands.ReconcileOrderPaymentsCommand{OrderID: o.ID}))
+
+	assert.Equal(t, order.StatusConfirmed, o.Status)
+	assert.Equal(t, schedule.Estimate(time.Now(), shipping.Processing{Days: 3}, 2, 4), o.Delivery, "estimated again from the slowest warehouse")
+	require.Len(t, notifier.sent, 1)
+	sent := notifier.sent[0]
+	assert.Equal(t, "budi@example.com", sent.Email)
+	assert.Equal(t, "Budi Santoso", sent.CustomerName)
+	assert.Equal(t, "id", sent.Locale)
+	assert.Equal(t, "Coffee Beans", sent.ProductNames["p1"])
+
+	require.NoError(t, reconcile.Handle(context.Background(), commands.ReconcileOrderPaymentsCommand{OrderID: o.ID}))
+	assert.Len(t, notifier.sent, 1, "an order is confirmed once")
+}
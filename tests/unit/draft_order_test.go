@@ -0,0 +1,240 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/draftorder"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/payment"
+	"online-shop/internal/domain/user"
+	"online-shop/pkg/apperror"
+)
+
+type memoryDraftRepo struct {
+	drafts map[string]*draftorder.Draft
+}
+
+func (r *memoryDraftRepo) Create(ctx context.Context, d *draftorder.Draft) error {
+	r.drafts[d.ID] = d
+	return nil
+}
+
+func (r *memoryDraftRepo) GetByID(ctx context.Context, id string) (*draftorder.Draft, error) {
+	if d, ok := r.drafts[id]; ok {
+		return d, nil
+	}
+	return nil, draftorder.ErrNotFound
+}
+
+func (r *memoryDraftRepo) GetByToken(ctx context.Context, token string) (*draftorder.Draft, error) {
+	for _, d := range r.drafts {
+		if d.Token == token {
+			return d, nil
+		}
+	}
+	return nil, draftorder.ErrNotFound
+}
+
+func (r *memoryDraftRepo) Update(ctx context.Context, d *draftorder.Draft) error {
+	r.drafts[d.ID] = d
+	return nil
+}
+
+// Claim stores a converting copy, leaving the draft the caller holds as it
+// was read, as the database does
+func (r *memoryDraftRepo) Claim(ctx context.Context, id string, now time.Time) (bool, error) {
+	d, ok := r.drafts[id]
+	if !ok || !d.Open() {
+		return false, nil
+	}
+	claimed := *d
+	claimed.Status = draftorder.StatusConverting
+	claimed.UpdatedAt = now
+	r.drafts[id] = &claimed
+	return true, nil
+}
+
+func (r *memoryDraftRepo) List(ctx context.Context, filter draftorder.Filter, limit, offset int) ([]*draftorder.Draft, error) {
+	var drafts []*draftorder.Draft
+	for _, d := range r.drafts {
+		if filter.MerchantID == "" || d.MerchantID == filter.MerchantID {
+			drafts = append(drafts, d)
+		}
+	}
+	return drafts, nil
+}
+
+func TestDraftOrderIsPaidThroughItsLink(t *testing.T) {
+	ctx := context.Background()
+	products := cartProducts()
+	orders := &flashOrderRepo{orders: map[string]*order.Order{}}
+	payments := &memoryPaymentRepo{}
+	users := &manualOrderUserRepo{&preferenceUserRepoStub{&deletionUserRepoStub{users: map[string]*user.User{}}}}
+	drafts := &memoryDraftRepo{drafts: map[string]*draftorder.Draft{}}
+	provider := &linkProviderStub{}
+	create := commands.NewCreateOrderCommandHandler(orders, products, noCommissionRepo{}, noWarehouseRepo{}, nil, &flashSaleRepoStub{}, newMemoryFlashCounter(), commands.CreateOrderOptions{})
+	draft := commands.NewCreateDraftOrderCommandHandler(drafts, users, products, create, &versionAuditStub{}, 7*24*time.Hour)
+	pay := commands.NewPayDraftOrderCommandHandler(drafts, payments, provider)
+	complete := commands.NewCompleteDraftOrderCommandHandler(drafts, orders, payments, create, nil, nil)
+
+	cmd := commands.CreateDraftOrderCommand{
+		ActorID:         "admin-1",
+		ActorRole:       "admin",
+		Customer:        &commands.ManualOrderCustomerCmd{Email: "budi@example.com", FirstName: "Budi"},
+		Items:           []commands.ManualOrderItemCmd{{ProductID: "p1", Quantity: 2, Price: 45000}, {ProductID: "p2", Quantity: 1}},
+		ShippingAddress: order.Address{Street: "Jl. Merdeka 1", City: "Jakarta", PostalCode: "10110", Country: "ID"},
+	}
+	_, err := draft.Handle(ctx, cmd)
+	assert.Equal(t, commands.ErrInvalidDraftOrder.Code, apperror.From(err).Code, "overridden prices need a reason")
+
+	cmd.PriceReason = "Bulk discount"
+	d, err := draft.Handle(ctx, cmd)
+	require.NoError(t, err)
+	assert.Equal(t, draftorder.StatusOpen, d.Status)
+	assert.Equal(t, 110000.0, d.TotalAmount)
+	assert.Empty(t, orders.orders, "nothing is ordered until the draft is paid")
+	assert.Equal(t, 10, products.products["p1"].Stock, "drafts reserve no stock")
+
+	started, err := pay.Handle(ctx, commands.PayDraftOrderCommand{Token: d.Token, Method: payment.MethodEWallet})
+	require.NoError(t, err)
+	assert.Equal(t, d.ID, started.DraftOrderID)
+	assert.Empty(t, started.OrderID)
+	assert.Equal(t, 110000.0, started.Amount)
+
+	again, err := pay.Handle(ctx, commands.PayDraftOrderCommand{Token: d.Token, Method: payment.MethodEWallet})
+	require.NoError(t, err)
+	assert.Equal(t, started.ID, again.ID, "a reloaded page reuses the live payment")
+	assert.Equal(t, 1, provider.links)
+
+	// An unpaid payment leaves the draft open
+	d, err = complete.Handle(ctx, commands.CompleteDraftOrderCommand{PaymentID: started.ID})
+	require.NoError(t, err)
+	assert.Equal(t, draftorder.StatusOpen, d.Status)
+
+	started.MarkAsPaid("trx-1")
+	d, err = complete.Handle(ctx, commands.CompleteDraftOrderCommand{PaymentID: started.ID})
+	require.NoError(t, err)
+	assert.Equal(t, draftorder.StatusCompleted, d.Status)
+	require.Len(t, orders.orders, 1)
+	o := orders.orders[d.OrderID]
+	require.NotNil(t, o)
+	assert.Equal(t, "admin-1", o.PlacedBy)
+	assert.Equal(t, order.ChannelPaymentLink, o.Channel)
+	assert.Equal(t, 110000.0, o.TotalAmount)
+	assert.Equal(t, order.PaymentStatusPaid, o.PaymentStatus)
+	assert.Equal(t, order.StatusConfirmed, o.Status)
+	assert.Equal(t, o.ID, started.OrderID)
+
+	// The webhook is delivered again
+	_, err = complete.Handle(ctx, commands.CompleteDraftOrderCommand{PaymentID: started.ID})
+	require.NoError(t, err)
+	assert.Len(t, orders.orders, 1)
+
+	_, err = pay.Handle(ctx, commands.PayDraftOrderCommand{Token: d.Token, Method: payment.MethodEWallet})
+	assert.Equal(t, commands.ErrDraftOrderWrongStatus.Code, apperror.From(err).Code)
+}
+
+func TestDraftOrderThatCannotBePlacedFails(t *testing.T) {
+	ctx := context.Background()
+	products := cartProducts()
+	orders := &flashOrderRepo{orders: map[string]*order.Order{}}
+	payments := &memoryPaymentRepo{}
+	users := &manualOrderUserRepo{&preferenceUserRepoStub{&deletionUserRepoStub{users: map[string]*user.User{}}}}
+	drafts := &memoryDraftRepo{drafts: map[string]*draftorder.Draft{}}
+	create := commands.NewCreateOrderCommandHandler(orders, products, noCommissionRepo{}, noWarehouseRepo{}, nil, &flashSaleRepoStub{}, newMemoryFlashCounter(), commands.CreateOrderOptions{})
+	draft := commands.NewCreateDraftOrderCommandHandler(drafts, users, products, create, &versionAuditStub{}, 7*24*time.Hour)
+	pay := commands.NewPayDraftOrderCommandHandler(drafts, payments, &linkProviderStub{})
+	complete := commands.NewCompleteDraftOrderCommandHandler(drafts, orders, payments, create, nil, nil)
+
+	d, err := draft.Handle(ctx, commands.CreateDraftOrderCommand{
+		ActorID:         "admin-1",
+		Customer:        &commands.ManualOrderCustomerCmd{Email: "sari@example.com", FirstName: "Sari"},
+		Items:           []commands.ManualOrderItemCmd{{ProductID: "p2", Quantity: 3}},
+		ShippingAddress: order.Address{Street: "Jl. Sudirman 2", City: "Bandung", PostalCode: "40111", Country: "ID"},
+	})
+	require.NoError(t, err)
+
+	started, err := pay.Handle(ctx, commands.PayDraftOrderCommand{Token: d.Token, Method: payment.MethodBankTransfer})
+	require.NoError(t, err)
+
+	products.products["p2"].Stock = 1
+	started.MarkAsPaid("trx-2")
+	d, err = complete.Handle(ctx, commands.CompleteDraftOrderCommand{PaymentID: started.ID})
+	require.NoError(t, err)
+	assert.Equal(t, draftorder.StatusFailed, d.Status)
+	assert.NotEmpty(t, d.FailureReason)
+	assert.Empty(t, orders.orders)
+	assert.Empty(t, started.OrderID, "the payment is left for staff to refund")
+}
+
+func TestDraftOrderBeingConvertedPlacesOneOrder(t *testing.T) {
+	ctx := context.Background()
+	products := cartProducts()
+	orders := &flashOrderRepo{orders: map[string]*order.Order{}}
+	payments := &memoryPaymentRepo{}
+	users := &manualOrderUserRepo{&preferenceUserRepoStub{&deletionUserRepoStub{users: map[string]*user.User{}}}}
+	drafts := &memoryDraftRepo{drafts: map[string]*draftorder.Draft{}}
+	create := commands.NewCreateOrderCommandHandler(orders, products, noCommissionRepo{}, noWarehouseRepo{}, nil, &flashSaleRepoStub{}, newMemoryFlashCounter(), commands.CreateOrderOptions{})
+	draft := commands.NewCreateDraftOrderCommandHandler(drafts, users, products, create, &versionAuditStub{}, 7*24*time.Hour)
+	pay := commands.NewPayDraftOrderCommandHandler(drafts, payments, &linkProviderStub{})
+	complete := commands.NewCompleteDraftOrderCommandHandler(drafts, orders, payments, create, nil, nil)
+
+	d, err := draft.Handle(ctx, commands.CreateDraftOrderCommand{
+		ActorID:         "admin-1",
+		Customer:        &commands.ManualOrderCustomerCmd{Email: "sari@example.com", FirstName: "Sari"},
+		Items:           []commands.ManualOrderItemCmd{{ProductID: "p2", Quantity: 1}},
+		ShippingAddress: order.Address{Street: "Jl. Sudirman 2", City: "Bandung", PostalCode: "40111", Country: "ID"},
+	})
+	require.NoError(t, err)
+	started, err := pay.Handle(ctx, commands.PayDraftOrderCommand{Token: d.Token, Method: payment.MethodEWallet})
+	require.NoError(t, err)
+	started.MarkAsPaid("trx-3")
+
+	// Another delivery of the webhook claimed the draft first
+	claimed, err := drafts.Claim(ctx, d.ID, time.Now())
+	require.NoError(t, err)
+	require.True(t, claimed)
+	_, err = complete.Handle(ctx, commands.CompleteDraftOrderCommand{PaymentID: started.ID})
+	assert.Equal(t, commands.ErrDraftOrderConverting.Code, apperror.From(err).Code)
+	assert.Equal(t, apperror.KindUnavailable, apperror.From(err).Kind, "the delivery is retried")
+	assert.Empty(t, orders.orders)
+	assert.Empty(t, started.OrderID)
+	assert.Equal(t, draftorder.StatusConverting, drafts.drafts[d.ID].Status, "the draft is not failed")
+
+	claimed, err = drafts.Claim(ctx, d.ID, time.Now())
+	require.NoError(t, err)
+	assert.False(t, claimed, "a draft is claimed once")
+}
+
+func TestDraftOrderSummaryPage(t *testing.T) {
+	drafts := &memoryDraftRepo{drafts: map[string]*draftorder.Draft{}}
+	d, err := draftorder.New("admin-1", "", "u1", "budi@example.com", []draftorder.Item{{ProductID: "p1", Name: "Kopi", Quantity: 2, Price: 45000, ListPrice: 50000}}, 100000, 0)
+	require.NoError(t, err)
+	d.ShippingFee = 10000
+	d.ExpiresAt = d.ExpiresAt.AddDate(0, 0, 7)
+	drafts.drafts[d.ID] = d
+
+	summaries := queries.NewGetDraftOrderSummaryQueryHandler(drafts, &memoryEmailTemplateRepo{})
+	summary, err := summaries.Handle(context.Background(), queries.GetDraftOrderSummaryQuery{Token: d.Token, HTML: true, PayURL: "/api/v1/draft-orders/" + d.Token + "/pay"})
+	require.NoError(t, err)
+	assert.True(t, summary.Payable)
+	assert.Equal(t, 90000.0, summary.Subtotal)
+	assert.Contains(t, summary.HTML, "Kopi")
+	assert.Contains(t, summary.HTML, "/pay")
+
+	require.NoError(t, d.Cancel(d.UpdatedAt))
+	summary, err = summaries.Handle(context.Background(), queries.GetDraftOrderSummaryQuery{Token: d.Token, HTML: true, PayURL: "/pay"})
+	require.NoError(t, err)
+	assert.False(t, summary.Payable)
+	assert.NotContains(t, summary.HTML, "/pay")
+
+	_, err = summaries.Handle(context.Background(), queries.GetDraftOrderSummaryQuery{Token: "unknown"})
+	assert.Equal(t, commands.ErrDraftOrderNotFound.Code, apperror.From(err).Code)
+}
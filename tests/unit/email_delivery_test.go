@@ -0,0 +1,421 @@
+package unit
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/emaildelivery"
+	"online-shop/internal/domain/user"
+	emailprovider "online-shop/internal/infrastructure/emaildelivery"
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/config"
+)
+
+type memorySuppressionRepo struct {
+	entries map[string]*emaildelivery.Suppression
+}
+
+func newMemorySuppressionRepo() *memorySuppressionRepo {
+	return &memorySuppressionRepo{entries: map[string]*emaildelivery.Suppression{}}
+}
+
+func (r *memorySuppressionRepo) Add(ctx context.Context, s *emaildelivery.Suppression) error {
+	r.entries[s.Email] = s
+	return nil
+}
+
+func (r *memorySuppressionRepo) Get(ctx context.Context, email string) (*emaildelivery.Suppression, error) {
+	s, ok := r.entries[email]
+	if !ok {
+		return nil, emaildelivery.ErrSuppressionNotFound
+	}
+	return s, nil
+}
+
+func (r *memorySuppressionRepo) List(ctx context.Context, limit, offset int) ([]*emaildelivery.Suppression, error) {
+	var list []*emaildelivery.Suppression
+	for _, s := range r.entries {
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+func (r *memorySuppressionRepo) Remove(ctx context.Context, email string) error {
+	if _, ok := r.entries[email]; !ok {
+		return emaildelivery.ErrSuppressionNotFound
+	}
+	delete(r.entries, email)
+	return nil
+}
+
+type stubEmailProvider struct {
+	name string
+	err  error
+	// failures is how many sends fail with err before they succeed; 0
+	// fails every send while err is set
+	failures int
+	calls    int
+	sent     []string
+}
+
+func (p *stubEmailProvider) Name() string { return p.name }
+
+func (p *stubEmailProvider) Send(ctx context.Context, msg *emaildelivery.Message) error {
+	p.calls++
+	if p.err != nil && (p.failures == 0 || p.calls <= p.failures) {
+		return p.err
+	}
+	p.sent = append(p.sent, msg.To)
+	return nil
+}
+
+type memoryDeadLetterRepo struct {
+	entries map[string]*emaildelivery.DeadLetter
+}
+
+func (r *memoryDeadLetterRepo) Create(ctx context.Context, d *emaildelivery.DeadLetter) error {
+	r.entries[d.ID] = d
+	return nil
+}
+
+func (r *memoryDeadLetterRepo) Get(ctx context.Context, id string) (*emaildelivery.DeadLetter, error) {
+	d, ok := r.entries[id]
+	if !ok {
+		return nil, emaildelivery.ErrDeadLetterNotFound
+	}
+	return d, nil
+}
+
+func (r *memoryDeadLetterRepo) List(ctx context.Context, pending bool, limit, offset int) ([]*emaildelivery.DeadLetter, error) {
+	var list []*emaildelivery.DeadLetter
+	for _, d := range r.entries {
+		if !pending || d.ResentAt == nil {
+			list = append(list, d)
+		}
+	}
+	return list, nil
+}
+
+func (r *memoryDeadLetterRepo) MarkResent(ctx context.Context, id string, at time.Time) error {
+	d, ok := r.entries[id]
+	if !ok {
+		return emaildelivery.ErrDeadLetterNotFound
+	}
+	d.ResentAt = &at
+	return nil
+}
+
+type recordingDeliveryPublisher struct {
+	resent  []string
+	batches []*emaildelivery.Batch
+}
+
+func (p *recordingDeliveryPublisher) Resend(ctx context.Context, d *emaildelivery.DeadLetter) error {
+	p.resent = append(p.resent, d.ID)
+	return nil
+}
+
+func (p *recordingDeliveryPublisher) PublishBatch(ctx context.Context, b *emaildelivery.Batch) error {
+	p.batches = append(p.batches, b)
+	return nil
+}
+
+func TestEmailSenderFailsOverAndSkipsSuppressed(t *testing.T) {
+	primary := &stubEmailProvider{name: "ses", err: errors.New("throttled")}
+	secondary := &stubEmailProvider{name: "sendgrid"}
+	suppressions := newMemorySuppressionRepo()
+	sender := emaildelivery.NewSender(suppressions, primary, secondary)
+
+	provider, err := sender.Send(context.Background(), &emaildelivery.Message{To: "budi@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "sendgrid", provider, "the next provider takes over")
+	assert.Equal(t, []string{"budi@example.com"}, secondary.sent)
+
+	secondary.err = errors.New("unauthorized")
+	_, err = sender.Send(context.Background(), &emaildelivery.Message{To: "budi@example.com"})
+	assert.EqualError(t, err, "every email provider failed: ses: throttled; sendgrid: unauthorized")
+
+	secondary.err = nil
+	require.NoError(t, suppressions.Add(context.Background(), emaildelivery.NewSuppression(" Ani@Example.com", emaildelivery.ReasonComplaint, "ses", "")))
+	_, err = sender.Send(context.Background(), &emaildelivery.Message{To: "ani@example.COM"})
+	assert.ErrorIs(t, err, emaildelivery.ErrSuppressed)
+	assert.Len(t, secondary.sent, 1, "suppressed addresses are not sent to")
+
+	_, err = emaildelivery.NewSender(nil).Send(context.Background(), &emaildelivery.Message{To: "budi@example.com"})
+	assert.ErrorIs(t, err, emaildelivery.ErrNoProvider)
+}
+
+func mailgunNotification(t *testing.T, key, event, severity string) []byte {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte("1700000000" + "token-1"))
+	body, err := json.Marshal(map[string]interface{}{
+		"signature": map[string]string{
+			"timestamp": "1700000000",
+			"token":     "token-1",
+			"signature": hex.EncodeToString(mac.Sum(nil)),
+		},
+		"event-data": map[string]interface{}{
+			"event":           event,
+			"severity":        severity,
+			"recipient":       "Budi@example.com",
+			"timestamp":       1700000000.5,
+			"delivery-status": map[string]string{"description": "mailbox does not exist"},
+		},
+	})
+	require.NoError(t, err)
+	return body
+}
+
+func TestIngestMailgunEvents(t *testing.T) {
+	mailgun := emailprovider.NewMailgunProvider(&config.MailgunConfig{Domain: "mg.example.com", APIKey: "key", WebhookSigningKey: "signing-key"})
+	suppressions := newMemorySuppressionRepo()
+	ingest := commands.NewIngestEmailEventsCommandHandler(map[string]emaildelivery.Webhook{"mailgun": mailgun}, suppressions)
+
+	result, err := ingest.Handle(context.Background(), commands.IngestEmailEventsCommand{Provider: "mailgun", Body: mailgunNotification(t, "signing-key", "failed", "temporary")})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Events)
+	assert.Zero(t, result.Suppressed, "soft bounces are retried by the provider")
+
+	result, err = ingest.Handle(context.Background(), commands.IngestEmailEventsCommand{Provider: "mailgun", Body: mailgunNotification(t, "signing-key", "failed", "permanent")})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Suppressed)
+	suppression, err := suppressions.Get(context.Background(), "budi@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, emaildelivery.ReasonBounce, suppression.Reason)
+	assert.Equal(t, "mailbox does not exist", suppression.Detail)
+
+	_, err = ingest.Handle(context.Background(), commands.IngestEmailEventsCommand{Provider: "mailgun", Body: mailgunNotification(t, "forged-key", "complained", "")})
+	assert.ErrorIs(t, err, emaildelivery.ErrInvalidWebhook)
+	assert.Equal(t, commands.ErrInvalidEmailWebhook.Code, apperror.From(err).Code)
+
+	_, err = ingest.Handle(context.Background(), commands.IngestEmailEventsCommand{Provider: "postmark"})
+	assert.Equal(t, commands.ErrUnknownEmailWebhook.Code, apperror.From(err).Code)
+}
+
+func TestSendGridWebhookVerifiesSignature(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	sendGrid, err := emailprovider.NewSendGridProvider(&config.SendGridConfig{WebhookPublicKey: base64.StdEncoding.EncodeToString(der)})
+	require.NoError(t, err)
+
+	body := []byte(`[{"email":"ani@example.com","timestamp":1700000000,"event":"spamreport"},` +
+		`{"email":"budi@example.com","event":"bounce","type":"blocked","reason":"mailbox full"},` +
+		`{"email":"citra@example.com","event":"delivered"}]`)
+	digest := sha256.Sum256(append([]byte("1700000000"), body...))
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+
+	header := http.Header{}
+	header.Set("X-Twilio-Email-Event-Webhook-Signature", base64.StdEncoding.EncodeToString(signature))
+	header.Set("X-Twilio-Email-Event-Webhook-Timestamp", "1700000000")
+	events, err := sendGrid.Events(context.Background(), header, body)
+	require.NoError(t, err)
+	require.Len(t, events, 2, "only bounces and complaints are events")
+	assert.True(t, events[0].Suppresses())
+	assert.Equal(t, emaildelivery.EventComplaint, events[0].Type)
+	assert.False(t, events[1].Suppresses(), "blocked is a soft bounce")
+
+	header.Set("X-Twilio-Email-Event-Webhook-Timestamp", "1700000001")
+	_, err = sendGrid.Events(context.Background(), header, body)
+	assert.ErrorIs(t, err, emaildelivery.ErrInvalidWebhook)
+}
+
+func TestEmailProvidersSend(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r)
+		bodies = append(bodies, string(body))
+		if strings.HasPrefix(r.URL.Path, "/v3/mail/send") {
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	defer server.Close()
+
+	msg := &emaildelivery.Message{From: "noreply@example.com", To: "budi@example.com", Subject: "Halo", HTML: "<p>Halo</p>", Locale: "id"}
+
+	ses, err := emailprovider.NewSESProvider(&config.SESConfig{Region: "ap-southeast-1", AccessKeyID: "AKID", SecretAccessKey: "secret", Endpoint: server.URL})
+	require.NoError(t, err)
+	require.NoError(t, ses.Send(context.Background(), msg))
+	assert.Equal(t, "/v2/email/outbound-emails", requests[0].URL.Path)
+	assert.Contains(t, requests[0].Header.Get("Authorization"), "Credential=AKID/")
+	assert.Contains(t, requests[0].Header.Get("Authorization"), "/ap-southeast-1/ses/aws4_request")
+	assert.Contains(t, bodies[0], `"ToAddresses":["budi@example.com"]`)
+
+	sendGrid, err := emailprovider.NewSendGridProvider(&config.SendGridConfig{APIKey: "SG.key", Endpoint: server.URL})
+	require.NoError(t, err)
+	require.NoError(t, sendGrid.Send(context.Background(), msg))
+	assert.Equal(t, "Bearer SG.key", requests[1].Header.Get("Authorization"))
+	assert.Contains(t, bodies[1], `"subject":"Halo"`)
+
+	mailgun := emailprovider.NewMailgunProvider(&config.MailgunConfig{Domain: "mg.example.com", APIKey: "key", Endpoint: server.URL})
+	require.NoError(t, mailgun.Send(context.Background(), msg))
+	assert.Equal(t, "/v3/mg.example.com/messages", requests[2].URL.Path)
+	user, password, _ := requests[2].BasicAuth()
+	assert.Equal(t, "api", user)
+	assert.Equal(t, "key", password)
+	form, err := url.ParseQuery(bodies[2])
+	require.NoError(t, err)
+	assert.Equal(t, "budi@example.com", form.Get("to"))
+	assert.Equal(t, "id", form.Get("h:Content-Language"))
+
+	providers, err := emailprovider.Providers(&config.Config{Email: config.EmailConfig{Providers: []string{"mailgun", "smtp"}}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mailgun", "smtp"}, emaildelivery.NewSender(nil, providers...).Providers())
+}
+
+func TestEmailSenderRetriesTransientFailures(t *testing.T) {
+	flaky := &stubEmailProvider{name: "smtp", err: errors.New("421 try again later"), failures: 2}
+	sender := emaildelivery.NewSender(nil, flaky)
+	sender.SetRetry(emaildelivery.RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+
+	provider, err := sender.Send(context.Background(), &emaildelivery.Message{To: "budi@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "smtp", provider)
+	assert.Equal(t, 3, flaky.calls)
+
+	rejected := &stubEmailProvider{name: "smtp", err: emaildelivery.Permanent(errors.New("550 no such user"))}
+	sender = emaildelivery.NewSender(nil, rejected)
+	sender.SetRetry(emaildelivery.RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond})
+	_, err = sender.Send(context.Background(), &emaildelivery.Message{To: "budi@example.com"})
+	assert.True(t, emaildelivery.IsPermanent(err))
+	assert.Equal(t, 1, rejected.calls, "permanent failures are not retried")
+
+	down := &stubEmailProvider{name: "smtp", err: errors.New("connection refused")}
+	sender = emaildelivery.NewSender(nil, down)
+	sender.SetRetry(emaildelivery.RetryPolicy{Attempts: 2, BaseDelay: time.Millisecond})
+	_, err = sender.Send(context.Background(), &emaildelivery.Message{To: "budi@example.com"})
+	assert.False(t, emaildelivery.IsPermanent(err), "running out of retries is not permanent")
+	assert.Equal(t, 2, down.calls)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := emaildelivery.RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, ceiling := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		delay := policy.Backoff(attempt)
+		assert.GreaterOrEqual(t, delay, ceiling/2, "attempt %d", attempt)
+		assert.LessOrEqual(t, delay, ceiling, "attempt %d", attempt)
+	}
+}
+
+func TestThrottledProviderWaitsForItsLimit(t *testing.T) {
+	stub := &stubEmailProvider{name: "ses"}
+	throttled := emaildelivery.Throttle(stub, 0.01)
+	assert.Equal(t, "ses", throttled.Name())
+	require.NoError(t, throttled.Send(context.Background(), &emaildelivery.Message{To: "budi@example.com"}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Error(t, throttled.Send(ctx, &emaildelivery.Message{To: "ani@example.com"}), "the next send would wait past the deadline")
+	assert.Len(t, stub.sent, 1)
+}
+
+func TestEmailProviderRejectionsArePermanent(t *testing.T) {
+	status := http.StatusBadRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	mailgun := emailprovider.NewMailgunProvider(&config.MailgunConfig{Domain: "mg.example.com", APIKey: "key", Endpoint: server.URL})
+	err := mailgun.Send(context.Background(), &emaildelivery.Message{To: "budi@example.com"})
+	assert.True(t, emaildelivery.IsPermanent(err))
+
+	for _, status = range []int{http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		err = mailgun.Send(context.Background(), &emaildelivery.Message{To: "budi@example.com"})
+		require.Error(t, err)
+		assert.False(t, emaildelivery.IsPermanent(err), "status %d is retried", status)
+	}
+}
+
+// everyoneConsents has every address agreeing to marketing emails
+type everyoneConsents struct {
+	user.ConsentRepository
+}
+
+func (everyoneConsents) ConsentedEmails(ctx context.Context, emails []string) (map[string]bool, error) {
+	consented := make(map[string]bool)
+	for _, email := range emails {
+		consented[email] = true
+	}
+	return consented, nil
+}
+
+func TestSendEmailCampaignInBatches(t *testing.T) {
+	publisher := &recordingDeliveryPublisher{}
+	send := commands.NewSendEmailCampaignCommandHandler(&memoryEmailTemplateRepo{}, publisher, everyoneConsents{}, 2)
+
+	cmd := commands.SendEmailCampaignCommand{
+		Template: "welcome",
+		Locale:   "id",
+		Data:     map[string]interface{}{"FirstName": "Pelanggan"},
+		Recipients: []emaildelivery.Recipient{
+			{To: "ani@example.com", Data: map[string]interface{}{"FirstName": "Ani"}},
+			{To: "budi@example.com"},
+			{To: "citra@example.com"},
+		},
+	}
+	campaign, err := send.Handle(context.Background(), cmd)
+	require.NoError(t, err)
+	assert.Equal(t, 3, campaign.Recipients)
+	assert.Equal(t, 2, campaign.Batches)
+	require.Len(t, publisher.batches, 2)
+	assert.Len(t, publisher.batches[0].Recipients, 2)
+	assert.Equal(t, campaign.ID, publisher.batches[1].CampaignID)
+	assert.Equal(t, "Ani", emaildelivery.MergeData(cmd.Data, cmd.Recipients[0].Data)["FirstName"], "recipient data wins over shared data")
+
+	cmd.Data = nil
+	publisher.batches = nil
+	_, err = send.Handle(context.Background(), cmd)
+	assert.Equal(t, commands.ErrEmailDataMismatch.Code, apperror.From(err).Code)
+	assert.Contains(t, apperror.From(err).Detail, "recipients[1]")
+	assert.Empty(t, publisher.batches, "nothing is queued when a recipient's data is wrong")
+}
+
+func TestResendEmailDeadLetter(t *testing.T) {
+	deadLetters := &memoryDeadLetterRepo{entries: map[string]*emaildelivery.DeadLetter{}}
+	deadLetter := emaildelivery.NewDeadLetter("budi@example.com", "", "welcome", "id", 0, nil, "", emaildelivery.Permanent(errors.New("550 mailbox full")))
+	require.NoError(t, deadLetters.Create(context.Background(), deadLetter))
+	assert.True(t, deadLetter.Permanent)
+
+	publisher := &recordingDeliveryPublisher{}
+	resend := commands.NewResendEmailDeadLetterCommandHandler(deadLetters, publisher)
+	resent, err := resend.Handle(context.Background(), commands.ResendEmailDeadLetterCommand{ID: deadLetter.ID})
+	require.NoError(t, err)
+	assert.NotNil(t, resent.ResentAt)
+	assert.Equal(t, []string{deadLetter.ID}, publisher.resent)
+
+	pending, err := deadLetters.List(context.Background(), true, 50, 0)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	_, err = resend.Handle(context.Background(), commands.ResendEmailDeadLetterCommand{ID: "missing"})
+	assert.Equal(t, commands.ErrEmailDeadLetterNotFound.Code, apperror.From(err).Code)
+
+	_, err = commands.NewResendEmailDeadLetterCommandHandler(deadLetters, emaildelivery.UnavailablePublisher{}).
+		Handle(context.Background(), commands.ResendEmailDeadLetterCommand{ID: deadLetter.ID})
+	assert.Equal(t, commands.ErrEmailQueueUnavailable.Code, apperror.From(err).Code)
+}
@@ -0,0 +1,239 @@
+package unit
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/emailtemplate"
+	"online-shop/pkg/apperror"
+)
+
+type memoryEmailTemplateRepo struct {
+	templates []*emailtemplate.Template
+	reads     int
+}
+
+func (r *memoryEmailTemplateRepo) Create(ctx context.Context, t *emailtemplate.Template) error {
+	for _, existing := range r.templates {
+		if existing.Name == t.Name && existing.Locale == t.Locale {
+			existing.Active = false
+		}
+	}
+	t.Active = true
+	r.templates = append(r.templates, t)
+	return nil
+}
+
+func (r *memoryEmailTemplateRepo) Active(ctx context.Context, name, locale string) (*emailtemplate.Template, error) {
+	r.reads++
+	for _, t := range r.templates {
+		if t.Name == name && t.Locale == locale && t.Active {
+			return t, nil
+		}
+	}
+	return nil, emailtemplate.ErrNotFound
+}
+
+func (r *memoryEmailTemplateRepo) GetVersion(ctx context.Context, name, locale string, version int) (*emailtemplate.Template, error) {
+	for _, t := range r.templates {
+		if t.Name == name && t.Locale == locale && t.Version == version {
+			return t, nil
+		}
+	}
+	return nil, emailtemplate.ErrNotFound
+}
+
+func (r *memoryEmailTemplateRepo) Versions(ctx context.Context, name, locale string) ([]*emailtemplate.Template, error) {
+	var versions []*emailtemplate.Template
+	for _, t := range r.templates {
+		if t.Name == name && t.Locale == locale {
+			versions = append(versions, t)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version > versions[j].Version })
+	return versions, nil
+}
+
+func (r *memoryEmailTemplateRepo) Activate(ctx context.Context, name, locale string, version int) error {
+	target, err := r.GetVersion(ctx, name, locale, version)
+	if err != nil {
+		return err
+	}
+	for _, t := range r.templates {
+		if t.Name == name && t.Locale == locale {
+			t.Active = t == target
+		}
+	}
+	return nil
+}
+
+func (r *memoryEmailTemplateRepo) List(ctx context.Context, limit, offset int) ([]*emailtemplate.Template, error) {
+	var active []*emailtemplate.Template
+	for _, t := range r.templates {
+		if t.Active {
+			active = append(active, t)
+		}
+	}
+	return active, nil
+}
+
+func (r *memoryEmailTemplateRepo) Delete(ctx context.Context, name, locale string) error {
+	kept := r.templates[:0]
+	for _, t := range r.templates {
+		if t.Name != name || t.Locale != locale {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) == len(r.templates) {
+		return emailtemplate.ErrNotFound
+	}
+	r.templates = kept
+	return nil
+}
+
+type memoryEmailTemplateCache struct {
+	entries map[string]*emailtemplate.Template
+}
+
+func (c *memoryEmailTemplateCache) Get(ctx context.Context, name, locale string) (*emailtemplate.Template, bool, error) {
+	t, ok := c.entries[name+":"+locale]
+	return t, ok, nil
+}
+
+func (c *memoryEmailTemplateCache) Set(ctx context.Context, name, locale string, t *emailtemplate.Template, ttl time.Duration) error {
+	c.entries[name+":"+locale] = t
+	return nil
+}
+
+func (c *memoryEmailTemplateCache) Invalidate(ctx context.Context, name, locale string) error {
+	delete(c.entries, name+":"+locale)
+	return nil
+}
+
+type recordingTemplatePublisher struct {
+	to      string
+	version int
+	data    map[string]interface{}
+}
+
+func (p *recordingTemplatePublisher) SendTest(ctx context.Context, to string, t *emailtemplate.Template, locale string, data map[string]interface{}) error {
+	p.to, p.version, p.data = to, t.Version, data
+	return nil
+}
+
+func welcomeCommand(body string) commands.SaveEmailTemplateCommand {
+	return commands.SaveEmailTemplateCommand{
+		Name:    "welcome",
+		Locale:  "id",
+		Subject: "Halo {{.FirstName}}",
+		Body:    body,
+		Variables: []emailtemplate.Variable{
+			{Name: "FirstName", Type: emailtemplate.TypeString, Required: true, Example: "Budi"},
+		},
+	}
+}
+
+func TestEmailTemplateValidation(t *testing.T) {
+	for _, name := range emailtemplate.Defaults() {
+		d, ok := emailtemplate.Default(name)
+		require.True(t, ok)
+		d.Locale = "en"
+		assert.NoError(t, d.Validate(), name)
+	}
+
+	_, err := emailtemplate.NewTemplate("welcome", "en", 1, "Hi", "<p>{{.FirstName}} {{range .Items}}{{.Sku}}{{$.Coupon}}{{end}}</p>",
+		[]emailtemplate.Variable{{Name: "Items", Type: emailtemplate.TypeList}}, "")
+	assert.ErrorIs(t, err, emailtemplate.ErrInvalid)
+	assert.EqualError(t, err, "template uses undeclared variables: Coupon, FirstName", "fields inside range belong to the element")
+
+	_, err = emailtemplate.NewTemplate("welcome", "en", 1, "Hi", "<p>{{.Name</p>", nil, "")
+	assert.ErrorIs(t, err, emailtemplate.ErrInvalid)
+
+	problem := apperror.From(err)
+	assert.Equal(t, commands.ErrInvalidEmailTemplate.Code, problem.Code)
+	assert.Contains(t, problem.Detail, "body", "the reason is shown to the admin")
+}
+
+func TestSaveEmailTemplateVersions(t *testing.T) {
+	repo := &memoryEmailTemplateRepo{}
+	cache := &memoryEmailTemplateCache{entries: map[string]*emailtemplate.Template{}}
+	save := commands.NewSaveEmailTemplateCommandHandler(repo, cache)
+	activate := commands.NewActivateEmailTemplateCommandHandler(repo, cache)
+	get := queries.NewGetEmailTemplateQueryHandler(repo, cache, time.Minute)
+
+	current, err := get.Handle(context.Background(), queries.GetEmailTemplateQuery{Name: "welcome", Locale: "id"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, current.Version, "nothing stored uses the embedded default")
+	_, err = get.Handle(context.Background(), queries.GetEmailTemplateQuery{Name: "welcome", Locale: "id"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, repo.reads, "the miss is cached")
+
+	first, err := save.Handle(context.Background(), welcomeCommand("<p>Halo {{.FirstName}}</p>"))
+	require.NoError(t, err)
+	second, err := save.Handle(context.Background(), welcomeCommand("<p>Selamat datang {{.FirstName}}</p>"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, first.Version)
+	assert.Equal(t, 2, second.Version)
+	assert.False(t, first.Active)
+
+	current, err = get.Handle(context.Background(), queries.GetEmailTemplateQuery{Name: "welcome", Locale: "id-ID"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, current.Version, "saving clears the cached template")
+
+	_, err = activate.Handle(context.Background(), commands.ActivateEmailTemplateCommand{Name: "welcome", Locale: "id", Version: 1})
+	require.NoError(t, err)
+	current, err = get.Handle(context.Background(), queries.GetEmailTemplateQuery{Name: "welcome", Locale: "id"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, current.Version)
+
+	_, err = activate.Handle(context.Background(), commands.ActivateEmailTemplateCommand{Name: "welcome", Locale: "id", Version: 9})
+	assert.Equal(t, commands.ErrEmailTemplateNotFound.Code, apperror.From(err).Code)
+}
+
+func TestPreviewEmailTemplate(t *testing.T) {
+	preview := queries.NewPreviewEmailTemplateQueryHandler(&memoryEmailTemplateRepo{})
+
+	draft := welcomeCommand(`<p>{{t "email.welcome.intro"}} {{.FirstName}}</p>`)
+	result, err := preview.Handle(context.Background(), queries.PreviewEmailTemplateQuery{
+		Name: draft.Name, Locale: draft.Locale, Subject: draft.Subject, Body: draft.Body, Variables: draft.Variables,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Halo Budi", result.Subject, "examples fill in data that was left out")
+	assert.Contains(t, result.Body, "Terima kasih telah bergabung")
+
+	result, err = preview.Handle(context.Background(), queries.PreviewEmailTemplateQuery{Name: "welcome", Data: map[string]interface{}{"FirstName": "Ani"}})
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.Version)
+	assert.Equal(t, "Welcome to Online Shop", result.Subject)
+	assert.Contains(t, result.Body, "Welcome Ani!")
+
+	_, err = preview.Handle(context.Background(), queries.PreviewEmailTemplateQuery{Name: "welcome", Data: map[string]interface{}{"FirstName": 7}})
+	assert.ErrorIs(t, err, emailtemplate.ErrDataMismatch)
+	assert.Equal(t, "FirstName must be a string", apperror.From(err).Detail)
+}
+
+func TestSendTestEmail(t *testing.T) {
+	repo := &memoryEmailTemplateRepo{}
+	cache := &memoryEmailTemplateCache{entries: map[string]*emailtemplate.Template{}}
+	_, err := commands.NewSaveEmailTemplateCommandHandler(repo, cache).Handle(context.Background(), welcomeCommand("<p>{{.FirstName}}</p>"))
+	require.NoError(t, err)
+
+	publisher := &recordingTemplatePublisher{}
+	send := commands.NewSendTestEmailCommandHandler(repo, publisher)
+	_, err = send.Handle(context.Background(), commands.SendTestEmailCommand{Name: "welcome", Locale: "id", Version: 1, To: "admin@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "admin@example.com", publisher.to)
+	assert.Equal(t, 1, publisher.version, "the worker renders the version under test")
+	assert.Equal(t, "Budi", publisher.data["FirstName"])
+
+	_, err = commands.NewSendTestEmailCommandHandler(repo, emailtemplate.UnavailablePublisher{}).
+		Handle(context.Background(), commands.SendTestEmailCommand{Name: "welcome", Locale: "id", To: "admin@example.com"})
+	assert.Equal(t, commands.ErrEmailQueueUnavailable.Code, apperror.From(err).Code)
+}
@@ -0,0 +1,85 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"online-shop/internal/domain/analytics"
+	"online-shop/internal/infrastructure/eventstore"
+)
+
+type recordingWriter struct {
+	mu      sync.Mutex
+	batches [][]analytics.Event
+	fail    bool
+}
+
+func (w *recordingWriter) Write(ctx context.Context, events []analytics.Event) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fail {
+		return errors.New("store unavailable")
+	}
+	w.batches = append(w.batches, events)
+	return nil
+}
+
+func (w *recordingWriter) count() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	total := 0
+	for _, b := range w.batches {
+		total += len(b)
+	}
+	return total
+}
+
+func testEvent(id string) analytics.Event {
+	return analytics.Event{EventID: id, EventType: "page", EventName: "page_view", Timestamp: time.Now()}
+}
+
+func TestBatchWriterFlushesWhenFull(t *testing.T) {
+	writer := &recordingWriter{}
+	batch := eventstore.NewBatchWriter(writer, 2, time.Hour, zap.NewNop())
+
+	require.NoError(t, batch.Add(context.Background(), testEvent("1")))
+	assert.Equal(t, 0, writer.count())
+
+	require.NoError(t, batch.Add(context.Background(), testEvent("2")))
+	assert.Equal(t, 1, len(writer.batches))
+	assert.Equal(t, 2, writer.count())
+}
+
+func TestBatchWriterFlushesOnInterval(t *testing.T) {
+	writer := &recordingWriter{}
+	batch := eventstore.NewBatchWriter(writer, 100, 10*time.Millisecond, zap.NewNop())
+	batch.Start()
+	defer batch.Close(context.Background())
+
+	require.NoError(t, batch.Add(context.Background(), testEvent("1")))
+
+	assert.Eventually(t, func() bool { return writer.count() == 1 }, time.Second, 5*time.Millisecond)
+}
+
+func TestBatchWriterRetriesFailedBatch(t *testing.T) {
+	writer := &recordingWriter{fail: true}
+	batch := eventstore.NewBatchWriter(writer, 10, time.Hour, zap.NewNop())
+
+	require.NoError(t, batch.Add(context.Background(), testEvent("1")))
+	assert.Error(t, batch.Flush(context.Background()))
+
+	writer.fail = false
+	require.NoError(t, batch.Add(context.Background(), testEvent("2")))
+	require.NoError(t, batch.Close(context.Background()))
+
+	require.Len(t, writer.batches, 1)
+	assert.Equal(t, "1", writer.batches[0][0].EventID)
+	assert.Equal(t, "2", writer.batches[0][1].EventID)
+}
@@ -0,0 +1,116 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"online-shop/internal/domain/analytics"
+	"online-shop/internal/infrastructure/queue"
+	"online-shop/pkg/config"
+)
+
+// recordingBroker is a queue.Broker keeping what was published
+type recordingBroker struct {
+	queues   []string
+	messages []queue.Message
+	err      error
+}
+
+func (b *recordingBroker) Publish(ctx context.Context, queueName string, message queue.Message) error {
+	b.queues = append(b.queues, queueName)
+	b.messages = append(b.messages, message)
+	return b.err
+}
+
+func (b *recordingBroker) Consume(ctx context.Context, queueName string, handler func(queue.Message) error) error {
+	return nil
+}
+
+func (b *recordingBroker) HealthCheck() error { return nil }
+func (b *recordingBroker) Close() error       { return nil }
+
+func TestAnalyticsPublisherUsesBroker(t *testing.T) {
+	broker := &recordingBroker{}
+	publisher := queue.NewAnalyticsPublisher(broker)
+
+	require.NoError(t, publisher.Publish(context.Background(), analytics.Event{EventName: "product_viewed"}))
+	require.Len(t, broker.messages, 1)
+	assert.Equal(t, queue.AnalyticsQueue, broker.queues[0])
+	assert.Equal(t, "analytics", broker.messages[0].Type)
+	assert.Equal(t, 1, broker.messages[0].MaxRetries)
+	assert.NotEmpty(t, broker.messages[0].ID)
+	assert.Equal(t, "product_viewed", broker.messages[0].Payload["event_name"])
+
+	broker.err = errors.New("broker down")
+	assert.Error(t, publisher.Publish(context.Background(), analytics.Event{EventName: "product_viewed"}))
+}
+
+func TestEventBrokerDefaultsToRabbitMQ(t *testing.T) {
+	cfg := &config.Config{}
+	_, closeBroker, err := queue.NewEventBroker(cfg, nil, zap.NewNop())
+	defer closeBroker()
+	assert.EqualError(t, err, "rabbitmq is not connected", "without kafka events need rabbitmq")
+}
+
+func TestKafkaTopics(t *testing.T) {
+	cfg := config.KafkaConfig{TopicPrefix: "online-shop."}
+	assert.Equal(t, "online-shop.analytics_queue", cfg.Topic(queue.AnalyticsQueue))
+	assert.Equal(t, "online-shop.analytics_queue_dlq", cfg.DeadLetterTopic(queue.AnalyticsQueue))
+}
+
+func TestKafkaConfigValidation(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Analytics.Transport = "kafka"
+	cfg.Kafka.StartOffset = "newest"
+	cfg.Kafka.MaxPollRecords = -1
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "kafka.brokers is required")
+	assert.Contains(t, err.Error(), "kafka.consumer_group is required")
+	assert.Contains(t, err.Error(), `kafka.start_offset must be one of earliest, latest, got "newest"`)
+	assert.Contains(t, err.Error(), "kafka.max_poll_records must not be negative")
+
+	cfg = &config.Config{}
+	cfg.Analytics.Transport = "sqs"
+	err = cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `analytics.transport must be one of rabbitmq, kafka, nats, got "sqs"`)
+}
+
+func TestNATSSubjects(t *testing.T) {
+	cfg := config.NATSConfig{SubjectPrefix: "online-shop"}
+	assert.Equal(t, "online-shop.analytics_queue", cfg.Subject(queue.AnalyticsQueue))
+	assert.Equal(t, "online-shop.analytics_queue_dlq", cfg.DeadLetterSubject(queue.AnalyticsQueue))
+	assert.Equal(t, 7*24*time.Hour, cfg.Retention())
+	assert.Equal(t, 30*time.Second, cfg.AckWait())
+
+	cfg.RetentionHours = 24
+	cfg.AckWaitSeconds = 90
+	assert.Equal(t, 24*time.Hour, cfg.Retention())
+	assert.Equal(t, 90*time.Second, cfg.AckWait())
+}
+
+func TestNATSConfigValidation(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Analytics.Transport = "nats"
+	cfg.NATS.Stream = "online.shop"
+	cfg.NATS.ReplayFrom = "yesterday"
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "nats.url is required")
+	assert.Contains(t, err.Error(), "nats.durable is required")
+	assert.Contains(t, err.Error(), "nats.stream must not contain '.', '*', '>' or spaces")
+	assert.Contains(t, err.Error(), `nats.replay_from must be an RFC 3339 time, got "yesterday"`)
+
+	cfg = &config.Config{}
+	cfg.NATS.ReplayFrom = "2026-10-01T00:00:00Z"
+	if err := cfg.Validate(); err != nil {
+		assert.NotContains(t, err.Error(), "nats.")
+	}
+}
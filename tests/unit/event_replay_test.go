@@ -0,0 +1,171 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/analytics"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/searchsync"
+)
+
+type replayEventReader struct {
+	analytics.EventReader
+	events []analytics.Event
+	pages  int
+}
+
+func (r *replayEventReader) ListAfter(ctx context.Context, filter analytics.ReplayFilter, after *analytics.Event, limit int) ([]analytics.Event, error) {
+	r.pages++
+	var page []analytics.Event
+	past := after == nil
+	for _, e := range r.events {
+		if !past {
+			past = e.EventID == after.EventID
+			continue
+		}
+		if e.Timestamp.Before(filter.From) || !e.Timestamp.Before(filter.To) {
+			continue
+		}
+		if filter.ProductID != "" && e.ProductID() != filter.ProductID {
+			continue
+		}
+		if len(page) < limit {
+			page = append(page, e)
+		}
+	}
+	return page, nil
+}
+
+type replayTrending struct {
+	product.TrendingRepository
+	forgot    []string
+	recorded  map[string]float64
+	refreshed bool
+}
+
+func (t *replayTrending) Forget(ctx context.Context, from, to time.Time, productID string) error {
+	t.forgot = append(t.forgot, from.Format("15:04")+"-"+to.Format("15:04")+" "+productID)
+	return nil
+}
+
+func (t *replayTrending) Record(ctx context.Context, productID string, score float64, at time.Time) error {
+	if t.recorded == nil {
+		t.recorded = make(map[string]float64)
+	}
+	t.recorded[productID] += score
+	return nil
+}
+
+func (t *replayTrending) Refresh(ctx context.Context, at time.Time) error {
+	t.refreshed = true
+	return nil
+}
+
+func replayEvent(id, name, productID string, quantity int, at time.Time) analytics.Event {
+	e := analytics.NewProductEvent(name, productID, "u-1", "", quantity)
+	e.EventID = id
+	e.Timestamp = at
+	return e
+}
+
+func newReplayFixture(now time.Time) (*replayEventReader, *syncProductRepo) {
+	events := &replayEventReader{events: []analytics.Event{
+		replayEvent("e-1", analytics.EventProductViewed, "p-1", 1, now.Add(-3*time.Hour)),
+		replayEvent("e-2", analytics.EventProductPurchased, "p-1", 2, now.Add(-2*time.Hour)),
+		replayEvent("e-3", analytics.EventProductViewed, "p-2", 1, now.Add(-time.Hour)),
+		replayEvent("e-4", analytics.EventProductViewed, "p-gone", 1, now.Add(-time.Hour)),
+	}}
+	products := &syncProductRepo{products: []*product.Product{
+		{ID: "p-1", Status: product.StatusActive},
+		{ID: "p-2", Status: product.StatusDeleted},
+	}}
+	return events, products
+}
+
+func TestReplayEventsRebuildsProjections(t *testing.T) {
+	now := time.Now()
+	events, products := newReplayFixture(now)
+	index := &syncIndex{}
+	cache := &syncCache{}
+	trending := &replayTrending{}
+	handler := commands.NewReplayEventsCommandHandler(events, products, index, trending, cache, cache)
+
+	result, err := handler.Handle(context.Background(), commands.ReplayEventsCommand{
+		From:      now.Add(-4 * time.Hour),
+		To:        now,
+		BatchSize: 2,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, analytics.Projections, result.Projections)
+	assert.Equal(t, 4, result.Events)
+	assert.Equal(t, map[string]int{analytics.EventProductViewed: 3, analytics.EventProductPurchased: 1}, result.EventsByName)
+	assert.Equal(t, 3, result.Products)
+	assert.Equal(t, 3, events.pages, "events are read in batches")
+
+	assert.Equal(t, []string{"p-1"}, index.indexed)
+	assert.Equal(t, []string{"p-2", "p-gone"}, index.deleted, "deleted and missing products leave the index")
+	assert.Equal(t, []string{"p-1", "p-2", "p-gone"}, cache.invalidated)
+	assert.Equal(t, searchsync.ListingPatterns, cache.patterns)
+
+	require.Len(t, trending.forgot, 1, "the replayed hours are dropped before they are scored again")
+	assert.Equal(t, map[string]float64{"p-1": 11, "p-2": 1, "p-gone": 1}, trending.recorded)
+	assert.True(t, trending.refreshed)
+	assert.Equal(t, 4, result.Scored)
+	assert.Equal(t, now.Add(-4*time.Hour).Truncate(time.Hour), result.From, "trending replays whole hours")
+}
+
+func TestReplayEventsDryRunWritesNothing(t *testing.T) {
+	now := time.Now()
+	events, products := newReplayFixture(now)
+	index := &syncIndex{}
+	cache := &syncCache{}
+	trending := &replayTrending{}
+	handler := commands.NewReplayEventsCommandHandler(events, products, index, trending, cache, cache)
+
+	result, err := handler.Handle(context.Background(), commands.ReplayEventsCommand{
+		From:      now.Add(-4 * time.Hour),
+		To:        now,
+		ProductID: "p-1",
+		DryRun:    true,
+	})
+	require.NoError(t, err)
+	assert.True(t, result.DryRun)
+	assert.Equal(t, 2, result.Events)
+	assert.Equal(t, 1, result.Indexed)
+	assert.Equal(t, 2, result.Scored)
+	assert.Equal(t, 1, result.Invalidated)
+
+	assert.Empty(t, index.indexed)
+	assert.Empty(t, cache.invalidated)
+	assert.Empty(t, cache.patterns)
+	assert.Empty(t, trending.forgot)
+	assert.Empty(t, trending.recorded)
+	assert.False(t, trending.refreshed)
+}
+
+func TestReplayEventsProjectionChecks(t *testing.T) {
+	now := time.Now()
+	events, products := newReplayFixture(now)
+	handler := commands.NewReplayEventsCommandHandler(events, products, &syncIndex{}, &replayTrending{}, &syncCache{}, &syncCache{})
+	ctx := context.Background()
+
+	_, err := handler.Handle(ctx, commands.ReplayEventsCommand{From: now, To: now.Add(-time.Hour)})
+	assert.True(t, errors.Is(err, analytics.ErrInvalidReplay))
+
+	_, err = handler.Handle(ctx, commands.ReplayEventsCommand{From: now.Add(-time.Hour), To: now, Projections: []string{"recommendations"}})
+	assert.True(t, errors.Is(err, analytics.ErrUnknownProjection))
+
+	_, err = handler.Handle(ctx, commands.ReplayEventsCommand{From: now.Add(-time.Hour), To: now, OrderID: "o-1", Projections: []string{analytics.ProjectionTrending}})
+	assert.True(t, errors.Is(err, analytics.ErrTrendingNotRebuildable), "one order's scores cannot be taken out of the ranking")
+
+	result, err := handler.Handle(ctx, commands.ReplayEventsCommand{From: now.Add(-time.Hour), To: now, UserID: "u-1", DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{analytics.ProjectionSearch, analytics.ProjectionCache}, result.Projections, "trending is left out for one user")
+}
@@ -0,0 +1,253 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/analytics"
+	"online-shop/internal/domain/experiment"
+)
+
+type experimentRepoStub struct {
+	experiments map[string]*experiment.Experiment
+}
+
+func (r *experimentRepoStub) Create(ctx context.Context, e *experiment.Experiment) error {
+	r.experiments[e.ID] = e
+	return nil
+}
+
+func (r *experimentRepoStub) GetByID(ctx context.Context, id string) (*experiment.Experiment, error) {
+	if e, ok := r.experiments[id]; ok {
+		return e, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func (r *experimentRepoStub) GetByKey(ctx context.Context, key string) (*experiment.Experiment, error) {
+	for _, e := range r.experiments {
+		if e.Key == key {
+			return e, nil
+		}
+	}
+	return nil, errors.New("not found")
+}
+
+func (r *experimentRepoStub) Update(ctx context.Context, e *experiment.Experiment) error {
+	r.experiments[e.ID] = e
+	return nil
+}
+
+func (r *experimentRepoStub) List(ctx context.Context, status string, limit, offset int) ([]*experiment.Experiment, error) {
+	var list []*experiment.Experiment
+	for _, e := range r.experiments {
+		if status == "" || e.Status == status {
+			list = append(list, e)
+		}
+	}
+	return list, nil
+}
+
+type eventPublisherStub struct {
+	events []analytics.Event
+}
+
+func (p *eventPublisherStub) Publish(ctx context.Context, event analytics.Event) error {
+	p.events = append(p.events, event)
+	return nil
+}
+
+func runningExperiment(t *testing.T, traffic float64, variants ...experiment.Variant) *experiment.Experiment {
+	e, err := experiment.NewExperiment("checkout-button", "Checkout button", "", variants, traffic, "")
+	require.NoError(t, err)
+	require.NoError(t, e.Start(time.Now()))
+	return e
+}
+
+func TestExperimentAssignmentIsDeterministic(t *testing.T) {
+	e := runningExperiment(t, 100, experiment.Variant{Key: "control", Weight: 50}, experiment.Variant{Key: "green", Weight: 50})
+
+	first, ok := e.Assign("user:u1")
+	require.True(t, ok)
+	for i := 0; i < 10; i++ {
+		again, _ := e.Assign("user:u1")
+		assert.Equal(t, first, again)
+	}
+
+	// A copy loaded elsewhere assigns the same way
+	copied := *e
+	again, _ := copied.Assign("user:u1")
+	assert.Equal(t, first, again)
+}
+
+func TestExperimentAssignmentFollowsWeights(t *testing.T) {
+	e := runningExperiment(t, 100, experiment.Variant{Key: "control", Weight: 80}, experiment.Variant{Key: "new", Weight: 20})
+
+	counts := map[string]int{}
+	for i := 0; i < 10000; i++ {
+		variant, ok := e.Assign(fmt.Sprintf("session:%d", i))
+		require.True(t, ok)
+		counts[variant]++
+	}
+	assert.InDelta(t, 8000, counts["control"], 300)
+	assert.InDelta(t, 2000, counts["new"], 300)
+}
+
+func TestExperimentTrafficAndStatusLimitAssignment(t *testing.T) {
+	e := runningExperiment(t, 10, experiment.Variant{Key: "a", Weight: 1}, experiment.Variant{Key: "b", Weight: 1})
+
+	enrolled := 0
+	for i := 0; i < 10000; i++ {
+		if _, ok := e.Assign(fmt.Sprintf("session:%d", i)); ok {
+			enrolled++
+		}
+	}
+	assert.InDelta(t, 1000, enrolled, 200)
+
+	before := map[string]string{}
+	for i := 0; i < 1000; i++ {
+		unit := fmt.Sprintf("session:%d", i)
+		if v, ok := e.Assign(unit); ok {
+			before[unit] = v
+		}
+	}
+	e.TrafficPercent = 50
+	for unit, v := range before {
+		after, ok := e.Assign(unit)
+		assert.True(t, ok, "raising traffic keeps enrolled visitors")
+		assert.Equal(t, v, after, "raising traffic does not move visitors between variants")
+	}
+
+	require.NoError(t, e.Pause(time.Now()))
+	_, ok := e.Assign("session:1")
+	assert.False(t, ok)
+
+	_, ok = runningExperiment(t, 100, experiment.Variant{Key: "a", Weight: 1}, experiment.Variant{Key: "b", Weight: 1}).Assign("")
+	assert.False(t, ok)
+}
+
+func TestExperimentTransitions(t *testing.T) {
+	e, err := experiment.NewExperiment("hero", "Hero banner", "", []experiment.Variant{{Key: "a", Weight: 1}, {Key: "b", Weight: 1}}, 100, "")
+	require.NoError(t, err)
+	assert.Equal(t, experiment.StatusDraft, e.Status)
+	assert.Equal(t, analytics.EventExperimentConversion, e.ConversionEvent)
+
+	assert.ErrorIs(t, e.Pause(time.Now()), experiment.ErrInvalidTransition)
+
+	started := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, e.Start(started))
+	require.NoError(t, e.Pause(started.Add(time.Hour)))
+	require.NoError(t, e.Start(started.Add(2*time.Hour)))
+	assert.Equal(t, started, *e.StartedAt, "resuming keeps the original start")
+
+	require.NoError(t, e.Complete(started.Add(3*time.Hour)))
+	assert.ErrorIs(t, e.Start(time.Now()), experiment.ErrInvalidTransition)
+}
+
+func TestNewExperimentValidates(t *testing.T) {
+	two := []experiment.Variant{{Key: "a", Weight: 1}, {Key: "b", Weight: 1}}
+
+	_, err := experiment.NewExperiment("Bad Key", "Name", "", two, 100, "")
+	assert.Error(t, err)
+	_, err = experiment.NewExperiment("key", "", "", two, 100, "")
+	assert.Error(t, err)
+	_, err = experiment.NewExperiment("key", "Name", "", two[:1], 100, "")
+	assert.Error(t, err)
+	_, err = experiment.NewExperiment("key", "Name", "", []experiment.Variant{{Key: "a", Weight: 1}, {Key: "a", Weight: 1}}, 100, "")
+	assert.Error(t, err)
+	_, err = experiment.NewExperiment("key", "Name", "", []experiment.Variant{{Key: "a", Weight: 1}, {Key: "b"}}, 100, "")
+	assert.Error(t, err)
+	_, err = experiment.NewExperiment("key", "Name", "", two, 101, "")
+	assert.Error(t, err)
+}
+
+func TestBuildExperimentReport(t *testing.T) {
+	e := runningExperiment(t, 100,
+		experiment.Variant{Key: "control", Weight: 1},
+		experiment.Variant{Key: "green", Weight: 1},
+		experiment.Variant{Key: "red", Weight: 1},
+	)
+
+	report := experiment.BuildReport(e, analytics.ReportRange{}, []experiment.VariantCounts{
+		{Variant: "control", Exposed: 1000, Converted: 100},
+		{Variant: "green", Exposed: 1000, Converted: 150},
+	})
+	require.Len(t, report.Variants, 3)
+
+	control, green, red := report.Variants[0], report.Variants[1], report.Variants[2]
+	assert.InDelta(t, 0.1, control.ConversionRate, 1e-9)
+	assert.Zero(t, control.Lift)
+
+	assert.InDelta(t, 0.5, green.Lift, 1e-9)
+	assert.InDelta(t, 3.38, green.ZScore, 0.01)
+	assert.True(t, green.Significant)
+
+	assert.Equal(t, "red", red.Variant)
+	assert.Zero(t, red.Exposed)
+	assert.False(t, red.Significant)
+}
+
+func TestTrackExperimentPublishesAssignedVariant(t *testing.T) {
+	e := runningExperiment(t, 100, experiment.Variant{Key: "control", Weight: 1}, experiment.Variant{Key: "green", Weight: 1})
+	repo := &experimentRepoStub{experiments: map[string]*experiment.Experiment{e.ID: e}}
+	publisher := &eventPublisherStub{}
+	handler := commands.NewTrackExperimentCommandHandler(repo, publisher)
+
+	assignment, err := handler.Handle(context.Background(), commands.TrackExperimentCommand{
+		ExperimentKey: e.Key,
+		SessionID:     "s1",
+		Event:         analytics.EventExperimentConversion,
+		Value:         120000,
+	})
+	require.NoError(t, err)
+
+	expected, _ := e.Assign("session:s1")
+	assert.Equal(t, expected, assignment.Variant)
+	require.Len(t, publisher.events, 1)
+	event := publisher.events[0]
+	assert.Equal(t, analytics.EventTypeExperiment, event.EventType)
+	assert.Equal(t, analytics.EventExperimentConversion, event.EventName)
+	assert.Equal(t, e.Key, event.Properties["experiment_key"])
+	assert.Equal(t, expected, event.Properties["variant"])
+	assert.Equal(t, "s1", event.SessionID)
+
+	_, err = handler.Handle(context.Background(), commands.TrackExperimentCommand{ExperimentKey: e.Key, Event: analytics.EventExperimentExposure})
+	assert.ErrorIs(t, err, commands.ErrExperimentVisitorUnknown)
+
+	_, err = handler.Handle(context.Background(), commands.TrackExperimentCommand{ExperimentKey: "missing", SessionID: "s1", Event: analytics.EventExperimentExposure})
+	assert.ErrorIs(t, err, commands.ErrExperimentNotFound)
+
+	_, err = handler.Handle(context.Background(), commands.TrackExperimentCommand{ExperimentKey: e.Key, SessionID: "s1", Event: "purchase"})
+	assert.ErrorIs(t, err, commands.ErrInvalidExperimentData)
+
+	require.NoError(t, e.Pause(time.Now()))
+	_, err = handler.Handle(context.Background(), commands.TrackExperimentCommand{ExperimentKey: e.Key, SessionID: "s1", Event: analytics.EventExperimentExposure})
+	assert.ErrorIs(t, err, commands.ErrNotInExperiment)
+	assert.Len(t, publisher.events, 1)
+}
+
+func TestUpdateExperimentLocksVariantsOnceStarted(t *testing.T) {
+	e := runningExperiment(t, 100, experiment.Variant{Key: "a", Weight: 1}, experiment.Variant{Key: "b", Weight: 1})
+	repo := &experimentRepoStub{experiments: map[string]*experiment.Experiment{e.ID: e}}
+	handler := commands.NewUpdateExperimentCommandHandler(repo)
+
+	_, err := handler.Handle(context.Background(), commands.UpdateExperimentCommand{ExperimentID: e.ID, Variants: []experiment.Variant{{Key: "a", Weight: 9}, {Key: "b", Weight: 1}}})
+	assert.ErrorIs(t, err, commands.ErrExperimentVariantsLocked)
+
+	completed := experiment.StatusCompleted
+	updated, err := handler.Handle(context.Background(), commands.UpdateExperimentCommand{ExperimentID: e.ID, Status: &completed})
+	require.NoError(t, err)
+	assert.Equal(t, experiment.StatusCompleted, updated.Status)
+	assert.NotNil(t, updated.EndedAt)
+
+	running := experiment.StatusRunning
+	_, err = handler.Handle(context.Background(), commands.UpdateExperimentCommand{ExperimentID: e.ID, Status: &running})
+	assert.ErrorIs(t, err, commands.ErrInvalidExperimentData)
+}
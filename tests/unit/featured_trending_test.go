@@ -0,0 +1,50 @@
+package unit
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/domain/analytics"
+	"online-shop/internal/domain/product"
+)
+
+func TestProductIsFeaturedAt(t *testing.T) {
+	now := time.Now()
+	yesterday := now.Add(-24 * time.Hour)
+	tomorrow := now.Add(24 * time.Hour)
+
+	p := &product.Product{Status: product.StatusActive}
+	assert.False(t, p.IsFeaturedAt(now), "not enabled")
+
+	p.Featured.Enabled = true
+	assert.True(t, p.IsFeaturedAt(now), "open window")
+
+	p.Featured.From = &tomorrow
+	assert.False(t, p.IsFeaturedAt(now), "window not started")
+
+	p.Featured.From = &yesterday
+	p.Featured.Until = &tomorrow
+	assert.True(t, p.IsFeaturedAt(now), "inside window")
+	assert.False(t, p.IsFeaturedAt(tomorrow), "window end is exclusive")
+
+	p.Status = product.StatusArchived
+	assert.False(t, p.IsFeaturedAt(now), "inactive product")
+}
+
+func TestProductEventSurvivesJSON(t *testing.T) {
+	event := analytics.NewProductEvent(analytics.EventProductPurchased, "prod-1", "user-1", "", 3)
+
+	data, err := json.Marshal(event)
+	require.NoError(t, err)
+
+	var decoded analytics.Event
+	require.NoError(t, json.Unmarshal(data, &decoded))
+
+	assert.Equal(t, analytics.EventTypeProduct, decoded.EventType)
+	assert.Equal(t, "prod-1", decoded.ProductID())
+	assert.Equal(t, 3, decoded.Quantity())
+}
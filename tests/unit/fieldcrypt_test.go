@@ -0,0 +1,155 @@
+package unit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/schema"
+
+	"online-shop/internal/domain/user"
+	"online-shop/pkg/config"
+	"online-shop/pkg/fieldcrypt"
+)
+
+func encryptionKey(t *testing.T, id string) config.EncryptionKeyConfig {
+	raw := make([]byte, 32)
+	_, err := rand.Read(raw)
+	require.NoError(t, err)
+	return config.EncryptionKeyConfig{ID: id, Key: base64.StdEncoding.EncodeToString(raw)}
+}
+
+func newKeyring(t *testing.T, active string, keys ...config.EncryptionKeyConfig) *fieldcrypt.Keyring {
+	k, err := fieldcrypt.NewKeyring(&config.EncryptionConfig{ActiveKeyID: active, Keys: keys, IndexKey: "index-secret"})
+	require.NoError(t, err)
+	return k
+}
+
+func TestKeyringEncryptsAndDecrypts(t *testing.T) {
+	k := newKeyring(t, "k1", encryptionKey(t, "k1"))
+
+	first, err := k.Encrypt("+62 812 3456 7890")
+	require.NoError(t, err)
+	second, err := k.Encrypt("+62 812 3456 7890")
+	require.NoError(t, err)
+
+	assert.True(t, fieldcrypt.IsEncrypted(first))
+	assert.NotContains(t, first, "3456")
+	assert.NotEqual(t, first, second, "every value gets its own data key and nonce")
+
+	plaintext, err := k.Decrypt(first)
+	require.NoError(t, err)
+	assert.Equal(t, "+62 812 3456 7890", plaintext)
+
+	// Values written before encryption was enabled read as they are
+	plaintext, err = k.Decrypt("+62 811 0000")
+	require.NoError(t, err)
+	assert.Equal(t, "+62 811 0000", plaintext)
+
+	empty, err := k.Encrypt("")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+}
+
+func TestKeyringRejectsTamperedValue(t *testing.T) {
+	k := newKeyring(t, "k1", encryptionKey(t, "k1"))
+
+	value, err := k.Encrypt("Jl. Sudirman 1")
+	require.NoError(t, err)
+
+	parts := strings.Split(value, ":")
+	body := []byte(parts[len(parts)-1])
+	body[len(body)/2] ^= 1
+	parts[len(parts)-1] = string(body)
+
+	_, err = k.Decrypt(strings.Join(parts, ":"))
+	assert.Error(t, err)
+}
+
+func TestKeyringRotateRewrapsOnActiveKey(t *testing.T) {
+	oldKey, newKey := encryptionKey(t, "2024-01"), encryptionKey(t, "2024-06")
+	before := newKeyring(t, "2024-01", oldKey)
+	value, err := before.Encrypt("Jl. Sudirman 1")
+	require.NoError(t, err)
+
+	after := newKeyring(t, "2024-06", oldKey, newKey)
+	rotated, changed, err := after.Rotate(value)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.True(t, strings.HasPrefix(rotated, "enc:v1:2024-06:"))
+
+	// Once rotated the old key is no longer needed
+	onlyNew := newKeyring(t, "2024-06", newKey)
+	plaintext, err := onlyNew.Decrypt(rotated)
+	require.NoError(t, err)
+	assert.Equal(t, "Jl. Sudirman 1", plaintext)
+
+	_, err = onlyNew.Decrypt(value)
+	assert.ErrorIs(t, err, fieldcrypt.ErrUnknownKey)
+
+	_, changed, err = after.Rotate(rotated)
+	require.NoError(t, err)
+	assert.False(t, changed, "values on the active key are left alone")
+
+	encrypted, changed, err := after.Rotate("plain value")
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.True(t, fieldcrypt.IsEncrypted(encrypted))
+}
+
+func TestKeyringIndexSurvivesRotation(t *testing.T) {
+	oldKey, newKey := encryptionKey(t, "k1"), encryptionKey(t, "k2")
+
+	assert.Equal(t,
+		newKeyring(t, "k1", oldKey).Index("midtrans-123"),
+		newKeyring(t, "k2", oldKey, newKey).Index("midtrans-123"),
+	)
+	assert.NotEqual(t,
+		newKeyring(t, "k1", oldKey).Index("midtrans-123"),
+		newKeyring(t, "k1", oldKey).Index("midtrans-124"),
+	)
+}
+
+func TestNewKeyringValidatesConfig(t *testing.T) {
+	_, err := fieldcrypt.NewKeyring(&config.EncryptionConfig{})
+	assert.ErrorIs(t, err, fieldcrypt.ErrNotConfigured)
+
+	_, err = fieldcrypt.NewKeyring(&config.EncryptionConfig{ActiveKeyID: "missing", Keys: []config.EncryptionKeyConfig{encryptionKey(t, "k1")}, IndexKey: "x"})
+	assert.Error(t, err)
+
+	_, err = fieldcrypt.NewKeyring(&config.EncryptionConfig{Keys: []config.EncryptionKeyConfig{{ID: "k1", Key: "c2hvcnQ="}}, IndexKey: "x"})
+	assert.Error(t, err)
+
+	_, err = fieldcrypt.NewKeyring(&config.EncryptionConfig{Keys: []config.EncryptionKeyConfig{encryptionKey(t, "k1")}})
+	assert.Error(t, err, "an index key is required")
+}
+
+func TestEncryptedSerializerRoundTrip(t *testing.T) {
+	fieldcrypt.Use(newKeyring(t, "k1", encryptionKey(t, "k1")))
+	defer fieldcrypt.Use(nil)
+
+	s, err := schema.Parse(&user.User{}, &sync.Map{}, schema.NamingStrategy{})
+	require.NoError(t, err)
+	field := s.LookUpField("phone")
+	require.NotNil(t, field)
+	require.IsType(t, fieldcrypt.Serializer{}, field.Serializer)
+
+	ctx := context.Background()
+	u := &user.User{Phone: "+62 812 3456 7890"}
+	stored, err := fieldcrypt.Serializer{}.Value(ctx, field, reflect.ValueOf(u), u.Phone)
+	require.NoError(t, err)
+	assert.True(t, fieldcrypt.IsEncrypted(stored.(string)))
+
+	var loaded user.User
+	require.NoError(t, fieldcrypt.Serializer{}.Scan(ctx, field, reflect.ValueOf(&loaded).Elem(), []byte(stored.(string))))
+	assert.Equal(t, "+62 812 3456 7890", loaded.Phone)
+
+	fieldcrypt.Use(nil)
+	assert.ErrorIs(t, fieldcrypt.Serializer{}.Scan(ctx, field, reflect.ValueOf(&loaded).Elem(), stored), fieldcrypt.ErrNotConfigured)
+}
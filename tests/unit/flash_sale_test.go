@@ -0,0 +1,233 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/commission"
+	"online-shop/internal/domain/flashsale"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/warehouse"
+	"online-shop/pkg/apperror"
+)
+
+type flashSaleRepoStub struct {
+	flashsale.Repository
+	sales []*flashsale.Sale
+}
+
+func (r *flashSaleRepoStub) ListLive(ctx context.Context, productIDs []string, at time.Time) ([]*flashsale.Sale, error) {
+	var live []*flashsale.Sale
+	for _, s := range r.sales {
+		if s.Live(at) {
+			live = append(live, s)
+		}
+	}
+	return live, nil
+}
+
+// memoryFlashCounter mirrors the Redis counter's rules in memory.
+type memoryFlashCounter struct {
+	mu   sync.Mutex
+	sold map[string]int
+	user map[string]int
+}
+
+func newMemoryFlashCounter() *memoryFlashCounter {
+	return &memoryFlashCounter{sold: map[string]int{}, user: map[string]int{}}
+}
+
+func (c *memoryFlashCounter) Reserve(ctx context.Context, item *flashsale.Item, userID string, quantity int, until time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := item.SaleID + ":" + item.ProductID
+	if c.sold[key]+quantity > item.Quantity {
+		return flashsale.ErrSoldOut
+	}
+	if item.PerUserLimit > 0 && c.user[key+":"+userID]+quantity > item.PerUserLimit {
+		return flashsale.ErrLimitReached
+	}
+	c.sold[key] += quantity
+	c.user[key+":"+userID] += quantity
+	return nil
+}
+
+func (c *memoryFlashCounter) Release(ctx context.Context, item *flashsale.Item, userID string, quantity int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := item.SaleID + ":" + item.ProductID
+	c.sold[key] -= quantity
+	c.user[key+":"+userID] -= quantity
+	return nil
+}
+
+func (c *memoryFlashCounter) Sold(ctx context.Context, items []*flashsale.Item) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, item := range items {
+		item.Sold = c.sold[item.SaleID+":"+item.ProductID]
+	}
+	return nil
+}
+
+type flashProductRepo struct {
+	product.Repository
+	products map[string]*product.Product
+}
+
+func (r *flashProductRepo) GetByID(ctx context.Context, id string) (*product.Product, error) {
+	if p, ok := r.products[id]; ok {
+		return p, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func (r *flashProductRepo) GetByIDs(ctx context.Context, ids []string) ([]*product.Product, error) {
+	var found []*product.Product
+	for _, id := range ids {
+		if p, ok := r.products[id]; ok {
+			found = append(found, p)
+		}
+	}
+	return found, nil
+}
+
+func (r *flashProductRepo) UpdateStock(ctx context.Context, productID string, quantity int) error {
+	r.products[productID].Stock += quantity
+	return nil
+}
+
+type flashOrderRepo struct {
+	order.Repository
+	orders map[string]*order.Order
+	// saved is the status each order was last saved in with UpdateFrom
+	saved map[string]order.Status
+}
+
+func (r *flashOrderRepo) Create(ctx context.Context, o *order.Order) error {
+	r.orders[o.ID] = o
+	return nil
+}
+
+func (r *flashOrderRepo) GetByID(ctx context.Context, id string) (*order.Order, error) {
+	if o, ok := r.orders[id]; ok {
+		return o, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func (r *flashOrderRepo) Update(ctx context.Context, o *order.Order) error {
+	r.orders[o.ID] = o
+	return nil
+}
+
+func (r *flashOrderRepo) UpdateFrom(ctx context.Context, o *order.Order, from order.Status) (bool, error) {
+	if saved, ok := r.saved[o.ID]; ok && saved != from {
+		return false, nil
+	}
+	if r.saved == nil {
+		r.saved = map[string]order.Status{}
+	}
+	r.saved[o.ID] = o.Status
+	r.orders[o.ID] = o
+	return true, nil
+}
+
+type noCommissionRepo struct{ commission.Repository }
+
+func (noCommissionRepo) ListActive(ctx context.Context) ([]*commission.Rule, error) { return nil, nil }
+
+type noWarehouseRepo struct{ warehouse.Repository }
+
+func (noWarehouseRepo) ListActive(context.Context) ([]*warehouse.Warehouse, error) { return nil, nil }
+
+func TestFlashSaleValidation(t *testing.T) {
+	now := time.Now()
+	item := flashsale.Item{ProductID: "p1", SalePrice: 60, Quantity: 5}
+
+	_, err := flashsale.NewSale("Midnight sale", now, now.Add(-time.Hour), []flashsale.Item{item})
+	assert.Equal(t, commands.ErrInvalidFlashSale.Code, apperror.From(err).Code)
+
+	_, err = flashsale.NewSale("Midnight sale", now, now.Add(time.Hour), []flashsale.Item{item, item})
+	assert.ErrorIs(t, err, flashsale.ErrInvalidSale, "a product is listed once")
+
+	sale, err := flashsale.NewSale("Midnight sale", now.Add(time.Hour), now.Add(2*time.Hour), []flashsale.Item{item})
+	require.NoError(t, err)
+	assert.ErrorIs(t, sale.CheckPrices([]*product.Product{{ID: "p1", Price: 50}}), flashsale.ErrInvalidSale, "the sale price must be a discount")
+	assert.NoError(t, sale.CheckPrices([]*product.Product{{ID: "p1", Price: 100}}))
+
+	countdown := sale.CountdownAt(now)
+	assert.Equal(t, flashsale.StatusUpcoming, countdown.Status)
+	assert.Equal(t, int64(3600), countdown.SecondsToStart)
+	assert.Equal(t, flashsale.StatusLive, sale.CountdownAt(now.Add(90*time.Minute)).Status)
+	assert.Equal(t, flashsale.StatusEnded, sale.CountdownAt(now.Add(2*time.Hour)).Status)
+}
+
+func TestFlashSaleOrdersRespectAllocationAndLimit(t *testing.T) {
+	now := time.Now()
+	sale, err := flashsale.NewSale("Midnight sale", now.Add(-time.Minute), now.Add(time.Hour), []flashsale.Item{
+		{ProductID: "p1", SalePrice: 60, Quantity: 3, PerUserLimit: 2},
+		{ProductID: "p2", SalePrice: 30, Quantity: 10},
+	})
+	require.NoError(t, err)
+
+	products := &flashProductRepo{products: map[string]*product.Product{
+		"p1": {ID: "p1", Price: 100, Stock: 50, Status: product.StatusActive},
+		"p2": {ID: "p2", Price: 50, Stock: 50, Status: product.StatusActive},
+	}}
+	orders := &flashOrderRepo{orders: map[string]*order.Order{}}
+	counter := newMemoryFlashCounter()
+	sales := &flashSaleRepoStub{sales: []*flashsale.Sale{sale}}
+	create := commands.NewCreateOrderCommandHandler(orders, products, noCommissionRepo{}, noWarehouseRepo{}, nil, sales, counter, commands.CreateOrderOptions{})
+	address := order.Address{Street: "Jl. Merdeka 1", City: "Jakarta", PostalCode: "10110", Country: "ID"}
+	buy := func(userID string, items ...commands.CreateOrderItemCmd) (*order.Order, error) {
+		return create.Handle(context.Background(), commands.CreateOrderCommand{UserID: userID, Items: items, ShippingAddress: address})
+	}
+
+	placed, err := buy("user-a", commands.CreateOrderItemCmd{ProductID: "p1", Quantity: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 120.0, placed.TotalAmount, "charged the sale price")
+	assert.Equal(t, sale.ID, placed.Items[0].FlashSaleID)
+
+	_, err = buy("user-a", commands.CreateOrderItemCmd{ProductID: "p1", Quantity: 1})
+	assert.Equal(t, commands.ErrFlashSaleLimitReached.Code, apperror.From(err).Code)
+
+	_, err = buy("user-b", commands.CreateOrderItemCmd{ProductID: "p2", Quantity: 1}, commands.CreateOrderItemCmd{ProductID: "p1", Quantity: 2})
+	assert.Equal(t, commands.ErrFlashSaleSoldOut.Code, apperror.From(err).Code)
+	assert.Equal(t, 0, counter.sold[sale.ID+":p2"], "a rejected order gives back what it reserved")
+
+	offered := []*product.Product{products.products["p1"], products.products["p2"]}
+	require.NoError(t, queries.NewGetFlashSaleOffersQueryHandler(sales, counter).Handle(context.Background(), queries.GetFlashSaleOffersQuery{Products: offered}))
+	require.NotNil(t, offered[0].FlashSale)
+	assert.Equal(t, 1, offered[0].FlashSale.Remaining)
+	assert.Equal(t, 40, offered[0].FlashSale.DiscountPercent)
+	assert.Equal(t, 100.0, offered[0].FlashSale.OriginalPrice)
+	assert.Equal(t, 10, offered[1].FlashSale.Remaining)
+
+	cancel := commands.NewCancelOrderCommandHandler(orders, products, nil, counter, &paymentRepoStub{}, nil, order.CancellationPolicy{}, nil)
+	require.NoError(t, cancel.Handle(context.Background(), commands.CancelOrderCommand{OrderID: placed.ID, UserID: "user-a"}))
+	_, err = buy("user-b", commands.CreateOrderItemCmd{ProductID: "p1", Quantity: 2})
+	assert.NoError(t, err, "cancelled units go back on sale")
+}
+
+func TestProductsOutsideASaleKeepTheirPrice(t *testing.T) {
+	now := time.Now()
+	upcoming, err := flashsale.NewSale("Tomorrow", now.Add(24*time.Hour), now.Add(25*time.Hour), []flashsale.Item{
+		{ProductID: "p1", SalePrice: 60, Quantity: 3},
+	})
+	require.NoError(t, err)
+
+	p := &product.Product{ID: "p1", Price: 100, Stock: 5, Status: product.StatusActive}
+	offers := queries.NewGetFlashSaleOffersQueryHandler(&flashSaleRepoStub{sales: []*flashsale.Sale{upcoming}}, newMemoryFlashCounter())
+	require.NoError(t, offers.Handle(context.Background(), queries.GetFlashSaleOffersQuery{Products: []*product.Product{p}}))
+	assert.Nil(t, p.FlashSale)
+}
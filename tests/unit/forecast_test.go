@@ -0,0 +1,142 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/analytics"
+	"online-shop/internal/domain/forecast"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/warehouse"
+)
+
+func TestForecastProject(t *testing.T) {
+	now := time.Now()
+	policy := forecast.Policy{LookbackDays: 10, LeadTimeDays: 5, SafetyDays: 5, CoverDays: 20}
+
+	s := forecast.Project("p1", "w1", 50, 20, policy, now)
+	assert.Equal(t, 2.0, s.DailyVelocity)
+	require.NotNil(t, s.DaysOfCover)
+	assert.Equal(t, 25.0, *s.DaysOfCover)
+	assert.Equal(t, now.Add(25*24*time.Hour), *s.StockoutAt)
+	assert.Equal(t, 20, s.ReorderPoint)
+	assert.Equal(t, forecast.StatusOK, s.Status)
+	assert.Zero(t, s.ReorderQuantity)
+
+	s = forecast.Project("p1", "w1", 15, 20, policy, now)
+	assert.Equal(t, forecast.StatusReorder, s.Status)
+	assert.Equal(t, 35, s.ReorderQuantity, "50 for lead time and cover, less the 15 on hand")
+
+	s = forecast.Project("p1", "w1", -2, 20, policy, now)
+	assert.Equal(t, forecast.StatusOutOfStock, s.Status)
+	assert.Equal(t, 50, s.ReorderQuantity)
+	assert.Equal(t, now, *s.StockoutAt)
+
+	s = forecast.Project("p1", "w1", 0, 0, policy, now)
+	assert.Equal(t, forecast.StatusOK, s.Status, "not selling")
+	assert.Nil(t, s.StockoutAt)
+}
+
+func TestForecastProductSharesUnattributedSales(t *testing.T) {
+	now := time.Now()
+	p := &product.Product{ID: "p1", Stock: 40}
+	stocks := []*warehouse.Stock{
+		{WarehouseID: "w1", ProductID: "p1", Quantity: 30},
+		{WarehouseID: "w2", ProductID: "p1", Quantity: 12, Reserved: 2},
+	}
+	sold := []analytics.ProductUnits{
+		{ProductID: "p1", WarehouseID: "w1", Units: 28},
+		{ProductID: "p1", Units: 56},
+		{ProductID: "p1", WarehouseID: "w3", Units: 7},
+	}
+
+	suggestions := forecast.ForecastProduct(p, stocks, sold, forecast.Policy{}, now)
+	require.Len(t, suggestions, 3)
+	assert.Equal(t, "w1", suggestions[0].WarehouseID)
+	assert.Equal(t, 70.0, suggestions[0].UnitsSold, "its own sales and three quarters of the rest")
+	assert.Equal(t, 10, suggestions[1].OnHand, "reservations aside")
+	assert.Equal(t, 14.0, suggestions[1].UnitsSold)
+	assert.Equal(t, "w3", suggestions[2].WarehouseID)
+	assert.Equal(t, forecast.StatusOutOfStock, suggestions[2].Status)
+
+	suggestions = forecast.ForecastProduct(p, nil, sold, forecast.Policy{}, now)
+	require.Len(t, suggestions, 1, "no warehouse stocks it")
+	assert.Equal(t, "", suggestions[0].WarehouseID)
+	assert.Equal(t, 40, suggestions[0].OnHand)
+	assert.Equal(t, 91.0, suggestions[0].UnitsSold)
+
+	bundle := &product.Product{ID: "b1", Components: []product.Component{{ProductID: "p1", Quantity: 2}}}
+	assert.Empty(t, forecast.ForecastProduct(bundle, nil, nil, forecast.Policy{}, now))
+}
+
+type unitsReportRepo struct {
+	analytics.ReportRepository
+	units []analytics.ProductUnits
+	asked analytics.ReportRange
+}
+
+func (r *unitsReportRepo) UnitsSold(ctx context.Context, rr analytics.ReportRange) ([]analytics.ProductUnits, error) {
+	r.asked = rr
+	return r.units, nil
+}
+
+type productStockRepo struct {
+	warehouse.StockRepository
+	stocks []*warehouse.Stock
+}
+
+func (r *productStockRepo) GetByProducts(ctx context.Context, productIDs []string) ([]*warehouse.Stock, error) {
+	var stocks []*warehouse.Stock
+	for _, s := range r.stocks {
+		for _, id := range productIDs {
+			if s.ProductID == id {
+				stocks = append(stocks, s)
+			}
+		}
+	}
+	return stocks, nil
+}
+
+type memoryForecastRepo struct {
+	forecast.Repository
+	suggestions []*forecast.Suggestion
+}
+
+func (r *memoryForecastRepo) Replace(ctx context.Context, suggestions []*forecast.Suggestion) error {
+	r.suggestions = suggestions
+	return nil
+}
+
+func TestRefreshForecastsReplacesSuggestions(t *testing.T) {
+	reports := &unitsReportRepo{units: []analytics.ProductUnits{
+		{ProductID: "p1", WarehouseID: "w1", Units: 280},
+		{ProductID: "p3", Units: 14},
+	}}
+	products := &pagedProductRepo{products: []*product.Product{
+		{ID: "p1"},
+		{ID: "p2", Stock: 5},
+		{ID: "p3", Stock: 100},
+	}}
+	stocks := &productStockRepo{stocks: []*warehouse.Stock{{WarehouseID: "w1", ProductID: "p1", Quantity: 100}}}
+	suggestions := &memoryForecastRepo{}
+	handler := commands.NewRefreshForecastsCommandHandler(reports, products, stocks, suggestions)
+
+	count, err := handler.Handle(context.Background(), commands.RefreshForecastsCommand{BatchSize: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.Equal(t, 28*24*time.Hour, reports.asked.To.Sub(reports.asked.From))
+
+	byProduct := make(map[string]*forecast.Suggestion)
+	for _, s := range suggestions.suggestions {
+		byProduct[s.ProductID] = s
+	}
+	assert.Equal(t, forecast.StatusReorder, byProduct["p1"].Status)
+	assert.Equal(t, 270, byProduct["p1"].ReorderQuantity, "37 days of 10 a day, less the 100 on hand")
+	assert.Equal(t, forecast.StatusOK, byProduct["p2"].Status, "in stock and not selling")
+	assert.Equal(t, forecast.StatusOK, byProduct["p3"].Status)
+}
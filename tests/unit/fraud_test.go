@@ -0,0 +1,173 @@
+package unit
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/fraud"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/user"
+	"online-shop/pkg/apperror"
+)
+
+type memoryFraudRepo struct {
+	assessments map[string]*fraud.Assessment
+}
+
+func (r *memoryFraudRepo) Create(ctx context.Context, a *fraud.Assessment) error {
+	r.assessments[a.OrderID] = a
+	return nil
+}
+
+func (r *memoryFraudRepo) GetByOrderID(ctx context.Context, orderID string) (*fraud.Assessment, error) {
+	if a, ok := r.assessments[orderID]; ok {
+		return a, nil
+	}
+	return nil, fraud.ErrNotFound
+}
+
+func (r *memoryFraudRepo) Update(ctx context.Context, a *fraud.Assessment) error {
+	r.assessments[a.OrderID] = a
+	return nil
+}
+
+func (r *memoryFraudRepo) List(ctx context.Context, status fraud.Status, limit, offset int) ([]*fraud.Assessment, error) {
+	return nil, nil
+}
+
+// fraudOrderRepo adds the customer's order history the screener reads
+type fraudOrderRepo struct {
+	*flashOrderRepo
+}
+
+func (r fraudOrderRepo) GetByUserID(ctx context.Context, userID string, limit, offset int) ([]*order.Order, error) {
+	var found []*order.Order
+	for _, o := range r.orders {
+		if o.UserID == userID {
+			found = append(found, o)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].CreatedAt.After(found[j].CreatedAt) })
+	if len(found) > limit {
+		found = found[:limit]
+	}
+	return found, nil
+}
+
+var fraudRules = fraud.Rules{
+	ReviewScore:     60,
+	VelocityWindow:  time.Hour,
+	VelocityLimit:   3,
+	HighValueAmount: 1000,
+}
+
+func reasonRules(a *fraud.Assessment) []fraud.Rule {
+	var rules []fraud.Rule
+	for _, r := range a.Reasons {
+		rules = append(rules, r.Rule)
+	}
+	return rules
+}
+
+func TestFraudAssessment(t *testing.T) {
+	now := time.Now()
+	shipping := fraud.Address{Country: "ID", PostalCode: "10110"}
+	returning := []time.Time{now.AddDate(0, -2, 0)}
+
+	a := fraud.Assess(fraudRules, fraud.Checkout{Email: "jane@example.com", Amount: 500, Shipping: shipping, Billing: &fraud.Address{Country: "id", PostalCode: "10 110"}, PreviousOrders: returning, At: now})
+	assert.Equal(t, 0, a.Score)
+	assert.Equal(t, fraud.LevelLow, a.Level)
+	assert.Equal(t, fraud.StatusCleared, a.Status)
+
+	a = fraud.Assess(fraudRules, fraud.Checkout{Email: "jane@mail.yopmail.com", Amount: 5000, Shipping: shipping, At: now})
+	assert.Equal(t, []fraud.Rule{fraud.RuleHighValueFirst, fraud.RuleDisposableEmail}, reasonRules(a))
+	assert.Equal(t, 65, a.Score)
+	assert.Equal(t, fraud.LevelHigh, a.Level)
+	assert.True(t, a.NeedsReview())
+
+	a = fraud.Assess(fraudRules, fraud.Checkout{Email: "jane@example.com", Amount: 500, Shipping: shipping, Billing: &fraud.Address{Country: "SG", PostalCode: "018989"}, PreviousOrders: returning, At: now})
+	assert.Equal(t, []fraud.Rule{fraud.RuleAddressMismatch}, reasonRules(a))
+	assert.Equal(t, fraud.LevelMedium, a.Level, "one signal alone is not held")
+	assert.False(t, a.NeedsReview())
+
+	rapid := []time.Time{now.Add(-10 * time.Minute), now.Add(-20 * time.Minute), now.Add(-30 * time.Minute)}
+	a = fraud.Assess(fraudRules, fraud.Checkout{Email: "jane@spam.test", Amount: 500, Shipping: shipping, PreviousOrders: rapid, At: now})
+	assert.Equal(t, []fraud.Rule{fraud.RuleVelocity}, reasonRules(a), "spam.test is not a built-in disposable domain")
+
+	rules := fraudRules
+	rules.DisposableDomains = []string{"spam.test"}
+	a = fraud.Assess(rules, fraud.Checkout{Email: "jane@spam.test", Amount: 5000, Shipping: shipping, Billing: &fraud.Address{Country: "SG"}, PreviousOrders: rapid, At: now})
+	assert.Equal(t, []fraud.Rule{fraud.RuleVelocity, fraud.RuleAddressMismatch, fraud.RuleDisposableEmail}, reasonRules(a), "configured domains are checked too")
+	assert.Equal(t, 95, a.Score)
+	assert.NotContains(t, reasonRules(a), fraud.RuleHighValueFirst, "a returning customer has no first order")
+}
+
+func TestRiskyOrdersWaitForReview(t *testing.T) {
+	products := &flashProductRepo{products: map[string]*product.Product{
+		"p1": {ID: "p1", Price: 1500, Stock: 5, Status: product.StatusActive},
+	}}
+	orders := fraudOrderRepo{&flashOrderRepo{orders: map[string]*order.Order{}}}
+	users := &deletionUserRepoStub{users: map[string]*user.User{
+		"u1": {ID: "u1", Email: "jane@mailinator.com"},
+		"u2": {ID: "u2", Email: "joe@example.com"},
+		"u3": {ID: "u3", Email: "ann@guerrillamail.com"},
+	}}
+	assessments := &memoryFraudRepo{assessments: map[string]*fraud.Assessment{}}
+	counter := newMemoryFlashCounter()
+	screener := commands.NewFraudScreener(assessments, users, orders, fraudRules)
+	create := commands.NewCreateOrderCommandHandler(orders, products, noCommissionRepo{}, noWarehouseRepo{}, nil, &flashSaleRepoStub{}, counter, commands.CreateOrderOptions{FraudScreener: screener})
+	cancel := commands.NewCancelOrderCommandHandler(orders, products, nil, counter, &paymentRepoStub{}, nil, order.CancellationPolicy{}, nil)
+
+	place := func(userID string) *order.Order {
+		o, err := create.Handle(context.Background(), commands.CreateOrderCommand{
+			UserID:          userID,
+			Items:           []commands.CreateOrderItemCmd{{ProductID: "p1", Quantity: 1}},
+			ShippingAddress: order.Address{Street: "Jl. Merdeka 1", City: "Jakarta", PostalCode: "10110", Country: "ID"},
+		})
+		require.NoError(t, err)
+		return o
+	}
+
+	safe := place("u2")
+	assert.NotEqual(t, order.StatusOnHold, safe.Status, "a high-value first order alone is not held")
+	assert.Equal(t, fraud.StatusCleared, assessments.assessments[safe.ID].Status)
+
+	held := place("u1")
+	assert.Equal(t, order.StatusOnHold, held.Status)
+	assert.Equal(t, fraud.StatusPendingReview, assessments.assessments[held.ID].Status)
+
+	pay := commands.NewCreateOrderPaymentsCommandHandler(orders, &memoryPaymentRepo{}, newMemoryMethodRepo(), &linkProviderStub{}, &tokenProviderStub{}, nil, nil)
+	_, err := pay.Handle(context.Background(), commands.CreateOrderPaymentsCommand{OrderID: held.ID, UserID: "u1", Installments: &commands.InstallmentPlanCmd{Count: 2, Method: "bank_transfer"}})
+	assert.Equal(t, commands.ErrOrderOnHold.Code, apperror.From(err).Code, "a held order cannot be paid")
+
+	approve := commands.NewApproveOrderReviewCommandHandler(assessments, orders)
+	a, err := approve.Handle(context.Background(), commands.ReviewOrderCommand{OrderID: held.ID, ReviewerID: "admin-1", Note: " known customer "})
+	require.NoError(t, err)
+	assert.Equal(t, fraud.StatusApproved, a.Status)
+	assert.Equal(t, "known customer", a.ReviewNote)
+	assert.Equal(t, order.StatusPending, held.Status)
+
+	_, err = approve.Handle(context.Background(), commands.ReviewOrderCommand{OrderID: held.ID, ReviewerID: "admin-1"})
+	assert.Equal(t, commands.ErrFraudReviewClosed.Code, apperror.From(err).Code)
+
+	declined := place("u3")
+	require.Equal(t, order.StatusOnHold, declined.Status)
+	assert.Equal(t, 2, products.products["p1"].Stock)
+
+	decline := commands.NewDeclineOrderReviewCommandHandler(assessments, orders, cancel)
+	a, err = decline.Handle(context.Background(), commands.ReviewOrderCommand{OrderID: declined.ID, ReviewerID: "admin-1"})
+	require.NoError(t, err)
+	assert.Equal(t, fraud.StatusDeclined, a.Status)
+	assert.Equal(t, order.StatusCancelled, declined.Status)
+	assert.Equal(t, 3, products.products["p1"].Stock, "a declined order gives its stock back")
+
+	_, err = decline.Handle(context.Background(), commands.ReviewOrderCommand{OrderID: "missing", ReviewerID: "admin-1"})
+	assert.Equal(t, commands.ErrFraudReviewNotFound.Code, apperror.From(err).Code)
+}
@@ -0,0 +1,158 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/fulfillment"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/warehouse"
+)
+
+type fulfillmentOrderRepo struct {
+	order.Repository
+	orders []*order.Order
+	// taken holds shipments another worker already moved on
+	taken map[string]bool
+	saved []string
+}
+
+func (r *fulfillmentOrderRepo) ListForFulfillment(ctx context.Context, warehouseID string, status order.ShipmentStatus, limit int) ([]*order.Order, error) {
+	return r.orders, nil
+}
+
+func (r *fulfillmentOrderRepo) GetByShipmentID(ctx context.Context, shipmentID string) (*order.Order, error) {
+	for _, o := range r.orders {
+		if o.Shipment(shipmentID) != nil {
+			return o, nil
+		}
+	}
+	return nil, order.ErrShipmentNotFound
+}
+
+func (r *fulfillmentOrderRepo) SaveShipment(ctx context.Context, o *order.Order, s *order.Shipment, from order.ShipmentStatus) error {
+	if r.taken[s.ID] {
+		return order.ErrShipmentState
+	}
+	r.saved = append(r.saved, s.ID)
+	return nil
+}
+
+type fulfillmentWarehouseRepo struct {
+	warehouse.Repository
+}
+
+func (fulfillmentWarehouseRepo) GetByID(ctx context.Context, id string) (*warehouse.Warehouse, error) {
+	return &warehouse.Warehouse{ID: id}, nil
+}
+
+type stubLabelPrinter struct {
+	requests []fulfillment.LabelRequest
+}
+
+func (p *stubLabelPrinter) Print(ctx context.Context, req fulfillment.LabelRequest) (*fulfillment.Label, error) {
+	p.requests = append(p.requests, req)
+	return &fulfillment.Label{Carrier: "jne", TrackingNumber: "JNE-" + req.ShipmentID, URL: "https://labels.example/" + req.ShipmentID}, nil
+}
+
+func twoShipmentOrder(id string) *order.Order {
+	return &order.Order{
+		ID:     id,
+		Number: "ORD-" + id,
+		Status: order.StatusConfirmed,
+		Items: []order.OrderItem{
+			{ID: id + "-i1", ProductID: "p1", Quantity: 2, ShipmentID: id + "-s1"},
+			{ID: id + "-i2", ProductID: "p2", Quantity: 1, ShipmentID: id + "-s1"},
+			{ID: id + "-i3", ProductID: "p1", Quantity: 1, ShipmentID: id + "-s2"},
+		},
+		Shipments: []order.Shipment{
+			{ID: id + "-s1", WarehouseID: "w1", Status: order.ShipmentStatusPending},
+			{ID: id + "-s2", WarehouseID: "w2", Status: order.ShipmentStatusPending},
+		},
+	}
+}
+
+func TestShipmentWorkflow(t *testing.T) {
+	o := twoShipmentOrder("o1")
+	now := time.Now()
+	parcel := order.Parcel{WeightGrams: 1200, LengthCm: 30, WidthCm: 20, HeightCm: 10}
+
+	_, err := o.PackShipment("o1-s1", parcel, now)
+	assert.ErrorIs(t, err, order.ErrShipmentState)
+
+	s, err := o.StartPicking("o1-s1", now)
+	require.NoError(t, err)
+	assert.Equal(t, order.ShipmentStatusPicking, s.Status)
+	assert.Equal(t, order.StatusProcessing, o.Status)
+
+	_, err = o.PackShipment("o1-s1", order.Parcel{WeightGrams: 1200}, now)
+	assert.ErrorIs(t, err, order.ErrInvalidParcel)
+	s, err = o.PackShipment("o1-s1", parcel, now)
+	require.NoError(t, err)
+	assert.Equal(t, parcel, s.Parcel)
+
+	_, err = o.ShipShipment("o1-s1", "jne", "T1", "", now)
+	require.NoError(t, err)
+	assert.Equal(t, order.StatusProcessing, o.Status, "the other shipment is still pending")
+
+	_, err = o.StartPicking("o1-s2", now)
+	require.NoError(t, err)
+	_, err = o.PackShipment("o1-s2", parcel, now)
+	require.NoError(t, err)
+	_, err = o.ShipShipment("o1-s2", "jne", "T2", "", now)
+	require.NoError(t, err)
+	assert.Equal(t, order.StatusShipped, o.Status)
+
+	pending := twoShipmentOrder("o2")
+	pending.Status = order.StatusPending
+	_, err = pending.StartPicking("o2-s1", now)
+	assert.ErrorIs(t, err, order.ErrShipmentState)
+	_, err = twoShipmentOrder("o3").StartPicking("missing", now)
+	assert.ErrorIs(t, err, order.ErrShipmentNotFound)
+}
+
+func TestGeneratePickListSkipsTakenShipments(t *testing.T) {
+	repo := &fulfillmentOrderRepo{
+		orders: []*order.Order{twoShipmentOrder("o1"), twoShipmentOrder("o2"), twoShipmentOrder("o3")},
+		taken:  map[string]bool{"o2-s1": true},
+	}
+	handler := commands.NewGeneratePickListCommandHandler(repo, fulfillmentWarehouseRepo{}, 0)
+
+	list, err := handler.Handle(context.Background(), commands.GeneratePickListCommand{WarehouseID: "w1"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"o1-s1", "o3-s1"}, repo.saved)
+	require.Len(t, list.Shipments, 2)
+	assert.Equal(t, "o1-s1", list.Shipments[0].ShipmentID)
+	assert.Equal(t, "o3-s1", list.Shipments[1].ShipmentID)
+	assert.Equal(t, []fulfillment.PickLine{{ProductID: "p1", Quantity: 4}, {ProductID: "p2", Quantity: 2}}, list.Lines)
+	assert.Equal(t, order.ShipmentStatusPending, repo.orders[0].Shipments[1].Status, "other warehouses are left alone")
+}
+
+func TestShipShipmentPrintsLabel(t *testing.T) {
+	o := twoShipmentOrder("o1")
+	o.Status = order.StatusProcessing
+	o.Shipments[0].Status = order.ShipmentStatusPacked
+	repo := &fulfillmentOrderRepo{orders: []*order.Order{o}}
+
+	_, err := commands.NewShipShipmentCommandHandler(repo, nil, nil, nil).Handle(context.Background(), commands.ShipShipmentCommand{ShipmentID: "o1-s1"})
+	assert.ErrorIs(t, err, fulfillment.ErrNoLabelPrinter)
+
+	printer := &stubLabelPrinter{}
+	s, err := commands.NewShipShipmentCommandHandler(repo, nil, printer, nil).Handle(context.Background(), commands.ShipShipmentCommand{ShipmentID: "o1-s1"})
+	require.NoError(t, err)
+	require.Len(t, printer.requests, 1)
+	assert.Equal(t, "ORD-o1", printer.requests[0].OrderNumber)
+	assert.Equal(t, order.ShipmentStatusShipped, s.Status)
+	assert.Equal(t, "JNE-o1-s1", s.TrackingNumber)
+	assert.Equal(t, "https://labels.example/o1-s1", s.LabelURL)
+
+	_, err = commands.NewShipShipmentCommandHandler(repo, nil, printer, nil).Handle(context.Background(), commands.ShipShipmentCommand{ShipmentID: "o1-s2", Carrier: "jne", TrackingNumber: "T2"})
+	assert.ErrorIs(t, err, order.ErrShipmentState, "a pending shipment is not shipped")
+	assert.Len(t, printer.requests, 1)
+}
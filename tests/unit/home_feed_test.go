@@ -0,0 +1,108 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/banner"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/recommendation"
+)
+
+type bannerRepoStub struct {
+	banner.Repository
+	banners []*banner.Banner
+}
+
+func (r *bannerRepoStub) ListActive(ctx context.Context, at time.Time) ([]*banner.Banner, error) {
+	return r.banners, nil
+}
+
+type failingTrendingStub struct{}
+
+func (failingTrendingStub) Record(ctx context.Context, productID string, score float64, at time.Time) error {
+	return nil
+}
+
+func (failingTrendingStub) Refresh(ctx context.Context, at time.Time) error {
+	return nil
+}
+
+func (failingTrendingStub) Top(ctx context.Context, limit, offset int) ([]string, error) {
+	return nil, errors.New("redis unavailable")
+}
+
+func (failingTrendingStub) Forget(ctx context.Context, from, to time.Time, productID string) error {
+	return nil
+}
+
+type boughtTogetherStub struct {
+	recommendation.Repository
+	pairs map[string][]*recommendation.Pair
+}
+
+func (r *boughtTogetherStub) BoughtTogether(ctx context.Context, productID string, limit int) ([]*recommendation.Pair, error) {
+	return r.pairs[productID], nil
+}
+
+type homeProductRepoStub struct {
+	productRepoStub
+}
+
+func (r *homeProductRepoStub) ListFeatured(ctx context.Context, at time.Time, limit int) ([]*product.Product, error) {
+	return []*product.Product{r.products["featured"]}, nil
+}
+
+func (r *homeProductRepoStub) GetByID(ctx context.Context, id string) (*product.Product, error) {
+	if p, ok := r.products[id]; ok {
+		return p, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func (r *homeProductRepoStub) List(ctx context.Context, filter product.SearchFilter) ([]*product.Product, error) {
+	return nil, nil
+}
+
+func TestHomeFeedToleratesFailingSections(t *testing.T) {
+	active := func(id string) *product.Product {
+		return &product.Product{ID: id, Status: product.StatusActive}
+	}
+	repo := &homeProductRepoStub{productRepoStub{products: map[string]*product.Product{
+		"featured": active("featured"),
+		"viewed":   active("viewed"),
+		"addon":    active("addon"),
+	}}}
+	cache := &productCacheStub{items: map[string][]byte{}}
+	views := &recentlyViewedStub{lists: map[string][]string{"user:u1": {"viewed"}}}
+	pairs := &boughtTogetherStub{pairs: map[string][]*recommendation.Pair{
+		"viewed": {{ProductID: "viewed", RelatedID: "addon", Count: 4}},
+	}}
+
+	handler := queries.NewGetHomeFeedQueryHandler(
+		&bannerRepoStub{banners: []*banner.Banner{{ID: "b1"}}},
+		queries.NewGetFeaturedProductsQueryHandler(repo),
+		queries.NewGetTrendingProductsQueryHandler(failingTrendingStub{}, repo),
+		queries.NewGetRecentlyViewedQueryHandler(views, repo, cache),
+		queries.NewGetSimilarProductsQueryHandler(repo, nil),
+		queries.NewGetBoughtTogetherQueryHandler(pairs, repo),
+	)
+
+	feed, err := handler.Handle(context.Background(), queries.GetHomeFeedQuery{UserID: "u1"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"trending"}, feed.Unavailable)
+	assert.Empty(t, feed.Trending)
+	require.Len(t, feed.Banners, 1)
+	require.Len(t, feed.Featured, 1)
+	require.Len(t, feed.RecentlyViewed, 1)
+	assert.Equal(t, "viewed", feed.RecentlyViewed[0].ID)
+	require.Len(t, feed.Recommended, 1)
+	assert.Equal(t, "addon", feed.Recommended[0].ID)
+}
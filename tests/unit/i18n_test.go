@@ -0,0 +1,127 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/user"
+	"online-shop/internal/interfaces/http/middleware"
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/i18n"
+	"online-shop/pkg/validation"
+)
+
+func TestNegotiateLocale(t *testing.T) {
+	assert.Equal(t, "id", i18n.Negotiate("en;q=0.5, id-ID;q=0.9"), "quality values order the header")
+	assert.Equal(t, "en", i18n.Negotiate("id;q=0, en"), "q=0 rules a language out")
+	assert.Equal(t, "en", i18n.Negotiate("de-DE, ja"))
+	assert.Equal(t, "en", i18n.Negotiate(""))
+
+	assert.Equal(t, "id", i18n.Negotiate("en", "id"), "a saved preference wins over the header")
+	assert.Equal(t, "en", i18n.Negotiate("en", "xx", ""), "unsupported preferences are skipped")
+	assert.Equal(t, "id", i18n.Negotiate("", "ID_id"))
+}
+
+func TestTranslateFallsBack(t *testing.T) {
+	assert.Equal(t, "Selamat datang, Budi!", i18n.T("id-ID", "email.welcome.heading", "FirstName", "Budi"))
+	assert.Equal(t, "Welcome Budi!", i18n.T("de", "email.welcome.heading", "FirstName", "Budi"))
+
+	i18n.Register("en", i18n.Messages{"test.fallback": "in English"})
+	assert.Equal(t, "in English", i18n.T("id", "test.fallback"), "missing translations use the default locale")
+	assert.Equal(t, "test.missing", i18n.T("id", "test.missing"))
+
+	_, ok := i18n.Lookup("id", "error.experiment_not_found")
+	assert.False(t, ok, "untranslated codes keep the catalog message")
+}
+
+func TestProblemTitleFollowsLocale(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	prefs := middleware.LocalePreferencesFunc(func(ctx context.Context, userID string) string {
+		if userID == "u-id" {
+			return "id"
+		}
+		return ""
+	})
+	router := gin.New()
+	router.Use(middleware.Errors(), middleware.Locale(prefs))
+	router.GET("/orders/:id", func(c *gin.Context) {
+		if user := c.Query("user"); user != "" {
+			c.Set("user_id", user)
+		}
+		middleware.AbortWithError(c, commands.ErrOrderNotFound)
+	})
+
+	call := func(path, acceptLanguage string) (*httptest.ResponseRecorder, apperror.Problem) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Language", acceptLanguage)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var problem apperror.Problem
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+		return w, problem
+	}
+
+	w, problem := call("/orders/1", "id-ID,id;q=0.9")
+	assert.Equal(t, "pesanan tidak ditemukan", problem.Title)
+	assert.Equal(t, "order_not_found", problem.Code, "codes do not change with the locale")
+	assert.Equal(t, "id", w.Header().Get("Content-Language"))
+
+	_, problem = call("/orders/1", "fr")
+	assert.Equal(t, commands.ErrOrderNotFound.Message, problem.Title)
+
+	w, problem = call("/orders/1?user=u-id", "en")
+	assert.Equal(t, "pesanan tidak ditemukan", problem.Title, "the user's saved locale wins")
+	assert.Equal(t, "id", w.Header().Get("Content-Language"))
+}
+
+func TestLocaleValidationRule(t *testing.T) {
+	cmd := commands.RegisterUserCommand{Email: "budi@example.com", Password: "password123", FirstName: "Budi", LastName: "Santoso", Locale: "xx"}
+	assert.Equal(t, map[string]string{"locale": validation.CodeUnsupportedLocale}, fieldCodes(t, validation.Struct(cmd)))
+
+	cmd.Locale = "id-ID"
+	assert.NoError(t, validation.Struct(cmd))
+}
+
+func TestUpdateProfileLocale(t *testing.T) {
+	u, err := user.NewUser("budi@example.com", "password123", "Budi", "Santoso", "")
+	require.NoError(t, err)
+	handler := commands.NewUpdateUserProfileCommandHandler(&deletionUserRepoStub{users: map[string]*user.User{u.ID: u}})
+
+	updated, err := handler.Handle(context.Background(), commands.UpdateUserProfileCommand{UserID: u.ID, Updates: map[string]interface{}{"locale": "ID-id"}})
+	require.NoError(t, err)
+	assert.Equal(t, "id", updated.Locale)
+
+	_, err = handler.Handle(context.Background(), commands.UpdateUserProfileCommand{UserID: u.ID, Updates: map[string]interface{}{"locale": "klingon"}})
+	assert.ErrorIs(t, err, commands.ErrUnsupportedLocale)
+	assert.Equal(t, "id", u.Locale)
+
+	updated, err = handler.Handle(context.Background(), commands.UpdateUserProfileCommand{UserID: u.ID, Updates: map[string]interface{}{"locale": ""}})
+	require.NoError(t, err)
+	assert.Empty(t, updated.Locale, "an empty locale goes back to the default")
+}
+
+func TestLocalizedGRPCStatus(t *testing.T) {
+	var localized *errdetails.LocalizedMessage
+	for _, detail := range commands.ErrInsufficientStock.LocalizedStatus("id").Details() {
+		if m, ok := detail.(*errdetails.LocalizedMessage); ok {
+			localized = m
+		}
+	}
+	require.NotNil(t, localized)
+	assert.Equal(t, "id", localized.Locale)
+	assert.Equal(t, "stok tidak mencukupi", localized.Message)
+
+	for _, detail := range commands.ErrInsufficientStock.LocalizedStatus("en").Details() {
+		assert.IsType(t, &errdetails.ErrorInfo{}, detail, "the catalog message needs no translation")
+	}
+}
@@ -0,0 +1,81 @@
+package unit
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/pkg/id"
+)
+
+func TestGeneratedIDsSortInCreationOrder(t *testing.T) {
+	gen, err := id.NewGenerator(7)
+	require.NoError(t, err)
+
+	ids := make([]string, 5000)
+	for i := range ids {
+		ids[i] = gen.New()
+	}
+	assert.True(t, sort.StringsAreSorted(ids), "IDs made later sort later, within and across milliseconds")
+
+	seen := map[string]bool{}
+	for _, s := range ids {
+		require.Len(t, s, id.Length)
+		require.True(t, id.Valid(s), s)
+		require.False(t, seen[s], "duplicate id %s", s)
+		seen[s] = true
+	}
+
+	node, err := id.Node(ids[0])
+	require.NoError(t, err)
+	assert.Equal(t, uint16(7), node)
+
+	made, err := id.Time(ids[len(ids)-1])
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), made, time.Second)
+}
+
+func TestGeneratedIDsAreUniqueAcrossGoroutinesAndNodes(t *testing.T) {
+	a, err := id.NewGenerator(1)
+	require.NoError(t, err)
+	b, err := id.NewGenerator(2)
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	seen := map[string]bool{}
+	var wg sync.WaitGroup
+	for _, gen := range []*id.Generator{a, a, b, b} {
+		wg.Add(1)
+		go func(gen *id.Generator) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				s := gen.New()
+				mu.Lock()
+				seen[s] = true
+				mu.Unlock()
+			}
+		}(gen)
+	}
+	wg.Wait()
+	assert.Len(t, seen, 4000)
+}
+
+func TestIDNodeRangeAndValidation(t *testing.T) {
+	_, err := id.NewGenerator(id.MaxNode + 1)
+	assert.Error(t, err)
+	assert.Error(t, id.SetNode(id.MaxNode+1))
+
+	assert.False(t, id.Valid("550e8400-e29b-41d4-a716-446655440000"), "UUIDs are not generated IDs")
+	assert.False(t, id.Valid("01ARZ3NDEKTSV4RRFFQ69G5FAU"), "U is not in the alphabet")
+	assert.False(t, id.Valid("81ARZ3NDEKTSV4RRFFQ69G5FAV"), "the first character holds only three bits")
+	assert.False(t, id.Valid("blue-cotton-shirt"))
+	assert.True(t, id.Valid("01ARZ3NDEKTSV4RRFFQ69G5FAV"))
+
+	made, err := id.Time("01ARZ3NDEKTSV4RRFFQ69G5FAV")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1469922850259), made.UnixMilli())
+}
@@ -0,0 +1,117 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"online-shop/internal/domain/idempotency"
+	"online-shop/internal/interfaces/http/middleware"
+)
+
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]idempotency.Record
+}
+
+func (s *memoryIdempotencyStore) Reserve(ctx context.Context, record *idempotency.Record, ttl time.Duration) (*idempotency.Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.records[record.Key]; ok {
+		return &existing, nil
+	}
+	s.records[record.Key] = *record
+	return nil, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(ctx context.Context, record *idempotency.Record, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[record.Key] = *record
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+func TestIdempotencyReplaysFirstResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &memoryIdempotencyStore{records: make(map[string]idempotency.Record)}
+
+	calls := 0
+	router := gin.New()
+	router.POST("/orders", middleware.Idempotency(store, time.Hour), func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusCreated, gin.H{"call": calls})
+	})
+
+	send := func(key, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/orders", bytes.NewBufferString(body))
+		req.Header.Set(middleware.IdempotencyKeyHeader, key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := send("key-1", `{"items":1}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+
+	replay := send("key-1", `{"items":1}`)
+	assert.Equal(t, http.StatusCreated, replay.Code)
+	assert.Equal(t, first.Body.String(), replay.Body.String())
+	assert.Equal(t, "true", replay.Header().Get(middleware.IdempotentReplayedHeader))
+	assert.Equal(t, 1, calls)
+
+	mismatch := send("key-1", `{"items":2}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, mismatch.Code)
+
+	other := send("key-2", `{"items":1}`)
+	assert.Equal(t, http.StatusCreated, other.Code)
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotencyReleasesKeyOnServerError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &memoryIdempotencyStore{records: make(map[string]idempotency.Record)}
+
+	router := gin.New()
+	router.POST("/orders", middleware.Idempotency(store, time.Hour), func(c *gin.Context) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "boom"})
+	})
+
+	req, _ := http.NewRequest("POST", "/orders", bytes.NewBufferString(`{}`))
+	req.Header.Set(middleware.IdempotencyKeyHeader, "key-1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Empty(t, store.records)
+}
+
+func TestIdempotencyReleasesKeyWhenHandlerPanics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &memoryIdempotencyStore{records: make(map[string]idempotency.Record)}
+
+	router := gin.New()
+	router.Use(gin.Recovery())
+	router.POST("/orders", middleware.Idempotency(store, time.Hour), func(c *gin.Context) {
+		panic("boom")
+	})
+
+	req, _ := http.NewRequest("POST", "/orders", bytes.NewBufferString(`{}`))
+	req.Header.Set(middleware.IdempotencyKeyHeader, "key-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Empty(t, store.records, "the key can be retried")
+}
@@ -0,0 +1,333 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/audit"
+	"online-shop/internal/domain/impersonation"
+	"online-shop/internal/domain/user"
+	"online-shop/internal/interfaces/http/handlers"
+	"online-shop/internal/interfaces/http/middleware"
+	"online-shop/pkg/config"
+	"online-shop/pkg/jwt"
+)
+
+type memoryGrantRepo struct {
+	mu     sync.Mutex
+	grants map[string]impersonation.Grant
+}
+
+func newMemoryGrantRepo() *memoryGrantRepo {
+	return &memoryGrantRepo{grants: make(map[string]impersonation.Grant)}
+}
+
+func (r *memoryGrantRepo) Create(ctx context.Context, grant *impersonation.Grant) error {
+	return r.Update(ctx, grant)
+}
+
+func (r *memoryGrantRepo) GetByID(ctx context.Context, id string) (*impersonation.Grant, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	grant, ok := r.grants[id]
+	if !ok {
+		return nil, impersonation.ErrNotFound
+	}
+	return &grant, nil
+}
+
+func (r *memoryGrantRepo) Update(ctx context.Context, grant *impersonation.Grant) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.grants[grant.ID] = *grant
+	return nil
+}
+
+func (r *memoryGrantRepo) List(ctx context.Context, filter impersonation.Filter, limit, offset int) ([]*impersonation.Grant, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var grants []*impersonation.Grant
+	for id := range r.grants {
+		grant := r.grants[id]
+		grants = append(grants, &grant)
+	}
+	return grants, int64(len(grants)), nil
+}
+
+// asyncAuditRepo receives the entries the middleware writes in the background
+type asyncAuditRepo struct {
+	auditRepoStub
+	entries chan *audit.Entry
+}
+
+func (r *asyncAuditRepo) Create(ctx context.Context, entry *audit.Entry) error {
+	r.entries <- entry
+	return nil
+}
+
+func (r *asyncAuditRepo) next(t *testing.T) *audit.Entry {
+	t.Helper()
+	select {
+	case entry := <-r.entries:
+		return entry
+	case <-time.After(time.Second):
+		t.Fatal("no audit entry was written")
+		return nil
+	}
+}
+
+type impersonationUserRepo struct {
+	user.Repository
+	users map[string]*user.User
+}
+
+func (r *impersonationUserRepo) GetByID(ctx context.Context, id string) (*user.User, error) {
+	if u, ok := r.users[id]; ok {
+		return u, nil
+	}
+	return nil, errors.New("user not found")
+}
+
+func testCustomer(id string) *user.User {
+	return &user.User{ID: id, Email: id + "@example.com", Role: user.RoleCustomer, Status: user.StatusActive}
+}
+
+func TestNewGrantRefusesWhoCannotBeImpersonated(t *testing.T) {
+	now := time.Now()
+	reason := "Ticket 4821: checkout keeps failing"
+
+	grant, err := impersonation.NewGrant("admin-1", testCustomer("cust-1"), reason, impersonation.ScopeRead, 15*time.Minute, now)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(15*time.Minute), grant.ExpiresAt)
+	assert.True(t, grant.Active(now))
+	assert.True(t, grant.ReadOnly())
+
+	admin := testCustomer("admin-2")
+	admin.Role = user.RoleAdmin
+	suspended := testCustomer("cust-2")
+	suspended.Status = user.StatusSuspended
+
+	for name, tc := range map[string]struct {
+		customer *user.User
+		reason   string
+	}{
+		"themselves":   {customer: testCustomer("admin-1"), reason: reason},
+		"staff":        {customer: admin, reason: reason},
+		"suspended":    {customer: suspended, reason: reason},
+		"short reason": {customer: testCustomer("cust-1"), reason: "  help   "},
+	} {
+		_, err := impersonation.NewGrant("admin-1", tc.customer, tc.reason, impersonation.ScopeRead, 15*time.Minute, now)
+		assert.ErrorIs(t, err, impersonation.ErrNotAllowed, name)
+	}
+}
+
+func TestGrantEnds(t *testing.T) {
+	now := time.Now()
+	grant, err := impersonation.NewGrant("admin-1", testCustomer("cust-1"), "Ticket 4821: wrong address", impersonation.ScopeWrite, time.Minute, now)
+	require.NoError(t, err)
+
+	require.NoError(t, grant.End("admin-1", now))
+	assert.False(t, grant.Active(now))
+	assert.ErrorIs(t, grant.End("admin-1", now), impersonation.ErrInactive)
+
+	expired, err := impersonation.NewGrant("admin-1", testCustomer("cust-1"), "Ticket 4821: wrong address", impersonation.ScopeWrite, time.Minute, now)
+	require.NoError(t, err)
+	assert.False(t, expired.Active(now.Add(time.Minute)))
+}
+
+func TestStartImpersonationIsAudited(t *testing.T) {
+	grants := newMemoryGrantRepo()
+	auditRepo := &recordingAuditRepo{}
+	users := &impersonationUserRepo{users: map[string]*user.User{"cust-1": testCustomer("cust-1")}}
+	handler := commands.NewStartImpersonationCommandHandler(users, grants, auditRepo, 15*time.Minute, time.Hour)
+
+	cmd := commands.StartImpersonationCommand{
+		AdminID:    "admin-1",
+		AdminRole:  "admin",
+		CustomerID: "cust-1",
+		Reason:     "Ticket 4821: checkout keeps failing",
+		RequestID:  "req-1",
+	}
+	started, err := handler.Handle(context.Background(), cmd)
+	require.NoError(t, err)
+	grant := started.Grant
+	assert.Equal(t, impersonation.ScopeRead, grant.Scope, "read only unless asked")
+	assert.WithinDuration(t, time.Now().Add(15*time.Minute), grant.ExpiresAt, time.Second)
+
+	require.Len(t, auditRepo.entries, 1)
+	entry := auditRepo.entries[0]
+	assert.Equal(t, audit.SourceCommand, entry.Source)
+	assert.Equal(t, "impersonation.start", entry.Action)
+	assert.Equal(t, "admin-1", entry.ActorID)
+	assert.Equal(t, "admin-1", entry.ImpersonatorID)
+	assert.Equal(t, "cust-1", entry.ResourceID)
+	assert.Equal(t, grant.ID, entry.Changes["impersonation_id"].After)
+	assert.Equal(t, cmd.Reason, entry.Changes["reason"].After)
+
+	cmd.Minutes = 90
+	_, err = handler.Handle(context.Background(), cmd)
+	assert.ErrorIs(t, err, impersonation.ErrNotAllowed)
+
+	cmd.Minutes, cmd.CustomerID = 0, "missing"
+	_, err = handler.Handle(context.Background(), cmd)
+	assert.ErrorIs(t, err, commands.ErrUserNotFound)
+
+	end := commands.NewEndImpersonationCommandHandler(grants, auditRepo)
+	ended, err := end.Handle(context.Background(), commands.EndImpersonationCommand{ID: grant.ID, ActorID: "admin-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "admin-1", ended.EndedBy)
+	assert.Equal(t, "impersonation.end", auditRepo.entries[1].Action)
+
+	_, err = end.Handle(context.Background(), commands.EndImpersonationCommand{ID: grant.ID, ActorID: "admin-1"})
+	assert.ErrorIs(t, err, impersonation.ErrInactive)
+}
+
+type impersonationFixture struct {
+	manager *jwt.JWTManager
+	grants  *memoryGrantRepo
+	audit   *asyncAuditRepo
+	router  *gin.Engine
+}
+
+func newImpersonationFixture(t *testing.T) *impersonationFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	manager, err := jwt.NewJWTManager(&config.JWTConfig{SecretKey: "secret", ExpiryHours: 1, RefreshExpiryHours: 24, Issuer: "online-shop"})
+	require.NoError(t, err)
+	f := &impersonationFixture{
+		manager: manager,
+		grants:  newMemoryGrantRepo(),
+		audit:   &asyncAuditRepo{entries: make(chan *audit.Entry, 10)},
+		router:  gin.New(),
+	}
+
+	auth := middleware.NewAuthMiddleware(manager, nil).WithImpersonation(f.grants, f.audit, zap.NewNop())
+	list := handlers.NewImpersonationHandler(nil, nil, queries.NewListImpersonationsQueryHandler(f.grants), manager)
+	f.router.GET("/orders", auth.RequireAuth(), list.ListImpersonations)
+	f.router.POST("/orders", auth.RequireAuth(), middleware.Audit(f.audit, zap.NewNop()), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+	f.router.PUT("/password", auth.RequireAuth(), middleware.DenyImpersonation(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return f
+}
+
+func (f *impersonationFixture) token(t *testing.T, scope impersonation.Scope) (string, *impersonation.Grant) {
+	t.Helper()
+	grant, err := impersonation.NewGrant("admin-1", testCustomer("cust-1"), "Ticket 4821: checkout keeps failing", scope, 15*time.Minute, time.Now())
+	require.NoError(t, err)
+	require.NoError(t, f.grants.Create(context.Background(), grant))
+	token, err := f.manager.GenerateImpersonationToken("cust-1", "cust-1@example.com", "customer", jwt.Actor{
+		Subject: grant.AdminID,
+		GrantID: grant.ID,
+		Scope:   string(grant.Scope),
+	}, grant.ExpiresAt)
+	require.NoError(t, err)
+	return token, grant
+}
+
+func (f *impersonationFixture) call(method, path, token string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestImpersonatedResponsesCarryTheBanner(t *testing.T) {
+	f := newImpersonationFixture(t)
+	token, grant := f.token(t, impersonation.ScopeRead)
+
+	w := f.call("GET", "/orders", token)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "admin-1", w.Header().Get(middleware.ImpersonatedByHeader))
+	assert.NotEmpty(t, w.Header().Get(middleware.ImpersonationExpiresHeader))
+
+	var body struct {
+		Impersonation *handlers.ImpersonationBanner `json:"impersonation"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.NotNil(t, body.Impersonation)
+	assert.Equal(t, grant.ID, body.Impersonation.ID)
+	assert.Equal(t, "admin-1", body.Impersonation.AdminID)
+	assert.Equal(t, "cust-1", body.Impersonation.CustomerID)
+
+	// Reads are recorded too, under the customer with the admin alongside
+	entry := f.audit.next(t)
+	assert.Equal(t, "GET /orders", entry.Action)
+	assert.Equal(t, "cust-1", entry.ActorID)
+	assert.Equal(t, "admin-1", entry.ImpersonatorID)
+	assert.Equal(t, http.StatusOK, entry.StatusCode)
+
+	// Ordinary tokens get neither
+	plain, err := f.manager.GenerateToken("cust-1", "cust-1@example.com", "customer")
+	require.NoError(t, err)
+	w = f.call("GET", "/orders", plain)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(middleware.ImpersonatedByHeader))
+	assert.NotContains(t, w.Body.String(), `"impersonation"`)
+}
+
+func TestImpersonationScope(t *testing.T) {
+	f := newImpersonationFixture(t)
+
+	read, _ := f.token(t, impersonation.ScopeRead)
+	w := f.call("POST", "/orders", read)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "impersonation_read_only")
+
+	write, _ := f.token(t, impersonation.ScopeWrite)
+	assert.Equal(t, http.StatusCreated, f.call("POST", "/orders", write).Code)
+	// Recorded once, by the Audit middleware
+	entry := f.audit.next(t)
+	assert.Equal(t, "POST /orders", entry.Action)
+	assert.Equal(t, "admin-1", entry.ImpersonatorID)
+	select {
+	case extra := <-f.audit.entries:
+		t.Fatalf("request recorded twice: %+v", extra)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	w = f.call("PUT", "/password", write)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "impersonation_denied")
+}
+
+func TestEndedImpersonationIsRefused(t *testing.T) {
+	f := newImpersonationFixture(t)
+	token, grant := f.token(t, impersonation.ScopeRead)
+	require.Equal(t, http.StatusOK, f.call("GET", "/orders", token).Code)
+	f.audit.next(t)
+
+	require.NoError(t, grant.End("admin-1", time.Now()))
+	require.NoError(t, f.grants.Update(context.Background(), grant))
+	w := f.call("GET", "/orders", token)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "impersonation_ended")
+
+	// Servers that do not accept impersonation refuse its tokens
+	fresh, _ := f.token(t, impersonation.ScopeRead)
+	router := gin.New()
+	router.GET("/orders", middleware.NewAuthMiddleware(f.manager, nil).RequireAuth(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	req, _ := http.NewRequest("GET", "/orders", nil)
+	req.Header.Set("Authorization", "Bearer "+fresh)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,138 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/job"
+	"online-shop/pkg/apperror"
+)
+
+type memoryJobRepo struct {
+	entries map[string]*job.Job
+}
+
+func (r *memoryJobRepo) Get(ctx context.Context, id string) (*job.Job, error) {
+	j, ok := r.entries[id]
+	if !ok {
+		return nil, job.ErrNotFound
+	}
+	copied := *j
+	return &copied, nil
+}
+
+func (r *memoryJobRepo) Save(ctx context.Context, j *job.Job) error {
+	copied := *j
+	r.entries[j.ID] = &copied
+	return nil
+}
+
+func (r *memoryJobRepo) List(ctx context.Context, filter job.Filter, limit, offset int) ([]*job.Job, int64, error) {
+	var jobs []*job.Job
+	for _, j := range r.entries {
+		if (filter.Queue == "" || j.Queue == filter.Queue) && (filter.State == "" || j.State == filter.State) {
+			jobs = append(jobs, j)
+		}
+	}
+	return jobs, int64(len(jobs)), nil
+}
+
+type recordingJobPublisher struct {
+	requeued []string
+}
+
+func (p *recordingJobPublisher) Requeue(ctx context.Context, j *job.Job) error {
+	p.requeued = append(p.requeued, j.ID)
+	return nil
+}
+
+func TestJobAttempts(t *testing.T) {
+	now := time.Now()
+	j := job.New("msg_1", "email_queue", "email", map[string]interface{}{"to": "budi@example.com"}, 2, now)
+	assert.True(t, j.Runnable())
+
+	j.Start(now)
+	j.Fail(errors.New("smtp timeout"), false, now)
+	assert.Equal(t, job.StatePending, j.State, "a failed attempt waits for the next delivery")
+	assert.Nil(t, j.FinishedAt)
+
+	j.Start(now)
+	j.Fail(errors.New("smtp timeout"), true, now)
+	assert.Equal(t, job.StateFailed, j.State)
+	assert.Equal(t, 2, j.Attempts)
+	assert.Equal(t, "smtp timeout", j.Error)
+
+	require.NoError(t, j.Retry(now))
+	j.Start(now)
+	j.Succeed(now)
+	assert.Equal(t, job.StateSucceeded, j.State)
+	assert.Equal(t, 3, j.Attempts, "attempts before a retry still count")
+	assert.Empty(t, j.Error)
+	assert.False(t, j.Runnable(), "a delivery of a job that succeeded is not run again")
+
+	assert.ErrorIs(t, j.Retry(now), job.ErrNotRetryable)
+	assert.ErrorIs(t, j.Cancel("admin-1", now), job.ErrNotCancellable)
+}
+
+func TestCancelAndRetryJob(t *testing.T) {
+	jobs := &memoryJobRepo{entries: map[string]*job.Job{}}
+	require.NoError(t, jobs.Save(context.Background(), job.New("msg_1", "export_queue", "export", nil, 3, time.Now())))
+
+	cancel := commands.NewCancelJobCommandHandler(jobs)
+	cancelled, err := cancel.Handle(context.Background(), commands.CancelJobCommand{ID: "msg_1", UserID: "admin-1"})
+	require.NoError(t, err)
+	assert.Equal(t, job.StateCancelled, cancelled.State)
+	assert.Equal(t, "admin-1", cancelled.CancelledBy)
+	assert.False(t, jobs.entries["msg_1"].Runnable(), "the worker skips a cancelled job")
+
+	_, err = cancel.Handle(context.Background(), commands.CancelJobCommand{ID: "msg_1", UserID: "admin-1"})
+	assert.Equal(t, commands.ErrJobNotCancellable.Code, apperror.From(err).Code)
+	assert.Contains(t, apperror.From(err).Detail, "cancelled")
+
+	publisher := &recordingJobPublisher{}
+	retried, err := commands.NewRetryJobCommandHandler(jobs, publisher).Handle(context.Background(), commands.RetryJobCommand{ID: "msg_1"})
+	require.NoError(t, err)
+	assert.Equal(t, job.StatePending, retried.State)
+	assert.Empty(t, retried.CancelledBy)
+	assert.Equal(t, []string{"msg_1"}, publisher.requeued)
+
+	_, err = commands.NewRetryJobCommandHandler(jobs, publisher).Handle(context.Background(), commands.RetryJobCommand{ID: "msg_1"})
+	assert.Equal(t, commands.ErrJobNotRetryable.Code, apperror.From(err).Code)
+
+	_, err = commands.NewRetryJobCommandHandler(jobs, publisher).Handle(context.Background(), commands.RetryJobCommand{ID: "missing"})
+	assert.Equal(t, commands.ErrJobNotFound.Code, apperror.From(err).Code)
+}
+
+func TestRetryJobWithoutQueue(t *testing.T) {
+	jobs := &memoryJobRepo{entries: map[string]*job.Job{}}
+	failed := job.New("msg_1", "invoice_queue", "invoice", nil, 1, time.Now())
+	failed.Start(time.Now())
+	failed.Fail(errors.New("pdf renderer crashed"), true, time.Now())
+	require.NoError(t, jobs.Save(context.Background(), failed))
+
+	_, err := commands.NewRetryJobCommandHandler(jobs, job.UnavailablePublisher{}).Handle(context.Background(), commands.RetryJobCommand{ID: "msg_1"})
+	assert.Equal(t, commands.ErrJobQueueUnavailable.Code, apperror.From(err).Code)
+	assert.Equal(t, job.StateFailed, jobs.entries["msg_1"].State, "a job that could not be queued stays failed")
+}
+
+func TestListJobs(t *testing.T) {
+	jobs := &memoryJobRepo{entries: map[string]*job.Job{}}
+	require.NoError(t, jobs.Save(context.Background(), job.New("msg_1", "email_queue", "email", nil, 3, time.Now())))
+	require.NoError(t, jobs.Save(context.Background(), job.New("msg_2", "export_queue", "export", nil, 3, time.Now())))
+
+	page, err := queries.NewListJobsQueryHandler(jobs).Handle(context.Background(), queries.ListJobsQuery{Queue: "export_queue", State: "pending"})
+	require.NoError(t, err)
+	require.Len(t, page.Jobs, 1)
+	assert.Equal(t, "msg_2", page.Jobs[0].ID)
+	assert.Equal(t, int64(1), page.Total)
+
+	_, err = queries.NewGetJobQueryHandler(jobs).Handle(context.Background(), queries.GetJobQuery{ID: "missing"})
+	assert.Equal(t, commands.ErrJobNotFound.Code, apperror.From(err).Code)
+}
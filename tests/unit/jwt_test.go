@@ -0,0 +1,153 @@
+package unit
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	jwtlib "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/pkg/config"
+	"online-shop/pkg/jwt"
+)
+
+func writeRSAKey(t *testing.T, dir, name string) (privatePath, publicPath string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+
+	privatePath = filepath.Join(dir, name+".pem")
+	publicPath = filepath.Join(dir, name+".pub.pem")
+	require.NoError(t, os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0o600))
+	require.NoError(t, os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public}), 0o600))
+	return privatePath, publicPath
+}
+
+func writeEdKey(t *testing.T, dir, name string) string {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, name+".pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+	return path
+}
+
+func jwtConfig(keys ...config.JWTKeyConfig) *config.JWTConfig {
+	return &config.JWTConfig{
+		ExpiryHours:        1,
+		RefreshExpiryHours: 720,
+		Issuer:             "online-shop",
+		Keys:               keys,
+	}
+}
+
+func TestJWTTokenTypesAreNotInterchangeable(t *testing.T) {
+	cfg := jwtConfig()
+	cfg.SecretKey = "secret"
+	manager, err := jwt.NewJWTManager(cfg)
+	require.NoError(t, err)
+
+	pair, err := manager.GenerateTokenPair("u1", "jane@example.com", "admin", "s1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3600), pair.ExpiresIn)
+
+	claims, err := manager.ValidateToken(pair.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, "admin", claims.Role)
+
+	_, err = manager.ValidateToken(pair.RefreshToken)
+	assert.ErrorIs(t, err, jwt.ErrWrongTokenType)
+
+	_, err = manager.ValidateRefreshToken(pair.AccessToken)
+	assert.ErrorIs(t, err, jwt.ErrWrongTokenType)
+
+	refresh, err := manager.ValidateRefreshToken(pair.RefreshToken)
+	require.NoError(t, err)
+	assert.Equal(t, "u1", refresh.UserID)
+	assert.Equal(t, "s1", refresh.SessionID)
+	assert.Empty(t, refresh.Role, "refresh tokens carry no role")
+	assert.True(t, refresh.ExpiresAt.After(claims.ExpiresAt.Time))
+}
+
+func TestJWTKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+	oldPrivate, oldPublic := writeRSAKey(t, dir, "old")
+	newPrivate := writeEdKey(t, dir, "new")
+
+	before, err := jwt.NewJWTManager(jwtConfig(config.JWTKeyConfig{ID: "old", Algorithm: jwt.AlgorithmRS256, PrivateKeyPath: oldPrivate}))
+	require.NoError(t, err)
+	oldToken, err := before.GenerateToken("u1", "jane@example.com", "customer")
+	require.NoError(t, err)
+
+	// The old key is kept as verify-only while the new one signs
+	cfg := jwtConfig(
+		config.JWTKeyConfig{ID: "old", Algorithm: jwt.AlgorithmRS256, PublicKeyPath: oldPublic},
+		config.JWTKeyConfig{ID: "new", Algorithm: jwt.AlgorithmEdDSA, PrivateKeyPath: newPrivate},
+	)
+	cfg.ActiveKeyID = "new"
+	after, err := jwt.NewJWTManager(cfg)
+	require.NoError(t, err)
+
+	_, err = after.ValidateToken(oldToken)
+	assert.NoError(t, err)
+
+	newToken, err := after.GenerateToken("u1", "jane@example.com", "customer")
+	require.NoError(t, err)
+	parsed, _, err := jwtlib.NewParser().ParseUnverified(newToken, &jwt.Claims{})
+	require.NoError(t, err)
+	assert.Equal(t, "new", parsed.Header["kid"])
+	assert.Equal(t, "EdDSA", parsed.Method.Alg())
+
+	_, err = before.ValidateToken(newToken)
+	assert.Error(t, err, "tokens from an unknown key are rejected")
+
+	keys := after.JWKS().Keys
+	require.Len(t, keys, 2)
+	assert.Equal(t, "new", keys[0].Kid)
+	assert.Equal(t, "OKP", keys[0].Kty)
+	assert.Equal(t, "old", keys[1].Kid)
+	assert.Equal(t, "RSA", keys[1].Kty)
+}
+
+func TestJWTRejectsVerifyOnlyActiveKey(t *testing.T) {
+	_, public := writeRSAKey(t, t.TempDir(), "old")
+	cfg := jwtConfig(config.JWTKeyConfig{ID: "old", Algorithm: jwt.AlgorithmRS256, PublicKeyPath: public})
+	cfg.ActiveKeyID = "old"
+
+	_, err := jwt.NewJWTManager(cfg)
+	assert.Error(t, err)
+}
+
+func TestJWTLegacySecretTokensStayValid(t *testing.T) {
+	dir := t.TempDir()
+	private, _ := writeRSAKey(t, dir, "k1")
+
+	legacyCfg := jwtConfig()
+	legacyCfg.SecretKey = "secret"
+	legacy, err := jwt.NewJWTManager(legacyCfg)
+	require.NoError(t, err)
+	token, err := legacy.GenerateToken("u1", "jane@example.com", "customer")
+	require.NoError(t, err)
+
+	cfg := jwtConfig(config.JWTKeyConfig{ID: "k1", Algorithm: jwt.AlgorithmRS256, PrivateKeyPath: private})
+	cfg.SecretKey = "secret"
+	manager, err := jwt.NewJWTManager(cfg)
+	require.NoError(t, err)
+
+	_, err = manager.ValidateToken(token)
+	assert.NoError(t, err)
+	for _, key := range manager.JWKS().Keys {
+		assert.NotEqual(t, "oct", key.Kty, "the shared secret is never published")
+	}
+	assert.Len(t, manager.JWKS().Keys, 1)
+}
@@ -0,0 +1,211 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"online-shop/internal/domain/systemlog"
+	"online-shop/internal/infrastructure/logstore"
+	"online-shop/internal/interfaces/http/middleware"
+	"online-shop/pkg/config"
+	"online-shop/pkg/logger"
+)
+
+func fileLoggerConfig(t *testing.T) *config.LoggerConfig {
+	t.Helper()
+	return &config.LoggerConfig{
+		Level:    "debug",
+		Format:   "json",
+		Output:   "file",
+		FilePath: filepath.Join(t.TempDir(), "app.log"),
+		Service:  "worker",
+	}
+}
+
+func searchLogs(t *testing.T, path string, filter systemlog.Filter) []*systemlog.Entry {
+	t.Helper()
+	entries, _, err := logstore.NewFileReader(path).Query(context.Background(), filter, 100, 0)
+	require.NoError(t, err)
+	return entries
+}
+
+func TestLogrusAdapterWritesThroughZap(t *testing.T) {
+	cfg := fileLoggerConfig(t)
+	require.NoError(t, logger.Init(cfg))
+
+	logger.WithField("job", "invoice").WithError(errors.New("smtp down")).Error("Invoice worker stopped")
+	logger.Zap().Warn("RabbitMQ health check failed", zap.String("queue", "email"))
+	logger.GetLogger().Debug("Polling queue")
+	require.NoError(t, logger.Sync())
+
+	entries := searchLogs(t, cfg.FilePath, systemlog.Filter{Level: "error"})
+	require.Len(t, entries, 1, "logrus entries are searchable like zap ones")
+	assert.Equal(t, "Invoice worker stopped", entries[0].Message)
+	assert.Equal(t, "worker", entries[0].Service)
+	assert.Equal(t, "invoice", entries[0].Fields["job"])
+	assert.Equal(t, "smtp down", entries[0].Fields["error"])
+
+	assert.Len(t, searchLogs(t, cfg.FilePath, systemlog.Filter{Level: "warn"}), 2)
+	assert.Len(t, searchLogs(t, cfg.FilePath, systemlog.Filter{Search: "Polling queue"}), 1, "debug entries follow the configured level")
+}
+
+func TestLoggerLevelIsSharedByBothAPIs(t *testing.T) {
+	cfg := fileLoggerConfig(t)
+	require.NoError(t, logger.Init(cfg))
+	require.NoError(t, logger.SetLevel("warning"))
+
+	logger.Info("dropped")
+	logger.Zap().Info("dropped")
+	logger.Warn("kept")
+	require.NoError(t, logger.Sync())
+	require.NoError(t, logger.SetLevel("debug"))
+
+	assert.Empty(t, searchLogs(t, cfg.FilePath, systemlog.Filter{Search: "dropped"}))
+	assert.Len(t, searchLogs(t, cfg.FilePath, systemlog.Filter{Search: "kept"}), 1)
+	assert.Error(t, logger.SetLevel("loud"))
+}
+
+func TestLoggerSamplingKeepsErrors(t *testing.T) {
+	cfg := fileLoggerConfig(t)
+	cfg.Sampling = config.LogSamplingConfig{Initial: 2, Thereafter: 0}
+	require.NoError(t, logger.Init(cfg))
+
+	for i := 0; i < 5; i++ {
+		logger.Zap().Info("Cache miss")
+		logger.GetLogger().Error("Cache unavailable")
+	}
+	require.NoError(t, logger.Sync())
+
+	assert.Len(t, searchLogs(t, cfg.FilePath, systemlog.Filter{Level: "info", Search: "Cache miss"}), 2)
+	assert.Len(t, searchLogs(t, cfg.FilePath, systemlog.Filter{Level: "error"}), 5)
+}
+
+func TestLoggerRotate(t *testing.T) {
+	cfg := fileLoggerConfig(t)
+	require.NoError(t, logger.Init(cfg))
+	logger.Info("before rotation")
+	require.NoError(t, logger.Rotate())
+	logger.Info("after rotation")
+
+	files, err := filepath.Glob(filepath.Join(filepath.Dir(cfg.FilePath), "app-*.log"))
+	require.NoError(t, err)
+	assert.Len(t, files, 1, "the old file is kept as a backup")
+	current, err := os.ReadFile(cfg.FilePath)
+	require.NoError(t, err)
+	assert.Contains(t, string(current), "after rotation")
+	assert.NotContains(t, string(current), "before rotation")
+}
+
+func TestTraceIDFromTraceparent(t *testing.T) {
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", logger.TraceID("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"))
+	assert.Empty(t, logger.TraceID("00-00000000000000000000000000000000-00f067aa0ba902b7-01"))
+	assert.Empty(t, logger.TraceID("not a trace"))
+	assert.Empty(t, logger.TraceID(""))
+}
+
+func TestRequestScopedLogFields(t *testing.T) {
+	cfg := fileLoggerConfig(t)
+	require.NoError(t, logger.Init(cfg))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.RequestID())
+	r.Use(middleware.RequestLogger(logger.Zap()))
+	r.GET("/orders", func(c *gin.Context) {
+		// Set by the auth middleware once the caller is known
+		logger.SetUserID(c.Request.Context(), "user-1")
+		logger.FromContext(c.Request.Context()).Info("Listing orders")
+		logger.EntryFromContext(c.Request.Context()).Info("Orders listed")
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-1")
+	req.Header.Set(middleware.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	require.NoError(t, logger.Sync())
+
+	entries := searchLogs(t, cfg.FilePath, systemlog.Filter{})
+	var messages []string
+	for _, entry := range entries {
+		if entry.Message == "Logger initialized successfully" {
+			continue
+		}
+		messages = append(messages, entry.Message)
+		assert.Equal(t, "req-1", entry.Fields["request_id"], entry.Message)
+		assert.Equal(t, "user-1", entry.Fields["user_id"], entry.Message)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", entry.Fields["trace_id"], entry.Message)
+	}
+	assert.ElementsMatch(t, []string{"Listing orders", "Orders listed", "HTTP Request"}, messages)
+	assert.Empty(t, logger.Fields(context.Background()))
+}
+
+func TestLoggerMasksPII(t *testing.T) {
+	cfg := fileLoggerConfig(t)
+	require.NoError(t, logger.Init(cfg))
+
+	logger.Zap().Info("User registration request", zap.String("email", "jane.doe@example.com"), zap.String("address", "0.0.0.0:12001"))
+	logger.Zap().Info("Alert queued", logger.Phone("contact", "+62 812-3456-7890"), logger.Address("ship_to", "Jl. Sudirman 1"))
+	logger.WithField("to", "+6281234567890").Info("WhatsApp sent")
+	logger.WithError(errors.New("550 mailbox jane.doe@example.com unavailable")).Error("Email to jane.doe@example.com failed")
+	require.NoError(t, logger.Sync())
+
+	data, err := os.ReadFile(cfg.FilePath)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "jane.doe@")
+	assert.NotContains(t, string(data), "3456")
+	assert.NotContains(t, string(data), "Sudirman")
+
+	entries := searchLogs(t, cfg.FilePath, systemlog.Filter{Search: "registration"})
+	require.Len(t, entries, 1)
+	assert.Equal(t, "j***@example.com", entries[0].Fields["email"])
+	assert.Equal(t, "0.0.0.0:12001", entries[0].Fields["address"], "listen addresses are not PII")
+
+	entries = searchLogs(t, cfg.FilePath, systemlog.Filter{Search: "Alert queued"})
+	require.Len(t, entries, 1)
+	assert.Equal(t, "*********7890", entries[0].Fields["contact"])
+	assert.Equal(t, "[redacted]", entries[0].Fields["ship_to"])
+
+	entries = searchLogs(t, cfg.FilePath, systemlog.Filter{Level: "error"})
+	require.Len(t, entries, 1)
+	assert.Equal(t, "Email to j***@example.com failed", entries[0].Message)
+	assert.Equal(t, "550 mailbox j***@example.com unavailable", entries[0].Fields["error"])
+}
+
+func TestLoggerHashesAndAllowsPII(t *testing.T) {
+	cfg := fileLoggerConfig(t)
+	cfg.Redaction = config.LogRedactionConfig{Mode: "hash", Allow: []string{"phone"}}
+	require.NoError(t, logger.Init(cfg))
+
+	logger.Zap().Info("first", logger.Email("email", "Jane.Doe@example.com"), zap.String("phone", "+6281234567890"))
+	logger.Zap().Info("second", logger.Email("email", "jane.doe@example.com "))
+	require.NoError(t, logger.Sync())
+
+	first := searchLogs(t, cfg.FilePath, systemlog.Filter{Search: "first"})
+	second := searchLogs(t, cfg.FilePath, systemlog.Filter{Search: "second"})
+	require.Len(t, first, 1)
+	require.Len(t, second, 1)
+	assert.Regexp(t, `^sha256:[0-9a-f]{16}$`, first[0].Fields["email"])
+	assert.Equal(t, first[0].Fields["email"], second[0].Fields["email"], "hashes let entries about one person be matched")
+	assert.Equal(t, "+6281234567890", first[0].Fields["phone"], "allowed kinds are logged in plain text")
+}
+
+func TestLogRedactionAllowListIsRefusedInProduction(t *testing.T) {
+	cfg := &config.Config{Environment: "production"}
+	cfg.Logger.Redaction = config.LogRedactionConfig{Mode: "scramble", Allow: []string{"email", "name"}}
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "logger.redaction.mode")
+	assert.Contains(t, err.Error(), `logger.redaction.allow must be one of email, phone, address, got "name"`)
+	assert.Contains(t, err.Error(), "logger.redaction.allow must be empty in production")
+}
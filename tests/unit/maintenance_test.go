@@ -0,0 +1,197 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/maintenance"
+	"online-shop/internal/interfaces/http/middleware"
+	"online-shop/pkg/config"
+)
+
+type memoryMaintenanceStore struct {
+	mu    sync.Mutex
+	state maintenance.State
+	reads int
+	err   error
+}
+
+func (s *memoryMaintenanceStore) Get(ctx context.Context) (*maintenance.State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reads++
+	if s.err != nil {
+		return nil, s.err
+	}
+	state := s.state
+	state.Windows = append([]maintenance.Window(nil), s.state.Windows...)
+	return &state, nil
+}
+
+func (s *memoryMaintenanceStore) Save(ctx context.Context, state *maintenance.State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = *state
+	return nil
+}
+
+func newMaintenanceRouter(store maintenance.Store, cfg *config.MaintenanceConfig, role string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Errors())
+	r.Use(func(c *gin.Context) {
+		if role != "" {
+			c.Set("user_role", role)
+		}
+		c.Next()
+	})
+	r.Use(middleware.Maintenance(store, cfg, zap.NewNop()))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/health", ok)
+	r.GET("/admin/system/maintenance", ok)
+	r.GET("/api/v1/products", ok)
+	r.POST("/api/v1/users/login", ok)
+	return r
+}
+
+func maintenanceCall(r *gin.Engine, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func TestMaintenanceStatus(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+
+	var state maintenance.State
+	assert.False(t, state.Status(now).Active)
+
+	require.NoError(t, state.Switch(true, "Upgrading", &later, "admin-1", now))
+	status := state.Status(now)
+	assert.True(t, status.Active)
+	assert.Equal(t, "Upgrading", status.Message)
+	assert.Equal(t, later, *status.Until)
+	assert.False(t, state.Status(later).Active, "maintenance ends by itself")
+
+	require.NoError(t, state.Switch(false, "ignored", nil, "admin-1", now))
+	assert.False(t, state.Status(now).Active)
+	assert.Empty(t, state.Message)
+
+	err := state.Switch(true, "", &now, "admin-1", later)
+	assert.True(t, errors.Is(err, maintenance.ErrInvalidPeriod))
+}
+
+func TestMaintenanceWindows(t *testing.T) {
+	now := time.Now()
+	var state maintenance.State
+
+	_, err := maintenance.NewWindow(now.Add(time.Hour), now, "", "admin-1", now)
+	assert.True(t, errors.Is(err, maintenance.ErrInvalidPeriod))
+	_, err = maintenance.NewWindow(now.Add(-2*time.Hour), now.Add(-time.Hour), "", "admin-1", now)
+	assert.True(t, errors.Is(err, maintenance.ErrInvalidPeriod))
+
+	short, err := maintenance.NewWindow(now.Add(time.Hour), now.Add(2*time.Hour), "Database upgrade", "admin-1", now)
+	require.NoError(t, err)
+	long, err := maintenance.NewWindow(now.Add(90*time.Minute), now.Add(3*time.Hour), "Search reindex", "admin-1", now)
+	require.NoError(t, err)
+	state.Schedule(*short, "admin-1", now)
+	state.Schedule(*long, "admin-1", now)
+
+	assert.False(t, state.Status(now).Active)
+	assert.Equal(t, "Database upgrade", state.Status(now.Add(time.Hour)).Message)
+	overlap := state.Status(now.Add(100 * time.Minute))
+	assert.True(t, overlap.Active)
+	assert.Equal(t, long.EndsAt, *overlap.Until, "retry once every window under way has ended")
+
+	state.Prune(now.Add(150 * time.Minute))
+	require.Len(t, state.Windows, 1)
+
+	_, err = state.Cancel(long.ID, "admin-1", now)
+	require.NoError(t, err)
+	assert.Empty(t, state.Windows)
+	_, err = state.Cancel(long.ID, "admin-1", now)
+	assert.Equal(t, maintenance.ErrWindowNotFound, err)
+}
+
+func TestMaintenanceAnswersPublicRoutesWith503(t *testing.T) {
+	until := time.Now().Add(10 * time.Minute)
+	store := &memoryMaintenanceStore{state: maintenance.State{Enabled: true, Message: "Back soon", EndsAt: &until}}
+	cfg := &config.MaintenanceConfig{ExemptPaths: []string{"/api/v1/users/login"}}
+	r := newMaintenanceRouter(store, cfg, "")
+
+	w := maintenanceCall(r, http.MethodGet, "/api/v1/products")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, []string{"599", "600"}, w.Header().Get(middleware.RetryAfterHeader))
+	assert.Contains(t, w.Body.String(), "maintenance_mode")
+	assert.Contains(t, w.Body.String(), "Back soon")
+
+	assert.Equal(t, http.StatusOK, maintenanceCall(r, http.MethodGet, "/health").Code)
+	assert.Equal(t, http.StatusOK, maintenanceCall(r, http.MethodGet, "/admin/system/maintenance").Code)
+	assert.Equal(t, http.StatusOK, maintenanceCall(r, http.MethodPost, "/api/v1/users/login").Code)
+
+	admin := newMaintenanceRouter(store, cfg, "admin")
+	assert.Equal(t, http.StatusOK, maintenanceCall(admin, http.MethodGet, "/api/v1/products").Code)
+}
+
+func TestMaintenanceWithoutEndUsesDefaultRetryAfter(t *testing.T) {
+	store := &memoryMaintenanceStore{state: maintenance.State{Enabled: true}}
+	r := newMaintenanceRouter(store, &config.MaintenanceConfig{RetryAfterSeconds: 120}, "")
+
+	w := maintenanceCall(r, http.MethodGet, "/api/v1/products")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "120", w.Header().Get(middleware.RetryAfterHeader))
+}
+
+func TestMaintenanceStateIsCachedAndFailsOpen(t *testing.T) {
+	store := &memoryMaintenanceStore{err: errors.New("redis down")}
+	r := newMaintenanceRouter(store, &config.MaintenanceConfig{RefreshSeconds: 60}, "")
+
+	assert.Equal(t, http.StatusOK, maintenanceCall(r, http.MethodGet, "/api/v1/products").Code)
+	assert.Equal(t, http.StatusOK, maintenanceCall(r, http.MethodGet, "/api/v1/products").Code)
+	assert.Equal(t, 1, store.reads, "a failed read is not retried on every request")
+}
+
+func TestSetMaintenanceIsAudited(t *testing.T) {
+	store := &memoryMaintenanceStore{}
+	auditRepo := &recordingAuditRepo{}
+	setHandler := commands.NewSetMaintenanceCommandHandler(store, auditRepo)
+
+	state, err := setHandler.Handle(context.Background(), commands.SetMaintenanceCommand{Enabled: true, Message: "Upgrading", ActorID: "admin-1", ActorRole: "admin"})
+	require.NoError(t, err)
+	assert.True(t, state.Enabled)
+	assert.True(t, store.state.Enabled)
+	assert.Equal(t, "admin-1", store.state.UpdatedBy)
+
+	require.Len(t, auditRepo.entries, 1)
+	entry := auditRepo.entries[0]
+	assert.Equal(t, "maintenance.update", entry.Action)
+	assert.Equal(t, "system", entry.ResourceType)
+	assert.Equal(t, true, entry.Changes["enabled"].After)
+
+	scheduleHandler := commands.NewScheduleMaintenanceCommandHandler(store, auditRepo)
+	window, err := scheduleHandler.Handle(context.Background(), commands.ScheduleMaintenanceCommand{
+		StartsAt: time.Now().Add(time.Hour),
+		EndsAt:   time.Now().Add(2 * time.Hour),
+		ActorID:  "admin-1",
+	})
+	require.NoError(t, err)
+	require.Len(t, store.state.Windows, 1)
+
+	cancelHandler := commands.NewCancelMaintenanceWindowCommandHandler(store, auditRepo)
+	require.NoError(t, cancelHandler.Handle(context.Background(), commands.CancelMaintenanceWindowCommand{ID: window.ID, ActorID: "admin-1"}))
+	assert.Empty(t, store.state.Windows)
+	require.Len(t, auditRepo.entries, 3)
+	assert.Equal(t, "maintenance.cancel", auditRepo.entries[2].Action)
+}
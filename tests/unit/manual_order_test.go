@@ -0,0 +1,93 @@
+package unit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/audit"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/payment"
+	"online-shop/internal/domain/user"
+	"online-shop/pkg/apperror"
+)
+
+type manualOrderUserRepo struct {
+	*preferenceUserRepoStub
+}
+
+func (r *manualOrderUserRepo) Create(ctx context.Context, u *user.User) error {
+	r.users[u.ID] = u
+	return nil
+}
+
+func TestAdminPlacesAnOrderForACustomer(t *testing.T) {
+	products := cartProducts()
+	orders := &flashOrderRepo{orders: map[string]*order.Order{}}
+	payments := &memoryPaymentRepo{}
+	users := &manualOrderUserRepo{&preferenceUserRepoStub{&deletionUserRepoStub{users: map[string]*user.User{}}}}
+	audits := &versionAuditStub{}
+	create := commands.NewCreateOrderCommandHandler(orders, products, noCommissionRepo{}, noWarehouseRepo{}, nil, &flashSaleRepoStub{}, newMemoryFlashCounter(), commands.CreateOrderOptions{})
+	offline := commands.NewRecordOfflinePaymentCommandHandler(orders, payments, nil, nil, audits)
+	manual := commands.NewCreateManualOrderCommandHandler(users, products, create, offline, audits)
+
+	cmd := commands.CreateManualOrderCommand{
+		ActorID:         "admin-1",
+		ActorRole:       "admin",
+		Customer:        &commands.ManualOrderCustomerCmd{Email: "budi@example.com", FirstName: "Budi", Phone: "081234567890"},
+		Channel:         order.ChannelPhone,
+		Items:           []commands.ManualOrderItemCmd{{ProductID: "p1", Quantity: 2, Price: 45000}, {ProductID: "p2", Quantity: 1}},
+		ShippingAddress: order.Address{Street: "Jl. Merdeka 1", City: "Jakarta", PostalCode: "10110", Country: "ID"},
+	}
+	_, err := manual.Handle(context.Background(), cmd)
+	assert.Equal(t, commands.ErrInvalidManualOrder.Code, apperror.From(err).Code, "overridden prices need a reason")
+	assert.Empty(t, users.users)
+
+	cmd.PriceReason = "Returning customer discount"
+	cmd.Payment = &commands.OfflinePaymentCmd{Reference: "EDC-0042"}
+	placed, err := manual.Handle(context.Background(), cmd)
+	require.NoError(t, err)
+	assert.True(t, placed.CustomerCreated)
+	o := placed.Order
+	assert.Equal(t, "admin-1", o.PlacedBy)
+	assert.Equal(t, order.ChannelPhone, o.Channel)
+	assert.Equal(t, 110000.0, o.TotalAmount, "p1 at the overridden price, p2 at its own")
+	assert.Equal(t, order.PaymentStatusPaid, o.PaymentStatus)
+	assert.Equal(t, order.StatusConfirmed, o.Status)
+	require.Len(t, payments.payments, 1)
+	assert.Equal(t, payment.MethodOffline, payments.payments[0].Method)
+	assert.Equal(t, "EDC-0042", payments.payments[0].TransactionID)
+
+	require.Len(t, audits.entries, 2)
+	assert.Equal(t, "order.place_for_customer", audits.entries[0].Action)
+	assert.Equal(t, audit.Change{Before: 50000.0, After: 45000.0}, audits.entries[0].Changes["items.p1.price"])
+	assert.NotContains(t, audits.entries[0].Changes, "items.p2.price")
+	assert.Equal(t, "payment.record_offline", audits.entries[1].Action)
+
+	// The same email finds the account opened for the first order
+	again, err := manual.Handle(context.Background(), commands.CreateManualOrderCommand{
+		ActorID:         "admin-1",
+		Customer:        &commands.ManualOrderCustomerCmd{Email: "budi@example.com", FirstName: "Budi"},
+		Channel:         order.ChannelInStore,
+		Items:           []commands.ManualOrderItemCmd{{ProductID: "p2", Quantity: 1}},
+		ShippingAddress: cmd.ShippingAddress,
+	})
+	require.NoError(t, err)
+	assert.False(t, again.CustomerCreated)
+	assert.Equal(t, o.UserID, again.Order.UserID)
+	assert.Nil(t, again.Payments)
+	assert.Equal(t, order.StatusPending, again.Order.Status)
+
+	pay := commands.RecordOfflinePaymentCommand{OrderID: again.Order.ID, ActorID: "admin-1", Amount: 30000, Reference: "TRF-1"}
+	_, err = offline.Handle(context.Background(), pay)
+	assert.Equal(t, commands.ErrInvalidOfflinePayment.Code, apperror.From(err).Code, "only 20000 is left to pay")
+
+	pay.Amount = 5000
+	summary, err := offline.Handle(context.Background(), pay)
+	require.NoError(t, err)
+	assert.Equal(t, 15000.0, summary.Outstanding)
+	assert.Equal(t, order.PaymentStatusPartiallyPaid, again.Order.PaymentStatus)
+}
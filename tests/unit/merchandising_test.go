@@ -0,0 +1,110 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/merchandising"
+	"online-shop/internal/domain/product"
+)
+
+func TestMerchandisingRuleCheck(t *testing.T) {
+	_, err := merchandising.NewRule("Sandals", merchandising.KindPin, "", "", []string{"p1"}, "", nil, 0, nil, nil, "admin")
+	assert.True(t, errors.Is(err, merchandising.ErrInvalidRule), "a pin needs a query or category")
+
+	_, err = merchandising.NewRule("Sandals", merchandising.KindPin, "sandals", "", []string{"p1", "p1"}, "", nil, 0, nil, nil, "admin")
+	assert.True(t, errors.Is(err, merchandising.ErrInvalidRule), "pinned twice")
+
+	_, err = merchandising.NewRule("Local", merchandising.KindBoost, "", "", nil, "origin", []string{"Bandung"}, 1, nil, nil, "admin")
+	assert.True(t, errors.Is(err, merchandising.ErrInvalidRule), "a weight of 1 changes nothing")
+
+	starts := time.Now()
+	ends := starts.Add(-time.Hour)
+	_, err = merchandising.NewRule("Local", merchandising.KindBoost, "", "", nil, "origin", []string{"Bandung"}, 2, &starts, &ends, "admin")
+	assert.True(t, errors.Is(err, merchandising.ErrInvalidRule), "ends before it starts")
+
+	rule, err := merchandising.NewRule("Sandals", merchandising.KindPin, "  Summer   SANDALS ", "", []string{"p1"}, "", nil, 0, nil, nil, "admin")
+	require.NoError(t, err)
+	assert.Equal(t, "summer sandals", rule.Query)
+	assert.True(t, rule.Matches("summer sandals", ""))
+	assert.True(t, rule.Matches("Summer Sandals", "c1"))
+	assert.False(t, rule.Matches("sandals", ""))
+}
+
+func TestMerchandisingResolve(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+	rules := []*merchandising.Rule{
+		{Name: "a", Kind: merchandising.KindPin, Query: "sandals", ProductIDs: []string{"p1", "p2"}, Active: true},
+		{Name: "b", Kind: merchandising.KindPin, Query: "sandals", ProductIDs: []string{"p2", "p3"}, Active: true},
+		{Name: "scheduled", Kind: merchandising.KindPin, Query: "sandals", ProductIDs: []string{"p9"}, Active: true, StartsAt: &later},
+		{Name: "inactive", Kind: merchandising.KindPin, Query: "sandals", ProductIDs: []string{"p8"}},
+		{Name: "bury", Kind: merchandising.KindBury, AttributeKey: "stock", AttributeValues: []string{"preorder"}, Weight: 4, Active: true},
+		{Name: "landing", Kind: merchandising.KindPin, CategoryID: "c1", ProductIDs: []string{"p5"}, Active: true},
+	}
+
+	ranking := merchandising.Resolve(rules, "Sandals", "", now)
+	assert.Equal(t, []string{"p1", "p2", "p3"}, ranking.Pinned)
+	require.Len(t, ranking.Boosts, 1)
+	assert.Equal(t, 0.25, ranking.Boosts[0].Factor)
+	assert.Equal(t, "stock", ranking.Boosts[0].Filter.Key)
+
+	ranking = merchandising.Resolve(rules, "", "c1", now)
+	assert.Equal(t, []string{"p5"}, ranking.Pinned, "the landing page of the category")
+
+	ranking = merchandising.Resolve(rules, "boots", "c1", now)
+	assert.Empty(t, ranking.Pinned, "a category rule without a query curates the landing page only")
+
+	ranking = merchandising.Resolve(rules, "sandals", "", later)
+	assert.Equal(t, []string{"p1", "p2", "p3", "p9"}, ranking.Pinned)
+}
+
+type memoryRuleRepo struct {
+	merchandising.Repository
+	rules []*merchandising.Rule
+}
+
+func (r *memoryRuleRepo) ListLive(ctx context.Context, at time.Time) ([]*merchandising.Rule, error) {
+	var live []*merchandising.Rule
+	for _, rule := range r.rules {
+		if rule.Live(at) {
+			live = append(live, rule)
+		}
+	}
+	return live, nil
+}
+
+type filterCapturingProductRepo struct {
+	product.Repository
+	filter product.SearchFilter
+}
+
+func (r *filterCapturingProductRepo) List(ctx context.Context, filter product.SearchFilter) ([]*product.Product, error) {
+	r.filter = filter
+	return nil, nil
+}
+
+func TestSearchProductsAppliesMerchandising(t *testing.T) {
+	rules := &memoryRuleRepo{rules: []*merchandising.Rule{
+		{Name: "a", Kind: merchandising.KindPin, Query: "sandals", ProductIDs: []string{"p1"}, Active: true},
+		{Name: "b", Kind: merchandising.KindBoost, AttributeKey: "brand", AttributeValues: []string{"local"}, Weight: 3, Active: true},
+	}}
+	products := &filterCapturingProductRepo{}
+	handler := queries.NewSearchProductsQueryHandler(products, queries.NewGetSearchRankingQueryHandler(rules))
+
+	_, err := handler.Handle(context.Background(), queries.SearchProductsQuery{Query: "sandals"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p1"}, products.filter.Ranking.Pinned)
+	require.Len(t, products.filter.Ranking.Boosts, 1)
+	assert.Equal(t, 3.0, products.filter.Ranking.Boosts[0].Factor)
+
+	_, err = handler.Handle(context.Background(), queries.SearchProductsQuery{Query: "boots"})
+	require.NoError(t, err)
+	assert.Empty(t, products.filter.Ranking.Pinned)
+}
@@ -0,0 +1,118 @@
+package unit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/moderation"
+	"online-shop/internal/domain/product"
+	"online-shop/pkg/apperror"
+)
+
+type memorySubmissionRepo struct {
+	moderation.Repository
+	submissions map[string]*moderation.Submission
+}
+
+func (r *memorySubmissionRepo) Create(ctx context.Context, s *moderation.Submission) error {
+	r.submissions[s.ID] = s
+	return nil
+}
+
+func (r *memorySubmissionRepo) Update(ctx context.Context, s *moderation.Submission) error {
+	r.submissions[s.ID] = s
+	return nil
+}
+
+func (r *memorySubmissionRepo) GetByID(ctx context.Context, id string) (*moderation.Submission, error) {
+	if s, ok := r.submissions[id]; ok {
+		return s, nil
+	}
+	return nil, moderation.ErrSubmissionNotFound
+}
+
+func (r *memorySubmissionRepo) Pending(ctx context.Context, productID string) (*moderation.Submission, error) {
+	for _, s := range r.submissions {
+		if s.ProductID == productID && s.Status == moderation.StatusPending {
+			return s, nil
+		}
+	}
+	return nil, moderation.ErrSubmissionNotFound
+}
+
+func TestMerchantProductWaitsForApproval(t *testing.T) {
+	ctx := context.Background()
+	products := &lifecycleProductRepo{products: map[string]*product.Product{}}
+	submissions := &memorySubmissionRepo{submissions: map[string]*moderation.Submission{}}
+	index := &syncIndex{}
+	cache := &syncCache{}
+	submit := commands.NewSubmitProductCommandHandler(products, attributeCategoryRepo{}, submissions)
+	review := commands.NewReviewProductSubmissionCommandHandler(submissions, products, attributeCategoryRepo{}, nil, index, cache)
+
+	s, err := submit.Handle(ctx, commands.SubmitProductCommand{MerchantID: "m1", Name: "Ultrabook", Price: 900, Stock: 4, CategoryID: "laptops"})
+	require.NoError(t, err)
+	assert.Equal(t, moderation.KindCreate, s.Kind)
+	p := products.products[s.ProductID]
+	require.NotNil(t, p)
+	assert.Equal(t, product.StatusPendingReview, p.Status)
+	assert.Equal(t, "ultrabook", p.Slug)
+
+	_, err = review.Handle(ctx, commands.ReviewProductSubmissionCommand{SubmissionID: s.ID, ReviewedBy: "admin"})
+	assert.Equal(t, "invalid_product_submission", apperror.From(err).Code, "rejecting needs a comment")
+	s, err = review.Handle(ctx, commands.ReviewProductSubmissionCommand{SubmissionID: s.ID, ReviewedBy: "admin", Comment: "Needs photos"})
+	require.NoError(t, err)
+	assert.Equal(t, moderation.StatusRejected, s.Status)
+	assert.Equal(t, product.StatusDraft, p.Status)
+	assert.Empty(t, index.indexed)
+
+	resubmitted, err := submit.Handle(ctx, commands.SubmitProductCommand{MerchantID: "m1", ProductID: p.ID, Name: "Ultrabook 14", Price: 900, CategoryID: "laptops", Images: []string{"https://cdn.example/u.jpg"}})
+	require.NoError(t, err)
+	assert.Equal(t, moderation.KindCreate, resubmitted.Kind)
+	assert.Equal(t, product.StatusPendingReview, p.Status)
+	assert.Equal(t, "Ultrabook 14", p.Name, "not live yet, so changed at once")
+
+	_, err = review.Handle(ctx, commands.ReviewProductSubmissionCommand{SubmissionID: resubmitted.ID, ReviewedBy: "admin", Approve: true})
+	require.NoError(t, err)
+	assert.Equal(t, product.StatusActive, p.Status)
+	assert.Equal(t, []string{p.ID}, index.indexed)
+	assert.Equal(t, []string{p.ID}, cache.invalidated)
+
+	_, err = review.Handle(ctx, commands.ReviewProductSubmissionCommand{SubmissionID: resubmitted.ID, ReviewedBy: "admin", Approve: true})
+	assert.Equal(t, "submission_already_reviewed", apperror.From(err).Code)
+}
+
+func TestLiveProductChangesWaitInTheSubmission(t *testing.T) {
+	ctx := context.Background()
+	products := &lifecycleProductRepo{products: map[string]*product.Product{
+		"p1": {ID: "p1", MerchantID: "m1", Name: "Grinder", Price: 100, CategoryID: "laptops", Status: product.StatusActive},
+	}}
+	submissions := &memorySubmissionRepo{submissions: map[string]*moderation.Submission{}}
+	submit := commands.NewSubmitProductCommandHandler(products, attributeCategoryRepo{}, submissions)
+	review := commands.NewReviewProductSubmissionCommandHandler(submissions, products, attributeCategoryRepo{}, nil, nil, nil)
+
+	_, err := submit.Handle(ctx, commands.SubmitProductCommand{MerchantID: "m2", ProductID: "p1", Name: "Mine", Price: 1, CategoryID: "laptops"})
+	assert.Equal(t, "product_not_found", apperror.From(err).Code, "another merchant's product")
+
+	first, err := submit.Handle(ctx, commands.SubmitProductCommand{MerchantID: "m1", ProductID: "p1", Name: "Burr Grinder", Price: 120, CategoryID: "laptops"})
+	require.NoError(t, err)
+	second, err := submit.Handle(ctx, commands.SubmitProductCommand{MerchantID: "m1", ProductID: "p1", Name: "Burr Grinder Pro", Price: 150, CategoryID: "laptops"})
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, second.ID, "resubmitting replaces the pending submission")
+	assert.Len(t, submissions.submissions, 1)
+	assert.Equal(t, moderation.KindUpdate, second.Kind)
+	assert.Equal(t, "Grinder", products.products["p1"].Name, "live until approved")
+
+	_, err = submit.Handle(ctx, commands.SubmitProductCommand{MerchantID: "m1", ProductID: "p1", Name: "Burr Grinder", Price: 120, CategoryID: "kettles"})
+	assert.Equal(t, "category_not_found", apperror.From(err).Code)
+
+	_, err = review.Handle(ctx, commands.ReviewProductSubmissionCommand{SubmissionID: second.ID, ReviewedBy: "admin", Approve: true, Comment: "Looks good"})
+	require.NoError(t, err)
+	p := products.products["p1"]
+	assert.Equal(t, "Burr Grinder Pro", p.Name)
+	assert.Equal(t, 150.0, p.Price)
+	assert.Equal(t, product.StatusActive, p.Status)
+}
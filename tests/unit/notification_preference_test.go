@@ -0,0 +1,206 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/notification"
+	"online-shop/internal/domain/user"
+	"online-shop/pkg/apperror"
+)
+
+type memoryPreferenceRepo struct {
+	prefs map[string]*notification.Preference
+}
+
+func newMemoryPreferenceRepo() *memoryPreferenceRepo {
+	return &memoryPreferenceRepo{prefs: map[string]*notification.Preference{}}
+}
+
+func (r *memoryPreferenceRepo) key(userID string, category notification.Category, channel user.Channel) string {
+	return userID + "/" + string(category) + "/" + string(channel)
+}
+
+func (r *memoryPreferenceRepo) List(ctx context.Context, userID string) ([]*notification.Preference, error) {
+	var prefs []*notification.Preference
+	for _, p := range r.prefs {
+		if p.UserID == userID {
+			prefs = append(prefs, p)
+		}
+	}
+	return prefs, nil
+}
+
+func (r *memoryPreferenceRepo) Save(ctx context.Context, prefs []*notification.Preference) error {
+	for _, p := range prefs {
+		r.prefs[r.key(p.UserID, p.Category, p.Channel)] = p
+	}
+	return nil
+}
+
+func (r *memoryPreferenceRepo) Enabled(ctx context.Context, userID string, category notification.Category, channel user.Channel) (bool, error) {
+	p, ok := r.prefs[r.key(userID, category, channel)]
+	return !ok || p.Enabled, nil
+}
+
+// EmailEnabled knows no addresses, which are enabled
+func (r *memoryPreferenceRepo) EmailEnabled(ctx context.Context, email string, category notification.Category) (bool, error) {
+	return true, nil
+}
+
+// preferenceUserRepoStub finds the users of deletionUserRepoStub by email too
+type preferenceUserRepoStub struct {
+	*deletionUserRepoStub
+}
+
+func (r *preferenceUserRepoStub) GetByEmail(ctx context.Context, email string) (*user.User, error) {
+	for _, u := range r.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return nil, errors.New("record not found")
+}
+
+func preferenceCustomer(t *testing.T) (*user.User, *preferenceUserRepoStub) {
+	u, err := user.NewUser("jane@example.com", "secret123", "Jane", "Doe", "")
+	require.NoError(t, err)
+	return u, &preferenceUserRepoStub{&deletionUserRepoStub{users: map[string]*user.User{u.ID: u}}}
+}
+
+func settingsOf(settings []notification.Settings, category notification.Category) notification.Settings {
+	for _, s := range settings {
+		if s.Category == category {
+			return s
+		}
+	}
+	return notification.Settings{}
+}
+
+func TestNotificationPolicyPerCategory(t *testing.T) {
+	u, _ := preferenceCustomer(t)
+	prefs := newMemoryPreferenceRepo()
+	policy := notification.NewPolicy(&memoryConsentRepo{users: []*user.User{u}}, prefs)
+	ctx := context.Background()
+
+	allowed, err := policy.Allowed(ctx, u.ID, notification.CategoryMarketing, user.ChannelSMS)
+	require.NoError(t, err)
+	assert.False(t, allowed, "marketing needs consent")
+
+	require.NoError(t, u.SetMarketingConsent(user.ChannelSMS, true, time.Now()))
+	allowed, err = policy.Allowed(ctx, u.ID, notification.CategoryMarketing, user.ChannelSMS)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	allowed, err = policy.Allowed(ctx, u.ID, notification.CategoryAlerts, user.ChannelPush)
+	require.NoError(t, err)
+	assert.True(t, allowed, "alerts are on until turned off")
+
+	require.NoError(t, prefs.Save(ctx, []*notification.Preference{{UserID: u.ID, Category: notification.CategoryAlerts, Channel: user.ChannelPush}}))
+	allowed, err = policy.Allowed(ctx, u.ID, notification.CategoryAlerts, user.ChannelPush)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	allowed, err = policy.Allowed(ctx, u.ID, notification.CategoryAccount, user.ChannelPush)
+	require.NoError(t, err)
+	assert.True(t, allowed, "account notices are always sent")
+
+	allowed, err = policy.AllowedEmail(ctx, "JANE@example.com", notification.CategoryMarketing)
+	require.NoError(t, err)
+	assert.False(t, allowed, "no email consent")
+}
+
+func TestUpdateNotificationPreferences(t *testing.T) {
+	u, users := preferenceCustomer(t)
+	prefs := newMemoryPreferenceRepo()
+	handler := commands.NewUpdateNotificationPreferencesCommandHandler(users, prefs)
+
+	settings, err := handler.Handle(context.Background(), commands.UpdateNotificationPreferencesCommand{
+		UserID: u.ID,
+		Preferences: map[string]map[string]bool{
+			"orders":    {"sms": false},
+			"marketing": {"email": true},
+		},
+	})
+	require.NoError(t, err)
+	orders := settingsOf(settings, notification.CategoryOrders)
+	assert.False(t, orders.Channels[user.ChannelSMS])
+	assert.True(t, orders.Channels[user.ChannelEmail], "left out channels keep their choice")
+	marketing := settingsOf(settings, notification.CategoryMarketing)
+	assert.True(t, marketing.Channels[user.ChannelEmail])
+	assert.False(t, marketing.Channels[user.ChannelPush])
+	assert.True(t, u.MarketingConsent.Granted(user.ChannelEmail), "marketing is the marketing consent")
+	assert.True(t, settingsOf(settings, notification.CategoryAccount).Required)
+
+	_, err = handler.Handle(context.Background(), commands.UpdateNotificationPreferencesCommand{
+		UserID:      u.ID,
+		Preferences: map[string]map[string]bool{"account": {"email": false}},
+	})
+	assert.Equal(t, "invalid_notification_preference", apperror.From(err).Code)
+
+	_, err = handler.Handle(context.Background(), commands.UpdateNotificationPreferencesCommand{
+		UserID:      u.ID,
+		Preferences: map[string]map[string]bool{"orders": {"fax": false}},
+	})
+	assert.Equal(t, "invalid_notification_preference", apperror.From(err).Code)
+}
+
+func TestUnsubscribeLinks(t *testing.T) {
+	links := notification.NewLinks("https://api.example.com/", "", "secret")
+
+	url := links.URL("jane@example.com", notification.CategoryMarketing)
+	require.Contains(t, url, "https://api.example.com"+notification.UnsubscribePath)
+	token := url[len("https://api.example.com"+notification.UnsubscribePath):]
+
+	link, err := links.Parse(token)
+	require.NoError(t, err)
+	assert.Equal(t, notification.Unsubscribe{Email: "jane@example.com", Category: notification.CategoryMarketing}, link)
+
+	headers := links.Headers("jane@example.com", notification.CategoryMarketing)
+	assert.Equal(t, "<"+url+">", headers["List-Unsubscribe"])
+	assert.Equal(t, "List-Unsubscribe=One-Click", headers["List-Unsubscribe-Post"])
+
+	_, err = links.Parse(token[:len(token)-2] + "xx")
+	assert.ErrorIs(t, err, notification.ErrInvalidUnsubscribe)
+	_, err = notification.NewLinks("https://api.example.com", "", "other").Parse(token)
+	assert.ErrorIs(t, err, notification.ErrInvalidUnsubscribe)
+	_, err = notification.NewLinks("https://api.example.com", "", "").Parse(token)
+	assert.ErrorIs(t, err, notification.ErrInvalidUnsubscribe, "nothing verifies without a secret")
+
+	page := notification.NewLinks("https://api.example.com", "https://shop.example.com/unsubscribe", "secret")
+	assert.Contains(t, page.URL("jane@example.com", notification.CategoryAlerts), "https://shop.example.com/unsubscribe?token=")
+}
+
+func TestUnsubscribeCommand(t *testing.T) {
+	u, users := preferenceCustomer(t)
+	require.NoError(t, u.SetMarketingConsent(user.ChannelEmail, true, time.Now()))
+	prefs := newMemoryPreferenceRepo()
+	links := notification.NewLinks("https://api.example.com", "", "secret")
+	handler := commands.NewUnsubscribeCommandHandler(users, prefs, links)
+	token := func(category notification.Category) string {
+		return links.URL(u.Email, category)[len("https://api.example.com"+notification.UnsubscribePath):]
+	}
+
+	link, err := handler.Handle(context.Background(), commands.UnsubscribeCommand{Token: token(notification.CategoryMarketing)})
+	require.NoError(t, err)
+	assert.Equal(t, notification.CategoryMarketing, link.Category)
+	assert.False(t, u.MarketingConsent.Granted(user.ChannelEmail))
+
+	_, err = handler.Handle(context.Background(), commands.UnsubscribeCommand{Token: token(notification.CategoryAlerts)})
+	require.NoError(t, err)
+	enabled, err := prefs.Enabled(context.Background(), u.ID, notification.CategoryAlerts, user.ChannelEmail)
+	require.NoError(t, err)
+	assert.False(t, enabled)
+	enabled, err = prefs.Enabled(context.Background(), u.ID, notification.CategoryAlerts, user.ChannelPush)
+	require.NoError(t, err)
+	assert.True(t, enabled, "only email is turned off")
+
+	_, err = handler.Handle(context.Background(), commands.UnsubscribeCommand{Token: "not-a-token"})
+	assert.Equal(t, "invalid_unsubscribe_link", apperror.From(err).Code)
+}
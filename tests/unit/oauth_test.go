@@ -0,0 +1,316 @@
+package unit
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	jwtlib "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/oauth"
+	"online-shop/internal/domain/user"
+	oauthprovider "online-shop/internal/infrastructure/oauth"
+)
+
+type oauthProviderStub struct {
+	identity *oauth.Identity
+}
+
+func (p *oauthProviderStub) Name() string {
+	return "google"
+}
+
+func (p *oauthProviderStub) AuthCodeURL(state *oauth.State) string {
+	return "https://accounts.example.com/auth?state=" + state.ID
+}
+
+func (p *oauthProviderStub) Exchange(ctx context.Context, code string, state *oauth.State) (*oauth.Identity, error) {
+	return p.identity, nil
+}
+
+type oauthStateStoreStub struct {
+	states map[string]*oauth.State
+}
+
+func (s *oauthStateStoreStub) Save(ctx context.Context, state *oauth.State, ttl time.Duration) error {
+	s.states[state.ID] = state
+	return nil
+}
+
+func (s *oauthStateStoreStub) Get(ctx context.Context, id string) (*oauth.State, error) {
+	state, ok := s.states[id]
+	if !ok {
+		return nil, oauth.ErrInvalidState
+	}
+	return state, nil
+}
+
+func (s *oauthStateStoreStub) Take(ctx context.Context, id string) (*oauth.State, error) {
+	state, ok := s.states[id]
+	if !ok {
+		return nil, oauth.ErrInvalidState
+	}
+	delete(s.states, id)
+	return state, nil
+}
+
+type oauthAccountRepoStub struct {
+	accounts []*oauth.Account
+}
+
+func (r *oauthAccountRepoStub) Create(ctx context.Context, account *oauth.Account) error {
+	r.accounts = append(r.accounts, account)
+	return nil
+}
+
+func (r *oauthAccountRepoStub) GetByProviderSubject(ctx context.Context, provider, subject string) (*oauth.Account, error) {
+	for _, a := range r.accounts {
+		if a.Provider == provider && a.Subject == subject {
+			return a, nil
+		}
+	}
+	return nil, errors.New("record not found")
+}
+
+type oauthUserRepoStub struct {
+	user.Repository
+	users map[string]*user.User
+}
+
+func (r *oauthUserRepoStub) Create(ctx context.Context, u *user.User) error {
+	r.users[u.ID] = u
+	return nil
+}
+
+func (r *oauthUserRepoStub) GetByID(ctx context.Context, id string) (*user.User, error) {
+	if u, ok := r.users[id]; ok {
+		return u, nil
+	}
+	return nil, errors.New("record not found")
+}
+
+func (r *oauthUserRepoStub) GetByEmail(ctx context.Context, email string) (*user.User, error) {
+	for _, u := range r.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return nil, errors.New("record not found")
+}
+
+type oauthFixture struct {
+	provider *oauthProviderStub
+	states   *oauthStateStoreStub
+	accounts *oauthAccountRepoStub
+	users    *oauthUserRepoStub
+	start    *commands.StartOAuthLoginCommandHandler
+	complete *commands.CompleteOAuthLoginCommandHandler
+}
+
+func newOAuthFixture(identity *oauth.Identity) *oauthFixture {
+	f := &oauthFixture{
+		provider: &oauthProviderStub{identity: identity},
+		states:   &oauthStateStoreStub{states: map[string]*oauth.State{}},
+		accounts: &oauthAccountRepoStub{},
+		users:    &oauthUserRepoStub{users: map[string]*user.User{}},
+	}
+	providers := map[string]oauth.Provider{"google": f.provider}
+	f.start = commands.NewStartOAuthLoginCommandHandler(providers, f.states, time.Minute)
+	f.complete = commands.NewCompleteOAuthLoginCommandHandler(providers, f.states, f.accounts, f.users)
+	return f
+}
+
+// login runs the start step and returns the callback command for its state
+func (f *oauthFixture) login(t *testing.T) commands.CompleteOAuthLoginCommand {
+	start, err := f.start.Handle(context.Background(), commands.StartOAuthLoginCommand{Provider: "google"})
+	require.NoError(t, err)
+	parsed, err := url.Parse(start.URL)
+	require.NoError(t, err)
+	return commands.CompleteOAuthLoginCommand{Provider: "google", Code: "code", State: parsed.Query().Get("state"), Binding: start.Binding}
+}
+
+func googleIdentity(verified bool) *oauth.Identity {
+	return &oauth.Identity{
+		Provider:      "google",
+		Subject:       "sub-1",
+		Email:         "jane@example.com",
+		EmailVerified: verified,
+		FirstName:     "Jane",
+		LastName:      "Doe",
+	}
+}
+
+func TestOAuthLoginCreatesUser(t *testing.T) {
+	f := newOAuthFixture(googleIdentity(true))
+
+	result, err := f.complete.Handle(context.Background(), f.login(t))
+	require.NoError(t, err)
+
+	assert.True(t, result.Created)
+	assert.Equal(t, "jane@example.com", result.User.Email)
+	assert.Equal(t, user.RoleCustomer, result.User.Role)
+	assert.Error(t, result.User.ValidatePassword(""), "password login stays closed")
+	require.Len(t, f.accounts.accounts, 1)
+	assert.Equal(t, result.User.ID, f.accounts.accounts[0].UserID)
+
+	// Signing in again uses the linked account
+	again, err := f.complete.Handle(context.Background(), f.login(t))
+	require.NoError(t, err)
+	assert.False(t, again.Created)
+	assert.Equal(t, result.User.ID, again.User.ID)
+	assert.Len(t, f.accounts.accounts, 1)
+}
+
+func TestOAuthLoginLinksExistingEmail(t *testing.T) {
+	f := newOAuthFixture(googleIdentity(true))
+	existing, err := user.NewUser("jane@example.com", "secret123", "Jane", "Doe", "")
+	require.NoError(t, err)
+	f.users.users[existing.ID] = existing
+
+	result, err := f.complete.Handle(context.Background(), f.login(t))
+	require.NoError(t, err)
+
+	assert.True(t, result.Linked)
+	assert.Equal(t, existing.ID, result.User.ID)
+	assert.Len(t, f.users.users, 1)
+}
+
+func TestOAuthLoginRejectsUnverifiedEmail(t *testing.T) {
+	f := newOAuthFixture(googleIdentity(false))
+	existing, err := user.NewUser("jane@example.com", "secret123", "Jane", "Doe", "")
+	require.NoError(t, err)
+	f.users.users[existing.ID] = existing
+
+	_, err = f.complete.Handle(context.Background(), f.login(t))
+	assert.ErrorIs(t, err, commands.ErrOAuthEmailUnverified)
+	assert.Empty(t, f.accounts.accounts)
+}
+
+func TestOAuthLoginStateIsSingleUse(t *testing.T) {
+	f := newOAuthFixture(googleIdentity(true))
+	cmd := f.login(t)
+
+	_, err := f.complete.Handle(context.Background(), cmd)
+	require.NoError(t, err)
+
+	_, err = f.complete.Handle(context.Background(), cmd)
+	assert.ErrorIs(t, err, oauth.ErrInvalidState)
+}
+
+func TestOAuthLoginIsBoundToTheStartingBrowser(t *testing.T) {
+	f := newOAuthFixture(googleIdentity(true))
+	cmd := f.login(t)
+
+	// A callback forged into another browser carries no binding, or another
+	// login's
+	forged := cmd
+	forged.Binding = ""
+	_, err := f.complete.Handle(context.Background(), forged)
+	assert.ErrorIs(t, err, oauth.ErrInvalidState)
+	forged.Binding = f.login(t).Binding
+	_, err = f.complete.Handle(context.Background(), forged)
+	assert.ErrorIs(t, err, oauth.ErrInvalidState)
+	// nor can it be worked out from the state in the callback URL
+	sum := sha256.Sum256([]byte("oauth-state:" + cmd.State))
+	forged.Binding = base64.RawURLEncoding.EncodeToString(sum[:])
+	_, err = f.complete.Handle(context.Background(), forged)
+	assert.ErrorIs(t, err, oauth.ErrInvalidState)
+
+	_, err = f.complete.Handle(context.Background(), cmd)
+	assert.NoError(t, err, "the forgeries did not spend the state")
+}
+
+func TestOAuthLoginUnknownProvider(t *testing.T) {
+	f := newOAuthFixture(googleIdentity(true))
+
+	_, err := f.start.Handle(context.Background(), commands.StartOAuthLoginCommand{Provider: "myspace"})
+	assert.ErrorIs(t, err, oauth.ErrUnknownProvider)
+}
+
+func TestOIDCProviderVerifiesIDToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var nonce string
+	sign := func(claims jwtlib.MapClaims) string {
+		token := jwtlib.NewWithClaims(jwtlib.SigningMethodRS256, claims)
+		token.Header["kid"] = "k1"
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+		return signed
+	}
+	idToken := func() string {
+		return sign(jwtlib.MapClaims{
+			"iss":            "https://issuer.example.com",
+			"aud":            "client-1",
+			"sub":            "sub-1",
+			"exp":            time.Now().Add(time.Hour).Unix(),
+			"nonce":          nonce,
+			"email":          "Jane@Example.com",
+			"email_verified": "true",
+		})
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "the-code", r.PostForm.Get("code"))
+		assert.NotEmpty(t, r.PostForm.Get("code_verifier"))
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": idToken()})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kid": "k1",
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	provider := oauthprovider.NewOIDCProvider(oauthprovider.OIDCConfig{
+		Name:         "google",
+		ClientID:     "client-1",
+		ClientSecret: func() (string, error) { return "secret", nil },
+		RedirectURL:  "https://shop.example.com/callback",
+		AuthURL:      server.URL + "/auth",
+		TokenURL:     server.URL + "/token",
+		JWKSURL:      server.URL + "/jwks",
+		Issuers:      []string{"https://issuer.example.com"},
+		Scopes:       []string{"openid", "email"},
+		UsePKCE:      true,
+	})
+
+	state, err := oauth.NewState("google")
+	require.NoError(t, err)
+	nonce = state.Nonce
+
+	authURL, err := url.Parse(provider.AuthCodeURL(state))
+	require.NoError(t, err)
+	assert.Equal(t, state.CodeChallenge(), authURL.Query().Get("code_challenge"))
+
+	identity, err := provider.Exchange(context.Background(), "the-code", state)
+	require.NoError(t, err)
+	assert.Equal(t, "sub-1", identity.Subject)
+	assert.Equal(t, "jane@example.com", identity.Email)
+	assert.True(t, identity.EmailVerified)
+
+	// A token minted for another login is rejected
+	nonce = "someone-else"
+	_, err = provider.Exchange(context.Background(), "the-code", state)
+	assert.Error(t, err)
+}
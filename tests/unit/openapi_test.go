@@ -0,0 +1,341 @@
+package unit
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/user"
+	"online-shop/internal/interfaces/http/handlers"
+	"online-shop/internal/interfaces/http/middleware"
+	"online-shop/pkg/openapi"
+)
+
+// serverRoute is a route registered in cmd/api/main.go
+type serverRoute struct {
+	method     string
+	path       string
+	auth       bool
+	optional   bool
+	idempotent bool
+}
+
+type routeGroup struct {
+	prefix   string
+	auth     bool
+	optional bool
+}
+
+// serverRoutes reads the routes main.go registers, following the groups
+// they are registered on and the middleware of the groups and routes.
+func serverRoutes(t *testing.T) []serverRoute {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "../../cmd/api/main.go", nil, 0)
+	require.NoError(t, err)
+
+	groups := map[string]*routeGroup{"r": {}}
+	var routes []serverRoute
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if len(n.Lhs) != 1 || len(n.Rhs) != 1 {
+				return true
+			}
+			call, recv, name := routeCall(n.Rhs[0])
+			if name != "Group" || groups[recv] == nil {
+				return true
+			}
+			parent := groups[recv]
+			g := &routeGroup{
+				prefix:   parent.prefix + routePath(t, call.Args[0]),
+				auth:     parent.auth || calls(call.Args[1:], "RequireAuth"),
+				optional: parent.optional || calls(call.Args[1:], "OptionalAuth"),
+			}
+			groups[n.Lhs[0].(*ast.Ident).Name] = g
+		case *ast.CallExpr:
+			call, recv, name := routeCall(n)
+			g := groups[recv]
+			if g == nil {
+				return true
+			}
+			switch name {
+			case "Use":
+				g.auth = g.auth || calls(call.Args, "RequireAuth")
+			case "GET", "POST", "PUT", "PATCH", "DELETE":
+				routes = append(routes, serverRoute{
+					method:     name,
+					path:       g.prefix + routePath(t, call.Args[0]),
+					auth:       g.auth || calls(call.Args[1:], "RequireAuth"),
+					optional:   g.optional || calls(call.Args[1:], "OptionalAuth"),
+					idempotent: calls(call.Args[1:], "Idempotency"),
+				})
+			}
+		}
+		return true
+	})
+	return routes
+}
+
+// routeCall splits recv.name(...)
+func routeCall(expr ast.Expr) (*ast.CallExpr, string, string) {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return nil, "", ""
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return nil, "", ""
+	}
+	recv, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return nil, "", ""
+	}
+	return call, recv.Name, sel.Sel.Name
+}
+
+func routePath(t *testing.T, expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		path, err := strconv.Unquote(e.Value)
+		require.NoError(t, err)
+		return path
+	case *ast.SelectorExpr:
+		if e.Sel.Name == "OpenAPIPath" {
+			return handlers.OpenAPIPath
+		}
+	}
+	t.Fatalf("route path %T is not a literal", expr)
+	return ""
+}
+
+// calls reports whether any of the arguments is a call of a function or
+// method with the name
+func calls(args []ast.Expr, name string) bool {
+	for _, arg := range args {
+		call, ok := arg.(*ast.CallExpr)
+		if !ok {
+			continue
+		}
+		switch fun := call.Fun.(type) {
+		case *ast.SelectorExpr:
+			if fun.Sel.Name == name {
+				return true
+			}
+		case *ast.Ident:
+			if fun.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestOpenAPIMatchesServerRoutes(t *testing.T) {
+	routes := serverRoutes(t)
+	require.NotEmpty(t, routes)
+	doc := handlers.OpenAPI()
+
+	registered := make(map[string]bool)
+	for _, route := range routes {
+		if route.path == "/docs/*any" {
+			continue
+		}
+		path, _ := openapi.Path(route.path)
+		key := route.method + " " + path
+		registered[key] = true
+
+		op := doc.Paths[path][strings.ToLower(route.method)]
+		if !assert.NotNil(t, op, "%s is not documented", key) {
+			continue
+		}
+
+		switch {
+		case route.auth:
+			assert.Equal(t, []openapi.SecurityRequirement{{"bearerAuth": {}}}, op.Security, "%s requires a token", key)
+			assert.Contains(t, op.Responses, "401", key)
+		case route.optional:
+			assert.Contains(t, op.Security, openapi.SecurityRequirement{}, key)
+			assert.Contains(t, op.Security, openapi.SecurityRequirement{"bearerAuth": {}}, key)
+		default:
+			assert.Empty(t, op.Security, "%s is public", key)
+		}
+
+		var idempotencyKey bool
+		for _, p := range op.Parameters {
+			idempotencyKey = idempotencyKey || (p.In == openapi.InHeader && p.Name == middleware.IdempotencyKeyHeader)
+		}
+		assert.Equal(t, route.idempotent, idempotencyKey, "%s Idempotency-Key", key)
+	}
+
+	for path, item := range doc.Paths {
+		for method := range item {
+			key := strings.ToUpper(method) + " " + path
+			assert.True(t, registered[key], "%s is documented but not served", key)
+		}
+	}
+}
+
+func TestOpenAPIDocumentIsConsistent(t *testing.T) {
+	doc := handlers.OpenAPI()
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+	require.Contains(t, doc.Components.SecuritySchemes, "bearerAuth")
+
+	ids := make(map[string]string)
+	for path, item := range doc.Paths {
+		var templated []string
+		for _, segment := range strings.Split(path, "/") {
+			if strings.HasPrefix(segment, "{") {
+				templated = append(templated, strings.Trim(segment, "{}"))
+			}
+		}
+		for method, op := range item {
+			key := method + " " + path
+			if other, ok := ids[op.OperationID]; ok {
+				t.Errorf("operationId %s of %s is also used by %s", op.OperationID, key, other)
+			}
+			ids[op.OperationID] = key
+
+			var params []string
+			for _, p := range op.Parameters {
+				if p.In == openapi.InPath {
+					assert.True(t, p.Required, key)
+					params = append(params, p.Name)
+				}
+			}
+			assert.Equal(t, templated, params, "path parameters of %s", key)
+			assert.Contains(t, op.Responses, "default", key)
+		}
+	}
+
+	// Every reference resolves
+	raw, err := json.Marshal(doc)
+	require.NoError(t, err)
+	var tree interface{}
+	require.NoError(t, json.Unmarshal(raw, &tree))
+	var refs []string
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for key, child := range v {
+				if ref, ok := child.(string); ok && key == "$ref" {
+					refs = append(refs, ref)
+				}
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(tree)
+	require.NotEmpty(t, refs)
+	for _, ref := range refs {
+		switch {
+		case strings.HasPrefix(ref, openapi.SchemaRef("")):
+			assert.Contains(t, doc.Components.Schemas, strings.TrimPrefix(ref, openapi.SchemaRef("")), ref)
+		case strings.HasPrefix(ref, openapi.ResponseRef("")):
+			assert.Contains(t, doc.Components.Responses, strings.TrimPrefix(ref, openapi.ResponseRef("")), ref)
+		default:
+			t.Errorf("unexpected reference %s", ref)
+		}
+	}
+}
+
+func TestOpenAPIErrorModel(t *testing.T) {
+	doc := handlers.OpenAPI()
+	problem := doc.Components.Schemas["apperror.Problem"]
+	require.NotNil(t, problem)
+	assert.Contains(t, problem.Properties["code"].Enum, "validation_failed")
+	assert.Contains(t, problem.Properties["code"].Enum, commands.ErrProductNotFound.Code)
+	assert.Equal(t, openapi.SchemaRef("apperror.FieldError"), problem.Properties["fields"].Items.Ref)
+
+	op := doc.Paths["/api/v1/orders"]["post"]
+	require.NotNil(t, op)
+	assert.Equal(t, openapi.ResponseRef("BadRequest"), op.Responses["400"].Ref)
+	assert.Contains(t, doc.Components.Responses["BadRequest"].Content, "application/problem+json")
+}
+
+func TestOpenAPIRequestSchemaFollowsValidation(t *testing.T) {
+	doc := handlers.OpenAPI()
+	body := doc.Paths["/api/v1/orders"]["post"].RequestBody
+	require.NotNil(t, body)
+	assert.True(t, body.Required)
+
+	create := doc.Components.Schemas["commands.CreateOrderCommand"]
+	require.NotNil(t, create)
+	// The customer comes from the token, not the body
+	assert.NotContains(t, create.Properties, "user_id")
+	assert.ElementsMatch(t, []string{"items", "shipping_address"}, create.Required)
+	items := create.Properties["items"]
+	assert.Equal(t, 1, *items.MinItems)
+	assert.Equal(t, 100, *items.MaxItems)
+
+	item := doc.Components.Schemas["commands.CreateOrderItemCmd"]
+	require.NotNil(t, item)
+	assert.Equal(t, 1.0, *item.Properties["quantity"].Minimum)
+	assert.Equal(t, 1000.0, *item.Properties["quantity"].Maximum)
+
+	register := doc.Components.Schemas["commands.RegisterUserCommand"]
+	require.NotNil(t, register)
+	assert.Equal(t, "email", register.Properties["email"].Format)
+	assert.Equal(t, 8, *register.Properties["password"].MinLength)
+	assert.Equal(t, 72, *register.Properties["password"].MaxLength)
+
+	cancel := doc.Components.Schemas["handlers.CancelOrderRequest"]
+	require.NotNil(t, cancel)
+	assert.Contains(t, cancel.Properties["reason"].Enum, "changed_mind")
+	assert.NotContains(t, cancel.Properties["reason"].Enum, "fraud_declined", "the shop's reasons are not the customer's to give")
+	assert.False(t, doc.Paths["/api/v1/orders/{id}/cancel"]["put"].RequestBody.Required)
+}
+
+// TestOpenAPIResponseSchemaMatchesEncoding checks the generated schemas list
+// exactly the members the handlers' responses are encoded with.
+func TestOpenAPIResponseSchemaMatchesEncoding(t *testing.T) {
+	doc := handlers.OpenAPI()
+	cases := map[string]interface{}{
+		"handlers.AuthResponse": handlers.AuthResponse{User: &user.User{}},
+		"handlers.OAuthLogin":   handlers.OAuthLogin{},
+		"handlers.SessionList":  handlers.SessionList{},
+		"user.User":             user.User{},
+	}
+	for name, v := range cases {
+		schema := doc.Components.Schemas[name]
+		if !assert.NotNil(t, schema, name) {
+			continue
+		}
+		raw, err := json.Marshal(v)
+		require.NoError(t, err)
+		var encoded map[string]interface{}
+		require.NoError(t, json.Unmarshal(raw, &encoded))
+
+		// Members left out when empty are documented but not encoded here
+		for key := range encoded {
+			assert.Contains(t, schema.Properties, key, name)
+		}
+	}
+
+	list := doc.Paths["/api/v2/orders"]["get"].Responses["200"].Content["application/json"].Schema
+	assert.Equal(t, openapi.SchemaRef("pagination.Meta"), list.Properties["meta"].Ref)
+	assert.Equal(t, openapi.SchemaRef("apiv2.Order"), list.Properties["data"].Items.Ref)
+}
+
+func TestOpenAPIPath(t *testing.T) {
+	path, params := openapi.Path("/api/v1/orders/:id/payments/:paymentId/pay")
+	assert.Equal(t, "/api/v1/orders/{id}/payments/{paymentId}/pay", path)
+	assert.Equal(t, []string{"id", "paymentId"}, params)
+
+	path, params = openapi.Path("/downloads/*key")
+	assert.Equal(t, "/downloads/{key}", path)
+	assert.Equal(t, []string{"key"}, params)
+}
@@ -0,0 +1,184 @@
+package unit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/payment"
+	"online-shop/internal/domain/product"
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/config"
+)
+
+// refundProviderStub answers a refund asked for again with the same key
+// with the first one, as Midtrans does
+type refundProviderStub struct {
+	payment.PaymentProvider
+	refunds map[string]float64
+	keys    map[string]bool
+	decline string
+}
+
+func (p *refundProviderStub) RefundPayment(ctx context.Context, externalID string, amount float64, key string) error {
+	if externalID == p.decline {
+		return fmt.Errorf("%w: transaction is not settled", payment.ErrRefundDeclined)
+	}
+	if p.keys[key] {
+		return nil
+	}
+	p.keys[key] = true
+	p.refunds[externalID] += amount
+	return nil
+}
+
+func shippedOrder() *order.Order {
+	return &order.Order{
+		ID:            "o1",
+		UserID:        "u1",
+		TotalAmount:   100000,
+		Status:        order.StatusShipped,
+		PaymentStatus: order.PaymentStatusPaid,
+		Items:         []order.OrderItem{{ID: "i1", ProductID: "p1", Quantity: 2, Price: 50000, Subtotal: 100000}},
+		Shipments:     []order.Shipment{{ID: "s1", Status: order.ShipmentStatusShipped}},
+	}
+}
+
+func paidPayment(id string, amount float64) *payment.Payment {
+	pay := payment.NewPayment("o1", "u1", amount, payment.MethodCreditCard)
+	pay.ID = id
+	pay.ExternalID = "ext-" + id
+	pay.MarkAsPaid("trx-" + id)
+	return pay
+}
+
+func TestCancellationPolicyFee(t *testing.T) {
+	policy := order.CancellationPolicy{AfterShipment: true, FlatFee: 10000, FeeRate: 0.05}
+
+	o := shippedOrder()
+	o.Status = order.StatusProcessing
+	o.Shipments[0].Status = order.ShipmentStatusPacked
+	fee, err := policy.Fee(o)
+	require.NoError(t, err)
+	assert.Zero(t, fee, "cancelling is free until the order ships")
+
+	o.Shipments = append(o.Shipments, order.Shipment{ID: "s2", Status: order.ShipmentStatusShipped})
+	fee, err = policy.Fee(o)
+	require.NoError(t, err)
+	assert.Equal(t, 15000.0, fee, "one shipment leaving is enough for the fee")
+
+	o.TotalAmount = 5000
+	fee, _ = policy.Fee(o)
+	assert.Equal(t, 5000.0, fee, "the fee is capped at the total")
+
+	_, err = order.CancellationPolicy{}.Fee(shippedOrder())
+	assert.ErrorIs(t, err, order.ErrNotCancellable)
+
+	o = shippedOrder()
+	o.Shipments = append(o.Shipments, order.Shipment{ID: "s2", Status: order.ShipmentStatusDelivered})
+	_, err = policy.Fee(o)
+	assert.ErrorIs(t, err, order.ErrNotCancellable, "a delivered parcel is returned, not cancelled")
+
+	o.Status = order.StatusDelivered
+	_, err = policy.Fee(o)
+	assert.ErrorIs(t, err, order.ErrNotCancellable)
+}
+
+func TestCancelShippedOrderRefundsLessFee(t *testing.T) {
+	o := shippedOrder()
+	orders := &codOrderRepo{order: o}
+	products := &flashProductRepo{products: map[string]*product.Product{"p1": {ID: "p1", Stock: 3}}}
+	payments := &paymentRepoStub{payments: []*payment.Payment{paidPayment("a", 60000), paidPayment("b", 40000)}}
+	provider := &refundProviderStub{refunds: map[string]float64{}, keys: map[string]bool{}, decline: "ext-b"}
+	policy := order.CancellationPolicy{AfterShipment: true, FlatFee: 10000}
+	cancel := commands.NewCancelOrderCommandHandler(orders, products, nil, newMemoryFlashCounter(), payments, provider, policy, nil)
+
+	assert.ErrorIs(t, cancel.Handle(context.Background(), commands.CancelOrderCommand{OrderID: "o1", UserID: "u2"}), commands.ErrForbidden)
+
+	cmd := commands.CancelOrderCommand{OrderID: "o1", UserID: "u1", Reason: order.CancelReasonChangedMind, Note: " arrived too late "}
+	err := cancel.Handle(context.Background(), cmd)
+	assert.ErrorIs(t, err, payment.ErrRefundDeclined)
+	assert.Equal(t, order.StatusCancelling, o.Status, "the order is not cancelled until its refunds are issued")
+	assert.Equal(t, payment.StatusRefunded, payments.payments[0].Status)
+	assert.Equal(t, 5, products.products["p1"].Stock, "the stock goes back as the order is claimed")
+
+	provider.decline = ""
+	require.NoError(t, cancel.Handle(context.Background(), cmd))
+	assert.Equal(t, map[string]float64{"ext-a": 60000, "ext-b": 30000}, provider.refunds, "a retry refunds nothing twice")
+	assert.Equal(t, 5, products.products["p1"].Stock, "a retry puts no stock back twice")
+	assert.Equal(t, 30000.0, payments.payments[1].RefundedAmount)
+
+	assert.Equal(t, order.StatusCancelled, o.Status)
+	assert.Equal(t, order.PaymentStatusRefunded, o.PaymentStatus)
+	assert.Equal(t, 10000.0, o.PaidAmount)
+	assert.Equal(t, order.CancelReasonChangedMind, o.Cancellation.Reason)
+	assert.Equal(t, "arrived too late", o.Cancellation.Note)
+	assert.Equal(t, 10000.0, o.Cancellation.Fee)
+	assert.Equal(t, 90000.0, o.Cancellation.Refunded)
+	assert.Equal(t, 5, products.products["p1"].Stock)
+
+	summary := payment.Summarize(o.TotalAmount, payments.payments)
+	assert.Equal(t, 10000.0, summary.Paid, "the fee is what the shop keeps")
+
+	_, err = o.ShipShipment("s1", "jne", "JNE1", "", *o.Cancellation.At)
+	assert.ErrorIs(t, err, order.ErrShipmentState, "a cancelled order's shipments do not move on")
+	assert.ErrorIs(t, cancel.Handle(context.Background(), cmd), order.ErrNotCancellable)
+}
+
+func TestCancelClaimsTheOrderOnce(t *testing.T) {
+	o := shippedOrder()
+	// Another request claimed the order after this one read it
+	orders := &codOrderRepo{order: o, saved: order.StatusCancelling}
+	products := &flashProductRepo{products: map[string]*product.Product{"p1": {ID: "p1", Stock: 3}}}
+	payments := &paymentRepoStub{payments: []*payment.Payment{paidPayment("a", 100000)}}
+	provider := &refundProviderStub{refunds: map[string]float64{}, keys: map[string]bool{}}
+	cancel := commands.NewCancelOrderCommandHandler(orders, products, nil, newMemoryFlashCounter(), payments, provider, order.CancellationPolicy{AfterShipment: true}, nil)
+
+	err := cancel.Handle(context.Background(), commands.CancelOrderCommand{OrderID: "o1", UserID: "u1"})
+	assert.Equal(t, commands.ErrOrderCancelConflict.Code, apperror.From(err).Code)
+	assert.Equal(t, 3, products.products["p1"].Stock, "only the request claiming the order puts its stock back")
+	assert.Empty(t, provider.refunds)
+}
+
+func TestCancelUnpaidOrder(t *testing.T) {
+	o := shippedOrder()
+	o.Status = order.StatusConfirmed
+	o.PaymentStatus = order.PaymentStatusUnpaid
+	o.Shipments[0].Status = order.ShipmentStatusPending
+	pending := payment.NewPayment("o1", "u1", 100000, payment.MethodBankTransfer)
+	payments := &paymentRepoStub{payments: []*payment.Payment{pending}}
+	products := &flashProductRepo{products: map[string]*product.Product{"p1": {ID: "p1"}}}
+	cancel := commands.NewCancelOrderCommandHandler(&codOrderRepo{order: o}, products, nil, newMemoryFlashCounter(), payments, nil, order.CancellationPolicy{FlatFee: 10000}, nil)
+
+	require.NoError(t, cancel.Handle(context.Background(), commands.CancelOrderCommand{OrderID: "o1", UserID: "u1"}))
+	assert.Equal(t, order.StatusCancelled, o.Status)
+	assert.Equal(t, order.CancelReasonOther, o.Cancellation.Reason, "no reason given is other")
+	assert.Zero(t, o.Cancellation.Fee)
+	assert.Equal(t, order.PaymentStatusUnpaid, o.PaymentStatus)
+	assert.Equal(t, payment.StatusCancelled, pending.Status, "its payment link can no longer be paid")
+}
+
+func TestShopCancelsForItsOwnReasons(t *testing.T) {
+	usePipeline(t, pipeline.Standard(&config.PipelineConfig{}, zap.NewNop(), nil))
+
+	for _, reason := range []order.CancelReason{order.CancelReasonFraudDeclined, order.CancelReasonPaymentFailed} {
+		o := shippedOrder()
+		o.Status = order.StatusPending
+		o.PaymentStatus = order.PaymentStatusUnpaid
+		o.Shipments[0].Status = order.ShipmentStatusPending
+		products := &flashProductRepo{products: map[string]*product.Product{"p1": {ID: "p1"}}}
+		cancel := commands.NewCancelOrderCommandHandler(&codOrderRepo{order: o}, products, nil, newMemoryFlashCounter(), &paymentRepoStub{}, nil, order.CancellationPolicy{}, nil)
+
+		require.NoError(t, cancel.Handle(context.Background(), commands.CancelOrderCommand{OrderID: "o1", UserID: "u1", Reason: reason}), reason)
+		assert.Equal(t, order.StatusCancelled, o.Status)
+		assert.Equal(t, reason, o.Cancellation.Reason)
+		assert.Equal(t, 2, products.products["p1"].Stock, "the order's stock is given back")
+	}
+}
@@ -0,0 +1,72 @@
+package unit
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/fulfillment"
+	"online-shop/internal/domain/order"
+	"online-shop/pkg/apperror"
+)
+
+func TestOrderGiftOptions(t *testing.T) {
+	o := &order.Order{TotalAmount: 100000}
+	require.NoError(t, o.SetGift(true, "  Happy birthday!  ", 15000))
+	assert.Equal(t, 115000.0, o.TotalAmount)
+	assert.Equal(t, "Happy birthday!", o.Gift.Message)
+
+	require.NoError(t, o.SetGift(true, "", 20000))
+	assert.Equal(t, 120000.0, o.TotalAmount, "the fee replaces the one charged before")
+	require.NoError(t, o.SetGift(false, "Selamat!", 20000))
+	assert.Equal(t, 100000.0, o.TotalAmount, "only wrapping is charged")
+	assert.True(t, o.Gift.IsGift())
+
+	assert.ErrorIs(t, o.SetGift(false, strings.Repeat("x", order.MaxGiftMessageLength+1), 0), order.ErrInvalidExtras)
+	assert.NoError(t, o.SetNote(strings.Repeat("é", order.MaxNoteLength)), "lengths are in characters")
+	assert.ErrorIs(t, o.SetNote(strings.Repeat("x", order.MaxNoteLength+1)), order.ErrInvalidExtras)
+}
+
+func TestCheckoutChargesGiftWrapping(t *testing.T) {
+	products := cartProducts()
+	orders := &flashOrderRepo{orders: map[string]*order.Order{}}
+	create := commands.NewCreateOrderCommandHandler(orders, products, noCommissionRepo{}, noWarehouseRepo{}, nil, &flashSaleRepoStub{}, newMemoryFlashCounter(), commands.CreateOrderOptions{GiftWrapFee: 15000})
+	preview := commands.NewPreviewCheckoutCommandHandler(newMemoryCartRepo(), create)
+	address := order.Address{Street: "Jl. Merdeka 1", City: "Jakarta", PostalCode: "10110", Country: "ID"}
+	items := []commands.CreateOrderItemCmd{{ProductID: "p1", Quantity: 1}}
+
+	previewed, err := preview.Handle(context.Background(), commands.PreviewCheckoutCommand{
+		UserID: "u1", Items: items, ShippingAddress: address, GiftWrap: true, GiftMessage: "Untuk Ibu",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 15000.0, previewed.Gift.WrapFee)
+	assert.Equal(t, previewed.Subtotal+15000, previewed.Total)
+
+	placed, err := create.Handle(context.Background(), commands.CreateOrderCommand{
+		UserID: "u1", Items: items, ShippingAddress: address, GiftWrap: true, GiftMessage: "Untuk Ibu", Note: "Ring the bell twice",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, previewed.Total, placed.TotalAmount)
+	assert.Equal(t, order.Gift{Wrap: true, Message: "Untuk Ibu", WrapFee: 15000}, placed.Gift)
+	assert.Equal(t, "Ring the bell twice", placed.Note)
+
+	_, err = create.Handle(context.Background(), commands.CreateOrderCommand{
+		UserID: "u1", Items: items, ShippingAddress: address, GiftMessage: strings.Repeat("x", 301),
+	})
+	assert.Equal(t, commands.ErrInvalidOrderExtras.Code, apperror.From(err).Code)
+
+	placed.Shipments = []order.Shipment{{ID: "s1", WarehouseID: "w1", Status: order.ShipmentStatusPicking}}
+	for i := range placed.Items {
+		placed.Items[i].ShipmentID = "s1"
+	}
+	list := fulfillment.BuildPickList("w1", []*order.Order{placed}, time.Now())
+	require.Len(t, list.Shipments, 1)
+	assert.True(t, list.Shipments[0].GiftWrap)
+	assert.Equal(t, "Untuk Ibu", list.Shipments[0].GiftMessage)
+	assert.Equal(t, "Ring the bell twice", list.Shipments[0].Note)
+}
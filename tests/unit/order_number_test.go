@@ -0,0 +1,65 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/product"
+)
+
+type sequenceNumbers struct {
+	next int64
+	err  error
+}
+
+func (s *sequenceNumbers) Next(ctx context.Context, at time.Time) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	s.next++
+	return order.FormatNumber("INV", at, s.next, 6), nil
+}
+
+func TestFormatOrderNumber(t *testing.T) {
+	at := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, "INV-2024-000123", order.FormatNumber("INV", at, 123, 6))
+	assert.Equal(t, "ORD-2024-1234567", order.FormatNumber("ORD", at, 1234567, 6), "numbers grow past the padding")
+
+	newYear := time.Date(2025, 1, 1, 6, 0, 0, 0, time.FixedZone("WIB", 7*3600))
+	assert.Equal(t, "ORD-2024-0042", order.FormatNumber("ORD", newYear, 42, 4), "the year is taken in UTC")
+}
+
+func TestCreateOrderAssignsNumber(t *testing.T) {
+	products := &flashProductRepo{products: map[string]*product.Product{
+		"p1": {ID: "p1", Price: 100, Stock: 50, Status: product.StatusActive},
+	}}
+	orders := &flashOrderRepo{orders: map[string]*order.Order{}}
+	numbers := &sequenceNumbers{next: 122}
+	create := commands.NewCreateOrderCommandHandler(orders, products, noCommissionRepo{}, noWarehouseRepo{}, nil, &flashSaleRepoStub{}, newMemoryFlashCounter(), commands.CreateOrderOptions{Numbers: numbers})
+	cmd := commands.CreateOrderCommand{
+		UserID:          "u1",
+		Items:           []commands.CreateOrderItemCmd{{ProductID: "p1", Quantity: 1}},
+		ShippingAddress: order.Address{Street: "Jl. Merdeka 1", City: "Jakarta", PostalCode: "10110", Country: "ID"},
+	}
+	year := time.Now().UTC().Year()
+
+	first, err := create.Handle(context.Background(), cmd)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("INV-%d-000123", year), first.Number)
+	second, err := create.Handle(context.Background(), cmd)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("INV-%d-000124", year), second.Number)
+
+	numbers.err = errors.New("sequence unavailable")
+	_, err = create.Handle(context.Background(), cmd)
+	assert.Error(t, err, "an order is not saved without a number")
+	assert.Len(t, orders.orders, 2)
+}
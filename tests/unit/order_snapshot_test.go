@@ -0,0 +1,67 @@
+package unit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/interfaces/http/apiv2"
+)
+
+func TestNewProductSnapshot(t *testing.T) {
+	p := &product.Product{
+		ID:     "grinder",
+		Name:   "Burr grinder",
+		Slug:   "burr-grinder",
+		Images: []string{"https://cdn.example.com/grinder.jpg", "https://cdn.example.com/grinder-side.jpg"},
+		Attributes: []product.Attribute{
+			{Key: "weight", Label: "Weight", Type: product.AttributeNumber, Value: "1.2", Unit: "kg"},
+		},
+	}
+
+	snapshot := order.NewProductSnapshot(p)
+	assert.Equal(t, "Burr grinder", snapshot.Name)
+	assert.Equal(t, "burr-grinder", snapshot.Slug)
+	assert.Equal(t, "https://cdn.example.com/grinder.jpg", snapshot.Image, "the first image")
+	assert.Equal(t, []order.SnapshotAttribute{{Key: "weight", Label: "Weight", Value: "1.2", Unit: "kg"}}, snapshot.Attributes)
+
+	assert.Empty(t, order.NewProductSnapshot(&product.Product{Name: "Plain"}).Image)
+}
+
+func TestOrderItemsKeepTheProductAsOrdered(t *testing.T) {
+	products := &flashProductRepo{products: map[string]*product.Product{
+		"beans": {ID: "beans", Name: "Arabica beans", Slug: "arabica-beans", MerchantID: "m1", Price: 50000, Stock: 10, Status: product.StatusActive, Images: []string{"https://cdn.example.com/beans.jpg"}},
+		"mug":   {ID: "mug", Name: "Mug", MerchantID: "m1", Price: 25000, Stock: 3, Status: product.StatusActive},
+		"kit": {ID: "kit", Name: "Brewing kit", MerchantID: "m1", Price: 80000, Stock: 1, Status: product.StatusActive, Components: []product.Component{
+			{ProductID: "beans", Quantity: 1},
+			{ProductID: "mug", Quantity: 2},
+		}},
+	}}
+	stocker := commands.NewBundleStocker(products, &bundleRepoStub{products: products})
+	orders := &flashOrderRepo{orders: map[string]*order.Order{}}
+	create := commands.NewCreateOrderCommandHandler(orders, products, noCommissionRepo{}, noWarehouseRepo{}, nil, &flashSaleRepoStub{}, newMemoryFlashCounter(), commands.CreateOrderOptions{Bundles: stocker})
+	address := order.Address{Street: "Jl. Merdeka 1", City: "Jakarta", PostalCode: "10110", Country: "ID"}
+
+	placed, err := create.Handle(context.Background(), commands.CreateOrderCommand{UserID: "u1", ShippingAddress: address, Items: []commands.CreateOrderItemCmd{
+		{ProductID: "beans", Quantity: 1},
+		{ProductID: "kit", Quantity: 1},
+	}})
+	require.NoError(t, err)
+	require.Len(t, placed.Items, 3)
+	assert.Equal(t, "Arabica beans", placed.Items[0].Product.Name)
+	assert.Equal(t, "https://cdn.example.com/beans.jpg", placed.Items[0].Product.Image)
+	assert.Equal(t, "Mug", placed.Items[2].Product.Name, "bundle components are snapshotted as themselves")
+
+	products.products["beans"].Name = "House blend"
+	products.products["beans"].Status = product.StatusDeleted
+	assert.Equal(t, "Arabica beans", orders.orders[placed.ID].Items[0].Product.Name)
+
+	dto := apiv2.MapOrder(placed)
+	assert.Equal(t, "Arabica beans", dto.Items[0].ProductName)
+	assert.Equal(t, "https://cdn.example.com/beans.jpg", dto.Items[0].ProductImage)
+}
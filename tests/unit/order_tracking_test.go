@@ -0,0 +1,82 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/user"
+)
+
+type trackingOrderRepo struct {
+	order.Repository
+	orders []*order.Order
+}
+
+func (r *trackingOrderRepo) GetByNumber(ctx context.Context, number string) (*order.Order, error) {
+	for _, o := range r.orders {
+		if o.Number == number {
+			return o, nil
+		}
+	}
+	return nil, order.ErrNotFound
+}
+
+func TestTrackOrder(t *testing.T) {
+	shippedAt := time.Now().Add(-time.Hour)
+	o := &order.Order{
+		ID:            "o1",
+		Number:        "ORD-2026-000123",
+		UserID:        "u1",
+		PaymentID:     "pay-1",
+		Status:        order.StatusShipped,
+		PaymentStatus: order.PaymentStatusPaid,
+		TotalAmount:   300000,
+		ShippingAddress: order.Address{
+			Street:     "Jl. Sudirman 1",
+			City:       "Jakarta",
+			PostalCode: "10220",
+			Country:    "ID",
+		},
+		Items: []order.OrderItem{
+			{ProductID: "p1", Quantity: 2, Price: 100000, ShipmentID: "s1"},
+			{ProductID: "p2", Quantity: 1, Price: 100000, ShipmentID: "s2"},
+		},
+		Shipments: []order.Shipment{
+			{ID: "s1", Status: order.ShipmentStatusShipped, Carrier: "JNE", TrackingNumber: "JNE123", ShippedAt: &shippedAt},
+			{ID: "s2", Status: order.ShipmentStatusPending},
+		},
+	}
+	orders := &trackingOrderRepo{orders: []*order.Order{o}}
+	users := &deletionUserRepoStub{users: map[string]*user.User{
+		"u1": {ID: "u1", Email: "Budi@example.com"},
+	}}
+	handler := queries.NewTrackOrderQueryHandler(orders, users)
+
+	tracking, err := handler.Handle(context.Background(), queries.TrackOrderQuery{Number: " ord-2026-000123 ", Email: "budi@EXAMPLE.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "ORD-2026-000123", tracking.Number)
+	assert.Equal(t, order.StatusShipped, tracking.Status)
+	assert.Equal(t, 3, tracking.ItemCount)
+	assert.Equal(t, order.TrackingAddress{City: "Jakarta", Country: "ID"}, tracking.ShipTo)
+	require.Len(t, tracking.Shipments, 2)
+	assert.Equal(t, 2, tracking.Shipments[0].Items)
+	assert.Equal(t, "JNE123", tracking.Shipments[0].TrackingNumber)
+
+	body, err := json.Marshal(tracking)
+	require.NoError(t, err)
+	for _, hidden := range []string{"o1", "u1", "pay-1", "Sudirman", "10220", "p1"} {
+		assert.NotContains(t, string(body), hidden, "tracking exposes only part of the order")
+	}
+
+	_, err = handler.Handle(context.Background(), queries.TrackOrderQuery{Number: o.Number, Email: "someone@example.com"})
+	assert.ErrorIs(t, err, order.ErrNotFound, "a wrong email looks like an unknown order")
+	_, err = handler.Handle(context.Background(), queries.TrackOrderQuery{Number: "ORD-2026-000124", Email: "budi@example.com"})
+	assert.ErrorIs(t, err, order.ErrNotFound)
+}
@@ -0,0 +1,213 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/organization"
+	"online-shop/internal/domain/payment"
+	"online-shop/pkg/apperror"
+)
+
+type memoryOrganizationRepo struct {
+	organization.Repository
+	orgs    map[string]*organization.Organization
+	members map[string]*organization.Member
+}
+
+func (r *memoryOrganizationRepo) GetByID(ctx context.Context, id string) (*organization.Organization, error) {
+	o, ok := r.orgs[id]
+	if !ok {
+		return nil, organization.ErrNotFound
+	}
+	return o, nil
+}
+
+func (r *memoryOrganizationRepo) Lock(ctx context.Context, id string) (*organization.Organization, error) {
+	return r.GetByID(ctx, id)
+}
+
+func (r *memoryOrganizationRepo) GetMember(ctx context.Context, userID string) (*organization.Member, error) {
+	m, ok := r.members[userID]
+	if !ok {
+		return nil, organization.ErrMemberNotFound
+	}
+	return m, nil
+}
+
+type memoryApprovalRepo struct {
+	organization.ApprovalRepository
+	approvals map[string]*organization.Approval
+}
+
+func (r *memoryApprovalRepo) Create(ctx context.Context, a *organization.Approval) error {
+	r.approvals[a.ID] = a
+	return nil
+}
+
+func (r *memoryApprovalRepo) GetByID(ctx context.Context, id string) (*organization.Approval, error) {
+	a, ok := r.approvals[id]
+	if !ok {
+		return nil, organization.ErrApprovalNotFound
+	}
+	return a, nil
+}
+
+func (r *memoryApprovalRepo) GetPendingByOrderID(ctx context.Context, orderID string) (*organization.Approval, error) {
+	for _, a := range r.approvals {
+		if a.OrderID == orderID && a.Status == organization.ApprovalPending {
+			return a, nil
+		}
+	}
+	return nil, organization.ErrApprovalNotFound
+}
+
+func (r *memoryApprovalRepo) Update(ctx context.Context, a *organization.Approval) error {
+	r.approvals[a.ID] = a
+	return nil
+}
+
+type memoryInvoiceRepo struct {
+	organization.InvoiceRepository
+	invoices map[string]*organization.Invoice
+}
+
+func (r *memoryInvoiceRepo) Create(ctx context.Context, i *organization.Invoice) error {
+	r.invoices[i.ID] = i
+	return nil
+}
+
+func (r *memoryInvoiceRepo) Exposure(ctx context.Context, organizationID string, now time.Time) (organization.Exposure, error) {
+	var owed organization.Exposure
+	for _, i := range r.invoices {
+		if i.OrganizationID != organizationID || i.Status != organization.InvoiceOpen {
+			continue
+		}
+		owed.Invoices++
+		owed.Amount += i.Amount
+		if i.Overdue(now) {
+			owed.Overdue++
+		}
+	}
+	return owed, nil
+}
+
+func TestOrganizationCreditCoversOpenInvoices(t *testing.T) {
+	org := &organization.Organization{ID: "org1", CreditLimit: 1000000, PaymentTermsDays: 30}
+
+	assert.NoError(t, org.CheckCredit(organization.Exposure{Invoices: 1, Amount: 600000}, 400000))
+	assert.ErrorIs(t, org.CheckCredit(organization.Exposure{Invoices: 1, Amount: 600000}, 400001), organization.ErrCreditExceeded)
+	assert.ErrorIs(t, org.CheckCredit(organization.Exposure{Invoices: 1, Amount: 100, Overdue: 1}, 100), organization.ErrCreditExceeded, "an overdue invoice blocks buying")
+	assert.ErrorIs(t, (&organization.Organization{}).CheckCredit(organization.Exposure{}, 100), organization.ErrNotInvoiceable, "no credit terms")
+
+	now := time.Now()
+	invoice := organization.NewInvoice(org, "o1", "p1", 600000, "PO-1", now)
+	assert.WithinDuration(t, now.AddDate(0, 0, 30), invoice.DueAt, time.Second)
+	assert.False(t, invoice.Overdue(now))
+	assert.True(t, invoice.Overdue(now.AddDate(0, 0, 31)))
+
+	credit := org.Credit(organization.Exposure{Invoices: 1, Amount: 600000})
+	assert.Equal(t, 400000.0, credit.Available)
+}
+
+func TestApprovalChainsAreDecidedStepByStep(t *testing.T) {
+	org := &organization.Organization{ID: "org1"}
+	assert.ErrorIs(t, org.SetApprovalChain([]organization.ApprovalStep{
+		{MinAmount: 500000, Role: organization.RoleAdmin},
+		{MinAmount: 100000, Role: organization.RoleApprover},
+	}), organization.ErrInvalidChain, "amounts go down")
+	require.NoError(t, org.SetApprovalChain([]organization.ApprovalStep{
+		{MinAmount: 100000, Role: organization.RoleApprover},
+		{MinAmount: 500000, Role: organization.RoleAdmin},
+	}))
+	assert.Empty(t, org.StepsFor(50000))
+	assert.Len(t, org.StepsFor(200000), 1)
+	steps := org.StepsFor(500000)
+	require.Len(t, steps, 2)
+
+	buyer := organization.NewMember("org1", "u1", organization.RoleBuyer, "admin")
+	approver := organization.NewMember("org1", "u2", organization.RoleApprover, "admin")
+	admin := organization.NewMember("org1", "u3", organization.RoleAdmin, "admin")
+	outsider := organization.NewMember("org2", "u4", organization.RoleAdmin, "u4")
+	now := time.Now()
+
+	a := organization.NewApproval("org1", "o1", "u1", 500000, "", steps)
+	assert.ErrorIs(t, a.Decide(buyer, true, "", now), organization.ErrNotPermitted, "the buyer's own purchase")
+	assert.ErrorIs(t, a.Decide(outsider, true, "", now), organization.ErrApprovalNotFound)
+
+	require.NoError(t, a.Decide(approver, true, "", now))
+	assert.Equal(t, organization.ApprovalPending, a.Status)
+	assert.ErrorIs(t, a.Decide(approver, true, "", now), organization.ErrNotPermitted, "one step each")
+	require.NoError(t, a.Decide(admin, true, "ok", now))
+	assert.Equal(t, organization.ApprovalApproved, a.Status)
+	assert.ErrorIs(t, a.Decide(admin, true, "", now), organization.ErrDecided)
+
+	rejected := organization.NewApproval("org1", "o2", "u1", 500000, "", steps)
+	assert.ErrorIs(t, rejected.Decide(buyer, true, "", now), organization.ErrNotPermitted)
+	require.NoError(t, rejected.Decide(admin, false, "over budget", now))
+	assert.Equal(t, organization.ApprovalRejected, rejected.Status)
+}
+
+func TestApprovedPurchasesAreInvoiced(t *testing.T) {
+	orgs := &memoryOrganizationRepo{
+		orgs: map[string]*organization.Organization{
+			"org1": {ID: "org1", CreditLimit: 1000000, PaymentTermsDays: 30, ApprovalChain: []organization.ApprovalStep{
+				{MinAmount: 300000, Role: organization.RoleApprover},
+			}},
+		},
+		members: map[string]*organization.Member{
+			"u1": organization.NewMember("org1", "u1", organization.RoleBuyer, "u3"),
+			"u2": organization.NewMember("org1", "u2", organization.RoleApprover, "u3"),
+		},
+	}
+	orders := &flashOrderRepo{orders: map[string]*order.Order{
+		"small": {ID: "small", UserID: "u1", Status: order.StatusPending, PaymentStatus: order.PaymentStatusUnpaid, TotalAmount: 200000},
+		"large": {ID: "large", UserID: "u1", Status: order.StatusPending, PaymentStatus: order.PaymentStatusUnpaid, TotalAmount: 700000},
+		"other": {ID: "other", UserID: "u1", Status: order.StatusPending, PaymentStatus: order.PaymentStatusUnpaid, TotalAmount: 200000},
+		"guest": {ID: "guest", UserID: "u9", Status: order.StatusPending, PaymentStatus: order.PaymentStatusUnpaid, TotalAmount: 200000},
+	}}
+	payments := &paymentRepoStub{}
+	approvals := &memoryApprovalRepo{approvals: map[string]*organization.Approval{}}
+	invoices := &memoryInvoiceRepo{invoices: map[string]*organization.Invoice{}}
+	pay := commands.NewPayOnInvoiceCommandHandler(orders, payments, orgs, approvals, invoices, nil)
+	decide := commands.NewDecidePurchaseApprovalCommandHandler(orders, payments, orgs, approvals, invoices, nil)
+
+	_, err := pay.Handle(context.Background(), commands.PayOnInvoiceCommand{OrderID: "guest", UserID: "u9"})
+	assert.Equal(t, commands.ErrInvoiceUnavailable.Code, apperror.From(err).Code, "not a member")
+
+	purchase, err := pay.Handle(context.Background(), commands.PayOnInvoiceCommand{OrderID: "small", UserID: "u1", PurchaseOrder: "PO-7"})
+	require.NoError(t, err)
+	require.NotNil(t, purchase.Invoice, "below the chain")
+	assert.Equal(t, order.StatusConfirmed, orders.orders["small"].Status)
+	assert.Equal(t, order.PaymentStatusInvoiced, orders.orders["small"].PaymentStatus)
+	assert.Equal(t, "org1", orders.orders["small"].OrganizationID)
+	require.Len(t, payments.payments, 1)
+	assert.Equal(t, payment.MethodInvoice, payments.payments[0].Method)
+	assert.Equal(t, payment.StatusPending, payments.payments[0].Status)
+	require.NotNil(t, payments.payments[0].DueAt)
+	assert.Equal(t, purchase.Invoice.DueAt, *payments.payments[0].DueAt)
+
+	purchase, err = pay.Handle(context.Background(), commands.PayOnInvoiceCommand{OrderID: "large", UserID: "u1"})
+	require.NoError(t, err)
+	require.NotNil(t, purchase.Approval)
+	assert.Nil(t, purchase.Invoice)
+	assert.Equal(t, order.StatusPending, orders.orders["large"].Status, "held for approval")
+
+	_, err = pay.Handle(context.Background(), commands.PayOnInvoiceCommand{OrderID: "large", UserID: "u1"})
+	assert.Equal(t, commands.ErrInvoiceUnavailable.Code, apperror.From(err).Code, "already awaiting approval")
+
+	decided, err := decide.Handle(context.Background(), commands.DecidePurchaseApprovalCommand{ApprovalID: purchase.Approval.ID, UserID: "u2", Approve: true})
+	require.NoError(t, err)
+	assert.Equal(t, organization.ApprovalApproved, decided.Approval.Status)
+	require.NotNil(t, decided.Invoice)
+	assert.Equal(t, order.PaymentStatusInvoiced, orders.orders["large"].PaymentStatus)
+
+	_, err = pay.Handle(context.Background(), commands.PayOnInvoiceCommand{OrderID: "other", UserID: "u1"})
+	assert.Equal(t, commands.ErrCreditLimitExceeded.Code, apperror.From(err).Code, "900000 owed of 1000000")
+}
@@ -0,0 +1,129 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/audit"
+	"online-shop/internal/interfaces/http/handlers"
+	"online-shop/internal/interfaces/http/middleware"
+	"online-shop/pkg/pagination"
+)
+
+type pagedAuditRepo struct {
+	entries       []*audit.Entry
+	limit, offset int
+}
+
+func (r *pagedAuditRepo) Create(ctx context.Context, entry *audit.Entry) error {
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+func (r *pagedAuditRepo) List(ctx context.Context, filter audit.Filter, limit, offset int) ([]*audit.Entry, int64, error) {
+	r.limit, r.offset = limit, offset
+	end := offset + limit
+	if end > len(r.entries) {
+		end = len(r.entries)
+	}
+	if offset > end {
+		offset = end
+	}
+	return r.entries[offset:end], int64(len(r.entries)), nil
+}
+
+func TestPageClampsParameters(t *testing.T) {
+	p := pagination.New(0, 0)
+	assert.Equal(t, 1, p.Page)
+	assert.Equal(t, pagination.DefaultPerPage, p.PerPage)
+	assert.Equal(t, 0, p.Offset())
+
+	p = pagination.New(3, 500)
+	assert.Equal(t, pagination.MaxPerPage, p.Limit())
+	assert.Equal(t, 200, p.Offset())
+}
+
+func TestPageFromOffsetKeepsOffset(t *testing.T) {
+	p := pagination.FromOffset(10, 25)
+	assert.Equal(t, 25, p.Offset(), "legacy offsets need not fall on a page boundary")
+	assert.Equal(t, 3, p.Page)
+	assert.Equal(t, 10, p.Limit())
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	p, err := pagination.FromCursor(pagination.EncodeCursor(40), 20)
+	require.NoError(t, err)
+	assert.Equal(t, 40, p.Offset())
+	assert.Equal(t, 3, p.Page)
+
+	for _, cursor := range []string{"not base64!", "MTIz", pagination.EncodeCursor(-1)} {
+		_, err := pagination.FromCursor(cursor, 20)
+		assert.ErrorIs(t, err, pagination.ErrInvalidCursor, cursor)
+	}
+}
+
+func TestMetaNextCursor(t *testing.T) {
+	p := pagination.New(1, 10)
+
+	m := p.Meta(10, nil)
+	assert.Nil(t, m.Total)
+	assert.Equal(t, pagination.EncodeCursor(10), m.NextCursor, "a full page without a total may have more")
+	assert.Empty(t, p.Meta(7, nil).NextCursor)
+
+	m = p.Meta(10, pagination.Total(10))
+	assert.Equal(t, int64(10), *m.Total)
+	assert.Empty(t, m.NextCursor, "the total says this is the last page")
+	assert.NotEmpty(t, pagination.New(2, 10).Meta(10, pagination.Total(35)).NextCursor)
+}
+
+func TestListRespondsWithEnvelope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &pagedAuditRepo{}
+	for i := 0; i < 5; i++ {
+		repo.Create(context.Background(), &audit.Entry{ID: string(rune('a' + i))})
+	}
+	handler := handlers.NewAuditHandler(queries.NewListAuditLogsQueryHandler(repo))
+
+	router := gin.New()
+	router.Use(middleware.Errors())
+	router.GET("/audit-logs", handler.ListAuditLogs)
+
+	call := func(query string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/audit-logs?"+query, nil))
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body
+	}
+
+	code, body := call("per_page=2")
+	require.Equal(t, http.StatusOK, code)
+	assert.Len(t, body["data"], 2)
+	meta := body["meta"].(map[string]interface{})
+	assert.Equal(t, float64(1), meta["page"])
+	assert.Equal(t, float64(2), meta["per_page"])
+	assert.Equal(t, float64(5), meta["total"])
+	require.NotEmpty(t, meta["next_cursor"])
+
+	code, body = call("per_page=2&cursor=" + meta["next_cursor"].(string))
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 2, repo.offset)
+	assert.Equal(t, float64(2), body["meta"].(map[string]interface{})["page"])
+
+	call("limit=3&offset=1")
+	assert.Equal(t, 3, repo.limit)
+	assert.Equal(t, 1, repo.offset)
+
+	code, body = call("cursor=bogus")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, pagination.ErrInvalidCursor.Code, body["code"])
+}
@@ -0,0 +1,103 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/midtrans/midtrans-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/domain/payment"
+	infrapayment "online-shop/internal/infrastructure/payment"
+	"online-shop/pkg/config"
+)
+
+func newTestGuard() *infrapayment.Guard {
+	return infrapayment.NewGuard("midtrans", infrapayment.GuardSettings{
+		Timeout:    50 * time.Millisecond,
+		Retries:    2,
+		Backoff:    time.Millisecond,
+		Resilience: config.DependencyConfig{BreakerFailures: 3, BreakerCooldownSeconds: 60},
+	})
+}
+
+func failingWith(status int, calls *int) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		*calls++
+		return nil, &midtrans.Error{Message: "midtrans", StatusCode: status}
+	}
+}
+
+func TestGuardRetriesIdempotentCalls(t *testing.T) {
+	guard := newTestGuard()
+
+	calls := 0
+	result, err := guard.Call(context.Background(), true, func() (interface{}, error) {
+		calls++
+		if calls < 3 {
+			return nil, &midtrans.Error{Message: "midtrans", StatusCode: http.StatusBadGateway}
+		}
+		return "settled", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "settled", result)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	_, err = guard.Call(context.Background(), false, failingWith(http.StatusServiceUnavailable, &calls))
+	assert.ErrorIs(t, err, payment.ErrProviderUnavailable)
+	assert.Equal(t, 1, calls, "a call that is not idempotent runs once")
+
+	calls = 0
+	_, err = guard.Call(context.Background(), true, failingWith(http.StatusBadRequest, &calls))
+	var merr *midtrans.Error
+	require.True(t, errors.As(err, &merr), "a refusal is passed through")
+	assert.False(t, errors.Is(err, payment.ErrProviderUnavailable))
+	assert.Equal(t, 1, calls, "a refusal is not retried")
+}
+
+func TestGuardOpensAfterRepeatedFailures(t *testing.T) {
+	guard := newTestGuard()
+
+	calls := 0
+	for i := 0; i < 5; i++ {
+		_, err := guard.Call(context.Background(), true, failingWith(http.StatusBadRequest, &calls))
+		require.Error(t, err)
+	}
+	assert.Equal(t, "closed", guard.State(), "refusals mean the provider is answering")
+
+	calls = 0
+	_, err := guard.Call(context.Background(), true, failingWith(http.StatusInternalServerError, &calls))
+	assert.ErrorIs(t, err, payment.ErrProviderUnavailable)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, "open", guard.State())
+
+	calls = 0
+	_, err = guard.Call(context.Background(), true, failingWith(http.StatusInternalServerError, &calls))
+	assert.ErrorIs(t, err, payment.ErrProviderUnavailable)
+	assert.Zero(t, calls, "an open breaker fails fast")
+}
+
+func TestGuardTimesOutSlowCalls(t *testing.T) {
+	guard := newTestGuard()
+
+	started := time.Now()
+	_, err := guard.Call(context.Background(), false, func() (interface{}, error) {
+		time.Sleep(time.Second)
+		return "late", nil
+	})
+	assert.ErrorIs(t, err, payment.ErrProviderUnavailable)
+	assert.Less(t, time.Since(started), 500*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = guard.Call(ctx, true, func() (interface{}, error) {
+		time.Sleep(time.Second)
+		return "late", nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,236 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/payment"
+	"online-shop/internal/domain/product"
+	"online-shop/pkg/apperror"
+)
+
+type memoryMethodRepo struct {
+	methods map[string]*payment.SavedMethod
+}
+
+func newMemoryMethodRepo() *memoryMethodRepo {
+	return &memoryMethodRepo{methods: map[string]*payment.SavedMethod{}}
+}
+
+func (r *memoryMethodRepo) Create(ctx context.Context, m *payment.SavedMethod) error {
+	r.methods[m.ID] = m
+	return nil
+}
+
+func (r *memoryMethodRepo) GetByID(ctx context.Context, id string) (*payment.SavedMethod, error) {
+	if m, ok := r.methods[id]; ok {
+		return m, nil
+	}
+	return nil, payment.ErrMethodNotFound
+}
+
+func (r *memoryMethodRepo) ListByUser(ctx context.Context, userID string) ([]*payment.SavedMethod, error) {
+	var methods []*payment.SavedMethod
+	for _, m := range r.methods {
+		if m.UserID == userID {
+			methods = append(methods, m)
+		}
+	}
+	sort.Slice(methods, func(i, j int) bool {
+		if methods[i].IsDefault != methods[j].IsDefault {
+			return methods[i].IsDefault
+		}
+		return methods[i].CreatedAt.After(methods[j].CreatedAt)
+	})
+	return methods, nil
+}
+
+func (r *memoryMethodRepo) Delete(ctx context.Context, id string) error {
+	if _, ok := r.methods[id]; !ok {
+		return payment.ErrMethodNotFound
+	}
+	delete(r.methods, id)
+	return nil
+}
+
+func (r *memoryMethodRepo) SetDefault(ctx context.Context, userID, id string) error {
+	for _, m := range r.methods {
+		if m.UserID == userID {
+			m.IsDefault = m.ID == id
+		}
+	}
+	return nil
+}
+
+type tokenProviderStub struct {
+	result  *payment.ChargeResult
+	err     error
+	charged []string
+	// declined are the tokens whose charges fail with an error of their own
+	declined map[string]error
+}
+
+func (p *tokenProviderStub) ChargeToken(ctx context.Context, pay *payment.Payment, token string) (*payment.ChargeResult, error) {
+	p.charged = append(p.charged, token)
+	if err, ok := p.declined[token]; ok {
+		return nil, err
+	}
+	return p.result, p.err
+}
+
+type paymentRepoStub struct {
+	payment.Repository
+	payments []*payment.Payment
+}
+
+func (r *paymentRepoStub) Create(ctx context.Context, p *payment.Payment) error {
+	r.payments = append(r.payments, p)
+	return nil
+}
+
+func (r *paymentRepoStub) ListByOrderID(ctx context.Context, orderID string) ([]*payment.Payment, error) {
+	var payments []*payment.Payment
+	for _, p := range r.payments {
+		if p.OrderID == orderID {
+			payments = append(payments, p)
+		}
+	}
+	return payments, nil
+}
+
+func (r *paymentRepoStub) Update(ctx context.Context, p *payment.Payment) error { return nil }
+
+func addCard(t *testing.T, add *commands.AddPaymentMethodCommandHandler, userID, token string) *payment.SavedMethod {
+	t.Helper()
+	m, err := add.Handle(context.Background(), commands.AddPaymentMethodCommand{
+		UserID:      userID,
+		Token:       token,
+		MaskedCard:  "481111-1114",
+		Brand:       "VISA",
+		ExpiryMonth: 12,
+		ExpiryYear:  time.Now().Year() + 2,
+	})
+	require.NoError(t, err)
+	return m
+}
+
+func TestSavedMethodStoresOnlyTheToken(t *testing.T) {
+	year := time.Now().Year() + 1
+
+	_, err := payment.NewSavedMethod("u1", "midtrans", "4811 1111 1111 1114", "481111-1114", "visa", 12, year, "")
+	assert.Equal(t, commands.ErrInvalidPaymentMethod.Code, apperror.From(err).Code, "a card number is refused as a token")
+
+	_, err = payment.NewSavedMethod("u1", "midtrans", "481111-1114-tok", "4811111111111114", "visa", 12, year, "")
+	assert.ErrorIs(t, err, payment.ErrInvalidMethod, "the masked number must be masked")
+
+	_, err = payment.NewSavedMethod("u1", "midtrans", "481111-1114-tok", "481111-1114", "visa", 1, 2001, "")
+	assert.ErrorIs(t, err, payment.ErrInvalidMethod, "an expired card is refused")
+
+	m, err := payment.NewSavedMethod("u1", "midtrans", "481111-1114-tok", "481111-1114", "VISA", 12, year, " Work ")
+	require.NoError(t, err)
+	assert.Equal(t, "1114", m.Last4)
+	assert.Equal(t, "visa", m.Brand)
+	assert.Equal(t, "Work", m.Label)
+	assert.False(t, m.ExpiredAt(time.Date(year, 12, 31, 23, 0, 0, 0, time.UTC)))
+	assert.True(t, m.ExpiredAt(time.Date(year+1, 1, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestPaymentMethodDefaults(t *testing.T) {
+	methods := newMemoryMethodRepo()
+	add := commands.NewAddPaymentMethodCommandHandler(methods, "midtrans")
+
+	first := addCard(t, add, "u1", "tok-a")
+	assert.True(t, first.IsDefault, "the first method becomes the default")
+	second := addCard(t, add, "u1", "tok-bb")
+	assert.False(t, second.IsDefault)
+
+	again := addCard(t, add, "u1", "tok-a")
+	assert.Equal(t, first.ID, again.ID, "saving a card twice keeps one method")
+
+	setDefault := commands.NewSetDefaultPaymentMethodCommandHandler(methods)
+	err := setDefault.Handle(context.Background(), commands.SetDefaultPaymentMethodCommand{UserID: "u2", MethodID: second.ID})
+	assert.Equal(t, commands.ErrPaymentMethodNotFound.Code, apperror.From(err).Code, "another user's method is not found")
+	require.NoError(t, setDefault.Handle(context.Background(), commands.SetDefaultPaymentMethodCommand{UserID: "u1", MethodID: second.ID}))
+	assert.True(t, second.IsDefault)
+	assert.False(t, first.IsDefault)
+
+	third := addCard(t, add, "u1", "tok-ccc")
+	third.CreatedAt = first.CreatedAt.Add(time.Second)
+	del := commands.NewDeletePaymentMethodCommandHandler(methods)
+	require.NoError(t, del.Handle(context.Background(), commands.DeletePaymentMethodCommand{UserID: "u1", MethodID: second.ID}))
+	assert.True(t, third.IsDefault, "the newest remaining method takes over as default")
+
+	for i := 0; len(methods.methods) < payment.MaxSavedMethods; i++ {
+		addCard(t, add, "u1", fmt.Sprintf("tok-extra-%d", i))
+	}
+	_, err = add.Handle(context.Background(), commands.AddPaymentMethodCommand{UserID: "u1", Token: "tok-over", MaskedCard: "481111-1114", ExpiryMonth: 12, ExpiryYear: time.Now().Year() + 1})
+	assert.Equal(t, commands.ErrPaymentMethodLimitReached.Code, apperror.From(err).Code)
+}
+
+func TestOneClickCheckout(t *testing.T) {
+	products := &flashProductRepo{products: map[string]*product.Product{
+		"p1": {ID: "p1", Price: 100, Stock: 7, Status: product.StatusActive},
+	}}
+	orders := &flashOrderRepo{orders: map[string]*order.Order{}}
+	counter := newMemoryFlashCounter()
+	create := commands.NewCreateOrderCommandHandler(orders, products, noCommissionRepo{}, noWarehouseRepo{}, nil, &flashSaleRepoStub{}, counter, commands.CreateOrderOptions{})
+	payments := &paymentRepoStub{}
+	cancel := commands.NewCancelOrderCommandHandler(orders, products, nil, counter, payments, nil, order.CancellationPolicy{}, nil)
+
+	methods := newMemoryMethodRepo()
+	card := addCard(t, commands.NewAddPaymentMethodCommandHandler(methods, "midtrans"), "u1", "tok-a")
+	provider := &tokenProviderStub{result: &payment.ChargeResult{Status: payment.StatusPaid, TransactionID: "trx-1"}}
+	checkout := commands.NewOneClickCheckoutCommandHandler(methods, payments, provider, orders, create, cancel, nil, nil)
+
+	cmd := commands.OneClickCheckoutCommand{
+		UserID:          "u1",
+		Items:           []commands.CreateOrderItemCmd{{ProductID: "p1", Quantity: 2}},
+		ShippingAddress: order.Address{Street: "Jl. Merdeka 1", City: "Jakarta", PostalCode: "10110", Country: "ID"},
+	}
+	result, err := checkout.Handle(context.Background(), cmd)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tok-a"}, provider.charged, "the default method is charged")
+	assert.Equal(t, order.StatusConfirmed, result.Order.Status)
+	assert.True(t, result.Payment.IsPaid())
+	assert.Equal(t, 200.0, result.Payment.Amount)
+	assert.Equal(t, 5, products.products["p1"].Stock)
+
+	provider.result, provider.err = nil, fmt.Errorf("%w: insufficient funds", payment.ErrChargeDeclined)
+	_, err = checkout.Handle(context.Background(), cmd)
+	assert.Equal(t, commands.ErrPaymentFailed.Code, apperror.From(err).Code)
+	assert.Equal(t, 5, products.products["p1"].Stock, "a declined order gives its stock back")
+	require.Len(t, payments.payments, 2, "the payment is recorded before the card is charged")
+	assert.Equal(t, payment.StatusFailed, payments.payments[1].Status)
+
+	// A charge that timed out may have been captured, so nothing is undone
+	provider.result, provider.err = nil, errors.New("midtrans: request timed out")
+	result, err = checkout.Handle(context.Background(), cmd)
+	require.NoError(t, err)
+	assert.Equal(t, order.StatusPending, result.Order.Status, "left for the webhook to settle")
+	assert.Equal(t, payment.StatusPending, result.Payment.Status)
+	assert.Equal(t, 3, products.products["p1"].Stock)
+	assert.Len(t, payments.payments, 3)
+
+	provider.result, provider.err = &payment.ChargeResult{Status: payment.StatusPending, RedirectURL: "https://3ds.example/ch"}, nil
+	result, err = checkout.Handle(context.Background(), cmd)
+	require.NoError(t, err)
+	assert.Equal(t, order.StatusPending, result.Order.Status, "3-D Secure is finished on the provider's page")
+	assert.Equal(t, "https://3ds.example/ch", result.Payment.PaymentURL)
+
+	card.ExpiryYear = 2001
+	_, err = checkout.Handle(context.Background(), cmd)
+	assert.Equal(t, commands.ErrPaymentMethodExpired.Code, apperror.From(err).Code)
+
+	cmd.PaymentMethodID = "missing"
+	_, err = checkout.Handle(context.Background(), cmd)
+	assert.True(t, errors.Is(err, payment.ErrMethodNotFound))
+}
@@ -0,0 +1,158 @@
+package unit
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/analytics"
+	"online-shop/internal/domain/export"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/user"
+	"online-shop/internal/infrastructure/archive"
+	"online-shop/internal/infrastructure/spreadsheet"
+)
+
+type exportPublisherStub struct {
+	queued []string
+}
+
+func (p *exportPublisherStub) Enqueue(ctx context.Context, exportID string) error {
+	p.queued = append(p.queued, exportID)
+	return nil
+}
+
+type personalDataSourceStub struct {
+	requested []string
+}
+
+func (s *personalDataSourceStub) LoadPersonalData(ctx context.Context, userID string) (*export.PersonalData, error) {
+	s.requested = append(s.requested, userID)
+	return &export.PersonalData{
+		UserID:      userID,
+		GeneratedAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		Profile:     &user.User{ID: userID, Email: "jane@example.com", Password: "hash"},
+		Addresses:   []map[string]interface{}{{"street": "1 Main St"}},
+		Orders:      []*order.Order{{ID: "o1", UserID: userID}},
+		AnalyticsEvents: []analytics.Event{
+			{EventID: "e1", UserID: userID, EventName: analytics.EventProductViewed},
+		},
+	}, nil
+}
+
+func TestRequestDataExportQueuesArchive(t *testing.T) {
+	repo := &exportRepoStub{exports: map[string]*export.Export{}}
+	publisher := &exportPublisherStub{}
+	handler := commands.NewRequestDataExportCommandHandler(repo, publisher)
+
+	e, err := handler.Handle(context.Background(), commands.RequestDataExportCommand{UserID: "u1"})
+	require.NoError(t, err)
+	assert.Equal(t, export.TypePersonalData, e.Type)
+	assert.Equal(t, export.FormatZIP, e.Format, "zip is the default format")
+	assert.Equal(t, []string{e.ID}, publisher.queued)
+
+	_, err = handler.Handle(context.Background(), commands.RequestDataExportCommand{UserID: "u1", Format: export.FormatJSON})
+	assert.ErrorIs(t, err, commands.ErrDataExportPending)
+
+	_, err = handler.Handle(context.Background(), commands.RequestDataExportCommand{UserID: "u2", Format: export.FormatCSV})
+	assert.ErrorIs(t, err, commands.ErrInvalidExportData)
+}
+
+func TestRequestExportRejectsPersonalData(t *testing.T) {
+	handler := commands.NewRequestExportCommandHandler(&exportRepoStub{exports: map[string]*export.Export{}}, &exportPublisherStub{}, export.Accounting{})
+
+	_, err := handler.Handle(context.Background(), commands.RequestExportCommand{RequestedBy: "admin-1", Type: export.TypePersonalData, Format: export.FormatZIP})
+	assert.ErrorIs(t, err, commands.ErrInvalidExportData)
+}
+
+func TestGeneratePersonalDataExportWritesArchive(t *testing.T) {
+	repo := &exportRepoStub{exports: map[string]*export.Export{}}
+	store := &memoryStorage{objects: map[string][]byte{}}
+	notifier := &exportNotifierStub{}
+	source := &personalDataSourceStub{}
+
+	e, err := export.NewExport("u1", export.TypePersonalData, export.FormatZIP, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.NoError(t, repo.Create(context.Background(), e))
+
+	handler := commands.NewGenerateExportCommandHandler(repo, exportSourceStub{}, store, spreadsheet.Encoders(), source, archive.Archivers(), notifier, time.Hour)
+	require.NoError(t, handler.Handle(context.Background(), commands.GenerateExportCommand{ExportID: e.ID}))
+
+	assert.Equal(t, export.StatusCompleted, e.Status)
+	assert.Equal(t, []string{"u1"}, source.requested)
+	assert.Equal(t, "exports/u1/personal_data-"+e.ID+".zip", e.ObjectKey)
+	assert.Equal(t, 4, e.RowCount)
+	require.Len(t, notifier.urls, 1)
+	assert.Equal(t, "https://files.test/"+e.ObjectKey, notifier.urls[0])
+
+	data := store.objects[e.ObjectKey]
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		files[f.Name] = content
+	}
+
+	for _, name := range []string{"profile.json", "addresses.json", "orders.json", "reviews.json", "linked_accounts.json", "analytics_events.json", "manifest.json"} {
+		assert.Contains(t, files, name)
+	}
+	assert.NotContains(t, string(files["profile.json"]), "hash", "password hashes are never exported")
+
+	var manifest struct {
+		UserID string `json:"user_id"`
+		Files  []struct {
+			Name    string `json:"name"`
+			Records int    `json:"records"`
+		} `json:"files"`
+	}
+	require.NoError(t, json.Unmarshal(files["manifest.json"], &manifest))
+	assert.Equal(t, "u1", manifest.UserID)
+	require.Len(t, manifest.Files, 6)
+	assert.Equal(t, "orders.json", manifest.Files[2].Name)
+	assert.Equal(t, 1, manifest.Files[2].Records)
+}
+
+func TestJSONArchiverWritesSingleDocument(t *testing.T) {
+	data, err := (&personalDataSourceStub{}).LoadPersonalData(context.Background(), "u1")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, archive.NewJSONArchiver().Archive(&buf, data))
+
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, "u1", doc["user_id"])
+	assert.Len(t, doc["orders"], 1)
+	assert.Len(t, doc["analytics_events"], 1)
+}
+
+func TestGeneratePersonalDataExportWithoutSourceFails(t *testing.T) {
+	repo := &exportRepoStub{exports: map[string]*export.Export{}}
+	notifier := &exportNotifierStub{}
+
+	e, err := export.NewExport("u1", export.TypePersonalData, export.FormatJSON, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.NoError(t, repo.Create(context.Background(), e))
+
+	handler := commands.NewGenerateExportCommandHandler(repo, exportSourceStub{err: errors.New("unused")}, &memoryStorage{objects: map[string][]byte{}}, spreadsheet.Encoders(), nil, nil, notifier, time.Hour)
+	require.NoError(t, handler.Handle(context.Background(), commands.GenerateExportCommand{ExportID: e.ID}))
+
+	assert.Equal(t, export.StatusFailed, e.Status)
+	require.Len(t, notifier.urls, 1)
+	assert.Empty(t, notifier.urls[0])
+}
@@ -0,0 +1,251 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/pipeline"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/payment"
+	"online-shop/internal/domain/product"
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/config"
+)
+
+type renameCommand struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type countQuery struct{}
+
+type batchCommand struct{}
+
+func (batchCommand) NonTransactional() {}
+
+// flakyProductRepo fails with an unavailable dependency a number of times
+// before answering
+type flakyProductRepo struct {
+	product.Repository
+	failures int
+	calls    int
+}
+
+func (r *flakyProductRepo) GetByID(ctx context.Context, id string) (*product.Product, error) {
+	r.calls++
+	if r.calls <= r.failures {
+		return nil, apperror.ErrUnavailable
+	}
+	return &product.Product{ID: id}, nil
+}
+
+// recordingTransactor records the context each transaction handed on
+type recordingTransactor struct {
+	began int
+}
+
+type inTxKey struct{}
+
+func (t *recordingTransactor) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	t.began++
+	return fn(context.WithValue(ctx, inTxKey{}, true))
+}
+
+// rollbackTransactor holds back the writes made through a txPaymentRepo
+// until the command succeeds, and drops them when it fails, as a database
+// rollback would
+type rollbackTransactor struct{}
+
+type stagedWrites struct {
+	writes []func()
+}
+
+type stagedKey struct{}
+
+func (rollbackTransactor) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	staged := &stagedWrites{}
+	if err := fn(context.WithValue(ctx, stagedKey{}, staged)); err != nil {
+		return err
+	}
+	for _, write := range staged.writes {
+		write()
+	}
+	return nil
+}
+
+// txPaymentRepo creates payments in the transaction of the context, when
+// there is one, and fails every update
+type txPaymentRepo struct {
+	memoryPaymentRepo
+}
+
+func (r *txPaymentRepo) Create(ctx context.Context, p *payment.Payment) error {
+	write := func() { r.payments = append(r.payments, p) }
+	if staged, ok := ctx.Value(stagedKey{}).(*stagedWrites); ok {
+		staged.writes = append(staged.writes, write)
+		return nil
+	}
+	write()
+	return nil
+}
+
+func (r *txPaymentRepo) Update(ctx context.Context, p *payment.Payment) error {
+	return errors.New("connection reset")
+}
+
+func usePipeline(t *testing.T, p *pipeline.Pipeline) {
+	t.Helper()
+	pipeline.Use(p)
+	t.Cleanup(func() { pipeline.Use(pipeline.New()) })
+}
+
+func TestPipelineRunsBehaviorsInOrder(t *testing.T) {
+	var order []string
+	record := func(name string) pipeline.Behavior {
+		return func(ctx context.Context, req pipeline.Request, next pipeline.Next) error {
+			order = append(order, name+" "+req.Name+" "+string(req.Kind))
+			return next(ctx)
+		}
+	}
+	usePipeline(t, pipeline.New(record("outer"), record("inner")))
+
+	got, err := pipeline.Handle(context.Background(), countQuery{}, func(ctx context.Context, q countQuery) (int, error) {
+		order = append(order, "handler")
+		return 3, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, got)
+	assert.Equal(t, []string{"outer countQuery query", "inner countQuery query", "handler"}, order)
+}
+
+func TestPipelineValidatesMessages(t *testing.T) {
+	usePipeline(t, pipeline.New(pipeline.Validation()))
+
+	called := false
+	err := pipeline.Exec(context.Background(), renameCommand{}, func(ctx context.Context, cmd renameCommand) error {
+		called = true
+		return nil
+	})
+	require.Error(t, err)
+	assert.False(t, called, "invalid messages are not handled")
+	e := apperror.From(err)
+	assert.Equal(t, apperror.ErrValidationFailed.Code, e.Code)
+	require.Len(t, e.Fields, 1)
+	assert.Equal(t, "name", e.Fields[0].Field)
+}
+
+func TestPipelineRetriesQueriesOnly(t *testing.T) {
+	usePipeline(t, pipeline.New(pipeline.Retry(2, time.Millisecond)))
+
+	repo := &flakyProductRepo{failures: 2}
+	found, err := queries.NewGetProductQueryHandler(repo).Handle(context.Background(), queries.GetProductQuery{ProductID: "p-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "p-1", found.ID)
+	assert.Equal(t, 3, repo.calls)
+
+	repo = &flakyProductRepo{failures: 5}
+	_, err = queries.NewGetProductQueryHandler(repo).Handle(context.Background(), queries.GetProductQuery{ProductID: "p-1"})
+	assert.ErrorIs(t, err, apperror.ErrUnavailable)
+	assert.Equal(t, 3, repo.calls, "retries stop after the configured count")
+
+	calls := 0
+	err = pipeline.Exec(context.Background(), renameCommand{Name: "x"}, func(ctx context.Context, cmd renameCommand) error {
+		calls++
+		return apperror.ErrUnavailable
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls, "commands are never retried")
+
+	assert.False(t, pipeline.Transient(context.Canceled))
+	assert.False(t, pipeline.Transient(apperror.ErrNotFound))
+}
+
+func TestPipelineRunsCommandsInTransactions(t *testing.T) {
+	tx := &recordingTransactor{}
+	usePipeline(t, pipeline.New(pipeline.Transaction(tx)))
+
+	inTx := func(ctx context.Context) bool {
+		v, _ := ctx.Value(inTxKey{}).(bool)
+		return v
+	}
+	err := pipeline.Exec(context.Background(), renameCommand{Name: "x"}, func(ctx context.Context, cmd renameCommand) error {
+		assert.True(t, inTx(ctx), "commands run in a transaction")
+		return nil
+	})
+	require.NoError(t, err)
+
+	_, err = pipeline.Handle(context.Background(), countQuery{}, func(ctx context.Context, q countQuery) (int, error) {
+		assert.False(t, inTx(ctx), "queries run without one")
+		return 0, nil
+	})
+	require.NoError(t, err)
+
+	err = pipeline.Exec(context.Background(), batchCommand{}, func(ctx context.Context, cmd batchCommand) error {
+		assert.False(t, inTx(ctx), "commands opting out run without one")
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, tx.began)
+}
+
+func TestChargeOutlivesAFailedCheckout(t *testing.T) {
+	usePipeline(t, pipeline.New(pipeline.Transaction(rollbackTransactor{})))
+	products := &flashProductRepo{products: map[string]*product.Product{
+		"p1": {ID: "p1", Price: 100, Stock: 5, Status: product.StatusActive},
+	}}
+	orders := &flashOrderRepo{orders: map[string]*order.Order{}}
+	counter := newMemoryFlashCounter()
+	create := commands.NewCreateOrderCommandHandler(orders, products, noCommissionRepo{}, noWarehouseRepo{}, nil, &flashSaleRepoStub{}, counter, commands.CreateOrderOptions{})
+	payments := &txPaymentRepo{}
+	cancel := commands.NewCancelOrderCommandHandler(orders, products, nil, counter, payments, nil, order.CancellationPolicy{}, nil)
+	methods := newMemoryMethodRepo()
+	card := addCard(t, commands.NewAddPaymentMethodCommandHandler(methods, "midtrans"), "u1", "tok-a")
+	provider := &tokenProviderStub{result: &payment.ChargeResult{Status: payment.StatusPaid, TransactionID: "trx-1"}}
+
+	_, err := commands.NewOneClickCheckoutCommandHandler(methods, payments, provider, orders, create, cancel, nil, nil).Handle(context.Background(), commands.OneClickCheckoutCommand{
+		UserID:          "u1",
+		PaymentMethodID: card.ID,
+		Items:           []commands.CreateOrderItemCmd{{ProductID: "p1", Quantity: 1}},
+		ShippingAddress: order.Address{Street: "Jl. Merdeka 1", City: "Jakarta", PostalCode: "10110", Country: "ID"},
+	})
+	require.Error(t, err, "saving the charge's outcome fails")
+	assert.Len(t, provider.charged, 1)
+	assert.Len(t, payments.payments, 1, "the checkout runs without a transaction, so the record of the charge is kept")
+}
+
+func TestPipelineLogsFailures(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	usePipeline(t, pipeline.New(pipeline.Logging(zap.New(core))))
+
+	_ = pipeline.Exec(context.Background(), renameCommand{}, func(ctx context.Context, cmd renameCommand) error {
+		return errors.New("connection reset")
+	})
+	_ = pipeline.Exec(context.Background(), renameCommand{}, func(ctx context.Context, cmd renameCommand) error {
+		return apperror.ErrNotFound
+	})
+
+	failed := logs.FilterLevelExact(zapcore.ErrorLevel).All()
+	require.Len(t, failed, 1, "only unexpected failures are errors")
+	assert.Equal(t, "Failed to handle command", failed[0].Message)
+	assert.Equal(t, "renameCommand", failed[0].ContextMap()["command"])
+	assert.Len(t, logs.FilterLevelExact(zapcore.DebugLevel).All(), 1)
+}
+
+func TestStandardPipelineConfig(t *testing.T) {
+	assert.Equal(t, 100*time.Millisecond, config.PipelineConfig{}.RetryBackoff())
+
+	cfg := &config.Config{}
+	cfg.Pipeline = config.PipelineConfig{Retries: -1}
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "pipeline.retries must not be negative")
+}
@@ -0,0 +1,252 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/pricealert"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/user"
+)
+
+type memoryPriceAlertRepo struct {
+	watches []*pricealert.Watch
+}
+
+func (r *memoryPriceAlertRepo) Create(ctx context.Context, w *pricealert.Watch) error {
+	r.watches = append(r.watches, w)
+	return nil
+}
+
+func (r *memoryPriceAlertRepo) GetByID(ctx context.Context, id string) (*pricealert.Watch, error) {
+	for _, w := range r.watches {
+		if w.ID == id {
+			return w, nil
+		}
+	}
+	return nil, pricealert.ErrNotFound
+}
+
+func (r *memoryPriceAlertRepo) Update(ctx context.Context, w *pricealert.Watch) error {
+	return nil
+}
+
+func (r *memoryPriceAlertRepo) FindActive(ctx context.Context, userID, productID string) (*pricealert.Watch, error) {
+	for _, w := range r.watches {
+		if w.UserID == userID && w.ProductID == productID && w.IsActive() {
+			return w, nil
+		}
+	}
+	return nil, pricealert.ErrNotFound
+}
+
+func (r *memoryPriceAlertRepo) ListByUser(ctx context.Context, userID string, limit, offset int) ([]*pricealert.Watch, error) {
+	return nil, nil
+}
+
+func (r *memoryPriceAlertRepo) ListTriggered(ctx context.Context, productID string, price float64, limit int) ([]*pricealert.Watch, error) {
+	var found []*pricealert.Watch
+	for _, w := range r.watches {
+		if w.ProductID == productID && w.IsActive() && w.TargetPrice >= price && len(found) < limit {
+			found = append(found, w)
+		}
+	}
+	return found, nil
+}
+
+func (r *memoryPriceAlertRepo) ExpireBefore(ctx context.Context, at time.Time) (int64, error) {
+	var expired int64
+	for _, w := range r.watches {
+		if w.IsActive() && w.ExpiresAt.Before(at) {
+			w.Expire()
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+type memoryPriceHistoryRepo struct {
+	changes []*product.PriceChange
+}
+
+func (r *memoryPriceHistoryRepo) Record(ctx context.Context, c *product.PriceChange) error {
+	r.changes = append(r.changes, c)
+	return nil
+}
+
+func (r *memoryPriceHistoryRepo) ListByProduct(ctx context.Context, productID string, limit int) ([]*product.PriceChange, error) {
+	return nil, nil
+}
+
+func (r *memoryPriceHistoryRepo) ListUnprocessedDrops(ctx context.Context, limit int) ([]*product.PriceChange, error) {
+	var drops []*product.PriceChange
+	for _, c := range r.changes {
+		if c.ProcessedAt == nil && c.IsDrop() && len(drops) < limit {
+			drops = append(drops, c)
+		}
+	}
+	return drops, nil
+}
+
+func (r *memoryPriceHistoryRepo) MarkProcessed(ctx context.Context, ids []string) error {
+	now := time.Now()
+	for _, c := range r.changes {
+		for _, id := range ids {
+			if c.ID == id {
+				c.ProcessedAt = &now
+			}
+		}
+	}
+	return nil
+}
+
+func (r *memoryPriceHistoryRepo) unprocessed() int {
+	drops, _ := r.ListUnprocessedDrops(context.Background(), 1000)
+	return len(drops)
+}
+
+type priceAlertNotifierStub struct {
+	alerts []pricealert.Alert
+	err    error
+}
+
+func (n *priceAlertNotifierStub) PriceDropped(ctx context.Context, alert pricealert.Alert) error {
+	if n.err != nil {
+		return n.err
+	}
+	n.alerts = append(n.alerts, alert)
+	return nil
+}
+
+func TestWatchPrice(t *testing.T) {
+	products := &flashProductRepo{products: map[string]*product.Product{
+		"p1": {ID: "p1", Price: 100000, Status: product.StatusActive},
+	}}
+	watches := &memoryPriceAlertRepo{}
+	watch := commands.NewWatchPriceCommandHandler(watches, products, 30*24*time.Hour)
+
+	_, err := watch.Handle(context.Background(), commands.WatchPriceCommand{UserID: "u1", ProductID: "p1", TargetPrice: 100000})
+	assert.ErrorIs(t, err, pricealert.ErrTargetNotBelowPrice, "a watch at the current price would trigger at once")
+	_, err = watch.Handle(context.Background(), commands.WatchPriceCommand{UserID: "u1", ProductID: "missing", TargetPrice: 10})
+	assert.ErrorIs(t, err, commands.ErrProductNotFound)
+
+	w, err := watch.Handle(context.Background(), commands.WatchPriceCommand{UserID: "u1", ProductID: "p1", TargetPrice: 80000})
+	require.NoError(t, err)
+	assert.Equal(t, pricealert.ChannelEmail, w.Channel, "email is the default channel")
+	assert.Equal(t, 100000.0, w.WatchedPrice)
+
+	again, err := watch.Handle(context.Background(), commands.WatchPriceCommand{UserID: "u1", ProductID: "p1", TargetPrice: 90000, Channel: pricealert.ChannelPush})
+	require.NoError(t, err)
+	assert.Equal(t, w.ID, again.ID, "watching again keeps one watch")
+	assert.Equal(t, 90000.0, again.TargetPrice)
+	assert.Equal(t, pricealert.ChannelPush, again.Channel)
+	assert.Len(t, watches.watches, 1)
+
+	cancel := commands.NewCancelPriceAlertCommandHandler(watches)
+	assert.ErrorIs(t, cancel.Handle(context.Background(), commands.CancelPriceAlertCommand{UserID: "u2", WatchID: w.ID}), pricealert.ErrNotFound, "only the watcher can cancel")
+	require.NoError(t, cancel.Handle(context.Background(), commands.CancelPriceAlertCommand{UserID: "u1", WatchID: w.ID}))
+	assert.Equal(t, pricealert.StatusCancelled, w.Status)
+}
+
+func TestRecordPriceChange(t *testing.T) {
+	history := &memoryPriceHistoryRepo{}
+	record := commands.NewRecordPriceChangeCommandHandler(history)
+
+	require.NoError(t, record.Handle(context.Background(), commands.RecordPriceChangeCommand{ProductID: "p1", OldPrice: 100, NewPrice: 100}))
+	assert.Empty(t, history.changes, "an unchanged price is not history")
+
+	require.NoError(t, record.Handle(context.Background(), commands.RecordPriceChangeCommand{ProductID: "p1", OldPrice: 100, NewPrice: 120}))
+	require.NoError(t, record.Handle(context.Background(), commands.RecordPriceChangeCommand{ProductID: "p1", OldPrice: 120, NewPrice: 90}))
+	require.Len(t, history.changes, 2)
+	assert.False(t, history.changes[0].IsDrop())
+	assert.True(t, history.changes[1].IsDrop())
+	assert.Equal(t, 1, history.unprocessed(), "only drops wait for the alert job")
+}
+
+func TestNotifyPriceDrops(t *testing.T) {
+	products := &flashProductRepo{products: map[string]*product.Product{
+		"p1": {ID: "p1", Name: "Espresso Machine", Slug: "espresso-machine", Price: 2000000, Status: product.StatusActive},
+		"p2": {ID: "p2", Name: "Grinder", Price: 500000, Status: product.StatusActive},
+	}}
+	users := &deletionUserRepoStub{users: map[string]*user.User{
+		"u1": {ID: "u1", Status: user.StatusActive, Phone: "+62811", Locale: "id"},
+		"u2": {ID: "u2", Status: user.StatusActive},
+		"u3": {ID: "u3", Status: user.StatusDeleted},
+	}}
+	watches := &memoryPriceAlertRepo{}
+	watchAt := func(userID, productID string, target float64) *pricealert.Watch {
+		p := products.products[productID]
+		w, err := pricealert.NewWatch(userID, productID, p.Price, target, pricealert.ChannelEmail, time.Hour)
+		require.NoError(t, err)
+		watches.Create(context.Background(), w)
+		return w
+	}
+	first := watchAt("u1", "p1", 1800000)
+	second := watchAt("u2", "p1", 1900000)
+	tooLow := watchAt("u2", "p2", 300000)
+	gone := watchAt("u3", "p1", 1900000)
+	reversed := watchAt("u1", "p2", 450000)
+
+	history := &memoryPriceHistoryRepo{}
+	drop := func(productID string, price float64) {
+		p := products.products[productID]
+		history.Record(context.Background(), product.NewPriceChange(productID, p.Price, price))
+		p.Price = price
+	}
+
+	notifier := &priceAlertNotifierStub{err: errors.New("queue down")}
+	handler := commands.NewNotifyPriceDropsCommandHandler(history, watches, products, users, notifier, "https://shop.example")
+
+	sent, err := handler.Handle(context.Background(), commands.NotifyPriceDropsCommand{})
+	require.NoError(t, err)
+	assert.Zero(t, sent, "nothing dropped yet")
+
+	drop("p1", 1850000)
+	_, err = handler.Handle(context.Background(), commands.NotifyPriceDropsCommand{})
+	require.Error(t, err)
+	assert.True(t, second.IsActive(), "an alert that could not be queued is retried")
+	assert.Equal(t, 1, history.unprocessed())
+
+	notifier.err = nil
+	sent, err = handler.Handle(context.Background(), commands.NotifyPriceDropsCommand{BatchSize: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	require.Len(t, notifier.alerts, 1)
+	alert := notifier.alerts[0]
+	assert.Equal(t, second.ID, alert.Watch.ID)
+	assert.Equal(t, 1850000.0, alert.Price)
+	assert.Equal(t, "https://shop.example/products/espresso-machine", alert.ProductURL)
+	assert.Equal(t, pricealert.StatusNotified, second.Status)
+	require.NotNil(t, second.NotifiedPrice)
+	assert.Equal(t, 1850000.0, *second.NotifiedPrice)
+	assert.Equal(t, 1, history.unprocessed(), "a full batch leaves the drop for the next run")
+
+	sent, err = handler.Handle(context.Background(), commands.NotifyPriceDropsCommand{})
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+	assert.Equal(t, pricealert.StatusExpired, gone.Status, "deleted users are not notified")
+	assert.True(t, first.IsActive(), "the price is still above its target")
+	assert.Zero(t, history.unprocessed())
+
+	drop("p2", 400000)
+	products.products["p2"].Price = 520000
+	sent, err = handler.Handle(context.Background(), commands.NotifyPriceDropsCommand{})
+	require.NoError(t, err)
+	assert.Zero(t, sent, "watches are checked against the current price")
+	assert.True(t, reversed.IsActive())
+	assert.True(t, tooLow.IsActive())
+	assert.Zero(t, history.unprocessed())
+
+	drop("p1", 1700000)
+	sent, err = handler.Handle(context.Background(), commands.NotifyPriceDropsCommand{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, pricealert.StatusNotified, first.Status)
+	assert.Equal(t, "+62811", notifier.alerts[1].Phone)
+}
@@ -0,0 +1,186 @@
+package unit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/product"
+	"online-shop/pkg/apperror"
+)
+
+type memoryTemplateRepo struct {
+	templates map[string][]*product.AttributeDefinition
+}
+
+func (r *memoryTemplateRepo) ListByCategory(ctx context.Context, categoryID string) ([]*product.AttributeDefinition, error) {
+	return r.templates[categoryID], nil
+}
+
+func (r *memoryTemplateRepo) Replace(ctx context.Context, categoryID string, defs []*product.AttributeDefinition) error {
+	r.templates[categoryID] = defs
+	return nil
+}
+
+type attributeCategoryRepo struct {
+	product.CategoryRepository
+}
+
+func (attributeCategoryRepo) GetByID(ctx context.Context, id string) (*product.Category, error) {
+	if id != "laptops" {
+		return nil, assert.AnError
+	}
+	return &product.Category{ID: id}, nil
+}
+
+// attributeProductRepo saves products set by the attribute commands
+type attributeProductRepo struct {
+	*flashProductRepo
+}
+
+func (r attributeProductRepo) Update(ctx context.Context, p *product.Product) error {
+	r.products[p.ID] = p
+	return nil
+}
+
+var laptopTemplate = []commands.AttributeDefinitionCmd{
+	{Key: "Screen_Size", Label: "Screen size", Type: product.AttributeNumber, Unit: "in", Required: true},
+	{Key: "colour", Type: product.AttributeEnum, Options: []string{"Silver", "Space Grey", " "}},
+	{Key: "touchscreen", Type: product.AttributeBoolean},
+	{Key: "notes"},
+}
+
+func TestCategoryAttributeTemplate(t *testing.T) {
+	templates := &memoryTemplateRepo{templates: map[string][]*product.AttributeDefinition{}}
+	handler := commands.NewSetCategoryAttributesCommandHandler(attributeCategoryRepo{}, templates)
+
+	template, err := handler.Handle(context.Background(), commands.SetCategoryAttributesCommand{CategoryID: "laptops", Attributes: laptopTemplate})
+	require.NoError(t, err)
+	require.Len(t, template, 4)
+	assert.Equal(t, "screen_size", template[0].Key)
+	assert.Equal(t, []string{"Silver", "Space Grey"}, template[1].Options)
+	assert.Equal(t, "colour", template[1].Label, "the key labels an attribute without one")
+	assert.Equal(t, product.AttributeText, template[3].Type)
+	assert.Equal(t, 3, template[3].Position)
+	assert.Equal(t, template, templates.templates["laptops"])
+
+	invalid := [][]commands.AttributeDefinitionCmd{
+		{{Key: "colour", Type: product.AttributeEnum}},
+		{{Key: "weight", Type: product.AttributeNumber, Options: []string{"1"}}},
+		{{Key: "weight"}, {Key: "Weight"}},
+		{{Key: "screen size"}},
+	}
+	for _, attrs := range invalid {
+		_, err := handler.Handle(context.Background(), commands.SetCategoryAttributesCommand{CategoryID: "laptops", Attributes: attrs})
+		assert.Equal(t, commands.ErrInvalidAttributeTemplate.Code, apperror.From(err).Code, "%+v", attrs)
+	}
+
+	_, err = handler.Handle(context.Background(), commands.SetCategoryAttributesCommand{CategoryID: "missing"})
+	assert.ErrorIs(t, err, commands.ErrCategoryNotFound)
+}
+
+func TestSetProductAttributes(t *testing.T) {
+	templates := &memoryTemplateRepo{templates: map[string][]*product.AttributeDefinition{}}
+	_, err := commands.NewSetCategoryAttributesCommandHandler(attributeCategoryRepo{}, templates).
+		Handle(context.Background(), commands.SetCategoryAttributesCommand{CategoryID: "laptops", Attributes: laptopTemplate})
+	require.NoError(t, err)
+
+	products := attributeProductRepo{&flashProductRepo{products: map[string]*product.Product{
+		"p1": {ID: "p1", CategoryID: "laptops", Status: product.StatusActive},
+		"p2": {ID: "p2", Status: product.StatusActive},
+	}}}
+	handler := commands.NewSetProductAttributesCommandHandler(products, templates, nil)
+
+	p, err := handler.Handle(context.Background(), commands.SetProductAttributesCommand{ProductID: "p1", Attributes: []commands.AttributeValueCmd{
+		{Key: "touchscreen", Value: "1"},
+		{Key: "colour", Value: "space grey"},
+		{Key: "screen_size", Value: "14.0", Type: product.AttributeText, Unit: "cm"},
+		{Key: "notes", Value: ""},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, []product.Attribute{
+		{Key: "screen_size", Label: "Screen size", Type: product.AttributeNumber, Value: "14", Unit: "in"},
+		{Key: "colour", Label: "colour", Type: product.AttributeEnum, Value: "Space Grey"},
+		{Key: "touchscreen", Label: "touchscreen", Type: product.AttributeBoolean, Value: "true"},
+	}, p.Attributes, "values are typed by the template, in its order, and empty ones are unset")
+
+	invalid := [][]commands.AttributeValueCmd{
+		{{Key: "screen_size", Value: "large"}},
+		{{Key: "screen_size", Value: "14"}, {Key: "colour", Value: "Gold"}},
+		{{Key: "screen_size", Value: "14"}, {Key: "weight", Value: "1.2"}},
+		{{Key: "colour", Value: "Silver"}},
+	}
+	for _, attrs := range invalid {
+		_, err := handler.Handle(context.Background(), commands.SetProductAttributesCommand{ProductID: "p1", Attributes: attrs})
+		assert.Equal(t, commands.ErrInvalidProductAttribute.Code, apperror.From(err).Code, "%+v", attrs)
+	}
+
+	p, err = handler.Handle(context.Background(), commands.SetProductAttributesCommand{ProductID: "p2", Attributes: []commands.AttributeValueCmd{
+		{Key: "weight", Value: "1.50", Type: product.AttributeNumber, Unit: "kg"},
+		{Key: "material", Value: " Aluminium "},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, []product.Attribute{
+		{Key: "material", Label: "material", Type: product.AttributeText, Value: "Aluminium"},
+		{Key: "weight", Label: "weight", Type: product.AttributeNumber, Value: "1.5", Unit: "kg"},
+	}, p.Attributes, "without a template attributes keep the type given")
+}
+
+func TestParseAttributeFilter(t *testing.T) {
+	f, err := product.ParseAttributeFilter("Colour", "Silver, Space Grey,")
+	require.NoError(t, err)
+	assert.Equal(t, "colour", f.Key)
+	assert.Equal(t, []string{"Silver", "Space Grey"}, f.Values)
+
+	f, err = product.ParseAttributeFilter("screen_size", "13..")
+	require.NoError(t, err)
+	require.NotNil(t, f.Min)
+	assert.Equal(t, 13.0, *f.Min)
+	assert.Nil(t, f.Max)
+
+	for _, raw := range []string{"..", "a..b", " , "} {
+		_, err := product.ParseAttributeFilter("screen_size", raw)
+		assert.ErrorIs(t, err, product.ErrInvalidAttribute, raw)
+	}
+}
+
+func TestCompareProducts(t *testing.T) {
+	products := &flashProductRepo{products: map[string]*product.Product{
+		"p1": {ID: "p1", Status: product.StatusActive, Attributes: []product.Attribute{
+			{Key: "screen_size", Label: "Screen size", Type: product.AttributeNumber, Value: "14", Unit: "in"},
+			{Key: "colour", Label: "Colour", Type: product.AttributeEnum, Value: "Silver"},
+		}},
+		"p2": {ID: "p2", Status: product.StatusActive, Attributes: []product.Attribute{
+			{Key: "screen_size", Label: "Screen size", Type: product.AttributeNumber, Value: "14", Unit: "in"},
+			{Key: "colour", Label: "Colour", Type: product.AttributeEnum, Value: "Space Grey"},
+			{Key: "touchscreen", Label: "Touchscreen", Type: product.AttributeBoolean, Value: "true"},
+		}},
+		"p3": {ID: "p3", Status: product.StatusArchived},
+	}}
+	handler := queries.NewCompareProductsQueryHandler(products)
+
+	comparison, err := handler.Handle(context.Background(), queries.CompareProductsQuery{ProductIDs: []string{"p2", "p1", "p2"}})
+	require.NoError(t, err)
+	require.Len(t, comparison.Products, 2)
+	assert.Equal(t, "p2", comparison.Products[0].ID, "products keep the order asked for")
+
+	require.Len(t, comparison.Attributes, 3)
+	rows := map[string]product.ComparisonRow{}
+	for _, row := range comparison.Attributes {
+		rows[row.Key] = row
+	}
+	assert.False(t, rows["screen_size"].Differs)
+	assert.True(t, rows["colour"].Differs)
+	assert.True(t, rows["touchscreen"].Differs)
+	assert.Nil(t, rows["touchscreen"].Values[1], "p1 has no touchscreen attribute")
+
+	_, err = handler.Handle(context.Background(), queries.CompareProductsQuery{ProductIDs: []string{"p1"}})
+	assert.Equal(t, commands.ErrInvalidProductComparison.Code, apperror.From(err).Code)
+
+	_, err = handler.Handle(context.Background(), queries.CompareProductsQuery{ProductIDs: []string{"p1", "p3"}})
+	assert.Equal(t, commands.ErrProductNotFound.Code, apperror.From(err).Code, "inactive products cannot be compared")
+}
@@ -0,0 +1,157 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/product"
+	"online-shop/pkg/apperror"
+)
+
+func TestProductStatusTransitions(t *testing.T) {
+	now := time.Now()
+	publishAt := now.Add(time.Hour)
+	p := &product.Product{Status: product.StatusDraft}
+
+	require.NoError(t, p.SchedulePublish(&publishAt, now))
+	assert.False(t, p.DueAt(now))
+	assert.True(t, p.DueAt(publishAt))
+
+	require.NoError(t, p.SetStatus(product.StatusActive, now))
+	assert.Nil(t, p.PublishAt, "publishing drops the schedule")
+	assert.True(t, errors.Is(p.SchedulePublish(&publishAt, now), product.ErrInvalidStatus), "only drafts are scheduled")
+
+	require.NoError(t, p.SetStatus(product.StatusArchived, now))
+	require.NoError(t, p.SetStatus(product.StatusDraft, now))
+	past := now.Add(-time.Minute)
+	assert.True(t, errors.Is(p.SchedulePublish(&past, now), product.ErrInvalidStatus))
+
+	assert.True(t, errors.Is(p.SetStatus("inactive", now), product.ErrInvalidStatus))
+	deleted := &product.Product{Status: product.StatusDeleted}
+	assert.True(t, errors.Is(deleted.SetStatus(product.StatusActive, now), product.ErrInvalidStatus))
+}
+
+func TestProductDuplicate(t *testing.T) {
+	p := &product.Product{
+		ID:         "p1",
+		Name:       "Espresso Machine",
+		Slug:       "espresso-machine",
+		Price:      2000000,
+		Stock:      7,
+		Status:     product.StatusActive,
+		Images:     []string{"a.jpg", "b.jpg"},
+		SEO:        product.SEO{MetaTitle: "Espresso", CanonicalURL: "https://shop.example/products/espresso-machine"},
+		Attributes: []product.Attribute{{Key: "wattage", Value: "1200"}},
+		Badges:     []product.Badge{{Code: "sale", Label: "Sale"}},
+	}
+
+	duplicate := p.Duplicate("", time.Now())
+	assert.NotEqual(t, p.ID, duplicate.ID)
+	assert.Equal(t, "Espresso Machine (copy)", duplicate.Name)
+	assert.Equal(t, product.StatusDraft, duplicate.Status)
+	assert.Zero(t, duplicate.Stock)
+	assert.Empty(t, duplicate.Slug)
+	assert.Empty(t, duplicate.Badges)
+	assert.Empty(t, duplicate.SEO.CanonicalURL)
+	assert.Equal(t, "Espresso", duplicate.SEO.MetaTitle)
+	assert.Equal(t, p.Attributes, duplicate.Attributes)
+
+	duplicate.Images[0] = "c.jpg"
+	assert.Equal(t, "a.jpg", p.Images[0], "the images are copied")
+}
+
+type lifecycleProductRepo struct {
+	product.Repository
+	products map[string]*product.Product
+	updated  []string
+}
+
+func (r *lifecycleProductRepo) GetByID(ctx context.Context, id string) (*product.Product, error) {
+	if p, ok := r.products[id]; ok {
+		return p, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func (r *lifecycleProductRepo) Create(ctx context.Context, p *product.Product) error {
+	r.products[p.ID] = p
+	return nil
+}
+
+func (r *lifecycleProductRepo) Update(ctx context.Context, p *product.Product) error {
+	r.updated = append(r.updated, p.ID)
+	return nil
+}
+
+func (r *lifecycleProductRepo) SlugExists(ctx context.Context, slug string) (bool, error) {
+	for _, p := range r.products {
+		if p.Slug == slug {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *lifecycleProductRepo) ListDueDrafts(ctx context.Context, at time.Time, limit int) ([]*product.Product, error) {
+	var due []*product.Product
+	for _, p := range r.products {
+		if p.DueAt(at) && len(due) < limit {
+			due = append(due, p)
+		}
+	}
+	return due, nil
+}
+
+func TestDuplicateProductTakesAFreeSlug(t *testing.T) {
+	repo := &lifecycleProductRepo{products: map[string]*product.Product{
+		"p1": {ID: "p1", Name: "Grinder", Slug: "grinder", Status: product.StatusActive},
+		"p2": {ID: "p2", Name: "Grinder (copy)", Slug: "grinder-copy", Status: product.StatusDraft},
+	}}
+	handler := commands.NewDuplicateProductCommandHandler(repo)
+
+	duplicate, err := handler.Handle(context.Background(), commands.DuplicateProductCommand{ProductID: "p1"})
+	require.NoError(t, err)
+	assert.Equal(t, "grinder-copy-2", duplicate.Slug)
+	assert.Contains(t, repo.products, duplicate.ID)
+
+	_, err = handler.Handle(context.Background(), commands.DuplicateProductCommand{ProductID: "missing"})
+	assert.Equal(t, "product_not_found", apperror.From(err).Code)
+}
+
+func TestPublishScheduledProducts(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	repo := &lifecycleProductRepo{products: map[string]*product.Product{
+		"p1": {ID: "p1", Status: product.StatusDraft, PublishAt: &past},
+		"p2": {ID: "p2", Status: product.StatusDraft, PublishAt: &past},
+		"p3": {ID: "p3", Status: product.StatusDraft, PublishAt: &future},
+		"p4": {ID: "p4", Status: product.StatusDraft},
+	}}
+	handler := commands.NewPublishScheduledProductsCommandHandler(repo, repo, nil)
+
+	published, err := handler.Handle(context.Background(), commands.PublishScheduledProductsCommand{BatchSize: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 2, published)
+	assert.Equal(t, product.StatusActive, repo.products["p1"].Status)
+	assert.Nil(t, repo.products["p1"].PublishAt)
+	assert.Equal(t, product.StatusActive, repo.products["p2"].Status)
+	assert.Equal(t, product.StatusDraft, repo.products["p3"].Status)
+	assert.Equal(t, product.StatusDraft, repo.products["p4"].Status)
+}
+
+func TestSetProductStatusRefusesDeletedProducts(t *testing.T) {
+	repo := &lifecycleProductRepo{products: map[string]*product.Product{
+		"p1": {ID: "p1", Status: product.StatusDeleted},
+	}}
+	handler := commands.NewSetProductStatusCommandHandler(repo, nil)
+
+	_, err := handler.Handle(context.Background(), commands.SetProductStatusCommand{ProductID: "p1", Status: product.StatusActive})
+	assert.Equal(t, "invalid_product_status", apperror.From(err).Code)
+	assert.Empty(t, repo.updated)
+}
@@ -0,0 +1,61 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/domain/product"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"Apple iPhone 15 Pro":    "apple-iphone-15-pro",
+		"  Men's  T-Shirt (XL) ": "men-s-t-shirt-xl",
+		"Café & Kitchen":         "caf-kitchen",
+		"!!!":                    "",
+	}
+
+	for name, expected := range tests {
+		assert.Equal(t, expected, product.Slugify(name), name)
+	}
+}
+
+func TestUniqueSlug(t *testing.T) {
+	taken := map[string]bool{"shoes": true, "shoes-2": true}
+	exists := func(slug string) (bool, error) { return taken[slug], nil }
+
+	slug, err := product.UniqueSlug("shoes", exists)
+	require.NoError(t, err)
+	assert.Equal(t, "shoes-3", slug)
+
+	slug, err = product.UniqueSlug("boots", exists)
+	require.NoError(t, err)
+	assert.Equal(t, "boots", slug)
+
+	_, err = product.UniqueSlug("", exists)
+	assert.Error(t, err)
+}
+
+func TestNewProductsAndCategoriesGetUniqueSlugs(t *testing.T) {
+	taken := map[string]bool{"kopi-arabika": true}
+	exists := func(slug string) (bool, error) { return taken[slug], nil }
+
+	first, err := product.NewProduct("Kopi Arabika", "", 45000, 1, "c1", "m1", nil, exists)
+	require.NoError(t, err)
+	assert.Equal(t, "kopi-arabika-2", first.Slug)
+	taken[first.Slug] = true
+	second, err := product.NewProduct("Kopi  Arabika!", "", 45000, 1, "c1", "m1", nil, exists)
+	require.NoError(t, err)
+	assert.Equal(t, "kopi-arabika-3", second.Slug, "names that slug the same are told apart")
+
+	untitled, err := product.NewProduct("???", "", 45000, 1, "c1", "m1", nil, exists)
+	require.NoError(t, err)
+	assert.Empty(t, untitled.Slug)
+
+	categories := map[string]bool{"minuman": true}
+	category, err := product.NewCategory("Minuman", "", nil, func(slug string) (bool, error) { return categories[slug], nil })
+	require.NoError(t, err)
+	assert.Equal(t, "minuman-2", category.Slug)
+}
@@ -0,0 +1,217 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/audit"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/productversion"
+	"online-shop/pkg/apperror"
+)
+
+type memoryVersionRepo struct {
+	versions []*productversion.Version
+}
+
+func (r *memoryVersionRepo) Get(ctx context.Context, productID string, number int) (*productversion.Version, error) {
+	for _, v := range r.versions {
+		if v.ProductID == productID && v.Number == number {
+			return v, nil
+		}
+	}
+	return nil, productversion.ErrVersionNotFound
+}
+
+func (r *memoryVersionRepo) Latest(ctx context.Context, productID string) (*productversion.Version, error) {
+	var latest *productversion.Version
+	for _, v := range r.versions {
+		if v.ProductID == productID && (latest == nil || v.Number > latest.Number) {
+			latest = v
+		}
+	}
+	if latest == nil {
+		return nil, productversion.ErrVersionNotFound
+	}
+	return latest, nil
+}
+
+func (r *memoryVersionRepo) List(ctx context.Context, productID string, limit, offset int) ([]*productversion.Version, int64, error) {
+	var versions []*productversion.Version
+	for i := len(r.versions) - 1; i >= 0; i-- {
+		if r.versions[i].ProductID == productID {
+			versions = append(versions, r.versions[i])
+		}
+	}
+	return versions, int64(len(versions)), nil
+}
+
+// record versions the product the way the version plugin does on writes
+func (r *memoryVersionRepo) record(ctx context.Context, p *product.Product, action productversion.Action) {
+	latest, _ := r.Latest(ctx, p.ID)
+	snapshot := productversion.Take(p)
+	if latest != nil && latest.Snapshot.Equal(snapshot) {
+		return
+	}
+	r.versions = append(r.versions, productversion.Next(ctx, latest, p.ID, snapshot, action, time.Now()))
+}
+
+// versionedProductRepo records a version on every product update
+type versionedProductRepo struct {
+	*lifecycleProductRepo
+	versions *memoryVersionRepo
+}
+
+func (r *versionedProductRepo) Update(ctx context.Context, p *product.Product) error {
+	r.versions.record(ctx, p, productversion.ActionUpdate)
+	return r.lifecycleProductRepo.Update(ctx, p)
+}
+
+type versionAuditStub struct {
+	audit.Repository
+	entries []*audit.Entry
+}
+
+func (r *versionAuditStub) Create(ctx context.Context, entry *audit.Entry) error {
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+func TestProductVersionSnapshotsContent(t *testing.T) {
+	p := &product.Product{ID: "p1", Name: "Grinder", Price: 80, CategoryID: "laptops", Stock: 4, Status: product.StatusActive}
+	before := productversion.Take(p)
+
+	p.Stock = 2
+	p.Status = product.StatusArchived
+	assert.True(t, before.Equal(productversion.Take(p)), "stock and status are not content")
+
+	p.Images = []string{}
+	assert.True(t, before.Equal(productversion.Take(p)), "empty and nil lists are the same")
+
+	p.Price = 95
+	p.SEO.MetaTitle = "Burr grinder"
+	after := productversion.Take(p)
+	assert.False(t, before.Equal(after))
+
+	changes := productversion.Diff(before, after)
+	assert.Len(t, changes, 2)
+	assert.Equal(t, audit.Change{Before: 80.0, After: 95.0}, changes["price"])
+	assert.Contains(t, changes, "seo")
+}
+
+func TestProductVersionNextNumbersAndRecordsActor(t *testing.T) {
+	ctx := audit.WithActor(context.Background(), audit.Actor{ID: "admin-1", Role: "admin", RequestID: "req-1"})
+	snapshot := productversion.Snapshot{Name: "Grinder"}
+
+	first := productversion.Next(context.Background(), nil, "p1", snapshot, productversion.ActionCreate, time.Now())
+	assert.Equal(t, 1, first.Number)
+	assert.Equal(t, audit.SystemActor, first.ActorID)
+
+	second := productversion.Next(ctx, first, "p1", snapshot, productversion.ActionUpdate, time.Now())
+	assert.Equal(t, 2, second.Number)
+	assert.Equal(t, productversion.ActionUpdate, second.Action)
+	assert.Equal(t, "admin-1", second.ActorID)
+	assert.Equal(t, "req-1", second.RequestID)
+
+	rollback := productversion.Next(productversion.WithRollback(ctx, 1), second, "p1", snapshot, productversion.ActionUpdate, time.Now())
+	assert.Equal(t, 3, rollback.Number)
+	assert.Equal(t, productversion.ActionRollback, rollback.Action)
+	assert.Equal(t, 1, rollback.RolledBackTo)
+}
+
+func TestRollbackProductRestoresVersion(t *testing.T) {
+	ctx := context.Background()
+	versions := &memoryVersionRepo{}
+	p := &product.Product{ID: "p1", Name: "Grinder", Price: 80, CategoryID: "laptops", Stock: 4, Status: product.StatusActive}
+	versions.record(ctx, p, productversion.ActionCreate)
+	products := &versionedProductRepo{
+		lifecycleProductRepo: &lifecycleProductRepo{products: map[string]*product.Product{"p1": p}},
+		versions:             versions,
+	}
+
+	p.Name = "Burr grinder"
+	p.Price = 95
+	p.Stock = 1
+	require.NoError(t, products.Update(ctx, p))
+	require.Len(t, versions.versions, 2)
+
+	auditRepo := &versionAuditStub{}
+	index := &syncIndex{}
+	cache := &syncCache{}
+	handler := commands.NewRollbackProductCommandHandler(products, attributeCategoryRepo{}, versions, auditRepo, nil, index, cache)
+
+	restored, err := handler.Handle(ctx, commands.RollbackProductCommand{ProductID: "p1", Version: 1, ActorID: "admin-1", RequestID: "req-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "Grinder", restored.Name)
+	assert.Equal(t, 80.0, restored.Price)
+	assert.Equal(t, 1, restored.Stock, "stock is not rolled back")
+
+	require.Len(t, versions.versions, 3)
+	latest := versions.versions[2]
+	assert.Equal(t, productversion.ActionRollback, latest.Action)
+	assert.Equal(t, 1, latest.RolledBackTo)
+
+	require.Len(t, auditRepo.entries, 1)
+	entry := auditRepo.entries[0]
+	assert.Equal(t, "products.rollback", entry.Action)
+	assert.Equal(t, "admin-1", entry.ActorID)
+	assert.Equal(t, audit.Change{Before: 2, After: 1}, entry.Changes["version"])
+	assert.Equal(t, audit.Change{Before: "Burr grinder", After: "Grinder"}, entry.Changes["name"])
+	assert.Equal(t, []string{"p1"}, index.indexed)
+	assert.Equal(t, []string{"p1"}, cache.invalidated)
+
+	// Rolling back to the content the product has changes nothing
+	_, err = handler.Handle(ctx, commands.RollbackProductCommand{ProductID: "p1", Version: 3})
+	require.NoError(t, err)
+	assert.Len(t, versions.versions, 3)
+	assert.Len(t, auditRepo.entries, 1)
+}
+
+func TestRollbackProductRejectsUnknownVersionAndCategory(t *testing.T) {
+	ctx := context.Background()
+	versions := &memoryVersionRepo{}
+	p := &product.Product{ID: "p1", Name: "Grinder", Price: 80, CategoryID: "retired", Status: product.StatusActive}
+	versions.record(ctx, p, productversion.ActionCreate)
+	p.CategoryID = "laptops"
+	versions.record(ctx, p, productversion.ActionUpdate)
+	products := &versionedProductRepo{
+		lifecycleProductRepo: &lifecycleProductRepo{products: map[string]*product.Product{"p1": p}},
+		versions:             versions,
+	}
+	handler := commands.NewRollbackProductCommandHandler(products, attributeCategoryRepo{}, versions, &versionAuditStub{}, nil, nil, nil)
+
+	_, err := handler.Handle(ctx, commands.RollbackProductCommand{ProductID: "p1", Version: 7})
+	assert.Equal(t, "product_version_not_found", apperror.From(err).Code)
+
+	_, err = handler.Handle(ctx, commands.RollbackProductCommand{ProductID: "p1", Version: 1})
+	assert.Equal(t, commands.ErrCategoryNotFound.Code, apperror.From(err).Code)
+	assert.Equal(t, "laptops", p.CategoryID)
+}
+
+func TestDiffProductVersionsAgainstLatest(t *testing.T) {
+	ctx := context.Background()
+	versions := &memoryVersionRepo{}
+	p := &product.Product{ID: "p1", Name: "Grinder", Price: 80}
+	versions.record(ctx, p, productversion.ActionCreate)
+	p.Price = 90
+	versions.record(ctx, p, productversion.ActionUpdate)
+	p.Description = "Conical burrs"
+	versions.record(ctx, p, productversion.ActionUpdate)
+	handler := queries.NewDiffProductVersionsQueryHandler(versions)
+
+	comparison, err := handler.Handle(ctx, queries.DiffProductVersionsQuery{ProductID: "p1", From: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 1, comparison.From)
+	assert.Equal(t, 3, comparison.To)
+	assert.Len(t, comparison.Changes, 2)
+
+	comparison, err = handler.Handle(ctx, queries.DiffProductVersionsQuery{ProductID: "p1", From: 2, To: 1})
+	require.NoError(t, err)
+	assert.Equal(t, audit.Change{Before: 90.0, After: 80.0}, comparison.Changes["price"])
+}
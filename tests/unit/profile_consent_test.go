@@ -0,0 +1,156 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/emaildelivery"
+	"online-shop/internal/domain/user"
+	"online-shop/internal/domain/whatsapp"
+	"online-shop/pkg/apperror"
+)
+
+// memoryConsentRepo answers from the consents of the users it holds
+type memoryConsentRepo struct {
+	users []*user.User
+}
+
+func (r *memoryConsentRepo) HasMarketingConsent(ctx context.Context, userID string, channel user.Channel) (bool, error) {
+	for _, u := range r.users {
+		if u.ID == userID {
+			return u.MarketingConsent.Granted(channel), nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryConsentRepo) ConsentedEmails(ctx context.Context, emails []string) (map[string]bool, error) {
+	consented := make(map[string]bool)
+	for _, email := range emails {
+		for _, u := range r.users {
+			if emaildelivery.NormalizeAddress(u.Email) == email && u.MarketingConsent.Granted(user.ChannelEmail) {
+				consented[email] = true
+			}
+		}
+	}
+	return consented, nil
+}
+
+type sentWhatsAppClient struct {
+	whatsapp.Client
+	sent []string
+}
+
+func (c *sentWhatsAppClient) SendTemplate(ctx context.Context, to string, t *whatsapp.Template, values []string) (string, error) {
+	c.sent = append(c.sent, t.Name)
+	return "wamid." + t.Name, nil
+}
+
+func TestUpdateProfileDemographicsAndConsent(t *testing.T) {
+	u, err := user.NewUser("jane@example.com", "secret123", "Jane", "Doe", "")
+	require.NoError(t, err)
+	users := &deletionUserRepoStub{users: map[string]*user.User{u.ID: u}}
+	handler := commands.NewUpdateUserProfileCommandHandler(users)
+
+	updated, err := handler.Handle(context.Background(), commands.UpdateUserProfileCommand{UserID: u.ID, Updates: map[string]interface{}{
+		"birthday":          "1990-04-21",
+		"gender":            "female",
+		"marketing_consent": map[string]interface{}{"email": true, "sms": false},
+	}})
+	require.NoError(t, err)
+	require.NotNil(t, updated.Birthday)
+	assert.Equal(t, "1990-04-21", updated.Birthday.Format(user.BirthdayLayout))
+	assert.Equal(t, user.GenderFemale, updated.Gender)
+	assert.True(t, updated.MarketingConsent.Granted(user.ChannelEmail))
+	assert.False(t, updated.MarketingConsent.Granted(user.ChannelSMS))
+	require.NotNil(t, updated.MarketingConsent.SMS.UpdatedAt, "withdrawing is recorded too")
+	assert.Nil(t, updated.MarketingConsent.Push.UpdatedAt, "never asked")
+
+	grantedAt := *updated.MarketingConsent.Email.UpdatedAt
+	updated, err = handler.Handle(context.Background(), commands.UpdateUserProfileCommand{UserID: u.ID, Updates: map[string]interface{}{
+		"birthday":          nil,
+		"marketing_consent": map[string]interface{}{"email": true},
+	}})
+	require.NoError(t, err)
+	assert.Nil(t, updated.Birthday)
+	assert.Equal(t, grantedAt, *updated.MarketingConsent.Email.UpdatedAt, "the same answer keeps when it was given")
+
+	for _, updates := range []map[string]interface{}{
+		{"birthday": "21/04/1990"},
+		{"birthday": time.Now().AddDate(0, 0, 1).Format(user.BirthdayLayout)},
+		{"gender": "unknown"},
+		{"marketing_consent": map[string]interface{}{"fax": true}},
+		{"marketing_consent": map[string]interface{}{"email": "yes"}},
+	} {
+		_, err := handler.Handle(context.Background(), commands.UpdateUserProfileCommand{UserID: u.ID, Updates: updates})
+		assert.Equal(t, commands.ErrInvalidProfile.Code, apperror.From(err).Code, "%v", updates)
+	}
+}
+
+func TestAnonymizeClearsProfileAndConsent(t *testing.T) {
+	u := &user.User{ID: "u1", Gender: user.GenderMale}
+	birthday := time.Date(1985, 1, 2, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, u.SetBirthday(&birthday, time.Now()))
+	require.NoError(t, u.SetMarketingConsent(user.ChannelWhatsApp, true, time.Now()))
+
+	u.Anonymize(time.Now())
+	assert.Nil(t, u.Birthday)
+	assert.Empty(t, u.Gender)
+	assert.Equal(t, user.MarketingConsent{}, u.MarketingConsent)
+}
+
+func TestEmailCampaignSkipsRecipientsWithoutConsent(t *testing.T) {
+	ani := &user.User{ID: "ani", Email: "ani@example.com"}
+	require.NoError(t, ani.SetMarketingConsent(user.ChannelEmail, true, time.Now()))
+	budi := &user.User{ID: "budi", Email: "budi@example.com"}
+	require.NoError(t, budi.SetMarketingConsent(user.ChannelEmail, false, time.Now()))
+	publisher := &recordingDeliveryPublisher{}
+	send := commands.NewSendEmailCampaignCommandHandler(&memoryEmailTemplateRepo{}, publisher, &memoryConsentRepo{users: []*user.User{ani, budi}}, 10)
+
+	campaign, err := send.Handle(context.Background(), commands.SendEmailCampaignCommand{
+		Template: "welcome",
+		Data:     map[string]interface{}{"FirstName": "Pelanggan"},
+		Recipients: []emaildelivery.Recipient{
+			{To: "Ani@Example.com"},
+			{To: "budi@example.com"},
+			{To: "stranger@example.com"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, campaign.Recipients)
+	assert.Equal(t, 2, campaign.Skipped)
+	require.Len(t, publisher.batches, 1)
+	assert.Equal(t, "Ani@Example.com", publisher.batches[0].Recipients[0].To)
+}
+
+func TestWhatsAppMarketingTemplatesNeedConsent(t *testing.T) {
+	templates := newMemoryWhatsAppTemplateRepo()
+	promo, err := whatsapp.NewTemplate("weekend_promo", "en", whatsapp.CategoryMarketing, "Weekend sale!", nil)
+	require.NoError(t, err)
+	promo.Status = whatsapp.StatusApproved
+	require.NoError(t, templates.Save(context.Background(), promo))
+	shipped := orderShippedTemplate(t, "en")
+	shipped.Status = whatsapp.StatusApproved
+	require.NoError(t, templates.Save(context.Background(), shipped))
+
+	u := &user.User{ID: "user-1"}
+	client := &sentWhatsAppClient{}
+	sender := whatsapp.NewSender(client, templates, &memoryWhatsAppMessageRepo{messages: map[string]*whatsapp.Message{}}, &memoryConsentRepo{users: []*user.User{u}})
+
+	_, err = sender.Send(context.Background(), whatsapp.Notification{UserID: u.ID, Type: "weekend_promo", Phone: "081234567890"})
+	assert.ErrorIs(t, err, user.ErrNoMarketingConsent)
+	assert.Equal(t, commands.ErrNoMarketingConsent.Code, apperror.From(err).Code)
+
+	_, err = sender.Send(context.Background(), whatsapp.Notification{UserID: u.ID, Type: "order_shipped", Phone: "081234567890", Data: map[string]interface{}{"FirstName": "Budi", "OrderNumber": "ORD-1"}})
+	require.NoError(t, err, "utility templates need no consent")
+
+	require.NoError(t, u.SetMarketingConsent(user.ChannelWhatsApp, true, time.Now()))
+	_, err = sender.Send(context.Background(), whatsapp.Notification{UserID: u.ID, Type: "weekend_promo", Phone: "081234567890"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"order_shipped", "weekend_promo"}, client.sent)
+}
@@ -0,0 +1,126 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/cart"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/user"
+	"online-shop/pkg/apperror"
+)
+
+// orderHistoryStub counts purchases from the orders placed in the test
+type orderHistoryStub struct {
+	orders *flashOrderRepo
+}
+
+func (h orderHistoryStub) PurchasedQuantities(ctx context.Context, userID string, productIDs []string) (map[string]int, error) {
+	quantities := map[string]int{}
+	for _, o := range h.orders.orders {
+		if o.UserID != userID || o.Status == order.StatusCancelled {
+			continue
+		}
+		for _, item := range o.Items {
+			quantities[item.ProductID] += item.Quantity
+		}
+	}
+	return quantities, nil
+}
+
+func TestPurchaseLimits(t *testing.T) {
+	p := &product.Product{ID: "p1", Name: "Kopi"}
+	assert.ErrorIs(t, p.SetLimits(product.PurchaseLimits{MaxPerOrder: -1}, time.Now()), product.ErrInvalidLimits)
+	assert.ErrorIs(t, p.SetLimits(product.PurchaseLimits{MaxPerOrder: 5, MaxPerCustomer: 3}, time.Now()), product.ErrInvalidLimits)
+	require.NoError(t, p.SetLimits(product.PurchaseLimits{MaxPerOrder: 2, MaxPerCustomer: 3}, time.Now()))
+
+	assert.NoError(t, p.CheckLimits(2, 0))
+	assert.ErrorIs(t, p.CheckLimits(3, 0), product.ErrLimitExceeded)
+	assert.NoError(t, p.CheckLimits(1, 2))
+	assert.EqualError(t, p.CheckLimits(2, 2), "purchase limit exceeded: at most 1 more of Kopi for you")
+	assert.ErrorIs(t, p.CheckLimits(1, 3), product.ErrLimitExceeded)
+
+	bundle := &product.Product{ID: "b1", Components: []product.Component{{ProductID: "p1", Quantity: 2}}}
+	assert.ErrorIs(t, bundle.SetLimits(product.PurchaseLimits{MaxPerOrder: 1}, time.Now()), product.ErrInvalidLimits, "bundles take their products' limits")
+}
+
+func TestVerifiedAge(t *testing.T) {
+	now := time.Date(2026, 6, 15, 12, 0, 0, 0, time.UTC)
+	u := &user.User{}
+	_, verified := u.VerifiedAge(now)
+	assert.False(t, verified)
+
+	birthday := time.Date(2005, 6, 16, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, u.SetBirthday(&birthday, now))
+	_, verified = u.VerifiedAge(now)
+	assert.False(t, verified, "a birthday given by the user is not verified")
+
+	require.NoError(t, u.VerifyAge(birthday, now))
+	age, verified := u.VerifiedAge(now)
+	assert.True(t, verified)
+	assert.Equal(t, 20, age, "the day before their birthday")
+	age, _ = u.VerifiedAge(now.AddDate(0, 0, 1))
+	assert.Equal(t, 21, age)
+
+	require.NoError(t, u.SetBirthday(&birthday, now))
+	_, verified = u.VerifiedAge(now)
+	assert.True(t, verified, "saving the same birthday keeps the verification")
+
+	earlier := birthday.AddDate(-5, 0, 0)
+	require.NoError(t, u.SetBirthday(&earlier, now))
+	_, verified = u.VerifiedAge(now)
+	assert.False(t, verified, "changing the birthday undoes the verification")
+}
+
+func TestCheckoutEnforcesPurchaseRules(t *testing.T) {
+	spirits := &product.Category{ID: "c1", Name: "Spirits"}
+	require.NoError(t, spirits.SetMinimumAge(21, time.Now()))
+	products := &flashProductRepo{products: map[string]*product.Product{
+		"p1": {ID: "p1", Name: "Kopi", Price: 50000, Stock: 50, Status: product.StatusActive, Limits: product.PurchaseLimits{MaxPerOrder: 3, MaxPerCustomer: 4}},
+		"p2": {ID: "p2", Name: "Arak", Price: 150000, Stock: 50, Status: product.StatusActive, CategoryID: "c1", Category: spirits},
+		"p3": {ID: "p3", Name: "Permen", Price: 5000, Stock: 50, Status: product.StatusActive},
+	}}
+	orders := &flashOrderRepo{orders: map[string]*order.Order{}}
+	adult := &user.User{ID: "u1"}
+	require.NoError(t, adult.VerifyAge(time.Now().AddDate(-30, 0, 0), time.Now()))
+	users := &deletionUserRepoStub{users: map[string]*user.User{"u1": adult, "u2": {ID: "u2"}}}
+	rules := commands.NewPurchaseRules(users, orderHistoryStub{orders: orders}, 20000)
+	create := commands.NewCreateOrderCommandHandler(orders, products, noCommissionRepo{}, noWarehouseRepo{}, nil, &flashSaleRepoStub{}, newMemoryFlashCounter(), commands.CreateOrderOptions{PurchaseRules: rules})
+	address := order.Address{Street: "Jl. Merdeka 1", City: "Jakarta", PostalCode: "10110", Country: "ID"}
+	buy := func(userID string, items ...commands.CreateOrderItemCmd) error {
+		_, err := create.Handle(context.Background(), commands.CreateOrderCommand{UserID: userID, Items: items, ShippingAddress: address})
+		return err
+	}
+
+	err := buy("u1", commands.CreateOrderItemCmd{ProductID: "p3", Quantity: 2})
+	assert.Equal(t, commands.ErrBelowMinimumOrderValue.Code, apperror.From(err).Code)
+
+	err = buy("u1", commands.CreateOrderItemCmd{ProductID: "p1", Quantity: 4})
+	assert.Equal(t, commands.ErrPurchaseLimitExceeded.Code, apperror.From(err).Code, "over the limit per order")
+	require.NoError(t, buy("u1", commands.CreateOrderItemCmd{ProductID: "p1", Quantity: 3}))
+	err = buy("u1", commands.CreateOrderItemCmd{ProductID: "p1", Quantity: 2})
+	assert.Equal(t, commands.ErrPurchaseLimitExceeded.Code, apperror.From(err).Code, "over the limit per customer")
+	assert.NoError(t, buy("u2", commands.CreateOrderItemCmd{ProductID: "p1", Quantity: 2}), "another customer's orders do not count")
+
+	require.NoError(t, buy("u1", commands.CreateOrderItemCmd{ProductID: "p2", Quantity: 1}))
+	err = buy("u2", commands.CreateOrderItemCmd{ProductID: "p2", Quantity: 1})
+	assert.Equal(t, commands.ErrAgeRestricted.Code, apperror.From(err).Code)
+	assert.Contains(t, apperror.From(err).Detail, "verify your age")
+
+	carts := newMemoryCartRepo()
+	add := commands.NewAddToCartCommandHandler(carts, products, rules)
+	_, err = add.Handle(context.Background(), commands.AddToCartCommand{Owner: cart.Owner{UserID: "u2"}, ProductID: "p2", Quantity: 1})
+	assert.Equal(t, commands.ErrAgeRestricted.Code, apperror.From(err).Code, "the cart checks signed-in customers")
+	_, err = add.Handle(context.Background(), commands.AddToCartCommand{Owner: cart.Owner{Token: "t"}, ProductID: "p2", Quantity: 1})
+	assert.NoError(t, err, "anonymous visitors are checked at checkout")
+	_, err = add.Handle(context.Background(), commands.AddToCartCommand{Owner: cart.Owner{Token: "t"}, ProductID: "p1", Quantity: 4})
+	assert.Equal(t, commands.ErrPurchaseLimitExceeded.Code, apperror.From(err).Code)
+	_, err = add.Handle(context.Background(), commands.AddToCartCommand{Owner: cart.Owner{UserID: "u1"}, ProductID: "p1", Quantity: 2})
+	assert.Equal(t, commands.ErrPurchaseLimitExceeded.Code, apperror.From(err).Code, "u1 has one left")
+}
@@ -0,0 +1,53 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/infrastructure/queue"
+	"online-shop/pkg/config"
+)
+
+func TestQueueConsumerDefaults(t *testing.T) {
+	workers := config.WorkersConfig{
+		Queues: map[string]config.QueueConsumerConfig{
+			queue.EmailQueue: {MinConsumers: 2, MaxConsumers: 8, BacklogPerConsumer: 50, MaxPriority: 10},
+		},
+	}
+
+	email := workers.Queue(queue.EmailQueue, 3)
+	assert.Equal(t, 8, email.MaxConsumers, "a configured maximum wins over the worker count")
+	assert.Equal(t, 8, email.Prefetch, "prefetch defaults to one message per consumer")
+	assert.Equal(t, 10, email.MaxPriority)
+
+	invoice := workers.Queue(queue.InvoiceQueue, 3)
+	assert.Equal(t, config.QueueConsumerConfig{Prefetch: 3, MinConsumers: 1, MaxConsumers: 3, BacklogPerConsumer: 10}, invoice)
+
+	assert.Equal(t, 1, workers.Queue(queue.AccountEventsQueue, 0).MaxConsumers)
+	assert.Equal(t, 10*time.Second, workers.ScaleInterval())
+}
+
+func TestConsumerCountFollowsBacklog(t *testing.T) {
+	cfg := config.QueueConsumerConfig{MinConsumers: 2, MaxConsumers: 6, BacklogPerConsumer: 50}
+
+	assert.Equal(t, 2, queue.ConsumerCount(0, cfg))
+	assert.Equal(t, 2, queue.ConsumerCount(49, cfg))
+	assert.Equal(t, 3, queue.ConsumerCount(50, cfg))
+	assert.Equal(t, 5, queue.ConsumerCount(160, cfg))
+	assert.Equal(t, 6, queue.ConsumerCount(10000, cfg), "never more than the maximum")
+}
+
+func TestQueueConsumerConfigValidation(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Workers.Queues = map[string]config.QueueConsumerConfig{
+		queue.EmailQueue:  {MinConsumers: 4, MaxConsumers: 2},
+		queue.ExportQueue: {MaxPriority: 300},
+	}
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "workers.queues.email_queue: min_consumers cannot be more than max_consumers")
+	assert.Contains(t, err.Error(), "workers.queues.export_queue.max_priority must be between 0 and 255")
+}
@@ -0,0 +1,144 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/quote"
+	"online-shop/pkg/apperror"
+)
+
+type memoryQuoteRepo struct {
+	quotes map[string]*quote.Quote
+}
+
+func (r *memoryQuoteRepo) Create(ctx context.Context, q *quote.Quote) error {
+	r.quotes[q.ID] = q
+	return nil
+}
+
+func (r *memoryQuoteRepo) GetByID(ctx context.Context, id string) (*quote.Quote, error) {
+	q, ok := r.quotes[id]
+	if !ok {
+		return nil, quote.ErrNotFound
+	}
+	copied := *q
+	return &copied, nil
+}
+
+func (r *memoryQuoteRepo) Update(ctx context.Context, q *quote.Quote) error {
+	r.quotes[q.ID] = q
+	return nil
+}
+
+func (r *memoryQuoteRepo) List(ctx context.Context, filter quote.Filter, limit, offset int) ([]*quote.Quote, error) {
+	var quotes []*quote.Quote
+	for _, q := range r.quotes {
+		quotes = append(quotes, q)
+	}
+	return quotes, nil
+}
+
+func TestQuoteRequestsAreForOneMerchantAndEnoughUnits(t *testing.T) {
+	products := &flashProductRepo{products: map[string]*product.Product{
+		"beans": {ID: "beans", MerchantID: "m1", Price: 50000, Stock: 100, Status: product.StatusActive},
+		"mug":   {ID: "mug", MerchantID: "m2", Price: 25000, Stock: 100, Status: product.StatusActive},
+	}}
+	request := commands.NewRequestQuoteCommandHandler(&memoryQuoteRepo{quotes: map[string]*quote.Quote{}}, products, nil, 10)
+
+	_, err := request.Handle(context.Background(), commands.RequestQuoteCommand{UserID: "u1", Company: "Kopi Nusantara", Items: []commands.QuoteItemCmd{
+		{ProductID: "beans", Quantity: 5},
+	}})
+	assert.ErrorIs(t, err, quote.ErrInvalidRequest, "fewer units than the minimum")
+
+	_, err = request.Handle(context.Background(), commands.RequestQuoteCommand{UserID: "u1", Company: "Kopi Nusantara", Items: []commands.QuoteItemCmd{
+		{ProductID: "beans", Quantity: 10},
+		{ProductID: "mug", Quantity: 10},
+	}})
+	assert.Equal(t, commands.ErrInvalidQuoteRequest.Code, apperror.From(err).Code, "products of two merchants")
+
+	q, err := request.Handle(context.Background(), commands.RequestQuoteCommand{UserID: "u1", Company: "Kopi Nusantara", Items: []commands.QuoteItemCmd{
+		{ProductID: "beans", Quantity: 10},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, quote.StatusRequested, q.Status)
+	assert.Equal(t, "m1", q.MerchantID)
+	assert.Equal(t, 50000.0, q.Items[0].ListPrice)
+}
+
+func TestAcceptedQuotesAreOrderedAtTheOfferedPrices(t *testing.T) {
+	products := &flashProductRepo{products: map[string]*product.Product{
+		"beans": {ID: "beans", MerchantID: "m1", Price: 50000, Stock: 100, Status: product.StatusActive},
+	}}
+	quotes := &memoryQuoteRepo{quotes: map[string]*quote.Quote{}}
+	orders := &flashOrderRepo{orders: map[string]*order.Order{}}
+	create := commands.NewCreateOrderCommandHandler(orders, products, noCommissionRepo{}, noWarehouseRepo{}, nil, &flashSaleRepoStub{}, newMemoryFlashCounter(), commands.CreateOrderOptions{})
+	request := commands.NewRequestQuoteCommandHandler(quotes, products, nil, 10)
+	offer := commands.NewOfferQuoteCommandHandler(quotes, 14*24*time.Hour, 90*24*time.Hour)
+	accept := commands.NewAcceptQuoteCommandHandler(quotes, create, nil)
+	address := order.Address{Street: "Jl. Merdeka 1", City: "Jakarta", PostalCode: "10110", Country: "ID"}
+
+	q, err := request.Handle(context.Background(), commands.RequestQuoteCommand{UserID: "u1", Company: "Kopi Nusantara", Items: []commands.QuoteItemCmd{
+		{ProductID: "beans", Quantity: 20},
+	}})
+	require.NoError(t, err)
+
+	_, err = accept.Handle(context.Background(), commands.AcceptQuoteCommand{QuoteID: q.ID, UserID: "u1", ShippingAddress: address})
+	assert.Equal(t, commands.ErrQuoteWrongStatus.Code, apperror.From(err).Code, "not priced yet")
+
+	_, err = offer.Handle(context.Background(), commands.OfferQuoteCommand{QuoteID: q.ID, MerchantID: "m2", Prices: []commands.QuotePriceCmd{{ProductID: "beans", Price: 40000}}})
+	assert.Equal(t, commands.ErrQuoteNotFound.Code, apperror.From(err).Code, "another merchant's quote")
+
+	tooLong := time.Now().Add(120 * 24 * time.Hour)
+	_, err = offer.Handle(context.Background(), commands.OfferQuoteCommand{QuoteID: q.ID, MerchantID: "m1", ValidUntil: &tooLong, Prices: []commands.QuotePriceCmd{{ProductID: "beans", Price: 40000}}})
+	assert.Equal(t, commands.ErrInvalidQuoteOffer.Code, apperror.From(err).Code)
+
+	offered, err := offer.Handle(context.Background(), commands.OfferQuoteCommand{QuoteID: q.ID, MerchantID: "m1", Prices: []commands.QuotePriceCmd{{ProductID: "beans", Price: 40000}}})
+	require.NoError(t, err)
+	assert.Equal(t, quote.StatusOffered, offered.Status)
+	assert.Equal(t, 800000.0, offered.TotalAmount)
+	require.NotNil(t, offered.ValidUntil)
+	assert.WithinDuration(t, time.Now().Add(14*24*time.Hour), *offered.ValidUntil, time.Minute, "the configured validity")
+
+	_, err = accept.Handle(context.Background(), commands.AcceptQuoteCommand{QuoteID: q.ID, UserID: "u2", ShippingAddress: address})
+	assert.Equal(t, commands.ErrForbidden.Code, apperror.From(err).Code)
+
+	placed, err := accept.Handle(context.Background(), commands.AcceptQuoteCommand{QuoteID: q.ID, UserID: "u1", ShippingAddress: address})
+	require.NoError(t, err)
+	assert.Equal(t, q.ID, placed.QuoteID)
+	assert.Equal(t, 40000.0, placed.Items[0].Price, "the offered price, not the list price")
+	assert.Equal(t, 800000.0, placed.TotalAmount)
+	assert.Equal(t, 80, products.products["beans"].Stock)
+
+	accepted := quotes.quotes[q.ID]
+	assert.Equal(t, quote.StatusAccepted, accepted.Status)
+	assert.Equal(t, placed.ID, accepted.OrderID)
+
+	_, err = accept.Handle(context.Background(), commands.AcceptQuoteCommand{QuoteID: q.ID, UserID: "u1", ShippingAddress: address})
+	assert.Equal(t, commands.ErrQuoteWrongStatus.Code, apperror.From(err).Code, "accepted once")
+}
+
+func TestLapsedOffersCannotBeAccepted(t *testing.T) {
+	lapsed := time.Now().Add(-time.Hour)
+	quotes := &memoryQuoteRepo{quotes: map[string]*quote.Quote{
+		"q1": {ID: "q1", UserID: "u1", MerchantID: "m1", Status: quote.StatusOffered, ValidUntil: &lapsed,
+			Items: []quote.Item{{ProductID: "beans", Quantity: 20, ListPrice: 50000, Price: 40000}}},
+	}}
+	accept := commands.NewAcceptQuoteCommandHandler(quotes, nil, nil)
+
+	_, err := accept.Handle(context.Background(), commands.AcceptQuoteCommand{QuoteID: "q1", UserID: "u1", ShippingAddress: order.Address{Street: "Jl. Merdeka 1", City: "Jakarta", PostalCode: "10110", Country: "ID"}})
+	assert.Equal(t, commands.ErrQuoteExpired.Code, apperror.From(err).Code)
+	assert.Equal(t, quote.StatusOffered, quotes.quotes["q1"].Status, "expired as it is read, so it can be priced again")
+
+	offer := commands.NewOfferQuoteCommandHandler(quotes, 14*24*time.Hour, 90*24*time.Hour)
+	revised, err := offer.Handle(context.Background(), commands.OfferQuoteCommand{QuoteID: "q1", MerchantID: "m1", Prices: []commands.QuotePriceCmd{{ProductID: "beans", Price: 42000}}})
+	require.NoError(t, err)
+	assert.Equal(t, quote.StatusOffered, revised.Status)
+}
@@ -0,0 +1,112 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"online-shop/internal/domain/ratelimit"
+	"online-shop/internal/interfaces/http/middleware"
+	"online-shop/pkg/config"
+)
+
+type countingLimiter struct {
+	mu     sync.Mutex
+	counts map[string]int
+	keys   []string
+	err    error
+}
+
+func (l *countingLimiter) Allow(ctx context.Context, key string, policy ratelimit.Policy) (*ratelimit.Result, error) {
+	if l.err != nil {
+		return nil, l.err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.keys = append(l.keys, key)
+	l.counts[key]++
+	if l.counts[key] > policy.Limit {
+		return &ratelimit.Result{Limit: policy.Limit, RetryAfter: 1500 * time.Millisecond}, nil
+	}
+	return &ratelimit.Result{Allowed: true, Limit: policy.Limit, Remaining: policy.Limit - l.counts[key]}, nil
+}
+
+func rateLimitedRouter(t *testing.T, limiter ratelimit.Limiter, role string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	rules, err := middleware.RateLimitRules(&config.RateLimitConfig{
+		Requests:      100,
+		WindowSeconds: 60,
+		ExemptRoles:   []string{"admin"},
+		ExemptCIDRs:   []string{"10.0.0.0/8"},
+		Routes: []config.RouteRateLimitConfig{
+			{Method: "POST", Path: "/orders", Requests: 1, WindowSeconds: 60, By: "user"},
+		},
+	})
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", "u1")
+		c.Set("user_role", role)
+	})
+	router.Use(middleware.DistributedRateLimit(limiter, rules, zap.NewNop()))
+	router.POST("/orders", func(c *gin.Context) { c.Status(http.StatusCreated) })
+	router.GET("/products", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router
+}
+
+func sendFrom(router *gin.Engine, method, path, remoteAddr string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, nil)
+	req.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestDistributedRateLimitAppliesRoutePolicy(t *testing.T) {
+	limiter := &countingLimiter{counts: map[string]int{}}
+	router := rateLimitedRouter(t, limiter, "customer")
+
+	assert.Equal(t, http.StatusCreated, sendFrom(router, "POST", "/orders", "1.2.3.4:1000").Code)
+
+	limited := sendFrom(router, "POST", "/orders", "5.6.7.8:1000")
+	assert.Equal(t, http.StatusTooManyRequests, limited.Code, "per-user policy ignores the IP")
+	assert.Equal(t, "2", limited.Header().Get(middleware.RetryAfterHeader))
+
+	ok := sendFrom(router, "GET", "/products", "1.2.3.4:1000")
+	assert.Equal(t, http.StatusOK, ok.Code)
+	assert.Equal(t, "99", ok.Header().Get(middleware.RateLimitRemainingHeader))
+	assert.Equal(t, "ratelimit:default:ip:1.2.3.4", limiter.keys[len(limiter.keys)-1])
+}
+
+func TestDistributedRateLimitExemptions(t *testing.T) {
+	limiter := &countingLimiter{counts: map[string]int{}}
+
+	admin := rateLimitedRouter(t, limiter, "admin")
+	internal := rateLimitedRouter(t, limiter, "customer")
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusCreated, sendFrom(admin, "POST", "/orders", "1.2.3.4:1000").Code)
+		assert.Equal(t, http.StatusCreated, sendFrom(internal, "POST", "/orders", "10.1.2.3:1000").Code)
+	}
+	assert.Empty(t, limiter.keys)
+}
+
+func TestDistributedRateLimitFailsOpen(t *testing.T) {
+	router := rateLimitedRouter(t, &countingLimiter{err: errors.New("redis down")}, "customer")
+
+	assert.Equal(t, http.StatusCreated, sendFrom(router, "POST", "/orders", "1.2.3.4:1000").Code)
+}
+
+func TestRateLimitRulesRejectInvalidPolicy(t *testing.T) {
+	_, err := middleware.RateLimitRules(&config.RateLimitConfig{Requests: 10, WindowSeconds: 60, By: "session"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,99 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/payment"
+	"online-shop/internal/domain/user"
+	"online-shop/pkg/apperror"
+)
+
+type receiptNotifierStub struct {
+	sent []payment.ReceiptNotice
+}
+
+func (n *receiptNotifierStub) PaymentReceived(ctx context.Context, notice payment.ReceiptNotice) error {
+	n.sent = append(n.sent, notice)
+	return nil
+}
+
+func TestReceiptIsEmailedOnceWhenAPaymentSettles(t *testing.T) {
+	orders := &flashOrderRepo{orders: map[string]*order.Order{}}
+	payments := &memoryPaymentRepo{}
+	users := &deletionUserRepoStub{users: map[string]*user.User{
+		"u1": {ID: "u1", Email: "jane@example.com", FirstName: "Jane", LastName: "Doe", Locale: "id"},
+	}}
+	notifier := &receiptNotifierStub{}
+	reconcile := commands.NewReconcileOrderPaymentsCommandHandler(orders, payments, nil, commands.NewReceiptSender(users, payments, notifier))
+
+	o := newPayableOrder(orders, 500)
+	o.Number = "ORD-2026-000042"
+	pay := payment.NewPayment(o.ID, "u1", 500, payment.MethodEWallet)
+	require.NoError(t, payments.Create(context.Background(), pay))
+
+	require.NoError(t, reconcile.Handle(context.Background(), commands.ReconcileOrderPaymentsCommand{OrderID: o.ID}))
+	assert.Empty(t, notifier.sent, "nothing is paid yet")
+
+	pay.MarkAsPaid("trx-1")
+	require.NoError(t, reconcile.Handle(context.Background(), commands.ReconcileOrderPaymentsCommand{OrderID: o.ID}))
+	require.Len(t, notifier.sent, 1)
+	notice := notifier.sent[0]
+	assert.Equal(t, "RCP-"+pay.ID, notice.Receipt.Number)
+	assert.Equal(t, "ORD-2026-000042", notice.Receipt.OrderNumber)
+	assert.Equal(t, "jane@example.com", notice.Email)
+	assert.Equal(t, "Jane Doe", notice.CustomerName)
+	assert.Equal(t, "id", notice.Locale)
+	assert.Zero(t, notice.Outstanding)
+	assert.NotNil(t, pay.ReceiptSentAt)
+
+	require.NoError(t, reconcile.Handle(context.Background(), commands.ReconcileOrderPaymentsCommand{OrderID: o.ID}))
+	assert.Len(t, notifier.sent, 1, "a receipt goes out once")
+
+	cash := payment.NewPayment(o.ID, "u1", 500, payment.MethodCashOnDelivery)
+	cash.MarkAsPaid("")
+	assert.False(t, cash.ReceiptDue(), "cash paid to the courier gets no emailed receipt")
+}
+
+func TestOrderInvoiceRendersWhatWasOrderedAndPaid(t *testing.T) {
+	payments := &memoryPaymentRepo{}
+	users := &deletionUserRepoStub{users: map[string]*user.User{"u1": {ID: "u1", Email: "jane@example.com"}}}
+	invoices := queries.NewGetOrderInvoiceQueryHandler(&memoryEmailTemplateRepo{}, payments, users, nil)
+
+	o := &order.Order{
+		ID:          "order-1",
+		Number:      "ORD-2026-000042",
+		UserID:      "u1",
+		Status:      order.StatusConfirmed,
+		TotalAmount: 115,
+		Shipping:    order.ShippingCharge{Fee: 15},
+		CreatedAt:   time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC),
+		Items: []order.OrderItem{
+			{ProductID: "p1", Quantity: 2, Price: 50, Subtotal: 100, Product: order.ProductSnapshot{Name: "Coffee Beans"}},
+		},
+	}
+	failed := payment.NewPayment(o.ID, "u1", 115, payment.MethodCreditCard)
+	failed.MarkAsFailed()
+	paid := payment.NewPayment(o.ID, "u1", 115, payment.MethodEWallet)
+	paid.MarkAsPaid("trx-1")
+	payments.payments = []*payment.Payment{failed, paid}
+
+	invoice, err := invoices.Handle(context.Background(), queries.GetOrderInvoiceQuery{Order: o, Locale: "en"})
+	require.NoError(t, err)
+	assert.Equal(t, "INV-20260301-ORD-2026-000042", invoice.Number)
+	assert.Contains(t, invoice.HTML, "INV-20260301-ORD-2026-000042")
+	assert.Contains(t, invoice.HTML, "Coffee Beans")
+	assert.Contains(t, invoice.HTML, "e_wallet")
+	assert.NotContains(t, invoice.HTML, "credit_card", "payments that never went through are left off")
+
+	o.Hold()
+	_, err = invoices.Handle(context.Background(), queries.GetOrderInvoiceQuery{Order: o, Locale: "en"})
+	assert.Equal(t, commands.ErrOrderInvoiceUnavailable.Code, apperror.From(err).Code)
+}
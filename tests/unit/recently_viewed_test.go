@@ -0,0 +1,108 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/product"
+)
+
+type recentlyViewedStub struct {
+	lists map[string][]string
+}
+
+func (s *recentlyViewedStub) Add(ctx context.Context, viewer, productID string) error {
+	return nil
+}
+
+func (s *recentlyViewedStub) List(ctx context.Context, viewer string, limit int) ([]string, error) {
+	return s.lists[viewer], nil
+}
+
+type productCacheStub struct {
+	mu    sync.Mutex
+	items map[string][]byte
+}
+
+func (s *productCacheStub) CacheProduct(ctx context.Context, productID string, p interface{}) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[productID] = data
+	return nil
+}
+
+func (s *productCacheStub) GetCachedProduct(ctx context.Context, productID string, dest interface{}) error {
+	s.mu.Lock()
+	data, ok := s.items[productID]
+	s.mu.Unlock()
+	if !ok {
+		return errors.New("cache miss")
+	}
+	return json.Unmarshal(data, dest)
+}
+
+type productRepoStub struct {
+	product.Repository
+	mu        sync.Mutex
+	products  map[string]*product.Product
+	requested []string
+}
+
+func (r *productRepoStub) GetByIDs(ctx context.Context, ids []string) ([]*product.Product, error) {
+	r.mu.Lock()
+	r.requested = append(r.requested, ids...)
+	r.mu.Unlock()
+	var found []*product.Product
+	for _, id := range ids {
+		if p, ok := r.products[id]; ok {
+			found = append(found, p)
+		}
+	}
+	return found, nil
+}
+
+func TestViewerKey(t *testing.T) {
+	assert.Equal(t, "user:u1", product.ViewerKey("u1", "s1"))
+	assert.Equal(t, "session:s1", product.ViewerKey("", "s1"))
+	assert.Equal(t, "", product.ViewerKey("", ""))
+}
+
+func TestGetRecentlyViewedHydratesFromCache(t *testing.T) {
+	views := &recentlyViewedStub{lists: map[string][]string{
+		"session:s1": {"p2", "p1", "gone"},
+	}}
+	cache := &productCacheStub{items: map[string][]byte{}}
+	require.NoError(t, cache.CacheProduct(context.Background(), "p2", &product.Product{ID: "p2", Status: product.StatusActive}))
+	repo := &productRepoStub{products: map[string]*product.Product{
+		"p1": {ID: "p1", Status: product.StatusActive},
+	}}
+
+	handler := queries.NewGetRecentlyViewedQueryHandler(views, repo, cache)
+	products, err := handler.Handle(context.Background(), queries.GetRecentlyViewedQuery{SessionID: "s1"})
+	require.NoError(t, err)
+
+	require.Len(t, products, 2)
+	assert.Equal(t, "p2", products[0].ID)
+	assert.Equal(t, "p1", products[1].ID)
+	assert.Equal(t, []string{"p1", "gone"}, repo.requested, "only cache misses hit the repository")
+	assert.Contains(t, cache.items, "p1", "misses are written back to the cache")
+}
+
+func TestGetRecentlyViewedWithoutViewer(t *testing.T) {
+	handler := queries.NewGetRecentlyViewedQueryHandler(&recentlyViewedStub{}, &productRepoStub{}, &productCacheStub{})
+
+	products, err := handler.Handle(context.Background(), queries.GetRecentlyViewedQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, products)
+}
@@ -0,0 +1,75 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/recommendation"
+)
+
+func TestMinePairs(t *testing.T) {
+	baskets := [][]string{
+		{"phone", "case", "charger"},
+		{"phone", "case"},
+		{"phone", "case", "case"},
+		{"phone", "charger"},
+		{"laptop"},
+	}
+
+	pairs := recommendation.MinePairs(baskets, 2, 0)
+
+	related := make(map[string]map[string]*recommendation.Pair)
+	for _, p := range pairs {
+		if related[p.ProductID] == nil {
+			related[p.ProductID] = make(map[string]*recommendation.Pair)
+		}
+		related[p.ProductID][p.RelatedID] = p
+	}
+
+	require.Contains(t, related["phone"], "case")
+	assert.Equal(t, 3, related["phone"]["case"].Count)
+	assert.InDelta(t, 0.75, related["phone"]["case"].Confidence, 0.0001)
+	assert.InDelta(t, 1.0, related["case"]["phone"].Confidence, 0.0001)
+
+	assert.Equal(t, 2, related["phone"]["charger"].Count)
+	assert.NotContains(t, related["case"], "charger", "below minimum support")
+	assert.NotContains(t, related, "laptop")
+}
+
+func TestMinePairsCapsRelatedProducts(t *testing.T) {
+	baskets := [][]string{
+		{"a", "b", "c"},
+		{"a", "b", "c"},
+		{"a", "b"},
+	}
+
+	var fromA []*recommendation.Pair
+	for _, p := range recommendation.MinePairs(baskets, 1, 1) {
+		if p.ProductID == "a" {
+			fromA = append(fromA, p)
+		}
+	}
+
+	require.Len(t, fromA, 1)
+	assert.Equal(t, "b", fromA[0].RelatedID)
+}
+
+func TestRankByPrice(t *testing.T) {
+	target := &product.Product{ID: "target", Price: 100, Status: product.StatusActive}
+	candidates := []*product.Product{
+		target,
+		{ID: "far", Price: 400, Status: product.StatusActive},
+		{ID: "close", Price: 95, Status: product.StatusActive},
+		{ID: "inactive", Price: 100, Status: product.StatusArchived},
+		{ID: "near", Price: 130, Status: product.StatusActive},
+	}
+
+	ranked := recommendation.RankByPrice(target, candidates, 2)
+
+	require.Len(t, ranked, 2)
+	assert.Equal(t, "close", ranked[0].ID)
+	assert.Equal(t, "near", ranked[1].ID)
+}
@@ -0,0 +1,240 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/payment"
+	"online-shop/internal/domain/reconciliation"
+)
+
+type reconciliationRepoStub struct {
+	runs map[string]*reconciliation.Run
+}
+
+func newReconciliationRepoStub() *reconciliationRepoStub {
+	return &reconciliationRepoStub{runs: map[string]*reconciliation.Run{}}
+}
+
+func (r *reconciliationRepoStub) Create(ctx context.Context, run *reconciliation.Run) error {
+	r.runs[run.ID] = run
+	return nil
+}
+
+func (r *reconciliationRepoStub) GetByID(ctx context.Context, id string) (*reconciliation.Run, error) {
+	if run, ok := r.runs[id]; ok {
+		return run, nil
+	}
+	return nil, reconciliation.ErrNotFound
+}
+
+func (r *reconciliationRepoStub) Update(ctx context.Context, run *reconciliation.Run) error {
+	r.runs[run.ID] = run
+	return nil
+}
+
+func (r *reconciliationRepoStub) List(ctx context.Context, limit, offset int) ([]*reconciliation.Run, error) {
+	return nil, nil
+}
+
+func (r *reconciliationRepoStub) ListByStatus(ctx context.Context, status reconciliation.Status) ([]*reconciliation.Run, error) {
+	var found []*reconciliation.Run
+	for _, run := range r.runs {
+		if run.Status == status {
+			found = append(found, run)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].CreatedAt.Before(found[j].CreatedAt) })
+	return found, nil
+}
+
+func (r *reconciliationRepoStub) LatestForPeriod(ctx context.Context, start time.Time) (*reconciliation.Run, error) {
+	var latest *reconciliation.Run
+	for _, run := range r.runs {
+		if run.PeriodStart.Equal(start) && (latest == nil || run.CreatedAt.After(latest.CreatedAt)) {
+			latest = run
+		}
+	}
+	return latest, nil
+}
+
+type reconciliationPaymentRepo struct {
+	memoryPaymentRepo
+}
+
+func (r *reconciliationPaymentRepo) ListCreatedBetween(ctx context.Context, from, to time.Time) ([]*payment.Payment, error) {
+	var found []*payment.Payment
+	for _, p := range r.payments {
+		if !p.CreatedAt.Before(from) && p.CreatedAt.Before(to) {
+			found = append(found, p)
+		}
+	}
+	return found, nil
+}
+
+func (r *reconciliationPaymentRepo) GetByExternalID(ctx context.Context, externalID string) (*payment.Payment, error) {
+	for _, p := range r.payments {
+		if p.ExternalID == externalID {
+			return p, nil
+		}
+	}
+	return nil, errors.New("not found")
+}
+
+type settlementSourceStub struct {
+	settlements []reconciliation.Settlement
+	err         error
+	calls       int
+}
+
+func (s *settlementSourceStub) Provider() string {
+	return "midtrans"
+}
+
+func (s *settlementSourceStub) Settlements(ctx context.Context, from, to time.Time, payments []*payment.Payment) ([]reconciliation.Settlement, error) {
+	s.calls++
+	return s.settlements, s.err
+}
+
+func reconciledPayment(id string, amount float64, status payment.Status, createdAt time.Time) *payment.Payment {
+	p := payment.NewPayment("order-"+id, "u1", amount, payment.MethodBankTransfer)
+	p.ID = id
+	p.ExternalID = id
+	p.Status = status
+	p.CreatedAt = createdAt
+	return p
+}
+
+func mismatchKinds(result *reconciliation.Result) map[string]reconciliation.Kind {
+	kinds := map[string]reconciliation.Kind{}
+	for _, m := range result.Mismatches {
+		kinds[m.ExternalID] = m.Kind
+	}
+	return kinds
+}
+
+func TestReconcileFlagsMismatches(t *testing.T) {
+	now := time.Now()
+	earlier := reconciledPayment("p-earlier", 40000, payment.StatusPaid, now.AddDate(0, 0, -2))
+	payments := []*payment.Payment{
+		reconciledPayment("p-ok", 100000, payment.StatusPaid, now),
+		reconciledPayment("p-missing", 50000, payment.StatusPaid, now),
+		reconciledPayment("p-drift", 75000, payment.StatusPaid, now),
+		reconciledPayment("p-unsettled", 20000, payment.StatusPending, now),
+		reconciledPayment("p-expired", 10000, payment.StatusExpired, now),
+		reconciledPayment("p-abandoned", 30000, payment.StatusPending, now),
+	}
+	settlements := []reconciliation.Settlement{
+		{ExternalID: "p-ok", Amount: 100000.004, Status: payment.StatusPaid},
+		{ExternalID: "p-drift", Amount: 70000, Status: payment.StatusPaid},
+		{ExternalID: "p-unsettled", Amount: 20000, Status: payment.StatusPaid},
+		{ExternalID: "p-expired", Amount: 10000, Status: payment.StatusFailed},
+		{ExternalID: "p-earlier", Amount: 40000, Status: payment.StatusPaid},
+		{ExternalID: "p-unknown", Amount: 90000, Status: payment.StatusPaid},
+	}
+	lookup := func(externalID string) *payment.Payment {
+		if externalID == earlier.ExternalID {
+			return earlier
+		}
+		return nil
+	}
+
+	result := reconciliation.Reconcile(payments, settlements, lookup)
+
+	assert.Equal(t, map[string]reconciliation.Kind{
+		"p-missing":   reconciliation.KindMissing,
+		"p-drift":     reconciliation.KindAmountDrift,
+		"p-unsettled": reconciliation.KindStatusMismatch,
+		"p-unknown":   reconciliation.KindOrphaned,
+	}, mismatchKinds(result), "an expired payment the provider failed and a pending one it never saw both match")
+	assert.Equal(t, 6, result.Checked)
+	assert.Equal(t, 3, result.Matched)
+	assert.Equal(t, 225000.0, result.LocalTotal)
+	assert.InDelta(t, 280000.0, result.ProviderTotal, 0.01)
+
+	result = reconciliation.Reconcile(payments, settlements, nil)
+	assert.Equal(t, reconciliation.KindOrphaned, mismatchKinds(result)["p-earlier"], "without a lookup the earlier payment's settlement is orphaned")
+}
+
+func TestNightlyReconciliation(t *testing.T) {
+	runs := newReconciliationRepoStub()
+	payments := &reconciliationPaymentRepo{}
+	today, _ := reconciliation.Day(time.Now())
+	yesterday := today.AddDate(0, 0, -1)
+	payments.payments = []*payment.Payment{
+		reconciledPayment("p-1", 100000, payment.StatusPaid, yesterday.Add(time.Hour)),
+		reconciledPayment("p-2", 50000, payment.StatusPaid, yesterday.Add(2*time.Hour)),
+		reconciledPayment("p-today", 10000, payment.StatusPaid, today),
+	}
+	source := &settlementSourceStub{settlements: []reconciliation.Settlement{
+		{ExternalID: "p-1", Amount: 100000, Status: payment.StatusPaid},
+	}}
+	handler := commands.NewRunReconciliationCommandHandler(runs, payments, source)
+
+	ran, err := handler.Handle(context.Background(), commands.RunReconciliationCommand{Nightly: true, Delay: 25 * time.Hour})
+	require.NoError(t, err)
+	assert.Empty(t, ran, "nothing runs before the delay has passed")
+
+	ran, err = handler.Handle(context.Background(), commands.RunReconciliationCommand{Nightly: true})
+	require.NoError(t, err)
+	require.Len(t, ran, 1)
+	run := ran[0]
+	assert.Equal(t, reconciliation.StatusCompleted, run.Status)
+	assert.Equal(t, reconciliation.TriggerScheduled, run.Trigger)
+	assert.True(t, run.PeriodStart.Equal(yesterday))
+	assert.Equal(t, 2, run.Checked, "only yesterday's payments are checked")
+	assert.Equal(t, 1, run.Matched)
+	assert.Equal(t, 1, run.Missing)
+	require.Len(t, run.Mismatches, 1)
+	assert.Equal(t, "p-2", run.Mismatches[0].PaymentID)
+	assert.Equal(t, run.ID, run.Mismatches[0].RunID)
+
+	ran, err = handler.Handle(context.Background(), commands.RunReconciliationCommand{Nightly: true})
+	require.NoError(t, err)
+	assert.Empty(t, ran, "a completed night is not reconciled again")
+	assert.Equal(t, 1, source.calls)
+}
+
+func TestFailedReconciliationIsRetried(t *testing.T) {
+	runs := newReconciliationRepoStub()
+	source := &settlementSourceStub{err: errors.New("provider unavailable")}
+	handler := commands.NewRunReconciliationCommandHandler(runs, &reconciliationPaymentRepo{}, source)
+
+	ran, err := handler.Handle(context.Background(), commands.RunReconciliationCommand{Nightly: true})
+	require.Error(t, err)
+	require.Len(t, ran, 1)
+	assert.Equal(t, reconciliation.StatusFailed, ran[0].Status)
+	assert.Contains(t, ran[0].Error, "provider unavailable")
+
+	source.err = nil
+	ran, err = handler.Handle(context.Background(), commands.RunReconciliationCommand{Nightly: true})
+	require.NoError(t, err)
+	require.Len(t, ran, 1)
+	assert.Equal(t, reconciliation.StatusCompleted, ran[0].Status)
+}
+
+func TestTriggerReconciliation(t *testing.T) {
+	runs := newReconciliationRepoStub()
+	jobs := &schedulerStub{}
+	handler := commands.NewTriggerReconciliationCommandHandler(runs, "midtrans", jobs)
+
+	_, err := handler.Handle(context.Background(), commands.TriggerReconciliationCommand{Date: time.Now().UTC().Format("2006-01-02")})
+	assert.ErrorIs(t, err, reconciliation.ErrInvalidPeriod, "today has not ended")
+
+	run, err := handler.Handle(context.Background(), commands.TriggerReconciliationCommand{Date: "2026-01-15", RequestedBy: "admin-1"})
+	require.NoError(t, err)
+	assert.Equal(t, reconciliation.StatusPending, run.Status)
+	assert.Equal(t, reconciliation.TriggerManual, run.Trigger)
+	assert.Equal(t, time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC), run.PeriodStart)
+	assert.Equal(t, []string{reconciliation.JobName}, jobs.triggered)
+
+	_, err = handler.Handle(context.Background(), commands.TriggerReconciliationCommand{Date: "2026-01-15"})
+	assert.ErrorIs(t, err, commands.ErrReconciliationInProgress)
+}
@@ -0,0 +1,226 @@
+package unit
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/export"
+	"online-shop/internal/infrastructure/spreadsheet"
+	"online-shop/internal/infrastructure/storage"
+	"online-shop/pkg/config"
+)
+
+type exportRepoStub struct {
+	exports map[string]*export.Export
+}
+
+func (r *exportRepoStub) Create(ctx context.Context, e *export.Export) error {
+	r.exports[e.ID] = e
+	return nil
+}
+
+func (r *exportRepoStub) GetByID(ctx context.Context, id string) (*export.Export, error) {
+	if e, ok := r.exports[id]; ok {
+		return e, nil
+	}
+	return nil, errors.New("record not found")
+}
+
+func (r *exportRepoStub) Update(ctx context.Context, e *export.Export) error {
+	r.exports[e.ID] = e
+	return nil
+}
+
+func (r *exportRepoStub) ListByRequester(ctx context.Context, userID string, limit, offset int) ([]*export.Export, error) {
+	var found []*export.Export
+	for _, e := range r.exports {
+		if e.RequestedBy == userID {
+			found = append(found, e)
+		}
+	}
+	return found, nil
+}
+
+type exportSourceStub struct {
+	table *export.Table
+	err   error
+}
+
+func (s exportSourceStub) Load(ctx context.Context, e *export.Export) (*export.Table, error) {
+	return s.table, s.err
+}
+
+type memoryStorage struct {
+	objects map[string][]byte
+}
+
+func (s *memoryStorage) Put(ctx context.Context, key, contentType string, data []byte) error {
+	s.objects[key] = data
+	return nil
+}
+
+func (s *memoryStorage) Delete(ctx context.Context, key string) error {
+	delete(s.objects, key)
+	return nil
+}
+
+func (s *memoryStorage) SignedURL(key string, ttl time.Duration) (string, error) {
+	return "https://files.test/" + key, nil
+}
+
+type exportNotifierStub struct {
+	notified []*export.Export
+	urls     []string
+}
+
+func (n *exportNotifierStub) ExportFinished(ctx context.Context, e *export.Export, downloadURL string) error {
+	n.notified = append(n.notified, e)
+	n.urls = append(n.urls, downloadURL)
+	return nil
+}
+
+func newPendingExport(t *testing.T, repo *exportRepoStub, format export.Format) *export.Export {
+	e, err := export.NewExport("admin-1", export.TypeOrders, format, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.NoError(t, repo.Create(context.Background(), e))
+	return e
+}
+
+func TestGenerateExportUploadsAndNotifies(t *testing.T) {
+	repo := &exportRepoStub{exports: map[string]*export.Export{}}
+	store := &memoryStorage{objects: map[string][]byte{}}
+	notifier := &exportNotifierStub{}
+	table := &export.Table{Header: []string{"order_id", "total"}, Rows: [][]string{{"o1", "10.00"}, {"o2", "5.50"}}}
+	e := newPendingExport(t, repo, export.FormatCSV)
+
+	handler := commands.NewGenerateExportCommandHandler(repo, exportSourceStub{table: table}, store, spreadsheet.Encoders(), nil, nil, notifier, time.Hour)
+	require.NoError(t, handler.Handle(context.Background(), commands.GenerateExportCommand{ExportID: e.ID}))
+
+	assert.Equal(t, export.StatusCompleted, e.Status)
+	assert.Equal(t, 2, e.RowCount)
+	require.Contains(t, store.objects, e.ObjectKey)
+	assert.Equal(t, "order_id,total\no1,10.00\no2,5.50\n", string(store.objects[e.ObjectKey]))
+	require.Len(t, notifier.urls, 1)
+	assert.Equal(t, "https://files.test/"+e.ObjectKey, notifier.urls[0])
+
+	// A redelivered message must not regenerate or notify twice
+	require.NoError(t, handler.Handle(context.Background(), commands.GenerateExportCommand{ExportID: e.ID}))
+	assert.Len(t, notifier.notified, 1)
+}
+
+func TestGenerateExportRecordsFailure(t *testing.T) {
+	repo := &exportRepoStub{exports: map[string]*export.Export{}}
+	notifier := &exportNotifierStub{}
+	e := newPendingExport(t, repo, export.FormatXLSX)
+
+	handler := commands.NewGenerateExportCommandHandler(repo, exportSourceStub{err: errors.New("query timeout")}, &memoryStorage{objects: map[string][]byte{}}, spreadsheet.Encoders(), nil, nil, notifier, time.Hour)
+	require.NoError(t, handler.Handle(context.Background(), commands.GenerateExportCommand{ExportID: e.ID}))
+
+	assert.Equal(t, export.StatusFailed, e.Status)
+	assert.Equal(t, "query timeout", e.Error)
+	require.Len(t, notifier.urls, 1)
+	assert.Empty(t, notifier.urls[0])
+}
+
+func TestNewExportValidatesRequest(t *testing.T) {
+	_, err := export.NewExport("admin-1", "invoices", export.FormatCSV, time.Time{}, time.Time{})
+	assert.Error(t, err)
+
+	_, err = export.NewExport("admin-1", export.TypeOrders, "pdf", time.Time{}, time.Time{})
+	assert.Error(t, err)
+
+	now := time.Now()
+	_, err = export.NewExport("admin-1", export.TypeOrders, export.FormatCSV, now, now.Add(-time.Hour))
+	assert.Error(t, err)
+}
+
+func TestCSVEncoderEscapesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	table := &export.Table{Header: []string{"name", "balance"}, Rows: [][]string{{"=HYPERLINK(\"x\")", "-12.50"}}}
+	require.NoError(t, spreadsheet.NewCSVEncoder().Encode(&buf, table))
+
+	assert.Equal(t, "name,balance\n\"'=HYPERLINK(\"\"x\"\")\",-12.50\n", buf.String())
+}
+
+func TestXLSXEncoderWritesWorkbook(t *testing.T) {
+	var buf bytes.Buffer
+	table := &export.Table{Header: []string{"sku", "price"}, Rows: [][]string{{"007", "9.99"}, {"A&B", "3"}}}
+	require.NoError(t, spreadsheet.NewXLSXEncoder().Encode(&buf, table))
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	files := map[string]string{}
+	for _, f := range archive.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		files[f.Name] = string(data)
+	}
+
+	require.Contains(t, files, "[Content_Types].xml")
+	sheet := files["xl/worksheets/sheet1.xml"]
+	assert.Contains(t, sheet, `<c r="A2" t="inlineStr"><is><t xml:space="preserve">007</t></is></c>`, "leading zeros stay text")
+	assert.Contains(t, sheet, `<c r="B2"><v>9.99</v></c>`)
+	assert.Contains(t, sheet, "A&amp;B")
+}
+
+func TestLocalStoreSignedLinks(t *testing.T) {
+	store, err := storage.NewLocalStore(&config.StorageConfig{
+		LocalDir:   t.TempDir(),
+		PublicURL:  "http://localhost:12000/downloads",
+		SigningKey: "secret",
+	})
+	require.NoError(t, err)
+	require.NoError(t, store.Put(context.Background(), "exports/a/report.csv", "text/csv", []byte("id\n")))
+
+	link, err := store.SignedURL("exports/a/report.csv", time.Hour)
+	require.NoError(t, err)
+	parsed, err := url.Parse(link)
+	require.NoError(t, err)
+	key := strings.TrimPrefix(parsed.Path, "/downloads")
+
+	_, err = store.Open(key, parsed.Query().Get("expires"), parsed.Query().Get("signature"))
+	assert.NoError(t, err)
+
+	_, err = store.Open("/exports/b/report.csv", parsed.Query().Get("expires"), parsed.Query().Get("signature"))
+	assert.ErrorIs(t, err, storage.ErrInvalidSignature)
+
+	_, err = store.Open(key, "1", parsed.Query().Get("signature"))
+	assert.ErrorIs(t, err, storage.ErrInvalidSignature)
+}
+
+func TestS3StorePresignsDownloads(t *testing.T) {
+	store, err := storage.NewS3Store(&config.StorageConfig{
+		Endpoint:  "http://localhost:9000",
+		Bucket:    "exports",
+		AccessKey: "minioadmin",
+		SecretKey: "minioadmin",
+	})
+	require.NoError(t, err)
+
+	link, err := store.SignedURL("exports/admin 1/report.csv", 15*time.Minute)
+	require.NoError(t, err)
+	parsed, err := url.Parse(link)
+	require.NoError(t, err)
+
+	assert.Equal(t, "/exports/exports/admin%201/report.csv", parsed.EscapedPath())
+	assert.Equal(t, "900", parsed.Query().Get("X-Amz-Expires"))
+	assert.Equal(t, "host", parsed.Query().Get("X-Amz-SignedHeaders"))
+	assert.Len(t, parsed.Query().Get("X-Amz-Signature"), 64)
+
+	_, err = store.SignedURL("exports/report.csv", 8*24*time.Hour)
+	assert.Error(t, err)
+}
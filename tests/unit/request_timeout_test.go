@@ -0,0 +1,94 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/interfaces/http/middleware"
+	"online-shop/pkg/config"
+)
+
+// slowProductRepo answers once the caller's context is done, like a query
+// cancelled by the database driver
+type slowProductRepo struct {
+	product.Repository
+	cancelled chan error
+}
+
+func (r *slowProductRepo) GetByID(ctx context.Context, id string) (*product.Product, error) {
+	<-ctx.Done()
+	r.cancelled <- ctx.Err()
+	return nil, ctx.Err()
+}
+
+func newTimeoutRouter(timeout time.Duration, routes func(r *gin.Engine)) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Errors())
+	r.Use(middleware.Timeout(timeout))
+	routes(r)
+	return r
+}
+
+func TestRequestDeadlineReachesRepository(t *testing.T) {
+	repo := &slowProductRepo{cancelled: make(chan error, 1)}
+	handler := queries.NewGetProductQueryHandler(repo)
+	r := newTimeoutRouter(20*time.Millisecond, func(r *gin.Engine) {
+		r.GET("/products/:id", func(c *gin.Context) {
+			found, err := handler.Handle(c.Request.Context(), queries.GetProductQuery{ProductID: c.Param("id")})
+			if err != nil {
+				middleware.AbortWithError(c, err)
+				return
+			}
+			c.JSON(http.StatusOK, found)
+		})
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/products/p-1", nil))
+
+	require.ErrorIs(t, <-repo.cancelled, context.DeadlineExceeded)
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"timeout"`)
+}
+
+func TestRequestDeadlineAnswersSilentHandlers(t *testing.T) {
+	r := newTimeoutRouter(10*time.Millisecond, func(r *gin.Engine) {
+		r.GET("/slow", func(c *gin.Context) {
+			<-c.Request.Context().Done()
+		})
+		r.GET("/fast", func(c *gin.Context) {
+			_, ok := c.Request.Context().Deadline()
+			assert.True(t, ok, "every request gets a deadline")
+			c.Status(http.StatusNoContent)
+		})
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestRequestTimeoutConfig(t *testing.T) {
+	assert.Equal(t, 30*time.Second, config.ServerConfig{}.RequestTimeout())
+	assert.Equal(t, 5*time.Second, config.GRPCConfig{RequestTimeoutSeconds: 5}.RequestTimeout())
+
+	cfg := &config.Config{}
+	cfg.Server.RequestTimeoutSeconds = -1
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "server.request_timeout_seconds must not be negative")
+}
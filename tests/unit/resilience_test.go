@@ -0,0 +1,95 @@
+package unit
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/pkg/config"
+	"online-shop/pkg/resilience"
+)
+
+var errDown = errors.New("connection refused")
+
+func TestBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	notFound := errors.New("not found")
+	breaker := resilience.New("test-open", config.DependencyConfig{BreakerFailures: 2, BreakerCooldownSeconds: 60}, func(err error) bool {
+		return err != nil && err != notFound
+	})
+
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, notFound, breaker.Do(func() error { return notFound }))
+	}
+	assert.Equal(t, "closed", breaker.State(), "answers the caller does not like are not failures")
+
+	assert.Equal(t, errDown, breaker.Do(func() error { return errDown }))
+	assert.NoError(t, breaker.Do(func() error { return nil }), "a success resets the count")
+	assert.Equal(t, errDown, breaker.Do(func() error { return errDown }))
+	assert.Equal(t, errDown, breaker.Do(func() error { return errDown }))
+	assert.Equal(t, "open", breaker.State())
+
+	called := false
+	err := breaker.Do(func() error { called = true; return nil })
+	assert.ErrorIs(t, err, resilience.ErrOpen)
+	assert.ErrorIs(t, err, resilience.ErrUnavailable)
+	assert.False(t, called)
+}
+
+func TestBreakerBulkheadRejectsExcessCalls(t *testing.T) {
+	breaker := resilience.New("test-bulkhead", config.DependencyConfig{MaxConcurrent: 2}, nil)
+
+	release := make(chan struct{})
+	var started, done sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		started.Add(1)
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			_ = breaker.Do(func() error {
+				started.Done()
+				<-release
+				return nil
+			})
+		}()
+	}
+	started.Wait()
+
+	err := breaker.Do(func() error { return nil })
+	assert.ErrorIs(t, err, resilience.ErrSaturated)
+	assert.Equal(t, "closed", breaker.State(), "a full bulkhead does not trip the breaker")
+
+	close(release)
+	done.Wait()
+	assert.NoError(t, breaker.Do(func() error { return nil }))
+}
+
+func TestBreakerReportsStateMetrics(t *testing.T) {
+	breaker := resilience.New("test-metrics", config.DependencyConfig{BreakerFailures: 1}, nil)
+	require.Equal(t, errDown, breaker.Do(func() error { return errDown }))
+
+	assert.Equal(t, 2.0, dependencyMetric(t, "dependency_circuit_state", "test-metrics"), "open is reported as 2")
+	assert.Equal(t, 0.0, dependencyMetric(t, "dependency_calls_in_flight", "test-metrics"))
+}
+
+func dependencyMetric(t *testing.T, name, dependency string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() == "dependency" && label.GetValue() == dependency {
+					return m.GetGauge().GetValue()
+				}
+			}
+		}
+	}
+	t.Fatalf("no %s for %s", name, dependency)
+	return 0
+}
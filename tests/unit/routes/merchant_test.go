@@ -0,0 +1,314 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/audit"
+	"online-shop/internal/domain/commission"
+	"online-shop/internal/domain/draftorder"
+	"online-shop/internal/domain/flashsale"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/quote"
+	"online-shop/internal/domain/user"
+	"online-shop/internal/domain/warehouse"
+	"online-shop/internal/interfaces/http/handlers"
+	"online-shop/internal/interfaces/http/middleware"
+	"online-shop/pkg/config"
+	"online-shop/pkg/jwt"
+)
+
+type memoryQuoteRepo struct {
+	quotes map[string]*quote.Quote
+}
+
+func (r *memoryQuoteRepo) Create(ctx context.Context, q *quote.Quote) error {
+	r.quotes[q.ID] = q
+	return nil
+}
+
+func (r *memoryQuoteRepo) GetByID(ctx context.Context, id string) (*quote.Quote, error) {
+	q, ok := r.quotes[id]
+	if !ok {
+		return nil, quote.ErrNotFound
+	}
+	copied := *q
+	return &copied, nil
+}
+
+func (r *memoryQuoteRepo) Update(ctx context.Context, q *quote.Quote) error {
+	r.quotes[q.ID] = q
+	return nil
+}
+
+func (r *memoryQuoteRepo) List(ctx context.Context, filter quote.Filter, limit, offset int) ([]*quote.Quote, error) {
+	quotes := []*quote.Quote{}
+	for _, q := range r.quotes {
+		if filter.MerchantID == "" || q.MerchantID == filter.MerchantID {
+			quotes = append(quotes, q)
+		}
+	}
+	return quotes, nil
+}
+
+type memoryDraftRepo struct {
+	drafts map[string]*draftorder.Draft
+}
+
+func (r *memoryDraftRepo) Create(ctx context.Context, d *draftorder.Draft) error {
+	r.drafts[d.ID] = d
+	return nil
+}
+
+func (r *memoryDraftRepo) GetByID(ctx context.Context, id string) (*draftorder.Draft, error) {
+	if d, ok := r.drafts[id]; ok {
+		return d, nil
+	}
+	return nil, draftorder.ErrNotFound
+}
+
+func (r *memoryDraftRepo) GetByToken(ctx context.Context, token string) (*draftorder.Draft, error) {
+	for _, d := range r.drafts {
+		if d.Token == token {
+			return d, nil
+		}
+	}
+	return nil, draftorder.ErrNotFound
+}
+
+func (r *memoryDraftRepo) Update(ctx context.Context, d *draftorder.Draft) error {
+	r.drafts[d.ID] = d
+	return nil
+}
+
+func (r *memoryDraftRepo) Claim(ctx context.Context, id string, now time.Time) (bool, error) {
+	return false, nil
+}
+
+func (r *memoryDraftRepo) List(ctx context.Context, filter draftorder.Filter, limit, offset int) ([]*draftorder.Draft, error) {
+	drafts := []*draftorder.Draft{}
+	for _, d := range r.drafts {
+		if filter.MerchantID == "" || d.MerchantID == filter.MerchantID {
+			drafts = append(drafts, d)
+		}
+	}
+	return drafts, nil
+}
+
+type draftNotifierStub struct {
+	sent []draftorder.LinkNotice
+}
+
+func (n *draftNotifierStub) LinkSent(ctx context.Context, notice draftorder.LinkNotice) error {
+	n.sent = append(n.sent, notice)
+	return nil
+}
+
+type productRepoStub struct {
+	product.Repository
+	products map[string]*product.Product
+}
+
+func (r *productRepoStub) GetByID(ctx context.Context, id string) (*product.Product, error) {
+	if p, ok := r.products[id]; ok {
+		return p, nil
+	}
+	return nil, product.ErrNotFound
+}
+
+type userRepoStub struct {
+	user.Repository
+	users map[string]*user.User
+}
+
+func (r *userRepoStub) GetByID(ctx context.Context, id string) (*user.User, error) {
+	if u, ok := r.users[id]; ok {
+		return u, nil
+	}
+	return nil, commands.ErrUserNotFound
+}
+
+type noCommissionRepo struct{ commission.Repository }
+
+func (noCommissionRepo) ListActive(ctx context.Context) ([]*commission.Rule, error) { return nil, nil }
+
+type noWarehouseRepo struct{ warehouse.Repository }
+
+func (noWarehouseRepo) ListActive(context.Context) ([]*warehouse.Warehouse, error) { return nil, nil }
+
+type noFlashSaleRepo struct{ flashsale.Repository }
+
+func (noFlashSaleRepo) ListLive(ctx context.Context, productIDs []string, at time.Time) ([]*flashsale.Sale, error) {
+	return nil, nil
+}
+
+type nopAuditRepo struct{}
+
+func (nopAuditRepo) Create(ctx context.Context, entry *audit.Entry) error {
+	return nil
+}
+
+func (nopAuditRepo) List(ctx context.Context, filter audit.Filter, limit, offset int) ([]*audit.Entry, int64, error) {
+	return nil, 0, nil
+}
+
+// merchantFixture serves the /merchant group as cmd/api does, behind the
+// real auth and role middleware
+type merchantFixture struct {
+	manager  *jwt.JWTManager
+	router   *gin.Engine
+	merchant *gin.RouterGroup
+}
+
+func newMerchantFixture(t *testing.T) *merchantFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	manager, err := jwt.NewJWTManager(&config.JWTConfig{SecretKey: "secret", ExpiryHours: 1, RefreshExpiryHours: 24, Issuer: "online-shop"})
+	require.NoError(t, err)
+	authMiddleware := middleware.NewAuthMiddleware(manager, nil)
+	auditMiddleware := middleware.Audit(nopAuditRepo{}, zap.NewNop())
+
+	r := gin.New()
+	merchant := r.Group("/merchant", authMiddleware.RequireAuth(), authMiddleware.RequireRole(string(user.RoleMerchant), string(user.RoleAdmin)), auditMiddleware)
+	return &merchantFixture{manager: manager, router: r, merchant: merchant}
+}
+
+func (f *merchantFixture) call(t *testing.T, method, path, userID string, role user.Role, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	token, err := f.manager.GenerateToken(userID, userID+"@example.com", string(role))
+	require.NoError(t, err)
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestMerchantQuoteRoutesPriceAndDeclineQuotes(t *testing.T) {
+	quotes := &memoryQuoteRepo{quotes: map[string]*quote.Quote{}}
+	requested := func() *quote.Quote {
+		q, err := quote.NewRequest("u1", "m1", "Kopi Nusantara", []quote.Item{{ProductID: "beans", Quantity: 20, ListPrice: 50000}}, "", 10)
+		require.NoError(t, err)
+		require.NoError(t, quotes.Create(context.Background(), q))
+		return q
+	}
+
+	f := newMerchantFixture(t)
+	quoteHandler := handlers.NewQuoteHandler(
+		commands.NewRequestQuoteCommandHandler(quotes, nil, nil, 10),
+		commands.NewOfferQuoteCommandHandler(quotes, 14*24*time.Hour, 90*24*time.Hour),
+		commands.NewDeclineQuoteCommandHandler(quotes),
+		commands.NewCancelQuoteCommandHandler(quotes),
+		nil,
+		queries.NewListQuotesQueryHandler(quotes),
+		queries.NewGetQuoteQueryHandler(quotes),
+	)
+	merchantQuotes := f.merchant.Group("/quotes")
+	{
+		merchantQuotes.GET("", quoteHandler.ListMerchantQuotes)
+		merchantQuotes.GET("/:id", quoteHandler.GetMerchantQuote)
+		merchantQuotes.POST("/:id/offer", quoteHandler.OfferQuote)
+		merchantQuotes.POST("/:id/decline", quoteHandler.DeclineQuote)
+	}
+
+	offered, declined := requested(), requested()
+	offer := `{"prices":[{"product_id":"beans","price":40000}],"response":"Our best price"}`
+
+	w := f.call(t, http.MethodPost, "/merchant/quotes/"+offered.ID+"/offer", "u1", user.RoleCustomer, offer)
+	assert.Equal(t, http.StatusForbidden, w.Code, "customers cannot price quotes")
+
+	w = f.call(t, http.MethodPost, "/merchant/quotes/"+offered.ID+"/offer", "m2", user.RoleMerchant, offer)
+	assert.Equal(t, http.StatusNotFound, w.Code, "another merchant's quote")
+
+	w = f.call(t, http.MethodPost, "/merchant/quotes/"+offered.ID+"/offer", "m1", user.RoleMerchant, offer)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"status":"offered"`)
+	assert.Equal(t, quote.StatusOffered, quotes.quotes[offered.ID].Status)
+	assert.Equal(t, 800000.0, quotes.quotes[offered.ID].TotalAmount)
+
+	w = f.call(t, http.MethodPost, "/merchant/quotes/"+declined.ID+"/decline", "admin-1", user.RoleAdmin, `{"response":"Out of stock"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, quote.StatusDeclined, quotes.quotes[declined.ID].Status)
+	assert.Equal(t, "Out of stock", quotes.quotes[declined.ID].Response)
+
+	w = f.call(t, http.MethodGet, "/merchant/quotes/"+declined.ID, "m1", user.RoleMerchant, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"declined"`)
+
+	w = f.call(t, http.MethodGet, "/merchant/quotes", "m2", user.RoleMerchant, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"data":[]`, "no quotes asked of m2")
+}
+
+func TestMerchantDraftOrderRoutesDraftSendAndCancelOrders(t *testing.T) {
+	drafts := &memoryDraftRepo{drafts: map[string]*draftorder.Draft{}}
+	products := &productRepoStub{products: map[string]*product.Product{
+		"beans": {ID: "beans", MerchantID: "m1", Name: "Coffee beans", Price: 50000, Stock: 10, Status: product.StatusActive},
+	}}
+	users := &userRepoStub{users: map[string]*user.User{
+		"u1": {ID: "u1", Email: "budi@example.com", FirstName: "Budi", Role: user.RoleCustomer, Status: user.StatusActive},
+	}}
+	notifier := &draftNotifierStub{}
+
+	f := newMerchantFixture(t)
+	createOrderHandler := commands.NewCreateOrderCommandHandler(nil, products, noCommissionRepo{}, noWarehouseRepo{}, nil, noFlashSaleRepo{}, nil, commands.CreateOrderOptions{})
+	draftOrderHandler := handlers.NewDraftOrderHandler(
+		commands.NewCreateDraftOrderCommandHandler(drafts, users, products, createOrderHandler, nopAuditRepo{}, 7*24*time.Hour),
+		commands.NewSendDraftOrderCommandHandler(drafts, users, notifier, "https://api.example.com", ""),
+		commands.NewCancelDraftOrderCommandHandler(drafts),
+		nil,
+		queries.NewListDraftOrdersQueryHandler(drafts),
+		queries.NewGetDraftOrderQueryHandler(drafts),
+		nil,
+	)
+	draftOrders := f.merchant.Group("/draft-orders")
+	{
+		draftOrders.GET("", draftOrderHandler.ListDrafts)
+		draftOrders.POST("", draftOrderHandler.CreateDraft)
+		draftOrders.GET("/:id", draftOrderHandler.GetDraft)
+		draftOrders.POST("/:id/send", draftOrderHandler.SendDraft)
+		draftOrders.POST("/:id/cancel", draftOrderHandler.CancelDraft)
+	}
+
+	w := f.call(t, http.MethodPost, "/merchant/draft-orders", "m1", user.RoleMerchant,
+		`{"user_id":"u1","items":[{"product_id":"beans","quantity":2}],"shipping_address":{"street":"Jl. Merdeka 1","city":"Jakarta","postal_code":"10110","country":"ID"}}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created struct {
+		Data draftorder.Draft `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	d := drafts.drafts[created.Data.ID]
+	require.NotNil(t, d)
+	assert.Equal(t, "m1", d.MerchantID)
+	assert.Equal(t, 100000.0, d.TotalAmount)
+
+	w = f.call(t, http.MethodGet, "/merchant/draft-orders/"+d.ID, "m2", user.RoleMerchant, "")
+	assert.Equal(t, http.StatusNotFound, w.Code, "another merchant's draft")
+
+	w = f.call(t, http.MethodGet, "/merchant/draft-orders", "m1", user.RoleMerchant, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), d.ID)
+
+	w = f.call(t, http.MethodPost, "/merchant/draft-orders/"+d.ID+"/send", "m1", user.RoleMerchant, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Len(t, notifier.sent, 1)
+	assert.Equal(t, "Budi", notifier.sent[0].CustomerName)
+	assert.Equal(t, draftorder.StatusSent, drafts.drafts[d.ID].Status)
+
+	w = f.call(t, http.MethodPost, "/merchant/draft-orders/"+d.ID+"/cancel", "admin-1", user.RoleAdmin, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, draftorder.StatusCancelled, drafts.drafts[d.ID].Status)
+}
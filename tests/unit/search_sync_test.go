@@ -0,0 +1,187 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/searchsync"
+)
+
+type syncChangeRepo struct {
+	searchsync.Repository
+	changes []*searchsync.Change
+}
+
+func (r *syncChangeRepo) ListDue(ctx context.Context, at time.Time, limit int) ([]*searchsync.Change, error) {
+	var due []*searchsync.Change
+	for _, c := range r.changes {
+		if !c.NextAttemptAt.After(at) && len(due) < limit {
+			due = append(due, c)
+		}
+	}
+	return due, nil
+}
+
+func (r *syncChangeRepo) Claim(ctx context.Context, c *searchsync.Change, until time.Time) (bool, error) {
+	c.NextAttemptAt = until
+	return true, nil
+}
+
+func (r *syncChangeRepo) Delete(ctx context.Context, ids []string) error {
+	applied := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		applied[id] = true
+	}
+	kept := r.changes[:0]
+	for _, c := range r.changes {
+		if !applied[c.ID] {
+			kept = append(kept, c)
+		}
+	}
+	r.changes = kept
+	return nil
+}
+
+func (r *syncChangeRepo) Update(ctx context.Context, c *searchsync.Change) error { return nil }
+
+type syncProductRepo struct {
+	product.Repository
+	products []*product.Product
+}
+
+func (r *syncProductRepo) GetByIDs(ctx context.Context, ids []string) ([]*product.Product, error) {
+	var found []*product.Product
+	for _, p := range r.products {
+		for _, id := range ids {
+			if p.ID == id {
+				found = append(found, p)
+			}
+		}
+	}
+	return found, nil
+}
+
+func (r *syncProductRepo) ListAfter(ctx context.Context, filter product.SearchFilter, after *product.Position, limit int) ([]*product.Product, error) {
+	if after != nil {
+		return nil, nil
+	}
+	var found []*product.Product
+	for _, p := range r.products {
+		if p.CategoryID == filter.CategoryID {
+			found = append(found, p)
+		}
+	}
+	return found, nil
+}
+
+type syncIndex struct {
+	indexed []string
+	deleted []string
+	failing map[string]bool
+}
+
+func (i *syncIndex) IndexProduct(ctx context.Context, p *product.Product) error {
+	if i.failing[p.ID] {
+		return errors.New("elasticsearch unavailable")
+	}
+	i.indexed = append(i.indexed, p.ID)
+	return nil
+}
+
+func (i *syncIndex) DeleteProduct(ctx context.Context, productID string) error {
+	i.deleted = append(i.deleted, productID)
+	return nil
+}
+
+type syncCache struct {
+	invalidated []string
+	patterns    []string
+}
+
+func (c *syncCache) InvalidateProduct(ctx context.Context, productID string) error {
+	c.invalidated = append(c.invalidated, productID)
+	return nil
+}
+
+func (c *syncCache) DeletePattern(ctx context.Context, pattern string) (int64, error) {
+	c.patterns = append(c.patterns, pattern)
+	return 1, nil
+}
+
+func TestSyncSearchAppliesCapturedChanges(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	changes := &syncChangeRepo{changes: []*searchsync.Change{
+		searchsync.NewChange(searchsync.EntityProduct, "p-1", past),
+		searchsync.NewChange(searchsync.EntityProduct, "p-1", past),
+		searchsync.NewChange(searchsync.EntityProduct, "p-2", past),
+		searchsync.NewChange(searchsync.EntityProduct, "p-gone", past),
+		searchsync.NewChange(searchsync.EntityCategory, "c-2", past),
+	}}
+	products := &syncProductRepo{products: []*product.Product{
+		{ID: "p-1", CategoryID: "c-1", Status: product.StatusActive},
+		{ID: "p-2", CategoryID: "c-1", Status: product.StatusDeleted},
+		{ID: "p-3", CategoryID: "c-2", Status: product.StatusActive},
+	}}
+	index := &syncIndex{}
+	cache := &syncCache{}
+	handler := commands.NewSyncSearchCommandHandler(changes, products, index, cache, cache)
+
+	applied, err := handler.Handle(context.Background(), commands.SyncSearchCommand{})
+	require.NoError(t, err)
+	assert.Equal(t, 5, applied)
+	assert.Equal(t, []string{"p-1", "p-3"}, index.indexed, "a product changed twice is indexed once; a category reindexes its products")
+	assert.Equal(t, []string{"p-2", "p-gone"}, index.deleted, "deleted and missing products leave the index")
+	assert.ElementsMatch(t, []string{"p-1", "p-2", "p-gone", "p-3"}, cache.invalidated)
+	assert.Equal(t, searchsync.ListingPatterns, cache.patterns)
+	assert.Empty(t, changes.changes)
+}
+
+func TestSyncSearchRetriesFailedChanges(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	changes := &syncChangeRepo{changes: []*searchsync.Change{
+		searchsync.NewChange(searchsync.EntityProduct, "p-1", past),
+		searchsync.NewChange(searchsync.EntityProduct, "p-2", past),
+	}}
+	products := &syncProductRepo{products: []*product.Product{
+		{ID: "p-1", Status: product.StatusActive},
+		{ID: "p-2", Status: product.StatusActive},
+	}}
+	index := &syncIndex{failing: map[string]bool{"p-1": true}}
+	handler := commands.NewSyncSearchCommandHandler(changes, products, index, &syncCache{}, &syncCache{})
+
+	applied, err := handler.Handle(context.Background(), commands.SyncSearchCommand{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, applied)
+	require.Len(t, changes.changes, 1)
+	failed := changes.changes[0]
+	assert.Equal(t, "p-1", failed.EntityID)
+	assert.Equal(t, 1, failed.Attempts)
+	assert.Equal(t, "elasticsearch unavailable", failed.LastError)
+	assert.True(t, failed.NextAttemptAt.After(time.Now()), "a failed change waits before it is tried again")
+
+	applied, err = handler.Handle(context.Background(), commands.SyncSearchCommand{})
+	require.NoError(t, err)
+	assert.Zero(t, applied, "not due yet")
+}
+
+func TestSearchSyncChangeBackoff(t *testing.T) {
+	now := time.Now()
+	c := searchsync.NewChange(searchsync.EntityProduct, "p-1", now)
+
+	c.Fail(errors.New("down"), now)
+	assert.Equal(t, now.Add(5*time.Second), c.NextAttemptAt)
+	c.Fail(errors.New("down"), now)
+	assert.Equal(t, now.Add(10*time.Second), c.NextAttemptAt)
+	for i := 0; i < 20; i++ {
+		c.Fail(errors.New("down"), now)
+	}
+	assert.Equal(t, now.Add(10*time.Minute), c.NextAttemptAt, "the backoff is capped")
+	assert.Equal(t, 22, c.Attempts)
+}
@@ -0,0 +1,237 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/pkg/config"
+	"online-shop/pkg/jwt"
+	"online-shop/pkg/secrets"
+)
+
+type secretsProviderStub struct {
+	values map[string]map[string]string
+	err    error
+	calls  int
+}
+
+func (p *secretsProviderStub) Fetch(ctx context.Context, path string) (map[string]string, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	values, ok := p.values[path]
+	if !ok {
+		return nil, secrets.ErrNotFound
+	}
+	copied := make(map[string]string, len(values))
+	for k, v := range values {
+		copied[k] = v
+	}
+	return copied, nil
+}
+
+func TestSecretsManagerResolvesLazilyAndCaches(t *testing.T) {
+	provider := &secretsProviderStub{values: map[string]map[string]string{
+		"shop/jwt":  {"secret_key": "s3cret", "issuer": "shop"},
+		"shop/smtp": {"password": "mail-pass"},
+	}}
+	manager := secrets.NewManager(provider, time.Hour)
+	assert.Zero(t, provider.calls, "nothing is fetched before it is used")
+
+	value, err := manager.Resolve(context.Background(), "plain value")
+	require.NoError(t, err)
+	assert.Equal(t, "plain value", value)
+	assert.Zero(t, provider.calls)
+
+	value, err = manager.Resolve(context.Background(), "secret://shop/jwt#secret_key")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", value)
+	value, err = manager.Resolve(context.Background(), "secret://shop/jwt#issuer")
+	require.NoError(t, err)
+	assert.Equal(t, "shop", value)
+	assert.Equal(t, 1, provider.calls, "a secret is fetched once per ttl")
+
+	value, err = manager.Resolve(context.Background(), "secret://shop/smtp")
+	require.NoError(t, err)
+	assert.Equal(t, "mail-pass", value, "a single-value secret needs no key")
+
+	_, err = manager.Resolve(context.Background(), "secret://shop/jwt#missing")
+	assert.ErrorIs(t, err, secrets.ErrNotFound)
+}
+
+func TestSecretsManagerServesStaleValueWhenFetchFails(t *testing.T) {
+	provider := &secretsProviderStub{values: map[string]map[string]string{"shop/jwt": {"secret_key": "s3cret"}}}
+	manager := secrets.NewManager(provider, 0)
+
+	_, err := manager.Resolve(context.Background(), "secret://shop/jwt#secret_key")
+	require.NoError(t, err)
+
+	provider.err = errors.New("vault sealed")
+	value, err := manager.Resolve(context.Background(), "secret://shop/jwt#secret_key")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", value)
+	assert.Equal(t, 2, provider.calls)
+
+	_, err = manager.Resolve(context.Background(), "secret://shop/other#key")
+	assert.Error(t, err)
+}
+
+func TestSecretsManagerResolveConfig(t *testing.T) {
+	provider := &secretsProviderStub{values: map[string]map[string]string{
+		"shop/jwt":        {"secret_key": "s3cret"},
+		"shop/encryption": {"2024-05": "a2V5"},
+	}}
+	cfg := &config.Config{
+		JWT:      config.JWTConfig{SecretKey: "secret://shop/jwt#secret_key", Issuer: "shop"},
+		Database: config.DatabaseConfig{Password: "password"},
+		Encryption: config.EncryptionConfig{Keys: []config.EncryptionKeyConfig{
+			{ID: "2024-05", Key: "secret://shop/encryption#2024-05"},
+		}},
+	}
+
+	require.NoError(t, secrets.NewManager(provider, time.Hour).ResolveConfig(context.Background(), cfg))
+	assert.Equal(t, "s3cret", cfg.JWT.SecretKey)
+	assert.Equal(t, "shop", cfg.JWT.Issuer)
+	assert.Equal(t, "password", cfg.Database.Password)
+	assert.Equal(t, "a2V5", cfg.Encryption.Keys[0].Key)
+
+	cfg.SMTP.Password = "secret://shop/smtp#password"
+	err := secrets.NewManager(provider, time.Hour).ResolveConfig(context.Background(), cfg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "SMTP.Password")
+
+	err = secrets.NewManager(nil, time.Hour).ResolveConfig(context.Background(), cfg)
+	assert.ErrorIs(t, err, secrets.ErrNotConfigured)
+}
+
+func TestSecretsManagerRefreshNotifiesWatchers(t *testing.T) {
+	provider := &secretsProviderStub{values: map[string]map[string]string{"shop/midtrans": {"server_key": "key-1"}}}
+	manager := secrets.NewManager(provider, time.Hour)
+
+	var seen []string
+	manager.Watch("secret://shop/midtrans#server_key", func(v string) { seen = append(seen, v) })
+	manager.Watch("plain", func(v string) { t.Fatal("plain values are never refreshed") })
+
+	require.NoError(t, manager.Refresh(context.Background()))
+	assert.Empty(t, seen, "unchanged values are not reported")
+
+	provider.values["shop/midtrans"]["server_key"] = "key-2"
+	require.NoError(t, manager.Refresh(context.Background()))
+	assert.Equal(t, []string{"key-2"}, seen)
+
+	value, err := manager.Resolve(context.Background(), "secret://shop/midtrans#server_key")
+	require.NoError(t, err)
+	assert.Equal(t, "key-2", value, "a refresh also updates the cache")
+
+	provider.err = errors.New("unreachable")
+	assert.Error(t, manager.Refresh(context.Background()))
+	assert.Equal(t, []string{"key-2"}, seen)
+}
+
+func TestVaultProviderReadsKV2Secret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "vault-token", r.Header.Get("X-Vault-Token"))
+		assert.Equal(t, "shop-team", r.Header.Get("X-Vault-Namespace"))
+		if r.URL.Path != "/v1/kv/data/shop/jwt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"data":     map[string]interface{}{"secret_key": "s3cret", "ttl": 60},
+				"metadata": map[string]interface{}{"version": 3},
+			},
+		})
+	}))
+	defer server.Close()
+
+	provider := secrets.NewVaultProvider(&config.VaultConfig{Address: server.URL + "/", Token: "vault-token", Mount: "kv", Namespace: "shop-team"})
+	values, err := provider.Fetch(context.Background(), "shop/jwt")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"secret_key": "s3cret", "ttl": "60"}, values)
+
+	_, err = provider.Fetch(context.Background(), "shop/missing")
+	assert.ErrorIs(t, err, secrets.ErrNotFound)
+}
+
+func TestAWSProviderSignsGetSecretValue(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		assert.Equal(t, "session", r.Header.Get("X-Amz-Security-Token"))
+		auth := r.Header.Get("Authorization")
+		assert.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"))
+		assert.Contains(t, auth, "/ap-southeast-1/secretsmanager/aws4_request")
+		assert.Contains(t, auth, "SignedHeaders=content-type;host;x-amz-date;x-amz-security-token;x-amz-target")
+
+		var body struct{ SecretId string }
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		switch body.SecretId {
+		case "shop/smtp":
+			json.NewEncoder(w).Encode(map[string]string{"SecretString": `{"password":"mail-pass"}`})
+		case "shop/jwt":
+			json.NewEncoder(w).Encode(map[string]string{"SecretString": "s3cret"})
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"ResourceNotFoundException","message":"not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	provider, err := secrets.NewAWSProvider(&config.AWSSecretsConfig{
+		Region: "ap-southeast-1", AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", SessionToken: "session", Endpoint: server.URL,
+	})
+	require.NoError(t, err)
+
+	values, err := provider.Fetch(context.Background(), "shop/smtp")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"password": "mail-pass"}, values)
+
+	values, err = provider.Fetch(context.Background(), "shop/jwt")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"": "s3cret"}, values)
+
+	_, err = provider.Fetch(context.Background(), "shop/missing")
+	assert.ErrorIs(t, err, secrets.ErrNotFound)
+}
+
+func TestJWTSetSecretKeepsPreviousTokensValid(t *testing.T) {
+	cfg := jwtConfig()
+	cfg.SecretKey = "first-secret"
+	manager, err := jwt.NewJWTManager(cfg)
+	require.NoError(t, err)
+
+	before, err := manager.GenerateToken("u1", "jane@example.com", "customer")
+	require.NoError(t, err)
+
+	manager.SetSecret("second-secret")
+	after, err := manager.GenerateToken("u1", "jane@example.com", "customer")
+	require.NoError(t, err)
+
+	_, err = manager.ValidateToken(before)
+	assert.NoError(t, err, "tokens signed before the rotation stay valid")
+	_, err = manager.ValidateToken(after)
+	assert.NoError(t, err)
+
+	cfg.SecretKey = "second-secret"
+	rotated, err := jwt.NewJWTManager(cfg)
+	require.NoError(t, err)
+	_, err = rotated.ValidateToken(after)
+	assert.NoError(t, err, "new tokens are signed with the new secret")
+	_, err = rotated.ValidateToken(before)
+	assert.Error(t, err)
+
+	manager.SetSecret("third-secret")
+	_, err = manager.ValidateToken(before)
+	assert.Error(t, err, "only the last secret before a rotation is kept")
+}
@@ -0,0 +1,143 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/analytics"
+	"online-shop/internal/interfaces/http/handlers"
+	"online-shop/internal/interfaces/http/middleware"
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/config"
+)
+
+type recordingAnalyticsPublisher struct {
+	events []analytics.Event
+}
+
+func (p *recordingAnalyticsPublisher) Publish(ctx context.Context, event analytics.Event) error {
+	p.events = append(p.events, event)
+	return nil
+}
+
+func securityHeadersFor(cfg *config.SecurityHeadersConfig) http.Header {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.SecurityHeaders(cfg))
+	r.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	return w.Header()
+}
+
+func TestSecurityHeadersDefaults(t *testing.T) {
+	h := securityHeadersFor(&config.SecurityHeadersConfig{})
+
+	assert.Equal(t, "nosniff", h.Get("X-Content-Type-Options"))
+	assert.Equal(t, "DENY", h.Get("X-Frame-Options"))
+	assert.Equal(t, "strict-origin-when-cross-origin", h.Get("Referrer-Policy"))
+	assert.Contains(t, h.Get("Content-Security-Policy"), "frame-ancestors 'none'")
+	assert.Empty(t, h.Get("Strict-Transport-Security"), "HSTS is only sent where it is enabled")
+	assert.Empty(t, h.Get("Reporting-Endpoints"))
+}
+
+func TestSecurityHeadersFromConfig(t *testing.T) {
+	h := securityHeadersFor(&config.SecurityHeadersConfig{
+		ContentSecurityPolicy: "default-src 'none'",
+		CSPReportOnly:         true,
+		CSPReportURI:          "/csp-reports",
+		HSTS:                  config.HSTSConfig{Enabled: true, MaxAgeSeconds: 600, IncludeSubdomains: true},
+		ReferrerPolicy:        "no-referrer",
+		FrameOptions:          "sameorigin",
+	})
+
+	assert.Empty(t, h.Get("Content-Security-Policy"))
+	assert.Equal(t, "default-src 'none'; report-uri /csp-reports; report-to csp-endpoint", h.Get("Content-Security-Policy-Report-Only"))
+	assert.Equal(t, `csp-endpoint="/csp-reports"`, h.Get("Reporting-Endpoints"))
+	assert.Equal(t, "max-age=600; includeSubDomains", h.Get("Strict-Transport-Security"))
+	assert.Equal(t, "no-referrer", h.Get("Referrer-Policy"))
+	assert.Equal(t, "SAMEORIGIN", h.Get("X-Frame-Options"))
+}
+
+func TestSecurityHeadersConfigValidation(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.SecurityHeaders.FrameOptions = "ALLOW-FROM https://example.com"
+	cfg.SecurityHeaders.HSTS = config.HSTSConfig{Enabled: true, Preload: true}
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "security_headers.frame_options")
+	assert.Contains(t, err.Error(), "preload needs include_subdomains")
+}
+
+func postCSPReport(t *testing.T, publisher analytics.Publisher, contentType, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Errors())
+	h := handlers.NewCSPReportHandler(commands.NewRecordCSPReportCommandHandler(publisher))
+	r.POST("/csp-reports", h.ReportViolation)
+
+	req := httptest.NewRequest(http.MethodPost, "/csp-reports", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCSPReportURIFormatIsRecorded(t *testing.T) {
+	publisher := &recordingAnalyticsPublisher{}
+	w := postCSPReport(t, publisher, "application/csp-report", `{"csp-report": {
+		"document-uri": "https://shop.example.com/checkout",
+		"referrer": "https://shop.example.com/cart",
+		"violated-directive": "script-src-elem",
+		"blocked-uri": "https://evil.example.com/skim.js",
+		"disposition": "enforce",
+		"source-file": "https://shop.example.com/checkout",
+		"line-number": 12
+	}}`)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	require.Len(t, publisher.events, 1)
+	event := publisher.events[0]
+	assert.Equal(t, analytics.EventTypeSecurity, event.EventType)
+	assert.Equal(t, analytics.EventCSPViolation, event.EventName)
+	assert.Equal(t, "https://shop.example.com/checkout", event.PageURL)
+	assert.Equal(t, "https://shop.example.com/cart", event.Referrer)
+	assert.Equal(t, "Mozilla/5.0", event.UserAgent)
+	assert.Equal(t, "https://evil.example.com/skim.js", event.Properties["blocked_uri"])
+	assert.Equal(t, "script-src-elem", event.Properties["effective_directive"], "defaults to the violated directive")
+	assert.Equal(t, 12, event.Properties["line_number"])
+}
+
+func TestCSPReportingAPIFormatIsRecorded(t *testing.T) {
+	publisher := &recordingAnalyticsPublisher{}
+	w := postCSPReport(t, publisher, "application/reports+json", `[
+		{"type": "csp-violation", "body": {"documentURL": "https://shop.example.com/", "blockedURL": "inline", "effectiveDirective": "style-src", "disposition": "report"}},
+		{"type": "deprecation", "body": {"id": "old-api"}}
+	]`)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	require.Len(t, publisher.events, 1, "reports of other types are ignored")
+	assert.Equal(t, "style-src", publisher.events[0].Properties["effective_directive"])
+	assert.Equal(t, "report", publisher.events[0].Properties["disposition"])
+}
+
+func TestInvalidCSPReportIsRefused(t *testing.T) {
+	publisher := &recordingAnalyticsPublisher{}
+	w := postCSPReport(t, publisher, "application/csp-report", `{"not": "a report"}`)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), commands.ErrInvalidCSPReport.Code)
+	assert.Empty(t, publisher.events)
+	assert.Equal(t, apperror.KindInvalidArgument, commands.ErrInvalidCSPReport.Kind)
+}
@@ -0,0 +1,182 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/session"
+	"online-shop/internal/interfaces/http/middleware"
+	"online-shop/pkg/config"
+	"online-shop/pkg/jwt"
+)
+
+type memorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]session.Session
+	touches  int
+}
+
+func newMemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{sessions: make(map[string]session.Session)}
+}
+
+func (s *memorySessionStore) Save(ctx context.Context, sess *session.Session, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess.ExpiresAt = time.Now().Add(ttl)
+	s.sessions[sess.ID] = *sess
+	return nil
+}
+
+func (s *memorySessionStore) Get(ctx context.Context, id string) (*session.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil, session.ErrNotFound
+	}
+	return &sess, nil
+}
+
+func (s *memorySessionStore) Touch(ctx context.Context, sess *session.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.touches++
+	s.sessions[sess.ID] = *sess
+	return nil
+}
+
+func (s *memorySessionStore) ListByUser(ctx context.Context, userID string) ([]*session.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list []*session.Session
+	for _, sess := range s.sessions {
+		if sess.UserID == userID {
+			sess := sess
+			list = append(list, &sess)
+		}
+	}
+	return list, nil
+}
+
+func (s *memorySessionStore) Delete(ctx context.Context, sess *session.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sess.ID)
+	return nil
+}
+
+func startTestSession(t *testing.T, store session.Store, userID string) *session.Session {
+	sess, err := commands.NewStartSessionCommandHandler(store, time.Hour).Handle(context.Background(), commands.StartSessionCommand{
+		UserID:    userID,
+		UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/124.0 Safari/537.36",
+		IPAddress: "10.0.0.1",
+	})
+	require.NoError(t, err)
+	return sess
+}
+
+func TestDescribeUserAgent(t *testing.T) {
+	assert.Equal(t, "Chrome on Windows", session.DescribeUserAgent("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/124.0 Safari/537.36"))
+	assert.Equal(t, "Safari on iPhone", session.DescribeUserAgent("Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 Version/17.4 Mobile/15E148 Safari/604.1"))
+	assert.Equal(t, "Unknown device", session.DescribeUserAgent("curl/8.5.0"))
+}
+
+func TestRevokeSessionOnlyForOwner(t *testing.T) {
+	store := newMemorySessionStore()
+	sess := startTestSession(t, store, "u1")
+	revoke := commands.NewRevokeSessionCommandHandler(store)
+
+	err := revoke.Handle(context.Background(), commands.RevokeSessionCommand{UserID: "u2", SessionID: sess.ID})
+	assert.ErrorIs(t, err, session.ErrNotFound)
+
+	require.NoError(t, revoke.Handle(context.Background(), commands.RevokeSessionCommand{UserID: "u1", SessionID: sess.ID}))
+	_, err = store.Get(context.Background(), sess.ID)
+	assert.ErrorIs(t, err, session.ErrNotFound)
+}
+
+func TestRevokeAllSessionsKeepsCurrent(t *testing.T) {
+	store := newMemorySessionStore()
+	current := startTestSession(t, store, "u1")
+	startTestSession(t, store, "u1")
+	startTestSession(t, store, "u1")
+	other := startTestSession(t, store, "u2")
+
+	revoked, err := commands.NewRevokeAllSessionsCommandHandler(store).Handle(context.Background(), commands.RevokeAllSessionsCommand{UserID: "u1", KeepSessionID: current.ID})
+	require.NoError(t, err)
+	assert.Equal(t, 2, revoked)
+
+	sessions, err := queries.NewListSessionsQueryHandler(store).Handle(context.Background(), queries.ListSessionsQuery{UserID: "u1"})
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, current.ID, sessions[0].ID)
+	assert.Equal(t, "Chrome on Windows", sessions[0].Device)
+
+	_, err = store.Get(context.Background(), other.ID)
+	assert.NoError(t, err, "other users keep their sessions")
+}
+
+func TestRefreshRevokedSessionFails(t *testing.T) {
+	store := newMemorySessionStore()
+	sess := startTestSession(t, store, "u1")
+	refresh := commands.NewRefreshSessionCommandHandler(store, time.Hour)
+
+	refreshed, err := refresh.Handle(context.Background(), commands.RefreshSessionCommand{UserID: "u1", SessionID: sess.ID, IPAddress: "10.0.0.2"})
+	require.NoError(t, err)
+	assert.Equal(t, sess.ID, refreshed.ID)
+	assert.Equal(t, "10.0.0.2", refreshed.IPAddress)
+
+	require.NoError(t, store.Delete(context.Background(), sess))
+	_, err = refresh.Handle(context.Background(), commands.RefreshSessionCommand{UserID: "u1", SessionID: sess.ID})
+	assert.ErrorIs(t, err, session.ErrNotFound)
+
+	_, err = refresh.Handle(context.Background(), commands.RefreshSessionCommand{UserID: "u1"})
+	assert.ErrorIs(t, err, session.ErrNotFound, "a refresh token without a session starts none")
+	assert.Empty(t, store.sessions)
+}
+
+func TestAuthMiddlewareRejectsRevokedSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	manager, err := jwt.NewJWTManager(&config.JWTConfig{SecretKey: "secret", ExpiryHours: 1, RefreshExpiryHours: 24, Issuer: "online-shop"})
+	require.NoError(t, err)
+	store := newMemorySessionStore()
+	auth := middleware.NewAuthMiddleware(manager, store)
+
+	router := gin.New()
+	router.GET("/me", auth.RequireAuth(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"session_id": c.GetString("session_id")})
+	})
+	call := func(token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	sess := startTestSession(t, store, "u1")
+	pair, err := manager.GenerateTokenPair("u1", "jane@example.com", "customer", sess.ID)
+	require.NoError(t, err)
+
+	w := call(pair.AccessToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), sess.ID)
+
+	require.NoError(t, commands.NewRevokeSessionCommandHandler(store).Handle(context.Background(), commands.RevokeSessionCommand{UserID: "u1", SessionID: sess.ID}))
+	assert.Equal(t, http.StatusUnauthorized, call(pair.AccessToken).Code)
+
+	// Tokens without a session could never be revoked
+	unbound, err := manager.GenerateToken("u1", "jane@example.com", "customer")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, call(unbound).Code)
+}
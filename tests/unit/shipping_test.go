@@ -0,0 +1,196 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/shipping"
+)
+
+type shippingZoneRepo struct {
+	shipping.Repository
+	zones []*shipping.Zone
+}
+
+func (r *shippingZoneRepo) ListActive(ctx context.Context) ([]*shipping.Zone, error) {
+	var active []*shipping.Zone
+	for _, z := range r.zones {
+		if z.Active {
+			active = append(active, z)
+		}
+	}
+	return active, nil
+}
+
+type geocoderStub struct {
+	location *shipping.Location
+	err      error
+	calls    int
+}
+
+func (g *geocoderStub) Name() string { return "stub" }
+
+func (g *geocoderStub) Geocode(ctx context.Context, a order.Address) (*shipping.Location, error) {
+	g.calls++
+	return g.location, g.err
+}
+
+type locationCacheStub struct {
+	locations map[string]*shipping.Location
+}
+
+func (c *locationCacheStub) Get(ctx context.Context, key string) (*shipping.Location, error) {
+	return c.locations[key], nil
+}
+
+func (c *locationCacheStub) Set(ctx context.Context, key string, location *shipping.Location, ttl time.Duration) error {
+	c.locations[key] = location
+	return nil
+}
+
+func shippingZones(t *testing.T) []*shipping.Zone {
+	indonesia, err := shipping.NewZone("Indonesia", []string{"ID"}, nil, nil, []shipping.Rate{
+		{Name: "Regular", Fee: 20000, FreeOver: 500000, MinDays: 3, MaxDays: 5},
+		{Name: "Express", Fee: 45000, MinDays: 1, MaxDays: 2},
+	})
+	require.NoError(t, err)
+	jakarta, err := shipping.NewZone("Jakarta", []string{"ID"}, nil, []shipping.PostalRange{{From: "10110", To: "14540"}}, []shipping.Rate{
+		{Name: "Same day", Fee: 15000},
+	})
+	require.NoError(t, err)
+	return []*shipping.Zone{indonesia, jakarta}
+}
+
+var jakartaAddress = order.Address{Street: "Jl. Merdeka 1", City: "Jakarta", PostalCode: "10110", Country: "ID"}
+
+func TestShippingZoneMatchesMostSpecific(t *testing.T) {
+	zones := shippingZones(t)
+
+	assert.Equal(t, "Jakarta", shipping.Match(zones, jakartaAddress).Name)
+	bandung := order.Address{City: "Bandung", PostalCode: "40111", Country: "id"}
+	assert.Equal(t, "Indonesia", shipping.Match(zones, bandung).Name, "countries match ignoring case")
+	assert.Nil(t, shipping.Match(zones, order.Address{PostalCode: "018956", Country: "SG"}))
+
+	zones[1].Active = false
+	assert.Equal(t, "Indonesia", shipping.Match(zones, jakartaAddress).Name, "inactive zones are skipped")
+
+	bali, err := shipping.NewZone("Bali", []string{"ID"}, []string{"Bali"}, nil, []shipping.Rate{{Name: "Regular", Fee: 30000}})
+	require.NoError(t, err)
+	denpasar := order.Address{State: " bali ", PostalCode: "80111", Country: "ID"}
+	assert.Equal(t, "Bali", shipping.Match(append(zones, bali), denpasar).Name)
+}
+
+func TestShippingPostalRange(t *testing.T) {
+	r := shipping.PostalRange{From: "SW1A 0AA", To: "SW1A-9ZZ"}
+	assert.True(t, r.Contains("sw1a 1aa"))
+	assert.False(t, r.Contains("SW1B 1AA"))
+	assert.False(t, r.Contains("SW1A1"), "codes of another length are outside the range")
+
+	_, err := shipping.NewZone("Broken", []string{"ID"}, nil, []shipping.PostalRange{{From: "20000", To: "1000"}}, []shipping.Rate{{Name: "Regular"}})
+	assert.ErrorIs(t, err, shipping.ErrInvalidZone)
+	_, err = shipping.NewZone("Twice", []string{"ID"}, nil, nil, []shipping.Rate{{Name: "Regular"}, {Name: "regular"}})
+	assert.ErrorIs(t, err, shipping.ErrInvalidZone)
+}
+
+func TestShippingQuoteListsRatesCheapestFirst(t *testing.T) {
+	zones := shippingZones(t)
+	handler := queries.NewGetShippingQuoteQueryHandler(&shippingZoneRepo{zones: zones}, nil, nil, time.Hour, nil)
+
+	quote, err := handler.Handle(context.Background(), queries.GetShippingQuoteQuery{
+		Address:  order.Address{City: "Surabaya", PostalCode: "60111", Country: "ID"},
+		Subtotal: 600000,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, zones[0].ID, quote.ZoneID)
+	require.Len(t, quote.Options, 2)
+	assert.Equal(t, "Regular", quote.Options[0].Name)
+	assert.Zero(t, quote.Options[0].Fee, "free over 500000")
+	assert.Equal(t, 45000.0, quote.Options[1].Fee)
+
+	_, err = handler.Handle(context.Background(), queries.GetShippingQuoteQuery{Address: order.Address{PostalCode: "018956", Country: "SG"}})
+	assert.ErrorIs(t, err, shipping.ErrNotServiceable)
+
+	open := queries.NewGetShippingQuoteQueryHandler(&shippingZoneRepo{}, nil, nil, time.Hour, nil)
+	quote, err = open.Handle(context.Background(), queries.GetShippingQuoteQuery{Address: jakartaAddress})
+	require.NoError(t, err)
+	assert.Empty(t, quote.ZoneID, "a shop without zones ships everywhere")
+	assert.Empty(t, quote.Options)
+}
+
+func TestShippingQuoteChecksAddress(t *testing.T) {
+	zones := &shippingZoneRepo{zones: shippingZones(t)}
+	cache := &locationCacheStub{locations: map[string]*shipping.Location{}}
+	geocoder := &geocoderStub{location: &shipping.Location{Latitude: -6.17, Longitude: 106.82, Country: "ID", State: "DKI Jakarta", PostalCode: "10110"}}
+	handler := queries.NewGetShippingQuoteQueryHandler(zones, geocoder, cache, time.Hour, nil)
+
+	quote, err := handler.Handle(context.Background(), queries.GetShippingQuoteQuery{Address: jakartaAddress})
+	require.NoError(t, err)
+	assert.Equal(t, "Jakarta", quote.ZoneName)
+	assert.Equal(t, -6.17, quote.Location.Latitude)
+	_, err = handler.Handle(context.Background(), queries.GetShippingQuoteQuery{Address: jakartaAddress})
+	require.NoError(t, err)
+	assert.Equal(t, 1, geocoder.calls, "the second lookup is served from the cache")
+	assert.Contains(t, cache.locations, shipping.AddressKey(jakartaAddress))
+
+	other := order.Address{Street: "Nowhere 1", City: "Atlantis", PostalCode: "99999", Country: "ID"}
+	geocoder.location, geocoder.err = nil, shipping.ErrAddressNotFound
+	_, err = handler.Handle(context.Background(), queries.GetShippingQuoteQuery{Address: other})
+	assert.ErrorIs(t, err, shipping.ErrAddressNotFound)
+
+	geocoder.location, geocoder.err = &shipping.Location{Country: "MY"}, nil
+	_, err = handler.Handle(context.Background(), queries.GetShippingQuoteQuery{Address: other})
+	assert.ErrorIs(t, err, shipping.ErrAddressNotFound, "found in another country")
+
+	geocoder.location, geocoder.err = nil, errors.New("geocoder unavailable")
+	surabaya := order.Address{Street: "Jl. Tunjungan 1", City: "Surabaya", PostalCode: "60261", Country: "ID"}
+	quote, err = handler.Handle(context.Background(), queries.GetShippingQuoteQuery{Address: surabaya})
+	require.NoError(t, err, "the address is taken as given while the geocoder is down")
+	assert.Equal(t, "Indonesia", quote.ZoneName)
+	assert.Nil(t, quote.Location)
+}
+
+func TestCreateOrderChargesShipping(t *testing.T) {
+	products := &flashProductRepo{products: map[string]*product.Product{
+		"p1": {ID: "p1", Price: 100000, Stock: 50, Status: product.StatusActive},
+	}}
+	orders := &flashOrderRepo{orders: map[string]*order.Order{}}
+	zones := shippingZones(t)
+	quoter := queries.NewGetShippingQuoteQueryHandler(&shippingZoneRepo{zones: zones}, nil, nil, time.Hour, nil)
+	create := commands.NewCreateOrderCommandHandler(orders, products, noCommissionRepo{}, noWarehouseRepo{}, nil, &flashSaleRepoStub{}, newMemoryFlashCounter(), commands.CreateOrderOptions{ShippingQuoter: quoter})
+	cmd := commands.CreateOrderCommand{
+		UserID:          "u1",
+		Items:           []commands.CreateOrderItemCmd{{ProductID: "p1", Quantity: 2}},
+		ShippingAddress: order.Address{Street: "Jl. Asia Afrika 8", City: "Bandung", PostalCode: "40111", Country: "ID"},
+	}
+
+	created, err := create.Handle(context.Background(), cmd)
+	require.NoError(t, err)
+	assert.Equal(t, "Regular", created.Shipping.Rate, "the cheapest rate without a choice")
+	assert.Equal(t, 20000.0, created.Shipping.Fee)
+	assert.Equal(t, 220000.0, created.TotalAmount)
+
+	cmd.ShippingRateID = zones[0].Rates[1].ID
+	created, err = create.Handle(context.Background(), cmd)
+	require.NoError(t, err)
+	assert.Equal(t, "Express", created.Shipping.Rate)
+	assert.Equal(t, 245000.0, created.TotalAmount)
+
+	cmd.ShippingRateID = zones[1].Rates[0].ID
+	_, err = create.Handle(context.Background(), cmd)
+	assert.ErrorIs(t, err, shipping.ErrRateNotFound, "a rate of another zone")
+
+	cmd.ShippingRateID = ""
+	cmd.ShippingAddress = order.Address{Street: "1 Raffles Place", City: "Singapore", PostalCode: "048616", Country: "SG"}
+	_, err = create.Handle(context.Background(), cmd)
+	assert.ErrorIs(t, err, shipping.ErrNotServiceable)
+	assert.Len(t, orders.orders, 2)
+}
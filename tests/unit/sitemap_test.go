@@ -0,0 +1,131 @@
+package unit
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/domain/product"
+	"online-shop/internal/infrastructure/sitemap"
+)
+
+type sitemapProductRepo struct {
+	product.Repository
+	products []*product.Product
+}
+
+func (r *sitemapProductRepo) List(ctx context.Context, filter product.SearchFilter) ([]*product.Product, error) {
+	if filter.Offset >= len(r.products) {
+		return nil, nil
+	}
+	end := filter.Offset + filter.Limit
+	if end > len(r.products) {
+		end = len(r.products)
+	}
+	return r.products[filter.Offset:end], nil
+}
+
+type sitemapCategoryRepo struct {
+	product.CategoryRepository
+	categories []*product.Category
+}
+
+func (r *sitemapCategoryRepo) List(ctx context.Context, limit, offset int) ([]*product.Category, error) {
+	if offset >= len(r.categories) {
+		return nil, nil
+	}
+	end := offset + limit
+	if end > len(r.categories) {
+		end = len(r.categories)
+	}
+	return r.categories[offset:end], nil
+}
+
+func TestSitemapGeneratorPaginates(t *testing.T) {
+	products := &sitemapProductRepo{products: []*product.Product{
+		{ID: "1", Slug: "red-shoes"},
+		{ID: "2", Slug: "blue-shoes"},
+		{ID: "3", SEO: product.SEO{CanonicalURL: "https://shop.test/products/canonical"}},
+	}}
+	categories := &sitemapCategoryRepo{categories: []*product.Category{{ID: "c1", Slug: "shoes"}}}
+
+	dir := filepath.Join(t.TempDir(), "sitemaps")
+	generator := sitemap.NewGenerator(products, categories, "https://shop.test/", dir, 2)
+	require.NoError(t, generator.Generate(context.Background()))
+
+	pages := sitemapPages(t, dir)
+	require.Len(t, pages, 3)
+	assert.Regexp(t, `^sitemap-\d+-products-1\.xml\.gz$`, pages[0])
+	assert.Regexp(t, `^sitemap-\d+-products-2\.xml\.gz$`, pages[1])
+	assert.Regexp(t, `^sitemap-\d+-categories-1\.xml\.gz$`, pages[2])
+
+	page := readGzipFile(t, filepath.Join(dir, pages[1]))
+	assert.Contains(t, page, "<loc>https://shop.test/products/canonical</loc>")
+	assert.NotContains(t, page, "red-shoes")
+
+	// Regenerating writes a new generation and keeps the one it replaced
+	// for readers still holding the previous index
+	products.products = products.products[:1]
+	require.NoError(t, generator.Generate(context.Background()))
+	second := sitemapPages(t, dir)
+	require.Len(t, second, 2)
+	assert.NotEqual(t, pages[0], second[0])
+	_, err := os.Stat(filepath.Join(dir, pages[1]))
+	assert.NoError(t, err)
+
+	// and removes the generations before that
+	require.NoError(t, generator.Generate(context.Background()))
+	_, err = os.Stat(filepath.Join(dir, pages[1]))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, second[0]))
+	assert.NoError(t, err)
+}
+
+func TestApplySEODefaults(t *testing.T) {
+	p := &product.Product{
+		ID:          "1",
+		Name:        "Red Shoes",
+		Slug:        "red-shoes",
+		Description: strings.Repeat("comfortable ", 20),
+		SEO:         product.SEO{MetaTitle: "Buy Red Shoes"},
+	}
+
+	p.ApplySEODefaults("https://shop.test/")
+
+	assert.Equal(t, "Buy Red Shoes", p.SEO.MetaTitle)
+	assert.Equal(t, "https://shop.test/products/red-shoes", p.SEO.CanonicalURL)
+	assert.LessOrEqual(t, len([]rune(p.SEO.MetaDescription)), 161)
+	assert.True(t, strings.HasSuffix(p.SEO.MetaDescription, "comfortable…"))
+}
+
+func readGzipFile(t *testing.T, path string) string {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	data, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	return string(data)
+}
+
+// sitemapPages returns the names of the pages the index in dir links to.
+func sitemapPages(t *testing.T, dir string) []string {
+	index, err := os.ReadFile(filepath.Join(dir, sitemap.IndexFile))
+	require.NoError(t, err)
+
+	var pages []string
+	for _, m := range regexp.MustCompile(`<loc>https://shop\.test/sitemaps/([^<]+)</loc>`).FindAllStringSubmatch(string(index), -1) {
+		pages = append(pages, m[1])
+	}
+	return pages
+}
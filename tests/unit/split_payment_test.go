@@ -0,0 +1,207 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/payment"
+	"online-shop/pkg/apperror"
+)
+
+type memoryPaymentRepo struct {
+	payment.Repository
+	payments []*payment.Payment
+}
+
+func (r *memoryPaymentRepo) Create(ctx context.Context, p *payment.Payment) error {
+	r.payments = append(r.payments, p)
+	return nil
+}
+
+func (r *memoryPaymentRepo) GetByID(ctx context.Context, id string) (*payment.Payment, error) {
+	for _, p := range r.payments {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("payment %s not found", id)
+}
+
+func (r *memoryPaymentRepo) ListByOrderID(ctx context.Context, orderID string) ([]*payment.Payment, error) {
+	var found []*payment.Payment
+	for _, p := range r.payments {
+		if p.OrderID == orderID {
+			found = append(found, p)
+		}
+	}
+	return found, nil
+}
+
+func (r *memoryPaymentRepo) Update(ctx context.Context, p *payment.Payment) error {
+	return nil
+}
+
+type linkProviderStub struct {
+	payment.PaymentProvider
+	links int
+}
+
+func (p *linkProviderStub) CreatePayment(ctx context.Context, pay *payment.Payment) (*payment.PaymentResponse, error) {
+	p.links++
+	return &payment.PaymentResponse{
+		PaymentURL: "https://pay.example/" + pay.ID,
+		ExternalID: pay.ID,
+		ExpiresAt:  time.Now().Add(24 * time.Hour),
+	}, nil
+}
+
+func newPayableOrder(orders *flashOrderRepo, total float64) *order.Order {
+	o := &order.Order{ID: fmt.Sprintf("order-%d", len(orders.orders)+1), UserID: "u1", TotalAmount: total, Status: order.StatusPending, PaymentStatus: order.PaymentStatusUnpaid}
+	orders.orders[o.ID] = o
+	return o
+}
+
+func TestInstallmentsAddUpToTheTotal(t *testing.T) {
+	start := time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)
+	parts, err := payment.Installments(1000.5, 3, payment.MethodBankTransfer, start)
+	require.NoError(t, err)
+	require.Len(t, parts, 3)
+	assert.Equal(t, 334.5, parts[0].Amount, "the remainder goes on the first installment")
+	assert.Equal(t, 333.0, parts[2].Amount)
+	assert.Equal(t, 3, parts[2].Installment)
+	assert.True(t, parts[1].DueAt.After(start))
+	assert.NoError(t, payment.CheckSplit(1000.5, parts))
+
+	_, err = payment.Installments(1000, 13, payment.MethodBankTransfer, start)
+	assert.ErrorIs(t, err, payment.ErrInvalidSplit)
+
+	err = payment.CheckSplit(100, []payment.Allocation{{Method: payment.MethodEWallet, Amount: 30}, {Method: payment.MethodCreditCard, Amount: 60}})
+	assert.Equal(t, commands.ErrInvalidPaymentSplit.Code, apperror.From(err).Code, "the split must cover what is outstanding")
+}
+
+func TestSplitPaymentReconcilesAsPartsSettle(t *testing.T) {
+	orders := &flashOrderRepo{orders: map[string]*order.Order{}}
+	payments := &memoryPaymentRepo{}
+	links := &linkProviderStub{}
+	methods := newMemoryMethodRepo()
+	card := addCard(t, commands.NewAddPaymentMethodCommandHandler(methods, "midtrans"), "u1", "tok-a")
+	cards := &tokenProviderStub{result: &payment.ChargeResult{Status: payment.StatusPaid, TransactionID: "trx-1"}}
+	create := commands.NewCreateOrderPaymentsCommandHandler(orders, payments, methods, links, cards, nil, nil)
+	reconcile := commands.NewReconcileOrderPaymentsCommandHandler(orders, payments, nil, nil)
+
+	o := newPayableOrder(orders, 500)
+	summary, err := create.Handle(context.Background(), commands.CreateOrderPaymentsCommand{
+		OrderID: o.ID,
+		UserID:  "u1",
+		Allocations: []commands.PaymentAllocationCmd{
+			{Method: payment.MethodCreditCard, Amount: 200, PaymentMethodID: card.ID},
+			{Method: payment.MethodEWallet, Amount: 300},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 200.0, summary.Paid, "the saved card is charged straight away")
+	assert.Equal(t, 300.0, summary.Outstanding)
+	assert.Equal(t, 1, links.links)
+	assert.Equal(t, order.PaymentStatusPartiallyPaid, o.PaymentStatus)
+	assert.Equal(t, order.StatusPending, o.Status)
+
+	_, err = create.Handle(context.Background(), commands.CreateOrderPaymentsCommand{OrderID: o.ID, UserID: "u1", Installments: &commands.InstallmentPlanCmd{Count: 2, Method: payment.MethodBankTransfer}})
+	assert.Equal(t, commands.ErrOrderPaymentsOpen.Code, apperror.From(err).Code)
+
+	// The e-wallet part fails; what it covered can be paid again
+	summary.Payments[1].Status = payment.StatusFailed
+	require.NoError(t, reconcile.Handle(context.Background(), commands.ReconcileOrderPaymentsCommand{OrderID: o.ID}))
+	summary, err = create.Handle(context.Background(), commands.CreateOrderPaymentsCommand{OrderID: o.ID, UserID: "u1", Installments: &commands.InstallmentPlanCmd{Count: 3, Method: payment.MethodBankTransfer}})
+	require.NoError(t, err)
+	require.Len(t, summary.Payments, 5)
+	assert.Equal(t, 300.0, summary.Pending, "only the failed share is planned again")
+	assert.Equal(t, 2, links.links, "only the installment due now gets a link")
+
+	later := summary.Payments[4]
+	assert.Empty(t, later.PaymentURL)
+	pay := commands.NewPayOrderPaymentCommandHandler(orders, payments, links)
+	paid, err := pay.Handle(context.Background(), commands.PayOrderPaymentCommand{OrderID: o.ID, UserID: "u1", PaymentID: later.ID})
+	require.NoError(t, err)
+	assert.NotEmpty(t, paid.PaymentURL, "an installment can be paid ahead of its due date")
+
+	for _, p := range summary.Payments[2:] {
+		p.MarkAsPaid("trx")
+	}
+	require.NoError(t, reconcile.Handle(context.Background(), commands.ReconcileOrderPaymentsCommand{OrderID: o.ID}))
+	assert.Equal(t, order.PaymentStatusPaid, o.PaymentStatus)
+	assert.Equal(t, order.StatusConfirmed, o.Status, "the order is confirmed once every part settles")
+	assert.Equal(t, 500.0, o.PaidAmount)
+
+	_, err = create.Handle(context.Background(), commands.CreateOrderPaymentsCommand{OrderID: o.ID, UserID: "u1", Installments: &commands.InstallmentPlanCmd{Count: 2, Method: payment.MethodBankTransfer}})
+	assert.Equal(t, commands.ErrOrderAlreadyPaid.Code, apperror.From(err).Code)
+}
+
+func TestDeclinedCardKeepsWhatWasCharged(t *testing.T) {
+	orders := &flashOrderRepo{orders: map[string]*order.Order{}}
+	payments := &memoryPaymentRepo{}
+	methods := newMemoryMethodRepo()
+	add := commands.NewAddPaymentMethodCommandHandler(methods, "midtrans")
+	first := addCard(t, add, "u1", "tok-a")
+	second := addCard(t, add, "u1", "tok-b")
+	third := addCard(t, add, "u1", "tok-c")
+	cards := &tokenProviderStub{
+		result:   &payment.ChargeResult{Status: payment.StatusPaid, TransactionID: "trx-1"},
+		declined: map[string]error{"tok-b": fmt.Errorf("%w: insufficient funds", payment.ErrChargeDeclined)},
+	}
+	links := &linkProviderStub{}
+	create := commands.NewCreateOrderPaymentsCommandHandler(orders, payments, methods, links, cards, nil, nil)
+
+	o := newPayableOrder(orders, 500)
+	_, err := create.Handle(context.Background(), commands.CreateOrderPaymentsCommand{
+		OrderID: o.ID,
+		UserID:  "u1",
+		Allocations: []commands.PaymentAllocationCmd{
+			{Method: payment.MethodEWallet, Amount: 200},
+			{Method: payment.MethodCreditCard, Amount: 100, PaymentMethodID: first.ID},
+			{Method: payment.MethodCreditCard, Amount: 100, PaymentMethodID: second.ID},
+			{Method: payment.MethodCreditCard, Amount: 100, PaymentMethodID: third.ID},
+		},
+	})
+	assert.Equal(t, commands.ErrPaymentFailed.Code, apperror.From(err).Code)
+	assert.Equal(t, []string{"tok-a", "tok-b"}, cards.charged, "no card is charged after a decline")
+	require.Len(t, payments.payments, 4, "every share is recorded")
+	assert.Equal(t, payment.StatusPending, payments.payments[0].Status)
+	assert.NotEmpty(t, payments.payments[0].PaymentURL, "the link already issued is kept")
+	assert.Equal(t, 1, links.links)
+	assert.Equal(t, payment.StatusPaid, payments.payments[1].Status, "the charge already made stays recorded")
+	assert.Equal(t, payment.StatusFailed, payments.payments[2].Status)
+	assert.Equal(t, payment.StatusFailed, payments.payments[3].Status)
+	assert.Equal(t, 100.0, o.PaidAmount)
+	assert.Equal(t, order.PaymentStatusPartiallyPaid, o.PaymentStatus)
+
+	_, err = create.Handle(context.Background(), commands.CreateOrderPaymentsCommand{OrderID: o.ID, UserID: "u2", Installments: &commands.InstallmentPlanCmd{Count: 2, Method: payment.MethodBankTransfer}})
+	assert.Equal(t, commands.ErrForbidden.Code, apperror.From(err).Code)
+}
+
+func TestTimedOutChargeIsLeftPending(t *testing.T) {
+	orders := &flashOrderRepo{orders: map[string]*order.Order{}}
+	payments := &memoryPaymentRepo{}
+	methods := newMemoryMethodRepo()
+	card := addCard(t, commands.NewAddPaymentMethodCommandHandler(methods, "midtrans"), "u1", "tok-a")
+	cards := &tokenProviderStub{err: errors.New("midtrans: request timed out")}
+	create := commands.NewCreateOrderPaymentsCommandHandler(orders, payments, methods, &linkProviderStub{}, cards, nil, nil)
+
+	o := newPayableOrder(orders, 500)
+	summary, err := create.Handle(context.Background(), commands.CreateOrderPaymentsCommand{
+		OrderID:     o.ID,
+		UserID:      "u1",
+		Allocations: []commands.PaymentAllocationCmd{{Method: payment.MethodCreditCard, Amount: 500, PaymentMethodID: card.ID}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 500.0, summary.Pending, "the charge may have gone through, so the webhook settles it")
+	require.Len(t, payments.payments, 1)
+	assert.Equal(t, payment.StatusPending, payments.payments[0].Status)
+}
@@ -0,0 +1,190 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/stockalert"
+	"online-shop/internal/domain/user"
+)
+
+type memoryStockAlertRepo struct {
+	subscriptions []*stockalert.Subscription
+}
+
+func (r *memoryStockAlertRepo) Create(ctx context.Context, s *stockalert.Subscription) error {
+	r.subscriptions = append(r.subscriptions, s)
+	return nil
+}
+
+func (r *memoryStockAlertRepo) GetByID(ctx context.Context, id string) (*stockalert.Subscription, error) {
+	for _, s := range r.subscriptions {
+		if s.ID == id {
+			return s, nil
+		}
+	}
+	return nil, stockalert.ErrNotFound
+}
+
+func (r *memoryStockAlertRepo) Update(ctx context.Context, s *stockalert.Subscription) error {
+	return nil
+}
+
+func (r *memoryStockAlertRepo) FindActive(ctx context.Context, userID, productID string) (*stockalert.Subscription, error) {
+	for _, s := range r.subscriptions {
+		if s.UserID == userID && s.ProductID == productID && s.IsActive() {
+			return s, nil
+		}
+	}
+	return nil, stockalert.ErrNotFound
+}
+
+func (r *memoryStockAlertRepo) ListByUser(ctx context.Context, userID string, limit, offset int) ([]*stockalert.Subscription, error) {
+	return nil, nil
+}
+
+func (r *memoryStockAlertRepo) ListProductIDs(ctx context.Context) ([]string, error) {
+	seen := map[string]bool{}
+	var ids []string
+	for _, s := range r.subscriptions {
+		if s.IsActive() && !seen[s.ProductID] {
+			seen[s.ProductID] = true
+			ids = append(ids, s.ProductID)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (r *memoryStockAlertRepo) ListActiveByProduct(ctx context.Context, productID string, limit int) ([]*stockalert.Subscription, error) {
+	var found []*stockalert.Subscription
+	for _, s := range r.subscriptions {
+		if s.ProductID == productID && s.IsActive() && len(found) < limit {
+			found = append(found, s)
+		}
+	}
+	return found, nil
+}
+
+func (r *memoryStockAlertRepo) ExpireBefore(ctx context.Context, at time.Time) (int64, error) {
+	var expired int64
+	for _, s := range r.subscriptions {
+		if s.IsActive() && s.ExpiresAt.Before(at) {
+			s.Expire()
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+type stockAlertNotifierStub struct {
+	alerts []stockalert.Alert
+	err    error
+}
+
+func (n *stockAlertNotifierStub) BackInStock(ctx context.Context, alert stockalert.Alert) error {
+	if n.err != nil {
+		return n.err
+	}
+	n.alerts = append(n.alerts, alert)
+	return nil
+}
+
+func TestSubscribeStockAlert(t *testing.T) {
+	products := &flashProductRepo{products: map[string]*product.Product{
+		"sold-out": {ID: "sold-out", Status: product.StatusActive},
+		"in-stock": {ID: "in-stock", Stock: 3, Status: product.StatusActive},
+	}}
+	alerts := &memoryStockAlertRepo{}
+	subscribe := commands.NewSubscribeStockAlertCommandHandler(alerts, products, 30*24*time.Hour)
+
+	s, err := subscribe.Handle(context.Background(), commands.SubscribeStockAlertCommand{UserID: "u1", ProductID: "sold-out"})
+	require.NoError(t, err)
+	assert.Equal(t, stockalert.ChannelEmail, s.Channel, "email is the default channel")
+	assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), s.ExpiresAt, time.Minute)
+
+	again, err := subscribe.Handle(context.Background(), commands.SubscribeStockAlertCommand{UserID: "u1", ProductID: "sold-out", Channel: stockalert.ChannelWhatsApp})
+	require.NoError(t, err)
+	assert.Equal(t, s.ID, again.ID, "subscribing again keeps one subscription")
+	assert.Equal(t, stockalert.ChannelWhatsApp, again.Channel)
+	assert.Len(t, alerts.subscriptions, 1)
+
+	_, err = subscribe.Handle(context.Background(), commands.SubscribeStockAlertCommand{UserID: "u1", ProductID: "in-stock"})
+	assert.ErrorIs(t, err, commands.ErrProductInStock)
+	_, err = subscribe.Handle(context.Background(), commands.SubscribeStockAlertCommand{UserID: "u1", ProductID: "missing"})
+	assert.ErrorIs(t, err, commands.ErrProductNotFound)
+
+	cancel := commands.NewCancelStockAlertCommandHandler(alerts)
+	assert.ErrorIs(t, cancel.Handle(context.Background(), commands.CancelStockAlertCommand{UserID: "u2", SubscriptionID: s.ID}), stockalert.ErrNotFound, "only the subscriber can cancel")
+	require.NoError(t, cancel.Handle(context.Background(), commands.CancelStockAlertCommand{UserID: "u1", SubscriptionID: s.ID}))
+	assert.Equal(t, stockalert.StatusCancelled, s.Status)
+}
+
+func TestNotifyBackInStock(t *testing.T) {
+	products := &flashProductRepo{products: map[string]*product.Product{
+		"p1": {ID: "p1", Name: "Espresso Machine", Slug: "espresso-machine", Price: 2500000, Status: product.StatusActive},
+		"p2": {ID: "p2", Name: "Grinder", Status: product.StatusActive},
+	}}
+	users := &deletionUserRepoStub{users: map[string]*user.User{
+		"u1": {ID: "u1", Status: user.StatusActive, Phone: "+62811", Locale: "id"},
+		"u2": {ID: "u2", Status: user.StatusActive},
+		"u3": {ID: "u3", Status: user.StatusDeleted},
+	}}
+	alerts := &memoryStockAlertRepo{}
+	subscribe := func(userID, productID string, channel stockalert.Channel) *stockalert.Subscription {
+		s := stockalert.NewSubscription(userID, productID, channel, time.Hour)
+		alerts.Create(context.Background(), s)
+		return s
+	}
+	first := subscribe("u1", "p1", stockalert.ChannelWhatsApp)
+	second := subscribe("u2", "p1", stockalert.ChannelEmail)
+	gone := subscribe("u3", "p1", stockalert.ChannelEmail)
+	waiting := subscribe("u1", "p2", stockalert.ChannelEmail)
+	stale := subscribe("u2", "p2", stockalert.ChannelEmail)
+	stale.ExpiresAt = time.Now().Add(-time.Minute)
+
+	notifier := &stockAlertNotifierStub{err: errors.New("queue down")}
+	handler := commands.NewNotifyBackInStockCommandHandler(alerts, products, users, notifier, "https://shop.example/")
+
+	sent, err := handler.Handle(context.Background(), commands.NotifyBackInStockCommand{})
+	require.NoError(t, err)
+	assert.Zero(t, sent, "nothing is back in stock yet")
+	assert.Equal(t, stockalert.StatusExpired, stale.Status, "subscriptions that ran out are expired")
+
+	products.products["p1"].Stock = 5
+	_, err = handler.Handle(context.Background(), commands.NotifyBackInStockCommand{})
+	require.Error(t, err)
+	assert.True(t, first.IsActive(), "an alert that could not be queued is retried")
+
+	notifier.err = nil
+	sent, err = handler.Handle(context.Background(), commands.NotifyBackInStockCommand{BatchSize: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	require.Len(t, notifier.alerts, 1)
+	alert := notifier.alerts[0]
+	assert.Equal(t, first.ID, alert.Subscription.ID, "the earliest subscriber hears first")
+	assert.Equal(t, "https://shop.example/products/espresso-machine", alert.ProductURL)
+	assert.Equal(t, "+62811", alert.Phone)
+	assert.Equal(t, stockalert.StatusNotified, first.Status)
+	assert.NotNil(t, first.NotifiedAt)
+	assert.True(t, second.IsActive())
+
+	sent, err = handler.Handle(context.Background(), commands.NotifyBackInStockCommand{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, stockalert.StatusNotified, second.Status)
+	assert.Equal(t, stockalert.StatusExpired, gone.Status, "deleted users are not notified")
+	assert.True(t, waiting.IsActive(), "p2 is still out of stock")
+
+	sent, err = handler.Handle(context.Background(), commands.NotifyBackInStockCommand{})
+	require.NoError(t, err)
+	assert.Zero(t, sent, "subscriptions are notified once")
+}
@@ -0,0 +1,171 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/audit"
+	"online-shop/internal/domain/storefront"
+	"online-shop/pkg/validation"
+)
+
+type storefrontRepoStub struct {
+	saved *storefront.Settings
+	gets  int
+}
+
+func (r *storefrontRepoStub) Get(ctx context.Context) (*storefront.Settings, error) {
+	r.gets++
+	return r.saved, nil
+}
+
+func (r *storefrontRepoStub) Save(ctx context.Context, s *storefront.Settings) error {
+	s.ID = storefront.SettingsID
+	r.saved = s
+	return nil
+}
+
+type storefrontCacheStub struct {
+	cached  *storefront.Settings
+	ttl     time.Duration
+	err     error
+	cleared int
+}
+
+func (c *storefrontCacheStub) Get(ctx context.Context) (*storefront.Settings, error) {
+	return c.cached, c.err
+}
+
+func (c *storefrontCacheStub) Set(ctx context.Context, s *storefront.Settings, ttl time.Duration) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.cached, c.ttl = s, ttl
+	return nil
+}
+
+func (c *storefrontCacheStub) Clear(ctx context.Context) error {
+	c.cached = nil
+	c.cleared++
+	return nil
+}
+
+type storefrontAuditStub struct {
+	audit.Repository
+	entries []*audit.Entry
+}
+
+func (r *storefrontAuditStub) Create(ctx context.Context, entry *audit.Entry) error {
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+func storefrontCommand() commands.UpdateStorefrontSettingsCommand {
+	return commands.UpdateStorefrontSettingsCommand{
+		StoreName:         "Kopi Kita",
+		Theme:             commands.StorefrontThemeCmd{PrimaryColor: "#6f4e37"},
+		Currencies:        []string{"IDR", "USD"},
+		DefaultCurrency:   "IDR",
+		Locales:           []string{"id", "en"},
+		DefaultLocale:     "id",
+		OrderNumberPrefix: "KK",
+		ActorID:           "admin-1",
+	}
+}
+
+func TestStorefrontSettingsDefaultUntilSaved(t *testing.T) {
+	repo := &storefrontRepoStub{}
+	cache := &storefrontCacheStub{}
+	defaults := storefront.Defaults("Online Shop", "IDR", "INV", []string{"en", "id"}, "en")
+	handler := queries.NewGetStorefrontSettingsQueryHandler(repo, cache, defaults, time.Minute)
+
+	settings, err := handler.Handle(context.Background(), queries.GetStorefrontSettingsQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, "Online Shop", settings.StoreName)
+	assert.Equal(t, []string{"IDR"}, settings.Currencies)
+	assert.Equal(t, "en", settings.DefaultLocale)
+	assert.Equal(t, time.Minute, cache.ttl)
+
+	settings.StoreName = "changed"
+	assert.Equal(t, "Online Shop", defaults.StoreName, "callers get a copy of the defaults")
+
+	prefix, err := handler.NumberPrefix(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "INV", prefix)
+	assert.Equal(t, 1, repo.gets, "served from the cache")
+}
+
+func TestStorefrontSettingsServedWhenCacheIsDown(t *testing.T) {
+	repo := &storefrontRepoStub{saved: &storefront.Settings{StoreName: "Kopi Kita", OrderNumberPrefix: "KK"}}
+	cache := &storefrontCacheStub{err: errors.New("redis down")}
+	handler := queries.NewGetStorefrontSettingsQueryHandler(repo, cache, storefront.Defaults("Online Shop", "IDR", "INV", []string{"en"}, "en"), time.Minute)
+
+	prefix, err := handler.NumberPrefix(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "KK", prefix)
+}
+
+func TestUpdateStorefrontSettings(t *testing.T) {
+	repo := &storefrontRepoStub{}
+	cache := &storefrontCacheStub{cached: storefront.Defaults("Online Shop", "IDR", "INV", []string{"en"}, "en")}
+	auditRepo := &storefrontAuditStub{}
+	handler := commands.NewUpdateStorefrontSettingsCommandHandler(repo, cache, auditRepo)
+
+	settings, err := handler.Handle(context.Background(), storefrontCommand())
+	require.NoError(t, err)
+	assert.Equal(t, "KK", repo.saved.OrderNumberPrefix)
+	assert.Equal(t, "admin-1", settings.UpdatedBy)
+	assert.Nil(t, cache.cached, "storefronts read the new settings on their next request")
+	assert.Equal(t, 1, cache.cleared)
+
+	require.Len(t, auditRepo.entries, 1)
+	entry := auditRepo.entries[0]
+	assert.Equal(t, "storefront.update", entry.Action)
+	assert.Equal(t, storefront.SettingsID, entry.ResourceID)
+	assert.Equal(t, settings, entry.Changes["settings"].After)
+
+	get := queries.NewGetStorefrontSettingsQueryHandler(repo, cache, storefront.Defaults("Online Shop", "IDR", "INV", []string{"en"}, "en"), time.Minute)
+	prefix, err := get.NumberPrefix(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "KK", prefix, "new orders take the saved prefix")
+}
+
+func TestUpdateStorefrontSettingsRejectsInconsistentSettings(t *testing.T) {
+	handler := commands.NewUpdateStorefrontSettingsCommandHandler(&storefrontRepoStub{}, &storefrontCacheStub{}, &storefrontAuditStub{})
+
+	cmd := storefrontCommand()
+	cmd.DefaultCurrency = "EUR"
+	_, err := handler.Handle(context.Background(), cmd)
+	assert.ErrorIs(t, err, storefront.ErrInvalidSettings)
+
+	cmd = storefrontCommand()
+	cmd.DefaultLocale = "fr"
+	_, err = handler.Handle(context.Background(), cmd)
+	assert.ErrorIs(t, err, storefront.ErrInvalidSettings)
+
+	cmd = storefrontCommand()
+	cmd.Checkout.RequireTermsAcceptance = true
+	_, err = handler.Handle(context.Background(), cmd)
+	assert.ErrorIs(t, err, storefront.ErrInvalidSettings)
+}
+
+func TestUpdateStorefrontSettingsValidatesFields(t *testing.T) {
+	cmd := storefrontCommand()
+	cmd.Currencies = []string{"IDR", "rupiah"}
+	cmd.Theme.PrimaryColor = "brown"
+	cmd.OrderNumberPrefix = "KK-"
+	cmd.Contact.Email = "not-an-email"
+
+	codes := fieldCodes(t, validation.Struct(cmd))
+	assert.Contains(t, codes, "currencies[1]")
+	assert.Contains(t, codes, "theme.primary_color")
+	assert.Contains(t, codes, "order_number_prefix")
+	assert.Equal(t, validation.CodeInvalidEmail, codes["contact.email"])
+}
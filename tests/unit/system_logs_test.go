@@ -0,0 +1,80 @@
+package unit
+
+import (
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/systemlog"
+	"online-shop/internal/infrastructure/logstore"
+)
+
+func writeLogFixtures(t *testing.T) string {
+	dir := t.TempDir()
+	current := filepath.Join(dir, "app.log")
+
+	require.NoError(t, os.WriteFile(current, []byte(
+		`{"level":"info","msg":"server started","service":"api","time":"2024-05-02T10:00:00Z"}
+not json
+{"level":"error","msg":"payment webhook failed","service":"api","time":"2024-05-02T11:00:00Z","order_id":"o1"}
+{"level":"warn","ts":1714645800.5,"msg":"queue backlog growing","service":"worker"}
+`), 0644))
+
+	f, err := os.Create(filepath.Join(dir, "app-2024-05-01T00-00-00.000.log.gz"))
+	require.NoError(t, err)
+	gz := gzip.NewWriter(f)
+	_, err = gz.Write([]byte(`{"level":"error","msg":"database timeout","time":"2024-05-01T09:00:00Z"}` + "\n"))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	require.NoError(t, f.Close())
+
+	return current
+}
+
+func TestFileReaderFiltersByLevelAndService(t *testing.T) {
+	reader := logstore.NewFileReader(writeLogFixtures(t))
+
+	entries, total, err := reader.Query(context.Background(), systemlog.Filter{Level: "warn"}, 10, 0)
+	require.NoError(t, err)
+
+	require.Equal(t, int64(3), total)
+	assert.Equal(t, "payment webhook failed", entries[0].Message, "newest first")
+	assert.Equal(t, "o1", entries[0].Fields["order_id"])
+	assert.Equal(t, "worker", entries[1].Service)
+	assert.Equal(t, "app", entries[2].Service, "service falls back to the file name")
+	assert.Equal(t, "app-2024-05-01T00-00-00.000.log.gz", entries[2].Source)
+
+	entries, total, err = reader.Query(context.Background(), systemlog.Filter{Service: "api"}, 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Len(t, entries, 2)
+}
+
+func TestFileReaderPaginatesWithinTimeWindow(t *testing.T) {
+	reader := logstore.NewFileReader(writeLogFixtures(t))
+	filter := systemlog.Filter{
+		From: time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC),
+	}
+
+	entries, total, err := reader.Query(context.Background(), filter, 1, 1)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(3), total)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "queue backlog growing", entries[0].Message)
+}
+
+func TestSearchSystemLogsRejectsUnknownLevel(t *testing.T) {
+	handler := queries.NewSearchSystemLogsQueryHandler(logstore.NewFileReader())
+
+	_, err := handler.Handle(context.Background(), queries.SearchSystemLogsQuery{Filter: systemlog.Filter{Level: "loud"}})
+	assert.ErrorIs(t, err, systemlog.ErrInvalidLevel)
+}
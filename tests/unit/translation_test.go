@@ -0,0 +1,100 @@
+package unit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/application/queries"
+	"online-shop/internal/domain/product"
+	"online-shop/pkg/apperror"
+)
+
+type memoryTranslationRepo struct {
+	product.TranslationRepository
+	products   map[string]*product.Translation
+	categories map[string]*product.CategoryTranslation
+}
+
+func newMemoryTranslationRepo() *memoryTranslationRepo {
+	return &memoryTranslationRepo{products: map[string]*product.Translation{}, categories: map[string]*product.CategoryTranslation{}}
+}
+
+func (r *memoryTranslationRepo) SaveProduct(ctx context.Context, t *product.Translation) error {
+	r.products[t.ProductID+"/"+t.Locale] = t
+	return nil
+}
+
+func (r *memoryTranslationRepo) ForProducts(ctx context.Context, locale string, productIDs []string) (map[string]*product.Translation, error) {
+	byID := map[string]*product.Translation{}
+	for _, id := range productIDs {
+		if t, ok := r.products[id+"/"+locale]; ok {
+			byID[id] = t
+		}
+	}
+	return byID, nil
+}
+
+func (r *memoryTranslationRepo) ForCategories(ctx context.Context, locale string, categoryIDs []string) (map[string]*product.CategoryTranslation, error) {
+	byID := map[string]*product.CategoryTranslation{}
+	for _, id := range categoryIDs {
+		if t, ok := r.categories[id+"/"+locale]; ok {
+			byID[id] = t
+		}
+	}
+	return byID, nil
+}
+
+func TestLocalizeCatalogTranslatesProductsAndCategories(t *testing.T) {
+	repo := newMemoryTranslationRepo()
+	repo.products["p1/id"] = product.NewTranslation("p1", "id", "Kaos Katun", "", "admin")
+	repo.categories["c1/id"] = product.NewCategoryTranslation("c1", "id", "Pakaian", "Pakaian pria dan wanita", "admin")
+	handler := queries.NewLocalizeCatalogQueryHandler(repo)
+
+	shirt := &product.Product{ID: "p1", Name: "Cotton Shirt", Description: "A soft shirt", Category: &product.Category{ID: "c1", Name: "Clothing"}}
+	mug := &product.Product{ID: "p2", Name: "Mug", Description: "A mug"}
+	clothing := &product.Category{ID: "c1", Name: "Clothing"}
+
+	err := handler.Handle(context.Background(), queries.LocalizeCatalogQuery{Locale: "id", Products: []*product.Product{shirt, mug}, Categories: []*product.Category{clothing}})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Kaos Katun", shirt.Name)
+	assert.Equal(t, "A soft shirt", shirt.Description, "no translated description keeps the product's")
+	assert.Equal(t, "id", shirt.Locale)
+	assert.Equal(t, "Pakaian", shirt.Category.Name)
+	assert.Equal(t, "Pakaian", clothing.Name)
+	assert.Equal(t, "Pakaian pria dan wanita", clothing.Description)
+
+	assert.Equal(t, "Mug", mug.Name, "untranslated products are served as written")
+	assert.Empty(t, mug.Locale)
+}
+
+func TestLocalizeCatalogLeavesDefaultLocale(t *testing.T) {
+	repo := newMemoryTranslationRepo()
+	repo.products["p1/en"] = product.NewTranslation("p1", "en", "Other", "", "admin")
+	handler := queries.NewLocalizeCatalogQueryHandler(repo)
+
+	shirt := &product.Product{ID: "p1", Name: "Cotton Shirt"}
+	require.NoError(t, handler.Handle(context.Background(), queries.LocalizeCatalogQuery{Locale: "en", Products: []*product.Product{shirt}}))
+	assert.Equal(t, "Cotton Shirt", shirt.Name)
+}
+
+func TestSetProductTranslation(t *testing.T) {
+	repo := newMemoryTranslationRepo()
+	products := &flashProductRepo{products: map[string]*product.Product{"p1": {ID: "p1", Name: "Cotton Shirt"}}}
+	handler := commands.NewSetProductTranslationCommandHandler(products, repo)
+
+	translation, err := handler.Handle(context.Background(), commands.SetProductTranslationCommand{ProductID: "p1", Locale: "id", Name: " Kaos Katun ", UpdatedBy: "admin"})
+	require.NoError(t, err)
+	assert.Equal(t, "Kaos Katun", translation.Name)
+	assert.Same(t, translation, repo.products["p1/id"])
+
+	_, err = handler.Handle(context.Background(), commands.SetProductTranslationCommand{ProductID: "p1", Locale: "en", Name: "Shirt"})
+	assert.Equal(t, commands.ErrDefaultLocaleTranslation.Code, apperror.From(err).Code)
+
+	_, err = handler.Handle(context.Background(), commands.SetProductTranslationCommand{ProductID: "missing", Locale: "id", Name: "Kaos"})
+	assert.Equal(t, commands.ErrProductNotFound.Code, apperror.From(err).Code)
+}
@@ -0,0 +1,151 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/interfaces/http/handlers"
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/validation"
+)
+
+func fieldCodes(t *testing.T, err error) map[string]string {
+	var verr *validation.Error
+	require.ErrorAs(t, err, &verr)
+	codes := map[string]string{}
+	for _, f := range verr.Fields {
+		codes[f.Field] = f.Code
+	}
+	return codes
+}
+
+func TestValidationReportsFieldsByJSONName(t *testing.T) {
+	err := validation.Struct(commands.RegisterUserCommand{
+		Email:     "not-an-email",
+		Password:  "short",
+		FirstName: "   ",
+		Phone:     "call me",
+	})
+
+	assert.Equal(t, map[string]string{
+		"email":      validation.CodeInvalidEmail,
+		"password":   validation.CodeTooShort,
+		"first_name": validation.CodeBlank,
+		"last_name":  validation.CodeRequired,
+		"phone":      validation.CodeInvalidPhone,
+	}, fieldCodes(t, err))
+
+	assert.NoError(t, validation.Struct(commands.RegisterUserCommand{
+		Email:     "jane@example.com",
+		Password:  "correct horse",
+		FirstName: "Jane",
+		LastName:  "Doe",
+		Phone:     "+62 812-3456-7890",
+	}))
+}
+
+func TestValidationDivesIntoNestedFields(t *testing.T) {
+	err := validation.Struct(commands.CreateOrderCommand{
+		UserID: "u1",
+		Items:  []commands.CreateOrderItemCmd{{ProductID: "p1", Quantity: 1}, {Quantity: 0}},
+		ShippingAddress: order.Address{
+			Street:     "Jl. Sudirman 1",
+			City:       "Jakarta",
+			PostalCode: "10220",
+			Country:    "IDN",
+		},
+	})
+
+	assert.Equal(t, map[string]string{
+		"items[1].product_id":      validation.CodeRequired,
+		"items[1].quantity":        validation.CodeRequired,
+		"shipping_address.country": validation.CodeTooShort,
+	}, fieldCodes(t, err))
+
+	err = validation.Struct(commands.CreateOrderCommand{UserID: "u1"})
+	assert.Equal(t, validation.CodeRequired, fieldCodes(t, err)["items"])
+}
+
+func TestValidationMessagesAreLocalized(t *testing.T) {
+	err := validation.Struct(commands.LoginUserCommand{Email: "jane@example.com"})
+	var verr *validation.Error
+	require.ErrorAs(t, err, &verr)
+	require.Len(t, verr.Fields, 1)
+	assert.Equal(t, "password is required", verr.Fields[0].Message)
+
+	assert.Equal(t, "password wajib diisi", verr.Localize("id").Fields[0].Message)
+	assert.Equal(t, "password is required", verr.Localize("fr").Fields[0].Message, "unknown locales fall back to English")
+	assert.Equal(t, "password is required", verr.Fields[0].Message, "localizing returns a copy")
+
+	validation.RegisterMessages("fr", validation.Messages{validation.CodeRequired: "{field} est obligatoire"})
+	assert.Equal(t, "password est obligatoire", verr.Localize("fr").Fields[0].Message)
+
+	assert.Equal(t, "id", validation.Locale("id-ID,id;q=0.9,en;q=0.8"))
+	assert.Equal(t, "en", validation.Locale("de-DE"))
+	assert.Equal(t, "en", validation.Locale(""))
+}
+
+func TestValidationCustomRules(t *testing.T) {
+	require.NoError(t, validation.Default().RegisterRule("even", "not_even", func(value interface{}) bool {
+		n, ok := value.(int)
+		return ok && n%2 == 0
+	}))
+
+	type request struct {
+		Count int    `json:"count" validate:"even"`
+		Slug  string `json:"slug" validate:"slug"`
+	}
+	err := validation.Struct(request{Count: 3, Slug: "Not A Slug"})
+	assert.Equal(t, map[string]string{"count": "not_even", "slug": validation.CodeInvalidSlug}, fieldCodes(t, err))
+	assert.NoError(t, validation.Struct(request{Count: 4, Slug: "summer-sale-2024"}))
+}
+
+func TestHandlersRespondWithFieldErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userHandler := handlers.NewUserHandler(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	router := gin.New()
+	router.POST("/register", userHandler.Register)
+
+	call := func(body string) (int, map[string]interface{}) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept-Language", "id")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, apperror.ProblemContentType, w.Header().Get("Content-Type"))
+
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return w.Code, resp
+	}
+
+	status, resp := call(`{"email": "jane@example.com", "password": "correct horse", "first_name": "Jane"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, apperror.ErrValidationFailed.Code, resp["code"])
+	assert.Equal(t, "/errors/validation_failed", resp["type"])
+	require.Len(t, resp["fields"], 1)
+	field := resp["fields"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "last_name", field["field"])
+	assert.Equal(t, validation.CodeRequired, field["code"])
+	assert.Equal(t, "last_name wajib diisi", field["message"])
+
+	status, resp = call(`{"email": 42}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	field = resp["fields"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "email", field["field"])
+	assert.Equal(t, validation.CodeInvalidType, field["code"])
+
+	status, resp = call(`{"email": `)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, apperror.ErrInvalidRequest.Code, resp["code"])
+}
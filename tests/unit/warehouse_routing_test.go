@@ -0,0 +1,195 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/flashsale"
+	"online-shop/internal/domain/order"
+	"online-shop/internal/domain/product"
+	"online-shop/internal/domain/warehouse"
+	"online-shop/pkg/apperror"
+)
+
+// guardedStockRepo routes on what it last read but only takes stock still
+// on hand, as the stock repository's conditional update does
+type guardedStockRepo struct {
+	estimateStockRepo
+	onHand map[string]int
+}
+
+func (r *guardedStockRepo) AdjustQuantity(ctx context.Context, warehouseID, productID string, delta int) error {
+	key := warehouseID + "/" + productID
+	if r.onHand[key]+delta < 0 {
+		return warehouse.ErrOutOfStock
+	}
+	r.onHand[key] += delta
+	return nil
+}
+
+func newTestWarehouse(id, city string, priority int) *warehouse.Warehouse {
+	return &warehouse.Warehouse{
+		ID:       id,
+		Code:     id,
+		Name:     id,
+		Address:  order.Address{City: city, State: "West Java", Country: "ID"},
+		Priority: priority,
+		Active:   true,
+	}
+}
+
+func TestRoutePrefersSingleWarehouse(t *testing.T) {
+	warehouses := []*warehouse.Warehouse{
+		newTestWarehouse("near", "Bandung", 2),
+		newTestWarehouse("far", "Bekasi", 1),
+	}
+	stock := []*warehouse.Stock{
+		{WarehouseID: "near", ProductID: "p1", Quantity: 5},
+		{WarehouseID: "far", ProductID: "p1", Quantity: 5},
+		{WarehouseID: "far", ProductID: "p2", Quantity: 5},
+	}
+	lines := []warehouse.Line{{ProductID: "p1", Quantity: 2}, {ProductID: "p2", Quantity: 1}}
+	dest := order.Address{City: "Bandung", State: "West Java", Country: "ID"}
+
+	allocations, err := warehouse.Route(lines, warehouses, stock, dest)
+	require.NoError(t, err)
+	require.Len(t, allocations, 2)
+	for _, a := range allocations {
+		assert.Equal(t, "far", a.WarehouseID)
+	}
+}
+
+func TestRouteSplitsAcrossWarehouses(t *testing.T) {
+	warehouses := []*warehouse.Warehouse{
+		newTestWarehouse("near", "Bandung", 1),
+		newTestWarehouse("far", "Bekasi", 1),
+	}
+	stock := []*warehouse.Stock{
+		{WarehouseID: "near", ProductID: "p1", Quantity: 3, Reserved: 1},
+		{WarehouseID: "far", ProductID: "p1", Quantity: 10},
+	}
+	lines := []warehouse.Line{{ProductID: "p1", Quantity: 5}}
+	dest := order.Address{City: "Bandung", State: "West Java", Country: "ID"}
+
+	allocations, err := warehouse.Route(lines, warehouses, stock, dest)
+	require.NoError(t, err)
+	require.Len(t, allocations, 1)
+	assert.Equal(t, "far", allocations[0].WarehouseID)
+
+	stock[1].Quantity = 4
+	allocations, err = warehouse.Route(lines, warehouses, stock, dest)
+	require.NoError(t, err)
+	require.Len(t, allocations, 2)
+	assert.Equal(t, warehouse.Allocation{ProductID: "p1", WarehouseID: "near", Quantity: 2}, allocations[0])
+	assert.Equal(t, warehouse.Allocation{ProductID: "p1", WarehouseID: "far", Quantity: 3}, allocations[1])
+}
+
+func TestRouteSumsLinesOfTheSameProduct(t *testing.T) {
+	warehouses := []*warehouse.Warehouse{
+		newTestWarehouse("near", "Bandung", 1),
+		newTestWarehouse("far", "Bekasi", 1),
+	}
+	stock := []*warehouse.Stock{
+		{WarehouseID: "near", ProductID: "p1", Quantity: 3},
+		{WarehouseID: "far", ProductID: "p1", Quantity: 5},
+	}
+	lines := []warehouse.Line{{ProductID: "p1", Quantity: 2}, {ProductID: "p1", Quantity: 2}}
+	dest := order.Address{City: "Bandung", State: "West Java", Country: "ID"}
+
+	allocations, err := warehouse.Route(lines, warehouses, stock, dest)
+	require.NoError(t, err)
+	require.Len(t, allocations, 2)
+	for _, a := range allocations {
+		assert.Equal(t, "far", a.WarehouseID)
+	}
+}
+
+func TestRouteInsufficientStock(t *testing.T) {
+	warehouses := []*warehouse.Warehouse{newTestWarehouse("w1", "Bandung", 1)}
+	stock := []*warehouse.Stock{{WarehouseID: "w1", ProductID: "p1", Quantity: 1}}
+	lines := []warehouse.Line{{ProductID: "p1", Quantity: 2}}
+
+	_, err := warehouse.Route(lines, warehouses, stock, order.Address{})
+	assert.ErrorIs(t, err, warehouse.ErrCannotFulfill)
+}
+
+func TestAssignWarehousesSplitsShipments(t *testing.T) {
+	o := &order.Order{
+		ID: "order-1",
+		Items: []order.OrderItem{
+			{ID: "item-1", ProductID: "p1", Quantity: 5, Price: 10, Subtotal: 50},
+		},
+	}
+
+	o.AssignWarehouses([]order.WarehouseAllocation{
+		{ProductID: "p1", WarehouseID: "w1", Quantity: 2},
+		{ProductID: "p1", WarehouseID: "w2", Quantity: 3},
+	})
+
+	require.Len(t, o.Items, 2)
+	assert.Equal(t, 20.0, o.Items[0].Subtotal)
+	assert.Equal(t, 30.0, o.Items[1].Subtotal)
+	assert.True(t, o.IsSplitShipment())
+	assert.NotEqual(t, o.Items[0].ShipmentID, o.Items[1].ShipmentID)
+}
+
+func TestOrderFailsWhenRoutedStockIsTaken(t *testing.T) {
+	products := &flashProductRepo{products: map[string]*product.Product{
+		"p1": {ID: "p1", Price: 100, Stock: 10, Status: product.StatusActive},
+	}}
+	orders := &flashOrderRepo{orders: map[string]*order.Order{}}
+	warehouses := &estimateWarehouseRepo{warehouses: []*warehouse.Warehouse{newTestWarehouse("w1", "Bandung", 1)}}
+	stock := &guardedStockRepo{
+		estimateStockRepo: estimateStockRepo{stock: []*warehouse.Stock{{WarehouseID: "w1", ProductID: "p1", Quantity: 5}}},
+		onHand:            map[string]int{"w1/p1": 5},
+	}
+	create := commands.NewCreateOrderCommandHandler(orders, products, noCommissionRepo{}, warehouses, stock, &flashSaleRepoStub{}, newMemoryFlashCounter(), commands.CreateOrderOptions{})
+	cmd := commands.CreateOrderCommand{
+		UserID:          "u1",
+		Items:           []commands.CreateOrderItemCmd{{ProductID: "p1", Quantity: 3}},
+		ShippingAddress: order.Address{Street: "Jl. Asia Afrika 8", City: "Bandung", PostalCode: "40111", Country: "ID"},
+	}
+
+	_, err := create.Handle(context.Background(), cmd)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stock.onHand["w1/p1"])
+
+	// The read still shows 5, but the first order took 3 of them
+	_, err = create.Handle(context.Background(), cmd)
+	assert.Equal(t, commands.ErrInsufficientStock.Code, apperror.From(err).Code)
+	assert.Equal(t, 2, stock.onHand["w1/p1"], "no warehouse is taken below zero")
+}
+
+func TestOrderFailingAfterReservingGivesFlashSaleUnitsBack(t *testing.T) {
+	now := time.Now()
+	sale, err := flashsale.NewSale("Midnight sale", now.Add(-time.Minute), now.Add(time.Hour), []flashsale.Item{
+		{ProductID: "p1", SalePrice: 60, Quantity: 3, PerUserLimit: 2},
+	})
+	require.NoError(t, err)
+
+	products := &flashProductRepo{products: map[string]*product.Product{
+		"p1": {ID: "p1", Price: 100, Stock: 10, Status: product.StatusActive},
+	}}
+	warehouses := &estimateWarehouseRepo{warehouses: []*warehouse.Warehouse{newTestWarehouse("w1", "Bandung", 1)}}
+	// The read shows 5, but another order has taken them all
+	stock := &guardedStockRepo{
+		estimateStockRepo: estimateStockRepo{stock: []*warehouse.Stock{{WarehouseID: "w1", ProductID: "p1", Quantity: 5}}},
+		onHand:            map[string]int{"w1/p1": 0},
+	}
+	counter := newMemoryFlashCounter()
+	create := commands.NewCreateOrderCommandHandler(&flashOrderRepo{orders: map[string]*order.Order{}}, products, noCommissionRepo{}, warehouses, stock, &flashSaleRepoStub{sales: []*flashsale.Sale{sale}}, counter, commands.CreateOrderOptions{})
+
+	_, err = create.Handle(context.Background(), commands.CreateOrderCommand{
+		UserID:          "u1",
+		Items:           []commands.CreateOrderItemCmd{{ProductID: "p1", Quantity: 2}},
+		ShippingAddress: order.Address{Street: "Jl. Asia Afrika 8", City: "Bandung", PostalCode: "40111", Country: "ID"},
+	})
+	assert.Equal(t, commands.ErrInsufficientStock.Code, apperror.From(err).Code)
+	assert.Equal(t, 0, counter.sold[sale.ID+":p1"], "the sale's allocation is given back")
+	assert.Equal(t, 0, counter.user[sale.ID+":p1:u1"], "and so is the customer's limit")
+}
@@ -0,0 +1,259 @@
+package unit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/internal/application/commands"
+	"online-shop/internal/domain/whatsapp"
+	whatsappprovider "online-shop/internal/infrastructure/whatsapp"
+	"online-shop/pkg/apperror"
+	"online-shop/pkg/config"
+)
+
+type memoryWhatsAppTemplateRepo struct {
+	templates map[string]*whatsapp.Template
+}
+
+func newMemoryWhatsAppTemplateRepo() *memoryWhatsAppTemplateRepo {
+	return &memoryWhatsAppTemplateRepo{templates: map[string]*whatsapp.Template{}}
+}
+
+func (r *memoryWhatsAppTemplateRepo) Save(ctx context.Context, t *whatsapp.Template) error {
+	r.templates[t.Name+"/"+t.Language] = t
+	return nil
+}
+
+func (r *memoryWhatsAppTemplateRepo) Get(ctx context.Context, name, language string) (*whatsapp.Template, error) {
+	t, ok := r.templates[name+"/"+language]
+	if !ok {
+		return nil, whatsapp.ErrTemplateNotFound
+	}
+	return t, nil
+}
+
+func (r *memoryWhatsAppTemplateRepo) List(ctx context.Context, limit, offset int) ([]*whatsapp.Template, error) {
+	var list []*whatsapp.Template
+	for _, t := range r.templates {
+		list = append(list, t)
+	}
+	return list, nil
+}
+
+func (r *memoryWhatsAppTemplateRepo) SetStatus(ctx context.Context, s whatsapp.TemplateStatus) error {
+	t, ok := r.templates[s.Name+"/"+s.Language]
+	if !ok {
+		return whatsapp.ErrTemplateNotFound
+	}
+	t.Status, t.RejectedReason = s.Status, s.RejectedReason
+	return nil
+}
+
+func (r *memoryWhatsAppTemplateRepo) Delete(ctx context.Context, name string) error {
+	for key, t := range r.templates {
+		if t.Name == name {
+			delete(r.templates, key)
+		}
+	}
+	return nil
+}
+
+type memoryWhatsAppMessageRepo struct {
+	messages map[string]*whatsapp.Message
+}
+
+func (r *memoryWhatsAppMessageRepo) Create(ctx context.Context, m *whatsapp.Message) error {
+	r.messages[m.ID] = m
+	return nil
+}
+
+func (r *memoryWhatsAppMessageRepo) Get(ctx context.Context, id string) (*whatsapp.Message, error) {
+	m, ok := r.messages[id]
+	if !ok {
+		return nil, whatsapp.ErrMessageNotFound
+	}
+	copied := *m
+	return &copied, nil
+}
+
+func (r *memoryWhatsAppMessageRepo) List(ctx context.Context, limit, offset int) ([]*whatsapp.Message, error) {
+	var list []*whatsapp.Message
+	for _, m := range r.messages {
+		list = append(list, m)
+	}
+	return list, nil
+}
+
+func (r *memoryWhatsAppMessageRepo) Update(ctx context.Context, m *whatsapp.Message) error {
+	r.messages[m.ID] = m
+	return nil
+}
+
+func orderShippedTemplate(t *testing.T, language string) *whatsapp.Template {
+	template, err := whatsapp.NewTemplate("order_shipped", language, whatsapp.CategoryUtility,
+		"Halo {{1}}, pesanan {{2}} sudah dikirim.",
+		[]whatsapp.Parameter{{Name: "FirstName", Example: "Budi"}, {Name: "OrderNumber", Example: "ORD-1001"}})
+	require.NoError(t, err)
+	return template
+}
+
+func TestWhatsAppTemplateValidation(t *testing.T) {
+	_, err := whatsapp.NewTemplate("Order Shipped", "id", whatsapp.CategoryUtility, "Halo", nil)
+	assert.ErrorIs(t, err, whatsapp.ErrInvalidTemplate)
+
+	_, err = whatsapp.NewTemplate("order_shipped", "id", "PROMO", "Halo", nil)
+	assert.ErrorIs(t, err, whatsapp.ErrInvalidTemplate)
+
+	_, err = whatsapp.NewTemplate("order_shipped", "id", whatsapp.CategoryUtility, "Halo {{1}}, pesanan {{3}}",
+		[]whatsapp.Parameter{{Name: "FirstName", Example: "Budi"}, {Name: "OrderNumber", Example: "ORD-1001"}})
+	assert.Equal(t, commands.ErrInvalidWhatsAppTemplate.Code, apperror.From(err).Code)
+	assert.Contains(t, apperror.From(err).Detail, "{{2}}")
+
+	template := orderShippedTemplate(t, "id")
+	assert.Equal(t, whatsapp.StatusPending, template.Status)
+	values, err := template.Values(map[string]interface{}{"FirstName": "Ani", "OrderNumber": "ORD-7"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Ani", "ORD-7"}, values)
+
+	assert.Equal(t, "6281234567890", whatsapp.NormalizePhone("0812-3456-7890"))
+	assert.Equal(t, "6281234567890", whatsapp.NormalizePhone("+62 812 3456 7890"))
+}
+
+func TestWhatsAppSenderUsesApprovedTemplates(t *testing.T) {
+	var sent map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v19.0/phone-1/messages", r.URL.Path)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&sent))
+		w.Write([]byte(`{"messages":[{"id":"wamid.1"}]}`))
+	}))
+	defer server.Close()
+
+	templates := newMemoryWhatsAppTemplateRepo()
+	messages := &memoryWhatsAppMessageRepo{messages: map[string]*whatsapp.Message{}}
+	client := whatsappprovider.NewCloudAPI(&config.WhatsAppConfig{PhoneNumberID: "phone-1", AccessToken: "token", Endpoint: server.URL})
+	sender := whatsapp.NewSender(client, templates, messages, &memoryConsentRepo{})
+
+	notification := whatsapp.Notification{
+		UserID: "user-1",
+		Type:   "order_shipped",
+		Phone:  "0812 3456 7890",
+		Locale: "id",
+		Data:   map[string]interface{}{"FirstName": "Budi", "OrderNumber": "ORD-1001"},
+	}
+	_, err := sender.Send(context.Background(), notification)
+	assert.ErrorIs(t, err, whatsapp.ErrTemplateNotFound)
+
+	english := orderShippedTemplate(t, "en")
+	require.NoError(t, templates.Save(context.Background(), english))
+	_, err = sender.Send(context.Background(), notification)
+	assert.Equal(t, commands.ErrWhatsAppTemplateNotApproved.Code, apperror.From(err).Code)
+
+	english.Status = whatsapp.StatusApproved
+	message, err := sender.Send(context.Background(), notification)
+	require.NoError(t, err)
+	assert.Equal(t, "wamid.1", message.ID)
+	assert.Equal(t, "en", message.Language, "falls back to the default language")
+	assert.Equal(t, whatsapp.MessageSent, messages.messages["wamid.1"].Status)
+	assert.Equal(t, "6281234567890", sent["to"])
+	template := sent["template"].(map[string]interface{})
+	assert.Equal(t, "order_shipped", template["name"])
+	parameters := template["components"].([]interface{})[0].(map[string]interface{})["parameters"].([]interface{})
+	assert.Equal(t, "ORD-1001", parameters[1].(map[string]interface{})["text"])
+
+	notification.Phone = ""
+	_, err = sender.Send(context.Background(), notification)
+	assert.ErrorIs(t, err, whatsapp.ErrNoPhone)
+}
+
+func signWhatsAppBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestIngestWhatsAppWebhook(t *testing.T) {
+	cloud := whatsappprovider.NewCloudAPI(&config.WhatsAppConfig{AppSecret: "app-secret", VerifyToken: "verify"})
+	templates := newMemoryWhatsAppTemplateRepo()
+	require.NoError(t, templates.Save(context.Background(), orderShippedTemplate(t, "id")))
+	messages := &memoryWhatsAppMessageRepo{messages: map[string]*whatsapp.Message{
+		"wamid.1": {ID: "wamid.1", Status: whatsapp.MessageSent},
+	}}
+	ingest := commands.NewIngestWhatsAppWebhookCommandHandler(cloud, templates, messages)
+
+	challenge, err := cloud.Challenge("subscribe", "verify", "12345")
+	require.NoError(t, err)
+	assert.Equal(t, "12345", challenge)
+	_, err = cloud.Challenge("subscribe", "guess", "12345")
+	assert.ErrorIs(t, err, whatsapp.ErrInvalidWebhook)
+
+	body := []byte(`{"object":"whatsapp_business_account","entry":[{"id":"waba-1","changes":[` +
+		`{"field":"messages","value":{"statuses":[` +
+		`{"id":"wamid.1","status":"read","timestamp":"1700000010","recipient_id":"6281234567890"},` +
+		`{"id":"wamid.1","status":"delivered","timestamp":"1700000005","recipient_id":"6281234567890"},` +
+		`{"id":"wamid.other","status":"delivered","timestamp":"1700000005"}]}},` +
+		`{"field":"message_template_status_update","value":{"event":"APPROVED","message_template_id":987,` +
+		`"message_template_name":"order_shipped","message_template_language":"id","reason":"NONE"}}]}]}`)
+
+	result, err := ingest.Handle(context.Background(), commands.IngestWhatsAppWebhookCommand{Signature: signWhatsAppBody("app-secret", body), Body: body})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Messages, "the late delivered status does not undo read")
+	assert.Equal(t, 1, result.Templates)
+	assert.Equal(t, whatsapp.MessageRead, messages.messages["wamid.1"].Status)
+	template, err := templates.Get(context.Background(), "order_shipped", "id")
+	require.NoError(t, err)
+	assert.Equal(t, whatsapp.StatusApproved, template.Status)
+
+	_, err = ingest.Handle(context.Background(), commands.IngestWhatsAppWebhookCommand{Signature: signWhatsAppBody("forged", body), Body: body})
+	assert.Equal(t, commands.ErrInvalidWhatsAppWebhook.Code, apperror.From(err).Code)
+}
+
+func TestCreateWhatsAppTemplate(t *testing.T) {
+	reject := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v19.0/waba-1/message_templates", r.URL.Path)
+		if reject {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"message":"Template name already exists","code":100}}`))
+			return
+		}
+		var submitted map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&submitted))
+		assert.Equal(t, "UTILITY", submitted["category"])
+		w.Write([]byte(`{"id":"987","status":"PENDING","category":"UTILITY"}`))
+	}))
+	defer server.Close()
+
+	client := whatsappprovider.NewCloudAPI(&config.WhatsAppConfig{BusinessAccountID: "waba-1", AccessToken: "token", Endpoint: server.URL})
+	templates := newMemoryWhatsAppTemplateRepo()
+	create := commands.NewCreateWhatsAppTemplateCommandHandler(client, templates)
+
+	cmd := commands.CreateWhatsAppTemplateCommand{
+		Name:       "order_shipped",
+		Language:   "id",
+		Category:   whatsapp.CategoryUtility,
+		Body:       "Halo {{1}}, pesanan {{2}} sudah dikirim.",
+		Parameters: []whatsapp.Parameter{{Name: "FirstName", Example: "Budi"}, {Name: "OrderNumber", Example: "ORD-1001"}},
+	}
+	template, err := create.Handle(context.Background(), cmd)
+	require.NoError(t, err)
+	assert.Equal(t, "987", template.ProviderID)
+	assert.Equal(t, whatsapp.StatusPending, template.Status)
+
+	reject = true
+	_, err = create.Handle(context.Background(), cmd)
+	assert.Equal(t, commands.ErrWhatsAppRejected.Code, apperror.From(err).Code)
+	assert.Contains(t, apperror.From(err).Detail, "already exists")
+
+	_, err = commands.NewCreateWhatsAppTemplateCommandHandler(whatsapp.DisabledClient{}, templates).Handle(context.Background(), cmd)
+	assert.Equal(t, commands.ErrWhatsAppDisabled.Code, apperror.From(err).Code)
+}
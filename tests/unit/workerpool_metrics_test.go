@@ -0,0 +1,90 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/pkg/workerpool"
+)
+
+// gathered returns the value of a default registry sample with the labels
+func gathered(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if want, ok := labels[label.GetName()]; ok && want != label.GetValue() {
+					continue metrics
+				}
+			}
+			switch {
+			case metric.GetCounter() != nil:
+				return metric.GetCounter().GetValue()
+			case metric.GetGauge() != nil:
+				return metric.GetGauge().GetValue()
+			case metric.GetHistogram() != nil:
+				return float64(metric.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	return 0
+}
+
+func TestPoolExportsMetrics(t *testing.T) {
+	labels := map[string]string{"pool": "metrics_test", "type": "invoice"}
+	succeeded := map[string]string{"pool": "metrics_test", "type": "invoice", "outcome": "succeeded"}
+	failed := map[string]string{"pool": "metrics_test", "type": "invoice", "outcome": "failed"}
+	// The metrics are the process's, so only their growth is checked
+	before := map[string]float64{
+		"submitted": gathered(t, "worker_pool_jobs_submitted_total", labels),
+		"duration":  gathered(t, "worker_pool_job_duration_seconds", labels),
+		"succeeded": gathered(t, "worker_pool_jobs_total", succeeded),
+		"failed":    gathered(t, "worker_pool_jobs_total", failed),
+	}
+
+	pool := newSchedulingPool(workerpool.PoolConfig{Name: "metrics_test", MaxWorkers: 1})
+
+	job := func(err error) *funcJob {
+		return &funcJob{BaseJob: workerpool.BaseJob{Type: "invoice"}, run: func(ctx context.Context) error { return err }}
+	}
+	require.NoError(t, pool.Submit(job(nil)))
+	require.NoError(t, pool.Submit(job(nil)))
+	require.NoError(t, pool.Submit(job(errors.New("pdf renderer crashed"))))
+	waitForProcessed(t, pool, 3)
+
+	assert.Equal(t, 3.0, gathered(t, "worker_pool_jobs_submitted_total", labels)-before["submitted"])
+	assert.Equal(t, 3.0, gathered(t, "worker_pool_job_duration_seconds", labels)-before["duration"], "every job run is observed by type")
+	assert.Equal(t, 2.0, gathered(t, "worker_pool_jobs_total", succeeded)-before["succeeded"])
+	assert.Equal(t, 1.0, gathered(t, "worker_pool_jobs_total", failed)-before["failed"])
+	assert.Equal(t, 0.0, gathered(t, "worker_pool_queue_depth", map[string]string{"pool": "metrics_test"}))
+	assert.Equal(t, 1.0, gathered(t, "worker_pool_workers", map[string]string{"pool": "metrics_test"}))
+
+	var state *workerpool.PoolState
+	for _, s := range workerpool.States() {
+		if s.Name == "metrics_test" {
+			s := s
+			state = &s
+		}
+	}
+	require.NotNil(t, state, "a running pool is listed")
+	assert.Equal(t, int64(3), state.JobsProcessed)
+	assert.Equal(t, int64(1), state.JobsFailed)
+	assert.NotNil(t, state.LastJobAt)
+
+	pool.Stop()
+	for _, s := range workerpool.States() {
+		assert.NotEqual(t, "metrics_test", s.Name, "a stopped pool is not listed")
+	}
+}
@@ -0,0 +1,153 @@
+package unit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/pkg/workerpool"
+)
+
+type orderedJob struct {
+	funcJob
+	priority int
+}
+
+func (j *orderedJob) GetPriority() int {
+	return j.priority
+}
+
+// jobLog records the order jobs ran in
+type jobLog struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+func (l *jobLog) job(id string, priority int) *orderedJob {
+	var cancelled int32
+	return &orderedJob{priority: priority, funcJob: funcJob{
+		BaseJob:   workerpool.BaseJob{ID: id},
+		cancelled: &cancelled,
+		run: func(ctx context.Context) error {
+			l.mu.Lock()
+			l.ids = append(l.ids, id)
+			l.mu.Unlock()
+			return nil
+		},
+	}}
+}
+
+func (l *jobLog) ran() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.ids...)
+}
+
+// occupy keeps a worker busy until the returned func is called
+func occupy(t *testing.T, pool *workerpool.WorkerPool, priority int) func() {
+	t.Helper()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var cancelled int32
+	require.NoError(t, pool.Submit(&orderedJob{priority: priority, funcJob: funcJob{
+		BaseJob:   workerpool.BaseJob{ID: "busy"},
+		cancelled: &cancelled,
+		run: func(ctx context.Context) error {
+			close(started)
+			<-release
+			return nil
+		},
+	}}))
+	<-started
+	return func() { close(release) }
+}
+
+func newSchedulingPool(config workerpool.PoolConfig) *workerpool.WorkerPool {
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	config.Logger = logger
+	if config.MaxQueue == 0 {
+		config.MaxQueue = 20
+	}
+	pool := workerpool.NewWorkerPool(config)
+	pool.Start(context.Background())
+	return pool
+}
+
+func TestPoolRunsHighestPriorityFirst(t *testing.T) {
+	pool := newSchedulingPool(workerpool.PoolConfig{MaxWorkers: 1})
+	defer pool.Stop()
+
+	log := &jobLog{}
+	release := occupy(t, pool, 0)
+	require.NoError(t, pool.Submit(log.job("newsletter", 0)))
+	require.NoError(t, pool.Submit(log.job("receipt", 5)))
+	require.NoError(t, pool.Submit(log.job("reminder", 3)))
+	require.NoError(t, pool.Submit(log.job("password-reset", 5)))
+	release()
+
+	waitForProcessed(t, pool, 5)
+	assert.Equal(t, []string{"receipt", "password-reset", "reminder", "newsletter"}, log.ran(),
+		"first come first served within a priority")
+}
+
+func TestPoolDelaysJobs(t *testing.T) {
+	pool := newSchedulingPool(workerpool.PoolConfig{MaxWorkers: 1})
+	defer pool.Stop()
+
+	log := &jobLog{}
+	submitted := time.Now()
+	require.NoError(t, pool.SubmitAfter(log.job("later", 9), 100*time.Millisecond))
+	scheduled := log.job("scheduled", 9)
+	scheduled.RunAfter = submitted.Add(50 * time.Millisecond)
+	require.NoError(t, pool.Submit(scheduled))
+	require.NoError(t, pool.Submit(log.job("now", 0)))
+	assert.Equal(t, 3, pool.GetQueueSize(), "delayed jobs are queued")
+
+	waitForProcessed(t, pool, 3)
+	assert.Equal(t, []string{"now", "scheduled", "later"}, log.ran())
+	assert.GreaterOrEqual(t, time.Since(submitted), 100*time.Millisecond)
+}
+
+func TestPoolCapsBulkJobs(t *testing.T) {
+	pool := newSchedulingPool(workerpool.PoolConfig{MaxWorkers: 2, MaxBulkWorkers: 1, MaxBulkQueued: 2})
+	defer pool.Stop()
+
+	release := occupy(t, pool, 0)
+	log := &jobLog{}
+	require.NoError(t, pool.Submit(log.job("campaign-1", 0)))
+	require.NoError(t, pool.Submit(log.job("campaign-2", 0)))
+	assert.ErrorIs(t, pool.Submit(log.job("campaign-3", 0)), workerpool.ErrQueueFull,
+		"bulk jobs only take their share of the queue")
+
+	require.NoError(t, pool.Submit(log.job("receipt", 5)))
+	require.Eventually(t, func() bool { return len(log.ran()) == 1 }, time.Second, 5*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, []string{"receipt"}, log.ran(), "the second worker does not take bulk jobs while one runs")
+
+	release()
+	waitForProcessed(t, pool, 4)
+	assert.Equal(t, []string{"receipt", "campaign-1", "campaign-2"}, log.ran())
+}
+
+func TestPoolAgesWaitingJobs(t *testing.T) {
+	pool := newSchedulingPool(workerpool.PoolConfig{MaxWorkers: 1, PriorityAging: 10 * time.Millisecond})
+	defer pool.Stop()
+
+	release := occupy(t, pool, 0)
+	log := &jobLog{}
+	require.NoError(t, pool.Submit(log.job("campaign", 0)))
+	time.Sleep(60 * time.Millisecond)
+	require.NoError(t, pool.Submit(log.job("reminder", 3)))
+	require.NoError(t, pool.Submit(log.job("receipt", 10)))
+	release()
+
+	waitForProcessed(t, pool, 4)
+	assert.Equal(t, []string{"receipt", "campaign", "reminder"}, log.ran(),
+		"a campaign that waited six intervals goes before a newer priority 3 job")
+}
@@ -0,0 +1,144 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"online-shop/pkg/workerpool"
+)
+
+type funcJob struct {
+	workerpool.BaseJob
+	run       func(ctx context.Context) error
+	cancelled *int32
+}
+
+func (j *funcJob) Execute(ctx context.Context) error {
+	return j.run(ctx)
+}
+
+func (j *funcJob) Cancel(err error) {
+	if errors.Is(err, workerpool.ErrPoolStopped) {
+		atomic.AddInt32(j.cancelled, 1)
+	}
+}
+
+func newTestPool(workers int, timeout time.Duration) *workerpool.WorkerPool {
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	return workerpool.NewWorkerPool(workerpool.PoolConfig{MaxWorkers: workers, MaxQueue: 10, JobTimeout: timeout, Logger: logger})
+}
+
+func waitForProcessed(t *testing.T, pool *workerpool.WorkerPool, n int64) workerpool.PoolMetrics {
+	t.Helper()
+	require.Eventually(t, func() bool { return pool.GetMetrics().JobsProcessed >= n }, 3*time.Second, 10*time.Millisecond)
+	return pool.GetMetrics()
+}
+
+func TestRunRecoversPanics(t *testing.T) {
+	err := workerpool.Run(context.Background(), &funcJob{run: func(ctx context.Context) error {
+		panic("nil map")
+	}})
+
+	var panicErr *workerpool.PanicError
+	require.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, "nil map", panicErr.Value)
+	assert.NotEmpty(t, panicErr.Stack)
+}
+
+func TestPoolSurvivesPanickingJob(t *testing.T) {
+	pool := newTestPool(1, 0)
+	pool.Start(context.Background())
+	defer pool.Stop()
+
+	require.NoError(t, pool.Submit(&funcJob{BaseJob: workerpool.BaseJob{ID: "1"}, run: func(ctx context.Context) error {
+		panic("boom")
+	}}))
+	require.NoError(t, pool.Submit(&funcJob{BaseJob: workerpool.BaseJob{ID: "2"}, run: func(ctx context.Context) error {
+		return nil
+	}}))
+
+	metrics := waitForProcessed(t, pool, 2)
+	assert.Equal(t, int64(1), metrics.JobsFailed)
+	assert.Equal(t, int64(1), metrics.JobsPanicked, "the worker keeps running after a panic")
+}
+
+func TestPoolTimesOutJobs(t *testing.T) {
+	pool := newTestPool(1, 50*time.Millisecond)
+	pool.Start(context.Background())
+	defer pool.Stop()
+
+	var deadline atomic.Value
+	require.NoError(t, pool.Submit(&funcJob{BaseJob: workerpool.BaseJob{ID: "1"}, run: func(ctx context.Context) error {
+		<-ctx.Done()
+		deadline.Store(ctx.Err())
+		return ctx.Err()
+	}}))
+	// A job that ignores its context is waited for, and times out only
+	// when it fails: the worker takes nothing else while it runs
+	var running, finished int32
+	require.NoError(t, pool.Submit(&funcJob{BaseJob: workerpool.BaseJob{ID: "2"}, run: func(ctx context.Context) error {
+		atomic.AddInt32(&running, 1)
+		time.Sleep(150 * time.Millisecond)
+		atomic.AddInt32(&finished, 1)
+		return errors.New("smtp: connection reset")
+	}}))
+	require.NoError(t, pool.Submit(&funcJob{BaseJob: workerpool.BaseJob{ID: "3", Timeout: time.Second}, run: func(ctx context.Context) error {
+		assert.Equal(t, atomic.LoadInt32(&running), atomic.LoadInt32(&finished))
+		time.Sleep(100 * time.Millisecond)
+		return nil
+	}}))
+
+	metrics := waitForProcessed(t, pool, 3)
+	assert.Equal(t, int64(2), metrics.JobsTimedOut)
+	assert.Equal(t, int64(2), metrics.JobsFailed, "a job's own timeout overrides the pool's")
+	assert.Equal(t, context.DeadlineExceeded, deadline.Load())
+}
+
+func TestStopCancelsQueuedJobs(t *testing.T) {
+	pool := newTestPool(1, 0)
+	pool.Start(context.Background())
+
+	var cancelled int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	require.NoError(t, pool.Submit(&funcJob{BaseJob: workerpool.BaseJob{ID: "running"}, cancelled: &cancelled, run: func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}}))
+	<-started
+
+	var ran int32
+	for _, id := range []string{"1", "2", "3"} {
+		require.NoError(t, pool.Submit(&funcJob{BaseJob: workerpool.BaseJob{ID: id}, cancelled: &cancelled, run: func(ctx context.Context) error {
+			atomic.AddInt32(&ran, 1)
+			return nil
+		}}))
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		pool.Stop()
+		close(stopped)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	<-stopped
+
+	metrics := pool.GetMetrics()
+	assert.Equal(t, int64(1), metrics.JobsProcessed, "the running job finishes")
+	assert.Equal(t, int32(0), atomic.LoadInt32(&ran))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&cancelled))
+	assert.Equal(t, int64(3), metrics.JobsCancelled)
+	assert.Equal(t, int64(0), metrics.JobsInQueue)
+
+	assert.ErrorIs(t, pool.Submit(&funcJob{run: func(ctx context.Context) error { return nil }}), workerpool.ErrPoolStopped)
+}